@@ -6,7 +6,6 @@ import (
 	"log"
 	"net/http"
 	"path/filepath"
-	"strconv"
 	"strings"
 	"time"
 
@@ -56,6 +55,32 @@ func (c *ActionsController) Setup(app *application.App) {
 	http.Handle("POST /repos/{id}/actions/{actionID}/enable", app.ProtectFunc(c.enableAction, AdminOnly()))
 	// Artifact download - public repos or admin
 	http.Handle("GET /repos/{id}/actions/{actionID}/artifacts/{artifactID}/download", app.ProtectFunc(c.downloadArtifact, PublicOrAdmin()))
+
+	// Workflows defined in .skyscape/workflows - view on public repos or as admin
+	http.Handle("GET /repos/{id}/workflows", app.Serve("repo-workflows.html", PublicOrAdmin()))
+	http.Handle("GET /repos/{id}/workflows/runs/{runID}", app.Serve("repo-workflow-run.html", PublicOrAdmin()))
+	http.Handle("GET /repos/{id}/workflows/runs/{runID}/events", app.ProtectFunc(c.streamWorkflowRun, PublicOrAdmin()))
+	http.Handle("GET /repos/{id}/workflows/runs/{runID}/artifacts/{artifactID}", app.ProtectFunc(c.downloadWorkflowArtifact, PublicOrAdmin()))
+	// Workflow operations - admin only
+	http.Handle("POST /repos/{id}/workflows/dispatch", app.ProtectFunc(c.dispatchWorkflow, AdminOnly()))
+	http.Handle("POST /repos/{id}/workflows/runs/{runID}/cancel", app.ProtectFunc(c.cancelWorkflowRun, AdminOnly()))
+	http.Handle("POST /repos/{id}/workflows/runs/{runID}/rerun", app.ProtectFunc(c.rerunWorkflowRun, AdminOnly()))
+
+	// Test results reported by workflows, actions and the assistant - view on public repos or as admin
+	http.Handle("GET /repos/{id}/tests", app.Serve("repo-tests.html", PublicOrAdmin()))
+
+	// Environments repositories deploy their images to - view on public repos or as admin
+	http.Handle("GET /repos/{id}/environments", app.Serve("repo-environments.html", PublicOrAdmin()))
+	// Environment operations - admin only
+	http.Handle("POST /repos/{id}/environments", app.ProtectFunc(c.createEnvironment, AdminOnly()))
+	http.Handle("POST /repos/{id}/environments/{envID}/health", app.ProtectFunc(c.saveEnvironmentHealth, AdminOnly()))
+	http.Handle("POST /repos/{id}/environments/{envID}/delete", app.ProtectFunc(c.deleteEnvironment, AdminOnly()))
+	http.Handle("POST /repos/{id}/environments/{envID}/deploy", app.ProtectFunc(c.deployEnvironment, AdminOnly()))
+	http.Handle("POST /repos/{id}/environments/{envID}/rollback", app.ProtectFunc(c.rollbackEnvironment, AdminOnly()))
+
+	failInterruptedWorkflows()
+	failInterruptedDeployments()
+	c.startWorkflowScheduler()
 }
 
 // RepoActions returns actions for the current repository
@@ -68,6 +93,17 @@ func (c *ActionsController) RepoActions() ([]*models.Action, error) {
 	return models.Actions.Search("WHERE RepoID = ? ORDER BY CreatedAt DESC", repo.ID)
 }
 
+// UpcomingRuns returns the next runs of the current repository's
+// scheduled actions
+func (c *ActionsController) UpcomingRuns() ([]*models.ScheduledRun, error) {
+	reposController := c.Use("repos").(*ReposController)
+	repo, err := reposController.CurrentRepo()
+	if err != nil {
+		return nil, err
+	}
+	return models.UpcomingScheduledRuns(repo.ID, time.Now(), 10)
+}
+
 // CurrentAction returns the action from the request
 func (c *ActionsController) CurrentAction() (*models.Action, error) {
 	actionID := c.Request.PathValue("actionID")
@@ -152,6 +188,12 @@ func (c *ActionsController) createAction(w http.ResponseWriter, r *http.Request)
 		RepoID:        repoID,
 		UserID:        user.ID,
 	}
+	if actionType == "scheduled" {
+		if err := action.SetSchedule(p.String("schedule", ""), p.String("overlap_policy", "")); err != nil {
+			c.RenderError(w, r, err)
+			return
+		}
+	}
 
 	_, err := models.Actions.Insert(action)
 	if err != nil {
@@ -191,9 +233,9 @@ func (c *ActionsController) runAction(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Run action manually
-	if action.Type != "manual" {
-		c.RenderError(w, r, errors.New("only manual actions can be executed directly"))
+	// Run action manually, or a scheduled one ahead of its schedule
+	if action.Type != "manual" && action.Type != "scheduled" {
+		c.RenderError(w, r, errors.New("only manual and scheduled actions can be executed directly"))
 		return
 	}
 
@@ -316,13 +358,18 @@ func (c *ActionsController) downloadArtifact(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	file, err := artifact.Open()
+	if err != nil {
+		http.Error(w, "artifact not found", http.StatusNotFound)
+		return
+	}
+
 	// Set headers for download
 	w.Header().Set("Content-Type", "application/octet-stream")
 	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", artifact.FileName))
-	w.Header().Set("Content-Length", strconv.Itoa(len(artifact.Content)))
 
 	// Write content
-	w.Write(artifact.Content)
+	serveStored(w, r, file, artifact.CreatedAt)
 
 	// Log activity
 	models.LogActivity("artifact_downloaded", "Downloaded artifact: "+artifact.FileName,
@@ -515,6 +562,7 @@ func (c *ActionsController) executeAction(action *models.Action, userID string)
 		repo.Name,
 		scriptToRun,
 		600, // 10 minute timeout by default
+		nil,
 	)
 	if err != nil {
 		run.Status = "failed"
@@ -523,6 +571,16 @@ func (c *ActionsController) executeAction(action *models.Action, userID string)
 		return
 	}
 
+	// Give the action its secrets, masked in everything it prints
+	secrets, err := models.ActionSecretEnv(repo.ID)
+	if err != nil {
+		run.Status = "failed"
+		run.Output = "Failed to read secrets: " + err.Error()
+		models.ActionRuns.Update(run)
+		return
+	}
+	sandbox.SetSecrets(secrets)
+
 	// Start sandbox execution
 	if err := sandbox.Start(); err != nil {
 		run.Status = "failed"
@@ -572,6 +630,9 @@ func (c *ActionsController) monitorExecution(action *models.Action, run *models.
 	// Update records
 	models.ActionRuns.Update(run)
 	models.Actions.Update(action)
+	if err := models.MeterActionRun(action, run); err != nil {
+		log.Printf("Failed to meter action run: %v", err)
+	}
 
 	// Collect artifacts if configured
 	if action.ArtifactPaths != "" && run.Status == "success" {
@@ -627,11 +688,10 @@ func (c *ActionsController) collectArtifacts(action *models.Action, run *models.
 			FilePath:    path,
 			GroupName:   filepath.Base(path), // Use filename as group for now
 			Size:        int64(len(content)),
-			Content:     content,
 		}
 
 		// Save artifact
-		if _, err := models.ActionArtifacts.Insert(artifact); err != nil {
+		if err := models.StoreArtifact(artifact, content); err != nil {
 			log.Printf("Failed to save artifact %s: %v", path, err)
 		}
 	}
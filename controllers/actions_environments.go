@@ -0,0 +1,241 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"strings"
+
+	"workspace/models"
+	"workspace/services"
+)
+
+// Environments returns the current repository's deployment environments
+func (c *ActionsController) Environments() ([]*models.Environment, error) {
+	repo, err := c.Use("repos").(*ReposController).CurrentRepo()
+	if err != nil {
+		return nil, err
+	}
+	return models.GetEnvironments(repo.ID)
+}
+
+// DeploymentOutput returns a deployment's log, as far as it has got while
+// the deployment runs
+func (c *ActionsController) DeploymentOutput(deployment *models.Deployment) string {
+	if output, ok := services.Deployments.Log(deployment.ID); ok {
+		return output
+	}
+	return deployment.Output
+}
+
+// DeploymentBadge renders the badge for a deployment's status
+func (c *ActionsController) DeploymentBadge(status string) template.HTML {
+	switch status {
+	case models.DeploymentRunning:
+		return template.HTML(workflowBadge(models.WorkflowRunning))
+	case models.DeploymentSucceeded:
+		return `<span class="badge badge-success badge-sm">Deployed</span>`
+	case models.DeploymentFailed:
+		return template.HTML(workflowBadge(models.WorkflowFailed))
+	}
+	return template.HTML(workflowBadge(status))
+}
+
+// environmentFromRequest loads the environment in the request's path,
+// making sure it belongs to the repository in the path
+func environmentFromRequest(r *http.Request) (*models.Environment, error) {
+	env, err := models.Environments.Get(r.PathValue("envID"))
+	if err != nil || env.RepoID != r.PathValue("id") {
+		return nil, errors.New("environment not found")
+	}
+	return env, nil
+}
+
+// createEnvironment handles POST /repos/{id}/environments
+func (c *ActionsController) createEnvironment(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+
+	// Admin access already verified by route middleware
+	auth := c.Use("auth").(*AuthController)
+	user, _, err := auth.Authenticate(r)
+	if err != nil {
+		c.RenderError(w, r, errors.New("authentication required"))
+		return
+	}
+
+	repo, err := models.Repositories.Get(r.PathValue("id"))
+	if err != nil {
+		c.RenderError(w, r, errors.New("repository not found"))
+		return
+	}
+
+	p := c.Params()
+	env, err := models.CreateEnvironment(repo.ID, p.String("name", ""), p.String("docker_host", ""),
+		p.String("ports", ""), p.String("health_url", ""), user.ID)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	ca, cert, key := p.String("tls_ca", ""), p.String("tls_cert", ""), p.String("tls_key", "")
+	if env.DockerHost != "" && (ca != "" || cert != "" || key != "") {
+		if err := env.SetTLS(ca, cert, key); err != nil {
+			models.DeleteEnvironment(env)
+			c.RenderError(w, r, err)
+			return
+		}
+	}
+	if err := env.SetHealthCheck(env.HealthURL, p.Int("health_status", 0), p.Int("health_timeout", 0),
+		r.FormValue("auto_rollback") == "on"); err != nil {
+		models.DeleteEnvironment(env)
+		c.RenderError(w, r, err)
+		return
+	}
+
+	models.LogActivity("environment_created", "Added environment "+env.Name,
+		fmt.Sprintf("Deploys %s to %s", repo.Name, env.Target()), user.ID, repo.ID, "environment", env.ID)
+	c.Refresh(w, r)
+}
+
+// saveEnvironmentHealth handles POST /repos/{id}/environments/{envID}/health,
+// configuring the health check run after each deployment
+func (c *ActionsController) saveEnvironmentHealth(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+
+	// Admin access already verified by route middleware
+	auth := c.Use("auth").(*AuthController)
+	user, _, err := auth.Authenticate(r)
+	if err != nil {
+		c.RenderError(w, r, errors.New("authentication required"))
+		return
+	}
+
+	env, err := environmentFromRequest(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	p := c.Params()
+	if err := env.SetHealthCheck(p.String("health_url", ""), p.Int("health_status", 0), p.Int("health_timeout", 0),
+		r.FormValue("auto_rollback") == "on"); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	description := "Health check turned off"
+	if env.HealthURL != "" {
+		description = fmt.Sprintf("Expects %d from %s within %s", env.ExpectedStatus(), env.HealthURL, env.HealthWait())
+		if env.AutoRollback {
+			description += ", rolling back otherwise"
+		}
+	}
+	models.LogActivity("environment_updated", "Updated the health check of "+env.Name,
+		description, user.ID, env.RepoID, "environment", env.ID)
+	c.Refresh(w, r)
+}
+
+// deleteEnvironment handles POST /repos/{id}/environments/{envID}/delete,
+// removing the environment's container along with it
+func (c *ActionsController) deleteEnvironment(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+
+	// Admin access already verified by route middleware
+	auth := c.Use("auth").(*AuthController)
+	user, _, err := auth.Authenticate(r)
+	if err != nil {
+		c.RenderError(w, r, errors.New("authentication required"))
+		return
+	}
+
+	env, err := environmentFromRequest(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+	if env.Deploying() {
+		c.RenderError(w, r, fmt.Errorf("wait for the deployment to %s to finish", env.Name))
+		return
+	}
+
+	// Stopped before the credentials it needs are deleted
+	if env.DeploymentID != "" {
+		if err := services.Deployments.Stop(env); err != nil {
+			log.Printf("ActionsController: Failed to stop %s: %v", env.ContainerName(), err)
+		}
+	}
+	if err := models.DeleteEnvironment(env); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	models.LogActivity("environment_deleted", "Deleted environment "+env.Name,
+		"Its container and deployment history were removed", user.ID, env.RepoID, "environment", env.ID)
+	c.Refresh(w, r)
+}
+
+// deployEnvironment handles POST /repos/{id}/environments/{envID}/deploy,
+// deploying a tag of one of the repository's images, given as name:tag
+func (c *ActionsController) deployEnvironment(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+
+	// Admin access already verified by route middleware
+	auth := c.Use("auth").(*AuthController)
+	user, _, err := auth.Authenticate(r)
+	if err != nil {
+		c.RenderError(w, r, errors.New("authentication required"))
+		return
+	}
+
+	env, err := environmentFromRequest(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	image, version, ok := strings.Cut(strings.TrimSpace(c.Params().String("image", "")), ":")
+	if !ok {
+		c.RenderError(w, r, errors.New("choose an image tag to deploy"))
+		return
+	}
+	if _, err := services.Deployments.Deploy(env, image, version, user.ID); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+	c.Refresh(w, r)
+}
+
+// rollbackEnvironment handles POST /repos/{id}/environments/{envID}/rollback,
+// redeploying what the environment ran before
+func (c *ActionsController) rollbackEnvironment(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+
+	// Admin access already verified by route middleware
+	auth := c.Use("auth").(*AuthController)
+	user, _, err := auth.Authenticate(r)
+	if err != nil {
+		c.RenderError(w, r, errors.New("authentication required"))
+		return
+	}
+
+	env, err := environmentFromRequest(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+	if _, err := services.Deployments.Rollback(env, user.ID); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+	c.Refresh(w, r)
+}
+
+// failInterruptedDeployments marks deployments the last shutdown cut short
+// as failed
+func failInterruptedDeployments() {
+	if err := models.FailInterruptedDeployments(); err != nil {
+		log.Printf("ActionsController: Failed to clean up interrupted deployments: %v", err)
+	}
+}
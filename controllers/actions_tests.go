@@ -0,0 +1,51 @@
+package controllers
+
+import (
+	"workspace/models"
+)
+
+// testDashboardRuns is how many of a repository's latest test runs its test
+// dashboard covers
+const testDashboardRuns = 50
+
+// TestRuns returns the current repository's latest test runs, newest first
+func (c *ActionsController) TestRuns() ([]*models.TestRun, error) {
+	repo, err := c.Use("repos").(*ReposController).CurrentRepo()
+	if err != nil {
+		return nil, err
+	}
+	return models.RecentTestRuns(repo.ID, testDashboardRuns)
+}
+
+// TestTrend returns the current repository's latest test runs oldest first,
+// for charting their pass rates
+func (c *ActionsController) TestTrend() ([]*models.TestRun, error) {
+	runs, err := c.TestRuns()
+	if err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(runs)-1; i < j; i, j = i+1, j-1 {
+		runs[i], runs[j] = runs[j], runs[i]
+	}
+	return runs, nil
+}
+
+// TestHistories returns how each test fared in the current repository's
+// latest test runs, flaky and failing tests first
+func (c *ActionsController) TestHistories() ([]*models.TestHistory, error) {
+	repo, err := c.Use("repos").(*ReposController).CurrentRepo()
+	if err != nil {
+		return nil, err
+	}
+	return models.TestHistories(repo.ID, testDashboardRuns)
+}
+
+// FlakyTests returns the tests whose outcome alternates in the current
+// repository's latest test runs
+func (c *ActionsController) FlakyTests() ([]*models.TestHistory, error) {
+	repo, err := c.Use("repos").(*ReposController).CurrentRepo()
+	if err != nil {
+		return nil, err
+	}
+	return models.FlakyTests(repo.ID, testDashboardRuns)
+}
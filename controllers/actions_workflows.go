@@ -0,0 +1,302 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"workspace/internal/sse"
+	"workspace/models"
+	"workspace/services"
+)
+
+// workflowPollInterval is how often a run's logs and statuses are sent to
+// the browser while it runs
+const workflowPollInterval = time.Second
+
+// RepoWorkflows returns the workflows defined on the current repository's
+// default branch, including ones that failed to parse
+func (c *ActionsController) RepoWorkflows() ([]*models.Workflow, error) {
+	repo, err := c.Use("repos").(*ReposController).CurrentRepo()
+	if err != nil {
+		return nil, err
+	}
+	return repo.GetWorkflows("")
+}
+
+// WorkflowRuns returns the current repository's latest workflow runs
+func (c *ActionsController) WorkflowRuns() ([]*models.WorkflowRun, error) {
+	repo, err := c.Use("repos").(*ReposController).CurrentRepo()
+	if err != nil {
+		return nil, err
+	}
+	return models.GetWorkflowRuns(repo.ID, 50)
+}
+
+// CurrentWorkflowRun returns the workflow run from the request
+func (c *ActionsController) CurrentWorkflowRun() (*models.WorkflowRun, error) {
+	return workflowRunFromRequest(c.Request)
+}
+
+// workflowStepOutput returns a step's log, as far as it has got while the
+// step runs
+func workflowStepOutput(step *models.WorkflowStepRun) string {
+	if output, ok := services.Workflows.StepLog(step.ID); ok {
+		return output
+	}
+	return step.Output
+}
+
+// WorkflowBadge renders the badge for a run, job or step status
+func (c *ActionsController) WorkflowBadge(status string) template.HTML {
+	return template.HTML(workflowBadge(status))
+}
+
+// workflowRunFromRequest loads the run in the request's path, making sure
+// it belongs to the repository in the path
+func workflowRunFromRequest(r *http.Request) (*models.WorkflowRun, error) {
+	run, err := models.WorkflowRuns.Get(r.PathValue("runID"))
+	if err != nil || run.RepoID != r.PathValue("id") {
+		return nil, errors.New("workflow run not found")
+	}
+	return run, nil
+}
+
+// workflowBadge renders the badge for a status
+func workflowBadge(status string) string {
+	switch status {
+	case models.WorkflowQueued:
+		return `<span class="badge badge-ghost badge-sm">Queued</span>`
+	case models.WorkflowRunning:
+		return `<span class="badge badge-warning badge-sm gap-1"><span class="loading loading-spinner loading-xs"></span>Running</span>`
+	case models.WorkflowSuccess:
+		return `<span class="badge badge-success badge-sm">Passed</span>`
+	case models.WorkflowFailed:
+		return `<span class="badge badge-error badge-sm">Failed</span>`
+	case models.WorkflowCancelled:
+		return `<span class="badge badge-neutral badge-sm">Cancelled</span>`
+	case models.WorkflowSkipped:
+		return `<span class="badge badge-ghost badge-sm">Skipped</span>`
+	}
+	return fmt.Sprintf(`<span class="badge badge-ghost badge-sm">%s</span>`, template.HTMLEscapeString(status))
+}
+
+// startWorkflowScheduler starts scheduled workflows and actions at the top
+// of each minute their schedules name
+func (c *ActionsController) startWorkflowScheduler() {
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+
+		var last time.Time
+		for now := range ticker.C {
+			minute := now.Truncate(time.Minute)
+			if minute.Equal(last) {
+				continue
+			}
+			last = minute
+			services.Workflows.RunSchedules(minute)
+			services.Actions.RunSchedules(minute)
+		}
+	}()
+}
+
+// dispatchWorkflow handles POST /repos/{id}/workflows/dispatch
+func (c *ActionsController) dispatchWorkflow(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+
+	// Admin access already verified by route middleware
+	auth := c.Use("auth").(*AuthController)
+	user, _, err := auth.Authenticate(r)
+	if err != nil {
+		c.RenderError(w, r, errors.New("authentication required"))
+		return
+	}
+
+	repo, err := models.Repositories.Get(r.PathValue("id"))
+	if err != nil {
+		c.RenderError(w, r, errors.New("repository not found"))
+		return
+	}
+
+	p := c.Params()
+	file := strings.TrimSpace(p.String("file", ""))
+	branch := strings.TrimSpace(p.String("branch", ""))
+	if file == "" {
+		c.RenderError(w, r, errors.New("choose a workflow to run"))
+		return
+	}
+
+	run, err := services.Workflows.Dispatch(repo, file, branch, user.ID)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	models.LogActivity("workflow_dispatched", "Ran workflow: "+run.Workflow,
+		fmt.Sprintf("Run #%d on %s", run.Number, run.Branch), user.ID, repo.ID, "workflow_run", run.ID)
+	c.Redirect(w, r, run.URL())
+}
+
+// cancelWorkflowRun handles POST /repos/{id}/workflows/runs/{runID}/cancel
+func (c *ActionsController) cancelWorkflowRun(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+
+	// Admin access already verified by route middleware
+	auth := c.Use("auth").(*AuthController)
+	user, _, err := auth.Authenticate(r)
+	if err != nil {
+		c.RenderError(w, r, errors.New("authentication required"))
+		return
+	}
+
+	run, err := workflowRunFromRequest(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+	if err := services.Workflows.Cancel(run.ID); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	models.LogActivity("workflow_cancelled", "Cancelled workflow: "+run.Workflow,
+		fmt.Sprintf("Run #%d on %s", run.Number, run.Branch), user.ID, run.RepoID, "workflow_run", run.ID)
+	c.Refresh(w, r)
+}
+
+// rerunWorkflowRun handles POST /repos/{id}/workflows/runs/{runID}/rerun
+func (c *ActionsController) rerunWorkflowRun(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+
+	// Admin access already verified by route middleware
+	auth := c.Use("auth").(*AuthController)
+	user, _, err := auth.Authenticate(r)
+	if err != nil {
+		c.RenderError(w, r, errors.New("authentication required"))
+		return
+	}
+
+	run, err := workflowRunFromRequest(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+	rerun, err := services.Workflows.Rerun(run, user.ID)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	models.LogActivity("workflow_rerun", "Re-ran workflow: "+run.Workflow,
+		fmt.Sprintf("Run #%d re-ran as #%d", run.Number, rerun.Number), user.ID, run.RepoID, "workflow_run", rerun.ID)
+	c.Redirect(w, r, rerun.URL())
+}
+
+// downloadWorkflowArtifact handles GET
+// /repos/{id}/workflows/runs/{runID}/artifacts/{artifactID}
+func (c *ActionsController) downloadWorkflowArtifact(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+
+	// Access already verified by route middleware (PublicOrAdmin)
+	run, err := workflowRunFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	artifact, err := models.ActionArtifacts.Get(r.PathValue("artifactID"))
+	if err != nil || artifact.RunID != run.ID {
+		http.Error(w, "artifact not found", http.StatusNotFound)
+		return
+	}
+
+	file, err := artifact.Open()
+	if err != nil {
+		http.Error(w, "artifact not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", artifact.FileName))
+	serveStored(w, r, file, artifact.CreatedAt)
+}
+
+// streamWorkflowRun handles GET /repos/{id}/workflows/runs/{runID}/events,
+// sending each step's new output as "log-<step>" and each run, job and step
+// status change as "state-<id>" until the run finishes
+func (c *ActionsController) streamWorkflowRun(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+
+	// Access already verified by route middleware (PublicOrAdmin)
+	run, err := workflowRunFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	var userID string
+	if user, _, err := c.Use("auth").(*AuthController).Authenticate(r); err == nil {
+		userID = user.ID
+	}
+	stream, err := sse.Streams.Open(w, r, "workflows", userID)
+	if err != nil {
+		return
+	}
+	defer stream.Close()
+
+	ticker := time.NewTicker(workflowPollInterval)
+	defer ticker.Stop()
+
+	// Steps still running when the page rendered show no output, so logs
+	// are sent from the start
+	sent := map[string]int{}
+	states := map[string]string{}
+	state := func(id, status string) {
+		if states[id] != status {
+			stream.Send("state-"+id, workflowBadge(status))
+			states[id] = status
+		}
+	}
+
+	for {
+		select {
+		case <-ticker.C:
+			if run, err = models.WorkflowRuns.Get(run.ID); err != nil {
+				return
+			}
+			jobs, _ := run.Jobs()
+			for _, job := range jobs {
+				steps, _ := job.Steps()
+				for _, step := range steps {
+					output := workflowStepOutput(step)
+					if len(output) > sent[step.ID] {
+						stream.Send("log-"+step.ID, template.HTMLEscapeString(output[sent[step.ID]:]))
+						sent[step.ID] = len(output)
+					}
+					state(step.ID, step.Status)
+				}
+				state(job.ID, job.Status)
+			}
+			state(run.ID, run.Status)
+			if run.Finished() {
+				stream.Send("run-done", "")
+				return
+			}
+		case <-stream.Heartbeat():
+			stream.Ping()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// failInterruptedWorkflows marks runs the last shutdown cut short as failed
+func failInterruptedWorkflows() {
+	if err := models.FailInterruptedWorkflowRuns(); err != nil {
+		log.Printf("ActionsController: Failed to clean up interrupted workflow runs: %v", err)
+	}
+}
@@ -2,20 +2,27 @@ package controllers
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"html/template"
 	"log"
+	"mime/multipart"
 	"net/http"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"workspace/internal/agents"
 	"workspace/internal/agents/providers"
 	"workspace/internal/agents/tools"
 	aiService "workspace/internal/ai"
+	"workspace/internal/aicache"
+	"workspace/internal/references"
+	"workspace/internal/sse"
 	"workspace/models"
 	"workspace/services"
 
@@ -33,6 +40,13 @@ type AIController struct {
 	application.Controller
 	toolRegistry *agents.ToolRegistry
 	provider     agents.Provider
+	executions   map[string]*execution // Running agent loops keyed by conversation ID
+	executionsMu *sync.Mutex
+}
+
+// execution is a running agent loop that can be stopped
+type execution struct {
+	cancel context.CancelFunc
 }
 
 // AIMetrics tracks performance metrics for AI responses
@@ -44,6 +58,8 @@ type AIMetrics struct {
 	PromptTokens     int
 	CompletionTokens int
 	TotalTokens      int
+	TrimmedTokens    int  // Estimated tokens dropped from history to fit the context window
+	Cached           bool // Answered from the response cache without the model
 	ToolCallCount    int
 	ModelUsed        string
 	Error            string
@@ -53,11 +69,14 @@ type AIMetrics struct {
 func AI() (string, *AIController) {
 	// Initialize tool registry
 	registry := agents.NewToolRegistry()
+	registry.SetPolicy(models.CheckToolPolicy)
 
 	// Note: Tools will be registered in Setup based on provider capabilities
 
 	return "ai", &AIController{
 		toolRegistry: registry,
+		executions:   map[string]*execution{},
+		executionsMu: &sync.Mutex{},
 	}
 }
 
@@ -66,6 +85,8 @@ func (c *AIController) Setup(app *application.App) {
 	c.App = app
 	auth := app.Use("auth").(*AuthController)
 
+	configureResponseCache()
+
 	// Initialize provider based on AI_MODEL environment variable
 	provider, err := providers.NewProvider()
 	if err != nil {
@@ -84,16 +105,24 @@ func (c *AIController) Setup(app *application.App) {
 	http.Handle("GET /ai/chat", app.ProtectFunc(c.redirectToPanel, auth.AdminOnly))
 	http.Handle("POST /ai/conversations", app.ProtectFunc(c.createConversation, auth.AdminOnly))
 	http.Handle("DELETE /ai/conversations/{id}", app.ProtectFunc(c.deleteConversation, auth.AdminOnly))
+	http.Handle("GET /ai/conversations/{id}/export", app.ProtectFunc(c.exportConversation, auth.AdminOnly))
+	http.Handle("POST /ai/conversations/import", app.ProtectFunc(c.importConversation, auth.AdminOnly))
+	http.Handle("POST /ai/explain", app.ProtectFunc(c.explain, auth.AdminOnly))
+	http.Handle("POST /ai/playbooks/{id}", app.ProtectFunc(c.startPlaybook, auth.AdminOnly))
+	http.Handle("POST /ai/repos/{id}/chat", app.ProtectFunc(c.openRepoChat, auth.AdminOnly))
 
 	// Chat routes - Admin only
 	http.Handle("GET /ai/chat/{id}", app.ProtectFunc(c.loadChat, auth.AdminOnly))
 	http.Handle("GET /ai/chat/{id}/messages", app.ProtectFunc(c.getMessages, auth.AdminOnly))
 	http.Handle("POST /ai/chat/{id}/send", app.ProtectFunc(c.sendMessage, auth.AdminOnly))
+	http.Handle("GET /ai/chat/{id}/images/{imageID}", app.ProtectFunc(c.serveImage, auth.AdminOnly))
 	http.Handle("GET /ai/chat/{id}/stream", app.ProtectFunc(c.streamResponse, auth.AdminOnly))
+	http.Handle("POST /ai/chat/{id}/model", app.ProtectFunc(c.updateModel, auth.AdminOnly))
+	http.Handle("POST /ai/chat/{id}/retrieval", app.ProtectFunc(c.toggleRetrieval, auth.AdminOnly))
+	http.Handle("POST /ai/chat/{id}/cache", app.ProtectFunc(c.toggleCache, auth.AdminOnly))
 
 	// Todo routes - Admin only
 	http.Handle("GET /ai/chat/{id}/todos/panel", app.ProtectFunc(c.getTodoPanel, auth.AdminOnly))
-	http.Handle("GET /ai/chat/{id}/todos", app.ProtectFunc(c.getTodos, auth.AdminOnly))
 	http.Handle("GET /ai/chat/{id}/todos/stream", app.ProtectFunc(c.streamTodos, auth.AdminOnly))
 
 	// Control routes - Admin only
@@ -106,9 +135,23 @@ func (c *AIController) Setup(app *application.App) {
 	http.Handle("POST /ai/config/delay", app.ProtectFunc(c.updateDelay, auth.AdminOnly))
 	http.Handle("GET /ai/activity", app.ProtectFunc(c.getRecentActivity, auth.AdminOnly))
 
+	// AI task queue dashboard
+	http.Handle("GET /admin/ai-queue", app.Serve("admin-ai-queue.html", auth.AdminOnly))
+	http.Handle("GET /admin/ai-queue/partial", app.ProtectFunc(c.getQueuePartial, auth.AdminOnly))
+	http.Handle("POST /admin/ai-queue/tasks/{id}/{action}", app.ProtectFunc(c.updateQueueTask, auth.AdminOnly))
+	http.Handle("POST /admin/ai-queue/workers/{worker}/{action}", app.ProtectFunc(c.updateQueueWorker, auth.AdminOnly))
+
+	// Prompt templates - Admin only
+	http.Handle("GET /settings/prompts", app.Serve("settings-prompts.html", auth.AdminOnly))
+	http.Handle("POST /settings/prompts/system", app.ProtectFunc(c.saveSystemPrompt, auth.AdminOnly))
+	http.Handle("POST /settings/prompts/repos", app.ProtectFunc(c.saveRepoPrompt, auth.AdminOnly))
+	http.Handle("POST /settings/prompts/commands", app.ProtectFunc(c.savePromptCommand, auth.AdminOnly))
+	http.Handle("POST /settings/prompts/commands/{name}/delete", app.ProtectFunc(c.deletePromptCommand, auth.AdminOnly))
+
 	// Dashboard route - Admin only
 	http.Handle("GET /ai/dashboard", app.Serve("ai-dashboard.html", auth.AdminOnly))
 	http.Handle("GET /ai/metrics", app.Serve("ai-metrics.html", auth.AdminOnly))
+	http.Handle("GET /ai/audit", app.Serve("ai-audit.html", auth.AdminOnly))
 
 	// Proactive AI routes - Admin only
 	http.Handle("GET /ai/recommendations", app.ProtectFunc(c.getRecommendations, auth.AdminOnly))
@@ -136,6 +179,7 @@ func (c *AIController) registerSupportedTools(provider agents.Provider) {
 		"list_repos":    &tools.ListReposTool{},
 		"get_repo":      &tools.GetRepoTool{},
 		"create_repo":   &tools.CreateRepoTool{},
+		"delete_repo":   &tools.DeleteRepoTool{},
 		"get_repo_link": &tools.GetRepoLinkTool{},
 
 		// File tools
@@ -147,6 +191,9 @@ func (c *AIController) registerSupportedTools(provider agents.Provider) {
 		"move_file":    &tools.MoveFileTool{},
 		"search_files": &tools.SearchFilesTool{},
 
+		// Search tools
+		"semantic_search": &tools.SemanticSearchTool{},
+
 		// Git tools
 		"git_status":  &tools.GitStatusTool{},
 		"git_history": &tools.GitLogTool{},
@@ -156,6 +203,10 @@ func (c *AIController) registerSupportedTools(provider agents.Provider) {
 		"git_pull":    &tools.GitPullTool{},
 		"git_merge":   &tools.GitMergeTool{},
 
+		// Branch tools
+		"git_branch_create": &tools.GitBranchCreateTool{},
+		"git_checkout":      &tools.GitCheckoutTool{},
+
 		// Issue tools
 		"create_issue": &tools.CreateIssueTool{},
 		"update_issue": &tools.UpdateIssueTool{},
@@ -163,17 +214,30 @@ func (c *AIController) registerSupportedTools(provider agents.Provider) {
 		"get_issue":    &tools.ListIssuesTool{}, // Alias for compatibility
 
 		// Pull Request tools
-		"create_pr": &tools.CreatePRTool{},
-		"list_prs":  &tools.ListPRsTool{},
+		"create_pr":           &tools.CreatePRTool{},
+		"create_pull_request": &tools.CreatePullRequestTool{},
+		"list_prs":            &tools.ListPRsTool{},
+
+		// Planning tools
+		"create_milestone":    &tools.CreateMilestoneTool{},
+		"list_milestones":     &tools.ListMilestonesTool{},
+		"update_milestone":    &tools.UpdateMilestoneTool{},
+		"create_project_card": &tools.CreateProjectCardTool{},
+		"list_project_cards":  &tools.ListProjectCardsTool{},
+		"update_project_card": &tools.UpdateProjectCardTool{},
 
 		// CI/CD tools
-		"build":  &tools.BuildTool{},
-		"test":   &tools.TestTool{},
-		"deploy": &tools.DeployTool{},
+		"build":       &tools.BuildTool{},
+		"test":        &tools.TestTool{},
+		"deploy":      &tools.DeployTool{},
+		"run_linters": &tools.LintTool{},
 
 		// Terminal tool
 		"terminal_execute": &tools.RunCommandTool{},
 
+		// Documentation lookup on allowlisted sites
+		"web_fetch": &tools.WebFetchTool{},
+
 		// Todo tools
 		"list_todos":  &tools.TodoListTool{},
 		"update_todo": &tools.TodoUpdateTool{},
@@ -356,6 +420,9 @@ func (c *AIController) deleteConversation(w http.ResponseWriter, r *http.Request
 	// Delete all messages
 	messages, _ := conversation.GetMessages()
 	for _, msg := range messages {
+		if msg.ImageCount > 0 {
+			models.DeleteAttachments("message", msg.ID)
+		}
 		models.Messages.Delete(msg)
 	}
 
@@ -416,21 +483,97 @@ func (c *AIController) getMessages(w http.ResponseWriter, r *http.Request) {
 		messages = []*models.Message{}
 	}
 
+	// Conversations opened with a question, such as "Explain with AI",
+	// stream their first answer as soon as the chat loads
+	if autoRespond, _ := conversation.GetSettings()["autoRespond"].(bool); autoRespond {
+		conversation.UpdateSetting("autoRespond", false)
+		if len(messages) > 0 && messages[len(messages)-1].Role == models.MessageRoleUser {
+			c.Render(w, r, "ai-messages-enhanced.html", map[string]any{
+				"Messages":       messages,
+				"ConversationID": conversationID,
+			})
+			return
+		}
+	}
+
 	c.Render(w, r, "ai-messages.html", messages)
 }
 
+// serveImage returns an image attached to one of the conversation's messages
+func (c *AIController) serveImage(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+
+	user, _, err := c.App.Use("auth").(*AuthController).Authenticate(r)
+	if err != nil || !user.IsAdmin {
+		http.Error(w, "Admin access required", http.StatusForbidden)
+		return
+	}
+
+	conversation, err := models.Conversations.Get(r.PathValue("id"))
+	if err != nil || conversation.UserID != user.ID {
+		http.NotFound(w, r)
+		return
+	}
+
+	image, err := models.Attachments.Get(r.PathValue("imageID"))
+	if err != nil || image.EntityType != "message" || !image.IsImage() {
+		http.NotFound(w, r)
+		return
+	}
+	msg, err := models.Messages.Get(image.EntityID)
+	if err != nil || msg.ConversationID != conversation.ID {
+		http.NotFound(w, r)
+		return
+	}
+
+	file, err := image.Open()
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", image.ContentType)
+	w.Header().Set("Content-Security-Policy", "sandbox")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	serveStored(w, r, file, image.CreatedAt)
+}
+
+// attachImages stores the screenshots sent with a message, failing on the
+// first file that is not an image
+func (c *AIController) attachImages(msg *models.Message, images []*multipart.FileHeader, userID string) error {
+	for _, header := range images {
+		file, err := header.Open()
+		if err != nil {
+			return fmt.Errorf("%s could not be read", header.Filename)
+		}
+		_, err = msg.AttachImage(header.Filename, file, userID)
+		file.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // sendMessage sends a message to the AI and gets a response
 func (c *AIController) sendMessage(w http.ResponseWriter, r *http.Request) {
 	c.SetRequest(r)
 
 	conversationID := r.PathValue("id")
-	content := strings.TrimSpace(r.FormValue("message"))
 	user, _, err := c.App.Use("auth").(*AuthController).Authenticate(r)
 	if err != nil || !user.IsAdmin {
 		c.RenderError(w, r, errors.New("Admin access required"))
 		return
 	}
 
+	// Screenshots are sent with the message as a multipart form
+	images, err := attachmentFiles(w, r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+	content := strings.TrimSpace(r.FormValue("message"))
+
 	// Initialize metrics
 	metrics := &AIMetrics{
 		StartTime: time.Now(),
@@ -450,6 +593,22 @@ func (c *AIController) sendMessage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := models.CheckQuota(user.ID, models.UsageAITokens); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	// Refuse new messages while the queue for the model is full
+	if services.Ollama.QueueStatus().Full() {
+		w.Header().Set("Retry-After", "30")
+		c.RenderError(w, r, services.ErrQueueFull)
+		return
+	}
+
+	// Expand slash commands such as /review into their prompt
+	typed := content
+	content, _ = models.ExpandPromptCommand(content, c.promptVariables(conversationID))
+
 	// Save user message
 	userMsg := &models.Message{
 		ConversationID: conversationID,
@@ -459,11 +618,20 @@ func (c *AIController) sendMessage(w http.ResponseWriter, r *http.Request) {
 	userMsg, err = models.Messages.Insert(userMsg)
 	if err != nil {
 		log.Printf("AIController: Failed to save user message: %v", err)
+	} else if err := c.attachImages(userMsg, images, user.ID); err != nil {
+		models.DeleteAttachments("message", userMsg.ID)
+		models.Messages.Delete(userMsg)
+		c.RenderError(w, r, err)
+		return
+	} else {
+		// Retrieve the code a question is about before the answer starts
+		c.retrieveSources(r.Context(), conversation, userMsg)
+		recordMessageMentions(conversation, userMsg)
 	}
 
 	// Update conversation title if it's the first message
 	if conversation.Title == "New Conversation" {
-		conversation.Title = content
+		conversation.Title = typed
 		if len(conversation.Title) > 50 {
 			conversation.Title = conversation.Title[:47] + "..."
 		}
@@ -516,7 +684,8 @@ func (c *AIController) sendMessage(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Check if provider is ready
-	if c.provider == nil {
+	provider := c.providerFor(conversation)
+	if provider == nil {
 		log.Printf("AIController: AI provider not initialized")
 
 		// Save error message with helpful information
@@ -537,12 +706,18 @@ func (c *AIController) sendMessage(w http.ResponseWriter, r *http.Request) {
 	agentMessages := agents.ConvertOllamaToAgentMessages(ollamaMessages)
 
 	// Get tools in agent format
-	tools := agents.ConvertRegistryToAgentTools(c.toolRegistry, c.provider.SupportedTools())
+	tools := agents.ConvertRegistryToAgentTools(c.toolRegistry, c.toolsFor(conversationID, provider.SupportedTools()))
+
+	// Register the run so the stop endpoint can cancel it
+	ctx, finish := c.startExecution(r.Context(), conversationID)
+	defer finish()
 
 	// Use provider to send request with tools
 	thinkingStart := time.Now()
-	log.Printf("AIController: Sending request to %s with %d tools available", c.provider.Model(), len(tools))
-	response, err := c.provider.ChatWithTools(agentMessages, tools, agents.ChatOptions{})
+	metrics.ModelUsed = provider.Model()
+	log.Printf("AIController: Sending request to %s with %d tools available", provider.Model(), len(tools))
+	response, err := provider.ChatWithTools(ctx, agentMessages, tools, agents.ChatOptions{})
+	meterTokens(metrics, user.ID, conversationID, response)
 	metrics.ThinkingDuration = time.Since(thinkingStart)
 	log.Printf("AIController: Initial response received in %.2fs", metrics.ThinkingDuration.Seconds())
 
@@ -551,7 +726,11 @@ func (c *AIController) sendMessage(w http.ResponseWriter, r *http.Request) {
 
 		// Determine error message based on error type
 		errorMessage := "Unable to get AI response. Please try again."
-		if strings.Contains(err.Error(), "model not found") {
+		if ctx.Err() != nil {
+			errorMessage = "Execution stopped."
+		} else if errors.Is(err, services.ErrQueueFull) {
+			errorMessage = "The AI service is busy with other conversations. Please try again in a moment."
+		} else if strings.Contains(err.Error(), "model not found") {
 			errorMessage = "AI model is being downloaded. This may take several minutes on first use. Please try again shortly."
 		} else if strings.Contains(err.Error(), "connection refused") {
 			errorMessage = "AI service is not responding. Please contact support if this persists."
@@ -582,11 +761,14 @@ func (c *AIController) sendMessage(w http.ResponseWriter, r *http.Request) {
 		log.Printf("AIController: Model chose not to use tools for this query")
 		// Save and return the response immediately
 		assistantMsg := &models.Message{
-			ConversationID: conversationID,
-			Role:           models.MessageRoleAssistant,
-			Content:        finalResponse,
-		}
-		models.Messages.Insert(assistantMsg)
+			ConversationID:   conversationID,
+			Role:             models.MessageRoleAssistant,
+			Content:          finalResponse,
+			PromptTokens:     metrics.PromptTokens,
+			CompletionTokens: metrics.CompletionTokens,
+		}
+		assistantMsg, _ = models.Messages.Insert(assistantMsg)
+		recordMessageReferences(conversation, assistantMsg)
 		conversation.UpdateLastMessage(finalResponse, models.MessageRoleAssistant)
 
 		metrics.TotalDuration = time.Since(metrics.StartTime)
@@ -604,6 +786,11 @@ func (c *AIController) sendMessage(w http.ResponseWriter, r *http.Request) {
 	log.Printf("AIController: Model decided to use tools, entering agentic loop")
 
 	for iteration < maxIterations {
+		if ctx.Err() != nil {
+			log.Printf("AIController: Execution stopped for conversation %s", conversationID)
+			break
+		}
+
 		var toolResults []string
 		toolStart := time.Now()
 
@@ -616,7 +803,7 @@ func (c *AIController) sendMessage(w http.ResponseWriter, r *http.Request) {
 			log.Printf("AIController: Executing %d tools (iteration %d): %v", len(response.ToolCalls), iteration+1, toolNames)
 
 			// Process native tool calls (without streaming in sendMessage)
-			toolResults = c.processNativeAgentToolCalls(response.ToolCalls, conversationID, user.ID, nil, nil)
+			toolResults = c.processNativeAgentToolCalls(ctx, response.ToolCalls, conversationID, user.ID, nil, nil)
 
 			toolDuration := time.Since(toolStart)
 			metrics.ToolDuration += toolDuration
@@ -663,8 +850,9 @@ func (c *AIController) sendMessage(w http.ResponseWriter, r *http.Request) {
 		followUpStart := time.Now()
 		log.Printf("AIController: Getting follow-up response after tool execution (iteration %d)", iteration+1)
 		agentMessages = agents.ConvertOllamaToAgentMessages(ollamaMessages)
-		tools = agents.ConvertRegistryToAgentTools(c.toolRegistry, c.provider.SupportedTools())
-		response, err = c.provider.ChatWithTools(agentMessages, tools, agents.ChatOptions{})
+		tools = agents.ConvertRegistryToAgentTools(c.toolRegistry, c.toolsFor(conversationID, provider.SupportedTools()))
+		response, err = provider.ChatWithTools(ctx, agentMessages, tools, agents.ChatOptions{})
+		meterTokens(metrics, user.ID, conversationID, response)
 		metrics.ThinkingDuration += time.Since(followUpStart)
 		if err != nil {
 			log.Printf("AIController: Failed to get follow-up response: %v", err)
@@ -683,14 +871,17 @@ func (c *AIController) sendMessage(w http.ResponseWriter, r *http.Request) {
 
 	// Save the final assistant response
 	assistantMsg := &models.Message{
-		ConversationID: conversationID,
-		Role:           models.MessageRoleAssistant,
-		Content:        finalResponse,
+		ConversationID:   conversationID,
+		Role:             models.MessageRoleAssistant,
+		Content:          finalResponse,
+		PromptTokens:     metrics.PromptTokens,
+		CompletionTokens: metrics.CompletionTokens,
 	}
 	assistantMsg, err = models.Messages.Insert(assistantMsg)
 	if err != nil {
 		log.Printf("AIController: Failed to save assistant message: %v", err)
 	}
+	recordMessageReferences(conversation, assistantMsg)
 
 	// Update conversation's last message
 	conversation.UpdateLastMessage(finalResponse, models.MessageRoleAssistant)
@@ -716,10 +907,9 @@ func (c *AIController) sendMessage(w http.ResponseWriter, r *http.Request) {
 	c.Render(w, r, "ai-messages.html", messages)
 }
 
-// buildSystemPrompt creates the system prompt optimized for gpt-oss
-func (c *AIController) buildSystemPrompt(conversationID string) string {
-	// Prompt optimized for native tool calling with gpt-oss
-	prompt := `You are an AI coding assistant in the Skyscape development platform, similar to Claude Code but integrated into a web interface.
+// defaultSystemPrompt is used unless an admin saves their own under
+// Settings → AI Prompts. It is optimized for native tool calling with gpt-oss.
+const defaultSystemPrompt = `You are an AI coding assistant in the Skyscape development platform, similar to Claude Code but integrated into a web interface.
 
 **YOUR PERSONALITY & APPROACH:**
 - Be proactive and intelligent in your exploration
@@ -796,12 +986,32 @@ Never jump ahead - explore methodically and share insights at each step.
 - Connect findings to build understanding of the whole system
 - Be conversational and engaging, not robotic`
 
+// buildSystemPrompt creates the system prompt from the admin's template, or
+// the default, followed by the project and repository context
+func (c *AIController) buildSystemPrompt(conversationID string) string {
+	vars := c.promptVariables(conversationID)
+
+	prompt := defaultSystemPrompt
+	if custom := models.GetSystemPrompt(); custom != nil {
+		prompt = models.RenderPrompt(custom.Content, vars)
+	}
+
 	// Check for project context file (SKYSCAPE.md) and append if exists
 	contextFile := c.loadProjectContext()
 	if contextFile != "" {
 		prompt += "\n\n## Project Context\n" + contextFile
 	}
 
+	// Add the admin's notes for the repository being worked on
+	if conversation, err := models.Conversations.Get(conversationID); err == nil {
+		prompt += repoScopePrompt(conversation)
+		if repoID, _ := conversation.GetWorkingContext()["current_repo_id"].(string); repoID != "" {
+			if snippet := models.GetRepoPrompt(repoID); snippet != nil {
+				prompt += "\n\n## Repository Context\n" + models.RenderPrompt(snippet.Content, vars)
+			}
+		}
+	}
+
 	return prompt
 }
 
@@ -828,13 +1038,20 @@ func (c *AIController) loadProjectContext() string {
 
 // RenderMessageMarkdown converts message content to HTML with markdown formatting
 func (c *AIController) RenderMessageMarkdown(content string) template.HTML {
+	extensions := []goldmark.Extender{
+		extension.GFM,      // GitHub Flavored Markdown
+		extension.Linkify,  // Auto-linkify URLs
+		extension.TaskList, // Task list support
+	}
+
+	// Conversations bound to a repository link its issues, pull requests and commits
+	if repo := c.conversationRepo(); repo != nil {
+		extensions = append(extensions, references.NewLinker(models.ReferenceResolver(repo)))
+	}
+
 	// Create goldmark markdown processor with GitHub Flavored Markdown
 	md := goldmark.New(
-		goldmark.WithExtensions(
-			extension.GFM,      // GitHub Flavored Markdown
-			extension.Linkify,  // Auto-linkify URLs
-			extension.TaskList, // Task list support
-		),
+		goldmark.WithExtensions(extensions...),
 		goldmark.WithParserOptions(
 			parser.WithAutoHeadingID(),
 		),
@@ -886,6 +1103,42 @@ func (c *AIController) RenderMessageMarkdown(content string) template.HTML {
 	return template.HTML(htmlStr)
 }
 
+// conversationRepo returns the repository the conversation in the URL is
+// bound to, nil for the global AI panel
+func (c *AIController) conversationRepo() *models.Repository {
+	if c.Request == nil {
+		return nil
+	}
+	conversation, err := models.Conversations.Get(c.Request.PathValue("id"))
+	if err != nil || conversation.RepoID == "" {
+		return nil
+	}
+	repo, err := conversation.Repository()
+	if err != nil {
+		return nil
+	}
+	return repo
+}
+
+// recordMessageReferences notes the issues and pull requests an assistant
+// message mentions, when its conversation is bound to a repository
+func recordMessageReferences(conversation *models.Conversation, message *models.Message) {
+	if conversation.RepoID == "" || message == nil {
+		return
+	}
+	if _, err := models.RecordReferences(conversation.RepoID, models.RefMessage, message.ID, message.Content, conversation.UserID); err != nil {
+		log.Printf("AIController: Failed to record references of message %s: %v", message.ID, err)
+	}
+}
+
+// recordMessageMentions notifies the users a message to the assistant
+// mentions by handle
+func recordMessageMentions(conversation *models.Conversation, message *models.Message) {
+	if _, err := models.RecordMentions(conversation.RepoID, models.RefMessage, message.ID, message.Content, conversation.UserID); err != nil {
+		log.Printf("AIController: Failed to record mentions of message %s: %v", message.ID, err)
+	}
+}
+
 // categorizeTools returns relevant tools based on the user's message and conversation state
 func (c *AIController) categorizeTools(message string, isFirstMessage bool, lastToolUsed string) []string {
 	messageLower := strings.ToLower(message)
@@ -981,18 +1234,13 @@ func (c *AIController) streamResponse(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Set SSE headers
-	w.Header().Set("Content-Type", "text/event-stream")
-	w.Header().Set("Cache-Control", "no-cache")
-	w.Header().Set("Connection", "keep-alive")
-	w.Header().Set("X-Accel-Buffering", "no") // Disable Nginx buffering
-
-	// Create flusher for real-time updates
-	flusher, ok := w.(http.Flusher)
-	if !ok {
-		http.Error(w, "SSE not supported", http.StatusInternalServerError)
+	// Open the event stream; the stream flushes each update to the browser
+	stream, err := sse.Streams.Open(w, r, "ai", user.ID)
+	if err != nil {
 		return
 	}
+	defer stream.Close()
+	var flusher http.Flusher = stream
 
 	// Get the latest pending message (should be created by sendMessage)
 	messages, _ := conversation.GetMessages()
@@ -1018,8 +1266,10 @@ func (c *AIController) streamResponse(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Build optimized context window
-	ollamaMessages := c.buildContextWindow(conversation, 30)
+	// Build optimized context window sized for the conversation's model
+	provider := c.providerFor(conversation)
+	ollamaMessages, trimmedTokens := c.buildContextWindow(conversation, 30, c.contextBudget(provider), provider != nil && provider.SupportsVision())
+	metrics.TrimmedTokens = trimmedTokens
 
 	// Add todos to context if any exist
 	todos, err := models.GetActiveTodos(conversationID)
@@ -1032,8 +1282,8 @@ func (c *AIController) streamResponse(w http.ResponseWriter, r *http.Request) {
 		})
 	}
 
-	// Check if Ollama service is ready
-	if !services.Ollama.IsRunning() {
+	// Check if Ollama service is ready, unless the conversation uses an external model
+	if provider == nil || (!providers.IsExternalModel(provider.Model()) && !services.Ollama.IsRunning()) {
 		log.Printf("AIController: Ollama service is not running")
 
 		// Save error as message in conversation
@@ -1059,6 +1309,16 @@ func (c *AIController) streamResponse(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Register the run so the stop endpoint can cancel it
+	ctx, finish := c.startExecution(r.Context(), conversationID)
+	defer finish()
+
+	// Show where the chat stands while others hold the model
+	ctx = services.WithQueueUpdates(ctx, func(position int) {
+		fmt.Fprintf(w, "event: status\ndata: <span class='loading loading-spinner loading-xs'></span> ⏳ Waiting for the AI model, %s\n\n", queuePosition(position))
+		flusher.Flush()
+	})
+
 	// Get the last user message for tool categorization
 	var lastUserMessage string
 	userMessageCount := 0
@@ -1092,6 +1352,7 @@ func (c *AIController) streamResponse(w http.ResponseWriter, r *http.Request) {
 		initialStatus = "🤔 Analyzing request and selecting approach..."
 	}
 	fmt.Fprintf(w, "event: status\ndata: <span class='loading loading-spinner loading-xs'></span> %s\n\n", initialStatus)
+	fmt.Fprintf(w, "event: start\ndata: \n\n")
 	flusher.Flush()
 
 	// Send initial thinking thoughts
@@ -1114,10 +1375,11 @@ func (c *AIController) streamResponse(w http.ResponseWriter, r *http.Request) {
 	agentMessages := agents.ConvertOllamaToAgentMessages(ollamaMessages)
 	var initialResponse *agents.Response
 
-	log.Printf("AIController: Streaming response with %s", c.provider.Model())
+	metrics.ModelUsed = provider.Model()
+	log.Printf("AIController: Streaming response with %s", provider.Model())
 
 	// Get tools in agent format - provider will filter to supported ones
-	tools := agents.ConvertRegistryToAgentTools(c.toolRegistry, c.provider.SupportedTools())
+	tools := agents.ConvertRegistryToAgentTools(c.toolRegistry, c.toolsFor(conversationID, provider.SupportedTools()))
 
 	// Log tool names for debugging
 	toolNames := []string{}
@@ -1126,14 +1388,42 @@ func (c *AIController) streamResponse(w http.ResponseWriter, r *http.Request) {
 	}
 	log.Printf("AIController: Providing %d tools to model: %v", len(tools), toolNames)
 
-	initialResponse, err = c.provider.ChatWithTools(agentMessages, tools, agents.ChatOptions{})
+	// Repeated questions the model answered without tools come from the cache
+	var cacheKey, cached string
+	if conversation.CacheEnabled() {
+		cacheKey = responseCacheKey(user.ID, provider.Model(), ollamaMessages)
+	}
+	if cacheKey != "" {
+		cached, metrics.Cached = aicache.Responses.Get(cacheKey)
+	}
+	if metrics.Cached {
+		log.Printf("AIController: Answered from the response cache")
+		c.streamChunk(w, flusher, cached)
+		initialResponse, err = &agents.Response{Content: cached}, nil
+	} else {
+		initialResponse, err = c.chatWithStreaming(ctx, w, flusher, provider, agentMessages, tools)
+		meterTokens(metrics, user.ID, conversationID, initialResponse)
+	}
 
 	metrics.ThinkingDuration = time.Since(thinkingStart)
 	log.Printf("AIController: Initial response received in %.2fs", metrics.ThinkingDuration.Seconds())
 
+	if err != nil && ctx.Err() != nil {
+		log.Printf("AIController: Execution stopped for conversation %s", conversationID)
+		fmt.Fprintf(w, "event: status\ndata: ⏹️ Execution stopped\n\n")
+		fmt.Fprintf(w, "event: done\ndata: \n\n")
+		flusher.Flush()
+		return
+	}
+
 	if err != nil {
 		log.Printf("AIController: Failed to get initial AI response: %v", err)
 
+		notice := "Failed to get AI response. Please try again."
+		if errors.Is(err, services.ErrQueueFull) {
+			notice = "The AI service is busy with other conversations. Please try again in a moment."
+		}
+
 		// Save error as message in conversation
 		errorMsg := &models.Message{
 			ConversationID: conversationID,
@@ -1147,7 +1437,7 @@ func (c *AIController) streamResponse(w http.ResponseWriter, r *http.Request) {
 			<svg xmlns="http://www.w3.org/2000/svg" class="h-5 w-5 shrink-0 stroke-current" fill="none" viewBox="0 0 24 24">
 				<path stroke-linecap="round" stroke-linejoin="round" stroke-width="2" d="M10 14l2-2m0 0l2-2m-2 2l-2-2m2 2l2 2m7-2a9 9 0 11-18 0 9 9 0 0118 0z" />
 			</svg>
-			<span class="text-sm">Failed to get AI response. Please try again.</span>
+			<span class="text-sm">` + notice + `</span>
 		</div>`
 		errorHTMLEscaped := strings.ReplaceAll(errorHTML, "\n", "")
 		errorHTMLEscaped = strings.ReplaceAll(errorHTMLEscaped, "\t", "")
@@ -1172,6 +1462,9 @@ func (c *AIController) streamResponse(w http.ResponseWriter, r *http.Request) {
 	if len(initialResponse.ToolCalls) == 0 {
 		// Native tool calling only - no text parsing needed
 		log.Printf("AIController: No tool calls detected, proceeding with direct response")
+		if cacheKey != "" && !metrics.Cached {
+			aicache.Responses.Put(cacheKey, finalResponse)
+		}
 		// Skip directly to streaming the response
 		goto streamResponse
 	}
@@ -1182,14 +1475,9 @@ func (c *AIController) streamResponse(w http.ResponseWriter, r *http.Request) {
 	c.streamThought(w, flusher, "Analyzing the task and planning approach...")
 
 	for !taskComplete && iteration < maxIterations {
-		// Check for cancellation
-		select {
-		case <-r.Context().Done():
-			log.Printf("AIController: Execution cancelled by user")
-			fmt.Fprintf(w, "event: status\ndata: ❌ Execution cancelled\n\n")
-			flusher.Flush()
-			return
-		default:
+		// Check for cancellation from the stop endpoint or a closed connection
+		if ctx.Err() != nil {
+			break
 		}
 		var toolResults []string
 		toolStart := time.Now()
@@ -1218,7 +1506,7 @@ func (c *AIController) streamResponse(w http.ResponseWriter, r *http.Request) {
 
 			// Process native tool calls with streaming
 			log.Printf("AIController: Processing %d tool call (iteration %d): %v", len(initialResponse.ToolCalls), iteration+1, toolNames)
-			toolResults = c.processNativeAgentToolCalls(initialResponse.ToolCalls, conversationID, user.ID, w, flusher)
+			toolResults = c.processNativeAgentToolCalls(ctx, initialResponse.ToolCalls, conversationID, user.ID, w, flusher)
 
 			// Extract and update working context from tool calls
 			for i, tc := range initialResponse.ToolCalls {
@@ -1350,8 +1638,9 @@ func (c *AIController) streamResponse(w http.ResponseWriter, r *http.Request) {
 
 		// Get new response with tool results context
 		agentMessages = agents.ConvertOllamaToAgentMessages(ollamaMessages)
-		tools = agents.ConvertRegistryToAgentTools(c.toolRegistry, c.provider.SupportedTools())
-		response, err := c.provider.ChatWithTools(agentMessages, tools, agents.ChatOptions{})
+		tools = agents.ConvertRegistryToAgentTools(c.toolRegistry, c.toolsFor(conversationID, provider.SupportedTools()))
+		response, err := c.chatWithStreaming(ctx, w, flusher, provider, agentMessages, tools)
+		meterTokens(metrics, user.ID, conversationID, response)
 		if err != nil {
 			finalResponse = finalResponse + "\n\n" + strings.Join(toolResults, "\n")
 			break
@@ -1368,7 +1657,8 @@ func (c *AIController) streamResponse(w http.ResponseWriter, r *http.Request) {
 			})
 
 			retryAgentMessages := agents.ConvertOllamaToAgentMessages(retryMessages)
-			retryResponse, retryErr := c.provider.ChatWithTools(retryAgentMessages, tools, agents.ChatOptions{})
+			retryResponse, retryErr := c.chatWithStreaming(ctx, w, flusher, provider, retryAgentMessages, tools)
+			meterTokens(metrics, user.ID, conversationID, retryResponse)
 			if retryErr == nil && retryResponse.Content != "" {
 				response = retryResponse
 				log.Printf("AIController: Regenerated response successfully")
@@ -1431,7 +1721,11 @@ func (c *AIController) streamResponse(w http.ResponseWriter, r *http.Request) {
 				// Tools are dynamically filtered by the provider
 			}
 			agentMessages = agents.ConvertOllamaToAgentMessages(ollamaMessages)
-			response, err = c.provider.ChatWithTools(agentMessages, tools, agents.ChatOptions{})
+			if response.Content != "" {
+				c.streamChunk(w, flusher, "\n\n")
+			}
+			response, err = c.chatWithStreaming(ctx, w, flusher, provider, agentMessages, tools)
+			meterTokens(metrics, user.ID, conversationID, response)
 			if err == nil && (len(response.ToolCalls) > 0 || response.Content != "") {
 				initialResponse = response
 				if response.Content != "" {
@@ -1446,6 +1740,11 @@ func (c *AIController) streamResponse(w http.ResponseWriter, r *http.Request) {
 		log.Printf("AIController: Autonomous iteration %d complete", iteration)
 	}
 
+	if ctx.Err() != nil {
+		log.Printf("AIController: Execution stopped for conversation %s after %d iterations", conversationID, iteration)
+		finalResponse = strings.TrimSpace(finalResponse + "\n\n*Execution stopped.*")
+	}
+
 streamResponse:
 	// Add final thinking before response
 	if iteration > 0 {
@@ -1458,43 +1757,7 @@ streamResponse:
 	fmt.Fprintf(w, "event: status\ndata: \n\n")
 	flusher.Flush()
 
-	log.Printf("AIController: Beginning response streaming, content length: %d", len(finalResponse))
-
-	// Send initial message structure (replaces typing indicator)
-	startHTML := `<div class="chat chat-start my-2" id="streaming-message">
-		<div class="chat-image avatar">
-			<div class="w-8 h-8 rounded-full flex-shrink-0">
-				<div class="bg-base-300 text-base-content w-8 h-8 flex items-center justify-center rounded-full">
-					<svg xmlns="http://www.w3.org/2000/svg" class="h-5 w-5" fill="none" viewBox="0 0 24 24" stroke="currentColor">
-						<path stroke-linecap="round" stroke-linejoin="round" stroke-width="2" d="M9.75 17L9 20l-1 1h8l-1-1-.75-3M3 13h18M5 17h14a2 2 0 002-2V5a2 2 0 00-2-2H5a2 2 0 00-2 2v10a2 2 0 002 2z" />
-					</svg>
-				</div>
-			</div>
-		</div>
-		<div class="chat-bubble max-w-[85%] sm:max-w-[70%] break-words text-sm">
-			<span id="streaming-content"></span>
-		</div>
-	</div>`
-	// SSE data must be on a single line - replace newlines
-	startHTMLEscaped := strings.ReplaceAll(startHTML, "\n", "")
-	startHTMLEscaped = strings.ReplaceAll(startHTMLEscaped, "\t", "")
-	fmt.Fprintf(w, "event: start\ndata: %s\n\n", startHTMLEscaped)
-	flusher.Flush()
-
-	// Stream plain text chunks for immediate feedback
-	chunkSize := 50 // Smaller chunks for smoother streaming
-	for i := 0; i < len(finalResponse); i += chunkSize {
-		end := i + chunkSize
-		if end > len(finalResponse) {
-			end = len(finalResponse)
-		}
-
-		// Send plain text chunk (will be escaped by browser)
-		chunk := finalResponse[i:end]
-		fmt.Fprintf(w, "event: chunk\ndata: %s\n\n", template.HTMLEscapeString(chunk))
-		flusher.Flush()
-		time.Sleep(20 * time.Millisecond) // Smooth streaming effect
-	}
+	log.Printf("AIController: Response streamed, content length: %d", len(finalResponse))
 
 	// Calculate final metrics
 	metrics.TotalDuration = time.Since(metrics.StartTime)
@@ -1508,6 +1771,15 @@ streamResponse:
 			metrics.ToolCallCount,
 			metrics.ToolDuration.Seconds())
 	}
+	if metrics.TrimmedTokens > 0 {
+		perfSummary += fmt.Sprintf(" | ✂️ %d tokens trimmed", metrics.TrimmedTokens)
+	}
+	if metrics.TotalTokens > 0 {
+		perfSummary += fmt.Sprintf(" | 🔤 %d tokens", metrics.TotalTokens)
+	}
+	if metrics.Cached {
+		perfSummary += " | 📦 cached answer"
+	}
 
 	log.Printf("AIController: Response complete - %s", perfSummary)
 
@@ -1541,18 +1813,21 @@ streamResponse:
 	// Save the final response to database
 	if finalResponse != "" {
 		assistantMsg := &models.Message{
-			ConversationID: conversationID,
-			Role:           models.MessageRoleAssistant,
-			Content:        finalResponse,
+			ConversationID:   conversationID,
+			Role:             models.MessageRoleAssistant,
+			Content:          finalResponse,
+			PromptTokens:     metrics.PromptTokens,
+			CompletionTokens: metrics.CompletionTokens,
 		}
 
-		models.Messages.Insert(assistantMsg)
+		assistantMsg, _ = models.Messages.Insert(assistantMsg)
+		recordMessageReferences(conversation, assistantMsg)
 		conversation.UpdateLastMessage(finalResponse, models.MessageRoleAssistant)
 	}
 }
 
 // processNativeAgentToolCalls processes native tool calls from agent provider
-func (c *AIController) processNativeAgentToolCalls(toolCalls []agents.ToolCall, conversationID, userID string, w http.ResponseWriter, flusher http.Flusher) []string {
+func (c *AIController) processNativeAgentToolCalls(ctx context.Context, toolCalls []agents.ToolCall, conversationID, userID string, w http.ResponseWriter, flusher http.Flusher) []string {
 	if c.toolRegistry == nil {
 		log.Printf("AIController: ERROR - Tool registry is nil")
 		return nil
@@ -1565,6 +1840,10 @@ func (c *AIController) processNativeAgentToolCalls(toolCalls []agents.ToolCall,
 	log.Printf("AIController: Processing %d tool calls", len(toolCalls))
 
 	for i, tc := range toolCalls {
+		if ctx.Err() != nil {
+			log.Printf("AIController: Skipping %d remaining tool calls after stop", len(toolCalls)-i)
+			break
+		}
 		toolStart := time.Now()
 
 		// Stream thought/planning message (only if streaming enabled)
@@ -1593,6 +1872,16 @@ func (c *AIController) processNativeAgentToolCalls(toolCalls []agents.ToolCall,
 				c.streamThought(w, flusher, "Examining changes...")
 			case "git_commit":
 				c.streamThought(w, flusher, "Creating commit...")
+			case "git_branch_create":
+				c.streamThought(w, flusher, "Creating a branch for this work...")
+			case "git_checkout":
+				c.streamThought(w, flusher, "Switching branches...")
+			case "git_merge":
+				c.streamThought(w, flusher, "Checking for conflicts and merging...")
+			case "create_pull_request":
+				c.streamThought(w, flusher, "Opening a pull request for review...")
+			case "web_fetch":
+				c.streamThought(w, flusher, "Reading documentation...")
 			case "todo_update":
 				c.streamThought(w, flusher, "Updating task list...")
 			}
@@ -1607,6 +1896,21 @@ func (c *AIController) processNativeAgentToolCalls(toolCalls []agents.ToolCall,
 			continue
 		}
 
+		// Todo and pull request tools operate on the conversation they are called from
+		if tc.Function.Name == "todo_update" || tc.Function.Name == "todo_list" || tc.Function.Name == "create_pull_request" {
+			params["_conversation_id"] = conversationID
+		}
+
+		// Repository chats only reach their own repository
+		if err := c.applyRepoScope(conversationID, tc.Function.Name, params); err != nil {
+			result := fmt.Sprintf("❌ Tool %s: %v", tc.Function.Name, err)
+			toolResults = append(toolResults, result)
+			continue
+		}
+
+		// File and history tools follow the branch the agent switched to
+		c.applyWorkingBranch(conversationID, tc.Function.Name, params)
+
 		// Get the tool instance
 		tool, exists := c.toolRegistry.Get(tc.Function.Name)
 		if !exists {
@@ -1619,6 +1923,7 @@ func (c *AIController) processNativeAgentToolCalls(toolCalls []agents.ToolCall,
 		// Validate parameters
 		if err := tool.ValidateParams(params); err != nil {
 			log.Printf("AIController: Invalid parameters for tool %s: %v", tc.Function.Name, err)
+			c.recordToolExecution(tc.Function.Name, params, "", fmt.Errorf("invalid parameters: %w", err), time.Since(toolStart), conversationID, userID)
 			result := fmt.Sprintf("❌ Tool %s: Invalid parameters - %v", tc.Function.Name, err)
 			toolResults = append(toolResults, result)
 			continue
@@ -1634,9 +1939,10 @@ func (c *AIController) processNativeAgentToolCalls(toolCalls []agents.ToolCall,
 			flusher.Flush()
 		}
 
-		result, err := tool.Execute(params, userID)
+		result, err := c.toolRegistry.ExecuteTool(ctx, tc.Function.Name, params, userID)
 
 		toolDuration := time.Since(toolStart)
+		c.recordToolExecution(tc.Function.Name, params, result, err, toolDuration, conversationID, userID)
 		if err != nil {
 			log.Printf("AIController: Tool %s failed after %.2fs: %v", tc.Function.Name, toolDuration.Seconds(), err)
 			result = fmt.Sprintf("❌ Tool %s failed: %v", tc.Function.Name, err)
@@ -1657,7 +1963,7 @@ func (c *AIController) processNativeAgentToolCalls(toolCalls []agents.ToolCall,
 }
 
 // processNativeToolCalls processes tool calls from Ollama's native response format
-func (c *AIController) processNativeToolCalls(toolCalls []services.OllamaToolCall, conversationID, userID string, w http.ResponseWriter, flusher http.Flusher) []string {
+func (c *AIController) processNativeToolCalls(ctx context.Context, toolCalls []services.OllamaToolCall, conversationID, userID string, w http.ResponseWriter, flusher http.Flusher) []string {
 	if c.toolRegistry == nil {
 		log.Printf("AIController: ERROR - Tool registry is nil")
 		return nil
@@ -1670,6 +1976,10 @@ func (c *AIController) processNativeToolCalls(toolCalls []services.OllamaToolCal
 	log.Printf("AIController: Processing %d tool calls", len(toolCalls))
 
 	for i, tc := range toolCalls {
+		if ctx.Err() != nil {
+			log.Printf("AIController: Skipping %d remaining tool calls after stop", len(toolCalls)-i)
+			break
+		}
 		toolStart := time.Now()
 
 		// Stream thought/planning message (only if streaming enabled)
@@ -1718,11 +2028,21 @@ func (c *AIController) processNativeToolCalls(toolCalls []services.OllamaToolCal
 			continue
 		}
 
-		// Inject conversation ID for todo_update tool
-		if tc.Function.Name == "todo_update" {
+		// Inject conversation ID for todo_update and create_pull_request
+		if tc.Function.Name == "todo_update" || tc.Function.Name == "create_pull_request" {
 			params["_conversation_id"] = conversationID
 		}
 
+		// Repository chats only reach their own repository
+		if err := c.applyRepoScope(conversationID, tc.Function.Name, params); err != nil {
+			errorResult := agents.FormatToolResult(tc.Function.Name, "", err)
+			toolResults = append(toolResults, errorResult)
+			if streaming {
+				c.streamToolResult(w, flusher, tc.Function.Name, errorResult, i+1, len(toolCalls))
+			}
+			continue
+		}
+
 		// Log parsed parameters for debugging
 		log.Printf("AIController: [Tool %d/%d] Parameters: %v", i+1, len(toolCalls), params)
 
@@ -1737,8 +2057,9 @@ func (c *AIController) processNativeToolCalls(toolCalls []services.OllamaToolCal
 		}
 
 		// Execute the tool
-		result, err := c.toolRegistry.ExecuteTool(tc.Function.Name, params, userID)
+		result, err := c.toolRegistry.ExecuteTool(ctx, tc.Function.Name, params, userID)
 		toolDuration := time.Since(toolStart)
+		c.recordToolExecution(tc.Function.Name, params, result, err, toolDuration, conversationID, userID)
 
 		if err != nil {
 			// Format error result with clear indication of failure
@@ -1807,6 +2128,62 @@ func (c *AIController) streamThought(w http.ResponseWriter, flusher http.Flusher
 	time.Sleep(100 * time.Millisecond)
 }
 
+// streamChunk sends a piece of model output as a chunk event via SSE
+func (c *AIController) streamChunk(w http.ResponseWriter, flusher http.Flusher, text string) {
+	if text == "" {
+		return
+	}
+
+	// Each line needs its own data field so newlines survive the event stream
+	fmt.Fprint(w, "event: chunk\n")
+	for _, line := range strings.Split(template.HTMLEscapeString(text), "\n") {
+		fmt.Fprintf(w, "data: %s\n", line)
+	}
+	fmt.Fprint(w, "\n")
+	flusher.Flush()
+}
+
+// queuePosition describes a chat's place in the queue for the model
+func queuePosition(position int) string {
+	if position == 1 {
+		return "next in line..."
+	}
+	return fmt.Sprintf("%d chats ahead...", position-1)
+}
+
+// chatWithStreaming requests a completion from the provider, forwarding content
+// tokens to the client as they are generated. Content that precedes a tool call
+// is retracted from the message bubble and shown as a thought instead.
+func (c *AIController) chatWithStreaming(ctx context.Context, w http.ResponseWriter, flusher http.Flusher, provider agents.Provider, messages []agents.Message, tools []agents.Tool) (*agents.Response, error) {
+	if !provider.SupportsStreaming() {
+		return provider.ChatWithTools(ctx, messages, tools, agents.ChatOptions{})
+	}
+
+	announced := map[string]bool{}
+	response, err := provider.ChatWithToolsStream(ctx, messages, tools, agents.ChatOptions{Stream: true}, func(chunk *agents.Response) error {
+		c.streamChunk(w, flusher, chunk.Content)
+		for _, tc := range chunk.ToolCalls {
+			if name := tc.Function.Name; name != "" && !announced[name] {
+				announced[name] = true
+				fmt.Fprintf(w, "event: status\ndata: <span class='loading loading-spinner loading-xs'></span> 🔧 Calling %s...\n\n", template.HTMLEscapeString(name))
+				flusher.Flush()
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(response.ToolCalls) > 0 && response.Content != "" {
+		fmt.Fprint(w, "event: reset\ndata: \n\n")
+		flusher.Flush()
+		c.streamThought(w, flusher, response.Content)
+	}
+
+	return response, nil
+}
+
 // streamToolResult streams a single tool result via SSE
 func (c *AIController) streamToolResult(w http.ResponseWriter, flusher http.Flusher, toolName string, result string, current int, total int) {
 	// Create the tool result HTML
@@ -1872,59 +2249,23 @@ func (c *AIController) getTodoPanel(w http.ResponseWriter, r *http.Request) {
 		todos = []*models.Todo{}
 	}
 
-	// Calculate stats
-	completedCount := 0
-	for _, todo := range todos {
-		if todo.Status == models.TodoStatusCompleted {
-			completedCount++
-		}
-	}
-
-	totalCount := len(todos)
-	percentComplete := 0
-	if totalCount > 0 {
-		percentComplete = (completedCount * 100) / totalCount
-	}
-
 	// Render panel
 	c.Render(w, r, "ai-todos.html", map[string]any{
-		"ConversationID":  conversationID,
-		"Todos":           todos,
-		"HasTodos":        len(todos) > 0,
-		"TotalCount":      totalCount,
-		"CompletedCount":  completedCount,
-		"PercentComplete": percentComplete,
+		"ConversationID": conversationID,
+		"HasTodos":       len(todos) > 0,
+		"Items":          template.HTML(renderTodoItems(todos)),
+		"Progress":       template.HTML(renderTodoProgress(todos)),
 	})
 }
 
-// getTodos returns just the todo list items (for HTMX refresh)
-func (c *AIController) getTodos(w http.ResponseWriter, r *http.Request) {
-	c.SetRequest(r)
-
-	conversationID := r.PathValue("id")
-
-	// Get conversation
-	conversation, err := models.Conversations.Get(conversationID)
-	if err != nil {
-		c.RenderError(w, r, errors.New("Conversation not found"))
-		return
-	}
-
-	// Check ownership
-	user, _, err := c.App.Use("auth").(*AuthController).Authenticate(r)
-	if err != nil || conversation.UserID != user.ID {
-		c.RenderError(w, r, errors.New("Unauthorized"))
-		return
+// renderTodoItems returns the todo list HTML shared by the panel and its live updates
+func renderTodoItems(todos []*models.Todo) string {
+	if len(todos) == 0 {
+		return `<div class="text-sm text-base-content/60 italic py-2">No tasks yet. The AI will create tasks as needed during complex operations.</div>`
 	}
 
-	// Get todos
-	todos, err := models.GetTodosByConversation(conversationID)
-	if err != nil {
-		todos = []*models.Todo{}
-	}
-
-	// Render just the todo items
-	w.Header().Set("Content-Type", "text/html")
+	var b strings.Builder
+	completedCount := 0
 	for _, todo := range todos {
 		statusIcon := ""
 		contentClass := "text-sm text-base-content/80"
@@ -1933,6 +2274,7 @@ func (c *AIController) getTodos(w http.ResponseWriter, r *http.Request) {
 		case models.TodoStatusCompleted:
 			statusIcon = `<input type="checkbox" checked disabled class="checkbox checkbox-xs checkbox-success mt-0.5" />`
 			contentClass = "text-sm line-through text-base-content/50"
+			completedCount++
 		case models.TodoStatusInProgress:
 			statusIcon = `<span class="loading loading-spinner loading-xs text-primary mt-0.5"></span>`
 			contentClass = "text-sm text-primary font-medium"
@@ -1940,14 +2282,35 @@ func (c *AIController) getTodos(w http.ResponseWriter, r *http.Request) {
 			statusIcon = `<input type="checkbox" disabled class="checkbox checkbox-xs mt-0.5" />`
 		}
 
-		fmt.Fprintf(w, `<div class="flex items-start gap-2 py-1 group">
-			%s
-			<span class="%s">%s</span>
-		</div>`, statusIcon, contentClass, template.HTMLEscapeString(todo.Content))
+		fmt.Fprintf(&b, `<div class="flex items-start gap-2 py-1 group">%s<span class="%s">%s</span></div>`,
+			statusIcon, contentClass, template.HTMLEscapeString(todo.Content))
+	}
+
+	if completedCount > 0 {
+		fmt.Fprintf(&b, `<div class="mt-3 pt-3 border-t border-base-300"><div class="w-full bg-base-300 rounded-full h-1.5"><div class="bg-primary h-1.5 rounded-full transition-all duration-500" style="width: %d%%"></div></div></div>`,
+			completedCount*100/len(todos))
+	}
+	return b.String()
+}
+
+// renderTodoProgress returns the completion badge shown in the todo panel header
+func renderTodoProgress(todos []*models.Todo) string {
+	if len(todos) == 0 {
+		return ""
+	}
+
+	completedCount := 0
+	for _, todo := range todos {
+		if todo.Status == models.TodoStatusCompleted {
+			completedCount++
+		}
 	}
+
+	return fmt.Sprintf(`<span class="badge badge-sm badge-primary">%d/%d</span><span class="text-xs text-base-content/60">%d%% complete</span>`,
+		completedCount, len(todos), completedCount*100/len(todos))
 }
 
-// streamTodos provides SSE endpoint for todo updates
+// streamTodos pushes the todo list to the browser whenever it changes
 func (c *AIController) streamTodos(w http.ResponseWriter, r *http.Request) {
 	c.SetRequest(r)
 
@@ -1967,38 +2330,74 @@ func (c *AIController) streamTodos(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Set SSE headers
-	w.Header().Set("Content-Type", "text/event-stream")
-	w.Header().Set("Cache-Control", "no-cache")
-	w.Header().Set("Connection", "keep-alive")
-
-	flusher, ok := w.(http.Flusher)
-	if !ok {
-		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+	stream, err := sse.Streams.Open(w, r, "todos", user.ID)
+	if err != nil {
 		return
 	}
+	defer stream.Close()
 
-	// Send initial connection event
-	fmt.Fprintf(w, "event: connected\ndata: Todo stream connected\n\n")
-	flusher.Flush()
+	// Subscribe before announcing the connection so no change is missed
+	changes, unsubscribe := models.SubscribeTodos(conversationID)
+	defer unsubscribe()
 
-	// Keep connection alive with periodic pings
-	// In a real implementation, this would watch for todo changes
-	// For now, just keep the connection open
-	ticker := time.NewTicker(30 * time.Second)
-	defer ticker.Stop()
+	stream.Send("connected", "Todo stream connected")
 
 	for {
 		select {
-		case <-ticker.C:
-			fmt.Fprintf(w, "event: ping\ndata: keepalive\n\n")
-			flusher.Flush()
+		case <-changes:
+			todos, err := models.GetTodosByConversation(conversationID)
+			if err != nil {
+				log.Printf("AIController: Failed to load todos for %s: %v", conversationID, err)
+				continue
+			}
+			stream.Send("todo-updated", renderTodoItems(todos))
+			stream.Send("todo-progress", renderTodoProgress(todos))
+		case <-stream.Heartbeat():
+			stream.Ping()
 		case <-r.Context().Done():
 			return
 		}
 	}
 }
 
+// startExecution registers a cancellable run for a conversation, replacing
+// any run already in progress, and returns its context and a cleanup func
+func (c *AIController) startExecution(parent context.Context, conversationID string) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(parent)
+	run := &execution{cancel: cancel}
+
+	c.executionsMu.Lock()
+	if previous, ok := c.executions[conversationID]; ok {
+		previous.cancel()
+	}
+	c.executions[conversationID] = run
+	c.executionsMu.Unlock()
+
+	finish := func() {
+		c.executionsMu.Lock()
+		if c.executions[conversationID] == run {
+			delete(c.executions, conversationID)
+		}
+		c.executionsMu.Unlock()
+		cancel()
+	}
+	return ctx, finish
+}
+
+// cancelExecution cancels the running execution for a conversation and
+// reports whether there was one
+func (c *AIController) cancelExecution(conversationID string) bool {
+	c.executionsMu.Lock()
+	defer c.executionsMu.Unlock()
+
+	run, ok := c.executions[conversationID]
+	if ok {
+		run.cancel()
+		delete(c.executions, conversationID)
+	}
+	return ok
+}
+
 // stopExecution handles cancellation of AI execution
 func (c *AIController) stopExecution(w http.ResponseWriter, r *http.Request) {
 	c.SetRequest(r)
@@ -2017,15 +2416,102 @@ func (c *AIController) stopExecution(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// The actual cancellation is handled by context.Done() in streamResponse
-	// This endpoint just acknowledges the request
-	log.Printf("AIController: Stop execution requested for conversation %s", conversationID)
+	if c.cancelExecution(conversationID) {
+		log.Printf("AIController: Stopped execution for conversation %s", conversationID)
+	} else {
+		log.Printf("AIController: Stop requested but no execution running for conversation %s", conversationID)
+	}
 
 	// For HTMX requests, use c.Refresh to properly handle the response
 	// This will trigger the appropriate HTMX behavior
 	c.Refresh(w, r)
 }
 
+// updateModel sets the Ollama model used by a conversation (admin only)
+func (c *AIController) updateModel(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+
+	conversationID := r.PathValue("id")
+	user, _, err := c.App.Use("auth").(*AuthController).Authenticate(r)
+	if err != nil || !user.IsAdmin {
+		c.RenderError(w, r, errors.New("Admin access required"))
+		return
+	}
+
+	// Verify ownership
+	conversation, err := models.Conversations.Get(conversationID)
+	if err != nil || conversation.UserID != user.ID {
+		c.RenderError(w, r, errors.New("Conversation not found"))
+		return
+	}
+
+	// An empty model resets the conversation to the workspace default
+	model := strings.TrimSpace(r.FormValue("model"))
+	if model != "" && !slices.Contains(c.AvailableModels(), model) {
+		c.RenderError(w, r, errors.New("Model is not installed"))
+		return
+	}
+
+	conversation.ModelName = model
+	if err := models.Conversations.Update(conversation); err != nil {
+		log.Printf("AIController: Failed to update conversation model: %v", err)
+		c.RenderError(w, r, errors.New("Failed to update model"))
+		return
+	}
+
+	log.Printf("AIController: Conversation %s now uses model %q", conversationID, model)
+	w.Write([]byte(""))
+}
+
+// providerFor returns the provider for a conversation, honouring its model
+// override and falling back to the workspace default provider
+func (c *AIController) providerFor(conversation *models.Conversation) agents.Provider {
+	// The default can be changed from the settings page after startup
+	model := services.Ollama.GetDefaultModel()
+	if conversation != nil && conversation.ModelName != "" {
+		model = conversation.ModelName
+	}
+	// External models don't need Ollama, so they work while it's loading
+	if providers.IsExternalModel(model) && slices.Contains(providers.ExternalModels(), model) {
+		provider, err := providers.NewExternalProvider(model)
+		if err == nil {
+			return provider
+		}
+		log.Printf("AIController: External model %s is unavailable: %v", model, err)
+	}
+	if c.provider == nil || model == c.provider.Model() {
+		return c.provider
+	}
+
+	provider, err := providers.GetProviderForModel(model)
+	if err != nil {
+		log.Printf("AIController: Falling back to %s instead of %s: %v", c.provider.Model(), model, err)
+		return c.provider
+	}
+	return provider
+}
+
+// AvailableModels returns the models installed in Ollama, followed by the
+// external provider's models, for template use
+func (c *AIController) AvailableModels() []string {
+	external := providers.ExternalModels()
+	if !services.Ollama.IsRunning() {
+		return external
+	}
+
+	installed, err := services.Ollama.ListModels()
+	if err != nil {
+		log.Printf("AIController: Failed to list models: %v", err)
+		return external
+	}
+	return append(installed, external...)
+}
+
+// DefaultModel returns the workspace default model for template use
+func (c *AIController) DefaultModel() string {
+	return services.Ollama.GetDefaultModel()
+}
+
 // compressToolOutput compresses verbose tool outputs to save context window space
 func (c *AIController) compressToolOutput(toolName string, output string) string {
 	lines := strings.Split(output, "\n")
@@ -2168,11 +2654,58 @@ func (c *AIController) extractContextFromToolCall(toolName string, params map[st
 		if path, ok := params["path"]; ok {
 			context["current_directory"] = path
 		}
+
+	case "git_branch_create", "git_checkout":
+		// Track the working branch, unless the switch failed
+		if strings.HasPrefix(result, "❌") {
+			break
+		}
+		branch := params["branch"]
+		if toolName == "git_branch_create" {
+			branch = params["name"]
+		}
+		context["current_repo_id"] = params["repo_id"]
+		context["current_branch"] = branch
 	}
 
 	return context
 }
 
+// branchTools are the tools that accept a branch and default to the
+// repository's default branch when none is given
+var branchTools = map[string]bool{
+	"list_files":   true,
+	"read_file":    true,
+	"search_files": true,
+	"write_file":   true,
+	"edit_file":    true,
+	"delete_file":  true,
+	"move_file":    true,
+	"git_status":   true,
+	"git_history":  true,
+}
+
+// applyWorkingBranch points branch-aware tool calls at the branch the
+// conversation switched to, when the call is for the same repository
+func (c *AIController) applyWorkingBranch(conversationID, toolName string, params map[string]any) {
+	if !branchTools[toolName] {
+		return
+	}
+	if branch, _ := params["branch"].(string); branch != "" {
+		return
+	}
+
+	conversation, err := models.Conversations.Get(conversationID)
+	if err != nil {
+		return
+	}
+	context := conversation.GetWorkingContext()
+	branch, _ := context["current_branch"].(string)
+	if branch != "" && params["repo_id"] == context["current_repo_id"] {
+		params["branch"] = branch
+	}
+}
+
 // resolveContextualReferences enhances user messages with context
 func (c *AIController) resolveContextualReferences(message string, workingContext map[string]any) string {
 	lowerMessage := strings.ToLower(message)
@@ -2215,8 +2748,28 @@ func (c *AIController) resolveContextualReferences(message string, workingContex
 	return message
 }
 
-// buildContextWindow creates an optimized context window for the AI
-func (c *AIController) buildContextWindow(conversation *models.Conversation, maxMessages int) []services.OllamaMessage {
+// Context budgeting parameters for buildContextWindow
+const (
+	contextResponseReserve = 0.25 // Fraction of the context window kept free for the reply and tool schemas
+	contextRecentMessages  = 4    // Most recent messages that are always kept, truncated if necessary
+	contextSummaryTokens   = 300  // Upper bound for the summary of dropped messages
+)
+
+// contextBudget returns how many prompt tokens the provider's context window can hold
+func (c *AIController) contextBudget(provider agents.Provider) int {
+	maxTokens := 8192
+	if provider != nil && provider.MaxContextTokens() > 0 {
+		maxTokens = provider.MaxContextTokens()
+	}
+	return int(float64(maxTokens) * (1 - contextResponseReserve))
+}
+
+// buildContextWindow creates an optimized context window for the AI. System messages
+// are always kept; conversation messages are selected newest first until tokenBudget
+// is spent, and dropped middle messages are replaced by a short summary. It returns
+// the messages along with the number of tokens trimmed to fit the budget. Images
+// are only included when the model can see them.
+func (c *AIController) buildContextWindow(conversation *models.Conversation, maxMessages int, tokenBudget int, vision bool) ([]services.OllamaMessage, int) {
 	messages, _ := conversation.GetMessages()
 	context := []services.OllamaMessage{
 		{
@@ -2241,6 +2794,7 @@ func (c *AIController) buildContextWindow(conversation *models.Conversation, max
 		startIdx = len(messages) - maxMessages
 	}
 
+	var candidates []services.OllamaMessage
 	for i := startIdx; i < len(messages); i++ {
 		msg := messages[i]
 
@@ -2269,13 +2823,116 @@ func (c *AIController) buildContextWindow(conversation *models.Conversation, max
 			role = "tool"
 		}
 
-		context = append(context, services.OllamaMessage{
+		// Screenshots go to vision models; others are told they can't see them
+		var images []string
+		if msg.Role == models.MessageRoleUser && msg.ImageCount > 0 {
+			if vision {
+				images = msg.EncodedImages()
+			} else {
+				content += fmt.Sprintf("\n\n[%d image(s) attached. The current model cannot view images; "+
+					"say so and suggest switching this conversation to a vision model such as llava.]", msg.ImageCount)
+			}
+		}
+
+		// Code retrieved for a question goes right before it
+		if sources := msg.Sources(); len(sources) > 0 {
+			candidates = append(candidates, services.OllamaMessage{
+				Role:    "system",
+				Content: retrievalPrompt(sources),
+			})
+		}
+
+		candidates = append(candidates, services.OllamaMessage{
 			Role:    role,
 			Content: content,
+			Images:  images,
 		})
 	}
 
-	return context
+	// System messages are never trimmed
+	remaining := tokenBudget
+	for _, msg := range context {
+		remaining -= agents.EstimateMessageTokens(msg.Role, msg.Content)
+	}
+
+	// Walk backwards from the newest message, keeping what fits
+	trimmed := 0
+	firstKept := len(candidates)
+	for i := len(candidates) - 1; i >= 0; i-- {
+		msg := candidates[i]
+		cost := agents.EstimateMessageTokens(msg.Role, msg.Content)
+
+		if cost > remaining {
+			if len(candidates)-i > contextRecentMessages {
+				break
+			}
+
+			// Recent turns are kept, but oversized content is cut down to fit
+			allowed := remaining - agents.EstimateMessageTokens(msg.Role, "")
+			if allowed < 64 {
+				allowed = 64
+			}
+			shortened := agents.TruncateToTokens(msg.Content, allowed)
+			trimmed += cost - agents.EstimateMessageTokens(msg.Role, shortened)
+			candidates[i].Content = shortened
+			cost = agents.EstimateMessageTokens(msg.Role, shortened)
+		}
+
+		remaining -= cost
+		firstKept = i
+	}
+
+	// Summarize anything that was dropped from the middle of the conversation
+	if firstKept > 0 {
+		dropped := candidates[:firstKept]
+		for _, msg := range dropped {
+			trimmed += agents.EstimateMessageTokens(msg.Role, msg.Content)
+		}
+
+		summary := summarizeDroppedMessages(dropped, contextSummaryTokens)
+		trimmed -= agents.EstimateMessageTokens("system", summary)
+		context = append(context, services.OllamaMessage{
+			Role:    "system",
+			Content: summary,
+		})
+	}
+
+	if trimmed > 0 {
+		log.Printf("AIController: Context trimmed by ~%d tokens (%d of %d messages kept, budget %d)",
+			trimmed, len(candidates)-firstKept, len(candidates), tokenBudget)
+	}
+
+	return append(context, candidates[firstKept:]...), trimmed
+}
+
+// summarizeDroppedMessages condenses messages that no longer fit the context window
+// into a single note listing what the user asked and which tools ran
+func summarizeDroppedMessages(dropped []services.OllamaMessage, maxTokens int) string {
+	var requests []string
+	toolResults := 0
+	for _, msg := range dropped {
+		switch msg.Role {
+		case "user":
+			line := strings.TrimSpace(strings.SplitN(msg.Content, "\n", 2)[0])
+			if len(line) > 120 {
+				line = line[:117] + "..."
+			}
+			requests = append(requests, "- "+line)
+		case "tool":
+			toolResults++
+		}
+	}
+
+	summary := fmt.Sprintf("Earlier in this conversation (%d messages omitted to fit the context window", len(dropped))
+	if toolResults > 0 {
+		summary += fmt.Sprintf(", including %d tool results", toolResults)
+	}
+	summary += ")."
+	if len(requests) > 0 {
+		summary += " The user asked:\n" + strings.Join(requests, "\n")
+	}
+
+	return agents.TruncateToTokens(summary, maxTokens)
 }
 
 // buildSystemPromptWithAutonomy creates an enhanced system prompt for autonomous execution
@@ -2446,12 +3103,13 @@ func (c *AIController) GetTodayStats() map[string]int {
 // GetFeatureStatus returns the status of AI features
 func (c *AIController) GetFeatureStatus() map[string]bool {
 	status := map[string]bool{
-		"issue_triage":     false,
-		"pr_review":        false,
-		"auto_approve":     false,
-		"daily_reports":    false,
-		"stale_management": false,
-		"security_scan":    false,
+		"issue_triage":      false,
+		"pr_review":         false,
+		"auto_approve":      false,
+		"daily_reports":     false,
+		"stale_management":  false,
+		"security_scan":     false,
+		"dependency_update": false,
 	}
 
 	if ai := c.getAIService(); ai != nil {
@@ -2462,6 +3120,7 @@ func (c *AIController) GetFeatureStatus() map[string]bool {
 		status["daily_reports"] = config.DailyReports
 		status["stale_management"] = config.StaleManagement
 		status["security_scan"] = config.SecurityScan
+		status["dependency_update"] = config.DependencyUpdate
 	}
 
 	return status
@@ -2888,6 +3547,8 @@ func (c *AIController) toggleFeature(w http.ResponseWriter, r *http.Request) {
 			config.StaleManagement = !config.StaleManagement
 		case "security_scan":
 			config.SecurityScan = !config.SecurityScan
+		case "dependency_update":
+			config.DependencyUpdate = !config.DependencyUpdate
 		default:
 			c.RenderError(w, r, errors.New("Unknown feature"))
 			return
@@ -0,0 +1,105 @@
+package controllers
+
+import (
+	"log"
+	"sort"
+	"strconv"
+	"time"
+
+	"workspace/models"
+
+	"github.com/The-Skyscape/devtools/pkg/authentication"
+)
+
+// recordToolExecution adds a tool call to the audit log
+func (c *AIController) recordToolExecution(toolName string, params map[string]any, result string, err error, duration time.Duration, conversationID, userID string) {
+	if _, recordErr := models.RecordToolExecution(toolName, params, result, err, duration, conversationID, userID); recordErr != nil {
+		log.Printf("AIController: Failed to record %s execution: %v", toolName, recordErr)
+	}
+}
+
+// AuditFilter returns the audit log filter from the request query. Dates
+// are whole days, so the end date includes everything up to midnight.
+func (c *AIController) AuditFilter() models.ToolExecutionFilter {
+	query := c.Request.URL.Query()
+	params := GetSearchParams(c.Request)
+
+	filter := models.ToolExecutionFilter{
+		ToolName: query.Get("tool"),
+		RepoID:   query.Get("repo"),
+		UserID:   query.Get("user"),
+		Limit:    params.Limit,
+		Offset:   (params.Page - 1) * params.Limit,
+	}
+	if from, err := time.ParseInLocation("2006-01-02", query.Get("from"), time.Local); err == nil {
+		filter.StartTime = from
+	}
+	if to, err := time.ParseInLocation("2006-01-02", query.Get("to"), time.Local); err == nil {
+		filter.EndTime = to.AddDate(0, 0, 1)
+	}
+	return filter
+}
+
+// AuditParam returns a raw filter value so the form can show it again
+func (c *AIController) AuditParam(name string) string {
+	return c.Request.URL.Query().Get(name)
+}
+
+// ToolExecutions returns the page of the audit log matching the filter
+func (c *AIController) ToolExecutions() ([]*models.ToolExecution, error) {
+	return models.QueryToolExecutions(c.AuditFilter())
+}
+
+// AuditToolNames returns the tool names that can be filtered on
+func (c *AIController) AuditToolNames() []string {
+	var names []string
+	if c.toolRegistry != nil {
+		for name := range c.toolRegistry.ListTools() {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// AuditRepos returns the repositories that can be filtered on
+func (c *AIController) AuditRepos() ([]*models.Repository, error) {
+	return models.Repositories.Search("ORDER BY Name ASC")
+}
+
+// AuditUsers returns the users that can be filtered on
+func (c *AIController) AuditUsers() ([]*authentication.User, error) {
+	return models.Users.Search("ORDER BY Name ASC")
+}
+
+// AuditPage returns the current page number of the audit log
+func (c *AIController) AuditPage() int {
+	return GetSearchParams(c.Request).Page
+}
+
+// AuditPrevURL returns the link to the previous page, empty on the first
+func (c *AIController) AuditPrevURL() string {
+	page := c.AuditPage()
+	if page <= 1 {
+		return ""
+	}
+	return c.auditPageURL(page - 1)
+}
+
+// AuditNextURL returns the link to the next page, empty on the last
+func (c *AIController) AuditNextURL() string {
+	filter := c.AuditFilter()
+	filter.Offset += filter.Limit
+	filter.Limit = 1
+	if more, err := models.QueryToolExecutions(filter); err != nil || len(more) == 0 {
+		return ""
+	}
+	return c.auditPageURL(c.AuditPage() + 1)
+}
+
+// auditPageURL returns the audit log URL for page, keeping the filters
+func (c *AIController) auditPageURL(page int) string {
+	query := c.Request.URL.Query()
+	query.Set("page", strconv.Itoa(page))
+	return "/ai/audit?" + query.Encode()
+}
@@ -0,0 +1,90 @@
+package controllers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"workspace/internal/aicache"
+	"workspace/models"
+	"workspace/services"
+)
+
+// configureResponseCache applies AI_CACHE_TTL, a duration such as 5m, or 0
+// to turn the response cache off
+func configureResponseCache() {
+	value := os.Getenv("AI_CACHE_TTL")
+	if value == "" {
+		return
+	}
+	ttl, err := time.ParseDuration(value)
+	if value == "0" {
+		ttl, err = 0, nil
+	}
+	if err != nil {
+		log.Printf("AIController: Ignoring AI_CACHE_TTL %q: %v", value, err)
+		return
+	}
+	aicache.Responses.SetTTL(ttl)
+}
+
+// responseCacheKey returns the cache key for the question the messages end
+// with, or "" when the answer can't be cached. The key covers the user and
+// every other message, so an answer is only reused for the same question in
+// the same state of a conversation.
+func responseCacheKey(userID, model string, messages []services.OllamaMessage) string {
+	question := -1
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "user" {
+			question = i
+			break
+		}
+	}
+	// Screenshots aren't part of the key, so questions about them aren't cached
+	if question < 0 || len(messages[question].Images) > 0 {
+		return ""
+	}
+
+	context := sha256.New()
+	context.Write([]byte(userID))
+	for i, msg := range messages {
+		if i == question {
+			continue
+		}
+		context.Write([]byte("\x00" + msg.Role + "\x00" + msg.Content))
+	}
+	return aicache.Key(model, messages[question].Content, hex.EncodeToString(context.Sum(nil)))
+}
+
+// toggleCache handles POST /ai/chat/{id}/cache, letting a conversation
+// bypass the response cache so every answer comes from the model
+func (c *AIController) toggleCache(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+
+	conversationID := r.PathValue("id")
+	user, _, err := c.App.Use("auth").(*AuthController).Authenticate(r)
+	if err != nil || !user.IsAdmin {
+		c.RenderError(w, r, errors.New("Admin access required"))
+		return
+	}
+
+	// Verify ownership
+	conversation, err := models.Conversations.Get(conversationID)
+	if err != nil || conversation.UserID != user.ID {
+		c.RenderError(w, r, errors.New("Conversation not found"))
+		return
+	}
+
+	enabled := r.FormValue("cache") == "true"
+	if err := conversation.UpdateSetting("cache", enabled); err != nil {
+		log.Printf("AIController: Failed to update cache setting: %v", err)
+		c.RenderError(w, r, errors.New("Failed to update conversation"))
+		return
+	}
+
+	w.Write([]byte(""))
+}
@@ -0,0 +1,214 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"workspace/models"
+)
+
+// Kinds of content that can be explained
+const (
+	ExplainFile       = "file"
+	ExplainCommitDiff = "diff"
+	ExplainPRDiff     = "pr_diff"
+	ExplainCILog      = "ci_log"
+	ExplainStackTrace = "stack_trace"
+)
+
+// explainMaxChars bounds how much content is pasted into the conversation,
+// leaving room in the context window for the answer and tool calls
+const explainMaxChars = 12000
+
+// commitHashPattern guards the hash passed to git from option injection
+var commitHashPattern = regexp.MustCompile(`^[0-9a-fA-F]{4,64}$`)
+
+// ExplainVals returns the hx-vals for an "Explain with AI" button, taking
+// the repository and content reference from the page being rendered
+func (c *AIController) ExplainVals(kind string) string {
+	vals := map[string]string{
+		"kind":    kind,
+		"repo_id": c.Request.PathValue("id"),
+	}
+
+	switch kind {
+	case ExplainFile:
+		vals["ref"] = c.Request.PathValue("path")
+		vals["branch"] = c.Request.URL.Query().Get("branch")
+	case ExplainCommitDiff:
+		vals["ref"] = c.Request.PathValue("hash")
+		if vals["ref"] == "" {
+			vals["ref"] = c.Request.URL.Query().Get("commit")
+		}
+	case ExplainPRDiff:
+		vals["ref"] = c.Request.PathValue("prID")
+	case ExplainCILog:
+		vals["ref"] = c.Request.PathValue("actionID")
+	case ExplainStackTrace:
+		vals["ref"] = c.Request.PathValue("issueID")
+	}
+
+	data, _ := json.Marshal(vals)
+	return string(data)
+}
+
+// explain handles POST /ai/explain by opening a new conversation seeded
+// with the selected content and bound to its repository
+func (c *AIController) explain(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+
+	user, _, err := c.App.Use("auth").(*AuthController).Authenticate(r)
+	if err != nil || !user.IsAdmin {
+		c.RenderError(w, r, errors.New("Admin access required"))
+		return
+	}
+
+	if err := models.CheckQuota(user.ID, models.UsageAITokens); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	repo, err := models.Repositories.Get(r.FormValue("repo_id"))
+	if err != nil {
+		c.RenderError(w, r, errors.New("Repository not found"))
+		return
+	}
+
+	kind, ref := r.FormValue("kind"), r.FormValue("ref")
+	title, prompt, err := explainPrompt(repo, kind, ref, r.FormValue("branch"))
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	if len(title) > 50 {
+		title = title[:47] + "..."
+	}
+	conversation, err := models.Conversations.Insert(&models.Conversation{
+		UserID: user.ID,
+		Title:  title,
+	})
+	if err != nil {
+		log.Printf("AIController: Failed to create conversation: %v", err)
+		c.RenderError(w, r, errors.New("Failed to create conversation"))
+		return
+	}
+
+	// Bind the conversation to the repository so follow-up questions and
+	// tool calls default to it
+	context := map[string]any{
+		"current_repo_id":   repo.ID,
+		"current_repo_name": repo.Name,
+	}
+	if kind == ExplainFile {
+		context["current_file_path"] = ref
+	}
+	c.updateWorkingContext(conversation.ID, context)
+
+	if _, err := models.Messages.Insert(&models.Message{
+		ConversationID: conversation.ID,
+		Role:           models.MessageRoleUser,
+		Content:        prompt,
+	}); err != nil {
+		log.Printf("AIController: Failed to save explain message: %v", err)
+		c.RenderError(w, r, errors.New("Failed to start conversation"))
+		return
+	}
+
+	// The chat view starts streaming the answer when it loads the messages
+	conversation, _ = models.Conversations.Get(conversation.ID)
+	conversation.UpdateSetting("autoRespond", true)
+
+	c.Render(w, r, "ai-chat.html", conversation)
+}
+
+// explainPrompt builds the conversation title and opening message for the
+// content being explained
+func explainPrompt(repo *models.Repository, kind, ref, branch string) (title, prompt string, err error) {
+	switch kind {
+	case ExplainFile:
+		if ref == "" || strings.Contains(ref, "..") {
+			return "", "", errors.New("invalid file path")
+		}
+		file, err := repo.GetFile(branch, ref)
+		if err != nil {
+			return "", "", errors.New("file not found")
+		}
+		if file.IsBinary {
+			return "", "", errors.New("binary files cannot be explained")
+		}
+		return "Explain " + file.Name,
+			fmt.Sprintf("Explain what the file `%s` in repository %s (ID: %s) does. Describe its purpose, how it is structured and how it fits into the rest of the project.\n\n```%s\n%s\n```",
+				ref, repo.Name, repo.ID, file.Language, truncateExplain(file.Content, false)), nil
+
+	case ExplainCommitDiff:
+		if !commitHashPattern.MatchString(ref) {
+			return "", "", errors.New("invalid commit hash")
+		}
+		diff, err := repo.GetCommitDiffContent(ref)
+		if err != nil {
+			return "", "", errors.New("commit not found")
+		}
+		return "Explain commit " + ref[:min(len(ref), 7)],
+			fmt.Sprintf("Explain the changes made in commit %s of repository %s (ID: %s). Summarize what changed and why it matters, and point out anything that looks risky.\n\n```diff\n%s\n```",
+				ref, repo.Name, repo.ID, truncateExplain(diff, false)), nil
+
+	case ExplainPRDiff:
+		pr, err := models.PullRequests.Get(ref)
+		if err != nil || pr.RepoID != repo.ID {
+			return "", "", errors.New("pull request not found")
+		}
+		diff, err := repo.GetPRDiffContent(pr.BaseBranch, pr.CompareBranch)
+		if err != nil {
+			return "", "", errors.New("failed to load pull request changes")
+		}
+		return "Explain PR: " + pr.Title,
+			fmt.Sprintf("Explain the changes in pull request \"%s\" (%s into %s) of repository %s (ID: %s). Summarize what changed and point out anything reviewers should look at closely.\n\n```diff\n%s\n```",
+				pr.Title, pr.CompareBranch, pr.BaseBranch, repo.Name, repo.ID, truncateExplain(diff, false)), nil
+
+	case ExplainCILog:
+		action, err := models.Actions.Get(ref)
+		if err != nil || action.RepoID != repo.ID {
+			return "", "", errors.New("action not found")
+		}
+		run, err := models.GetLatestRunByAction(action.ID)
+		if err != nil {
+			return "", "", errors.New("action has not run yet")
+		}
+		return "Explain failure: " + action.Title,
+			fmt.Sprintf("The CI action \"%s\" in repository %s (ID: %s) finished with status %s and exit code %d. Explain why it failed and suggest how to fix it.\n\n```\n%s\n```",
+				action.Title, repo.Name, repo.ID, run.Status, run.ExitCode, truncateExplain(run.Output, true)), nil
+
+	case ExplainStackTrace:
+		issue, err := models.Issues.Get(ref)
+		if err != nil || issue.RepoID != repo.ID {
+			return "", "", errors.New("issue not found")
+		}
+		trace := issue.StackTrace()
+		if trace == "" {
+			return "", "", errors.New("no stack trace found in this issue")
+		}
+		return "Explain trace: " + issue.Title,
+			fmt.Sprintf("Issue \"%s\" in repository %s (ID: %s) includes this stack trace. Explain what went wrong, find the code involved in the repository and suggest a fix.\n\n```\n%s\n```",
+				issue.Title, repo.Name, repo.ID, truncateExplain(trace, false)), nil
+	}
+
+	return "", "", fmt.Errorf("cannot explain %q", kind)
+}
+
+// truncateExplain shortens content to explainMaxChars. Logs keep their end,
+// where failures are reported, everything else keeps its beginning.
+func truncateExplain(content string, keepEnd bool) string {
+	if len(content) <= explainMaxChars {
+		return content
+	}
+	if keepEnd {
+		return "... (earlier output truncated)\n" + strings.ToValidUTF8(content[len(content)-explainMaxChars:], "")
+	}
+	return strings.ToValidUTF8(content[:explainMaxChars], "") + "\n... (truncated)"
+}
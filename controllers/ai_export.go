@@ -0,0 +1,115 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"workspace/models"
+)
+
+// maxConversationImport bounds an uploaded export, images included
+const maxConversationImport = 64 << 20
+
+var exportFilenameUnsafe = regexp.MustCompile(`[^a-z0-9]+`)
+
+// exportConversation handles GET /ai/conversations/{id}/export, downloading
+// the conversation as a Markdown transcript or, with ?format=json, in the
+// form accepted by importConversation
+func (c *AIController) exportConversation(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+
+	user, _, err := c.App.Use("auth").(*AuthController).Authenticate(r)
+	if err != nil || !user.IsAdmin {
+		c.RenderError(w, r, errors.New("Admin access required"))
+		return
+	}
+
+	conversation, err := models.Conversations.Get(r.PathValue("id"))
+	if err != nil || conversation.UserID != user.ID {
+		c.RenderError(w, r, errors.New("Conversation not found"))
+		return
+	}
+
+	var body []byte
+	var contentType, extension string
+	switch r.URL.Query().Get("format") {
+	case "json":
+		export, err := conversation.Export()
+		if err != nil {
+			c.RenderError(w, r, err)
+			return
+		}
+		if body, err = json.MarshalIndent(export, "", "  "); err != nil {
+			c.RenderError(w, r, err)
+			return
+		}
+		contentType, extension = "application/json", "json"
+	default:
+		transcript, err := conversation.Markdown()
+		if err != nil {
+			c.RenderError(w, r, err)
+			return
+		}
+		body = []byte(transcript)
+		contentType, extension = "text/markdown; charset=utf-8", "md"
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": exportFilename(conversation, extension),
+	}))
+	w.Write(body)
+}
+
+// importConversation handles POST /ai/conversations/import, recreating a
+// conversation from a JSON export and opening it
+func (c *AIController) importConversation(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+
+	user, _, err := c.App.Use("auth").(*AuthController).Authenticate(r)
+	if err != nil || !user.IsAdmin {
+		c.RenderError(w, r, errors.New("Admin access required"))
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxConversationImport)
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		c.RenderError(w, r, fmt.Errorf("choose a conversation export of up to %d MB", maxConversationImport>>20))
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		c.RenderError(w, r, errors.New("failed to read the uploaded file"))
+		return
+	}
+
+	conversation, err := models.ImportConversation(user.ID, data)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	c.Render(w, r, "ai-chat.html", conversation)
+}
+
+// exportFilename names a download after the conversation title and date
+func exportFilename(conversation *models.Conversation, extension string) string {
+	slug := strings.Trim(exportFilenameUnsafe.ReplaceAllString(strings.ToLower(conversation.Title), "-"), "-")
+	if len(slug) > 40 {
+		slug = strings.TrimRight(slug[:40], "-")
+	}
+	if slug == "" {
+		slug = "conversation"
+	}
+	return fmt.Sprintf("%s-%s.%s", slug, time.Now().Format("2006-01-02"), extension)
+}
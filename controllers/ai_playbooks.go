@@ -0,0 +1,141 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"workspace/models"
+)
+
+// Playbooks returns the predefined AI workflows offered in the AI panel
+func (c *AIController) Playbooks() []*models.Playbook {
+	return models.Playbooks
+}
+
+// startPlaybook handles POST /ai/playbooks/{id} by opening a conversation
+// bound to the chosen repository and seeded with the playbook's prompt
+func (c *AIController) startPlaybook(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+
+	user, _, err := c.App.Use("auth").(*AuthController).Authenticate(r)
+	if err != nil || !user.IsAdmin {
+		c.RenderError(w, r, errors.New("Admin access required"))
+		return
+	}
+
+	if err := models.CheckQuota(user.ID, models.UsageAITokens); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	playbook := models.GetPlaybook(r.PathValue("id"))
+	if playbook == nil {
+		c.RenderError(w, r, errors.New("Playbook not found"))
+		return
+	}
+
+	repo, err := models.Repositories.Get(r.FormValue("repo_id"))
+	if err != nil {
+		c.RenderError(w, r, errors.New("Choose a repository"))
+		return
+	}
+
+	input := strings.TrimSpace(r.FormValue("input"))
+	if playbook.NeedsInput && input == "" {
+		c.RenderError(w, r, fmt.Errorf("%s is required", playbook.InputLabel))
+		return
+	}
+	if playbook.ID == "write-tests" && strings.Contains(input, "..") {
+		c.RenderError(w, r, errors.New("invalid file path"))
+		return
+	}
+
+	conversation, err := models.Conversations.Insert(&models.Conversation{
+		UserID: user.ID,
+		Title:  playbook.Name + " · " + repo.Name,
+	})
+	if err != nil {
+		log.Printf("AIController: Failed to create playbook conversation: %v", err)
+		c.RenderError(w, r, errors.New("Failed to create conversation"))
+		return
+	}
+
+	context := map[string]any{
+		"current_repo_id":   repo.ID,
+		"current_repo_name": repo.Name,
+		"current_branch":    repo.GetDefaultBranch(),
+	}
+	if playbook.ID == "write-tests" {
+		context["current_file_path"] = input
+	}
+	c.updateWorkingContext(conversation.ID, context)
+
+	prompt := playbook.Render(c.promptVariables(conversation.ID), input)
+	if playbook.ID == "investigate-ci" {
+		prompt += failedRunContext(repo)
+	}
+
+	if _, err := models.Messages.Insert(&models.Message{
+		ConversationID: conversation.ID,
+		Role:           models.MessageRoleUser,
+		Content:        prompt,
+	}); err != nil {
+		log.Printf("AIController: Failed to save playbook message: %v", err)
+		c.RenderError(w, r, errors.New("Failed to start conversation"))
+		return
+	}
+
+	// The playbook setting limits the tools offered while streaming, and the
+	// chat view starts the answer as soon as it loads
+	conversation, _ = models.Conversations.Get(conversation.ID)
+	conversation.UpdateSetting("playbook", playbook.ID)
+	conversation.UpdateSetting("autoRespond", true)
+
+	c.Render(w, r, "ai-chat.html", conversation)
+}
+
+// failedRunContext appends the log of the repository's latest failed action
+// run, so the investigation starts from the actual failure
+func failedRunContext(repo *models.Repository) string {
+	run, err := models.GetLatestFailedRun(repo.ID)
+	if err != nil || run == nil {
+		return "\n\nNo failed action runs were found, so check the actions configured for this repository first."
+	}
+
+	title := run.ActionID
+	if action, err := models.Actions.Get(run.ActionID); err == nil {
+		title = action.Title
+	}
+	return fmt.Sprintf("\n\nThe latest failure is the action \"%s\", which ran on %s at %s and exited with code %d:\n\n```\n%s\n```",
+		title, run.Branch, run.CreatedAt.Format("Jan 2, 3:04 PM"), run.ExitCode, truncateExplain(run.Output, true))
+}
+
+// toolsFor returns the provider's supported tools, narrowed to the
+// conversation's playbook when it was started from one and to the
+// repository tools when it is bound to a repository
+func (c *AIController) toolsFor(conversationID string, supported []string) []string {
+	conversation, err := models.Conversations.Get(conversationID)
+	if err != nil {
+		return supported
+	}
+	id, _ := conversation.GetSettings()["playbook"].(string)
+	playbook := models.GetPlaybook(id)
+	if playbook == nil && conversation.RepoID == "" {
+		return supported
+	}
+
+	var tools []string
+	for _, name := range supported {
+		if playbook != nil && !playbook.AllowsTool(name) {
+			continue
+		}
+		if _, ok := repoChatTools[name]; conversation.RepoID != "" && !ok {
+			continue
+		}
+		tools = append(tools, name)
+	}
+	return tools
+}
@@ -0,0 +1,156 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"workspace/models"
+)
+
+// RepoPrompt pairs a repository context snippet with its repository
+type RepoPrompt struct {
+	Repo   *models.Repository
+	Prompt *models.PromptTemplate
+}
+
+// SystemPrompt returns the system prompt as it will be sent, before
+// variables are filled in
+func (c *AIController) SystemPrompt() string {
+	if custom := models.GetSystemPrompt(); custom != nil {
+		return custom.Content
+	}
+	return defaultSystemPrompt
+}
+
+// HasCustomSystemPrompt reports whether an admin replaced the default prompt
+func (c *AIController) HasCustomSystemPrompt() bool {
+	return models.GetSystemPrompt() != nil
+}
+
+// PromptCommands returns the slash commands available in chat
+func (c *AIController) PromptCommands() []*models.PromptTemplate {
+	commands, _ := models.GetPromptCommands()
+	return commands
+}
+
+// PromptVariables returns the variables prompt templates may use
+func (c *AIController) PromptVariables() []models.PromptVariable {
+	return models.PromptVariables
+}
+
+// RepoPrompts returns the repository context snippets with their repositories
+func (c *AIController) RepoPrompts() []RepoPrompt {
+	prompts, _ := models.GetRepoPrompts()
+	var list []RepoPrompt
+	for _, prompt := range prompts {
+		if repo, err := models.Repositories.Get(prompt.RepoID); err == nil {
+			list = append(list, RepoPrompt{Repo: repo, Prompt: prompt})
+		}
+	}
+	return list
+}
+
+// promptVariables returns the values for {{variables}} in prompts used by
+// a conversation, taken from its working context
+func (c *AIController) promptVariables(conversationID string) map[string]string {
+	vars := map[string]string{
+		"date": time.Now().Format("2006-01-02"),
+	}
+
+	conversation, err := models.Conversations.Get(conversationID)
+	if err != nil {
+		return vars
+	}
+	if user, err := models.Auth.Users.Get(conversation.UserID); err == nil {
+		vars["user"] = user.Name
+	}
+
+	working := conversation.GetWorkingContext()
+	if name, _ := working["current_repo_name"].(string); name != "" {
+		vars["repo"] = name
+	} else if repoID, _ := working["current_repo_id"].(string); repoID != "" {
+		if repo, err := models.Repositories.Get(repoID); err == nil {
+			vars["repo"] = repo.Name
+		}
+	}
+	if branch, _ := working["current_branch"].(string); branch != "" {
+		vars["branch"] = branch
+	} else if repoID, _ := working["current_repo_id"].(string); repoID != "" {
+		if repo, err := models.Repositories.Get(repoID); err == nil {
+			vars["branch"] = repo.GetDefaultBranch()
+		}
+	}
+	return vars
+}
+
+// saveSystemPrompt handles POST /settings/prompts/system. Submitting an
+// empty prompt, or the reset button, restores the default.
+func (c *AIController) saveSystemPrompt(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	user, _, err := c.App.Use("auth").(*AuthController).Authenticate(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	// Browsers send textarea line endings as CRLF
+	content := strings.ReplaceAll(r.FormValue("content"), "\r\n", "\n")
+	if r.FormValue("reset") == "true" || strings.TrimSpace(content) == defaultSystemPrompt {
+		content = ""
+	}
+	if err := models.SaveSystemPrompt(content, user.ID); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	c.Refresh(w, r)
+}
+
+// saveRepoPrompt handles POST /settings/prompts/repos
+func (c *AIController) saveRepoPrompt(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	user, _, err := c.App.Use("auth").(*AuthController).Authenticate(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	if err := models.SaveRepoPrompt(r.FormValue("repo_id"), r.FormValue("content"), user.ID); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	c.Refresh(w, r)
+}
+
+// savePromptCommand handles POST /settings/prompts/commands
+func (c *AIController) savePromptCommand(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	user, _, err := c.App.Use("auth").(*AuthController).Authenticate(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	err = models.SavePromptCommand(r.FormValue("name"), r.FormValue("description"), r.FormValue("content"), user.ID)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	c.Refresh(w, r)
+}
+
+// deletePromptCommand handles POST /settings/prompts/commands/{name}/delete
+func (c *AIController) deletePromptCommand(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+
+	if err := models.DeletePromptCommand(r.PathValue("name")); err != nil {
+		c.RenderError(w, r, errors.New("only saved commands can be deleted"))
+		return
+	}
+
+	c.Refresh(w, r)
+}
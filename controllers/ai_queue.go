@@ -0,0 +1,151 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"workspace/internal/ai/queue"
+	"workspace/models"
+)
+
+// failedTaskLimit caps the failed tasks the queue dashboard lists
+const failedTaskLimit = 50
+
+// aiQueue returns the AI task queue, or nil when AI automation is off
+func (c *AIController) aiQueue() *queue.Queue {
+	if ai := c.getAIService(); ai != nil {
+		return ai.Queue
+	}
+	return nil
+}
+
+// QueueRunning returns whether AI automation is processing tasks
+func (c *AIController) QueueRunning() bool {
+	ai := c.getAIService()
+	return ai != nil && ai.IsRunning()
+}
+
+// QueuedTasks returns the tasks waiting on the AI queue, most urgent first
+func (c *AIController) QueuedTasks() []*queue.Task {
+	if q := c.aiQueue(); q != nil {
+		return q.Queued()
+	}
+	return nil
+}
+
+// ProcessingTasks returns the tasks the AI queue's workers are processing
+func (c *AIController) ProcessingTasks() []*queue.Task {
+	if q := c.aiQueue(); q != nil {
+		return q.Processing()
+	}
+	return nil
+}
+
+// FailedTasks returns the AI tasks that ran out of retries, newest first
+func (c *AIController) FailedTasks() []*queue.Task {
+	q := c.aiQueue()
+	if q == nil {
+		return nil
+	}
+	tasks, err := q.Failed(failedTaskLimit)
+	if err != nil {
+		log.Printf("AIController: Failed to load failed tasks: %v", err)
+	}
+	return tasks
+}
+
+// QueueWorkers returns each AI queue worker and the task it's processing
+func (c *AIController) QueueWorkers() []queue.WorkerStatus {
+	if q := c.aiQueue(); q != nil {
+		return q.Workers()
+	}
+	return nil
+}
+
+// getQueuePartial renders the AI queue's workers and tasks
+func (c *AIController) getQueuePartial(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	c.Render(w, r, "ai-queue-tasks.html", nil)
+}
+
+// updateQueueTask handles POST /admin/ai-queue/tasks/{id}/{action} to
+// retry, cancel or bump a task
+func (c *AIController) updateQueueTask(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	user, _, err := c.App.Use("auth").(*AuthController).Authenticate(r)
+	if err != nil || !user.IsAdmin {
+		c.RenderError(w, r, errors.New("Admin access required"))
+		return
+	}
+
+	q := c.aiQueue()
+	if q == nil {
+		c.RenderError(w, r, errors.New("AI service not available"))
+		return
+	}
+
+	var task *queue.Task
+	action := r.PathValue("action")
+	switch action {
+	case "retry":
+		task, err = q.Retry(r.PathValue("id"))
+	case "cancel":
+		task, err = q.Cancel(r.PathValue("id"))
+	case "bump":
+		task, err = q.Bump(r.PathValue("id"))
+	default:
+		err = errors.New("Unknown task action")
+	}
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	log.Printf("Admin action: AI task %s %s by %s", task.ID, action, user.Email)
+	models.LogActivity("ai_task_"+action, "Changed an AI task",
+		"Task "+task.ID+" ("+string(task.Type)+"): "+action, user.ID, task.RepoID, "ai_task", task.ID)
+
+	c.Render(w, r, "ai-queue-tasks.html", nil)
+}
+
+// updateQueueWorker handles POST /admin/ai-queue/workers/{worker}/{action}
+// to pause or resume a worker
+func (c *AIController) updateQueueWorker(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	user, _, err := c.App.Use("auth").(*AuthController).Authenticate(r)
+	if err != nil || !user.IsAdmin {
+		c.RenderError(w, r, errors.New("Admin access required"))
+		return
+	}
+
+	q := c.aiQueue()
+	if q == nil {
+		c.RenderError(w, r, errors.New("AI service not available"))
+		return
+	}
+
+	worker, err := strconv.Atoi(r.PathValue("worker"))
+	if err != nil {
+		c.RenderError(w, r, errors.New("Invalid worker"))
+		return
+	}
+
+	action := r.PathValue("action")
+	switch action {
+	case "pause":
+		err = q.PauseWorker(worker)
+	case "resume":
+		err = q.ResumeWorker(worker)
+	default:
+		err = errors.New("Unknown worker action")
+	}
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	log.Printf("Admin action: AI queue worker %d %sd by %s", worker, action, user.Email)
+	c.Render(w, r, "ai-queue-tasks.html", nil)
+}
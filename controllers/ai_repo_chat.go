@@ -0,0 +1,124 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"workspace/models"
+)
+
+// repoChatTools are the tools offered in a conversation bound to a
+// repository, true for those whose repo_id is pinned to that repository
+var repoChatTools = map[string]bool{
+	"list_files":      true,
+	"read_file":       true,
+	"write_file":      true,
+	"edit_file":       true,
+	"delete_file":     true,
+	"move_file":       true,
+	"search_files":    true,
+	"semantic_search": true,
+
+	"git_status":        true,
+	"git_history":       true,
+	"git_diff":          true,
+	"git_commit":        true,
+	"git_branch_create": true,
+	"git_checkout":      true,
+	"git_merge":         true,
+
+	"list_issues":         true,
+	"get_issue":           true,
+	"list_prs":            true,
+	"create_issue":        true,
+	"create_pull_request": true,
+
+	// Todos belong to the conversation, listed under both their provider
+	// and tool names
+	"list_todos":  false,
+	"update_todo": false,
+	"todo_list":   false,
+	"todo_update": false,
+}
+
+// openRepoChat handles POST /ai/repos/{id}/chat from the chat button on
+// repository pages, resuming the user's conversation about the repository
+// or starting one when there is none or a new one was asked for
+func (c *AIController) openRepoChat(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+
+	user, _, err := c.App.Use("auth").(*AuthController).Authenticate(r)
+	if err != nil || !user.IsAdmin {
+		c.RenderError(w, r, errors.New("Admin access required"))
+		return
+	}
+
+	repo, err := models.Repositories.Get(r.PathValue("id"))
+	if err != nil {
+		c.RenderError(w, r, errors.New("Repository not found"))
+		return
+	}
+
+	if r.FormValue("new") != "true" {
+		if conversation, err := models.GetRepoConversation(user.ID, repo.ID); err == nil && conversation != nil {
+			c.Render(w, r, "ai-chat.html", conversation)
+			return
+		}
+	}
+
+	conversation, err := models.Conversations.Insert(&models.Conversation{
+		UserID: user.ID,
+		Title:  "Chat · " + repo.Name,
+		RepoID: repo.ID,
+	})
+	if err != nil {
+		log.Printf("AIController: Failed to create repository conversation: %v", err)
+		c.RenderError(w, r, errors.New("Failed to create conversation"))
+		return
+	}
+
+	c.updateWorkingContext(conversation.ID, map[string]any{
+		"current_repo_id":   repo.ID,
+		"current_repo_name": repo.Name,
+		"current_branch":    repo.GetDefaultBranch(),
+	})
+
+	conversation, _ = models.Conversations.Get(conversation.ID)
+	c.Render(w, r, "ai-chat.html", conversation)
+}
+
+// applyRepoScope keeps tool calls in a repository conversation on that
+// repository, rejecting tools outside the chat's set and overriding any
+// other repo_id the model asked for
+func (c *AIController) applyRepoScope(conversationID, toolName string, params map[string]any) error {
+	conversation, err := models.Conversations.Get(conversationID)
+	if err != nil || conversation.RepoID == "" {
+		return nil
+	}
+
+	pinned, ok := repoChatTools[toolName]
+	if !ok {
+		return fmt.Errorf("%s is not available in a repository chat", toolName)
+	}
+	if pinned {
+		params["repo_id"] = conversation.RepoID
+	}
+	return nil
+}
+
+// repoScopePrompt tells the model which repository a bound conversation is
+// about, empty for conversations that aren't bound
+func repoScopePrompt(conversation *models.Conversation) string {
+	if conversation.RepoID == "" {
+		return ""
+	}
+	repo, err := conversation.Repository()
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("\n\n## Repository Scope\nThis conversation was opened from the %s repository (repo_id %q, default branch %s). "+
+		"Every file, search and git tool works on this repository only, so don't ask which repository is meant.",
+		repo.Name, repo.ID, repo.GetDefaultBranch())
+}
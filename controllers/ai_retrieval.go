@@ -0,0 +1,154 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"workspace/internal/semantic"
+	"workspace/models"
+	"workspace/services"
+)
+
+const (
+	// retrievalLimit is how many snippets are injected for a question
+	retrievalLimit = 5
+
+	// retrievalMinScore drops snippets too dissimilar to the question to help
+	retrievalMinScore = 0.35
+
+	// retrievalTimeout bounds the query embedding so a slow model doesn't
+	// hold up sending the message
+	retrievalTimeout = 10 * time.Second
+)
+
+// citationPattern matches citations like [2]. The optional groups catch
+// indexing such as items[2] and existing links, which are left alone.
+var citationPattern = regexp.MustCompile(`(\w?)\[(\d+)\](\()?`)
+
+// retrieveSources finds the indexed code most relevant to a question about
+// code in the conversation's repository and records it on the message.
+// Nothing is retrieved when the conversation turned retrieval off, has no
+// repository, or the repository isn't indexed.
+func (c *AIController) retrieveSources(ctx context.Context, conversation *models.Conversation, msg *models.Message) {
+	if !conversation.RetrievalEnabled() || !semantic.IsCodeQuery(msg.Content) {
+		return
+	}
+
+	repoID := conversation.RepoID
+	if repoID == "" {
+		repoID, _ = conversation.GetWorkingContext()["current_repo_id"].(string)
+	}
+	if repoID == "" || !services.Semantic.IsAvailable() {
+		return
+	}
+	if index := models.GetSemanticIndex(repoID); index == nil || index.Status != models.SemanticReady {
+		return
+	}
+	repo, err := models.Repositories.Get(repoID)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, retrievalTimeout)
+	defer cancel()
+	results, err := services.Semantic.Search(ctx, repo, msg.Content, retrievalLimit)
+	if err != nil {
+		log.Printf("AIController: Retrieval failed for %s: %v", repo.Name, err)
+		return
+	}
+
+	var sources []models.RetrievedSource
+	for _, result := range results {
+		if result.Score < retrievalMinScore {
+			continue
+		}
+		sources = append(sources, models.RetrievedSource{
+			RepoID:    repo.ID,
+			Path:      result.Path,
+			StartLine: result.StartLine,
+			EndLine:   result.EndLine,
+			Content:   result.Content,
+		})
+	}
+	if len(sources) == 0 {
+		return
+	}
+
+	if err := msg.SetSources(sources); err != nil {
+		log.Printf("AIController: Failed to save retrieved sources: %v", err)
+	}
+}
+
+// retrievalPrompt presents retrieved snippets to the model, numbered so the
+// answer can cite them
+func retrievalPrompt(sources []models.RetrievedSource) string {
+	var b strings.Builder
+	b.WriteString("Code retrieved from the repository for the next question. ")
+	b.WriteString("Use it when it is relevant and cite it by number, like [1], right after the statement it supports. ")
+	b.WriteString("Read the files with tools when you need more than these excerpts.\n")
+	for i, source := range sources {
+		fmt.Fprintf(&b, "\n[%d] %s lines %d-%d\n```\n%s\n```\n", i+1, source.Path, source.StartLine, source.EndLine, source.Content)
+	}
+	return b.String()
+}
+
+// linkCitations turns [n] citations of retrieved sources into links to the
+// cited lines, leaving numbers that don't match a source alone
+func linkCitations(content string, sources []models.RetrievedSource) string {
+	return citationPattern.ReplaceAllStringFunc(content, func(match string) string {
+		groups := citationPattern.FindStringSubmatch(match)
+		n, err := strconv.Atoi(groups[2])
+		if err != nil || n < 1 || n > len(sources) || groups[1] != "" || groups[3] != "" {
+			return match
+		}
+		source := sources[n-1]
+		return fmt.Sprintf("[[%d]](/repos/%s/files/%s#L%d)", n, source.RepoID, source.Path, source.StartLine)
+	})
+}
+
+// RenderAnswer renders an assistant message with its citations linked to
+// the files retrieved for the question it answers
+func (c *AIController) RenderAnswer(msg *models.Message) template.HTML {
+	content := msg.Content
+	if sources := msg.AnsweredSources(); len(sources) > 0 {
+		content = linkCitations(content, sources)
+	}
+	return c.RenderMessageMarkdown(content)
+}
+
+// toggleRetrieval handles POST /ai/chat/{id}/retrieval, turning code
+// retrieval on or off for a conversation
+func (c *AIController) toggleRetrieval(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+
+	conversationID := r.PathValue("id")
+	user, _, err := c.App.Use("auth").(*AuthController).Authenticate(r)
+	if err != nil || !user.IsAdmin {
+		c.RenderError(w, r, errors.New("Admin access required"))
+		return
+	}
+
+	// Verify ownership
+	conversation, err := models.Conversations.Get(conversationID)
+	if err != nil || conversation.UserID != user.ID {
+		c.RenderError(w, r, errors.New("Conversation not found"))
+		return
+	}
+
+	enabled := r.FormValue("retrieval") == "true"
+	if err := conversation.UpdateSetting("retrieval", enabled); err != nil {
+		log.Printf("AIController: Failed to update retrieval setting: %v", err)
+		c.RenderError(w, r, errors.New("Failed to update conversation"))
+		return
+	}
+
+	w.Write([]byte(""))
+}
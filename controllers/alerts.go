@@ -0,0 +1,417 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"workspace/internal/backup"
+	"workspace/models"
+	"workspace/services"
+
+	"github.com/The-Skyscape/devtools/pkg/application"
+)
+
+// How often alert rules are evaluated, how long resolved alerts are kept,
+// and how many action runs the failure rate needs before it can fire
+const (
+	alertEvaluationInterval = time.Minute
+	alertRetention          = 90 * 24 * time.Hour
+	alertMinActionRuns      = 3
+	alertSampleMaxAge       = 10 * time.Minute
+)
+
+// Alerts is a factory function with the prefix and instance
+func Alerts() (string, *AlertsController) {
+	return "alerts", &AlertsController{evaluating: &sync.Mutex{}}
+}
+
+// AlertsController evaluates alert rules on a schedule and routes the
+// alerts they fire to email, Slack and webhook channels
+type AlertsController struct {
+	application.Controller
+	evaluating *sync.Mutex // Keeps a manual check from racing the ticker
+}
+
+// Setup registers routes and starts evaluating rules
+func (c *AlertsController) Setup(app *application.App) {
+	c.Controller.Setup(app)
+
+	http.Handle("GET /alerts", app.Serve("alerts.html", AdminOnly()))
+	http.Handle("POST /alerts/evaluate", app.ProtectFunc(c.evaluateNow, AdminOnly()))
+	http.Handle("POST /alerts/rules", app.ProtectFunc(c.createRule, AdminOnly()))
+	http.Handle("POST /alerts/rules/{id}", app.ProtectFunc(c.updateRule, AdminOnly()))
+	http.Handle("POST /alerts/rules/{id}/delete", app.ProtectFunc(c.deleteRule, AdminOnly()))
+	http.Handle("POST /alerts/channels", app.ProtectFunc(c.createChannel, AdminOnly()))
+	http.Handle("POST /alerts/channels/{id}", app.ProtectFunc(c.updateChannel, AdminOnly()))
+	http.Handle("POST /alerts/channels/{id}/delete", app.ProtectFunc(c.deleteChannel, AdminOnly()))
+	http.Handle("POST /alerts/channels/{id}/test", app.ProtectFunc(c.testChannel, AdminOnly()))
+
+	c.startEvaluating()
+}
+
+// Handle returns a new controller instance for the request
+func (c AlertsController) Handle(req *http.Request) application.Handler {
+	c.Request = req
+	return &c
+}
+
+// Firing returns the alerts firing, newest first
+func (c *AlertsController) Firing() []*models.Alert {
+	alerts, err := models.FiringAlerts()
+	if err != nil {
+		log.Printf("AlertsController: Failed to load firing alerts: %v", err)
+	}
+	return alerts
+}
+
+// Resolved returns the most recently resolved alerts
+func (c *AlertsController) Resolved() []*models.Alert {
+	alerts, err := models.ResolvedAlerts(50)
+	if err != nil {
+		log.Printf("AlertsController: Failed to load resolved alerts: %v", err)
+	}
+	return alerts
+}
+
+// Rules returns every alert rule
+func (c *AlertsController) Rules() []*models.AlertRule {
+	rules, err := models.GetAlertRules()
+	if err != nil {
+		log.Printf("AlertsController: Failed to load alert rules: %v", err)
+	}
+	return rules
+}
+
+// Channels returns every alert channel
+func (c *AlertsController) Channels() []*models.AlertChannel {
+	channels, err := models.GetAlertChannels()
+	if err != nil {
+		log.Printf("AlertsController: Failed to load alert channels: %v", err)
+	}
+	return channels
+}
+
+// Conditions returns the conditions rules can watch
+func (c *AlertsController) Conditions() []models.AlertCondition {
+	return models.AlertConditions
+}
+
+// MailConfigured reports whether email channels can send
+func (c *AlertsController) MailConfigured() bool {
+	return services.MailConfigured()
+}
+
+// evaluateNow handles POST /alerts/evaluate, checking every rule without
+// waiting for the next evaluation
+func (c *AlertsController) evaluateNow(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	c.evaluate()
+	c.Refresh(w, r)
+}
+
+// createRule handles POST /alerts/rules
+func (c *AlertsController) createRule(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	auth := c.App.Use("auth").(*AuthController)
+	user := auth.CurrentUser()
+
+	threshold, window, err := ruleLimits(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+	rule, err := models.CreateAlertRule(r.FormValue("name"), r.FormValue("condition"), threshold, window, r.Form["channels"], user.ID)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	models.LogActivity("alert_rule_created", "Added alert rule "+rule.Name, rule.Describe(), user.ID, "", "alert_rule", rule.ID)
+	c.Refresh(w, r)
+}
+
+// updateRule handles POST /alerts/rules/{id}
+func (c *AlertsController) updateRule(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	auth := c.App.Use("auth").(*AuthController)
+	user := auth.CurrentUser()
+
+	rule, err := models.AlertRules.Get(r.PathValue("id"))
+	if err != nil {
+		c.RenderError(w, r, errors.New("alert rule not found"))
+		return
+	}
+	threshold, window, err := ruleLimits(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+	if err := rule.Update(r.FormValue("name"), threshold, window, r.Form["channels"], r.FormValue("active") == "on"); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	models.LogActivity("alert_rule_updated", "Changed alert rule "+rule.Name, rule.Describe(), user.ID, "", "alert_rule", rule.ID)
+	c.Refresh(w, r)
+}
+
+// deleteRule handles POST /alerts/rules/{id}/delete
+func (c *AlertsController) deleteRule(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	auth := c.App.Use("auth").(*AuthController)
+	user := auth.CurrentUser()
+
+	rule, err := models.AlertRules.Get(r.PathValue("id"))
+	if err != nil {
+		c.RenderError(w, r, errors.New("alert rule not found"))
+		return
+	}
+	if err := models.DeleteAlertRule(rule); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	models.LogActivity("alert_rule_deleted", "Removed alert rule "+rule.Name, "", user.ID, "", "alert_rule", rule.ID)
+	c.Refresh(w, r)
+}
+
+// ruleLimits reads a rule's threshold and window, empty for the
+// condition's defaults
+func ruleLimits(r *http.Request) (float64, int, error) {
+	r.ParseForm()
+	var threshold float64
+	if value := strings.TrimSpace(r.FormValue("threshold")); value != "" {
+		var err error
+		if threshold, err = strconv.ParseFloat(value, 64); err != nil {
+			return 0, 0, errors.New("the threshold must be a number")
+		}
+	}
+	var window int
+	if value := strings.TrimSpace(r.FormValue("window")); value != "" {
+		var err error
+		if window, err = strconv.Atoi(value); err != nil {
+			return 0, 0, errors.New("the window must be a whole number of minutes")
+		}
+	}
+	return threshold, window, nil
+}
+
+// createChannel handles POST /alerts/channels
+func (c *AlertsController) createChannel(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	auth := c.App.Use("auth").(*AuthController)
+	user := auth.CurrentUser()
+
+	channel, err := models.CreateAlertChannel(r.FormValue("name"), r.FormValue("kind"), r.FormValue("target"), r.FormValue("secret"), user.ID)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	models.LogActivity("alert_channel_created", "Added alert channel "+channel.Name, "", user.ID, "", "alert_channel", channel.ID)
+	c.Refresh(w, r)
+}
+
+// updateChannel handles POST /alerts/channels/{id}
+func (c *AlertsController) updateChannel(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	auth := c.App.Use("auth").(*AuthController)
+	user := auth.CurrentUser()
+
+	channel, err := models.AlertChannels.Get(r.PathValue("id"))
+	if err != nil {
+		c.RenderError(w, r, errors.New("alert channel not found"))
+		return
+	}
+	if err := channel.Update(r.FormValue("name"), r.FormValue("target"), r.FormValue("secret"), r.FormValue("active") == "on"); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	models.LogActivity("alert_channel_updated", "Changed alert channel "+channel.Name, "", user.ID, "", "alert_channel", channel.ID)
+	c.Refresh(w, r)
+}
+
+// deleteChannel handles POST /alerts/channels/{id}/delete
+func (c *AlertsController) deleteChannel(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	auth := c.App.Use("auth").(*AuthController)
+	user := auth.CurrentUser()
+
+	channel, err := models.AlertChannels.Get(r.PathValue("id"))
+	if err != nil {
+		c.RenderError(w, r, errors.New("alert channel not found"))
+		return
+	}
+	if err := models.DeleteAlertChannel(channel); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	models.LogActivity("alert_channel_deleted", "Removed alert channel "+channel.Name, "", user.ID, "", "alert_channel", channel.ID)
+	c.Refresh(w, r)
+}
+
+// testChannel handles POST /alerts/channels/{id}/test, sending a test
+// alert with the channel
+func (c *AlertsController) testChannel(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	channel, err := models.AlertChannels.Get(r.PathValue("id"))
+	if err != nil {
+		c.RenderError(w, r, errors.New("alert channel not found"))
+		return
+	}
+	if err := services.TestAlertChannel(channel); err != nil {
+		c.RenderError(w, r, fmt.Errorf("the test alert wasn't sent: %w", err))
+		return
+	}
+	c.Refresh(w, r)
+}
+
+// startEvaluating checks every rule once a minute and prunes old alerts
+// once a day
+func (c *AlertsController) startEvaluating() {
+	go func() {
+		ticker := time.NewTicker(alertEvaluationInterval)
+		defer ticker.Stop()
+
+		var pruned time.Time
+		for range ticker.C {
+			c.evaluate()
+
+			if time.Since(pruned) >= 24*time.Hour {
+				pruned = time.Now()
+				if err := models.PruneResolvedAlerts(pruned.Add(-alertRetention)); err != nil {
+					log.Printf("AlertsController: Failed to prune resolved alerts: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// evaluate measures each active rule's condition, firing an alert when it
+// starts to hold and resolving it when it stops. Alerts are only sent when
+// they fire and resolve, not while they keep firing.
+func (c *AlertsController) evaluate() {
+	c.evaluating.Lock()
+	defer c.evaluating.Unlock()
+
+	rules, err := models.GetAlertRules()
+	if err != nil {
+		log.Printf("AlertsController: Failed to load alert rules: %v", err)
+		return
+	}
+
+	for _, rule := range rules {
+		if !rule.Active {
+			continue
+		}
+		value, holds, summary, ok := c.measure(rule)
+		if !ok {
+			continue
+		}
+		if err := rule.RecordEvaluation(value); err != nil {
+			log.Printf("AlertsController: %v", err)
+		}
+
+		if holds {
+			alert, fired, err := models.FireAlert(rule, summary, value)
+			if err != nil {
+				log.Printf("AlertsController: %v", err)
+			} else if fired {
+				c.notify(rule, alert)
+			}
+			continue
+		}
+
+		alert, err := models.ResolveAlert(rule, summary)
+		if err != nil {
+			log.Printf("AlertsController: %v", err)
+		} else if alert != nil {
+			c.notify(rule, alert)
+		}
+	}
+}
+
+// measure checks a rule's condition, returning the value measured, whether
+// the condition holds and a summary of it. Conditions that can't be
+// measured right now, such as the AI queue while it's offline, aren't ok
+// and leave the rule's alert as it is.
+func (c *AlertsController) measure(rule *models.AlertRule) (float64, bool, string, bool) {
+	switch rule.Condition {
+	case models.AlertAIBacklog:
+		stats := c.App.Use("ai").(*AIController).GetQueueStats()
+		waiting, ok := stats["queue_length"].(int)
+		if !ok {
+			return 0, false, "", false
+		}
+		return float64(waiting), float64(waiting) > rule.Threshold,
+			fmt.Sprintf("%d tasks are waiting in the AI queue", waiting), true
+
+	case models.AlertBackupFailed:
+		if backup.Scheduler == nil {
+			return 0, false, "", false
+		}
+		if status := backup.Scheduler.GetStatus(); status.LastError != "" {
+			return 1, true, "The last backup failed: " + status.LastError, true
+		}
+		return 0, false, "The last backup succeeded", true
+
+	case models.AlertDiskUsage:
+		// The monitoring controller samples the host every minute
+		sample, err := models.LatestResourceSample(models.ResourceHostSource)
+		if err != nil || time.Since(sample.CreatedAt) > alertSampleMaxAge {
+			return 0, false, "", false
+		}
+		return sample.DiskPercent, sample.DiskPercent > rule.Threshold,
+			fmt.Sprintf("The host's disk is %.0f%% full", sample.DiskPercent), true
+
+	case models.AlertActionFailures:
+		failed, finished := models.ActionFailureRate(time.Now().Add(-time.Duration(rule.WindowMinutes) * time.Minute))
+		summary := fmt.Sprintf("%d of %d action runs failed in the last %d minutes", failed, finished, rule.WindowMinutes)
+		if finished < alertMinActionRuns {
+			return 0, false, summary, true
+		}
+		rate := float64(failed) / float64(finished) * 100
+		return rate, rate > rule.Threshold, summary, true
+
+	case models.AlertOllamaUnhealthy:
+		if os.Getenv("AI_ENABLED") != "true" || services.Ollama == nil {
+			return 0, false, "AI is turned off", true
+		}
+		if !services.Ollama.IsRunning() {
+			return 1, true, "The Ollama service isn't running", true
+		}
+		if _, err := services.Ollama.LoadedModels(); err != nil {
+			return 1, true, "Ollama isn't answering: " + err.Error(), true
+		}
+		return 0, false, "Ollama is answering", true
+	}
+	return 0, false, "", false
+}
+
+// notify sends an alert that fired or resolved to its rule's channels, and
+// tells administrators in the workspace when one fires
+func (c *AlertsController) notify(rule *models.AlertRule, alert *models.Alert) {
+	if alert.Status == models.AlertFiring {
+		log.Printf("AlertsController: Warning: %s is firing: %s", rule.Name, alert.Summary)
+		models.LogActivity("alert_fired", "Alert firing: "+rule.Name, alert.Summary, "", "", "alert", alert.ID)
+		if err := models.NotifyAdmins("alert_fired", "Alert firing: "+rule.Name, alert.Summary, "/alerts"); err != nil {
+			log.Printf("AlertsController: Failed to notify administrators of the alert: %v", err)
+		}
+	} else {
+		log.Printf("AlertsController: %s resolved: %s", rule.Name, alert.Summary)
+		models.LogActivity("alert_resolved", "Alert resolved: "+rule.Name, alert.Summary, "", "", "alert", alert.ID)
+	}
+
+	if err := services.SendAlert(rule, alert); err != nil {
+		log.Printf("AlertsController: %v", err)
+	}
+}
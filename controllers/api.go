@@ -0,0 +1,360 @@
+package controllers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"workspace/internal/openapi"
+	"workspace/models"
+	"workspace/services"
+
+	"github.com/The-Skyscape/devtools/pkg/application"
+)
+
+// API is a factory function with the prefix and instance
+func API() (string, *APIController) {
+	return "api", &APIController{}
+}
+
+// APIController serves the JSON REST API under /api/v1 for scripts and
+// integrations, authenticated with personal access tokens. Its OpenAPI
+// document is generated from the same routes and types it serves.
+type APIController struct {
+	application.Controller
+	spec []byte
+}
+
+// Pagination defaults for list endpoints
+const (
+	apiPerPage    = 30
+	apiMaxPerPage = 100
+)
+
+// apiRoute is an endpoint of the API, as it is served and documented
+type apiRoute struct {
+	Method   string
+	Path     string // Under /api/v1
+	Scope    string // Token scope required, any when empty
+	Tag      string
+	Summary  string
+	Request  any  // JSON body type, if any
+	Response any  // Success response type
+	Status   int  // Success status, 200 when zero
+	Paged    bool // Takes page and per_page and sets Link headers
+	State    bool // Takes a state filter
+	Stream   bool // Server-sent events of Response rather than JSON
+	handler  http.HandlerFunc
+}
+
+// Setup registers routes
+func (c *APIController) Setup(app *application.App) {
+	c.Controller.Setup(app)
+
+	routes := c.routes()
+	for _, route := range routes {
+		http.Handle(route.Method+" /api/v1"+route.Path, app.ProtectFunc(route.handler, APIAccess(route.Scope)))
+	}
+
+	spec, err := json.MarshalIndent(apiSpec(routes), "", "  ")
+	if err != nil {
+		log.Printf("Failed to generate OpenAPI document: %v", err)
+	}
+	c.spec = spec
+
+	// The document is public so clients can be generated before a token exists
+	http.HandleFunc("GET /api/v1/openapi.json", c.openAPI)
+}
+
+// Handle returns a new controller instance for the request
+func (c APIController) Handle(req *http.Request) application.Handler {
+	c.Request = req
+	return &c
+}
+
+// routes lists every endpoint of the API
+func (c *APIController) routes() []apiRoute {
+	return []apiRoute{
+		// Repositories
+		{Method: "GET", Path: "/repos", Scope: models.ScopeRepoRead, Tag: "Repositories", Summary: "List repositories you can read",
+			Response: []apiRepo{}, Paged: true, handler: c.listRepos},
+		{Method: "GET", Path: "/repos/{id}", Scope: models.ScopeRepoRead, Tag: "Repositories", Summary: "Get a repository",
+			Response: apiRepo{}, handler: c.getRepo},
+
+		// Issues
+		{Method: "GET", Path: "/repos/{id}/issues", Scope: models.ScopeIssues, Tag: "Issues", Summary: "List issues",
+			Response: []apiIssue{}, Paged: true, State: true, handler: c.listIssues},
+		{Method: "POST", Path: "/repos/{id}/issues", Scope: models.ScopeIssues, Tag: "Issues", Summary: "Open an issue",
+			Request: apiIssueRequest{}, Response: apiIssue{}, Status: http.StatusCreated, handler: c.createIssue},
+		{Method: "GET", Path: "/repos/{id}/issues/{issueID}", Scope: models.ScopeIssues, Tag: "Issues", Summary: "Get an issue",
+			Response: apiIssue{}, handler: c.getIssue},
+		{Method: "GET", Path: "/repos/{id}/issues/{issueID}/comments", Scope: models.ScopeIssues, Tag: "Issues", Summary: "List an issue's comments, oldest first",
+			Response: []apiComment{}, Paged: true, handler: c.listIssueComments},
+		{Method: "POST", Path: "/repos/{id}/issues/{issueID}/comments", Scope: models.ScopeIssues, Tag: "Issues", Summary: "Comment on an issue",
+			Request: apiCommentRequest{}, Response: apiComment{}, Status: http.StatusCreated, handler: c.createIssueComment},
+
+		// Pull requests
+		{Method: "GET", Path: "/repos/{id}/pulls", Scope: models.ScopeIssues, Tag: "Pull Requests", Summary: "List pull requests",
+			Response: []apiPullRequest{}, Paged: true, State: true, handler: c.listPulls},
+		{Method: "GET", Path: "/repos/{id}/pulls/{prID}", Scope: models.ScopeIssues, Tag: "Pull Requests", Summary: "Get a pull request",
+			Response: apiPullRequest{}, handler: c.getPull},
+		{Method: "GET", Path: "/repos/{id}/pulls/{prID}/comments", Scope: models.ScopeIssues, Tag: "Pull Requests", Summary: "List a pull request's comments, oldest first",
+			Response: []apiComment{}, Paged: true, handler: c.listPullComments},
+		{Method: "POST", Path: "/repos/{id}/pulls/{prID}/comments", Scope: models.ScopeIssues, Tag: "Pull Requests", Summary: "Comment on a pull request",
+			Request: apiCommentRequest{}, Response: apiComment{}, Status: http.StatusCreated, handler: c.createPullComment},
+
+		// Actions
+		{Method: "GET", Path: "/repos/{id}/actions", Scope: models.ScopeRepoRead, Tag: "Actions", Summary: "List a repository's actions",
+			Response: []apiAction{}, Paged: true, handler: c.listActions},
+		{Method: "GET", Path: "/repos/{id}/actions/{actionID}/runs", Scope: models.ScopeRepoRead, Tag: "Actions", Summary: "List an action's runs, newest first",
+			Response: []apiActionRun{}, Paged: true, handler: c.listActionRuns},
+
+		// Events
+		{Method: "GET", Path: "/events", Tag: "Events", Summary: "Stream workspace events the token has scopes for",
+			Response: services.WebhookPayload{}, Stream: true, handler: c.streamEvents},
+
+		// AI conversations
+		{Method: "GET", Path: "/conversations", Scope: models.ScopeAI, Tag: "AI", Summary: "List your assistant conversations",
+			Response: []apiConversation{}, Paged: true, handler: c.listConversations},
+		{Method: "GET", Path: "/conversations/{conversationID}/messages", Scope: models.ScopeAI, Tag: "AI", Summary: "List a conversation's messages, oldest first",
+			Response: []apiMessage{}, Paged: true, handler: c.listMessages},
+	}
+}
+
+// openAPI handles GET /api/v1/openapi.json
+func (c *APIController) openAPI(w http.ResponseWriter, r *http.Request) {
+	if c.spec == nil {
+		apiError(w, http.StatusInternalServerError, "OpenAPI document unavailable")
+		return
+	}
+	writeETagged(w, r, http.StatusOK, c.spec)
+}
+
+// apiSpec generates the OpenAPI document describing routes
+func apiSpec(routes []apiRoute) *openapi.Spec {
+	spec := openapi.New(openapi.Info{
+		Title:       "Skyscape Workspace API",
+		Version:     "1",
+		Description: "Send a personal access token from Settings > Access Tokens as \"Authorization: Bearer sky_...\". Lists are paginated with page and per_page and return the total in X-Total-Count. Responses carry an ETag; send it back in If-None-Match to get 304 Not Modified when nothing changed.",
+	})
+	spec.Servers = []openapi.Server{{URL: "/api/v1"}}
+	spec.Components.SecuritySchemes = map[string]openapi.SecurityScheme{
+		"token": {Type: "http", Scheme: "bearer", BearerFormat: "sky_ personal access token"},
+	}
+	errorSchema := spec.Define("Error", struct {
+		Message string `json:"message"`
+	}{})
+
+	one, maxPerPage := float64(1), float64(apiMaxPerPage)
+	for _, route := range routes {
+		op := openapi.Operation{
+			Summary:     route.Summary,
+			OperationID: operationID(route),
+			Tags:        []string{route.Tag},
+			Security:    []map[string][]string{{"token": {}}},
+			Responses: map[string]openapi.Response{
+				"401": {Description: "Missing or invalid token", Content: openapi.JSON(errorSchema)},
+				"404": {Description: "Not found", Content: openapi.JSON(errorSchema)},
+			},
+		}
+		if route.Scope != "" {
+			op.Security[0]["token"] = []string{route.Scope}
+			op.Responses["403"] = openapi.Response{Description: "Token lacks the " + route.Scope + " scope", Content: openapi.JSON(errorSchema)}
+		}
+
+		if route.Paged {
+			op.Parameters = append(op.Parameters,
+				openapi.Parameter{Name: "page", In: "query", Description: "Page number, from 1", Schema: &openapi.Schema{Type: "integer", Minimum: &one}},
+				openapi.Parameter{Name: "per_page", In: "query", Description: fmt.Sprintf("Items per page, %d by default", apiPerPage), Schema: &openapi.Schema{Type: "integer", Minimum: &one, Maximum: &maxPerPage}},
+			)
+		}
+		if route.Stream {
+			op.Parameters = append(op.Parameters,
+				openapi.Parameter{Name: "repo", In: "query", Description: "Only these repository IDs, comma separated", Schema: &openapi.Schema{Type: "string"}},
+				openapi.Parameter{Name: "event", In: "query", Description: "Only these events, comma separated", Schema: &openapi.Schema{Type: "string"}},
+				openapi.Parameter{Name: "Last-Event-ID", In: "header", Description: "Resend remembered events after this one", Schema: &openapi.Schema{Type: "string"}},
+				openapi.Parameter{Name: "since", In: "query", Description: "Last-Event-ID for clients that can't set headers", Schema: &openapi.Schema{Type: "string"}},
+			)
+		}
+		if route.State {
+			op.Parameters = append(op.Parameters, openapi.Parameter{Name: "state", In: "query", Description: "open by default, or all", Schema: &openapi.Schema{Type: "string"}})
+		}
+		if route.Request != nil {
+			op.RequestBody = &openapi.RequestBody{Required: true, Content: openapi.JSON(defineType(spec, route.Request))}
+			op.Responses["422"] = openapi.Response{Description: "Invalid request", Content: openapi.JSON(errorSchema)}
+		}
+
+		status := route.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		response := openapi.Response{
+			Description: http.StatusText(status),
+			Content:     openapi.JSON(defineType(spec, route.Response)),
+		}
+		if route.Stream {
+			response.Description = "A text/event-stream of events named after their type, with this payload as data"
+			response.Content = map[string]openapi.MediaType{"text/event-stream": response.Content["application/json"]}
+		}
+		op.Responses[strconv.Itoa(status)] = response
+		spec.Add(route.Method, route.Path, op)
+	}
+	return spec
+}
+
+// defineType adds the schema of an API type to the document, named
+// without its api prefix, returning a reference to it or to a list of it
+func defineType(spec *openapi.Spec, v any) *openapi.Schema {
+	t := reflect.TypeOf(v)
+	if t.Kind() == reflect.Slice {
+		return openapi.ArrayOf(defineType(spec, reflect.Zero(t.Elem()).Interface()))
+	}
+	return spec.Define(strings.TrimPrefix(t.Name(), "api"), v)
+}
+
+// operationID names an operation for generated clients, like
+// "getReposIssues" for GET /repos/{id}/issues
+func operationID(route apiRoute) string {
+	id := strings.ToLower(route.Method)
+	for _, part := range strings.Split(route.Path, "/") {
+		if part == "" || strings.HasPrefix(part, "{") {
+			continue
+		}
+		id += strings.ToUpper(part[:1]) + part[1:]
+	}
+	if strings.HasSuffix(route.Path, "}") {
+		id += "ByID"
+	}
+	return id
+}
+
+// apiPage is the page a list request asks for
+type apiPage struct {
+	Number  int
+	PerPage int
+}
+
+// pageOf reads page and per_page from the query, clamping them to valid
+// values
+func pageOf(r *http.Request) apiPage {
+	page := apiPage{Number: 1, PerPage: apiPerPage}
+	if n, err := strconv.Atoi(r.URL.Query().Get("page")); err == nil && n > 0 {
+		page.Number = n
+	}
+	if n, err := strconv.Atoi(r.URL.Query().Get("per_page")); err == nil && n > 0 {
+		page.PerPage = min(n, apiMaxPerPage)
+	}
+	return page
+}
+
+// Offset returns how many items come before the page
+func (p apiPage) Offset() int {
+	return (p.Number - 1) * p.PerPage
+}
+
+// apiList writes a page of items, converted for the response, with the
+// total count and Link headers to the neighbouring pages
+func apiList[T, V any](w http.ResponseWriter, r *http.Request, list func(limit, offset int) ([]T, int, error), convert func(T) V) {
+	page := pageOf(r)
+	items, total, err := list(page.PerPage, page.Offset())
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	out := make([]V, 0, len(items))
+	for _, item := range items {
+		out = append(out, convert(item))
+	}
+
+	var links []string
+	if page.Offset()+len(items) < total {
+		links = append(links, pageLink(r, page.Number+1, "next"))
+	}
+	if page.Number > 1 {
+		links = append(links, pageLink(r, page.Number-1, "prev"))
+	}
+	if len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	apiRespond(w, r, http.StatusOK, out)
+}
+
+// pageLink returns a Link header entry for another page of the request
+func pageLink(r *http.Request, number int, rel string) string {
+	query := r.URL.Query()
+	query.Set("page", strconv.Itoa(number))
+	u := url.URL{Path: r.URL.Path, RawQuery: query.Encode()}
+	return fmt.Sprintf("<%s>; rel=%q", u.String(), rel)
+}
+
+// slicePage pages a list already loaded in full, for models without
+// paginated queries
+func slicePage[T any](items []T, err error) func(limit, offset int) ([]T, int, error) {
+	return func(limit, offset int) ([]T, int, error) {
+		if err != nil {
+			return nil, 0, err
+		}
+		start := min(offset, len(items))
+		end := min(start+limit, len(items))
+		return items[start:end], len(items), nil
+	}
+}
+
+// apiRespond writes a JSON API response with an ETag
+func apiRespond(w http.ResponseWriter, r *http.Request, status int, v any) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, "failed to encode response")
+		return
+	}
+	writeETagged(w, r, status, append(body, '\n'))
+}
+
+// writeETagged writes a JSON body tagged with a hash of it, answering 304
+// Not Modified to GET requests that already have it
+func writeETagged(w http.ResponseWriter, r *http.Request, status int, body []byte) {
+	sum := sha256.Sum256(body)
+	etag := `W/"` + hex.EncodeToString(sum[:16]) + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "private, no-cache")
+
+	if r.Method == http.MethodGet && etagMatch(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+// etagMatch reports whether an If-None-Match header lists etag, compared
+// weakly as RFC 9110 asks
+func etagMatch(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeAPIBody reads a request's JSON body into v, answering 400 when it
+// can't
+func decodeAPIBody(w http.ResponseWriter, r *http.Request, v any) bool {
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(v); err != nil {
+		apiError(w, http.StatusBadRequest, "invalid JSON body")
+		return false
+	}
+	return true
+}
@@ -0,0 +1,88 @@
+package controllers
+
+import (
+	"net/http"
+	"time"
+
+	"workspace/models"
+)
+
+// apiConversation is an assistant conversation in API responses
+type apiConversation struct {
+	ID          string    `json:"id"`
+	Title       string    `json:"title"`
+	RepoID      string    `json:"repo_id,omitempty"`
+	Model       string    `json:"model,omitempty"`
+	LastMessage string    `json:"last_message"`
+	LastRole    string    `json:"last_role"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// newAPIConversation converts a conversation for an API response
+func newAPIConversation(conversation *models.Conversation) apiConversation {
+	return apiConversation{
+		ID:          conversation.ID,
+		Title:       conversation.Title,
+		RepoID:      conversation.RepoID,
+		Model:       conversation.ModelName,
+		LastMessage: conversation.LastMessage,
+		LastRole:    conversation.LastRole,
+		CreatedAt:   conversation.CreatedAt,
+		UpdatedAt:   conversation.UpdatedAt,
+	}
+}
+
+// apiMessage is a message of a conversation in API responses
+type apiMessage struct {
+	ID         string    `json:"id"`
+	Role       string    `json:"role"`
+	Content    string    `json:"content"`
+	ToolName   string    `json:"tool_name,omitempty"`
+	TokenCount int       `json:"token_count"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// newAPIMessage converts a message for an API response
+func newAPIMessage(message *models.Message) apiMessage {
+	return apiMessage{
+		ID:         message.ID,
+		Role:       message.Role,
+		Content:    message.Content,
+		ToolName:   message.ToolName,
+		TokenCount: message.TokenCount,
+		CreatedAt:  message.CreatedAt,
+	}
+}
+
+// listConversations handles GET /api/v1/conversations, listing only the
+// user's own conversations
+func (c *APIController) listConversations(w http.ResponseWriter, r *http.Request) {
+	user, _, err := apiUser(c.App, r)
+	if err != nil {
+		apiError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	apiList(w, r, func(limit, offset int) ([]*models.Conversation, int, error) {
+		return models.Conversations.SearchPaginated("WHERE UserID = ? ORDER BY UpdatedAt DESC", limit, offset, user.ID)
+	}, newAPIConversation)
+}
+
+// listMessages handles GET /api/v1/conversations/{conversationID}/messages
+func (c *APIController) listMessages(w http.ResponseWriter, r *http.Request) {
+	user, _, err := apiUser(c.App, r)
+	if err != nil {
+		apiError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	// Other users' conversations are private, even to admins
+	conversation, err := models.Conversations.Get(r.PathValue("conversationID"))
+	if err != nil || conversation.UserID != user.ID {
+		apiError(w, http.StatusNotFound, "conversation not found")
+		return
+	}
+	apiList(w, r, func(limit, offset int) ([]*models.Message, int, error) {
+		return models.Messages.SearchPaginated("WHERE ConversationID = ? ORDER BY CreatedAt ASC", limit, offset, conversation.ID)
+	}, newAPIMessage)
+}
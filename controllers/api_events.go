@@ -0,0 +1,141 @@
+package controllers
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"workspace/internal/events"
+	"workspace/internal/sse"
+	"workspace/models"
+
+	"github.com/The-Skyscape/devtools/pkg/authentication"
+)
+
+// eventScopes is the token scope needed to receive each event in the
+// stream. Events not listed aren't streamed.
+var eventScopes = map[string]string{
+	models.WebhookEventPush:        models.ScopeRepoRead,
+	models.WebhookEventRepository:  models.ScopeRepoRead,
+	models.WebhookEventDeployment:  models.ScopeRepoRead,
+	models.WebhookEventIssues:      models.ScopeIssues,
+	models.WebhookEventPullRequest: models.ScopeIssues,
+	models.WebhookEventComment:     models.ScopeIssues,
+	models.WebhookEventAI:          models.ScopeAI,
+}
+
+// eventFilter decides which events a stream's listener receives
+type eventFilter struct {
+	user   *authentication.User
+	token  *models.PersonalToken // nil for browser sessions
+	repos  []string              // Only these repositories, when set
+	types  []string              // Only these events, when set
+	access map[string]bool       // Repositories the user can read, as checked
+}
+
+// allows reports whether the listener may and wants to receive an event
+func (f *eventFilter) allows(event events.Event) bool {
+	scope, ok := eventScopes[event.Type]
+	switch {
+	case !ok:
+		return false
+	case len(f.types) > 0 && !slices.Contains(f.types, event.Type):
+		return false
+	case len(f.repos) > 0 && !slices.Contains(f.repos, event.RepoID):
+		return false
+	case f.token != nil && !f.token.HasScope(scope):
+		return false
+	case event.Type == models.WebhookEventAI && event.UserID != f.user.ID:
+		// Assistant tasks are private to whoever asked for them
+		return false
+	}
+
+	allowed, checked := f.access[event.RepoID]
+	if !checked {
+		allowed = models.CheckRepoAccess(f.user, event.RepoID, models.RoleRead) == nil
+		f.access[event.RepoID] = allowed
+	}
+	return allowed
+}
+
+// splitList reads a query parameter given as a comma separated list or
+// repeated
+func splitList(values []string) []string {
+	var list []string
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+	}
+	return list
+}
+
+// streamEvents handles GET /api/v1/events, a server-sent event stream of
+// the events webhooks receive, from the repositories the user can read
+// and limited to the token's scopes. Each event is named after its webhook
+// event and carries the same JSON payload.
+func (c *APIController) streamEvents(w http.ResponseWriter, r *http.Request) {
+	user, token, err := apiUser(c.App, r)
+	if err != nil {
+		apiError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	filter := &eventFilter{
+		user:   user,
+		token:  token,
+		repos:  splitList(r.URL.Query()["repo"]),
+		types:  splitList(r.URL.Query()["event"]),
+		access: map[string]bool{},
+	}
+	for _, event := range filter.types {
+		if _, ok := eventScopes[event]; !ok {
+			apiError(w, http.StatusBadRequest, "unknown event "+event)
+			return
+		}
+	}
+
+	// Reconnecting listeners catch up on what they missed, if it's still
+	// remembered
+	since, _ := strconv.ParseUint(r.Header.Get("Last-Event-ID"), 10, 64)
+	if since == 0 {
+		since, _ = strconv.ParseUint(r.URL.Query().Get("since"), 10, 64)
+	}
+
+	stream, err := sse.Streams.Open(w, r, "events", user.ID)
+	if err != nil {
+		return
+	}
+	defer stream.Close()
+
+	sub := events.Stream.Subscribe(since)
+	defer sub.Close()
+
+	stream.Send("connected", "Event stream connected")
+
+	for {
+		select {
+		case event := <-sub.C:
+			if filter.allows(event) {
+				stream.SendID(event.IDString(), event.Type, string(event.Data))
+			}
+		case <-stream.Heartbeat():
+			// End streams whose token was revoked or expired, and pick up
+			// repository access that changed since it was checked
+			if token != nil {
+				current, err := models.PersonalTokens.Get(token.ID)
+				if err != nil || !current.Active() {
+					stream.Send("error", "token is invalid, expired or revoked")
+					return
+				}
+			}
+			clear(filter.access)
+			stream.Ping()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
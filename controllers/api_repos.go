@@ -0,0 +1,526 @@
+package controllers
+
+import (
+	"cmp"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"workspace/internal/github"
+	"workspace/models"
+	"workspace/services"
+
+	"github.com/The-Skyscape/devtools/pkg/authentication"
+)
+
+// apiRepo is a repository in API responses
+type apiRepo struct {
+	ID              string    `json:"id"`
+	Name            string    `json:"name"`
+	Description     string    `json:"description"`
+	Visibility      string    `json:"visibility"`
+	OwnerID         string    `json:"owner_id"`
+	DefaultBranch   string    `json:"default_branch"`
+	PrimaryLanguage string    `json:"primary_language"`
+	Size            int64     `json:"size"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+	LastActivityAt  time.Time `json:"last_activity_at,omitzero"`
+}
+
+// newAPIRepo converts a repository for an API response
+func newAPIRepo(repo *models.Repository) apiRepo {
+	return apiRepo{
+		ID:              repo.ID,
+		Name:            repo.Name,
+		Description:     repo.Description,
+		Visibility:      repo.Visibility,
+		OwnerID:         repo.UserID,
+		DefaultBranch:   repo.DefaultBranch,
+		PrimaryLanguage: repo.PrimaryLanguage,
+		Size:            repo.Size,
+		CreatedAt:       repo.CreatedAt,
+		UpdatedAt:       repo.UpdatedAt,
+		LastActivityAt:  repo.LastActivityAt,
+	}
+}
+
+// apiIssue is an issue in API responses
+type apiIssue struct {
+	ID          string    `json:"id"`
+	RepoID      string    `json:"repo_id"`
+	Title       string    `json:"title"`
+	Body        string    `json:"body"`
+	State       string    `json:"state"`
+	Column      string    `json:"column"`
+	Priority    int       `json:"priority"`
+	AuthorID    string    `json:"author_id"`
+	AssigneeID  string    `json:"assignee_id,omitempty"`
+	MilestoneID string    `json:"milestone_id,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// newAPIIssue converts an issue for an API response
+func newAPIIssue(issue *models.Issue) apiIssue {
+	return apiIssue{
+		ID:          issue.ID,
+		RepoID:      issue.RepoID,
+		Title:       issue.Title,
+		Body:        issue.Body,
+		State:       string(issue.Status),
+		Column:      issue.Column,
+		Priority:    int(issue.Priority),
+		AuthorID:    issue.AuthorID,
+		AssigneeID:  issue.AssigneeID,
+		MilestoneID: issue.MilestoneID,
+		CreatedAt:   issue.CreatedAt,
+		UpdatedAt:   issue.UpdatedAt,
+	}
+}
+
+// apiIssueRequest opens an issue
+type apiIssueRequest struct {
+	Title string `json:"title"`
+	Body  string `json:"body,omitempty"`
+}
+
+// apiPullRequest is a pull request in API responses
+type apiPullRequest struct {
+	ID           string    `json:"id"`
+	RepoID       string    `json:"repo_id"`
+	Title        string    `json:"title"`
+	Body         string    `json:"body"`
+	State        string    `json:"state"`
+	Draft        bool      `json:"draft"`
+	AuthorID     string    `json:"author_id"`
+	BaseBranch   string    `json:"base_branch"`
+	HeadBranch   string    `json:"head_branch"`
+	Additions    int       `json:"additions"`
+	Deletions    int       `json:"deletions"`
+	ChangedFiles int       `json:"changed_files"`
+	MergedAt     time.Time `json:"merged_at,omitzero"`
+	MergedBy     string    `json:"merged_by,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// newAPIPullRequest converts a pull request for an API response
+func newAPIPullRequest(pr *models.PullRequest) apiPullRequest {
+	return apiPullRequest{
+		ID:           pr.ID,
+		RepoID:       pr.RepoID,
+		Title:        pr.Title,
+		Body:         cmp.Or(pr.Body, pr.Description),
+		State:        pr.Status,
+		Draft:        pr.Draft,
+		AuthorID:     pr.AuthorID,
+		BaseBranch:   pr.BaseBranch,
+		HeadBranch:   cmp.Or(pr.CompareBranch, pr.HeadBranch),
+		Additions:    pr.Additions,
+		Deletions:    pr.Deletions,
+		ChangedFiles: pr.ChangedFiles,
+		MergedAt:     pr.MergedAt,
+		MergedBy:     pr.MergedBy,
+		CreatedAt:    pr.CreatedAt,
+		UpdatedAt:    pr.UpdatedAt,
+	}
+}
+
+// apiComment is a comment on an issue or pull request in API responses
+type apiComment struct {
+	ID         string    `json:"id"`
+	Body       string    `json:"body"`
+	AuthorID   string    `json:"author_id"`
+	FilePath   string    `json:"file_path,omitempty"`
+	LineNumber int       `json:"line_number,omitempty"`
+	ParentID   string    `json:"parent_id,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// newAPIComment converts a comment for an API response
+func newAPIComment(comment *models.Comment) apiComment {
+	return apiComment{
+		ID:         comment.ID,
+		Body:       comment.Body,
+		AuthorID:   comment.AuthorID,
+		FilePath:   comment.FilePath,
+		LineNumber: comment.LineNumber,
+		ParentID:   comment.ParentID,
+		CreatedAt:  comment.CreatedAt,
+		UpdatedAt:  comment.UpdatedAt,
+	}
+}
+
+// apiCommentRequest posts a comment
+type apiCommentRequest struct {
+	Body string `json:"body"`
+}
+
+// apiAction is an action in API responses
+type apiAction struct {
+	ID             string     `json:"id"`
+	Title          string     `json:"title"`
+	Description    string     `json:"description"`
+	Type           string     `json:"type"`
+	Branch         string     `json:"branch"`
+	Schedule       string     `json:"schedule,omitempty"`
+	Status         string     `json:"status"`
+	LastRun        *time.Time `json:"last_run,omitempty"`
+	ExecutionCount int        `json:"execution_count"`
+	SuccessCount   int        `json:"success_count"`
+	FailureCount   int        `json:"failure_count"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// newAPIAction converts an action for an API response
+func newAPIAction(action *models.Action) apiAction {
+	return apiAction{
+		ID:             action.ID,
+		Title:          action.Title,
+		Description:    action.Description,
+		Type:           action.Type,
+		Branch:         action.Branch,
+		Schedule:       action.Schedule,
+		Status:         action.Status,
+		LastRun:        action.LastRun,
+		ExecutionCount: action.ExecutionCount,
+		SuccessCount:   action.SuccessCount,
+		FailureCount:   action.FailureCount,
+		CreatedAt:      action.CreatedAt,
+	}
+}
+
+// apiActionRun is a run of an action in API responses. Output is left out
+// as it can be large; it is on the run's page.
+type apiActionRun struct {
+	ID          string    `json:"id"`
+	Status      string    `json:"status"`
+	ExitCode    int       `json:"exit_code"`
+	Duration    int       `json:"duration_seconds"`
+	TriggerType string    `json:"trigger_type"`
+	TriggeredBy string    `json:"triggered_by,omitempty"`
+	Branch      string    `json:"branch"`
+	CommitSHA   string    `json:"commit_sha,omitempty"`
+	StartedAt   time.Time `json:"started_at"`
+}
+
+// newAPIActionRun converts an action run for an API response
+func newAPIActionRun(run *models.ActionRun) apiActionRun {
+	return apiActionRun{
+		ID:          run.ID,
+		Status:      run.Status,
+		ExitCode:    run.ExitCode,
+		Duration:    run.Duration,
+		TriggerType: run.TriggerType,
+		TriggeredBy: run.TriggeredBy,
+		Branch:      run.Branch,
+		CommitSHA:   run.CommitSHA,
+		StartedAt:   run.CreatedAt,
+	}
+}
+
+// apiRepoAccess returns the requested repository and the user, answering
+// 404 when the user can't read it so private repositories stay hidden
+func (c *APIController) apiRepoAccess(w http.ResponseWriter, r *http.Request) (*models.Repository, *authentication.User, bool) {
+	user, _, err := apiUser(c.App, r)
+	if err != nil {
+		apiError(w, http.StatusUnauthorized, err.Error())
+		return nil, nil, false
+	}
+	repo, err := models.Repositories.Get(r.PathValue("id"))
+	if err != nil || models.CheckRepoAccess(user, repo.ID, models.RoleRead) != nil {
+		apiError(w, http.StatusNotFound, "repository not found")
+		return nil, nil, false
+	}
+	return repo, user, true
+}
+
+// stateCondition adds the state query filter to a list's condition, open
+// by default
+func stateCondition(r *http.Request, condition string, args []any) (string, []any) {
+	switch state := r.URL.Query().Get("state"); state {
+	case "all":
+	case "":
+		condition += " AND Status = ?"
+		args = append(args, "open")
+	default:
+		condition += " AND Status = ?"
+		args = append(args, state)
+	}
+	return condition, args
+}
+
+// listRepos handles GET /api/v1/repos
+func (c *APIController) listRepos(w http.ResponseWriter, r *http.Request) {
+	user, _, err := apiUser(c.App, r)
+	if err != nil {
+		apiError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	// Admins read every repository, others public ones and their teams'
+	condition, args := "", []any{}
+	if !user.IsAdmin {
+		condition = "WHERE Visibility = ?"
+		args = append(args, "public")
+		if ids := models.TeamRepoIDs(user.ID); len(ids) > 0 {
+			condition += " OR ID IN (" + strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",") + ")"
+			for _, id := range ids {
+				args = append(args, id)
+			}
+		}
+	}
+
+	apiList(w, r, func(limit, offset int) ([]*models.Repository, int, error) {
+		return models.Repositories.SearchPaginated(condition+" ORDER BY UpdatedAt DESC", limit, offset, args...)
+	}, newAPIRepo)
+}
+
+// getRepo handles GET /api/v1/repos/{id}
+func (c *APIController) getRepo(w http.ResponseWriter, r *http.Request) {
+	repo, _, ok := c.apiRepoAccess(w, r)
+	if !ok {
+		return
+	}
+	apiRespond(w, r, http.StatusOK, newAPIRepo(repo))
+}
+
+// listIssues handles GET /api/v1/repos/{id}/issues
+func (c *APIController) listIssues(w http.ResponseWriter, r *http.Request) {
+	repo, _, ok := c.apiRepoAccess(w, r)
+	if !ok {
+		return
+	}
+	condition, args := stateCondition(r, "WHERE RepoID = ?", []any{repo.ID})
+	apiList(w, r, func(limit, offset int) ([]*models.Issue, int, error) {
+		return models.Issues.SearchPaginated(condition+" ORDER BY CreatedAt DESC", limit, offset, args...)
+	}, newAPIIssue)
+}
+
+// repoIssue returns the issue in the path if it belongs to repo
+func repoIssue(w http.ResponseWriter, r *http.Request, repo *models.Repository) (*models.Issue, bool) {
+	issue, err := models.Issues.Get(r.PathValue("issueID"))
+	if err != nil || issue.RepoID != repo.ID {
+		apiError(w, http.StatusNotFound, "issue not found")
+		return nil, false
+	}
+	return issue, true
+}
+
+// getIssue handles GET /api/v1/repos/{id}/issues/{issueID}
+func (c *APIController) getIssue(w http.ResponseWriter, r *http.Request) {
+	repo, _, ok := c.apiRepoAccess(w, r)
+	if !ok {
+		return
+	}
+	if issue, ok := repoIssue(w, r, repo); ok {
+		apiRespond(w, r, http.StatusOK, newAPIIssue(issue))
+	}
+}
+
+// createIssue handles POST /api/v1/repos/{id}/issues, opening an issue as
+// the issues page does
+func (c *APIController) createIssue(w http.ResponseWriter, r *http.Request) {
+	repo, user, ok := c.apiRepoAccess(w, r)
+	if !ok {
+		return
+	}
+
+	var req apiIssueRequest
+	if !decodeAPIBody(w, r, &req) {
+		return
+	}
+	title, body := strings.TrimSpace(req.Title), strings.TrimSpace(req.Body)
+	if title == "" {
+		apiError(w, http.StatusUnprocessableEntity, "issue title is required")
+		return
+	}
+
+	issue, err := models.CreateIssue(title, body, user.ID, repo.ID)
+	if issue == nil {
+		apiError(w, http.StatusInternalServerError, "failed to create issue: "+err.Error())
+		return
+	} else if err != nil {
+		log.Printf("Failed to record issue event: %v", err)
+	}
+
+	models.RecordReferences(repo.ID, models.RefIssue, issue.ID, issue.Title+"\n"+issue.Body, user.ID)
+	models.RecordMentions(repo.ID, models.RefIssue, issue.ID, issue.Title+"\n"+issue.Body, user.ID)
+	models.LogActivity("issue_created", "Created issue: "+issue.Title,
+		"New issue opened through the API", user.ID, repo.ID, "issue", issue.ID)
+	services.EmitIssueWebhook("opened", issue, user.ID)
+	models.RunProjectRules("issue_opened", repo.ID, issue.ID, "")
+	go services.TriggerActionsByEvent("on_issue", repo.ID, map[string]string{
+		"ISSUE_ID":     issue.ID,
+		"ISSUE_TITLE":  issue.Title,
+		"ISSUE_STATUS": string(issue.Status),
+		"AUTHOR_ID":    user.ID,
+	})
+
+	apiRespond(w, r, http.StatusCreated, newAPIIssue(issue))
+}
+
+// listIssueComments handles GET /api/v1/repos/{id}/issues/{issueID}/comments
+func (c *APIController) listIssueComments(w http.ResponseWriter, r *http.Request) {
+	repo, _, ok := c.apiRepoAccess(w, r)
+	if !ok {
+		return
+	}
+	if issue, ok := repoIssue(w, r, repo); ok {
+		apiList(w, r, slicePage(models.GetIssueComments(issue.ID)), newAPIComment)
+	}
+}
+
+// createIssueComment handles POST /api/v1/repos/{id}/issues/{issueID}/comments
+func (c *APIController) createIssueComment(w http.ResponseWriter, r *http.Request) {
+	repo, user, ok := c.apiRepoAccess(w, r)
+	if !ok {
+		return
+	}
+	issue, ok := repoIssue(w, r, repo)
+	if !ok {
+		return
+	}
+
+	comment, ok := createAPIComment(w, r, user, repo, "issue", issue.ID)
+	if !ok {
+		return
+	}
+	models.LogActivity("comment_created", "Commented on issue: "+issue.Title,
+		"New comment added through the API", user.ID, repo.ID, "issue_comment", issue.ID)
+	services.EmitCommentWebhook(comment, issue.Title)
+
+	apiRespond(w, r, http.StatusCreated, newAPIComment(comment))
+}
+
+// listPulls handles GET /api/v1/repos/{id}/pulls
+func (c *APIController) listPulls(w http.ResponseWriter, r *http.Request) {
+	repo, _, ok := c.apiRepoAccess(w, r)
+	if !ok {
+		return
+	}
+	condition, args := stateCondition(r, "WHERE RepoID = ?", []any{repo.ID})
+	apiList(w, r, func(limit, offset int) ([]*models.PullRequest, int, error) {
+		return models.PullRequests.SearchPaginated(condition+" ORDER BY CreatedAt DESC", limit, offset, args...)
+	}, newAPIPullRequest)
+}
+
+// repoPull returns the pull request in the path if it belongs to repo
+func repoPull(w http.ResponseWriter, r *http.Request, repo *models.Repository) (*models.PullRequest, bool) {
+	pr, err := models.PullRequests.Get(r.PathValue("prID"))
+	if err != nil || pr.RepoID != repo.ID {
+		apiError(w, http.StatusNotFound, "pull request not found")
+		return nil, false
+	}
+	return pr, true
+}
+
+// getPull handles GET /api/v1/repos/{id}/pulls/{prID}
+func (c *APIController) getPull(w http.ResponseWriter, r *http.Request) {
+	repo, _, ok := c.apiRepoAccess(w, r)
+	if !ok {
+		return
+	}
+	if pr, ok := repoPull(w, r, repo); ok {
+		apiRespond(w, r, http.StatusOK, newAPIPullRequest(pr))
+	}
+}
+
+// listPullComments handles GET /api/v1/repos/{id}/pulls/{prID}/comments
+func (c *APIController) listPullComments(w http.ResponseWriter, r *http.Request) {
+	repo, _, ok := c.apiRepoAccess(w, r)
+	if !ok {
+		return
+	}
+	if pr, ok := repoPull(w, r, repo); ok {
+		apiList(w, r, slicePage(models.GetPRComments(pr.ID)), newAPIComment)
+	}
+}
+
+// createPullComment handles POST /api/v1/repos/{id}/pulls/{prID}/comments
+func (c *APIController) createPullComment(w http.ResponseWriter, r *http.Request) {
+	repo, user, ok := c.apiRepoAccess(w, r)
+	if !ok {
+		return
+	}
+	pr, ok := repoPull(w, r, repo)
+	if !ok {
+		return
+	}
+
+	comment, ok := createAPIComment(w, r, user, repo, "pr", pr.ID)
+	if !ok {
+		return
+	}
+	models.LogActivity("comment_created", "Commented on PR: "+pr.Title,
+		"New comment added through the API", user.ID, repo.ID, "pr_comment", pr.ID)
+	services.EmitCommentWebhook(comment, pr.Title)
+
+	// A comment from a requested reviewer completes their review request
+	if err := models.RecordReview(pr, user.ID); err != nil {
+		log.Printf("Failed to record review: %v", err)
+	}
+
+	apiRespond(w, r, http.StatusCreated, newAPIComment(comment))
+}
+
+// createAPIComment posts the comment in a request's body on an issue or
+// pull request, recording its references and mentions and mirroring it to
+// GitHub as comments made on their pages are
+func createAPIComment(w http.ResponseWriter, r *http.Request, user *authentication.User, repo *models.Repository, entityType, entityID string) (*models.Comment, bool) {
+	var req apiCommentRequest
+	if !decodeAPIBody(w, r, &req) {
+		return nil, false
+	}
+	body := strings.TrimSpace(req.Body)
+	if body == "" {
+		apiError(w, http.StatusUnprocessableEntity, "comment body is required")
+		return nil, false
+	}
+
+	comment, err := models.CreateComment(entityType, entityID, repo.ID, user.ID, body)
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, "failed to create comment")
+		return nil, false
+	}
+
+	models.RecordReferences(repo.ID, models.RefComment, comment.ID, comment.Body, user.ID)
+	models.RecordMentions(repo.ID, models.RefComment, comment.ID, comment.Body, user.ID)
+	go func() {
+		syncService := &github.GitHubSyncService{}
+		if err := syncService.PushComment(comment.ID, user.ID); err != nil {
+			log.Printf("Failed to push comment to GitHub: %v", err)
+		}
+	}()
+	return comment, true
+}
+
+// listActions handles GET /api/v1/repos/{id}/actions
+func (c *APIController) listActions(w http.ResponseWriter, r *http.Request) {
+	repo, _, ok := c.apiRepoAccess(w, r)
+	if !ok {
+		return
+	}
+	apiList(w, r, func(limit, offset int) ([]*models.Action, int, error) {
+		return models.Actions.SearchPaginated("WHERE RepoID = ? ORDER BY CreatedAt DESC", limit, offset, repo.ID)
+	}, newAPIAction)
+}
+
+// listActionRuns handles GET /api/v1/repos/{id}/actions/{actionID}/runs
+func (c *APIController) listActionRuns(w http.ResponseWriter, r *http.Request) {
+	repo, _, ok := c.apiRepoAccess(w, r)
+	if !ok {
+		return
+	}
+	action, err := models.Actions.Get(r.PathValue("actionID"))
+	if err != nil || action.RepoID != repo.ID {
+		apiError(w, http.StatusNotFound, "action not found")
+		return
+	}
+	apiList(w, r, func(limit, offset int) ([]*models.ActionRun, int, error) {
+		return models.ActionRuns.SearchPaginated("WHERE ActionID = ? ORDER BY CreatedAt DESC", limit, offset, action.ID)
+	}, newAPIActionRun)
+}
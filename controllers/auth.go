@@ -3,7 +3,6 @@ package controllers
 import (
 	"errors"
 	"net/http"
-	"time"
 	"workspace/models"
 
 	"github.com/The-Skyscape/devtools/pkg/application"
@@ -45,6 +44,12 @@ func (c *AuthController) Setup(app *application.App) {
 	http.HandleFunc("GET /signup", c.ShowSignup)
 	http.HandleFunc("POST /_auth/signup", c.HandleSignup)
 	http.HandleFunc("POST /_auth/signout", c.HandleSignout)
+
+	// Password resets and invitations sent by email
+	c.setupMailRoutes()
+
+	// Single sign-on through an OpenID Connect provider
+	c.setupSSORoutes()
 }
 
 // Handle prepares the controller for request-specific operations.
@@ -73,6 +78,11 @@ func (c *AuthController) HandleSignin(w http.ResponseWriter, r *http.Request) {
 	// Set the request on the controller
 	c.SetRequest(r)
 
+	if c.PasswordLoginDisabled() {
+		c.RenderError(w, r, errors.New("Password sign-in is turned off, sign in with single sign-on"))
+		return
+	}
+
 	handle := r.FormValue("handle")
 	password := r.FormValue("password")
 
@@ -89,21 +99,12 @@ func (c *AuthController) HandleSignin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Generate session token
-	token, err := c.auth.GenerateSessionToken(user.ID, 30*24*time.Hour)
-	if err != nil {
+	// Sign this browser in
+	if err := c.startSession(w, r, user); err != nil {
 		c.RenderError(w, r, err)
 		return
 	}
 
-	// Set cookie
-	c.auth.SetCookie(w, c.cookieName, token, time.Now().Add(30*24*time.Hour), r.TLS != nil)
-
-	// Create session record
-	models.Auth.Sessions.Insert(&authentication.Session{
-		UserID: user.ID,
-	})
-
 	c.Refresh(w, r)
 }
 
@@ -150,22 +151,14 @@ func (c *AuthController) HandleSignup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Generate session token
-	token, err := c.auth.GenerateSessionToken(user.ID, 30*24*time.Hour)
-	if err != nil {
+	// Sign this browser in
+	if err := c.startSession(w, r, user); err != nil {
 		c.RenderError(w, r, err)
 		return
 	}
 
-	// Set cookie
-	c.auth.SetCookie(w, c.cookieName, token, time.Now().Add(30*24*time.Hour), r.TLS != nil)
-
-	// Create session record
-	models.Auth.Sessions.Insert(&authentication.Session{
-		UserID: user.ID,
-	})
-
-	c.Refresh(w, r)
+	// Continue with the rest of the setup wizard
+	c.Redirect(w, r, "/setup?step=theme")
 }
 
 // HandleSignout processes signout
@@ -174,6 +167,12 @@ func (c *AuthController) HandleSignout(w http.ResponseWriter, r *http.Request) {
 	// Set the request on the controller
 	c.SetRequest(r)
 
+	// Sign out this browser's session, so the cookie stops working even
+	// if it was copied
+	if _, session, err := c.sessionUser(r); err == nil {
+		session.Revoke("")
+	}
+
 	// Clear cookie
 	c.auth.ClearCookie(w, c.cookieName)
 	c.Redirect(w, r, "/signin")
@@ -183,30 +182,10 @@ func (c *AuthController) HandleSignout(w http.ResponseWriter, r *http.Request) {
 // Returns nil if no user is authenticated. This method is accessible in templates
 // as {{auth.CurrentUser}} for displaying user information or conditional rendering.
 func (c *AuthController) CurrentUser() *authentication.User {
-	// Try to get token from cookie
-	token, err := c.auth.GetTokenFromCookie(c.Request, c.cookieName)
-	if err != nil {
-		return nil
-	}
-
-	// Validate token
-	claims, err := c.auth.ValidateToken(token)
-	if err != nil {
-		return nil
-	}
-
-	// Get user ID from claims
-	userID, ok := claims["user_id"].(string)
-	if !ok {
-		return nil
-	}
-
-	// Get user from database
-	user, err := models.Auth.Users.Get(userID)
+	user, _, err := c.sessionUser(c.Request)
 	if err != nil {
 		return nil
 	}
-
 	return user
 }
 
@@ -219,32 +198,10 @@ func (c *AuthController) IsAuthenticated() bool {
 // Required is an AccessCheck middleware that ensures a user is authenticated and is an admin.
 // In the workspace model, only admins (developers) have write access.
 func (c *AuthController) Required(app *application.App, w http.ResponseWriter, r *http.Request) bool {
-	// Try to get token from cookie
-	token, err := c.auth.GetTokenFromCookie(r, c.cookieName)
-	if err != nil {
-		// Not authenticated
-		http.Redirect(w, r, "/signin", http.StatusSeeOther)
-		return false
-	}
-
-	// Validate token
-	claims, err := c.auth.ValidateToken(token)
-	if err != nil {
-		// Invalid token
-		http.Redirect(w, r, "/signin", http.StatusSeeOther)
-		return false
-	}
-
-	// Get user ID from claims
-	userID, ok := claims["user_id"].(string)
-	if !ok {
-		http.Redirect(w, r, "/signin", http.StatusSeeOther)
-		return false
-	}
-
-	// Get user from database
-	user, err := models.Auth.Users.Get(userID)
+	// Get the user from the session cookie
+	user, _, err := c.sessionUser(r)
 	if err != nil {
+		// Not authenticated, or the session was signed out
 		http.Redirect(w, r, "/signin", http.StatusSeeOther)
 		return false
 	}
@@ -262,29 +219,13 @@ func (c *AuthController) Required(app *application.App, w http.ResponseWriter, r
 // ReadOnly is an AccessCheck middleware that allows both admins and regular users.
 // Regular users (guests) can read code, view commit history, and report issues.
 func (c *AuthController) ReadOnly(app *application.App, w http.ResponseWriter, r *http.Request) bool {
-	// Try to get token from cookie
-	token, err := c.auth.GetTokenFromCookie(r, c.cookieName)
-	if err != nil {
+	// Check the session cookie
+	if _, _, err := c.sessionUser(r); err != nil {
 		// Not authenticated - redirect to signin
 		http.Redirect(w, r, "/signin", http.StatusSeeOther)
 		return false
 	}
 
-	// Validate token
-	claims, err := c.auth.ValidateToken(token)
-	if err != nil {
-		// Invalid token
-		http.Redirect(w, r, "/signin", http.StatusSeeOther)
-		return false
-	}
-
-	// Get user ID from claims
-	userID, ok := claims["user_id"].(string)
-	if !ok || userID == "" {
-		http.Redirect(w, r, "/signin", http.StatusSeeOther)
-		return false
-	}
-
 	// Any authenticated user can access read-only resources
 	return true
 }
@@ -304,30 +245,10 @@ func (c *AuthController) AdminOnly(app *application.App, w http.ResponseWriter,
 // GetAuthenticatedUser gets the current user from a request.
 // This is a helper method used by other controllers.
 func (c *AuthController) GetAuthenticatedUser(r *http.Request) *authentication.User {
-	// Try to get token from cookie
-	token, err := c.auth.GetTokenFromCookie(r, c.cookieName)
-	if err != nil {
-		return nil
-	}
-
-	// Validate token
-	claims, err := c.auth.ValidateToken(token)
+	user, _, err := c.sessionUser(r)
 	if err != nil {
 		return nil
 	}
-
-	// Get user ID from claims
-	userID, ok := claims["user_id"].(string)
-	if !ok {
-		return nil
-	}
-
-	// Get user from database
-	user, err := models.Auth.Users.Get(userID)
-	if err != nil {
-		return nil
-	}
-
 	return user
 }
 
@@ -362,40 +283,16 @@ func (c *AuthController) ProtectFunc(h http.HandlerFunc, adminOnly bool) http.Ha
 // Authenticate validates the request and returns the user and session.
 // This method provides backward compatibility with existing code.
 func (c *AuthController) Authenticate(r *http.Request) (*authentication.User, *authentication.Session, error) {
-	// Try to get token from cookie
-	token, err := c.auth.GetTokenFromCookie(r, c.cookieName)
-	if err != nil {
-		return nil, nil, err
-	}
-
-	// Validate token
-	claims, err := c.auth.ValidateToken(token)
+	user, browser, err := c.sessionUser(r)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	// Get user ID from claims
-	userID, ok := claims["user_id"].(string)
-	if !ok {
-		return nil, nil, errors.New("invalid token claims")
-	}
-
-	// Get user from database
-	user, err := models.Auth.Users.Get(userID)
-	if err != nil {
-		return nil, nil, err
-	}
-
-	// Get session ID from claims if present
-	sessionID, _ := claims["session_id"].(string)
-
 	// Create a session object for compatibility
 	session := &authentication.Session{
-		UserID: userID,
-	}
-	if sessionID != "" {
-		session.ID = sessionID
+		UserID: user.ID,
 	}
+	session.ID = browser.ID
 
 	return user, session, nil
 }
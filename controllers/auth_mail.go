@@ -0,0 +1,189 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"workspace/models"
+	"workspace/services"
+)
+
+// setupMailRoutes registers the pages people reach from emailed links
+func (c *AuthController) setupMailRoutes() {
+	http.HandleFunc("GET /forgot-password", c.ShowForgotPassword)
+	http.HandleFunc("POST /_auth/forgot-password", c.HandleForgotPassword)
+	http.HandleFunc("GET /reset-password/{token}", c.ShowResetPassword)
+	http.HandleFunc("POST /_auth/reset-password/{token}", c.HandleResetPassword)
+	http.HandleFunc("GET /invite/{token}", c.ShowInvitation)
+	http.HandleFunc("POST /_auth/invite/{token}", c.HandleInvitation)
+	http.HandleFunc("GET /mail/unsubscribe/{token}", c.HandleUnsubscribe)
+}
+
+// ShowForgotPassword displays the form asking for a password reset link
+func (c *AuthController) ShowForgotPassword(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	c.Render(w, r, "forgot-password.html", map[string]any{
+		"MailConfigured": services.MailConfigured(),
+	})
+}
+
+// HandleForgotPassword emails a password reset link to the account named
+// by handle or email. The response is the same whether or not the account
+// exists, so the form can't be used to find out who has one.
+func (c *AuthController) HandleForgotPassword(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+
+	if c.PasswordLoginDisabled() {
+		c.RenderError(w, r, errors.New("Password sign-in is turned off, sign in with single sign-on"))
+		return
+	}
+	if !services.MailConfigured() {
+		c.RenderError(w, r, errors.New("Password resets by email are not set up, ask an administrator to reset your password"))
+		return
+	}
+
+	if user, err := models.Auth.GetUser(strings.TrimSpace(r.FormValue("handle"))); err == nil {
+		if _, token, err := models.CreatePasswordReset(user.ID); err != nil {
+			log.Printf("AuthController: Failed to start password reset for %s: %v", user.Email, err)
+		} else {
+			err = services.SendTemplate([]string{user.Email}, "password_reset", services.MailMessage{Data: map[string]any{
+				"Name": user.Name,
+				"Link": workspaceURL(r) + "/reset-password/" + token,
+			}})
+			if err != nil {
+				log.Printf("AuthController: Failed to email password reset to %s: %v", user.Email, err)
+			}
+		}
+	}
+
+	w.Write([]byte(`<div class="alert alert-success">If an account matches, a link to reset its password is on its way.</div>`))
+}
+
+// ShowResetPassword displays the form choosing a new password
+func (c *AuthController) ShowResetPassword(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	_, err := models.GetPasswordReset(r.PathValue("token"))
+	c.Render(w, r, "reset-password.html", map[string]any{
+		"Token": r.PathValue("token"),
+		"Error": err,
+	})
+}
+
+// HandleResetPassword sets the new password of the account a reset link
+// was emailed to, then signs it in
+func (c *AuthController) HandleResetPassword(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+
+	reset, err := models.GetPasswordReset(r.PathValue("token"))
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	password := r.FormValue("password")
+	if len(password) < 8 {
+		c.RenderError(w, r, errors.New("Password must be at least 8 characters"))
+		return
+	}
+	if password != r.FormValue("confirm_password") {
+		c.RenderError(w, r, errors.New("Passwords do not match"))
+		return
+	}
+
+	user, err := models.Auth.Users.Get(reset.UserID)
+	if err != nil {
+		c.RenderError(w, r, errors.New("Account not found"))
+		return
+	}
+	if err := user.SetupPassword(password); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+	if err := models.Auth.Users.Update(user); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+	reset.Use()
+
+	// Whoever knew the old password shouldn't stay signed in
+	if _, err := models.RevokeUserSessions(user.ID, "", user.ID); err != nil {
+		log.Printf("AuthController: Failed to sign %s out after a password reset: %v", user.ID, err)
+	}
+
+	models.LogActivity("password_reset", "Reset password",
+		"User reset their password from an emailed link", user.ID, "", "account", "")
+
+	if err := c.startSession(w, r, user); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+	c.Redirect(w, r, "/")
+}
+
+// ShowInvitation displays the form creating an account from an invitation
+func (c *AuthController) ShowInvitation(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	invitation, err := models.GetInvitation(r.PathValue("token"))
+	c.Render(w, r, "invite.html", map[string]any{
+		"Token":      r.PathValue("token"),
+		"Invitation": invitation,
+		"Error":      err,
+	})
+}
+
+// HandleInvitation creates the account an invitation was emailed for,
+// as a developer or guest as invited, then signs it in
+func (c *AuthController) HandleInvitation(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+
+	invitation, err := models.GetInvitation(r.PathValue("token"))
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	name := strings.TrimSpace(r.FormValue("name"))
+	handle := strings.TrimSpace(r.FormValue("handle"))
+	password := r.FormValue("password")
+	if name == "" || handle == "" || password == "" {
+		c.RenderError(w, r, errors.New("All fields are required"))
+		return
+	}
+	if len(password) < 8 {
+		c.RenderError(w, r, errors.New("Password must be at least 8 characters"))
+		return
+	}
+
+	user, err := models.Auth.Signup(name, invitation.Email, handle, password, invitation.Admin)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+	if err := invitation.Accept(user.ID); err != nil {
+		log.Printf("AuthController: Failed to mark invitation %s accepted: %v", invitation.ID, err)
+	}
+
+	models.LogActivity("user_joined", "Joined the workspace",
+		"Accepted an invitation sent to "+invitation.Email, user.ID, "", "user", user.ID)
+
+	if err := c.startSession(w, r, user); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+	c.Redirect(w, r, "/")
+}
+
+// HandleUnsubscribe turns off the notification digests of the user an
+// emailed unsubscribe link names
+func (c *AuthController) HandleUnsubscribe(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	pref, err := models.MailPreferenceByToken(r.PathValue("token"))
+	if err == nil {
+		err = pref.SetDigests(false)
+	}
+	c.Render(w, r, "mail-unsubscribed.html", map[string]any{
+		"Error": err,
+	})
+}
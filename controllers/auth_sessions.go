@@ -0,0 +1,82 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"workspace/middleware"
+	"workspace/models"
+
+	"github.com/The-Skyscape/devtools/pkg/authentication"
+)
+
+// sessionLength is how long signing in keeps a browser signed in
+const sessionLength = 30 * 24 * time.Hour
+
+// startSession signs a user in on this browser, as signing in does, and
+// records the browser so it can be listed and signed out later
+func (c *AuthController) startSession(w http.ResponseWriter, r *http.Request, user *authentication.User) error {
+	token, err := c.auth.GenerateSessionToken(user.ID, sessionLength)
+	if err != nil {
+		return err
+	}
+	expires := time.Now().Add(sessionLength)
+	if _, err := models.CreateUserSession(user.ID, token, r.UserAgent(), middleware.ClientIP(r), expires); err != nil {
+		return err
+	}
+	c.auth.SetCookie(w, c.cookieName, token, expires, r.TLS != nil)
+	models.Auth.Sessions.Insert(&authentication.Session{
+		UserID: user.ID,
+	})
+	return nil
+}
+
+// sessionUser returns who the request's session cookie signs in and the
+// browser session it belongs to. Cookies whose session was signed out, or
+// that were issued before sessions were recorded, are refused.
+func (c *AuthController) sessionUser(r *http.Request) (*authentication.User, *models.UserSession, error) {
+	if r == nil {
+		return nil, nil, errors.New("no request")
+	}
+	token, err := c.auth.GetTokenFromCookie(r, c.cookieName)
+	if err != nil {
+		return nil, nil, err
+	}
+	claims, err := c.auth.ValidateToken(token)
+	if err != nil {
+		return nil, nil, err
+	}
+	userID, ok := claims["user_id"].(string)
+	if !ok || userID == "" {
+		return nil, nil, errors.New("invalid token claims")
+	}
+
+	session, err := models.GetUserSession(token)
+	if err != nil {
+		return nil, nil, err
+	}
+	if session.UserID != userID {
+		return nil, nil, errors.New("session belongs to another user")
+	}
+	user, err := models.Auth.Users.Get(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := session.Seen(middleware.ClientIP(r)); err != nil {
+		log.Printf("AuthController: Failed to record activity of session %s: %v", session.ID, err)
+	}
+	return user, session, nil
+}
+
+// CurrentSession returns the browser session of the current request, for
+// the sessions page to tell this browser apart from the others
+func (c *AuthController) CurrentSession() *models.UserSession {
+	_, session, err := c.sessionUser(c.Request)
+	if err != nil {
+		return nil
+	}
+	return session
+}
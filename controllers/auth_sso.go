@@ -0,0 +1,252 @@
+package controllers
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"workspace/internal/oidc"
+	"workspace/models"
+
+	"github.com/The-Skyscape/devtools/pkg/authentication"
+)
+
+// ssoCookieName holds the state of a sign-in started with the provider
+const ssoCookieName = "workspace_sso"
+
+// ssoCookiePath limits the sign-in cookie to the callback
+const ssoCookiePath = "/_auth/sso"
+
+// ssoScopes are what the provider is asked to share about the user
+var ssoScopes = []string{"openid", "email", "profile"}
+
+// ssoProvider is the provider as last discovered, kept so its signing
+// keys are fetched once rather than on every sign-in
+var ssoProvider struct {
+	sync.Mutex
+	url      string
+	provider *oidc.Provider
+}
+
+// setupSSORoutes registers the single sign-on flow
+func (c *AuthController) setupSSORoutes() {
+	http.HandleFunc("GET /_auth/sso", c.StartSSO)
+	http.HandleFunc("GET /_auth/sso/callback", c.HandleSSOCallback)
+}
+
+// SSO returns the workspace settings when users can sign in through the
+// provider, for the sign-in page
+func (c *AuthController) SSO() *models.Settings {
+	settings, err := models.GetSettings()
+	if err != nil || !settings.SSOConfigured() {
+		return nil
+	}
+	return settings
+}
+
+// PasswordLoginDisabled reports whether users must sign in through the
+// provider instead of with a password
+func (c *AuthController) PasswordLoginDisabled() bool {
+	settings, err := models.GetSettings()
+	return err == nil && settings.PasswordLoginDisabled()
+}
+
+// discoverSSO returns the provider at a discovery URL, discovering it again
+// when the URL changed
+func discoverSSO(ctx context.Context, discoveryURL string) (*oidc.Provider, error) {
+	ssoProvider.Lock()
+	defer ssoProvider.Unlock()
+	if ssoProvider.provider != nil && ssoProvider.url == discoveryURL {
+		return ssoProvider.provider, nil
+	}
+	provider, err := oidc.Discover(ctx, discoveryURL)
+	if err != nil {
+		return nil, err
+	}
+	ssoProvider.url, ssoProvider.provider = discoveryURL, provider
+	return provider, nil
+}
+
+// ssoConfig returns how the workspace is registered with the provider
+func ssoConfig(r *http.Request, settings *models.Settings) (oidc.Config, error) {
+	secret, err := models.GetSSOClientSecret()
+	if err != nil {
+		return oidc.Config{}, errors.New("Single sign-on has no client secret, ask an administrator to save one")
+	}
+	return oidc.Config{
+		ClientID:     settings.SSOClientID,
+		ClientSecret: secret,
+		RedirectURL:  workspaceURL(r) + ssoCookiePath + "/callback",
+		Scopes:       ssoScopes,
+	}, nil
+}
+
+// StartSSO handles GET /_auth/sso, sending the browser to the provider to
+// sign in. With ?test=1 an administrator checks the provider signs them
+// in before password sign-in can be turned off.
+func (c *AuthController) StartSSO(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+
+	settings, err := models.GetSettings()
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+	if !settings.SSOConfigured() {
+		c.RenderError(w, r, errors.New("Single sign-on is not set up"))
+		return
+	}
+	cfg, err := ssoConfig(r, settings)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+	provider, err := discoverSSO(r.Context(), settings.SSODiscoveryURL)
+	if err != nil {
+		log.Printf("AuthController: Failed to discover SSO provider: %v", err)
+		c.RenderError(w, r, errors.New("The single sign-on provider can't be reached, try again later"))
+		return
+	}
+
+	// The callback checks the state and nonce came from this browser, and
+	// only it can exchange the code with the verifier
+	flow := url.Values{}
+	flow.Set("state", oidc.RandomString())
+	flow.Set("nonce", oidc.RandomString())
+	flow.Set("verifier", oidc.RandomString())
+	if r.URL.Query().Get("test") == "1" {
+		flow.Set("test", "1")
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     ssoCookieName,
+		Value:    flow.Encode(),
+		Path:     ssoCookiePath,
+		MaxAge:   int((10 * time.Minute).Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	c.Redirect(w, r, provider.AuthCodeURL(cfg, flow.Get("state"), flow.Get("nonce"), flow.Get("verifier")))
+}
+
+// HandleSSOCallback handles GET /_auth/sso/callback, where the provider
+// sends the browser back. The ID token names who signed in, whose account
+// is found, linked or created as settings allow before they're signed in.
+func (c *AuthController) HandleSSOCallback(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+
+	cookie, err := r.Cookie(ssoCookieName)
+	if err != nil {
+		c.RenderError(w, r, errors.New("Sign-in expired, please try again"))
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: ssoCookieName, Path: ssoCookiePath, MaxAge: -1})
+	flow, err := url.ParseQuery(cookie.Value)
+	if err != nil || flow.Get("state") == "" || flow.Get("state") != r.URL.Query().Get("state") {
+		c.RenderError(w, r, errors.New("Sign-in expired, please try again"))
+		return
+	}
+	if reason := r.URL.Query().Get("error"); reason != "" {
+		c.RenderError(w, r, errors.New("The provider didn't sign you in: "+cmp.Or(r.URL.Query().Get("error_description"), reason)))
+		return
+	}
+
+	settings, err := models.GetSettings()
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+	if !settings.SSOConfigured() {
+		c.RenderError(w, r, errors.New("Single sign-on is not set up"))
+		return
+	}
+	cfg, err := ssoConfig(r, settings)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+	provider, err := discoverSSO(r.Context(), settings.SSODiscoveryURL)
+	if err != nil {
+		c.RenderError(w, r, errors.New("The single sign-on provider can't be reached, try again later"))
+		return
+	}
+
+	token, err := provider.Exchange(r.Context(), cfg, r.URL.Query().Get("code"), flow.Get("verifier"))
+	if err != nil {
+		log.Printf("AuthController: SSO code exchange failed: %v", err)
+		c.RenderError(w, r, errors.New("The provider couldn't confirm the sign-in, please try again"))
+		return
+	}
+	claims, err := provider.VerifyIDToken(r.Context(), cfg.ClientID, token.IDToken, flow.Get("nonce"))
+	if err != nil {
+		log.Printf("AuthController: SSO ID token rejected: %v", err)
+		c.RenderError(w, r, errors.New("The provider's sign-in couldn't be verified"))
+		return
+	}
+
+	// An administrator testing sign-in must stay signed in as themselves
+	tester := c.GetAuthenticatedUser(r)
+	if flow.Get("test") == "1" && (tester == nil || !tester.IsAdmin) {
+		c.RenderError(w, r, errors.New("Only administrators can test single sign-on"))
+		return
+	}
+
+	user, err := models.SSOSignIn(settings, models.SSOProfile{
+		Issuer:        claims.Issuer,
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified,
+		Name:          claims.Name,
+		Username:      claims.PreferredUsername,
+		Groups:        claims.Strings(settings.SSOGroupsClaimName()),
+	})
+	if err != nil {
+		log.Printf("AuthController: SSO sign-in of %s refused: %v", claims.Email, err)
+		c.RenderError(w, r, err)
+		return
+	}
+
+	if flow.Get("test") == "1" {
+		c.finishSSOTest(w, r, settings, tester, user)
+		return
+	}
+
+	models.LogActivity("sso_signin", "Signed in with single sign-on",
+		"Signed in through "+settings.SSOName(), user.ID, "", "user", user.ID)
+	if err := c.startSession(w, r, user); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+	c.Redirect(w, r, "/")
+}
+
+// finishSSOTest marks single sign-on verified when the provider signed in
+// the administrator testing it, still as an administrator, which is what
+// password sign-in can then be turned off on
+func (c *AuthController) finishSSOTest(w http.ResponseWriter, r *http.Request, settings *models.Settings, tester, user *authentication.User) {
+	if user.ID != tester.ID {
+		c.RenderError(w, r, errors.New("The provider signed in "+user.Email+", not your account. Sign in to the provider as yourself and test again."))
+		return
+	}
+	if !user.IsAdmin {
+		c.RenderError(w, r, errors.New("Your groups at the provider don't make you an administrator, check the group mappings and test again"))
+		return
+	}
+
+	settings.SSOVerifiedAt = time.Now()
+	settings.LastUpdatedBy = user.Email
+	settings.LastUpdatedAt = time.Now()
+	if err := models.GlobalSettings.Update(settings); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+	models.LogActivity("sso_verified", "Verified single sign-on",
+		"Signed in through "+settings.SSOName()+" as a test", user.ID, "", "settings", "")
+	c.Redirect(w, r, "/settings/sso")
+}
@@ -1,11 +1,20 @@
 package controllers
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
 	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 	"workspace/internal/backup"
+	"workspace/internal/storage"
+	"workspace/middleware"
+	"workspace/models"
 
 	"github.com/The-Skyscape/devtools/pkg/application"
 )
@@ -41,6 +50,7 @@ func (b *BackupController) Setup(app *application.App) {
 
 	// Backup management page
 	http.Handle("GET /settings/backup", app.Serve("settings-backup.html", adminRequired))
+	http.Handle("GET /settings/backup/restore", app.Serve("settings-backup-restore.html", adminRequired))
 
 	// API endpoints
 	http.Handle("POST /backup/create", app.ProtectFunc(b.createBackup, auth.AdminOnly))
@@ -48,10 +58,17 @@ func (b *BackupController) Setup(app *application.App) {
 	http.Handle("GET /backup/list", app.ProtectFunc(b.listBackups, auth.AdminOnly))
 	http.Handle("GET /backup/status", app.ProtectFunc(b.getStatus, auth.AdminOnly))
 	http.Handle("POST /backup/toggle", app.ProtectFunc(b.toggleScheduler, auth.AdminOnly))
+	http.Handle("POST /backup/verify", app.ProtectFunc(b.verifyBackup, auth.AdminOnly))
+	http.Handle("POST /backup/test-restore", app.ProtectFunc(b.testRestore, auth.AdminOnly))
+	http.Handle("POST /backup/settings", app.ProtectFunc(b.updateSettings, auth.AdminOnly))
+	http.Handle("POST /backup/key", app.ProtectFunc(b.revealKey, auth.AdminOnly))
+	http.Handle("POST /backup/restore/plan", app.ProtectFunc(b.planRestore, auth.AdminOnly))
+	http.Handle("POST /backup/restore/apply", app.ProtectFunc(b.applyRestore, auth.AdminOnly))
 
 	// HTMX partials
 	http.Handle("GET /backup/partial/list", app.ProtectFunc(b.getBackupListPartial, auth.AdminOnly))
 	http.Handle("GET /backup/partial/status", app.ProtectFunc(b.getStatusPartial, auth.AdminOnly))
+	http.Handle("GET /backup/partial/test-restores", app.ProtectFunc(b.getTestRestoresPartial, auth.AdminOnly))
 }
 
 // Handle prepares the controller for each request
@@ -80,27 +97,258 @@ func (b *BackupController) GetBackupList() []backup.BackupInfo {
 	return []backup.BackupInfo{}
 }
 
-// createBackup creates a manual backup
+// BackupSettings returns the saved backup settings for templates
+func (b *BackupController) BackupSettings() *models.Settings {
+	settings, err := models.GetSettings()
+	if err != nil {
+		return &models.Settings{}
+	}
+	return settings
+}
+
+// HasDestinationSecret reports whether the backup destination's password
+// or secret key is in the vault
+func (b *BackupController) HasDestinationSecret() bool {
+	_, err := models.GetBackupDestinationSecret()
+	return err == nil
+}
+
+// ConfigureBackups applies the backup settings to the scheduler: where
+// backups are copied, the key they're encrypted with and how long they
+// are kept
+func ConfigureBackups(settings *models.Settings) {
+	if backup.Scheduler == nil {
+		return
+	}
+
+	store, err := models.BackupDestination(settings)
+	if err != nil {
+		log.Printf("BackupController: Backups won't be copied off the host: %v", err)
+	}
+	backup.Scheduler.SetStore(store)
+
+	var key []byte
+	if settings.BackupEncrypted {
+		if key, err = models.EnsureBackupKey(); err != nil {
+			log.Printf("BackupController: Backups won't be encrypted, the key is unavailable: %v", err)
+		}
+	}
+	backup.Scheduler.SetEncryptionKey(key)
+	backup.Scheduler.SetRetention(settings.BackupRetention())
+	backup.Scheduler.SetDatabaseCheck(models.CheckBackupDatabase)
+	backup.Scheduler.SetTestRestore(settings.BackupTestRestoreInterval(), notifyTestRestore)
+}
+
+// notifyTestRestore tells administrators when a test restore fails
+func notifyTestRestore(result *backup.TestRestoreResult) {
+	if result.OK {
+		return
+	}
+	models.LogActivity("backup_test_failed", "Backup test restore failed", result.Summary(), "", "", "backup", result.Backup)
+	if err := models.NotifyAdmins("backup_test_failed", "Backup test restore failed",
+		result.Summary(), "/settings/backup"); err != nil {
+		log.Printf("BackupController: Failed to notify administrators of the failed test restore: %v", err)
+	}
+}
+
+// GetTestRestores returns the last test restores for templates
+func (b *BackupController) GetTestRestores() []backup.TestRestoreResult {
+	if backup.Scheduler == nil {
+		return nil
+	}
+	return backup.Scheduler.TestRestoreResults()
+}
+
+// createBackup starts a manual backup in the background, whose progress
+// the status partial follows
 func (b *BackupController) createBackup(w http.ResponseWriter, r *http.Request) {
 	if backup.Scheduler == nil {
 		b.RenderError(w, r, errors.New("Backup system not initialized"))
 		return
 	}
 
-	backupPath, err := backup.Scheduler.TriggerBackup()
-	if err != nil {
+	if err := backup.Scheduler.StartBackup(); err != nil {
 		b.RenderError(w, r, fmt.Errorf("backup failed: %w", err))
 		return
 	}
 
 	// Return success message
+	w.Header().Set("HX-Trigger", "backupStarted")
+	b.Render(w, r, "backup-success.html", map[string]any{
+		"Message": "Backup started, its progress is shown above",
+	})
+}
+
+// verifyBackup checks every copy of a backup against its manifest
+func (b *BackupController) verifyBackup(w http.ResponseWriter, r *http.Request) {
+	if backup.Scheduler == nil {
+		b.RenderError(w, r, errors.New("Backup system not initialized"))
+		return
+	}
+
+	if _, err := backup.Scheduler.VerifyBackup(r.FormValue("name")); err != nil {
+		b.RenderError(w, r, fmt.Errorf("verification failed: %w", err))
+		return
+	}
+	b.Refresh(w, r)
+}
+
+// testRestore test restores the latest backup in the background
+func (b *BackupController) testRestore(w http.ResponseWriter, r *http.Request) {
+	if backup.Scheduler == nil {
+		b.RenderError(w, r, errors.New("Backup system not initialized"))
+		return
+	}
+	if err := backup.Scheduler.StartTestRestore(); err != nil {
+		b.RenderError(w, r, err)
+		return
+	}
+	b.getTestRestoresPartial(w, r)
+}
+
+// updateSettings saves where backups are copied, whether they're
+// encrypted and how long they're kept. A destination is checked with a
+// test file before it is saved.
+func (b *BackupController) updateSettings(w http.ResponseWriter, r *http.Request) {
+	b.SetRequest(r)
+	auth := b.App.Use("auth").(*AuthController)
+	user := auth.CurrentUser()
+
+	settings, err := models.GetSettings()
+	if err != nil {
+		b.RenderError(w, r, err)
+		return
+	}
+
+	target := r.FormValue("backup_target")
+	switch target {
+	case "", storage.BackendS3, storage.BackendSFTP, storage.BackendWebDAV:
+	default:
+		b.RenderError(w, r, fmt.Errorf("unknown backup destination %q", target))
+		return
+	}
+	endpoint := strings.TrimSpace(r.FormValue("backup_endpoint"))
+	// Another server has another host key
+	if target != settings.BackupTarget || endpoint != settings.BackupEndpoint {
+		settings.BackupHostKey = ""
+	}
+	settings.BackupTarget = target
+	settings.BackupEndpoint = endpoint
+	settings.BackupBucket = strings.TrimSpace(r.FormValue("backup_bucket"))
+	settings.BackupRegion = strings.TrimSpace(r.FormValue("backup_region"))
+	settings.BackupPrefix = strings.TrimSpace(r.FormValue("backup_prefix"))
+	settings.BackupUsername = strings.TrimSpace(r.FormValue("backup_username"))
+	settings.BackupEncrypted = r.FormValue("backup_encrypted") == "on"
+
+	if settings.BackupTestRestoreDays, err = strconv.Atoi(r.FormValue("test_restore_days")); err != nil {
+		settings.BackupTestRestoreDays = 0
+	}
+
+	retention := []*int{
+		&settings.BackupRetentionDays, &settings.BackupMaxBackups,
+		&settings.BackupRemoteRetentionDays, &settings.BackupRemoteMaxBackups,
+	}
+	for i, name := range []string{"retention_days", "max_backups", "remote_retention_days", "remote_max_backups"} {
+		value := strings.TrimSpace(r.FormValue(name))
+		if value == "" {
+			*retention[i] = 0
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			b.RenderError(w, r, errors.New("retention must be a whole number of days or backups"))
+			return
+		}
+		*retention[i] = n
+	}
+
+	// The secret is never sent back to the browser, so empty keeps it
+	secret := strings.TrimSpace(r.FormValue("backup_secret"))
+	if target != "" {
+		// The host key is recorded the first time, and checked from then on
+		if target == storage.BackendSFTP && settings.BackupHostKey == "" {
+			if settings.BackupHostKey, err = storage.SFTPHostKey(endpoint); err != nil {
+				b.RenderError(w, r, err)
+				return
+			}
+		}
+		config := settings.BackupDestinationConfig()
+		if secret != "" {
+			config.SecretKey = secret
+		}
+		store, err := storage.New(config)
+		if err != nil {
+			b.RenderError(w, r, err)
+			return
+		}
+		if err := storage.Check(store); err != nil {
+			b.RenderError(w, r, err)
+			return
+		}
+	}
+
+	if target == "" {
+		models.DeleteBackupDestinationSecret()
+	} else if secret != "" {
+		if err := models.StoreBackupDestinationSecret(secret); err != nil {
+			b.RenderError(w, r, fmt.Errorf("failed to store the secret in the vault: %w", err))
+			return
+		}
+	}
+	if settings.BackupEncrypted {
+		if _, err := models.EnsureBackupKey(); err != nil {
+			b.RenderError(w, r, fmt.Errorf("failed to create the encryption key in the vault: %w", err))
+			return
+		}
+	}
+
+	settings.LastUpdatedBy = user.Email
+	settings.LastUpdatedAt = time.Now()
+	if err := models.GlobalSettings.Update(settings); err != nil {
+		b.RenderError(w, r, err)
+		return
+	}
+	ConfigureBackups(settings)
+
+	destination := "stay on the host"
+	if status := b.GetSchedulerStatus(); status.Destination != "" {
+		destination = "are copied to " + status.Destination
+	}
+	middleware.For(r.Context(), "backup").Info("changed backup settings", "user", user.Email, "destination", destination)
+	models.LogActivity("backup_settings_updated", "Changed backup settings",
+		"Backups "+destination, user.ID, "", "settings", "")
+
+	b.Refresh(w, r)
+}
+
+// revealKey shows the encryption key, which restores backups when the
+// workspace and its vault are gone and so must be kept somewhere else
+func (b *BackupController) revealKey(w http.ResponseWriter, r *http.Request) {
+	b.SetRequest(r)
+	auth := b.App.Use("auth").(*AuthController)
+	user := auth.CurrentUser()
+
+	key, err := models.GetBackupKey()
+	if err != nil {
+		b.RenderError(w, r, errors.New("there is no encryption key, turn encryption on first"))
+		return
+	}
+
+	middleware.For(r.Context(), "backup").Warn("revealed the backup encryption key", "user", user.Email, "key", backup.KeyID(key))
+	models.LogActivity("backup_key_revealed", "Revealed the backup encryption key",
+		"Key "+backup.KeyID(key), user.ID, "", "settings", "")
+
 	b.Render(w, r, "backup-success.html", map[string]any{
-		"Message": fmt.Sprintf("Backup created successfully: %s", backupPath),
+		"Message": fmt.Sprintf("Recovery key %s: %s", backup.KeyID(key), base64.StdEncoding.EncodeToString(key)),
 	})
 }
 
 // restoreBackup restores from a backup
 func (b *BackupController) restoreBackup(w http.ResponseWriter, r *http.Request) {
+	b.SetRequest(r)
+	auth := b.App.Use("auth").(*AuthController)
+	user := auth.CurrentUser()
+
 	if backup.Scheduler == nil {
 		b.RenderError(w, r, errors.New("Backup system not initialized"))
 		return
@@ -113,8 +361,21 @@ func (b *BackupController) restoreBackup(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	key, err := recoveryKey(r)
+	if err != nil {
+		b.RenderError(w, r, err)
+		return
+	}
+
 	// Perform restore
-	if err := backup.Scheduler.RestoreBackup(backupPath); err != nil {
+	err = backup.Scheduler.RestoreBackup(backupPath, key)
+	details := "Restored everything from " + filepath.Base(backupPath)
+	if err != nil {
+		details = fmt.Sprintf("Failed to restore everything from %s: %v", filepath.Base(backupPath), err)
+	}
+	models.LogAuditEvent(models.AuditEventBackupRestored, user.ID, user.Email, "backup", filepath.Base(backupPath),
+		"restore", details, middleware.ClientIP(r), r.UserAgent(), err == nil)
+	if err != nil {
 		b.RenderError(w, r, fmt.Errorf("restore failed: %w", err))
 		return
 	}
@@ -125,6 +386,125 @@ func (b *BackupController) restoreBackup(w http.ResponseWriter, r *http.Request)
 	})
 }
 
+// recoveryKey returns the recovery key entered with a restore, nil when
+// none was. Encrypted backups can be restored with the key they were made
+// with when the workspace's own key has changed or been lost.
+func recoveryKey(r *http.Request) ([]byte, error) {
+	recovery := strings.TrimSpace(r.FormValue("recovery_key"))
+	if recovery == "" {
+		return nil, nil
+	}
+	key, err := base64.StdEncoding.DecodeString(recovery)
+	if err != nil || len(key) != backup.KeySize {
+		return nil, errors.New("invalid recovery key")
+	}
+	return key, nil
+}
+
+// RestoreSnapshot returns what the backup chosen in the restore wizard
+// holds, nil before one is chosen or when it can't be read
+func (b *BackupController) RestoreSnapshot() *backup.Snapshot {
+	name := b.Request.URL.Query().Get("backup")
+	if backup.Scheduler == nil || name == "" {
+		return nil
+	}
+	snapshot, err := backup.Scheduler.Snapshot(name, nil)
+	if err != nil {
+		middleware.For(b.Request.Context(), "backup").Error("failed to read backup", "backup", name, "error", err)
+		return nil
+	}
+	return snapshot
+}
+
+// RepositoryName returns a repository's name for the restore wizard, or
+// its ID when it has since been deleted
+func (b *BackupController) RepositoryName(id string) string {
+	if repo, err := models.Repositories.Get(id); err == nil {
+		return repo.Name
+	}
+	return id
+}
+
+// restoreScope reads what to restore from the wizard's form
+func restoreScope(r *http.Request) backup.RestoreScope {
+	r.ParseForm()
+	return backup.RestoreScope{
+		Database:     r.FormValue("database") == "on",
+		Secrets:      r.FormValue("secrets") == "on",
+		Uploads:      r.FormValue("uploads") == "on",
+		Repositories: r.Form["repository"],
+	}
+}
+
+// planRestore shows what restoring part of a backup would change, without
+// changing anything
+func (b *BackupController) planRestore(w http.ResponseWriter, r *http.Request) {
+	if backup.Scheduler == nil {
+		b.RenderError(w, r, errors.New("Backup system not initialized"))
+		return
+	}
+	key, err := recoveryKey(r)
+	if err != nil {
+		b.RenderError(w, r, err)
+		return
+	}
+
+	plan, err := backup.Scheduler.PlanRestore(r.FormValue("backup"), restoreScope(r), key)
+	if err != nil {
+		b.RenderError(w, r, err)
+		return
+	}
+	b.Render(w, r, "backup-restore-plan.html", map[string]any{
+		"Plan": plan,
+	})
+}
+
+// applyRestore restores part of a backup once its changes were previewed,
+// recording who did it in the audit log
+func (b *BackupController) applyRestore(w http.ResponseWriter, r *http.Request) {
+	b.SetRequest(r)
+	auth := b.App.Use("auth").(*AuthController)
+	user := auth.CurrentUser()
+
+	if backup.Scheduler == nil {
+		b.RenderError(w, r, errors.New("Backup system not initialized"))
+		return
+	}
+	key, err := recoveryKey(r)
+	if err != nil {
+		b.RenderError(w, r, err)
+		return
+	}
+
+	name, scope := r.FormValue("backup"), restoreScope(r)
+	plan, err := backup.Scheduler.RestoreSelected(name, scope, key)
+
+	details := fmt.Sprintf("Restored %s from %s", scope, name)
+	if err != nil {
+		details = fmt.Sprintf("Failed to restore %s from %s: %v", scope, name, err)
+	} else {
+		details += fmt.Sprintf(": %d files added, %d replaced, %d removed", plan.Added, plan.Replaced, plan.Removed)
+	}
+	models.LogAuditEvent(models.AuditEventBackupRestored, user.ID, user.Email, "backup", name,
+		"restore", details, middleware.ClientIP(r), r.UserAgent(), err == nil)
+	if err != nil {
+		b.RenderError(w, r, fmt.Errorf("restore failed: %w", err))
+		return
+	}
+
+	middleware.For(r.Context(), "backup").Info("restored part of a backup", "user", user.Email, "scope", scope.String(), "backup", name)
+	models.LogActivity("backup_restored", "Restored "+scope.String()+" from a backup",
+		details, user.ID, "", "backup", name)
+
+	message := "Restored " + scope.String() + " from " + name + "."
+	if plan.NeedsRestart() {
+		message += " Restart the workspace to load the restored data."
+	}
+	b.Render(w, r, "backup-success.html", map[string]any{
+		"Message": message,
+	})
+}
+
 // listBackups returns the list of available backups as JSON
 func (b *BackupController) listBackups(w http.ResponseWriter, r *http.Request) {
 	if backup.Scheduler == nil {
@@ -180,9 +560,21 @@ func (b *BackupController) getBackupListPartial(w http.ResponseWriter, r *http.R
 	})
 }
 
-// getStatusPartial returns the scheduler status as HTML partial
+// getTestRestoresPartial returns the test restore results as HTML partial
+func (b *BackupController) getTestRestoresPartial(w http.ResponseWriter, r *http.Request) {
+	b.Render(w, r, "backup-test-restores.html", map[string]any{
+		"Results": b.GetTestRestores(),
+	})
+}
+
+// getStatusPartial returns the scheduler status as HTML partial. When it
+// was polled during a backup that has since finished, the list is told to
+// refresh.
 func (b *BackupController) getStatusPartial(w http.ResponseWriter, r *http.Request) {
 	status := b.GetSchedulerStatus()
+	if r.URL.Query().Has("polling") && !status.Running && !status.Upload.Active() {
+		w.Header().Set("HX-Trigger", "backupFinished")
+	}
 
 	b.Render(w, r, "backup-status.html", map[string]any{
 		"Status": status,
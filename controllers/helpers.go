@@ -120,14 +120,13 @@ func GetRepositoryWithAccess(r *http.Request, needsWrite bool, auth *AuthControl
 		return nil, fmt.Errorf("authentication required")
 	}
 
-	// For write access, must be admin
-	if needsWrite && !user.IsAdmin {
-		return nil, fmt.Errorf("admin access required")
+	// Admins, or the user's teams, grant access beyond reading public repos
+	role := models.RoleRead
+	if needsWrite {
+		role = models.RoleWrite
 	}
-
-	// For read access on private repos, must be admin
-	if repo.Visibility != "public" && !user.IsAdmin {
-		return nil, fmt.Errorf("access denied - private repository")
+	if err := models.CheckRepoAccess(user, repo.ID, role); err != nil {
+		return nil, err
 	}
 
 	return repo, nil
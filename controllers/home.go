@@ -339,6 +339,74 @@ func (c *HomeController) NextActivitiesOffset() int {
 	return offset + 20
 }
 
+// AssignedIssues returns open issues assigned to the current user
+func (c *HomeController) AssignedIssues() ([]*models.Issue, error) {
+	auth := c.Use("auth").(*AuthController)
+	user := auth.GetAuthenticatedUser(c.Request)
+	if user == nil {
+		return nil, nil
+	}
+
+	return models.Issues.Search(`
+		WHERE (AssigneeID = ? OR ID IN (SELECT IssueID FROM issue_assignees WHERE UserID = ?))
+		AND Status NOT IN ('closed', 'resolved')
+		ORDER BY Priority ASC, UpdatedAt DESC LIMIT 10
+	`, user.ID, user.ID)
+}
+
+// ReviewRequests returns open pull requests from others on repositories the current user owns
+func (c *HomeController) ReviewRequests() ([]*models.PullRequest, error) {
+	auth := c.Use("auth").(*AuthController)
+	user := auth.GetAuthenticatedUser(c.Request)
+	if user == nil {
+		return nil, nil
+	}
+
+	return models.PullRequests.Search(`
+		WHERE Status IN ('open', 'changes_requested')
+		AND AuthorID != ?
+		AND RepoID IN (SELECT ID FROM repositories WHERE UserID = ?)
+		ORDER BY UpdatedAt DESC LIMIT 10
+	`, user.ID, user.ID)
+}
+
+// FailingActions returns actions whose last run failed on repositories the current user owns
+func (c *HomeController) FailingActions() ([]*models.Action, error) {
+	auth := c.Use("auth").(*AuthController)
+	user := auth.GetAuthenticatedUser(c.Request)
+	if user == nil {
+		return nil, nil
+	}
+
+	return models.Actions.Search(`
+		WHERE Status = 'failed'
+		AND RepoID IN (SELECT ID FROM repositories WHERE UserID = ?)
+		ORDER BY UpdatedAt DESC LIMIT 5
+	`, user.ID)
+}
+
+// RecentConversations returns the current user's latest AI conversations
+func (c *HomeController) RecentConversations() ([]*models.Conversation, error) {
+	auth := c.Use("auth").(*AuthController)
+	user := auth.GetAuthenticatedUser(c.Request)
+	if user == nil {
+		return nil, nil
+	}
+
+	return models.Conversations.Search("WHERE UserID = ? ORDER BY UpdatedAt DESC LIMIT 5", user.ID)
+}
+
+// ContributionCalendar returns the current user's activity heatmap for the past year
+func (c *HomeController) ContributionCalendar() (*models.ContributionCalendar, error) {
+	auth := c.Use("auth").(*AuthController)
+	user := auth.GetAuthenticatedUser(c.Request)
+	if user == nil {
+		return nil, errors.New("authentication required")
+	}
+
+	return models.GetContributionCalendar(user.ID, 52)
+}
+
 // ActiveWorkspaces returns the count of active workspaces (admin only)
 func (c *HomeController) ActiveWorkspaces() int {
 	// Count Docker containers that are workspace containers
@@ -369,16 +437,24 @@ func (c *HomeController) PublicActivity() ([]*models.Activity, error) {
 	`)
 }
 
-// homePage handles the home page - redirects to signup if no users exist
+// homePage handles the home page - redirects to the setup wizard on first run
 func (c *HomeController) homePage(w http.ResponseWriter, r *http.Request) {
 	c.SetRequest(r)
 	// Check if any users exist
 	if models.Auth.Users.Count("") == 0 {
-		// No users, redirect to signup
-		c.Redirect(w, r, "/signup")
+		// No users, start the setup wizard with the admin account
+		c.Redirect(w, r, "/setup")
 		return
 	}
 
+	// Admins continue the setup wizard until it is finished or skipped
+	if user, _, err := c.App.Use("auth").(*AuthController).Authenticate(r); err == nil && user.IsAdmin {
+		if settings, err := models.GetSettings(); err == nil && settings.NeedsSetup() {
+			c.Redirect(w, r, "/setup")
+			return
+		}
+	}
+
 	// Show home page (public or dashboard based on auth status)
 	c.Render(w, r, "home.html", nil)
 }
@@ -493,13 +569,20 @@ func (c *HomeController) submitPublicIssue(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	// Add public-submission tag
-	err = models.AddTagToIssue(newIssue.ID, "public-submission")
+	// Label it as a public submission
+	tag, err := models.GetOrCreateTag("public-submission", repo.ID)
+	if err == nil {
+		err = models.AddLabelToIssue(newIssue.ID, tag.ID, "")
+	}
 	if err != nil {
 		c.RenderError(w, r, fmt.Errorf("failed to create issue: %w", err))
 		return
 	}
 
+	models.RecordReferences(repo.ID, models.RefIssue, newIssue.ID, newIssue.Title+"\n"+newIssue.Body, "")
+	models.RecordMentions(repo.ID, models.RefIssue, newIssue.ID, newIssue.Title+"\n"+newIssue.Body, "")
+	models.RunProjectRules("issue_opened", repo.ID, newIssue.ID, "")
+
 	// Redirect back to the issues page with success
 	c.Redirect(w, r, "/public/repos/"+repo.ID+"/issues?submitted=true")
 }
@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"cmp"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -8,6 +9,7 @@ import (
 	"net/http"
 	"net/url"
 	"os/exec"
+	"strconv"
 	"strings"
 	"time"
 
@@ -46,6 +48,7 @@ func (c *IntegrationsController) Setup(app *application.App) {
 	http.Handle("POST /repos/{id}/github/setup", app.ProtectFunc(c.setupGitHubRepo, AdminOnly()))
 	http.Handle("POST /repos/{id}/github/sync", app.ProtectFunc(c.syncGitHubRepo, AdminOnly()))
 	http.Handle("POST /repos/{id}/github/disconnect", app.ProtectFunc(c.disconnectGitHubRepo, AdminOnly()))
+	http.Handle("POST /repos/{id}/github/settings", app.ProtectFunc(c.updateGitHubSettings, AdminOnly()))
 
 	// Git sync operations
 	http.Handle("POST /repos/{id}/github/push", app.ProtectFunc(c.pushGitHubRepo, auth.Required))
@@ -53,16 +56,38 @@ func (c *IntegrationsController) Setup(app *application.App) {
 	http.Handle("GET /repos/{id}/github/status", app.ProtectFunc(c.getSyncStatus, auth.Required))
 	http.Handle("POST /repos/{id}/github/configure-remote", app.ProtectFunc(c.configureGitHubRemote, AdminOnly()))
 
+	// Bitbucket repository mirroring
+	http.Handle("POST /repos/{id}/bitbucket/setup", app.ProtectFunc(c.setupBitbucketRepo, AdminOnly()))
+	http.Handle("POST /repos/{id}/bitbucket/disconnect", app.ProtectFunc(c.disconnectBitbucketRepo, AdminOnly()))
+	http.Handle("POST /repos/{id}/bitbucket/push", app.ProtectFunc(c.pushBitbucketRepo, auth.Required))
+	http.Handle("POST /repos/{id}/bitbucket/pull", app.ProtectFunc(c.pullBitbucketRepo, auth.Required))
+	http.Handle("GET /repos/{id}/bitbucket/status", app.ProtectFunc(c.getBitbucketSyncStatus, auth.Required))
+
+	// Dependency scanning
+	http.Handle("POST /repos/{id}/integrations/dependencies/scan", app.ProtectFunc(c.scanDependencies, AdminOnly()))
+
+	// Slack, Discord and Matrix notifications of repository events
+	http.Handle("POST /repos/{id}/integrations/chat", app.ProtectFunc(c.createChatIntegration, AdminOnly()))
+	http.Handle("POST /repos/{id}/integrations/chat/{chatID}", app.ProtectFunc(c.updateChatIntegration, AdminOnly()))
+	http.Handle("POST /repos/{id}/integrations/chat/{chatID}/toggle", app.ProtectFunc(c.toggleChatIntegration, AdminOnly()))
+	http.Handle("POST /repos/{id}/integrations/chat/{chatID}/test", app.ProtectFunc(c.testChatIntegration, AdminOnly()))
+	http.Handle("POST /repos/{id}/integrations/chat/{chatID}/delete", app.ProtectFunc(c.deleteChatIntegration, AdminOnly()))
+
 	// OAuth flow
 	http.Handle("GET /auth/github", app.ProtectFunc(c.initiateGitHubOAuth, auth.Required))
 	http.Handle("GET /auth/github/callback", app.ProtectFunc(c.handleGitHubCallback, auth.Required))
 	http.Handle("POST /auth/github/disconnect", app.ProtectFunc(c.disconnectGitHubAccount, auth.Required))
+	http.Handle("POST /auth/bitbucket/connect", app.ProtectFunc(c.connectBitbucketAccount, auth.Required))
+	http.Handle("POST /auth/bitbucket/disconnect", app.ProtectFunc(c.disconnectBitbucketAccount, auth.Required))
 
 	// GitHub repository listing for import
 	http.Handle("GET /github/repos", app.ProtectFunc(c.listGitHubRepos, auth.Required))
 
 	// Vault management
 	http.Handle("POST /integrations/vault/restart", app.ProtectFunc(c.restartVault, AdminOnly()))
+
+	// Automatic GitHub sync for repositories with auto-sync on
+	github.Scheduler.Start()
 }
 
 // Handle prepares controller for request
@@ -186,7 +211,7 @@ func (c *IntegrationsController) configureGitHubOAuth(w http.ResponseWriter, r *
 
 	// Store OAuth app credentials in vault
 	if clientID != "" && clientSecret != "" {
-		err = models.Secrets.StoreSecret("github/oauth_app", map[string]any{
+		err = models.StoreSecret("github/oauth_app", map[string]any{
 			"client_id":     clientID,
 			"client_secret": clientSecret,
 			"enabled":       settings.GitHubEnabled,
@@ -286,7 +311,7 @@ func (c *IntegrationsController) handleGitHubCallback(w http.ResponseWriter, r *
 	}
 
 	// Store user's OAuth token and username in vault
-	err = models.Secrets.StoreSecret(fmt.Sprintf("github/users/%s", user.ID), map[string]any{
+	err = models.StoreSecret(fmt.Sprintf("github/users/%s", user.ID), map[string]any{
 		"token":    token,
 		"username": username,
 	})
@@ -482,6 +507,7 @@ func (c *IntegrationsController) setupGitHubRepo(w http.ResponseWriter, r *http.
 		"github_token":   githubToken,
 		"sync_direction": syncDirection,
 		"auto_sync":      autoSync,
+		"owner_id":       user.ID,
 		"enabled":        true,
 	})
 	if err != nil {
@@ -553,46 +579,20 @@ func (c *IntegrationsController) syncGitHubRepo(w http.ResponseWriter, r *http.R
 		return
 	}
 
-	// Get user's GitHub token for code sync
-	token, err := models.GetGitHubOAuthToken(user.ID)
+	// Sync code, issues and PRs, recording the run in the sync history
+	run, err := github.Scheduler.Sync(repo, user.ID, models.SyncTriggerManual)
 	if err != nil {
-		// Fall back to issues/PRs only sync
-		syncService := &github.GitHubSyncService{}
-		if err := syncService.SyncRepository(repo.ID, user.ID); err != nil {
-			c.RenderError(w, r, fmt.Errorf("sync failed: %w", err))
-			return
-		}
-	} else {
-		// Full sync including code
-		// Configure remote if needed
-		if !repo.RemoteConfigured && repo.GitHubURL != "" {
-			gitOps := github.NewGitOperationsService()
-			if err := gitOps.ConfigureRemote(repo, repo.GitHubURL); err != nil {
-				log.Printf("Failed to configure remote: %v", err)
-			}
-		}
-
-		// Perform code sync
-		gitOps := github.NewGitOperationsService()
-		if err := gitOps.SyncWithRemote(repo, token); err != nil {
-			log.Printf("Code sync failed: %v", err)
-			// Continue with issues/PRs sync even if code sync fails
-		}
-
-		// Sync issues and PRs
-		syncService := &github.GitHubSyncService{}
-		if err := syncService.SyncRepository(repo.ID, user.ID); err != nil {
-			log.Printf("Issues/PRs sync failed: %v", err)
-		}
+		c.RenderError(w, r, fmt.Errorf("sync failed: %w", err))
+		return
+	}
+	if run.Outcome == models.SyncFailed {
+		c.RenderError(w, r, fmt.Errorf("sync failed: %s", run.Error))
+		return
 	}
-
-	// Update last sync time
-	repo.LastSyncAt = time.Now()
-	models.Repositories.Update(repo)
 
 	// Log activity
 	models.LogActivity("github_synced", "Synced repository with GitHub",
-		fmt.Sprintf("Full sync completed for %s", repo.Name),
+		fmt.Sprintf("Manual sync of %s finished with %s", repo.Name, run.Outcome),
 		user.ID, repo.ID, "integration", "")
 
 	// Redirect to integrations page
@@ -649,6 +649,62 @@ func (c *IntegrationsController) disconnectGitHubRepo(w http.ResponseWriter, r *
 	c.Redirect(w, r, fmt.Sprintf("/repos/%s/integrations", repoID))
 }
 
+// updateGitHubSettings handles changes to how a connected repository syncs with GitHub
+func (c *IntegrationsController) updateGitHubSettings(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	auth := c.Use("auth").(*AuthController)
+	user, _, _ := auth.Authenticate(r)
+
+	repoID := r.PathValue("id")
+	repo, err := models.Repositories.Get(repoID)
+	if err != nil {
+		c.RenderError(w, r, errors.New("repository not found"))
+		return
+	}
+
+	if repo.GitHubURL == "" {
+		c.RenderError(w, r, errors.New("GitHub not configured"))
+		return
+	}
+
+	syncDirection := r.FormValue("sync_direction")
+	if syncDirection != "push" && syncDirection != "pull" && syncDirection != "both" {
+		c.RenderError(w, r, errors.New("invalid sync direction"))
+		return
+	}
+
+	commentSync := r.FormValue("comment_sync")
+	if commentSync != "" && commentSync != models.CommentSyncPull && commentSync != models.CommentSyncBoth {
+		c.RenderError(w, r, errors.New("invalid comment sync mode"))
+		return
+	}
+
+	syncInterval, err := strconv.Atoi(cmp.Or(r.FormValue("sync_interval"), strconv.Itoa(models.DefaultSyncInterval)))
+	if err != nil || syncInterval < models.MinSyncInterval || syncInterval > models.MaxSyncInterval {
+		c.RenderError(w, r, fmt.Errorf("sync interval must be between %d and %d minutes", models.MinSyncInterval, models.MaxSyncInterval))
+		return
+	}
+
+	repo.SyncDirection = syncDirection
+	repo.AutoSync = r.FormValue("auto_sync") == "true"
+	repo.CommentSync = commentSync
+	repo.SyncInterval = syncInterval
+
+	// Start the new schedule afresh, without any earlier failure backoff
+	repo.SyncFailures = 0
+	repo.NextSyncAt = time.Time{}
+	if err := models.Repositories.Update(repo); err != nil {
+		c.RenderError(w, r, errors.New("failed to save GitHub settings"))
+		return
+	}
+
+	models.LogActivity("github_settings_updated", "Updated GitHub sync settings",
+		fmt.Sprintf("Sync direction %s every %d minutes, comment sync %s", syncDirection, syncInterval, cmp.Or(commentSync, "off")),
+		user.ID, repo.ID, "integration", "")
+
+	c.Redirect(w, r, fmt.Sprintf("/repos/%s/integrations", repoID))
+}
+
 // ========== Git Sync Methods ==========
 
 // GetRepoSyncStatus returns the sync status for the current repository
@@ -680,6 +736,16 @@ func (c *IntegrationsController) IsRepoSynced() bool {
 	return status == "synced"
 }
 
+// GitHubSyncRuns returns the current repository's most recent GitHub syncs
+func (c *IntegrationsController) GitHubSyncRuns() []*models.GitHubSyncRun {
+	runs, err := models.RepoGitHubSyncRuns(c.Request.PathValue("id"), 10)
+	if err != nil {
+		log.Printf("Failed to get sync history for repo %s: %v", c.Request.PathValue("id"), err)
+		return nil
+	}
+	return runs
+}
+
 // pushGitHubRepo handles pushing commits to GitHub
 func (c *IntegrationsController) pushGitHubRepo(w http.ResponseWriter, r *http.Request) {
 	c.SetRequest(r)
@@ -698,7 +764,7 @@ func (c *IntegrationsController) pushGitHubRepo(w http.ResponseWriter, r *http.R
 	}
 
 	// Check permissions (need write access to push)
-	if !user.IsAdmin && repo.UserID != user.ID {
+	if err := models.CheckRepoAccess(user, repo.ID, models.RoleWrite); err != nil {
 		c.RenderError(w, r, errors.New("permission required to push changes"))
 		return
 	}
@@ -753,7 +819,7 @@ func (c *IntegrationsController) pullGitHubRepo(w http.ResponseWriter, r *http.R
 	}
 
 	// Check permissions (need write access to pull)
-	if !user.IsAdmin && repo.UserID != user.ID {
+	if err := models.CheckRepoAccess(user, repo.ID, models.RoleWrite); err != nil {
 		c.RenderError(w, r, errors.New("permission required to pull changes"))
 		return
 	}
@@ -820,10 +886,13 @@ func (c *IntegrationsController) getSyncStatus(w http.ResponseWriter, r *http.Re
 
 	// Render status partial
 	data := map[string]any{
-		"Ahead":  ahead,
-		"Behind": behind,
-		"Status": status,
-		"Repo":   repo,
+		"Ahead":        ahead,
+		"Behind":       behind,
+		"Status":       status,
+		"Repo":         repo,
+		"Configured":   repo.RemoteConfigured,
+		"Provider":     "github",
+		"ProviderName": "GitHub",
 	}
 
 	c.Render(w, r, "repo-sync-status.html", data)
@@ -847,7 +916,7 @@ func (c *IntegrationsController) configureGitHubRemote(w http.ResponseWriter, r
 	}
 
 	// Check permissions (need admin access to configure remote)
-	if !user.IsAdmin && repo.UserID != user.ID {
+	if err := models.CheckRepoAccess(user, repo.ID, models.RoleAdmin); err != nil {
 		c.RenderError(w, r, errors.New("permission required to configure remote"))
 		return
 	}
@@ -0,0 +1,293 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"workspace/internal/bitbucket"
+	"workspace/internal/github"
+	"workspace/models"
+)
+
+// ========== Bitbucket Account Methods ==========
+
+// HasBitbucketConnected checks if the current user has stored Bitbucket credentials
+func (c *IntegrationsController) HasBitbucketConnected() bool {
+	return c.GetBitbucketUsername() != ""
+}
+
+// GetBitbucketUsername returns the current user's Bitbucket username if connected
+func (c *IntegrationsController) GetBitbucketUsername() string {
+	auth := c.Use("auth").(*AuthController)
+	user, _, err := auth.Authenticate(c.Request)
+	if err != nil {
+		return ""
+	}
+
+	creds, err := models.GetBitbucketCredentials(user.ID)
+	if err != nil {
+		return ""
+	}
+	return creds.Username
+}
+
+// connectBitbucketAccount verifies and stores the user's Bitbucket app password or OAuth token
+func (c *IntegrationsController) connectBitbucketAccount(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	auth := c.Use("auth").(*AuthController)
+	user, _, err := auth.Authenticate(r)
+	if err != nil {
+		c.RenderError(w, r, errors.New("authentication required"))
+		return
+	}
+
+	creds := &models.BitbucketCredentials{
+		Username:    strings.TrimSpace(r.FormValue("username")),
+		AppPassword: strings.TrimSpace(r.FormValue("app_password")),
+		Token:       strings.TrimSpace(r.FormValue("token")),
+	}
+	if creds.Token == "" && (creds.Username == "" || creds.AppPassword == "") {
+		c.RenderError(w, r, errors.New("a username and app password, or an OAuth token, is required"))
+		return
+	}
+
+	// Verify the credentials before storing them
+	account, err := bitbucket.NewBitbucketClientWithCredentials(creds).CurrentUser()
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+	creds.Username = account.Username
+
+	if err := models.StoreBitbucketCredentials(user.ID, creds); err != nil {
+		c.RenderError(w, r, errors.New("Failed to store Bitbucket credentials"))
+		return
+	}
+
+	models.LogActivity("bitbucket_connected", "Connected Bitbucket account",
+		fmt.Sprintf("User connected Bitbucket account: %s", account.Username),
+		user.ID, "", "integration", "")
+
+	c.Refresh(w, r)
+}
+
+// disconnectBitbucketAccount removes the user's Bitbucket credentials
+func (c *IntegrationsController) disconnectBitbucketAccount(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	auth := c.Use("auth").(*AuthController)
+	user, _, err := auth.Authenticate(r)
+	if err != nil {
+		c.RenderError(w, r, errors.New("authentication required"))
+		return
+	}
+
+	if err := models.DeleteBitbucketCredentials(user.ID); err != nil {
+		log.Printf("Failed to delete Bitbucket credentials: %v", err)
+	}
+
+	models.LogActivity("bitbucket_disconnected", "Disconnected Bitbucket account",
+		"User disconnected their Bitbucket account", user.ID, "", "integration", "")
+
+	c.Redirect(w, r, "/settings/account")
+}
+
+// ========== Repository Bitbucket Integration ==========
+
+// setupBitbucketRepo links a repository to a Bitbucket Cloud repository
+func (c *IntegrationsController) setupBitbucketRepo(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	auth := c.Use("auth").(*AuthController)
+	user, _, err := auth.Authenticate(r)
+	if err != nil {
+		c.RenderError(w, r, errors.New("authentication required"))
+		return
+	}
+
+	repoID := r.PathValue("id")
+	repo, err := models.Repositories.Get(repoID)
+	if err != nil {
+		c.RenderError(w, r, errors.New("repository not found"))
+		return
+	}
+
+	workspace, slug, err := bitbucket.ParseBitbucketURL(r.FormValue("bitbucket_url"))
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	// Confirm the repository exists and the user's credentials can reach it
+	client, err := bitbucket.NewBitbucketClient(user.ID)
+	if err != nil {
+		c.RenderError(w, r, errors.New("Bitbucket account not connected. Please connect your account in Settings."))
+		return
+	}
+	if _, err := client.GetRepository(workspace, slug); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	gitOps := github.NewGitOperationsService()
+	if err := gitOps.ConfigureBitbucketRemote(repo, bitbucket.CloneURL(workspace, slug)); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	models.LogActivity("bitbucket_repo_connected", "Connected repository to Bitbucket",
+		fmt.Sprintf("Repository %s mirrored to bitbucket.org/%s/%s", repo.Name, workspace, slug),
+		user.ID, repo.ID, "integration", "")
+
+	c.Redirect(w, r, fmt.Sprintf("/repos/%s/integrations", repoID))
+}
+
+// disconnectBitbucketRepo removes a repository's Bitbucket remote
+func (c *IntegrationsController) disconnectBitbucketRepo(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	auth := c.Use("auth").(*AuthController)
+	user, _, _ := auth.Authenticate(r)
+
+	repoID := r.PathValue("id")
+	repo, err := models.Repositories.Get(repoID)
+	if err != nil {
+		c.RenderError(w, r, errors.New("repository not found"))
+		return
+	}
+
+	gitOps := github.NewGitOperationsService()
+	if err := gitOps.RemoveBitbucketRemote(repo); err != nil {
+		c.RenderError(w, r, errors.New("failed to clear Bitbucket settings"))
+		return
+	}
+
+	models.LogActivity("bitbucket_disconnected", "Disconnected repository from Bitbucket",
+		"Bitbucket mirror removed", user.ID, repo.ID, "integration", "")
+
+	c.Redirect(w, r, fmt.Sprintf("/repos/%s/integrations", repoID))
+}
+
+// pushBitbucketRepo handles pushing a branch to Bitbucket
+func (c *IntegrationsController) pushBitbucketRepo(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	user, repo, creds, err := c.bitbucketRequest(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	branch := r.FormValue("branch")
+	gitOps := github.NewGitOperationsService()
+	if err := gitOps.PushToBitbucket(repo, branch, creds); err != nil {
+		c.RenderError(w, r, fmt.Errorf("push failed: %w", err))
+		return
+	}
+
+	models.LogActivity("bitbucket_push", "Pushed to Bitbucket",
+		fmt.Sprintf("Pushed branch %s to Bitbucket", branchOrDefault(repo, branch)),
+		user.ID, repo.ID, "git", "")
+
+	c.Redirect(w, r, fmt.Sprintf("/repos/%s/integrations", repo.ID))
+}
+
+// pullBitbucketRepo handles pulling a branch from Bitbucket
+func (c *IntegrationsController) pullBitbucketRepo(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	user, repo, creds, err := c.bitbucketRequest(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	branch := r.FormValue("branch")
+	gitOps := github.NewGitOperationsService()
+	if err := gitOps.PullFromBitbucket(repo, branch, creds); err != nil {
+		c.RenderError(w, r, fmt.Errorf("pull failed: %w", err))
+		return
+	}
+
+	models.LogActivity("bitbucket_pull", "Pulled from Bitbucket",
+		fmt.Sprintf("Pulled branch %s from Bitbucket", branchOrDefault(repo, branch)),
+		user.ID, repo.ID, "git", "")
+
+	c.Redirect(w, r, fmt.Sprintf("/repos/%s/integrations", repo.ID))
+}
+
+// getBitbucketSyncStatus renders the ahead/behind indicator for the Bitbucket mirror
+func (c *IntegrationsController) getBitbucketSyncStatus(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	auth := c.Use("auth").(*AuthController)
+	user, _, err := auth.Authenticate(r)
+	if err != nil {
+		c.RenderError(w, r, errors.New("authentication required"))
+		return
+	}
+
+	repo, err := models.Repositories.Get(r.PathValue("id"))
+	if err != nil {
+		c.RenderError(w, r, errors.New("repository not found"))
+		return
+	}
+	if err := models.CheckRepoAccess(user, repo.ID, models.RoleRead); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	// Public mirrors can be fetched without the user's credentials
+	var creds *url.Userinfo
+	if stored, err := models.GetBitbucketCredentials(user.ID); err == nil {
+		creds = stored.GitUserinfo()
+	}
+
+	gitOps := github.NewGitOperationsService()
+	ahead, behind, status, err := gitOps.GetBitbucketSyncStatus(repo, creds)
+	if err != nil {
+		c.RenderError(w, r, fmt.Errorf("failed to get status: %w", err))
+		return
+	}
+
+	c.Render(w, r, "repo-sync-status.html", map[string]any{
+		"Ahead":        ahead,
+		"Behind":       behind,
+		"Status":       status,
+		"Repo":         repo,
+		"Configured":   repo.BitbucketURL != "",
+		"Provider":     "bitbucket",
+		"ProviderName": "Bitbucket",
+	})
+}
+
+// bitbucketRequest loads the user, repository and Bitbucket credentials for a
+// push or pull, requiring write access to the repository
+func (c *IntegrationsController) bitbucketRequest(r *http.Request) (*models.User, *models.Repository, *url.Userinfo, error) {
+	auth := c.Use("auth").(*AuthController)
+	user, _, err := auth.Authenticate(r)
+	if err != nil {
+		return nil, nil, nil, errors.New("authentication required")
+	}
+
+	repo, err := models.Repositories.Get(r.PathValue("id"))
+	if err != nil {
+		return nil, nil, nil, errors.New("repository not found")
+	}
+
+	if err := models.CheckRepoAccess(user, repo.ID, models.RoleWrite); err != nil {
+		return nil, nil, nil, errors.New("write access required to sync with Bitbucket")
+	}
+
+	creds, err := models.GetBitbucketCredentials(user.ID)
+	if err != nil {
+		return nil, nil, nil, errors.New("Bitbucket account not connected. Please connect your account in Settings.")
+	}
+	return user, repo, creds.GitUserinfo(), nil
+}
+
+// branchOrDefault names the branch a push or pull used
+func branchOrDefault(repo *models.Repository, branch string) string {
+	if branch == "" {
+		return repo.GetDefaultBranch()
+	}
+	return branch
+}
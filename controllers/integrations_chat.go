@@ -0,0 +1,173 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"workspace/models"
+	"workspace/services"
+)
+
+// ChatIntegrations returns the Slack, Discord and Matrix integrations of
+// the current repository
+func (c *IntegrationsController) ChatIntegrations() ([]*models.ChatIntegration, error) {
+	repo, err := c.CurrentRepo()
+	if err != nil {
+		return nil, err
+	}
+	return models.RepoChatIntegrations(repo.ID)
+}
+
+// ChatEvents returns the events a chat integration can post
+func (c *IntegrationsController) ChatEvents() []string {
+	return models.ChatEvents
+}
+
+// ChatTemplates returns the default message of each chat event
+func (c *IntegrationsController) ChatTemplates() map[string]string {
+	return models.ChatTemplates
+}
+
+// createChatIntegration handles POST /repos/{id}/integrations/chat
+func (c *IntegrationsController) createChatIntegration(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	repo, err := models.Repositories.Get(r.PathValue("id"))
+	if err != nil {
+		c.RenderError(w, r, errors.New("repository not found"))
+		return
+	}
+
+	// Admin already verified by route middleware
+	user, _, err := c.Use("auth").(*AuthController).Authenticate(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+	chat, err := models.CreateChatIntegration(repo.ID, r.FormValue("provider"), r.FormValue("url"),
+		r.FormValue("room"), r.FormValue("token"), r.Form["events"], r.FormValue("template"), user.ID)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	models.LogActivity("chat_integration_created", fmt.Sprintf("Added %s notifications to %s", chat.Provider, repo.Name),
+		"Repository events are posted to chat", user.ID, repo.ID, "integration", chat.ID)
+
+	c.Refresh(w, r)
+}
+
+// updateChatIntegration handles POST /repos/{id}/integrations/chat/{chatID},
+// changing where the integration posts, which events and their message
+func (c *IntegrationsController) updateChatIntegration(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	_, chat, err := c.requestChatIntegration(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+	// The Matrix token is never sent back to the browser, so empty keeps it
+	err = chat.Update(r.FormValue("url"), r.FormValue("room"), r.FormValue("token"), r.Form["events"], r.FormValue("template"))
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	c.Refresh(w, r)
+}
+
+// toggleChatIntegration handles POST /repos/{id}/integrations/chat/{chatID}/toggle,
+// pausing or resuming its messages
+func (c *IntegrationsController) toggleChatIntegration(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	_, chat, err := c.requestChatIntegration(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	chat.Active = !chat.Active
+	if err := models.ChatIntegrations.Update(chat); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	c.Refresh(w, r)
+}
+
+// testChatIntegration handles POST /repos/{id}/integrations/chat/{chatID}/test,
+// posting a test message
+func (c *IntegrationsController) testChatIntegration(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	_, chat, err := c.requestChatIntegration(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	// Admin already verified by route middleware
+	user, _, err := c.Use("auth").(*AuthController).Authenticate(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	if _, err := services.TestChat(chat, user.ID); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	c.Refresh(w, r)
+}
+
+// deleteChatIntegration handles POST /repos/{id}/integrations/chat/{chatID}/delete
+func (c *IntegrationsController) deleteChatIntegration(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	repo, chat, err := c.requestChatIntegration(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	// Admin already verified by route middleware
+	user, _, err := c.Use("auth").(*AuthController).Authenticate(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	if err := models.DeleteChatIntegration(chat); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	models.LogActivity("chat_integration_deleted", fmt.Sprintf("Removed %s notifications from %s", chat.Provider, repo.Name),
+		"Repository events are no longer posted to chat", user.ID, repo.ID, "integration", chat.ID)
+
+	c.Refresh(w, r)
+}
+
+// requestChatIntegration returns the repository and chat integration
+// named in the request's path, failing when the integration belongs to
+// another repository
+func (c *IntegrationsController) requestChatIntegration(r *http.Request) (*models.Repository, *models.ChatIntegration, error) {
+	repo, err := models.Repositories.Get(r.PathValue("id"))
+	if err != nil {
+		return nil, nil, errors.New("repository not found")
+	}
+	chat, err := models.ChatIntegrations.Get(r.PathValue("chatID"))
+	if err != nil || chat.RepoID != repo.ID {
+		return nil, nil, errors.New("chat integration not found")
+	}
+	return repo, chat, nil
+}
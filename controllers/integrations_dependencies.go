@@ -0,0 +1,58 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	aiService "workspace/internal/ai"
+	"workspace/models"
+)
+
+// DependencyScan returns the current repository's latest dependency scan,
+// or nil when it hasn't been scanned
+func (c *IntegrationsController) DependencyScan() *models.DependencyScan {
+	repo, err := c.CurrentRepo()
+	if err != nil {
+		return nil
+	}
+	return models.LatestDependencyScan(repo.ID)
+}
+
+// scanDependencies handles POST /repos/{id}/integrations/dependencies/scan,
+// queueing a dependency scan of the repository
+func (c *IntegrationsController) scanDependencies(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	repo, err := models.Repositories.Get(r.PathValue("id"))
+	if err != nil {
+		c.RenderError(w, r, errors.New("repository not found"))
+		return
+	}
+
+	// Admin already verified by route middleware
+	user, _, err := c.Use("auth").(*AuthController).Authenticate(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	service := aiService.Instance
+	if service == nil {
+		c.RenderError(w, r, errors.New("the AI queue isn't running, so dependencies can't be scanned"))
+		return
+	}
+	err = service.EnqueueTask("dependency_check", map[string]any{
+		"repo_id": repo.ID,
+		"user_id": user.ID,
+	}, 4)
+	if err != nil {
+		c.RenderError(w, r, fmt.Errorf("failed to queue dependency scan: %w", err))
+		return
+	}
+
+	models.LogActivity("dependency_scan", fmt.Sprintf("Queued dependency scan of %s", repo.Name),
+		"Manifests will be checked for updates and known vulnerabilities",
+		user.ID, repo.ID, "repository", "")
+
+	w.Write([]byte(`<div class="alert alert-success text-sm">Scan queued. Reload the page in a minute to see the results.</div>`))
+}
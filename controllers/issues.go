@@ -5,9 +5,12 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"strings"
+	"time"
 
 	"workspace/internal/ai"
+	"workspace/internal/github"
 	"workspace/models"
 	"workspace/services"
 
@@ -37,11 +40,12 @@ func (c *IssuesController) Setup(app *application.App) {
 	auth := app.Use("auth").(*AuthController)
 
 	// Issues - view on public repos or as admin
-	http.Handle("GET /repos/{id}/issues", app.Serve("repo-issues.html", PublicOrAdmin()))
-	http.Handle("GET /repos/{id}/issues/kanban", app.Serve("repo-issues-kanban.html", PublicOrAdmin()))
-	http.Handle("GET /repos/{id}/issues/search", app.ProtectFunc(c.searchIssues, PublicOrAdmin()))
-	http.Handle("GET /repos/{id}/issues/more", app.Serve("issues-more.html", PublicOrAdmin()))
-	http.Handle("GET /repos/{id}/issues/{issueID}", app.Serve("repo-issue-view.html", PublicOrAdmin()))
+	http.Handle("GET /repos/{id}/issues", app.Serve("repo-issues.html", PublicAdminOrGuest()))
+	http.Handle("GET /repos/{id}/issues/kanban", app.Serve("repo-issues-kanban.html", PublicAdminOrGuest()))
+	http.Handle("GET /repos/{id}/issues/search", app.ProtectFunc(c.searchIssues, PublicAdminOrGuest()))
+	http.Handle("GET /repos/{id}/issues/more", app.Serve("issues-more.html", PublicAdminOrGuest()))
+	http.Handle("GET /repos/{id}/issues/form", app.ProtectFunc(c.issueFormFields, PublicRepoOnly()))
+	http.Handle("GET /repos/{id}/issues/{issueID}", app.Serve("repo-issue-view.html", PublicAdminOrGuest()))
 
 	// Issue operations - authenticated users on public repos, admins on any
 	http.Handle("POST /repos/{id}/issues/create", app.ProtectFunc(c.createIssue, PublicRepoOnly()))
@@ -52,9 +56,30 @@ func (c *IssuesController) Setup(app *application.App) {
 	http.Handle("POST /repos/{id}/issues/{issueID}/reopen", app.ProtectFunc(c.reopenIssue, auth.Required))
 	http.Handle("POST /repos/{id}/issues/{issueID}/edit", app.ProtectFunc(c.editIssue, auth.Required))
 	http.Handle("POST /repos/{id}/issues/{issueID}/move", app.ProtectFunc(c.moveIssue, auth.Required))
+	http.Handle("POST /repos/{id}/issues/{issueID}/labels", app.ProtectFunc(c.setIssueLabels, auth.Required))
+	http.Handle("POST /repos/{id}/issues/{issueID}/assignees", app.ProtectFunc(c.setIssueAssignees, auth.Required))
+	http.Handle("POST /repos/{id}/issues/{issueID}/milestone", app.ProtectFunc(c.setIssueMilestone, auth.Required))
+
+	// Read state - per user
+	http.Handle("POST /repos/{id}/issues/{issueID}/read", app.ProtectFunc(c.markIssueRead, auth.Required))
+	http.Handle("POST /repos/{id}/issues/{issueID}/unread", app.ProtectFunc(c.markIssueUnread, auth.Required))
 
 	// Issue deletion - admin only
 	http.Handle("POST /repos/{id}/issues/{issueID}/delete", app.ProtectFunc(c.deleteIssue, AdminOnly()))
+
+	// Labels - listed with issues, managed by admins
+	http.Handle("GET /repos/{id}/labels", app.Serve("repo-labels.html", PublicAdminOrGuest()))
+	http.Handle("POST /repos/{id}/labels/create", app.ProtectFunc(c.createLabel, AdminOnly()))
+	http.Handle("POST /repos/{id}/labels/{labelID}/edit", app.ProtectFunc(c.editLabel, AdminOnly()))
+	http.Handle("POST /repos/{id}/labels/{labelID}/delete", app.ProtectFunc(c.deleteLabel, AdminOnly()))
+
+	// Milestones - listed with issues, managed by admins
+	http.Handle("GET /repos/{id}/milestones", app.Serve("repo-milestones.html", PublicAdminOrGuest()))
+	http.Handle("POST /repos/{id}/milestones/create", app.ProtectFunc(c.createMilestone, AdminOnly()))
+	http.Handle("POST /repos/{id}/milestones/{milestoneID}/edit", app.ProtectFunc(c.editMilestone, AdminOnly()))
+	http.Handle("POST /repos/{id}/milestones/{milestoneID}/close", app.ProtectFunc(c.closeMilestone, AdminOnly()))
+	http.Handle("POST /repos/{id}/milestones/{milestoneID}/reopen", app.ProtectFunc(c.reopenMilestone, AdminOnly()))
+	http.Handle("POST /repos/{id}/milestones/{milestoneID}/delete", app.ProtectFunc(c.deleteMilestone, AdminOnly()))
 }
 
 // CurrentRepo returns the current repository from the request
@@ -63,6 +88,21 @@ func (c *IssuesController) CurrentRepo() (*models.Repository, error) {
 	return reposController.CurrentRepo()
 }
 
+// IssueForms returns the YAML issue forms defined in the current repository
+func (c *IssuesController) IssueForms() []*models.IssueForm {
+	repo, err := c.CurrentRepo()
+	if err != nil {
+		return nil
+	}
+
+	forms, err := repo.GetIssueForms()
+	if err != nil {
+		log.Printf("Failed to load issue forms for %s: %v", repo.ID, err)
+		return nil
+	}
+	return forms
+}
+
 // RepoIssues returns issues for the current repository
 func (c *IssuesController) RepoIssues() ([]*models.Issue, error) {
 	reposController := c.Use("repos").(*ReposController)
@@ -71,29 +111,11 @@ func (c *IssuesController) RepoIssues() ([]*models.Issue, error) {
 		return nil, err
 	}
 
-	// Get search query from request
-	searchQuery := c.Request.URL.Query().Get("search")
-	includeClosed := c.Request.URL.Query().Get("includeClosed") == "true"
+	// Filter by the request, or the saved view it names
+	condition, args := models.ListCondition(models.ViewIssues, repo.ID, c.viewerID(), c.filters())
 
-	// Build search condition
-	condition := "WHERE RepoID = ?"
-	args := []any{repo.ID}
-
-	// Add status filter
-	if !includeClosed {
-		condition += " AND Status = ?"
-		args = append(args, "open")
-	}
-
-	// Add search filter if provided
-	if searchQuery != "" {
-		condition += " AND (Title LIKE ? OR Body LIKE ?)"
-		searchPattern := "%" + searchQuery + "%"
-		args = append(args, searchPattern, searchPattern)
-	}
-
-	// Add ordering and limit for initial load
-	condition += " ORDER BY CreatedAt DESC LIMIT 20"
+	// Add limit for initial load
+	condition += " LIMIT 20"
 
 	// Search issues
 	issues, err := models.Issues.Search(condition, args...)
@@ -119,11 +141,8 @@ func (c *IssuesController) MoreIssues() ([]*models.Issue, error) {
 		fmt.Sscanf(offsetStr, "%d", &offset)
 	}
 
-	// Get filter options
-	includeClosed := c.Request.URL.Query().Get("includeClosed") == "true"
-
 	// Get next batch of issues
-	issues, _, err := models.GetRepoIssuesPaginated(repo.ID, includeClosed, 20, offset)
+	issues, _, err := c.issuesPage(repo.ID, offset)
 	return issues, err
 }
 
@@ -141,8 +160,7 @@ func (c *IssuesController) HasMoreIssues() bool {
 		fmt.Sscanf(offsetStr, "%d", &offset)
 	}
 
-	includeClosed := c.Request.URL.Query().Get("includeClosed") == "true"
-	issues, total, err := models.GetRepoIssuesPaginated(repo.ID, includeClosed, 20, offset)
+	issues, total, err := c.issuesPage(repo.ID, offset)
 	if err != nil {
 		return false
 	}
@@ -150,6 +168,26 @@ func (c *IssuesController) HasMoreIssues() bool {
 	return (offset + len(issues)) < total
 }
 
+// issuesPage returns a page of 20 issues matching the list's filters
+func (c *IssuesController) issuesPage(repoID string, offset int) ([]*models.Issue, int, error) {
+	condition, args := models.ListCondition(models.ViewIssues, repoID, c.viewerID(), c.filters())
+	return models.Issues.SearchPaginated(condition, 20, offset, args...)
+}
+
+// filters returns the list filters of the request, or of the saved view
+// it names
+func (c *IssuesController) filters() url.Values {
+	return models.ApplyView(c.Request.URL.Query(), c.viewerID())
+}
+
+// viewerID returns the signed-in user's ID, empty for guests
+func (c *IssuesController) viewerID() string {
+	if user := c.CurrentUser(); user != nil {
+		return user.ID
+	}
+	return ""
+}
+
 // NextIssuesOffset returns the offset for the next page of issues
 func (c *IssuesController) NextIssuesOffset() int {
 	offsetStr := c.Request.URL.Query().Get("offset")
@@ -162,12 +200,58 @@ func (c *IssuesController) NextIssuesOffset() int {
 
 // SearchQuery returns the current search query for issues
 func (c *IssuesController) SearchQuery() string {
-	return c.Request.URL.Query().Get("search")
+	return c.filters().Get("search")
 }
 
 // IncludeClosed returns whether to include closed issues
 func (c *IssuesController) IncludeClosed() bool {
-	return c.Request.URL.Query().Get("includeClosed") == "true"
+	return c.filters().Get("includeClosed") == "true"
+}
+
+// UnreadOnly returns whether only issues the user hasn't read are listed
+func (c *IssuesController) UnreadOnly() bool {
+	return c.filters().Get("unread") == "true"
+}
+
+// LabelFilter returns the label issues are filtered by
+func (c *IssuesController) LabelFilter() string {
+	return c.filters().Get("label")
+}
+
+// AssigneeFilter returns who issues are filtered by assignment to: a user
+// ID, "me" or "none"
+func (c *IssuesController) AssigneeFilter() string {
+	return c.filters().Get("assignee")
+}
+
+// SortOrder returns how issues are ordered
+func (c *IssuesController) SortOrder() string {
+	return c.filters().Get("sort")
+}
+
+// FilterQuery returns the list's filters as a query string, for loading
+// more issues with them
+func (c *IssuesController) FilterQuery() string {
+	return models.ViewQuery(models.ViewIssues, c.filters())
+}
+
+// Unread returns which of the issues the current user hasn't read
+func (c *IssuesController) Unread(issues []*models.Issue) map[string]bool {
+	user := c.CurrentUser()
+	if user == nil {
+		return map[string]bool{}
+	}
+	return models.UnreadIssues(user.ID, issues)
+}
+
+// LastRead returns when the current user last read the current issue, zero
+// if they never have. Comments after it are new to them.
+func (c *IssuesController) LastRead() time.Time {
+	user := c.CurrentUser()
+	if user == nil {
+		return time.Time{}
+	}
+	return models.LastRead(user.ID, models.ReadIssue, c.Request.PathValue("issueID"))
 }
 
 // CurrentIssue returns the issue from the request
@@ -204,9 +288,9 @@ func (c *IssuesController) CanCreateIssue() bool {
 		return true
 	}
 
-	// Non-admins can create issues on public repos if authenticated
+	// Non-admins can create issues on repos they can read if authenticated
 	user := c.CurrentUser()
-	return user != nil && repo.Visibility == "public"
+	return user != nil && models.RepoRole(user, repo) != ""
 }
 
 // CanEditIssue returns true if the current user can edit the given issue
@@ -215,14 +299,30 @@ func (c *IssuesController) CanEditIssue(issue *models.Issue) bool {
 		return false
 	}
 
-	// Admins can edit any issue
-	if c.IsAdmin() {
-		return true
+	// Authors can edit their own issues, and those with write access any
+	user := c.CurrentUser()
+	if user == nil {
+		return false
 	}
+	return issue.AuthorID == user.ID || models.CheckRepoAccess(user, issue.RepoID, models.RoleWrite) == nil
+}
 
-	// Authors can edit their own issues
-	user := c.CurrentUser()
-	return user != nil && issue.AuthorID == user.ID
+// AssignableUsers returns the users issues can be assigned to
+func (c *IssuesController) AssignableUsers() ([]*authentication.User, error) {
+	return models.Users.Search("ORDER BY Name ASC")
+}
+
+// Mentions returns where the current issue was mentioned, newest first
+func (c *IssuesController) Mentions() []*models.CrossReference {
+	issue, err := c.CurrentIssue()
+	if err != nil {
+		return nil
+	}
+	refs, err := models.GetReferencesTo(models.RefIssue, issue.ID)
+	if err != nil {
+		return nil
+	}
+	return refs
 }
 
 // IssueComments returns comments for the current issue
@@ -266,6 +366,36 @@ func (c *IssuesController) createIssue(w http.ResponseWriter, r *http.Request) {
 	body := strings.TrimSpace(r.FormValue("body"))
 	column := strings.TrimSpace(r.FormValue("column"))
 
+	// Issue forms replace the free-form body with structured answers
+	var form *models.IssueForm
+	var formData string
+	if formName := r.FormValue("form"); formName != "" {
+		repo, err := c.CurrentRepo()
+		if err != nil {
+			c.RenderError(w, r, errors.New("repository not found"))
+			return
+		}
+		if form, err = repo.GetIssueForm(formName); err != nil {
+			c.RenderError(w, r, err)
+			return
+		}
+
+		r.ParseForm()
+		answers, err := form.Collect(func(name string) []string { return r.Form[name] })
+		if err != nil {
+			c.RenderError(w, r, err)
+			return
+		}
+		if formData, err = models.EncodeIssueFormData(answers); err != nil {
+			c.RenderError(w, r, fmt.Errorf("failed to save form answers: %w", err))
+			return
+		}
+		body = models.RenderIssueFormBody(form, answers)
+		if title == "" {
+			title = form.Title
+		}
+	}
+
 	if title == "" {
 		c.RenderError(w, r, errors.New("issue title is required"))
 		return
@@ -280,6 +410,15 @@ func (c *IssuesController) createIssue(w http.ResponseWriter, r *http.Request) {
 		RepoID:     repoID,
 		AuthorID:   user.ID, // Set the author
 		AssigneeID: user.ID, // Initially assign to creator
+		FormData:   formData,
+	}
+	if form != nil {
+		issue.FormName = form.Name
+	}
+	if milestoneID := r.FormValue("milestone_id"); milestoneID != "" {
+		if milestone, err := models.Milestones.Get(milestoneID); err == nil && milestone.RepoID == repoID {
+			issue.MilestoneID = milestone.ID
+		}
 	}
 
 	// If column is "done", set status to closed
@@ -293,9 +432,33 @@ func (c *IssuesController) createIssue(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Apply labels from the form definition and the labels input
+	labels := strings.Split(r.FormValue("tags"), ",")
+	if form != nil {
+		labels = append(labels, form.Labels...)
+	}
+	for _, name := range labels {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		tag, err := models.GetOrCreateTag(name, repoID)
+		if err != nil {
+			log.Printf("Failed to create label %s: %v", name, err)
+			continue
+		}
+		models.AddLabelToIssue(issue.ID, tag.ID, user.ID)
+	}
+	models.AssignIssue(issue, user.ID, user.ID)
+
+	models.MarkRead(user.ID, models.ReadIssue, issue.ID, repoID)
+	models.RecordReferences(repoID, models.RefIssue, issue.ID, issue.Title+"\n"+issue.Body, user.ID)
+	models.RecordMentions(repoID, models.RefIssue, issue.ID, issue.Title+"\n"+issue.Body, user.ID)
+
 	// Log activity
 	models.LogActivity("issue_created", "Created issue: "+issue.Title,
 		"New issue opened", user.ID, repoID, "issue", issue.ID)
+	services.EmitIssueWebhook("opened", issue, user.ID)
+	models.RunProjectRules("issue_opened", repoID, issue.ID, "")
 
 	// Trigger actions for issue creation event
 	eventData := map[string]string{
@@ -319,6 +482,31 @@ func (c *IssuesController) createIssue(w http.ResponseWriter, r *http.Request) {
 	c.Refresh(w, r)
 }
 
+// issueFormFields renders the controls for the selected issue form
+func (c *IssuesController) issueFormFields(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+
+	name := r.URL.Query().Get("form")
+	if name == "" {
+		c.Render(w, r, "issue-form-fields.html", nil)
+		return
+	}
+
+	repo, err := c.CurrentRepo()
+	if err != nil {
+		c.RenderError(w, r, errors.New("repository not found"))
+		return
+	}
+
+	form, err := repo.GetIssueForm(name)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	c.Render(w, r, "issue-form-fields.html", form)
+}
+
 // closeIssue handles closing an issue
 func (c *IssuesController) closeIssue(w http.ResponseWriter, r *http.Request) {
 	c.SetRequest(r)
@@ -335,15 +523,9 @@ func (c *IssuesController) closeIssue(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get and update issue
-	issue, err := models.Issues.Get(issueID)
+	issue, err := c.issueToEdit(r, user)
 	if err != nil {
-		c.RenderError(w, r, errors.New("issue not found"))
-		return
-	}
-
-	// Check if user is admin or issue author
-	if !user.IsAdmin && issue.AuthorID != user.ID {
-		c.RenderError(w, r, errors.New("only the author or admin can close this issue"))
+		c.RenderError(w, r, err)
 		return
 	}
 
@@ -356,7 +538,9 @@ func (c *IssuesController) closeIssue(w http.ResponseWriter, r *http.Request) {
 
 	// Log activity
 	models.LogActivity("issue_closed", "Closed issue: "+issue.Title,
-		"Issue marked as closed", user.ID, repoID, "issue", issue.ID)
+		"Issue marked as closed", user.ID, issue.RepoID, "issue", issue.ID)
+	services.EmitIssueWebhook("closed", issue, user.ID)
+	models.RunProjectRules("issue_closed", issue.RepoID, issue.ID, "")
 
 	c.Refresh(w, r)
 }
@@ -377,15 +561,9 @@ func (c *IssuesController) reopenIssue(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get and update issue
-	issue, err := models.Issues.Get(issueID)
+	issue, err := c.issueToEdit(r, user)
 	if err != nil {
-		c.RenderError(w, r, errors.New("issue not found"))
-		return
-	}
-
-	// Check if user is admin or issue author
-	if !user.IsAdmin && issue.AuthorID != user.ID {
-		c.RenderError(w, r, errors.New("only the author or admin can reopen this issue"))
+		c.RenderError(w, r, err)
 		return
 	}
 
@@ -398,7 +576,9 @@ func (c *IssuesController) reopenIssue(w http.ResponseWriter, r *http.Request) {
 
 	// Log activity
 	models.LogActivity("issue_reopened", "Reopened issue: "+issue.Title,
-		"Issue marked as open", user.ID, repoID, "issue", issue.ID)
+		"Issue marked as open", user.ID, issue.RepoID, "issue", issue.ID)
+	services.EmitIssueWebhook("reopened", issue, user.ID)
+	models.RunProjectRules("issue_reopened", issue.RepoID, issue.ID, "")
 
 	c.Refresh(w, r)
 }
@@ -419,28 +599,20 @@ func (c *IssuesController) editIssue(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get the issue
-	issue, err := models.Issues.Get(issueID)
+	issue, err := c.issueToEdit(r, user)
 	if err != nil {
-		c.RenderError(w, r, errors.New("issue not found"))
-		return
-	}
-
-	// Check if user is admin or issue author
-	if !user.IsAdmin && issue.AuthorID != user.ID {
-		c.RenderError(w, r, errors.New("only the author or admin can edit this issue"))
+		c.RenderError(w, r, err)
 		return
 	}
 
 	// Update fields
 	title := strings.TrimSpace(r.FormValue("title"))
 	body := strings.TrimSpace(r.FormValue("body"))
-	assigneeID := strings.TrimSpace(r.FormValue("assignee_id"))
 
 	if title != "" {
 		issue.Title = title
 	}
 	issue.Body = body
-	issue.AssigneeID = assigneeID
 
 	// Save changes
 	err = models.Issues.Update(issue)
@@ -448,14 +620,123 @@ func (c *IssuesController) editIssue(w http.ResponseWriter, r *http.Request) {
 		c.RenderError(w, r, errors.New("failed to update issue"))
 		return
 	}
+	models.RecordReferences(repoID, models.RefIssue, issue.ID, issue.Title+"\n"+issue.Body, user.ID)
+	models.RecordMentions(repoID, models.RefIssue, issue.ID, issue.Title+"\n"+issue.Body, user.ID)
+
+	// Labels are edited as a comma-separated list
+	if _, ok := r.Form["tags"]; ok {
+		if err := models.SetIssueLabels(issue, strings.Split(r.FormValue("tags"), ","), user.ID); err != nil {
+			c.RenderError(w, r, fmt.Errorf("failed to update labels: %w", err))
+			return
+		}
+	}
 
 	// Log activity
 	models.LogActivity("issue_updated", "Updated issue: "+issue.Title,
 		"Issue details modified", user.ID, repoID, "issue", issue.ID)
+	services.EmitIssueWebhook("edited", issue, user.ID)
+
+	c.Refresh(w, r)
+}
+
+// setIssueLabels replaces the labels of an issue with the checked ones
+func (c *IssuesController) setIssueLabels(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	// Access already checked by route middleware (auth.Required)
+	user := c.CurrentUser()
+
+	issue, err := c.issueToEdit(r, user)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	r.ParseForm()
+	if err := models.SetIssueLabels(issue, r.Form["label"], user.ID); err != nil {
+		c.RenderError(w, r, fmt.Errorf("failed to update labels: %w", err))
+		return
+	}
+
+	models.LogActivity("issue_updated", "Updated issue: "+issue.Title,
+		"Labels changed", user.ID, issue.RepoID, "issue", issue.ID)
+	services.EmitIssueWebhook("labeled", issue, user.ID)
+
+	c.Refresh(w, r)
+}
+
+// setIssueAssignees replaces the users an issue is assigned to with the
+// checked ones
+func (c *IssuesController) setIssueAssignees(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	// Access already checked by route middleware (auth.Required)
+	user := c.CurrentUser()
+
+	issue, err := c.issueToEdit(r, user)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	r.ParseForm()
+	assignees := make([]string, 0, len(r.Form["assignee"]))
+	for _, id := range r.Form["assignee"] {
+		if _, err := models.Users.Get(id); err != nil {
+			c.RenderError(w, r, errors.New("user not found"))
+			return
+		}
+		assignees = append(assignees, id)
+	}
+
+	if err := models.SetIssueAssignees(issue, assignees, user.ID); err != nil {
+		c.RenderError(w, r, fmt.Errorf("failed to update assignees: %w", err))
+		return
+	}
+
+	models.LogActivity("issue_updated", "Updated issue: "+issue.Title,
+		"Assignees changed", user.ID, issue.RepoID, "issue", issue.ID)
+	services.EmitIssueWebhook("assigned", issue, user.ID)
 
 	c.Refresh(w, r)
 }
 
+// setIssueMilestone plans an issue for a milestone, or for none
+func (c *IssuesController) setIssueMilestone(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	// Access already checked by route middleware (auth.Required)
+	user := c.CurrentUser()
+
+	issue, err := c.issueToEdit(r, user)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	if err := models.SetIssueMilestone(issue, r.FormValue("milestone_id")); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	models.LogActivity("issue_updated", "Updated issue: "+issue.Title,
+		"Milestone changed", user.ID, issue.RepoID, "issue", issue.ID)
+	services.EmitIssueWebhook("milestoned", issue, user.ID)
+
+	c.Refresh(w, r)
+}
+
+// issueToEdit returns the issue in the request if the user may change it:
+// those with write access to the repository can change any of its
+// issues, authors their own
+func (c *IssuesController) issueToEdit(r *http.Request, user *authentication.User) (*models.Issue, error) {
+	issue, err := models.Issues.Get(r.PathValue("issueID"))
+	if err != nil || issue.RepoID != r.PathValue("id") {
+		return nil, errors.New("issue not found")
+	}
+	if models.CheckRepoAccess(user, issue.RepoID, models.RoleWrite) != nil && issue.AuthorID != user.ID {
+		return nil, errors.New("only the author or those with write access can change this issue")
+	}
+	return issue, nil
+}
+
 // deleteIssue handles deleting an issue
 func (c *IssuesController) deleteIssue(w http.ResponseWriter, r *http.Request) {
 	c.SetRequest(r)
@@ -473,7 +754,7 @@ func (c *IssuesController) deleteIssue(w http.ResponseWriter, r *http.Request) {
 
 	// Get the issue for logging
 	issue, err := models.Issues.Get(issueID)
-	if err != nil {
+	if err != nil || issue.RepoID != repoID {
 		c.RenderError(w, r, errors.New("issue not found"))
 		return
 	}
@@ -486,10 +767,15 @@ func (c *IssuesController) deleteIssue(w http.ResponseWriter, r *http.Request) {
 		c.RenderError(w, r, errors.New("failed to delete issue"))
 		return
 	}
+	if err := models.DeleteAttachments("issue", issue.ID); err != nil {
+		log.Printf("Failed to remove attachments of issue %s: %v", issue.ID, err)
+	}
+	services.Search.UnindexIssue(issue)
 
 	// Log activity
 	models.LogActivity("issue_deleted", "Deleted issue: "+issue.Title,
 		"Issue permanently removed", user.ID, repoID, "issue", issue.ID)
+	services.EmitIssueWebhook("deleted", issue, user.ID)
 
 	c.Refresh(w, r)
 }
@@ -501,6 +787,12 @@ func (c *IssuesController) createIssueComment(w http.ResponseWriter, r *http.Req
 	auth := c.Use("auth").(*AuthController)
 	user := auth.CurrentUser()
 
+	files, err := attachmentFiles(w, r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
 	repoID := r.PathValue("id")
 	issueID := r.PathValue("issueID")
 	body := strings.TrimSpace(r.FormValue("body"))
@@ -512,27 +804,78 @@ func (c *IssuesController) createIssueComment(w http.ResponseWriter, r *http.Req
 
 	// Access already verified by route middleware
 
-	// Verify issue exists
+	// Verify issue exists in this repository
 	issue, err := models.Issues.Get(issueID)
-	if err != nil {
+	if err != nil || issue.RepoID != repoID {
 		c.RenderError(w, r, errors.New("issue not found"))
 		return
 	}
 
 	// Create comment
-	_, err = models.CreateIssueComment(issueID, repoID, user.ID, body)
+	comment, err := models.CreateIssueComment(issueID, repoID, user.ID, body)
 	if err != nil {
 		c.RenderError(w, r, errors.New("failed to create comment"))
 		return
 	}
 
+	models.MarkRead(user.ID, models.ReadIssue, issueID, repoID)
+	models.RecordReferences(repoID, models.RefComment, comment.ID, comment.Body, user.ID)
+	models.RecordMentions(repoID, models.RefComment, comment.ID, comment.Body, user.ID)
+
 	// Log activity
 	models.LogActivity("comment_created", "Commented on issue: "+issue.Title,
 		"New comment added", user.ID, repoID, "issue_comment", issueID)
+	services.EmitCommentWebhook(comment, issue.Title)
+
+	// Mirror the comment to GitHub when the repository syncs comments both ways
+	go func() {
+		syncService := &github.GitHubSyncService{}
+		if err := syncService.PushComment(comment.ID, user.ID); err != nil {
+			log.Printf("Failed to push comment to GitHub: %v", err)
+		}
+	}()
+
+	if rejected := saveAttachments(files, repoID, "comment", comment.ID, user.ID); len(rejected) > 0 {
+		c.RenderError(w, r, fmt.Errorf("comment posted, but some files were not attached: %s", strings.Join(rejected, "; ")))
+		return
+	}
 
 	c.Refresh(w, r)
 }
 
+// markIssueRead handles POST /repos/{id}/issues/{issueID}/read, sent when
+// the issue page has loaded
+func (c *IssuesController) markIssueRead(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	user := c.CurrentUser()
+	if user == nil {
+		c.RenderError(w, r, errors.New("authentication required"))
+		return
+	}
+
+	if err := models.MarkRead(user.ID, models.ReadIssue, r.PathValue("issueID"), r.PathValue("id")); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// markIssueUnread handles POST /repos/{id}/issues/{issueID}/unread
+func (c *IssuesController) markIssueUnread(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	user := c.CurrentUser()
+	if user == nil {
+		c.RenderError(w, r, errors.New("authentication required"))
+		return
+	}
+
+	if err := models.MarkUnread(user.ID, models.ReadIssue, r.PathValue("issueID")); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+	c.Redirect(w, r, "/repos/"+r.PathValue("id")+"/issues")
+}
+
 // moveIssue handles moving an issue between Kanban columns
 func (c *IssuesController) moveIssue(w http.ResponseWriter, r *http.Request) {
 	c.SetRequest(r)
@@ -564,20 +907,15 @@ func (c *IssuesController) moveIssue(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get issue
-	issue, err := models.Issues.Get(issueID)
+	issue, err := c.issueToEdit(r, user)
 	if err != nil {
-		c.RenderError(w, r, errors.New("issue not found"))
-		return
-	}
-
-	// Check if user can modify (author or admin)
-	if issue.AuthorID != user.ID && !user.IsAdmin {
-		c.RenderError(w, r, errors.New("unauthorized"))
+		c.RenderError(w, r, err)
 		return
 	}
 
 	// Update column and status based on kanban movement
 	oldColumn := issue.Column
+	oldStatus := issue.Status
 
 	// Update based on target column
 	switch newStatus {
@@ -606,7 +944,14 @@ func (c *IssuesController) moveIssue(w http.ResponseWriter, r *http.Request) {
 		oldColumnDisplay = "todo"
 	}
 	models.LogActivity("issue_moved", fmt.Sprintf("Moved issue from %s to %s", oldColumnDisplay, newStatus),
-		fmt.Sprintf("Issue %s moved", issue.Title), user.ID, repoID, "issue", issue.ID)
+		fmt.Sprintf("Issue %s moved", issue.Title), user.ID, issue.RepoID, "issue", issue.ID)
+	if issue.Status != oldStatus {
+		event := "issue_reopened"
+		if issue.Status == "closed" {
+			event = "issue_closed"
+		}
+		models.RunProjectRules(event, issue.RepoID, issue.ID, "")
+	}
 
 	// Test with w.WriteHeader(200) as requested
 	w.WriteHeader(200)
@@ -0,0 +1,101 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"workspace/models"
+)
+
+// RepoLabels returns the labels issues in the current repository can have
+func (c *IssuesController) RepoLabels() ([]*models.TagDefinition, error) {
+	repo, err := c.CurrentRepo()
+	if err != nil {
+		return nil, err
+	}
+	return models.GetRepoLabels(repo.ID)
+}
+
+// createLabel handles POST /repos/{id}/labels/create
+func (c *IssuesController) createLabel(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	// Admin already verified by route middleware
+	user := c.CurrentUser()
+
+	repo, err := c.CurrentRepo()
+	if err != nil {
+		c.RenderError(w, r, errors.New("repository not found"))
+		return
+	}
+
+	label, err := models.CreateLabel(repo.ID, r.FormValue("name"), r.FormValue("color"), r.FormValue("description"))
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	models.LogActivity("label_created", "Created label: "+label.Name,
+		"New issue label", user.ID, repo.ID, "label", label.ID)
+
+	c.Refresh(w, r)
+}
+
+// editLabel handles POST /repos/{id}/labels/{labelID}/edit
+func (c *IssuesController) editLabel(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	// Admin already verified by route middleware
+	user := c.CurrentUser()
+
+	label, err := c.repoLabel(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	if err := label.Edit(r.FormValue("name"), r.FormValue("color"), r.FormValue("description")); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	models.LogActivity("label_updated", "Updated label: "+label.Name,
+		"Issue label changed", user.ID, label.RepoID, "label", label.ID)
+
+	c.Refresh(w, r)
+}
+
+// deleteLabel handles POST /repos/{id}/labels/{labelID}/delete
+func (c *IssuesController) deleteLabel(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	// Admin already verified by route middleware
+	user := c.CurrentUser()
+
+	label, err := c.repoLabel(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	if err := models.DeleteLabel(label); err != nil {
+		c.RenderError(w, r, fmt.Errorf("failed to delete label: %w", err))
+		return
+	}
+
+	models.LogActivity("label_deleted", "Deleted label: "+label.Name,
+		"Issue label removed from every issue", user.ID, label.RepoID, "label", label.ID)
+
+	c.Refresh(w, r)
+}
+
+// repoLabel returns the label in the request. Global labels are shared by
+// every repository, so they can't be changed from one.
+func (c *IssuesController) repoLabel(r *http.Request) (*models.TagDefinition, error) {
+	label, err := models.TagDefinitions.Get(r.PathValue("labelID"))
+	if err != nil {
+		return nil, errors.New("label not found")
+	}
+	if label.RepoID != r.PathValue("id") {
+		return nil, errors.New("only the repository's own labels can be changed")
+	}
+	return label, nil
+}
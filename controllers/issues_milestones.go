@@ -0,0 +1,167 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"workspace/models"
+)
+
+// RepoMilestones returns the open milestones of the current repository
+func (c *IssuesController) RepoMilestones() ([]*models.Milestone, error) {
+	repo, err := c.CurrentRepo()
+	if err != nil {
+		return nil, err
+	}
+	return models.GetRepoMilestones(repo.ID, false)
+}
+
+// Milestones returns every milestone of the current repository, closed ones
+// last
+func (c *IssuesController) Milestones() ([]*models.Milestone, error) {
+	repo, err := c.CurrentRepo()
+	if err != nil {
+		return nil, err
+	}
+	return models.GetRepoMilestones(repo.ID, true)
+}
+
+// createMilestone handles POST /repos/{id}/milestones/create
+func (c *IssuesController) createMilestone(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	// Admin already verified by route middleware
+	user := c.CurrentUser()
+
+	repo, err := c.CurrentRepo()
+	if err != nil {
+		c.RenderError(w, r, errors.New("repository not found"))
+		return
+	}
+
+	due, err := parseMilestoneDue(r.FormValue("due_date"))
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	milestone, err := models.CreateMilestone(repo.ID, r.FormValue("title"), r.FormValue("description"), due, user.ID)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	models.LogActivity("milestone_created", "Created milestone: "+milestone.Title,
+		"New milestone", user.ID, repo.ID, "milestone", milestone.ID)
+
+	c.Refresh(w, r)
+}
+
+// editMilestone handles POST /repos/{id}/milestones/{milestoneID}/edit
+func (c *IssuesController) editMilestone(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	// Admin already verified by route middleware
+	user := c.CurrentUser()
+
+	milestone, err := c.repoMilestone(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	due, err := parseMilestoneDue(r.FormValue("due_date"))
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	if err := milestone.Edit(r.FormValue("title"), r.FormValue("description"), due); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	models.LogActivity("milestone_updated", "Updated milestone: "+milestone.Title,
+		"Milestone details changed", user.ID, milestone.RepoID, "milestone", milestone.ID)
+
+	c.Refresh(w, r)
+}
+
+// closeMilestone handles POST /repos/{id}/milestones/{milestoneID}/close
+func (c *IssuesController) closeMilestone(w http.ResponseWriter, r *http.Request) {
+	c.setMilestoneStatus(w, r, models.MilestoneClosed)
+}
+
+// reopenMilestone handles POST /repos/{id}/milestones/{milestoneID}/reopen
+func (c *IssuesController) reopenMilestone(w http.ResponseWriter, r *http.Request) {
+	c.setMilestoneStatus(w, r, models.MilestoneOpen)
+}
+
+// setMilestoneStatus closes or reopens the milestone in the request
+func (c *IssuesController) setMilestoneStatus(w http.ResponseWriter, r *http.Request, status string) {
+	c.SetRequest(r)
+	// Admin already verified by route middleware
+	user := c.CurrentUser()
+
+	milestone, err := c.repoMilestone(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	if err := milestone.SetStatus(status); err != nil {
+		c.RenderError(w, r, fmt.Errorf("failed to update milestone: %w", err))
+		return
+	}
+
+	models.LogActivity("milestone_updated", "Updated milestone: "+milestone.Title,
+		"Milestone marked as "+status, user.ID, milestone.RepoID, "milestone", milestone.ID)
+
+	c.Refresh(w, r)
+}
+
+// deleteMilestone handles POST /repos/{id}/milestones/{milestoneID}/delete
+func (c *IssuesController) deleteMilestone(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	// Admin already verified by route middleware
+	user := c.CurrentUser()
+
+	milestone, err := c.repoMilestone(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	if err := models.DeleteMilestone(milestone); err != nil {
+		c.RenderError(w, r, fmt.Errorf("failed to delete milestone: %w", err))
+		return
+	}
+
+	models.LogActivity("milestone_deleted", "Deleted milestone: "+milestone.Title,
+		"Its issues no longer have a milestone", user.ID, milestone.RepoID, "milestone", milestone.ID)
+
+	c.Refresh(w, r)
+}
+
+// repoMilestone returns the milestone in the request
+func (c *IssuesController) repoMilestone(r *http.Request) (*models.Milestone, error) {
+	milestone, err := models.Milestones.Get(r.PathValue("milestoneID"))
+	if err != nil || milestone.RepoID != r.PathValue("id") {
+		return nil, errors.New("milestone not found")
+	}
+	return milestone, nil
+}
+
+// parseMilestoneDue parses a due date from a date input, zero when empty
+func parseMilestoneDue(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	due, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, errors.New("due date must be YYYY-MM-DD")
+	}
+	return due, nil
+}
@@ -1,13 +1,32 @@
 package controllers
 
 import (
+	"cmp"
+	"compress/gzip"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"log/slog"
+	"mime"
 	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"workspace/internal/sse"
 	"workspace/middleware"
+	"workspace/models"
 
 	"github.com/The-Skyscape/devtools/pkg/application"
 )
 
+// tailBacklog is how many of the latest entries a live tail starts with
+const tailBacklog = 200
+
 // Logs is a factory function with the prefix and instance
 func Logs() (string, *LogsController) {
 	return "logs", &LogsController{}
@@ -24,10 +43,13 @@ func (c *LogsController) Setup(app *application.App) {
 
 	// Logs viewing page (admin only)
 	http.Handle("GET /logs", app.Serve("logs.html", AdminOnly()))
+	http.Handle("POST /logs/settings", app.ProtectFunc(c.updateSettings, AdminOnly()))
+	http.Handle("GET /logs/tail", app.ProtectFunc(c.streamTail, AdminOnly()))
+	http.Handle("GET /logs/download", app.ProtectFunc(c.download, AdminOnly()))
 
-	// API endpoints for log data (admin only)
-	http.Handle("GET /api/logs/recent", app.ProtectFunc(c.getRecentLogs, AdminOnly()))
-	http.Handle("GET /api/logs/stats", app.ProtectFunc(c.getLogStats, AdminOnly()))
+	// API endpoints for log data (admin only, or tokens with the admin scope)
+	http.Handle("GET /api/logs/recent", app.ProtectFunc(c.getRecentLogs, APIAccess(models.ScopeAdmin)))
+	http.Handle("GET /api/logs/stats", app.ProtectFunc(c.getLogStats, APIAccess(models.ScopeAdmin)))
 }
 
 // Handle prepares controller for request
@@ -36,11 +58,131 @@ func (c LogsController) Handle(req *http.Request) application.Handler {
 	return &c
 }
 
+// ConfigureLogging applies the logging settings: the levels logged for
+// each module and whether output is JSON. LOG_LEVEL and LOG_FORMAT take
+// precedence when set.
+func ConfigureLogging(settings *models.Settings) {
+	var levels, format string
+	if settings != nil {
+		levels, format = settings.LogLevels, settings.LogFormat
+	}
+
+	parsed, err := middleware.ParseLevels(cmp.Or(os.Getenv("LOG_LEVEL"), levels))
+	if err != nil {
+		log.Printf("LogsController: Warning: %v, logging at info", err)
+		parsed, _ = middleware.ParseLevels("")
+	}
+	if os.Getenv("DEBUG") == "true" {
+		parsed.Default = slog.LevelDebug
+	}
+	middleware.AppLogger.SetLevels(parsed)
+	middleware.AppLogger.SetJSON(cmp.Or(os.Getenv("LOG_FORMAT"), format) == "json")
+	middleware.AppLogger.Install()
+}
+
 // GetRecentLogs returns recent log entries for templates
 func (c *LogsController) GetRecentLogs(limit int) []middleware.LogEntry {
 	return middleware.AppLogger.GetRecentLogs(limit)
 }
 
+// LogParam returns a filter field from the logs page's query
+func (c *LogsController) LogParam(name string) string {
+	return c.Request.URL.Query().Get(name)
+}
+
+// LogFilterError describes what's wrong with the page's filter, such as
+// a pattern that isn't a regular expression
+func (c *LogsController) LogFilterError() string {
+	if _, err := logFilter(c.Request); err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+// TailURL returns the address of the live tail for the page's filter
+func (c *LogsController) TailURL() string {
+	query := url.Values{}
+	for _, name := range []string{"level", "module", "request_id", "q", "pattern"} {
+		if value := c.LogParam(name); value != "" {
+			query.Set(name, value)
+		}
+	}
+	return "/logs/tail?" + query.Encode()
+}
+
+// LogModules returns the modules that have logged recently
+func (c *LogsController) LogModules() []string {
+	return middleware.AppLogger.Modules()
+}
+
+// LogLevels returns the levels logged at, such as "info,ai=debug"
+func (c *LogsController) LogLevels() string {
+	return middleware.AppLogger.Levels().String()
+}
+
+// LogJSON reports whether logs are written as JSON lines
+func (c *LogsController) LogJSON() bool {
+	return middleware.AppLogger.JSON()
+}
+
+// LoggingFromEnv reports whether LOG_LEVEL or LOG_FORMAT override the
+// settings
+func (c *LogsController) LoggingFromEnv() bool {
+	return os.Getenv("LOG_LEVEL") != "" || os.Getenv("LOG_FORMAT") != "" || os.Getenv("DEBUG") == "true"
+}
+
+// logFilter reads a filter from a request's query: a level, module,
+// request_id, text to search for, a regular expression in pattern, the
+// from and to times and the sequence number to start after
+func logFilter(r *http.Request) (middleware.LogFilter, error) {
+	query := r.URL.Query()
+	filter := middleware.LogFilter{
+		Level:     query.Get("level"),
+		Module:    query.Get("module"),
+		RequestID: strings.TrimSpace(query.Get("request_id")),
+		Search:    strings.TrimSpace(query.Get("q")),
+	}
+
+	if pattern := query.Get("pattern"); pattern != "" {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return filter, fmt.Errorf("invalid pattern: %w", err)
+		}
+		filter.Pattern = re
+	}
+
+	var err error
+	if filter.From, err = parseLogTime(query.Get("from")); err != nil {
+		return filter, err
+	}
+	if filter.To, err = parseLogTime(query.Get("to")); err != nil {
+		return filter, err
+	}
+
+	if after := query.Get("after"); after != "" {
+		if filter.After, err = strconv.ParseUint(after, 10, 64); err != nil {
+			return filter, errors.New("invalid log position")
+		}
+	}
+	return filter, nil
+}
+
+// parseLogTime reads a time from a datetime-local field, in the server's
+// time zone, or as RFC 3339. Empty is the zero time.
+func parseLogTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02T15:04", value, time.Local); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return t, fmt.Errorf("invalid time %q", value)
+	}
+	return t, nil
+}
+
 // GetLogStats returns log statistics for templates
 func (c *LogsController) GetLogStats() map[string]any {
 	return middleware.AppLogger.GetLogStats()
@@ -59,7 +201,12 @@ func (c *LogsController) getRecentLogs(w http.ResponseWriter, r *http.Request) {
 		limit = 1000
 	}
 
-	logs := middleware.AppLogger.GetRecentLogs(limit)
+	filter, err := logFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	logs := middleware.AppLogger.Query(filter, limit)
 
 	// Return as JSON
 	w.Header().Set("Content-Type", "application/json")
@@ -78,3 +225,153 @@ func (c *LogsController) getLogStats(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(stats)
 }
+
+// updateSettings saves the levels logged for each module and the output
+// format, and applies them straight away
+func (c *LogsController) updateSettings(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	auth := c.App.Use("auth").(*AuthController)
+	user := auth.CurrentUser()
+
+	levels, err := middleware.ParseLevels(r.FormValue("levels"))
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+	format := r.FormValue("format")
+	if format != "text" && format != "json" {
+		c.RenderError(w, r, errors.New("log format must be text or json"))
+		return
+	}
+
+	settings, err := models.GetSettings()
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+	settings.LogLevels = levels.String()
+	settings.LogFormat = format
+	settings.LastUpdatedBy = user.Email
+	settings.LastUpdatedAt = time.Now()
+	if err := models.GlobalSettings.Update(settings); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+	ConfigureLogging(settings)
+
+	middleware.For(r.Context(), "logs").Info("changed log settings", "user_id", user.ID, "levels", settings.LogLevels, "format", format)
+	models.LogActivity("log_settings_updated", "Changed log settings",
+		fmt.Sprintf("Logging at %s as %s", settings.LogLevels, format), user.ID, "", "settings", "")
+
+	c.Refresh(w, r)
+}
+
+// streamTail follows the log for the live tail, starting with the latest
+// entries passing the filter. A tail resumed after a pause, or reconnected
+// by the browser, catches up from the last entry it had.
+func (c *LogsController) streamTail(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	filter, err := logFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if last, err := strconv.ParseUint(r.Header.Get("Last-Event-ID"), 10, 64); err == nil {
+		filter.After = last
+	}
+
+	auth := c.App.Use("auth").(*AuthController)
+	stream, err := sse.Streams.Open(w, r, "logs", auth.CurrentUser().ID)
+	if err != nil {
+		return
+	}
+	defer stream.Close()
+
+	// Subscribe first so nothing logged while catching up is missed
+	entries, stop := middleware.AppLogger.Subscribe()
+	defer stop()
+
+	limit := tailBacklog
+	if filter.After > 0 {
+		limit = 0
+	}
+	last := filter.After
+	for _, entry := range middleware.AppLogger.Query(filter, limit) {
+		stream.SendID(strconv.FormatUint(entry.Seq, 10), "log", renderLogLine(entry))
+		last = entry.Seq
+	}
+	stream.Send("caught-up", "")
+
+	for {
+		select {
+		case entry := <-entries:
+			if entry.Seq <= last || !filter.Matches(entry) {
+				continue
+			}
+			stream.SendID(strconv.FormatUint(entry.Seq, 10), "log", renderLogLine(entry))
+			last = entry.Seq
+		case <-stream.Heartbeat():
+			stream.Ping()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// renderLogLine renders an entry for the live tail, with warnings and
+// errors highlighted and its request ID linking to the rest of the request
+func renderLogLine(entry middleware.LogEntry) string {
+	class := "text-base-content/80"
+	switch entry.Level {
+	case "ERROR":
+		class = "bg-error/10 text-error"
+	case "WARN":
+		class = "bg-warning/10 text-warning"
+	case "DEBUG":
+		class = "text-base-content/50"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<div class="log-line px-2 whitespace-pre-wrap break-all %s" data-level="%s">`, class, entry.Level)
+	fmt.Fprintf(&b, `<span class="opacity-60">%s</span> <span class="font-bold">%-5s</span> `,
+		entry.Timestamp.Format("15:04:05"), entry.Level)
+	if entry.Module != "" {
+		fmt.Fprintf(&b, `<a href="?module=%s" class="link link-hover">%s</a>: `,
+			url.QueryEscape(entry.Module), template.HTMLEscapeString(entry.Module))
+	}
+	b.WriteString(template.HTMLEscapeString(entry.Message))
+	if details := entry.Details(); details != "" {
+		fmt.Fprintf(&b, ` <span class="opacity-70">%s</span>`, template.HTMLEscapeString(details))
+	}
+	if entry.RequestID != "" {
+		fmt.Fprintf(&b, ` <a href="?request_id=%s" class="link link-hover opacity-60" title="Show everything logged for this request">%s</a>`,
+			url.QueryEscape(entry.RequestID), template.HTMLEscapeString(entry.RequestID))
+	}
+	b.WriteString(`</div>`)
+	return b.String()
+}
+
+// download sends the entries logged between from and to that pass the
+// filter, as gzipped JSON lines
+func (c *LogsController) download(w http.ResponseWriter, r *http.Request) {
+	filter, err := logFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	entries := middleware.AppLogger.Query(filter, 0)
+
+	filename := "workspace-logs-" + time.Now().Format("20060102-150405") + ".jsonl.gz"
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+
+	gz := gzip.NewWriter(w)
+	defer gz.Close()
+	encoder := json.NewEncoder(gz)
+	for _, entry := range entries {
+		if err := encoder.Encode(entry); err != nil {
+			return
+		}
+	}
+	middleware.For(r.Context(), "logs").Info("downloaded logs", "entries", len(entries))
+}
@@ -1,11 +1,17 @@
 package controllers
 
 import (
+	"errors"
+	"log"
+	"mime"
 	"net/http"
+	"strings"
 
+	"workspace/middleware"
 	"workspace/models"
 
 	"github.com/The-Skyscape/devtools/pkg/application"
+	"github.com/The-Skyscape/devtools/pkg/authentication"
 )
 
 // AdminOnly - AccessCheck that requires admin user
@@ -29,7 +35,8 @@ func AdminOnly() application.AccessCheck {
 	}
 }
 
-// PublicOrAdmin - AccessCheck for public repos or admin access
+// PublicOrAdmin - AccessCheck for public repos, admins, or users whose
+// teams can read the repo
 func PublicOrAdmin() application.AccessCheck {
 	return func(app *application.App, w http.ResponseWriter, r *http.Request) bool {
 		repoID := r.PathValue("id")
@@ -49,10 +56,10 @@ func PublicOrAdmin() application.AccessCheck {
 			return true
 		}
 
-		// Private repos require admin
+		// Private repos require admin or a team with access
 		auth := app.Use("auth").(*AuthController)
 		user, _, err := auth.Authenticate(r)
-		if err != nil || !user.IsAdmin {
+		if err != nil || models.RepoRole(user, repo) == "" {
 			app.Render(w, r, "signin.html", nil)
 			return false
 		}
@@ -93,8 +100,15 @@ func AuthorOrAdmin(getAuthorID func(r *http.Request) (string, error)) applicatio
 	}
 }
 
-// PublicRepoOnly - AccessCheck that allows authenticated users on public repos, admins on any
+// PublicRepoOnly - AccessCheck that allows authenticated users on public repos,
+// admins and team members on any they can read
 func PublicRepoOnly() application.AccessCheck {
+	return RepoAccess(models.RoleRead)
+}
+
+// RepoAccess - AccessCheck that requires at least role on the repo, given
+// by being an admin or through the user's teams
+func RepoAccess(role string) application.AccessCheck {
 	return func(app *application.App, w http.ResponseWriter, r *http.Request) bool {
 		auth := app.Use("auth").(*AuthController)
 		user, _, err := auth.Authenticate(r)
@@ -109,23 +123,108 @@ func PublicRepoOnly() application.AccessCheck {
 			return false
 		}
 
-		repo, err := models.Repositories.Get(repoID)
-		if err != nil {
+		if _, err := models.Repositories.Get(repoID); err != nil {
 			app.Render(w, r, "error-404.html", nil)
 			return false
 		}
 
-		// Admins can access any repo
-		if user.IsAdmin {
-			return true
+		if err := models.CheckRepoAccess(user, repoID, role); err != nil {
+			app.Render(w, r, "insufficient-permissions.html", nil)
+			return false
 		}
 
-		// Non-admins can only access public repos
-		if repo.Visibility != "public" {
-			app.Render(w, r, "insufficient-permissions.html", nil)
+		return true
+	}
+}
+
+// APIAccess - AccessCheck for the HTTP API, accepting a personal access
+// token that allows scope as "Authorization: Bearer", or the browser
+// session, whose writes must be JSON. Failures are answered in JSON rather
+// than with the signin page.
+// An empty scope accepts any token, for handlers that check scopes per item.
+func APIAccess(scope string) application.AccessCheck {
+	return func(app *application.App, w http.ResponseWriter, r *http.Request) bool {
+		user, token, err := apiUser(app, r)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="Skyscape API"`)
+			apiError(w, http.StatusUnauthorized, err.Error())
+			return false
+		}
+
+		if token != nil {
+			if err := authorizeToken(token, scope, r); err != nil {
+				apiError(w, http.StatusForbidden, err.Error())
+				return false
+			}
+		} else if !sessionWriteAllowed(r) {
+			apiError(w, http.StatusUnsupportedMediaType, "requests signed in with a session must send Content-Type: application/json")
+			return false
+		}
+
+		// Tokens with the admin scope still need an admin to own them
+		if scope == models.ScopeAdmin && !user.IsAdmin {
+			apiError(w, http.StatusForbidden, "admin access required")
 			return false
 		}
 
 		return true
 	}
 }
+
+// sessionWriteAllowed reports whether a request authenticated by the
+// session cookie may change anything. Writes must be JSON, which a
+// cross-site form can't send without a CORS preflight, so another site
+// can't post to the API as the signed in user.
+func sessionWriteAllowed(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == "application/json"
+}
+
+// bearerToken returns the personal access token in the request's
+// Authorization header, empty when there is none
+func bearerToken(r *http.Request) string {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || !strings.HasPrefix(token, models.PersonalTokenPrefix) {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// apiUser returns who an API request is from: the owner of its personal
+// access token, or the signed in user. The token is nil for sessions.
+func apiUser(app *application.App, r *http.Request) (*authentication.User, *models.PersonalToken, error) {
+	value := bearerToken(r)
+	if value == "" {
+		user, _, err := app.Use("auth").(*AuthController).Authenticate(r)
+		if err != nil {
+			return nil, nil, errors.New("authentication required")
+		}
+		return user, nil, nil
+	}
+
+	token, err := models.GetPersonalToken(value)
+	if err != nil {
+		return nil, nil, err
+	}
+	user, err := models.Users.Get(token.UserID)
+	if err != nil {
+		return nil, nil, errors.New("invalid token user")
+	}
+	return user, token, nil
+}
+
+// authorizeToken checks a personal access token allows scope, recording
+// that it was used when it does
+func authorizeToken(token *models.PersonalToken, scope string, r *http.Request) error {
+	if scope != "" && !token.HasScope(scope) {
+		return errors.New("token lacks the " + scope + " scope")
+	}
+	if err := token.Used(middleware.ClientIP(r)); err != nil {
+		log.Printf("Failed to record use of token %s: %v", token.ID, err)
+	}
+	return nil
+}
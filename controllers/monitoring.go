@@ -11,6 +11,10 @@ import (
 	"sync"
 	"time"
 
+	"workspace/internal/sse"
+	"workspace/models"
+	"workspace/services"
+
 	"github.com/The-Skyscape/devtools/pkg/application"
 	"github.com/The-Skyscape/devtools/pkg/containers"
 )
@@ -25,6 +29,14 @@ type MonitoringController struct {
 	containersMu            *sync.RWMutex
 	containerUpdateInterval time.Duration
 	stopContainerMonitor    chan struct{}
+
+	// Resource history, container disk use and threshold alerts
+	resources *resourceMonitor
+
+	// Vault monitoring state
+	vaultStatus        *models.VaultStatus
+	vaultMu            *sync.RWMutex
+	vaultCheckInterval time.Duration
 }
 
 // Monitoring is the factory function for the monitoring controller
@@ -34,6 +46,9 @@ func Monitoring() (string, *MonitoringController) {
 		containersMu:            &sync.RWMutex{},
 		containerUpdateInterval: 15 * time.Second,
 		stopContainerMonitor:    make(chan struct{}),
+		resources:               newResourceMonitor(),
+		vaultMu:                 &sync.RWMutex{},
+		vaultCheckInterval:      30 * time.Second,
 	}
 }
 
@@ -48,6 +63,9 @@ func (m *MonitoringController) Setup(app *application.App) {
 	// Start background container monitoring
 	m.startContainerMonitor()
 
+	// Watch Vault for seal and fallback changes
+	m.startVaultMonitor()
+
 	// Create admin-only access check that redirects to profile
 	adminRequired := func(app *application.App, w http.ResponseWriter, r *http.Request) bool {
 		user, _, err := auth.Authenticate(r)
@@ -66,6 +84,7 @@ func (m *MonitoringController) Setup(app *application.App) {
 
 	// Live monitoring dashboard (now part of settings, admin only)
 	http.Handle("GET /settings/monitoring", app.Serve("settings-monitoring.html", adminRequired))
+	http.Handle("GET /monitoring/ai", app.Serve("monitoring-ai-usage.html", adminRequired))
 
 	// API endpoints for live updates
 	http.Handle("GET /monitoring/stats", app.ProtectFunc(m.getCurrentStats, auth.Required))
@@ -73,6 +92,7 @@ func (m *MonitoringController) Setup(app *application.App) {
 	http.Handle("GET /monitoring/history", app.ProtectFunc(m.getHistory, auth.Required))
 	http.Handle("GET /monitoring/alerts", app.ProtectFunc(m.getAlerts, auth.Required))
 	http.Handle("GET /monitoring/processes", app.ProtectFunc(m.getTopProcesses, auth.Required))
+	http.Handle("GET /monitoring/streams", app.ProtectFunc(m.getStreams, auth.AdminOnly))
 
 	// HTMX partial updates
 	http.Handle("GET /monitoring/partial/cpu", app.ProtectFunc(m.getCPUPartial, auth.Required))
@@ -80,6 +100,31 @@ func (m *MonitoringController) Setup(app *application.App) {
 	http.Handle("GET /monitoring/partial/disk", app.ProtectFunc(m.getDiskPartial, auth.Required))
 	http.Handle("GET /monitoring/partial/containers", app.ProtectFunc(m.getContainersPartial, auth.Required))
 	http.Handle("GET /monitoring/partial/alerts", app.ProtectFunc(m.getAlertsPartial, auth.Required))
+	http.Handle("GET /monitoring/partial/vault", app.ProtectFunc(m.getVaultPartial, auth.AdminOnly))
+	http.Handle("GET /monitoring/partial/ai", app.ProtectFunc(m.getAIPartial, auth.AdminOnly))
+	http.Handle("GET /monitoring/partial/history", app.ProtectFunc(m.getHistoryPartial, auth.AdminOnly))
+
+	// Thresholds resource alerts fire over
+	http.Handle("POST /monitoring/thresholds", app.ProtectFunc(m.updateThresholds, AdminOnly()))
+
+	// Vault recovery actions
+	http.Handle("POST /monitoring/vault/unseal", app.ProtectFunc(m.unsealVault, auth.AdminOnly))
+	http.Handle("POST /monitoring/vault/migrate", app.ProtectFunc(m.migrateVaultSecrets, auth.AdminOnly))
+
+	// Load the AI model before someone waits on it, and drop cached answers
+	http.Handle("POST /monitoring/ai/preload", app.ProtectFunc(m.preloadAIModel, auth.AdminOnly))
+	http.Handle("POST /monitoring/ai/cache/clear", app.ProtectFunc(m.clearAICache, auth.AdminOnly))
+
+	// Custom dashboards composed from widgets (admin only)
+	http.Handle("GET /settings/dashboards", app.Serve("settings-dashboards.html", adminRequired))
+	http.Handle("GET /settings/dashboards/{id}", app.Serve("settings-dashboard.html", adminRequired))
+	http.Handle("POST /settings/dashboards", app.ProtectFunc(m.createDashboard, AdminOnly()))
+	http.Handle("POST /settings/dashboards/{id}/delete", app.ProtectFunc(m.deleteDashboard, AdminOnly()))
+	http.Handle("POST /settings/dashboards/{id}/widgets", app.ProtectFunc(m.addWidget, AdminOnly()))
+	http.Handle("POST /settings/dashboards/{id}/widgets/{widgetID}/move", app.ProtectFunc(m.moveWidget, AdminOnly()))
+	http.Handle("POST /settings/dashboards/{id}/widgets/{widgetID}/resize", app.ProtectFunc(m.resizeWidget, AdminOnly()))
+	http.Handle("POST /settings/dashboards/{id}/widgets/{widgetID}/delete", app.ProtectFunc(m.removeWidget, AdminOnly()))
+	http.Handle("GET /monitoring/dashboards/{id}/widgets/{widgetID}", app.ProtectFunc(m.getWidgetPartial, AdminOnly()))
 }
 
 // Handle prepares the controller for each request
@@ -96,7 +141,11 @@ func (m *MonitoringController) GetCurrentStats() *containers.SystemStats {
 
 // GetAlertCount returns the number of current alerts
 func (m *MonitoringController) GetAlertCount() int {
-	return len(m.collector.CheckAlerts())
+	count := len(m.collector.CheckAlerts()) + len(m.ResourceAlerts())
+	if m.GetVaultAlert() != "" {
+		count++
+	}
+	return count
 }
 
 // getCurrentStats returns current statistics as JSON
@@ -112,6 +161,13 @@ func (m *MonitoringController) getCurrentStats(w http.ResponseWriter, r *http.Re
 	json.NewEncoder(w).Encode(stats)
 }
 
+// getStreams returns the open server-sent event streams by kind
+func (m *MonitoringController) getStreams(w http.ResponseWriter, r *http.Request) {
+	m.SetRequest(r)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sse.Streams.Stats())
+}
+
 // getLiveStats returns live statistics for HTMX polling
 func (m *MonitoringController) getLiveStats(w http.ResponseWriter, r *http.Request) {
 	m.SetRequest(r)
@@ -230,9 +286,7 @@ func (m *MonitoringController) getDiskPartial(w http.ResponseWriter, r *http.Req
 func (m *MonitoringController) getContainersPartial(w http.ResponseWriter, r *http.Request) {
 	m.SetRequest(r)
 	// Use cached container data for instant response
-	containers := m.GetContainers()
-
-	m.Render(w, r, "monitoring-containers.html", containers)
+	m.Render(w, r, "monitoring-containers.html", m.ContainerResources())
 }
 
 // getAlertsPartial returns alerts as HTML partial
@@ -255,6 +309,16 @@ func (m *MonitoringController) FormatPercent(percent float64) string {
 	return containers.FormatPercent(percent)
 }
 
+// GPU returns the GPUs found on the host
+func (m *MonitoringController) GPU() *services.GPUInfo {
+	return services.Ollama.GPU()
+}
+
+// OllamaOnGPU returns whether the AI service is running with GPU access
+func (m *MonitoringController) OllamaOnGPU() bool {
+	return services.Ollama.IsRunning() && services.Ollama.IsGPUEnabled()
+}
+
 // GetContainers returns cached container stats for templates
 func (m *MonitoringController) GetContainers() []containers.ContainerStats {
 	m.containersMu.RLock()
@@ -271,6 +335,7 @@ func (m *MonitoringController) startContainerMonitor() {
 	go func() {
 		// Initial update
 		m.updateContainers()
+		m.sampleResources()
 
 		ticker := time.NewTicker(m.containerUpdateInterval)
 		defer ticker.Stop()
@@ -279,6 +344,7 @@ func (m *MonitoringController) startContainerMonitor() {
 			select {
 			case <-ticker.C:
 				m.updateContainers()
+				m.sampleResources()
 			case <-m.stopContainerMonitor:
 				return
 			}
@@ -298,6 +364,13 @@ func (m *MonitoringController) updateContainers() {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
+	// Stats only cover running containers, the list has the others
+	listed, err := dockerContainers(ctx, false)
+	if err != nil {
+		log.Printf("Failed to list Docker containers: %v", err)
+		return
+	}
+
 	// Get container stats using docker stats
 	cmd := exec.CommandContext(ctx, "docker", "stats", "--no-stream", "--format",
 		"{{.Container}}|{{.Name}}|{{.CPUPerc}}|{{.MemUsage}}|{{.MemPerc}}|{{.NetIO}}|{{.BlockIO}}")
@@ -337,8 +410,10 @@ func (m *MonitoringController) updateContainers() {
 			memUsage = parseMemoryString(memUsageParts[0])
 		}
 
-		// Determine status (simplified - in real implementation would use docker ps)
 		status := "running"
+		if c, ok := listed[parts[1]]; ok {
+			status = c.status
+		}
 
 		container := containers.ContainerStats{
 			ID:         parts[0][:12], // First 12 chars of container ID
@@ -354,6 +429,18 @@ func (m *MonitoringController) updateContainers() {
 		containerList = append(containerList, container)
 	}
 
+	// Add the stopped containers, such as environments that were shut down
+	for name, c := range listed {
+		if c.status == "running" {
+			continue
+		}
+		containerList = append(containerList, containers.ContainerStats{
+			ID:     c.id,
+			Name:   name,
+			Status: c.status,
+		})
+	}
+
 	// Update cached state
 	m.containersMu.Lock()
 	m.containers = containerList
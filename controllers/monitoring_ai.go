@@ -0,0 +1,146 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"workspace/internal/aicache"
+	"workspace/models"
+	"workspace/services"
+)
+
+// AIStatus describes whether the AI model is ready to answer without a cold load
+type AIStatus struct {
+	Running      bool
+	DefaultModel string
+	Loaded       []services.LoadedModel
+	Loading      bool   // The default model is being preloaded
+	KeepAlive    string // How long models stay loaded after a request
+	Queue        services.ChatQueueStatus
+	Cache        aicache.Stats
+	CheckedAt    time.Time
+	Error        string
+}
+
+// DefaultLoaded returns the default model's entry when a server holds it
+func (s *AIStatus) DefaultLoaded() *services.LoadedModel {
+	for i, model := range s.Loaded {
+		if services.Ollama.IsDefaultModel(model.Name) {
+			return &s.Loaded[i]
+		}
+	}
+	return nil
+}
+
+// GetAIStatus returns which models the Ollama servers hold in memory
+func (m *MonitoringController) GetAIStatus() *AIStatus {
+	status := &AIStatus{
+		Running:      services.Ollama.IsRunning(),
+		DefaultModel: services.Ollama.GetDefaultModel(),
+		KeepAlive:    services.Ollama.KeepAlive(),
+		Queue:        services.Ollama.QueueStatus(),
+		Cache:        aicache.Responses.Stats(),
+		CheckedAt:    time.Now(),
+	}
+	if !status.Running {
+		return status
+	}
+
+	loaded, err := services.Ollama.LoadedModels()
+	if err != nil {
+		status.Error = err.Error()
+	}
+	status.Loaded = loaded
+	status.Loading = services.Ollama.IsModelLoading(status.DefaultModel)
+	return status
+}
+
+// getAIPartial returns the AI model card as HTML partial
+func (m *MonitoringController) getAIPartial(w http.ResponseWriter, r *http.Request) {
+	m.SetRequest(r)
+	m.Render(w, r, "monitoring-ai.html", m.GetAIStatus())
+}
+
+// preloadAIModel loads the default model into memory in the background
+func (m *MonitoringController) preloadAIModel(w http.ResponseWriter, r *http.Request) {
+	m.SetRequest(r)
+	user, _, err := m.Use("auth").(*AuthController).Authenticate(r)
+	if err != nil {
+		m.RenderError(w, r, errors.New("unauthorized"))
+		return
+	}
+
+	model := services.Ollama.GetDefaultModel()
+	if err := services.Ollama.StartPreload(model); err != nil {
+		m.RenderError(w, r, err)
+		return
+	}
+
+	log.Printf("Admin action: AI model %s preload started by %s", model, user.Email)
+	models.LogActivity("ai_model_preloaded", "Preloaded an AI model",
+		"Loading "+model+" into memory for "+services.Ollama.KeepAlive(), user.ID, "", "ai_model", model)
+
+	m.Render(w, r, "monitoring-ai.html", m.GetAIStatus())
+}
+
+// clearAICache drops every cached AI answer
+func (m *MonitoringController) clearAICache(w http.ResponseWriter, r *http.Request) {
+	m.SetRequest(r)
+	user, _, err := m.Use("auth").(*AuthController).Authenticate(r)
+	if err != nil {
+		m.RenderError(w, r, errors.New("unauthorized"))
+		return
+	}
+
+	cleared := aicache.Responses.Clear()
+	log.Printf("Admin action: %d cached AI answers cleared by %s", cleared, user.Email)
+
+	m.Render(w, r, "monitoring-ai.html", m.GetAIStatus())
+}
+
+// tokenReportDays are the periods the AI usage page offers, in days
+var tokenReportDays = []int{7, 30, 90}
+
+// TokenDays returns the number of days the AI usage page covers, taken from
+// ?days= and defaulting to 30
+func (m *MonitoringController) TokenDays() int {
+	if days, err := strconv.Atoi(m.Request.URL.Query().Get("days")); err == nil && slices.Contains(tokenReportDays, days) {
+		return days
+	}
+	return 30
+}
+
+// TokenDayOptions returns the periods the AI usage page offers
+func (m *MonitoringController) TokenDayOptions() []int {
+	return tokenReportDays
+}
+
+// TokenReport returns the tokens used in the period being viewed
+func (m *MonitoringController) TokenReport() (*models.TokenReport, error) {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	return models.GetTokenReport(today.AddDate(0, 0, 1-m.TokenDays()))
+}
+
+// UserEmail returns a user's email, or "" when they were deleted
+func (m *MonitoringController) UserEmail(id string) string {
+	if user, err := models.Users.Get(id); err == nil {
+		return user.Email
+	}
+	return ""
+}
+
+// ConversationTitle returns a conversation's title, or "" when it was deleted
+func (m *MonitoringController) ConversationTitle(id string) string {
+	if conversation, err := models.Conversations.Get(id); err == nil {
+		if conversation.Title != "" {
+			return conversation.Title
+		}
+		return "Untitled conversation"
+	}
+	return ""
+}
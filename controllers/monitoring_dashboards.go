@@ -0,0 +1,272 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"workspace/internal/backup"
+	"workspace/middleware"
+	"workspace/models"
+)
+
+// ChartPoint is one bar of a metric chart
+type ChartPoint struct {
+	Value  float64
+	Height int // Percent of the chart's height
+}
+
+// Dashboards returns the saved monitoring dashboards
+func (m *MonitoringController) Dashboards() ([]*models.Dashboard, error) {
+	return models.ListDashboards()
+}
+
+// CurrentDashboard returns the dashboard named in the request path
+func (m *MonitoringController) CurrentDashboard() (*models.Dashboard, error) {
+	return models.Dashboards.Get(m.Request.PathValue("id"))
+}
+
+// EditingDashboard reports whether the dashboard is shown with layout controls
+func (m *MonitoringController) EditingDashboard() bool {
+	return m.Request.URL.Query().Get("edit") == "true"
+}
+
+// WidgetTypes returns the widgets that can be added to a dashboard
+func (m *MonitoringController) WidgetTypes() []models.DashboardWidgetType {
+	return models.DashboardWidgetTypes
+}
+
+// DashboardMetrics returns the metrics a chart widget can plot
+func (m *MonitoringController) DashboardMetrics() map[string]string {
+	return models.DashboardMetrics
+}
+
+// DashboardWindows returns the history ranges offered for chart widgets
+func (m *MonitoringController) DashboardWindows() []int {
+	return models.DashboardWindows
+}
+
+// createDashboard handles POST /settings/dashboards
+func (m *MonitoringController) createDashboard(w http.ResponseWriter, r *http.Request) {
+	m.SetRequest(r)
+	user, _, err := m.App.Use("auth").(*AuthController).Authenticate(r)
+	if err != nil {
+		m.RenderError(w, r, err)
+		return
+	}
+
+	dashboard, err := models.CreateDashboard(r.FormValue("name"), r.FormValue("description"), user.ID)
+	if err != nil {
+		m.RenderError(w, r, err)
+		return
+	}
+
+	m.Redirect(w, r, fmt.Sprintf("/settings/dashboards/%s?edit=true", dashboard.ID))
+}
+
+// deleteDashboard handles POST /settings/dashboards/{id}/delete
+func (m *MonitoringController) deleteDashboard(w http.ResponseWriter, r *http.Request) {
+	m.SetRequest(r)
+	dashboard, err := models.Dashboards.Get(r.PathValue("id"))
+	if err != nil {
+		m.RenderError(w, r, errors.New("dashboard not found"))
+		return
+	}
+
+	if err := models.Dashboards.Delete(dashboard); err != nil {
+		m.RenderError(w, r, err)
+		return
+	}
+
+	m.Redirect(w, r, "/settings/dashboards")
+}
+
+// addWidget handles POST /settings/dashboards/{id}/widgets
+func (m *MonitoringController) addWidget(w http.ResponseWriter, r *http.Request) {
+	m.SetRequest(r)
+	dashboard, user, err := m.dashboardForUpdate(r)
+	if err != nil {
+		m.RenderError(w, r, err)
+		return
+	}
+
+	width, _ := strconv.Atoi(r.FormValue("width"))
+	window, _ := strconv.Atoi(r.FormValue("window"))
+	_, err = dashboard.AddWidget(models.DashboardWidget{
+		Type:   r.FormValue("type"),
+		Title:  r.FormValue("title"),
+		Width:  width,
+		Metric: r.FormValue("metric"),
+		Window: window,
+	}, user.ID)
+	if err != nil {
+		m.RenderError(w, r, err)
+		return
+	}
+
+	m.Redirect(w, r, fmt.Sprintf("/settings/dashboards/%s?edit=true", dashboard.ID))
+}
+
+// moveWidget handles POST /settings/dashboards/{id}/widgets/{widgetID}/move
+func (m *MonitoringController) moveWidget(w http.ResponseWriter, r *http.Request) {
+	m.SetRequest(r)
+	dashboard, user, err := m.dashboardForUpdate(r)
+	if err != nil {
+		m.RenderError(w, r, err)
+		return
+	}
+
+	offset := 1
+	if r.FormValue("direction") == "up" {
+		offset = -1
+	}
+	if err := dashboard.MoveWidget(r.PathValue("widgetID"), offset, user.ID); err != nil {
+		m.RenderError(w, r, err)
+		return
+	}
+
+	m.Redirect(w, r, fmt.Sprintf("/settings/dashboards/%s?edit=true", dashboard.ID))
+}
+
+// resizeWidget handles POST /settings/dashboards/{id}/widgets/{widgetID}/resize
+func (m *MonitoringController) resizeWidget(w http.ResponseWriter, r *http.Request) {
+	m.SetRequest(r)
+	dashboard, user, err := m.dashboardForUpdate(r)
+	if err != nil {
+		m.RenderError(w, r, err)
+		return
+	}
+
+	width, err := strconv.Atoi(r.FormValue("width"))
+	if err != nil {
+		m.RenderError(w, r, errors.New("width must be a number of columns"))
+		return
+	}
+	if err := dashboard.ResizeWidget(r.PathValue("widgetID"), width, user.ID); err != nil {
+		m.RenderError(w, r, err)
+		return
+	}
+
+	m.Redirect(w, r, fmt.Sprintf("/settings/dashboards/%s?edit=true", dashboard.ID))
+}
+
+// removeWidget handles POST /settings/dashboards/{id}/widgets/{widgetID}/delete
+func (m *MonitoringController) removeWidget(w http.ResponseWriter, r *http.Request) {
+	m.SetRequest(r)
+	dashboard, user, err := m.dashboardForUpdate(r)
+	if err != nil {
+		m.RenderError(w, r, err)
+		return
+	}
+
+	if err := dashboard.RemoveWidget(r.PathValue("widgetID"), user.ID); err != nil {
+		m.RenderError(w, r, err)
+		return
+	}
+
+	m.Redirect(w, r, fmt.Sprintf("/settings/dashboards/%s?edit=true", dashboard.ID))
+}
+
+// dashboardForUpdate loads the dashboard being edited and the admin editing it
+func (m *MonitoringController) dashboardForUpdate(r *http.Request) (*models.Dashboard, *models.User, error) {
+	user, _, err := m.App.Use("auth").(*AuthController).Authenticate(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	dashboard, err := models.Dashboards.Get(r.PathValue("id"))
+	if err != nil {
+		return nil, nil, errors.New("dashboard not found")
+	}
+	return dashboard, user, nil
+}
+
+// getWidgetPartial handles GET /monitoring/dashboards/{id}/widgets/{widgetID},
+// rendering the widget's current data
+func (m *MonitoringController) getWidgetPartial(w http.ResponseWriter, r *http.Request) {
+	m.SetRequest(r)
+	dashboard, err := models.Dashboards.Get(r.PathValue("id"))
+	if err != nil {
+		m.RenderError(w, r, errors.New("dashboard not found"))
+		return
+	}
+
+	widget, ok := dashboard.Widget(r.PathValue("widgetID"))
+	if !ok {
+		m.RenderError(w, r, errors.New("widget not found"))
+		return
+	}
+
+	data := map[string]any{"Widget": widget}
+	switch widget.Type {
+	case "metric_chart":
+		data["Label"] = models.DashboardMetrics[widget.Metric]
+		data["Points"], data["Current"] = m.metricHistory(widget.Metric, time.Duration(widget.Window)*time.Minute)
+	case "container_status":
+		data["Containers"] = m.GetContainers()
+	case "queue_depth":
+		data["AIQueue"] = m.App.Use("ai").(*AIController).GetQueueStats()
+		data["QueuedEvents"] = models.CountQueuedEvents()
+	case "recent_errors":
+		data["Errors"] = recentErrors(10)
+	case "backup_status":
+		if backup.Scheduler != nil {
+			data["Backup"] = backup.Scheduler.GetStatus()
+			if backups, err := backup.Scheduler.ListBackups(); err == nil {
+				slices.SortFunc(backups, func(a, b backup.BackupInfo) int { return b.Created.Compare(a.Created) })
+				data["Backups"] = backups[:min(len(backups), 3)]
+			}
+		}
+	}
+
+	m.Render(w, r, "dashboard-widget.html", data)
+}
+
+// metricHistory returns the collected samples of a metric over the window,
+// scaled for a bar chart, along with the latest value
+func (m *MonitoringController) metricHistory(metric string, window time.Duration) ([]ChartPoint, float64) {
+	var values []float64
+	for _, stats := range m.collector.GetHistorySince(time.Now().Add(-window)) {
+		switch metric {
+		case "cpu":
+			values = append(values, stats.CPU.UsagePercent)
+		case "memory":
+			values = append(values, stats.Memory.UsedPercent)
+		case "disk":
+			values = append(values, stats.Disk.UsedPercent)
+		case "load":
+			values = append(values, stats.LoadAverage.Load1)
+		}
+	}
+	if len(values) == 0 {
+		return nil, 0
+	}
+
+	// Percentages use a fixed scale, load scales to its peak
+	scale := 100.0
+	if metric == "load" {
+		scale = math.Max(slices.Max(values), 1)
+	}
+
+	points := make([]ChartPoint, len(values))
+	for i, v := range values {
+		points[i] = ChartPoint{Value: v, Height: int(math.Round(math.Min(v/scale, 1) * 100))}
+	}
+	return points, values[len(values)-1]
+}
+
+// recentErrors returns the latest error log entries, newest first
+func recentErrors(limit int) []middleware.LogEntry {
+	logs := middleware.AppLogger.GetRecentLogs(0)
+	var errs []middleware.LogEntry
+	for i := len(logs) - 1; i >= 0 && len(errs) < limit; i-- {
+		if logs[i].Level == "ERROR" || logs[i].Level == "FATAL" {
+			errs = append(errs, logs[i])
+		}
+	}
+	return errs
+}
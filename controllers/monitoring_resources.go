@@ -0,0 +1,499 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"workspace/models"
+
+	"github.com/The-Skyscape/devtools/pkg/containers"
+)
+
+// How often resource use is recorded, and how thresholds alert: after
+// resourceAlertSamples samples in a row over the threshold, until a
+// sample is resourceAlertHysteresis points back under it
+const (
+	resourceSampleInterval  = time.Minute
+	resourcePruneInterval   = time.Hour
+	resourceAlertSamples    = 3
+	resourceAlertHysteresis = 5.0
+	resourceChartBars       = 60
+)
+
+// resourceMonitor holds what the container monitor learns between
+// requests: the disk used by each container and the alerts firing
+type resourceMonitor struct {
+	mu        sync.RWMutex
+	disk      map[string]uint64 // Writable layer size by container name
+	alerts    map[string]*ResourceAlert
+	sampledAt time.Time
+	prunedAt  time.Time
+}
+
+func newResourceMonitor() *resourceMonitor {
+	return &resourceMonitor{
+		disk:   map[string]uint64{},
+		alerts: map[string]*ResourceAlert{},
+	}
+}
+
+// ContainerResources is a container's latest stats with what it is and
+// the disk its writable layer takes up
+type ContainerResources struct {
+	containers.ContainerStats
+	Kind     string
+	DiskUsed uint64
+}
+
+// ResourceAlert is a host or container metric over its threshold
+type ResourceAlert struct {
+	Source    string
+	Kind      string
+	Metric    string // cpu, memory or disk
+	Value     float64
+	Threshold float64
+	Since     time.Time // First sample over the threshold
+	Firing    bool
+	over      int // Samples in a row over the threshold
+}
+
+// Title describes the alert in a few words
+func (a *ResourceAlert) Title() string {
+	return fmt.Sprintf("%s %s over %.0f%%", resourceLabel(a.Source), metricLabel(a.Metric), a.Threshold)
+}
+
+// Summary describes the alert's current value
+func (a *ResourceAlert) Summary() string {
+	return fmt.Sprintf("%s %s has been at %.0f%% since %s, over the %.0f%% threshold",
+		resourceLabel(a.Source), metricLabel(a.Metric), a.Value, a.Since.Format("15:04"), a.Threshold)
+}
+
+// ResourceChart is the history of one metric of a host or container
+type ResourceChart struct {
+	Metric  string
+	Label   string
+	Bytes   bool // Values are bytes rather than percentages
+	Points  []ChartPoint
+	Current float64
+}
+
+// Format writes one of the chart's values for display
+func (c ResourceChart) Format(value float64) string {
+	switch {
+	case c.Bytes:
+		return containers.FormatBytes(uint64(value))
+	case c.Metric == "load":
+		return fmt.Sprintf("%.2f", value)
+	default:
+		return fmt.Sprintf("%.1f%%", value)
+	}
+}
+
+// ResourceHistory is the charts of a host or container over some hours
+type ResourceHistory struct {
+	Source string
+	Kind   string
+	Hours  int
+	Charts []ResourceChart
+}
+
+// ContainerResources returns the latest stats of each container with
+// their kind and disk use, grouped by kind
+func (m *MonitoringController) ContainerResources() []ContainerResources {
+	m.resources.mu.RLock()
+	defer m.resources.mu.RUnlock()
+
+	var result []ContainerResources
+	for _, c := range m.GetContainers() {
+		result = append(result, ContainerResources{
+			ContainerStats: c,
+			Kind:           models.ContainerKind(c.Name),
+			DiskUsed:       m.resources.disk[c.Name],
+		})
+	}
+	slices.SortStableFunc(result, func(a, b ContainerResources) int {
+		if a.Kind != b.Kind {
+			return strings.Compare(a.Kind, b.Kind)
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+	return result
+}
+
+// ResourceAlerts returns the alerts firing, the longest firing first
+func (m *MonitoringController) ResourceAlerts() []*ResourceAlert {
+	m.resources.mu.RLock()
+	defer m.resources.mu.RUnlock()
+
+	var firing []*ResourceAlert
+	for _, alert := range m.resources.alerts {
+		if alert.Firing {
+			copied := *alert
+			firing = append(firing, &copied)
+		}
+	}
+	slices.SortFunc(firing, func(a, b *ResourceAlert) int { return a.Since.Compare(b.Since) })
+	return firing
+}
+
+// MonitorSettings returns the thresholds and retention for templates
+func (m *MonitoringController) MonitorSettings() *models.Settings {
+	settings, err := models.GetSettings()
+	if err != nil {
+		return &models.Settings{}
+	}
+	return settings
+}
+
+// ResourceHistory returns the charts of the host, or a container, from
+// the samples recorded over the last hours
+func (m *MonitoringController) ResourceHistory(source string, hours int) *ResourceHistory {
+	if source == "" {
+		source = models.ResourceHostSource
+	}
+	if hours <= 0 {
+		hours = 24
+	}
+	window := time.Duration(hours) * time.Hour
+
+	history := &ResourceHistory{Source: source, Kind: models.ResourceHost, Hours: hours}
+	metrics := []ResourceChart{
+		{Metric: "cpu", Label: "CPU"},
+		{Metric: "memory", Label: "Memory"},
+		{Metric: "disk", Label: "Disk"},
+		{Metric: "load", Label: "Load"},
+	}
+	if source != models.ResourceHostSource {
+		history.Kind = models.ContainerKind(source)
+		metrics = []ResourceChart{
+			{Metric: "cpu", Label: "CPU"},
+			{Metric: "memory", Label: "Memory"},
+			{Metric: "disk", Label: "Disk", Bytes: true},
+		}
+	}
+
+	start := time.Now().Add(-window)
+	samples, err := models.ResourceHistory(source, start)
+	if err != nil {
+		log.Printf("MonitoringController: Failed to load the resource history of %s: %v", source, err)
+	}
+	for _, chart := range metrics {
+		chart.Points, chart.Current = resourceChart(samples, chart, start, window)
+		history.Charts = append(history.Charts, chart)
+	}
+	return history
+}
+
+// resourceChart averages samples into bars across the window, leaving
+// bars without samples empty so the chart keeps its time scale
+func resourceChart(samples []*models.ResourceSample, chart ResourceChart, start time.Time, window time.Duration) ([]ChartPoint, float64) {
+	if len(samples) == 0 {
+		return nil, 0
+	}
+
+	value := func(s *models.ResourceSample) float64 {
+		switch chart.Metric {
+		case "cpu":
+			return s.CPUPercent
+		case "memory":
+			return s.MemPercent
+		case "disk":
+			if chart.Bytes {
+				return float64(s.DiskUsed)
+			}
+			return s.DiskPercent
+		default:
+			return s.Load1
+		}
+	}
+
+	sums := make([]float64, resourceChartBars)
+	counts := make([]int, resourceChartBars)
+	width := window / resourceChartBars
+	for _, s := range samples {
+		i := min(max(int(s.CreatedAt.Sub(start)/width), 0), resourceChartBars-1)
+		sums[i] += value(s)
+		counts[i]++
+	}
+
+	// Percentages use a fixed scale, load and bytes scale to their peak
+	points := make([]ChartPoint, resourceChartBars)
+	scale := 100.0
+	if chart.Bytes || chart.Metric == "load" {
+		scale = 1
+		for i := range sums {
+			if counts[i] > 0 {
+				scale = math.Max(scale, sums[i]/float64(counts[i]))
+			}
+		}
+	}
+	for i := range points {
+		if counts[i] == 0 {
+			continue
+		}
+		v := sums[i] / float64(counts[i])
+		points[i] = ChartPoint{Value: v, Height: int(math.Round(math.Min(v/scale, 1) * 100))}
+	}
+	return points, value(samples[len(samples)-1])
+}
+
+// getHistoryPartial handles GET /monitoring/partial/history, rendering
+// the charts of the source and hours asked for
+func (m *MonitoringController) getHistoryPartial(w http.ResponseWriter, r *http.Request) {
+	m.SetRequest(r)
+	hours, _ := strconv.Atoi(r.URL.Query().Get("hours"))
+	m.Render(w, r, "monitoring-history.html", m.ResourceHistory(r.URL.Query().Get("source"), hours))
+}
+
+// updateThresholds handles POST /monitoring/thresholds, saving when
+// resources alert and how long their history is kept
+func (m *MonitoringController) updateThresholds(w http.ResponseWriter, r *http.Request) {
+	m.SetRequest(r)
+	auth := m.App.Use("auth").(*AuthController)
+	user := auth.CurrentUser()
+
+	settings, err := models.GetSettings()
+	if err != nil {
+		m.RenderError(w, r, err)
+		return
+	}
+
+	fields := []*int{
+		&settings.MonitorCPUPercent, &settings.MonitorMemoryPercent,
+		&settings.MonitorDiskPercent, &settings.MonitorRetentionDays,
+	}
+	for i, name := range []string{"cpu_percent", "memory_percent", "disk_percent", "retention_days"} {
+		value := strings.TrimSpace(r.FormValue(name))
+		if value == "" {
+			*fields[i] = 0
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 || (name != "retention_days" && n > 100) {
+			m.RenderError(w, r, errors.New("thresholds must be percentages and retention a whole number of days"))
+			return
+		}
+		*fields[i] = n
+	}
+
+	settings.LastUpdatedBy = user.Email
+	settings.LastUpdatedAt = time.Now()
+	if err := models.GlobalSettings.Update(settings); err != nil {
+		m.RenderError(w, r, err)
+		return
+	}
+
+	cpu, memory, disk := settings.MonitorThresholds()
+	models.LogActivity("monitor_settings_updated", "Changed resource alert thresholds",
+		fmt.Sprintf("CPU %.0f%%, memory %.0f%%, disk %.0f%%", cpu, memory, disk), user.ID, "", "settings", "")
+
+	m.Refresh(w, r)
+}
+
+// sampleResources records the host's and each running container's
+// resource use once a minute, checks them against the thresholds and
+// prunes samples older than the retention
+func (m *MonitoringController) sampleResources() {
+	now := time.Now()
+	if now.Sub(m.resources.sampledAt) < resourceSampleInterval {
+		return
+	}
+	m.resources.sampledAt = now
+
+	settings, err := models.GetSettings()
+	if err != nil {
+		settings = &models.Settings{}
+	}
+
+	var samples []*models.ResourceSample
+	if stats, err := m.collector.GetCurrent(); err == nil {
+		samples = append(samples, &models.ResourceSample{
+			Source:      models.ResourceHostSource,
+			Kind:        models.ResourceHost,
+			CPUPercent:  stats.CPU.UsagePercent,
+			MemUsed:     int64(stats.Memory.Used),
+			MemPercent:  stats.Memory.UsedPercent,
+			DiskUsed:    int64(stats.Disk.Used),
+			DiskPercent: stats.Disk.UsedPercent,
+			Load1:       stats.LoadAverage.Load1,
+		})
+	}
+
+	// Sizing writable layers is slow, so it's only done when sampling
+	if _, err := exec.LookPath("docker"); err == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		listed, err := dockerContainers(ctx, true)
+		cancel()
+		if err != nil {
+			log.Printf("MonitoringController: Failed to get container sizes: %v", err)
+		} else {
+			disk := map[string]uint64{}
+			for name, c := range listed {
+				disk[name] = c.size
+			}
+			m.resources.mu.Lock()
+			m.resources.disk = disk
+			m.resources.mu.Unlock()
+		}
+	}
+
+	for _, c := range m.ContainerResources() {
+		if c.Status != "running" {
+			continue
+		}
+		samples = append(samples, &models.ResourceSample{
+			Source:     c.Name,
+			Kind:       c.Kind,
+			CPUPercent: c.CPUPercent,
+			MemUsed:    int64(c.MemUsage),
+			MemPercent: c.MemPercent,
+			DiskUsed:   int64(c.DiskUsed),
+		})
+	}
+
+	for _, sample := range samples {
+		if err := models.RecordResourceSample(sample); err != nil {
+			log.Printf("MonitoringController: %v", err)
+			break
+		}
+	}
+	m.checkThresholds(samples, settings)
+
+	if now.Sub(m.resources.prunedAt) >= resourcePruneInterval {
+		m.resources.prunedAt = now
+		if err := models.PruneResourceSamples(now.Add(-settings.MonitorRetention())); err != nil {
+			log.Printf("MonitoringController: Failed to prune resource samples: %v", err)
+		}
+	}
+}
+
+// checkThresholds fires an alert for each metric over its threshold for
+// several samples in a row, and resolves it once the metric is back
+// under. Containers alert on memory only, as their CPU is measured
+// against a single core and often passes 100%.
+func (m *MonitoringController) checkThresholds(samples []*models.ResourceSample, settings *models.Settings) {
+	cpu, memory, disk := settings.MonitorThresholds()
+
+	m.resources.mu.Lock()
+	var fired, resolved []ResourceAlert
+	seen := map[string]bool{}
+	for _, sample := range samples {
+		values := map[string]float64{"memory": sample.MemPercent}
+		thresholds := map[string]float64{"memory": memory}
+		if sample.Kind == models.ResourceHost {
+			values["cpu"], values["disk"] = sample.CPUPercent, sample.DiskPercent
+			thresholds["cpu"], thresholds["disk"] = cpu, disk
+		}
+
+		for metric, value := range values {
+			key := sample.Source + ":" + metric
+			seen[key] = true
+			threshold := thresholds[metric]
+			alert := m.resources.alerts[key]
+
+			switch {
+			case value >= threshold:
+				if alert == nil {
+					alert = &ResourceAlert{Source: sample.Source, Kind: sample.Kind, Metric: metric, Since: time.Now()}
+					m.resources.alerts[key] = alert
+				}
+				alert.Value, alert.Threshold = value, threshold
+				if alert.over++; !alert.Firing && alert.over >= resourceAlertSamples {
+					alert.Firing = true
+					fired = append(fired, *alert)
+				}
+			case alert != nil && alert.Firing && value > threshold-resourceAlertHysteresis:
+				alert.Value = value
+			case alert != nil:
+				delete(m.resources.alerts, key)
+				if alert.Firing {
+					alert.Value = value
+					resolved = append(resolved, *alert)
+				}
+			}
+		}
+	}
+
+	// Containers that have stopped or been removed no longer alert
+	for key := range m.resources.alerts {
+		if !seen[key] {
+			delete(m.resources.alerts, key)
+		}
+	}
+	m.resources.mu.Unlock()
+
+	for _, alert := range fired {
+		log.Printf("MonitoringController: Warning: %s", alert.Summary())
+		models.LogActivity("resource_alert", alert.Title(), alert.Summary(), "", "", "monitoring", alert.Source)
+		if err := models.NotifyAdmins("resource_alert", alert.Title(), alert.Summary(), "/settings/monitoring"); err != nil {
+			log.Printf("MonitoringController: Failed to notify administrators of the resource alert: %v", err)
+		}
+	}
+	for _, alert := range resolved {
+		summary := fmt.Sprintf("%s %s is back to %.0f%%", resourceLabel(alert.Source), metricLabel(alert.Metric), alert.Value)
+		log.Printf("MonitoringController: %s", summary)
+		models.LogActivity("resource_alert_resolved", "Resolved: "+alert.Title(), summary, "", "", "monitoring", alert.Source)
+	}
+}
+
+// dockerContainer is a container as docker ps lists it
+type dockerContainer struct {
+	id     string
+	status string // running, exited, paused, ...
+	size   uint64 // Writable layer, when listed with sizes
+}
+
+// dockerContainers lists every container by name, stopped ones included
+func dockerContainers(ctx context.Context, withSize bool) (map[string]dockerContainer, error) {
+	// Docker sizes every container when the format mentions its size
+	format := "{{.ID}}|{{.Names}}|{{.State}}|"
+	if withSize {
+		format += "{{.Size}}"
+	}
+	output, err := exec.CommandContext(ctx, "docker", "ps", "--all", "--format", format).Output()
+	if err != nil {
+		return nil, err
+	}
+
+	listed := map[string]dockerContainer{}
+	for _, line := range strings.Split(string(output), "\n") {
+		parts := strings.Split(strings.TrimSpace(line), "|")
+		if len(parts) < 4 {
+			continue
+		}
+		// Sizes read "12.3MB (virtual 1.2GB)"
+		size, _, _ := strings.Cut(parts[3], " ")
+		listed[parts[1]] = dockerContainer{
+			id:     parts[0],
+			status: parts[2],
+			size:   parseMemoryString(size),
+		}
+	}
+	return listed, nil
+}
+
+// resourceLabel names the host or a container in alerts
+func resourceLabel(source string) string {
+	if source == models.ResourceHostSource {
+		return "Host"
+	}
+	return source
+}
+
+// metricLabel names a metric in alerts
+func metricLabel(metric string) string {
+	if metric == "cpu" {
+		return "CPU"
+	}
+	return metric
+}
@@ -0,0 +1,154 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"workspace/models"
+)
+
+// GetVaultStatus returns the last collected Vault status for templates
+func (m *MonitoringController) GetVaultStatus() *models.VaultStatus {
+	m.vaultMu.RLock()
+	defer m.vaultMu.RUnlock()
+
+	if m.vaultStatus == nil {
+		return &models.VaultStatus{}
+	}
+	return m.vaultStatus
+}
+
+// GetVaultAlert returns a warning about the secrets backend, or "" when healthy
+func (m *MonitoringController) GetVaultAlert() string {
+	m.vaultMu.RLock()
+	status := m.vaultStatus
+	m.vaultMu.RUnlock()
+
+	switch {
+	case status == nil:
+		return ""
+	case status.Sealed:
+		return "Vault is sealed. Secrets cannot be read until it is unsealed."
+	case !status.Available && status.FallbackMode:
+		return "Vault is unavailable. Secrets are being stored in the fallback file store."
+	case !status.Available:
+		return "Vault is unavailable and no fallback store is active."
+	case status.PendingMigrations > 0:
+		return fmt.Sprintf("%d secrets stored during a Vault outage are waiting to be migrated.", status.PendingMigrations)
+	}
+	return ""
+}
+
+// startVaultMonitor starts the background Vault health check goroutine
+func (m *MonitoringController) startVaultMonitor() {
+	go func() {
+		m.checkVault()
+
+		ticker := time.NewTicker(m.vaultCheckInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				m.checkVault()
+			case <-m.stopContainerMonitor:
+				return
+			}
+		}
+	}()
+}
+
+// checkVault refreshes the Vault status, unsealing and migrating when possible
+func (m *MonitoringController) checkVault() {
+	status := models.GetVaultStatus()
+
+	if status.Sealed {
+		if unsealed, err := models.AutoUnsealVault(); err != nil {
+			log.Printf("Vault: Auto-unseal failed: %v", err)
+		} else if unsealed {
+			models.LogActivity("vault_unsealed", "Vault auto-unsealed",
+				"Vault was sealed and has been unsealed with the configured keys", "", "", "integration", "")
+			status = models.GetVaultStatus()
+		}
+	}
+
+	if status.Available && status.PendingMigrations > 0 {
+		if migrated, err := models.MigrateFallbackSecrets(); err != nil {
+			log.Printf("Vault: Secret migration failed: %v", err)
+		} else if migrated > 0 {
+			models.LogActivity("vault_migrated", "Migrated fallback secrets",
+				fmt.Sprintf("Moved %d secrets from the fallback store into Vault", migrated), "", "", "integration", "")
+			status = models.GetVaultStatus()
+		}
+	}
+
+	m.vaultMu.Lock()
+	previous := m.vaultStatus
+	m.vaultStatus = status
+	m.vaultMu.Unlock()
+
+	// Record transitions so outages show up in the activity feed
+	if previous == nil {
+		return
+	}
+	if previous.Available && !status.Available {
+		log.Printf("Vault: Became unavailable (sealed=%v, fallback=%v): %s", status.Sealed, status.FallbackMode, status.Error)
+		models.LogActivity("vault_unavailable", "Vault became unavailable",
+			fmt.Sprintf("Secrets storage switched to %s", status.Mode()), "", "", "integration", "")
+	} else if !previous.Available && status.Available {
+		log.Printf("Vault: Recovered")
+		models.LogActivity("vault_recovered", "Vault recovered",
+			"Vault is available again", "", "", "integration", "")
+	}
+}
+
+// getVaultPartial returns the Vault status card as HTML partial
+func (m *MonitoringController) getVaultPartial(w http.ResponseWriter, r *http.Request) {
+	m.SetRequest(r)
+	m.Render(w, r, "monitoring-vault.html", m.GetVaultStatus())
+}
+
+// unsealVault submits an unseal key share provided by an administrator
+func (m *MonitoringController) unsealVault(w http.ResponseWriter, r *http.Request) {
+	m.SetRequest(r)
+	user, _, err := m.Use("auth").(*AuthController).Authenticate(r)
+	if err != nil {
+		m.RenderError(w, r, errors.New("unauthorized"))
+		return
+	}
+
+	status, err := models.UnsealVault(r.FormValue("key"))
+	if err != nil {
+		m.RenderError(w, r, err)
+		return
+	}
+
+	log.Printf("Admin action: Vault unseal key submitted by %s (%d/%d)", user.Email, status.Progress, status.Threshold)
+	if !status.Sealed {
+		models.LogActivity("vault_unsealed", "Unsealed Vault",
+			"Administrator unsealed Vault", user.ID, "", "integration", "")
+	}
+
+	m.checkVault()
+	m.Render(w, r, "monitoring-vault.html", m.GetVaultStatus())
+}
+
+// migrateVaultSecrets moves fallback secrets into Vault on demand
+func (m *MonitoringController) migrateVaultSecrets(w http.ResponseWriter, r *http.Request) {
+	m.SetRequest(r)
+	if _, _, err := m.Use("auth").(*AuthController).Authenticate(r); err != nil {
+		m.RenderError(w, r, errors.New("unauthorized"))
+		return
+	}
+
+	if _, err := models.MigrateFallbackSecrets(); err != nil {
+		m.RenderError(w, r, err)
+		return
+	}
+
+	m.checkVault()
+	m.Render(w, r, "monitoring-vault.html", m.GetVaultStatus())
+}
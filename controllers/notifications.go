@@ -0,0 +1,94 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"workspace/models"
+
+	"github.com/The-Skyscape/devtools/pkg/application"
+)
+
+// Notifications is a factory function with the prefix and instance
+func Notifications() (string, *NotificationsController) {
+	return "notifications", &NotificationsController{}
+}
+
+// NotificationsController shows users their in-app notifications
+type NotificationsController struct {
+	application.Controller
+}
+
+// Setup registers routes
+func (c *NotificationsController) Setup(app *application.App) {
+	c.Controller.Setup(app)
+	auth := app.Use("auth").(*AuthController)
+
+	http.Handle("GET /notifications", app.Serve("notifications.html", auth.Required))
+	http.Handle("POST /notifications/read", app.ProtectFunc(c.markAllRead, auth.Required))
+	http.Handle("POST /notifications/{id}/read", app.ProtectFunc(c.markRead, auth.Required))
+}
+
+// Handle returns a new controller instance for the request
+func (c NotificationsController) Handle(req *http.Request) application.Handler {
+	c.Request = req
+	return &c
+}
+
+// Recent returns the current user's latest notifications
+func (c *NotificationsController) Recent() ([]*models.Notification, error) {
+	user := c.Use("auth").(*AuthController).CurrentUser()
+	if user == nil {
+		return nil, errors.New("authentication required")
+	}
+	return models.GetNotifications(user.ID, 50)
+}
+
+// UnreadCount returns how many notifications the current user hasn't read
+func (c *NotificationsController) UnreadCount() int {
+	user := c.Use("auth").(*AuthController).CurrentUser()
+	if user == nil {
+		return 0
+	}
+	return models.CountUnreadNotifications(user.ID)
+}
+
+// markRead handles POST /notifications/{id}/read and opens the page the
+// notification links to
+func (c *NotificationsController) markRead(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	user, _, err := c.Use("auth").(*AuthController).Authenticate(r)
+	if err != nil {
+		c.RenderError(w, r, errors.New("authentication required"))
+		return
+	}
+
+	notification, err := models.MarkNotificationRead(r.PathValue("id"), user.ID)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	if notification.URL == "" {
+		c.Refresh(w, r)
+		return
+	}
+	c.Redirect(w, r, notification.URL)
+}
+
+// markAllRead handles POST /notifications/read
+func (c *NotificationsController) markAllRead(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	user, _, err := c.Use("auth").(*AuthController).Authenticate(r)
+	if err != nil {
+		c.RenderError(w, r, errors.New("authentication required"))
+		return
+	}
+
+	if err := models.MarkAllNotificationsRead(user.ID); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	c.Refresh(w, r)
+}
@@ -0,0 +1,386 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"workspace/internal/ai"
+	"workspace/internal/backup"
+	"workspace/models"
+	"workspace/services"
+
+	"github.com/The-Skyscape/devtools/pkg/application"
+)
+
+// OnboardingController runs the first-run setup wizard
+type OnboardingController struct {
+	application.Controller
+}
+
+// SetupStep is one page of the setup wizard
+type SetupStep struct {
+	ID    string
+	Label string
+}
+
+// setupSteps are the wizard pages in the order they are shown
+var setupSteps = []SetupStep{
+	{ID: "admin", Label: "Admin Account"},
+	{ID: "theme", Label: "Theme"},
+	{ID: "ai", Label: "AI Assistant"},
+	{ID: "github", Label: "GitHub"},
+	{ID: "backup", Label: "Backups"},
+	{ID: "repo", Label: "First Repository"},
+}
+
+// setupThemes are the themes offered by the wizard, as in the settings theme menu
+var setupThemes = []string{"corporate", "light", "dark", "business", "emerald", "lofi", "cyberpunk", "valentine"}
+
+// Onboarding is the factory function for the onboarding controller
+func Onboarding() (string, *OnboardingController) {
+	return "onboarding", &OnboardingController{}
+}
+
+// Setup registers the setup wizard routes
+func (c *OnboardingController) Setup(app *application.App) {
+	c.Controller.Setup(app)
+
+	http.Handle("GET /setup", app.Serve("onboarding-wizard.html", c.setupAccess))
+	http.Handle("POST /setup/theme", app.ProtectFunc(c.saveTheme, c.setupAccess))
+	http.Handle("POST /setup/ai", app.ProtectFunc(c.saveAI, c.setupAccess))
+	http.Handle("POST /setup/github", app.ProtectFunc(c.saveGitHub, c.setupAccess))
+	http.Handle("POST /setup/backup", app.ProtectFunc(c.saveBackup, c.setupAccess))
+	http.Handle("POST /setup/repo", app.ProtectFunc(c.createRepo, c.setupAccess))
+	http.Handle("POST /setup/complete", app.ProtectFunc(c.complete, c.setupAccess))
+}
+
+// Handle prepares the controller for each request
+func (c OnboardingController) Handle(req *http.Request) application.Handler {
+	c.Request = req
+	return &c
+}
+
+// setupAccess lets anyone see the admin account step while no users exist,
+// after that the wizard is limited to administrators
+func (c *OnboardingController) setupAccess(app *application.App, w http.ResponseWriter, r *http.Request) bool {
+	if models.Auth.Users.Count("") == 0 {
+		if r.Method == http.MethodGet {
+			return true
+		}
+		c.Redirect(w, r, "/setup")
+		return false
+	}
+	return AdminOnly()(app, w, r)
+}
+
+// Steps returns the wizard pages for the progress indicator
+func (c *OnboardingController) Steps() []SetupStep {
+	return setupSteps
+}
+
+// Step returns the ID of the page being shown
+func (c *OnboardingController) Step() string {
+	if models.Auth.Users.Count("") == 0 {
+		return "admin"
+	}
+	step := c.Request.URL.Query().Get("step")
+	if step == "admin" || !slices.ContainsFunc(setupSteps, func(s SetupStep) bool { return s.ID == step }) {
+		return "theme"
+	}
+	return step
+}
+
+// StepReached reports whether the wizard has got to the given page
+func (c *OnboardingController) StepReached(id string) bool {
+	current := c.Step()
+	for _, step := range setupSteps {
+		if step.ID == id {
+			return true
+		}
+		if step.ID == current {
+			return false
+		}
+	}
+	return false
+}
+
+// Settings returns the global settings being configured
+func (c *OnboardingController) Settings() (*models.Settings, error) {
+	return models.GetSettings()
+}
+
+// Themes returns the themes to choose from
+func (c *OnboardingController) Themes() []string {
+	return setupThemes
+}
+
+// Memory returns the host memory, zero when it cannot be read
+func (c *OnboardingController) Memory() services.MemoryInfo {
+	memory, err := services.CheckMemory()
+	if err != nil {
+		log.Printf("OnboardingController: Failed to read memory: %v", err)
+	}
+	return memory
+}
+
+// ModelOptions returns the AI models that can be enabled
+func (c *OnboardingController) ModelOptions() []services.AIModelOption {
+	return services.AIModelOptions
+}
+
+// AIEnabled reports whether AI features are currently switched on
+func (c *OnboardingController) AIEnabled() bool {
+	return os.Getenv("AI_ENABLED") == "true"
+}
+
+// AILockedByEnvironment reports whether AI_ENABLED was set by the deployment
+func (c *OnboardingController) AILockedByEnvironment() bool {
+	return models.IsSetByEnvironment("AI_ENABLED")
+}
+
+// CurrentModel returns the configured AI model
+func (c *OnboardingController) CurrentModel() string {
+	return services.Ollama.GetDefaultModel()
+}
+
+// BackupDir returns where backups are currently written
+func (c *OnboardingController) BackupDir() string {
+	if backup.Scheduler == nil {
+		return backup.DefaultBackupConfig().BackupDir
+	}
+	return backup.Scheduler.BackupDir()
+}
+
+// saveTheme handles POST /setup/theme
+func (c *OnboardingController) saveTheme(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+
+	theme := r.FormValue("theme")
+	if !slices.Contains(setupThemes, theme) {
+		c.RenderError(w, r, errors.New("unknown theme"))
+		return
+	}
+
+	err := c.updateSettings(r, func(settings *models.Settings) {
+		settings.DefaultTheme = theme
+	})
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	c.App.SetTheme(theme)
+	c.Redirect(w, r, "/setup?step=ai")
+}
+
+// saveAI handles POST /setup/ai, refusing models the host lacks memory for
+func (c *OnboardingController) saveAI(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+
+	// The deployment decides, nothing to save
+	if models.IsSetByEnvironment("AI_ENABLED") {
+		c.Redirect(w, r, "/setup?step=github")
+		return
+	}
+
+	enabled := r.FormValue("ai_enabled") == "on"
+	model := r.FormValue("model")
+	if models.IsSetByEnvironment("AI_MODEL") {
+		model = os.Getenv("AI_MODEL")
+	}
+
+	if enabled {
+		idx := slices.IndexFunc(services.AIModelOptions, func(o services.AIModelOption) bool { return o.Name == model })
+		if idx < 0 && !models.IsSetByEnvironment("AI_MODEL") {
+			c.RenderError(w, r, errors.New("choose a model to enable the AI assistant"))
+			return
+		}
+		if memory, err := services.CheckMemory(); idx >= 0 && err == nil && !memory.Fits(services.AIModelOptions[idx]) {
+			option := services.AIModelOptions[idx]
+			c.RenderError(w, r, fmt.Errorf("%s needs at least %d GB of memory, this server has %d GB",
+				option.Label, option.MinMemoryGB, memory.TotalGB()))
+			return
+		}
+	}
+
+	err := c.updateSettings(r, func(settings *models.Settings) {
+		settings.AIEnabled = enabled
+		if enabled {
+			settings.AIModel = model
+		}
+		settings.ApplyEnvironment()
+	})
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	if enabled {
+		services.EnableAI(os.Getenv("AI_MODEL"))
+		ai.InitializeAISystem()
+	}
+
+	c.Redirect(w, r, "/setup?step=github")
+}
+
+// saveGitHub handles POST /setup/github
+func (c *OnboardingController) saveGitHub(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+
+	clientID := strings.TrimSpace(r.FormValue("github_client_id"))
+	clientSecret := strings.TrimSpace(r.FormValue("github_client_secret"))
+	if clientID == "" || clientSecret == "" {
+		c.RenderError(w, r, errors.New("both the client ID and client secret are required"))
+		return
+	}
+
+	err := models.StoreSecret("github/oauth_app", map[string]any{
+		"client_id":     clientID,
+		"client_secret": clientSecret,
+		"enabled":       true,
+	})
+	if err != nil {
+		c.RenderError(w, r, errors.New("Failed to store GitHub credentials"))
+		return
+	}
+
+	err = c.updateSettings(r, func(settings *models.Settings) {
+		settings.GitHubEnabled = true
+	})
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	c.Redirect(w, r, "/setup?step=backup")
+}
+
+// saveBackup handles POST /setup/backup after checking the directory is writable
+func (c *OnboardingController) saveBackup(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+
+	dir := filepath.Clean(strings.TrimSpace(r.FormValue("backup_dir")))
+	if !filepath.IsAbs(dir) {
+		c.RenderError(w, r, errors.New("backup directory must be an absolute path"))
+		return
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		c.RenderError(w, r, fmt.Errorf("cannot create backup directory: %w", err))
+		return
+	}
+	probe, err := os.CreateTemp(dir, ".write-test-*")
+	if err != nil {
+		c.RenderError(w, r, fmt.Errorf("backup directory is not writable: %w", err))
+		return
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+
+	err = c.updateSettings(r, func(settings *models.Settings) {
+		settings.BackupDir = dir
+	})
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	if backup.Scheduler != nil {
+		backup.Scheduler.SetBackupDir(dir)
+	}
+
+	c.Redirect(w, r, "/setup?step=repo")
+}
+
+// createRepo handles POST /setup/repo, creating the first repository and
+// finishing the wizard
+func (c *OnboardingController) createRepo(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	auth := c.App.Use("auth").(*AuthController)
+	user, _, err := auth.Authenticate(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	name := strings.TrimSpace(r.FormValue("name"))
+	if name == "" {
+		c.RenderError(w, r, errors.New("repository name is required"))
+		return
+	}
+	visibility := r.FormValue("visibility")
+	if visibility != "public" && visibility != "private" {
+		visibility = "private"
+	}
+
+	repo, err := models.CreateRepository(name, r.FormValue("description"), visibility, user.ID)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	// Don't fail setup if the Code Server copy can't be made
+	if err := services.Coder.CloneRepository(repo, user); err != nil {
+		log.Printf("ERROR: Failed to clone repository %s to Code Server: %v", repo.ID, err)
+	}
+
+	if err := c.finish(r); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	c.Redirect(w, r, fmt.Sprintf("/repos/%s", repo.ID))
+}
+
+// complete handles POST /setup/complete when the wizard is finished or skipped
+func (c *OnboardingController) complete(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+
+	if err := c.finish(r); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	c.Redirect(w, r, "/")
+}
+
+// finish marks the setup as completed so the wizard is no longer offered
+func (c *OnboardingController) finish(r *http.Request) error {
+	err := c.updateSettings(r, func(settings *models.Settings) {
+		settings.SetupCompleted = true
+	})
+	if err != nil {
+		return err
+	}
+
+	if user, _, err := c.App.Use("auth").(*AuthController).Authenticate(r); err == nil {
+		models.LogActivity("setup_completed", "Completed workspace setup",
+			"Administrator finished the setup wizard", user.ID, "", "settings", "")
+	}
+	return nil
+}
+
+// updateSettings applies a change to the global settings and saves it
+func (c *OnboardingController) updateSettings(r *http.Request, change func(*models.Settings)) error {
+	user, _, err := c.App.Use("auth").(*AuthController).Authenticate(r)
+	if err != nil {
+		return err
+	}
+
+	settings, err := models.GetSettings()
+	if err != nil {
+		return err
+	}
+
+	change(settings)
+	settings.LastUpdatedBy = user.Email
+	settings.LastUpdatedAt = time.Now()
+
+	return models.GlobalSettings.Update(settings)
+}
@@ -0,0 +1,463 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"workspace/models"
+
+	"github.com/The-Skyscape/devtools/pkg/application"
+)
+
+// Projects is a factory function with the prefix and instance
+func Projects() (string, *ProjectsController) {
+	return "projects", &ProjectsController{}
+}
+
+// ProjectsController handles kanban boards of repositories and of the
+// whole workspace
+type ProjectsController struct {
+	application.Controller
+}
+
+// ProjectRuleOption is an event a board can react to, with the column it
+// moves cards to
+type ProjectRuleOption struct {
+	Event    string
+	Name     string
+	ColumnID string
+}
+
+// Setup registers routes
+func (c *ProjectsController) Setup(app *application.App) {
+	c.Controller.Setup(app)
+	auth := app.Use("auth").(*AuthController)
+
+	http.Handle("GET /projects", app.Serve("projects.html", auth.Required))
+	http.Handle("GET /projects/{projectID}", app.Serve("project.html", auth.Required))
+	http.Handle("GET /repos/{id}/projects", app.Serve("repo-projects.html", PublicRepoOnly()))
+
+	http.Handle("POST /projects/create", app.ProtectFunc(c.createProject, AdminOnly()))
+	http.Handle("POST /projects/{projectID}/edit", app.ProtectFunc(c.editProject, AdminOnly()))
+	http.Handle("POST /projects/{projectID}/delete", app.ProtectFunc(c.deleteProject, AdminOnly()))
+	http.Handle("POST /projects/{projectID}/columns/create", app.ProtectFunc(c.createColumn, AdminOnly()))
+	http.Handle("POST /projects/{projectID}/columns/{columnID}/edit", app.ProtectFunc(c.editColumn, AdminOnly()))
+	http.Handle("POST /projects/{projectID}/columns/{columnID}/shift", app.ProtectFunc(c.shiftColumn, AdminOnly()))
+	http.Handle("POST /projects/{projectID}/columns/{columnID}/delete", app.ProtectFunc(c.deleteColumn, AdminOnly()))
+	http.Handle("POST /projects/{projectID}/cards/create", app.ProtectFunc(c.createCard, AdminOnly()))
+	http.Handle("POST /projects/{projectID}/cards/{cardID}/move", app.ProtectFunc(c.moveCard, AdminOnly()))
+	http.Handle("POST /projects/{projectID}/cards/{cardID}/delete", app.ProtectFunc(c.deleteCard, AdminOnly()))
+}
+
+// Handle returns a new controller instance for the request
+func (c ProjectsController) Handle(req *http.Request) application.Handler {
+	c.Request = req
+	return &c
+}
+
+// IsAdmin returns true if the current user can manage boards
+func (c *ProjectsController) IsAdmin() bool {
+	user := c.Use("auth").(*AuthController).CurrentUser()
+	return user != nil && user.IsAdmin
+}
+
+// WorkspaceProjects returns the boards spanning repositories
+func (c *ProjectsController) WorkspaceProjects() ([]*models.Project, error) {
+	return models.GetProjects("")
+}
+
+// RepoProjects returns the boards of the repository in the URL, or of
+// every repository the user can see on the projects page
+func (c *ProjectsController) RepoProjects() ([]*models.Project, error) {
+	if repoID := c.Request.PathValue("id"); repoID != "" {
+		return models.GetProjects(repoID)
+	}
+
+	projects, err := models.Projects.Search("WHERE RepoID != '' ORDER BY Name")
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(projects, func(p *models.Project) bool {
+		return !c.canSee(p.RepoID)
+	}), nil
+}
+
+// Repositories returns the repositories new boards can plan
+func (c *ProjectsController) Repositories() ([]*models.Repository, error) {
+	return models.Repositories.Search("ORDER BY Name")
+}
+
+// CurrentProject returns the board in the URL
+func (c *ProjectsController) CurrentProject() (*models.Project, error) {
+	project, err := models.Projects.Get(c.Request.PathValue("projectID"))
+	if err != nil || !c.canSee(project.RepoID) {
+		return nil, errors.New("board not found")
+	}
+	return project, nil
+}
+
+// SpansRepositories returns true if the current board plans work across
+// repositories
+func (c *ProjectsController) SpansRepositories() bool {
+	project, err := c.CurrentProject()
+	return err == nil && project.RepoID == ""
+}
+
+// Cards returns the cards of a column in one swimlane of the current
+// board, leaving out those from repositories the user can't see
+func (c *ProjectsController) Cards(column *models.ProjectColumn, lane string) []*models.ProjectCard {
+	project, err := c.CurrentProject()
+	if err != nil {
+		return nil
+	}
+	cards, err := column.LaneCards(project.Swimlanes, lane)
+	if err != nil {
+		return nil
+	}
+	return slices.DeleteFunc(cards, func(card *models.ProjectCard) bool {
+		return !c.canSee(card.RepoID)
+	})
+}
+
+// Rules returns the events the current board can react to with the
+// columns they move cards to
+func (c *ProjectsController) Rules() []ProjectRuleOption {
+	project, err := c.CurrentProject()
+	if err != nil {
+		return nil
+	}
+	var options []ProjectRuleOption
+	for _, event := range models.ProjectEvents {
+		options = append(options, ProjectRuleOption{
+			Event:    event,
+			Name:     models.ProjectEventNames[event],
+			ColumnID: project.RuleColumn(event),
+		})
+	}
+	return options
+}
+
+// OpenIssues returns the open issues the current board has no card for
+func (c *ProjectsController) OpenIssues() []*models.Issue {
+	project, err := c.CurrentProject()
+	if err != nil {
+		return nil
+	}
+
+	query, args := "WHERE Status = 'open' ORDER BY CreatedAt DESC LIMIT 100", []any{}
+	if project.RepoID != "" {
+		query, args = "WHERE RepoID = ? AND Status = 'open' ORDER BY CreatedAt DESC LIMIT 100", []any{project.RepoID}
+	}
+	issues, err := models.Issues.Search(query, args...)
+	if err != nil {
+		return nil
+	}
+	return slices.DeleteFunc(issues, func(issue *models.Issue) bool {
+		return project.Tracks(issue.ID, "")
+	})
+}
+
+// OpenPullRequests returns the open pull requests the current board has
+// no card for
+func (c *ProjectsController) OpenPullRequests() []*models.PullRequest {
+	project, err := c.CurrentProject()
+	if err != nil {
+		return nil
+	}
+
+	query, args := "WHERE Status = 'open' ORDER BY CreatedAt DESC LIMIT 100", []any{}
+	if project.RepoID != "" {
+		query, args = "WHERE RepoID = ? AND Status = 'open' ORDER BY CreatedAt DESC LIMIT 100", []any{project.RepoID}
+	}
+	prs, err := models.PullRequests.Search(query, args...)
+	if err != nil {
+		return nil
+	}
+	return slices.DeleteFunc(prs, func(pr *models.PullRequest) bool {
+		return project.Tracks("", pr.ID)
+	})
+}
+
+// canSee returns true if the user can see a repository's boards and cards.
+// Boards and notes without a repository are visible to everyone signed in.
+func (c *ProjectsController) canSee(repoID string) bool {
+	if repoID == "" {
+		return true
+	}
+	repo, err := models.Repositories.Get(repoID)
+	if err != nil {
+		return false
+	}
+	return models.RepoRole(c.Use("auth").(*AuthController).CurrentUser(), repo) != ""
+}
+
+// createProject handles POST /projects/create
+func (c *ProjectsController) createProject(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	// Admin already verified by route middleware
+	user := c.Use("auth").(*AuthController).CurrentUser()
+
+	project, err := models.CreateProject(r.FormValue("repo_id"), r.FormValue("name"), r.FormValue("description"), user.ID)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	if project.RepoID != "" {
+		models.LogActivity("project_created", "Created board: "+project.Name,
+			"New project board", user.ID, project.RepoID, "project", project.ID)
+	}
+
+	c.Redirect(w, r, "/projects/"+project.ID)
+}
+
+// editProject handles POST /projects/{projectID}/edit, saving the board's
+// details and automation rules
+func (c *ProjectsController) editProject(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+
+	project, err := c.CurrentProject()
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	if err := project.Edit(r.FormValue("name"), r.FormValue("description"), r.FormValue("swimlanes")); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+	for _, event := range models.ProjectEvents {
+		if err := project.SetRule(event, r.FormValue("rule_"+event)); err != nil {
+			c.RenderError(w, r, fmt.Errorf("failed to save rule: %w", err))
+			return
+		}
+	}
+
+	c.Refresh(w, r)
+}
+
+// deleteProject handles POST /projects/{projectID}/delete
+func (c *ProjectsController) deleteProject(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	// Admin already verified by route middleware
+	user := c.Use("auth").(*AuthController).CurrentUser()
+
+	project, err := c.CurrentProject()
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	if err := models.DeleteProject(project); err != nil {
+		c.RenderError(w, r, fmt.Errorf("failed to delete board: %w", err))
+		return
+	}
+
+	if project.RepoID == "" {
+		c.Redirect(w, r, "/projects")
+		return
+	}
+	models.LogActivity("project_deleted", "Deleted board: "+project.Name,
+		"Project board removed", user.ID, project.RepoID, "project", project.ID)
+	c.Redirect(w, r, "/repos/"+project.RepoID+"/projects")
+}
+
+// createColumn handles POST /projects/{projectID}/columns/create
+func (c *ProjectsController) createColumn(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+
+	project, err := c.CurrentProject()
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	limit, err := parseWIPLimit(r.FormValue("wip_limit"))
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	if _, err := project.AddColumn(r.FormValue("name"), limit); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	c.Refresh(w, r)
+}
+
+// editColumn handles POST /projects/{projectID}/columns/{columnID}/edit
+func (c *ProjectsController) editColumn(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+
+	column, err := c.projectColumn(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	limit, err := parseWIPLimit(r.FormValue("wip_limit"))
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	if err := column.Edit(r.FormValue("name"), limit); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	c.Refresh(w, r)
+}
+
+// shiftColumn handles POST /projects/{projectID}/columns/{columnID}/shift,
+// moving the column left or right
+func (c *ProjectsController) shiftColumn(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+
+	column, err := c.projectColumn(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	direction := 1
+	if r.FormValue("direction") == "left" {
+		direction = -1
+	}
+	if err := column.Shift(direction); err != nil {
+		c.RenderError(w, r, fmt.Errorf("failed to move column: %w", err))
+		return
+	}
+
+	c.Refresh(w, r)
+}
+
+// deleteColumn handles POST /projects/{projectID}/columns/{columnID}/delete
+func (c *ProjectsController) deleteColumn(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+
+	column, err := c.projectColumn(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	if err := models.DeleteProjectColumn(column); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	c.Refresh(w, r)
+}
+
+// createCard handles POST /projects/{projectID}/cards/create. Cards track
+// an issue, a pull request, or hold a note.
+func (c *ProjectsController) createCard(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	// Admin already verified by route middleware
+	user := c.Use("auth").(*AuthController).CurrentUser()
+
+	project, err := c.CurrentProject()
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	card := &models.ProjectCard{
+		ProjectID: project.ID,
+		Column:    r.FormValue("column_id"),
+		Title:     r.FormValue("title"),
+		Note:      strings.TrimSpace(r.FormValue("note")),
+		CreatedBy: user.ID,
+	}
+	if item := r.FormValue("item"); item != "" {
+		kind, id, _ := strings.Cut(item, ":")
+		if kind == "pr" {
+			card.PullRequestID = id
+		} else {
+			card.IssueID = id
+		}
+	}
+
+	if _, err := models.CreateProjectCard(card); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	c.Refresh(w, r)
+}
+
+// moveCard handles POST /projects/{projectID}/cards/{cardID}/move, placing
+// a dragged card in a column before another card, or at the column's end
+func (c *ProjectsController) moveCard(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+
+	card, err := c.projectCard(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	if err := card.Move(r.FormValue("column_id"), r.FormValue("before")); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	c.Refresh(w, r)
+}
+
+// deleteCard handles POST /projects/{projectID}/cards/{cardID}/delete
+func (c *ProjectsController) deleteCard(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+
+	card, err := c.projectCard(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	if err := models.ProjectCards.Delete(card); err != nil {
+		c.RenderError(w, r, fmt.Errorf("failed to delete card: %w", err))
+		return
+	}
+
+	c.Refresh(w, r)
+}
+
+// projectColumn loads the column in the URL, checking it's on the board
+// in the URL
+func (c *ProjectsController) projectColumn(r *http.Request) (*models.ProjectColumn, error) {
+	column, err := models.ProjectColumns.Get(r.PathValue("columnID"))
+	if err != nil || column.ProjectID != r.PathValue("projectID") {
+		return nil, errors.New("column not found")
+	}
+	return column, nil
+}
+
+// projectCard loads the card in the URL, checking it's on the board in
+// the URL
+func (c *ProjectsController) projectCard(r *http.Request) (*models.ProjectCard, error) {
+	card, err := models.ProjectCards.Get(r.PathValue("cardID"))
+	if err != nil || card.ProjectID != r.PathValue("projectID") {
+		return nil, errors.New("card not found")
+	}
+	return card, nil
+}
+
+// parseWIPLimit reads a column's WIP limit, empty meaning no limit
+func parseWIPLimit(value string) (int, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 0 {
+		return 0, errors.New("WIP limit must be a whole number, 0 for no limit")
+	}
+	return limit, nil
+}
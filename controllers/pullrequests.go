@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"strings"
+	"time"
 
 	"workspace/internal/ai"
 	"workspace/internal/github"
@@ -13,6 +15,7 @@ import (
 	"workspace/services"
 
 	"github.com/The-Skyscape/devtools/pkg/application"
+	"github.com/The-Skyscape/devtools/pkg/authentication"
 )
 
 // PullRequests controller prefix
@@ -46,11 +49,45 @@ func (c *PullRequestsController) Setup(app *application.App) {
 	http.Handle("POST /repos/{id}/prs/create", app.ProtectFunc(c.createPR, PublicRepoOnly()))
 	http.Handle("POST /repos/{id}/prs/{prID}/comment", app.ProtectFunc(c.createPRComment, PublicRepoOnly()))
 
-	// PR merge - admin only
-	http.Handle("POST /repos/{id}/prs/{prID}/merge", app.ProtectFunc(c.mergePR, AdminOnly()))
+	// Read state - per user
+	http.Handle("POST /repos/{id}/prs/{prID}/read", app.ProtectFunc(c.markPRRead, auth.Required))
+	http.Handle("POST /repos/{id}/prs/{prID}/unread", app.ProtectFunc(c.markPRUnread, auth.Required))
+
+	// PR merge - write access, as merging pushes to the base branch
+	http.Handle("POST /repos/{id}/prs/{prID}/merge", app.ProtectFunc(c.mergePR, RepoAccess(models.RoleWrite)))
+
+	// Conflict resolution commits to the PR branch - admin only, like pushes
+	http.Handle("GET /repos/{id}/prs/{prID}/conflicts", app.Serve("repo-pr-conflicts.html", AdminOnly()))
+	http.Handle("POST /repos/{id}/prs/{prID}/conflicts", app.ProtectFunc(c.resolveConflicts, AdminOnly()))
 
 	// PR close - author or admin
 	http.Handle("POST /repos/{id}/prs/{prID}/close", app.ProtectFunc(c.closePR, auth.Required))
+
+	// Review requests - author or admin
+	http.Handle("POST /repos/{id}/prs/{prID}/reviewers", app.ProtectFunc(c.requestReview, PublicRepoOnly()))
+
+	// Reviews - anyone but the author approves or requests changes; approvals count toward protected branches
+	http.Handle("POST /repos/{id}/prs/{prID}/approve", app.ProtectFunc(c.approvePR, PublicRepoOnly()))
+	http.Handle("POST /repos/{id}/prs/{prID}/reviews", app.ProtectFunc(c.reviewPR, PublicRepoOnly()))
+
+	// Inline review comments - resolving is for the author, the thread's author, or an admin
+	http.Handle("POST /repos/{id}/prs/{prID}/review-comments", app.ProtectFunc(c.createReviewComment, PublicRepoOnly()))
+	http.Handle("POST /repos/{id}/prs/{prID}/review-comments/{commentID}/replies", app.ProtectFunc(c.replyToReviewComment, PublicRepoOnly()))
+	http.Handle("POST /repos/{id}/prs/{prID}/review-comments/{commentID}/resolve", app.ProtectFunc(c.resolveReviewThread, PublicRepoOnly()))
+	http.Handle("POST /repos/{id}/prs/{prID}/review-comments/{commentID}/unresolve", app.ProtectFunc(c.unresolveReviewThread, PublicRepoOnly()))
+
+	// Applying a suggestion commits to the PR branch - author or admin
+	http.Handle("POST /repos/{id}/prs/{prID}/review-comments/{commentID}/apply", app.ProtectFunc(c.applySuggestion, auth.Required))
+
+	// Ready for review - author or admin
+	http.Handle("POST /repos/{id}/prs/{prID}/ready", app.ProtectFunc(c.readyPR, auth.Required))
+
+	// Review latency report and SLA - admin only
+	http.Handle("GET /settings/reviews", app.Serve("settings-reviews.html", AdminOnly()))
+	http.Handle("POST /settings/reviews", app.ProtectFunc(c.updateReviewSettings, AdminOnly()))
+
+	// Remind reviewers about requests past the SLA
+	c.startReviewReminders()
 }
 
 // CurrentRepo returns the current repository from the request
@@ -67,29 +104,11 @@ func (c *PullRequestsController) RepoPullRequests() ([]*models.PullRequest, erro
 		return nil, err
 	}
 
-	// Get search query from request
-	searchQuery := c.Request.URL.Query().Get("search")
-	includeClosed := c.Request.URL.Query().Get("includeClosed") == "true"
-
-	// Build search condition
-	condition := "WHERE RepoID = ?"
-	args := []any{repo.ID}
-
-	// Add status filter
-	if !includeClosed {
-		condition += " AND Status = ?"
-		args = append(args, "open")
-	}
-
-	// Add search filter if provided
-	if searchQuery != "" {
-		condition += " AND (Title LIKE ? OR Body LIKE ?)"
-		searchPattern := "%" + searchQuery + "%"
-		args = append(args, searchPattern, searchPattern)
-	}
+	// Filter by the request, or the saved view it names
+	condition, args := models.ListCondition(models.ViewPullRequests, repo.ID, c.viewerID(), c.filters())
 
-	// Add ordering and limit for initial load
-	condition += " ORDER BY CreatedAt DESC LIMIT 20"
+	// Add limit for initial load
+	condition += " LIMIT 20"
 
 	// Search pull requests
 	prs, err := models.PullRequests.Search(condition, args...)
@@ -115,11 +134,8 @@ func (c *PullRequestsController) MorePRs() ([]*models.PullRequest, error) {
 		fmt.Sscanf(offsetStr, "%d", &offset)
 	}
 
-	// Get filter options
-	includeClosed := c.Request.URL.Query().Get("includeClosed") == "true"
-
 	// Get next batch of PRs
-	prs, _, err := models.GetRepoPRsPaginated(repo.ID, includeClosed, 20, offset)
+	prs, _, err := c.prsPage(repo.ID, offset)
 	return prs, err
 }
 
@@ -137,8 +153,7 @@ func (c *PullRequestsController) HasMorePRs() bool {
 		fmt.Sscanf(offsetStr, "%d", &offset)
 	}
 
-	includeClosed := c.Request.URL.Query().Get("includeClosed") == "true"
-	prs, total, err := models.GetRepoPRsPaginated(repo.ID, includeClosed, 20, offset)
+	prs, total, err := c.prsPage(repo.ID, offset)
 	if err != nil {
 		return false
 	}
@@ -146,6 +161,68 @@ func (c *PullRequestsController) HasMorePRs() bool {
 	return (offset + len(prs)) < total
 }
 
+// prsPage returns a page of 20 pull requests matching the list's filters
+func (c *PullRequestsController) prsPage(repoID string, offset int) ([]*models.PullRequest, int, error) {
+	condition, args := models.ListCondition(models.ViewPullRequests, repoID, c.viewerID(), c.filters())
+	return models.PullRequests.SearchPaginated(condition, 20, offset, args...)
+}
+
+// filters returns the list filters of the request, or of the saved view
+// it names
+func (c *PullRequestsController) filters() url.Values {
+	return models.ApplyView(c.Request.URL.Query(), c.viewerID())
+}
+
+// viewerID returns the signed-in user's ID, empty for guests
+func (c *PullRequestsController) viewerID() string {
+	if user := c.currentUser(); user != nil {
+		return user.ID
+	}
+	return ""
+}
+
+// UnreadOnly returns whether only pull requests the user hasn't read are
+// listed
+func (c *PullRequestsController) UnreadOnly() bool {
+	return c.filters().Get("unread") == "true"
+}
+
+// ReviewerFilter returns who pull requests are filtered by review requests
+// to: a user ID, "me" or "none"
+func (c *PullRequestsController) ReviewerFilter() string {
+	return c.filters().Get("reviewer")
+}
+
+// SortOrder returns how pull requests are ordered
+func (c *PullRequestsController) SortOrder() string {
+	return c.filters().Get("sort")
+}
+
+// FilterQuery returns the list's filters as a query string, for loading
+// more pull requests with them
+func (c *PullRequestsController) FilterQuery() string {
+	return models.ViewQuery(models.ViewPullRequests, c.filters())
+}
+
+// Reviewers returns the users pull requests can be filtered by
+func (c *PullRequestsController) Reviewers() ([]*authentication.User, error) {
+	return models.Auth.Users.Search("ORDER BY Name ASC")
+}
+
+// Unread returns which of the pull requests the current user hasn't read
+func (c *PullRequestsController) Unread(prs []*models.PullRequest) map[string]bool {
+	user := c.currentUser()
+	if user == nil {
+		return map[string]bool{}
+	}
+	return models.UnreadPullRequests(user.ID, prs)
+}
+
+// currentUser returns the signed-in user, or nil
+func (c *PullRequestsController) currentUser() *authentication.User {
+	return c.Use("auth").(*AuthController).CurrentUser()
+}
+
 // NextPRsOffset returns the offset for the next page of PRs
 func (c *PullRequestsController) NextPRsOffset() int {
 	offsetStr := c.Request.URL.Query().Get("offset")
@@ -165,6 +242,30 @@ func (c *PullRequestsController) CurrentPullRequest() (*models.PullRequest, erro
 	return models.PullRequests.Get(prID)
 }
 
+// CanMerge returns true if the current user can merge pull requests into
+// the current repository
+func (c *PullRequestsController) CanMerge() bool {
+	user := c.currentUser()
+	if user == nil || c.Request == nil {
+		return false
+	}
+	return models.CheckRepoAccess(user, c.Request.PathValue("id"), models.RoleWrite) == nil
+}
+
+// Mentions returns where the current pull request was mentioned, newest
+// first
+func (c *PullRequestsController) Mentions() []*models.CrossReference {
+	pr, err := c.CurrentPullRequest()
+	if err != nil {
+		return nil
+	}
+	refs, err := models.GetReferencesTo(models.RefPullRequest, pr.ID)
+	if err != nil {
+		return nil
+	}
+	return refs
+}
+
 // PRComments returns comments for the current pull request
 func (c *PullRequestsController) PRComments() ([]*models.Comment, error) {
 	pr, err := c.CurrentPullRequest()
@@ -218,12 +319,12 @@ func (c *PullRequestsController) RepoPRDiffContent() (string, error) {
 
 // SearchQuery returns the current search query
 func (c *PullRequestsController) SearchQuery() string {
-	return c.Request.URL.Query().Get("search")
+	return c.filters().Get("search")
 }
 
 // IncludeClosed returns whether closed PRs should be included
 func (c *PullRequestsController) IncludeClosed() bool {
-	return c.Request.URL.Query().Get("includeClosed") == "true"
+	return c.filters().Get("includeClosed") == "true"
 }
 
 // RepoBranches returns branches for the current repository via repos controller
@@ -289,6 +390,7 @@ func (c *PullRequestsController) createPR(w http.ResponseWriter, r *http.Request
 		BaseBranch:    baseBranch,
 		CompareBranch: compareBranch,
 		Status:        "open",
+		Draft:         r.FormValue("draft") == "on",
 		SyncDirection: "push", // Default to push for new PRs
 	}
 
@@ -298,9 +400,20 @@ func (c *PullRequestsController) createPR(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	models.MarkRead(user.ID, models.ReadPullRequest, pr.ID, repoID)
+	models.RecordReferences(repoID, models.RefPullRequest, pr.ID, pr.Title+"\n"+pr.Body, user.ID)
+	models.RecordMentions(repoID, models.RefPullRequest, pr.ID, pr.Title+"\n"+pr.Body, user.ID)
+
+	// Note up front whether the branches merge cleanly
+	if _, err := models.CheckMerge(pr); err != nil {
+		log.Printf("Failed to check merge of pull request %s: %v", pr.ID, err)
+	}
+
 	// Log activity
 	models.LogActivity("pr_created", "Created pull request: "+pr.Title,
 		"New pull request opened", user.ID, repoID, "pull_request", pr.ID)
+	services.EmitPullRequestWebhook("opened", pr, user.ID)
+	models.RunProjectRules("pr_opened", repoID, "", pr.ID)
 
 	// Trigger AI event for PR review if AI is enabled
 	if services.Ollama.IsRunning() {
@@ -332,6 +445,7 @@ func (c *PullRequestsController) createPR(w http.ResponseWriter, r *http.Request
 		"AUTHOR_ID":      user.ID,
 	}
 	go services.TriggerActionsByEvent("on_pr", repoID, eventData)
+	go services.Workflows.PullRequestOpened(pr, user.ID)
 
 	// Queue AI task for PR review if AI is enabled
 	// Trigger AI PR review if enabled
@@ -370,14 +484,14 @@ func (c *PullRequestsController) mergePR(w http.ResponseWriter, r *http.Request)
 
 	// Get PR first
 	pr, err := models.PullRequests.Get(prID)
-	if err != nil {
+	if err != nil || pr.RepoID != repoID {
 		c.RenderError(w, r, errors.New("pull request not found"))
 		return
 	}
 
-	// Only admins can merge PRs
-	if !user.IsAdmin {
-		c.RenderError(w, r, errors.New("only admins can merge pull requests"))
+	// Merging pushes to the base branch, so it takes write access
+	if err := models.CheckRepoAccess(user, repoID, models.RoleWrite); err != nil {
+		c.RenderError(w, r, errors.New("write access is required to merge pull requests"))
 		return
 	}
 
@@ -402,10 +516,32 @@ func (c *PullRequestsController) mergePR(w http.ResponseWriter, r *http.Request)
 	}
 
 	if !canMerge {
-		c.RenderError(w, r, errors.New("merge conflicts detected - cannot auto-merge"))
+		conflicts, _ := repo.MergeConflicts(pr.BaseBranch, pr.CompareBranch)
+		if len(conflicts) == 0 {
+			c.RenderError(w, r, errors.New("merge conflicts detected - cannot auto-merge"))
+			return
+		}
+		c.RenderError(w, r, fmt.Errorf("merge conflicts in %s - resolve them before merging", strings.Join(conflicts, ", ")))
+		return
+	}
+
+	// Drafts, requested changes and protected base branches hold the merge
+	blockers, err := pr.MergeBlockers()
+	if err != nil {
+		c.RenderError(w, r, fmt.Errorf("failed to check reviews and branch protection: %w", err))
+		return
+	}
+	if len(blockers) > 0 {
+		c.RenderError(w, r, fmt.Errorf("pull request can't be merged yet: %s", strings.Join(blockers, "; ")))
 		return
 	}
 
+	// The commits the merge brings in, for the issues they close
+	commits, err := repo.GetCommitsBetween(pr.BaseBranch, pr.CompareBranch)
+	if err != nil {
+		log.Printf("Failed to list commits of pull request %s: %v", pr.ID, err)
+	}
+
 	// Perform the actual git merge
 	mergeMessage := fmt.Sprintf("Merge pull request #%s: %s", prID, pr.Title)
 	err = repo.MergeBranch(pr.CompareBranch, pr.BaseBranch, mergeMessage, user.Name, user.Email)
@@ -416,15 +552,28 @@ func (c *PullRequestsController) mergePR(w http.ResponseWriter, r *http.Request)
 
 	// Update PR status
 	pr.Status = "merged"
+	pr.MergedAt = time.Now()
+	pr.MergedBy = user.ID
 	err = models.PullRequests.Update(pr)
 	if err != nil {
 		c.RenderError(w, r, errors.New("failed to update pull request status"))
 		return
 	}
+	models.WithdrawReviewRequests(pr.ID)
+
+	// Other pull requests into the base branch may now be behind or conflict
+	go models.RefreshMergeChecks(repoID)
 
 	// Log activity
 	models.LogActivity("pr_merged", "Merged pull request: "+pr.Title,
 		"Pull request merged", user.ID, repoID, "pull_request", pr.ID)
+	services.EmitPullRequestWebhook("merged", pr, user.ID)
+	models.RunProjectRules("pr_merged", pr.RepoID, "", pr.ID)
+
+	// Close the issues the pull request fixes, as in "fixes #12"
+	for _, issue := range models.CloseIssuesFixedBy(pr, commits, user.ID) {
+		services.EmitIssueWebhook("closed", issue, user.ID)
+	}
 
 	// Sync merge to GitHub if repo has GitHub integration
 	if repo.GitHubURL != "" {
@@ -446,6 +595,11 @@ func (c *PullRequestsController) mergePR(w http.ResponseWriter, r *http.Request)
 		"EVENT_TYPE":     "merge",
 	}
 	go services.TriggerActionsByEvent("on_push", repoID, eventData)
+	go func() {
+		if head, err := repo.ResolveCommit("refs/heads/" + pr.BaseBranch); err == nil {
+			services.Workflows.Pushed(repo, pr.BaseBranch, head, user.ID)
+		}
+	}()
 
 	c.Refresh(w, r)
 }
@@ -470,7 +624,7 @@ func (c *PullRequestsController) closePR(w http.ResponseWriter, r *http.Request)
 
 	// Get and update PR
 	pr, err := models.PullRequests.Get(prID)
-	if err != nil {
+	if err != nil || pr.RepoID != repoID {
 		c.RenderError(w, r, errors.New("pull request not found"))
 		return
 	}
@@ -487,10 +641,13 @@ func (c *PullRequestsController) closePR(w http.ResponseWriter, r *http.Request)
 		c.RenderError(w, r, errors.New("failed to close pull request"))
 		return
 	}
+	models.WithdrawReviewRequests(pr.ID)
 
 	// Log activity
 	models.LogActivity("pr_closed", "Closed pull request: "+pr.Title,
 		"Pull request closed", user.ID, repoID, "pull_request", pr.ID)
+	services.EmitPullRequestWebhook("closed", pr, user.ID)
+	models.RunProjectRules("pr_closed", pr.RepoID, "", pr.ID)
 
 	// Sync close to GitHub if repo has GitHub integration
 	repo, _ := models.Repositories.Get(repoID)
@@ -514,6 +671,12 @@ func (c *PullRequestsController) createPRComment(w http.ResponseWriter, r *http.
 	auth := c.Use("auth").(*AuthController)
 	user, _, _ := auth.Authenticate(r)
 
+	files, err := attachmentFiles(w, r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
 	repoID := r.PathValue("id")
 	prID := r.PathValue("prID")
 	body := strings.TrimSpace(r.FormValue("body"))
@@ -523,23 +686,79 @@ func (c *PullRequestsController) createPRComment(w http.ResponseWriter, r *http.
 		return
 	}
 
-	// Verify PR exists
+	// Verify PR exists in this repository
 	pr, err := models.PullRequests.Get(prID)
-	if err != nil {
+	if err != nil || pr.RepoID != repoID {
 		c.RenderError(w, r, errors.New("pull request not found"))
 		return
 	}
 
 	// Create comment
-	_, err = models.CreatePRComment(prID, repoID, user.ID, body)
+	comment, err := models.CreatePRComment(prID, repoID, user.ID, body)
 	if err != nil {
 		c.RenderError(w, r, errors.New("failed to create comment"))
 		return
 	}
 
+	models.MarkRead(user.ID, models.ReadPullRequest, prID, repoID)
+	models.RecordReferences(repoID, models.RefComment, comment.ID, comment.Body, user.ID)
+	models.RecordMentions(repoID, models.RefComment, comment.ID, comment.Body, user.ID)
+
 	// Log activity
 	models.LogActivity("comment_created", "Commented on PR: "+pr.Title,
 		"New comment added", user.ID, repoID, "pr_comment", prID)
+	services.EmitCommentWebhook(comment, pr.Title)
+
+	// Mirror the comment to GitHub when the repository syncs comments both ways
+	go func() {
+		syncService := &github.GitHubSyncService{}
+		if err := syncService.PushComment(comment.ID, user.ID); err != nil {
+			log.Printf("Failed to push comment to GitHub: %v", err)
+		}
+	}()
+
+	// A comment from a requested reviewer completes their review request
+	if err := models.RecordReview(pr, user.ID); err != nil {
+		log.Printf("Failed to record review: %v", err)
+	}
+
+	if rejected := saveAttachments(files, repoID, "comment", comment.ID, user.ID); len(rejected) > 0 {
+		c.RenderError(w, r, fmt.Errorf("comment posted, but some files were not attached: %s", strings.Join(rejected, "; ")))
+		return
+	}
 
 	c.Refresh(w, r)
 }
+
+// markPRRead handles POST /repos/{id}/prs/{prID}/read, sent when the pull
+// request page has loaded
+func (c *PullRequestsController) markPRRead(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	user := c.currentUser()
+	if user == nil {
+		c.RenderError(w, r, errors.New("authentication required"))
+		return
+	}
+
+	if err := models.MarkRead(user.ID, models.ReadPullRequest, r.PathValue("prID"), r.PathValue("id")); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// markPRUnread handles POST /repos/{id}/prs/{prID}/unread
+func (c *PullRequestsController) markPRUnread(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	user := c.currentUser()
+	if user == nil {
+		c.RenderError(w, r, errors.New("authentication required"))
+		return
+	}
+
+	if err := models.MarkUnread(user.ID, models.ReadPullRequest, r.PathValue("prID")); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+	c.Redirect(w, r, "/repos/"+r.PathValue("id")+"/prs")
+}
@@ -0,0 +1,110 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"workspace/models"
+)
+
+// PRMergeCheck returns whether the current pull request merges cleanly,
+// and how far its branch is ahead of and behind the base branch
+func (c *PullRequestsController) PRMergeCheck() *models.MergeCheck {
+	pr, err := c.CurrentPullRequest()
+	if err != nil || pr.Status != "open" {
+		return nil
+	}
+	check, err := pr.MergeCheck()
+	if err != nil {
+		return nil
+	}
+	return check
+}
+
+// PRConflictFiles returns the files that conflict when merging the current
+// pull request, split into hunks for the editor
+func (c *PullRequestsController) PRConflictFiles() ([]*models.ConflictFile, error) {
+	pr, err := c.CurrentPullRequest()
+	if err != nil {
+		return nil, err
+	}
+	return pr.ConflictFiles()
+}
+
+// resolveConflicts handles POST /repos/{id}/prs/{prID}/conflicts, committing
+// the hunks picked in the editor as a merge of the base branch into the
+// pull request's branch
+func (c *PullRequestsController) resolveConflicts(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	repo, err := c.Use("repos").(*ReposController).getCurrentRepoFromRequest(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	// Admin already verified by route middleware
+	user, _, err := c.Use("auth").(*AuthController).Authenticate(r)
+	if err != nil {
+		c.RenderError(w, r, errors.New("authentication required"))
+		return
+	}
+
+	pr, err := models.PullRequests.Get(r.PathValue("prID"))
+	if err != nil || pr.RepoID != repo.ID {
+		c.RenderError(w, r, errors.New("pull request not found"))
+		return
+	}
+	if pr.Status != "open" {
+		c.RenderError(w, r, errors.New("pull request is not open"))
+		return
+	}
+
+	// The hunks on the page only line up with the branch they were loaded from
+	head := r.FormValue("head")
+	if current, _ := pr.HeadCommit(); current != head {
+		c.RenderError(w, r, fmt.Errorf("%s has changed since the conflicts were loaded, reload and resolve them again", pr.CompareBranch))
+		return
+	}
+
+	files, err := pr.ConflictFiles()
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+	resolved := make(map[string]string, len(files))
+	for i, file := range files {
+		picks := make([]models.ConflictPick, file.Hunks)
+		for hunk := range picks {
+			picks[hunk] = models.ConflictPick{
+				Keep:   r.FormValue(fmt.Sprintf("pick-%d-%d", i, hunk)),
+				Custom: r.FormValue(fmt.Sprintf("custom-%d-%d", i, hunk)),
+			}
+		}
+		content, err := file.Resolve(picks)
+		if err != nil {
+			c.RenderError(w, r, err)
+			return
+		}
+		resolved[file.Path] = content
+	}
+
+	before := branchHeads(repo)
+	if _, err := models.ResolveConflicts(pr, head, resolved, user.Name, user.Email); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	paths := make([]string, 0, len(files))
+	for _, file := range files {
+		paths = append(paths, file.Path)
+	}
+	models.LogActivity("pr_updated", "Resolved conflicts in pull request: "+pr.Title,
+		"Resolved "+strings.Join(paths, ", "), user.ID, repo.ID, "pull_request", pr.ID)
+
+	// The resolution is a new commit on the branch, same as a push
+	go gitPushed(repo, user.ID, before)
+
+	c.Redirect(w, r, fmt.Sprintf("/repos/%s/prs/%s/diff", repo.ID, pr.ID))
+}
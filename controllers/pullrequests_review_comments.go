@@ -0,0 +1,201 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"workspace/internal/github"
+	"workspace/models"
+	"workspace/services"
+)
+
+// PRFileDiffs returns the current pull request's diff line by line, keyed
+// by file path, with the review threads on each line
+func (c *PullRequestsController) PRFileDiffs() map[string]*models.FileDiff {
+	pr, err := c.CurrentPullRequest()
+	if err != nil {
+		return nil
+	}
+	files, err := pr.FileDiffs()
+	if err != nil {
+		return nil
+	}
+	byPath := make(map[string]*models.FileDiff, len(files))
+	for _, file := range files {
+		byPath[file.Path] = file
+	}
+	return byPath
+}
+
+// createReviewComment handles POST /repos/{id}/prs/{prID}/review-comments,
+// starting a review thread on a line of the diff
+func (c *PullRequestsController) createReviewComment(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	user, _, err := c.Use("auth").(*AuthController).Authenticate(r)
+	if err != nil {
+		c.RenderError(w, r, errors.New("authentication required"))
+		return
+	}
+
+	pr, err := models.PullRequests.Get(r.PathValue("prID"))
+	if err != nil || pr.RepoID != r.PathValue("id") {
+		c.RenderError(w, r, errors.New("pull request not found"))
+		return
+	}
+
+	line, _ := strconv.Atoi(r.FormValue("line"))
+	comment, err := models.CreateReviewComment(pr, user.ID, r.FormValue("body"), r.FormValue("path"), line)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	c.reviewCommented(pr, comment, user.ID)
+	c.Refresh(w, r)
+}
+
+// replyToReviewComment handles POST
+// /repos/{id}/prs/{prID}/review-comments/{commentID}/replies
+func (c *PullRequestsController) replyToReviewComment(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	user, _, err := c.Use("auth").(*AuthController).Authenticate(r)
+	if err != nil {
+		c.RenderError(w, r, errors.New("authentication required"))
+		return
+	}
+
+	pr, err := models.PullRequests.Get(r.PathValue("prID"))
+	if err != nil || pr.RepoID != r.PathValue("id") {
+		c.RenderError(w, r, errors.New("pull request not found"))
+		return
+	}
+
+	comment, err := models.ReplyToReviewComment(pr, r.PathValue("commentID"), user.ID, r.FormValue("body"))
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	c.reviewCommented(pr, comment, user.ID)
+	c.Refresh(w, r)
+}
+
+// reviewCommented records a new review comment, like a conversation
+// comment, and mirrors it to GitHub when comments sync both ways
+func (c *PullRequestsController) reviewCommented(pr *models.PullRequest, comment *models.Comment, userID string) {
+	models.MarkRead(userID, models.ReadPullRequest, pr.ID, pr.RepoID)
+	models.RecordReferences(pr.RepoID, models.RefComment, comment.ID, comment.Body, userID)
+	models.RecordMentions(pr.RepoID, models.RefComment, comment.ID, comment.Body, userID)
+	models.LogActivity("comment_created", "Commented on PR: "+pr.Title,
+		"Commented on "+comment.FilePath+":"+strconv.Itoa(comment.LineNumber), userID, pr.RepoID, "pr_comment", pr.ID)
+	services.EmitCommentWebhook(comment, pr.Title)
+
+	go func() {
+		syncService := &github.GitHubSyncService{}
+		if err := syncService.PushComment(comment.ID, userID); err != nil {
+			log.Printf("Failed to push review comment to GitHub: %v", err)
+		}
+	}()
+
+	// A comment from a requested reviewer completes their review request
+	if err := models.RecordReview(pr, userID); err != nil {
+		log.Printf("Failed to record review: %v", err)
+	}
+}
+
+// resolveReviewThread handles POST
+// /repos/{id}/prs/{prID}/review-comments/{commentID}/resolve
+func (c *PullRequestsController) resolveReviewThread(w http.ResponseWriter, r *http.Request) {
+	c.setReviewThreadResolved(w, r, true)
+}
+
+// unresolveReviewThread handles POST
+// /repos/{id}/prs/{prID}/review-comments/{commentID}/unresolve
+func (c *PullRequestsController) unresolveReviewThread(w http.ResponseWriter, r *http.Request) {
+	c.setReviewThreadResolved(w, r, false)
+}
+
+// setReviewThreadResolved resolves or reopens a review thread. The pull
+// request's author, whoever started the thread, or an admin can.
+func (c *PullRequestsController) setReviewThreadResolved(w http.ResponseWriter, r *http.Request, resolved bool) {
+	c.SetRequest(r)
+	user, _, err := c.Use("auth").(*AuthController).Authenticate(r)
+	if err != nil {
+		c.RenderError(w, r, errors.New("authentication required"))
+		return
+	}
+
+	pr, err := models.PullRequests.Get(r.PathValue("prID"))
+	if err != nil || pr.RepoID != r.PathValue("id") {
+		c.RenderError(w, r, errors.New("pull request not found"))
+		return
+	}
+
+	comment, err := models.Comments.Get(r.PathValue("commentID"))
+	if err != nil {
+		c.RenderError(w, r, errors.New("review comment not found"))
+		return
+	}
+	starter := comment.AuthorID
+	if comment.ParentID != "" {
+		if root, err := models.Comments.Get(comment.ParentID); err == nil {
+			starter = root.AuthorID
+		}
+	}
+	if !user.IsAdmin && user.ID != pr.AuthorID && user.ID != starter {
+		c.RenderError(w, r, errors.New("only the pull request's author, the thread's author, or an admin can resolve it"))
+		return
+	}
+
+	if _, err := models.ResolveReviewThread(pr, comment.ID, user.ID, resolved); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+	c.Refresh(w, r)
+}
+
+// applySuggestion handles POST
+// /repos/{id}/prs/{prID}/review-comments/{commentID}/apply, committing a
+// review comment's suggestion to the pull request's branch. Like pushing
+// to the branch, only the pull request's author or an admin can.
+func (c *PullRequestsController) applySuggestion(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	repo, err := c.Use("repos").(*ReposController).getCurrentRepoFromRequest(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	user, _, err := c.Use("auth").(*AuthController).Authenticate(r)
+	if err != nil {
+		c.RenderError(w, r, errors.New("authentication required"))
+		return
+	}
+
+	pr, err := models.PullRequests.Get(r.PathValue("prID"))
+	if err != nil || pr.RepoID != repo.ID {
+		c.RenderError(w, r, errors.New("pull request not found"))
+		return
+	}
+	if !user.IsAdmin && user.ID != pr.AuthorID {
+		c.RenderError(w, r, errors.New("only the pull request's author or an admin can apply suggestions"))
+		return
+	}
+
+	before := branchHeads(repo)
+	commit, err := models.ApplySuggestion(pr, r.PathValue("commentID"), user.ID, user.Name, user.Email)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	models.LogActivity("pr_updated", "Applied a suggestion to pull request: "+pr.Title,
+		"Committed "+commit[:7]+" to "+pr.CompareBranch, user.ID, repo.ID, "pull_request", pr.ID)
+
+	// The suggestion is a new commit on the branch, same as a push
+	go gitPushed(repo, user.ID, before)
+
+	c.Refresh(w, r)
+}
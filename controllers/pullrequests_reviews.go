@@ -0,0 +1,307 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"workspace/models"
+	"workspace/services"
+
+	"github.com/The-Skyscape/devtools/pkg/authentication"
+)
+
+// reviewReminderInterval is how often overdue review requests are checked
+const reviewReminderInterval = 15 * time.Minute
+
+// reviewReportWindows are the periods, in days, the review report covers
+var reviewReportWindows = []int{7, 30, 90}
+
+// PRReviewRequests returns the review requests on the current pull request
+func (c *PullRequestsController) PRReviewRequests() ([]*models.ReviewRequest, error) {
+	return models.GetReviewRequests(c.Request.PathValue("prID"))
+}
+
+// ReviewerCandidates returns the users who can be asked to review the
+// current pull request
+func (c *PullRequestsController) ReviewerCandidates() ([]*authentication.User, error) {
+	pr, err := c.CurrentPullRequest()
+	if err != nil {
+		return nil, err
+	}
+	return models.Auth.Users.Search("WHERE ID != ? ORDER BY Name ASC", pr.AuthorID)
+}
+
+// ReviewSLA returns how long review requests may wait before reminders
+func (c *PullRequestsController) ReviewSLA() time.Duration {
+	settings, err := models.GetSettings()
+	if err != nil {
+		return models.DefaultReviewSLA
+	}
+	return settings.ReviewSLA()
+}
+
+// ReviewReportDays returns the period the review report covers
+func (c *PullRequestsController) ReviewReportDays() int {
+	days, err := strconv.Atoi(c.Request.URL.Query().Get("days"))
+	if err != nil || days <= 0 {
+		return 30
+	}
+	return days
+}
+
+// ReviewReportWindows returns the periods offered on the review report
+func (c *PullRequestsController) ReviewReportWindows() []int {
+	return reviewReportWindows
+}
+
+// ReviewLatency returns the review latency report for the chosen period
+func (c *PullRequestsController) ReviewLatency() (*models.ReviewLatencyReport, error) {
+	since := time.Now().AddDate(0, 0, -c.ReviewReportDays())
+	return models.GetReviewLatencyReport(since, c.ReviewSLA())
+}
+
+// FormatLatency formats a review or merge duration for display
+func (c *PullRequestsController) FormatLatency(d time.Duration) string {
+	return models.FormatLatency(d)
+}
+
+// requestReview handles POST /repos/{id}/prs/{prID}/reviewers
+func (c *PullRequestsController) requestReview(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	user, _, err := c.Use("auth").(*AuthController).Authenticate(r)
+	if err != nil {
+		c.RenderError(w, r, errors.New("authentication required"))
+		return
+	}
+
+	pr, err := models.PullRequests.Get(r.PathValue("prID"))
+	if err != nil || pr.RepoID != r.PathValue("id") {
+		c.RenderError(w, r, errors.New("pull request not found"))
+		return
+	}
+	if !user.IsAdmin && pr.AuthorID != user.ID {
+		c.RenderError(w, r, errors.New("only the author or an admin can request reviews"))
+		return
+	}
+
+	if _, err := models.RequestReview(pr, r.FormValue("reviewer_id"), user.ID); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	c.Refresh(w, r)
+}
+
+// PRReviews returns the reviews left on the current pull request
+func (c *PullRequestsController) PRReviews() ([]*models.PRReview, error) {
+	return models.GetPRReviews(c.Request.PathValue("prID"))
+}
+
+// PRReviewStates returns where each reviewer of the current pull request
+// stands, by user ID
+func (c *PullRequestsController) PRReviewStates() (map[string]string, error) {
+	pr, err := c.CurrentPullRequest()
+	if err != nil {
+		return nil, err
+	}
+	return pr.ReviewStates()
+}
+
+// PRApprovals returns the approvals of the current pull request
+func (c *PullRequestsController) PRApprovals() ([]*models.PRApproval, error) {
+	return models.GetPRApprovals(c.Request.PathValue("prID"))
+}
+
+// PRHeadCommit returns the latest commit of the current pull request, which
+// approvals must match to count
+func (c *PullRequestsController) PRHeadCommit() string {
+	pr, err := c.CurrentPullRequest()
+	if err != nil {
+		return ""
+	}
+	head, _ := pr.HeadCommit()
+	return head
+}
+
+// PRMergeBlockers returns what keeps the current pull request from merging
+// into its protected base branch
+func (c *PullRequestsController) PRMergeBlockers() ([]string, error) {
+	pr, err := c.CurrentPullRequest()
+	if err != nil {
+		return nil, err
+	}
+	return pr.MergeBlockers()
+}
+
+// PRChecks returns the statuses reported for the head of the current pull
+// request's branch
+func (c *PullRequestsController) PRChecks() []*models.CommitStatus {
+	pr, err := c.CurrentPullRequest()
+	if err != nil {
+		return nil
+	}
+	checks, err := pr.Checks()
+	if err != nil {
+		return nil
+	}
+	return checks
+}
+
+// PRChecksState sums up the checks of the current pull request as failure,
+// pending or success
+func (c *PullRequestsController) PRChecksState() string {
+	return models.CombinedStatus(c.PRChecks())
+}
+
+// approvePR handles POST /repos/{id}/prs/{prID}/approve
+func (c *PullRequestsController) approvePR(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	user, _, err := c.Use("auth").(*AuthController).Authenticate(r)
+	if err != nil {
+		c.RenderError(w, r, errors.New("authentication required"))
+		return
+	}
+
+	pr, err := models.PullRequests.Get(r.PathValue("prID"))
+	if err != nil || pr.RepoID != r.PathValue("id") {
+		c.RenderError(w, r, errors.New("pull request not found"))
+		return
+	}
+
+	if _, err := models.SubmitReview(pr, user.ID, models.ReviewApproved, ""); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	models.LogActivity("pr_approved", "Approved pull request: "+pr.Title,
+		"Pull request approved", user.ID, pr.RepoID, "pull_request", pr.ID)
+
+	c.Refresh(w, r)
+}
+
+// reviewPR handles POST /repos/{id}/prs/{prID}/reviews, approving,
+// requesting changes or commenting
+func (c *PullRequestsController) reviewPR(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	user, _, err := c.Use("auth").(*AuthController).Authenticate(r)
+	if err != nil {
+		c.RenderError(w, r, errors.New("authentication required"))
+		return
+	}
+
+	pr, err := models.PullRequests.Get(r.PathValue("prID"))
+	if err != nil || pr.RepoID != r.PathValue("id") {
+		c.RenderError(w, r, errors.New("pull request not found"))
+		return
+	}
+
+	review, err := models.SubmitReview(pr, user.ID, r.FormValue("state"), r.FormValue("body"))
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	activity := map[string]string{
+		models.ReviewApproved:         "pr_approved",
+		models.ReviewChangesRequested: "pr_changes_requested",
+		models.ReviewCommented:        "pr_reviewed",
+	}[review.State]
+	models.LogActivity(activity, review.Summary()+" on pull request: "+pr.Title,
+		review.Body, user.ID, pr.RepoID, "pull_request", pr.ID)
+
+	c.Refresh(w, r)
+}
+
+// readyPR handles POST /repos/{id}/prs/{prID}/ready, taking a draft pull
+// request out of draft so it can be merged
+func (c *PullRequestsController) readyPR(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	user, _, err := c.Use("auth").(*AuthController).Authenticate(r)
+	if err != nil {
+		c.RenderError(w, r, errors.New("authentication required"))
+		return
+	}
+
+	pr, err := models.PullRequests.Get(r.PathValue("prID"))
+	if err != nil || pr.RepoID != r.PathValue("id") {
+		c.RenderError(w, r, errors.New("pull request not found"))
+		return
+	}
+	if !user.IsAdmin && pr.AuthorID != user.ID {
+		c.RenderError(w, r, errors.New("only the author or admin can mark this pull request ready"))
+		return
+	}
+	if pr.Status != "open" || !pr.Draft {
+		c.RenderError(w, r, errors.New("pull request is not an open draft"))
+		return
+	}
+
+	pr.Draft = false
+	if err := models.PullRequests.Update(pr); err != nil {
+		c.RenderError(w, r, errors.New("failed to update pull request"))
+		return
+	}
+
+	models.LogActivity("pr_updated", "Marked pull request ready for review: "+pr.Title,
+		"Pull request is no longer a draft", user.ID, pr.RepoID, "pull_request", pr.ID)
+	services.EmitPullRequestWebhook("ready_for_review", pr, user.ID)
+
+	c.Refresh(w, r)
+}
+
+// updateReviewSettings handles POST /settings/reviews
+func (c *PullRequestsController) updateReviewSettings(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	user, _, err := c.Use("auth").(*AuthController).Authenticate(r)
+	if err != nil {
+		c.RenderError(w, r, errors.New("authentication required"))
+		return
+	}
+
+	hours, err := strconv.Atoi(r.FormValue("review_sla_hours"))
+	if err != nil || hours < 1 || hours > 24*30 {
+		c.RenderError(w, r, errors.New("review SLA must be between 1 and 720 hours"))
+		return
+	}
+
+	settings, err := models.GetSettings()
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+	settings.ReviewSLAHours = hours
+	settings.ReviewRemindersOff = r.FormValue("review_reminders") != "true"
+	settings.LastUpdatedBy = user.Email
+	settings.LastUpdatedAt = time.Now()
+	if err := models.GlobalSettings.Update(settings); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	c.Refresh(w, r)
+}
+
+// startReviewReminders periodically reminds reviewers about requests that
+// have waited longer than the review SLA
+func (c *PullRequestsController) startReviewReminders() {
+	go func() {
+		ticker := time.NewTicker(reviewReminderInterval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			settings, err := models.GetSettings()
+			if err != nil || settings.ReviewRemindersOff {
+				continue
+			}
+			sent, err := models.SendReviewReminders(settings.ReviewSLA(), time.Now())
+			if err != nil {
+				log.Printf("PullRequestsController: Failed to send review reminders: %v", err)
+			} else if sent > 0 {
+				log.Printf("PullRequestsController: Sent %d review reminders", sent)
+			}
+		}
+	}()
+}
@@ -4,6 +4,7 @@ import (
 	"log"
 	"net/http"
 	"workspace/middleware"
+	"workspace/models"
 
 	"github.com/The-Skyscape/devtools/pkg/application"
 )
@@ -26,7 +27,7 @@ func (c *RateLimitController) Setup(app *application.App) {
 
 	// Wrap signin endpoint with rate limiting
 	http.HandleFunc("POST /signin", func(w http.ResponseWriter, r *http.Request) {
-		ip := c.getClientIP(r)
+		ip := middleware.ClientIP(r)
 
 		// Check rate limit
 		if !middleware.AuthRateLimiter.Allow(ip) {
@@ -54,7 +55,7 @@ func (c *RateLimitController) Setup(app *application.App) {
 
 	// Wrap signup endpoint with stricter rate limiting
 	http.HandleFunc("POST /signup", func(w http.ResponseWriter, r *http.Request) {
-		ip := c.getClientIP(r)
+		ip := middleware.ClientIP(r)
 
 		// Check rate limit for signups
 		if !middleware.SignupRateLimiter.Allow(ip) {
@@ -91,29 +92,28 @@ func (c *RateLimitController) GetRateLimitStats() map[string]any {
 	}
 }
 
-// getClientIP extracts the client IP from the request
-func (c *RateLimitController) getClientIP(r *http.Request) string {
-	// Check X-Forwarded-For header first (for proxies)
-	forwarded := r.Header.Get("X-Forwarded-For")
-	if forwarded != "" {
-		// Use the first IP in the chain
-		if idx := len(forwarded) - 1; idx >= 0 {
-			for i := idx; i >= 0; i-- {
-				if forwarded[i] == ',' || forwarded[i] == ' ' {
-					return forwarded[i+1:]
-				}
-			}
-			return forwarded
+// RateLimitKey returns the user a request is from, so rate limits follow
+// users rather than addresses, or empty for anonymous requests. Cookies
+// are only checked for their signature, leaving whether the session is
+// still active to the handlers, so counting stays cheap.
+func (c *AuthController) RateLimitKey(r *http.Request) string {
+	if value := bearerToken(r); value != "" {
+		if token, err := models.GetPersonalToken(value); err == nil {
+			return token.UserID
 		}
+		return ""
 	}
 
-	// Check X-Real-IP header
-	if realIP := r.Header.Get("X-Real-IP"); realIP != "" {
-		return realIP
+	token, err := c.auth.GetTokenFromCookie(r, c.cookieName)
+	if err != nil {
+		return ""
 	}
-
-	// Fall back to RemoteAddr
-	return r.RemoteAddr
+	claims, err := c.auth.ValidateToken(token)
+	if err != nil {
+		return ""
+	}
+	userID, _ := claims["user_id"].(string)
+	return userID
 }
 
 // responseWriter wraps http.ResponseWriter to capture status code
@@ -39,29 +39,104 @@ func (c *ReposController) Setup(app *application.App) {
 	// Register Git HTTP endpoints
 	// These handle git clone, push, pull operations
 	http.Handle("/repo/", http.StripPrefix("/repo/", gitServer))
+	c.startSSHServer(auth)
+
+	// Both serve pushes through git, where protected branches are enforced
+	// by each repository's pre-receive hook
+	if err := models.SyncProtectionHooks(); err != nil {
+		log.Printf("Branch protection: %v", err)
+	}
+
+	// Git LFS, found by clients under the repository's git URL
+	http.HandleFunc("POST /repo/{id}/info/lfs/objects/batch", c.lfsBatch)
+	http.HandleFunc("GET /repo/{id}/info/lfs/objects/{oid}", c.lfsDownload)
+	http.HandleFunc("PUT /repo/{id}/info/lfs/objects/{oid}", c.lfsUpload)
+	http.HandleFunc("POST /repo/{id}/info/lfs/objects/verify", c.lfsVerify)
+	http.HandleFunc("POST /repo/{id}/info/lfs/locks/verify", c.lfsVerifyLocks)
+
+	// Container registry, which Docker expects at the root of the host
+	http.HandleFunc("/v2/", c.registryAPI)
+	http.Handle("POST /repos/{id}/images/delete", app.ProtectFunc(c.deleteRegistryTag, AdminOnly()))
+
+	// Commit statuses, reported by CI systems with git credentials
+	http.HandleFunc("POST /api/repos/{id}/statuses/{sha}", c.createCommitStatus)
+	http.HandleFunc("GET /api/repos/{id}/statuses/{sha}", c.getCommitStatuses)
 
 	// Repository browsing/reading
 	http.Handle("GET /repos", app.Serve("repos-list.html", auth.Required))
 	http.Handle("GET /repos/search", app.ProtectFunc(c.searchRepositories, auth.Required))
-	http.Handle("GET /repos/{id}", app.Serve("repo-view.html", PublicOrAdmin()))
+	http.Handle("GET /repos/{id}", app.Serve("repo-view.html", PublicAdminOrGuest()))
 	http.Handle("GET /repos/{id}/activity", app.Serve("repo-activity.html", PublicOrAdmin()))
-	http.Handle("GET /repos/{id}/files", app.Serve("repo-files.html", PublicOrAdmin()))
-	http.Handle("GET /repos/{id}/files/{path...}", app.Serve("repo-file-view.html", PublicOrAdmin()))
-	http.Handle("GET /repos/{id}/edit/{path...}", app.Serve("repo-file-edit.html", AdminOnly()))
-	http.Handle("GET /repos/{id}/commits", app.Serve("repo-commits.html", PublicOrAdmin()))
-	http.Handle("GET /repos/{id}/commits/{hash}/diff", app.Serve("repo-commit-diff.html", PublicOrAdmin()))
-	http.Handle("GET /repos/{id}/settings", app.Serve("repo-settings.html", AdminOnly()))
+	http.Handle("GET /repos/{id}/insights", app.Serve("repo-insights.html", PublicOrAdmin()))
+	http.Handle("GET /repos/{id}/files", app.Serve("repo-files.html", PublicAdminOrGuest()))
+	http.Handle("GET /repos/{id}/files/{path...}", app.Serve("repo-file-view.html", PublicAdminOrGuest()))
+	http.Handle("GET /repos/{id}/edit/{path...}", app.Serve("repo-file-edit.html", RepoAccess(models.RoleWrite)))
+	http.Handle("GET /repos/{id}/commits", app.Serve("repo-commits.html", PublicAdminOrGuest()))
+	http.Handle("GET /repos/{id}/commits/{hash}/diff", app.Serve("repo-commit-diff.html", PublicAdminOrGuest()))
+	http.Handle("GET /repos/{id}/search", app.Serve("repo-search.html", PublicAdminOrGuest()))
+	http.Handle("GET /repos/{id}/settings", app.Serve("repo-settings.html", RepoAccess(models.RoleAdmin)))
 
 	// Repository management - admin only
 	http.Handle("POST /repos/create", app.ProtectFunc(c.createRepository, AdminOnly()))
 	http.Handle("POST /repos/import", app.ProtectFunc(c.importRepository, AdminOnly()))
-	http.Handle("POST /repos/{id}/settings/update", app.ProtectFunc(c.updateRepository, AdminOnly()))
+	http.Handle("GET /repos/{id}/import/events", app.ProtectFunc(c.streamImport, AdminOnly()))
 	http.Handle("POST /repos/{id}/delete", app.ProtectFunc(c.deleteRepository, AdminOnly()))
-
-	// File operations - admin only
-	http.Handle("POST /repos/{id}/files/save", app.ProtectFunc(c.saveFile, AdminOnly()))
-	http.Handle("POST /repos/{id}/files/create", app.ProtectFunc(c.createFile, AdminOnly()))
-	http.Handle("POST /repos/{id}/files/delete/{path...}", app.ProtectFunc(c.deleteFile, AdminOnly()))
+	http.Handle("POST /repos/{id}/search/index", app.ProtectFunc(c.reindexRepository, AdminOnly()))
+
+	// Repository settings - repository admins, given by being an admin or through a team
+	http.Handle("POST /repos/{id}/settings/update", app.ProtectFunc(c.updateRepository, RepoAccess(models.RoleAdmin)))
+	http.Handle("POST /repos/{id}/settings/sandbox", app.ProtectFunc(c.updateSandboxPolicy, RepoAccess(models.RoleAdmin)))
+	http.Handle("POST /repos/{id}/settings/tools", app.ProtectFunc(c.updateToolPolicy, RepoAccess(models.RoleAdmin)))
+	http.Handle("POST /repos/{id}/settings/stale", app.ProtectFunc(c.updateStalePolicy, RepoAccess(models.RoleAdmin)))
+	http.Handle("GET /repos/{id}/settings/stale/preview", app.ProtectFunc(c.previewStalePolicy, RepoAccess(models.RoleAdmin)))
+	http.Handle("POST /repos/{id}/settings/auto-approval", app.ProtectFunc(c.updateAutoApprovalPolicy, RepoAccess(models.RoleAdmin)))
+	http.Handle("POST /repos/{id}/settings/mirror", app.ProtectFunc(c.updateMirrorExclusion, RepoAccess(models.RoleAdmin)))
+	http.Handle("POST /repos/{id}/settings/branches", app.ProtectFunc(c.createBranchProtection, RepoAccess(models.RoleAdmin)))
+	http.Handle("POST /repos/{id}/settings/branches/{ruleID}/delete", app.ProtectFunc(c.deleteBranchProtection, RepoAccess(models.RoleAdmin)))
+	http.Handle("POST /repos/{id}/settings/secrets", app.ProtectFunc(c.saveActionSecret, RepoAccess(models.RoleAdmin)))
+	http.Handle("POST /repos/{id}/settings/secrets/{secretID}/delete", app.ProtectFunc(c.deleteActionSecret, RepoAccess(models.RoleAdmin)))
+
+	// Read-only guest links - repository admins manage them, anyone holding one can open it
+	http.Handle("GET /guest/{token}", app.ProtectFunc(c.openGuestLink, nil))
+	http.Handle("POST /repos/{id}/guest-links", app.ProtectFunc(c.createGuestLink, RepoAccess(models.RoleAdmin)))
+	http.Handle("POST /repos/{id}/guest-links/{linkID}/revoke", app.ProtectFunc(c.revokeGuestLink, RepoAccess(models.RoleAdmin)))
+
+	// Outgoing webhooks - repository admins
+	http.Handle("POST /repos/{id}/webhooks", app.ProtectFunc(c.createWebhook, RepoAccess(models.RoleAdmin)))
+	http.Handle("POST /repos/{id}/webhooks/{hookID}/toggle", app.ProtectFunc(c.toggleWebhook, RepoAccess(models.RoleAdmin)))
+	http.Handle("POST /repos/{id}/webhooks/{hookID}/delete", app.ProtectFunc(c.deleteWebhook, RepoAccess(models.RoleAdmin)))
+	http.Handle("POST /repos/{id}/webhooks/{hookID}/ping", app.ProtectFunc(c.pingWebhook, RepoAccess(models.RoleAdmin)))
+	http.Handle("POST /repos/{id}/webhooks/{hookID}/deliveries/{deliveryID}/redeliver", app.ProtectFunc(c.redeliverWebhook, RepoAccess(models.RoleAdmin)))
+
+	// Scheduled reports - repository admins
+	http.Handle("POST /repos/{id}/reports", app.ProtectFunc(c.createReportSchedule, RepoAccess(models.RoleAdmin)))
+	http.Handle("POST /repos/{id}/reports/{scheduleID}/run", app.ProtectFunc(c.runReportSchedule, RepoAccess(models.RoleAdmin)))
+	http.Handle("POST /repos/{id}/reports/{scheduleID}/toggle", app.ProtectFunc(c.toggleReportSchedule, RepoAccess(models.RoleAdmin)))
+	http.Handle("POST /repos/{id}/reports/{scheduleID}/delete", app.ProtectFunc(c.deleteReportSchedule, RepoAccess(models.RoleAdmin)))
+	http.Handle("GET /repos/{id}/reports/files/{reportID}", app.ProtectFunc(c.downloadReport, RepoAccess(models.RoleAdmin)))
+
+	// Software bill of materials and license report
+	http.Handle("GET /repos/{id}/dependencies", app.Serve("repo-dependencies.html", PublicOrAdmin()))
+	http.Handle("GET /repos/{id}/dependencies/sbom.cdx.json", app.ProtectFunc(c.downloadSBOM, PublicOrAdmin()))
+	http.Handle("POST /repos/{id}/dependencies/generate", app.ProtectFunc(c.generateSBOM, AdminOnly()))
+
+	// Files attached to issues, pull requests and comments
+	http.Handle("GET /repos/{id}/attachments/{attachmentID}", app.ProtectFunc(c.serveAttachment, PublicAdminOrGuest()))
+	http.Handle("POST /repos/{id}/attachments", app.ProtectFunc(c.uploadAttachments, PublicRepoOnly()))
+	http.Handle("POST /repos/{id}/attachments/{attachmentID}/delete", app.ProtectFunc(c.deleteAttachment, PublicRepoOnly()))
+
+	// File operations - write access
+	http.Handle("POST /repos/{id}/files/save", app.ProtectFunc(c.saveFile, RepoAccess(models.RoleWrite)))
+	http.Handle("POST /repos/{id}/files/create", app.ProtectFunc(c.createFile, RepoAccess(models.RoleWrite)))
+	http.Handle("POST /repos/{id}/files/delete/{path...}", app.ProtectFunc(c.deleteFile, RepoAccess(models.RoleWrite)))
+
+	// Collaborative editing - write access
+	http.Handle("GET /repos/{id}/collab/events/{path...}", app.ProtectFunc(c.collabEvents, RepoAccess(models.RoleWrite)))
+	http.Handle("POST /repos/{id}/collab/ops/{path...}", app.ProtectFunc(c.collabOperation, RepoAccess(models.RoleWrite)))
+	http.Handle("POST /repos/{id}/collab/selection/{path...}", app.ProtectFunc(c.collabSelection, RepoAccess(models.RoleWrite)))
+
+	// Generate scheduled reports as they come due
+	c.startReportScheduler()
 }
 
 // Handle returns a controller instance configured for the current request
@@ -79,7 +154,7 @@ func (c *ReposController) CurrentRepo() (*models.Repository, error) {
 }
 
 // UserRepos returns all repositories accessible to the current user
-// Admins see all repos, guests see only public repos
+// Admins see all repos, members see public repos and their teams' repos
 func (c *ReposController) UserRepos() ([]*models.Repository, error) {
 	auth := c.Use("auth").(*AuthController)
 	user, _, err := auth.Authenticate(c.Request)
@@ -93,8 +168,15 @@ func (c *ReposController) UserRepos() ([]*models.Repository, error) {
 		return models.Repositories.Search("ORDER BY UpdatedAt DESC")
 	}
 
-	// Non-admins see only public repositories
-	return models.Repositories.Search("WHERE Visibility = ? ORDER BY UpdatedAt DESC", "public")
+	// Non-admins see public repositories and those shared with their teams
+	where, args := "Visibility = ?", []any{"public"}
+	if ids := models.TeamRepoIDs(user.ID); len(ids) > 0 {
+		where += " OR ID IN (" + strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",") + ")"
+		for _, id := range ids {
+			args = append(args, id)
+		}
+	}
+	return models.Repositories.Search("WHERE "+where+" ORDER BY UpdatedAt DESC", args...)
 }
 
 // CurrentUser returns the currently authenticated user
@@ -121,8 +203,24 @@ func (c *ReposController) IsAuthenticated() bool {
 
 // CanEdit returns true if the current user can edit the current repository
 func (c *ReposController) CanEdit() bool {
-	// Only admins can edit repositories
-	return c.IsAdmin()
+	// Admins and teams with write access can edit repositories
+	return c.hasRepoRole(models.RoleWrite)
+}
+
+// CanAdmin returns true if the current user can change the current
+// repository's settings
+func (c *ReposController) CanAdmin() bool {
+	return c.hasRepoRole(models.RoleAdmin)
+}
+
+// hasRepoRole reports whether the current user has at least role on the
+// current repository
+func (c *ReposController) hasRepoRole(role string) bool {
+	user := c.CurrentUser()
+	if user == nil || c.Request == nil {
+		return false
+	}
+	return models.CheckRepoAccess(user, c.Request.PathValue("id"), role) == nil
 }
 
 // CanCreateRepo returns true if the current user can create repositories
@@ -202,15 +300,20 @@ func (c *ReposController) getCurrentRepoFromRequest(r *http.Request) (*models.Re
 		return repo, nil
 	}
 
-	// Private repos require admin
+	// Guest links allow reading this one repository
+	if guestLinkForRequest(r) != nil {
+		return repo, nil
+	}
+
+	// Private repos require admin or a team with access
 	auth := c.Use("auth").(*AuthController)
 	user, _, err := auth.Authenticate(r)
 	if err != nil {
 		return nil, errors.New("authentication required")
 	}
 
-	if !user.IsAdmin {
-		return nil, errors.New("access denied - private repository")
+	if err := models.CheckRepoAccess(user, repo.ID, models.RoleRead); err != nil {
+		return nil, err
 	}
 
 	return repo, nil
@@ -241,6 +344,12 @@ func (c *ReposController) createRepository(w http.ResponseWriter, r *http.Reques
 		visibility = "private"
 	}
 
+	// Users over their storage quota can't add repositories
+	if err := models.CheckQuota(user.ID, models.UsageStorage); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
 	// Use models.CreateRepository to ensure URL-safe IDs and proper Git initialization
 	repo, err := models.CreateRepository(name, description, visibility, user.ID)
 	if err != nil {
@@ -303,6 +412,11 @@ func (c *ReposController) updateRepository(w http.ResponseWriter, r *http.Reques
 	models.LogActivity("repo_updated", fmt.Sprintf("Updated repository %s", repo.Name),
 		fmt.Sprintf("Repository settings were updated"),
 		user.ID, repo.ID, "repository", "")
+	services.EmitWebhook(repo.ID, models.WebhookEventRepository, "updated", user.ID, map[string]any{
+		"name":        repo.Name,
+		"description": repo.Description,
+		"visibility":  repo.Visibility,
+	})
 
 	// Redirect back to settings
 	c.Redirect(w, r, fmt.Sprintf("/repos/%s/settings", repo.ID))
@@ -331,10 +445,8 @@ func (c *ReposController) deleteRepository(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	// Delete repository (handles filesystem and database)
-	// Note: We don't remove from Code Server - user may want to keep working copy
-	err = models.DeleteRepository(repo.ID)
-	if err != nil {
+	// Delete repository (handles environments, filesystem and database)
+	if err = services.DeleteRepository(repo); err != nil {
 		c.RenderError(w, r, err)
 		return
 	}
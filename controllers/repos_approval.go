@@ -0,0 +1,73 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"workspace/models"
+)
+
+// AutoApprovalPolicy returns the pull request auto-approval policy for the
+// current repository
+func (c *ReposController) AutoApprovalPolicy() (*models.AutoApprovalPolicy, error) {
+	repo, err := c.CurrentRepo()
+	if err != nil {
+		return nil, err
+	}
+	return models.GetAutoApprovalPolicy(repo.ID), nil
+}
+
+// ApprovalCategories returns the change categories a policy can allow
+func (c *ReposController) ApprovalCategories() []string {
+	return models.ApprovalCategories
+}
+
+// updateAutoApprovalPolicy handles POST /repos/{id}/settings/auto-approval
+func (c *ReposController) updateAutoApprovalPolicy(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	repo, err := c.getCurrentRepoFromRequest(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	// Admin already verified by route middleware
+	auth := c.App.Use("auth").(*AuthController)
+	user, _, err := auth.Authenticate(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+	maxLines, err := strconv.Atoi(r.FormValue("max_lines_changed"))
+	if err != nil {
+		c.RenderError(w, r, errors.New("max lines changed must be a number"))
+		return
+	}
+
+	policy := models.GetAutoApprovalPolicy(repo.ID)
+	policy.Enabled = r.FormValue("enabled") == "on"
+	policy.AllowedCategories = strings.Join(r.Form["categories"], "\n")
+	policy.MaxLinesChanged = maxLines
+	policy.RequiredChecks = r.FormValue("required_checks")
+	policy.ProtectedPaths = r.FormValue("protected_paths")
+	policy.AllowedAuthors = r.FormValue("allowed_authors")
+
+	if _, err := models.SaveAutoApprovalPolicy(policy, user.ID); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	models.LogActivity("repo_updated", fmt.Sprintf("Updated auto-approval policy for %s", repo.Name),
+		"The rules for approving pull requests without a human review were changed",
+		user.ID, repo.ID, "repository", "")
+
+	c.Redirect(w, r, fmt.Sprintf("/repos/%s/settings", repo.ID))
+}
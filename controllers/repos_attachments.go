@@ -0,0 +1,190 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+
+	"workspace/models"
+)
+
+// AttachmentGroup is the list of files attached to one issue, pull request
+// or comment, as rendered by attachment-list.html
+type AttachmentGroup struct {
+	RepoID      string
+	EntityType  string
+	EntityID    string
+	Attachments []*models.Attachment
+	Uploadable  bool     // Shows the drop zone; comments take files with the comment instead
+	Errors      []string // Files rejected by the last upload
+	user        *models.User
+}
+
+// CanDelete returns true if the viewer uploaded the file or is an admin
+func (g *AttachmentGroup) CanDelete(a *models.Attachment) bool {
+	return g.user != nil && (g.user.IsAdmin || g.user.ID == a.UploadedBy)
+}
+
+// AttachmentsFor returns the files attached to an entity of the current repository
+func (c *ReposController) AttachmentsFor(entityType, entityID string) *AttachmentGroup {
+	return c.attachmentGroup(c.Request.PathValue("id"), entityType, entityID)
+}
+
+// attachmentGroup loads an entity's attachments for the current viewer
+func (c *ReposController) attachmentGroup(repoID, entityType, entityID string) *AttachmentGroup {
+	group := &AttachmentGroup{
+		RepoID:     repoID,
+		EntityType: entityType,
+		EntityID:   entityID,
+		user:       c.CurrentUser(),
+	}
+
+	attachments, err := models.GetAttachments(entityType, entityID)
+	if err != nil {
+		log.Printf("Failed to load attachments for %s %s: %v", entityType, entityID, err)
+	}
+	group.Attachments = attachments
+
+	if group.user != nil && entityType != "comment" {
+		repo, err := models.Repositories.Get(repoID)
+		group.Uploadable = err == nil && models.RepoRole(group.user, repo) != ""
+	}
+	return group
+}
+
+// uploadAttachments handles POST /repos/{id}/attachments, attaching the
+// dropped files to an issue or pull request
+func (c *ReposController) uploadAttachments(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	user := c.CurrentUser()
+	repoID := r.PathValue("id")
+
+	files, err := attachmentFiles(w, r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	entityType, entityID := r.FormValue("entity_type"), r.FormValue("entity_id")
+	var entityRepoID string
+	switch entityType {
+	case "issue":
+		if issue, err := models.Issues.Get(entityID); err == nil {
+			entityRepoID = issue.RepoID
+		}
+	case "pr":
+		if pr, err := models.PullRequests.Get(entityID); err == nil {
+			entityRepoID = pr.RepoID
+		}
+	}
+	if entityRepoID == "" || entityRepoID != repoID {
+		c.RenderError(w, r, errors.New("files can only be attached to issues and pull requests in this repository"))
+		return
+	}
+
+	rejected := saveAttachments(files, repoID, entityType, entityID, user.ID)
+	if len(rejected) < len(files) {
+		models.LogActivity("attachment_uploaded", fmt.Sprintf("Attached %d file(s)", len(files)-len(rejected)),
+			"Files attached to "+entityType+" #"+entityID, user.ID, repoID, entityType, entityID)
+	}
+
+	group := c.attachmentGroup(repoID, entityType, entityID)
+	group.Errors = rejected
+	c.Render(w, r, "attachment-list.html", group)
+}
+
+// serveAttachment handles GET /repos/{id}/attachments/{attachmentID}.
+// Only images and plain text are shown inline; everything else downloads.
+func (c *ReposController) serveAttachment(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	attachment, err := models.Attachments.Get(r.PathValue("attachmentID"))
+	if err != nil || attachment.RepoID != r.PathValue("id") {
+		http.NotFound(w, r)
+		return
+	}
+
+	file, err := attachment.Open()
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	disposition := "attachment"
+	switch {
+	case attachment.IsImage():
+		w.Header().Set("Content-Type", attachment.ContentType)
+		disposition = "inline"
+	case attachment.IsText():
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		disposition = "inline"
+	default:
+		w.Header().Set("Content-Type", "application/octet-stream")
+	}
+	w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": attachment.Filename}))
+	w.Header().Set("Content-Security-Policy", "sandbox")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	serveStored(w, r, file, attachment.CreatedAt)
+}
+
+// deleteAttachment handles POST /repos/{id}/attachments/{attachmentID}/delete
+func (c *ReposController) deleteAttachment(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	user := c.CurrentUser()
+
+	attachment, err := models.Attachments.Get(r.PathValue("attachmentID"))
+	if err != nil || attachment.RepoID != r.PathValue("id") {
+		c.RenderError(w, r, errors.New("attachment not found"))
+		return
+	}
+	if !user.IsAdmin && user.ID != attachment.UploadedBy {
+		c.RenderError(w, r, errors.New("only the uploader or an admin can remove this file"))
+		return
+	}
+
+	if err := attachment.Delete(); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	c.Render(w, r, "attachment-list.html", c.attachmentGroup(attachment.RepoID, attachment.EntityType, attachment.EntityID))
+}
+
+// attachmentFiles limits the request to what can be attached at once and
+// returns the uploaded files. Plain forms without files return none.
+func attachmentFiles(w http.ResponseWriter, r *http.Request) ([]*multipart.FileHeader, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, models.MaxAttachmentsPerUpload*models.MaxAttachmentSize+1<<20)
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		if errors.Is(err, http.ErrNotMultipart) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("upload is too large: attach up to %d files of %d MB each",
+			models.MaxAttachmentsPerUpload, models.MaxAttachmentSize>>20)
+	}
+
+	files := r.MultipartForm.File["files"]
+	if len(files) > models.MaxAttachmentsPerUpload {
+		return nil, fmt.Errorf("attach up to %d files at a time", models.MaxAttachmentsPerUpload)
+	}
+	return files, nil
+}
+
+// saveAttachments stores each uploaded file, returning why any were rejected
+func saveAttachments(files []*multipart.FileHeader, repoID, entityType, entityID, userID string) []string {
+	var rejected []string
+	for _, header := range files {
+		file, err := header.Open()
+		if err != nil {
+			rejected = append(rejected, fmt.Sprintf("%s could not be read", header.Filename))
+			continue
+		}
+		_, err = models.SaveAttachment(repoID, entityType, entityID, header.Filename, file, userID)
+		file.Close()
+		if err != nil {
+			rejected = append(rejected, err.Error())
+		}
+	}
+	return rejected
+}
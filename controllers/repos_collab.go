@@ -0,0 +1,143 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"workspace/internal/collab"
+	"workspace/internal/sse"
+	"workspace/models"
+)
+
+// collabKey returns the editing session key for the file in the request
+func (c *ReposController) collabKey(r *http.Request) (*models.Repository, string, string, error) {
+	repo, err := c.getCurrentRepoFromRequest(r)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	path := r.PathValue("path")
+	if path == "" || strings.Contains(path, "..") {
+		return nil, "", "", errors.New("invalid file path")
+	}
+
+	branch := r.URL.Query().Get("branch")
+	if branch == "" {
+		branch = c.CurrentBranch()
+	}
+	return repo, branch, collab.Key(repo.ID, branch, path), nil
+}
+
+// collabEvents streams document changes and peer cursors to an editor
+func (c *ReposController) collabEvents(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	repo, branch, key, err := c.collabKey(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	user := c.Use("auth").(*AuthController).CurrentUser()
+	path := r.PathValue("path")
+	sub, err := collab.Sessions.Join(key, user.Name, func() (string, error) {
+		file, err := repo.GetFile(branch, path)
+		if err != nil {
+			return "", err
+		}
+		if file.IsBinary {
+			return "", errors.New("binary files cannot be edited")
+		}
+		// Browsers normalize textarea line endings, so offsets only agree on LF
+		return strings.ReplaceAll(file.Content, "\r\n", "\n"), nil
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	defer sub.Close()
+
+	stream, err := sse.Streams.Open(w, r, "collab", user.ID)
+	if err != nil {
+		return
+	}
+	defer stream.Close()
+
+	for {
+		select {
+		case event, ok := <-sub.Events:
+			if !ok {
+				// Dropped for falling behind, the browser reconnects for a fresh snapshot
+				return
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				log.Printf("ReposController: Failed to encode collab event: %v", err)
+				continue
+			}
+			stream.Send("collab", string(data))
+		case <-stream.Heartbeat():
+			stream.Ping()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// collabOperation applies an editor's change to the shared document
+func (c *ReposController) collabOperation(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	_, _, key, err := c.collabKey(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	var req struct {
+		ClientID  string            `json:"clientId"`
+		Revision  int               `json:"revision"`
+		Operation *collab.Operation `json:"operation"`
+		Selection *collab.Selection `json:"selection"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Operation == nil {
+		http.Error(w, "Invalid operation", http.StatusBadRequest)
+		return
+	}
+
+	if err := collab.Sessions.Submit(key, req.ClientID, req.Revision, req.Operation, req.Selection); err != nil {
+		status := http.StatusConflict
+		if errors.Is(err, collab.ErrSessionNotFound) {
+			status = http.StatusGone
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// collabSelection shares an editor's cursor with the other editors
+func (c *ReposController) collabSelection(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	_, _, key, err := c.collabKey(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	var req struct {
+		ClientID  string           `json:"clientId"`
+		Selection collab.Selection `json:"selection"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid selection", http.StatusBadRequest)
+		return
+	}
+
+	if err := collab.Sessions.Select(key, req.ClientID, req.Selection); err != nil {
+		http.Error(w, err.Error(), http.StatusGone)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
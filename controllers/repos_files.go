@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"workspace/internal/collab"
 	"workspace/models"
 )
 
@@ -262,6 +263,10 @@ func (c *ReposController) saveFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Let open editors know which revision is now on disk
+	collab.Sessions.MarkSaved(collab.Key(repo.ID, r.FormValue("branch"), filePath),
+		strings.ReplaceAll(content, "\r\n", "\n"))
+
 	// Log activity
 	models.LogActivity("file_edited", fmt.Sprintf("Edited file %s", filePath),
 		fmt.Sprintf("File %s was edited in repository %s", filePath, repo.Name),
@@ -407,26 +412,6 @@ func isSubPath(parent, path string) bool {
 	return !strings.HasPrefix(rel, "..") && !strings.HasPrefix(rel, "/")
 }
 
-// isBinary checks if content appears to be binary
-func isBinary(content []byte) bool {
-	if len(content) == 0 {
-		return false
-	}
-
-	// Check for null bytes in first 8KB
-	checkLen := len(content)
-	if checkLen > 8192 {
-		checkLen = 8192
-	}
-
-	for i := 0; i < checkLen; i++ {
-		if content[i] == 0 {
-			return true
-		}
-	}
-	return false
-}
-
 // getLanguageFromExtension returns the programming language based on file extension
 func getLanguageFromExtension(ext string) string {
 	languages := map[string]string{
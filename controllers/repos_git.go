@@ -171,70 +171,6 @@ func (c *ReposController) RepoCommitCount() int {
 	return count
 }
 
-// importRepository handles importing an existing Git repository
-func (c *ReposController) importRepository(w http.ResponseWriter, r *http.Request) {
-	c.SetRequest(r)
-		// Parse form data
-	name := r.FormValue("name")
-	description := r.FormValue("description")
-	gitURL := r.FormValue("git_url")
-	visibility := r.FormValue("visibility")
-
-	if name == "" || gitURL == "" {
-		c.RenderError(w, r, errors.New("repository name and Git URL are required"))
-		return
-	}
-
-	// Validate visibility
-	if visibility != "public" && visibility != "private" {
-		visibility = "private"
-	}
-
-	// Create repository record
-	repo := &models.Repository{
-		Model:       models.DB.NewModel(""),
-		Name:        name,
-		Description: description,
-		Visibility:  visibility,
-		UserID:      "", // Will be set based on current user
-	}
-
-	// Get current user
-	auth := c.Use("auth").(*AuthController)
-	user, _, err := auth.Authenticate(r)
-	if err == nil {
-		repo.UserID = user.ID
-	}
-
-	// Insert repository into database
-	repo, err = models.Repositories.Insert(repo)
-	if err != nil {
-		c.RenderError(w, r, err)
-		return
-	}
-
-	// Clone the repository
-	cmd := exec.Command("git", "clone", gitURL, repo.Path())
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		// Clean up on failure
-		models.Repositories.Delete(repo)
-		c.RenderError(w, r, fmt.Errorf("failed to clone repository: %s", string(output)))
-		return
-	}
-
-	// Update repository stats if method exists
-	// repo.UpdateStats()
-
-	// Log activity
-	models.LogActivity("repo_imported", fmt.Sprintf("Imported repository %s", name),
-		fmt.Sprintf("Repository %s was imported from %s", name, gitURL),
-		repo.UserID, repo.ID, "repository", "")
-
-	// Redirect to the new repository
-	c.Redirect(w, r, fmt.Sprintf("/repos/%s", repo.ID))
-}
-
 // InitGitServer initializes the gitkit server with authentication
 // This handles git clone, push, pull operations via HTTP
 func (c *ReposController) InitGitServer(auth *AuthController) *gitkit.Server {
@@ -271,25 +207,9 @@ func (c *ReposController) InitGitServer(auth *AuthController) *gitkit.Server {
 				return false, errors.New("invalid SSH key format")
 			}
 		} else if creds.Username != "" && creds.Password != "" {
-			// Check if it's token-based auth (using token ID as username, token value as password)
-			token, err := models.AccessTokens.Get(creds.Username)
-			if err == nil && token != nil && token.Token == creds.Password {
-				// Token matches, get the user associated with the token
-				user, err = auth.Users.Get(token.UserID)
-				if err != nil {
-					return false, errors.New("invalid token user")
-				}
-				log.Printf("Token auth successful - ID: %s", creds.Username)
-			} else {
-				// Fall back to username/password authentication
-				user, err = auth.GetUser(creds.Username)
-				if err != nil {
-					return false, errors.New("invalid username or password")
-				}
-				if !user.VerifyPassword(creds.Password) {
-					return false, errors.New("invalid username or password")
-				}
-				log.Printf("User auth successful for %s", creds.Username)
+			var err error
+			if user, err = gitPasswordUser(auth, creds.Username, creds.Password); err != nil {
+				return false, err
 			}
 		} else {
 			return false, errors.New("authentication required")
@@ -313,13 +233,19 @@ func (c *ReposController) InitGitServer(auth *AuthController) *gitkit.Server {
 			strings.Contains(req.Request.URL.Query().Get("service"), "git-receive-pack")
 		isPull := strings.Contains(req.Request.URL.Path, "git-upload-pack") ||
 			strings.Contains(req.Request.URL.Query().Get("service"), "git-upload-pack")
+		if !isPush && !isPull {
+			return true, nil
+		}
+		if err := authorizeGit(user, repo, isPush); err != nil {
+			return false, err
+		}
 
-		// Check access based on operation
+		// Note where each branch was, so webhooks can say what the push changed.
+		// Only the pack upload itself pushes; the ref advertisement before it doesn't.
 		if isPush {
-			// Push operation - admin only
-			if !user.IsAdmin {
-				log.Printf("Push denied for non-admin user %s to repo %s", user.Email, repoID)
-				return false, errors.New("only admins can push to repositories")
+			var before map[string]string
+			if req.Request.Method == http.MethodPost {
+				before = branchHeads(repo)
 			}
 
 			// Schedule a workspace update after the push completes
@@ -327,18 +253,8 @@ func (c *ReposController) InitGitServer(auth *AuthController) *gitkit.Server {
 			go func() {
 				// Wait a moment for the push to complete
 				time.Sleep(2 * time.Second)
-
-				// Update the working copy in Code Server
-				if err := services.Coder.UpdateRepository(repoID); err != nil {
-					log.Printf("Failed to update repository in Code Server after push: %v", err)
-				}
+				gitPushed(repo, user.ID, before)
 			}()
-		} else if isPull {
-			// Pull/clone operation - check repository visibility
-			if repo.Visibility != "public" && !user.IsAdmin {
-				log.Printf("Pull denied for non-admin user %s to private repo %s", user.Email, repoID)
-				return false, errors.New("access denied - private repository")
-			}
 		}
 
 		return true, nil
@@ -351,6 +267,134 @@ func (c *ReposController) InitGitServer(auth *AuthController) *gitkit.Server {
 	return git
 }
 
+// authorizeGit checks a user may pull from, or push to, a repository. The
+// same rules apply over HTTP and SSH.
+func authorizeGit(user *authentication.User, repo *models.Repository, push bool) error {
+	if push {
+		// Push operation - write access, as an admin or through a team
+		if err := models.CheckRepoAccess(user, repo.ID, models.RoleWrite); err != nil {
+			log.Printf("Push denied for user %s to repo %s: %v", user.Email, repo.ID, err)
+			return err
+		}
+		return nil
+	}
+
+	// Pull/clone operation - public repos or read access
+	if err := models.CheckRepoAccess(user, repo.ID, models.RoleRead); err != nil {
+		log.Printf("Pull denied for user %s to repo %s: %v", user.Email, repo.ID, err)
+		return err
+	}
+	return nil
+}
+
+// gitPushed follows up on a finished push: webhooks for each branch that
+// moved since before, when known, then the Code Server copy, the search
+// indexes and the open pull requests' merge checks are refreshed
+func gitPushed(repo *models.Repository, userID string, before map[string]string) {
+	if before != nil {
+		after := branchHeads(repo)
+		for branch, head := range after {
+			if before[branch] != head {
+				services.EmitPushWebhook(repo, branch, before[branch], head, userID)
+				go services.Workflows.Pushed(repo, branch, head, userID)
+				pushedCommits(repo, branch, before[branch], head, userID)
+			}
+		}
+		for branch, head := range before {
+			if _, ok := after[branch]; !ok {
+				services.EmitPushWebhook(repo, branch, head, "", userID)
+			}
+		}
+	}
+
+	// Update the working copy in Code Server
+	if err := services.Coder.UpdateRepository(repo.ID); err != nil {
+		log.Printf("Failed to update repository in Code Server after push: %v", err)
+	}
+
+	// Re-index the files the push changed
+	services.Indexer.RefreshAsync(repo.ID)
+	services.Semantic.IndexAsync(repo.ID)
+	services.Search.SyncAsync(repo.ID)
+
+	// Open pull requests may have gained commits, or conflicts
+	if err := models.RefreshMergeChecks(repo.ID); err != nil {
+		log.Printf("Failed to check pull requests of %s after push: %v", repo.ID, err)
+	}
+}
+
+// pushedCommits records the issues and pull requests mentioned by the
+// commits a push brought to a branch. On the default branch the issues
+// they fix, as in "fixes #12", are closed.
+func pushedCommits(repo *models.Repository, branch, from, to, userID string) {
+	var commits []*models.Commit
+	var err error
+	if from == "" {
+		commits, err = repo.GetCommits(to, 20)
+	} else {
+		commits, err = repo.GetCommitsBetween(from, to)
+	}
+	if err != nil {
+		log.Printf("Failed to list commits pushed to %s: %v", branch, err)
+		return
+	}
+
+	models.RecordCommitReferences(repo.ID, commits, userID)
+	if branch != repo.GetDefaultBranch() {
+		return
+	}
+	for _, commit := range commits {
+		for _, issue := range models.CloseFixedIssues(repo.ID, "commit "+commit.ShortHash, userID, commit.Message) {
+			services.EmitIssueWebhook("closed", issue, userID)
+		}
+	}
+}
+
+// gitPasswordUser authenticates git over HTTP, with an access token's ID
+// and value or a username and password
+func gitPasswordUser(auth *AuthController, username, password string) (*authentication.User, error) {
+	// Check if it's token-based auth (using token ID as username, token value as password)
+	token, err := models.AccessTokens.Get(username)
+	if err == nil && token != nil && token.Token == password {
+		// Token matches, get the user associated with the token
+		user, err := auth.Users.Get(token.UserID)
+		if err != nil {
+			return nil, errors.New("invalid token user")
+		}
+		log.Printf("Token auth successful - ID: %s", username)
+		return user, nil
+	}
+
+	// Fall back to username/password authentication, unless users must
+	// sign in through single sign-on
+	if auth.PasswordLoginDisabled() {
+		return nil, errors.New("password authentication is turned off, use an access token or SSH key")
+	}
+	user, err := auth.GetUser(username)
+	if err != nil {
+		return nil, errors.New("invalid username or password")
+	}
+	if !user.VerifyPassword(password) {
+		return nil, errors.New("invalid username or password")
+	}
+	log.Printf("User auth successful for %s", username)
+	return user, nil
+}
+
+// branchHeads returns the commit each of the repository's branches points at
+func branchHeads(repo *models.Repository) map[string]string {
+	heads := map[string]string{}
+	branches, err := repo.GetBranches()
+	if err != nil {
+		log.Printf("Failed to read branches of %s: %v", repo.ID, err)
+		return heads
+	}
+	for _, branch := range branches {
+		heads[branch.Name] = branch.LastCommit
+	}
+	return heads
+}
+
 // IsGitRequest checks if the current request is a Git operation
 func (c *ReposController) IsGitRequest() bool {
 	path := c.Request.URL.Path
@@ -0,0 +1,167 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"workspace/middleware"
+	"workspace/models"
+
+	"github.com/The-Skyscape/devtools/pkg/application"
+)
+
+// guestCookieName holds the token of the guest link a visitor opened
+const guestCookieName = "workspace_guest"
+
+// PublicAdminOrGuest - AccessCheck like PublicOrAdmin that also admits
+// visitors holding a guest link for the repository, logging each page they view
+func PublicAdminOrGuest() application.AccessCheck {
+	publicOrAdmin := PublicOrAdmin()
+	return func(app *application.App, w http.ResponseWriter, r *http.Request) bool {
+		link := guestLinkForRequest(r)
+		if link == nil {
+			return publicOrAdmin(app, w, r)
+		}
+
+		if err := link.RecordAccess(r.URL.Path, middleware.ClientIP(r), r.UserAgent()); err != nil {
+			log.Printf("Failed to log guest access to %s: %v", link.RepoID, err)
+		}
+		return true
+	}
+}
+
+// guestLinkForRequest returns the guest link in the request's cookie when it
+// is still active and scoped to the repository being read
+func guestLinkForRequest(r *http.Request) *models.GuestLink {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return nil
+	}
+
+	cookie, err := r.Cookie(guestCookieName)
+	if err != nil {
+		return nil
+	}
+
+	link, err := models.GetGuestLink(cookie.Value)
+	if err != nil || link.RepoID != r.PathValue("id") {
+		return nil
+	}
+	return link
+}
+
+// IsGuest returns true when the page is being viewed through a guest link
+func (c *ReposController) IsGuest() bool {
+	return c.CurrentUser() == nil && guestLinkForRequest(c.Request) != nil
+}
+
+// GuestLinks returns the guest links created for the current repository
+func (c *ReposController) GuestLinks() ([]*models.GuestLink, error) {
+	repo, err := c.CurrentRepo()
+	if err != nil {
+		return nil, err
+	}
+	return models.GuestLinksForRepo(repo.ID)
+}
+
+// openGuestLink handles GET /guest/{token}, remembering the link in a cookie
+// and sending the visitor to the repository
+func (c *ReposController) openGuestLink(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+
+	token := r.PathValue("token")
+	link, err := models.GetGuestLink(token)
+	if err != nil {
+		c.App.Render(w, r, "error-404.html", nil)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     guestCookieName,
+		Value:    token,
+		Path:     "/",
+		Expires:  link.ExpiresAt,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	c.Redirect(w, r, fmt.Sprintf("/repos/%s/files", link.RepoID))
+}
+
+// createGuestLink handles POST /repos/{id}/guest-links
+func (c *ReposController) createGuestLink(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	repo, err := c.getCurrentRepoFromRequest(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	// Admin already verified by route middleware
+	auth := c.App.Use("auth").(*AuthController)
+	user, _, err := auth.Authenticate(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	days, err := strconv.Atoi(r.FormValue("expires_days"))
+	if err != nil {
+		c.RenderError(w, r, errors.New("expiry must be a number of days"))
+		return
+	}
+
+	link, token, err := models.CreateGuestLink(repo.ID, r.FormValue("label"), user.ID, time.Duration(days)*24*time.Hour)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	models.LogActivity("guest_link_created", fmt.Sprintf("Created guest link for %s", repo.Name),
+		fmt.Sprintf("Read-only link for %s expires %s", link.Label, link.ExpiresAt.Format("Jan 2, 2006")),
+		user.ID, repo.ID, "guest_link", link.ID)
+
+	c.Render(w, r, "guest-link-created.html", map[string]any{
+		"Link": link,
+		"URL":  fmt.Sprintf("%s/guest/%s", c.HostURL(), token),
+	})
+}
+
+// revokeGuestLink handles POST /repos/{id}/guest-links/{linkID}/revoke
+func (c *ReposController) revokeGuestLink(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	repo, err := c.getCurrentRepoFromRequest(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	// Admin already verified by route middleware
+	auth := c.App.Use("auth").(*AuthController)
+	user, _, err := auth.Authenticate(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	link, err := models.GuestLinks.Get(r.PathValue("linkID"))
+	if err != nil || link.RepoID != repo.ID {
+		c.RenderError(w, r, errors.New("guest link not found"))
+		return
+	}
+
+	if err := link.Revoke(user.ID); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	models.LogActivity("guest_link_revoked", fmt.Sprintf("Revoked guest link for %s", repo.Name),
+		fmt.Sprintf("Read-only link for %s no longer works", link.Label),
+		user.ID, repo.ID, "guest_link", link.ID)
+
+	c.Redirect(w, r, fmt.Sprintf("/repos/%s/settings", repo.ID))
+}
@@ -0,0 +1,94 @@
+package controllers
+
+import (
+	"fmt"
+	"strings"
+
+	"workspace/models"
+)
+
+// healthTrendDays is how much history the insights charts show
+const healthTrendDays = 90
+
+// HealthTrendLine is one series of the health trend chart
+type HealthTrendLine struct {
+	Name   string
+	Color  string
+	Points string // SVG polyline points in a 100x40 view box
+}
+
+// RepoHealth computes the current repository's health and records it as
+// today's snapshot for the trend chart
+func (c *ReposController) RepoHealth() (*models.RepoHealth, error) {
+	repo, err := c.CurrentRepo()
+	if err != nil {
+		return nil, err
+	}
+
+	health, err := repo.ComputeHealth()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := models.RecordHealthSnapshot(repo.ID, health); err != nil {
+		return nil, err
+	}
+	return health, nil
+}
+
+// RepoHealthSummary returns the last recorded health score, which is cheap
+// enough to show on every repository page
+func (c *ReposController) RepoHealthSummary() *models.RepoHealthSnapshot {
+	repo, err := c.CurrentRepo()
+	if err != nil {
+		return nil
+	}
+	snapshot, _ := models.GetLatestHealthSnapshot(repo.ID)
+	return snapshot
+}
+
+// RepoHealthHistory returns the daily health snapshots shown on the chart
+func (c *ReposController) RepoHealthHistory() ([]*models.RepoHealthSnapshot, error) {
+	repo, err := c.CurrentRepo()
+	if err != nil {
+		return nil, err
+	}
+	return models.GetHealthHistory(repo.ID, healthTrendDays)
+}
+
+// RepoHealthTrend returns the overall score and each metric as chart lines
+func (c *ReposController) RepoHealthTrend() ([]*HealthTrendLine, error) {
+	history, err := c.RepoHealthHistory()
+	if err != nil || len(history) < 2 {
+		return nil, err
+	}
+
+	series := []struct {
+		name, color string
+		score       func(*models.RepoHealthSnapshot) int
+	}{
+		{"Overall", "#3b82f6", func(s *models.RepoHealthSnapshot) int { return s.Score }},
+		{"CI Stability", "#10b981", func(s *models.RepoHealthSnapshot) int { return s.CIScore }},
+		{"Open Issue Age", "#f59e0b", func(s *models.RepoHealthSnapshot) int { return s.IssueScore }},
+		{"Review Latency", "#8b5cf6", func(s *models.RepoHealthSnapshot) int { return s.ReviewScore }},
+		{"Test Coverage", "#ec4899", func(s *models.RepoHealthSnapshot) int { return s.CoverageScore }},
+		{"Stale Branches", "#64748b", func(s *models.RepoHealthSnapshot) int { return s.BranchScore }},
+	}
+
+	lines := make([]*HealthTrendLine, 0, len(series))
+	for _, s := range series {
+		var points []string
+		for i, snapshot := range history {
+			score := s.score(snapshot)
+			if score < 0 {
+				continue // Metric had no data that day
+			}
+			x := float64(i) * 100 / float64(len(history)-1)
+			y := 40 - float64(score)*0.4
+			points = append(points, fmt.Sprintf("%.1f,%.1f", x, y))
+		}
+		if len(points) > 1 {
+			lines = append(lines, &HealthTrendLine{Name: s.name, Color: s.color, Points: strings.Join(points, " ")})
+		}
+	}
+	return lines, nil
+}
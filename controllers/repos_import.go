@@ -0,0 +1,159 @@
+package controllers
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"workspace/internal/sse"
+	"workspace/models"
+	"workspace/services"
+)
+
+// importPollInterval is how often an import's progress is sent to the browser
+const importPollInterval = 500 * time.Millisecond
+
+// importRepository handles POST /repos/import, creating a repository and
+// cloning a Git URL into it in the background. It returns the import's
+// progress card, which follows the clone over SSE.
+func (c *ReposController) importRepository(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	auth := c.App.Use("auth").(*AuthController)
+	user, _, err := auth.Authenticate(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	gitURL := strings.TrimSpace(r.FormValue("git_url"))
+	if err := models.ValidateImportURL(gitURL); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	name := cmp.Or(strings.TrimSpace(r.FormValue("name")), models.ImportName(gitURL))
+	if name == "" {
+		c.RenderError(w, r, errors.New("repository name is required"))
+		return
+	}
+
+	importIssues := r.FormValue("import_issues") == "true"
+	if importIssues {
+		if u, err := url.Parse(gitURL); err != nil || u.Hostname() != "github.com" {
+			c.RenderError(w, r, errors.New("issues and pull requests can only be imported from GitHub HTTPS URLs"))
+			return
+		}
+		if _, err := models.GetGitHubOAuthToken(user.ID); err != nil {
+			c.RenderError(w, r, errors.New("connect your GitHub account to import issues and pull requests"))
+			return
+		}
+	}
+
+	// Users over their storage quota can't add repositories
+	if err := models.CheckQuota(user.ID, models.UsageStorage); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	repo, err := models.CreateRepository(name, r.FormValue("description"), r.FormValue("visibility"), user.ID)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	imp, err := services.Importer.Start(repo, user, services.ImportOptions{
+		SourceURL: gitURL,
+		Issues:    importIssues,
+	})
+	if err != nil {
+		models.DeleteRepository(repo.ID)
+		c.RenderError(w, r, err)
+		return
+	}
+
+	c.Render(w, r, "repo-import-progress.html", imp)
+}
+
+// streamImport handles GET /repos/{id}/import/events, sending an import's
+// progress until it finishes
+func (c *ReposController) streamImport(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+
+	repoID := r.PathValue("id")
+	if _, ok := services.Importer.Status(repoID); !ok {
+		http.Error(w, "No import in progress for this repository", http.StatusNotFound)
+		return
+	}
+
+	auth := c.App.Use("auth").(*AuthController)
+	user, _, err := auth.Authenticate(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	stream, err := sse.Streams.Open(w, r, "imports", user.ID)
+	if err != nil {
+		return
+	}
+	defer stream.Close()
+
+	ticker := time.NewTicker(importPollInterval)
+	defer ticker.Stop()
+
+	var last string
+	for {
+		select {
+		case <-ticker.C:
+			imp, _ := services.Importer.Status(repoID)
+			if progress := renderImport(imp); progress != last {
+				stream.Send("import-progress", progress)
+				last = progress
+			}
+			if imp.Done {
+				stream.Send("import-done", "")
+				return
+			}
+		case <-stream.Heartbeat():
+			stream.Ping()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// renderImport renders an import's progress for the import card
+func renderImport(imp services.RepoImport) string {
+	name := template.HTMLEscapeString(imp.RepoName)
+	link := fmt.Sprintf(`<a href="/repos/%s" class="btn btn-primary btn-sm">Open %s</a>`,
+		template.HTMLEscapeString(imp.RepoID), name)
+
+	switch {
+	case imp.Done && imp.Error != "":
+		var b strings.Builder
+		fmt.Fprintf(&b, `<div class="alert alert-error text-sm whitespace-pre-wrap">%s</div>`,
+			template.HTMLEscapeString(imp.Error))
+		// A failed clone removes the repository; later steps leave it in place
+		if _, err := models.Repositories.Get(imp.RepoID); err == nil {
+			fmt.Fprintf(&b, `<div class="mt-2">%s</div>`, link)
+		}
+		return b.String()
+	case imp.Done:
+		return fmt.Sprintf(`<div class="flex items-center justify-between gap-2"><span class="text-sm text-success">%s is ready</span>%s</div>`,
+			name, link)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<div class="flex items-center gap-2 text-sm"><span class="loading loading-spinner loading-xs text-primary"></span><span>%s</span></div>`,
+		template.HTMLEscapeString(imp.Step))
+	if imp.Detail != "" {
+		fmt.Fprintf(&b, `<div class="font-mono text-xs text-base-content/60 truncate">%s</div>`,
+			template.HTMLEscapeString(imp.Detail))
+	}
+	return b.String()
+}
@@ -0,0 +1,250 @@
+package controllers
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"workspace/models"
+
+	"github.com/The-Skyscape/devtools/pkg/authentication"
+)
+
+// lfsMediaType is the content type of Git LFS API requests and responses
+const lfsMediaType = "application/vnd.git-lfs+json"
+
+// lfsPointer identifies an LFS object in batch requests and responses
+type lfsPointer struct {
+	OID  string `json:"oid"`
+	Size int64  `json:"size"`
+}
+
+// lfsAction tells the client where to send or fetch an object
+type lfsAction struct {
+	Href   string            `json:"href"`
+	Header map[string]string `json:"header,omitempty"`
+}
+
+// lfsObjectError explains why an object in a batch can't be transferred
+type lfsObjectError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// lfsBatchObject is one object of a batch response
+type lfsBatchObject struct {
+	lfsPointer
+	Authenticated bool                 `json:"authenticated,omitempty"`
+	Actions       map[string]lfsAction `json:"actions,omitempty"`
+	Error         *lfsObjectError      `json:"error,omitempty"`
+}
+
+// RepoLFSUsage returns how many Git LFS objects the current repository has
+// and their total size
+func (c *ReposController) RepoLFSUsage() (models.LFSUsage, error) {
+	repo, err := c.CurrentRepo()
+	if err != nil {
+		return models.LFSUsage{}, err
+	}
+	return repo.LFSUsage()
+}
+
+// lfsAccess authenticates a Git LFS request with the same credentials as
+// git over HTTP. Anyone may download from public repositories; private
+// repositories need read access and uploads write access, as with git
+// itself. The user is nil for anonymous downloads.
+func (c *ReposController) lfsAccess(w http.ResponseWriter, r *http.Request, upload bool) (*models.Repository, *authentication.User, bool) {
+	repo, err := models.Repositories.Get(strings.TrimSuffix(r.PathValue("id"), ".git"))
+	if err != nil {
+		lfsError(w, http.StatusNotFound, "repository not found")
+		return nil, nil, false
+	}
+
+	username, password, hasAuth := r.BasicAuth()
+	if !hasAuth {
+		if !upload && repo.Visibility == "public" {
+			return repo, nil, true
+		}
+		w.Header().Set("LFS-Authenticate", `Basic realm="Git LFS"`)
+		lfsError(w, http.StatusUnauthorized, "authentication required")
+		return nil, nil, false
+	}
+
+	auth := c.App.Use("auth").(*AuthController)
+	user, err := gitPasswordUser(auth, username, password)
+	if err != nil {
+		w.Header().Set("LFS-Authenticate", `Basic realm="Git LFS"`)
+		lfsError(w, http.StatusUnauthorized, err.Error())
+		return nil, nil, false
+	}
+	role := models.RoleRead
+	if upload {
+		role = models.RoleWrite
+	}
+	if err := models.CheckRepoAccess(user, repo.ID, role); err != nil {
+		lfsError(w, http.StatusForbidden, "access denied")
+		return nil, nil, false
+	}
+	return repo, user, true
+}
+
+// lfsBatch handles POST /repo/{id}/info/lfs/objects/batch, telling the
+// client where to upload or download each object it asked about
+func (c *ReposController) lfsBatch(w http.ResponseWriter, r *http.Request) {
+	var batch struct {
+		Operation string       `json:"operation"`
+		Transfers []string     `json:"transfers"`
+		Objects   []lfsPointer `json:"objects"`
+	}
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&batch); err != nil {
+		lfsError(w, http.StatusBadRequest, "invalid batch request")
+		return
+	}
+	if batch.Operation != "upload" && batch.Operation != "download" {
+		lfsError(w, http.StatusBadRequest, "unknown operation "+batch.Operation)
+		return
+	}
+	if len(batch.Transfers) > 0 && !slices.Contains(batch.Transfers, "basic") {
+		lfsError(w, http.StatusNotImplemented, "only the basic transfer adapter is supported")
+		return
+	}
+
+	repo, _, ok := c.lfsAccess(w, r, batch.Operation == "upload")
+	if !ok {
+		return
+	}
+
+	// The client sends the same credentials with the transfers themselves
+	var header map[string]string
+	if authorization := r.Header.Get("Authorization"); authorization != "" {
+		header = map[string]string{"Authorization": authorization}
+	}
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	objectsURL := scheme + "://" + r.Host + "/repo/" + r.PathValue("id") + "/info/lfs/objects/"
+
+	objects := make([]lfsBatchObject, 0, len(batch.Objects))
+	for _, pointer := range batch.Objects {
+		object := lfsBatchObject{lfsPointer: pointer, Authenticated: true}
+		stored, err := models.GetLFSObject(repo.ID, pointer.OID)
+		switch {
+		case !models.ValidLFSOID(pointer.OID):
+			object.Error = &lfsObjectError{Code: http.StatusUnprocessableEntity, Message: "invalid object ID"}
+		case batch.Operation == "download" && err != nil:
+			object.Error = &lfsObjectError{Code: http.StatusNotFound, Message: "object does not exist"}
+		case batch.Operation == "download":
+			object.Size = stored.Size
+			object.Actions = map[string]lfsAction{
+				"download": {Href: objectsURL + pointer.OID, Header: header},
+			}
+		case pointer.Size > models.MaxLFSObjectSize:
+			object.Error = &lfsObjectError{Code: http.StatusUnprocessableEntity, Message: "object is larger than the size limit"}
+		case err != nil:
+			// Objects the repository already has need no upload
+			object.Actions = map[string]lfsAction{
+				"upload": {Href: objectsURL + pointer.OID, Header: header},
+				"verify": {Href: objectsURL + "verify", Header: header},
+			}
+		}
+		objects = append(objects, object)
+	}
+
+	lfsJSON(w, http.StatusOK, map[string]any{"transfer": "basic", "objects": objects})
+}
+
+// lfsDownload handles GET /repo/{id}/info/lfs/objects/{oid}
+func (c *ReposController) lfsDownload(w http.ResponseWriter, r *http.Request) {
+	repo, _, ok := c.lfsAccess(w, r, false)
+	if !ok {
+		return
+	}
+
+	object, err := models.GetLFSObject(repo.ID, r.PathValue("oid"))
+	if err != nil {
+		lfsError(w, http.StatusNotFound, "object does not exist")
+		return
+	}
+	content, err := object.Open()
+	if err != nil {
+		log.Printf("LFS: Failed to open %s in %s: %v", object.OID, repo.ID, err)
+		lfsError(w, http.StatusInternalServerError, "object could not be read")
+		return
+	}
+	defer content.Close()
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.FormatInt(object.Size, 10))
+	io.Copy(w, content)
+}
+
+// lfsUpload handles PUT /repo/{id}/info/lfs/objects/{oid}, storing the
+// object once its content matches the ID
+func (c *ReposController) lfsUpload(w http.ResponseWriter, r *http.Request) {
+	repo, user, ok := c.lfsAccess(w, r, true)
+	if !ok {
+		return
+	}
+	if r.ContentLength < 0 {
+		lfsError(w, http.StatusLengthRequired, "Content-Length is required")
+		return
+	}
+
+	if _, err := models.StoreLFSObject(repo.ID, r.PathValue("oid"), r.ContentLength, r.Body, user.ID); err != nil {
+		lfsError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// lfsVerify handles POST /repo/{id}/info/lfs/objects/verify, confirming an
+// upload arrived whole
+func (c *ReposController) lfsVerify(w http.ResponseWriter, r *http.Request) {
+	repo, _, ok := c.lfsAccess(w, r, true)
+	if !ok {
+		return
+	}
+
+	var pointer lfsPointer
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<10)).Decode(&pointer); err != nil {
+		lfsError(w, http.StatusBadRequest, "invalid verify request")
+		return
+	}
+	object, err := models.GetLFSObject(repo.ID, pointer.OID)
+	if err != nil {
+		lfsError(w, http.StatusNotFound, "object does not exist")
+		return
+	}
+	if object.Size != pointer.Size {
+		lfsError(w, http.StatusUnprocessableEntity, "object size does not match")
+		return
+	}
+	lfsJSON(w, http.StatusOK, map[string]any{})
+}
+
+// lfsVerifyLocks handles POST /repo/{id}/info/lfs/locks/verify. File locking
+// isn't supported, so there are never locks in the way of a push; answering
+// keeps git-lfs from warning about it on every push.
+func (c *ReposController) lfsVerifyLocks(w http.ResponseWriter, r *http.Request) {
+	if _, _, ok := c.lfsAccess(w, r, true); !ok {
+		return
+	}
+	lfsJSON(w, http.StatusOK, map[string]any{"ours": []any{}, "theirs": []any{}})
+}
+
+// lfsJSON writes a Git LFS API response
+func lfsJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", lfsMediaType)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// lfsError writes a Git LFS API error
+func lfsError(w http.ResponseWriter, status int, message string) {
+	lfsJSON(w, status, map[string]string{"message": message})
+}
@@ -0,0 +1,60 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+
+	"workspace/models"
+)
+
+// MirrorStatus returns the current repository's last push to the mirror
+// organization, or nil when it hasn't been mirrored
+func (c *ReposController) MirrorStatus() *models.RepoMirror {
+	repo, err := c.CurrentRepo()
+	if err != nil {
+		return nil
+	}
+	mirror, _ := models.RepoMirrorFor(repo.ID)
+	return mirror
+}
+
+// MirrorEnabled reports whether repositories are mirrored to another host
+func (c *ReposController) MirrorEnabled() bool {
+	settings, err := models.GetSettings()
+	return err == nil && settings.MirrorEnabled()
+}
+
+// updateMirrorExclusion handles POST /repos/{id}/settings/mirror, leaving
+// the repository out of the mirror export or putting it back
+func (c *ReposController) updateMirrorExclusion(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	repo, err := c.getCurrentRepoFromRequest(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	// Admin already verified by route middleware
+	auth := c.App.Use("auth").(*AuthController)
+	user, _, err := auth.Authenticate(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	repo.MirrorExcluded = r.FormValue("excluded") == "true"
+	if err := models.Repositories.Update(repo); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	change := "included in"
+	if repo.MirrorExcluded {
+		change = "excluded from"
+	}
+	models.LogActivity("repo_updated", fmt.Sprintf("Updated mirror export for %s", repo.Name),
+		fmt.Sprintf("Repository is now %s the mirror export", change),
+		user.ID, repo.ID, "repository", "")
+
+	c.Refresh(w, r)
+}
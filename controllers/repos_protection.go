@@ -0,0 +1,107 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"workspace/models"
+)
+
+// BranchProtections returns the current repository's protected branch rules
+func (c *ReposController) BranchProtections() ([]*models.BranchProtection, error) {
+	repo, err := c.CurrentRepo()
+	if err != nil {
+		return nil, err
+	}
+	return models.GetBranchProtections(repo.ID)
+}
+
+// MaxRequiredApprovals is the most approvals a protection rule can require
+func (c *ReposController) MaxRequiredApprovals() int {
+	return models.MaxRequiredApprovals
+}
+
+// createBranchProtection handles POST /repos/{id}/settings/branches
+func (c *ReposController) createBranchProtection(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	repo, err := c.getCurrentRepoFromRequest(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	// Admin already verified by route middleware
+	auth := c.App.Use("auth").(*AuthController)
+	user, _, err := auth.Authenticate(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	approvals := 0
+	if value := strings.TrimSpace(r.FormValue("required_approvals")); value != "" {
+		if approvals, err = strconv.Atoi(value); err != nil {
+			c.RenderError(w, r, errors.New("required approvals must be a number"))
+			return
+		}
+	}
+
+	rule, err := models.SaveBranchProtection(&models.BranchProtection{
+		RepoID:            repo.ID,
+		Pattern:           r.FormValue("pattern"),
+		BlockPushes:       r.FormValue("block_pushes") == "on",
+		BlockForcePushes:  r.FormValue("block_force_pushes") == "on",
+		RequiredApprovals: approvals,
+		RequiredChecks:    r.FormValue("required_checks"),
+	}, user.ID)
+	if rule == nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	models.LogActivity("repo_updated", fmt.Sprintf("Protected %s in %s", rule.Pattern, repo.Name),
+		"Branch protection rule added", user.ID, repo.ID, "repository", "")
+
+	// The rule is saved even when the hook couldn't be written
+	if err != nil {
+		c.RenderError(w, r, fmt.Errorf("rule saved, but pushes are not checked: %w", err))
+		return
+	}
+	c.Redirect(w, r, fmt.Sprintf("/repos/%s/settings", repo.ID))
+}
+
+// deleteBranchProtection handles POST /repos/{id}/settings/branches/{ruleID}/delete
+func (c *ReposController) deleteBranchProtection(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	repo, err := c.getCurrentRepoFromRequest(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	// Admin already verified by route middleware
+	auth := c.App.Use("auth").(*AuthController)
+	user, _, err := auth.Authenticate(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	rule, err := models.BranchProtections.Get(r.PathValue("ruleID"))
+	if err != nil || rule.RepoID != repo.ID {
+		c.RenderError(w, r, errors.New("branch protection rule not found"))
+		return
+	}
+	if err := models.DeleteBranchProtection(rule); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	models.LogActivity("repo_updated", fmt.Sprintf("Unprotected %s in %s", rule.Pattern, repo.Name),
+		"Branch protection rule removed", user.ID, repo.ID, "repository", "")
+
+	c.Redirect(w, r, fmt.Sprintf("/repos/%s/settings", repo.ID))
+}
@@ -0,0 +1,142 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+
+	"workspace/models"
+	"workspace/services"
+)
+
+// registryPath splits a registry API path into the image's full name and
+// the endpoint, as in /v2/shop/app/manifests/latest
+var registryPath = regexp.MustCompile(`^/v2/(.+?)/(manifests|blobs|tags|referrers)/`)
+
+// RegistryImages returns the images in the current repository's namespace
+// of the container registry
+func (c *ReposController) RegistryImages() ([]*models.RegistryImage, error) {
+	repo, err := c.CurrentRepo()
+	if err != nil {
+		return nil, err
+	}
+	return services.Registry.Images(repo.ID)
+}
+
+// RegistryHost is the host images are pulled from and pushed to, which is
+// the workspace's own
+func (c *ReposController) RegistryHost() string {
+	if c.Request == nil {
+		return ""
+	}
+	return c.Request.Host
+}
+
+// registryAPI handles the registry API under /v2/, passing requests on to
+// the registry once their credentials allow them. Credentials are the same
+// as for git over HTTP: anyone signed in may pull from public repositories,
+// while pushing, deleting and pulling from private repositories are limited
+// to admins.
+func (c *ReposController) registryAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Docker-Distribution-API-Version", "registry/2.0")
+
+	// Docker only sends credentials once it has been asked for them, so
+	// every request starts with a challenge
+	username, password, hasAuth := r.BasicAuth()
+	if !hasAuth {
+		registryError(w, http.StatusUnauthorized, "UNAUTHORIZED", "authentication required")
+		return
+	}
+	auth := c.App.Use("auth").(*AuthController)
+	user, err := gitPasswordUser(auth, username, password)
+	if err != nil {
+		registryError(w, http.StatusUnauthorized, "UNAUTHORIZED", err.Error())
+		return
+	}
+
+	switch {
+	case r.URL.Path == "/v2/" || r.URL.Path == "/v2":
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte("{}"))
+		return
+	case r.URL.Path == "/v2/_catalog":
+		if !user.IsAdmin {
+			registryError(w, http.StatusForbidden, "DENIED", "only admins can list every image")
+			return
+		}
+		services.Registry.ServeHTTP(w, r)
+		return
+	}
+
+	match := registryPath.FindStringSubmatch(r.URL.Path)
+	if match == nil {
+		registryError(w, http.StatusNotFound, "NAME_UNKNOWN", "unknown endpoint")
+		return
+	}
+	repoID, _, ok := models.SplitRegistryName(match[1])
+	if !ok {
+		registryError(w, http.StatusBadRequest, "NAME_INVALID",
+			"images are named after a repository's ID, as in "+r.Host+"/repo-id/app")
+		return
+	}
+	repo, err := models.Repositories.Get(repoID)
+	if err != nil {
+		registryError(w, http.StatusNotFound, "NAME_UNKNOWN", "repository not found")
+		return
+	}
+
+	role := models.RoleRead
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		role = models.RoleWrite
+	}
+	if err := models.CheckRepoAccess(user, repo.ID, role); err != nil {
+		registryError(w, http.StatusForbidden, "DENIED", "access denied")
+		return
+	}
+	services.Registry.ServeHTTP(w, r)
+}
+
+// deleteRegistryTag handles POST /repos/{id}/images/delete, deleting an
+// image's tag and any other tag of the same build
+func (c *ReposController) deleteRegistryTag(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	repo, err := c.getCurrentRepoFromRequest(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	// Admin already verified by route middleware
+	auth := c.App.Use("auth").(*AuthController)
+	user, _, err := auth.Authenticate(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	image, tag := r.FormValue("image"), r.FormValue("tag")
+	if err := services.Registry.DeleteTag(repo.ID, image, tag); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	models.LogActivity("image_deleted", fmt.Sprintf("Deleted image %s:%s", image, tag),
+		fmt.Sprintf("Its layers are removed from %s at the next garbage collection", repo.Name),
+		user.ID, repo.ID, "image", repo.ID+"/"+image)
+
+	c.Refresh(w, r)
+}
+
+// registryError writes an error in the registry API's format, challenging
+// the client for credentials when it isn't authorized
+func registryError(w http.ResponseWriter, status int, code, message string) {
+	if status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Basic realm="Skyscape Registry"`)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{
+		"errors": []map[string]string{{"code": code, "message": message}},
+	})
+}
@@ -7,6 +7,9 @@ import (
 	"regexp"
 	"strings"
 
+	"workspace/internal/references"
+	"workspace/models"
+
 	"github.com/yuin/goldmark"
 	"github.com/yuin/goldmark/extension"
 	"github.com/yuin/goldmark/parser"
@@ -17,14 +20,21 @@ import (
 // This is used by templates to render markdown files like README.md
 // The content parameter comes from file objects in templates
 func (c *ReposController) RenderMarkdown(content string) template.HTML {
+	extensions := []goldmark.Extender{
+		extension.GFM,         // GitHub Flavored Markdown (tables, strikethrough, etc.)
+		extension.Linkify,     // Auto-linkify URLs
+		extension.TaskList,    // Task list support
+		extension.Typographer, // Smart punctuation
+	}
+
+	// Link mentions of the repository's issues, pull requests and commits
+	if repo, err := c.CurrentRepo(); err == nil {
+		extensions = append(extensions, references.NewLinker(models.ReferenceResolver(repo)))
+	}
+
 	// Create a new goldmark markdown processor with GitHub Flavored Markdown extensions
 	md := goldmark.New(
-		goldmark.WithExtensions(
-			extension.GFM,         // GitHub Flavored Markdown (tables, strikethrough, etc.)
-			extension.Linkify,     // Auto-linkify URLs
-			extension.TaskList,    // Task list support
-			extension.Typographer, // Smart punctuation
-		),
+		goldmark.WithExtensions(extensions...),
 		goldmark.WithParserOptions(
 			parser.WithAutoHeadingID(), // Auto-generate heading IDs for anchors
 		),
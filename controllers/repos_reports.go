@@ -0,0 +1,201 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"time"
+
+	"workspace/models"
+	"workspace/services"
+)
+
+// reportCheckInterval is how often due report schedules are looked for
+const reportCheckInterval = 10 * time.Minute
+
+// ReportSchedules returns the current repository's scheduled reports
+func (c *ReposController) ReportSchedules() ([]*models.ReportSchedule, error) {
+	repo, err := c.CurrentRepo()
+	if err != nil {
+		return nil, err
+	}
+	return models.ReportSchedulesForRepo(repo.ID)
+}
+
+// DailyReport returns the current repository's latest daily health report
+// from the AI queue, or nil when none has been written
+func (c *ReposController) DailyReport() *models.RepoReport {
+	repo, err := c.CurrentRepo()
+	if err != nil {
+		return nil
+	}
+	return models.LatestRepoReport(repo.ID, models.ReportDailyHealth)
+}
+
+// MailConfigured returns true when reports can be delivered by email
+func (c *ReposController) MailConfigured() bool {
+	return services.MailConfigured()
+}
+
+// startReportScheduler runs report schedules as they come due
+func (c *ReposController) startReportScheduler() {
+	go func() {
+		ticker := time.NewTicker(reportCheckInterval)
+		defer ticker.Stop()
+
+		for now := range ticker.C {
+			schedules, err := models.DueReportSchedules(now)
+			if err != nil {
+				log.Printf("ReposController: Failed to load due report schedules: %v", err)
+				continue
+			}
+			for _, schedule := range schedules {
+				if _, err := services.RunReport(schedule, now); err != nil {
+					log.Printf("ReposController: Failed to run report schedule %s: %v", schedule.ID, err)
+				}
+			}
+		}
+	}()
+}
+
+// createReportSchedule handles POST /repos/{id}/reports
+func (c *ReposController) createReportSchedule(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	repo, err := c.getCurrentRepoFromRequest(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	// Admin already verified by route middleware
+	auth := c.App.Use("auth").(*AuthController)
+	user, _, err := auth.Authenticate(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	schedule, err := models.CreateReportSchedule(&models.ReportSchedule{
+		RepoID:     repo.ID,
+		Kind:       r.FormValue("kind"),
+		Frequency:  r.FormValue("frequency"),
+		Format:     r.FormValue("format"),
+		Delivery:   r.FormValue("delivery"),
+		Recipients: r.FormValue("recipients"),
+		Path:       r.FormValue("path"),
+		CreatedBy:  user.ID,
+	})
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	models.LogActivity("report_scheduled", fmt.Sprintf("Scheduled %s for %s", schedule.KindName(), repo.Name),
+		fmt.Sprintf("Runs %s, first on %s", schedule.Frequency, schedule.NextRunAt.Format("Jan 2, 2006")),
+		user.ID, repo.ID, "report_schedule", schedule.ID)
+
+	c.Redirect(w, r, fmt.Sprintf("/repos/%s/settings", repo.ID))
+}
+
+// runReportSchedule handles POST /repos/{id}/reports/{scheduleID}/run,
+// generating a report now without moving the schedule
+func (c *ReposController) runReportSchedule(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	schedule, err := c.reportScheduleFromRequest(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	report, err := services.RunReport(schedule, time.Now())
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+	if report.Error != "" {
+		c.RenderError(w, r, fmt.Errorf("report generated but not delivered: %s", report.Error))
+		return
+	}
+
+	c.Redirect(w, r, fmt.Sprintf("/repos/%s/settings", schedule.RepoID))
+}
+
+// toggleReportSchedule handles POST /repos/{id}/reports/{scheduleID}/toggle
+func (c *ReposController) toggleReportSchedule(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	schedule, err := c.reportScheduleFromRequest(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	schedule.Enabled = !schedule.Enabled
+	if schedule.Enabled && schedule.NextRunAt.Before(time.Now()) {
+		// Don't catch up on the runs missed while paused
+		schedule.NextRunAt = schedule.NextRun(time.Now())
+	}
+	if err := models.ReportSchedules.Update(schedule); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	c.Redirect(w, r, fmt.Sprintf("/repos/%s/settings", schedule.RepoID))
+}
+
+// deleteReportSchedule handles POST /repos/{id}/reports/{scheduleID}/delete
+func (c *ReposController) deleteReportSchedule(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	schedule, err := c.reportScheduleFromRequest(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	if err := models.DeleteReportSchedule(schedule); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	c.Redirect(w, r, fmt.Sprintf("/repos/%s/settings", schedule.RepoID))
+}
+
+// downloadReport handles GET /repos/{id}/reports/files/{reportID}, sending
+// a generated report as Markdown or, with ?format=pdf, as a PDF
+func (c *ReposController) downloadReport(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	repo, err := c.getCurrentRepoFromRequest(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	report, err := models.RepoReports.Get(r.PathValue("reportID"))
+	if err != nil || report.RepoID != repo.ID {
+		c.RenderError(w, r, errors.New("report not found"))
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	data, contentType := services.ReportDocument(report, format)
+
+	filename := models.ReportFileName(report.Kind, format, report.PeriodEnd)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	w.Write(data)
+}
+
+// reportScheduleFromRequest returns the schedule named in the path, making
+// sure it belongs to the repository in the path
+func (c *ReposController) reportScheduleFromRequest(r *http.Request) (*models.ReportSchedule, error) {
+	repo, err := c.getCurrentRepoFromRequest(r)
+	if err != nil {
+		return nil, err
+	}
+	schedule, err := models.ReportSchedules.Get(r.PathValue("scheduleID"))
+	if err != nil || schedule.RepoID != repo.ID {
+		return nil, errors.New("report schedule not found")
+	}
+	return schedule, nil
+}
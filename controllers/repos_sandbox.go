@@ -0,0 +1,144 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"workspace/models"
+)
+
+// SandboxPolicy returns the sandbox limits for the current repository
+func (c *ReposController) SandboxPolicy() (*models.SandboxPolicy, error) {
+	repo, err := c.CurrentRepo()
+	if err != nil {
+		return nil, err
+	}
+	return models.GetSandboxPolicy(repo.ID), nil
+}
+
+// updateSandboxPolicy handles POST /repos/{id}/settings/sandbox
+func (c *ReposController) updateSandboxPolicy(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	repo, err := c.getCurrentRepoFromRequest(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	// Admin already verified by route middleware
+	auth := c.App.Use("auth").(*AuthController)
+	user, _, err := auth.Authenticate(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	policy := models.GetSandboxPolicy(repo.ID)
+
+	cpus, err := parseLimit(r.FormValue("cpus"))
+	if err != nil {
+		c.RenderError(w, r, fmt.Errorf("invalid CPU limit: %w", err))
+		return
+	}
+	memory, err := parseLimit(r.FormValue("memory_mb"))
+	if err != nil {
+		c.RenderError(w, r, fmt.Errorf("invalid memory limit: %w", err))
+		return
+	}
+	timeout, err := strconv.Atoi(r.FormValue("max_timeout_secs"))
+	if err != nil {
+		c.RenderError(w, r, errors.New("time limit must be a number of seconds"))
+		return
+	}
+
+	policy.CPUMillicores = int(math.Round(cpus * 1000))
+	policy.MemoryMB = int(memory)
+	policy.MaxTimeoutSecs = timeout
+	policy.NetworkEnabled = r.FormValue("network_enabled") == "on"
+	policy.AllowedBinaries = r.FormValue("allowed_binaries")
+	policy.WritablePaths = r.FormValue("writable_paths")
+
+	if _, err := models.SaveSandboxPolicy(policy, user.ID); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	models.LogActivity("repo_updated", fmt.Sprintf("Updated sandbox policy for %s", repo.Name),
+		"AI command sandbox limits were changed",
+		user.ID, repo.ID, "repository", "")
+
+	c.Redirect(w, r, fmt.Sprintf("/repos/%s/settings", repo.ID))
+}
+
+// ToolPolicy returns the AI tool policy for the current repository
+func (c *ReposController) ToolPolicy() (*models.ToolPolicy, error) {
+	repo, err := c.CurrentRepo()
+	if err != nil {
+		return nil, err
+	}
+	return models.GetToolPolicy(repo.ID), nil
+}
+
+// ReadOnlyAITools returns the tools a read-only repository still allows
+func (c *ReposController) ReadOnlyAITools() []string {
+	return models.ReadOnlyAITools
+}
+
+// WriteAITools returns the tools that change a repository
+func (c *ReposController) WriteAITools() []string {
+	return models.WriteAITools
+}
+
+// updateToolPolicy handles POST /repos/{id}/settings/tools
+func (c *ReposController) updateToolPolicy(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	repo, err := c.getCurrentRepoFromRequest(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	// Admin already verified by route middleware
+	auth := c.App.Use("auth").(*AuthController)
+	user, _, err := auth.Authenticate(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+	policy := models.GetToolPolicy(repo.ID)
+	policy.Access = r.FormValue("access")
+	policy.DeniedTools = strings.Join(r.Form["denied_tools"], "\n")
+
+	if _, err := models.SaveToolPolicy(policy, user.ID); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	models.LogActivity("repo_updated", fmt.Sprintf("Updated AI tool policy for %s", repo.Name),
+		fmt.Sprintf("AI tool access is now %s", policy.Access),
+		user.ID, repo.ID, "repository", "")
+
+	c.Redirect(w, r, fmt.Sprintf("/repos/%s/settings", repo.ID))
+}
+
+// parseLimit reads an optional resource limit, blank meaning unlimited
+func parseLimit(value string) (float64, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+	limit, err := strconv.ParseFloat(value, 64)
+	if err != nil || limit < 0 {
+		return 0, errors.New("must be a positive number")
+	}
+	return limit, nil
+}
@@ -0,0 +1,86 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+
+	"workspace/models"
+	"workspace/services"
+)
+
+// SBOM returns the current repository's bill of materials, or nil when
+// none has been generated
+func (c *ReposController) SBOM() *models.SBOM {
+	repo, err := c.CurrentRepo()
+	if err != nil {
+		return nil
+	}
+	return models.LatestSBOM(repo.ID)
+}
+
+// GeneratingSBOM returns true while the current repository's bill of
+// materials is being generated
+func (c *ReposController) GeneratingSBOM() bool {
+	repo, err := c.CurrentRepo()
+	if err != nil {
+		return false
+	}
+	return services.IsGeneratingSBOM(repo.ID)
+}
+
+// generateSBOM handles POST /repos/{id}/dependencies/generate
+func (c *ReposController) generateSBOM(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	repo, err := c.getCurrentRepoFromRequest(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	// Admin already verified by route middleware
+	user, _, err := c.Use("auth").(*AuthController).Authenticate(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	if !services.GenerateSBOMAsync(repo) {
+		c.RenderError(w, r, errors.New("the bill of materials is already being generated"))
+		return
+	}
+
+	models.LogActivity("sbom_generated", fmt.Sprintf("Generating the bill of materials of %s", repo.Name),
+		"Dependencies and their licenses are being read from the repository's manifests",
+		user.ID, repo.ID, "repository", "")
+
+	w.Write([]byte(`<div class="alert alert-success text-sm">Generating. Reload the page in a minute to see the dependencies.</div>`))
+}
+
+// downloadSBOM handles GET /repos/{id}/dependencies/sbom.cdx.json, sending
+// the bill of materials as CycloneDX JSON
+func (c *ReposController) downloadSBOM(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	repo, err := c.getCurrentRepoFromRequest(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	bom := models.LatestSBOM(repo.ID)
+	if bom == nil {
+		c.RenderError(w, r, errors.New("no bill of materials has been generated"))
+		return
+	}
+	data, err := bom.CycloneDX(repo)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	filename := models.SBOMFileName(repo, bom.CreatedAt)
+	w.Header().Set("Content-Type", "application/vnd.cyclonedx+json")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	w.Write(data)
+}
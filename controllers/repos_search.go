@@ -1,14 +1,17 @@
 package controllers
 
 import (
+	"context"
 	"errors"
+	"log"
 	"net/http"
-	"os"
 	"path/filepath"
-	"regexp"
 	"strings"
+	"time"
 
+	"workspace/internal/codeindex"
 	"workspace/models"
+	"workspace/services"
 
 	"github.com/The-Skyscape/devtools/pkg/authentication"
 )
@@ -23,9 +26,9 @@ type SearchResult struct {
 	Language string   // Programming language
 }
 
-// SearchCode searches for code within the current repository
-// Walks the file tree, skips binary files, and returns matches with context
-// Returns up to 100 results to prevent overwhelming the UI
+// SearchCode searches for code within the current repository's default
+// branch using its code index. Returns up to 100 results to prevent
+// overwhelming the UI.
 func (c *ReposController) SearchCode() ([]*SearchResult, error) {
 	repo, err := c.CurrentRepo()
 	if err != nil {
@@ -41,104 +44,117 @@ func (c *ReposController) SearchCode() ([]*SearchResult, error) {
 	return c.searchInRepository(repo.ID, query)
 }
 
-// searchInRepository performs the actual file search within a repository
-// This is a private helper that does the heavy lifting of searching
-func (c *ReposController) searchInRepository(repoID, query string) ([]*SearchResult, error) {
-	// Get the repository to use its Path() method
-	repo, err := models.Repositories.Get(repoID)
+// SearchSymbols returns the definitions whose name contains ?q=
+func (c *ReposController) SearchSymbols() ([]codeindex.SymbolMatch, error) {
+	repo, err := c.CurrentRepo()
 	if err != nil {
-		return nil, errors.New("repository not found")
+		return nil, err
 	}
 
-	repoPath := repo.Path()
-	var results []*SearchResult
+	query := c.SearchQuery()
+	if query == "" {
+		return nil, nil
+	}
 
-	// Compile regex for case-insensitive search
-	regex, err := regexp.Compile("(?i)" + regexp.QuoteMeta(query))
+	ix, err := services.Indexer.Get(repo)
 	if err != nil {
-		return nil, errors.New("invalid search query")
+		return nil, err
 	}
+	return ix.FindSymbols(query, 20), nil
+}
 
-	// Walk through repository files
-	err = filepath.Walk(repoPath, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return nil // Skip files with errors
-		}
-
-		// Skip directories and hidden files
-		if info.IsDir() || strings.HasPrefix(info.Name(), ".") {
-			// Skip .git directory entirely
-			if info.IsDir() && info.Name() == ".git" {
-				return filepath.SkipDir
-			}
-			return nil
-		}
+// SearchQuery returns the code search query from ?q=
+func (c *ReposController) SearchQuery() string {
+	return strings.TrimSpace(c.Request.URL.Query().Get("q"))
+}
 
-		// Skip large files (>1MB) to prevent memory issues
-		if info.Size() > 1024*1024 {
-			return nil
-		}
+// SearchMode returns "semantic" when ?mode=semantic, otherwise "text"
+func (c *ReposController) SearchMode() string {
+	if c.Request.URL.Query().Get("mode") == "semantic" {
+		return "semantic"
+	}
+	return "text"
+}
 
-		// Get relative path from repo root
-		relPath, err := filepath.Rel(repoPath, path)
-		if err != nil {
-			return nil
-		}
+// SemanticAvailable returns true when embeddings can be computed
+func (c *ReposController) SemanticAvailable() bool {
+	return services.Semantic.IsAvailable()
+}
 
-		// Read file content
-		content, err := os.ReadFile(path)
-		if err != nil || isBinary(content) {
-			return nil // Skip binary files and files we can't read
-		}
+// SemanticIndex returns the current repository's embeddings index, or nil
+// before its first indexing pass
+func (c *ReposController) SemanticIndex() *models.SemanticIndex {
+	repo, err := c.CurrentRepo()
+	if err != nil {
+		return nil
+	}
+	return models.GetSemanticIndex(repo.ID)
+}
 
-		// Search within file content
-		lines := strings.Split(string(content), "\n")
-		for lineNum, line := range lines {
-			if regex.MatchString(line) {
-				// Get context lines (2 before and 2 after)
-				context := getContextLines(lines, lineNum, 2)
-
-				result := &SearchResult{
-					File:     info.Name(),
-					Path:     relPath,
-					LineNum:  lineNum + 1, // 1-indexed
-					Line:     line,
-					Context:  context,
-					Language: getLanguageFromExtension(filepath.Ext(path)),
-				}
-				results = append(results, result)
-
-				// Limit results to prevent overwhelming response
-				if len(results) >= 100 {
-					return filepath.SkipDir
-				}
-			}
-		}
+// SemanticResults returns the indexed snippets closest in meaning to ?q=.
+// Failures are logged and shown as no results, with the index status
+// explaining why.
+func (c *ReposController) SemanticResults() []*services.SemanticResult {
+	repo, err := c.CurrentRepo()
+	if err != nil || c.SearchQuery() == "" {
+		return nil
+	}
 
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+	results, err := services.Semantic.Search(ctx, repo, c.SearchQuery(), 20)
+	if err != nil {
+		log.Printf("ReposController: Semantic search in %s failed: %v", repo.Name, err)
 		return nil
-	})
+	}
+	return results
+}
 
+// reindexRepository handles POST /repos/{id}/search/index
+func (c *ReposController) reindexRepository(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	repo, err := c.getCurrentRepoFromRequest(r)
 	if err != nil {
-		return nil, err
+		c.RenderError(w, r, err)
+		return
+	}
+	if !services.Semantic.IsAvailable() {
+		c.RenderError(w, r, errors.New("semantic search requires the AI service to be running"))
+		return
+	}
+	if services.Semantic.IsIndexing(repo.ID) {
+		c.RenderError(w, r, errors.New("repository is already being indexed"))
+		return
 	}
 
-	return results, nil
+	services.Semantic.IndexAsync(repo.ID)
+	c.Refresh(w, r)
 }
 
-// getContextLines returns context lines around a specific line number
-// Returns 'contextSize' lines before and after the target line
-func getContextLines(lines []string, lineNum, contextSize int) []string {
-	start := lineNum - contextSize
-	end := lineNum + contextSize + 1
-
-	if start < 0 {
-		start = 0
+// searchInRepository performs the actual file search within a repository
+func (c *ReposController) searchInRepository(repoID, query string) ([]*SearchResult, error) {
+	repo, err := models.Repositories.Get(repoID)
+	if err != nil {
+		return nil, errors.New("repository not found")
 	}
-	if end > len(lines) {
-		end = len(lines)
+
+	ix, err := services.Indexer.Get(repo)
+	if err != nil {
+		return nil, err
 	}
 
-	return lines[start:end]
+	var results []*SearchResult
+	for _, match := range ix.Search(query, 100) {
+		results = append(results, &SearchResult{
+			File:     filepath.Base(match.Path),
+			Path:     match.Path,
+			LineNum:  match.Line,
+			Line:     match.Text,
+			Context:  match.Context,
+			Language: getLanguageFromExtension(filepath.Ext(match.Path)),
+		})
+	}
+	return results, nil
 }
 
 // searchRepositories handles repository search via HTMX
@@ -179,8 +195,13 @@ func (c *ReposController) searchUserRepositories(user *authentication.User, quer
 		// Admin sees all
 		conditions = append(conditions, "1=1")
 	} else {
-		conditions = append(conditions, "(UserID = ? OR Visibility = 'public')")
+		condition := "UserID = ? OR Visibility = 'public'"
 		args = append(args, user.ID)
+		for _, id := range models.TeamRepoIDs(user.ID) {
+			condition += " OR ID = ?"
+			args = append(args, id)
+		}
+		conditions = append(conditions, "("+condition+")")
 	}
 
 	// Add search query if provided
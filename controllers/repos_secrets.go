@@ -0,0 +1,90 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"workspace/models"
+)
+
+// ActionSecrets returns the secrets of the current repository. Only names
+// and history are shown, never values.
+func (c *ReposController) ActionSecrets() ([]*models.ActionSecret, error) {
+	repo, err := c.CurrentRepo()
+	if err != nil {
+		return nil, err
+	}
+	return models.GetActionSecrets(repo.ID)
+}
+
+// saveActionSecret handles POST /repos/{id}/settings/secrets, creating a
+// secret or rotating the value of an existing one
+func (c *ReposController) saveActionSecret(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	repo, err := c.getCurrentRepoFromRequest(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	// Admin already verified by route middleware
+	auth := c.App.Use("auth").(*AuthController)
+	user, _, err := auth.Authenticate(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	secret, err := models.SetActionSecret(repo.ID, r.FormValue("name"), r.FormValue("value"), user.ID)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	if secret.RotatedAt.IsZero() {
+		models.LogActivity("secret_created", fmt.Sprintf("Added secret %s to %s", secret.Name, repo.Name),
+			"Actions and workflows get it as an environment variable",
+			user.ID, repo.ID, "action_secret", secret.ID)
+	} else {
+		models.LogActivity("secret_rotated", fmt.Sprintf("Rotated secret %s in %s", secret.Name, repo.Name),
+			"Later runs get the new value",
+			user.ID, repo.ID, "action_secret", secret.ID)
+	}
+
+	c.Redirect(w, r, fmt.Sprintf("/repos/%s/settings", repo.ID))
+}
+
+// deleteActionSecret handles POST /repos/{id}/settings/secrets/{secretID}/delete
+func (c *ReposController) deleteActionSecret(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	repo, err := c.getCurrentRepoFromRequest(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	secret, err := models.ActionSecrets.Get(r.PathValue("secretID"))
+	if err != nil || secret.RepoID != repo.ID {
+		c.RenderError(w, r, errors.New("secret not found"))
+		return
+	}
+
+	// Admin already verified by route middleware
+	auth := c.App.Use("auth").(*AuthController)
+	user, _, err := auth.Authenticate(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	if err := models.DeleteActionSecret(secret); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	models.LogActivity("secret_deleted", fmt.Sprintf("Removed secret %s from %s", secret.Name, repo.Name),
+		"Runs no longer get it", user.ID, repo.ID, "action_secret", secret.ID)
+
+	c.Redirect(w, r, fmt.Sprintf("/repos/%s/settings", repo.ID))
+}
@@ -0,0 +1,111 @@
+package controllers
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+
+	"workspace/internal/gitssh"
+	"workspace/models"
+
+	"github.com/The-Skyscape/devtools/pkg/database"
+	"golang.org/x/crypto/ssh"
+)
+
+// defaultSSHPort is where git over SSH listens unless GIT_SSH_PORT is set
+const defaultSSHPort = "2222"
+
+// sshPort returns the port git over SSH listens on, or nothing when
+// GIT_SSH_PORT is "off"
+func sshPort() string {
+	port := cmp.Or(os.Getenv("GIT_SSH_PORT"), defaultSSHPort)
+	if port == "off" {
+		return ""
+	}
+	return port
+}
+
+// SSHCloneURL returns the SSH address to clone the current repository
+// from, or nothing when git over SSH is off
+func (c *ReposController) SSHCloneURL() string {
+	repo, err := c.CurrentRepo()
+	if err != nil || c.Request == nil {
+		return ""
+	}
+	return sshCloneURL(c.Request.Host, repo.ID)
+}
+
+// sshCloneURL builds the SSH address of a repository on the host the
+// browser reached the workspace at
+func sshCloneURL(requestHost, repoID string) string {
+	port := sshPort()
+	if port == "" {
+		return ""
+	}
+	host := requestHost
+	if h, _, err := net.SplitHostPort(requestHost); err == nil {
+		host = h
+	}
+	if port == "22" {
+		return fmt.Sprintf("git@%s:repo/%s.git", host, repoID)
+	}
+	return fmt.Sprintf("ssh://git@%s/repo/%s.git", net.JoinHostPort(host, port), repoID)
+}
+
+// startSSHServer serves git over SSH for users' registered keys, with the
+// same access rules as git over HTTP
+func (c *ReposController) startSSHServer(auth *AuthController) {
+	port := sshPort()
+	if port == "" {
+		return
+	}
+
+	server, err := gitssh.New(gitssh.Config{
+		ReposDir:    filepath.Join(database.DataDir(), "repos"),
+		HostKeyPath: filepath.Join(database.DataDir(), "ssh", "host_ed25519"),
+		LookupKey: func(key ssh.PublicKey) (string, error) {
+			sshKey, err := models.ValidateSSHKey(key)
+			if err != nil {
+				return "", err
+			}
+			return sshKey.UserID, nil
+		},
+		Authorize: func(userID, repoID, service string) (func(), error) {
+			user, err := auth.Users.Get(userID)
+			if err != nil {
+				return nil, errors.New("invalid SSH key user")
+			}
+			repo, err := models.Repositories.Get(repoID)
+			if err != nil {
+				return nil, errors.New("repository not found")
+			}
+
+			push := service == gitssh.ReceivePack
+			if err := authorizeGit(user, repo, push); err != nil {
+				return nil, err
+			}
+			if !push {
+				return nil, nil
+			}
+
+			// Unlike HTTP, the push has finished when git exits
+			before := branchHeads(repo)
+			return func() { go gitPushed(repo, user.ID, before) }, nil
+		},
+	})
+	if err != nil {
+		log.Printf("Failed to start git SSH server: %v", err)
+		return
+	}
+
+	go func() {
+		log.Printf("Git SSH server listening on port %s", port)
+		if err := server.ListenAndServe(":" + port); err != nil {
+			log.Printf("Git SSH server stopped: %v", err)
+		}
+	}()
+}
@@ -0,0 +1,92 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"workspace/models"
+)
+
+// StalePolicy returns the stale issue and pull request policy for the
+// current repository
+func (c *ReposController) StalePolicy() (*models.StalePolicy, error) {
+	repo, err := c.CurrentRepo()
+	if err != nil {
+		return nil, err
+	}
+	return models.GetStalePolicy(repo.ID), nil
+}
+
+// updateStalePolicy handles POST /repos/{id}/settings/stale
+func (c *ReposController) updateStalePolicy(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	repo, err := c.getCurrentRepoFromRequest(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	// Admin already verified by route middleware
+	auth := c.App.Use("auth").(*AuthController)
+	user, _, err := auth.Authenticate(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	daysUntilStale, err := strconv.Atoi(r.FormValue("days_until_stale"))
+	if err != nil {
+		c.RenderError(w, r, errors.New("days until stale must be a number"))
+		return
+	}
+	daysUntilClose, err := strconv.Atoi(r.FormValue("days_until_close"))
+	if err != nil {
+		c.RenderError(w, r, errors.New("days until close must be a number"))
+		return
+	}
+
+	policy := models.GetStalePolicy(repo.ID)
+	policy.Enabled = r.FormValue("enabled") == "on"
+	policy.DryRun = r.FormValue("dry_run") == "on"
+	policy.IncludePRs = r.FormValue("include_prs") == "on"
+	policy.DaysUntilStale = daysUntilStale
+	policy.DaysUntilClose = daysUntilClose
+	policy.ExemptLabels = r.FormValue("exempt_labels")
+
+	if _, err := models.SaveStalePolicy(policy, user.ID); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	models.LogActivity("repo_updated", fmt.Sprintf("Updated stale policy for %s", repo.Name),
+		"Stale issue and pull request automation was changed",
+		user.ID, repo.ID, "repository", "")
+
+	c.Redirect(w, r, fmt.Sprintf("/repos/%s/settings", repo.ID))
+}
+
+// previewStalePolicy handles GET /repos/{id}/settings/stale/preview, listing
+// what the saved policy would do if it ran now without changing anything
+func (c *ReposController) previewStalePolicy(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	repo, err := c.getCurrentRepoFromRequest(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	policy := models.GetStalePolicy(repo.ID)
+	actions, err := policy.Plan(time.Now())
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	c.Render(w, r, "stale-preview.html", map[string]any{
+		"Policy":  policy,
+		"Actions": actions,
+	})
+}
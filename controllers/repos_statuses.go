@@ -0,0 +1,164 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"workspace/models"
+
+	"github.com/The-Skyscape/devtools/pkg/authentication"
+)
+
+// apiStatus is a commit status in API requests and responses, named as
+// GitHub names them so existing CI integrations work with few changes
+type apiStatus struct {
+	ID          string    `json:"id,omitempty"`
+	SHA         string    `json:"sha,omitempty"`
+	State       string    `json:"state"`
+	Context     string    `json:"context"`
+	Description string    `json:"description"`
+	TargetURL   string    `json:"target_url"`
+	CreatorID   string    `json:"creator_id,omitempty"`
+	CreatedAt   time.Time `json:"created_at,omitzero"`
+	UpdatedAt   time.Time `json:"updated_at,omitzero"`
+}
+
+// newAPIStatus converts a commit status for an API response
+func newAPIStatus(status *models.CommitStatus) apiStatus {
+	return apiStatus{
+		ID:          status.ID,
+		SHA:         status.CommitSHA,
+		State:       status.State,
+		Context:     status.Context,
+		Description: status.Description,
+		TargetURL:   status.TargetURL,
+		CreatorID:   status.CreatorID,
+		CreatedAt:   status.CreatedAt,
+		UpdatedAt:   status.UpdatedAt,
+	}
+}
+
+// statusAccess authenticates a commit status API request, with a personal
+// access token, the same credentials as git over HTTP or the browser
+// session. Reporting statuses takes write access, like pushing; anyone may
+// read them on public repositories.
+func (c *ReposController) statusAccess(w http.ResponseWriter, r *http.Request, write bool) (*models.Repository, *authentication.User, bool) {
+	repo, err := models.Repositories.Get(r.PathValue("id"))
+	if err != nil {
+		apiError(w, http.StatusNotFound, "repository not found")
+		return nil, nil, false
+	}
+
+	auth := c.App.Use("auth").(*AuthController)
+	var user *authentication.User
+	if bearerToken(r) != "" {
+		var token *models.PersonalToken
+		if user, token, err = apiUser(c.App, r); err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="Skyscape API"`)
+			apiError(w, http.StatusUnauthorized, err.Error())
+			return nil, nil, false
+		}
+		scope := models.ScopeRepoRead
+		if write {
+			scope = models.ScopeRepoWrite
+		}
+		if err := authorizeToken(token, scope, r); err != nil {
+			apiError(w, http.StatusForbidden, err.Error())
+			return nil, nil, false
+		}
+	} else if username, password, ok := r.BasicAuth(); ok {
+		if user, err = gitPasswordUser(auth, username, password); err != nil {
+			w.Header().Set("WWW-Authenticate", `Basic realm="Skyscape API"`)
+			apiError(w, http.StatusUnauthorized, err.Error())
+			return nil, nil, false
+		}
+	} else {
+		user, _, _ = auth.Authenticate(r)
+	}
+
+	if user == nil && (write || repo.Visibility != "public") {
+		w.Header().Set("WWW-Authenticate", `Basic realm="Skyscape API"`)
+		apiError(w, http.StatusUnauthorized, "authentication required")
+		return nil, nil, false
+	}
+	role := models.RoleRead
+	if write {
+		role = models.RoleWrite
+	}
+	if user != nil && models.CheckRepoAccess(user, repo.ID, role) != nil {
+		apiError(w, http.StatusForbidden, "access denied")
+		return nil, nil, false
+	}
+	return repo, user, true
+}
+
+// createCommitStatus handles POST /api/repos/{id}/statuses/{sha}, where CI
+// systems report a commit as pending, passing or failing under a context
+func (c *ReposController) createCommitStatus(w http.ResponseWriter, r *http.Request) {
+	repo, user, ok := c.statusAccess(w, r, true)
+	if !ok {
+		return
+	}
+
+	var req apiStatus
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+		apiError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+
+	status, err := models.SetCommitStatus(repo, r.PathValue("sha"), &models.CommitStatus{
+		State:       req.State,
+		Context:     req.Context,
+		Description: req.Description,
+		TargetURL:   req.TargetURL,
+		CreatorID:   user.ID,
+	})
+	if err != nil {
+		apiError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	apiJSON(w, http.StatusCreated, newAPIStatus(status))
+}
+
+// getCommitStatuses handles GET /api/repos/{id}/statuses/{sha}, returning
+// the latest status of each context and their combined state
+func (c *ReposController) getCommitStatuses(w http.ResponseWriter, r *http.Request) {
+	repo, _, ok := c.statusAccess(w, r, false)
+	if !ok {
+		return
+	}
+
+	sha, err := repo.ResolveCommit(r.PathValue("sha"))
+	if err != nil {
+		apiError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	statuses, err := models.GetCommitStatuses(repo.ID, sha)
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	list := make([]apiStatus, 0, len(statuses))
+	for _, status := range statuses {
+		list = append(list, newAPIStatus(status))
+	}
+	apiJSON(w, http.StatusOK, map[string]any{
+		"sha":      sha,
+		"state":    models.CombinedStatus(statuses),
+		"statuses": list,
+	})
+}
+
+// apiJSON writes a JSON API response
+func apiJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// apiError writes a JSON API error
+func apiError(w http.ResponseWriter, status int, message string) {
+	apiJSON(w, status, map[string]string{"message": message})
+}
@@ -0,0 +1,170 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"workspace/models"
+	"workspace/services"
+)
+
+// Webhooks returns the webhooks of the current repository
+func (c *ReposController) Webhooks() ([]*models.Webhook, error) {
+	repo, err := c.CurrentRepo()
+	if err != nil {
+		return nil, err
+	}
+	return models.RepoWebhooks(repo.ID)
+}
+
+// WebhookEvents returns the events a webhook can subscribe to
+func (c *ReposController) WebhookEvents() []string {
+	return models.WebhookEvents
+}
+
+// createWebhook handles POST /repos/{id}/webhooks
+func (c *ReposController) createWebhook(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	repo, err := c.getCurrentRepoFromRequest(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	// Admin already verified by route middleware
+	auth := c.App.Use("auth").(*AuthController)
+	user, _, err := auth.Authenticate(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+	hook, err := models.CreateWebhook(repo.ID, r.FormValue("url"), r.FormValue("secret"), r.Form["events"], user.ID)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	models.LogActivity("webhook_created", fmt.Sprintf("Added webhook to %s", repo.Name),
+		fmt.Sprintf("Events are posted to %s", hook.URL),
+		user.ID, repo.ID, "webhook", hook.ID)
+
+	c.Redirect(w, r, fmt.Sprintf("/repos/%s/settings", repo.ID))
+}
+
+// toggleWebhook handles POST /repos/{id}/webhooks/{hookID}/toggle,
+// pausing or resuming deliveries
+func (c *ReposController) toggleWebhook(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	repo, hook, err := c.requestWebhook(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	hook.Active = !hook.Active
+	if err := models.Webhooks.Update(hook); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	c.Redirect(w, r, fmt.Sprintf("/repos/%s/settings", repo.ID))
+}
+
+// deleteWebhook handles POST /repos/{id}/webhooks/{hookID}/delete
+func (c *ReposController) deleteWebhook(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	repo, hook, err := c.requestWebhook(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	// Admin already verified by route middleware
+	auth := c.App.Use("auth").(*AuthController)
+	user, _, err := auth.Authenticate(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	if err := models.DeleteWebhook(hook); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	models.LogActivity("webhook_deleted", fmt.Sprintf("Removed webhook from %s", repo.Name),
+		fmt.Sprintf("Events are no longer posted to %s", hook.URL),
+		user.ID, repo.ID, "webhook", hook.ID)
+
+	c.Redirect(w, r, fmt.Sprintf("/repos/%s/settings", repo.ID))
+}
+
+// pingWebhook handles POST /repos/{id}/webhooks/{hookID}/ping, sending a
+// test event to the endpoint
+func (c *ReposController) pingWebhook(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	repo, hook, err := c.requestWebhook(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	// Admin already verified by route middleware
+	auth := c.App.Use("auth").(*AuthController)
+	user, _, err := auth.Authenticate(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	if _, err := services.PingWebhook(hook, user.ID); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	c.Redirect(w, r, fmt.Sprintf("/repos/%s/settings", repo.ID))
+}
+
+// redeliverWebhook handles POST /repos/{id}/webhooks/{hookID}/deliveries/{deliveryID}/redeliver,
+// sending a recorded payload again
+func (c *ReposController) redeliverWebhook(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	repo, hook, err := c.requestWebhook(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	delivery, err := models.WebhookDeliveries.Get(r.PathValue("deliveryID"))
+	if err != nil || delivery.WebhookID != hook.ID {
+		c.RenderError(w, r, errors.New("delivery not found"))
+		return
+	}
+
+	if _, err := services.RedeliverWebhook(delivery); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	c.Redirect(w, r, fmt.Sprintf("/repos/%s/settings", repo.ID))
+}
+
+// requestWebhook returns the repository and webhook named in the request's
+// path, failing when the webhook belongs to another repository
+func (c *ReposController) requestWebhook(r *http.Request) (*models.Repository, *models.Webhook, error) {
+	repo, err := c.getCurrentRepoFromRequest(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	hook, err := models.Webhooks.Get(r.PathValue("hookID"))
+	if err != nil || hook.RepoID != repo.ID {
+		return nil, nil, errors.New("webhook not found")
+	}
+	return repo, hook, nil
+}
@@ -0,0 +1,192 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"workspace/models"
+
+	"github.com/The-Skyscape/devtools/pkg/application"
+)
+
+// SavedViews is a factory function with the prefix and instance
+func SavedViews() (string, *SavedViewsController) {
+	return "views", &SavedViewsController{}
+}
+
+// SavedViewsController handles the named issue and pull request filters
+// users save and pin to the sidebar of the lists
+type SavedViewsController struct {
+	application.Controller
+}
+
+// Setup registers routes
+func (c *SavedViewsController) Setup(app *application.App) {
+	c.Controller.Setup(app)
+	auth := app.Use("auth").(*AuthController)
+
+	// Saving views - authenticated users on public repos, admins on any
+	http.Handle("POST /repos/{id}/views/create", app.ProtectFunc(c.createView, PublicRepoOnly()))
+
+	// View changes - whoever saved the view or an admin
+	http.Handle("POST /repos/{id}/views/{viewID}/edit", app.ProtectFunc(c.editView, auth.Required))
+	http.Handle("POST /repos/{id}/views/{viewID}/pin", app.ProtectFunc(c.pinView, auth.Required))
+	http.Handle("POST /repos/{id}/views/{viewID}/delete", app.ProtectFunc(c.deleteView, auth.Required))
+}
+
+// Handle returns a new controller instance for the request
+func (c SavedViewsController) Handle(req *http.Request) application.Handler {
+	c.Request = req
+	return &c
+}
+
+// Kind returns the list the page shows, issues or pull requests
+func (c *SavedViewsController) Kind() string {
+	if strings.HasSuffix(c.Request.URL.Path, "/prs") {
+		return models.ViewPullRequests
+	}
+	return models.ViewIssues
+}
+
+// Saved returns the views of the current list the user can see
+func (c *SavedViewsController) Saved() []*models.SavedView {
+	views, err := models.GetSavedViews(c.userID(), c.Request.PathValue("id"), c.Kind())
+	if err != nil {
+		return nil
+	}
+	return views
+}
+
+// Pinned returns the views listed in the sidebar of the current list
+func (c *SavedViewsController) Pinned() []*models.SavedView {
+	var pinned []*models.SavedView
+	for _, view := range c.Saved() {
+		if view.Pinned {
+			pinned = append(pinned, view)
+		}
+	}
+	return pinned
+}
+
+// CurrentView returns the view the list is filtered by, nil for none
+func (c *SavedViewsController) CurrentView() *models.SavedView {
+	view, err := models.SavedViews.Get(c.Request.URL.Query().Get("view"))
+	if err != nil || !view.VisibleTo(c.userID()) {
+		return nil
+	}
+	return view
+}
+
+// CanEdit returns true if the current user can change or delete the view
+func (c *SavedViewsController) CanEdit(view *models.SavedView) bool {
+	user := c.Use("auth").(*AuthController).CurrentUser()
+	return user != nil && (user.ID == view.UserID || user.IsAdmin)
+}
+
+// userID returns the signed-in user's ID, empty for guests
+func (c *SavedViewsController) userID() string {
+	if user := c.Use("auth").(*AuthController).CurrentUser(); user != nil {
+		return user.ID
+	}
+	return ""
+}
+
+// createView handles POST /repos/{id}/views/create, saving the filters
+// posted with the form
+func (c *SavedViewsController) createView(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	user := c.Use("auth").(*AuthController).CurrentUser()
+	if user == nil {
+		c.RenderError(w, r, errors.New("sign in to save views"))
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	repoID := r.PathValue("id")
+	scope := repoID
+	if r.FormValue("scope") == "all" {
+		scope = ""
+	}
+
+	kind := r.FormValue("kind")
+	view, err := models.CreateSavedView(user.ID, scope, kind, r.FormValue("name"), r.Form, r.FormValue("shared") == "true")
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	c.Redirect(w, r, "/repos/"+repoID+"/"+kind+"?view="+view.ID)
+}
+
+// editView handles POST /repos/{id}/views/{viewID}/edit, renaming the view
+// or changing who can see it
+func (c *SavedViewsController) editView(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+
+	view, err := c.viewToEdit(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	if err := view.Edit(r.FormValue("name"), view.Pinned, r.FormValue("shared") == "true"); err != nil {
+		c.RenderError(w, r, fmt.Errorf("failed to save view: %w", err))
+		return
+	}
+
+	c.Refresh(w, r)
+}
+
+// pinView handles POST /repos/{id}/views/{viewID}/pin, pinning the view to
+// the sidebar or unpinning it
+func (c *SavedViewsController) pinView(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+
+	view, err := c.viewToEdit(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	if err := view.Edit(view.Name, !view.Pinned, view.Shared); err != nil {
+		c.RenderError(w, r, fmt.Errorf("failed to save view: %w", err))
+		return
+	}
+
+	c.Refresh(w, r)
+}
+
+// deleteView handles POST /repos/{id}/views/{viewID}/delete
+func (c *SavedViewsController) deleteView(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+
+	view, err := c.viewToEdit(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	if err := models.DeleteSavedView(view); err != nil {
+		c.RenderError(w, r, fmt.Errorf("failed to delete view: %w", err))
+		return
+	}
+
+	c.Redirect(w, r, "/repos/"+r.PathValue("id")+"/"+view.Kind)
+}
+
+// viewToEdit returns the view in the URL if the current user can change it
+func (c *SavedViewsController) viewToEdit(r *http.Request) (*models.SavedView, error) {
+	view, err := models.SavedViews.Get(r.PathValue("viewID"))
+	if err != nil || !view.VisibleTo(c.userID()) {
+		return nil, errors.New("view not found")
+	}
+	if !c.CanEdit(view) {
+		return nil, errors.New("only whoever saved the view or an admin can change it")
+	}
+	return view, nil
+}
@@ -0,0 +1,155 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"workspace/internal/search"
+	"workspace/models"
+	"workspace/services"
+
+	"github.com/The-Skyscape/devtools/pkg/application"
+)
+
+const (
+	// searchLimit is how many results the search page shows
+	searchLimit = 50
+
+	// quickOpenLimit is how many documents quick-open suggests, after the
+	// repositories whose names match
+	quickOpenLimit = 8
+)
+
+// Search is a factory function with the prefix and instance
+func Search() (string, *SearchController) {
+	return "search", &SearchController{}
+}
+
+// SearchController searches the issues, pull requests, comments, commits
+// and files of every repository the user can see
+type SearchController struct {
+	application.Controller
+}
+
+// SearchHit is a matching document with the line showing why it matched
+type SearchHit struct {
+	*models.SearchDocument
+	Repo    *models.Repository
+	Line    int // Line of the excerpt in files, 0 otherwise
+	Excerpt string
+}
+
+// Setup registers routes and starts indexing
+func (c *SearchController) Setup(app *application.App) {
+	c.Controller.Setup(app)
+	auth := app.Use("auth").(*AuthController)
+
+	http.Handle("GET /search", app.Serve("search.html", auth.Required))
+	http.Handle("GET /search/quick", app.Serve("search-quick.html", auth.Required))
+
+	services.Search.Start()
+}
+
+// Handle returns a new controller instance for the request
+func (c SearchController) Handle(req *http.Request) application.Handler {
+	c.Request = req
+	return &c
+}
+
+// Query returns the search as the user typed it
+func (c *SearchController) Query() string {
+	return strings.TrimSpace(c.Request.URL.Query().Get("q"))
+}
+
+// Filters returns the filters and text of the search
+func (c *SearchController) Filters() search.Query {
+	return search.Parse(c.Query())
+}
+
+// WithKind returns the search limited to a kind of document, or to none
+// when kind is ""
+func (c *SearchController) WithKind(kind string) string {
+	return search.WithKind(c.Query(), kind)
+}
+
+// FullText returns true when results are ranked by the full-text index
+func (c *SearchController) FullText() bool {
+	return models.FullTextSearch()
+}
+
+// Results returns the documents matching the search, best first
+func (c *SearchController) Results() ([]*SearchHit, error) {
+	return c.results(searchLimit)
+}
+
+// QuickResults returns the few best documents for quick-open
+func (c *SearchController) QuickResults() ([]*SearchHit, error) {
+	return c.results(quickOpenLimit)
+}
+
+// Repositories returns the repositories whose names match the search's
+// text, for quick-open to jump to
+func (c *SearchController) Repositories() ([]*models.Repository, error) {
+	user := c.Use("auth").(*AuthController).CurrentUser()
+	if user == nil {
+		return nil, errors.New("authentication required")
+	}
+	text := c.Filters().Text()
+	if text == "" {
+		return nil, nil
+	}
+	query := "WHERE LOWER(Name) LIKE LOWER(?)"
+	args := []any{"%" + text + "%"}
+	if !user.IsAdmin {
+		readable := "Visibility = 'public'"
+		if ids := models.TeamRepoIDs(user.ID); len(ids) > 0 {
+			readable += " OR ID IN (" + strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",") + ")"
+			for _, id := range ids {
+				args = append(args, id)
+			}
+		}
+		query += " AND (" + readable + ")"
+	}
+	return models.Repositories.Search(query+" ORDER BY UpdatedAt DESC LIMIT 5", args...)
+}
+
+// results searches the repositories the current user can read: public
+// ones, and private ones when they're an admin or their teams have access.
+func (c *SearchController) results(limit int) ([]*SearchHit, error) {
+	user := c.Use("auth").(*AuthController).CurrentUser()
+	if user == nil {
+		return nil, errors.New("authentication required")
+	}
+	q := c.Filters()
+	if q.IsEmpty() {
+		return nil, nil
+	}
+
+	docs, err := models.SearchAll(q, user, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	repos := map[string]*models.Repository{}
+	hits := make([]*SearchHit, 0, len(docs))
+	for _, doc := range docs {
+		repo, ok := repos[doc.RepoID]
+		if !ok {
+			if repo, err = models.Repositories.Get(doc.RepoID); err != nil {
+				repo = nil
+			}
+			repos[doc.RepoID] = repo
+		}
+		if repo == nil {
+			// Deleted while it was being indexed
+			continue
+		}
+		line, excerpt := search.Excerpt(doc.Body, q.Terms)
+		if doc.Kind != search.KindCode {
+			line = 0
+		}
+		hits = append(hits, &SearchHit{SearchDocument: doc, Repo: repo, Line: line, Excerpt: excerpt})
+	}
+	return hits, nil
+}
@@ -4,17 +4,17 @@ import (
 	"cmp"
 	"errors"
 	"fmt"
-	"io"
+	"mime"
 	"net/http"
-	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
+	"workspace/internal/migration"
 	"workspace/models"
+	"workspace/services"
 
 	"github.com/The-Skyscape/devtools/pkg/application"
-	"github.com/The-Skyscape/devtools/pkg/database"
 )
 
 type SettingsController struct {
@@ -55,6 +55,10 @@ func (s *SettingsController) Setup(app *application.App) {
 	http.Handle("GET /settings", app.Serve("settings.html", adminRequired))
 	http.Handle("POST /settings", app.ProtectFunc(s.updateSettings, adminRequired))
 	http.Handle("POST /settings/theme", app.ProtectFunc(s.updateTheme, adminRequired))
+	http.Handle("POST /settings/storage", app.ProtectFunc(s.updateStorage, adminRequired))
+	http.Handle("POST /settings/secrets", app.ProtectFunc(s.saveWorkspaceSecret, adminRequired))
+	http.Handle("POST /settings/secrets/{id}/delete", app.ProtectFunc(s.deleteWorkspaceSecret, adminRequired))
+	http.Handle("POST /settings/registry/gc", app.ProtectFunc(s.collectRegistryGarbage, adminRequired))
 	// GitHub settings moved to IntegrationsController
 
 	// User Account settings - for individual users
@@ -62,15 +66,68 @@ func (s *SettingsController) Setup(app *application.App) {
 	http.Handle("POST /settings/account", app.ProtectFunc(s.updateAccount, auth.Required))
 	http.Handle("POST /settings/account/password", app.ProtectFunc(s.updatePassword, auth.Required))
 	http.Handle("POST /settings/account/avatar", app.ProtectFunc(s.uploadAvatar, auth.Required))
+	http.Handle("POST /settings/account/email", app.ProtectFunc(s.updateMailPreference, auth.Required))
 
-	// SSH Key management (admin only for now)
-	http.Handle("GET /settings/ssh-keys", app.Serve("settings-ssh-keys.html", adminRequired))
-	http.Handle("POST /settings/ssh-keys", app.ProtectFunc(s.addSSHKey, adminRequired))
-	http.Handle("DELETE /settings/ssh-keys/{id}", app.ProtectFunc(s.deleteSSHKey, adminRequired))
+	// SSH Key management - each user manages their own keys
+	http.Handle("GET /settings/ssh-keys", app.Serve("settings-ssh-keys.html", auth.Required))
+	http.Handle("POST /settings/ssh-keys", app.ProtectFunc(s.addSSHKey, auth.Required))
+	http.Handle("DELETE /settings/ssh-keys/{id}", app.ProtectFunc(s.deleteSSHKey, auth.Required))
+	http.Handle("GET /settings/keys", http.RedirectHandler("/settings/ssh-keys", http.StatusMovedPermanently))
+
+	// Personal access tokens for the HTTP API - each user manages their own
+	http.Handle("GET /settings/tokens", app.Serve("settings-tokens.html", auth.Required))
+	http.Handle("POST /settings/tokens", app.ProtectFunc(s.createPersonalToken, auth.Required))
+	http.Handle("POST /settings/tokens/{id}/revoke", app.ProtectFunc(s.revokePersonalToken, auth.Required))
+
+	// Browsers signed in as the user, who can sign out any of them
+	http.Handle("GET /settings/sessions", app.Serve("settings-sessions.html", auth.Required))
+	http.Handle("POST /settings/sessions/others/revoke", app.ProtectFunc(s.revokeOtherSessions, auth.Required))
+	http.Handle("POST /settings/sessions/{id}/revoke", app.ProtectFunc(s.revokeSession, auth.Required))
 
 	// Serve avatar images
 	http.HandleFunc("GET /avatar/{filename}", s.serveAvatar)
 
+	// AI model management (admin only)
+	http.Handle("GET /settings/ai", app.Serve("settings-ai.html", adminRequired))
+	http.Handle("POST /settings/ai/models/pull", app.ProtectFunc(s.pullModel, adminRequired))
+	http.Handle("GET /settings/ai/models/pull/events", app.ProtectFunc(s.streamModelPull, adminRequired))
+	http.Handle("POST /settings/ai/models/remove", app.ProtectFunc(s.removeModel, adminRequired))
+	http.Handle("POST /settings/ai/models/default", app.ProtectFunc(s.setDefaultModel, adminRequired))
+	http.Handle("POST /settings/ai/host", app.ProtectFunc(s.updateOllamaHost, adminRequired))
+	http.Handle("POST /settings/ai/keep-alive", app.ProtectFunc(s.updateKeepAlive, adminRequired))
+	http.Handle("POST /settings/ai/report-digest", app.ProtectFunc(s.updateReportDigest, adminRequired))
+	http.Handle("POST /settings/ai/models/preload", app.ProtectFunc(s.preloadModel, adminRequired))
+	http.Handle("POST /settings/ai/models/unload", app.ProtectFunc(s.unloadModel, adminRequired))
+
+	// Migration to or from another workspace
+	http.Handle("GET /settings/migration", app.Serve("settings-migration.html", adminRequired))
+	http.Handle("POST /settings/migration", app.ProtectFunc(s.startMigration, adminRequired))
+	http.Handle("POST /settings/migration/{id}/resume", app.ProtectFunc(s.resumeMigration, adminRequired))
+	http.Handle("POST /settings/migration/{id}/pause", app.ProtectFunc(s.pauseMigration, adminRequired))
+	http.Handle("POST /settings/migration/{id}/resync", app.ProtectFunc(s.resyncMigration, adminRequired))
+	http.Handle("POST /settings/migration/{id}/checklist", app.ProtectFunc(s.toggleCutoverStep, adminRequired))
+	http.Handle("POST /settings/migration/{id}/delete", app.ProtectFunc(s.deleteMigration, adminRequired))
+	http.Handle("POST /settings/migration/invites", app.ProtectFunc(s.createMigrationInvite, adminRequired))
+	http.Handle("POST /settings/migration/invites/{id}/revoke", app.ProtectFunc(s.revokeMigrationInvite, adminRequired))
+	http.HandleFunc("POST "+migration.StatusPath, s.receiveMigration)
+	http.HandleFunc("POST "+migration.ItemsPath+"{item...}", s.receiveMigration)
+
+	// Mirror export of every repository to another host
+	http.Handle("GET /settings/mirror", app.Serve("settings-mirror.html", adminRequired))
+	http.Handle("POST /settings/mirror", app.ProtectFunc(s.updateMirror, adminRequired))
+	http.Handle("POST /settings/mirror/run", app.ProtectFunc(s.runMirror, adminRequired))
+	services.Mirrors.Start()
+
+	// Outgoing mail for digests, password resets and invitations
+	http.Handle("GET /settings/mail", app.Serve("settings-mail.html", adminRequired))
+	http.Handle("POST /settings/mail", app.ProtectFunc(s.updateMail, adminRequired))
+	http.Handle("POST /settings/mail/test", app.ProtectFunc(s.sendTestMail, adminRequired))
+	services.Digests.Start()
+
+	// Single sign-on through an OpenID Connect provider
+	http.Handle("GET /settings/sso", app.Serve("settings-sso.html", adminRequired))
+	http.Handle("POST /settings/sso", app.ProtectFunc(s.updateSSO, adminRequired))
+
 	// Workspace Profile settings - GET is for all authenticated users, POST is admin only
 	http.Handle("GET /settings/workspace", app.Serve("settings-workspace.html", auth.Required))
 	http.Handle("POST /settings/workspace", app.ProtectFunc(s.updateWorkspace, adminRequired))
@@ -123,6 +180,12 @@ func (s *SettingsController) updateSettings(w http.ResponseWriter, r *http.Reque
 		settings.GitHubEnabled = r.FormValue("github_enabled") == "true"
 	}
 
+	// Documentation sites the AI may read
+	if _, exists := r.Form["web_fetch_domains"]; exists {
+		settings.WebFetchDomains = r.FormValue("web_fetch_domains")
+		settings.WebFetchDomains = strings.Join(settings.WebFetchDomainList(), "\n")
+	}
+
 	// Update metadata
 	settings.LastUpdatedBy = user.Email
 	settings.LastUpdatedAt = time.Now()
@@ -317,10 +380,6 @@ func (s *SettingsController) uploadAvatar(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	// Create avatars directory
-	avatarDir := filepath.Join(database.DataDir(), "avatars")
-	os.MkdirAll(avatarDir, 0755)
-
 	// Generate filename based on user ID and file extension
 	ext := filepath.Ext(handler.Filename)
 	if ext == "" {
@@ -328,19 +387,9 @@ func (s *SettingsController) uploadAvatar(w http.ResponseWriter, r *http.Request
 		ext = ".jpg"
 	}
 	filename := fmt.Sprintf("%s%s", user.ID, ext)
-	filePath := filepath.Join(avatarDir, filename)
-
-	// Create the destination file
-	dst, err := os.Create(filePath)
-	if err != nil {
-		s.RenderError(w, r, errors.New("failed to save avatar"))
-		return
-	}
-	defer dst.Close()
 
-	// Copy the uploaded file to the destination
-	_, err = io.Copy(dst, file)
-	if err != nil {
+	// Save the uploaded file to storage
+	if err := models.Storage().Put("avatars/"+filename, file); err != nil {
 		s.RenderError(w, r, errors.New("failed to save avatar"))
 		return
 	}
@@ -370,17 +419,24 @@ func (s *SettingsController) serveAvatar(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Build the full path to the avatar file
-	avatarPath := filepath.Join(database.DataDir(), "avatars", filename)
-
-	// Check if file exists
-	if _, err := os.Stat(avatarPath); os.IsNotExist(err) {
+	// Open the avatar from storage
+	key := "avatars/" + filename
+	object, err := models.Storage().Stat(key)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	file, err := models.Storage().Get(key)
+	if err != nil {
 		http.NotFound(w, r)
 		return
 	}
 
 	// Serve the file
-	http.ServeFile(w, r, avatarPath)
+	if contentType := mime.TypeByExtension(filepath.Ext(filename)); contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+	serveStored(w, r, file, object.Modified)
 }
 
 // updateWorkspace handles workspace profile settings form submission
@@ -461,6 +517,15 @@ func (s *SettingsController) GetSSHKeys() ([]*models.SSHKey, error) {
 	return models.GetUserSSHKeys(user.ID)
 }
 
+// SSHCloneExample shows how to clone over SSH from this workspace, or
+// nothing when git over SSH is off
+func (s *SettingsController) SSHCloneExample() string {
+	if s.Request == nil {
+		return ""
+	}
+	return sshCloneURL(s.Request.Host, "repository-id")
+}
+
 // addSSHKey handles adding a new SSH key
 func (s *SettingsController) addSSHKey(w http.ResponseWriter, r *http.Request) {
 	s.SetRequest(r)
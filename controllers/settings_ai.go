@@ -0,0 +1,431 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"workspace/internal/sse"
+	"workspace/models"
+	"workspace/services"
+)
+
+// modelPullPollInterval is how often a pull's progress is sent to the browser
+const modelPullPollInterval = 500 * time.Millisecond
+
+// modelNamePattern matches Ollama model names such as llama3.2:3b or
+// namespace/model:tag
+var modelNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._\-/]*(:[a-zA-Z0-9._\-]+)?$`)
+
+// AIRunning returns true when the Ollama service is up
+func (s *SettingsController) AIRunning() bool {
+	return services.Ollama.IsRunning()
+}
+
+// InstalledModels returns the models installed in Ollama
+func (s *SettingsController) InstalledModels() ([]services.OllamaModelInfo, error) {
+	if !services.Ollama.IsRunning() {
+		return nil, nil
+	}
+	return services.Ollama.ListModelInfo()
+}
+
+// DefaultModel returns the model the AI assistant uses by default
+func (s *SettingsController) DefaultModel() string {
+	return services.Ollama.GetDefaultModel()
+}
+
+// IsDefaultModel returns true when name is the default model
+func (s *SettingsController) IsDefaultModel(name string) bool {
+	return services.Ollama.IsDefaultModel(name)
+}
+
+// DefaultModelLocked returns true when AI_MODEL is set by the deployment,
+// so the default can't be changed here
+func (s *SettingsController) DefaultModelLocked() bool {
+	return models.IsSetByEnvironment("AI_MODEL")
+}
+
+// AIRemote returns true when AI requests go to remote Ollama servers
+func (s *SettingsController) AIRemote() bool {
+	return services.Ollama.IsRemote()
+}
+
+// AIEndpoints returns the remote Ollama servers and whether they answer
+func (s *SettingsController) AIEndpoints() []services.OllamaEndpoint {
+	return services.Ollama.Endpoints()
+}
+
+// OllamaHostLocked returns true when OLLAMA_HOST is set by the deployment
+func (s *SettingsController) OllamaHostLocked() bool {
+	return models.IsSetByEnvironment("OLLAMA_HOST")
+}
+
+// KeepAlive returns how long models stay loaded after their last request
+func (s *SettingsController) KeepAlive() string {
+	return services.Ollama.KeepAlive()
+}
+
+// KeepAliveLocked returns true when OLLAMA_KEEP_ALIVE is set by the deployment
+func (s *SettingsController) KeepAliveLocked() bool {
+	return models.IsSetByEnvironment("OLLAMA_KEEP_ALIVE")
+}
+
+// ModelLoaded returns true when a server holds the model in memory
+func (s *SettingsController) ModelLoaded(name string) bool {
+	return services.Ollama.IsModelLoaded(name)
+}
+
+// ModelLoading returns true while the model is being preloaded
+func (s *SettingsController) ModelLoading(name string) bool {
+	return services.Ollama.IsModelLoading(name)
+}
+
+// ModelPulls returns the model downloads in progress
+func (s *SettingsController) ModelPulls() []services.ModelPull {
+	return services.Ollama.ActivePulls()
+}
+
+// SuggestedModels returns the models the workspace has tuned providers for
+func (s *SettingsController) SuggestedModels() []services.AIModelOption {
+	return services.AIModelOptions
+}
+
+// pullModel handles POST /settings/ai/models/pull, starting a download and
+// returning its progress card
+func (s *SettingsController) pullModel(w http.ResponseWriter, r *http.Request) {
+	s.SetRequest(r)
+
+	model := strings.TrimSpace(r.FormValue("model"))
+	if !modelNamePattern.MatchString(model) {
+		s.RenderError(w, r, errors.New("enter a model name like llama3.2:3b"))
+		return
+	}
+
+	pull, err := services.Ollama.StartPull(model)
+	if err != nil {
+		s.RenderError(w, r, err)
+		return
+	}
+
+	auth := s.App.Use("auth").(*AuthController)
+	log.Printf("SettingsController: %s started downloading %s", auth.CurrentUser().Email, model)
+
+	s.Render(w, r, "settings-ai-pull.html", pull)
+}
+
+// streamModelPull handles GET /settings/ai/models/pull/events?model=,
+// sending a download's progress until it finishes
+func (s *SettingsController) streamModelPull(w http.ResponseWriter, r *http.Request) {
+	s.SetRequest(r)
+
+	model := r.URL.Query().Get("model")
+	if _, ok := services.Ollama.PullStatus(model); !ok {
+		http.Error(w, "No download in progress for this model", http.StatusNotFound)
+		return
+	}
+
+	auth := s.App.Use("auth").(*AuthController)
+	stream, err := sse.Streams.Open(w, r, "ai-models", auth.CurrentUser().ID)
+	if err != nil {
+		return
+	}
+	defer stream.Close()
+
+	ticker := time.NewTicker(modelPullPollInterval)
+	defer ticker.Stop()
+
+	var last string
+	for {
+		select {
+		case <-ticker.C:
+			pull, _ := services.Ollama.PullStatus(model)
+			if progress := renderModelPull(pull); progress != last {
+				stream.Send("pull-progress", progress)
+				last = progress
+			}
+			if pull.Done {
+				stream.Send("pull-done", "")
+				return
+			}
+		case <-stream.Heartbeat():
+			stream.Ping()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// renderModelPull renders a download's progress for the pull card
+func renderModelPull(pull services.ModelPull) string {
+	model := template.HTMLEscapeString(pull.Model)
+
+	switch {
+	case pull.Done && pull.Error != "":
+		return fmt.Sprintf(`<div class="text-sm text-error">Failed to download %s: %s</div>`,
+			model, template.HTMLEscapeString(pull.Error))
+	case pull.Done:
+		// Tell the installed models list to reload
+		return fmt.Sprintf(`<div class="text-sm text-success" _="init trigger modelPulled on body">%s is ready to use</div>`, model)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<div class="flex justify-between text-sm"><span>%s</span><span class="text-base-content/60">%s</span></div>`,
+		template.HTMLEscapeString(pull.Status), pull.Progress())
+	fmt.Fprintf(&b, `<progress class="progress progress-primary w-full" value="%d" max="100"></progress>`, pull.Percent())
+	return b.String()
+}
+
+// removeModel handles POST /settings/ai/models/remove
+func (s *SettingsController) removeModel(w http.ResponseWriter, r *http.Request) {
+	s.SetRequest(r)
+
+	model := r.FormValue("model")
+	if services.Ollama.IsDefaultModel(model) {
+		s.RenderError(w, r, errors.New("choose another default model before removing this one"))
+		return
+	}
+
+	if err := services.Ollama.RemoveModel(model); err != nil {
+		s.RenderError(w, r, err)
+		return
+	}
+
+	auth := s.App.Use("auth").(*AuthController)
+	log.Printf("SettingsController: %s removed %s", auth.CurrentUser().Email, model)
+
+	s.Refresh(w, r)
+}
+
+// setDefaultModel handles POST /settings/ai/models/default, saving the model
+// the AI assistant uses unless a conversation picks another
+func (s *SettingsController) setDefaultModel(w http.ResponseWriter, r *http.Request) {
+	s.SetRequest(r)
+
+	if models.IsSetByEnvironment("AI_MODEL") {
+		s.RenderError(w, r, errors.New("the default model is set by the AI_MODEL environment variable"))
+		return
+	}
+
+	model := r.FormValue("model")
+	if !services.Ollama.HasModel(model) {
+		s.RenderError(w, r, fmt.Errorf("%s is not installed", model))
+		return
+	}
+
+	auth := s.App.Use("auth").(*AuthController)
+	user := auth.CurrentUser()
+
+	settings, err := models.GetSettings()
+	if err != nil {
+		s.RenderError(w, r, err)
+		return
+	}
+	settings.AIModel = model
+	settings.LastUpdatedBy = user.Email
+	settings.LastUpdatedAt = time.Now()
+	if err := models.GlobalSettings.Update(settings); err != nil {
+		s.RenderError(w, r, err)
+		return
+	}
+
+	settings.ApplyEnvironment()
+	services.Ollama.SetDefaultModel(model)
+	log.Printf("SettingsController: Default AI model set to %s by %s", model, user.Email)
+
+	s.Refresh(w, r)
+}
+
+// updateOllamaHost handles POST /settings/ai/host, sending AI requests to
+// remote Ollama servers, or back to the local container when left empty
+func (s *SettingsController) updateOllamaHost(w http.ResponseWriter, r *http.Request) {
+	s.SetRequest(r)
+
+	if models.IsSetByEnvironment("OLLAMA_HOST") {
+		s.RenderError(w, r, errors.New("the Ollama servers are set by the OLLAMA_HOST environment variable"))
+		return
+	}
+
+	hosts, err := services.ParseOllamaHosts(r.FormValue("ollama_host"))
+	if err != nil {
+		s.RenderError(w, r, err)
+		return
+	}
+
+	// Refuse a bank where no server answers, AI would stop working
+	if len(hosts) > 0 {
+		var failures []string
+		for _, host := range hosts {
+			if err := services.CheckOllamaEndpoint(host); err != nil {
+				failures = append(failures, fmt.Sprintf("%s: %v", host, err))
+			}
+		}
+		if len(failures) == len(hosts) {
+			s.RenderError(w, r, fmt.Errorf("no Ollama server answered (%s)", strings.Join(failures, "; ")))
+			return
+		}
+	}
+
+	auth := s.App.Use("auth").(*AuthController)
+	user := auth.CurrentUser()
+
+	settings, err := models.GetSettings()
+	if err != nil {
+		s.RenderError(w, r, err)
+		return
+	}
+	settings.OllamaHost = strings.Join(hosts, ", ")
+	settings.LastUpdatedBy = user.Email
+	settings.LastUpdatedAt = time.Now()
+	if err := models.GlobalSettings.Update(settings); err != nil {
+		s.RenderError(w, r, err)
+		return
+	}
+
+	settings.ApplyEnvironment()
+	services.Ollama.SetRemoteHosts(hosts)
+	if os.Getenv("AI_ENABLED") == "true" {
+		// Checks the new servers, or starts the container again
+		services.EnableAI("")
+	}
+
+	if len(hosts) == 0 {
+		log.Printf("SettingsController: %s moved Ollama back to the local container", user.Email)
+	} else {
+		log.Printf("SettingsController: %s pointed Ollama at %s", user.Email, settings.OllamaHost)
+	}
+
+	s.Refresh(w, r)
+}
+
+// updateKeepAlive handles POST /settings/ai/keep-alive, saving how long
+// models stay in memory after their last request
+func (s *SettingsController) updateKeepAlive(w http.ResponseWriter, r *http.Request) {
+	s.SetRequest(r)
+
+	if models.IsSetByEnvironment("OLLAMA_KEEP_ALIVE") {
+		s.RenderError(w, r, errors.New("the keep-alive is set by the OLLAMA_KEEP_ALIVE environment variable"))
+		return
+	}
+
+	keepAlive, err := services.ParseKeepAlive(r.FormValue("keep_alive"))
+	if err != nil {
+		s.RenderError(w, r, err)
+		return
+	}
+
+	auth := s.App.Use("auth").(*AuthController)
+	user := auth.CurrentUser()
+
+	settings, err := models.GetSettings()
+	if err != nil {
+		s.RenderError(w, r, err)
+		return
+	}
+	settings.OllamaKeepAlive = keepAlive
+	settings.LastUpdatedBy = user.Email
+	settings.LastUpdatedAt = time.Now()
+	if err := models.GlobalSettings.Update(settings); err != nil {
+		s.RenderError(w, r, err)
+		return
+	}
+
+	settings.ApplyEnvironment()
+	log.Printf("SettingsController: %s set the Ollama keep-alive to %s", user.Email, services.Ollama.KeepAlive())
+
+	s.Refresh(w, r)
+}
+
+// updateReportDigest handles POST /settings/ai/report-digest, saving who
+// the daily health reports are emailed to and the webhook they're posted to
+func (s *SettingsController) updateReportDigest(w http.ResponseWriter, r *http.Request) {
+	s.SetRequest(r)
+
+	recipients := strings.TrimSpace(r.FormValue("recipients"))
+	if recipients != "" {
+		if _, err := mail.ParseAddressList(recipients); err != nil {
+			s.RenderError(w, r, errors.New("recipients must be a comma separated list of email addresses"))
+			return
+		}
+	}
+
+	webhookURL := strings.TrimSpace(r.FormValue("webhook_url"))
+	if webhookURL != "" {
+		if u, err := url.Parse(webhookURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			s.RenderError(w, r, errors.New("the webhook must be an http or https URL"))
+			return
+		}
+	}
+
+	auth := s.App.Use("auth").(*AuthController)
+	user := auth.CurrentUser()
+
+	settings, err := models.GetSettings()
+	if err != nil {
+		s.RenderError(w, r, err)
+		return
+	}
+	settings.DailyReportRecipients = recipients
+	settings.DailyReportWebhookURL = webhookURL
+	settings.LastUpdatedBy = user.Email
+	settings.LastUpdatedAt = time.Now()
+	if err := models.GlobalSettings.Update(settings); err != nil {
+		s.RenderError(w, r, err)
+		return
+	}
+
+	log.Printf("SettingsController: %s updated the daily report digest", user.Email)
+	s.Refresh(w, r)
+}
+
+// preloadModel handles POST /settings/ai/models/preload, loading a model
+// into memory in the background so the next request doesn't wait for it
+func (s *SettingsController) preloadModel(w http.ResponseWriter, r *http.Request) {
+	s.SetRequest(r)
+
+	model := r.FormValue("model")
+	if model != "" && !services.Ollama.HasModel(model) {
+		s.RenderError(w, r, fmt.Errorf("%s is not installed", model))
+		return
+	}
+	if err := services.Ollama.StartPreload(model); err != nil {
+		s.RenderError(w, r, err)
+		return
+	}
+
+	auth := s.App.Use("auth").(*AuthController)
+	user := auth.CurrentUser()
+	if model == "" {
+		model = services.Ollama.GetDefaultModel()
+	}
+	log.Printf("SettingsController: %s started loading %s", user.Email, model)
+	models.LogActivity("ai_model_preloaded", "Preloaded an AI model",
+		fmt.Sprintf("Loading %s into memory for %s", model, services.Ollama.KeepAlive()), user.ID, "", "ai_model", model)
+
+	s.Refresh(w, r)
+}
+
+// unloadModel handles POST /settings/ai/models/unload, freeing the memory a
+// model holds until its next request
+func (s *SettingsController) unloadModel(w http.ResponseWriter, r *http.Request) {
+	s.SetRequest(r)
+
+	model := r.FormValue("model")
+	if err := services.Ollama.Unload(r.Context(), model); err != nil {
+		s.RenderError(w, r, err)
+		return
+	}
+
+	auth := s.App.Use("auth").(*AuthController)
+	log.Printf("SettingsController: %s unloaded %s", auth.CurrentUser().Email, model)
+
+	s.Refresh(w, r)
+}
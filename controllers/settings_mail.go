@@ -0,0 +1,176 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+
+	"workspace/models"
+	"workspace/services"
+)
+
+// MailSetByEnvironment reports whether the SMTP_ environment variables
+// choose the mail server instead of the settings page
+func (s *SettingsController) MailSetByEnvironment() bool {
+	return services.MailSetByEnvironment()
+}
+
+// MailConfigured reports whether outgoing mail can be sent
+func (s *SettingsController) MailConfigured() bool {
+	return services.MailConfigured()
+}
+
+// HasMailPassword reports whether a mail server password is stored
+func (s *SettingsController) HasMailPassword() bool {
+	_, err := models.GetMailPassword()
+	return err == nil
+}
+
+// MailPreference returns what the signed in user wants emailed to them
+func (s *SettingsController) MailPreference() (*models.MailPreference, error) {
+	auth := s.App.Use("auth").(*AuthController)
+	user := auth.CurrentUser()
+	if user == nil {
+		return nil, errors.New("not signed in")
+	}
+	return models.GetMailPreference(user.ID)
+}
+
+// workspaceURL returns the address links in emails start with, falling
+// back to the address of the request when none is set
+func workspaceURL(r *http.Request) string {
+	if settings, err := models.GetSettings(); err == nil && settings.MailAddress() != "" {
+		return settings.MailAddress()
+	}
+	return requestURL(r)
+}
+
+// requestURL returns the scheme and host a request was made to
+func requestURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// updateMail handles POST /settings/mail, saving the server outgoing mail
+// goes through. An empty host turns mail off and forgets the password.
+func (s *SettingsController) updateMail(w http.ResponseWriter, r *http.Request) {
+	s.SetRequest(r)
+	auth := s.App.Use("auth").(*AuthController)
+	user := auth.CurrentUser()
+
+	settings, err := models.GetSettings()
+	if err != nil {
+		s.RenderError(w, r, err)
+		return
+	}
+
+	host := strings.TrimSpace(r.FormValue("mail_host"))
+	if host == "" {
+		settings.MailHost = ""
+		settings.MailUsername = ""
+		if err := models.GlobalSettings.Update(settings); err != nil {
+			s.RenderError(w, r, err)
+			return
+		}
+		models.DeleteMailPassword()
+		log.Printf("SettingsController: %s turned off outgoing mail", user.Email)
+		s.Refresh(w, r)
+		return
+	}
+
+	port := 0
+	if value := strings.TrimSpace(r.FormValue("mail_port")); value != "" {
+		if port, err = strconv.Atoi(value); err != nil || port < 1 || port > 65535 {
+			s.RenderError(w, r, errors.New("the port must be between 1 and 65535"))
+			return
+		}
+	}
+
+	security := r.FormValue("mail_security")
+	switch security {
+	case models.MailStartTLS, models.MailTLS, models.MailPlain:
+	default:
+		s.RenderError(w, r, errors.New("choose how the connection is secured"))
+		return
+	}
+
+	from := strings.TrimSpace(r.FormValue("mail_from"))
+	if from != "" {
+		if _, err := mail.ParseAddress(from); err != nil {
+			s.RenderError(w, r, errors.New("the from address must be an email address"))
+			return
+		}
+	}
+
+	// The password is never sent back to the browser, so empty keeps it
+	username := strings.TrimSpace(r.FormValue("mail_username"))
+	if password := r.FormValue("mail_password"); password != "" && username != "" {
+		if err := models.StoreMailPassword(password); err != nil {
+			s.RenderError(w, r, err)
+			return
+		}
+	} else if username == "" {
+		models.DeleteMailPassword()
+	}
+
+	settings.MailHost = host
+	settings.MailPort = port
+	settings.MailSecurity = security
+	settings.MailFrom = from
+	settings.MailUsername = username
+	settings.PublicURL = strings.TrimRight(strings.TrimSpace(r.FormValue("public_url")), "/")
+	if settings.PublicURL == "" {
+		settings.PublicURL = requestURL(r)
+	}
+	settings.LastUpdatedBy = user.Email
+	settings.LastUpdatedAt = time.Now()
+	if err := models.GlobalSettings.Update(settings); err != nil {
+		s.RenderError(w, r, err)
+		return
+	}
+
+	log.Printf("SettingsController: %s set outgoing mail to %s", user.Email, host)
+	s.Refresh(w, r)
+}
+
+// sendTestMail handles POST /settings/mail/test, emailing the signed in
+// administrator through the saved mail server
+func (s *SettingsController) sendTestMail(w http.ResponseWriter, r *http.Request) {
+	s.SetRequest(r)
+	auth := s.App.Use("auth").(*AuthController)
+	user := auth.CurrentUser()
+
+	if !services.MailConfigured() {
+		s.RenderError(w, r, errors.New("save a mail server first"))
+		return
+	}
+	if err := services.SendTemplate([]string{user.Email}, "test", services.MailMessage{}); err != nil {
+		s.RenderError(w, r, err)
+		return
+	}
+	w.Write([]byte(`<div class="alert alert-success">Test email sent to ` + user.Email + `</div>`))
+}
+
+// updateMailPreference handles POST /settings/account/email, turning the
+// signed in user's notification digests on or off
+func (s *SettingsController) updateMailPreference(w http.ResponseWriter, r *http.Request) {
+	s.SetRequest(r)
+
+	pref, err := s.MailPreference()
+	if err != nil {
+		s.RenderError(w, r, err)
+		return
+	}
+	if err := pref.SetDigests(r.FormValue("digests") == "true"); err != nil {
+		s.RenderError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,238 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"workspace/internal/migration"
+	"workspace/models"
+	"workspace/services"
+)
+
+// Migrations returns the transfers to other workspaces, newest first
+func (s *SettingsController) Migrations() ([]*models.Migration, error) {
+	return models.GetMigrations()
+}
+
+// MigrationInvites returns the invites other workspaces can send data with
+func (s *SettingsController) MigrationInvites() ([]*models.MigrationInvite, error) {
+	return models.GetMigrationInvites()
+}
+
+// MigrationRunning returns true while a migration is being sent
+func (s *SettingsController) MigrationRunning(id string) bool {
+	return services.Migrator.IsRunning(id)
+}
+
+// createMigrationInvite handles POST /settings/migration/invites, showing
+// the new invite's token once
+func (s *SettingsController) createMigrationInvite(w http.ResponseWriter, r *http.Request) {
+	s.SetRequest(r)
+	auth := s.App.Use("auth").(*AuthController)
+	user := auth.CurrentUser()
+
+	invite, token, err := models.CreateMigrationInvite(user.ID)
+	if err != nil {
+		s.RenderError(w, r, err)
+		return
+	}
+
+	models.LogActivity("migration_invite_created", "Created a migration invite",
+		"Another workspace can send its data here until "+invite.ExpiresAt.Format("Jan 2, 2006"),
+		user.ID, "", "migration_invite", invite.ID)
+
+	s.Render(w, r, "migration-invite-created.html", map[string]any{
+		"Invite": invite,
+		"Token":  token,
+	})
+}
+
+// revokeMigrationInvite handles POST /settings/migration/invites/{id}/revoke
+func (s *SettingsController) revokeMigrationInvite(w http.ResponseWriter, r *http.Request) {
+	s.SetRequest(r)
+	invite, err := models.MigrationInvites.Get(r.PathValue("id"))
+	if err != nil {
+		s.RenderError(w, r, errors.New("invite not found"))
+		return
+	}
+	if err := invite.Revoke(); err != nil {
+		s.RenderError(w, r, err)
+		return
+	}
+	s.Refresh(w, r)
+}
+
+// startMigration handles POST /settings/migration, checking the target
+// accepts the token before planning and starting the transfer
+func (s *SettingsController) startMigration(w http.ResponseWriter, r *http.Request) {
+	s.SetRequest(r)
+	auth := s.App.Use("auth").(*AuthController)
+	user := auth.CurrentUser()
+
+	targetURL, token := r.FormValue("target_url"), r.FormValue("token")
+	target, err := services.CheckMigrationTarget(targetURL, token)
+	if err != nil {
+		s.RenderError(w, r, err)
+		return
+	}
+
+	m, err := models.CreateMigration(targetURL, token, user.ID)
+	if err != nil {
+		s.RenderError(w, r, err)
+		return
+	}
+	if err := services.Migrator.Start(m); err != nil {
+		s.RenderError(w, r, err)
+		return
+	}
+
+	log.Printf("SettingsController: %s started a migration to %s", user.Email, m.TargetURL)
+	models.LogActivity("migration_started", "Started a migration",
+		fmt.Sprintf("Sending this workspace to %s (%s)", target, m.TargetURL), user.ID, "", "migration", m.ID)
+	s.Refresh(w, r)
+}
+
+// resumeMigration handles POST /settings/migration/{id}/resume
+func (s *SettingsController) resumeMigration(w http.ResponseWriter, r *http.Request) {
+	s.SetRequest(r)
+	m, err := models.Migrations.Get(r.PathValue("id"))
+	if err != nil {
+		s.RenderError(w, r, errors.New("migration not found"))
+		return
+	}
+	if m.Status == models.MigrationCompleted {
+		s.RenderError(w, r, errors.New("migration is complete, run a final sync to send everything again"))
+		return
+	}
+	if err := services.Migrator.Start(m); err != nil {
+		s.RenderError(w, r, err)
+		return
+	}
+	s.Refresh(w, r)
+}
+
+// pauseMigration handles POST /settings/migration/{id}/pause
+func (s *SettingsController) pauseMigration(w http.ResponseWriter, r *http.Request) {
+	s.SetRequest(r)
+	services.Migrator.Pause(r.PathValue("id"))
+	s.Refresh(w, r)
+}
+
+// resyncMigration handles POST /settings/migration/{id}/resync, sending
+// everything again for the final sync of a cutover
+func (s *SettingsController) resyncMigration(w http.ResponseWriter, r *http.Request) {
+	s.SetRequest(r)
+	auth := s.App.Use("auth").(*AuthController)
+	user := auth.CurrentUser()
+
+	m, err := models.Migrations.Get(r.PathValue("id"))
+	if err != nil {
+		s.RenderError(w, r, errors.New("migration not found"))
+		return
+	}
+	if services.Migrator.IsRunning(m.ID) {
+		s.RenderError(w, r, errors.New("pause the migration before syncing again"))
+		return
+	}
+	if err := m.Resync(); err != nil {
+		s.RenderError(w, r, err)
+		return
+	}
+	if err := services.Migrator.Start(m); err != nil {
+		s.RenderError(w, r, err)
+		return
+	}
+
+	models.LogActivity("migration_resynced", "Started a final sync",
+		"Sending everything to "+m.TargetURL+" again", user.ID, "", "migration", m.ID)
+	s.Refresh(w, r)
+}
+
+// toggleCutoverStep handles POST /settings/migration/{id}/checklist
+func (s *SettingsController) toggleCutoverStep(w http.ResponseWriter, r *http.Request) {
+	s.SetRequest(r)
+	m, err := models.Migrations.Get(r.PathValue("id"))
+	if err != nil {
+		s.RenderError(w, r, errors.New("migration not found"))
+		return
+	}
+	if err := m.ToggleCutoverStep(r.FormValue("step")); err != nil {
+		s.RenderError(w, r, err)
+		return
+	}
+	s.Refresh(w, r)
+}
+
+// deleteMigration handles POST /settings/migration/{id}/delete. Nothing is
+// removed from the target workspace.
+func (s *SettingsController) deleteMigration(w http.ResponseWriter, r *http.Request) {
+	s.SetRequest(r)
+	m, err := models.Migrations.Get(r.PathValue("id"))
+	if err != nil {
+		s.RenderError(w, r, errors.New("migration not found"))
+		return
+	}
+	if services.Migrator.IsRunning(m.ID) {
+		s.RenderError(w, r, errors.New("pause the migration before deleting it"))
+		return
+	}
+	if err := models.DeleteMigration(m); err != nil {
+		s.RenderError(w, r, err)
+		return
+	}
+	s.Refresh(w, r)
+}
+
+// receiveMigration handles the requests a sending workspace makes with an
+// invite from here. They carry no session; each is sealed with the invite's
+// key instead, so only the holder of the token can send or ask for status.
+func (s *SettingsController) receiveMigration(w http.ResponseWriter, r *http.Request) {
+	invite, err := models.MigrationInvites.Get(r.Header.Get(migration.InviteHeader))
+	if err != nil || !invite.Usable() {
+		http.Error(w, "invite is not valid", http.StatusUnauthorized)
+		return
+	}
+	key, err := invite.SealingKey()
+	if err != nil {
+		http.Error(w, "invite is not valid", http.StatusUnauthorized)
+		return
+	}
+	req, err := migration.ReadRequest(r, key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	invite.Touch(r.RemoteAddr)
+
+	w.Header().Set("Content-Type", "application/json")
+	if req.Item == "" {
+		status, err := invite.Status()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(status)
+		return
+	}
+
+	receipt, err := invite.Receive(req)
+	if errors.Is(err, models.ErrMigrationOffset) {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(receipt)
+		return
+	}
+	if err != nil {
+		log.Printf("SettingsController: Failed to receive %s from %s: %v", req.Item, r.RemoteAddr, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// Secrets are sent last, so they mark the end of a transfer
+	if req.Item == "secrets" {
+		models.LogActivity("migration_received", "Received a migration",
+			"Imported a workspace sent from "+r.RemoteAddr, invite.CreatedBy, "", "migration_invite", invite.ID)
+	}
+	json.NewEncoder(w).Encode(receipt)
+}
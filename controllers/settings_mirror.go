@@ -0,0 +1,131 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"workspace/internal/mirror"
+	"workspace/models"
+	"workspace/services"
+)
+
+// MirrorReport returns every repository with the status of its last push
+// to the mirror organization
+func (s *SettingsController) MirrorReport() ([]models.MirrorReportRow, error) {
+	return models.MirrorReport()
+}
+
+// MirrorRunning reports whether a mirror export is in progress
+func (s *SettingsController) MirrorRunning() bool {
+	return services.Mirrors.IsRunning()
+}
+
+// HasMirrorToken reports whether a mirror access token is stored
+func (s *SettingsController) HasMirrorToken() bool {
+	_, err := models.GetMirrorToken()
+	return err == nil
+}
+
+// updateMirror handles POST /settings/mirror, saving the organization
+// repositories are mirrored to once the token has been checked against it.
+// An empty provider turns the export off and forgets the token.
+func (s *SettingsController) updateMirror(w http.ResponseWriter, r *http.Request) {
+	s.SetRequest(r)
+	auth := s.App.Use("auth").(*AuthController)
+	user := auth.CurrentUser()
+
+	settings, err := models.GetSettings()
+	if err != nil {
+		s.RenderError(w, r, err)
+		return
+	}
+
+	provider := r.FormValue("mirror_provider")
+	if provider == "" {
+		settings.MirrorProvider = ""
+		settings.MirrorOrg = ""
+		settings.MirrorBaseURL = ""
+		if err := models.GlobalSettings.Update(settings); err != nil {
+			s.RenderError(w, r, err)
+			return
+		}
+		models.DeleteMirrorToken()
+		log.Printf("SettingsController: %s turned off mirror export", user.Email)
+		s.Refresh(w, r)
+		return
+	}
+
+	hours, err := strconv.Atoi(r.FormValue("mirror_interval_hours"))
+	if err != nil || hours < 1 || hours > 24*7 {
+		s.RenderError(w, r, errors.New("the mirror interval must be between 1 and 168 hours"))
+		return
+	}
+
+	target := mirror.Target{
+		Provider: provider,
+		Org:      strings.Trim(strings.TrimSpace(r.FormValue("mirror_org")), "/"),
+		Token:    strings.TrimSpace(r.FormValue("mirror_token")),
+	}
+	if provider == models.MirrorGitLab {
+		target.BaseURL = strings.TrimSpace(r.FormValue("mirror_base_url"))
+	}
+	// The token is never sent back to the browser, so empty keeps it
+	newToken := target.Token != ""
+	if !newToken {
+		target.Token, _ = models.GetMirrorToken()
+	}
+
+	client, err := mirror.NewClient(target)
+	if err != nil {
+		s.RenderError(w, r, err)
+		return
+	}
+	if err := client.Check(); err != nil {
+		s.RenderError(w, r, err)
+		return
+	}
+
+	if newToken {
+		if err := models.StoreMirrorToken(target.Token); err != nil {
+			s.RenderError(w, r, err)
+			return
+		}
+	}
+	settings.MirrorProvider = target.Provider
+	settings.MirrorBaseURL = target.BaseURL
+	settings.MirrorOrg = target.Org
+	settings.MirrorIntervalHours = hours
+	settings.LastUpdatedBy = user.Email
+	settings.LastUpdatedAt = time.Now()
+	if err := models.GlobalSettings.Update(settings); err != nil {
+		s.RenderError(w, r, err)
+		return
+	}
+
+	log.Printf("SettingsController: %s set mirror export to %s %s", user.Email, target.Provider, target.Org)
+	s.Refresh(w, r)
+}
+
+// runMirror handles POST /settings/mirror/run, starting a mirror export now
+func (s *SettingsController) runMirror(w http.ResponseWriter, r *http.Request) {
+	s.SetRequest(r)
+
+	settings, err := models.GetSettings()
+	if err != nil {
+		s.RenderError(w, r, err)
+		return
+	}
+	if !settings.MirrorEnabled() {
+		s.RenderError(w, r, errors.New("choose an organization to mirror to first"))
+		return
+	}
+	if err := services.Mirrors.RunAsync(); err != nil {
+		s.RenderError(w, r, err)
+		return
+	}
+	s.Refresh(w, r)
+}
@@ -0,0 +1,41 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"workspace/models"
+	"workspace/services"
+)
+
+// RegistryRunning reports whether the container registry is up. It starts
+// when an image is first pushed or pulled.
+func (s *SettingsController) RegistryRunning() bool {
+	return services.Registry.IsRunning()
+}
+
+// RegistryLastGC returns the registry's last garbage collection since the
+// server started, if any
+func (s *SettingsController) RegistryLastGC() *services.RegistryGC {
+	return services.Registry.LastGC()
+}
+
+// collectRegistryGarbage handles POST /settings/registry/gc, removing
+// untagged images and unused layers now rather than at the daily run
+func (s *SettingsController) collectRegistryGarbage(w http.ResponseWriter, r *http.Request) {
+	s.SetRequest(r)
+	auth := s.App.Use("auth").(*AuthController)
+	user := auth.CurrentUser()
+
+	gc, err := services.Registry.GarbageCollect()
+	if err != nil {
+		s.RenderError(w, r, err)
+		return
+	}
+
+	models.LogActivity("registry_gc", "Collected container registry garbage",
+		fmt.Sprintf("Took %s, %s", gc.Duration.Round(time.Millisecond), gc.Output), user.ID, "", "settings", "")
+
+	s.Refresh(w, r)
+}
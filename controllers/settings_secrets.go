@@ -0,0 +1,61 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"workspace/models"
+)
+
+// WorkspaceSecrets returns the secrets every repository's runs get. Only
+// names and history are shown, never values.
+func (s *SettingsController) WorkspaceSecrets() ([]*models.ActionSecret, error) {
+	return models.GetActionSecrets("")
+}
+
+// saveWorkspaceSecret handles POST /settings/secrets, creating a workspace
+// secret or rotating the value of an existing one
+func (s *SettingsController) saveWorkspaceSecret(w http.ResponseWriter, r *http.Request) {
+	s.SetRequest(r)
+	auth := s.App.Use("auth").(*AuthController)
+	user := auth.CurrentUser()
+
+	secret, err := models.SetActionSecret("", r.FormValue("name"), r.FormValue("value"), user.ID)
+	if err != nil {
+		s.RenderError(w, r, err)
+		return
+	}
+
+	if secret.RotatedAt.IsZero() {
+		models.LogActivity("secret_created", "Added workspace secret "+secret.Name,
+			"Every repository's actions and workflows get it", user.ID, "", "action_secret", secret.ID)
+	} else {
+		models.LogActivity("secret_rotated", "Rotated workspace secret "+secret.Name,
+			"Later runs get the new value", user.ID, "", "action_secret", secret.ID)
+	}
+
+	s.Refresh(w, r)
+}
+
+// deleteWorkspaceSecret handles POST /settings/secrets/{id}/delete
+func (s *SettingsController) deleteWorkspaceSecret(w http.ResponseWriter, r *http.Request) {
+	s.SetRequest(r)
+	auth := s.App.Use("auth").(*AuthController)
+	user := auth.CurrentUser()
+
+	secret, err := models.ActionSecrets.Get(r.PathValue("id"))
+	if err != nil || secret.RepoID != "" {
+		s.RenderError(w, r, errors.New("secret not found"))
+		return
+	}
+
+	if err := models.DeleteActionSecret(secret); err != nil {
+		s.RenderError(w, r, err)
+		return
+	}
+
+	models.LogActivity("secret_deleted", "Removed workspace secret "+secret.Name,
+		"Runs no longer get it", user.ID, "", "action_secret", secret.ID)
+
+	s.Refresh(w, r)
+}
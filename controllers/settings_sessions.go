@@ -0,0 +1,78 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"workspace/models"
+)
+
+// Sessions returns the browsers signed in as the current user, most
+// recently seen first
+func (s *SettingsController) Sessions() ([]*models.UserSession, error) {
+	auth := s.App.Use("auth").(*AuthController)
+	user, _, err := auth.Authenticate(s.Request)
+	if err != nil {
+		return nil, err
+	}
+	return models.ActiveUserSessions(user.ID)
+}
+
+// revokeSession handles POST /settings/sessions/{id}/revoke, signing out
+// one of the user's browsers
+func (s *SettingsController) revokeSession(w http.ResponseWriter, r *http.Request) {
+	s.SetRequest(r)
+	auth := s.App.Use("auth").(*AuthController)
+	user, current, err := auth.sessionUser(r)
+	if err != nil {
+		s.RenderError(w, r, err)
+		return
+	}
+
+	// Users only see and sign out their own sessions
+	session, err := models.UserSessions.Get(r.PathValue("id"))
+	if err != nil || session.UserID != user.ID {
+		s.RenderError(w, r, errors.New("session not found"))
+		return
+	}
+
+	if err := session.Revoke(user.ID); err != nil {
+		s.RenderError(w, r, err)
+		return
+	}
+
+	models.LogActivity("session_revoked", "Signed out a session",
+		fmt.Sprintf("Signed out %s from %s", session.Device(), session.LastSeenIP), user.ID, "", "session", session.ID)
+
+	// Signing out this browser is signing out
+	if session.ID == current.ID {
+		auth.auth.ClearCookie(w, auth.cookieName)
+		s.Redirect(w, r, "/signin")
+		return
+	}
+	s.Refresh(w, r)
+}
+
+// revokeOtherSessions handles POST /settings/sessions/others/revoke,
+// signing out every browser but this one
+func (s *SettingsController) revokeOtherSessions(w http.ResponseWriter, r *http.Request) {
+	s.SetRequest(r)
+	auth := s.App.Use("auth").(*AuthController)
+	user, current, err := auth.sessionUser(r)
+	if err != nil {
+		s.RenderError(w, r, err)
+		return
+	}
+
+	revoked, err := models.RevokeUserSessions(user.ID, current.ID, user.ID)
+	if err != nil {
+		s.RenderError(w, r, err)
+		return
+	}
+
+	models.LogActivity("sessions_revoked", "Signed out other sessions",
+		fmt.Sprintf("Signed out %d other browsers", revoked), user.ID, "", "session", "")
+
+	s.Refresh(w, r)
+}
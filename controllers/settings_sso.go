@@ -0,0 +1,135 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"workspace/internal/oidc"
+	"workspace/models"
+)
+
+// HasSSOClientSecret reports whether the provider's client secret is stored
+func (s *SettingsController) HasSSOClientSecret() bool {
+	_, err := models.GetSSOClientSecret()
+	return err == nil
+}
+
+// SSOCallbackURL returns the address to register with the provider as the
+// redirect URI
+func (s *SettingsController) SSOCallbackURL() string {
+	return workspaceURL(s.Request) + ssoCookiePath + "/callback"
+}
+
+// SSOPasswordOverride reports whether SSO_ALLOW_PASSWORD lets users sign in
+// with passwords even though settings turn them off
+func (s *SettingsController) SSOPasswordOverride() bool {
+	settings, err := models.GetSettings()
+	return err == nil && settings.SSOPasswordOff && settings.SSOVerified() && !settings.PasswordLoginDisabled()
+}
+
+// SSOIdentityCount returns how many users have signed in through the provider
+func (s *SettingsController) SSOIdentityCount() int {
+	return models.SSOIdentities.Count("")
+}
+
+// updateSSO handles POST /settings/sso, saving the provider users sign in
+// with. The provider is discovered before saving so mistakes show up here
+// rather than on the sign-in page. Pointing at another provider or client
+// needs sign-in tested again before passwords can be turned off.
+func (s *SettingsController) updateSSO(w http.ResponseWriter, r *http.Request) {
+	s.SetRequest(r)
+	auth := s.App.Use("auth").(*AuthController)
+	user := auth.CurrentUser()
+
+	settings, err := models.GetSettings()
+	if err != nil {
+		s.RenderError(w, r, err)
+		return
+	}
+
+	discoveryURL := strings.TrimRight(strings.TrimSpace(r.FormValue("sso_discovery_url")), "/")
+	clientID := strings.TrimSpace(r.FormValue("sso_client_id"))
+	enabled := r.FormValue("sso_enabled") == "true"
+	if enabled && (discoveryURL == "" || clientID == "") {
+		s.RenderError(w, r, errors.New("enter the provider's discovery URL and the client ID"))
+		return
+	}
+	if discoveryURL != "" {
+		if u, err := url.Parse(discoveryURL); err != nil || (u.Scheme != "https" && u.Hostname() != "localhost") {
+			s.RenderError(w, r, errors.New("the discovery URL must start with https://"))
+			return
+		}
+		if discoveryURL != settings.SSODiscoveryURL {
+			if _, err := oidc.Discover(r.Context(), discoveryURL); err != nil {
+				s.RenderError(w, r, err)
+				return
+			}
+		}
+	}
+
+	passwordOff := settings.SSOPasswordOff
+	settings.SSOGroupRoles = strings.TrimSpace(r.FormValue("sso_group_roles"))
+	mappings, err := settings.SSOGroupMappings()
+	if err != nil {
+		s.RenderError(w, r, err)
+		return
+	}
+	for _, mapping := range mappings {
+		if mapping.Team == "" {
+			continue
+		}
+		if teams, err := models.Teams.Search("WHERE Name = ? COLLATE NOCASE", mapping.Team); err != nil || len(teams) == 0 {
+			s.RenderError(w, r, errors.New("there's no team named "+mapping.Team))
+			return
+		}
+	}
+
+	// The secret is never sent back to the browser, so empty keeps it
+	if secret := r.FormValue("sso_client_secret"); secret != "" {
+		if err := models.StoreSSOClientSecret(secret); err != nil {
+			s.RenderError(w, r, err)
+			return
+		}
+	} else if enabled && !s.HasSSOClientSecret() {
+		s.RenderError(w, r, errors.New("enter the client secret the provider gave you"))
+		return
+	}
+
+	if discoveryURL != settings.SSODiscoveryURL || clientID != settings.SSOClientID || !enabled {
+		settings.SSOVerifiedAt = time.Time{}
+	}
+	settings.SSOEnabled = enabled
+	settings.SSOProviderName = strings.TrimSpace(r.FormValue("sso_provider_name"))
+	settings.SSODiscoveryURL = discoveryURL
+	settings.SSOClientID = clientID
+	settings.SSOAllowedDomains = strings.TrimSpace(r.FormValue("sso_allowed_domains"))
+	settings.SSOAllowedDomains = strings.Join(settings.SSODomainList(), "\n")
+	settings.SSOAutoCreate = r.FormValue("sso_auto_create") == "true"
+	settings.SSOGroupsClaim = strings.TrimSpace(r.FormValue("sso_groups_claim"))
+	settings.SSOPasswordOff = r.FormValue("sso_password_off") == "true"
+	if settings.SSOPasswordOff && !settings.SSOVerified() {
+		s.RenderError(w, r, errors.New("test signing in with the provider before turning password sign-in off"))
+		return
+	}
+	settings.LastUpdatedBy = user.Email
+	settings.LastUpdatedAt = time.Now()
+	if err := models.GlobalSettings.Update(settings); err != nil {
+		s.RenderError(w, r, err)
+		return
+	}
+
+	if passwordOff != settings.SSOPasswordOff {
+		state := "back on"
+		if settings.SSOPasswordOff {
+			state = "off"
+		}
+		models.LogActivity("sso_password_login", "Changed password sign-in",
+			"Turned password sign-in "+state, user.ID, "", "settings", "")
+	}
+	log.Printf("SettingsController: %s updated single sign-on settings", user.Email)
+	s.Refresh(w, r)
+}
@@ -0,0 +1,94 @@
+package controllers
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"workspace/internal/storage"
+	"workspace/models"
+)
+
+// StorageLocation describes where uploaded and generated files are kept
+func (s *SettingsController) StorageLocation() string {
+	return models.Storage().String()
+}
+
+// updateStorage handles POST /settings/storage, switching file storage to
+// another backend once it has been checked with a test file
+func (s *SettingsController) updateStorage(w http.ResponseWriter, r *http.Request) {
+	s.SetRequest(r)
+	auth := s.App.Use("auth").(*AuthController)
+	user := auth.CurrentUser()
+
+	settings, err := models.GetSettings()
+	if err != nil {
+		s.RenderError(w, r, err)
+		return
+	}
+
+	settings.StorageBackend = r.FormValue("storage_backend")
+	settings.StoragePath = strings.TrimSpace(r.FormValue("storage_path"))
+	settings.StorageEndpoint = strings.TrimSpace(r.FormValue("storage_endpoint"))
+	settings.StorageBucket = strings.TrimSpace(r.FormValue("storage_bucket"))
+	settings.StorageRegion = strings.TrimSpace(r.FormValue("storage_region"))
+	settings.StoragePrefix = strings.TrimSpace(r.FormValue("storage_prefix"))
+	settings.StorageAccessKey = strings.TrimSpace(r.FormValue("storage_access_key"))
+	// The secret is never sent back to the browser, so empty keeps it
+	if secret := strings.TrimSpace(r.FormValue("storage_secret_key")); secret != "" {
+		settings.StorageSecretKey = secret
+	}
+
+	if settings.StoragePath != "" && !filepath.IsAbs(settings.StoragePath) {
+		s.RenderError(w, r, errors.New("the storage directory must be an absolute path"))
+		return
+	}
+	if !settings.UsesDefaultStorage() {
+		store, err := storage.New(settings.StorageConfig())
+		if err != nil {
+			s.RenderError(w, r, err)
+			return
+		}
+		if err := storage.Check(store); err != nil {
+			s.RenderError(w, r, err)
+			return
+		}
+	}
+
+	settings.LastUpdatedBy = user.Email
+	settings.LastUpdatedAt = time.Now()
+	if err := models.GlobalSettings.Update(settings); err != nil {
+		s.RenderError(w, r, err)
+		return
+	}
+	if err := models.ConfigureStorage(settings); err != nil {
+		s.RenderError(w, r, err)
+		return
+	}
+	ConfigureBackups(settings)
+
+	log.Printf("SettingsController: %s moved file storage to %s", user.Email, models.Storage())
+	models.LogActivity("storage_updated", "Changed file storage",
+		"Files are now kept in "+models.Storage().String(), user.ID, "", "settings", "")
+
+	s.Refresh(w, r)
+}
+
+// serveStored sends a file from storage and closes it. Range requests work
+// when the backend's files can seek, which local ones can.
+func serveStored(w http.ResponseWriter, r *http.Request, file io.ReadCloser, modified time.Time) {
+	defer file.Close()
+
+	if seeker, ok := file.(io.ReadSeeker); ok {
+		http.ServeContent(w, r, "", modified, seeker)
+		return
+	}
+	if !modified.IsZero() {
+		w.Header().Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+	io.Copy(w, file)
+}
@@ -0,0 +1,84 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"workspace/models"
+)
+
+// PersonalTokens returns the current user's personal access tokens,
+// newest first. Only their names and hints are shown, never values.
+func (s *SettingsController) PersonalTokens() ([]*models.PersonalToken, error) {
+	auth := s.App.Use("auth").(*AuthController)
+	user, _, err := auth.Authenticate(s.Request)
+	if err != nil {
+		return nil, err
+	}
+	return models.UserPersonalTokens(user.ID)
+}
+
+// TokenScopes returns the scopes a personal access token can have
+func (s *SettingsController) TokenScopes() []models.TokenScope {
+	return models.TokenScopes
+}
+
+// createPersonalToken handles POST /settings/tokens, showing the new token
+// once
+func (s *SettingsController) createPersonalToken(w http.ResponseWriter, r *http.Request) {
+	s.SetRequest(r)
+	auth := s.App.Use("auth").(*AuthController)
+	user := auth.CurrentUser()
+
+	if err := r.ParseForm(); err != nil {
+		s.RenderError(w, r, err)
+		return
+	}
+	days, err := strconv.Atoi(r.FormValue("expires_days"))
+	if err != nil {
+		s.RenderError(w, r, errors.New("expiry must be a number of days"))
+		return
+	}
+
+	token, value, err := models.CreatePersonalToken(user.ID, r.FormValue("name"), r.Form["scopes"], time.Duration(days)*24*time.Hour)
+	if err != nil {
+		s.RenderError(w, r, err)
+		return
+	}
+
+	models.LogActivity("personal_token_created", fmt.Sprintf("Created personal access token %s", token.Name),
+		fmt.Sprintf("Scopes %s, expires %s", token.Scopes, token.ExpiresAt.Format("Jan 2, 2006")),
+		user.ID, "", "personal_token", token.ID)
+
+	s.Render(w, r, "personal-token-created.html", map[string]any{
+		"Token": token,
+		"Value": value,
+	})
+}
+
+// revokePersonalToken handles POST /settings/tokens/{id}/revoke
+func (s *SettingsController) revokePersonalToken(w http.ResponseWriter, r *http.Request) {
+	s.SetRequest(r)
+	auth := s.App.Use("auth").(*AuthController)
+	user := auth.CurrentUser()
+
+	// Users only see and revoke their own tokens
+	token, err := models.PersonalTokens.Get(r.PathValue("id"))
+	if err != nil || token.UserID != user.ID {
+		s.RenderError(w, r, errors.New("token not found"))
+		return
+	}
+
+	if err := token.Revoke(); err != nil {
+		s.RenderError(w, r, err)
+		return
+	}
+
+	models.LogActivity("personal_token_revoked", fmt.Sprintf("Revoked personal access token %s", token.Name),
+		"Requests with it are refused", user.ID, "", "personal_token", token.ID)
+
+	s.Refresh(w, r)
+}
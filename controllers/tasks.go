@@ -0,0 +1,244 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"workspace/models"
+
+	"github.com/The-Skyscape/devtools/pkg/application"
+	"github.com/The-Skyscape/devtools/pkg/authentication"
+)
+
+// Tasks is a factory function with the prefix and instance
+func Tasks() (string, *TasksController) {
+	return "tasks", &TasksController{}
+}
+
+// TasksController handles workspace task lists
+type TasksController struct {
+	application.Controller
+}
+
+// Setup registers routes
+func (c *TasksController) Setup(app *application.App) {
+	c.Controller.Setup(app)
+	auth := app.Use("auth").(*AuthController)
+
+	http.Handle("GET /tasks", app.Serve("tasks.html", auth.Required))
+	http.Handle("POST /tasks", app.ProtectFunc(c.createTask, auth.Required))
+	http.Handle("POST /tasks/{id}/status", app.ProtectFunc(c.updateTaskStatus, auth.Required))
+	http.Handle("POST /tasks/{id}/delete", app.ProtectFunc(c.deleteTask, auth.Required))
+}
+
+// Handle returns a new controller instance for the request
+func (c TasksController) Handle(req *http.Request) application.Handler {
+	c.Request = req
+	return &c
+}
+
+// MyTasks returns the open tasks assigned to or created by the current user
+func (c *TasksController) MyTasks() ([]*models.Todo, error) {
+	user := c.Use("auth").(*AuthController).CurrentUser()
+	if user == nil {
+		return nil, errors.New("authentication required")
+	}
+	return models.GetTodosForUser(user.ID, false)
+}
+
+// CompletedTasks returns the current user's most recently completed tasks
+func (c *TasksController) CompletedTasks() ([]*models.Todo, error) {
+	user := c.Use("auth").(*AuthController).CurrentUser()
+	if user == nil {
+		return nil, errors.New("authentication required")
+	}
+
+	todos, err := models.GetTodosForUser(user.ID, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var completed []*models.Todo
+	for _, todo := range todos {
+		if todo.IsCompleted() {
+			completed = append(completed, todo)
+		}
+	}
+	slices.SortFunc(completed, func(a, b *models.Todo) int {
+		return b.UpdatedAt.Compare(a.UpdatedAt)
+	})
+	if len(completed) > 20 {
+		completed = completed[:20]
+	}
+	return completed, nil
+}
+
+// IssueTasks returns the tasks attached to the current issue
+func (c *TasksController) IssueTasks() ([]*models.Todo, error) {
+	issueID := c.Request.PathValue("issueID")
+	if issueID == "" {
+		return nil, errors.New("issue ID required")
+	}
+	return models.GetTodosByIssue(issueID)
+}
+
+// Repos returns the repositories the current user can attach tasks to
+func (c *TasksController) Repos() ([]*models.Repository, error) {
+	user := c.Use("auth").(*AuthController).CurrentUser()
+	if user == nil {
+		return nil, errors.New("authentication required")
+	}
+	if user.IsAdmin {
+		return models.Repositories.Search("ORDER BY Name ASC")
+	}
+	return models.Repositories.Search("WHERE Visibility = ? ORDER BY Name ASC", "public")
+}
+
+// Assignees returns the users a task can be assigned to
+func (c *TasksController) Assignees() ([]*authentication.User, error) {
+	return models.Users.Search("ORDER BY Name ASC")
+}
+
+// canModifyTask returns true if the user may change or delete the task
+func canModifyTask(user *authentication.User, todo *models.Todo) bool {
+	return user.IsAdmin || todo.CreatorID == user.ID || todo.AssigneeID == user.ID
+}
+
+// createTask handles adding a task to the user's list, a repository or an issue
+func (c *TasksController) createTask(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	user := c.Use("auth").(*AuthController).CurrentUser()
+
+	content := SanitizeInput(r.FormValue("content"), 500)
+	if content == "" {
+		c.RenderError(w, r, errors.New("task description is required"))
+		return
+	}
+
+	todo := &models.Todo{
+		Content:    content,
+		Status:     models.TodoStatusPending,
+		CreatorID:  user.ID,
+		AssigneeID: r.FormValue("assignee_id"),
+		RepoID:     r.FormValue("repo_id"),
+		IssueID:    r.FormValue("issue_id"),
+	}
+
+	if due := r.FormValue("due_date"); due != "" {
+		date, err := time.ParseInLocation("2006-01-02", due, time.Local)
+		if err != nil {
+			c.RenderError(w, r, errors.New("invalid due date"))
+			return
+		}
+		todo.DueDate = date
+	}
+
+	if todo.AssigneeID != "" {
+		if _, err := models.Users.Get(todo.AssigneeID); err != nil {
+			c.RenderError(w, r, errors.New("assignee not found"))
+			return
+		}
+	}
+
+	// Issue tasks always belong to the issue's repository
+	if todo.IssueID != "" {
+		issue, err := models.Issues.Get(todo.IssueID)
+		if err != nil {
+			c.RenderError(w, r, errors.New("issue not found"))
+			return
+		}
+		todo.RepoID = issue.RepoID
+	}
+
+	if todo.RepoID != "" {
+		if err := models.CheckRepoAccess(user, todo.RepoID, models.RoleRead); err != nil {
+			c.RenderError(w, r, err)
+			return
+		}
+	}
+
+	// Append to the end of whichever list the task belongs to
+	switch {
+	case todo.IssueID != "":
+		existing, _ := models.GetTodosByIssue(todo.IssueID)
+		todo.Position = len(existing) + 1
+	case todo.RepoID != "":
+		existing, _ := models.GetTodosByRepo(todo.RepoID)
+		todo.Position = len(existing) + 1
+	}
+
+	todo, err := models.Todos.Insert(todo)
+	if err != nil {
+		log.Printf("TasksController: Failed to create task: %v", err)
+		c.RenderError(w, r, errors.New("failed to create task"))
+		return
+	}
+
+	models.LogActivity("task_created", "Created task: "+todo.Content,
+		"Task added to the workspace", user.ID, todo.RepoID, "task", todo.ID)
+
+	c.Refresh(w, r)
+}
+
+// updateTaskStatus handles marking a task pending, in progress or completed
+func (c *TasksController) updateTaskStatus(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	user := c.Use("auth").(*AuthController).CurrentUser()
+
+	todo, err := models.Todos.Get(r.PathValue("id"))
+	if err != nil {
+		c.RenderError(w, r, errors.New("task not found"))
+		return
+	}
+
+	if !canModifyTask(user, todo) {
+		c.RenderError(w, r, errors.New("only the creator, assignee or admin can update this task"))
+		return
+	}
+
+	status := r.FormValue("status")
+	switch status {
+	case models.TodoStatusPending, models.TodoStatusInProgress, models.TodoStatusCompleted:
+	default:
+		c.RenderError(w, r, errors.New("invalid task status"))
+		return
+	}
+
+	if err := todo.UpdateStatus(status); err != nil {
+		c.RenderError(w, r, errors.New("failed to update task"))
+		return
+	}
+
+	c.Refresh(w, r)
+}
+
+// deleteTask handles removing a task
+func (c *TasksController) deleteTask(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	user := c.Use("auth").(*AuthController).CurrentUser()
+
+	todo, err := models.Todos.Get(r.PathValue("id"))
+	if err != nil {
+		c.RenderError(w, r, errors.New("task not found"))
+		return
+	}
+
+	if !canModifyTask(user, todo) {
+		c.RenderError(w, r, errors.New("only the creator, assignee or admin can delete this task"))
+		return
+	}
+
+	if err := models.Todos.Delete(todo); err != nil {
+		c.RenderError(w, r, errors.New("failed to delete task"))
+		return
+	}
+
+	if todo.ConversationID != "" {
+		models.PublishTodoChange(todo.ConversationID)
+	}
+
+	c.Refresh(w, r)
+}
@@ -0,0 +1,261 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"workspace/models"
+
+	"github.com/The-Skyscape/devtools/pkg/application"
+	"github.com/The-Skyscape/devtools/pkg/authentication"
+)
+
+// Teams is a factory function with the prefix and instance
+func Teams() (string, *TeamsController) {
+	return "teams", &TeamsController{}
+}
+
+// TeamsController handles the teams admins share repositories with,
+// giving each team's members a read, write or admin role
+type TeamsController struct {
+	application.Controller
+}
+
+// Setup registers routes
+func (c *TeamsController) Setup(app *application.App) {
+	c.Controller.Setup(app)
+
+	// Team management - admin only
+	http.Handle("GET /teams", app.Serve("teams.html", AdminOnly()))
+	http.Handle("GET /teams/{id}", app.Serve("team.html", AdminOnly()))
+	http.Handle("POST /teams", app.ProtectFunc(c.createTeam, AdminOnly()))
+	http.Handle("POST /teams/{id}/update", app.ProtectFunc(c.updateTeam, AdminOnly()))
+	http.Handle("POST /teams/{id}/delete", app.ProtectFunc(c.deleteTeam, AdminOnly()))
+	http.Handle("POST /teams/{id}/members", app.ProtectFunc(c.addTeamMember, AdminOnly()))
+	http.Handle("POST /teams/{id}/members/{userID}/remove", app.ProtectFunc(c.removeTeamMember, AdminOnly()))
+	http.Handle("POST /teams/{id}/repos", app.ProtectFunc(c.grantTeamRepo, AdminOnly()))
+	http.Handle("POST /teams/{id}/repos/{repoID}/remove", app.ProtectFunc(c.revokeTeamRepo, AdminOnly()))
+}
+
+// Handle returns a new controller instance for the request
+func (c TeamsController) Handle(req *http.Request) application.Handler {
+	c.Request = req
+	return &c
+}
+
+// AllTeams returns every team by name
+func (c *TeamsController) AllTeams() ([]*models.Team, error) {
+	return models.GetTeams()
+}
+
+// CurrentTeam returns the team named in the path
+func (c *TeamsController) CurrentTeam() (*models.Team, error) {
+	team, err := models.Teams.Get(c.Request.PathValue("id"))
+	if err != nil {
+		return nil, errors.New("team not found")
+	}
+	return team, nil
+}
+
+// ParentChoices returns the teams the current team could be nested under,
+// leaving out itself and the teams nested under it
+func (c *TeamsController) ParentChoices() ([]*models.Team, error) {
+	current, err := c.CurrentTeam()
+	if err != nil {
+		return nil, err
+	}
+	teams, err := models.GetTeams()
+	if err != nil {
+		return nil, err
+	}
+
+	var choices []*models.Team
+	for _, team := range teams {
+		nested := team.ID == current.ID
+		for _, ancestor := range team.Ancestors() {
+			nested = nested || ancestor.ID == current.ID
+		}
+		if !nested {
+			choices = append(choices, team)
+		}
+	}
+	return choices, nil
+}
+
+// Users returns every user, to pick team members from
+func (c *TeamsController) Users() ([]*authentication.User, error) {
+	return models.Users.Search("ORDER BY Name ASC")
+}
+
+// Repositories returns every repository, to share with teams
+func (c *TeamsController) Repositories() ([]*models.Repository, error) {
+	return models.Repositories.Search("ORDER BY Name ASC")
+}
+
+// Roles returns the roles a team can have on a repository
+func (c *TeamsController) Roles() []string {
+	return models.Roles
+}
+
+// MemberTeams returns the teams a user is in
+func (c *TeamsController) MemberTeams(userID string) ([]*models.Team, error) {
+	return models.UserTeams(userID)
+}
+
+// createTeam handles POST /teams
+func (c *TeamsController) createTeam(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	// Admin already verified by route middleware
+	user, _, err := c.Use("auth").(*AuthController).Authenticate(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	team, err := models.CreateTeam(r.FormValue("name"), r.FormValue("description"), r.FormValue("parent_id"), user.ID)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	models.LogActivity("team_created", fmt.Sprintf("Created team %s", team.Name),
+		"Repositories can be shared with the team", user.ID, "", "team", team.ID)
+
+	c.Redirect(w, r, "/teams/"+team.ID)
+}
+
+// updateTeam handles POST /teams/{id}/update, renaming or moving the team
+func (c *TeamsController) updateTeam(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	team, err := c.CurrentTeam()
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	if err := team.Update(r.FormValue("name"), r.FormValue("description"), r.FormValue("parent_id")); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	c.Refresh(w, r)
+}
+
+// deleteTeam handles POST /teams/{id}/delete
+func (c *TeamsController) deleteTeam(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	team, err := c.CurrentTeam()
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	// Admin already verified by route middleware
+	user, _, err := c.Use("auth").(*AuthController).Authenticate(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	if err := models.DeleteTeam(team); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	models.LogActivity("team_deleted", fmt.Sprintf("Deleted team %s", team.Name),
+		"Its members lost the repositories shared with it", user.ID, "", "team", team.ID)
+
+	c.Redirect(w, r, "/teams")
+}
+
+// addTeamMember handles POST /teams/{id}/members
+func (c *TeamsController) addTeamMember(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	team, err := c.CurrentTeam()
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	if err := team.AddMember(r.FormValue("user_id")); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	c.Refresh(w, r)
+}
+
+// removeTeamMember handles POST /teams/{id}/members/{userID}/remove
+func (c *TeamsController) removeTeamMember(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	team, err := c.CurrentTeam()
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	if err := team.RemoveMember(r.PathValue("userID")); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	c.Refresh(w, r)
+}
+
+// grantTeamRepo handles POST /teams/{id}/repos, giving the team a role on
+// a repository or changing the one it has
+func (c *TeamsController) grantTeamRepo(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	team, err := c.CurrentTeam()
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	// Admin already verified by route middleware
+	user, _, err := c.Use("auth").(*AuthController).Authenticate(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	repoID, role := r.FormValue("repo_id"), r.FormValue("role")
+	if err := team.GrantRepo(repoID, role); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	models.LogActivity("team_access_granted", fmt.Sprintf("Gave team %s %s access", team.Name, role),
+		"Team members share the role on the repository", user.ID, repoID, "team", team.ID)
+
+	c.Refresh(w, r)
+}
+
+// revokeTeamRepo handles POST /teams/{id}/repos/{repoID}/remove
+func (c *TeamsController) revokeTeamRepo(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	team, err := c.CurrentTeam()
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	// Admin already verified by route middleware
+	user, _, err := c.Use("auth").(*AuthController).Authenticate(r)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	repoID := r.PathValue("repoID")
+	if err := team.RevokeRepo(repoID); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	models.LogActivity("team_access_revoked", fmt.Sprintf("Removed team %s's access", team.Name),
+		"Team members no longer share a role on the repository", user.ID, repoID, "team", team.ID)
+
+	c.Refresh(w, r)
+}
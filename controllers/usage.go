@@ -0,0 +1,315 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"workspace/internal/agents"
+	"workspace/internal/metering"
+	"workspace/models"
+
+	"github.com/The-Skyscape/devtools/pkg/application"
+)
+
+// meteringInterval is how often worker time is sampled. Storage is sampled
+// hourly and the usage webhook is sent once a day.
+const meteringInterval = 15 * time.Minute
+
+// usageMonths is how many billing periods the usage page offers
+const usageMonths = 6
+
+// Usage is a factory function with the prefix and instance
+func Usage() (string, *UsageController) {
+	return "usage", &UsageController{}
+}
+
+// UsageController meters resource consumption per user, enforces quotas
+// and exports usage for billing
+type UsageController struct {
+	application.Controller
+}
+
+// Setup registers routes and starts the metering loop
+func (c *UsageController) Setup(app *application.App) {
+	c.Controller.Setup(app)
+
+	http.Handle("GET /settings/usage", app.Serve("settings-usage.html", AdminOnly()))
+	http.Handle("GET /settings/usage/export", app.ProtectFunc(c.exportCSV, AdminOnly()))
+	http.Handle("POST /settings/usage", app.ProtectFunc(c.updateUsageSettings, AdminOnly()))
+	http.Handle("POST /settings/usage/push", app.ProtectFunc(c.pushUsage, AdminOnly()))
+
+	c.startMetering()
+}
+
+// Handle returns a new controller instance for the request
+func (c UsageController) Handle(req *http.Request) application.Handler {
+	c.Request = req
+	return &c
+}
+
+// Period returns the start of the billing period being viewed, taken from
+// ?month=2006-01 and defaulting to the current month
+func (c *UsageController) Period() time.Time {
+	if month, err := time.ParseInLocation("2006-01", c.Request.URL.Query().Get("month"), time.Local); err == nil {
+		return month
+	}
+	return models.BillingPeriodStart(time.Now())
+}
+
+// Months returns the billing periods offered on the usage page
+func (c *UsageController) Months() []time.Time {
+	start := models.BillingPeriodStart(time.Now())
+	months := make([]time.Time, usageMonths)
+	for i := range months {
+		months[i] = start.AddDate(0, -i, 0)
+	}
+	return months
+}
+
+// Report returns every user's usage in the period being viewed
+func (c *UsageController) Report() (*metering.Report, error) {
+	since := c.Period()
+	return metering.NewReport(since, since.AddDate(0, 1, 0))
+}
+
+// Metrics returns the metered resources in display order
+func (c *UsageController) Metrics() []string {
+	return models.UsageMetrics
+}
+
+// Label returns a metric's display name
+func (c *UsageController) Label(metric string) string {
+	return models.UsageLabel(metric)
+}
+
+// Format formats a metric's value with its unit
+func (c *UsageController) Format(metric string, value float64) string {
+	return models.FormatUsage(metric, value)
+}
+
+// Exporters returns the names of the exporters that receive daily reports
+func (c *UsageController) Exporters() []string {
+	var names []string
+	for _, exporter := range usageExporters() {
+		names = append(names, exporter.Name())
+	}
+	return names
+}
+
+// exportCSV handles GET /settings/usage/export and downloads the period's
+// usage as CSV
+func (c *UsageController) exportCSV(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	report, err := c.Report()
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	filename := fmt.Sprintf("usage-%s.csv", report.Since.Format("2006-01"))
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	if err := (&metering.CSVExporter{W: w}).Export(report); err != nil {
+		log.Printf("UsageController: Failed to write CSV export: %v", err)
+	}
+}
+
+// updateUsageSettings handles POST /settings/usage
+func (c *UsageController) updateUsageSettings(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	user, _, err := c.Use("auth").(*AuthController).Authenticate(r)
+	if err != nil {
+		c.RenderError(w, r, errors.New("authentication required"))
+		return
+	}
+
+	quotas := map[string]int{}
+	for _, field := range []string{"quota_storage_mb", "quota_ci_minutes", "quota_ai_tokens", "quota_worker_hours"} {
+		value := 0
+		if text := r.FormValue(field); text != "" {
+			if value, err = strconv.Atoi(text); err != nil || value < 0 {
+				c.RenderError(w, r, errors.New("quotas must be whole numbers, 0 for unlimited"))
+				return
+			}
+		}
+		quotas[field] = value
+	}
+
+	webhook := r.FormValue("webhook_url")
+	if webhook != "" {
+		if u, err := url.Parse(webhook); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			c.RenderError(w, r, errors.New("webhook URL must be an http or https URL"))
+			return
+		}
+	}
+
+	settings, err := models.GetSettings()
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+	settings.QuotaStorageMB = quotas["quota_storage_mb"]
+	settings.QuotaCIMinutes = quotas["quota_ci_minutes"]
+	settings.QuotaAITokens = quotas["quota_ai_tokens"]
+	settings.QuotaWorkerHours = quotas["quota_worker_hours"]
+	settings.UsageWebhookURL = webhook
+	if secret := r.FormValue("webhook_secret"); secret != "" || webhook == "" {
+		settings.UsageWebhookSecret = secret
+	}
+	settings.LastUpdatedBy = user.Email
+	settings.LastUpdatedAt = time.Now()
+	if err := models.GlobalSettings.Update(settings); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	c.Refresh(w, r)
+}
+
+// pushUsage handles POST /settings/usage/push and sends the current
+// period's usage to every exporter right away
+func (c *UsageController) pushUsage(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	exporters := usageExporters()
+	if len(exporters) == 0 {
+		c.RenderError(w, r, errors.New("no usage exporters are configured"))
+		return
+	}
+
+	since := models.BillingPeriodStart(time.Now())
+	if err := exportUsage(exporters, since, time.Now()); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	c.Refresh(w, r)
+}
+
+// startMetering samples storage and worker time and sends yesterday's
+// usage to the exporters each day
+func (c *UsageController) startMetering() {
+	go func() {
+		ticker := time.NewTicker(meteringInterval)
+		defer ticker.Stop()
+
+		var lastStorage time.Time
+		lastExport := today()
+		for range ticker.C {
+			meterWorkspaceSessions(meteringInterval)
+
+			if time.Since(lastStorage) >= time.Hour {
+				lastStorage = time.Now()
+				if _, err := models.MeterStorage(); err != nil {
+					log.Printf("UsageController: Failed to meter storage: %v", err)
+				}
+			}
+
+			if day := today(); day.After(lastExport) {
+				if exporters := usageExporters(); len(exporters) > 0 {
+					if err := exportUsage(exporters, lastExport, day); err != nil {
+						log.Printf("UsageController: %v", err)
+					}
+				}
+				lastExport = day
+			}
+		}
+	}()
+}
+
+// usageExporters returns the webhook from settings, when set, followed by
+// any exporters registered by the deployment
+func usageExporters() []metering.Exporter {
+	var exporters []metering.Exporter
+	if settings, err := models.GetSettings(); err == nil && settings.UsageWebhookURL != "" {
+		exporters = append(exporters, &metering.WebhookExporter{
+			URL:    settings.UsageWebhookURL,
+			Secret: settings.UsageWebhookSecret,
+		})
+	}
+	return append(exporters, metering.Registered()...)
+}
+
+// exportUsage sends the usage between since and until to each exporter,
+// returning the first failure after trying them all
+func exportUsage(exporters []metering.Exporter, since, until time.Time) error {
+	report, err := metering.NewReport(since, until)
+	if err != nil {
+		return err
+	}
+
+	var failed error
+	for _, exporter := range exporters {
+		if err := exporter.Export(report); err != nil && failed == nil {
+			failed = fmt.Errorf("%s usage export failed: %w", exporter.Name(), err)
+		}
+	}
+	return failed
+}
+
+func today() time.Time {
+	now := time.Now()
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+}
+
+// meterTokens records the tokens a model call used against the user and
+// adds them to the response's metrics
+func meterTokens(metrics *AIMetrics, userID, conversationID string, response *agents.Response) {
+	if response == nil {
+		return
+	}
+	prompt, completion := response.Metadata.PromptEvalCount, response.Metadata.EvalCount
+	metrics.PromptTokens += prompt
+	metrics.CompletionTokens += completion
+	metrics.TotalTokens += prompt + completion
+
+	model := response.Metadata.Model
+	if model == "" {
+		model = metrics.ModelUsed
+	}
+	if _, err := models.RecordTokenUsage(userID, conversationID, model, prompt, completion); err != nil {
+		log.Printf("UsageController: Failed to meter tokens: %v", err)
+	}
+}
+
+// workspaceSessions tracks who has used the code workspace since it was
+// last metered
+var workspaceSessions = struct {
+	sync.Mutex
+	active map[string]bool
+}{active: map[string]bool{}}
+
+// workspaceActive reports whether a user has used the code workspace since
+// it was last metered
+func workspaceActive(userID string) bool {
+	workspaceSessions.Lock()
+	defer workspaceSessions.Unlock()
+	return workspaceSessions.active[userID]
+}
+
+// touchWorkspace marks a user as active in the code workspace
+func touchWorkspace(userID string) {
+	workspaceSessions.Lock()
+	workspaceSessions.active[userID] = true
+	workspaceSessions.Unlock()
+}
+
+// meterWorkspaceSessions bills each user active in the code workspace
+// during the last interval for the whole interval
+func meterWorkspaceSessions(interval time.Duration) {
+	workspaceSessions.Lock()
+	active := workspaceSessions.active
+	workspaceSessions.active = map[string]bool{}
+	workspaceSessions.Unlock()
+
+	for userID := range active {
+		if err := models.RecordUsage(userID, models.UsageWorkerHours, interval.Hours(), "", "workspace"); err != nil {
+			log.Printf("UsageController: Failed to meter workspace time: %v", err)
+		}
+	}
+}
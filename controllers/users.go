@@ -2,6 +2,7 @@ package controllers
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
 
 	"workspace/models"
@@ -42,6 +43,12 @@ func (c *UsersController) Setup(app *application.App) {
 	http.Handle("POST /settings/users/{id}/role", app.ProtectFunc(c.updateUserRole, adminRequired))
 	http.Handle("POST /settings/users/{id}/disable", app.ProtectFunc(c.disableUser, adminRequired))
 	http.Handle("POST /settings/users/{id}/enable", app.ProtectFunc(c.enableUser, adminRequired))
+	http.Handle("POST /settings/users/{id}/sessions/revoke", app.ProtectFunc(c.revokeUserSessions, adminRequired))
+	http.Handle("POST /settings/users/invitations", app.ProtectFunc(c.inviteUser, adminRequired))
+	http.Handle("POST /settings/users/invitations/{id}/revoke", app.ProtectFunc(c.revokeInvitation, adminRequired))
+
+	// @mention autocomplete in comments, descriptions and AI chat
+	http.Handle("GET /users/mentions", app.Serve("mention-suggestions.html", auth.Required))
 }
 
 func (c UsersController) Handle(req *http.Request) application.Handler {
@@ -49,6 +56,25 @@ func (c UsersController) Handle(req *http.Request) application.Handler {
 	return &c
 }
 
+// mentionSuggestionLimit is how many users the @mention autocomplete
+// suggests
+const mentionSuggestionLimit = 8
+
+// MentionSuggestions returns the users whose handle or name starts with
+// what was typed after the @. In a repository, only users who can see it
+// are suggested.
+func (c *UsersController) MentionSuggestions() ([]*authentication.User, error) {
+	query := c.Request.URL.Query()
+	var repo *models.Repository
+	if id := query.Get("repo"); id != "" {
+		var err error
+		if repo, err = models.Repositories.Get(id); err != nil {
+			return nil, errors.New("repository not found")
+		}
+	}
+	return models.MentionableUsers(repo, query.Get("q"), mentionSuggestionLimit)
+}
+
 // GetAllUsers returns all users for the users list
 func (c *UsersController) GetAllUsers() ([]*authentication.User, error) {
 	auth := c.App.Use("auth").(*AuthController)
@@ -116,6 +142,15 @@ func (c *UsersController) GetUserRepositories() ([]*models.Repository, error) {
 	return models.Repositories.Search("WHERE UserID = ?", userID)
 }
 
+// SessionCount returns how many browsers a user is signed in on
+func (c *UsersController) SessionCount(userID string) int {
+	sessions, err := models.ActiveUserSessions(userID)
+	if err != nil {
+		return 0
+	}
+	return len(sessions)
+}
+
 // GetByID returns a user by their ID
 func (c *UsersController) GetByID(id string) (*authentication.User, error) {
 	if id == "" {
@@ -254,3 +289,38 @@ func (c *UsersController) enableUser(w http.ResponseWriter, r *http.Request) {
 
 	c.Refresh(w, r)
 }
+
+// revokeUserSessions signs a user out of every browser, such as when they
+// leave, so they must sign in again to get back in
+func (c *UsersController) revokeUserSessions(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	auth := c.App.Use("auth").(*AuthController)
+	currentUser, _, err := auth.Authenticate(r)
+	if err != nil {
+		c.RenderError(w, r, errors.New("authentication required"))
+		return
+	}
+
+	userID := r.PathValue("id")
+	if userID == currentUser.ID {
+		c.RenderError(w, r, errors.New("sign out your own sessions from your sessions page"))
+		return
+	}
+
+	user, err := auth.Users.Get(userID)
+	if err != nil {
+		c.RenderError(w, r, errors.New("user not found"))
+		return
+	}
+
+	revoked, err := models.RevokeUserSessions(user.ID, "", currentUser.ID)
+	if err != nil {
+		c.RenderError(w, r, errors.New("failed to sign user out"))
+		return
+	}
+
+	models.LogActivity("user_signed_out", "Signed user out everywhere",
+		fmt.Sprintf("Signed %s out of %d browsers", user.Name, revoked), currentUser.ID, "", "user", userID)
+
+	c.Refresh(w, r)
+}
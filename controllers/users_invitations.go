@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"workspace/models"
+	"workspace/services"
+)
+
+// GetInvitations returns the workspace invitations that weren't revoked
+func (c *UsersController) GetInvitations() ([]*models.Invitation, error) {
+	return models.GetInvitations()
+}
+
+// inviteUser handles POST /settings/users/invitations, emailing someone a
+// link to create their account
+func (c *UsersController) inviteUser(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+	auth := c.App.Use("auth").(*AuthController)
+	currentUser, _, err := auth.Authenticate(r)
+	if err != nil {
+		c.RenderError(w, r, errors.New("authentication required"))
+		return
+	}
+
+	if !services.MailConfigured() {
+		c.RenderError(w, r, errors.New("set up outgoing mail in settings to send invitations"))
+		return
+	}
+
+	email := r.FormValue("email")
+	if _, err := models.Auth.GetUser(email); err == nil {
+		c.RenderError(w, r, errors.New("someone with that email already has an account"))
+		return
+	}
+
+	invitation, token, err := models.CreateInvitation(email, r.FormValue("role") == "developer", currentUser.ID)
+	if err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+
+	err = services.SendTemplate([]string{invitation.Email}, "invitation", services.MailMessage{Data: map[string]any{
+		"InvitedBy": currentUser.Name,
+		"Link":      workspaceURL(r) + "/invite/" + token,
+		"Expires":   invitation.ExpiresAt,
+	}})
+	if err != nil {
+		invitation.Revoke()
+		c.RenderError(w, r, err)
+		return
+	}
+
+	models.LogActivity("user_invited", "Invited "+invitation.Email,
+		currentUser.Name+" invited "+invitation.Email+" to the workspace", currentUser.ID, "", "user", "")
+	log.Printf("UsersController: %s invited %s", currentUser.Email, invitation.Email)
+	c.Refresh(w, r)
+}
+
+// revokeInvitation handles POST /settings/users/invitations/{id}/revoke
+func (c *UsersController) revokeInvitation(w http.ResponseWriter, r *http.Request) {
+	c.SetRequest(r)
+
+	invitation, err := models.Invitations.Get(r.PathValue("id"))
+	if err != nil {
+		c.RenderError(w, r, errors.New("invitation not found"))
+		return
+	}
+	if err := invitation.Revoke(); err != nil {
+		c.RenderError(w, r, err)
+		return
+	}
+	c.Refresh(w, r)
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"log"
 	"net/http"
+	"workspace/models"
 	"workspace/services"
 
 	"github.com/The-Skyscape/devtools/pkg/application"
@@ -54,6 +55,21 @@ func (w *WorkspacesController) proxyToCodeServer(wr http.ResponseWriter, r *http
 		return
 	}
 
+	// Time spent in the workspace counts as worker hours. The quota is
+	// checked once per metering interval rather than on every request.
+	user, _, err := w.Use("auth").(*AuthController).Authenticate(r)
+	if err != nil {
+		w.RenderError(wr, r, errors.New("authentication required"))
+		return
+	}
+	if !workspaceActive(user.ID) {
+		if err := models.CheckQuota(user.ID, models.UsageWorkerHours); err != nil {
+			w.RenderError(wr, r, err)
+			return
+		}
+		touchWorkspace(user.ID)
+	}
+
 	// Use the containers.Service proxy method like the original
 	service := &containers.Service{
 		Host: containers.Local(),
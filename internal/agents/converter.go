@@ -12,6 +12,7 @@ func ConvertOllamaToAgentMessages(ollamaMessages []services.OllamaMessage) []Mes
 		messages[i] = Message{
 			Role:    msg.Role,
 			Content: msg.Content,
+			Images:  msg.Images,
 		}
 
 		// Convert tool calls if present
@@ -39,6 +40,7 @@ func ConvertAgentToOllamaMessages(agentMessages []Message) []services.OllamaMess
 		ollamaMessages[i] = services.OllamaMessage{
 			Role:    msg.Role,
 			Content: msg.Content,
+			Images:  msg.Images,
 		}
 
 		// Convert tool calls if present
@@ -1,6 +1,7 @@
 package agents
 
 import (
+	"context"
 	"encoding/json"
 	"workspace/services"
 )
@@ -17,6 +18,7 @@ type Provider interface {
 	SupportsToolCalling() bool
 	SupportsStreaming() bool
 	RequiresGPU() bool
+	SupportsVision() bool // Whether images can be included with messages
 
 	// Message formatting for provider-specific needs
 	FormatMessages(messages []Message, tools []Tool) []Message
@@ -28,8 +30,12 @@ type Provider interface {
 
 	// Chat methods
 	Chat(messages []Message, options ChatOptions) (*Response, error)
-	ChatWithTools(messages []Message, tools []Tool, options ChatOptions) (*Response, error)
+	ChatWithTools(ctx context.Context, messages []Message, tools []Tool, options ChatOptions) (*Response, error)
 	StreamChat(messages []Message, options ChatOptions, callback StreamCallback) error
+
+	// ChatWithToolsStream streams a tool-enabled chat, invoking callback with each
+	// content or tool-call delta, and returns the fully assembled response
+	ChatWithToolsStream(ctx context.Context, messages []Message, tools []Tool, options ChatOptions, callback StreamCallback) (*Response, error)
 }
 
 // Message represents a chat message
@@ -37,6 +43,7 @@ type Message struct {
 	Role      string     `json:"role"` // "user", "assistant", "system", "tool"
 	Content   string     `json:"content"`
 	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	Images    []string   `json:"images,omitempty"` // Base64 encoded, only sent to vision models
 }
 
 // ChatOptions configures chat behavior
@@ -95,6 +102,7 @@ func ConvertToOllamaMessage(msg Message) services.OllamaMessage {
 	ollamaMsg := services.OllamaMessage{
 		Role:    msg.Role,
 		Content: msg.Content,
+		Images:  msg.Images,
 	}
 
 	// Convert tool calls if present
@@ -120,6 +128,7 @@ func ConvertFromOllamaMessage(msg services.OllamaMessage) Message {
 	agentMsg := Message{
 		Role:    msg.Role,
 		Content: msg.Content,
+		Images:  msg.Images,
 	}
 
 	// Convert tool calls if present
@@ -0,0 +1,391 @@
+package providers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+	"workspace/internal/agents"
+)
+
+// ExternalModelPrefix marks conversation models served by the external
+// provider rather than Ollama, as in "external:gpt-4o"
+const ExternalModelPrefix = "external:"
+
+// externalClient talks to the external provider. Responses stream, so the
+// timeout only bounds slow starts rather than long answers.
+var externalClient = &http.Client{
+	Transport: &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		ResponseHeaderTimeout: 2 * time.Minute,
+	},
+}
+
+// ExternalModels returns the models offered from the external provider,
+// prefixed with ExternalModelPrefix. AI_EXTERNAL_URL is the base of an
+// OpenAI-compatible chat completions API, such as https://api.openai.com/v1,
+// and AI_EXTERNAL_MODELS a comma separated list of its models.
+func ExternalModels() []string {
+	if os.Getenv("AI_EXTERNAL_URL") == "" {
+		return nil
+	}
+	var models []string
+	for _, model := range strings.Split(os.Getenv("AI_EXTERNAL_MODELS"), ",") {
+		if model = strings.TrimSpace(model); model != "" {
+			models = append(models, ExternalModelPrefix+model)
+		}
+	}
+	return models
+}
+
+// IsExternalModel reports whether a model is served by the external provider
+func IsExternalModel(model string) bool {
+	return strings.HasPrefix(model, ExternalModelPrefix)
+}
+
+// ExternalProvider implements the Provider interface for an OpenAI-compatible
+// hosted API, for workspaces that want a larger model than they can run
+type ExternalProvider struct {
+	baseURL string
+	apiKey  string
+	model   string // Model name at the provider, without the prefix
+}
+
+// NewExternalProvider creates a provider for one of ExternalModels
+func NewExternalProvider(model string) (*ExternalProvider, error) {
+	baseURL := strings.TrimRight(os.Getenv("AI_EXTERNAL_URL"), "/")
+	if baseURL == "" {
+		return nil, fmt.Errorf("no external AI provider is configured (AI_EXTERNAL_URL)")
+	}
+	name := strings.TrimPrefix(model, ExternalModelPrefix)
+	if name == "" {
+		return nil, fmt.Errorf("model name is required")
+	}
+	return &ExternalProvider{
+		baseURL: baseURL,
+		apiKey:  os.Getenv("AI_EXTERNAL_API_KEY"),
+		model:   name,
+	}, nil
+}
+
+// Name returns the provider name
+func (p *ExternalProvider) Name() string {
+	return "External Provider"
+}
+
+// Model returns the model identifier, prefixed as conversations store it
+func (p *ExternalProvider) Model() string {
+	return ExternalModelPrefix + p.model
+}
+
+// MaxContextTokens returns the maximum context size
+func (p *ExternalProvider) MaxContextTokens() int {
+	return 128000 // Hosted models commonly take 128K or more
+}
+
+// SupportedTools returns the same tools as GPT-OSS, since hosted models
+// handle tool calling at least as well
+func (p *ExternalProvider) SupportedTools() []string {
+	return (&GPTOSSProvider{}).SupportedTools()
+}
+
+// SupportsToolCalling returns whether this model supports tool calling
+func (p *ExternalProvider) SupportsToolCalling() bool {
+	return true
+}
+
+// SupportsStreaming returns whether this model supports streaming
+func (p *ExternalProvider) SupportsStreaming() bool {
+	return true
+}
+
+// RequiresGPU returns whether this model requires GPU
+func (p *ExternalProvider) RequiresGPU() bool {
+	return false // Runs at the provider
+}
+
+// SupportsVision returns false, screenshots stay within the workspace
+func (p *ExternalProvider) SupportsVision() bool {
+	return false
+}
+
+// FormatMessages returns messages unchanged, tools are sent separately
+func (p *ExternalProvider) FormatMessages(messages []agents.Message, tools []agents.Tool) []agents.Message {
+	return messages
+}
+
+// ParseResponse processes the response for any provider-specific parsing
+func (p *ExternalProvider) ParseResponse(response *agents.Response) *agents.Response {
+	return response
+}
+
+// RequiresToolsInMessages returns whether tools should be embedded in messages
+func (p *ExternalProvider) RequiresToolsInMessages() bool {
+	return false
+}
+
+// PrefersSeparateTools returns whether tools should be passed separately
+func (p *ExternalProvider) PrefersSeparateTools() bool {
+	return true
+}
+
+// Chat sends a chat request to the model
+func (p *ExternalProvider) Chat(messages []agents.Message, options agents.ChatOptions) (*agents.Response, error) {
+	return p.ChatWithTools(context.Background(), messages, nil, options)
+}
+
+// ChatWithTools sends a chat request with tool definitions
+func (p *ExternalProvider) ChatWithTools(ctx context.Context, messages []agents.Message, tools []agents.Tool, options agents.ChatOptions) (*agents.Response, error) {
+	body, err := p.post(ctx, p.request(messages, tools, options, false))
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	var completion externalCompletion
+	if err := json.NewDecoder(body).Decode(&completion); err != nil {
+		return nil, fmt.Errorf("failed to decode external response: %w", err)
+	}
+	if len(completion.Choices) == 0 {
+		return nil, fmt.Errorf("external provider returned no choices")
+	}
+
+	message := completion.Choices[0].Message
+	response := &agents.Response{
+		Content:   message.Content,
+		ToolCalls: fromExternalToolCalls(message.ToolCalls),
+		Metadata:  p.metadata(completion.Usage),
+	}
+	unwrapArguments(response.ToolCalls)
+	return response, nil
+}
+
+// StreamChat sends a streaming chat request
+func (p *ExternalProvider) StreamChat(messages []agents.Message, options agents.ChatOptions, callback agents.StreamCallback) error {
+	_, err := p.ChatWithToolsStream(context.Background(), messages, nil, options, callback)
+	return err
+}
+
+// ChatWithToolsStream streams a chat request with tool definitions, forwarding each chunk
+// to the callback and returning the assembled response once the stream completes
+func (p *ExternalProvider) ChatWithToolsStream(ctx context.Context, messages []agents.Message, tools []agents.Tool, options agents.ChatOptions, callback agents.StreamCallback) (*agents.Response, error) {
+	body, err := p.post(ctx, p.request(messages, tools, options, true))
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	log.Printf("ExternalProvider: Streaming request to %s with %d messages and %d tools", p.model, len(messages), len(tools))
+
+	var acc agents.ResponseAccumulator
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk externalCompletion
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return nil, fmt.Errorf("failed to decode external stream: %w", err)
+		}
+		response := &agents.Response{Metadata: p.metadata(chunk.Usage)}
+		if len(chunk.Choices) > 0 {
+			delta := chunk.Choices[0].Delta
+			response.Content = delta.Content
+			response.ToolCalls = fromExternalToolCalls(delta.ToolCalls)
+		}
+		acc.Add(response)
+		if callback != nil {
+			if err := callback(response); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("external stream failed: %w", err)
+	}
+
+	response := acc.Response()
+	unwrapArguments(response.ToolCalls)
+	return response, nil
+}
+
+// externalMessage is a chat message in the OpenAI format
+type externalMessage struct {
+	Role       string             `json:"role"`
+	Content    string             `json:"content"`
+	ToolCalls  []externalToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string             `json:"tool_call_id,omitempty"`
+}
+
+// externalToolCall is a tool call, whose arguments are a JSON string
+type externalToolCall struct {
+	Index    *int   `json:"index,omitempty"` // Only in stream deltas
+	ID       string `json:"id,omitempty"`
+	Type     string `json:"type,omitempty"`
+	Function struct {
+		Name      string `json:"name,omitempty"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+// externalCompletion is a chat completion or one chunk of its stream
+type externalCompletion struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Message externalMessage `json:"message"`
+		Delta   externalMessage `json:"delta"`
+	} `json:"choices"`
+	Usage *externalUsage `json:"usage"` // Only on the last chunk of a stream
+}
+
+// externalUsage is the tokens a completion used
+type externalUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+// request builds the chat completion request. Tool results are matched to
+// the calls they answer by ID, which the API requires, so calls made by
+// Ollama models earlier in the conversation are given IDs in order.
+func (p *ExternalProvider) request(messages []agents.Message, tools []agents.Tool, options agents.ChatOptions, stream bool) map[string]any {
+	var converted []externalMessage
+	var pending []string // IDs of tool calls not yet answered
+	for i, msg := range messages {
+		out := externalMessage{Role: msg.Role, Content: msg.Content}
+		for j, tc := range msg.ToolCalls {
+			call := externalToolCall{ID: tc.ID, Type: "function"}
+			if call.ID == "" {
+				call.ID = fmt.Sprintf("call_%d_%d", i, j)
+			}
+			call.Function.Name = tc.Function.Name
+			call.Function.Arguments = string(tc.Function.Arguments)
+			if call.Function.Arguments == "" {
+				call.Function.Arguments = "{}"
+			}
+			out.ToolCalls = append(out.ToolCalls, call)
+			pending = append(pending, call.ID)
+		}
+		if msg.Role == "tool" {
+			if len(pending) == 0 {
+				// A result without a call the API would reject, so keep it as context
+				out.Role = "user"
+				out.Content = "Tool result:\n" + msg.Content
+			} else {
+				out.ToolCallID, pending = pending[0], pending[1:]
+			}
+		}
+		converted = append(converted, out)
+	}
+
+	request := map[string]any{
+		"model":    p.model,
+		"messages": converted,
+		"stream":   stream,
+	}
+	if len(tools) > 0 {
+		request["tools"] = tools
+	}
+	if options.Temperature > 0 {
+		request["temperature"] = options.Temperature
+	}
+	if options.MaxTokens > 0 {
+		request["max_tokens"] = options.MaxTokens
+	}
+	if stream {
+		request["stream_options"] = map[string]any{"include_usage": true}
+	}
+	return request
+}
+
+// post sends a chat completion request, returning the response body
+func (p *ExternalProvider) post(ctx context.Context, request map[string]any) (io.ReadCloser, error) {
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode external request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/chat/completions", bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+
+	resp, err := externalClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("external provider request failed: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("external provider returned %s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+	return resp.Body, nil
+}
+
+// metadata reports usage as Ollama does, under the model's name at the
+// provider
+func (p *ExternalProvider) metadata(usage *externalUsage) agents.ResponseMetadata {
+	metadata := agents.ResponseMetadata{Model: p.model}
+	if usage != nil {
+		metadata.PromptEvalCount = usage.PromptTokens
+		metadata.EvalCount = usage.CompletionTokens
+	}
+	return metadata
+}
+
+// fromExternalToolCalls converts tool calls to agent format. Arguments stay
+// JSON strings so streamed fragments join, and are unwrapped once complete.
+func fromExternalToolCalls(calls []externalToolCall) []agents.ToolCall {
+	if len(calls) == 0 {
+		return nil
+	}
+
+	converted := make([]agents.ToolCall, len(calls))
+	for i, call := range calls {
+		var arguments json.RawMessage
+		if call.Function.Arguments != "" {
+			arguments, _ = json.Marshal(call.Function.Arguments)
+		}
+		converted[i] = agents.ToolCall{
+			ID:   call.ID,
+			Type: call.Type,
+			Function: agents.FunctionCall{
+				Name:      call.Function.Name,
+				Arguments: arguments,
+			},
+		}
+	}
+	return converted
+}
+
+// unwrapArguments replaces each call's JSON string of arguments with the
+// object it holds, the form tools are executed with
+func unwrapArguments(calls []agents.ToolCall) {
+	for i := range calls {
+		var arguments string
+		if json.Unmarshal(calls[i].Function.Arguments, &arguments) != nil {
+			continue
+		}
+		if arguments == "" || !json.Valid([]byte(arguments)) {
+			arguments = "{}"
+		}
+		calls[i].Function.Arguments = json.RawMessage(arguments)
+	}
+}
@@ -0,0 +1,189 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"workspace/internal/agents"
+)
+
+// externalServer serves chat completions, recording the last request it got
+func externalServer(t *testing.T, handler func(w http.ResponseWriter, request map[string]any)) *map[string]any {
+	var last map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/chat/completions" {
+			t.Errorf("Expected /chat/completions, got %s", r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer test-key" {
+			t.Errorf("Expected the API key as a bearer token, got %q", got)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&last); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+		handler(w, last)
+	}))
+	t.Cleanup(server.Close)
+
+	t.Setenv("AI_EXTERNAL_URL", server.URL+"/")
+	t.Setenv("AI_EXTERNAL_API_KEY", "test-key")
+	t.Setenv("AI_EXTERNAL_MODELS", "gpt-4o")
+	return &last
+}
+
+func TestExternalModels(t *testing.T) {
+	t.Setenv("AI_EXTERNAL_URL", "")
+	t.Setenv("AI_EXTERNAL_MODELS", "gpt-4o")
+	if models := ExternalModels(); len(models) != 0 {
+		t.Errorf("Expected no models without AI_EXTERNAL_URL, got %v", models)
+	}
+	if _, err := NewExternalProvider("external:gpt-4o"); err == nil {
+		t.Error("Expected an error without AI_EXTERNAL_URL")
+	}
+
+	t.Setenv("AI_EXTERNAL_URL", "https://api.example.com/v1")
+	t.Setenv("AI_EXTERNAL_MODELS", " gpt-4o, ,o3-mini ")
+	models := ExternalModels()
+	if len(models) != 2 || models[0] != "external:gpt-4o" || models[1] != "external:o3-mini" {
+		t.Fatalf("ExternalModels() = %v", models)
+	}
+	if !IsExternalModel(models[0]) || IsExternalModel("llama3.2:3b") {
+		t.Error("IsExternalModel() should only match prefixed models")
+	}
+
+	provider, err := NewExternalProvider(models[1])
+	if err != nil {
+		t.Fatalf("NewExternalProvider() error = %v", err)
+	}
+	if provider.Model() != "external:o3-mini" {
+		t.Errorf("Model() = %s, want external:o3-mini", provider.Model())
+	}
+}
+
+func TestExternalProviderRequest(t *testing.T) {
+	provider := &ExternalProvider{model: "gpt-4o"}
+	messages := []agents.Message{
+		{Role: "user", Content: "What does main.go do?"},
+		{Role: "assistant", ToolCalls: []agents.ToolCall{
+			{Function: agents.FunctionCall{Name: "read_file", Arguments: json.RawMessage(`{"path":"main.go"}`)}},
+			{ID: "call_abc", Function: agents.FunctionCall{Name: "list_files"}},
+		}},
+		{Role: "tool", Content: "package main"},
+		{Role: "tool", Content: "main.go"},
+		{Role: "tool", Content: "left over"},
+	}
+
+	request := provider.request(messages, nil, agents.ChatOptions{Temperature: 0.2}, true)
+	if request["model"] != "gpt-4o" || request["stream"] != true || request["temperature"] != 0.2 {
+		t.Errorf("Unexpected request options: %v", request)
+	}
+	if _, ok := request["tools"]; ok {
+		t.Error("Expected no tools when none are given")
+	}
+
+	converted := request["messages"].([]externalMessage)
+	calls := converted[1].ToolCalls
+	if len(calls) != 2 || calls[0].ID != "call_1_0" || calls[1].ID != "call_abc" {
+		t.Fatalf("Expected tool calls to be given IDs in order, got %+v", calls)
+	}
+	if calls[0].Function.Arguments != `{"path":"main.go"}` || calls[1].Function.Arguments != "{}" {
+		t.Errorf("Expected arguments as JSON strings, got %q and %q", calls[0].Function.Arguments, calls[1].Function.Arguments)
+	}
+	if converted[2].ToolCallID != "call_1_0" || converted[3].ToolCallID != "call_abc" {
+		t.Errorf("Expected results to answer the calls in order, got %q and %q", converted[2].ToolCallID, converted[3].ToolCallID)
+	}
+	if converted[4].Role != "user" || !strings.HasSuffix(converted[4].Content, "left over") {
+		t.Errorf("Expected an unanswered result to be sent as context, got %+v", converted[4])
+	}
+}
+
+func TestExternalProviderChatWithTools(t *testing.T) {
+	externalServer(t, func(w http.ResponseWriter, request map[string]any) {
+		if request["stream"] != false {
+			t.Errorf("Expected a non-streaming request, got %v", request["stream"])
+		}
+		fmt.Fprint(w, `{"choices": [{"message": {"role": "assistant", "content": "Reading it", "tool_calls": [
+			{"id": "call_1", "type": "function", "function": {"name": "read_file", "arguments": "{\"path\": \"main.go\"}"}}
+		]}}], "usage": {"prompt_tokens": 12, "completion_tokens": 5}}`)
+	})
+
+	provider, err := NewExternalProvider("external:gpt-4o")
+	if err != nil {
+		t.Fatalf("NewExternalProvider() error = %v", err)
+	}
+	response, err := provider.ChatWithTools(context.Background(), []agents.Message{{Role: "user", Content: "hi"}}, nil, agents.ChatOptions{})
+	if err != nil {
+		t.Fatalf("ChatWithTools() error = %v", err)
+	}
+	if response.Content != "Reading it" || len(response.ToolCalls) != 1 {
+		t.Fatalf("Unexpected response: %+v", response)
+	}
+	call := response.ToolCalls[0]
+	if call.ID != "call_1" || call.Function.Name != "read_file" || string(call.Function.Arguments) != `{"path": "main.go"}` {
+		t.Errorf("Expected the arguments object, got %+v with %s", call, call.Function.Arguments)
+	}
+	if response.Metadata.Model != "gpt-4o" || response.Metadata.PromptEvalCount != 12 || response.Metadata.EvalCount != 5 {
+		t.Errorf("Unexpected usage: %+v", response.Metadata)
+	}
+}
+
+func TestExternalProviderStream(t *testing.T) {
+	last := externalServer(t, func(w http.ResponseWriter, request map[string]any) {
+		for _, chunk := range []string{
+			`{"choices": [{"delta": {"role": "assistant", "content": "Let me "}}]}`,
+			`{"choices": [{"delta": {"content": "look"}}]}`,
+			`{"choices": [{"delta": {"tool_calls": [{"index": 0, "id": "call_1", "type": "function", "function": {"name": "read_file", "arguments": ""}}]}}]}`,
+			`{"choices": [{"delta": {"tool_calls": [{"index": 0, "function": {"arguments": "{\"path\":"}}]}}]}`,
+			`{"choices": [{"delta": {"tool_calls": [{"index": 0, "function": {"arguments": "\"main.go\"}"}}]}}]}`,
+			`{"choices": [], "usage": {"prompt_tokens": 30, "completion_tokens": 9}}`,
+			`[DONE]`,
+		} {
+			fmt.Fprintf(w, "data: %s\n\n", chunk)
+		}
+	})
+
+	provider, err := NewExternalProvider("external:gpt-4o")
+	if err != nil {
+		t.Fatalf("NewExternalProvider() error = %v", err)
+	}
+	var streamed strings.Builder
+	response, err := provider.ChatWithToolsStream(context.Background(), []agents.Message{{Role: "user", Content: "hi"}}, nil, agents.ChatOptions{}, func(chunk *agents.Response) error {
+		streamed.WriteString(chunk.Content)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ChatWithToolsStream() error = %v", err)
+	}
+
+	if (*last)["stream"] != true || (*last)["stream_options"] == nil {
+		t.Errorf("Expected a streaming request that asks for usage, got %v", *last)
+	}
+	if streamed.String() != "Let me look" || response.Content != "Let me look" {
+		t.Errorf("Expected the content deltas joined, got %q and %q", streamed.String(), response.Content)
+	}
+	if len(response.ToolCalls) != 1 || string(response.ToolCalls[0].Function.Arguments) != `{"path":"main.go"}` {
+		t.Fatalf("Expected one call with the fragments joined, got %+v", response.ToolCalls)
+	}
+	if response.Metadata.PromptEvalCount != 30 || response.Metadata.EvalCount != 9 {
+		t.Errorf("Unexpected usage: %+v", response.Metadata)
+	}
+}
+
+func TestExternalProviderError(t *testing.T) {
+	externalServer(t, func(w http.ResponseWriter, request map[string]any) {
+		http.Error(w, `{"error": {"message": "Incorrect API key provided"}}`, http.StatusUnauthorized)
+	})
+
+	provider, err := NewExternalProvider("external:gpt-4o")
+	if err != nil {
+		t.Fatalf("NewExternalProvider() error = %v", err)
+	}
+	_, err = provider.ChatWithTools(context.Background(), []agents.Message{{Role: "user", Content: "hi"}}, nil, agents.ChatOptions{})
+	if err == nil || !strings.Contains(err.Error(), "Incorrect API key") {
+		t.Errorf("Expected the provider's error, got %v", err)
+	}
+}
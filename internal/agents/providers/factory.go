@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
 	"workspace/internal/agents"
 	"workspace/services"
 )
@@ -44,21 +45,48 @@ func NewProvider() (agents.Provider, error) {
 }
 
 // GetProviderForModel returns a provider for a specific model name
-// This is useful for testing or when you need to override the environment
+// Llama 3.2 variants use the lightweight provider; any other installed model
+// is driven through the full-featured GPT-OSS provider, and models prefixed
+// with ExternalModelPrefix through the external provider
 func GetProviderForModel(modelName string) (agents.Provider, error) {
+	if IsExternalModel(modelName) {
+		return NewExternalProvider(modelName)
+	}
+
 	// Ensure Ollama service is available
 	if !services.Ollama.IsRunning() {
 		return nil, fmt.Errorf("Ollama service is not running")
 	}
 	
-	switch modelName {
-	case "llama3.2:1b":
-		return NewLlama32Provider(services.Ollama), nil
+	switch {
+	case modelName == "":
+		return nil, fmt.Errorf("model name is required")
+		
+	case strings.HasPrefix(modelName, "llama3.2"):
+		return &Llama32Provider{ollamaService: services.Ollama, model: modelName}, nil
 		
-	case "gpt-oss":
+	case modelName == "gpt-oss":
 		return NewGPTOSSProvider(services.Ollama), nil
 		
 	default:
-		return nil, fmt.Errorf("unsupported model: %s", modelName)
+		return &GPTOSSProvider{ollamaService: services.Ollama, model: modelName}, nil
 	}
-}
\ No newline at end of file
+}
+
+// visionModels are the Ollama model families that accept images
+var visionModels = []string{
+	"llava", "bakllava", "llama3.2-vision", "llama4", "gemma3",
+	"qwen2.5vl", "minicpm-v", "moondream", "granite3.2-vision",
+}
+
+// isVisionModel reports whether a model tag belongs to a multimodal family
+func isVisionModel(model string) bool {
+	name := strings.ToLower(strings.SplitN(model, ":", 2)[0])
+	name = name[strings.LastIndex(name, "/")+1:]
+	for _, family := range visionModels {
+		if strings.HasPrefix(name, family) {
+			return true
+		}
+	}
+	return false
+}
@@ -1,6 +1,7 @@
 package providers
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"workspace/internal/agents"
@@ -11,6 +12,7 @@ import (
 // Optimized for GPU-accelerated environments with full tool support
 type GPTOSSProvider struct {
 	ollamaService *services.OllamaService
+	model         string // Installed model tag to use instead of the default
 }
 
 // NewGPTOSSProvider creates a new GPT-OSS provider
@@ -27,6 +29,9 @@ func (p *GPTOSSProvider) Name() string {
 
 // Model returns the model identifier
 func (p *GPTOSSProvider) Model() string {
+	if p.model != "" {
+		return p.model
+	}
 	return "gpt-oss"
 }
 
@@ -53,6 +58,7 @@ func (p *GPTOSSProvider) SupportedTools() []string {
 		"delete_file",
 		"move_file",
 		"search_files",
+		"semantic_search",
 		
 		// Git operations
 		"git_status",
@@ -60,15 +66,24 @@ func (p *GPTOSSProvider) SupportedTools() []string {
 		"git_diff",
 		"git_commit",
 		"git_push",
+		"git_branch_create",
+		"git_checkout",
+		"git_merge",
+		"create_pull_request",
 		
 		// Issue and project management
 		"create_issue",
 		"get_issue",
 		"create_milestone",
+		"list_milestones",
+		"update_milestone",
 		"create_project_card",
+		"list_project_cards",
+		"update_project_card",
 		
 		// Advanced operations
 		"terminal_execute",
+		"web_fetch",
 		"create_todo",
 		"list_todos",
 		"update_todo",
@@ -90,6 +105,11 @@ func (p *GPTOSSProvider) RequiresGPU() bool {
 	return true // Requires GPU for optimal performance
 }
 
+// SupportsVision returns whether the installed model accepts images
+func (p *GPTOSSProvider) SupportsVision() bool {
+	return isVisionModel(p.Model())
+}
+
 // FormatMessages formats messages according to GPT-OSS preferences
 // GPT-OSS follows OpenAI's format closely
 func (p *GPTOSSProvider) FormatMessages(messages []agents.Message, tools []agents.Tool) []agents.Message {
@@ -149,7 +169,7 @@ func (p *GPTOSSProvider) Chat(messages []agents.Message, options agents.ChatOpti
 }
 
 // ChatWithTools sends a chat request with tool definitions
-func (p *GPTOSSProvider) ChatWithTools(messages []agents.Message, tools []agents.Tool, options agents.ChatOptions) (*agents.Response, error) {
+func (p *GPTOSSProvider) ChatWithTools(ctx context.Context, messages []agents.Message, tools []agents.Tool, options agents.ChatOptions) (*agents.Response, error) {
 	if !p.ollamaService.IsRunning() {
 		return nil, fmt.Errorf("Ollama service is not running")
 	}
@@ -176,7 +196,7 @@ func (p *GPTOSSProvider) ChatWithTools(messages []agents.Message, tools []agents
 	log.Printf("GPTOSSProvider: Sending request with %d messages and %d tools", len(messages), len(tools))
 	
 	// Send chat request with tools
-	response, err := p.ollamaService.ChatWithTools(p.Model(), ollamaMessages, ollamaTools, false)
+	response, err := p.ollamaService.ChatWithTools(ctx, p.Model(), ollamaMessages, ollamaTools, false)
 	if err != nil {
 		return nil, fmt.Errorf("chat with tools failed: %w", err)
 	}
@@ -228,6 +248,48 @@ func (p *GPTOSSProvider) StreamChat(messages []agents.Message, options agents.Ch
 	})
 }
 
+// ChatWithToolsStream streams a chat request with tool definitions, forwarding each chunk
+// to the callback and returning the assembled response once the stream completes
+func (p *GPTOSSProvider) ChatWithToolsStream(ctx context.Context, messages []agents.Message, tools []agents.Tool, options agents.ChatOptions, callback agents.StreamCallback) (*agents.Response, error) {
+	if !p.ollamaService.IsRunning() {
+		return nil, fmt.Errorf("Ollama service is not running")
+	}
+
+	// Convert messages to Ollama format
+	ollamaMessages := make([]services.OllamaMessage, len(messages))
+	for i, msg := range messages {
+		ollamaMessages[i] = agents.ConvertToOllamaMessage(msg)
+	}
+
+	ollamaTools := agents.ConvertAgentToOllamaTools(tools)
+
+	log.Printf("GPTOSSProvider: Streaming request with %d messages and %d tools", len(messages), len(tools))
+
+	var acc agents.ResponseAccumulator
+	err := p.ollamaService.StreamChatWithTools(ctx, p.Model(), ollamaMessages, ollamaTools, func(chunk *services.OllamaChatResponse) error {
+		response := &agents.Response{
+			Content:   chunk.Message.Content,
+			ToolCalls: p.convertToolCalls(chunk.Message.ToolCalls),
+			Metadata: agents.ResponseMetadata{
+				Model:           chunk.Model,
+				TotalDuration:   chunk.TotalDuration,
+				EvalCount:       chunk.EvalCount,
+				PromptEvalCount: chunk.PromptEvalCount,
+			},
+		}
+		acc.Add(response)
+		if callback != nil {
+			return callback(response)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("streaming chat with tools failed: %w", err)
+	}
+
+	return acc.Response(), nil
+}
+
 // convertToolCalls converts Ollama tool calls to agent format
 func (p *GPTOSSProvider) convertToolCalls(ollamaCalls []services.OllamaToolCall) []agents.ToolCall {
 	if len(ollamaCalls) == 0 {
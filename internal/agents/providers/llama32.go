@@ -1,6 +1,7 @@
 package providers
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"workspace/internal/agents"
@@ -11,6 +12,7 @@ import (
 // Optimized for CPU-limited environments with basic tool support
 type Llama32Provider struct {
 	ollamaService *services.OllamaService
+	model         string // Installed model tag to use instead of the default
 }
 
 // NewLlama32Provider creates a new Llama 3.2:1b provider
@@ -27,6 +29,9 @@ func (p *Llama32Provider) Name() string {
 
 // Model returns the model identifier
 func (p *Llama32Provider) Model() string {
+	if p.model != "" {
+		return p.model
+	}
 	return "llama3.2:1b"
 }
 
@@ -71,6 +76,11 @@ func (p *Llama32Provider) RequiresGPU() bool {
 	return false // Optimized for CPU
 }
 
+// SupportsVision returns true for the llama3.2-vision variants
+func (p *Llama32Provider) SupportsVision() bool {
+	return isVisionModel(p.Model())
+}
+
 // FormatMessages formats messages according to Llama's preferences
 // Llama models often work better with tools described in the system message
 func (p *Llama32Provider) FormatMessages(messages []agents.Message, tools []agents.Tool) []agents.Message {
@@ -131,7 +141,7 @@ func (p *Llama32Provider) Chat(messages []agents.Message, options agents.ChatOpt
 }
 
 // ChatWithTools sends a chat request with tool definitions
-func (p *Llama32Provider) ChatWithTools(messages []agents.Message, tools []agents.Tool, options agents.ChatOptions) (*agents.Response, error) {
+func (p *Llama32Provider) ChatWithTools(ctx context.Context, messages []agents.Message, tools []agents.Tool, options agents.ChatOptions) (*agents.Response, error) {
 	if !p.ollamaService.IsRunning() {
 		return nil, fmt.Errorf("Ollama service is not running")
 	}
@@ -161,7 +171,7 @@ func (p *Llama32Provider) ChatWithTools(messages []agents.Message, tools []agent
 	log.Printf("Llama32Provider: Sending request with %d messages and %d tools", len(messages), len(supportedTools))
 	
 	// Send chat request with tools
-	response, err := p.ollamaService.ChatWithTools(p.Model(), ollamaMessages, ollamaTools, false)
+	response, err := p.ollamaService.ChatWithTools(ctx, p.Model(), ollamaMessages, ollamaTools, false)
 	if err != nil {
 		return nil, fmt.Errorf("chat with tools failed: %w", err)
 	}
@@ -205,6 +215,49 @@ func (p *Llama32Provider) StreamChat(messages []agents.Message, options agents.C
 	})
 }
 
+// ChatWithToolsStream streams a chat request with the supported subset of tools
+// and returns the assembled response once the stream completes
+func (p *Llama32Provider) ChatWithToolsStream(ctx context.Context, messages []agents.Message, tools []agents.Tool, options agents.ChatOptions, callback agents.StreamCallback) (*agents.Response, error) {
+	if !p.ollamaService.IsRunning() {
+		return nil, fmt.Errorf("Ollama service is not running")
+	}
+
+	// Filter tools to only include supported ones
+	supportedTools := p.filterSupportedTools(tools)
+
+	// Convert messages to Ollama format
+	ollamaMessages := make([]services.OllamaMessage, len(messages))
+	for i, msg := range messages {
+		ollamaMessages[i] = agents.ConvertToOllamaMessage(msg)
+	}
+
+	log.Printf("Llama32Provider: Streaming request with %d messages and %d tools", len(messages), len(supportedTools))
+
+	var acc agents.ResponseAccumulator
+	err := p.ollamaService.StreamChatWithTools(ctx, p.Model(), ollamaMessages, agents.ConvertAgentToOllamaTools(supportedTools), func(chunk *services.OllamaChatResponse) error {
+		response := &agents.Response{
+			Content:   chunk.Message.Content,
+			ToolCalls: p.convertToolCalls(chunk.Message.ToolCalls),
+			Metadata: agents.ResponseMetadata{
+				Model:           chunk.Model,
+				TotalDuration:   chunk.TotalDuration,
+				EvalCount:       chunk.EvalCount,
+				PromptEvalCount: chunk.PromptEvalCount,
+			},
+		}
+		acc.Add(response)
+		if callback != nil {
+			return callback(response)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("streaming chat with tools failed: %w", err)
+	}
+
+	return acc.Response(), nil
+}
+
 // filterSupportedTools returns only the tools this model supports
 func (p *Llama32Provider) filterSupportedTools(tools []agents.Tool) []agents.Tool {
 	supported := make(map[string]bool)
@@ -0,0 +1,100 @@
+package agents
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// ResponseAccumulator assembles a complete Response from streamed chunks
+type ResponseAccumulator struct {
+	content   strings.Builder
+	toolCalls []ToolCall
+	metadata  ResponseMetadata
+}
+
+// Add merges a streamed chunk into the accumulated response
+func (a *ResponseAccumulator) Add(chunk *Response) {
+	if chunk == nil {
+		return
+	}
+
+	a.content.WriteString(chunk.Content)
+	a.toolCalls = MergeToolCallDeltas(a.toolCalls, chunk.ToolCalls)
+
+	// Usage counters only arrive on the final chunk
+	if chunk.Metadata.Model != "" {
+		a.metadata.Model = chunk.Metadata.Model
+	}
+	if chunk.Metadata.TotalDuration > 0 {
+		a.metadata.TotalDuration = chunk.Metadata.TotalDuration
+	}
+	if chunk.Metadata.EvalCount > 0 {
+		a.metadata.EvalCount = chunk.Metadata.EvalCount
+	}
+	if chunk.Metadata.PromptEvalCount > 0 {
+		a.metadata.PromptEvalCount = chunk.Metadata.PromptEvalCount
+	}
+}
+
+// Response returns the response accumulated so far
+func (a *ResponseAccumulator) Response() *Response {
+	return &Response{
+		Content:   a.content.String(),
+		ToolCalls: a.toolCalls,
+		Metadata:  a.metadata,
+	}
+}
+
+// MergeToolCallDeltas folds partial tool calls from a stream chunk into the calls seen so far.
+// A delta without a function name continues the previous call (or the call with the same ID),
+// appending to its arguments; a named delta starts a new call.
+func MergeToolCallDeltas(calls []ToolCall, deltas []ToolCall) []ToolCall {
+	for _, delta := range deltas {
+		target := -1
+		if delta.ID != "" {
+			for i := range calls {
+				if calls[i].ID == delta.ID {
+					target = i
+					break
+				}
+			}
+		} else if delta.Function.Name == "" && len(calls) > 0 {
+			target = len(calls) - 1
+		}
+
+		if target < 0 {
+			if delta.Type == "" {
+				delta.Type = "function"
+			}
+			calls = append(calls, delta)
+			continue
+		}
+
+		if delta.Function.Name != "" {
+			calls[target].Function.Name = delta.Function.Name
+		}
+		calls[target].Function.Arguments = appendArguments(calls[target].Function.Arguments, delta.Function.Arguments)
+	}
+	return calls
+}
+
+// appendArguments concatenates streamed argument fragments. Fragments sent as JSON
+// strings (OpenAI-style deltas) are joined inside a single string value.
+func appendArguments(existing, fragment json.RawMessage) json.RawMessage {
+	if len(fragment) == 0 {
+		return existing
+	}
+	if len(existing) == 0 {
+		return fragment
+	}
+
+	var head, tail string
+	if json.Unmarshal(existing, &head) == nil && json.Unmarshal(fragment, &tail) == nil {
+		joined, _ := json.Marshal(head + tail)
+		return joined
+	}
+
+	merged := make(json.RawMessage, 0, len(existing)+len(fragment))
+	merged = append(merged, existing...)
+	return append(merged, fragment...)
+}
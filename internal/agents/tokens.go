@@ -0,0 +1,76 @@
+package agents
+
+import (
+	"unicode/utf8"
+)
+
+const (
+	// charsPerToken approximates how many characters a typical BPE tokenizer
+	// packs into one token for English text and code
+	charsPerToken = 4
+
+	// messageOverheadTokens covers the role markers and separators a chat
+	// template adds around every message
+	messageOverheadTokens = 4
+)
+
+// EstimateTokens returns a rough token count for text. It errs on the high
+// side so budgets computed from it leave headroom for the real tokenizer.
+func EstimateTokens(text string) int {
+	if text == "" {
+		return 0
+	}
+
+	// Counting bytes rather than runes already charges more for non-ASCII
+	// text, which tokenizes less efficiently
+	return (len(text) + charsPerToken - 1) / charsPerToken
+}
+
+// EstimateMessageTokens returns the estimated tokens a message occupies in the prompt
+func EstimateMessageTokens(role, content string) int {
+	return messageOverheadTokens + EstimateTokens(role) + EstimateTokens(content)
+}
+
+// TruncateToTokens shortens text to roughly maxTokens, keeping the beginning and end
+// where tool output usually carries its headline and summary
+func TruncateToTokens(text string, maxTokens int) string {
+	if maxTokens <= 0 {
+		return ""
+	}
+	if EstimateTokens(text) <= maxTokens {
+		return text
+	}
+
+	const marker = "\n... [truncated] ...\n"
+	keep := maxTokens*charsPerToken - len(marker)
+	if keep <= 0 {
+		return marker
+	}
+
+	head := keep * 2 / 3
+	tail := keep - head
+	return safePrefix(text, head) + marker + safeSuffix(text, tail)
+}
+
+// safePrefix returns at most n bytes from the start of s without splitting a rune
+func safePrefix(s string, n int) string {
+	if n >= len(s) {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// safeSuffix returns at most n bytes from the end of s without splitting a rune
+func safeSuffix(s string, n int) string {
+	if n >= len(s) {
+		return s
+	}
+	start := len(s) - n
+	for start < len(s) && !utf8.RuneStart(s[start]) {
+		start++
+	}
+	return s[start:]
+}
@@ -1,6 +1,7 @@
 package agents
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 )
@@ -14,7 +15,7 @@ type ToolImplementation interface {
 	Description() string
 
 	// Execute runs the tool with the given parameters
-	Execute(params map[string]any, userID string) (string, error)
+	Execute(ctx context.Context, params map[string]any, userID string) (string, error)
 
 	// ValidateParams checks if the parameters are valid
 	ValidateParams(params map[string]any) error
@@ -29,9 +30,14 @@ type ToolCallRequest struct {
 	Params map[string]any `json:"params,omitempty"`
 }
 
+// ToolPolicy decides whether a tool may run with the given parameters,
+// returning why not when it may not
+type ToolPolicy func(tool string, params map[string]any) error
+
 // ToolRegistry manages available tools
 type ToolRegistry struct {
-	tools map[string]ToolImplementation
+	tools  map[string]ToolImplementation
+	policy ToolPolicy
 }
 
 // NewToolRegistry creates a new tool registry
@@ -46,6 +52,11 @@ func (r *ToolRegistry) Register(tool ToolImplementation) {
 	r.tools[tool.Name()] = tool
 }
 
+// SetPolicy makes every tool execution pass the policy first
+func (r *ToolRegistry) SetPolicy(policy ToolPolicy) {
+	r.policy = policy
+}
+
 // Get retrieves a tool by name
 func (r *ToolRegistry) Get(name string) (ToolImplementation, bool) {
 	tool, exists := r.tools[name]
@@ -61,20 +72,34 @@ func (r *ToolRegistry) ListTools() map[string]string {
 	return list
 }
 
-// ExecuteTool executes a tool by name with given parameters
-func (r *ToolRegistry) ExecuteTool(name string, params map[string]any, userID string) (string, error) {
+// ExecuteTool executes a tool by name with given parameters.
+// The tool is not started when ctx has already been cancelled or the
+// registry's policy refuses it.
+func (r *ToolRegistry) ExecuteTool(ctx context.Context, name string, params map[string]any, userID string) (string, error) {
 	tool, exists := r.tools[name]
 	if !exists {
 		return "", fmt.Errorf("tool '%s' not found", name)
 	}
 
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
 	// Validate parameters
 	if err := tool.ValidateParams(params); err != nil {
 		return "", fmt.Errorf("invalid parameters for tool '%s': %w", name, err)
 	}
 
+	// Checked after validation so the policy sees the parameters the tool
+	// will run with, and by the tool's own name rather than an alias
+	if r.policy != nil {
+		if err := r.policy(tool.Name(), params); err != nil {
+			return "", fmt.Errorf("tool '%s' denied: %w", name, err)
+		}
+	}
+
 	// Execute the tool
-	result, err := tool.Execute(params, userID)
+	result, err := tool.Execute(ctx, params, userID)
 	if err != nil {
 		return "", fmt.Errorf("tool '%s' execution failed: %w", name, err)
 	}
@@ -1,9 +1,12 @@
 package tools
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"time"
+	"workspace/internal/lint"
+	"workspace/internal/testreport"
 	"workspace/models"
 	"workspace/services"
 )
@@ -67,7 +70,7 @@ func (t *BuildTool) Schema() map[string]any {
 	})
 }
 
-func (t *BuildTool) Execute(params map[string]any, userID string) (string, error) {
+func (t *BuildTool) Execute(ctx context.Context, params map[string]any, userID string) (string, error) {
 	repoID := params["repo_id"].(string)
 	command := params["command"].(string)
 
@@ -127,7 +130,7 @@ func (t *BuildTool) Execute(params map[string]any, userID string) (string, error
 
 	// Execute in sandbox
 	sandboxName := fmt.Sprintf("build-%s-%d", repo.ID, time.Now().Unix())
-	sandbox, err := services.NewSandbox(sandboxName, repo.Path(), repo.Name, fullCommand, timeout)
+	sandbox, err := services.NewSandbox(sandboxName, repo.Path(), repo.Name, fullCommand, timeout, nil)
 	if err != nil {
 		return "", fmt.Errorf("failed to create sandbox: %w", err)
 	}
@@ -194,7 +197,7 @@ func (t *TestTool) Name() string {
 }
 
 func (t *TestTool) Description() string {
-	return "Execute tests and parse results. Required params: repo_id, command. Optional params: working_dir, timeout_seconds, coverage"
+	return "Execute tests and parse results. Per-test results are recorded and flaky tests flagged when the output is go test -json, JUnit XML or pytest -v/-rA. Required params: repo_id, command. Optional params: working_dir, timeout_seconds, coverage"
 }
 
 func (t *TestTool) ValidateParams(params map[string]any) error {
@@ -246,7 +249,7 @@ func (t *TestTool) Schema() map[string]any {
 	})
 }
 
-func (t *TestTool) Execute(params map[string]any, userID string) (string, error) {
+func (t *TestTool) Execute(ctx context.Context, params map[string]any, userID string) (string, error) {
 	repoID := params["repo_id"].(string)
 	command := params["command"].(string)
 
@@ -311,7 +314,7 @@ func (t *TestTool) Execute(params map[string]any, userID string) (string, error)
 
 	// Execute in sandbox
 	sandboxName := fmt.Sprintf("test-%s-%d", repo.ID, time.Now().Unix())
-	sandbox, err := services.NewSandbox(sandboxName, repo.Path(), repo.Name, fullCommand, timeout)
+	sandbox, err := services.NewSandbox(sandboxName, repo.Path(), repo.Name, fullCommand, timeout, nil)
 	if err != nil {
 		return "", fmt.Errorf("failed to create sandbox: %w", err)
 	}
@@ -339,8 +342,46 @@ func (t *TestTool) Execute(params map[string]any, userID string) (string, error)
 	// Parse test statistics
 	result.WriteString("### Test Results\n")
 
-	// Try to extract test counts from common formats
-	if strings.Contains(output, "PASS") || strings.Contains(output, "FAIL") {
+	// Structured results are recorded for the repository's test dashboard
+	var failedTests []string
+	format, results, parseErr := testreport.Parse([]byte(output))
+	if parseErr == nil {
+		summary := testreport.Summarize(results)
+		result.WriteString(fmt.Sprintf("- **Passed:** %d\n", summary.Passed))
+		result.WriteString(fmt.Sprintf("- **Failed:** %d\n", summary.Failed))
+		result.WriteString(fmt.Sprintf("- **Skipped:** %d\n", summary.Skipped))
+		for _, r := range results {
+			if r.Status == testreport.Failed {
+				failedTests = append(failedTests, r.Suite+" "+r.Name)
+			}
+		}
+
+		branch := repo.GetDefaultBranch()
+		commit, _ := repo.ResolveCommit("refs/heads/" + branch)
+		if _, err := models.RecordTestRun(&models.TestRun{
+			RepoID:    repo.ID,
+			Source:    models.TestSourceAssistant,
+			Name:      command,
+			URL:       fmt.Sprintf("/repos/%s/tests", repo.ID),
+			Branch:    branch,
+			CommitSHA: commit,
+		}, format, results); err == nil {
+			result.WriteString(fmt.Sprintf("- Recorded on the [test dashboard](/repos/%s/tests)\n", repo.ID))
+		}
+
+		// Failures of tests known to be flaky may not be real
+		if flaky, err := models.FlakyTests(repo.ID, 50); err == nil && len(flaky) > 0 {
+			known := map[string]bool{}
+			for _, history := range flaky {
+				known[history.Suite+" "+history.Name] = true
+			}
+			for _, name := range failedTests {
+				if known[name] {
+					result.WriteString(fmt.Sprintf("- `%s` is flaky, it alternates between passing and failing\n", name))
+				}
+			}
+		}
+	} else if strings.Contains(output, "PASS") || strings.Contains(output, "FAIL") {
 		// Go test format
 		passCount := strings.Count(output, "PASS")
 		failCount := strings.Count(output, "FAIL")
@@ -373,7 +414,9 @@ func (t *TestTool) Execute(params map[string]any, userID string) (string, error)
 		result.WriteString("\n### Actions Taken\n")
 
 		// Parse for specific test failures and suggest creating issues
-		failedTests := extractFailedTests(output)
+		if parseErr != nil {
+			failedTests = extractFailedTests(output)
+		}
 		if len(failedTests) > 0 {
 			result.WriteString(fmt.Sprintf("- Found %d test failures\n", len(failedTests)))
 			result.WriteString("- Consider creating issues for each failure\n")
@@ -421,7 +464,8 @@ func extractFailedTests(output string) []string {
 	return failed
 }
 
-// DeployTool handles deployment to staging/production environments
+// DeployTool deploys images from the repository's registry to its
+// configured environments and rolls them back
 type DeployTool struct{}
 
 func (t *DeployTool) Name() string {
@@ -429,7 +473,7 @@ func (t *DeployTool) Name() string {
 }
 
 func (t *DeployTool) Description() string {
-	return "Deploy application to staging or production. Required params: repo_id, environment. Optional params: version, rollback"
+	return "Deploy an image from the repository's registry to one of its environments, or roll an environment back. Required params: repo_id, environment. Optional params: image, version, rollback, dry_run"
 }
 
 func (t *DeployTool) ValidateParams(params map[string]any) error {
@@ -445,22 +489,8 @@ func (t *DeployTool) ValidateParams(params map[string]any) error {
 	if !exists {
 		return fmt.Errorf("environment is required")
 	}
-	envStr, ok := environment.(string)
-	if !ok {
-		return fmt.Errorf("environment must be a string")
-	}
-
-	// Validate environment
-	validEnvs := []string{"staging", "production", "development", "test"}
-	valid := false
-	for _, env := range validEnvs {
-		if envStr == env {
-			valid = true
-			break
-		}
-	}
-	if !valid {
-		return fmt.Errorf("environment must be one of: staging, production, development, test")
+	if envStr, ok := environment.(string); !ok || envStr == "" {
+		return fmt.Errorf("environment must be the name of one of the repository's environments")
 	}
 
 	return nil
@@ -475,34 +505,31 @@ func (t *DeployTool) Schema() map[string]any {
 		},
 		"environment": map[string]any{
 			"type":        "string",
-			"description": "Target environment (staging, production, development, test)",
+			"description": "Name of one of the repository's environments, such as staging",
 			"required":    true,
-			"enum":        []string{"staging", "production", "development", "test"},
+		},
+		"image": map[string]any{
+			"type":        "string",
+			"description": "Image in the repository's container registry, such as app (default: the one the environment runs, or the only one)",
 		},
 		"version": map[string]any{
 			"type":        "string",
-			"description": "Version/tag to deploy (default: latest)",
+			"description": "Image tag to deploy, such as a short commit SHA (default: the default branch's tag)",
 		},
 		"rollback": map[string]any{
 			"type":        "boolean",
-			"description": "Rollback to previous version",
+			"description": "Redeploy what the environment ran before its current deployment",
 			"default":     false,
 		},
-		"strategy": map[string]any{
-			"type":        "string",
-			"description": "Deployment strategy (blue-green, rolling, recreate)",
-			"default":     "rolling",
-			"enum":        []string{"blue-green", "rolling", "recreate"},
-		},
 		"dry_run": map[string]any{
 			"type":        "boolean",
-			"description": "Perform a dry run without actual deployment",
+			"description": "Describe the deployment without running it",
 			"default":     false,
 		},
 	})
 }
 
-func (t *DeployTool) Execute(params map[string]any, userID string) (string, error) {
+func (t *DeployTool) Execute(ctx context.Context, params map[string]any, userID string) (string, error) {
 	repoID := params["repo_id"].(string)
 	environment := params["environment"].(string)
 
@@ -526,185 +553,254 @@ func (t *DeployTool) Execute(params map[string]any, userID string) (string, erro
 		return "", fmt.Errorf("access denied: you don't have deployment permissions")
 	}
 
-	// Get parameters
-	version := "latest"
-	if v, exists := params["version"]; exists {
-		if vStr, ok := v.(string); ok && vStr != "" {
-			version = vStr
+	env, err := models.GetEnvironment(repo.ID, environment)
+	if err != nil {
+		envs, _ := models.GetEnvironments(repo.ID)
+		if len(envs) == 0 {
+			return "", fmt.Errorf("%s has no environments; add one on its Environments tab", repo.Name)
 		}
-	}
-
-	rollback := false
-	if r, exists := params["rollback"]; exists {
-		if rBool, ok := r.(bool); ok {
-			rollback = rBool
+		names := make([]string, len(envs))
+		for i, e := range envs {
+			names[i] = e.Name
 		}
+		return "", fmt.Errorf("no environment named %s; %s has: %s", environment, repo.Name, strings.Join(names, ", "))
 	}
 
-	strategy := "rolling"
-	if s, exists := params["strategy"]; exists {
-		if sStr, ok := s.(string); ok && sStr != "" {
-			strategy = sStr
+	rollback, _ := params["rollback"].(bool)
+	dryRun, _ := params["dry_run"].(bool)
+
+	// Work out what to deploy
+	var image, version string
+	if rollback {
+		previous, err := env.Previous()
+		if err != nil {
+			return "", err
+		}
+		image, version = previous.Image, previous.Version
+	} else {
+		image, _ = params["image"].(string)
+		if image == "" {
+			image = env.Image
+		}
+		if image == "" {
+			images, err := services.Registry.Images(repo.ID)
+			if err != nil {
+				return "", fmt.Errorf("failed to list images: %w", err)
+			}
+			if len(images) != 1 {
+				return "", fmt.Errorf("%s has %d images in its registry; name the image to deploy", repo.Name, len(images))
+			}
+			image = images[0].Name
+		}
+		version, _ = params["version"].(string)
+		if version == "" {
+			version = models.ImageTag(repo.GetDefaultBranch())
 		}
 	}
 
-	dryRun := false
-	if d, exists := params["dry_run"]; exists {
-		if dBool, ok := d.(bool); ok {
-			dryRun = dBool
+	var result strings.Builder
+	if dryRun {
+		result.WriteString("**Dry Run** - nothing was deployed\n\n")
+		result.WriteString(fmt.Sprintf("**Environment:** %s on %s\n", env.Name, env.Target()))
+		result.WriteString(fmt.Sprintf("**Would deploy:** %s:%s\n", image, version))
+		if env.CurrentVersion != "" {
+			result.WriteString(fmt.Sprintf("**Replacing:** %s:%s\n", env.Image, env.CurrentVersion))
+		}
+		if ports := env.PortList(); len(ports) > 0 {
+			result.WriteString(fmt.Sprintf("**Ports:** %s\n", strings.Join(ports, ", ")))
 		}
+		result.WriteString(fmt.Sprintf("**Container:** %s\n", env.ContainerName()))
+		return result.String(), nil
 	}
 
-	// Build deployment script based on repository configuration
-	var deployScript string
-
-	// Detect deployment method
-	deployMethod := "docker" // default
-
-	// Build deployment command based on method
+	var deployment *models.Deployment
 	if rollback {
-		deployScript = buildRollbackScript(environment, strategy)
+		deployment, err = services.Deployments.Rollback(env, user.ID)
 	} else {
-		deployScript = buildDeployScript(repo.Name, environment, version, strategy, deployMethod)
-	}
-
-	if dryRun {
-		deployScript = fmt.Sprintf("echo 'DRY RUN MODE - No actual deployment'\n%s",
-			strings.ReplaceAll(deployScript, "docker", "echo docker"))
+		deployment, err = services.Deployments.Deploy(env, image, version, user.ID)
 	}
-
-	// Execute in sandbox
-	sandboxName := fmt.Sprintf("deploy-%s-%s-%d", repo.ID, environment, time.Now().Unix())
-	sandbox, err := services.NewSandbox(sandboxName, repo.Path(), repo.Name, deployScript, 600)
 	if err != nil {
-		return "", fmt.Errorf("failed to create sandbox: %w", err)
+		return "", err
 	}
-	defer sandbox.Cleanup()
 
-	// Execute the deployment
-	startTime := time.Now()
-	output, exitCode, err := sandbox.Execute(deployScript)
-	duration := time.Since(startTime)
-
-	success := err == nil && exitCode == 0
-	var result strings.Builder
+	// Wait for the deployment to finish, so its outcome can be reported
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+	for deployment.Status == models.DeploymentRunning {
+		select {
+		case <-ctx.Done():
+			return fmt.Sprintf("Deployment of %s to %s is still running; follow it on the repository's Environments tab", deployment.Reference(), env.Name), nil
+		case <-ticker.C:
+		}
+		if deployment, err = models.Deployments.Get(deployment.ID); err != nil {
+			return "", fmt.Errorf("failed to check the deployment: %w", err)
+		}
+	}
 
-	if success {
-		result.WriteString(fmt.Sprintf("✅ **Deployment Successful**\n"))
+	if deployment.Status == models.DeploymentSucceeded {
+		result.WriteString("✅ **Deployment Successful**\n")
 	} else {
-		result.WriteString(fmt.Sprintf("❌ **Deployment Failed**\n"))
+		result.WriteString("❌ **Deployment Failed**\n")
 	}
-
-	result.WriteString(fmt.Sprintf("**Environment:** %s\n", environment))
-	result.WriteString(fmt.Sprintf("**Version:** %s\n", version))
-	result.WriteString(fmt.Sprintf("**Strategy:** %s\n", strategy))
-	result.WriteString(fmt.Sprintf("**Duration:** %s\n", duration.Round(time.Second)))
-	if dryRun {
-		result.WriteString("**Mode:** Dry Run\n")
+	result.WriteString(fmt.Sprintf("**Environment:** %s on %s\n", env.Name, env.Target()))
+	result.WriteString(fmt.Sprintf("**Image:** %s\n", deployment.Reference()))
+	if rollback {
+		result.WriteString("**Mode:** Rollback\n")
+	}
+	if deployment.Health != "" {
+		result.WriteString(fmt.Sprintf("**Health:** %s (%s)\n", deployment.Health, deployment.HealthDetail))
+		if deployment.Health == models.DeploymentUnhealthy && env.AutoRollback && env.DeploymentID != "" {
+			result.WriteString(fmt.Sprintf("**Rollback:** %s is being rolled back automatically\n", env.Name))
+		}
 	}
+	result.WriteString(fmt.Sprintf("**Duration:** %s\n", deployment.FormatDuration()))
 	result.WriteString(fmt.Sprintf("**Repository:** %s\n\n", repo.Name))
 
-	// Add deployment details
 	result.WriteString("### Deployment Output\n```\n")
-	result.WriteString(output)
+	result.WriteString(deployment.Output)
 	result.WriteString("\n```\n")
 
-	// Log the activity
-	if !dryRun {
-		activity := &models.Activity{
-			Type:        "deployment",
-			UserID:      user.ID,
-			RepoID:      repo.ID,
-			Description: fmt.Sprintf("Deployed to %s (version: %s)", environment, version),
-		}
-		models.Activities.Insert(activity)
+	if deployment.Status == models.DeploymentSucceeded && !rollback {
+		result.WriteString("\nIf it misbehaves, deploy again with rollback to restore the previous version.\n")
 	}
+	return result.String(), nil
+}
 
-	// Add post-deployment actions
-	if success && !dryRun {
-		result.WriteString("\n### Post-Deployment Actions\n")
-		result.WriteString("- ✅ Deployment logged\n")
-		result.WriteString("- ✅ Monitoring initiated\n")
-		result.WriteString("- Consider running smoke tests\n")
-		result.WriteString("- Consider checking application health\n")
+// LintTool runs the linters in a sandbox and reports their findings
+type LintTool struct{}
+
+func (t *LintTool) Name() string {
+	return "run_linters"
+}
+
+func (t *LintTool) Description() string {
+	return "Run go vet, staticcheck, eslint and gosec on a branch in a sandbox. Findings are recorded, posted on the lines its open pull requests add, and security findings count in pull request reviews. Required params: repo_id. Optional params: branch, linters"
+}
 
-		if environment == "production" {
-			result.WriteString("- **Production deployment** - Monitor closely for issues\n")
-			result.WriteString("- Be ready to rollback if needed\n")
+func (t *LintTool) ValidateParams(params map[string]any) error {
+	repoID, exists := params["repo_id"]
+	if !exists {
+		return fmt.Errorf("repo_id is required")
+	}
+	if _, ok := repoID.(string); !ok {
+		return fmt.Errorf("repo_id must be a string")
+	}
+
+	if linters, exists := params["linters"]; exists {
+		if _, err := lint.Select(stringList(linters)); err != nil {
+			return err
 		}
 	}
 
-	return result.String(), nil
+	return nil
 }
 
-// buildDeployScript creates a deployment script based on the method
-func buildDeployScript(appName, environment, version, strategy, method string) string {
-	var script strings.Builder
-
-	script.WriteString("#!/bin/bash\n")
-	script.WriteString("set -e\n\n")
-	script.WriteString(fmt.Sprintf("echo 'Deploying %s to %s environment'\n", appName, environment))
-	script.WriteString(fmt.Sprintf("echo 'Version: %s'\n", version))
-	script.WriteString(fmt.Sprintf("echo 'Strategy: %s'\n\n", strategy))
-
-	switch method {
-	case "docker":
-		script.WriteString("# Docker deployment\n")
-		script.WriteString(fmt.Sprintf("docker build -t %s:%s .\n", appName, version))
-
-		if strategy == "blue-green" {
-			script.WriteString("# Blue-Green deployment\n")
-			script.WriteString(fmt.Sprintf("docker tag %s:%s %s:%s-new\n", appName, version, appName, environment))
-			script.WriteString(fmt.Sprintf("docker run -d --name %s-%s-new %s:%s-new\n", appName, environment, appName, environment))
-			script.WriteString("# Health check\n")
-			script.WriteString("sleep 10\n")
-			script.WriteString(fmt.Sprintf("docker stop %s-%s || true\n", appName, environment))
-			script.WriteString(fmt.Sprintf("docker rm %s-%s || true\n", appName, environment))
-			script.WriteString(fmt.Sprintf("docker rename %s-%s-new %s-%s\n", appName, environment, appName, environment))
-		} else {
-			script.WriteString("# Rolling deployment\n")
-			script.WriteString(fmt.Sprintf("docker stop %s-%s || true\n", appName, environment))
-			script.WriteString(fmt.Sprintf("docker rm %s-%s || true\n", appName, environment))
-			script.WriteString(fmt.Sprintf("docker run -d --name %s-%s %s:%s\n", appName, environment, appName, version))
-		}
-
-		script.WriteString("\necho 'Deployment complete'\n")
-		script.WriteString(fmt.Sprintf("docker ps | grep %s-%s\n", appName, environment))
-
-	case "kubernetes":
-		script.WriteString("# Kubernetes deployment\n")
-		script.WriteString(fmt.Sprintf("kubectl set image deployment/%s %s=%s:%s -n %s\n",
-			appName, appName, appName, version, environment))
-		script.WriteString(fmt.Sprintf("kubectl rollout status deployment/%s -n %s\n", appName, environment))
-
-	default:
-		script.WriteString("# Generic deployment\n")
-		script.WriteString("echo 'Running deployment script'\n")
-		script.WriteString("if [ -f deploy.sh ]; then\n")
-		script.WriteString("  ./deploy.sh\n")
-		script.WriteString("else\n")
-		script.WriteString("  echo 'No deploy.sh found, using default deployment'\n")
-		script.WriteString("fi\n")
-	}
-
-	return script.String()
+func (t *LintTool) Schema() map[string]any {
+	return SimpleSchema(map[string]any{
+		"repo_id": map[string]any{
+			"type":        "string",
+			"description": "The repository ID",
+			"required":    true,
+		},
+		"branch": map[string]any{
+			"type":        "string",
+			"description": "Branch to lint (default: the default branch)",
+		},
+		"linters": map[string]any{
+			"type":        "array",
+			"description": "Linters to run: " + strings.Join(lint.Names(), ", ") + " (default: all that apply)",
+			"items": map[string]any{
+				"type": "string",
+			},
+		},
+	})
 }
 
-// buildRollbackScript creates a rollback script
-func buildRollbackScript(environment, strategy string) string {
-	var script strings.Builder
+func (t *LintTool) Execute(ctx context.Context, params map[string]any, userID string) (string, error) {
+	repoID := params["repo_id"].(string)
 
-	script.WriteString("#!/bin/bash\n")
-	script.WriteString("set -e\n\n")
-	script.WriteString(fmt.Sprintf("echo 'Rolling back %s environment'\n", environment))
-	script.WriteString(fmt.Sprintf("echo 'Strategy: %s'\n\n", strategy))
+	// Get user for permissions
+	user, err := models.Auth.GetUser(userID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get user: %w", err)
+	}
 
-	script.WriteString("# Rollback to previous version\n")
-	script.WriteString("echo 'Identifying previous version...'\n")
-	script.WriteString("# This would typically query your deployment history\n")
-	script.WriteString("echo 'Rolling back...'\n")
-	script.WriteString("# Actual rollback commands would go here\n")
-	script.WriteString("echo 'Rollback complete'\n")
+	// Get repository
+	repo, err := models.Repositories.Get(repoID)
+	if err != nil {
+		return "", fmt.Errorf("repository not found: %s", repoID)
+	}
 
-	return script.String()
+	// Check permissions
+	if !user.IsAdmin && repo.UserID != user.ID {
+		return "", fmt.Errorf("access denied: you don't have lint permissions")
+	}
+
+	branch, _ := params["branch"].(string)
+	run, report, err := services.RunLinters(ctx, repo, branch, stringList(params["linters"]), user.ID)
+	if err != nil {
+		return "", fmt.Errorf("failed to run linters: %w", err)
+	}
+
+	var result strings.Builder
+	if len(report.Issues) == 0 {
+		result.WriteString("✅ **No Lint Issues**\n")
+	} else {
+		result.WriteString(fmt.Sprintf("❌ **%d Lint Issues**\n", len(report.Issues)))
+	}
+	result.WriteString(fmt.Sprintf("**Branch:** %s\n", run.Branch))
+	result.WriteString(fmt.Sprintf("**Linters:** %s\n", strings.Join(report.Ran, ", ")))
+	for _, skipped := range report.Skipped {
+		result.WriteString(fmt.Sprintf("**Skipped:** %s\n", skipped))
+	}
+	result.WriteString(fmt.Sprintf("**Repository:** %s\n\n", repo.Name))
+
+	if security := report.Security(); len(security) > 0 {
+		result.WriteString("### Security Issues\n")
+		for _, issue := range security {
+			result.WriteString(fmt.Sprintf("- `%s` **%s** (%s): %s\n", issue.Location(), issue.Title(), issue.Severity, issue.Message))
+		}
+		result.WriteString("\n")
+	}
+	if len(report.Issues) > len(report.Security()) {
+		result.WriteString("### Issues\n")
+		for _, issue := range report.Issues {
+			if !issue.Security {
+				result.WriteString(fmt.Sprintf("- `%s` **%s** (%s): %s\n", issue.Location(), issue.Title(), issue.Severity, issue.Message))
+			}
+		}
+	}
+	if report.Truncated {
+		result.WriteString(fmt.Sprintf("\nOnly the first %d issues were kept.\n", lint.MaxIssues))
+	}
+
+	// Log the activity
+	activity := &models.Activity{
+		Type:        "lint",
+		UserID:      user.ID,
+		RepoID:      repo.ID,
+		Description: fmt.Sprintf("Ran linters on %s: %d issues", run.Branch, run.Issues),
+	}
+	models.Activities.Insert(activity)
+
+	return result.String(), nil
+}
+
+// stringList reads a list of strings param, which may be a single string
+func stringList(value any) []string {
+	switch v := value.(type) {
+	case string:
+		return strings.FieldsFunc(v, func(r rune) bool { return r == ',' })
+	case []any:
+		var list []string
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				list = append(list, s)
+			}
+		}
+		return list
+	case []string:
+		return v
+	}
+	return nil
 }
@@ -1,8 +1,10 @@
 package tools
 
 import (
+	"context"
 	"fmt"
 	"strings"
+	"workspace/internal/collab"
 	"workspace/models"
 )
 
@@ -86,7 +88,7 @@ func (t *EditFileTool) Schema() map[string]any {
 	})
 }
 
-func (t *EditFileTool) Execute(params map[string]any, userID string) (string, error) {
+func (t *EditFileTool) Execute(ctx context.Context, params map[string]any, userID string) (string, error) {
 	repoID := params["repo_id"].(string)
 	path := params["path"].(string)
 	content := params["content"].(string)
@@ -120,11 +122,18 @@ func (t *EditFileTool) Execute(params map[string]any, userID string) (string, er
 		branch = repo.GetDefaultBranch()
 	}
 
+	// Remember the previous content so open editors can merge the change
+	before := ""
+	if existing, err := repo.GetFile(branch, path); err == nil {
+		before = existing.Content
+	}
+
 	// Update the file
 	err = repo.UpdateFile(branch, path, content, message, user.Name, user.Email)
 	if err != nil {
 		return "", fmt.Errorf("failed to update file: %w", err)
 	}
+	merged := syncOpenEditors(repoID, branch, path, before, content)
 
 	// Format success response
 	var result strings.Builder
@@ -134,6 +143,9 @@ func (t *EditFileTool) Execute(params map[string]any, userID string) (string, er
 	result.WriteString(fmt.Sprintf("**Branch:** %s\n", branch))
 	result.WriteString(fmt.Sprintf("**Commit Message:** %s\n", message))
 	result.WriteString(fmt.Sprintf("**Author:** %s <%s>\n", user.Name, user.Email))
+	if merged {
+		result.WriteString("**Live editors:** change merged into the open editing session\n")
+	}
 
 	return result.String(), nil
 }
@@ -218,7 +230,7 @@ func (t *WriteFileTool) Schema() map[string]any {
 	})
 }
 
-func (t *WriteFileTool) Execute(params map[string]any, userID string) (string, error) {
+func (t *WriteFileTool) Execute(ctx context.Context, params map[string]any, userID string) (string, error) {
 	repoID := params["repo_id"].(string)
 	path := params["path"].(string)
 	content := params["content"].(string)
@@ -261,6 +273,10 @@ func (t *WriteFileTool) Execute(params map[string]any, userID string) (string, e
 	if err != nil {
 		return "", fmt.Errorf("failed to write file: %w", err)
 	}
+	merged := false
+	if !isNew {
+		merged = syncOpenEditors(repoID, branch, path, existingFile.Content, content)
+	}
 
 	// Format success response
 	var result strings.Builder
@@ -274,10 +290,21 @@ func (t *WriteFileTool) Execute(params map[string]any, userID string) (string, e
 	result.WriteString(fmt.Sprintf("**Branch:** %s\n", branch))
 	result.WriteString(fmt.Sprintf("**Commit Message:** %s\n", message))
 	result.WriteString(fmt.Sprintf("**Author:** %s <%s>\n", user.Name, user.Email))
+	if merged {
+		result.WriteString("**Live editors:** change merged into the open editing session\n")
+	}
 
 	return result.String(), nil
 }
 
+// syncOpenEditors merges a file change into the web editor session for the
+// file, if anyone has it open, and reports whether one was
+func syncOpenEditors(repoID, branch, path, before, after string) bool {
+	normalize := func(s string) string { return strings.ReplaceAll(s, "\r\n", "\n") }
+	return collab.Sessions.ApplyFileChange(collab.Key(repoID, branch, path),
+		normalize(before), normalize(after), "AI Assistant")
+}
+
 // DeleteFileTool removes a file from a repository
 type DeleteFileTool struct{}
 
@@ -345,7 +372,7 @@ func (t *DeleteFileTool) Schema() map[string]any {
 	})
 }
 
-func (t *DeleteFileTool) Execute(params map[string]any, userID string) (string, error) {
+func (t *DeleteFileTool) Execute(ctx context.Context, params map[string]any, userID string) (string, error) {
 	repoID := params["repo_id"].(string)
 	path := params["path"].(string)
 	message := params["message"].(string)
@@ -480,7 +507,7 @@ func (t *MoveFileTool) Schema() map[string]any {
 	})
 }
 
-func (t *MoveFileTool) Execute(params map[string]any, userID string) (string, error) {
+func (t *MoveFileTool) Execute(ctx context.Context, params map[string]any, userID string) (string, error) {
 	repoID := params["repo_id"].(string)
 	oldPath := params["old_path"].(string)
 	newPath := params["new_path"].(string)
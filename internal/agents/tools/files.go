@@ -1,9 +1,11 @@
 package tools
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"workspace/models"
+	"workspace/services"
 )
 
 // ListFilesTool lists files and directories in a repository
@@ -58,7 +60,7 @@ func (t *ListFilesTool) Schema() map[string]any {
 	})
 }
 
-func (t *ListFilesTool) Execute(params map[string]any, userID string) (string, error) {
+func (t *ListFilesTool) Execute(ctx context.Context, params map[string]any, userID string) (string, error) {
 	repoID := params["repo_id"].(string)
 
 	// Get user to check permissions
@@ -218,7 +220,7 @@ func (t *ReadFileTool) Schema() map[string]any {
 	})
 }
 
-func (t *ReadFileTool) Execute(params map[string]any, userID string) (string, error) {
+func (t *ReadFileTool) Execute(ctx context.Context, params map[string]any, userID string) (string, error) {
 	repoIDVal, exists := params["repo_id"]
 	if !exists || repoIDVal == nil || repoIDVal == "" {
 		return "", fmt.Errorf("repo_id is required")
@@ -357,7 +359,7 @@ func (t *SearchFilesTool) Schema() map[string]any {
 	})
 }
 
-func (t *SearchFilesTool) Execute(params map[string]any, userID string) (string, error) {
+func (t *SearchFilesTool) Execute(ctx context.Context, params map[string]any, userID string) (string, error) {
 	repoID := params["repo_id"].(string)
 	pattern := params["pattern"].(string)
 
@@ -393,6 +395,18 @@ func (t *SearchFilesTool) Execute(params map[string]any, userID string) (string,
 	var matches []string
 	pattern = strings.ToLower(pattern)
 
+	// The default branch is served from the code index
+	if branch == repo.GetDefaultBranch() {
+		ix, err := services.Indexer.Get(repo)
+		if err != nil {
+			return "", fmt.Errorf("search failed: %w", err)
+		}
+		matches = ix.FindFiles(func(name string) bool {
+			return !strings.HasPrefix(name, ".") && matchesPattern(strings.ToLower(name), pattern)
+		})
+		return formatFileMatches(repo.Name, branch, pattern, matches), nil
+	}
+
 	var searchDir func(path string) error
 	searchDir = func(path string) error {
 		files, err := repo.GetFileTree(branch, path)
@@ -428,9 +442,13 @@ func (t *SearchFilesTool) Execute(params map[string]any, userID string) (string,
 		return "", fmt.Errorf("search failed: %w", err)
 	}
 
-	// Format results
+	return formatFileMatches(repo.Name, branch, pattern, matches), nil
+}
+
+// formatFileMatches formats the results of a file name search
+func formatFileMatches(repoName, branch, pattern string, matches []string) string {
 	var result strings.Builder
-	result.WriteString(fmt.Sprintf("## Search Results for '%s' in %s\n", pattern, repo.Name))
+	result.WriteString(fmt.Sprintf("## Search Results for '%s' in %s\n", pattern, repoName))
 	result.WriteString(fmt.Sprintf("**Branch:** %s\n\n", branch))
 
 	if len(matches) == 0 {
@@ -445,7 +463,7 @@ func (t *SearchFilesTool) Execute(params map[string]any, userID string) (string,
 		result.WriteString("\n💡 *Use read_file to examine these files for their content.*")
 	}
 
-	return result.String(), nil
+	return result.String()
 }
 
 // Helper functions
@@ -1,6 +1,7 @@
 package tools
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"time"
@@ -44,7 +45,7 @@ func (t *GitStatusTool) Schema() map[string]any {
 	})
 }
 
-func (t *GitStatusTool) Execute(params map[string]any, userID string) (string, error) {
+func (t *GitStatusTool) Execute(ctx context.Context, params map[string]any, userID string) (string, error) {
 	repoID := params["repo_id"].(string)
 
 	// Get user for permissions
@@ -201,7 +202,7 @@ func (t *GitDiffTool) Schema() map[string]any {
 	})
 }
 
-func (t *GitDiffTool) Execute(params map[string]any, userID string) (string, error) {
+func (t *GitDiffTool) Execute(ctx context.Context, params map[string]any, userID string) (string, error) {
 	repoID := params["repo_id"].(string)
 
 	// Get user for permissions
@@ -345,7 +346,7 @@ func (t *GitCommitTool) Schema() map[string]any {
 	})
 }
 
-func (t *GitCommitTool) Execute(params map[string]any, userID string) (string, error) {
+func (t *GitCommitTool) Execute(ctx context.Context, params map[string]any, userID string) (string, error) {
 	repoID := params["repo_id"].(string)
 	message := params["message"].(string)
 
@@ -469,7 +470,7 @@ func (t *GitBranchTool) Schema() map[string]any {
 	})
 }
 
-func (t *GitBranchTool) Execute(params map[string]any, userID string) (string, error) {
+func (t *GitBranchTool) Execute(ctx context.Context, params map[string]any, userID string) (string, error) {
 	repoID := params["repo_id"].(string)
 
 	// Get user for permissions
@@ -582,6 +583,204 @@ func (t *GitBranchTool) Execute(params map[string]any, userID string) (string, e
 	}
 }
 
+// GitBranchCreateTool creates a working branch for a task
+type GitBranchCreateTool struct{}
+
+func (t *GitBranchCreateTool) Name() string {
+	return "git_branch_create"
+}
+
+func (t *GitBranchCreateTool) Description() string {
+	return "Create a new branch to work on a task and switch to it. Required params: repo_id, name. Optional params: from (source branch, default: the repository's default branch)"
+}
+
+func (t *GitBranchCreateTool) ValidateParams(params map[string]any) error {
+	repoID, exists := params["repo_id"]
+	if !exists {
+		return fmt.Errorf("repo_id is required")
+	}
+	if _, ok := repoID.(string); !ok {
+		return fmt.Errorf("repo_id must be a string")
+	}
+
+	name, exists := params["name"]
+	if !exists {
+		return fmt.Errorf("name is required")
+	}
+	if nameStr, ok := name.(string); !ok || nameStr == "" {
+		return fmt.Errorf("name must be a non-empty string")
+	}
+
+	if from, exists := params["from"]; exists {
+		if _, ok := from.(string); !ok {
+			return fmt.Errorf("from must be a string")
+		}
+	}
+
+	return nil
+}
+
+func (t *GitBranchCreateTool) Schema() map[string]any {
+	return SimpleSchema(map[string]any{
+		"repo_id": map[string]any{
+			"type":        "string",
+			"description": "The repository ID",
+			"required":    true,
+		},
+		"name": map[string]any{
+			"type":        "string",
+			"description": "Name of the new branch, e.g. fix/login-timeout",
+			"required":    true,
+		},
+		"from": map[string]any{
+			"type":        "string",
+			"description": "Branch to start from (default: the repository's default branch)",
+		},
+	})
+}
+
+func (t *GitBranchCreateTool) Execute(ctx context.Context, params map[string]any, userID string) (string, error) {
+	repoID := params["repo_id"].(string)
+	name := params["name"].(string)
+
+	// Get user for permissions
+	user, err := models.Auth.GetUser(userID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if !user.IsAdmin {
+		return "", fmt.Errorf("access denied: only admins can create branches")
+	}
+
+	// Get repository
+	repo, err := models.Repositories.Get(repoID)
+	if err != nil {
+		return "", fmt.Errorf("repository not found: %s", repoID)
+	}
+
+	// Reject names git would refuse or read as an option
+	if strings.HasPrefix(name, "-") {
+		return "", fmt.Errorf("invalid branch name: %s", name)
+	}
+	if _, _, err := repo.Git("check-ref-format", "--branch", name); err != nil {
+		return "", fmt.Errorf("invalid branch name: %s", name)
+	}
+
+	from := repo.GetDefaultBranch()
+	if f, ok := params["from"].(string); ok && f != "" {
+		from = f
+	}
+	if !repo.BranchExists(from) {
+		return "", fmt.Errorf("source branch '%s' does not exist", from)
+	}
+
+	if err := repo.CreateBranch(name, from); err != nil {
+		return "", fmt.Errorf("failed to create branch: %w", err)
+	}
+
+	models.LogActivity("branch_created", fmt.Sprintf("Created branch %s", name),
+		fmt.Sprintf("AI assistant created branch %s from %s", name, from),
+		user.ID, repo.ID, "branch", name)
+
+	var result strings.Builder
+	result.WriteString(fmt.Sprintf("✅ Created branch **%s** from %s in %s and switched to it.\n\n", name, from, repo.Name))
+	result.WriteString(fmt.Sprintf("File changes now go to %s. When the work is done, open a pull request from %s into %s.\n", name, name, from))
+
+	return result.String(), nil
+}
+
+// GitCheckoutTool switches the branch the agent works on
+type GitCheckoutTool struct{}
+
+func (t *GitCheckoutTool) Name() string {
+	return "git_checkout"
+}
+
+func (t *GitCheckoutTool) Description() string {
+	return "Switch to an existing branch so following file reads and changes use it. Required params: repo_id, branch"
+}
+
+func (t *GitCheckoutTool) ValidateParams(params map[string]any) error {
+	repoID, exists := params["repo_id"]
+	if !exists {
+		return fmt.Errorf("repo_id is required")
+	}
+	if _, ok := repoID.(string); !ok {
+		return fmt.Errorf("repo_id must be a string")
+	}
+
+	branch, exists := params["branch"]
+	if !exists {
+		return fmt.Errorf("branch is required")
+	}
+	if branchStr, ok := branch.(string); !ok || branchStr == "" {
+		return fmt.Errorf("branch must be a non-empty string")
+	}
+
+	return nil
+}
+
+func (t *GitCheckoutTool) Schema() map[string]any {
+	return SimpleSchema(map[string]any{
+		"repo_id": map[string]any{
+			"type":        "string",
+			"description": "The repository ID",
+			"required":    true,
+		},
+		"branch": map[string]any{
+			"type":        "string",
+			"description": "Branch to switch to",
+			"required":    true,
+		},
+	})
+}
+
+func (t *GitCheckoutTool) Execute(ctx context.Context, params map[string]any, userID string) (string, error) {
+	repoID := params["repo_id"].(string)
+	branch := params["branch"].(string)
+
+	// Get user for permissions
+	user, err := models.Auth.GetUser(userID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get user: %w", err)
+	}
+
+	// Get repository
+	repo, err := models.Repositories.Get(repoID)
+	if err != nil {
+		return "", fmt.Errorf("repository not found: %s", repoID)
+	}
+
+	// Check permissions
+	if repo.Visibility == "private" && !user.IsAdmin {
+		return "", fmt.Errorf("access denied: repository is private")
+	}
+
+	if strings.HasPrefix(branch, "-") || !repo.BranchExists(branch) {
+		return "", fmt.Errorf("branch '%s' does not exist, create it with git_branch_create", branch)
+	}
+
+	// Repositories are bare, so switching only changes the branch the
+	// conversation works on and never moves HEAD for other users
+	defaultBranch := repo.GetDefaultBranch()
+	lastCommit, _, _ := repo.Git("log", "-1", "--format=%h %s", "refs/heads/"+branch)
+
+	var result strings.Builder
+	result.WriteString(fmt.Sprintf("✅ Switched to branch **%s** in %s\n\n", branch, repo.Name))
+	if commit := strings.TrimSpace(lastCommit.String()); commit != "" {
+		result.WriteString(fmt.Sprintf("**Latest commit:** %s\n", commit))
+	}
+	if branch != defaultBranch {
+		counts, _, err := repo.Git("rev-list", "--left-right", "--count", defaultBranch+"..."+branch)
+		if fields := strings.Fields(counts.String()); err == nil && len(fields) == 2 {
+			result.WriteString(fmt.Sprintf("**Compared to %s:** %s ahead, %s behind\n", defaultBranch, fields[1], fields[0]))
+		}
+	}
+
+	return result.String(), nil
+}
+
 // GitLogTool shows commit history
 type GitLogTool struct{}
 
@@ -623,7 +822,7 @@ func (t *GitLogTool) Schema() map[string]any {
 	})
 }
 
-func (t *GitLogTool) Execute(params map[string]any, userID string) (string, error) {
+func (t *GitLogTool) Execute(ctx context.Context, params map[string]any, userID string) (string, error) {
 	repoID := params["repo_id"].(string)
 
 	// Get user for permissions
@@ -752,7 +951,7 @@ func (t *GitPushTool) Schema() map[string]any {
 	})
 }
 
-func (t *GitPushTool) Execute(params map[string]any, userID string) (string, error) {
+func (t *GitPushTool) Execute(ctx context.Context, params map[string]any, userID string) (string, error) {
 	repoID := params["repo_id"].(string)
 
 	// Get user for permissions
@@ -805,7 +1004,7 @@ func (t *GitPushTool) Execute(params map[string]any, userID string) (string, err
 
 	// Execute in sandbox for safety
 	sandboxName := fmt.Sprintf("git-push-%s-%d", repo.ID, time.Now().Unix())
-	sandbox, err := services.NewSandbox(sandboxName, repo.Path(), repo.Name, pushCmd, 30)
+	sandbox, err := services.NewSandbox(sandboxName, repo.Path(), repo.Name, pushCmd, 30, nil)
 	if err != nil {
 		return "", fmt.Errorf("failed to create sandbox: %w", err)
 	}
@@ -875,7 +1074,7 @@ func (t *GitPullTool) Schema() map[string]any {
 	})
 }
 
-func (t *GitPullTool) Execute(params map[string]any, userID string) (string, error) {
+func (t *GitPullTool) Execute(ctx context.Context, params map[string]any, userID string) (string, error) {
 	repoID := params["repo_id"].(string)
 
 	// Get user for permissions
@@ -929,7 +1128,7 @@ func (t *GitPullTool) Execute(params map[string]any, userID string) (string, err
 
 	// Execute in sandbox
 	sandboxName := fmt.Sprintf("git-pull-%s-%d", repo.ID, time.Now().Unix())
-	sandbox, err := services.NewSandbox(sandboxName, repo.Path(), repo.Name, pullCmd, 30)
+	sandbox, err := services.NewSandbox(sandboxName, repo.Path(), repo.Name, pullCmd, 30, nil)
 	if err != nil {
 		return "", fmt.Errorf("failed to create sandbox: %w", err)
 	}
@@ -953,7 +1152,7 @@ func (t *GitPullTool) Execute(params map[string]any, userID string) (string, err
 	return fmt.Sprintf("✅ Successfully pulled from %s\n\n%s", remote, output), nil
 }
 
-// GitMergeTool merges branches or pull requests
+// GitMergeTool merges one branch into another, refusing when they conflict
 type GitMergeTool struct{}
 
 func (t *GitMergeTool) Name() string {
@@ -961,7 +1160,7 @@ func (t *GitMergeTool) Name() string {
 }
 
 func (t *GitMergeTool) Description() string {
-	return "Merge branches or pull requests. Required params: repo_id, source_branch. Optional params: target_branch (default: main), squash (bool), no_ff (bool)"
+	return "Merge a branch into another after checking for conflicts. Required params: repo_id, source_branch. Optional params: target_branch (default: the repository's default branch), message, pr_id (pull request to mark as merged)"
 }
 
 func (t *GitMergeTool) ValidateParams(params map[string]any) error {
@@ -998,18 +1197,11 @@ func (t *GitMergeTool) Schema() map[string]any {
 		},
 		"target_branch": map[string]any{
 			"type":        "string",
-			"description": "Branch to merge into (default: main)",
-			"default":     "main",
-		},
-		"squash": map[string]any{
-			"type":        "boolean",
-			"description": "Squash commits before merging",
-			"default":     false,
+			"description": "Branch to merge into (default: the repository's default branch)",
 		},
-		"no_ff": map[string]any{
-			"type":        "boolean",
-			"description": "Create merge commit even if fast-forward is possible",
-			"default":     true,
+		"message": map[string]any{
+			"type":        "string",
+			"description": "Merge commit message",
 		},
 		"pr_id": map[string]any{
 			"type":        "string",
@@ -1018,7 +1210,7 @@ func (t *GitMergeTool) Schema() map[string]any {
 	})
 }
 
-func (t *GitMergeTool) Execute(params map[string]any, userID string) (string, error) {
+func (t *GitMergeTool) Execute(ctx context.Context, params map[string]any, userID string) (string, error) {
 	repoID := params["repo_id"].(string)
 	sourceBranch := params["source_branch"].(string)
 
@@ -1039,82 +1231,86 @@ func (t *GitMergeTool) Execute(params map[string]any, userID string) (string, er
 		return "", fmt.Errorf("access denied: you don't have merge permissions")
 	}
 
-	// Get parameters
-	targetBranch := "main"
-	if t, exists := params["target_branch"]; exists {
-		if targetStr, ok := t.(string); ok && targetStr != "" {
-			targetBranch = targetStr
-		}
+	targetBranch := repo.GetDefaultBranch()
+	if t, ok := params["target_branch"].(string); ok && t != "" {
+		targetBranch = t
 	}
-
-	squash := false
-	if s, exists := params["squash"]; exists {
-		if squashBool, ok := s.(bool); ok {
-			squash = squashBool
-		}
+	if sourceBranch == targetBranch {
+		return "", fmt.Errorf("cannot merge %s into itself", sourceBranch)
 	}
-
-	noFF := true
-	if n, exists := params["no_ff"]; exists {
-		if noFFBool, ok := n.(bool); ok {
-			noFF = noFFBool
+	for _, branch := range []string{sourceBranch, targetBranch} {
+		if strings.HasPrefix(branch, "-") || !repo.BranchExists(branch) {
+			return "", fmt.Errorf("branch '%s' does not exist", branch)
 		}
 	}
 
-	// Build merge commands
-	var commands []string
-	commands = append(commands, fmt.Sprintf("git checkout %s", targetBranch))
-	commands = append(commands, "git pull origin "+targetBranch)
+	// Refuse conflicting merges and tell the agent which files clash
+	conflicts, err := repo.MergeConflicts(targetBranch, sourceBranch)
+	if err != nil {
+		return "", fmt.Errorf("failed to check for conflicts: %w", err)
+	}
+	if len(conflicts) > 0 {
+		return "", fmt.Errorf("merging %s into %s would conflict in %d file(s): %s. Nothing was merged; open a pull request so the conflicts can be resolved",
+			sourceBranch, targetBranch, len(conflicts), strings.Join(conflicts, ", "))
+	}
 
-	mergeCmd := fmt.Sprintf("git merge %s", sourceBranch)
-	if squash {
-		mergeCmd = fmt.Sprintf("%s --squash", mergeCmd)
+	// Pull requests take the same review and protection checks as merging
+	// in the browser, and branches that block pushes only take pull requests
+	var pr *models.PullRequest
+	if prID, ok := params["pr_id"].(string); ok && prID != "" {
+		if found, err := models.PullRequests.Get(prID); err == nil && found.RepoID == repo.ID {
+			pr = found
+		}
 	}
-	if noFF {
-		mergeCmd = fmt.Sprintf("%s --no-ff", mergeCmd)
+	if pr != nil && pr.BaseBranch == targetBranch && pr.CompareBranch == sourceBranch {
+		blockers, err := pr.MergeBlockers()
+		if err != nil {
+			return "", fmt.Errorf("failed to check reviews and branch protection: %w", err)
+		}
+		if len(blockers) > 0 {
+			return "", fmt.Errorf("the pull request can't merge yet: %s", strings.Join(blockers, "; "))
+		}
+	} else if models.IsPushProtected(repo.ID, targetBranch) {
+		return "", fmt.Errorf("%s is protected; open a pull request to merge %s into it", targetBranch, sourceBranch)
 	}
-	mergeCmd = fmt.Sprintf("%s -m 'Merge %s into %s (AI-automated)'", mergeCmd, sourceBranch, targetBranch)
-	commands = append(commands, mergeCmd)
 
-	// Push the merge
-	commands = append(commands, fmt.Sprintf("git push origin %s", targetBranch))
+	// The commits the merge brings in, for the issues they close
+	commits, _ := repo.GetCommitsBetween(targetBranch, sourceBranch)
 
-	// Execute in sandbox
-	fullCmd := strings.Join(commands, " && ")
-	sandboxName := fmt.Sprintf("git-merge-%s-%d", repo.ID, time.Now().Unix())
-	sandbox, err := services.NewSandbox(sandboxName, repo.Path(), repo.Name, fullCmd, 60)
-	if err != nil {
-		return "", fmt.Errorf("failed to create sandbox: %w", err)
+	message, _ := params["message"].(string)
+	if message == "" {
+		message = fmt.Sprintf("Merge branch '%s' into %s", sourceBranch, targetBranch)
 	}
-	defer sandbox.Cleanup()
-
-	// Execute the merge
-	output, exitCode, err := sandbox.Execute(fullCmd)
-	if err != nil || exitCode != 0 {
-		return "", fmt.Errorf("merge failed: %w\nOutput: %s", err, output)
+	if err := repo.MergeBranch(sourceBranch, targetBranch, message, user.Name, user.Email); err != nil {
+		return "", fmt.Errorf("merge failed: %w", err)
 	}
 
 	// If PR ID provided, mark it as merged
-	if prID, exists := params["pr_id"]; exists {
-		if prIDStr, ok := prID.(string); ok && prIDStr != "" {
-			pr, err := models.PullRequests.Get(prIDStr)
-			if err == nil {
-				pr.Status = "merged"
-				pr.MergedBy = user.ID
-				pr.MergedAt = time.Now()
-				models.PullRequests.Update(pr)
+	var closed []*models.Issue
+	if pr != nil {
+		pr.Status = "merged"
+		pr.MergedBy = user.ID
+		pr.MergedAt = time.Now()
+		models.PullRequests.Update(pr)
+		models.RunProjectRules("pr_merged", repo.ID, "", pr.ID)
+		closed = models.CloseIssuesFixedBy(pr, commits, user.ID)
+	} else {
+		models.RecordCommitReferences(repo.ID, commits, user.ID)
+		if targetBranch == repo.GetDefaultBranch() {
+			for _, commit := range commits {
+				closed = append(closed, models.CloseFixedIssues(repo.ID, "commit "+commit.ShortHash, user.ID, commit.Message)...)
 			}
 		}
 	}
-
-	// Log the activity
-	activity := &models.Activity{
-		Type:        "git_merge",
-		UserID:      user.ID,
-		RepoID:      repo.ID,
-		Description: fmt.Sprintf("Merged %s into %s", sourceBranch, targetBranch),
+	for _, issue := range closed {
+		services.EmitIssueWebhook("closed", issue, user.ID)
 	}
-	models.Activities.Insert(activity)
 
-	return fmt.Sprintf("✅ Successfully merged %s into %s\n\n%s", sourceBranch, targetBranch, output), nil
+	models.LogActivity("git_merge", fmt.Sprintf("Merged %s into %s", sourceBranch, targetBranch),
+		fmt.Sprintf("AI assistant merged %s into %s", sourceBranch, targetBranch),
+		user.ID, repo.ID, "branch", targetBranch)
+
+	commitOut, _, _ := repo.Git("log", "-1", "--format=%h %s", "refs/heads/"+targetBranch)
+	return fmt.Sprintf("✅ Successfully merged %s into %s\n\n**Head of %s:** %s\n",
+		sourceBranch, targetBranch, targetBranch, strings.TrimSpace(commitOut.String())), nil
 }
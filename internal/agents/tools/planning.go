@@ -0,0 +1,582 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"workspace/models"
+)
+
+// CreateMilestoneTool creates a milestone in a repository
+type CreateMilestoneTool struct{}
+
+func (t *CreateMilestoneTool) Name() string {
+	return "create_milestone"
+}
+
+func (t *CreateMilestoneTool) Description() string {
+	return "Create a milestone to group work in a repository toward a target date. Required params: repo_id, title. Optional params: description, due_date (YYYY-MM-DD)"
+}
+
+func (t *CreateMilestoneTool) ValidateParams(params map[string]any) error {
+	if err := requireString(params, "repo_id"); err != nil {
+		return err
+	}
+	if err := requireString(params, "title"); err != nil {
+		return err
+	}
+	if _, err := parseDueDate(params); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *CreateMilestoneTool) Schema() map[string]any {
+	return SimpleSchema(map[string]any{
+		"repo_id": map[string]any{
+			"type":        "string",
+			"description": "The repository ID",
+			"required":    true,
+		},
+		"title": map[string]any{
+			"type":        "string",
+			"description": "Milestone title, e.g. v1.2",
+			"required":    true,
+		},
+		"description": map[string]any{
+			"type":        "string",
+			"description": "What the milestone delivers",
+		},
+		"due_date": map[string]any{
+			"type":        "string",
+			"description": "Target date as YYYY-MM-DD",
+		},
+	})
+}
+
+func (t *CreateMilestoneTool) Execute(ctx context.Context, params map[string]any, userID string) (string, error) {
+	user, repo, err := planningAccess(params["repo_id"].(string), userID)
+	if err != nil {
+		return "", err
+	}
+
+	description, _ := params["description"].(string)
+	due, _ := parseDueDate(params)
+	milestone, err := models.CreateMilestone(repo.ID, params["title"].(string), description, due, user.ID)
+	if err != nil {
+		return "", fmt.Errorf("failed to create milestone: %w", err)
+	}
+
+	models.LogActivity("milestone_created", "Created milestone: "+milestone.Title,
+		"AI assistant created a milestone", user.ID, repo.ID, "milestone", milestone.ID)
+
+	var result strings.Builder
+	result.WriteString("✅ **Milestone Created**\n\n")
+	result.WriteString(fmt.Sprintf("**Milestone:** %s (ID: %s)\n", milestone.Title, milestone.ID))
+	result.WriteString(fmt.Sprintf("**Repository:** %s\n", repo.Name))
+	if milestone.HasDueDate() {
+		result.WriteString(fmt.Sprintf("**Due:** %s\n", milestone.DueDate.Format("Jan 2, 2006")))
+	}
+	if milestone.Description != "" {
+		result.WriteString(fmt.Sprintf("\n%s\n", milestone.Description))
+	}
+	return result.String(), nil
+}
+
+// ListMilestonesTool lists the milestones of a repository
+type ListMilestonesTool struct{}
+
+func (t *ListMilestonesTool) Name() string {
+	return "list_milestones"
+}
+
+func (t *ListMilestonesTool) Description() string {
+	return "List a repository's milestones, soonest due first. Required params: repo_id. Optional params: include_closed (boolean)"
+}
+
+func (t *ListMilestonesTool) ValidateParams(params map[string]any) error {
+	return requireString(params, "repo_id")
+}
+
+func (t *ListMilestonesTool) Schema() map[string]any {
+	return SimpleSchema(map[string]any{
+		"repo_id": map[string]any{
+			"type":        "string",
+			"description": "The repository ID",
+			"required":    true,
+		},
+		"include_closed": map[string]any{
+			"type":        "boolean",
+			"description": "Include closed milestones",
+		},
+	})
+}
+
+func (t *ListMilestonesTool) Execute(ctx context.Context, params map[string]any, userID string) (string, error) {
+	_, repo, err := planningAccess(params["repo_id"].(string), userID)
+	if err != nil {
+		return "", err
+	}
+
+	includeClosed, _ := params["include_closed"].(bool)
+	milestones, err := models.GetRepoMilestones(repo.ID, includeClosed)
+	if err != nil {
+		return "", fmt.Errorf("failed to list milestones: %w", err)
+	}
+
+	var result strings.Builder
+	result.WriteString(fmt.Sprintf("## Milestones in %s\n\n", repo.Name))
+	if len(milestones) == 0 {
+		result.WriteString("No milestones found. Use create_milestone to add one.\n")
+		return result.String(), nil
+	}
+
+	for _, m := range milestones {
+		icon := "🎯"
+		switch {
+		case m.IsClosed():
+			icon = "✅"
+		case m.IsOverdue():
+			icon = "⚠️"
+		}
+		result.WriteString(fmt.Sprintf("%s **%s** (ID: %s)", icon, m.Title, m.ID))
+		if m.HasDueDate() {
+			result.WriteString(fmt.Sprintf(" - due %s", m.DueDate.Format("Jan 2, 2006")))
+		}
+		if m.IsOverdue() {
+			result.WriteString(" - overdue")
+		}
+		result.WriteString("\n")
+		if m.Description != "" {
+			result.WriteString(fmt.Sprintf("   %s\n", m.Description))
+		}
+	}
+	return result.String(), nil
+}
+
+// UpdateMilestoneTool edits, closes or reopens a milestone
+type UpdateMilestoneTool struct{}
+
+func (t *UpdateMilestoneTool) Name() string {
+	return "update_milestone"
+}
+
+func (t *UpdateMilestoneTool) Description() string {
+	return "Update a milestone. Required params: milestone_id. Optional params: title, description, due_date (YYYY-MM-DD, empty to clear), status (open/closed)"
+}
+
+func (t *UpdateMilestoneTool) ValidateParams(params map[string]any) error {
+	if err := requireString(params, "milestone_id"); err != nil {
+		return err
+	}
+	if _, err := parseDueDate(params); err != nil {
+		return err
+	}
+	if status, exists := params["status"]; exists {
+		if status != models.MilestoneOpen && status != models.MilestoneClosed {
+			return fmt.Errorf("status must be open or closed")
+		}
+	}
+	return nil
+}
+
+func (t *UpdateMilestoneTool) Schema() map[string]any {
+	return SimpleSchema(map[string]any{
+		"milestone_id": map[string]any{
+			"type":        "string",
+			"description": "The milestone ID",
+			"required":    true,
+		},
+		"title": map[string]any{
+			"type":        "string",
+			"description": "New title",
+		},
+		"description": map[string]any{
+			"type":        "string",
+			"description": "New description",
+		},
+		"due_date": map[string]any{
+			"type":        "string",
+			"description": "New target date as YYYY-MM-DD, or empty to clear it",
+		},
+		"status": map[string]any{
+			"type":        "string",
+			"enum":        []string{"open", "closed"},
+			"description": "Close or reopen the milestone",
+		},
+	})
+}
+
+func (t *UpdateMilestoneTool) Execute(ctx context.Context, params map[string]any, userID string) (string, error) {
+	milestoneID := params["milestone_id"].(string)
+	milestone, err := models.Milestones.Get(milestoneID)
+	if err != nil {
+		return "", fmt.Errorf("milestone not found: %s", milestoneID)
+	}
+	user, _, err := planningAccess(milestone.RepoID, userID)
+	if err != nil {
+		return "", err
+	}
+
+	var updates []string
+	if title, _ := params["title"].(string); strings.TrimSpace(title) != "" {
+		milestone.Title = strings.TrimSpace(title)
+		updates = append(updates, "title")
+	}
+	if description, ok := params["description"].(string); ok {
+		milestone.Description = strings.TrimSpace(description)
+		updates = append(updates, "description")
+	}
+	if _, exists := params["due_date"]; exists {
+		milestone.DueDate, _ = parseDueDate(params)
+		updates = append(updates, "due date")
+	}
+	if status, ok := params["status"].(string); ok {
+		// SetStatus saves the other changes along with the status
+		if err := milestone.SetStatus(status); err != nil {
+			return "", fmt.Errorf("failed to update milestone: %w", err)
+		}
+		updates = append(updates, "status")
+	} else if err := models.Milestones.Update(milestone); err != nil {
+		return "", fmt.Errorf("failed to update milestone: %w", err)
+	}
+
+	models.LogActivity("milestone_updated", "Updated milestone: "+milestone.Title,
+		"AI assistant updated "+strings.Join(updates, ", "), user.ID, milestone.RepoID, "milestone", milestone.ID)
+
+	return fmt.Sprintf("✅ Milestone **%s** updated (%s). Status: %s\n",
+		milestone.Title, strings.Join(updates, ", "), milestone.Status), nil
+}
+
+// CreateProjectCardTool adds a card to a kanban board
+type CreateProjectCardTool struct{}
+
+func (t *CreateProjectCardTool) Name() string {
+	return "create_project_card"
+}
+
+func (t *CreateProjectCardTool) Description() string {
+	return "Add a card to a kanban board. Cards track an issue or pull request, or hold a note. Required params: repo_id. Optional params: project_id (default: the repository's first board), column (column name, default the first column), issue_id, pr_id, title (required for notes), note"
+}
+
+func (t *CreateProjectCardTool) ValidateParams(params map[string]any) error {
+	if err := requireString(params, "repo_id"); err != nil {
+		return err
+	}
+	for _, field := range []string{"project_id", "column", "issue_id", "pr_id", "title", "note"} {
+		if value, exists := params[field]; exists {
+			if _, ok := value.(string); !ok {
+				return fmt.Errorf("%s must be a string", field)
+			}
+		}
+	}
+	return nil
+}
+
+func (t *CreateProjectCardTool) Schema() map[string]any {
+	return SimpleSchema(map[string]any{
+		"repo_id": map[string]any{
+			"type":        "string",
+			"description": "The repository ID",
+			"required":    true,
+		},
+		"project_id": map[string]any{
+			"type":        "string",
+			"description": "Board to add the card to (default: the repository's first board)",
+		},
+		"column": map[string]any{
+			"type":        "string",
+			"description": "Name of the board column for the card",
+		},
+		"issue_id": map[string]any{
+			"type":        "string",
+			"description": "Issue the card tracks",
+		},
+		"pr_id": map[string]any{
+			"type":        "string",
+			"description": "Pull request the card tracks",
+		},
+		"title": map[string]any{
+			"type":        "string",
+			"description": "Card title (defaults to the issue or pull request title)",
+		},
+		"note": map[string]any{
+			"type":        "string",
+			"description": "Additional notes on the card",
+		},
+	})
+}
+
+func (t *CreateProjectCardTool) Execute(ctx context.Context, params map[string]any, userID string) (string, error) {
+	user, repo, err := planningAccess(params["repo_id"].(string), userID)
+	if err != nil {
+		return "", err
+	}
+
+	project, err := planningBoard(params, repo, user)
+	if err != nil {
+		return "", err
+	}
+
+	card := &models.ProjectCard{ProjectID: project.ID, CreatedBy: user.ID}
+	card.Column, _ = params["column"].(string)
+	card.IssueID, _ = params["issue_id"].(string)
+	card.PullRequestID, _ = params["pr_id"].(string)
+	card.Title, _ = params["title"].(string)
+	card.Note, _ = params["note"].(string)
+
+	card, err = models.CreateProjectCard(card)
+	if err != nil {
+		return "", fmt.Errorf("failed to create card: %w", err)
+	}
+
+	return fmt.Sprintf("✅ Added card **%s** (ID: %s) to the %s column of %s\n",
+		card.Title, card.ID, card.ColumnName(), project.Name), nil
+}
+
+// ListProjectCardsTool shows a kanban board
+type ListProjectCardsTool struct{}
+
+func (t *ListProjectCardsTool) Name() string {
+	return "list_project_cards"
+}
+
+func (t *ListProjectCardsTool) Description() string {
+	return "Show the cards on a kanban board grouped by column. Required params: repo_id. Optional params: project_id (default: the repository's first board), column (column name)"
+}
+
+func (t *ListProjectCardsTool) ValidateParams(params map[string]any) error {
+	return requireString(params, "repo_id")
+}
+
+func (t *ListProjectCardsTool) Schema() map[string]any {
+	return SimpleSchema(map[string]any{
+		"repo_id": map[string]any{
+			"type":        "string",
+			"description": "The repository ID",
+			"required":    true,
+		},
+		"project_id": map[string]any{
+			"type":        "string",
+			"description": "Board to show (default: the repository's first board)",
+		},
+		"column": map[string]any{
+			"type":        "string",
+			"description": "Only show the column with this name",
+		},
+	})
+}
+
+func (t *ListProjectCardsTool) Execute(ctx context.Context, params map[string]any, userID string) (string, error) {
+	user, repo, err := planningAccess(params["repo_id"].(string), userID)
+	if err != nil {
+		return "", err
+	}
+	project, err := planningBoard(params, repo, user)
+	if err != nil {
+		return "", err
+	}
+
+	columnID := ""
+	if name, _ := params["column"].(string); name != "" {
+		column, err := project.Column(name)
+		if err != nil {
+			return "", err
+		}
+		columnID = column.ID
+	}
+	cards, err := models.GetProjectCards(project.ID, columnID)
+	if err != nil {
+		return "", fmt.Errorf("failed to list cards: %w", err)
+	}
+
+	var result strings.Builder
+	result.WriteString(fmt.Sprintf("## %s (ID: %s)\n", project.Name, project.ID))
+	if len(cards) == 0 {
+		result.WriteString("\nNo cards found. Use create_project_card to add one.\n")
+		return result.String(), nil
+	}
+
+	current := ""
+	for _, card := range cards {
+		if card.Column != current {
+			current = card.Column
+			result.WriteString(fmt.Sprintf("\n### %s\n", card.ColumnName()))
+		}
+		result.WriteString(fmt.Sprintf("- **%s** (ID: %s)", card.Title, card.ID))
+		switch {
+		case card.IssueID != "":
+			result.WriteString(fmt.Sprintf(" - issue #%s", card.IssueID))
+		case card.PullRequestID != "":
+			result.WriteString(fmt.Sprintf(" - PR #%s", card.PullRequestID))
+		}
+		result.WriteString("\n")
+		if card.Note != "" {
+			result.WriteString(fmt.Sprintf("  %s\n", card.Note))
+		}
+	}
+	return result.String(), nil
+}
+
+// UpdateProjectCardTool moves or edits a kanban card
+type UpdateProjectCardTool struct{}
+
+func (t *UpdateProjectCardTool) Name() string {
+	return "update_project_card"
+}
+
+func (t *UpdateProjectCardTool) Description() string {
+	return "Move a kanban card to another column of its board or edit it. Required params: card_id. Optional params: column (column name), title, note, delete (boolean, removes the card)"
+}
+
+func (t *UpdateProjectCardTool) ValidateParams(params map[string]any) error {
+	return requireString(params, "card_id")
+}
+
+func (t *UpdateProjectCardTool) Schema() map[string]any {
+	return SimpleSchema(map[string]any{
+		"card_id": map[string]any{
+			"type":        "string",
+			"description": "The card ID",
+			"required":    true,
+		},
+		"column": map[string]any{
+			"type":        "string",
+			"description": "Name of the column to move the card to",
+		},
+		"title": map[string]any{
+			"type":        "string",
+			"description": "New title",
+		},
+		"note": map[string]any{
+			"type":        "string",
+			"description": "New notes",
+		},
+		"delete": map[string]any{
+			"type":        "boolean",
+			"description": "Remove the card from the board",
+		},
+	})
+}
+
+func (t *UpdateProjectCardTool) Execute(ctx context.Context, params map[string]any, userID string) (string, error) {
+	cardID := params["card_id"].(string)
+	card, err := models.ProjectCards.Get(cardID)
+	if err != nil {
+		return "", fmt.Errorf("card not found: %s", cardID)
+	}
+	project, err := card.Project()
+	if err != nil {
+		return "", fmt.Errorf("board not found for card %s", cardID)
+	}
+	if _, err := planningUser(userID); err != nil {
+		return "", err
+	}
+
+	if remove, _ := params["delete"].(bool); remove {
+		if err := models.ProjectCards.Delete(card); err != nil {
+			return "", fmt.Errorf("failed to delete card: %w", err)
+		}
+		return fmt.Sprintf("🗑️ Removed card **%s** from the board\n", card.Title), nil
+	}
+
+	if title, _ := params["title"].(string); strings.TrimSpace(title) != "" {
+		card.Title = strings.TrimSpace(title)
+	}
+	if note, ok := params["note"].(string); ok {
+		card.Note = note
+	}
+	if name, _ := params["column"].(string); name != "" {
+		column, err := project.Column(name)
+		if err != nil {
+			return "", err
+		}
+		if column.ID != card.Column {
+			// Move saves the other changes along with the new column
+			if err := card.Move(column.ID, ""); err != nil {
+				return "", fmt.Errorf("failed to move card: %w", err)
+			}
+			return fmt.Sprintf("✅ Card **%s** is in the %s column\n", card.Title, column.Name), nil
+		}
+	}
+	if err := models.ProjectCards.Update(card); err != nil {
+		return "", fmt.Errorf("failed to update card: %w", err)
+	}
+
+	return fmt.Sprintf("✅ Card **%s** is in the %s column\n", card.Title, card.ColumnName()), nil
+}
+
+// planningAccess loads the repository being planned and checks that the
+// user may organize its work
+func planningAccess(repoID, userID string) (*models.User, *models.Repository, error) {
+	user, err := planningUser(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	repo, err := models.Repositories.Get(repoID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("repository not found: %s", repoID)
+	}
+	return user, repo, nil
+}
+
+// planningUser checks that the user may organize work
+func planningUser(userID string) (*models.User, error) {
+	user, err := models.Auth.GetUser(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if !user.IsAdmin {
+		return nil, fmt.Errorf("access denied: only admins can manage milestones and project boards")
+	}
+	return user, nil
+}
+
+// planningBoard loads the board named by the project_id parameter, or the
+// repository's first board
+func planningBoard(params map[string]any, repo *models.Repository, user *models.User) (*models.Project, error) {
+	projectID, _ := params["project_id"].(string)
+	if projectID == "" {
+		return models.RepoBoard(repo.ID, user.ID)
+	}
+
+	project, err := models.Projects.Get(projectID)
+	if err != nil || (project.RepoID != "" && project.RepoID != repo.ID) {
+		return nil, fmt.Errorf("board not found: %s", projectID)
+	}
+	return project, nil
+}
+
+// requireString checks that a required parameter is a non-empty string
+func requireString(params map[string]any, name string) error {
+	value, exists := params[name]
+	if !exists {
+		return fmt.Errorf("%s is required", name)
+	}
+	if s, ok := value.(string); !ok || s == "" {
+		return fmt.Errorf("%s must be a non-empty string", name)
+	}
+	return nil
+}
+
+// parseDueDate reads the optional due_date parameter
+func parseDueDate(params map[string]any) (time.Time, error) {
+	value, exists := params["due_date"]
+	if !exists {
+		return time.Time{}, nil
+	}
+	due, ok := value.(string)
+	if !ok {
+		return time.Time{}, fmt.Errorf("due_date must be a string")
+	}
+	if due == "" {
+		return time.Time{}, nil
+	}
+	date, err := time.ParseInLocation("2006-01-02", due, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("due_date must be formatted YYYY-MM-DD")
+	}
+	return date, nil
+}
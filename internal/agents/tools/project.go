@@ -1,9 +1,12 @@
 package tools
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"time"
+	"workspace/internal/ai"
+	"workspace/internal/ai/queue"
 	"workspace/models"
 )
 
@@ -73,7 +76,7 @@ func (t *CreateIssueTool) Schema() map[string]any {
 	})
 }
 
-func (t *CreateIssueTool) Execute(params map[string]any, userID string) (string, error) {
+func (t *CreateIssueTool) Execute(ctx context.Context, params map[string]any, userID string) (string, error) {
 	repoID := params["repo_id"].(string)
 	title := params["title"].(string)
 	body := params["body"].(string)
@@ -127,16 +130,14 @@ func (t *CreateIssueTool) Execute(params map[string]any, userID string) (string,
 		return "", fmt.Errorf("failed to create issue: %w", err)
 	}
 
-	// Add tags using IssueTag model
-	for _, tag := range tagsList {
-		if tag != "" {
-			err := models.AddTagToIssue(issue.ID, tag)
-			if err != nil {
-				// Log but don't fail
-				fmt.Printf("Warning: failed to add tag %s: %v\n", tag, err)
-			}
-		}
+	// Apply tags as labels, creating those the repository lacks
+	if err := models.SetIssueLabels(issue, tagsList, user.ID); err != nil {
+		// Log but don't fail
+		fmt.Printf("Warning: failed to add labels: %v\n", err)
 	}
+	models.RecordReferences(repoID, models.RefIssue, issue.ID, issue.Title+"\n"+issue.Body, user.ID)
+	models.RecordMentions(repoID, models.RefIssue, issue.ID, issue.Title+"\n"+issue.Body, user.ID)
+	models.RunProjectRules("issue_opened", repoID, issue.ID, "")
 
 	// Continue with the response
 	issue, err = models.Issues.Get(issue.ID)
@@ -199,7 +200,7 @@ func (t *ListIssuesTool) Schema() map[string]any {
 	})
 }
 
-func (t *ListIssuesTool) Execute(params map[string]any, userID string) (string, error) {
+func (t *ListIssuesTool) Execute(ctx context.Context, params map[string]any, userID string) (string, error) {
 	repoID := params["repo_id"].(string)
 
 	// Get user for permissions
@@ -285,11 +286,9 @@ func (t *ListIssuesTool) Execute(params map[string]any, userID string) (string,
 				author,
 				issue.CreatedAt.Format("Jan 2, 2006")))
 
-			// Show tags if any
-			// Get tags from IssueTag model
-			tags, _ := models.GetIssueTags(issue.ID)
-			if len(tags) > 0 {
-				result.WriteString(fmt.Sprintf("   Tags: %s\n", strings.Join(tags, ", ")))
+			// Show labels if any
+			if labels := issue.LabelNames(); labels != "" {
+				result.WriteString(fmt.Sprintf("   Labels: %s\n", labels))
 			}
 
 			// Show truncated body
@@ -370,7 +369,7 @@ func (t *UpdateIssueTool) Schema() map[string]any {
 	})
 }
 
-func (t *UpdateIssueTool) Execute(params map[string]any, userID string) (string, error) {
+func (t *UpdateIssueTool) Execute(ctx context.Context, params map[string]any, userID string) (string, error) {
 	issueID := params["issue_id"].(string)
 
 	// Get user for permissions
@@ -428,22 +427,22 @@ func (t *UpdateIssueTool) Execute(params map[string]any, userID string) (string,
 	}
 
 	if tags, exists := params["tags"]; exists {
+		var strTags []string
 		switch v := tags.(type) {
 		case []any:
-			strTags := make([]string, len(v))
-			for i, tag := range v {
+			for _, tag := range v {
 				if tagStr, ok := tag.(string); ok {
-					strTags[i] = tagStr
+					strTags = append(strTags, tagStr)
 				}
 			}
-			// Tags will be handled separately with IssueTag model
-			// TODO: Clear existing tags and add new ones
-			updates = append(updates, "tags")
 		case []string:
-			// Tags will be handled separately with IssueTag model
-			// TODO: Clear existing tags and add new ones
-			updates = append(updates, "tags")
+			strTags = v
+		}
+		// Tags replace the issue's labels
+		if err := models.SetIssueLabels(issue, strTags, user.ID); err != nil {
+			return "", fmt.Errorf("failed to update labels: %w", err)
 		}
+		updates = append(updates, "tags")
 	}
 
 	// Save updates
@@ -452,6 +451,8 @@ func (t *UpdateIssueTool) Execute(params map[string]any, userID string) (string,
 	if err != nil {
 		return "", fmt.Errorf("failed to update issue: %w", err)
 	}
+	models.RecordReferences(issue.RepoID, models.RefIssue, issue.ID, issue.Title+"\n"+issue.Body, user.ID)
+	models.RecordMentions(issue.RepoID, models.RefIssue, issue.ID, issue.Title+"\n"+issue.Body, user.ID)
 
 	// Get repository for response
 	repo, _ := models.Repositories.Get(issue.RepoID)
@@ -532,7 +533,7 @@ func (t *CreatePRTool) Schema() map[string]any {
 	})
 }
 
-func (t *CreatePRTool) Execute(params map[string]any, userID string) (string, error) {
+func (t *CreatePRTool) Execute(ctx context.Context, params map[string]any, userID string) (string, error) {
 	repoID := params["repo_id"].(string)
 	title := params["title"].(string)
 	body := params["body"].(string)
@@ -602,6 +603,9 @@ func (t *CreatePRTool) Execute(params map[string]any, userID string) (string, er
 	if err != nil {
 		return "", fmt.Errorf("failed to create pull request: %w", err)
 	}
+	models.RecordReferences(repo.ID, models.RefPullRequest, pr.ID, pr.Title+"\n"+pr.Body, user.ID)
+	models.RecordMentions(repo.ID, models.RefPullRequest, pr.ID, pr.Title+"\n"+pr.Body, user.ID)
+	models.RunProjectRules("pr_opened", repo.ID, "", pr.ID)
 
 	// Get commit count
 	commitCount := len(strings.Split(strings.TrimSpace(stdout.String()), "\n"))
@@ -620,6 +624,235 @@ func (t *CreatePRTool) Execute(params map[string]any, userID string) (string, er
 	return result.String(), nil
 }
 
+// CreatePullRequestTool opens a pull request from the branch the agent
+// worked on, so autonomous changes end up reviewable instead of merged
+type CreatePullRequestTool struct{}
+
+func (t *CreatePullRequestTool) Name() string {
+	return "create_pull_request"
+}
+
+func (t *CreatePullRequestTool) Description() string {
+	return "Open a pull request from a source branch into the default branch once work on a branch is committed. Title and description are filled in from the conversation and commits when omitted. Required params: repo_id. Optional params: source_branch (defaults to the branch this conversation is working on), target_branch, title, description, request_review (queue an AI review, default true)"
+}
+
+func (t *CreatePullRequestTool) ValidateParams(params map[string]any) error {
+	repoID, exists := params["repo_id"]
+	if !exists {
+		return fmt.Errorf("repo_id is required")
+	}
+	if _, ok := repoID.(string); !ok {
+		return fmt.Errorf("repo_id must be a string")
+	}
+	for _, field := range []string{"source_branch", "target_branch", "title", "description"} {
+		if value, exists := params[field]; exists {
+			if _, ok := value.(string); !ok {
+				return fmt.Errorf("%s must be a string", field)
+			}
+		}
+	}
+	if value, exists := params["request_review"]; exists {
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("request_review must be a boolean")
+		}
+	}
+	return nil
+}
+
+func (t *CreatePullRequestTool) Schema() map[string]any {
+	return SimpleSchema(map[string]any{
+		"repo_id": map[string]any{
+			"type":        "string",
+			"description": "The repository ID",
+			"required":    true,
+		},
+		"source_branch": map[string]any{
+			"type":        "string",
+			"description": "Branch with the changes (defaults to the conversation's working branch)",
+		},
+		"target_branch": map[string]any{
+			"type":        "string",
+			"description": "Branch to merge into (defaults to the repository's default branch)",
+		},
+		"title": map[string]any{
+			"type":        "string",
+			"description": "Pull request title",
+		},
+		"description": map[string]any{
+			"type":        "string",
+			"description": "Pull request description in markdown",
+		},
+		"request_review": map[string]any{
+			"type":        "boolean",
+			"description": "Queue an AI review of the pull request (default true)",
+		},
+	})
+}
+
+func (t *CreatePullRequestTool) Execute(ctx context.Context, params map[string]any, userID string) (string, error) {
+	repoID := params["repo_id"].(string)
+
+	user, err := models.Auth.GetUser(userID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get user: %w", err)
+	}
+	if !user.IsAdmin {
+		return "", fmt.Errorf("access denied: only admins can create pull requests")
+	}
+
+	repo, err := models.Repositories.Get(repoID)
+	if err != nil {
+		return "", fmt.Errorf("repository not found: %s", repoID)
+	}
+
+	// The conversation supplies the working branch and the request behind it
+	var conversation *models.Conversation
+	if id, _ := params["_conversation_id"].(string); id != "" {
+		conversation, _ = models.Conversations.Get(id)
+	}
+
+	source, _ := params["source_branch"].(string)
+	if source == "" && conversation != nil {
+		working := conversation.GetWorkingContext()
+		if working["current_repo_id"] == repo.ID {
+			source, _ = working["current_branch"].(string)
+		}
+	}
+	if source == "" {
+		return "", fmt.Errorf("source_branch is required when the conversation has not switched to a branch")
+	}
+
+	target, _ := params["target_branch"].(string)
+	if target == "" {
+		target = repo.GetDefaultBranch()
+	}
+	if source == target {
+		return "", fmt.Errorf("source and target are both %s; create a branch with git_branch_create and commit the changes there first", source)
+	}
+
+	// Don't open a second PR for the same branches
+	existing, err := models.PullRequests.Search("WHERE RepoID = ? AND CompareBranch = ? AND BaseBranch = ? AND Status NOT IN ('merged', 'closed')", repo.ID, source, target)
+	if err == nil && len(existing) > 0 {
+		return fmt.Sprintf("ℹ️ Pull request #%s already tracks %s → %s: %s\n", existing[0].ID, source, target, existing[0].Title), nil
+	}
+
+	diff, err := repo.GetPRDiff(target, source)
+	if err != nil {
+		return "", fmt.Errorf("failed to compare %s with %s: %w", source, target, err)
+	}
+	if len(diff.Commits) == 0 {
+		return "", fmt.Errorf("%s has no commits that are not already in %s", source, target)
+	}
+
+	title, _ := params["title"].(string)
+	if title = strings.TrimSpace(title); title == "" {
+		title = pullRequestTitle(conversation, diff.Commits, source)
+	}
+	description, _ := params["description"].(string)
+	if description = strings.TrimSpace(description); description == "" {
+		description = pullRequestDescription(conversation, diff.Commits)
+	}
+
+	pr, err := models.PullRequests.Insert(&models.PullRequest{
+		Title:         title,
+		Body:          description,
+		RepoID:        repo.ID,
+		AuthorID:      user.ID,
+		BaseBranch:    target,
+		CompareBranch: source,
+		Status:        "open",
+		SyncStatus:    "local_only",
+		Additions:     diff.Additions,
+		Deletions:     diff.Deletions,
+		ChangedFiles:  len(diff.Files),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create pull request: %w", err)
+	}
+	models.RecordReferences(repo.ID, models.RefPullRequest, pr.ID, pr.Title+"\n"+pr.Body, user.ID)
+	models.RecordMentions(repo.ID, models.RefPullRequest, pr.ID, pr.Title+"\n"+pr.Body, user.ID)
+	models.RunProjectRules("pr_opened", repo.ID, "", pr.ID)
+
+	models.LogActivity("pr_created", "Created pull request: "+pr.Title,
+		fmt.Sprintf("AI assistant opened a pull request from %s into %s", source, target),
+		user.ID, repo.ID, "pull_request", pr.ID)
+
+	var result strings.Builder
+	result.WriteString("✅ **Pull Request Created**\n\n")
+	result.WriteString(fmt.Sprintf("**PR #%s:** %s\n", pr.ID, pr.Title))
+	result.WriteString(fmt.Sprintf("**Branches:** %s → %s\n", source, target))
+	result.WriteString(fmt.Sprintf("**Changes:** %d commit(s), %d file(s), +%d -%d\n",
+		len(diff.Commits), len(diff.Files), diff.Additions, diff.Deletions))
+	if diff.HasConflicts {
+		result.WriteString("⚠️ The branches conflict and must be reconciled before merging\n")
+	}
+
+	if review, ok := params["request_review"].(bool); !ok || review {
+		if err := queuePullRequestReview(pr, user.ID); err != nil {
+			result.WriteString(fmt.Sprintf("**Review:** not queued (%v)\n", err))
+		} else {
+			result.WriteString("**Review:** AI review queued\n")
+		}
+	}
+
+	return result.String(), nil
+}
+
+// pullRequestTitle names a PR after its only commit, or after the
+// conversation that produced it
+func pullRequestTitle(conversation *models.Conversation, commits []*models.Commit, source string) string {
+	if len(commits) == 1 {
+		return commits[0].Message
+	}
+	if conversation != nil && conversation.Title != "" {
+		return conversation.Title
+	}
+	return fmt.Sprintf("Changes from %s", source)
+}
+
+// pullRequestDescription summarises the request behind a PR and its commits
+func pullRequestDescription(conversation *models.Conversation, commits []*models.Commit) string {
+	var body strings.Builder
+
+	if conversation != nil {
+		if messages, err := conversation.GetMessages(); err == nil {
+			for i := len(messages) - 1; i >= 0; i-- {
+				if messages[i].Role == "user" {
+					body.WriteString("## Request\n\n")
+					body.WriteString(strings.TrimSpace(messages[i].Content))
+					body.WriteString("\n\n")
+					break
+				}
+			}
+		}
+	}
+
+	body.WriteString("## Commits\n\n")
+	for _, commit := range commits {
+		body.WriteString(fmt.Sprintf("- %s %s\n", commit.ShortHash, commit.Message))
+	}
+	body.WriteString("\n_Opened by the AI assistant._\n")
+	return body.String()
+}
+
+// queuePullRequestReview puts a review of the PR on the AI queue
+func queuePullRequestReview(pr *models.PullRequest, userID string) error {
+	if ai.Instance == nil || ai.Instance.Queue == nil {
+		return fmt.Errorf("the AI queue is not running")
+	}
+	return ai.Instance.Queue.Enqueue(&queue.Task{
+		Type:       queue.TaskPRReview,
+		Priority:   queue.PriorityMedium,
+		RepoID:     pr.RepoID,
+		UserID:     userID,
+		EntityType: "pull_request",
+		EntityID:   pr.ID,
+		Data: map[string]any{
+			"pr_id": pr.ID,
+		},
+	})
+}
+
 // ListPRsTool lists pull requests in a repository
 type ListPRsTool struct{}
 
@@ -658,7 +891,7 @@ func (t *ListPRsTool) Schema() map[string]any {
 	})
 }
 
-func (t *ListPRsTool) Execute(params map[string]any, userID string) (string, error) {
+func (t *ListPRsTool) Execute(ctx context.Context, params map[string]any, userID string) (string, error) {
 	repoID := params["repo_id"].(string)
 
 	// Get user for permissions
@@ -1,9 +1,15 @@
 package tools
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"strings"
+	"sync"
+	"time"
 	"workspace/models"
+	"workspace/services"
 )
 
 // ListReposTool lists repositories
@@ -46,7 +52,7 @@ func (t *ListReposTool) Schema() map[string]any {
 	}
 }
 
-func (t *ListReposTool) Execute(params map[string]any, userID string) (string, error) {
+func (t *ListReposTool) Execute(ctx context.Context, params map[string]any, userID string) (string, error) {
 	// Get user to check admin status
 	user, err := models.Auth.GetUser(userID)
 	if err != nil {
@@ -135,7 +141,7 @@ func (t *GetRepoTool) Schema() map[string]any {
 	}
 }
 
-func (t *GetRepoTool) Execute(params map[string]any, userID string) (string, error) {
+func (t *GetRepoTool) Execute(ctx context.Context, params map[string]any, userID string) (string, error) {
 	repoID := params["repo_id"].(string)
 
 	// Get user to check permissions
@@ -229,7 +235,7 @@ func (t *CreateRepoTool) Schema() map[string]any {
 	}
 }
 
-func (t *CreateRepoTool) Execute(params map[string]any, userID string) (string, error) {
+func (t *CreateRepoTool) Execute(ctx context.Context, params map[string]any, userID string) (string, error) {
 	// Get user to check admin status
 	user, err := models.Auth.GetUser(userID)
 	if err != nil {
@@ -336,7 +342,7 @@ func (t *GetRepoLinkTool) Schema() map[string]any {
 	}
 }
 
-func (t *GetRepoLinkTool) Execute(params map[string]any, userID string) (string, error) {
+func (t *GetRepoLinkTool) Execute(ctx context.Context, params map[string]any, userID string) (string, error) {
 	repoID := params["repo_id"].(string)
 
 	// Get user to check permissions
@@ -395,3 +401,178 @@ func (t *GetRepoLinkTool) Execute(params map[string]any, userID string) (string,
 
 	return result, nil
 }
+
+// deleteConfirmationTTL is how long a delete_repo confirmation token stays valid
+const deleteConfirmationTTL = 5 * time.Minute
+
+// pendingDeletion is a repository deletion waiting to be confirmed
+type pendingDeletion struct {
+	repoID    string
+	userID    string
+	expiresAt time.Time
+}
+
+var (
+	pendingDeletions   = map[string]pendingDeletion{}
+	pendingDeletionsMu sync.Mutex
+)
+
+// DeleteRepoTool deletes a repository in two steps: the first call returns
+// a summary and a confirmation token, the second call with that token deletes
+type DeleteRepoTool struct{}
+
+func (t *DeleteRepoTool) Name() string {
+	return "delete_repo"
+}
+
+func (t *DeleteRepoTool) Description() string {
+	return "Permanently delete a repository. Required params: repo_id. Call without confirmation_token first to get a summary and token, show the summary to the user, and only call again with the token once the user explicitly confirms"
+}
+
+func (t *DeleteRepoTool) ValidateParams(params map[string]any) error {
+	repoID, exists := params["repo_id"]
+	if !exists {
+		return fmt.Errorf("repo_id is required")
+	}
+
+	if _, ok := repoID.(string); !ok {
+		return fmt.Errorf("repo_id must be a string")
+	}
+
+	if token, exists := params["confirmation_token"]; exists {
+		if _, ok := token.(string); !ok {
+			return fmt.Errorf("confirmation_token must be a string")
+		}
+	}
+
+	return nil
+}
+
+func (t *DeleteRepoTool) Schema() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"repo_id": map[string]any{
+				"type":        "string",
+				"description": "The ID of the repository to delete",
+				"required":    true,
+			},
+			"confirmation_token": map[string]any{
+				"type":        "string",
+				"description": "Token returned by the first call, only pass it after the user confirmed the deletion",
+			},
+		},
+		"required": []string{"repo_id"},
+	}
+}
+
+func (t *DeleteRepoTool) Execute(ctx context.Context, params map[string]any, userID string) (string, error) {
+	// Get user to check admin status
+	user, err := models.Auth.GetUser(userID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get user: %w", err)
+	}
+
+	// Only admins can delete repositories
+	if !user.IsAdmin {
+		return "", fmt.Errorf("only administrators can delete repositories")
+	}
+
+	repoID := params["repo_id"].(string)
+	repo, err := models.Repositories.Get(repoID)
+	if err != nil {
+		return "", fmt.Errorf("repository not found: %s", repoID)
+	}
+
+	token, _ := params["confirmation_token"].(string)
+	if token == "" {
+		return t.requestConfirmation(repo, userID)
+	}
+
+	if !consumeDeletionToken(token, repo.ID, userID) {
+		models.LogActivity("repo_delete_rejected", fmt.Sprintf("Rejected deletion of %s", repo.Name),
+			"AI assistant used an invalid or expired confirmation token",
+			userID, repo.ID, "repository", repo.ID)
+		return "", fmt.Errorf("invalid or expired confirmation token, call delete_repo without a token to start over")
+	}
+
+	if err := services.DeleteRepository(repo); err != nil {
+		return "", fmt.Errorf("failed to delete repository: %w", err)
+	}
+
+	models.LogActivity("repo_deleted", fmt.Sprintf("Deleted repository %s", repo.Name),
+		fmt.Sprintf("Repository %s was deleted by the AI assistant after confirmation", repo.Name),
+		userID, "", "repository", "")
+
+	return fmt.Sprintf("🗑️ Repository **%s** (ID: %s) has been permanently deleted.\n", repo.Name, repo.ID), nil
+}
+
+// requestConfirmation issues a confirmation token and describes what a
+// deletion would remove
+func (t *DeleteRepoTool) requestConfirmation(repo *models.Repository, userID string) (string, error) {
+	token, err := issueDeletionToken(repo.ID, userID)
+	if err != nil {
+		return "", fmt.Errorf("failed to create confirmation token: %w", err)
+	}
+
+	models.LogActivity("repo_delete_requested", fmt.Sprintf("Requested deletion of %s", repo.Name),
+		"AI assistant asked for confirmation to delete this repository",
+		userID, repo.ID, "repository", repo.ID)
+
+	var result strings.Builder
+	result.WriteString(fmt.Sprintf("⚠️ Deleting repository **%s** (ID: %s) cannot be undone. It will remove:\n\n", repo.Name, repo.ID))
+	if count, err := repo.GetCommitCount("HEAD"); err == nil {
+		result.WriteString(fmt.Sprintf("- %d commits\n", count))
+	}
+	if size, err := repo.GetSize(); err == nil {
+		result.WriteString(fmt.Sprintf("- %.1f MB of git data\n", float64(size)/(1024*1024)))
+	}
+	result.WriteString(fmt.Sprintf("- %d issues\n", models.Issues.Count("WHERE RepoID = ?", repo.ID)))
+	result.WriteString(fmt.Sprintf("- %d pull requests\n", models.PullRequests.Count("WHERE RepoID = ?", repo.ID)))
+	result.WriteString("- All permissions and access tokens for the repository\n")
+	result.WriteString(fmt.Sprintf("\nConfirmation token: `%s` (valid for %d minutes)\n", token, int(deleteConfirmationTTL.Minutes())))
+	result.WriteString("Show this summary to the user. Only if they explicitly confirm, call delete_repo again with the same repo_id and this confirmation_token.\n")
+
+	return result.String(), nil
+}
+
+// issueDeletionToken records a pending deletion and returns its token
+func issueDeletionToken(repoID, userID string) (string, error) {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(bytes)
+
+	pendingDeletionsMu.Lock()
+	defer pendingDeletionsMu.Unlock()
+
+	now := time.Now()
+	for t, pending := range pendingDeletions {
+		if now.After(pending.expiresAt) {
+			delete(pendingDeletions, t)
+		}
+	}
+	pendingDeletions[token] = pendingDeletion{
+		repoID:    repoID,
+		userID:    userID,
+		expiresAt: now.Add(deleteConfirmationTTL),
+	}
+
+	return token, nil
+}
+
+// consumeDeletionToken reports whether the token confirms deleting the
+// repository for this user. Tokens are single use.
+func consumeDeletionToken(token, repoID, userID string) bool {
+	pendingDeletionsMu.Lock()
+	defer pendingDeletionsMu.Unlock()
+
+	pending, exists := pendingDeletions[token]
+	if !exists {
+		return false
+	}
+	delete(pendingDeletions, token)
+
+	return pending.repoID == repoID && pending.userID == userID && time.Now().Before(pending.expiresAt)
+}
@@ -0,0 +1,121 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"workspace/models"
+	"workspace/services"
+)
+
+// SemanticSearchTool finds code by meaning using the repository's
+// embeddings index
+type SemanticSearchTool struct{}
+
+func (t *SemanticSearchTool) Name() string {
+	return "semantic_search"
+}
+
+func (t *SemanticSearchTool) Description() string {
+	return "Find code by what it does rather than exact text, e.g. 'where are sessions validated'. Required params: repo_id, query. Optional params: limit (default 5)"
+}
+
+func (t *SemanticSearchTool) ValidateParams(params map[string]any) error {
+	repoID, exists := params["repo_id"]
+	if !exists {
+		return fmt.Errorf("repo_id is required")
+	}
+
+	if _, ok := repoID.(string); !ok {
+		return fmt.Errorf("repo_id must be a string")
+	}
+
+	query, exists := params["query"]
+	if !exists {
+		return fmt.Errorf("query is required")
+	}
+
+	if q, ok := query.(string); !ok || strings.TrimSpace(q) == "" {
+		return fmt.Errorf("query must be a non-empty string")
+	}
+
+	if limit, exists := params["limit"]; exists {
+		if _, ok := limit.(float64); !ok {
+			return fmt.Errorf("limit must be a number")
+		}
+	}
+
+	return nil
+}
+
+func (t *SemanticSearchTool) Schema() map[string]any {
+	return SimpleSchema(map[string]any{
+		"repo_id": map[string]any{
+			"type":        "string",
+			"description": "The repository ID",
+			"required":    true,
+		},
+		"query": map[string]any{
+			"type":        "string",
+			"description": "Natural-language description of the code to find",
+			"required":    true,
+		},
+		"limit": map[string]any{
+			"type":        "number",
+			"description": "Maximum number of snippets to return (1-20)",
+			"default":     5,
+		},
+	})
+}
+
+func (t *SemanticSearchTool) Execute(ctx context.Context, params map[string]any, userID string) (string, error) {
+	repoID := params["repo_id"].(string)
+	query := params["query"].(string)
+
+	limit := 5
+	if l, ok := params["limit"].(float64); ok {
+		limit = min(max(int(l), 1), 20)
+	}
+
+	// Get user to check permissions
+	user, err := models.Auth.GetUser(userID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get user: %w", err)
+	}
+
+	// Get repository
+	repo, err := models.Repositories.Get(repoID)
+	if err != nil {
+		return "", fmt.Errorf("repository not found: %s", repoID)
+	}
+
+	// Check permissions
+	if repo.Visibility == "private" && !user.IsAdmin {
+		return "", fmt.Errorf("access denied: repository is private")
+	}
+
+	results, err := services.Semantic.Search(ctx, repo, query, limit)
+	if err != nil {
+		// Start indexing so the next search can succeed
+		if models.GetSemanticIndex(repo.ID) == nil {
+			services.Semantic.IndexAsync(repo.ID)
+		}
+		return "", fmt.Errorf("semantic search unavailable: %w", err)
+	}
+
+	var result strings.Builder
+	result.WriteString(fmt.Sprintf("## Semantic Search for '%s' in %s\n\n", query, repo.Name))
+
+	if len(results) == 0 {
+		result.WriteString("No indexed code matched the query.\n")
+		return result.String(), nil
+	}
+
+	for _, r := range results {
+		result.WriteString(fmt.Sprintf("### %s (lines %d-%d, score %.2f)\n", r.Path, r.StartLine, r.EndLine, r.Score))
+		result.WriteString(fmt.Sprintf("```%s\n%s\n```\n\n", strings.ToLower(r.Language()), r.Content))
+	}
+	result.WriteString("💡 *Use read_file to see the surrounding code.*")
+
+	return result.String(), nil
+}
@@ -1,6 +1,8 @@
 package tools
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"path/filepath"
 	"strings"
@@ -17,7 +19,7 @@ func (t *RunCommandTool) Name() string {
 }
 
 func (t *RunCommandTool) Description() string {
-	return "Execute a shell command in a sandboxed environment. Required params: command. Optional params: repo_id, working_dir, timeout_seconds"
+	return "Execute a shell command in a sandboxed environment. Commands run against a repository are limited by its sandbox policy. Required params: command. Optional params: repo_id, working_dir, timeout_seconds"
 }
 
 func (t *RunCommandTool) ValidateParams(params map[string]any) error {
@@ -65,7 +67,7 @@ func (t *RunCommandTool) Schema() map[string]any {
 	})
 }
 
-func (t *RunCommandTool) Execute(params map[string]any, userID string) (string, error) {
+func (t *RunCommandTool) Execute(ctx context.Context, params map[string]any, userID string) (string, error) {
 	// Get user to check permissions
 	user, err := models.Auth.GetUser(userID)
 	if err != nil {
@@ -81,6 +83,7 @@ func (t *RunCommandTool) Execute(params map[string]any, userID string) (string,
 	// Get optional parameters
 	var repoID, workingDir string
 	var repoPath string
+	var policy *models.SandboxPolicy
 
 	if rid, exists := params["repo_id"]; exists && rid != nil {
 		repoID = rid.(string)
@@ -90,6 +93,7 @@ func (t *RunCommandTool) Execute(params map[string]any, userID string) (string,
 			return "", fmt.Errorf("repository not found: %s", repoID)
 		}
 		repoPath = repo.Path()
+		policy = models.GetSandboxPolicy(repo.ID)
 	}
 
 	if wd, exists := params["working_dir"]; exists && wd != nil {
@@ -99,6 +103,9 @@ func (t *RunCommandTool) Execute(params map[string]any, userID string) (string,
 	}
 
 	timeout := 30
+	if policy != nil && policy.MaxTimeoutSecs < timeout {
+		timeout = policy.MaxTimeoutSecs
+	}
 	if t, exists := params["timeout_seconds"]; exists && t != nil {
 		if tFloat, ok := t.(float64); ok {
 			timeout = int(tFloat)
@@ -117,7 +124,12 @@ func (t *RunCommandTool) Execute(params map[string]any, userID string) (string,
 		repoID,
 		wrappedCommand,
 		timeout,
+		policy,
 	)
+	var violation *services.SandboxViolation
+	if errors.As(err, &violation) {
+		return "", violation
+	}
 	if err != nil {
 		return "", fmt.Errorf("failed to create sandbox: %w", err)
 	}
@@ -127,13 +139,19 @@ func (t *RunCommandTool) Execute(params map[string]any, userID string) (string,
 		return "", fmt.Errorf("failed to start sandbox: %w", err)
 	}
 
-	// Wait for completion (up to timeout)
+	// Wait for completion (up to timeout), stopping early if the caller cancels
 	startTime := time.Now()
 	for time.Since(startTime) < time.Duration(timeout+5)*time.Second {
 		if !sandbox.IsRunning() {
 			break
 		}
-		time.Sleep(500 * time.Millisecond)
+		select {
+		case <-ctx.Done():
+			sandbox.Stop()
+			sandbox.Cleanup()
+			return "", ctx.Err()
+		case <-time.After(500 * time.Millisecond):
+		}
 	}
 
 	// Get output
@@ -145,6 +163,12 @@ func (t *RunCommandTool) Execute(params map[string]any, userID string) (string,
 	// Get exit code
 	exitCode := sandbox.GetExitCode()
 
+	// Check the finished command against the repository's policy
+	if err := sandbox.Violation(); err != nil {
+		sandbox.Cleanup()
+		return "", err
+	}
+
 	// Clean up sandbox
 	sandbox.Cleanup()
 
@@ -205,7 +229,7 @@ func (t *GetWorkingDirectoryTool) Schema() map[string]any {
 	})
 }
 
-func (t *GetWorkingDirectoryTool) Execute(params map[string]any, userID string) (string, error) {
+func (t *GetWorkingDirectoryTool) Execute(ctx context.Context, params map[string]any, userID string) (string, error) {
 	repoID := params["repo_id"].(string)
 
 	// Get user to check permissions
@@ -302,7 +326,7 @@ func (t *InstallPackageTool) Schema() map[string]any {
 	})
 }
 
-func (t *InstallPackageTool) Execute(params map[string]any, userID string) (string, error) {
+func (t *InstallPackageTool) Execute(ctx context.Context, params map[string]any, userID string) (string, error) {
 	// Get user to check permissions
 	user, err := models.Auth.GetUser(userID)
 	if err != nil {
@@ -359,7 +383,7 @@ func (t *InstallPackageTool) Execute(params map[string]any, userID string) (stri
 
 	// Execute using RunCommandTool
 	runCmd := &RunCommandTool{}
-	result, err := runCmd.Execute(map[string]any{
+	result, err := runCmd.Execute(ctx, map[string]any{
 		"command":         command,
 		"repo_id":         params["repo_id"],
 		"timeout_seconds": 120, // 2 minutes for package installation
@@ -397,7 +421,7 @@ func (t *ListProcessesTool) Schema() map[string]any {
 	})
 }
 
-func (t *ListProcessesTool) Execute(params map[string]any, userID string) (string, error) {
+func (t *ListProcessesTool) Execute(ctx context.Context, params map[string]any, userID string) (string, error) {
 	// Get user to check permissions
 	user, err := models.Auth.GetUser(userID)
 	if err != nil {
@@ -415,7 +439,7 @@ func (t *ListProcessesTool) Execute(params map[string]any, userID string) (strin
 
 	// Execute using RunCommandTool
 	runCmd := &RunCommandTool{}
-	result, err := runCmd.Execute(map[string]any{
+	result, err := runCmd.Execute(ctx, map[string]any{
 		"command":         command,
 		"timeout_seconds": 10,
 	}, userID)
@@ -1,6 +1,7 @@
 package tools
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"workspace/models"
@@ -29,7 +30,7 @@ func (t *TodoListTool) Schema() map[string]any {
 	}
 }
 
-func (t *TodoListTool) Execute(params map[string]any, userID string) (string, error) {
+func (t *TodoListTool) Execute(ctx context.Context, params map[string]any, userID string) (string, error) {
 	// Get conversation ID from params (will be injected by controller)
 	conversationIDVal, exists := params["_conversation_id"]
 	if !exists {
@@ -196,7 +197,7 @@ func (t *TodoUpdateTool) Schema() map[string]any {
 	}
 }
 
-func (t *TodoUpdateTool) Execute(params map[string]any, userID string) (string, error) {
+func (t *TodoUpdateTool) Execute(ctx context.Context, params map[string]any, userID string) (string, error) {
 	// Get conversation ID from params (will be injected by controller)
 	conversationIDVal, exists := params["_conversation_id"]
 	if !exists {
@@ -207,6 +208,17 @@ func (t *TodoUpdateTool) Execute(params map[string]any, userID string) (string,
 	if !ok || conversationID == "" {
 		return "", fmt.Errorf("invalid conversation ID")
 	}
+
+	result, err := t.apply(conversationID, params)
+	if err == nil {
+		// Push the change to any open todo panels for this conversation
+		models.PublishTodoChange(conversationID)
+	}
+	return result, err
+}
+
+// apply performs the requested todo action for a conversation
+func (t *TodoUpdateTool) apply(conversationID string, params map[string]any) (string, error) {
 	action := params["action"].(string)
 
 	switch action {
@@ -241,7 +253,8 @@ func (t *TodoUpdateTool) Execute(params map[string]any, userID string) (string,
 
 			var err error
 			todo, err = models.Todos.Get(id)
-			if err != nil {
+			// Workspace tasks share the table, so stay within this conversation
+			if err != nil || todo.ConversationID != conversationID {
 				return "", fmt.Errorf("todo not found with ID %s", id)
 			}
 		} else if contentVal, exists := params["content"]; exists {
@@ -309,7 +322,7 @@ func (t *TodoUpdateTool) Execute(params map[string]any, userID string) (string,
 
 			var err error
 			todo, err = models.Todos.Get(id)
-			if err != nil {
+			if err != nil || todo.ConversationID != conversationID {
 				return "", fmt.Errorf("todo not found with ID %s", id)
 			}
 		} else if contentVal, exists := params["content"]; exists {
@@ -0,0 +1,232 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+	"workspace/models"
+)
+
+const (
+	// webFetchMaxBytes caps how much of a page is downloaded
+	webFetchMaxBytes = 2 << 20
+
+	// webFetchDefaultChars and webFetchMaxChars bound the text returned to
+	// the model, keeping a single page from crowding out the conversation
+	webFetchDefaultChars = 12000
+	webFetchMaxChars     = 40000
+)
+
+// WebFetchTool reads documentation pages from admin-approved sites
+type WebFetchTool struct{}
+
+func (t *WebFetchTool) Name() string {
+	return "web_fetch"
+}
+
+func (t *WebFetchTool) Description() string {
+	return "Fetch a documentation page (e.g. go.dev, MDN, a package README) and return its readable text. Only domains on the admin's allowlist can be fetched. Required params: url. Optional params: max_chars (default 12000)"
+}
+
+func (t *WebFetchTool) ValidateParams(params map[string]any) error {
+	rawURL, ok := params["url"].(string)
+	if !ok || rawURL == "" {
+		return fmt.Errorf("url is required")
+	}
+	if _, err := parseFetchURL(rawURL); err != nil {
+		return err
+	}
+	if value, exists := params["max_chars"]; exists {
+		if _, ok := value.(float64); !ok {
+			if _, ok := value.(int); !ok {
+				return fmt.Errorf("max_chars must be a number")
+			}
+		}
+	}
+	return nil
+}
+
+func (t *WebFetchTool) Schema() map[string]any {
+	return SimpleSchema(map[string]any{
+		"url": map[string]any{
+			"type":        "string",
+			"description": "Full http(s) URL of the page to read",
+			"required":    true,
+		},
+		"max_chars": map[string]any{
+			"type":        "integer",
+			"description": fmt.Sprintf("Maximum characters of text to return (up to %d)", webFetchMaxChars),
+		},
+	})
+}
+
+func (t *WebFetchTool) Execute(ctx context.Context, params map[string]any, userID string) (string, error) {
+	target, err := parseFetchURL(params["url"].(string))
+	if err != nil {
+		return "", err
+	}
+
+	settings, err := models.GetSettings()
+	if err != nil {
+		return "", fmt.Errorf("failed to load settings: %w", err)
+	}
+	if !settings.AllowsWebFetch(target.Hostname()) {
+		allowed := settings.WebFetchDomainList()
+		if len(allowed) == 0 {
+			return "", fmt.Errorf("web access is disabled: an admin can allow documentation sites under Settings → AI Assistant")
+		}
+		return "", fmt.Errorf("%s is not on the allowlist. Allowed domains: %s", target.Hostname(), strings.Join(allowed, ", "))
+	}
+
+	maxChars := webFetchDefaultChars
+	switch v := params["max_chars"].(type) {
+	case float64:
+		maxChars = int(v)
+	case int:
+		maxChars = v
+	}
+	maxChars = max(1000, min(maxChars, webFetchMaxChars))
+
+	ctx, cancel := context.WithTimeout(ctx, 20*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return "", fmt.Errorf("invalid request: %w", err)
+	}
+	req.Header.Set("User-Agent", "Skyscape-Workspace/1.0 (documentation lookup)")
+	req.Header.Set("Accept", "text/html, text/plain, text/markdown, application/json;q=0.9")
+
+	client := &http.Client{
+		// Redirects must stay on allowlisted sites too
+		CheckRedirect: func(next *http.Request, via []*http.Request) error {
+			if len(via) >= 5 {
+				return errors.New("too many redirects")
+			}
+			if next.URL.Scheme != "https" && next.URL.Scheme != "http" {
+				return fmt.Errorf("redirect to unsupported scheme %s", next.URL.Scheme)
+			}
+			if !settings.AllowsWebFetch(next.URL.Hostname()) {
+				return fmt.Errorf("redirect to %s is not on the allowlist", next.URL.Hostname())
+			}
+			return nil
+		},
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch %s: %w", target, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to fetch %s: %s", target, resp.Status)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	isHTML := mediaType == "text/html" || mediaType == "application/xhtml+xml"
+	if !isHTML && !strings.HasPrefix(mediaType, "text/") && mediaType != "application/json" {
+		return "", fmt.Errorf("%s is %s, only web pages and text can be read", target, mediaType)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, webFetchMaxBytes))
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", target, err)
+	}
+
+	title, text := "", strings.TrimSpace(strings.ToValidUTF8(string(body), ""))
+	if isHTML {
+		title, text = readableText(text)
+	}
+
+	truncated := false
+	if runes := []rune(text); len(runes) > maxChars {
+		text = string(runes[:maxChars])
+		truncated = true
+	}
+
+	var result strings.Builder
+	result.WriteString(fmt.Sprintf("## %s\n", resp.Request.URL))
+	if title != "" {
+		result.WriteString(fmt.Sprintf("**Title:** %s\n", title))
+	}
+	result.WriteString("\n")
+	result.WriteString(text)
+	if truncated {
+		result.WriteString(fmt.Sprintf("\n\n[Truncated to %d characters. Fetch a more specific page, or raise max_chars, for more.]", maxChars))
+	}
+	return result.String(), nil
+}
+
+// parseFetchURL accepts absolute http(s) URLs without credentials
+func parseFetchURL(rawURL string) (*url.URL, error) {
+	target, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return nil, fmt.Errorf("invalid url: %w", err)
+	}
+	if target.Scheme != "https" && target.Scheme != "http" {
+		return nil, fmt.Errorf("url must start with https:// or http://")
+	}
+	if target.Hostname() == "" {
+		return nil, fmt.Errorf("url must include a host")
+	}
+	if target.User != nil {
+		return nil, fmt.Errorf("url must not contain credentials")
+	}
+	return target, nil
+}
+
+var (
+	htmlTitle    = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+	htmlMain     = regexp.MustCompile(`(?is)<main\b[^>]*>(.*)</main>`)
+	htmlNoise    = regexp.MustCompile(`(?is)<(script|style|noscript|svg|nav|header|footer|aside|form|template|iframe)\b[^>]*>.*?</(script|style|noscript|svg|nav|header|footer|aside|form|template|iframe)>`)
+	htmlComments = regexp.MustCompile(`(?s)<!--.*?-->`)
+	htmlHeading  = regexp.MustCompile(`(?i)<h([1-6])\b[^>]*>`)
+	htmlListItem = regexp.MustCompile(`(?i)<li\b[^>]*>`)
+	htmlBreaks   = regexp.MustCompile(`(?i)<(br|hr)\b[^>]*>|</(p|div|section|article|h[1-6]|tr|pre|blockquote|dt|dd|table|ul|ol)>`)
+	htmlCells    = regexp.MustCompile(`(?i)</t[dh]>`)
+	htmlTags     = regexp.MustCompile(`(?s)<[^>]*>`)
+	blankRuns    = regexp.MustCompile(`\n{3,}`)
+	spaceRuns    = regexp.MustCompile(`[ \t\f\v]+`)
+)
+
+// readableText reduces an HTML page to its title and text, dropping
+// scripts, styles and site chrome and keeping headings and list structure
+func readableText(page string) (string, string) {
+	title := ""
+	if m := htmlTitle.FindStringSubmatch(page); m != nil {
+		title = strings.TrimSpace(html.UnescapeString(htmlTags.ReplaceAllString(m[1], "")))
+	}
+
+	// Prefer the main content when the page marks it
+	if m := htmlMain.FindStringSubmatch(page); m != nil {
+		page = m[1]
+	}
+
+	text := htmlComments.ReplaceAllString(page, "")
+	text = htmlNoise.ReplaceAllString(text, "")
+	text = htmlHeading.ReplaceAllStringFunc(text, func(tag string) string {
+		level := htmlHeading.FindStringSubmatch(tag)[1]
+		return "\n\n" + strings.Repeat("#", int(level[0]-'0')) + " "
+	})
+	text = htmlListItem.ReplaceAllString(text, "\n- ")
+	text = htmlBreaks.ReplaceAllString(text, "\n")
+	text = htmlCells.ReplaceAllString(text, " | ")
+	text = htmlTags.ReplaceAllString(text, "")
+	text = html.UnescapeString(text)
+
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(spaceRuns.ReplaceAllString(line, " "))
+	}
+	text = blankRuns.ReplaceAllString(strings.Join(lines, "\n"), "\n\n")
+	return title, strings.TrimSpace(text)
+}
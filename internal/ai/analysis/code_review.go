@@ -0,0 +1,273 @@
+package analysis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	// maxChunkChars keeps each review prompt well inside a small model's context
+	maxChunkChars = 12000
+
+	// maxReviewChunks caps the model calls spent on one pull request
+	maxReviewChunks = 20
+
+	// maxReviewFindings caps the line comments posted for one pull request
+	maxReviewFindings = 30
+)
+
+// codeReviewPrompt is the system prompt for reviewing one chunk of a diff
+const codeReviewPrompt = `You are reviewing part of a pull request diff. Look for bugs, security problems, ` +
+	`error handling mistakes, race conditions and performance problems in the added lines. ` +
+	`Ignore style and formatting. Reply with JSON only, in this shape:
+{"summary": "one or two sentences on what this part changes", "risk": "low|medium|high|critical",
+ "findings": [{"file": "path", "line": 12, "severity": "low|medium|high|critical", "message": "the problem", "suggestion": "how to fix it"}]}
+Lines are line numbers in the new version of the file. Leave findings empty when nothing is wrong.`
+
+// hunkHeader matches a unified diff hunk header, capturing the first line of
+// the old file and of the new file
+var hunkHeader = regexp.MustCompile(`^@@ -(\d+)(?:,\d+)? \+(\d+)(?:,\d+)? @@`)
+
+// ChatFunc sends a system prompt and a user prompt to a model and returns
+// its reply
+type ChatFunc func(ctx context.Context, system, prompt string) (string, error)
+
+// DiffChunk is a piece of a unified diff small enough for one review prompt
+type DiffChunk struct {
+	File    string
+	Content string
+	lines   map[int]bool // New-file lines shown in the chunk, which findings can anchor to
+}
+
+// ReviewFinding is a problem the model found, anchored to a line of the new
+// file when the line is part of the diff
+type ReviewFinding struct {
+	File       string `json:"file"`
+	Line       int    `json:"line"`
+	Severity   string `json:"severity"`
+	Message    string `json:"message"`
+	Suggestion string `json:"suggestion"`
+}
+
+// CodeReview is a model's review of a pull request's diff
+type CodeReview struct {
+	Summary       string          `json:"summary"`
+	Risk          string          `json:"risk"`
+	Findings      []ReviewFinding `json:"findings"`
+	FilesReviewed int             `json:"files_reviewed"`
+	ChunksSkipped int             `json:"chunks_skipped"` // Over the chunk cap or failed
+}
+
+// CodeReviewer reviews diffs chunk by chunk with a model
+type CodeReviewer struct {
+	chat ChatFunc
+}
+
+// NewCodeReviewer creates a reviewer that sends each chunk to chat
+func NewCodeReviewer(chat ChatFunc) *CodeReviewer {
+	return &CodeReviewer{chat: chat}
+}
+
+// Review splits the diff into chunks, reviews each one and combines the
+// results. It fails only when no chunk could be reviewed.
+func (r *CodeReviewer) Review(ctx context.Context, title, description, diff string) (*CodeReview, error) {
+	chunks := ChunkDiff(diff, maxChunkChars)
+	if len(chunks) == 0 {
+		return nil, fmt.Errorf("diff has no reviewable changes")
+	}
+
+	review := &CodeReview{Risk: "low"}
+	files := map[string]bool{}
+	var summaries []string
+	var lastErr error
+	for i, chunk := range chunks {
+		if i >= maxReviewChunks {
+			review.ChunksSkipped += len(chunks) - i
+			break
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		prompt := fmt.Sprintf("Pull request: %s\n\n%s\n\nDiff of %s:\n```diff\n%s\n```", title, description, chunk.File, chunk.Content)
+		reply, err := r.chat(ctx, codeReviewPrompt, prompt)
+		if err != nil {
+			lastErr = err
+			review.ChunksSkipped++
+			continue
+		}
+		result, err := parseChunkReview(reply)
+		if err != nil {
+			lastErr = err
+			review.ChunksSkipped++
+			continue
+		}
+
+		if !files[chunk.File] {
+			files[chunk.File] = true
+			review.FilesReviewed++
+		}
+		if result.Summary != "" {
+			summaries = append(summaries, fmt.Sprintf("- `%s`: %s", chunk.File, strings.TrimSpace(result.Summary)))
+		}
+		if riskRank(result.Risk) > riskRank(review.Risk) {
+			review.Risk = normalizeSeverity(result.Risk)
+		}
+		for _, finding := range result.Findings {
+			if len(review.Findings) >= maxReviewFindings || strings.TrimSpace(finding.Message) == "" {
+				continue
+			}
+			review.Findings = append(review.Findings, chunk.anchor(finding))
+		}
+	}
+
+	if review.FilesReviewed == 0 {
+		return nil, fmt.Errorf("no part of the diff could be reviewed: %w", lastErr)
+	}
+	review.Summary = strings.Join(summaries, "\n")
+	return review, nil
+}
+
+// anchor keeps a finding on the chunk's file and drops its line number when
+// the line isn't part of the chunk, so comments never point at unchanged code
+func (c DiffChunk) anchor(finding ReviewFinding) ReviewFinding {
+	finding.File = c.File
+	if !c.lines[finding.Line] {
+		finding.Line = 0
+	}
+	finding.Severity = normalizeSeverity(finding.Severity)
+	finding.Message = strings.TrimSpace(finding.Message)
+	finding.Suggestion = strings.TrimSpace(finding.Suggestion)
+	return finding
+}
+
+// ChunkDiff splits a unified diff into per-file chunks of at most maxChars,
+// splitting large files between hunks. Binary files are skipped and a
+// single hunk longer than maxChars is cut short.
+func ChunkDiff(diff string, maxChars int) []DiffChunk {
+	var chunks []DiffChunk
+	for _, section := range splitBefore(diff, "diff --git ") {
+		file, header, hunks := parseFileDiff(section)
+		if file == "" || len(hunks) == 0 {
+			continue
+		}
+
+		chunk := DiffChunk{File: file, Content: header, lines: map[int]bool{}}
+		for _, hunk := range hunks {
+			if len(chunk.Content) > len(header) && len(chunk.Content)+len(hunk) > maxChars {
+				chunks = append(chunks, chunk)
+				chunk = DiffChunk{File: file, Content: header, lines: map[int]bool{}}
+			}
+			if room := maxChars - len(chunk.Content); len(hunk) > room && room > 0 {
+				hunk = hunk[:room] + "\n... (hunk truncated)\n"
+			}
+			chunk.Content += hunk
+			addHunkLines(hunk, chunk.lines)
+		}
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+// parseFileDiff returns the new path of a file's diff, the lines before its
+// first hunk and each hunk
+func parseFileDiff(section string) (string, string, []string) {
+	parts := splitBefore(section, "@@ ")
+	if len(parts) == 0 || strings.HasPrefix(parts[0], "@@ ") {
+		return "", "", nil
+	}
+	header, hunks := parts[0], parts[1:]
+
+	var file, oldFile string
+	for _, line := range strings.Split(header, "\n") {
+		switch {
+		case strings.HasPrefix(line, "+++ "):
+			file = strings.TrimPrefix(strings.TrimPrefix(line, "+++ "), "b/")
+		case strings.HasPrefix(line, "--- "):
+			oldFile = strings.TrimPrefix(strings.TrimPrefix(line, "--- "), "a/")
+		}
+	}
+	if file == "/dev/null" {
+		file = oldFile
+	}
+	return strings.TrimSpace(file), header, hunks
+}
+
+// addHunkLines records the new-file line numbers a hunk shows
+func addHunkLines(hunk string, lines map[int]bool) {
+	lineNo := 0
+	for i, line := range strings.Split(hunk, "\n") {
+		if i == 0 {
+			m := hunkHeader.FindStringSubmatch(line)
+			if m == nil {
+				return
+			}
+			lineNo, _ = strconv.Atoi(m[2])
+			continue
+		}
+		if strings.HasPrefix(line, "+") || strings.HasPrefix(line, " ") {
+			lines[lineNo] = true
+			lineNo++
+		}
+	}
+}
+
+// splitBefore splits text into pieces that each start at a line beginning
+// with prefix. Anything before the first such line is the first piece.
+func splitBefore(text, prefix string) []string {
+	var pieces []string
+	var current strings.Builder
+	for _, line := range strings.SplitAfter(text, "\n") {
+		if strings.HasPrefix(line, prefix) && current.Len() > 0 {
+			pieces = append(pieces, current.String())
+			current.Reset()
+		}
+		current.WriteString(line)
+	}
+	if current.Len() > 0 {
+		pieces = append(pieces, current.String())
+	}
+	return pieces
+}
+
+// parseChunkReview reads the JSON object out of a model's reply
+func parseChunkReview(reply string) (*CodeReview, error) {
+	start, end := strings.Index(reply, "{"), strings.LastIndex(reply, "}")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("review reply has no JSON object")
+	}
+	var review CodeReview
+	if err := json.Unmarshal([]byte(reply[start:end+1]), &review); err != nil {
+		return nil, fmt.Errorf("failed to parse review reply: %w", err)
+	}
+	return &review, nil
+}
+
+// normalizeSeverity maps a model's severity onto low, medium, high or
+// critical, defaulting to medium
+func normalizeSeverity(severity string) string {
+	switch s := strings.ToLower(strings.TrimSpace(severity)); s {
+	case "low", "medium", "high", "critical":
+		return s
+	}
+	return "medium"
+}
+
+// riskRank orders risk levels so the higher of two can be kept
+func riskRank(risk string) int {
+	switch strings.ToLower(strings.TrimSpace(risk)) {
+	case "low":
+		return 1
+	case "medium":
+		return 2
+	case "high":
+		return 3
+	case "critical":
+		return 4
+	}
+	return 0
+}
@@ -0,0 +1,108 @@
+package analysis
+
+import (
+	"strconv"
+	"strings"
+)
+
+// diffFile is one file's changes in a unified diff
+type diffFile struct {
+	Path      string
+	OldPath   string
+	Status    string // added, deleted, renamed or modified
+	Binary    bool
+	Additions int
+	Deletions int
+	Added     []diffLine // With their line numbers in the new file
+	Removed   []diffLine // With their line numbers in the old file
+}
+
+// diffLine is an added or removed line of a diff
+type diffLine struct {
+	Line int
+	Text string
+}
+
+// parseDiff splits a unified diff, as git prints it, into files with their
+// added and removed lines
+func parseDiff(diff string) []diffFile {
+	var files []diffFile
+	for _, section := range splitBefore(diff, "diff --git ") {
+		if !strings.HasPrefix(section, "diff --git ") {
+			continue
+		}
+		parts := splitBefore(section, "@@ ")
+		file := parseDiffHeader(parts[0])
+		if file.Path == "" {
+			continue
+		}
+		for _, hunk := range parts[1:] {
+			addHunkChanges(hunk, &file)
+		}
+		files = append(files, file)
+	}
+	return files
+}
+
+// parseDiffHeader reads a file's paths and status from the lines of its
+// diff before the first hunk
+func parseDiffHeader(header string) diffFile {
+	file := diffFile{Status: "modified"}
+	lines := strings.Split(header, "\n")
+	if names := strings.TrimPrefix(lines[0], "diff --git "); strings.HasPrefix(names, "a/") {
+		if i := strings.Index(names, " b/"); i >= 0 {
+			file.OldPath, file.Path = names[2:i], names[i+3:]
+		}
+	}
+
+	for _, line := range lines[1:] {
+		switch {
+		case strings.HasPrefix(line, "new file mode"):
+			file.Status = "added"
+		case strings.HasPrefix(line, "deleted file mode"):
+			file.Status = "deleted"
+		case strings.HasPrefix(line, "rename from "):
+			file.Status = "renamed"
+			file.OldPath = strings.TrimPrefix(line, "rename from ")
+		case strings.HasPrefix(line, "rename to "):
+			file.Path = strings.TrimPrefix(line, "rename to ")
+		case strings.HasPrefix(line, "Binary files "):
+			file.Binary = true
+		case strings.HasPrefix(line, "--- a/"):
+			file.OldPath = strings.TrimPrefix(line, "--- a/")
+		case strings.HasPrefix(line, "+++ b/"):
+			file.Path = strings.TrimPrefix(line, "+++ b/")
+		}
+	}
+	if file.Status == "deleted" && file.OldPath != "" {
+		file.Path = file.OldPath
+	}
+	return file
+}
+
+// addHunkChanges adds a hunk's added and removed lines to file
+func addHunkChanges(hunk string, file *diffFile) {
+	lines := strings.Split(strings.TrimSuffix(hunk, "\n"), "\n")
+	m := hunkHeader.FindStringSubmatch(lines[0])
+	if m == nil {
+		return
+	}
+	oldLine, _ := strconv.Atoi(m[1])
+	newLine, _ := strconv.Atoi(m[2])
+
+	for _, line := range lines[1:] {
+		switch {
+		case strings.HasPrefix(line, "+"):
+			file.Added = append(file.Added, diffLine{Line: newLine, Text: line[1:]})
+			file.Additions++
+			newLine++
+		case strings.HasPrefix(line, "-"):
+			file.Removed = append(file.Removed, diffLine{Line: oldLine, Text: line[1:]})
+			file.Deletions++
+			oldLine++
+		case strings.HasPrefix(line, " "):
+			oldLine++
+			newLine++
+		}
+	}
+}
@@ -5,18 +5,68 @@ import (
 	"fmt"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
+
+	"workspace/internal/depscan"
+	"workspace/internal/lint"
 )
 
 // PRAnalyzer performs intelligent analysis on pull requests
 type PRAnalyzer struct {
-	// Patterns for detecting different types of changes
-	securityPatterns    []*regexp.Regexp
-	performancePatterns []*regexp.Regexp
-	breakingPatterns    []*regexp.Regexp
-	docPatterns         []*regexp.Regexp
-	testPatterns        []*regexp.Regexp
-	configPatterns      []*regexp.Regexp
+	// Checks run on the lines a pull request adds
+	securityPatterns    []diffPattern
+	performancePatterns []diffPattern
+
+	// Declarations that break callers when they're removed or changed
+	breakingPatterns []*regexp.Regexp
+	routePattern     *regexp.Regexp
+
+	// Patterns matched against changed file paths
+	sensitivePatterns []*regexp.Regexp
+	docPatterns       []*regexp.Regexp
+	testPatterns      []*regexp.Regexp
+	configPatterns    []*regexp.Regexp
+	ciPatterns        []*regexp.Regexp
+}
+
+// diffPattern flags a changed line that matches re
+type diffPattern struct {
+	re       *regexp.Regexp
+	severity string
+	message  string
+}
+
+// PRChanges is what the analyzer reads from a pull request
+type PRChanges struct {
+	Title       string
+	Description string
+	Diff        string          // Unified diff of the branch against its base, as git prints it
+	Author      string          // Handle or bot ID of whoever opened it
+	Checks      map[string]bool // Latest result of each check run on the branch, true when it passed
+	Lint        []lint.Issue    // Findings of the linters last run on the branch
+
+	// Where its review stands
+	Draft            bool     // Still in progress
+	ChangesRequested []string // Reviewers whose latest review asks for changes
+}
+
+// ApprovalPolicy is a repository's rules for which pull requests can be
+// approved without a human review. Whatever the policy, pull requests that
+// couldn't be read, have security implications or breaking changes, or are
+// more than low risk are never eligible.
+type ApprovalPolicy struct {
+	AllowedCategories []string // Every category of the PR must be one of these, empty allows any
+	MaxLinesChanged   int      // Most lines added and deleted, 0 for no limit
+	RequiredChecks    []string // Checks that must have passed on the branch
+	ProtectedPaths    []string // Directories ending in / or globs that only a human can approve changes to
+	AllowedAuthors    []string // Authors whose pull requests can be approved, empty allows anyone
+}
+
+// defaultApprovalPolicy is applied when the caller doesn't give a policy
+var defaultApprovalPolicy = ApprovalPolicy{
+	MaxLinesChanged: 200,
+	ProtectedPaths:  []string{".github/workflows/", ".skyscape/"},
 }
 
 // PRAnalysis contains the results of PR analysis
@@ -34,10 +84,16 @@ type PRAnalysis struct {
 	TestCoverage         string                `json:"test_coverage"`
 	AutoApprovalEligible bool                  `json:"auto_approval_eligible"`
 	AutoApprovalReasons  []string              `json:"auto_approval_reasons"`
+	AutoApprovalBlockers []string              `json:"auto_approval_blockers"` // Why it isn't eligible
 	Recommendation       string                `json:"recommendation"`
+	Additions            int                   `json:"additions"`
+	Deletions            int                   `json:"deletions"`
+	ChangedFiles         int                   `json:"changed_files"`
 	FileAnalysis         map[string]FileDetail `json:"file_analysis"`
 	DependencyChanges    []DependencyChange    `json:"dependency_changes"`
 	APIChanges           []APIChange           `json:"api_changes"`
+	ReviewSummary        string                `json:"review_summary,omitempty"` // What the model's diff review found, file by file
+	ReviewRisk           string                `json:"review_risk,omitempty"`    // Risk the model gave the diff, a floor for RiskLevel
 }
 
 // ChecklistItem represents a review checklist item
@@ -91,65 +147,86 @@ type APIChange struct {
 // NewPRAnalyzer creates a new PR analyzer
 func NewPRAnalyzer() *PRAnalyzer {
 	return &PRAnalyzer{
-		securityPatterns: []*regexp.Regexp{
-			regexp.MustCompile(`(?i)(password|secret|key|token|api[_-]?key|credential)`),
-			regexp.MustCompile(`(?i)(auth|authentication|authorization|permission)`),
-			regexp.MustCompile(`(?i)(encrypt|decrypt|hash|salt|crypto)`),
-			regexp.MustCompile(`(?i)(sql|query|database|injection)`),
-			regexp.MustCompile(`(?i)(cors|csrf|xss|vulnerability)`),
-			regexp.MustCompile(`(?i)(eval|exec|system|shell|command)`),
+		securityPatterns: []diffPattern{
+			{regexp.MustCompile(`(?i)(password|passwd|secret|api[_-]?key|access[_-]?key|private[_-]?key|auth[_-]?token)\w*["']?\s*(:=|=|:)\s*["'][^"'\s]{8,}["']`),
+				"critical", "Possible hardcoded credential"},
+			{regexp.MustCompile(`-----BEGIN ([A-Z]+ )?PRIVATE KEY-----`),
+				"critical", "Private key added to the repository"},
+			{regexp.MustCompile(`\bexec\.Command(Context)?\(|\bos\.system\(|\bsubprocess\.(call|run|Popen)\(|\bchild_process\b|\beval\(`),
+				"high", "Runs a shell command or evaluates code; make sure user input can't reach it"},
+			{regexp.MustCompile(`(?i)"\s*(select|insert into|update|delete from)\b[^"]*"\s*\+|sprintf\(\s*"\s*(select|insert into|update|delete from)\b|f["'](select|insert into|update|delete from)\b[^"']*\{`),
+				"high", "SQL built from strings; use query parameters"},
+			{regexp.MustCompile(`InsecureSkipVerify:\s*true|verify\s*=\s*False|rejectUnauthorized:\s*false`),
+				"high", "Turns off TLS certificate verification"},
+			{regexp.MustCompile(`\b(md5|sha1)\.(New|Sum)\(|hashlib\.(md5|sha1)\(|createHash\(["'](md5|sha1)["']\)`),
+				"medium", "Uses a weak hash; don't rely on it for passwords or signatures"},
+			{regexp.MustCompile(`\.innerHTML\s*=|dangerouslySetInnerHTML|template\.HTML\(`),
+				"medium", "Writes unescaped HTML; make sure it can't contain user input"},
+			{regexp.MustCompile(`(?i)access-control-allow-origin["']?\s*[,:]\s*["']\*`),
+				"medium", "Allows cross-origin requests from any site"},
 		},
-		performancePatterns: []*regexp.Regexp{
-			regexp.MustCompile(`(?i)(cache|caching|memoiz|memo)`),
-			regexp.MustCompile(`(?i)(optimize|optimization|performance)`),
-			regexp.MustCompile(`(?i)(async|await|concurrent|parallel|goroutine)`),
-			regexp.MustCompile(`(?i)(index|indices|query[_-]?plan)`),
-			regexp.MustCompile(`(?i)(batch|bulk|pagination|limit)`),
-			regexp.MustCompile(`(?i)(n\+1|eager[_-]?load|lazy[_-]?load)`),
+		performancePatterns: []diffPattern{
+			{regexp.MustCompile(`\btime\.Sleep\(|\bThread\.sleep\(|\btime\.sleep\(`), "low", "Adds a sleep"},
+			{regexp.MustCompile(`(?i)\bselect\s+\*\s+from\b`), "low", "Selects every column"},
+			{regexp.MustCompile(`\b(io|ioutil)\.ReadAll\(|\b(ioutil|os)\.ReadFile\(`), "low", "Reads a whole body or file into memory"},
+			{regexp.MustCompile(`^\s*go (func\b|\w+(\.\w+)*\()`), "low", "Starts a goroutine"},
+			{regexp.MustCompile(`\.(Lock|RLock)\(\)`), "low", "Takes a lock"},
+			{regexp.MustCompile(`(?i)\b(cache|memoiz)`), "low", "Changes caching"},
 		},
 		breakingPatterns: []*regexp.Regexp{
-			regexp.MustCompile(`(?i)(breaking[_-]?change|incompatible)`),
-			regexp.MustCompile(`(?i)(deprecat|obsolete|legacy)`),
-			regexp.MustCompile(`(?i)(remov|delet).*(?i)(api|endpoint|method|function)`),
-			regexp.MustCompile(`(?i)changed?.*(?i)(signature|interface|contract)`),
-			regexp.MustCompile(`(?i)(renam|refactor).*(?i)(public|export)`),
+			regexp.MustCompile(`^func\s+(?:\([^)]*\)\s*)?([A-Z]\w*)\s*[\[(]`),                                               // Exported Go functions and methods
+			regexp.MustCompile(`^export\s+(?:default\s+)?(?:async\s+)?(?:function|class|const|let|interface|type)\s+(\w+)`), // JavaScript and TypeScript exports
+		},
+		routePattern: regexp.MustCompile(`(?:Handle|HandleFunc|\.(?:Get|Post|Put|Patch|Delete|get|post|put|patch|delete|route))\(\s*["'](?:(GET|POST|PUT|PATCH|DELETE) +)?(/[^"']*)["']`),
+		sensitivePatterns: []*regexp.Regexp{
+			regexp.MustCompile(`(?i)(auth|login|session|password|secret|credential|crypto|token|permission|oauth|vault|security|sanitiz)`),
 		},
 		docPatterns: []*regexp.Regexp{
-			regexp.MustCompile(`\.(md|markdown|rst|txt|doc|pdf)$`),
-			regexp.MustCompile(`(?i)(readme|license|contributing|changelog)`),
-			regexp.MustCompile(`(?i)(docs?/|documentation/)`),
-			regexp.MustCompile(`(?i)^\s*(//|/\*|\#)\s*`), // Comments
+			regexp.MustCompile(`(?i)\.(md|markdown|rst|txt|adoc)$`),
+			regexp.MustCompile(`(?i)(^|/)(readme|license|contributing|changelog)[^/]*$`),
+			regexp.MustCompile(`(?i)(^|/)(docs?|documentation)/`),
 		},
 		testPatterns: []*regexp.Regexp{
-			regexp.MustCompile(`(?i)(_test\.|test_|\.test\.|\.spec\.)`),
-			regexp.MustCompile(`(?i)(test/|tests/|spec/|specs/)`),
-			regexp.MustCompile(`(?i)(describe|it|expect|assert|should)`),
-			regexp.MustCompile(`(?i)(mock|stub|spy|fake)`),
+			regexp.MustCompile(`(?i)(_test\.|(^|/)test_|\.test\.|\.spec\.)`),
+			regexp.MustCompile(`(?i)(^|/)(tests?|specs?|__tests__|testdata)/`),
 		},
 		configPatterns: []*regexp.Regexp{
-			regexp.MustCompile(`\.(json|yaml|yml|toml|ini|conf|config|env)$`),
-			regexp.MustCompile(`(?i)(config|configuration|settings)`),
-			regexp.MustCompile(`(?i)(dockerfile|docker-compose|k8s|kubernetes)`),
-			regexp.MustCompile(`(?i)(package\.json|go\.mod|requirements|gemfile)`),
+			regexp.MustCompile(`(?i)\.(json|yaml|yml|toml|ini|conf|cfg|env)$`),
+			regexp.MustCompile(`(?i)(^|/)(dockerfile|docker-compose[^/]*|makefile)$`),
+			regexp.MustCompile(`(?i)(^|/)(go\.mod|go\.sum|package-lock\.json|requirements[^/]*\.txt|gemfile)$`),
+		},
+		ciPatterns: []*regexp.Regexp{
+			regexp.MustCompile(`(?i)(^|/)(\.github/workflows|\.circleci|\.gitlab-ci\.yml|jenkinsfile|\.skyscape)`),
 		},
 	}
 }
 
 // Analyze performs comprehensive analysis on a pull request
-func (a *PRAnalyzer) Analyze(ctx context.Context, pr any) (*PRAnalysis, error) {
-	// Type assertion for PR model
+func (a *PRAnalyzer) Analyze(ctx context.Context, pr PRChanges) (*PRAnalysis, error) {
+	return a.AnalyzeWithReview(ctx, pr, nil, nil)
+}
+
+// AnalyzeWithReview analyzes a pull request, folding in a model's review of
+// its diff: the review's findings become issues and its risk is the lowest
+// risk level the analysis can give. A nil review is ignored. Auto-approval
+// eligibility is decided by policy, or the default policy when it's nil.
+func (a *PRAnalyzer) AnalyzeWithReview(ctx context.Context, pr PRChanges, review *CodeReview, policy *ApprovalPolicy) (*PRAnalysis, error) {
 	prData := extractPRData(pr)
 
 	result := &PRAnalysis{
-		FileAnalysis:        make(map[string]FileDetail),
-		Categories:          []string{},
-		ChecklistItems:      []ChecklistItem{},
-		Issues:              []Issue{},
-		Suggestions:         []string{},
-		PerformanceNotes:    []string{},
-		DependencyChanges:   []DependencyChange{},
-		APIChanges:          []APIChange{},
-		AutoApprovalReasons: []string{},
+		Additions:            prData.Additions,
+		Deletions:            prData.Deletions,
+		ChangedFiles:         prData.ChangedFiles,
+		FileAnalysis:         make(map[string]FileDetail),
+		Categories:           []string{},
+		ChecklistItems:       []ChecklistItem{},
+		Issues:               []Issue{},
+		Suggestions:          []string{},
+		PerformanceNotes:     []string{},
+		DependencyChanges:    []DependencyChange{},
+		APIChanges:           []APIChange{},
+		AutoApprovalReasons:  []string{},
+		AutoApprovalBlockers: []string{},
 	}
 
 	// Analyze PR size and complexity
@@ -176,6 +253,14 @@ func (a *PRAnalyzer) Analyze(ctx context.Context, pr any) (*PRAnalysis, error) {
 	// Detect API changes
 	a.analyzeAPIChanges(prData, result)
 
+	// Add the linters' findings in the changed files
+	a.analyzeLint(prData, result)
+
+	// Add the model's findings from the diff
+	if review != nil {
+		a.applyReview(review, result)
+	}
+
 	// Build review checklist
 	a.buildChecklist(prData, result)
 
@@ -183,7 +268,10 @@ func (a *PRAnalyzer) Analyze(ctx context.Context, pr any) (*PRAnalysis, error) {
 	a.calculateRiskLevel(result)
 
 	// Determine auto-approval eligibility
-	a.checkAutoApproval(result)
+	if policy == nil {
+		policy = &defaultApprovalPolicy
+	}
+	a.checkAutoApproval(prData, result, policy)
 
 	// Generate recommendation
 	a.generateRecommendation(result)
@@ -212,43 +300,40 @@ func (a *PRAnalyzer) analyzeComplexity(pr prInfo, result *PRAnalysis) {
 	}
 }
 
-// analyzeFiles performs detailed analysis on changed files
+// analyzeFiles records each changed file's language, size and whether its
+// path is security-sensitive
 func (a *PRAnalyzer) analyzeFiles(pr prInfo, result *PRAnalysis) {
-	// Simulate file analysis based on PR description and patterns
-	files := a.extractFileList(pr)
-
-	for _, file := range files {
+	for _, file := range pr.Files {
 		detail := FileDetail{
-			Language:     a.detectLanguage(file),
-			Changes:      0, // Would be calculated from diff
-			Risk:         "low",
-			Issues:       []string{},
-			Improvements: []string{},
+			Language:      a.detectLanguage(file.Path),
+			Changes:       file.Additions + file.Deletions,
+			Risk:          "low",
+			Issues:        []string{},
+			Improvements:  []string{},
+			TestsAffected: matchesAny(a.testPatterns, file.Path),
 		}
 
-		// Check for security patterns in filename
-		for _, pattern := range a.securityPatterns {
-			if pattern.MatchString(file) {
-				detail.Risk = "high"
-				detail.Issues = append(detail.Issues, "Security-sensitive file modified")
-				result.HasSecurity = true
-				break
-			}
+		if !detail.TestsAffected && matchesAny(a.sensitivePatterns, file.Path) {
+			detail.Risk = "high"
+			detail.Issues = append(detail.Issues, "Security-sensitive file modified")
+			result.HasSecurity = true
 		}
-
-		// Check if test file
-		for _, pattern := range a.testPatterns {
-			if pattern.MatchString(file) {
-				detail.TestsAffected = true
-				break
+		if file.Binary {
+			detail.Issues = append(detail.Issues, "Binary file changed, its contents can't be reviewed")
+		}
+		if detail.Changes > 400 {
+			if detail.Risk == "low" {
+				detail.Risk = "medium"
 			}
+			detail.Improvements = append(detail.Improvements, "Large change, consider splitting it into smaller pull requests")
 		}
 
-		result.FileAnalysis[file] = detail
+		result.FileAnalysis[file.Path] = detail
 	}
 }
 
-// detectCategories identifies the types of changes in the PR
+// detectCategories identifies the types of changes in the PR from its
+// description and the files it changes
 func (a *PRAnalyzer) detectCategories(pr prInfo, result *PRAnalysis) {
 	description := strings.ToLower(pr.Title + " " + pr.Description)
 
@@ -274,31 +359,91 @@ func (a *PRAnalyzer) detectCategories(pr prInfo, result *PRAnalysis) {
 		}
 	}
 
+	// The changed files say more than the description does
+	for _, file := range pr.Files {
+		switch {
+		case matchesAny(a.testPatterns, file.Path):
+			addCategory(result, "testing")
+		case matchesAny(a.ciPatterns, file.Path):
+			addCategory(result, "ci/cd")
+		case isManifest(file.Path):
+			addCategory(result, "dependency")
+		case matchesAny(a.docPatterns, file.Path):
+			addCategory(result, "documentation")
+		}
+	}
+
 	// Ensure we have at least one category
 	if len(result.Categories) == 0 {
 		result.Categories = append(result.Categories, "other")
 	}
 }
 
-// analyzeSecurityRisks checks for security implications
+// analyzeSecurityRisks scans the lines the PR adds for risky code. Test
+// files are skipped since their fixtures trip the credential checks.
 func (a *PRAnalyzer) analyzeSecurityRisks(pr prInfo, result *PRAnalysis) {
-	description := pr.Title + " " + pr.Description
+	found := 0
+	for _, file := range pr.Files {
+		if matchesAny(a.testPatterns, file.Path) {
+			continue
+		}
+		detail := result.FileAnalysis[file.Path]
+		for _, line := range file.Added {
+			if isCommentLine(line.Text) {
+				continue
+			}
+			for _, pattern := range a.securityPatterns {
+				if !pattern.re.MatchString(line.Text) {
+					continue
+				}
+				found++
+				if found > maxPatternIssues {
+					break
+				}
+				result.Issues = append(result.Issues, Issue{
+					Type:        "Security",
+					Severity:    pattern.severity,
+					Description: pattern.message,
+					File:        file.Path,
+					Line:        line.Line,
+				})
+				detail.Issues = append(detail.Issues, fmt.Sprintf("Line %d: %s", line.Line, pattern.message))
+				if pattern.severity == "critical" || pattern.severity == "high" {
+					detail.Risk = "high"
+				}
+			}
+		}
+		result.FileAnalysis[file.Path] = detail
+	}
 
-	securityIssues := 0
-	for _, pattern := range a.securityPatterns {
-		if pattern.MatchString(description) {
-			securityIssues++
-			result.HasSecurity = true
+	// Security linters' findings count the same as the patterns'
+	for _, issue := range pr.Lint {
+		if !issue.Security {
+			continue
+		}
+		found++
+		if found <= maxPatternIssues {
+			addLintIssue("Security", issue, result)
 		}
 	}
 
-	if securityIssues > 0 {
-		result.Issues = append(result.Issues, Issue{
-			Type:        "Security Review Required",
-			Severity:    "high",
-			Description: "This PR contains security-related changes that require careful review",
-			Suggestion:  "Ensure proper security review by a qualified team member",
-		})
+	if found > maxPatternIssues {
+		result.Suggestions = append(result.Suggestions,
+			fmt.Sprintf("%d more security findings weren't listed, review the diff closely", found-maxPatternIssues))
+	}
+	if found > 0 {
+		result.HasSecurity = true
+	}
+
+	if result.HasSecurity {
+		if found == 0 {
+			result.Issues = append(result.Issues, Issue{
+				Type:        "Security Review Required",
+				Severity:    "high",
+				Description: "This PR changes security-sensitive files that require careful review",
+				Suggestion:  "Ensure proper security review by a qualified team member",
+			})
+		}
 
 		result.Suggestions = append(result.Suggestions,
 			"Request review from security team",
@@ -308,112 +453,287 @@ func (a *PRAnalyzer) analyzeSecurityRisks(pr prInfo, result *PRAnalysis) {
 	}
 }
 
-// analyzePerformanceImpact evaluates performance implications
+// analyzePerformanceImpact scans the lines the PR adds for code that
+// commonly affects performance
 func (a *PRAnalyzer) analyzePerformanceImpact(pr prInfo, result *PRAnalysis) {
-	description := pr.Title + " " + pr.Description
+	var matched []string
+	locations := map[string][]string{}
+	for _, file := range pr.Files {
+		if matchesAny(a.testPatterns, file.Path) {
+			continue
+		}
+		for _, line := range file.Added {
+			if isCommentLine(line.Text) {
+				continue
+			}
+			for _, pattern := range a.performancePatterns {
+				if !pattern.re.MatchString(line.Text) {
+					continue
+				}
+				if _, ok := locations[pattern.message]; !ok {
+					matched = append(matched, pattern.message)
+				}
+				locations[pattern.message] = append(locations[pattern.message], fmt.Sprintf("%s:%d", file.Path, line.Line))
+			}
+		}
+	}
 
-	perfPatterns := 0
-	for _, pattern := range a.performancePatterns {
-		if pattern.MatchString(description) {
-			perfPatterns++
+	for _, message := range matched {
+		where := locations[message]
+		note := fmt.Sprintf("%s in `%s`", message, where[0])
+		if len(where) > 1 {
+			note += fmt.Sprintf(" and %d more places", len(where)-1)
 		}
+		result.PerformanceNotes = append(result.PerformanceNotes, note)
 	}
 
-	if perfPatterns > 2 {
+	if len(matched) > 2 {
 		result.PerformanceImpact = "significant"
 		result.PerformanceNotes = append(result.PerformanceNotes,
-			"This PR includes performance-related changes",
 			"Consider running performance benchmarks",
 			"Monitor metrics after deployment",
 		)
-	} else if perfPatterns > 0 {
+	} else if len(matched) > 0 {
 		result.PerformanceImpact = "moderate"
-		result.PerformanceNotes = append(result.PerformanceNotes,
-			"Some performance implications detected",
-		)
 	} else {
 		result.PerformanceImpact = "minimal"
 	}
 }
 
-// analyzeTestCoverage checks test coverage
+// analyzeTestCoverage checks whether source changes come with test changes
 func (a *PRAnalyzer) analyzeTestCoverage(pr prInfo, result *PRAnalysis) {
-	hasTests := false
-	testFiles := 0
-
-	for file := range result.FileAnalysis {
-		for _, pattern := range a.testPatterns {
-			if pattern.MatchString(file) {
-				hasTests = true
-				testFiles++
-				break
-			}
+	testFiles, sourceFiles := 0, 0
+	for path, detail := range result.FileAnalysis {
+		switch {
+		case detail.TestsAffected:
+			testFiles++
+		case detail.Language == "Unknown", matchesAny(a.docPatterns, path), matchesAny(a.configPatterns, path):
+			// Not source code
+		default:
+			sourceFiles++
 		}
 	}
 
 	if testFiles > 0 {
 		result.TestCoverage = fmt.Sprintf("Tests included (%d test files modified)", testFiles)
-	} else if hasTests {
-		result.TestCoverage = "Existing tests may be affected"
+	} else if sourceFiles == 0 {
+		result.TestCoverage = noSourceChanges
 	} else {
-		result.TestCoverage = "No test changes detected - consider adding tests"
+		result.TestCoverage = fmt.Sprintf("No test changes for %d changed source files - consider adding tests", sourceFiles)
 		result.Suggestions = append(result.Suggestions, "Add tests for new functionality")
 	}
 }
 
-// analyzeDependencies checks for dependency changes
+// analyzeDependencies compares the versions removed from and added to
+// go.mod, package.json and requirements files
 func (a *PRAnalyzer) analyzeDependencies(pr prInfo, result *PRAnalysis) {
-	description := strings.ToLower(pr.Title + " " + pr.Description)
-
-	// Check for dependency-related keywords
-	depKeywords := []string{"dependency", "package", "library", "module", "upgrade", "update", "version"}
-	for _, keyword := range depKeywords {
-		if strings.Contains(description, keyword) {
-			// Add placeholder dependency change
-			result.DependencyChanges = append(result.DependencyChanges, DependencyChange{
-				Type:  "updated",
-				Risk:  "low",
-				Notes: "Dependency update detected - verify compatibility",
-			})
-			break
+	for _, file := range pr.Files {
+		if !isManifest(file.Path) {
+			continue
+		}
+		manifest := filepath.Base(file.Path)
+		removed := manifestVersions(manifest, file.Removed)
+		added := manifestVersions(manifest, file.Added)
+
+		for _, name := range sortedKeys(added) {
+			newVersion := added[name]
+			oldVersion, ok := removed[name]
+			switch {
+			case !ok:
+				result.DependencyChanges = append(result.DependencyChanges, DependencyChange{
+					Name:       name,
+					Type:       "added",
+					NewVersion: newVersion,
+					Risk:       "low",
+					Notes:      "New dependency - check its license and maintenance",
+				})
+			case oldVersion != newVersion:
+				change := DependencyChange{
+					Name:       name,
+					Type:       "updated",
+					OldVersion: oldVersion,
+					NewVersion: newVersion,
+					Risk:       "low",
+					Notes:      depscan.UpdateType(oldVersion, newVersion) + " update",
+				}
+				if depscan.CompareVersions(newVersion, oldVersion) < 0 {
+					change.Notes = "Downgrade - confirm it's intended"
+					change.Risk = "medium"
+				} else if depscan.UpdateType(oldVersion, newVersion) == "major" {
+					change.Notes = "Major update - check the changelog for breaking changes"
+					change.Risk = "medium"
+				}
+				result.DependencyChanges = append(result.DependencyChanges, change)
+			}
+		}
+		for _, name := range sortedKeys(removed) {
+			if _, ok := added[name]; !ok {
+				result.DependencyChanges = append(result.DependencyChanges, DependencyChange{
+					Name:       name,
+					Type:       "removed",
+					OldVersion: removed[name],
+					Risk:       "low",
+					Notes:      "Dependency removed",
+				})
+			}
 		}
 	}
 }
 
-// analyzeAPIChanges detects API modifications
+// analyzeAPIChanges finds HTTP routes and exported declarations the PR adds,
+// removes or changes. Removing or changing them breaks callers.
 func (a *PRAnalyzer) analyzeAPIChanges(pr prInfo, result *PRAnalysis) {
-	description := strings.ToLower(pr.Title + " " + pr.Description)
-
-	// Check for API-related keywords
-	apiKeywords := []string{"api", "endpoint", "route", "handler", "controller"}
-	for _, keyword := range apiKeywords {
-		if strings.Contains(description, keyword) {
-			// Check if it's a breaking change
-			breaking := false
-			for _, pattern := range a.breakingPatterns {
-				if pattern.MatchString(description) {
-					breaking = true
-					break
-				}
-			}
+	addedRoutes, removedRoutes := map[string]string{}, map[string]string{}
+	addedDecls, removedDecls := map[string]string{}, map[string]string{}
+	for _, file := range pr.Files {
+		if matchesAny(a.testPatterns, file.Path) {
+			continue
+		}
+		for _, line := range file.Added {
+			a.collectAPI(line.Text, addedRoutes, addedDecls)
+		}
+		for _, line := range file.Removed {
+			a.collectAPI(line.Text, removedRoutes, removedDecls)
+		}
+	}
 
+	var names []string
+	breaking := 0
+	for _, route := range sortedKeys(addedRoutes) {
+		if _, ok := removedRoutes[route]; !ok {
+			method, path, _ := strings.Cut(route, " ")
+			result.APIChanges = append(result.APIChanges, APIChange{
+				Type:        "added",
+				Path:        path,
+				Method:      method,
+				Description: "New route",
+			})
+		}
+	}
+	for _, route := range sortedKeys(removedRoutes) {
+		if _, ok := addedRoutes[route]; !ok {
+			method, path, _ := strings.Cut(route, " ")
 			result.APIChanges = append(result.APIChanges, APIChange{
-				Type:        "modified",
-				Breaking:    breaking,
-				Description: "API changes detected - verify backward compatibility",
+				Type:        "removed",
+				Path:        path,
+				Method:      method,
+				Breaking:    true,
+				Description: "Route removed - clients calling it will fail",
 			})
+			names = append(names, "`"+strings.TrimSpace(route)+"`")
+			breaking++
+		}
+	}
 
-			if breaking {
-				result.Issues = append(result.Issues, Issue{
-					Type:        "Breaking Change",
-					Severity:    "high",
-					Description: "This PR may contain breaking API changes",
-					Suggestion:  "Document migration path for API consumers",
-				})
+	for _, name := range sortedKeys(removedDecls) {
+		declaration, ok := addedDecls[name]
+		if ok && declaration == removedDecls[name] {
+			continue // Moved, not changed
+		}
+		change := APIChange{Type: "removed", Path: name, Breaking: true, Description: "Exported declaration removed"}
+		if ok {
+			change.Type = "modified"
+			change.Description = "Exported signature changed"
+		}
+		result.APIChanges = append(result.APIChanges, change)
+		names = append(names, "`"+name+"`")
+		breaking++
+	}
+
+	if breaking > 0 {
+		description := "This PR removes or changes public APIs"
+		if len(names) > 5 {
+			names = append(names[:5], fmt.Sprintf("%d more", len(names)-5))
+		}
+		if len(names) > 0 {
+			description += ": " + strings.Join(names, ", ")
+		}
+		result.Issues = append(result.Issues, Issue{
+			Type:        "Breaking Change",
+			Severity:    "high",
+			Description: description,
+			Suggestion:  "Document migration path for API consumers",
+		})
+	}
+}
+
+// collectAPI records the route or exported declaration on a line of code,
+// keyed by "METHOD /path" or by name
+func (a *PRAnalyzer) collectAPI(text string, routes, decls map[string]string) {
+	if m := a.routePattern.FindStringSubmatch(text); m != nil {
+		routes[m[1]+" "+m[2]] = strings.TrimSpace(text)
+		return
+	}
+	for _, pattern := range a.breakingPatterns {
+		if m := pattern.FindStringSubmatch(text); m != nil {
+			decls[m[1]] = strings.TrimSpace(text)
+			return
+		}
+	}
+}
+
+// applyReview adds a diff review's findings to the analysis as issues
+func (a *PRAnalyzer) applyReview(review *CodeReview, result *PRAnalysis) {
+	for _, finding := range review.Findings {
+		result.Issues = append(result.Issues, Issue{
+			Type:        "Code Review",
+			Severity:    finding.Severity,
+			Description: finding.Message,
+			File:        finding.File,
+			Line:        finding.Line,
+			Suggestion:  finding.Suggestion,
+		})
+		if detail, ok := result.FileAnalysis[finding.File]; ok {
+			detail.Issues = append(detail.Issues, finding.Message)
+			if finding.Severity == "critical" || finding.Severity == "high" {
+				detail.Risk = "high"
 			}
-			break
+			result.FileAnalysis[finding.File] = detail
 		}
 	}
+	result.ReviewSummary = review.Summary
+	result.ReviewRisk = review.Risk
+}
+
+// analyzeLint adds the linters' findings that aren't security problems,
+// which analyzeSecurityRisks adds
+func (a *PRAnalyzer) analyzeLint(pr prInfo, result *PRAnalysis) {
+	found := 0
+	for _, issue := range pr.Lint {
+		if issue.Security {
+			continue
+		}
+		found++
+		if found <= maxPatternIssues {
+			addLintIssue("Lint", issue, result)
+		}
+	}
+
+	if found > maxPatternIssues {
+		result.Suggestions = append(result.Suggestions,
+			fmt.Sprintf("%d more lint findings weren't listed, see the branch's lint run", found-maxPatternIssues))
+	}
+	if found > 0 {
+		result.Suggestions = append(result.Suggestions, "Fix the problems the linters found in the changed files")
+	}
+}
+
+// addLintIssue adds a linter's finding as an issue of the given type
+func addLintIssue(issueType string, issue lint.Issue, result *PRAnalysis) {
+	result.Issues = append(result.Issues, Issue{
+		Type:        issueType,
+		Severity:    issue.Severity,
+		Description: issue.Title() + ": " + issue.Message,
+		File:        issue.File,
+		Line:        issue.Line,
+	})
+	if detail, ok := result.FileAnalysis[issue.File]; ok {
+		detail.Issues = append(detail.Issues, fmt.Sprintf("Line %d: %s", issue.Line, issue.Message))
+		if issue.Severity == lint.SeverityHigh {
+			detail.Risk = "high"
+		}
+		result.FileAnalysis[issue.File] = detail
+	}
 }
 
 // buildChecklist creates the review checklist
@@ -448,10 +768,11 @@ func (a *PRAnalyzer) buildChecklist(pr prInfo, result *PRAnalysis) {
 	})
 
 	// Test coverage check
+	testsCovered := strings.Contains(result.TestCoverage, "Tests included") || result.TestCoverage == noSourceChanges
 	result.ChecklistItems = append(result.ChecklistItems, ChecklistItem{
 		Description: "Tests included or updated",
-		Passed:      strings.Contains(result.TestCoverage, "Tests included"),
-		Warning:     !strings.Contains(result.TestCoverage, "Tests included"),
+		Passed:      testsCovered,
+		Warning:     !testsCovered,
 		Details:     result.TestCoverage,
 	})
 
@@ -532,74 +853,124 @@ func (a *PRAnalyzer) calculateRiskLevel(result *PRAnalysis) {
 	} else {
 		result.RiskLevel = "critical"
 	}
-}
 
-// checkAutoApproval determines if PR can be auto-approved
-func (a *PRAnalyzer) checkAutoApproval(result *PRAnalysis) {
-	// Start optimistic
-	result.AutoApprovalEligible = true
+	// The model read the actual changes, so its risk is a floor
+	if riskRank(result.ReviewRisk) > riskRank(result.RiskLevel) {
+		result.RiskLevel = result.ReviewRisk
+	}
+}
 
-	// Disqualifiers
-	if result.RiskLevel == "high" || result.RiskLevel == "critical" {
-		result.AutoApprovalEligible = false
-		return
+// checkAutoApproval decides whether the PR can be approved without a human
+// review, recording what stops it in AutoApprovalBlockers
+func (a *PRAnalyzer) checkAutoApproval(pr prInfo, result *PRAnalysis, policy *ApprovalPolicy) {
+	block := func(format string, args ...any) {
+		result.AutoApprovalBlockers = append(result.AutoApprovalBlockers, fmt.Sprintf(format, args...))
 	}
 
+	// Never approved, whatever the policy
+	if len(pr.Files) == 0 {
+		block("The diff couldn't be read")
+	}
+	if pr.Draft {
+		block("It's a draft")
+	}
+	if len(pr.ChangesRequested) > 0 {
+		block("Changes requested by %s", strings.Join(pr.ChangesRequested, ", "))
+	}
+	if result.RiskLevel != "low" {
+		block("Risk level is %s", result.RiskLevel)
+	}
 	if result.HasSecurity {
-		result.AutoApprovalEligible = false
-		return
+		block("Has security implications")
+	}
+	for _, api := range result.APIChanges {
+		if api.Breaking {
+			block("Contains breaking API changes")
+			break
+		}
 	}
 
-	if result.Complexity == "complex" || result.Complexity == "very complex" {
-		result.AutoApprovalEligible = false
-		return
+	// The repository's policy
+	if len(policy.AllowedCategories) > 0 {
+		var disallowed []string
+		for _, category := range result.Categories {
+			if !containsFold(policy.AllowedCategories, category) {
+				disallowed = append(disallowed, category)
+			}
+		}
+		if len(disallowed) > 0 {
+			block("Changes of type %s aren't allowed", strings.Join(disallowed, ", "))
+		}
 	}
 
-	// Check for breaking changes
-	for _, api := range result.APIChanges {
-		if api.Breaking {
-			result.AutoApprovalEligible = false
-			return
+	lines := pr.Additions + pr.Deletions
+	if policy.MaxLinesChanged > 0 && lines > policy.MaxLinesChanged {
+		block("Changes %d lines, more than the %d allowed", lines, policy.MaxLinesChanged)
+	}
+
+	var protected []string
+	for _, file := range pr.Files {
+		for _, pattern := range policy.ProtectedPaths {
+			if matchesPath(pattern, file.Path) || (file.OldPath != "" && matchesPath(pattern, file.OldPath)) {
+				protected = append(protected, file.Path)
+				break
+			}
 		}
 	}
+	if len(protected) > 0 {
+		block("Changes protected paths: %s", strings.Join(protected, ", "))
+	}
 
-	// Check if only safe categories
-	safeCategories := map[string]bool{
-		"documentation": true,
-		"testing":       true,
-		"ci/cd":         true,
+	if len(policy.AllowedAuthors) > 0 && !containsFold(policy.AllowedAuthors, pr.Author) {
+		block("Pull requests by %s need a human review", pr.Author)
 	}
 
-	allSafe := true
-	for _, cat := range result.Categories {
-		if !safeCategories[cat] {
-			allSafe = false
-			break
+	for _, check := range policy.RequiredChecks {
+		passed, ran := pr.Checks[check]
+		switch {
+		case !ran:
+			block("Required check %q hasn't finished on this branch", check)
+		case !passed:
+			block("Required check %q failed", check)
 		}
 	}
 
-	if result.AutoApprovalEligible && allSafe {
+	result.AutoApprovalEligible = len(result.AutoApprovalBlockers) == 0
+	if !result.AutoApprovalEligible {
+		return
+	}
+
+	result.AutoApprovalReasons = append(result.AutoApprovalReasons,
+		"Low risk, with no security implications or breaking changes")
+	if len(policy.AllowedCategories) > 0 {
 		result.AutoApprovalReasons = append(result.AutoApprovalReasons,
-			"Only contains safe change categories",
-			"Low risk assessment",
-			"No security implications",
-			"Simple complexity level",
-		)
-	} else if result.AutoApprovalEligible && result.RiskLevel == "low" {
+			"Only contains allowed change types: "+strings.Join(result.Categories, ", "))
+	}
+	if policy.MaxLinesChanged > 0 {
 		result.AutoApprovalReasons = append(result.AutoApprovalReasons,
-			"Low risk changes",
-			"No breaking changes detected",
-			"Minimal complexity",
-		)
-	} else {
-		result.AutoApprovalEligible = false
+			fmt.Sprintf("Changes %d lines, within the limit of %d", lines, policy.MaxLinesChanged))
+	}
+	if len(policy.ProtectedPaths) > 0 {
+		result.AutoApprovalReasons = append(result.AutoApprovalReasons, "No protected paths changed")
+	}
+	if len(policy.AllowedAuthors) > 0 {
+		result.AutoApprovalReasons = append(result.AutoApprovalReasons, "Opened by an allowed author, "+pr.Author)
+	}
+	if len(policy.RequiredChecks) > 0 {
+		result.AutoApprovalReasons = append(result.AutoApprovalReasons,
+			"Required checks passed: "+strings.Join(policy.RequiredChecks, ", "))
 	}
 }
 
 // generateRecommendation creates final recommendation
 func (a *PRAnalyzer) generateRecommendation(result *PRAnalysis) {
 	if result.AutoApprovalEligible {
-		result.Recommendation = "This PR appears safe and can be auto-approved after automated checks pass."
+		result.Recommendation = "This PR meets the auto-approval policy and can be approved without further review."
+		return
+	}
+
+	if len(result.FileAnalysis) == 0 {
+		result.Recommendation = "The diff couldn't be read, so only the title and description were analyzed. Review the changes manually."
 		return
 	}
 
@@ -670,60 +1041,168 @@ func (a *PRAnalyzer) estimateReviewTime(pr prInfo, result *PRAnalysis) {
 
 // Helper functions
 
+const (
+	// maxPatternIssues caps the issues raised by pattern matches on one PR
+	maxPatternIssues = 20
+
+	// noSourceChanges is the test coverage of a PR that only changes
+	// documentation, configuration or other non-code files
+	noSourceChanges = "No source code changes"
+)
+
 type prInfo struct {
 	Title        string
 	Description  string
+	Author       string
+	Checks       map[string]bool
 	Additions    int
 	Deletions    int
 	ChangedFiles int
-	Files        []string
+	Files        []diffFile
+	Lint         []lint.Issue // In the changed files
+
+	Draft            bool
+	ChangesRequested []string
 }
 
-func extractPRData(pr any) prInfo {
-	// This would extract data from the actual PR model
-	// For now, return placeholder data
+// extractPRData parses the PR's diff into its changed files and totals
+func extractPRData(pr PRChanges) prInfo {
 	info := prInfo{
-		Title:        "Sample PR",
-		Description:  "Sample description",
-		Additions:    100,
-		Deletions:    50,
-		ChangedFiles: 5,
-	}
-
-	// Extract from actual model if possible
-	if prModel, ok := pr.(interface {
-		GetTitle() string
-		GetDescription() string
-		GetAdditions() int
-		GetDeletions() int
-		GetChangedFiles() int
-	}); ok {
-		info.Title = prModel.GetTitle()
-		info.Description = prModel.GetDescription()
-		info.Additions = prModel.GetAdditions()
-		info.Deletions = prModel.GetDeletions()
-		info.ChangedFiles = prModel.GetChangedFiles()
+		Title:            pr.Title,
+		Description:      pr.Description,
+		Author:           pr.Author,
+		Checks:           pr.Checks,
+		Files:            parseDiff(pr.Diff),
+		Draft:            pr.Draft,
+		ChangesRequested: pr.ChangesRequested,
+	}
+	changed := make(map[string]bool, len(info.Files))
+	for _, file := range info.Files {
+		info.Additions += file.Additions
+		info.Deletions += file.Deletions
+		changed[file.Path] = true
+	}
+	info.ChangedFiles = len(info.Files)
+	for _, issue := range pr.Lint {
+		if changed[issue.File] {
+			info.Lint = append(info.Lint, issue)
+		}
 	}
-
 	return info
 }
 
-func (a *PRAnalyzer) extractFileList(pr prInfo) []string {
-	// This would extract from actual diff
-	// For now, simulate based on common patterns
-	files := []string{
-		"controllers/repos.go",
-		"models/repository.go",
-		"views/repo-list.html",
-		"README.md",
-		"go.mod",
+// matchesAny reports whether any of the patterns match s
+func matchesAny(patterns []*regexp.Regexp, s string) bool {
+	for _, pattern := range patterns {
+		if pattern.MatchString(s) {
+			return true
+		}
 	}
+	return false
+}
 
-	if pr.ChangedFiles > 0 {
-		return files[:min(pr.ChangedFiles, len(files))]
+// matchesPath reports whether file is under a directory pattern ending in
+// /, or matches a glob. Globs without a / match the file's name in any
+// directory.
+func matchesPath(pattern, file string) bool {
+	pattern = strings.TrimPrefix(pattern, "/")
+	if strings.HasSuffix(pattern, "/") {
+		return strings.HasPrefix(file, pattern)
 	}
+	if !strings.Contains(pattern, "/") {
+		file = filepath.Base(file)
+	}
+	matched, _ := filepath.Match(pattern, file)
+	return matched
+}
 
-	return files
+// containsFold reports whether list holds s, ignoring case
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// isCommentLine reports whether a line of code is only a comment
+func isCommentLine(text string) bool {
+	text = strings.TrimSpace(text)
+	for _, prefix := range []string{"//", "#", "/*", "*", "<!--"} {
+		if strings.HasPrefix(text, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// addCategory adds a category to the analysis unless it's already there
+func addCategory(result *PRAnalysis, category string) {
+	for _, existing := range result.Categories {
+		if existing == category {
+			return
+		}
+	}
+	result.Categories = append(result.Categories, category)
+}
+
+// isManifest reports whether a path is a dependency manifest whose version
+// changes can be read from the diff
+func isManifest(path string) bool {
+	switch name := filepath.Base(path); {
+	case name == "go.mod", name == "package.json":
+		return true
+	case strings.HasPrefix(name, "requirements") && strings.HasSuffix(name, ".txt"):
+		return true
+	}
+	return false
+}
+
+// manifestVersion matches a package.json dependency line
+var manifestVersion = regexp.MustCompile(`^\s*"([^"]+)"\s*:\s*"[\^~=]?(\d[^"]*)"`)
+
+// manifestVersions reads dependency versions from changed manifest lines,
+// keyed by package name
+func manifestVersions(manifest string, lines []diffLine) map[string]string {
+	versions := map[string]string{}
+	for _, line := range lines {
+		text := strings.TrimSpace(line.Text)
+		if i := strings.Index(text, "//"); i >= 0 && manifest == "go.mod" {
+			text = strings.TrimSpace(text[:i])
+		}
+
+		switch {
+		case manifest == "go.mod":
+			fields := strings.Fields(strings.TrimPrefix(text, "require "))
+			if len(fields) == 2 && strings.Contains(fields[0], ".") && strings.HasPrefix(fields[1], "v") {
+				versions[fields[0]] = fields[1]
+			}
+		case manifest == "package.json":
+			if m := manifestVersion.FindStringSubmatch(text); m != nil && m[1] != "version" {
+				versions[m[1]] = m[2]
+			}
+		default:
+			if i := strings.Index(text, "#"); i >= 0 {
+				text = text[:i]
+			}
+			if name, version, ok := strings.Cut(text, "=="); ok && strings.TrimSpace(name) != "" {
+				versions[strings.ToLower(strings.TrimSpace(name))] = strings.TrimSpace(version)
+			}
+		}
+	}
+	return versions
+}
+
+// sortedKeys returns a map's keys in order, so findings come out the same
+// way each run
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
 }
 
 func (a *PRAnalyzer) detectLanguage(filename string) string {
@@ -755,10 +1234,3 @@ func (a *PRAnalyzer) detectLanguage(filename string) string {
 
 	return "Unknown"
 }
-
-func min(a, b int) int {
-	if a < b {
-		return a
-	}
-	return b
-}
@@ -55,14 +55,15 @@ func (p *IssueTriageProcessor) ProcessEvent(event *AIEvent) error {
 		}
 	}
 	
-	// Add labels as tags
+	// Apply the suggested labels, creating those the repository lacks
 	for _, label := range analysis.Labels {
-		tag := &models.IssueTag{
-			IssueID: issue.ID,
-			Tag:     label,
+		tag, err := models.GetOrCreateTag(label, issue.RepoID)
+		if err != nil || tag == nil {
+			log.Printf("IssueTriageProcessor: Failed to create label %s: %v", label, err)
+			continue
 		}
-		if _, err := models.IssueTags.Insert(tag); err != nil {
-			log.Printf("IssueTriageProcessor: Failed to add tag %s: %v", label, err)
+		if err := models.AddLabelToIssue(issue.ID, tag.ID, "system"); err != nil {
+			log.Printf("IssueTriageProcessor: Failed to add label %s: %v", label, err)
 		}
 	}
 	
@@ -365,11 +366,9 @@ func (p *StaleCheckProcessor) ProcessEvent(event *AIEvent) error {
 	
 	for _, issue := range staleIssues {
 		// Add stale label
-		tag := &models.IssueTag{
-			IssueID: issue.ID,
-			Tag:     "stale",
+		if tag, err := models.GetOrCreateTag("stale", issue.RepoID); err == nil && tag != nil {
+			models.AddLabelToIssue(issue.ID, tag.ID, "system")
 		}
-		models.IssueTags.Insert(tag)
 		
 		// Would add stale comment here (IssueComment model doesn't exist yet)
 		log.Printf("StaleCheckProcessor: Would mark issue %s as stale", issue.ID)
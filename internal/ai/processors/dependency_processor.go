@@ -8,48 +8,68 @@ import (
 	"time"
 
 	"workspace/internal/ai/queue"
+	"workspace/internal/depscan"
 	"workspace/models"
 )
 
-// DependencyProcessor handles dependency update checks
-type DependencyProcessor struct{}
+// DependencyProcessor scans repository manifests for outdated and
+// vulnerable dependencies and keeps an issue open listing what it found
+type DependencyProcessor struct {
+	enabled func() bool // Whether scheduled dependency scans are switched on
+	scanner *depscan.Scanner
+}
 
-// NewDependencyProcessor creates a new dependency processor
-func NewDependencyProcessor() *DependencyProcessor {
-	return &DependencyProcessor{}
+// NewDependencyProcessor creates a new dependency processor. Scheduled
+// runs are skipped while enabled returns false.
+func NewDependencyProcessor(enabled func() bool) *DependencyProcessor {
+	return &DependencyProcessor{enabled: enabled, scanner: depscan.NewScanner()}
 }
 
-// Process handles dependency update tasks
+// Process scans the task's repository, or every repository when the task
+// doesn't name one
 func (p *DependencyProcessor) Process(ctx context.Context, task *queue.Task) error {
-	repoID, ok := task.Data["repo_id"].(string)
-	if !ok {
-		return fmt.Errorf("invalid repo ID in task data")
+	if scheduled, _ := task.Data["scheduled"].(bool); scheduled && p.enabled != nil && !p.enabled() {
+		return nil
 	}
 
-	// Get repository
-	repo, err := models.Repos.Get(repoID)
-	if err != nil {
-		return fmt.Errorf("failed to get repo: %w", err)
-	}
-
-	// Check for outdated dependencies
-	updates, err := p.checkDependencies(ctx, repo)
-	if err != nil {
-		return fmt.Errorf("dependency check failed: %w", err)
+	var repos []*models.Repository
+	if repoID, _ := task.Data["repo_id"].(string); repoID != "" {
+		repo, err := models.Repos.Get(repoID)
+		if err != nil {
+			return fmt.Errorf("failed to get repo: %w", err)
+		}
+		repos = append(repos, repo)
+	} else {
+		all, err := models.Repos.Search("")
+		if err != nil {
+			return fmt.Errorf("failed to get repositories: %w", err)
+		}
+		repos = all
 	}
 
-	// Create update report if needed
-	if len(updates) > 0 {
-		if err := p.createUpdateReport(repo, updates); err != nil {
-			return fmt.Errorf("failed to create update report: %w", err)
+	var scanned, outdated, vulnerable int
+	for _, repo := range repos {
+		scan, err := p.scanRepo(ctx, repo)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if len(repos) == 1 {
+				return err
+			}
+			log.Printf("DependencyProcessor: Failed to scan %s: %v", repo.Name, err)
+			continue
 		}
+		scanned++
+		outdated += scan.Outdated
+		vulnerable += scan.Vulnerable
 	}
 
 	task.Result = map[string]any{
-		"updates_available": len(updates),
-		"updates":           updates,
+		"repositories": scanned,
+		"outdated":     outdated,
+		"vulnerable":   vulnerable,
 	}
-
 	return nil
 }
 
@@ -58,292 +78,183 @@ func (p *DependencyProcessor) CanHandle(taskType queue.TaskType) bool {
 	return taskType == queue.TaskDependencyUpdate
 }
 
-// DependencyUpdate represents an available dependency update
-type DependencyUpdate struct {
-	Name           string `json:"name"`
-	CurrentVersion string `json:"current_version"`
-	LatestVersion  string `json:"latest_version"`
-	Type           string `json:"type"` // major, minor, patch
-	Security       bool   `json:"security"`
-	Breaking       bool   `json:"breaking"`
-	ReleaseNotes   string `json:"release_notes"`
-}
-
-// checkDependencies checks for outdated dependencies
-func (p *DependencyProcessor) checkDependencies(ctx context.Context, repo *models.Repository) ([]DependencyUpdate, error) {
-	var updates []DependencyUpdate
-
-	// This is a simplified check - in production would check actual package files
-	// For demonstration, we'll simulate finding some updates
-
-	// Simulate checking different package managers
-	lang := p.detectLanguage(repo)
+// scanRepo checks the manifests at the root of a repository's default
+// branch, records the scan and updates its issue
+func (p *DependencyProcessor) scanRepo(ctx context.Context, repo *models.Repository) (*models.DependencyScan, error) {
+	var deps []depscan.Dependency
+	var manifests []string
+	for _, name := range depscan.Manifests {
+		file, err := repo.GetFile("", name)
+		if err != nil {
+			continue
+		}
+		found, err := depscan.Parse(name, []byte(file.Content))
+		if err != nil {
+			log.Printf("DependencyProcessor: Skipping %s in %s: %v", name, repo.Name, err)
+			continue
+		}
+		manifests = append(manifests, name)
+		deps = append(deps, found...)
+	}
 
-	switch lang {
-	case "go":
-		updates = append(updates, p.checkGoModules(repo)...)
-	case "javascript":
-		updates = append(updates, p.checkNodePackages(repo)...)
-	case "python":
-		updates = append(updates, p.checkPythonPackages(repo)...)
-	default:
-		// Generic check
-		updates = append(updates, DependencyUpdate{
-			Name:           "example-lib",
-			CurrentVersion: "1.0.0",
-			LatestVersion:  "1.2.0",
-			Type:           "minor",
-			Security:       false,
-			Breaking:       false,
-			ReleaseNotes:   "Bug fixes and performance improvements",
-		})
+	result, err := p.scanner.Scan(ctx, deps)
+	if err != nil {
+		return nil, err
+	}
+	scan, err := models.RecordDependencyScan(repo.ID, manifests, result)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.syncIssue(repo, scan); err != nil {
+		return nil, fmt.Errorf("failed to update dependency issue: %w", err)
 	}
 
-	log.Printf("DependencyProcessor: Found %d dependency updates for repo %s", len(updates), repo.Name)
-	return updates, nil
+	log.Printf("DependencyProcessor: Checked %d dependencies in %s, %d outdated, %d vulnerable",
+		scan.Checked, repo.Name, scan.Outdated, scan.Vulnerable)
+	return scan, nil
 }
 
-// detectLanguage detects the primary language of the repository
-func (p *DependencyProcessor) detectLanguage(repo *models.Repository) string {
-	// Check for language-specific files in repo description or recent activity
-	desc := strings.ToLower(repo.Description)
-
-	if strings.Contains(desc, "go") || strings.Contains(desc, "golang") {
-		return "go"
-	}
-	if strings.Contains(desc, "javascript") || strings.Contains(desc, "node") || strings.Contains(desc, "react") {
-		return "javascript"
+// syncIssue opens an issue for the scan's findings, or updates the one
+// opened by an earlier scan, and closes it once nothing is left to fix
+func (p *DependencyProcessor) syncIssue(repo *models.Repository, scan *models.DependencyScan) error {
+	var issue *models.Issue
+	if scan.IssueID != "" {
+		if existing, err := models.Issues.Get(scan.IssueID); err == nil && existing.Status == models.IssueStatusOpen {
+			issue = existing
+		}
 	}
-	if strings.Contains(desc, "python") || strings.Contains(desc, "django") || strings.Contains(desc, "flask") {
-		return "python"
+
+	findings := scan.FindingList()
+	if len(findings) == 0 {
+		if issue == nil {
+			return nil
+		}
+		issue.Status = models.IssueStatusClosed
+		if err := models.Issues.Update(issue); err != nil {
+			return err
+		}
+		models.Comments.Insert(&models.Comment{
+			Body:       "All dependencies are up to date with no known vulnerabilities. Closing.",
+			AuthorID:   "system",
+			RepoID:     repo.ID,
+			EntityType: "issue",
+			EntityID:   issue.ID,
+		})
+		scan.IssueID = ""
+		return models.DependencyScans.Update(scan)
 	}
 
-	return "unknown"
-}
+	priority := models.PriorityLow
+	for _, finding := range findings {
+		switch {
+		case len(finding.Advisories) > 0:
+			priority = models.PriorityHigh
+		case finding.Update == "major" && priority == models.PriorityLow:
+			priority = models.PriorityMedium
+		}
+	}
 
-// checkGoModules checks for Go module updates
-func (p *DependencyProcessor) checkGoModules(repo *models.Repository) []DependencyUpdate {
-	// Simulated Go dependency updates
-	return []DependencyUpdate{
-		{
-			Name:           "github.com/gorilla/mux",
-			CurrentVersion: "v1.8.0",
-			LatestVersion:  "v1.8.1",
-			Type:           "patch",
-			Security:       false,
-			Breaking:       false,
-			ReleaseNotes:   "Minor bug fixes",
-		},
-		{
-			Name:           "github.com/stretchr/testify",
-			CurrentVersion: "v1.7.0",
-			LatestVersion:  "v1.8.4",
-			Type:           "minor",
-			Security:       false,
-			Breaking:       false,
-			ReleaseNotes:   "New assertion methods and improvements",
-		},
+	title := fmt.Sprintf("📦 Dependency Updates Available (%d packages)", len(findings))
+	if scan.Vulnerable > 0 {
+		title = fmt.Sprintf("🔒 Vulnerable Dependencies (%d vulnerable, %d outdated)", scan.Vulnerable, scan.Outdated)
 	}
-}
+	body := p.formatReport(repo, scan, findings)
 
-// checkNodePackages checks for Node.js package updates
-func (p *DependencyProcessor) checkNodePackages(repo *models.Repository) []DependencyUpdate {
-	// Simulated Node.js dependency updates
-	return []DependencyUpdate{
-		{
-			Name:           "express",
-			CurrentVersion: "4.18.0",
-			LatestVersion:  "4.18.2",
-			Type:           "patch",
-			Security:       true,
-			Breaking:       false,
-			ReleaseNotes:   "Security patch for CVE-2023-XXXXX",
-		},
-		{
-			Name:           "react",
-			CurrentVersion: "18.0.0",
-			LatestVersion:  "18.2.0",
-			Type:           "minor",
-			Security:       false,
-			Breaking:       false,
-			ReleaseNotes:   "Performance improvements and bug fixes",
-		},
+	if issue != nil {
+		issue.Title = title
+		issue.Body = body
+		issue.Priority = priority
+		return models.Issues.Update(issue)
 	}
-}
 
-// checkPythonPackages checks for Python package updates
-func (p *DependencyProcessor) checkPythonPackages(repo *models.Repository) []DependencyUpdate {
-	// Simulated Python dependency updates
-	return []DependencyUpdate{
-		{
-			Name:           "django",
-			CurrentVersion: "4.1.0",
-			LatestVersion:  "4.2.7",
-			Type:           "minor",
-			Security:       true,
-			Breaking:       false,
-			ReleaseNotes:   "Security updates and new features",
-		},
-		{
-			Name:           "requests",
-			CurrentVersion: "2.28.0",
-			LatestVersion:  "2.31.0",
-			Type:           "minor",
-			Security:       false,
-			Breaking:       false,
-			ReleaseNotes:   "Compatibility improvements",
-		},
+	issue, err := models.Issues.Insert(&models.Issue{
+		Title:    title,
+		Body:     body,
+		Status:   models.IssueStatusOpen,
+		Priority: priority,
+		RepoID:   repo.ID,
+		AuthorID: "system",
+	})
+	if err != nil {
+		return err
 	}
+	scan.IssueID = issue.ID
+	return models.DependencyScans.Update(scan)
 }
 
-// createUpdateReport creates an issue or PR with dependency updates
-func (p *DependencyProcessor) createUpdateReport(repo *models.Repository, updates []DependencyUpdate) error {
+// formatReport writes the issue body for a scan's findings
+func (p *DependencyProcessor) formatReport(repo *models.Repository, scan *models.DependencyScan, findings []depscan.Finding) string {
 	var report strings.Builder
 
 	report.WriteString("# 📦 Dependency Update Report\n\n")
 	report.WriteString(fmt.Sprintf("**Repository:** %s\n", repo.Name))
-	report.WriteString(fmt.Sprintf("**Date:** %s\n", time.Now().Format("January 2, 2006")))
-	report.WriteString(fmt.Sprintf("**Updates Available:** %d\n\n", len(updates)))
-
-	// Group updates by type
-	security := []DependencyUpdate{}
-	major := []DependencyUpdate{}
-	minor := []DependencyUpdate{}
-	patch := []DependencyUpdate{}
-
-	for _, update := range updates {
-		if update.Security {
-			security = append(security, update)
-		} else {
-			switch update.Type {
-			case "major":
-				major = append(major, update)
-			case "minor":
-				minor = append(minor, update)
-			case "patch":
-				patch = append(patch, update)
+	report.WriteString(fmt.Sprintf("**Scanned:** %s (%s)\n", time.Now().Format("January 2, 2006"), strings.Join(scan.ManifestList(), ", ")))
+	report.WriteString(fmt.Sprintf("**Dependencies Checked:** %d\n\n", scan.Checked))
+
+	ecosystems := map[string]bool{}
+	if scan.Vulnerable > 0 {
+		report.WriteString("## 🔒 Known Vulnerabilities\n\n")
+		report.WriteString("| Package | Current | Fixed In | Advisories |\n")
+		report.WriteString("|---------|---------|----------|------------|\n")
+		for _, f := range findings {
+			if len(f.Advisories) == 0 {
+				continue
 			}
-		}
-	}
-
-	// Security updates
-	if len(security) > 0 {
-		report.WriteString("## 🔒 Security Updates (Recommended)\n\n")
-		report.WriteString("| Package | Current | Latest | Notes |\n")
-		report.WriteString("|---------|---------|--------|-------|\n")
-		for _, u := range security {
-			report.WriteString(fmt.Sprintf("| %s | %s | **%s** | ⚠️ Security Update |\n",
-				u.Name, u.CurrentVersion, u.LatestVersion))
-		}
-		report.WriteString("\n")
-	}
-
-	// Major updates
-	if len(major) > 0 {
-		report.WriteString("## 🚀 Major Updates (Breaking Changes)\n\n")
-		report.WriteString("| Package | Current | Latest | Notes |\n")
-		report.WriteString("|---------|---------|--------|-------|\n")
-		for _, u := range major {
-			report.WriteString(fmt.Sprintf("| %s | %s | %s | May contain breaking changes |\n",
-				u.Name, u.CurrentVersion, u.LatestVersion))
-		}
-		report.WriteString("\n")
-	}
-
-	// Minor updates
-	if len(minor) > 0 {
-		report.WriteString("## ✨ Minor Updates (New Features)\n\n")
-		report.WriteString("| Package | Current | Latest | Notes |\n")
-		report.WriteString("|---------|---------|--------|-------|\n")
-		for _, u := range minor {
-			report.WriteString(fmt.Sprintf("| %s | %s | %s | %s |\n",
-				u.Name, u.CurrentVersion, u.LatestVersion, u.ReleaseNotes))
+			ecosystems[f.Ecosystem] = true
+			var ids []string
+			fixed := f.Latest
+			for _, a := range f.Advisories {
+				id := a.ID
+				if cves := a.CVEs(); len(cves) > 0 {
+					id = cves[0]
+				}
+				if a.Summary != "" {
+					id += ": " + strings.ReplaceAll(a.Summary, "|", "/")
+				}
+				ids = append(ids, id)
+				if a.Fixed != "" && (fixed == "" || depscan.CompareVersions(a.Fixed, fixed) > 0) {
+					fixed = a.Fixed
+				}
+			}
+			if fixed == "" {
+				fixed = "unknown"
+			}
+			report.WriteString(fmt.Sprintf("| %s | %s | **%s** | %s |\n", f.Name, f.Version, fixed, strings.Join(ids, "<br>")))
 		}
 		report.WriteString("\n")
 	}
 
-	// Patch updates
-	if len(patch) > 0 {
-		report.WriteString("## 🐛 Patch Updates (Bug Fixes)\n\n")
-		report.WriteString("| Package | Current | Latest |\n")
-		report.WriteString("|---------|---------|--------|\n")
-		for _, u := range patch {
-			report.WriteString(fmt.Sprintf("| %s | %s | %s |\n",
-				u.Name, u.CurrentVersion, u.LatestVersion))
+	if scan.Outdated > 0 {
+		report.WriteString("## ⬆️ Available Updates\n\n")
+		report.WriteString("| Package | Current | Latest | Type | Manifest |\n")
+		report.WriteString("|---------|---------|--------|------|----------|\n")
+		for _, f := range findings {
+			if f.Latest == "" {
+				continue
+			}
+			ecosystems[f.Ecosystem] = true
+			update := f.Update
+			if update == "major" {
+				update = "**major**, may contain breaking changes"
+			}
+			report.WriteString(fmt.Sprintf("| %s | %s | %s | %s | %s |\n", f.Name, f.Version, f.Latest, update, f.Manifest))
 		}
 		report.WriteString("\n")
 	}
 
-	// Recommendations
-	report.WriteString("## 💡 Recommendations\n\n")
-
-	if len(security) > 0 {
-		report.WriteString("1. **Apply security updates immediately** - These address known vulnerabilities\n")
+	report.WriteString("## 🛠️ Update Commands\n\n```bash\n")
+	if ecosystems[depscan.EcosystemGo] {
+		report.WriteString("go get -u ./... && go mod tidy\n")
 	}
-
-	report.WriteString("2. **Test updates in development** - Ensure compatibility before production\n")
-	report.WriteString("3. **Review breaking changes** - Major updates may require code changes\n")
-	report.WriteString("4. **Update incrementally** - Apply one update at a time for easier debugging\n")
-	report.WriteString("5. **Check release notes** - Understand what's changing in each update\n\n")
-
-	// Update commands
-	report.WriteString("## 🛠️ Update Commands\n\n")
-
-	lang := p.detectLanguage(repo)
-	switch lang {
-	case "go":
-		report.WriteString("```bash\n")
-		report.WriteString("# Update all dependencies\n")
-		report.WriteString("go get -u ./...\n")
-		report.WriteString("go mod tidy\n")
-		report.WriteString("```\n")
-	case "javascript":
-		report.WriteString("```bash\n")
-		report.WriteString("# Update dependencies\n")
+	if ecosystems[depscan.EcosystemNPM] {
 		report.WriteString("npm update\n")
-		report.WriteString("# Or for specific packages\n")
-		report.WriteString("npm install package@latest\n")
-		report.WriteString("```\n")
-	case "python":
-		report.WriteString("```bash\n")
-		report.WriteString("# Update dependencies\n")
+	}
+	if ecosystems[depscan.EcosystemPyPI] {
 		report.WriteString("pip install --upgrade -r requirements.txt\n")
-		report.WriteString("```\n")
 	}
+	report.WriteString("```\n")
 
 	report.WriteString("\n---\n")
-	report.WriteString("*This report was generated automatically by AI dependency scanning.*\n")
-
-	// Determine priority based on security updates
-	priority := "low"
-	if len(security) > 0 {
-		priority = "high"
-	} else if len(major) > 0 {
-		priority = "medium"
-	}
-
-	// Create issue
-	var issuePriority models.IssuePriority
-	switch priority {
-	case "critical":
-		issuePriority = models.PriorityCritical
-	case "high":
-		issuePriority = models.PriorityHigh
-	case "medium":
-		issuePriority = models.PriorityMedium
-	default:
-		issuePriority = models.PriorityLow
-	}
-
-	issue := &models.Issue{
-		Title:    fmt.Sprintf("📦 Dependency Updates Available (%d packages)", len(updates)),
-		Body:     report.String(),
-		Status:   models.IssueStatusOpen,
-		Priority: issuePriority,
-		RepoID:   repo.ID,
-		AuthorID: "system",
-	}
-
-	_, err := models.Issues.Insert(issue)
-	return err
+	report.WriteString("*This issue is updated by each dependency scan and closed once everything is current.*\n")
+	return report.String()
 }
@@ -115,6 +115,12 @@ func (p *IssueProcessor) applyAnalysis(issue *models.Issue, result *analysis.Iss
 
 // postComment posts an analysis comment on the issue
 func (p *IssueProcessor) postComment(task *queue.Task, issue *models.Issue, result *analysis.IssueAnalysis) error {
+	// A retry after a crash mustn't triage the issue in a second comment
+	if posted := queue.PostedComment(task); posted != "" {
+		log.Printf("IssueProcessor: Issue %s already has analysis comment %s", issue.ID, posted)
+		return nil
+	}
+	
 	commentBody := p.formatComment(issue, result)
 	
 	comment := &models.Comment{
@@ -125,9 +131,11 @@ func (p *IssueProcessor) postComment(task *queue.Task, issue *models.Issue, resu
 		RepoID:     task.RepoID,
 	}
 	
-	if _, err := models.Comments.Insert(comment); err != nil {
+	posted, err := models.Comments.Insert(comment)
+	if err != nil {
 		return fmt.Errorf("failed to insert comment: %w", err)
 	}
+	queue.RecordComment(task, posted.ID)
 	
 	log.Printf("IssueProcessor: Posted analysis comment on issue %s", issue.ID)
 	return nil
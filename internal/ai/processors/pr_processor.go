@@ -8,18 +8,24 @@ import (
 
 	"workspace/internal/ai/analysis"
 	"workspace/internal/ai/queue"
+	"workspace/internal/lint"
 	"workspace/models"
+	"workspace/services"
 )
 
 // PRProcessor handles pull request review and analysis
 type PRProcessor struct {
-	analyzer *analysis.PRAnalyzer
+	analyzer    *analysis.PRAnalyzer
+	autoApprove func() bool // Whether auto-approval is switched on globally
 }
 
-// NewPRProcessor creates a new PR processor
-func NewPRProcessor() *PRProcessor {
+// NewPRProcessor creates a new PR processor. Pull requests are only
+// approved while autoApprove returns true and their repository's policy
+// is enabled.
+func NewPRProcessor(autoApprove func() bool) *PRProcessor {
 	return &PRProcessor{
-		analyzer: analysis.NewPRAnalyzer(),
+		analyzer:    analysis.NewPRAnalyzer(),
+		autoApprove: autoApprove,
 	}
 }
 
@@ -36,21 +42,35 @@ func (p *PRProcessor) Process(ctx context.Context, task *queue.Task) error {
 		return fmt.Errorf("failed to get PR %s: %w", prID, err)
 	}
 	
-	// Analyze the PR
-	result, err := p.analyzer.Analyze(ctx, pr)
+	// Review the diff with the model, then analyze the PR with its findings
+	// against the repository's auto-approval policy
+	policy := models.GetAutoApprovalPolicy(pr.RepoID)
+	approving := policy.Enabled && p.autoApprove != nil && p.autoApprove()
+	diff := p.prDiff(pr)
+	review := p.reviewDiff(ctx, task, pr, diff)
+	result, err := p.analyzer.AnalyzeWithReview(ctx, analysis.PRChanges{
+		Title:            pr.Title,
+		Description:      pr.Body,
+		Diff:             diff,
+		Author:           p.authorName(pr),
+		Checks:           p.checkResults(policy, pr),
+		Lint:             p.lintIssues(pr),
+		Draft:            pr.Draft,
+		ChangesRequested: p.changesRequested(pr),
+	}, review, approvalPolicy(policy))
 	if err != nil {
 		return fmt.Errorf("failed to analyze PR: %w", err)
 	}
 	
-	// Check for auto-approval
-	if result.AutoApprovalEligible && result.RiskLevel == "low" {
-		if err := p.autoApprovePR(task, pr); err != nil {
+	// Approve only what the policy allows, and only while it's switched on
+	if approving && result.AutoApprovalEligible && pr.Status == "open" {
+		if err := p.autoApprovePR(task, pr, result); err != nil {
 			log.Printf("PRProcessor: Failed to auto-approve PR %s: %v", prID, err)
 		}
 	}
 	
 	// Post review comment
-	if err := p.postReview(task, pr, result); err != nil {
+	if err := p.postReview(task, pr, result, review, approving); err != nil {
 		return fmt.Errorf("failed to post review: %w", err)
 	}
 	
@@ -68,8 +88,9 @@ func (p *PRProcessor) CanHandle(taskType queue.TaskType) bool {
 	return taskType == queue.TaskPRReview || taskType == queue.TaskAutoApprove
 }
 
-// autoApprovePR automatically approves a PR if eligible
-func (p *PRProcessor) autoApprovePR(task *queue.Task, pr *models.PullRequest) error {
+// autoApprovePR approves a PR the analysis found eligible, commenting with
+// the policy rules it met
+func (p *PRProcessor) autoApprovePR(task *queue.Task, pr *models.PullRequest, result *analysis.PRAnalysis) error {
 	// Update PR status
 	pr.Status = "approved"
 	if err := models.PullRequests.Update(pr); err != nil {
@@ -77,19 +98,17 @@ func (p *PRProcessor) autoApprovePR(task *queue.Task, pr *models.PullRequest) er
 	}
 	
 	// Post approval comment
+	var body strings.Builder
+	body.WriteString("## ✅ Auto-Approved\n\n")
+	body.WriteString("This pull request meets the repository's auto-approval policy:\n")
+	for _, reason := range result.AutoApprovalReasons {
+		body.WriteString(fmt.Sprintf("- %s\n", reason))
+	}
+	body.WriteString("\n*Auto-approval by AI Assistant*")
 	comment := &models.Comment{
-		Body: `## ✅ Auto-Approved
-
-This pull request has been automatically approved as it contains only safe changes:
-- Documentation updates
-- Configuration file changes
-- Non-critical dependency updates
-
-The changes have been reviewed and determined to have minimal risk.
-
-*Auto-approval by AI Assistant*`,
+		Body:       body.String(),
 		AuthorID:   task.UserID,
-		EntityType: "pull_request",
+		EntityType: "pr",
 		EntityID:   pr.ID,
 		RepoID:     task.RepoID,
 	}
@@ -98,32 +117,188 @@ The changes have been reviewed and determined to have minimal risk.
 		return fmt.Errorf("failed to post approval comment: %w", err)
 	}
 	
+	services.NotifyChat(pr.RepoID, models.ChatEventAutoApproved, task.UserID, services.ChatNotice{
+		Number: pr.ID,
+		Title:  pr.Title,
+		Detail: strings.Join(result.AutoApprovalReasons, "; "),
+		URL:    "/repos/" + pr.RepoID + "/prs/" + pr.ID,
+	})
+
 	log.Printf("PRProcessor: Auto-approved PR %s", pr.ID)
 	return nil
 }
 
-// postReview posts a review comment on the PR
-func (p *PRProcessor) postReview(task *queue.Task, pr *models.PullRequest, result *analysis.PRAnalysis) error {
-	reviewBody := p.formatReview(pr, result)
+// postReview posts a review comment on the PR, followed by a comment on
+// each line the diff review found a problem with
+func (p *PRProcessor) postReview(task *queue.Task, pr *models.PullRequest, result *analysis.PRAnalysis, review *analysis.CodeReview, approving bool) error {
+	if posted := queue.PostedComment(task); posted != "" {
+		log.Printf("PRProcessor: PR %s already has review comment %s", pr.ID, posted)
+		return nil
+	}
+	
+	reviewBody := p.formatReview(pr, result, approving)
 	
 	comment := &models.Comment{
 		Body:       reviewBody,
 		AuthorID:   task.UserID,
-		EntityType: "pull_request",
+		EntityType: "pr",
 		EntityID:   pr.ID,
 		RepoID:     task.RepoID,
 	}
 	
-	if _, err := models.Comments.Insert(comment); err != nil {
+	posted, err := models.Comments.Insert(comment)
+	if err != nil {
 		return fmt.Errorf("failed to insert review comment: %w", err)
 	}
+	queue.RecordComment(task, posted.ID)
+	
+	if review != nil {
+		for _, finding := range review.Findings {
+			if finding.Line == 0 {
+				continue // Listed in the review comment instead
+			}
+			body := fmt.Sprintf("%s **%s**: %s", p.getSeverityEmoji(finding.Severity), finding.Severity, finding.Message)
+			if finding.Suggestion != "" {
+				body += "\n\n💡 " + finding.Suggestion
+			}
+			if _, err := models.CreateLineComment("pr", pr.ID, pr.RepoID, task.UserID, body, finding.File, finding.Line); err != nil {
+				log.Printf("PRProcessor: Failed to comment on %s:%d of PR %s: %v", finding.File, finding.Line, pr.ID, err)
+			}
+		}
+	}
 	
 	log.Printf("PRProcessor: Posted review on PR %s", pr.ID)
 	return nil
 }
 
-// formatReview formats the analysis results as a review comment
-func (p *PRProcessor) formatReview(pr *models.PullRequest, result *analysis.PRAnalysis) string {
+// authorName returns the handle of the PR's author, or its author ID for
+// bots and deleted users
+func (p *PRProcessor) authorName(pr *models.PullRequest) string {
+	if user, err := models.Users.Get(pr.AuthorID); err == nil && user.Handle != "" {
+		return user.Handle
+	}
+	return pr.AuthorID
+}
+
+// changesRequested returns the names of the reviewers still asking for
+// changes on the PR
+func (p *PRProcessor) changesRequested(pr *models.PullRequest) []string {
+	userIDs, err := pr.ChangesRequestedBy()
+	if err != nil {
+		log.Printf("PRProcessor: Failed to get reviews of PR %s: %v", pr.ID, err)
+	}
+	names := make([]string, 0, len(userIDs))
+	for _, userID := range userIDs {
+		if user, err := models.Users.Get(userID); err == nil {
+			names = append(names, user.Name)
+		} else {
+			names = append(names, userID)
+		}
+	}
+	return names
+}
+
+// checkResults returns the results of the policy's required checks on the
+// PR's branch
+func (p *PRProcessor) checkResults(policy *models.AutoApprovalPolicy, pr *models.PullRequest) map[string]bool {
+	branch := pr.CompareBranch
+	if branch == "" {
+		branch = pr.HeadBranch
+	}
+	results, err := policy.CheckResults(branch)
+	if err != nil {
+		log.Printf("PRProcessor: Failed to get check results for PR %s: %v", pr.ID, err)
+	}
+	return results
+}
+
+// lintIssues returns what the linters found the last time they ran on the
+// PR's branch
+func (p *PRProcessor) lintIssues(pr *models.PullRequest) []lint.Issue {
+	branch := pr.CompareBranch
+	if branch == "" {
+		branch = pr.HeadBranch
+	}
+	run := models.LatestLintRun(pr.RepoID, branch)
+	if run == nil {
+		return nil
+	}
+	return run.IssueList()
+}
+
+// approvalPolicy converts a repository's auto-approval policy for the
+// analyzer
+func approvalPolicy(policy *models.AutoApprovalPolicy) *analysis.ApprovalPolicy {
+	return &analysis.ApprovalPolicy{
+		AllowedCategories: policy.CategoryList(),
+		MaxLinesChanged:   policy.MaxLinesChanged,
+		RequiredChecks:    policy.RequiredCheckList(),
+		ProtectedPaths:    policy.ProtectedPathList(),
+		AllowedAuthors:    policy.AuthorList(),
+	}
+}
+
+// prDiff returns the PR's unified diff against its base branch, or "" when
+// it can't be read
+func (p *PRProcessor) prDiff(pr *models.PullRequest) string {
+	repo, err := models.Repositories.Get(pr.RepoID)
+	if err != nil {
+		log.Printf("PRProcessor: Failed to get repo for PR %s: %v", pr.ID, err)
+		return ""
+	}
+	compare := pr.CompareBranch
+	if compare == "" {
+		compare = pr.HeadBranch
+	}
+	diff, err := repo.GetPRDiffContent(pr.BaseBranch, compare)
+	if err != nil {
+		log.Printf("PRProcessor: Failed to get diff for PR %s: %v", pr.ID, err)
+		return ""
+	}
+	return diff
+}
+
+// reviewDiff has the model review the PR's diff, returning nil when the AI
+// assistant isn't running or the diff is empty or can't be reviewed
+func (p *PRProcessor) reviewDiff(ctx context.Context, task *queue.Task, pr *models.PullRequest, diff string) *analysis.CodeReview {
+	if diff == "" || services.Ollama == nil || !services.Ollama.IsRunning() {
+		return nil
+	}
+
+	review, err := analysis.NewCodeReviewer(p.chat(task)).Review(ctx, pr.Title, pr.Body, diff)
+	if err != nil {
+		log.Printf("PRProcessor: Failed to review diff of PR %s: %v", pr.ID, err)
+		return nil
+	}
+	log.Printf("PRProcessor: Reviewed %d files of PR %s, %d findings", review.FilesReviewed, pr.ID, len(review.Findings))
+	return review
+}
+
+// chat sends review prompts to the default model, recording the tokens
+// against the user who queued the task
+func (p *PRProcessor) chat(task *queue.Task) analysis.ChatFunc {
+	return func(ctx context.Context, system, prompt string) (string, error) {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		model := services.Ollama.GetDefaultModel()
+		response, err := services.Ollama.Chat(model, []services.OllamaMessage{
+			{Role: "system", Content: system},
+			{Role: "user", Content: prompt},
+		}, false)
+		if err != nil {
+			return "", err
+		}
+		if _, err := models.RecordTokenUsage(task.UserID, "", model, response.PromptEvalCount, response.EvalCount); err != nil {
+			log.Printf("PRProcessor: Failed to record token usage: %v", err)
+		}
+		return response.Message.Content, nil
+	}
+}
+
+// formatReview formats the analysis results as a review comment, with the
+// auto-approval outcome while approving is switched on for the repository
+func (p *PRProcessor) formatReview(pr *models.PullRequest, result *analysis.PRAnalysis, approving bool) string {
 	var b strings.Builder
 	
 	// Header with overall assessment
@@ -136,12 +311,12 @@ func (p *PRProcessor) formatReview(pr *models.PullRequest, result *analysis.PRAn
 	b.WriteString(fmt.Sprintf("**Estimated Review Time:** %s\n\n", result.EstimatedReviewTime))
 	
 	// Change summary
-	if pr.Additions > 0 || pr.Deletions > 0 || pr.ChangedFiles > 0 {
+	if result.ChangedFiles > 0 {
 		b.WriteString("### 📊 Change Summary\n")
-		b.WriteString(fmt.Sprintf("- **Files Changed:** %d\n", pr.ChangedFiles))
-		b.WriteString(fmt.Sprintf("- **Lines Added:** +%d\n", pr.Additions))
-		b.WriteString(fmt.Sprintf("- **Lines Deleted:** -%d\n", pr.Deletions))
-		b.WriteString(fmt.Sprintf("- **Net Change:** %+d lines\n\n", pr.Additions-pr.Deletions))
+		b.WriteString(fmt.Sprintf("- **Files Changed:** %d\n", result.ChangedFiles))
+		b.WriteString(fmt.Sprintf("- **Lines Added:** +%d\n", result.Additions))
+		b.WriteString(fmt.Sprintf("- **Lines Deleted:** -%d\n", result.Deletions))
+		b.WriteString(fmt.Sprintf("- **Net Change:** %+d lines\n\n", result.Additions-result.Deletions))
 	}
 	
 	// Categories detected
@@ -172,6 +347,12 @@ func (p *PRProcessor) formatReview(pr *models.PullRequest, result *analysis.PRAn
 	}
 	b.WriteString("\n")
 	
+	// What the model saw in the diff
+	if result.ReviewSummary != "" {
+		b.WriteString("### 🔍 Diff Review\n")
+		b.WriteString(result.ReviewSummary + "\n\n")
+	}
+	
 	// Issues found
 	if len(result.Issues) > 0 {
 		b.WriteString("### ⚠️ Issues Found\n")
@@ -202,6 +383,33 @@ func (p *PRProcessor) formatReview(pr *models.PullRequest, result *analysis.PRAn
 		b.WriteString("\n")
 	}
 	
+	// Dependency and API changes read from the diff
+	if len(result.DependencyChanges) > 0 {
+		b.WriteString("### 📦 Dependency Changes\n")
+		for _, dep := range result.DependencyChanges {
+			versions := dep.NewVersion
+			if dep.Type == "updated" {
+				versions = dep.OldVersion + " → " + dep.NewVersion
+			} else if dep.Type == "removed" {
+				versions = dep.OldVersion
+			}
+			b.WriteString(fmt.Sprintf("- `%s` %s %s - %s\n", dep.Name, dep.Type, versions, dep.Notes))
+		}
+		b.WriteString("\n")
+	}
+	if len(result.APIChanges) > 0 {
+		b.WriteString("### 🔌 API Changes\n")
+		for _, api := range result.APIChanges {
+			name := strings.TrimSpace(api.Method + " " + api.Path)
+			marker := ""
+			if api.Breaking {
+				marker = " ⚠️ breaking"
+			}
+			b.WriteString(fmt.Sprintf("- `%s` %s%s\n", name, api.Type, marker))
+		}
+		b.WriteString("\n")
+	}
+
 	// Security considerations
 	if result.HasSecurity {
 		b.WriteString("### 🔒 Security Considerations\n")
@@ -232,13 +440,20 @@ func (p *PRProcessor) formatReview(pr *models.PullRequest, result *analysis.PRAn
 	}
 	
 	// Auto-approval status
-	if result.AutoApprovalEligible {
+	if approving && result.AutoApprovalEligible {
 		b.WriteString("### ✅ Auto-Approval Status\n")
 		b.WriteString("This PR is eligible for auto-approval based on:\n")
 		for _, reason := range result.AutoApprovalReasons {
 			b.WriteString(fmt.Sprintf("- %s\n", reason))
 		}
 		b.WriteString("\n")
+	} else if approving {
+		b.WriteString("### ✋ Auto-Approval Status\n")
+		b.WriteString("This PR needs a human review:\n")
+		for _, blocker := range result.AutoApprovalBlockers {
+			b.WriteString(fmt.Sprintf("- %s\n", blocker))
+		}
+		b.WriteString("\n")
 	}
 	
 	// Overall recommendation
@@ -255,9 +470,16 @@ func (p *PRProcessor) formatReview(pr *models.PullRequest, result *analysis.PRAn
 
 // updatePRMetadata updates PR metadata based on analysis
 func (p *PRProcessor) updatePRMetadata(pr *models.PullRequest, result *analysis.PRAnalysis) error {
-	// Update status if auto-approved
-	if result.AutoApprovalEligible && pr.Status == "open" {
-		pr.Status = "approved"
+	changed := false
+
+	// Keep the change stats in line with the diff that was analyzed
+	if result.ChangedFiles > 0 && (pr.Additions != result.Additions || pr.Deletions != result.Deletions || pr.ChangedFiles != result.ChangedFiles) {
+		pr.Additions = result.Additions
+		pr.Deletions = result.Deletions
+		pr.ChangedFiles = result.ChangedFiles
+		changed = true
+	}
+	if changed {
 		return models.PullRequests.Update(pr)
 	}
 	
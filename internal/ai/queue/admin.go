@@ -0,0 +1,206 @@
+package queue
+
+import (
+	"container/heap"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+
+	"workspace/models"
+)
+
+// ErrTaskNotFound is returned for a task that isn't on the queue
+var ErrTaskNotFound = errors.New("task not found")
+
+// WorkerStatus describes one queue worker
+type WorkerStatus struct {
+	ID     int
+	Paused bool
+	Task   *Task // Task being processed, nil when idle
+}
+
+// Payload returns the task data as indented JSON
+func (t *Task) Payload() string {
+	payload, err := json.MarshalIndent(t.Data, "", "  ")
+	if err != nil {
+		return ""
+	}
+	return string(payload)
+}
+
+// Queued returns the tasks waiting to be processed, most urgent first
+func (q *Queue) Queued() []*Task {
+	q.mu.RLock()
+	tasks := make([]*Task, len(q.tasks))
+	copy(tasks, q.tasks)
+	q.mu.RUnlock()
+
+	sort.SliceStable(tasks, func(i, j int) bool {
+		if tasks[i].Priority != tasks[j].Priority {
+			return tasks[i].Priority < tasks[j].Priority
+		}
+		return tasks[i].CreatedAt.Before(tasks[j].CreatedAt)
+	})
+	return tasks
+}
+
+// Processing returns the tasks being processed, oldest first
+func (q *Queue) Processing() []*Task {
+	q.mu.RLock()
+	tasks := make([]*Task, 0, len(q.processing))
+	for _, task := range q.processing {
+		tasks = append(tasks, task)
+	}
+	q.mu.RUnlock()
+
+	sort.Slice(tasks, func(i, j int) bool {
+		return tasks[i].StartedAt.Before(*tasks[j].StartedAt)
+	})
+	return tasks
+}
+
+// Failed returns the most recent tasks that ran out of retries
+func (q *Queue) Failed(limit int) ([]*Task, error) {
+	records, err := models.AITasks.Search("WHERE Status = ? ORDER BY FinishedAt DESC LIMIT ?",
+		models.AITaskFailed, limit)
+	if err != nil {
+		return nil, err
+	}
+	tasks := make([]*Task, len(records))
+	for i, record := range records {
+		tasks[i] = taskFromRecord(record)
+	}
+	return tasks, nil
+}
+
+// Workers returns each worker and what it's doing
+func (q *Queue) Workers() []WorkerStatus {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	workers := make([]WorkerStatus, q.workers)
+	for id := range workers {
+		workers[id] = WorkerStatus{ID: id, Paused: q.paused[id], Task: q.active[id]}
+	}
+	return workers
+}
+
+// IsWorkerPaused returns whether a worker was paused
+func (q *Queue) IsWorkerPaused(id int) bool {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return q.paused[id]
+}
+
+// PauseWorker stops a worker taking new tasks, the task it's processing
+// still finishes
+func (q *Queue) PauseWorker(id int) error {
+	return q.setPaused(id, true)
+}
+
+// ResumeWorker lets a paused worker take tasks again
+func (q *Queue) ResumeWorker(id int) error {
+	return q.setPaused(id, false)
+}
+
+func (q *Queue) setPaused(id int, paused bool) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if id < 0 || id >= q.workers {
+		return fmt.Errorf("no worker %d", id)
+	}
+	q.paused[id] = paused
+	return nil
+}
+
+// Retry puts a failed task back on the queue with its retries reset
+func (q *Queue) Retry(id string) (*Task, error) {
+	record, err := models.AITasks.Get(id)
+	if err != nil || record.Status != models.AITaskFailed {
+		return nil, ErrTaskNotFound
+	}
+
+	task := taskFromRecord(record)
+	task.Retries = 0
+	task.Error = ""
+	task.StartedAt = nil
+	task.Status = StatusQueued
+	if err := q.Enqueue(task); err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+// Cancel removes a queued task, or stops one being processed
+func (q *Queue) Cancel(id string) (*Task, error) {
+	q.mu.Lock()
+	if cancel, ok := q.cancels[id]; ok {
+		task := q.processing[id]
+		task.Status = StatusCancelled
+		q.mu.Unlock()
+		cancel() // The worker records the cancellation
+		return task, nil
+	}
+
+	for i, task := range q.tasks {
+		if task.ID == id {
+			heap.Remove(&q.tasks, i)
+			task.Status = StatusCancelled
+			q.mu.Unlock()
+
+			q.persist(task)
+			q.logActivity(task, "cancelled", false)
+			log.Printf("AI Queue: Task %s was cancelled", task.ID)
+			return task, nil
+		}
+	}
+	q.mu.Unlock()
+	return nil, ErrTaskNotFound
+}
+
+// Bump raises a queued task's priority by one level
+func (q *Queue) Bump(id string) (*Task, error) {
+	q.mu.Lock()
+	for i, task := range q.tasks {
+		if task.ID == id {
+			if task.Priority > PriorityCritical {
+				task.Priority--
+				heap.Fix(&q.tasks, i)
+			}
+			q.mu.Unlock()
+
+			q.persist(task)
+			return task, nil
+		}
+	}
+	q.mu.Unlock()
+	return nil, ErrTaskNotFound
+}
+
+// taskFromRecord returns the task a stored record describes
+func taskFromRecord(record *models.AITask) *Task {
+	task := &Task{
+		ID:             record.ID,
+		Type:           TaskType(record.Type),
+		Priority:       record.Priority,
+		RepoID:         record.RepoID,
+		UserID:         record.UserID,
+		EntityType:     record.EntityType,
+		EntityID:       record.EntityID,
+		Data:           record.DecodeData(),
+		IdempotencyKey: record.IdempotencyKey,
+		CreatedAt:      record.CreatedAt,
+		Retries:        record.Retries,
+		MaxRetries:     record.MaxRetries,
+		Status:         TaskStatus(record.Status),
+		Error:          record.Error,
+	}
+	if !record.StartedAt.IsZero() {
+		startedAt := record.StartedAt
+		task.StartedAt = &startedAt
+	}
+	return task
+}
@@ -55,6 +55,10 @@ type Task struct {
 	Status     TaskStatus     `json:"status"`
 	Error      string         `json:"error,omitempty"`
 	Result     any            `json:"result,omitempty"`
+
+	// IdempotencyKey identifies the work the task does, a task is dropped
+	// while another with the same key is pending
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
 }
 
 // TaskStatus represents the status of a task
@@ -74,6 +78,9 @@ type Queue struct {
 	mu         sync.RWMutex
 	tasks      taskHeap
 	processing map[string]*Task
+	active     map[int]*Task                 // Task each worker is processing
+	paused     map[int]bool                  // Workers an admin paused
+	cancels    map[string]context.CancelFunc // Stops a processing task
 	workers    int
 	maxWorkers int
 	ctx        context.Context
@@ -136,6 +143,9 @@ func New(cfg *Config) *Queue {
 	q := &Queue{
 		tasks:           make(taskHeap, 0),
 		processing:      make(map[string]*Task),
+		active:          make(map[int]*Task),
+		paused:          make(map[int]bool),
+		cancels:         make(map[string]context.CancelFunc),
 		workers:         cfg.Workers,
 		maxWorkers:      cfg.MaxWorkers,
 		ctx:             ctx,
@@ -157,8 +167,24 @@ func (q *Queue) RegisterProcessor(taskType TaskType, processor Processor) {
 	q.processors[taskType] = processor
 }
 
-// Start begins processing tasks
+// OnTaskDone sets the handlers called when a task completes and when it
+// fails for good, after its retries
+func (q *Queue) OnTaskDone(complete func(*Task), fail func(*Task, error)) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.onTaskComplete = complete
+	q.onTaskFail = fail
+}
+
+// Start begins processing tasks, starting with those left pending by the
+// last run
 func (q *Queue) Start() {
+	if restored, err := q.Restore(); err != nil {
+		log.Printf("AI Queue: Failed to restore pending tasks: %v", err)
+	} else if restored > 0 {
+		log.Printf("AI Queue: Restored %d pending tasks", restored)
+	}
+
 	q.mu.Lock()
 	workers := q.workers
 	q.mu.Unlock()
@@ -192,8 +218,19 @@ func (q *Queue) Enqueue(task *Task) error {
 		task.MaxRetries = q.maxRetries
 	}
 
-	task.Status = StatusQueued
+	if task.IdempotencyKey == "" {
+		task.IdempotencyKey = defaultIdempotencyKey(task)
+	}
+	if q.isDuplicate(task) {
+		log.Printf("AI Queue: Skipping task %s, %s is already queued", task.ID, task.IdempotencyKey)
+		return nil
+	}
+
+	if task.Status != StatusRetrying {
+		task.Status = StatusQueued
+	}
 	task.CreatedAt = time.Now()
+	q.persist(task)
 
 	q.mu.Lock()
 	heap.Push(&q.tasks, task)
@@ -234,7 +271,9 @@ func (q *Queue) worker(id int) {
 		case <-q.ctx.Done():
 			return
 		case <-ticker.C:
-			q.processNext(id)
+			if !q.IsWorkerPaused(id) {
+				q.processNext(id)
+			}
 		}
 	}
 }
@@ -254,13 +293,19 @@ func (q *Queue) processNext(workerID int) {
 	task.StartedAt = &now
 	task.Status = StatusProcessing
 
+	ctx, cancel := context.WithTimeout(q.ctx, 5*time.Minute)
+	defer cancel()
+
 	q.mu.Lock()
 	q.processing[task.ID] = task
+	q.active[workerID] = task
+	q.cancels[task.ID] = cancel
 	q.mu.Unlock()
+	q.persist(task)
 
 	// Process the task
 	startTime := time.Now()
-	err := q.processTask(task)
+	err := q.processTask(ctx, task)
 	duration := time.Since(startTime)
 
 	// Update metrics
@@ -270,9 +315,16 @@ func (q *Queue) processNext(workerID int) {
 	// Handle result
 	q.mu.Lock()
 	delete(q.processing, task.ID)
+	delete(q.active, workerID)
+	delete(q.cancels, task.ID)
+	cancelled := task.Status == StatusCancelled
 	q.mu.Unlock()
 
-	if err != nil {
+	if cancelled {
+		log.Printf("AI Queue: Task %s was cancelled", task.ID)
+		q.persist(task)
+		q.logActivity(task, "cancelled", false)
+	} else if err != nil {
 		q.handleTaskError(task, err)
 	} else {
 		q.handleTaskSuccess(task, duration)
@@ -280,16 +332,12 @@ func (q *Queue) processNext(workerID int) {
 }
 
 // processTask executes a task using the appropriate processor
-func (q *Queue) processTask(task *Task) error {
+func (q *Queue) processTask(ctx context.Context, task *Task) error {
 	processor, exists := q.processors[task.Type]
 	if !exists {
 		return fmt.Errorf("no processor registered for task type: %s", task.Type)
 	}
 
-	// Create timeout context
-	ctx, cancel := context.WithTimeout(q.ctx, 5*time.Minute)
-	defer cancel()
-
 	return processor.Process(ctx, task)
 }
 
@@ -309,6 +357,7 @@ func (q *Queue) dequeue() *Task {
 // handleTaskSuccess handles successful task completion
 func (q *Queue) handleTaskSuccess(task *Task, duration time.Duration) {
 	task.Status = StatusCompleted
+	q.persist(task)
 
 	log.Printf("AI Queue: Task %s completed in %v", task.ID, duration)
 
@@ -334,6 +383,7 @@ func (q *Queue) handleTaskError(task *Task, err error) {
 		task.Priority++ // Lower priority for retry
 
 		atomic.AddUint64(&q.totalRetried, 1)
+		q.persist(task)
 
 		// Re-enqueue after delay
 		go func() {
@@ -346,6 +396,7 @@ func (q *Queue) handleTaskError(task *Task, err error) {
 	} else {
 		// Final failure
 		task.Status = StatusFailed
+		q.persist(task)
 
 		log.Printf("AI Queue: Task %s failed after %d retries: %v",
 			task.ID, task.MaxRetries, err)
@@ -441,6 +492,7 @@ func (q *Queue) scheduleDailyTasks() {
 		return
 	}
 
+	day := time.Now().Format("2006-01-02")
 	for _, repo := range repos {
 		task := &Task{
 			Type:     TaskDailyReport,
@@ -449,12 +501,24 @@ func (q *Queue) scheduleDailyTasks() {
 			Data: map[string]any{
 				"repo_id":   repo.ID,
 				"repo_name": repo.Name,
+				"scheduled": true,
 			},
+			IdempotencyKey: string(TaskDailyReport) + ":" + repo.ID + ":" + day,
 		}
 		q.Enqueue(task)
 	}
 
 	log.Printf("AI Queue: Scheduled %d daily report tasks", len(repos))
+
+	// Scan every repository's dependencies once a day
+	q.Enqueue(&Task{
+		Type:     TaskDependencyUpdate,
+		Priority: PriorityIdle,
+		Data: map[string]any{
+			"scheduled": true,
+		},
+		IdempotencyKey: string(TaskDependencyUpdate) + ":" + day,
+	})
 }
 
 // scheduleHourlyTasks schedules hourly maintenance tasks
@@ -465,6 +529,7 @@ func (q *Queue) scheduleHourlyTasks() {
 		Priority: PriorityIdle,
 		Data: map[string]any{
 			"check_type": "hourly",
+			"scheduled":  true,
 		},
 	}
 	q.Enqueue(task)
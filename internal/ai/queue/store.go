@@ -0,0 +1,132 @@
+package queue
+
+import (
+	"container/heap"
+	"encoding/json"
+	"log"
+	"time"
+
+	"workspace/models"
+)
+
+// defaultIdempotencyKey keys tasks about an issue or pull request by their
+// type and entity, so the same issue is never triaged twice
+func defaultIdempotencyKey(task *Task) string {
+	switch task.Type {
+	case TaskIssueTriage, TaskPRReview, TaskAutoApprove:
+		if task.EntityID != "" {
+			return string(task.Type) + ":" + task.EntityID
+		}
+	}
+	return ""
+}
+
+// isDuplicate returns whether a task with the same idempotency key is
+// already waiting or running. A retried task's own stored record doesn't
+// count.
+func (q *Queue) isDuplicate(task *Task) bool {
+	if task.IdempotencyKey == "" {
+		return false
+	}
+
+	q.mu.RLock()
+	for _, other := range q.tasks {
+		if other.IdempotencyKey == task.IdempotencyKey {
+			q.mu.RUnlock()
+			return true
+		}
+	}
+	for _, other := range q.processing {
+		if other.IdempotencyKey == task.IdempotencyKey {
+			q.mu.RUnlock()
+			return true
+		}
+	}
+	q.mu.RUnlock()
+
+	return models.FindPendingAITask(task.IdempotencyKey, task.ID) != nil
+}
+
+// persist stores the task's current state so it survives a restart
+func (q *Queue) persist(task *Task) {
+	data, err := json.Marshal(task.Data)
+	if err != nil {
+		log.Printf("AI Queue: Failed to encode task %s: %v", task.ID, err)
+		return
+	}
+
+	record, err := models.AITasks.Get(task.ID)
+	if err != nil {
+		record = models.NewAITask(task.ID)
+	}
+	record.Type = string(task.Type)
+	record.Priority = task.Priority
+	record.RepoID = task.RepoID
+	record.UserID = task.UserID
+	record.EntityType = task.EntityType
+	record.EntityID = task.EntityID
+	record.Data = string(data)
+	record.IdempotencyKey = task.IdempotencyKey
+	record.Status = string(task.Status)
+	record.Retries = task.Retries
+	record.MaxRetries = task.MaxRetries
+	record.Error = task.Error
+	if task.StartedAt != nil {
+		record.StartedAt = *task.StartedAt
+	}
+	switch task.Status {
+	case StatusCompleted, StatusFailed, StatusCancelled:
+		record.FinishedAt = time.Now()
+	default:
+		record.FinishedAt = time.Time{}
+	}
+
+	if err := models.SaveAITask(record); err != nil {
+		log.Printf("AI Queue: Failed to save task %s: %v", task.ID, err)
+	}
+}
+
+// Restore puts the tasks left pending when the server last stopped back on
+// the queue. Tasks that were being processed are retried, their processors
+// skip work an earlier attempt already finished.
+func (q *Queue) Restore() (int, error) {
+	interrupted, err := models.RecoverAITasks()
+	if err != nil {
+		return 0, err
+	}
+	if interrupted > 0 {
+		log.Printf("AI Queue: %d tasks were interrupted by a restart", interrupted)
+	}
+
+	records, err := models.PendingAITasks()
+	if err != nil {
+		return 0, err
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, record := range records {
+		heap.Push(&q.tasks, taskFromRecord(record))
+	}
+	return len(records), nil
+}
+
+// PostedComment returns the comment an earlier attempt at the same work
+// already posted, or "" when there is none
+func PostedComment(task *Task) string {
+	commentID := models.AITaskComment(task.IdempotencyKey)
+	if commentID == "" {
+		return ""
+	}
+	if _, err := models.Comments.Get(commentID); err != nil {
+		return "" // Deleted since, post it again
+	}
+	return commentID
+}
+
+// RecordComment remembers the comment the task posted
+func RecordComment(task *Task, commentID string) {
+	if err := models.RecordAITaskComment(task.ID, commentID); err != nil {
+		log.Printf("AI Queue: Failed to record comment for task %s: %v", task.ID, err)
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"workspace/internal/ai/queue"
 	"workspace/internal/ai/websocket"
 	"workspace/models"
+	"workspace/services"
 )
 
 // Service coordinates all AI components
@@ -97,6 +98,11 @@ func Initialize(config *Config) error {
 		// Register processors
 		Instance.registerProcessors()
 
+		// Tell repository webhooks about finished tasks
+		Instance.Queue.OnTaskDone(emitTaskWebhook, func(task *queue.Task, err error) {
+			emitTaskWebhook(task)
+		})
+
 		// Initialize WebSocket hub
 		Instance.Hub = websocket.NewHub()
 
@@ -161,24 +167,58 @@ func (s *Service) registerProcessors() {
 	s.Queue.RegisterProcessor(queue.TaskIssueTriage, processors.NewIssueProcessor())
 
 	// Register PR processor
-	s.Queue.RegisterProcessor(queue.TaskPRReview, processors.NewPRProcessor())
-	s.Queue.RegisterProcessor(queue.TaskAutoApprove, processors.NewPRProcessor())
+	prProcessor := processors.NewPRProcessor(func() bool {
+		return s.GetConfig().AutoApprove
+	})
+	s.Queue.RegisterProcessor(queue.TaskPRReview, prProcessor)
+	s.Queue.RegisterProcessor(queue.TaskAutoApprove, prProcessor)
 
 	// Register report processor
-	s.Queue.RegisterProcessor(queue.TaskDailyReport, processors.NewReportProcessor())
+	s.Queue.RegisterProcessor(queue.TaskDailyReport, processors.NewReportProcessor(func() bool {
+		return s.GetConfig().DailyReports
+	}))
 
 	// Register stale management processor
-	s.Queue.RegisterProcessor(queue.TaskStaleManagement, processors.NewStaleProcessor())
+	s.Queue.RegisterProcessor(queue.TaskStaleManagement, processors.NewStaleProcessor(func() bool {
+		return s.GetConfig().StaleManagement
+	}))
 
 	// Register security processor
 	s.Queue.RegisterProcessor(queue.TaskSecurityScan, processors.NewSecurityProcessor())
 
 	// Register dependency processor
-	s.Queue.RegisterProcessor(queue.TaskDependencyUpdate, processors.NewDependencyProcessor())
+	s.Queue.RegisterProcessor(queue.TaskDependencyUpdate, processors.NewDependencyProcessor(func() bool {
+		return s.GetConfig().DependencyUpdate
+	}))
 
 	log.Println("AI Service: Registered all processors")
 }
 
+// emitTaskWebhook sends an ai_task event to the webhooks of the task's
+// repository, if it has one
+func emitTaskWebhook(task *queue.Task) {
+	repoID := task.RepoID
+	if repoID == "" {
+		repoID, _ = task.Data["repo_id"].(string)
+	}
+	if repoID == "" {
+		return
+	}
+
+	action := "completed"
+	if task.Status == queue.StatusFailed {
+		action = "failed"
+	}
+	services.EmitWebhook(repoID, models.WebhookEventAI, action, task.UserID, map[string]any{
+		"id":          task.ID,
+		"type":        task.Type,
+		"entity_type": task.EntityType,
+		"entity_id":   task.EntityID,
+		"error":       task.Error,
+		"result":      task.Result,
+	})
+}
+
 // monitor runs periodic monitoring and broadcasts stats
 func (s *Service) monitor() {
 	ticker := time.NewTicker(5 * time.Second)
@@ -0,0 +1,164 @@
+// Package aicache keeps AI answers to repeated questions for a short time.
+// Only answers the model gave without calling tools are cached, since those
+// depend on nothing but the question and the conversation so far; anything
+// that looked at a repository could be stale on the next ask.
+package aicache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultTTL is how long an answer is reused
+	DefaultTTL = 10 * time.Minute
+
+	// DefaultMaxEntries bounds the memory the cache holds
+	DefaultMaxEntries = 512
+)
+
+// Cache maps questions to answers until they expire
+type Cache struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	entries    map[string]entry
+
+	hits      uint64
+	misses    uint64
+	evictions uint64
+}
+
+type entry struct {
+	answer  string
+	expires time.Time
+}
+
+// Stats describes how the cache is used, for monitoring
+type Stats struct {
+	Entries    int
+	MaxEntries int
+	TTL        time.Duration
+	Hits       uint64
+	Misses     uint64
+	Evictions  uint64
+}
+
+// HitRate returns the share of lookups answered from the cache, in percent
+func (s Stats) HitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) * 100 / float64(s.Hits+s.Misses)
+}
+
+// Responses is the cache shared by AI conversations
+var Responses = New(DefaultTTL, DefaultMaxEntries)
+
+// New creates a cache. A TTL of zero or less disables it.
+func New(ttl time.Duration, maxEntries int) *Cache {
+	return &Cache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    map[string]entry{},
+	}
+}
+
+// Normalize reduces a question to the form it is cached under, so changes
+// in case, spacing or closing punctuation ask the same thing
+func Normalize(question string) string {
+	question = strings.Join(strings.Fields(strings.ToLower(question)), " ")
+	return strings.TrimRight(question, "?!. ")
+}
+
+// Key identifies a question asked of a model, given everything else the
+// model was sent with it
+func Key(model, question, context string) string {
+	sum := sha256.Sum256([]byte(model + "\x00" + Normalize(question) + "\x00" + context))
+	return hex.EncodeToString(sum[:])
+}
+
+// Get returns the answer cached under key, if it hasn't expired
+func (c *Cache) Get(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.ttl <= 0 {
+		return "", false
+	}
+	e, ok := c.entries[key]
+	if !ok || time.Now().After(e.expires) {
+		delete(c.entries, key)
+		c.misses++
+		return "", false
+	}
+	c.hits++
+	return e.answer, true
+}
+
+// Put caches an answer under key for the TTL, making room by dropping
+// expired answers and then those closest to expiring
+func (c *Cache) Put(key, answer string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.ttl <= 0 || answer == "" {
+		return
+	}
+	if _, ok := c.entries[key]; !ok && c.maxEntries > 0 && len(c.entries) >= c.maxEntries {
+		c.evict()
+	}
+	c.entries[key] = entry{answer: answer, expires: time.Now().Add(c.ttl)}
+}
+
+// evict frees at least one entry. The caller must hold mu.
+func (c *Cache) evict() {
+	now := time.Now()
+	var oldest string
+	for key, e := range c.entries {
+		if now.After(e.expires) {
+			delete(c.entries, key)
+			continue
+		}
+		if oldest == "" || e.expires.Before(c.entries[oldest].expires) {
+			oldest = key
+		}
+	}
+	if len(c.entries) >= c.maxEntries && oldest != "" {
+		delete(c.entries, oldest)
+		c.evictions++
+	}
+}
+
+// SetTTL changes how long new answers are kept. Zero disables the cache.
+func (c *Cache) SetTTL(ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ttl = ttl
+}
+
+// Clear drops every cached answer and returns how many there were
+func (c *Cache) Clear() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := len(c.entries)
+	c.entries = map[string]entry{}
+	return n
+}
+
+// Stats returns the cache's counters
+func (c *Cache) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return Stats{
+		Entries:    len(c.entries),
+		MaxEntries: c.maxEntries,
+		TTL:        c.ttl,
+		Hits:       c.hits,
+		Misses:     c.misses,
+		Evictions:  c.evictions,
+	}
+}
@@ -0,0 +1,72 @@
+package aicache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNormalizedQuestionsShareAnAnswer(t *testing.T) {
+	c := New(time.Minute, 10)
+
+	c.Put(Key("llama3.2:3b", "What repos do I have?", "ctx"), "Two.")
+	answer, ok := c.Get(Key("llama3.2:3b", "  what REPOS do i   have", "ctx"))
+	if !ok || answer != "Two." {
+		t.Fatalf("expected the cached answer, got %q, %v", answer, ok)
+	}
+
+	// Another model or conversation is a different question
+	if _, ok := c.Get(Key("gpt-oss:20b", "what repos do i have", "ctx")); ok {
+		t.Error("answer reused across models")
+	}
+	if _, ok := c.Get(Key("llama3.2:3b", "what repos do i have", "other")); ok {
+		t.Error("answer reused across contexts")
+	}
+
+	stats := c.Stats()
+	if stats.Hits != 1 || stats.Misses != 2 {
+		t.Errorf("expected 1 hit and 2 misses, got %d and %d", stats.Hits, stats.Misses)
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	c := New(20*time.Millisecond, 10)
+	c.Put("key", "answer")
+	time.Sleep(30 * time.Millisecond)
+
+	if _, ok := c.Get("key"); ok {
+		t.Fatal("expired answer returned")
+	}
+	if c.Stats().Entries != 0 {
+		t.Error("expired answer kept")
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	c := New(0, 10)
+	c.Put("key", "answer")
+	if _, ok := c.Get("key"); ok {
+		t.Fatal("disabled cache returned an answer")
+	}
+}
+
+func TestEviction(t *testing.T) {
+	c := New(time.Minute, 2)
+	c.Put("first", "1")
+	time.Sleep(time.Millisecond)
+	c.Put("second", "2")
+	c.Put("third", "3")
+
+	if _, ok := c.Get("first"); ok {
+		t.Error("answer closest to expiring wasn't evicted")
+	}
+	if _, ok := c.Get("third"); !ok {
+		t.Error("new answer missing")
+	}
+	if stats := c.Stats(); stats.Entries != 2 || stats.Evictions != 1 {
+		t.Errorf("expected 2 entries and 1 eviction, got %d and %d", stats.Entries, stats.Evictions)
+	}
+
+	if n := c.Clear(); n != 2 {
+		t.Errorf("expected to clear 2 answers, cleared %d", n)
+	}
+}
@@ -3,15 +3,25 @@ package backup
 import (
 	"archive/tar"
 	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
 	"time"
+
+	"workspace/internal/storage"
 )
 
+// storedPrefix marks the path of a backup that is only in file storage
+const storedPrefix = "storage:"
+
 // BackupConfig holds backup configuration
 type BackupConfig struct {
 	// Paths to backup
@@ -23,9 +33,23 @@ type BackupConfig struct {
 	// Backup destination
 	BackupDir      string
 	
-	// Retention settings
+	// Remote storage each backup is copied to when set, so backups survive
+	// losing the host: file storage, or a destination of their own
+	Store          storage.Store
+	
+	// Key archives are encrypted with, nil leaves them unencrypted
+	EncryptionKey  []byte
+	
+	// Opens a test restored database to count its rows, nil only checks
+	// that it is an SQLite file
+	CheckDatabase  DatabaseCheck
+	
+	// Retention settings, for the copies on the host and then those in
+	// remote storage, which follow the host's when zero
 	RetentionDays  int
 	MaxBackups     int
+	RemoteRetentionDays int
+	RemoteMaxBackups    int
 	
 	// Schedule
 	Schedule       string // cron expression
@@ -52,10 +76,26 @@ func DefaultBackupConfig() *BackupConfig {
 	}
 }
 
+// RemoteRetention returns how many days and how many backups are kept in
+// remote storage
+func (c *BackupConfig) RemoteRetention() (int, int) {
+	days, count := c.RemoteRetentionDays, c.RemoteMaxBackups
+	if days == 0 {
+		days = c.RetentionDays
+	}
+	if count == 0 {
+		count = c.MaxBackups
+	}
+	return days, count
+}
+
 // BackupManager handles backup operations
 type BackupManager struct {
 	config *BackupConfig
 	stopCh chan struct{}
+	
+	mu     sync.Mutex
+	upload UploadProgress
 }
 
 // NewBackupManager creates a new backup manager
@@ -70,7 +110,8 @@ func NewBackupManager(config *BackupConfig) *BackupManager {
 	}
 }
 
-// CreateBackup creates a full backup
+// CreateBackup creates a full backup, encrypted when a key is set, and
+// records its checksums in a manifest before copying it to remote storage
 func (bm *BackupManager) CreateBackup() (string, error) {
 	// Create backup directory if it doesn't exist
 	if err := os.MkdirAll(bm.config.BackupDir, 0755); err != nil {
@@ -78,9 +119,17 @@ func (bm *BackupManager) CreateBackup() (string, error) {
 	}
 	
 	// Generate backup filename with timestamp
-	timestamp := time.Now().Format("20060102-150405")
-	backupName := fmt.Sprintf("workspace-backup-%s.tar.gz", timestamp)
+	created := time.Now()
+	backupName := fmt.Sprintf("workspace-backup-%s.tar.gz", created.Format("20060102-150405"))
+	key := bm.config.EncryptionKey
+	if key != nil {
+		backupName += EncryptedSuffix
+	}
 	backupPath := filepath.Join(bm.config.BackupDir, backupName)
+	manifest := &Manifest{Name: backupName, Created: created, Encrypted: key != nil}
+	if key != nil {
+		manifest.KeyID = KeyID(key)
+	}
 	
 	// Create backup file
 	file, err := os.Create(backupPath)
@@ -89,8 +138,20 @@ func (bm *BackupManager) CreateBackup() (string, error) {
 	}
 	defer file.Close()
 	
+	// The checksum is of the archive as stored, after encryption
+	sum := sha256.New()
+	var out io.Writer = io.MultiWriter(file, sum)
+	var encrypter io.WriteCloser
+	if key != nil {
+		if encrypter, err = NewEncryptWriter(out, key); err != nil {
+			os.Remove(backupPath)
+			return "", fmt.Errorf("failed to encrypt backup: %w", err)
+		}
+		out = encrypter
+	}
+	
 	// Create gzip writer
-	gzWriter := gzip.NewWriter(file)
+	gzWriter := gzip.NewWriter(out)
 	defer gzWriter.Close()
 	
 	// Create tar writer
@@ -99,25 +160,25 @@ func (bm *BackupManager) CreateBackup() (string, error) {
 	
 	// Backup database
 	log.Printf("Backing up database: %s", bm.config.DatabasePath)
-	if err := bm.addFileToTar(tarWriter, bm.config.DatabasePath, "database/workspace.db"); err != nil {
+	if err := bm.addFileToTar(tarWriter, manifest, bm.config.DatabasePath, "database/workspace.db"); err != nil {
 		log.Printf("Warning: Failed to backup database: %v", err)
 	}
 	
 	// Backup repositories
 	log.Printf("Backing up repositories: %s", bm.config.ReposPath)
-	if err := bm.addDirectoryToTar(tarWriter, bm.config.ReposPath, "repos"); err != nil {
+	if err := bm.addDirectoryToTar(tarWriter, manifest, bm.config.ReposPath, "repos"); err != nil {
 		log.Printf("Warning: Failed to backup repositories: %v", err)
 	}
 	
 	// Backup secrets (vault)
 	log.Printf("Backing up secrets: %s", bm.config.SecretsPath)
-	if err := bm.addDirectoryToTar(tarWriter, bm.config.SecretsPath, "vault"); err != nil {
+	if err := bm.addDirectoryToTar(tarWriter, manifest, bm.config.SecretsPath, "vault"); err != nil {
 		log.Printf("Warning: Failed to backup secrets: %v", err)
 	}
 	
 	// Backup uploads
 	log.Printf("Backing up uploads: %s", bm.config.UploadsPath)
-	if err := bm.addDirectoryToTar(tarWriter, bm.config.UploadsPath, "uploads"); err != nil {
+	if err := bm.addDirectoryToTar(tarWriter, manifest, bm.config.UploadsPath, "uploads"); err != nil {
 		log.Printf("Warning: Failed to backup uploads: %v", err)
 	}
 	
@@ -125,14 +186,44 @@ func (bm *BackupManager) CreateBackup() (string, error) {
 	metadata := fmt.Sprintf(`Backup created: %s
 Version: 1.0
 Type: Full Backup
-`, time.Now().Format(time.RFC3339))
+`, created.Format(time.RFC3339))
 	
-	if err := bm.addStringToTar(tarWriter, metadata, "backup.info"); err != nil {
+	if err := bm.addStringToTar(tarWriter, manifest, metadata, "backup.info"); err != nil {
 		log.Printf("Warning: Failed to add metadata: %v", err)
 	}
 	
+	// Finish the archive before copying it
+	if err := tarWriter.Close(); err != nil {
+		return "", fmt.Errorf("failed to write backup: %w", err)
+	}
+	if err := gzWriter.Close(); err != nil {
+		return "", fmt.Errorf("failed to write backup: %w", err)
+	}
+	if encrypter != nil {
+		if err := encrypter.Close(); err != nil {
+			return "", fmt.Errorf("failed to write backup: %w", err)
+		}
+	}
+	if err := file.Close(); err != nil {
+		return "", fmt.Errorf("failed to write backup: %w", err)
+	}
+	
+	info, err := os.Stat(backupPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to write backup: %w", err)
+	}
+	manifest.Size = info.Size()
+	manifest.SHA256 = hex.EncodeToString(sum.Sum(nil))
+	if err := bm.saveManifest(manifest, true, false); err != nil {
+		log.Printf("Warning: Failed to write manifest: %v", err)
+	}
+	
 	log.Printf("Backup created successfully: %s", backupPath)
 	
+	if err := bm.storeBackup(backupPath, manifest); err != nil {
+		log.Printf("Warning: Failed to copy backup to %s: %v", bm.config.Store, err)
+	}
+	
 	// Clean old backups
 	if err := bm.cleanOldBackups(); err != nil {
 		log.Printf("Warning: Failed to clean old backups: %v", err)
@@ -142,7 +233,7 @@ Type: Full Backup
 }
 
 // addFileToTar adds a single file to the tar archive
-func (bm *BackupManager) addFileToTar(tw *tar.Writer, sourcePath, targetPath string) error {
+func (bm *BackupManager) addFileToTar(tw *tar.Writer, m *Manifest, sourcePath, targetPath string) error {
 	// Check if file exists
 	info, err := os.Stat(sourcePath)
 	if err != nil {
@@ -173,12 +264,17 @@ func (bm *BackupManager) addFileToTar(tw *tar.Writer, sourcePath, targetPath str
 	}
 	
 	// Copy file content
-	_, err = io.Copy(tw, file)
-	return err
+	sum := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tw, sum), file)
+	if err != nil {
+		return err
+	}
+	m.addFile(targetPath, size, sum)
+	return nil
 }
 
 // addDirectoryToTar recursively adds a directory to the tar archive
-func (bm *BackupManager) addDirectoryToTar(tw *tar.Writer, sourcePath, targetPath string) error {
+func (bm *BackupManager) addDirectoryToTar(tw *tar.Writer, m *Manifest, sourcePath, targetPath string) error {
 	// Check if directory exists
 	if _, err := os.Stat(sourcePath); os.IsNotExist(err) {
 		return nil // Skip if doesn't exist
@@ -218,8 +314,12 @@ func (bm *BackupManager) addDirectoryToTar(tw *tar.Writer, sourcePath, targetPat
 			}
 			defer file.Close()
 			
-			_, err = io.Copy(tw, file)
-			return err
+			sum := sha256.New()
+			size, err := io.Copy(io.MultiWriter(tw, sum), file)
+			if err != nil {
+				return err
+			}
+			m.addFile(tarPath, size, sum)
 		}
 		
 		return nil
@@ -227,7 +327,7 @@ func (bm *BackupManager) addDirectoryToTar(tw *tar.Writer, sourcePath, targetPat
 }
 
 // addStringToTar adds a string as a file to the tar archive
-func (bm *BackupManager) addStringToTar(tw *tar.Writer, content, filename string) error {
+func (bm *BackupManager) addStringToTar(tw *tar.Writer, m *Manifest, content, filename string) error {
 	header := &tar.Header{
 		Name:    filename,
 		Size:    int64(len(content)),
@@ -239,28 +339,210 @@ func (bm *BackupManager) addStringToTar(tw *tar.Writer, content, filename string
 		return err
 	}
 	
-	_, err := tw.Write([]byte(content))
+	if _, err := tw.Write([]byte(content)); err != nil {
+		return err
+	}
+	sum := sha256.New()
+	sum.Write([]byte(content))
+	m.addFile(filename, int64(len(content)), sum)
+	return nil
+}
+
+// storeBackup copies a finished backup and its manifest to remote storage
+// when it is configured, tracking the upload's progress
+func (bm *BackupManager) storeBackup(backupPath string, manifest *Manifest) error {
+	store := bm.config.Store
+	if store == nil {
+		return nil
+	}
+	file, err := os.Open(backupPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	
+	bm.setUpload(UploadProgress{
+		Name:        manifest.Name,
+		Destination: store.String(),
+		Total:       manifest.Size,
+		Started:     time.Now(),
+	})
+	err = store.Put("backups/"+manifest.Name, &progressReader{r: file, bm: bm})
+	if err == nil {
+		// A copy of the wrong size was cut short on the way
+		var object storage.Object
+		if object, err = store.Stat("backups/" + manifest.Name); err == nil && object.Size != manifest.Size {
+			err = fmt.Errorf("uploaded copy is %d bytes, not %d", object.Size, manifest.Size)
+		}
+	}
+	if err == nil {
+		manifest.Destination = store.String()
+		manifest.Uploaded = time.Now()
+		err = bm.saveManifest(manifest, true, true)
+	}
+	bm.finishUpload(err)
 	return err
 }
 
-// RestoreBackup restores from a backup file
-func (bm *BackupManager) RestoreBackup(backupPath string) error {
+// UploadProgress is how far copying a backup to remote storage has got
+type UploadProgress struct {
+	Name        string
+	Destination string
+	Sent        int64
+	Total       int64
+	Started     time.Time
+	Finished    time.Time
+	Error       string
+}
+
+// Active reports whether the upload is still running
+func (p UploadProgress) Active() bool {
+	return !p.Started.IsZero() && p.Finished.IsZero()
+}
+
+// Percent returns how much has been sent, from 0 to 100
+func (p UploadProgress) Percent() int {
+	if p.Total <= 0 {
+		return 0
+	}
+	return int(p.Sent * 100 / p.Total)
+}
+
+// Upload returns the progress of the latest upload
+func (bm *BackupManager) Upload() UploadProgress {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	return bm.upload
+}
+
+// setUpload starts tracking an upload
+func (bm *BackupManager) setUpload(progress UploadProgress) {
+	bm.mu.Lock()
+	bm.upload = progress
+	bm.mu.Unlock()
+}
+
+// finishUpload records how an upload ended
+func (bm *BackupManager) finishUpload(err error) {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	bm.upload.Finished = time.Now()
+	if err != nil {
+		bm.upload.Error = err.Error()
+	}
+}
+
+// progressReader counts what has been read for the upload's progress
+type progressReader struct {
+	r  io.Reader
+	bm *BackupManager
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.bm.mu.Lock()
+	p.bm.upload.Sent += int64(n)
+	p.bm.mu.Unlock()
+	return n, err
+}
+
+// fetchBackup downloads a backup that is only in file storage into the
+// backup directory, returning its local path
+func (bm *BackupManager) fetchBackup(backupPath string) (string, error) {
+	name := filepath.Base(strings.TrimPrefix(backupPath, storedPrefix))
+	if bm.config.Store == nil {
+		return "", fmt.Errorf("backup %s is in file storage, which is not configured", name)
+	}
+	if err := os.MkdirAll(bm.config.BackupDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	
+	localPath := filepath.Join(bm.config.BackupDir, name)
+	if err := storage.Copy(storage.NewLocal(bm.config.BackupDir), name, bm.config.Store, "backups/"+name); err != nil {
+		return "", fmt.Errorf("failed to download backup %s: %w", name, err)
+	}
+	return localPath, nil
+}
+
+// openBackup opens a backup's archive for reading, downloading it first
+// when only remote storage has a copy. Encrypted backups are opened with
+// key, or the configured key when it is nil. When the backup has a manifest
+// its checksum is checked before anything is read from it.
+func (bm *BackupManager) openBackup(backupPath string, key []byte) (*openedBackup, error) {
+	stored := strings.HasPrefix(backupPath, storedPrefix)
+	if stored {
+		localPath, err := bm.fetchBackup(backupPath)
+		if err != nil {
+			return nil, err
+		}
+		backupPath = localPath
+	}
+	if key == nil {
+		key = bm.config.EncryptionKey
+	}
+	
+	name := filepath.Base(backupPath)
+	encrypted := strings.HasSuffix(name, EncryptedSuffix)
+	if manifest, err := bm.loadManifest(name, stored); err == nil {
+		if encrypted && key != nil && manifest.KeyID != KeyID(key) {
+			return nil, fmt.Errorf("backup was encrypted with key %s, not key %s; enter the recovery key it was made with", manifest.KeyID, KeyID(key))
+		}
+		if err := bm.verifyLocal(backupPath, manifest); err != nil {
+			return nil, fmt.Errorf("backup failed verification, nothing was restored: %w", err)
+		}
+	}
+	if encrypted && key == nil {
+		return nil, errors.New("backup is encrypted, enter its recovery key to restore it")
+	}
+	
 	// Open backup file
 	file, err := os.Open(backupPath)
 	if err != nil {
-		return fmt.Errorf("failed to open backup file: %w", err)
+		return nil, fmt.Errorf("failed to open backup file: %w", err)
+	}
+	
+	var archive io.Reader = file
+	if encrypted {
+		if archive, err = NewDecryptReader(file, key); err != nil {
+			file.Close()
+			return nil, err
+		}
 	}
-	defer file.Close()
 	
 	// Create gzip reader
-	gzReader, err := gzip.NewReader(file)
+	gzReader, err := gzip.NewReader(archive)
 	if err != nil {
-		return fmt.Errorf("failed to create gzip reader: %w", err)
+		file.Close()
+		return nil, fmt.Errorf("failed to create gzip reader: %w", err)
 	}
-	defer gzReader.Close()
 	
-	// Create tar reader
-	tarReader := tar.NewReader(gzReader)
+	return &openedBackup{Reader: tar.NewReader(gzReader), path: backupPath, file: file, gz: gzReader}, nil
+}
+
+// openedBackup is a backup's archive being read
+type openedBackup struct {
+	*tar.Reader
+	path string // Of the local copy
+	file *os.File
+	gz   *gzip.Reader
+}
+
+// Close closes the archive
+func (o *openedBackup) Close() error {
+	o.gz.Close()
+	return o.file.Close()
+}
+
+// RestoreBackup restores everything in a backup file over the data in
+// ~/.skyscape. Encrypted backups are opened with key, or the configured key
+// when it is nil.
+func (bm *BackupManager) RestoreBackup(backupPath string, key []byte) error {
+	tarReader, err := bm.openBackup(backupPath, key)
+	if err != nil {
+		return err
+	}
+	defer tarReader.Close()
+	backupPath = tarReader.path
 	
 	// Create restore directory
 	homeDir := os.Getenv("HOME")
@@ -333,84 +615,179 @@ func (bm *BackupManager) RestoreBackup(backupPath string) error {
 	return nil
 }
 
-// ListBackups returns a list of available backups
+// ListBackups returns a list of available backups, with their manifests
 func (bm *BackupManager) ListBackups() ([]BackupInfo, error) {
 	files, err := os.ReadDir(bm.config.BackupDir)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return []BackupInfo{}, nil
-		}
+	if err != nil && !os.IsNotExist(err) {
 		return nil, err
 	}
 	
 	var backups []BackupInfo
 	for _, file := range files {
-		if !file.IsDir() && strings.HasSuffix(file.Name(), ".tar.gz") {
+		if !file.IsDir() && isBackupName(file.Name()) {
 			info, err := file.Info()
 			if err != nil {
 				continue
 			}
 			
 			backups = append(backups, BackupInfo{
-				Name:     file.Name(),
-				Path:     filepath.Join(bm.config.BackupDir, file.Name()),
-				Size:     info.Size(),
-				Created:  info.ModTime(),
+				Name:      file.Name(),
+				Path:      filepath.Join(bm.config.BackupDir, file.Name()),
+				Size:      info.Size(),
+				Created:   info.ModTime(),
+				Encrypted: strings.HasSuffix(file.Name(), EncryptedSuffix),
 			})
 		}
 	}
 	
+	backups, err = bm.addStoredBackups(backups)
+	if err != nil {
+		return nil, err
+	}
+	for i := range backups {
+		if manifest, err := bm.loadManifest(backups[i].Name, backups[i].Stored); err == nil {
+			backups[i].Manifest = manifest
+			if !manifest.Created.IsZero() {
+				backups[i].Created = manifest.Created
+			}
+		}
+	}
+	if backups == nil {
+		backups = []BackupInfo{}
+	}
+	return backups, nil
+}
+
+// addStoredBackups marks the backups that have a copy in file storage and
+// adds the ones that are only there
+func (bm *BackupManager) addStoredBackups(backups []BackupInfo) ([]BackupInfo, error) {
+	if bm.config.Store == nil {
+		return backups, nil
+	}
+	stored, err := bm.config.Store.List("backups/")
+	if err != nil {
+		log.Printf("Warning: Failed to list backups in %s: %v", bm.config.Store, err)
+		return backups, nil
+	}
+	
+	local := make(map[string]int, len(backups))
+	for i, backup := range backups {
+		local[backup.Name] = i
+	}
+	for _, object := range stored {
+		name := strings.TrimPrefix(object.Key, "backups/")
+		if !isBackupName(name) || strings.Contains(name, "/") {
+			continue
+		}
+		if i, ok := local[name]; ok {
+			backups[i].Stored = true
+			continue
+		}
+		backups = append(backups, BackupInfo{
+			Name:      name,
+			Path:      storedPrefix + name,
+			Size:      object.Size,
+			Created:   object.Modified,
+			Stored:    true,
+			Encrypted: strings.HasSuffix(name, EncryptedSuffix),
+		})
+	}
 	return backups, nil
 }
 
-// cleanOldBackups removes backups older than retention period
+// removeLocal deletes the copy of a backup on the host
+func (bm *BackupManager) removeLocal(backup BackupInfo) error {
+	if strings.HasPrefix(backup.Path, storedPrefix) {
+		return nil
+	}
+	if err := os.Remove(backup.Path); err != nil {
+		return err
+	}
+	os.Remove(bm.manifestPath(backup.Name))
+	return nil
+}
+
+// removeStored deletes the copy of a backup in remote storage
+func (bm *BackupManager) removeStored(backup BackupInfo) error {
+	if !backup.Stored || bm.config.Store == nil {
+		return nil
+	}
+	if err := bm.config.Store.Delete("backups/" + backup.Name); err != nil {
+		return err
+	}
+	return bm.config.Store.Delete("backups/" + backup.Name + ManifestSuffix)
+}
+
+// cleanOldBackups applies the retention policies, the one for copies on
+// the host and the one for copies in remote storage
 func (bm *BackupManager) cleanOldBackups() error {
 	backups, err := bm.ListBackups()
 	if err != nil {
 		return err
 	}
+	slices.SortFunc(backups, func(a, b BackupInfo) int { return b.Created.Compare(a.Created) })
 	
-	// Remove backups older than retention period
-	cutoff := time.Now().Add(-time.Duration(bm.config.RetentionDays) * 24 * time.Hour)
+	var local, stored []BackupInfo
 	for _, backup := range backups {
-		if backup.Created.Before(cutoff) {
-			log.Printf("Removing old backup: %s", backup.Name)
-			if err := os.Remove(backup.Path); err != nil {
-				log.Printf("Warning: Failed to remove old backup %s: %v", backup.Name, err)
-			}
+		if !strings.HasPrefix(backup.Path, storedPrefix) {
+			local = append(local, backup)
+		}
+		if backup.Stored {
+			stored = append(stored, backup)
 		}
 	}
 	
-	// Keep only MaxBackups most recent
-	if len(backups) > bm.config.MaxBackups {
-		// Sort by creation time (newest first)
-		// Simple bubble sort for small lists
-		for i := 0; i < len(backups)-1; i++ {
-			for j := i + 1; j < len(backups); j++ {
-				if backups[i].Created.Before(backups[j].Created) {
-					backups[i], backups[j] = backups[j], backups[i]
-				}
-			}
+	retentionDays, maxBackups := bm.config.RemoteRetention()
+	pruneBackups(local, bm.config.RetentionDays, bm.config.MaxBackups, "old backup", bm.removeLocal)
+	pruneBackups(stored, retentionDays, maxBackups, "old backup copy in "+fmt.Sprint(bm.config.Store), bm.removeStored)
+	return nil
+}
+
+// pruneBackups removes the backups, newest first, that are older than the
+// retention period or beyond the most to keep. Zero leaves either unlimited.
+func pruneBackups(backups []BackupInfo, retentionDays, maxBackups int, kind string, remove func(BackupInfo) error) {
+	cutoff := time.Now().Add(-time.Duration(retentionDays) * 24 * time.Hour)
+	for i, backup := range backups {
+		expired := retentionDays > 0 && backup.Created.Before(cutoff)
+		excess := maxBackups > 0 && i >= maxBackups
+		if !expired && !excess {
+			continue
 		}
-		
-		// Remove excess backups
-		for i := bm.config.MaxBackups; i < len(backups); i++ {
-			log.Printf("Removing excess backup: %s", backups[i].Name)
-			if err := os.Remove(backups[i].Path); err != nil {
-				log.Printf("Warning: Failed to remove excess backup %s: %v", backups[i].Name, err)
-			}
+		log.Printf("Removing %s: %s", kind, backup.Name)
+		if err := remove(backup); err != nil {
+			log.Printf("Warning: Failed to remove %s %s: %v", kind, backup.Name, err)
 		}
 	}
-	
-	return nil
+}
+
+// findBackup looks up a backup by name
+func (bm *BackupManager) findBackup(name string) (*BackupInfo, error) {
+	backups, err := bm.ListBackups()
+	if err != nil {
+		return nil, err
+	}
+	for i := range backups {
+		if backups[i].Name == name {
+			return &backups[i], nil
+		}
+	}
+	return nil, fmt.Errorf("backup %s not found", name)
 }
 
 // BackupInfo holds information about a backup
 type BackupInfo struct {
-	Name    string
-	Path    string
-	Size    int64
-	Created time.Time
+	Name      string
+	Path      string
+	Size      int64
+	Created   time.Time
+	Stored    bool      // A copy is kept in file storage
+	Encrypted bool
+	Manifest  *Manifest // Nil for backups made before manifests were kept
+}
+
+// Local reports whether a copy is kept on the host
+func (bi BackupInfo) Local() bool {
+	return !strings.HasPrefix(bi.Path, storedPrefix)
 }
 
 // FormatSize formats bytes as human-readable string
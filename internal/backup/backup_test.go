@@ -0,0 +1,329 @@
+package backup
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"workspace/internal/storage"
+)
+
+func TestEncryption(t *testing.T) {
+	key, err := GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	// Spans several chunks, ending part way through one
+	plain := bytes.Repeat([]byte("backup "), 3*encryptChunkSize/7+100)
+
+	var sealed bytes.Buffer
+	w, err := NewEncryptWriter(&sealed, key)
+	if err != nil {
+		t.Fatalf("NewEncryptWriter: %v", err)
+	}
+	w.Write(plain[:1000])
+	w.Write(plain[1000:])
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if !IsEncrypted(sealed.Bytes()) || bytes.Contains(sealed.Bytes(), []byte("backup backup")) {
+		t.Fatal("output is not encrypted")
+	}
+
+	decrypt := func(data, key []byte) ([]byte, error) {
+		r, err := NewDecryptReader(bytes.NewReader(data), key)
+		if err != nil {
+			return nil, err
+		}
+		return io.ReadAll(r)
+	}
+
+	got, err := decrypt(sealed.Bytes(), key)
+	if err != nil || !bytes.Equal(got, plain) {
+		t.Fatalf("decrypt returned %d bytes, %v", len(got), err)
+	}
+
+	other, _ := GenerateKey()
+	if _, err := decrypt(sealed.Bytes(), other); !errors.Is(err, ErrWrongKey) {
+		t.Errorf("wrong key returned %v", err)
+	}
+
+	tampered := bytes.Clone(sealed.Bytes())
+	tampered[len(tampered)/2] ^= 1
+	if _, err := decrypt(tampered, key); !errors.Is(err, ErrWrongKey) {
+		t.Errorf("modified backup returned %v", err)
+	}
+
+	// Cutting the file at a chunk boundary must still be noticed
+	truncated := sealed.Bytes()[:encryptHeaderSize+4+encryptChunkSize+16]
+	if _, err := decrypt(truncated, key); err == nil || !strings.Contains(err.Error(), "truncated") {
+		t.Errorf("truncated backup returned %v", err)
+	}
+
+	if KeyID(key) == KeyID(other) || len(KeyID(key)) != 16 {
+		t.Errorf("KeyID = %q", KeyID(key))
+	}
+}
+
+// testWorkspace creates data to back up and a config for it
+func testWorkspace(t *testing.T) *BackupConfig {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string]string{
+		"workspace.db":             "database",
+		"repos/r1/HEAD":            "ref: refs/heads/main",
+		"repos/r1/objects/ab/cdef": "object",
+		"vault/secret":             "sealed",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &BackupConfig{
+		DatabasePath:  filepath.Join(dir, "workspace.db"),
+		ReposPath:     filepath.Join(dir, "repos"),
+		SecretsPath:   filepath.Join(dir, "vault"),
+		UploadsPath:   filepath.Join(dir, "uploads"),
+		BackupDir:     filepath.Join(dir, "backups"),
+		RetentionDays: 30,
+		MaxBackups:    10,
+	}
+}
+
+func TestEncryptedBackup(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	config := testWorkspace(t)
+	config.EncryptionKey, _ = GenerateKey()
+	remote := storage.NewLocal(t.TempDir())
+	config.Store = remote
+	manager := NewBackupManager(config)
+
+	path, err := manager.CreateBackup()
+	if err != nil {
+		t.Fatalf("CreateBackup: %v", err)
+	}
+	name := filepath.Base(path)
+	if !strings.HasSuffix(name, ".tar.gz"+EncryptedSuffix) {
+		t.Errorf("backup %s isn't named as encrypted", name)
+	}
+	if upload := manager.Upload(); upload.Error != "" || upload.Percent() != 100 {
+		t.Errorf("upload = %+v", upload)
+	}
+
+	backups, err := manager.ListBackups()
+	if err != nil || len(backups) != 1 {
+		t.Fatalf("ListBackups = %v, %v", backups, err)
+	}
+	backup := backups[0]
+	if !backup.Stored || !backup.Encrypted || backup.Manifest == nil {
+		t.Fatalf("backup = %+v", backup)
+	}
+	if len(backup.Manifest.Files) != 5 || backup.Manifest.Destination == "" {
+		t.Errorf("manifest = %+v", backup.Manifest)
+	}
+
+	manifest, err := manager.VerifyBackup(name)
+	if err != nil || !manifest.VerifiedOK() {
+		t.Fatalf("VerifyBackup: %v", err)
+	}
+
+	// A corrupted remote copy fails verification, the local one doesn't
+	remotePath, _ := remote.Path("backups/" + name)
+	data, _ := os.ReadFile(remotePath)
+	data[len(data)-1] ^= 1
+	os.WriteFile(remotePath, data, 0644)
+	manifest, err = manager.VerifyBackup(name)
+	if err == nil || !strings.Contains(manifest.VerifyError, "copy in") || strings.Contains(manifest.VerifyError, "local copy") {
+		t.Errorf("VerifyBackup of a corrupted copy = %q, %v", manifest.VerifyError, err)
+	}
+
+	// Restoring needs the key it was made with
+	other, _ := GenerateKey()
+	if err := manager.RestoreBackup(path, other); err == nil {
+		t.Error("restored with the wrong key")
+	}
+	if err := manager.RestoreBackup(path, nil); err != nil {
+		t.Fatalf("RestoreBackup: %v", err)
+	}
+	restored, err := os.ReadFile(filepath.Join(os.Getenv("HOME"), ".skyscape", "repos", "r1", "objects", "ab", "cdef"))
+	if err != nil || string(restored) != "object" {
+		t.Errorf("restored file = %q, %v", restored, err)
+	}
+}
+
+func TestRetention(t *testing.T) {
+	config := testWorkspace(t)
+	config.Store = storage.NewLocal(t.TempDir())
+	config.MaxBackups = 1
+	config.RemoteMaxBackups = 2
+	manager := NewBackupManager(config)
+
+	os.MkdirAll(config.BackupDir, 0755)
+	for age, name := range []string{"workspace-backup-3.tar.gz", "workspace-backup-2.tar.gz", "workspace-backup-1.tar.gz"} {
+		path := filepath.Join(config.BackupDir, name)
+		os.WriteFile(path, []byte(name), 0644)
+		manifest := &Manifest{Name: name, Size: int64(len(name)), Created: time.Now().Add(-time.Duration(age) * time.Hour)}
+		if err := manager.storeBackup(path, manifest); err != nil {
+			t.Fatalf("storeBackup: %v", err)
+		}
+	}
+	if err := manager.cleanOldBackups(); err != nil {
+		t.Fatalf("cleanOldBackups: %v", err)
+	}
+
+	local, _ := filepath.Glob(filepath.Join(config.BackupDir, "*.tar.gz"))
+	stored, _ := config.Store.List("backups/")
+	if len(local) != 1 || filepath.Base(local[0]) != "workspace-backup-3.tar.gz" {
+		t.Errorf("kept %d local backups, want 1", len(local))
+	}
+	archives := 0
+	for _, object := range stored {
+		if isBackupName(object.Key) {
+			archives++
+		}
+	}
+	if archives != 2 {
+		t.Errorf("kept %d remote backups, want 2", archives)
+	}
+}
+
+func TestSelectiveRestore(t *testing.T) {
+	config := testWorkspace(t)
+	os.MkdirAll(filepath.Join(config.ReposPath, "r3"), 0755)
+	os.WriteFile(filepath.Join(config.ReposPath, "r3", "HEAD"), []byte("ref: refs/heads/dev"), 0644)
+	manager := NewBackupManager(config)
+
+	path, err := manager.CreateBackup()
+	if err != nil {
+		t.Fatalf("CreateBackup: %v", err)
+	}
+	name := filepath.Base(path)
+
+	snapshot, err := manager.Snapshot(name, nil)
+	if err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+	if !snapshot.Database || snapshot.Secrets != 1 || strings.Join(snapshot.Repositories, ",") != "r1,r3" {
+		t.Errorf("snapshot = %+v", snapshot)
+	}
+
+	// Change r1 after the backup, and the database and the other repository
+	head := filepath.Join(config.ReposPath, "r1", "HEAD")
+	os.WriteFile(head, []byte("ref: refs/heads/broken"), 0644)
+	os.WriteFile(filepath.Join(config.ReposPath, "r1", "FETCH_HEAD"), []byte("new"), 0644)
+	os.Remove(filepath.Join(config.ReposPath, "r1", "objects", "ab", "cdef"))
+	os.WriteFile(config.DatabasePath, []byte("changed"), 0644)
+	os.WriteFile(filepath.Join(config.ReposPath, "r3", "HEAD"), []byte("changed"), 0644)
+
+	scope := RestoreScope{Repositories: []string{"r1"}}
+	plan, err := manager.PlanRestore(name, scope, nil)
+	if err != nil {
+		t.Fatalf("PlanRestore: %v", err)
+	}
+	if plan.Added != 1 || plan.Replaced != 1 || plan.Removed != 1 || plan.Applied {
+		t.Errorf("plan = %+v", plan)
+	}
+	if data, _ := os.ReadFile(head); string(data) != "ref: refs/heads/broken" {
+		t.Error("dry run changed the repository")
+	}
+
+	if _, err := manager.RestoreSelected(name, scope, nil); err != nil {
+		t.Fatalf("RestoreSelected: %v", err)
+	}
+	if data, _ := os.ReadFile(head); string(data) != "ref: refs/heads/main" {
+		t.Errorf("HEAD = %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(config.ReposPath, "r1", "FETCH_HEAD")); !os.IsNotExist(err) {
+		t.Error("file made after the backup was kept")
+	}
+	if data, _ := os.ReadFile(filepath.Join(config.ReposPath, "r1", "objects", "ab", "cdef")); string(data) != "object" {
+		t.Errorf("object = %q", data)
+	}
+	// Nothing outside the scope is touched
+	if data, _ := os.ReadFile(config.DatabasePath); string(data) != "changed" {
+		t.Error("database was restored")
+	}
+	if data, _ := os.ReadFile(filepath.Join(config.ReposPath, "r3", "HEAD")); string(data) != "changed" {
+		t.Error("another repository was restored")
+	}
+	if leftovers, _ := filepath.Glob(filepath.Join(config.ReposPath, "*.*")); len(leftovers) > 0 {
+		t.Errorf("left %v behind", leftovers)
+	}
+
+	plan, err = manager.RestoreSelected(name, RestoreScope{Database: true}, nil)
+	if err != nil || !plan.NeedsRestart() {
+		t.Fatalf("RestoreSelected database = %+v, %v", plan, err)
+	}
+	if data, _ := os.ReadFile(config.DatabasePath); string(data) != "database" {
+		t.Errorf("database = %q", data)
+	}
+
+	if _, err := manager.PlanRestore(name, RestoreScope{Repositories: []string{"missing"}}, nil); err == nil {
+		t.Error("planned restoring a repository the backup doesn't have")
+	}
+	if _, err := manager.PlanRestore(name, RestoreScope{Repositories: []string{"../vault"}}, nil); err == nil {
+		t.Error("planned restoring outside the repositories")
+	}
+}
+
+func TestTestRestore(t *testing.T) {
+	config := testWorkspace(t)
+	manager := NewBackupManager(config)
+
+	if result := manager.TestRestore(""); result.OK || !strings.Contains(result.Error, "no backups") {
+		t.Errorf("test restore without backups = %+v", result)
+	}
+
+	// The fake database and repository fail their checks
+	if _, err := manager.CreateBackup(); err != nil {
+		t.Fatalf("CreateBackup: %v", err)
+	}
+	result := manager.TestRestore("")
+	if result.OK || result.Error != "" || result.Files != 5 {
+		t.Fatalf("result = %+v", result)
+	}
+	failed := map[string]bool{}
+	for _, check := range result.Failed() {
+		failed[check.Name] = true
+	}
+	if !failed["Database"] {
+		t.Errorf("database that isn't SQLite passed: %+v", result.Checks)
+	}
+
+	// A real repository and database pass
+	os.WriteFile(config.DatabasePath, append([]byte("SQLite format 3\x00"), make([]byte, 84)...), 0644)
+	config.CheckDatabase = func(path string) (map[string]int64, error) {
+		return map[string]int64{"users": 2, "issues": 5}, nil
+	}
+	os.RemoveAll(config.ReposPath)
+	if _, err := exec.LookPath("git"); err == nil {
+		if output, err := exec.Command("git", "init", "--bare", filepath.Join(config.ReposPath, "r1")).CombinedOutput(); err != nil {
+			t.Fatalf("git init: %v: %s", err, output)
+		}
+	}
+	time.Sleep(time.Second) // Backups are named by the second
+	if _, err := manager.CreateBackup(); err != nil {
+		t.Fatalf("CreateBackup: %v", err)
+	}
+	result = manager.TestRestore("")
+	if !result.OK || result.TotalRows() != 7 {
+		t.Errorf("result = %+v", result)
+	}
+
+	results := manager.TestRestoreResults()
+	if len(results) != 3 || results[0].Backup != result.Backup || results[2].Error == "" {
+		t.Errorf("kept %d results", len(results))
+	}
+	if leftovers, _ := filepath.Glob(filepath.Join(config.BackupDir, ".test-restore-*")); len(leftovers) > 0 {
+		t.Errorf("left %v behind", leftovers)
+	}
+}
@@ -0,0 +1,236 @@
+package backup
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Encrypted backups are split into chunks sealed with AES-256-GCM, so they
+// can be written and read as a stream. The file starts with a header:
+//
+//	"SKYBAK" version(1) nonce-prefix(8)
+//
+// followed by chunks of length(4) ciphertext. Each chunk's nonce is the
+// prefix and the chunk's number, and the high bit of the length marks the
+// last chunk. The header and the length are authenticated with each chunk,
+// so chunks can't be reordered, and a file cut short is detected because
+// its last chunk is missing.
+const (
+	encryptMagic      = "SKYBAK"
+	encryptVersion    = 1
+	encryptHeaderSize = len(encryptMagic) + 1 + 8
+	encryptChunkSize  = 64 << 10
+	encryptFinalChunk = 1 << 31
+)
+
+// EncryptedSuffix is added to the names of encrypted backups
+const EncryptedSuffix = ".enc"
+
+// KeySize is the length of a backup encryption key
+const KeySize = 32
+
+// ErrWrongKey is returned when a backup can't be decrypted with the key
+var ErrWrongKey = errors.New("backup can't be decrypted with this key, or has been modified")
+
+// GenerateKey returns a new random encryption key
+func GenerateKey() ([]byte, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// KeyID identifies a key without revealing it, so a backup records which
+// key it needs
+func KeyID(key []byte) string {
+	sum := sha256.Sum256(append([]byte("skyscape-backup-key:"), key...))
+	return hex.EncodeToString(sum[:8])
+}
+
+// newGCM returns the cipher for a key
+func newGCM(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("backup key must be %d bytes", KeySize)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encryptWriter seals what is written to it a chunk at a time
+type encryptWriter struct {
+	w       io.Writer
+	gcm     cipher.AEAD
+	header  []byte
+	buf     []byte
+	counter uint32
+	closed  bool
+}
+
+// NewEncryptWriter returns a writer encrypting to w with key. Close must
+// be called to write the last chunk; it doesn't close w.
+func NewEncryptWriter(w io.Writer, key []byte) (io.WriteCloser, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, encryptHeaderSize)
+	copy(header, encryptMagic)
+	header[len(encryptMagic)] = encryptVersion
+	if _, err := rand.Read(header[len(encryptMagic)+1:]); err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+	return &encryptWriter{w: w, gcm: gcm, header: header, buf: make([]byte, 0, encryptChunkSize)}, nil
+}
+
+func (e *encryptWriter) Write(p []byte) (int, error) {
+	if e.closed {
+		return 0, errors.New("write to closed backup encrypter")
+	}
+	written := 0
+	for len(p) > 0 {
+		n := copy(e.buf[len(e.buf):cap(e.buf)], p)
+		e.buf = e.buf[:len(e.buf)+n]
+		p = p[n:]
+		written += n
+		// A full chunk is only sealed once more arrives, so the last one
+		// is always sealed by Close
+		if len(e.buf) == cap(e.buf) && len(p) > 0 {
+			if err := e.seal(false); err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+// Close seals the last chunk
+func (e *encryptWriter) Close() error {
+	if e.closed {
+		return nil
+	}
+	e.closed = true
+	return e.seal(true)
+}
+
+// seal encrypts and writes the buffered chunk
+func (e *encryptWriter) seal(final bool) error {
+	length := uint32(len(e.buf) + e.gcm.Overhead())
+	if final {
+		length |= encryptFinalChunk
+	}
+	prefix := binary.BigEndian.AppendUint32(nil, length)
+	sealed := e.gcm.Seal(prefix, chunkNonce(e.header, e.counter), e.buf, chunkData(e.header, prefix))
+	e.counter++
+	e.buf = e.buf[:0]
+	_, err := e.w.Write(sealed)
+	return err
+}
+
+// decryptReader opens chunks as they are read
+type decryptReader struct {
+	r       io.Reader
+	gcm     cipher.AEAD
+	header  []byte
+	plain   []byte
+	counter uint32
+	done    bool
+}
+
+// NewDecryptReader returns a reader decrypting what NewEncryptWriter wrote
+func NewDecryptReader(r io.Reader, key []byte) (io.Reader, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, encryptHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil || !IsEncrypted(header) {
+		return nil, errors.New("not an encrypted backup")
+	}
+	if header[len(encryptMagic)] != encryptVersion {
+		return nil, fmt.Errorf("unsupported backup encryption version %d", header[len(encryptMagic)])
+	}
+	return &decryptReader{r: r, gcm: gcm, header: header}, nil
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for len(d.plain) == 0 {
+		if d.done {
+			return 0, io.EOF
+		}
+		if err := d.open(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, d.plain)
+	d.plain = d.plain[n:]
+	return n, nil
+}
+
+// open reads and decrypts the next chunk
+func (d *decryptReader) open() error {
+	prefix := make([]byte, 4)
+	if _, err := io.ReadFull(d.r, prefix); err != nil {
+		if err == io.EOF {
+			return errors.New("encrypted backup is truncated")
+		}
+		return err
+	}
+	length := binary.BigEndian.Uint32(prefix)
+	final := length&encryptFinalChunk != 0
+	length &^= encryptFinalChunk
+	if length < uint32(d.gcm.Overhead()) || length > uint32(encryptChunkSize+d.gcm.Overhead()) {
+		return ErrWrongKey
+	}
+
+	sealed := make([]byte, length)
+	if _, err := io.ReadFull(d.r, sealed); err != nil {
+		return errors.New("encrypted backup is truncated")
+	}
+	plain, err := d.gcm.Open(sealed[:0], chunkNonce(d.header, d.counter), sealed, chunkData(d.header, prefix))
+	if err != nil {
+		return ErrWrongKey
+	}
+	d.counter++
+	d.plain = plain
+	if final {
+		d.done = true
+		// Anything after the last chunk has been appended
+		if n, _ := d.r.Read(make([]byte, 1)); n > 0 {
+			return errors.New("encrypted backup has data after its end")
+		}
+	}
+	return nil
+}
+
+// IsEncrypted reports whether content starts with an encrypted backup's
+// header
+func IsEncrypted(header []byte) bool {
+	return len(header) >= len(encryptMagic) && string(header[:len(encryptMagic)]) == encryptMagic
+}
+
+// chunkNonce is the nonce for a chunk: the file's random prefix and the
+// chunk's number
+func chunkNonce(header []byte, counter uint32) []byte {
+	nonce := make([]byte, 0, 12)
+	nonce = append(nonce, header[len(encryptMagic)+1:]...)
+	return binary.BigEndian.AppendUint32(nonce, counter)
+}
+
+// chunkData is the data authenticated with a chunk
+func chunkData(header, length []byte) []byte {
+	return append(append([]byte{}, header...), length...)
+}
@@ -0,0 +1,254 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ManifestSuffix is added to a backup's name for its manifest, which is
+// kept next to each copy of the backup
+const ManifestSuffix = ".manifest.json"
+
+// Manifest records the checksums of a backup, so every copy of it can be
+// checked for corruption or tampering
+type Manifest struct {
+	Name      string
+	Created   time.Time
+	Size      int64  // Of the archive as stored, encrypted or not
+	SHA256    string // Of the archive as stored
+	Encrypted bool
+	KeyID     string // Identifies the key it was encrypted with
+	Files     []ManifestFile
+
+	Destination string    // Remote storage it was copied to, empty for none
+	Uploaded    time.Time // When the remote copy was finished
+	Verified    time.Time // When its copies were last checked
+	VerifyError string    // What was wrong with them, empty when they matched
+}
+
+// ManifestFile is a file in the archive
+type ManifestFile struct {
+	Path   string
+	Size   int64
+	SHA256 string
+}
+
+// addFile records a file written to the archive
+func (m *Manifest) addFile(path string, size int64, sum hash.Hash) {
+	m.Files = append(m.Files, ManifestFile{Path: path, Size: size, SHA256: hex.EncodeToString(sum.Sum(nil))})
+}
+
+// VerifiedOK reports whether the last check found every copy intact
+func (m *Manifest) VerifiedOK() bool {
+	return !m.Verified.IsZero() && m.VerifyError == ""
+}
+
+// isBackupName reports whether a file name is a backup archive
+func isBackupName(name string) bool {
+	return strings.HasSuffix(name, ".tar.gz") || strings.HasSuffix(name, ".tar.gz"+EncryptedSuffix)
+}
+
+// manifestPath is where a backup's manifest is kept on the host
+func (bm *BackupManager) manifestPath(name string) string {
+	return filepath.Join(bm.config.BackupDir, name+ManifestSuffix)
+}
+
+// saveManifest writes a manifest next to the local copy of its backup, and
+// the remote copy when there is one
+func (bm *BackupManager) saveManifest(m *Manifest, local, stored bool) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if local {
+		if err := os.WriteFile(bm.manifestPath(m.Name), data, 0644); err != nil {
+			return err
+		}
+	}
+	if stored && bm.config.Store != nil {
+		return bm.config.Store.Put("backups/"+m.Name+ManifestSuffix, bytes.NewReader(data))
+	}
+	return nil
+}
+
+// loadManifest reads a backup's manifest from the host, or from remote
+// storage when only it has a copy
+func (bm *BackupManager) loadManifest(name string, stored bool) (*Manifest, error) {
+	data, err := os.ReadFile(bm.manifestPath(name))
+	if err != nil && stored && bm.config.Store != nil {
+		var r io.ReadCloser
+		if r, err = bm.config.Store.Get("backups/" + name + ManifestSuffix); err == nil {
+			data, err = io.ReadAll(io.LimitReader(r, 64<<20))
+			r.Close()
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("invalid manifest for %s: %w", name, err)
+	}
+	return &m, nil
+}
+
+// VerifyBackup checks each copy of a backup against its manifest: the
+// archive's size and checksum, and every file in it when it can be
+// decrypted. The result is recorded in the manifest.
+func (bm *BackupManager) VerifyBackup(name string) (*Manifest, error) {
+	backup, err := bm.findBackup(name)
+	if err != nil {
+		return nil, err
+	}
+	if backup.Manifest == nil {
+		return nil, fmt.Errorf("backup %s has no manifest, it was made before checksums were recorded", name)
+	}
+	manifest := backup.Manifest
+
+	var problems []string
+	local := backup.Local()
+	if local {
+		if err := bm.verifyLocal(backup.Path, manifest); err != nil {
+			problems = append(problems, "local copy: "+err.Error())
+		}
+	}
+	if backup.Stored {
+		if err := bm.verifyStored(manifest); err != nil {
+			problems = append(problems, "copy in "+bm.config.Store.String()+": "+err.Error())
+		}
+	}
+
+	manifest.Verified = time.Now()
+	manifest.VerifyError = strings.Join(problems, "; ")
+	if err := bm.saveManifest(manifest, local, backup.Stored); err != nil {
+		return manifest, fmt.Errorf("failed to record verification: %w", err)
+	}
+	if manifest.VerifyError != "" {
+		return manifest, errors.New(manifest.VerifyError)
+	}
+	return manifest, nil
+}
+
+// verifyLocal checks the copy on the host
+func (bm *BackupManager) verifyLocal(path string, m *Manifest) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return verifyArchive(file, m, bm.config.EncryptionKey)
+}
+
+// verifyStored checks the copy in remote storage
+func (bm *BackupManager) verifyStored(m *Manifest) error {
+	r, err := bm.config.Store.Get("backups/" + m.Name)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return verifyArchive(r, m, bm.config.EncryptionKey)
+}
+
+// verifyArchive reads a copy of a backup, comparing its checksum and those
+// of its files with the manifest. The files are only checked when the
+// archive isn't encrypted or key is the one it was encrypted with.
+func verifyArchive(r io.Reader, m *Manifest, key []byte) error {
+	sum := sha256.New()
+	counter := &countingReader{r: r}
+	archive := io.TeeReader(counter, sum)
+
+	var contentErr error
+	if !m.Encrypted || (key != nil && KeyID(key) == m.KeyID) {
+		contentErr = verifyFiles(archive, m, key)
+	}
+	// The checksum covers the whole archive, whatever was left unread
+	if _, err := io.Copy(io.Discard, archive); err != nil {
+		return err
+	}
+
+	if counter.n != m.Size {
+		return fmt.Errorf("size is %d bytes, the manifest says %d", counter.n, m.Size)
+	}
+	if got := hex.EncodeToString(sum.Sum(nil)); got != m.SHA256 {
+		return fmt.Errorf("checksum is %s, the manifest says %s", got, m.SHA256)
+	}
+	return contentErr
+}
+
+// verifyFiles compares the files in an archive with the manifest
+func verifyFiles(archive io.Reader, m *Manifest, key []byte) error {
+	if m.Encrypted {
+		decrypted, err := NewDecryptReader(archive, key)
+		if err != nil {
+			return err
+		}
+		archive = decrypted
+	}
+	gz, err := gzip.NewReader(archive)
+	if err != nil {
+		return fmt.Errorf("failed to decompress: %w", err)
+	}
+	defer gz.Close()
+
+	expected := make(map[string]ManifestFile, len(m.Files))
+	for _, file := range m.Files {
+		expected[file.Path] = file
+	}
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read archive: %w", err)
+		}
+		file, ok := expected[header.Name]
+		if !ok {
+			continue // Directories
+		}
+		sum := sha256.New()
+		size, err := io.Copy(sum, tr)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", header.Name, err)
+		}
+		if size != file.Size || hex.EncodeToString(sum.Sum(nil)) != file.SHA256 {
+			return fmt.Errorf("%s doesn't match its checksum", header.Name)
+		}
+		delete(expected, header.Name)
+	}
+	if len(expected) > 0 {
+		missing := make([]string, 0, len(expected))
+		for path := range expected {
+			missing = append(missing, path)
+		}
+		slices.Sort(missing)
+		return fmt.Errorf("%d files are missing from the archive, such as %s", len(missing), missing[0])
+	}
+	return nil
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
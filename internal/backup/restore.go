@@ -0,0 +1,456 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// RestoreScope selects the parts of a backup a restore replaces. Each part
+// is replaced as a whole, so files made since the backup are removed.
+type RestoreScope struct {
+	Database     bool
+	Secrets      bool
+	Uploads      bool
+	Repositories []string // IDs of the repositories to restore
+}
+
+// Empty reports whether nothing was selected
+func (s RestoreScope) Empty() bool {
+	return !s.Database && !s.Secrets && !s.Uploads && len(s.Repositories) == 0
+}
+
+// String describes the scope, such as "the database and 2 repositories"
+func (s RestoreScope) String() string {
+	var parts []string
+	if s.Database {
+		parts = append(parts, "the database")
+	}
+	if s.Secrets {
+		parts = append(parts, "secrets")
+	}
+	if s.Uploads {
+		parts = append(parts, "uploads")
+	}
+	switch len(s.Repositories) {
+	case 0:
+	case 1:
+		parts = append(parts, "repository "+s.Repositories[0])
+	default:
+		parts = append(parts, fmt.Sprintf("%d repositories", len(s.Repositories)))
+	}
+	if len(parts) == 0 {
+		return "nothing"
+	}
+	if len(parts) == 1 {
+		return parts[0]
+	}
+	return strings.Join(parts[:len(parts)-1], ", ") + " and " + parts[len(parts)-1]
+}
+
+// Snapshot is what a backup holds, for choosing what to restore from it
+type Snapshot struct {
+	BackupInfo
+	Database     bool
+	Secrets      int      // Files in the vault
+	Uploads      int      // Uploaded files
+	Repositories []string // IDs of the repositories in it
+}
+
+// Snapshot lists what a backup holds. It's read from the manifest, or from
+// the archive itself for backups made before manifests were kept, which
+// needs the key when they're encrypted.
+func (bm *BackupManager) Snapshot(name string, key []byte) (*Snapshot, error) {
+	backup, err := bm.findBackup(name)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := &Snapshot{BackupInfo: *backup}
+
+	var files []string
+	if backup.Manifest != nil {
+		for _, file := range backup.Manifest.Files {
+			files = append(files, file.Path)
+		}
+	} else {
+		archive, err := bm.openBackup(backup.Path, key)
+		if err != nil {
+			return nil, err
+		}
+		defer archive.Close()
+		for {
+			header, err := archive.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to read archive: %w", err)
+			}
+			if header.Typeflag == tar.TypeReg {
+				files = append(files, header.Name)
+			}
+		}
+	}
+
+	for _, file := range files {
+		part, rest, _ := strings.Cut(file, "/")
+		switch part {
+		case "database":
+			snapshot.Database = true
+		case "vault":
+			snapshot.Secrets++
+		case "uploads":
+			snapshot.Uploads++
+		case "repos":
+			id, _, _ := strings.Cut(rest, "/")
+			if id != "" && !slices.Contains(snapshot.Repositories, id) {
+				snapshot.Repositories = append(snapshot.Repositories, id)
+			}
+		}
+	}
+	slices.Sort(snapshot.Repositories)
+	return snapshot, nil
+}
+
+// Restore change actions
+const (
+	RestoreAdd     = "add"
+	RestoreReplace = "replace"
+	RestoreRemove  = "remove"
+)
+
+// maxPlanChanges is how many changes a plan lists; the rest are counted
+const maxPlanChanges = 500
+
+// RestoreChange is a file a restore adds, replaces or removes
+type RestoreChange struct {
+	Path   string // As in the archive, such as repos/<id>/HEAD
+	Action string
+	Size   int64 // Of the file in the backup, or on disk when removed
+}
+
+// RestorePlan is what a restore changes, worked out in a dry run before
+// anything is written
+type RestorePlan struct {
+	Backup    string
+	Scope     RestoreScope
+	Changes   []RestoreChange
+	More      int // Changes left out of the list
+	Added     int
+	Replaced  int
+	Removed   int
+	Unchanged int
+	Applied   bool
+}
+
+// NeedsRestart reports whether the workspace must restart to use what was
+// restored, as the database and vault are held open while it runs
+func (p *RestorePlan) NeedsRestart() bool {
+	return p.Applied && (p.Scope.Database || p.Scope.Secrets)
+}
+
+// record adds a change to the plan
+func (p *RestorePlan) record(change RestoreChange) {
+	switch change.Action {
+	case RestoreAdd:
+		p.Added++
+	case RestoreReplace:
+		p.Replaced++
+	case RestoreRemove:
+		p.Removed++
+	}
+	if len(p.Changes) < maxPlanChanges {
+		p.Changes = append(p.Changes, change)
+	} else {
+		p.More++
+	}
+}
+
+// PlanRestore compares part of a backup with the data on disk without
+// changing anything
+func (bm *BackupManager) PlanRestore(name string, scope RestoreScope, key []byte) (*RestorePlan, error) {
+	return bm.restore(name, scope, key, false)
+}
+
+// RestoreSelected replaces part of the workspace's data with a backup's.
+// Everything is extracted next to the data first and swapped in once the
+// whole archive has been read, so a bad archive leaves the data untouched.
+func (bm *BackupManager) RestoreSelected(name string, scope RestoreScope, key []byte) (*RestorePlan, error) {
+	return bm.restore(name, scope, key, true)
+}
+
+// restoreRoot is a file or directory a restore replaces as a whole
+type restoreRoot struct {
+	prefix   string          // Its path in the archive
+	label    string          // For messages
+	target   string          // The database file, or a directory
+	staging  string          // Where the backup's copy is extracted to
+	database bool            // A single file, with SQLite's journal next to it
+	files    map[string]bool // Paths in the archive restored to it
+	found    bool            // The backup has it
+}
+
+func (bm *BackupManager) restore(name string, scope RestoreScope, key []byte, apply bool) (*RestorePlan, error) {
+	if scope.Empty() {
+		return nil, errors.New("choose what to restore")
+	}
+	roots, err := bm.restoreRoots(scope)
+	if err != nil {
+		return nil, err
+	}
+	backup, err := bm.findBackup(name)
+	if err != nil {
+		return nil, err
+	}
+	archive, err := bm.openBackup(backup.Path, key)
+	if err != nil {
+		return nil, err
+	}
+	defer archive.Close()
+
+	plan := &RestorePlan{Backup: name, Scope: scope}
+	if apply {
+		for _, root := range roots {
+			if err := os.RemoveAll(root.staging); err != nil {
+				return nil, err
+			}
+		}
+		// Nothing half extracted is left behind
+		defer func() {
+			for _, root := range roots {
+				os.RemoveAll(root.staging)
+			}
+		}()
+	}
+
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read archive: %w", err)
+		}
+		root, rel := matchRoot(roots, header.Name)
+		if root == nil {
+			continue
+		}
+		root.found = true
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if (rel == ".") != root.database || !filepath.IsLocal(rel) {
+			return nil, fmt.Errorf("backup has an invalid path %q", header.Name)
+		}
+		root.files[root.prefix+"/"+filepath.ToSlash(rel)] = true
+
+		change, err := restoreFile(archive, header, root, rel, apply)
+		if err != nil {
+			return nil, err
+		}
+		if change.Action == "" {
+			plan.Unchanged++
+		} else {
+			plan.record(change)
+		}
+	}
+
+	for _, root := range roots {
+		if !root.found {
+			return nil, fmt.Errorf("%s isn't in backup %s", root.label, name)
+		}
+		// What was made since the backup goes
+		removed, err := filesNotIn(root)
+		if err != nil {
+			return nil, err
+		}
+		for _, change := range removed {
+			plan.record(change)
+		}
+	}
+
+	if apply {
+		for _, root := range roots {
+			if err := swapRoot(root); err != nil {
+				return plan, err
+			}
+		}
+		plan.Applied = true
+		log.Printf("Restored %s from backup %s: %d added, %d replaced, %d removed",
+			scope, name, plan.Added, plan.Replaced, plan.Removed)
+	}
+	return plan, nil
+}
+
+// restoreRoots returns what a scope replaces
+func (bm *BackupManager) restoreRoots(scope RestoreScope) ([]*restoreRoot, error) {
+	var roots []*restoreRoot
+	add := func(prefix, label, target string) *restoreRoot {
+		root := &restoreRoot{prefix: prefix, label: label, target: target, staging: target + ".restoring", files: make(map[string]bool)}
+		roots = append(roots, root)
+		return root
+	}
+	if scope.Database {
+		add("database/workspace.db", "The database", bm.config.DatabasePath).database = true
+	}
+	if scope.Secrets {
+		add("vault", "The vault", bm.config.SecretsPath)
+	}
+	if scope.Uploads {
+		add("uploads", "Uploads", bm.config.UploadsPath)
+	}
+	for _, id := range scope.Repositories {
+		if id == "" || strings.ContainsAny(id, `/\`) || !filepath.IsLocal(id) {
+			return nil, fmt.Errorf("invalid repository %q", id)
+		}
+		add("repos/"+id, "Repository "+id, filepath.Join(bm.config.ReposPath, id))
+	}
+	return roots, nil
+}
+
+// matchRoot returns the root a path in the archive is restored to and the
+// path relative to it
+func matchRoot(roots []*restoreRoot, name string) (*restoreRoot, string) {
+	name = path.Clean(name)
+	for _, root := range roots {
+		if name == root.prefix {
+			return root, "."
+		}
+		if rel, ok := strings.CutPrefix(name, root.prefix+"/"); ok {
+			return root, filepath.FromSlash(rel)
+		}
+	}
+	return nil, ""
+}
+
+// restoreFile compares a file in the archive with the one on disk, and
+// extracts it to the staging area when applying
+func restoreFile(archive io.Reader, header *tar.Header, root *restoreRoot, rel string, apply bool) (RestoreChange, error) {
+	target, staged := filepath.Join(root.target, rel), filepath.Join(root.staging, rel)
+
+	sum := sha256.New()
+	content := io.TeeReader(archive, sum)
+	if apply {
+		if err := os.MkdirAll(filepath.Dir(staged), 0755); err != nil {
+			return RestoreChange{}, err
+		}
+		file, err := os.OpenFile(staged, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(header.Mode)&fs.ModePerm|0200)
+		if err != nil {
+			return RestoreChange{}, err
+		}
+		_, err = io.Copy(file, content)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return RestoreChange{}, fmt.Errorf("failed to extract %s: %w", header.Name, err)
+		}
+		// Git makes objects read-only
+		os.Chmod(staged, os.FileMode(header.Mode)&fs.ModePerm)
+	} else if _, err := io.Copy(io.Discard, content); err != nil {
+		return RestoreChange{}, fmt.Errorf("failed to read %s: %w", header.Name, err)
+	}
+
+	change := RestoreChange{Path: header.Name, Size: header.Size}
+	current, err := fileSum(target)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		change.Action = RestoreAdd
+	case err != nil:
+		return RestoreChange{}, err
+	case !bytes.Equal(current, sum.Sum(nil)):
+		change.Action = RestoreReplace
+	}
+	return change, nil
+}
+
+// fileSum returns the SHA-256 of a file on disk
+func fileSum(name string) ([]byte, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	sum := sha256.New()
+	if _, err := io.Copy(sum, file); err != nil {
+		return nil, err
+	}
+	return sum.Sum(nil), nil
+}
+
+// filesNotIn lists the files under a root that the backup doesn't have
+func filesNotIn(root *restoreRoot) ([]RestoreChange, error) {
+	if root.database {
+		return nil, nil // A single file, which the backup has
+	}
+
+	var removed []RestoreChange
+	err := filepath.WalkDir(root.target, func(name string, entry fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(root.target, name)
+		if err != nil {
+			return err
+		}
+		archived := root.prefix + "/" + filepath.ToSlash(rel)
+		if !root.files[archived] {
+			info, _ := entry.Info()
+			change := RestoreChange{Path: archived, Action: RestoreRemove}
+			if info != nil {
+				change.Size = info.Size()
+			}
+			removed = append(removed, change)
+		}
+		return nil
+	})
+	return removed, err
+}
+
+// swapRoot moves the extracted copy into place, putting the old data back
+// if it can't
+func swapRoot(root *restoreRoot) error {
+	if _, err := os.Stat(root.staging); errors.Is(err, fs.ErrNotExist) {
+		// The backup has the directory but nothing in it
+		if err := os.MkdirAll(root.staging, 0755); err != nil {
+			return err
+		}
+	}
+
+	previous := root.target + ".before-restore"
+	if err := os.RemoveAll(previous); err != nil {
+		return err
+	}
+	existed := true
+	if err := os.Rename(root.target, previous); errors.Is(err, fs.ErrNotExist) {
+		existed = false
+	} else if err != nil {
+		return fmt.Errorf("failed to move %s aside: %w", root.target, err)
+	}
+	if err := os.Rename(root.staging, root.target); err != nil {
+		if existed {
+			os.Rename(previous, root.target)
+		}
+		return fmt.Errorf("failed to restore %s: %w", root.target, err)
+	}
+
+	// SQLite's journal belongs to the database that was replaced
+	if root.database {
+		os.Remove(root.target + "-wal")
+		os.Remove(root.target + "-shm")
+	}
+	return os.RemoveAll(previous)
+}
@@ -1,9 +1,12 @@
 package backup
 
 import (
+	"errors"
 	"log"
 	"sync"
 	"time"
+
+	"workspace/internal/storage"
 )
 
 // BackupScheduler handles automated backup scheduling
@@ -16,16 +19,30 @@ type BackupScheduler struct {
 	wg       sync.WaitGroup
 	lastRun  time.Time
 	nextRun  time.Time
+	running  bool
+	restoring bool
+	lastErr  string
+	
+	// Test restores of the latest backup, every testEvery when positive
+	testEvery     time.Duration
+	testing       bool
+	lastTest      *TestRestoreResult
+	onTestRestore func(*TestRestoreResult)
 }
 
 // NewBackupScheduler creates a new backup scheduler
 func NewBackupScheduler(config *BackupConfig) *BackupScheduler {
-	return &BackupScheduler{
-		manager:  NewBackupManager(config),
-		schedule: config.Schedule,
-		enabled:  config.Enabled,
-		stopCh:   make(chan struct{}),
+	bs := &BackupScheduler{
+		manager:   NewBackupManager(config),
+		schedule:  config.Schedule,
+		enabled:   config.Enabled,
+		stopCh:    make(chan struct{}),
+		testEvery: DefaultTestRestoreInterval,
 	}
+	if results := bs.manager.TestRestoreResults(); len(results) > 0 {
+		bs.lastTest = &results[0]
+	}
+	return bs
 }
 
 // Start begins the backup scheduler
@@ -82,19 +99,18 @@ func (bs *BackupScheduler) run() {
 				bs.runBackup()
 				bs.calculateNextRun()
 			}
+			if enabled && bs.testDue() && bs.claimTest() {
+				bs.runTestRestore()
+			}
 		}
 	}
 }
 
 // runBackup executes a backup
 func (bs *BackupScheduler) runBackup() {
-	bs.mu.Lock()
-	bs.lastRun = time.Now()
-	bs.mu.Unlock()
-	
 	log.Println("Starting scheduled backup")
 	
-	backupPath, err := bs.manager.CreateBackup()
+	backupPath, err := bs.createBackup()
 	if err != nil {
 		log.Printf("Scheduled backup failed: %v", err)
 		return
@@ -103,6 +119,109 @@ func (bs *BackupScheduler) runBackup() {
 	log.Printf("Scheduled backup completed: %s", backupPath)
 }
 
+// createBackup creates a backup unless one is already being made,
+// recording when it ran and how it went
+func (bs *BackupScheduler) createBackup() (string, error) {
+	bs.mu.Lock()
+	if bs.running {
+		bs.mu.Unlock()
+		return "", errors.New("a backup is already being made")
+	}
+	if bs.restoring {
+		bs.mu.Unlock()
+		return "", errors.New("a backup is being restored")
+	}
+	bs.running = true
+	bs.lastRun = time.Now()
+	bs.mu.Unlock()
+	
+	backupPath, err := bs.manager.CreateBackup()
+	
+	bs.mu.Lock()
+	bs.running = false
+	bs.lastErr = ""
+	if err != nil {
+		bs.lastErr = err.Error()
+	}
+	bs.mu.Unlock()
+	return backupPath, err
+}
+
+// testDue reports whether the latest backup should be test restored
+func (bs *BackupScheduler) testDue() bool {
+	bs.mu.RLock()
+	defer bs.mu.RUnlock()
+	if bs.testEvery <= 0 || bs.running || bs.testing {
+		return false
+	}
+	return bs.lastTest == nil || time.Since(bs.lastTest.Started) >= bs.testEvery
+}
+
+// claimTest marks a test restore as running unless one already is
+func (bs *BackupScheduler) claimTest() bool {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	if bs.testing {
+		return false
+	}
+	bs.testing = true
+	return true
+}
+
+// runTestRestore test restores the latest backup once claimTest has
+// succeeded, reporting the result to the callback so failures reach
+// administrators
+func (bs *BackupScheduler) runTestRestore() *TestRestoreResult {
+	result := bs.manager.TestRestore("")
+	if result.OK {
+		log.Printf("Test restore passed: %s", result.Summary())
+	} else {
+		log.Printf("Test restore failed: %s", result.Summary())
+	}
+	
+	bs.mu.Lock()
+	bs.testing = false
+	bs.lastTest = result
+	notify := bs.onTestRestore
+	bs.mu.Unlock()
+	
+	if notify != nil {
+		notify(result)
+	}
+	return result
+}
+
+// StartTestRestore test restores the latest backup in the background
+func (bs *BackupScheduler) StartTestRestore() error {
+	if !bs.claimTest() {
+		return errors.New("a test restore is already running")
+	}
+	
+	go bs.runTestRestore()
+	return nil
+}
+
+// TestRestoreResults returns the last test restores, newest first
+func (bs *BackupScheduler) TestRestoreResults() []TestRestoreResult {
+	return bs.manager.TestRestoreResults()
+}
+
+// SetTestRestore sets how often the latest backup is test restored, never
+// when every isn't positive, and what is told of each result
+func (bs *BackupScheduler) SetTestRestore(every time.Duration, notify func(*TestRestoreResult)) {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	bs.testEvery = every
+	bs.onTestRestore = notify
+}
+
+// SetDatabaseCheck sets how test restored databases are opened
+func (bs *BackupScheduler) SetDatabaseCheck(check DatabaseCheck) {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	bs.manager.config.CheckDatabase = check
+}
+
 // calculateNextRun calculates the next backup time
 func (bs *BackupScheduler) calculateNextRun() {
 	now := time.Now()
@@ -130,12 +249,65 @@ func (bs *BackupScheduler) calculateNextRun() {
 // TriggerBackup manually triggers a backup
 func (bs *BackupScheduler) TriggerBackup() (string, error) {
 	log.Println("Manual backup triggered")
-	return bs.manager.CreateBackup()
+	return bs.createBackup()
+}
+
+// StartBackup triggers a backup in the background, so its progress can be
+// followed from the status
+func (bs *BackupScheduler) StartBackup() error {
+	bs.mu.RLock()
+	running := bs.running
+	bs.mu.RUnlock()
+	if running {
+		return errors.New("a backup is already being made")
+	}
+	
+	go func() {
+		if _, err := bs.TriggerBackup(); err != nil {
+			log.Printf("Manual backup failed: %v", err)
+		}
+	}()
+	return nil
+}
+
+// RestoreBackup restores from a backup, opening encrypted backups with key
+// or the configured key when it is nil
+func (bs *BackupScheduler) RestoreBackup(backupPath string, key []byte) error {
+	return bs.manager.RestoreBackup(backupPath, key)
+}
+
+// Snapshot lists what a backup holds
+func (bs *BackupScheduler) Snapshot(name string, key []byte) (*Snapshot, error) {
+	return bs.manager.Snapshot(name, key)
+}
+
+// PlanRestore works out what restoring part of a backup would change
+func (bs *BackupScheduler) PlanRestore(name string, scope RestoreScope, key []byte) (*RestorePlan, error) {
+	return bs.manager.PlanRestore(name, scope, key)
 }
 
-// RestoreBackup restores from a backup
-func (bs *BackupScheduler) RestoreBackup(backupPath string) error {
-	return bs.manager.RestoreBackup(backupPath)
+// RestoreSelected restores part of a backup, unless a backup is being
+// made or another restore is running
+func (bs *BackupScheduler) RestoreSelected(name string, scope RestoreScope, key []byte) (*RestorePlan, error) {
+	bs.mu.Lock()
+	if bs.running || bs.restoring {
+		bs.mu.Unlock()
+		return nil, errors.New("wait for the backup or restore that is running to finish")
+	}
+	bs.restoring = true
+	bs.mu.Unlock()
+	
+	defer func() {
+		bs.mu.Lock()
+		bs.restoring = false
+		bs.mu.Unlock()
+	}()
+	return bs.manager.RestoreSelected(name, scope, key)
+}
+
+// VerifyBackup checks each copy of a backup against its manifest
+func (bs *BackupScheduler) VerifyBackup(name string) (*Manifest, error) {
+	return bs.manager.VerifyBackup(name)
 }
 
 // ListBackups returns available backups
@@ -148,12 +320,36 @@ func (bs *BackupScheduler) GetStatus() SchedulerStatus {
 	bs.mu.RLock()
 	defer bs.mu.RUnlock()
 	
-	return SchedulerStatus{
-		Enabled:  bs.enabled,
-		LastRun:  bs.lastRun,
-		NextRun:  bs.nextRun,
-		Schedule: bs.schedule,
+	config := bs.manager.config
+	status := SchedulerStatus{
+		Enabled:       bs.enabled,
+		LastRun:       bs.lastRun,
+		NextRun:       bs.nextRun,
+		Schedule:      bs.schedule,
+		Running:       bs.running,
+		LastError:     bs.lastErr,
+		Encrypted:     config.EncryptionKey != nil,
+		RetentionDays: config.RetentionDays,
+		MaxBackups:    config.MaxBackups,
+		Upload:        bs.manager.Upload(),
+	}
+	if config.EncryptionKey != nil {
+		status.KeyID = KeyID(config.EncryptionKey)
+	}
+	if config.Store != nil {
+		status.Destination = config.Store.String()
+	}
+	status.RemoteRetentionDays, status.RemoteMaxBackups = config.RemoteRetention()
+	
+	status.TestRestoring = bs.testing
+	status.LastTestRestore = bs.lastTest
+	if bs.testEvery > 0 {
+		status.NextTestRestore = time.Now()
+		if bs.lastTest != nil {
+			status.NextTestRestore = bs.lastTest.Started.Add(bs.testEvery)
+		}
 	}
+	return status
 }
 
 // SetEnabled enables or disables the scheduler
@@ -170,12 +366,70 @@ func (bs *BackupScheduler) SetEnabled(enabled bool) {
 	}
 }
 
+// BackupDir returns the directory backups are written to
+func (bs *BackupScheduler) BackupDir() string {
+	bs.mu.RLock()
+	defer bs.mu.RUnlock()
+	return bs.manager.config.BackupDir
+}
+
+// SetBackupDir changes where future backups are written and listed from
+func (bs *BackupScheduler) SetBackupDir(dir string) {
+	bs.mu.Lock()
+	bs.manager.config.BackupDir = dir
+	bs.mu.Unlock()
+
+	log.Printf("Backup directory set to %s", dir)
+}
+
+// SetStore sets the remote storage backups are copied to, nil for none
+func (bs *BackupScheduler) SetStore(store storage.Store) {
+	bs.mu.Lock()
+	bs.manager.config.Store = store
+	bs.mu.Unlock()
+}
+
+// SetEncryptionKey sets the key future backups are encrypted with, nil to
+// stop encrypting them
+func (bs *BackupScheduler) SetEncryptionKey(key []byte) {
+	bs.mu.Lock()
+	bs.manager.config.EncryptionKey = key
+	bs.mu.Unlock()
+}
+
+// SetRetention sets how many days and how many backups are kept on the
+// host, and in remote storage where zero follows the host
+func (bs *BackupScheduler) SetRetention(days, count, remoteDays, remoteCount int) {
+	bs.mu.Lock()
+	config := bs.manager.config
+	config.RetentionDays, config.MaxBackups = days, count
+	config.RemoteRetentionDays, config.RemoteMaxBackups = remoteDays, remoteCount
+	bs.mu.Unlock()
+}
+
 // SchedulerStatus holds scheduler status information
 type SchedulerStatus struct {
 	Enabled  bool
 	LastRun  time.Time
 	NextRun  time.Time
 	Schedule string
+	
+	Running   bool   // A backup is being made
+	LastError string // Why the last backup failed
+	
+	Encrypted   bool
+	KeyID       string // Identifies the encryption key
+	Destination string // Remote storage backups are copied to
+	Upload      UploadProgress
+	
+	RetentionDays       int
+	MaxBackups          int
+	RemoteRetentionDays int
+	RemoteMaxBackups    int
+	
+	TestRestoring   bool
+	LastTestRestore *TestRestoreResult
+	NextTestRestore time.Time // Zero when test restores are off
 }
 
 // Global backup scheduler instance
@@ -0,0 +1,343 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// DefaultTestRestoreInterval is how often the latest backup is test
+// restored unless the settings say otherwise
+const DefaultTestRestoreInterval = 7 * 24 * time.Hour
+
+// testRestoreHistory is how many test restore results are kept
+const testRestoreHistory = 20
+
+// fsckTimeout bounds checking one repository
+const fsckTimeout = 10 * time.Minute
+
+// DatabaseCheck opens a restored database and counts the rows in each of
+// its tables, failing when it can't be read
+type DatabaseCheck func(path string) (map[string]int64, error)
+
+// TestCheck is one of the checks a test restore runs
+type TestCheck struct {
+	Name    string
+	OK      bool
+	Skipped bool // It couldn't be run here, such as fsck without git
+	Detail  string
+}
+
+// TestRestoreResult is how restoring a backup into a scratch directory
+// and checking what came out went
+type TestRestoreResult struct {
+	Backup   string
+	Started  time.Time
+	Duration time.Duration
+	OK       bool
+	Error    string // Why the backup couldn't be restored at all
+	Checks   []TestCheck
+	Files    int
+	Rows     map[string]int64 // In each database table
+}
+
+// Failed returns the checks that failed
+func (r *TestRestoreResult) Failed() []TestCheck {
+	var failed []TestCheck
+	for _, check := range r.Checks {
+		if !check.OK && !check.Skipped {
+			failed = append(failed, check)
+		}
+	}
+	return failed
+}
+
+// Summary describes the result in a sentence, for notifications
+func (r *TestRestoreResult) Summary() string {
+	if r.Error != "" {
+		return fmt.Sprintf("Backup %s couldn't be restored: %s", r.Backup, r.Error)
+	}
+	failed := r.Failed()
+	if len(failed) == 0 {
+		return fmt.Sprintf("Backup %s restored and passed %d checks", r.Backup, len(r.Checks))
+	}
+	problems := make([]string, len(failed))
+	for i, check := range failed {
+		problems[i] = check.Name + ": " + check.Detail
+	}
+	return fmt.Sprintf("Backup %s restored but failed %d checks. %s", r.Backup, len(failed), strings.Join(problems, "; "))
+}
+
+// TotalRows is the number of rows in the restored database
+func (r *TestRestoreResult) TotalRows() int64 {
+	var total int64
+	for _, rows := range r.Rows {
+		total += rows
+	}
+	return total
+}
+
+// TestRestore restores a backup, the latest when name is empty, into a
+// temporary directory and checks it: the archive's checksum, that the
+// database opens and its row counts, git fsck on every repository and the
+// vault. The result is kept with the last few, and nothing is changed.
+func (bm *BackupManager) TestRestore(name string) *TestRestoreResult {
+	result := &TestRestoreResult{Backup: name, Started: time.Now()}
+	defer func() {
+		result.Duration = time.Since(result.Started)
+		result.OK = result.Error == "" && len(result.Failed()) == 0
+		if err := bm.saveTestResult(result); err != nil {
+			log.Printf("Warning: Failed to save test restore result: %v", err)
+		}
+	}()
+
+	if err := bm.testRestore(result); err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+func (bm *BackupManager) testRestore(result *TestRestoreResult) error {
+	if result.Backup == "" {
+		backups, err := bm.ListBackups()
+		if err != nil {
+			return err
+		}
+		if len(backups) == 0 {
+			return errors.New("there are no backups to test")
+		}
+		latest := slices.MaxFunc(backups, func(a, b BackupInfo) int { return a.Created.Compare(b.Created) })
+		result.Backup = latest.Name
+	}
+	backup, err := bm.findBackup(result.Backup)
+	if err != nil {
+		return err
+	}
+
+	// Next to the backups, where there's room for them
+	if err := os.MkdirAll(bm.config.BackupDir, 0755); err != nil {
+		return err
+	}
+	dir, err := os.MkdirTemp(bm.config.BackupDir, ".test-restore-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	// Opening checks the archive against its manifest
+	archive, err := bm.openBackup(backup.Path, nil)
+	if err != nil {
+		return err
+	}
+	result.Files, err = extractArchive(archive, dir)
+	archive.Close()
+	if err != nil {
+		return err
+	}
+	detail := fmt.Sprintf("%d files extracted", result.Files)
+	if backup.Manifest != nil {
+		detail += ", checksum matches the manifest"
+	}
+	result.Checks = append(result.Checks, TestCheck{Name: "Archive", OK: true, Detail: detail})
+
+	result.Checks = append(result.Checks, bm.checkDatabase(filepath.Join(dir, "database", "workspace.db"), result))
+	result.Checks = append(result.Checks, checkRepositories(filepath.Join(dir, "repos"))...)
+	result.Checks = append(result.Checks, checkVault(filepath.Join(dir, "vault")))
+	return nil
+}
+
+// extractArchive writes every file in a backup under dir
+func extractArchive(archive *openedBackup, dir string) (int, error) {
+	files := 0
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return files, fmt.Errorf("failed to read archive: %w", err)
+		}
+		if !filepath.IsLocal(header.Name) {
+			return files, fmt.Errorf("backup has an invalid path %q", header.Name)
+		}
+		target := filepath.Join(dir, header.Name)
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return files, err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return files, err
+			}
+			file, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+			if err != nil {
+				return files, err
+			}
+			_, err = io.Copy(file, archive)
+			if closeErr := file.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				return files, fmt.Errorf("failed to extract %s: %w", header.Name, err)
+			}
+			files++
+		}
+	}
+}
+
+// sqliteHeader starts every SQLite database
+var sqliteHeader = []byte("SQLite format 3\x00")
+
+// checkDatabase makes sure the restored database opens and counts its rows
+func (bm *BackupManager) checkDatabase(path string, result *TestRestoreResult) TestCheck {
+	check := TestCheck{Name: "Database"}
+	file, err := os.Open(path)
+	if err != nil {
+		check.Detail = "the backup has no database"
+		return check
+	}
+	header := make([]byte, len(sqliteHeader))
+	_, err = io.ReadFull(file, header)
+	file.Close()
+	if err != nil || !bytes.Equal(header, sqliteHeader) {
+		check.Detail = "not an SQLite database"
+		return check
+	}
+
+	if bm.config.CheckDatabase == nil {
+		check.OK = true
+		check.Detail = "has an SQLite header, its tables weren't read"
+		return check
+	}
+	rows, err := bm.config.CheckDatabase(path)
+	if err != nil {
+		check.Detail = err.Error()
+		return check
+	}
+	if len(rows) == 0 {
+		check.Detail = "has no tables"
+		return check
+	}
+	result.Rows = rows
+	check.OK = true
+	check.Detail = fmt.Sprintf("opened, %d tables with %d rows", len(rows), result.TotalRows())
+	return check
+}
+
+// checkRepositories runs git fsck on each restored repository, listing the
+// ones that fail after a check summing up the rest
+func checkRepositories(dir string) []TestCheck {
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return []TestCheck{{Name: "Repositories", Detail: err.Error()}}
+	}
+	var repos []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			repos = append(repos, entry.Name())
+		}
+	}
+	if len(repos) == 0 {
+		return []TestCheck{{Name: "Repositories", OK: true, Detail: "the backup has no repositories"}}
+	}
+	if _, err := exec.LookPath("git"); err != nil {
+		return []TestCheck{{Name: "Repositories", Skipped: true, Detail: "git isn't installed, so they weren't checked"}}
+	}
+
+	var failed []TestCheck
+	for _, repo := range repos {
+		if err := fsck(filepath.Join(dir, repo)); err != nil {
+			failed = append(failed, TestCheck{Name: "Repository " + repo, Detail: err.Error()})
+		}
+	}
+	summary := TestCheck{Name: "Repositories", OK: len(failed) == 0,
+		Detail: fmt.Sprintf("%d of %d passed git fsck", len(repos)-len(failed), len(repos))}
+	return append([]TestCheck{summary}, failed...)
+}
+
+// fsck checks a repository's objects and refs
+func fsck(repo string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), fsckTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "git", "--git-dir", repo, "fsck", "--no-progress", "--no-dangling")
+	cmd.Env = append(os.Environ(), "GIT_CONFIG_NOSYSTEM=1")
+	output, err := cmd.CombinedOutput()
+	if err == nil {
+		return nil
+	}
+	// The first line says what is wrong, the rest is usually more of it
+	first, _, _ := strings.Cut(strings.TrimSpace(string(output)), "\n")
+	if first == "" {
+		return err
+	}
+	return errors.New(first)
+}
+
+// checkVault makes sure the vault came back
+func checkVault(dir string) TestCheck {
+	check := TestCheck{Name: "Vault"}
+	files := 0
+	err := filepath.WalkDir(dir, func(path string, entry os.DirEntry, err error) error {
+		if err == nil && !entry.IsDir() {
+			files++
+		}
+		return err
+	})
+	switch {
+	case os.IsNotExist(err):
+		check.Detail = "the backup has no vault, so secrets can't be restored"
+	case err != nil:
+		check.Detail = err.Error()
+	default:
+		check.OK = true
+		check.Detail = fmt.Sprintf("%d files", files)
+	}
+	return check
+}
+
+// testResultsPath is where test restore results are kept
+func (bm *BackupManager) testResultsPath() string {
+	return filepath.Join(bm.config.BackupDir, "test-restores.json")
+}
+
+// TestRestoreResults returns the last test restores, newest first
+func (bm *BackupManager) TestRestoreResults() []TestRestoreResult {
+	data, err := os.ReadFile(bm.testResultsPath())
+	if err != nil {
+		return nil
+	}
+	var results []TestRestoreResult
+	if err := json.Unmarshal(data, &results); err != nil {
+		log.Printf("Warning: Failed to read test restore results: %v", err)
+		return nil
+	}
+	return results
+}
+
+// saveTestResult adds a result to the ones kept
+func (bm *BackupManager) saveTestResult(result *TestRestoreResult) error {
+	results := append([]TestRestoreResult{*result}, bm.TestRestoreResults()...)
+	if len(results) > testRestoreHistory {
+		results = results[:testRestoreHistory]
+	}
+	data, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(bm.config.BackupDir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(bm.testResultsPath(), data, 0644)
+}
@@ -0,0 +1,161 @@
+package bitbucket
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"workspace/models"
+)
+
+// apiBaseURL is the Bitbucket Cloud REST API
+const apiBaseURL = "https://api.bitbucket.org/2.0"
+
+// BitbucketClient provides Bitbucket Cloud API operations using credentials from vault
+type BitbucketClient struct {
+	creds   *models.BitbucketCredentials
+	baseURL string
+	client  *http.Client
+}
+
+// BitbucketUser represents a Bitbucket account
+type BitbucketUser struct {
+	UUID        string `json:"uuid"`
+	Username    string `json:"username"`
+	DisplayName string `json:"display_name"`
+}
+
+// BitbucketRepository represents a repository from the Bitbucket API
+type BitbucketRepository struct {
+	UUID       string `json:"uuid"`
+	Name       string `json:"name"`
+	FullName   string `json:"full_name"` // workspace/slug
+	IsPrivate  bool   `json:"is_private"`
+	MainBranch struct {
+		Name string `json:"name"`
+	} `json:"mainbranch"`
+	Links struct {
+		HTML struct {
+			Href string `json:"href"`
+		} `json:"html"`
+	} `json:"links"`
+}
+
+// NewBitbucketClient creates a Bitbucket client for a user using credentials from vault
+func NewBitbucketClient(userID string) (*BitbucketClient, error) {
+	creds, err := models.GetBitbucketCredentials(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Bitbucket credentials: %w", err)
+	}
+	return NewBitbucketClientWithCredentials(creds), nil
+}
+
+// NewBitbucketClientWithCredentials creates a Bitbucket client with specific credentials,
+// used to verify them before they are stored
+func NewBitbucketClientWithCredentials(creds *models.BitbucketCredentials) *BitbucketClient {
+	return &BitbucketClient{
+		creds:   creds,
+		baseURL: apiBaseURL,
+		client:  &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// CurrentUser returns the account the credentials belong to
+func (c *BitbucketClient) CurrentUser() (*BitbucketUser, error) {
+	var user BitbucketUser
+	if err := c.get("/user", &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// GetRepository returns a repository the credentials can access
+func (c *BitbucketClient) GetRepository(workspace, slug string) (*BitbucketRepository, error) {
+	var repo BitbucketRepository
+	path := fmt.Sprintf("/repositories/%s/%s", url.PathEscape(workspace), url.PathEscape(slug))
+	if err := c.get(path, &repo); err != nil {
+		return nil, err
+	}
+	return &repo, nil
+}
+
+// get performs an authenticated API request and decodes the JSON response
+func (c *BitbucketClient) get(path string, v any) error {
+	req, err := http.NewRequest("GET", c.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+
+	if c.creds.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.creds.Token)
+	} else {
+		req.SetBasicAuth(c.creds.Username, c.creds.AppPassword)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("network error: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized:
+		return fmt.Errorf("Bitbucket authentication failed. Check your app password or reconnect your account")
+	case http.StatusForbidden:
+		return fmt.Errorf("insufficient permissions for this Bitbucket operation")
+	case http.StatusNotFound:
+		return fmt.Errorf("Bitbucket repository not found or not accessible")
+	case http.StatusTooManyRequests:
+		return fmt.Errorf("Bitbucket API rate limit exceeded. Try again later")
+	default:
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("Bitbucket API error (status %d): %s", resp.StatusCode, string(body))
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode Bitbucket response: %w", err)
+	}
+	return nil
+}
+
+// bitbucketName matches workspace IDs and repository slugs
+var bitbucketName = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// ParseBitbucketURL extracts the workspace and repository slug from a
+// Bitbucket Cloud HTTPS or SSH URL
+func ParseBitbucketURL(rawURL string) (workspace, slug string, err error) {
+	rawURL = strings.TrimSpace(rawURL)
+	rawURL = strings.TrimSuffix(strings.TrimSuffix(rawURL, "/"), ".git")
+
+	var path string
+	switch {
+	case strings.HasPrefix(rawURL, "git@bitbucket.org:"):
+		path = strings.TrimPrefix(rawURL, "git@bitbucket.org:")
+	case strings.HasPrefix(rawURL, "https://"):
+		u, err := url.Parse(rawURL)
+		if err != nil || u.Host != "bitbucket.org" {
+			return "", "", fmt.Errorf("not a Bitbucket Cloud URL: %s", rawURL)
+		}
+		path = strings.TrimPrefix(u.Path, "/")
+	default:
+		return "", "", fmt.Errorf("not a Bitbucket Cloud URL: %s", rawURL)
+	}
+
+	parts := strings.Split(path, "/")
+	if len(parts) != 2 || !bitbucketName.MatchString(parts[0]) || !bitbucketName.MatchString(parts[1]) {
+		return "", "", fmt.Errorf("invalid Bitbucket URL: expected bitbucket.org/workspace/repository")
+	}
+	return parts[0], parts[1], nil
+}
+
+// CloneURL returns the HTTPS clone URL of a repository, without credentials
+func CloneURL(workspace, slug string) string {
+	return fmt.Sprintf("https://bitbucket.org/%s/%s.git", workspace, slug)
+}
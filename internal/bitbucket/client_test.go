@@ -0,0 +1,102 @@
+package bitbucket
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"workspace/models"
+)
+
+func TestParseBitbucketURL(t *testing.T) {
+	tests := []struct {
+		name          string
+		url           string
+		wantWorkspace string
+		wantSlug      string
+		wantErr       bool
+	}{
+		{name: "HTTPS URL", url: "https://bitbucket.org/team/repo", wantWorkspace: "team", wantSlug: "repo"},
+		{name: "HTTPS URL with .git", url: "https://bitbucket.org/team/repo.git", wantWorkspace: "team", wantSlug: "repo"},
+		{name: "HTTPS URL with username", url: "https://alice@bitbucket.org/team/my.repo.git", wantWorkspace: "team", wantSlug: "my.repo"},
+		{name: "SSH URL", url: "git@bitbucket.org:team/repo.git", wantWorkspace: "team", wantSlug: "repo"},
+		{name: "Invalid URL - not Bitbucket", url: "https://github.com/team/repo", wantErr: true},
+		{name: "Invalid URL - missing slug", url: "https://bitbucket.org/team", wantErr: true},
+		{name: "Invalid URL - extra path", url: "https://bitbucket.org/team/repo/src/main", wantErr: true},
+		{name: "Invalid URL - empty", url: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workspace, slug, err := ParseBitbucketURL(tt.url)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseBitbucketURL() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if workspace != tt.wantWorkspace || slug != tt.wantSlug {
+				t.Errorf("ParseBitbucketURL() = %s/%s, want %s/%s", workspace, slug, tt.wantWorkspace, tt.wantSlug)
+			}
+		})
+	}
+}
+
+func TestBitbucketClientAuthentication(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); ok && user == "alice" && pass == "app-pass" {
+			w.Write([]byte(`{"username": "alice", "display_name": "Alice"}`))
+			return
+		}
+		if r.Header.Get("Authorization") == "Bearer oauth-token" {
+			w.Write([]byte(`{"username": "bob"}`))
+			return
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	client := NewBitbucketClientWithCredentials(&models.BitbucketCredentials{Username: "alice", AppPassword: "app-pass"})
+	client.baseURL = server.URL
+	user, err := client.CurrentUser()
+	if err != nil || user.Username != "alice" {
+		t.Fatalf("CurrentUser() with app password = %v, %v", user, err)
+	}
+
+	client = NewBitbucketClientWithCredentials(&models.BitbucketCredentials{Token: "oauth-token"})
+	client.baseURL = server.URL
+	user, err = client.CurrentUser()
+	if err != nil || user.Username != "bob" {
+		t.Fatalf("CurrentUser() with OAuth token = %v, %v", user, err)
+	}
+
+	client = NewBitbucketClientWithCredentials(&models.BitbucketCredentials{Username: "alice", AppPassword: "wrong"})
+	client.baseURL = server.URL
+	if _, err := client.CurrentUser(); err == nil || !strings.Contains(err.Error(), "authentication failed") {
+		t.Errorf("Expected authentication error, got %v", err)
+	}
+}
+
+func TestBitbucketClientGetRepository(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repositories/team/repo" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"full_name": "team/repo", "is_private": true, "mainbranch": {"name": "develop"}}`))
+	}))
+	defer server.Close()
+
+	client := NewBitbucketClientWithCredentials(&models.BitbucketCredentials{Token: "token"})
+	client.baseURL = server.URL
+
+	repo, err := client.GetRepository("team", "repo")
+	if err != nil {
+		t.Fatalf("GetRepository() error = %v", err)
+	}
+	if repo.FullName != "team/repo" || !repo.IsPrivate || repo.MainBranch.Name != "develop" {
+		t.Errorf("GetRepository() = %+v", repo)
+	}
+
+	if _, err := client.GetRepository("team", "missing"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected not found error, got %v", err)
+	}
+}
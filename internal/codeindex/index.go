@@ -0,0 +1,306 @@
+// Package codeindex keeps an in-memory trigram and symbol index of a
+// repository snapshot, so text, file and symbol searches don't have to read
+// every file on each request.
+package codeindex
+
+import (
+	"bytes"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ContextLines is how many lines are returned around a text match
+const ContextLines = 2
+
+// Symbol is a definition found in a file
+type Symbol struct {
+	Name string
+	Kind string // "func", "method", "type", "class", ...
+	Line int    // 1-indexed
+}
+
+// File is one indexed file
+type File struct {
+	Path    string
+	Blob    string // Git blob hash, used to reuse unchanged files
+	Content string
+	Symbols []Symbol
+
+	lower string
+}
+
+// NewFile prepares a file for indexing. It returns nil for binary content.
+func NewFile(filePath, blob string, content []byte) *File {
+	if bytes.IndexByte(content, 0) >= 0 {
+		return nil
+	}
+	text := string(content)
+	return &File{
+		Path:    filePath,
+		Blob:    blob,
+		Content: text,
+		Symbols: extractSymbols(filePath, text),
+		lower:   strings.ToLower(text),
+	}
+}
+
+// Index is a searchable snapshot of a repository at one commit
+type Index struct {
+	Commit  string
+	BuiltAt time.Time
+
+	files    []*File
+	byPath   map[string]*File
+	postings map[uint32][]int32 // Trigram to the files containing it, ascending
+}
+
+// Build indexes files as the snapshot of commit
+func Build(commit string, files []*File) *Index {
+	slices.SortFunc(files, func(a, b *File) int { return strings.Compare(a.Path, b.Path) })
+
+	ix := &Index{
+		Commit:   commit,
+		BuiltAt:  time.Now(),
+		files:    files,
+		byPath:   make(map[string]*File, len(files)),
+		postings: map[uint32][]int32{},
+	}
+	for i, file := range files {
+		ix.byPath[file.Path] = file
+		for _, tri := range trigrams(file.lower) {
+			ix.postings[tri] = append(ix.postings[tri], int32(i))
+		}
+	}
+	return ix
+}
+
+// Len returns how many files are indexed
+func (ix *Index) Len() int {
+	return len(ix.files)
+}
+
+// File returns an indexed file by path
+func (ix *Index) File(filePath string) (*File, bool) {
+	file, ok := ix.byPath[filePath]
+	return file, ok
+}
+
+// Paths returns every indexed path in order
+func (ix *Index) Paths() []string {
+	paths := make([]string, len(ix.files))
+	for i, file := range ix.files {
+		paths[i] = file.Path
+	}
+	return paths
+}
+
+// Match is a line containing the searched text
+type Match struct {
+	Path    string
+	Line    int // 1-indexed
+	Text    string
+	Context []string // ContextLines either side, including the line itself
+}
+
+// Search returns up to limit lines containing query, ignoring case
+func (ix *Index) Search(query string, limit int) []Match {
+	query = strings.ToLower(query)
+	if query == "" {
+		return nil
+	}
+
+	var matches []Match
+	for _, i := range ix.candidates(query) {
+		file := ix.files[i]
+		if !strings.Contains(file.lower, query) {
+			continue
+		}
+		lines := strings.Split(file.Content, "\n")
+		for n, line := range lines {
+			if !strings.Contains(strings.ToLower(line), query) {
+				continue
+			}
+			start, end := max(n-ContextLines, 0), min(n+ContextLines+1, len(lines))
+			matches = append(matches, Match{
+				Path:    file.Path,
+				Line:    n + 1,
+				Text:    line,
+				Context: lines[start:end],
+			})
+			if limit > 0 && len(matches) >= limit {
+				return matches
+			}
+		}
+	}
+	return matches
+}
+
+// FindFiles returns the paths whose file name satisfies match
+func (ix *Index) FindFiles(match func(name string) bool) []string {
+	var paths []string
+	for _, file := range ix.files {
+		if match(path.Base(file.Path)) {
+			paths = append(paths, file.Path)
+		}
+	}
+	return paths
+}
+
+// SymbolMatch is a definition and the file it is in
+type SymbolMatch struct {
+	Path string
+	Symbol
+}
+
+// FindSymbols returns up to limit definitions whose name contains query,
+// ignoring case. Exact name matches come first.
+func (ix *Index) FindSymbols(query string, limit int) []SymbolMatch {
+	query = strings.ToLower(query)
+	if query == "" {
+		return nil
+	}
+
+	var exact, partial []SymbolMatch
+	for _, file := range ix.files {
+		for _, symbol := range file.Symbols {
+			name := strings.ToLower(symbol.Name)
+			switch {
+			case name == query:
+				exact = append(exact, SymbolMatch{Path: file.Path, Symbol: symbol})
+			case strings.Contains(name, query):
+				partial = append(partial, SymbolMatch{Path: file.Path, Symbol: symbol})
+			}
+		}
+	}
+	matches := append(exact, partial...)
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
+
+// candidates returns the files that contain every trigram of query, or all
+// files when the query is too short to have any
+func (ix *Index) candidates(query string) []int32 {
+	tris := trigrams(query)
+	if len(tris) == 0 {
+		all := make([]int32, len(ix.files))
+		for i := range all {
+			all[i] = int32(i)
+		}
+		return all
+	}
+
+	// Intersect starting from the rarest trigram
+	slices.SortFunc(tris, func(a, b uint32) int { return len(ix.postings[a]) - len(ix.postings[b]) })
+	result := ix.postings[tris[0]]
+	for _, tri := range tris[1:] {
+		if len(result) == 0 {
+			break
+		}
+		result = intersect(result, ix.postings[tri])
+	}
+	return result
+}
+
+// trigrams returns the distinct three-byte sequences of s
+func trigrams(s string) []uint32 {
+	if len(s) < 3 {
+		return nil
+	}
+	seen := make(map[uint32]bool, len(s))
+	var tris []uint32
+	for i := 0; i+3 <= len(s); i++ {
+		tri := uint32(s[i])<<16 | uint32(s[i+1])<<8 | uint32(s[i+2])
+		if !seen[tri] {
+			seen[tri] = true
+			tris = append(tris, tri)
+		}
+	}
+	return tris
+}
+
+// intersect returns the values in both ascending lists
+func intersect(a, b []int32) []int32 {
+	var out []int32
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			out = append(out, a[i])
+			i++
+			j++
+		}
+	}
+	return out
+}
+
+// symbolPattern finds one kind of definition. The name is the last submatch.
+type symbolPattern struct {
+	kind string
+	re   *regexp.Regexp
+}
+
+// symbolPatterns find definitions by file extension
+var symbolPatterns = map[string][]symbolPattern{
+	".go": {
+		{"method", regexp.MustCompile(`^func \([^)]*\) (\w+)`)},
+		{"func", regexp.MustCompile(`^func (\w+)`)},
+		{"type", regexp.MustCompile(`^type (\w+)`)},
+	},
+	".py": {
+		{"class", regexp.MustCompile(`^\s*class (\w+)`)},
+		{"func", regexp.MustCompile(`^\s*(?:async )?def (\w+)`)},
+	},
+	".js":  jsPatterns,
+	".jsx": jsPatterns,
+	".ts":  jsPatterns,
+	".tsx": jsPatterns,
+	".rs": {
+		{"func", regexp.MustCompile(`^\s*(?:pub(?:\([^)]*\))? )?(?:async )?fn (\w+)`)},
+		{"type", regexp.MustCompile(`^\s*(?:pub(?:\([^)]*\))? )?(?:struct|enum|trait) (\w+)`)},
+	},
+	".java": classPatterns,
+	".kt":   classPatterns,
+	".cs":   classPatterns,
+	".rb": {
+		{"class", regexp.MustCompile(`^\s*(?:class|module) (\w+)`)},
+		{"func", regexp.MustCompile(`^\s*def (?:self\.)?(\w+)`)},
+	},
+}
+
+var jsPatterns = []symbolPattern{
+	{"class", regexp.MustCompile(`^\s*(?:export )?(?:default )?(?:abstract )?class (\w+)`)},
+	{"func", regexp.MustCompile(`^\s*(?:export )?(?:default )?(?:async )?function\*? (\w+)`)},
+	{"func", regexp.MustCompile(`^\s*(?:export )?(?:const|let) (\w+) = (?:async )?(?:\([^)]*\)|\w+) =>`)},
+	{"type", regexp.MustCompile(`^\s*(?:export )?(?:interface|type|enum) (\w+)`)},
+}
+
+var classPatterns = []symbolPattern{
+	{"class", regexp.MustCompile(`^\s*(?:(?:public|private|protected|internal|abstract|final|sealed|static|data|open) )*(?:class|interface|enum|record|object) (\w+)`)},
+}
+
+// extractSymbols finds the definitions in a file, one per line at most
+func extractSymbols(filePath, content string) []Symbol {
+	patterns := symbolPatterns[strings.ToLower(path.Ext(filePath))]
+	if len(patterns) == 0 {
+		return nil
+	}
+
+	var symbols []Symbol
+	for n, line := range strings.Split(content, "\n") {
+		for _, p := range patterns {
+			if m := p.re.FindStringSubmatch(line); m != nil {
+				symbols = append(symbols, Symbol{Name: m[len(m)-1], Kind: p.kind, Line: n + 1})
+				break
+			}
+		}
+	}
+	return symbols
+}
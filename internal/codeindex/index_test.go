@@ -0,0 +1,76 @@
+package codeindex
+
+import (
+	"strings"
+	"testing"
+)
+
+func testIndex() *Index {
+	return Build("abc123", []*File{
+		NewFile("main.go", "1", []byte("package main\n\nfunc main() {\n\tstartServer()\n}\n")),
+		NewFile("server/server.go", "2", []byte("package server\n\ntype Server struct{}\n\nfunc (s *Server) StartServer() error {\n\treturn nil\n}\n")),
+		NewFile("web/app.ts", "3", []byte("export class App {}\nexport const render = (el) => el\n")),
+		NewFile("README.md", "4", []byte("# Demo\n\nRun the server with go run.\n")),
+	})
+}
+
+func TestNewFileSkipsBinary(t *testing.T) {
+	if NewFile("logo.png", "5", []byte("\x89PNG\x00\x01")) != nil {
+		t.Error("binary content should not be indexed")
+	}
+}
+
+func TestSearch(t *testing.T) {
+	ix := testIndex()
+	if ix.Len() != 4 {
+		t.Fatalf("Len = %d, want 4", ix.Len())
+	}
+
+	matches := ix.Search("STARTSERVER", 0)
+	if len(matches) != 2 {
+		t.Fatalf("got %d matches, want 2: %+v", len(matches), matches)
+	}
+	if matches[0].Path != "main.go" || matches[0].Line != 4 || strings.TrimSpace(matches[0].Text) != "startServer()" {
+		t.Errorf("unexpected first match %+v", matches[0])
+	}
+	if len(matches[0].Context) != 5 {
+		t.Errorf("context has %d lines, want 5", len(matches[0].Context))
+	}
+
+	if got := ix.Search("server", 1); len(got) != 1 {
+		t.Errorf("limit ignored, got %d matches", len(got))
+	}
+	if got := ix.Search("go", 0); len(got) != 1 || got[0].Path != "README.md" {
+		t.Errorf("short query = %+v", got)
+	}
+	if got := ix.Search("missing text", 0); len(got) != 0 {
+		t.Errorf("expected no matches, got %+v", got)
+	}
+}
+
+func TestFindFilesAndSymbols(t *testing.T) {
+	ix := testIndex()
+
+	files := ix.FindFiles(func(name string) bool { return strings.HasSuffix(name, ".go") })
+	if len(files) != 2 || files[0] != "main.go" || files[1] != "server/server.go" {
+		t.Errorf("FindFiles = %v", files)
+	}
+
+	symbols := ix.FindSymbols("server", 0)
+	if len(symbols) != 2 || symbols[0].Name != "Server" || symbols[0].Kind != "type" || symbols[1].Kind != "method" {
+		t.Errorf("FindSymbols = %+v", symbols)
+	}
+	if symbols := ix.FindSymbols("render", 0); len(symbols) != 1 || symbols[0].Path != "web/app.ts" || symbols[0].Line != 2 {
+		t.Errorf("FindSymbols(render) = %+v", symbols)
+	}
+	if file, ok := ix.File("web/app.ts"); !ok || len(file.Symbols) != 2 || file.Symbols[0].Name != "App" {
+		t.Errorf("File symbols = %+v", file)
+	}
+}
+
+func TestIntersect(t *testing.T) {
+	got := intersect([]int32{1, 3, 5, 7}, []int32{2, 3, 4, 7, 9})
+	if len(got) != 2 || got[0] != 3 || got[1] != 7 {
+		t.Errorf("intersect = %v", got)
+	}
+}
@@ -0,0 +1,420 @@
+// Package collab provides real-time collaborative editing of text files
+// using operational transformation.
+//
+// Operations follow the ot.js model: a sequence of retain, insert and delete
+// components that together span the whole document. Lengths are counted in
+// UTF-16 code units so that offsets agree with JavaScript strings in the
+// browser.
+package collab
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"unicode/utf16"
+)
+
+// component is a single step of an operation. A positive n retains, a
+// negative n deletes and a non-nil text inserts.
+type component struct {
+	n    int
+	text []uint16
+}
+
+func (c component) isRetain() bool { return c.text == nil && c.n > 0 }
+func (c component) isDelete() bool { return c.text == nil && c.n < 0 }
+func (c component) isInsert() bool { return c.text != nil }
+
+// Operation is a change to a document
+type Operation struct {
+	components []component
+	BaseLen    int // Length of the document the operation applies to
+	TargetLen  int // Length of the document after applying the operation
+}
+
+// NewOperation returns an empty operation
+func NewOperation() *Operation {
+	return &Operation{}
+}
+
+// Retain skips over n units of the document
+func (o *Operation) Retain(n int) *Operation {
+	if n <= 0 {
+		return o
+	}
+	o.BaseLen += n
+	o.TargetLen += n
+	if last := len(o.components) - 1; last >= 0 && o.components[last].isRetain() {
+		o.components[last].n += n
+	} else {
+		o.components = append(o.components, component{n: n})
+	}
+	return o
+}
+
+// Insert adds text at the current position
+func (o *Operation) Insert(text string) *Operation {
+	return o.insert(encode(text))
+}
+
+func (o *Operation) insert(text []uint16) *Operation {
+	if len(text) == 0 {
+		return o
+	}
+	o.TargetLen += len(text)
+	last := len(o.components) - 1
+	switch {
+	case last >= 0 && o.components[last].isInsert():
+		o.components[last].text = append(o.components[last].text, text...)
+	case last >= 0 && o.components[last].isDelete():
+		// Keep inserts ahead of deletes so equal operations compare equal
+		if last > 0 && o.components[last-1].isInsert() {
+			o.components[last-1].text = append(o.components[last-1].text, text...)
+		} else {
+			del := o.components[last]
+			o.components[last] = component{text: append([]uint16{}, text...)}
+			o.components = append(o.components, del)
+		}
+	default:
+		o.components = append(o.components, component{text: append([]uint16{}, text...)})
+	}
+	return o
+}
+
+// Delete removes n units at the current position
+func (o *Operation) Delete(n int) *Operation {
+	if n < 0 {
+		n = -n
+	}
+	if n == 0 {
+		return o
+	}
+	o.BaseLen += n
+	if last := len(o.components) - 1; last >= 0 && o.components[last].isDelete() {
+		o.components[last].n -= n
+	} else {
+		o.components = append(o.components, component{n: -n})
+	}
+	return o
+}
+
+// IsNoop returns true if the operation leaves the document unchanged
+func (o *Operation) IsNoop() bool {
+	return len(o.components) == 0 || (len(o.components) == 1 && o.components[0].isRetain())
+}
+
+// Apply returns the document with the operation applied
+func (o *Operation) Apply(doc string) (string, error) {
+	units := encode(doc)
+	if len(units) != o.BaseLen {
+		return "", fmt.Errorf("operation expects a document of length %d, got %d", o.BaseLen, len(units))
+	}
+
+	result := make([]uint16, 0, o.TargetLen)
+	index := 0
+	for _, c := range o.components {
+		switch {
+		case c.isRetain():
+			result = append(result, units[index:index+c.n]...)
+			index += c.n
+		case c.isInsert():
+			result = append(result, c.text...)
+		default:
+			index -= c.n
+		}
+	}
+	return decode(result), nil
+}
+
+// TransformIndex moves a cursor position so it points at the same place
+// after the operation is applied
+func (o *Operation) TransformIndex(index int) int {
+	newIndex := index
+	for _, c := range o.components {
+		switch {
+		case c.isRetain():
+			index -= c.n
+		case c.isInsert():
+			newIndex += len(c.text)
+		default:
+			newIndex -= min(index, -c.n)
+			index += c.n
+		}
+		if index < 0 {
+			break
+		}
+	}
+	return newIndex
+}
+
+// Compose merges a and b into a single operation with the same effect as
+// applying a followed by b
+func Compose(a, b *Operation) (*Operation, error) {
+	if a.TargetLen != b.BaseLen {
+		return nil, errors.New("compose requires the first operation's target length to match the second's base length")
+	}
+
+	result := NewOperation()
+	ops1, ops2 := a.components, b.components
+	i1, i2 := 0, 0
+	op1, ok1 := next(ops1, &i1)
+	op2, ok2 := next(ops2, &i2)
+	for ok1 || ok2 {
+		if ok1 && op1.isDelete() {
+			result.Delete(op1.n)
+			op1, ok1 = next(ops1, &i1)
+			continue
+		}
+		if ok2 && op2.isInsert() {
+			result.insert(op2.text)
+			op2, ok2 = next(ops2, &i2)
+			continue
+		}
+		if !ok1 || !ok2 {
+			return nil, errors.New("compose failed: operations have different lengths")
+		}
+
+		switch {
+		case op1.isRetain() && op2.isRetain():
+			switch {
+			case op1.n > op2.n:
+				result.Retain(op2.n)
+				op1.n -= op2.n
+				op2, ok2 = next(ops2, &i2)
+			case op1.n == op2.n:
+				result.Retain(op1.n)
+				op1, ok1 = next(ops1, &i1)
+				op2, ok2 = next(ops2, &i2)
+			default:
+				result.Retain(op1.n)
+				op2.n -= op1.n
+				op1, ok1 = next(ops1, &i1)
+			}
+		case op1.isInsert() && op2.isDelete():
+			switch {
+			case len(op1.text) > -op2.n:
+				op1.text = op1.text[-op2.n:]
+				op2, ok2 = next(ops2, &i2)
+			case len(op1.text) == -op2.n:
+				op1, ok1 = next(ops1, &i1)
+				op2, ok2 = next(ops2, &i2)
+			default:
+				op2.n += len(op1.text)
+				op1, ok1 = next(ops1, &i1)
+			}
+		case op1.isInsert() && op2.isRetain():
+			switch {
+			case len(op1.text) > op2.n:
+				result.insert(op1.text[:op2.n])
+				op1.text = op1.text[op2.n:]
+				op2, ok2 = next(ops2, &i2)
+			case len(op1.text) == op2.n:
+				result.insert(op1.text)
+				op1, ok1 = next(ops1, &i1)
+				op2, ok2 = next(ops2, &i2)
+			default:
+				result.insert(op1.text)
+				op2.n -= len(op1.text)
+				op1, ok1 = next(ops1, &i1)
+			}
+		case op1.isRetain() && op2.isDelete():
+			switch {
+			case op1.n > -op2.n:
+				result.Delete(op2.n)
+				op1.n += op2.n
+				op2, ok2 = next(ops2, &i2)
+			case op1.n == -op2.n:
+				result.Delete(op2.n)
+				op1, ok1 = next(ops1, &i1)
+				op2, ok2 = next(ops2, &i2)
+			default:
+				result.Delete(op1.n)
+				op2.n += op1.n
+				op1, ok1 = next(ops1, &i1)
+			}
+		}
+	}
+	return result, nil
+}
+
+// Transform takes two operations made concurrently against the same document
+// and returns a' and b' such that applying a then b' equals applying b then
+// a'. When both insert at the same position, a's text comes first.
+func Transform(a, b *Operation) (*Operation, *Operation, error) {
+	if a.BaseLen != b.BaseLen {
+		return nil, nil, errors.New("transform requires both operations to have the same base length")
+	}
+
+	aPrime, bPrime := NewOperation(), NewOperation()
+	ops1, ops2 := a.components, b.components
+	i1, i2 := 0, 0
+	op1, ok1 := next(ops1, &i1)
+	op2, ok2 := next(ops2, &i2)
+	for ok1 || ok2 {
+		if ok1 && op1.isInsert() {
+			aPrime.insert(op1.text)
+			bPrime.Retain(len(op1.text))
+			op1, ok1 = next(ops1, &i1)
+			continue
+		}
+		if ok2 && op2.isInsert() {
+			aPrime.Retain(len(op2.text))
+			bPrime.insert(op2.text)
+			op2, ok2 = next(ops2, &i2)
+			continue
+		}
+		if !ok1 || !ok2 {
+			return nil, nil, errors.New("transform failed: operations have different lengths")
+		}
+
+		switch {
+		case op1.isRetain() && op2.isRetain():
+			var n int
+			switch {
+			case op1.n > op2.n:
+				n = op2.n
+				op1.n -= op2.n
+				op2, ok2 = next(ops2, &i2)
+			case op1.n == op2.n:
+				n = op2.n
+				op1, ok1 = next(ops1, &i1)
+				op2, ok2 = next(ops2, &i2)
+			default:
+				n = op1.n
+				op2.n -= op1.n
+				op1, ok1 = next(ops1, &i1)
+			}
+			aPrime.Retain(n)
+			bPrime.Retain(n)
+		case op1.isDelete() && op2.isDelete():
+			// Both deleted the same text, so neither needs to delete it again
+			switch {
+			case -op1.n > -op2.n:
+				op1.n -= op2.n
+				op2, ok2 = next(ops2, &i2)
+			case op1.n == op2.n:
+				op1, ok1 = next(ops1, &i1)
+				op2, ok2 = next(ops2, &i2)
+			default:
+				op2.n -= op1.n
+				op1, ok1 = next(ops1, &i1)
+			}
+		case op1.isDelete() && op2.isRetain():
+			var n int
+			switch {
+			case -op1.n > op2.n:
+				n = op2.n
+				op1.n += op2.n
+				op2, ok2 = next(ops2, &i2)
+			case -op1.n == op2.n:
+				n = op2.n
+				op1, ok1 = next(ops1, &i1)
+				op2, ok2 = next(ops2, &i2)
+			default:
+				n = -op1.n
+				op2.n += op1.n
+				op1, ok1 = next(ops1, &i1)
+			}
+			aPrime.Delete(n)
+		case op1.isRetain() && op2.isDelete():
+			var n int
+			switch {
+			case op1.n > -op2.n:
+				n = -op2.n
+				op1.n += op2.n
+				op2, ok2 = next(ops2, &i2)
+			case op1.n == -op2.n:
+				n = op1.n
+				op1, ok1 = next(ops1, &i1)
+				op2, ok2 = next(ops2, &i2)
+			default:
+				n = op1.n
+				op2.n += op1.n
+				op1, ok1 = next(ops1, &i1)
+			}
+			bPrime.Delete(n)
+		}
+	}
+	return aPrime, bPrime, nil
+}
+
+// Diff returns an operation that turns before into after by replacing the
+// span between their common prefix and suffix
+func Diff(before, after string) *Operation {
+	a, b := encode(before), encode(after)
+
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	return NewOperation().
+		Retain(prefix).
+		Delete(len(a) - prefix - suffix).
+		insert(b[prefix : len(b)-suffix]).
+		Retain(suffix)
+}
+
+// MarshalJSON encodes the operation in the ot.js wire format: an array of
+// positive retains, negative deletes and insert strings
+func (o *Operation) MarshalJSON() ([]byte, error) {
+	parts := make([]any, 0, len(o.components))
+	for _, c := range o.components {
+		if c.isInsert() {
+			parts = append(parts, decode(c.text))
+		} else {
+			parts = append(parts, c.n)
+		}
+	}
+	return json.Marshal(parts)
+}
+
+// UnmarshalJSON decodes an operation from the ot.js wire format
+func (o *Operation) UnmarshalJSON(data []byte) error {
+	var parts []any
+	if err := json.Unmarshal(data, &parts); err != nil {
+		return err
+	}
+
+	*o = Operation{}
+	for _, part := range parts {
+		switch v := part.(type) {
+		case float64:
+			if v != float64(int(v)) {
+				return fmt.Errorf("invalid operation component %v", v)
+			}
+			if v > 0 {
+				o.Retain(int(v))
+			} else {
+				o.Delete(int(v))
+			}
+		case string:
+			o.Insert(v)
+		default:
+			return fmt.Errorf("invalid operation component %v", v)
+		}
+	}
+	return nil
+}
+
+// next returns the component at *i and advances it
+func next(components []component, i *int) (component, bool) {
+	if *i >= len(components) {
+		return component{}, false
+	}
+	c := components[*i]
+	*i++
+	return c, true
+}
+
+func encode(s string) []uint16 {
+	return utf16.Encode([]rune(s))
+}
+
+func decode(units []uint16) string {
+	return string(utf16.Decode(units))
+}
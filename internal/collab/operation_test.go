@@ -0,0 +1,109 @@
+package collab
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestApply(t *testing.T) {
+	op := NewOperation().Retain(6).Delete(5).Insert("there").Retain(1)
+	got, err := op.Apply("hello world!")
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if got != "hello there!" {
+		t.Errorf("Apply = %q, want %q", got, "hello there!")
+	}
+
+	if _, err := op.Apply("short"); err == nil {
+		t.Error("Apply should reject a document of the wrong length")
+	}
+}
+
+func TestTransformConverges(t *testing.T) {
+	doc := "the quick brown fox"
+	cases := []struct {
+		name string
+		a, b *Operation
+	}{
+		{"inserts at different positions", NewOperation().Retain(4).Insert("very ").Retain(15), NewOperation().Retain(19).Insert(" jumps")},
+		{"inserts at the same position", NewOperation().Retain(10).Insert("red ").Retain(9), NewOperation().Retain(10).Insert("big ").Retain(9)},
+		{"overlapping deletes", NewOperation().Retain(4).Delete(10).Retain(5), NewOperation().Retain(10).Delete(6).Retain(3)},
+		{"insert inside a delete", NewOperation().Retain(4).Delete(6).Retain(9), NewOperation().Retain(7).Insert("!!").Retain(12)},
+		{"replace all against edit", Diff(doc, "something else"), NewOperation().Retain(16).Insert("f").Retain(3)},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			aPrime, bPrime, err := Transform(tc.a, tc.b)
+			if err != nil {
+				t.Fatalf("Transform failed: %v", err)
+			}
+
+			afterA, _ := tc.a.Apply(doc)
+			left, err := bPrime.Apply(afterA)
+			if err != nil {
+				t.Fatalf("applying b' failed: %v", err)
+			}
+			afterB, _ := tc.b.Apply(doc)
+			right, err := aPrime.Apply(afterB)
+			if err != nil {
+				t.Fatalf("applying a' failed: %v", err)
+			}
+			if left != right {
+				t.Errorf("documents diverged: %q vs %q", left, right)
+			}
+		})
+	}
+}
+
+func TestCompose(t *testing.T) {
+	doc := "abcdef"
+	a := NewOperation().Retain(3).Insert("XYZ").Retain(3)
+	b := NewOperation().Retain(1).Delete(4).Retain(4)
+
+	composed, err := Compose(a, b)
+	if err != nil {
+		t.Fatalf("Compose failed: %v", err)
+	}
+
+	afterA, _ := a.Apply(doc)
+	want, _ := b.Apply(afterA)
+	got, err := composed.Apply(doc)
+	if err != nil {
+		t.Fatalf("applying composed operation failed: %v", err)
+	}
+	if got != want {
+		t.Errorf("Compose = %q, want %q", got, want)
+	}
+}
+
+func TestDiffUsesUTF16Offsets(t *testing.T) {
+	op := Diff("a😀b", "a😀cb")
+	data, err := json.Marshal(op)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	// The emoji is a surrogate pair, so it counts as two units like in JavaScript
+	if string(data) != `[3,"c",1]` {
+		t.Errorf("Diff encoded as %s", data)
+	}
+
+	var decoded Operation
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	got, err := decoded.Apply("a😀b")
+	if err != nil || got != "a😀cb" {
+		t.Errorf("Apply = %q, %v", got, err)
+	}
+}
+
+func TestTransformIndex(t *testing.T) {
+	op := NewOperation().Retain(2).Insert("xx").Retain(3).Delete(2).Retain(1)
+	for index, want := range map[int]int{0: 0, 2: 4, 4: 6, 6: 7, 8: 8} {
+		if got := op.TransformIndex(index); got != want {
+			t.Errorf("TransformIndex(%d) = %d, want %d", index, got, want)
+		}
+	}
+}
@@ -0,0 +1,368 @@
+package collab
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+)
+
+// Event types sent to editors
+const (
+	EventSnapshot = "snapshot" // Full document state, sent when an editor joins
+	EventAck      = "ack"      // The editor's own operation was applied
+	EventOp       = "op"       // Another editor's (or the agent's) operation
+	EventPresence = "presence" // A peer joined or moved their selection
+	EventLeave    = "leave"    // A peer closed the editor
+)
+
+// peerColors are assigned to editors in the order they join
+var peerColors = []string{"#3b82f6", "#ef4444", "#10b981", "#f59e0b", "#8b5cf6", "#ec4899", "#14b8a6", "#f97316"}
+
+// Selection is a cursor or selected range in UTF-16 units
+type Selection struct {
+	Anchor int `json:"anchor"`
+	Head   int `json:"head"`
+}
+
+// Peer describes an editor connected to a session
+type Peer struct {
+	ClientID  string    `json:"clientId"`
+	Name      string    `json:"name"`
+	Color     string    `json:"color"`
+	Selection Selection `json:"selection"`
+}
+
+// Event is a message sent to a connected editor
+type Event struct {
+	Type      string     `json:"type"`
+	ClientID  string     `json:"clientId,omitempty"`
+	Author    string     `json:"author,omitempty"`
+	Revision  int        `json:"revision"`
+	Content   *string    `json:"content,omitempty"`
+	Operation *Operation `json:"operation,omitempty"`
+	Peer      *Peer      `json:"peer,omitempty"`
+	Peers     []*Peer    `json:"peers,omitempty"`
+}
+
+// ErrSessionNotFound is returned when there is no open session for a file or
+// the client is no longer part of it
+var ErrSessionNotFound = errors.New("collaboration session not found")
+
+// subscriber is one connected editor
+type subscriber struct {
+	peer   *Peer
+	events chan Event
+}
+
+// session is the shared state of one file being edited
+type session struct {
+	mu      sync.Mutex
+	content string
+	history []*Operation // history[i] turns revision i into revision i+1
+	peers   map[string]*subscriber
+	joined  int // Total editors that have joined, used to pick colors
+
+	// Content last written to disk and the revision it corresponds to, so
+	// changes made to the file outside the editor can be merged
+	savedContent  string
+	savedRevision int
+}
+
+// Hub tracks the open editing sessions
+type Hub struct {
+	mu       sync.Mutex
+	sessions map[string]*session
+}
+
+// Sessions is the hub shared by the web editor and the agent tools
+var Sessions = NewHub()
+
+// NewHub creates an empty hub
+func NewHub() *Hub {
+	return &Hub{sessions: map[string]*session{}}
+}
+
+// Key identifies a file on a branch of a repository
+func Key(repoID, branch, path string) string {
+	return fmt.Sprintf("%s:%s:%s", repoID, branch, path)
+}
+
+// Subscription is an editor's connection to a session
+type Subscription struct {
+	ClientID string
+	Events   <-chan Event
+	leave    func()
+}
+
+// Close removes the editor from the session
+func (s *Subscription) Close() {
+	s.leave()
+}
+
+// Join connects an editor to the session for key, loading the document with
+// load if nobody else has it open. The first event is always a snapshot.
+func (h *Hub) Join(key, name string, load func() (string, error)) (*Subscription, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s, ok := h.sessions[key]
+	if !ok {
+		content, err := load()
+		if err != nil {
+			return nil, err
+		}
+		s = &session{
+			content:      content,
+			peers:        map[string]*subscriber{},
+			savedContent: content,
+		}
+		h.sessions[key] = s
+	}
+
+	id, err := newClientID()
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sub := &subscriber{
+		peer: &Peer{
+			ClientID: id,
+			Name:     name,
+			Color:    peerColors[s.joined%len(peerColors)],
+		},
+		events: make(chan Event, 64),
+	}
+	s.joined++
+
+	peers := make([]*Peer, 0, len(s.peers))
+	for _, other := range s.peers {
+		peer := *other.peer
+		peers = append(peers, &peer)
+	}
+	content := s.content
+	sub.events <- Event{
+		Type:     EventSnapshot,
+		ClientID: id,
+		Revision: len(s.history),
+		Content:  &content,
+		Peer:     sub.peer,
+		Peers:    peers,
+	}
+
+	peer := *sub.peer
+	s.broadcast(id, Event{Type: EventPresence, ClientID: id, Revision: len(s.history), Peer: &peer})
+	s.peers[id] = sub
+
+	return &Subscription{
+		ClientID: id,
+		Events:   sub.events,
+		leave:    func() { h.leave(key, id) },
+	}, nil
+}
+
+// leave removes an editor and closes the session once it is empty
+func (h *Hub) leave(key, clientID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s, ok := h.sessions[key]
+	if !ok {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if sub, ok := s.peers[clientID]; ok {
+		delete(s.peers, clientID)
+		close(sub.events)
+		s.broadcast("", Event{Type: EventLeave, ClientID: clientID, Revision: len(s.history)})
+	}
+	if len(s.peers) == 0 {
+		delete(h.sessions, key)
+	}
+}
+
+// get returns the session for key
+func (h *Hub) get(key string) (*session, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.sessions[key]
+	return s, ok
+}
+
+// Submit applies an editor's operation made against revision, transforming
+// it past any operations the editor had not seen yet
+func (h *Hub) Submit(key, clientID string, revision int, op *Operation, selection *Selection) error {
+	s, ok := h.get(key)
+	if !ok {
+		return ErrSessionNotFound
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sub, ok := s.peers[clientID]
+	if !ok {
+		return ErrSessionNotFound
+	}
+
+	applied, err := s.apply(revision, op)
+	if err != nil {
+		return err
+	}
+
+	if selection != nil {
+		sub.peer.Selection = *selection
+	}
+	s.send(sub, Event{Type: EventAck, ClientID: clientID, Revision: len(s.history)})
+
+	peer := *sub.peer
+	s.broadcast(clientID, Event{
+		Type:      EventOp,
+		ClientID:  clientID,
+		Author:    sub.peer.Name,
+		Revision:  len(s.history),
+		Operation: applied,
+		Peer:      &peer,
+	})
+	return nil
+}
+
+// Select updates an editor's cursor and shares it with the other editors
+func (h *Hub) Select(key, clientID string, selection Selection) error {
+	s, ok := h.get(key)
+	if !ok {
+		return ErrSessionNotFound
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sub, ok := s.peers[clientID]
+	if !ok {
+		return ErrSessionNotFound
+	}
+
+	sub.peer.Selection = selection
+	peer := *sub.peer
+	s.broadcast(clientID, Event{Type: EventPresence, ClientID: clientID, Revision: len(s.history), Peer: &peer})
+	return nil
+}
+
+// ApplyFileChange merges a change made to the file outside the editor, such
+// as an agent patch, into an open session. before is the file content the
+// change was made against. It reports whether a session was open.
+func (h *Hub) ApplyFileChange(key, before, after, author string) bool {
+	s, ok := h.get(key)
+	if !ok {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Rebase the change onto the editors' work when we know which revision
+	// it was made against, otherwise replace the document outright
+	revision, base := s.savedRevision, s.savedContent
+	if before != s.savedContent {
+		revision, base = len(s.history), s.content
+	}
+
+	applied, err := s.apply(revision, Diff(base, after))
+	if err != nil {
+		log.Printf("Collab: Failed to merge external change to %s: %v", key, err)
+		return true
+	}
+	s.savedContent, s.savedRevision = after, len(s.history)
+
+	s.broadcast("", Event{
+		Type:      EventOp,
+		Author:    author,
+		Revision:  len(s.history),
+		Operation: applied,
+	})
+	return true
+}
+
+// MarkSaved records that content was written to disk, so later external
+// changes to the file can be rebased onto the editors' work
+func (h *Hub) MarkSaved(key, content string) {
+	s, ok := h.get(key)
+	if !ok {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if content == s.content {
+		s.savedContent, s.savedRevision = content, len(s.history)
+	}
+}
+
+// apply transforms op from revision to the latest revision, applies it and
+// returns the operation as applied
+func (s *session) apply(revision int, op *Operation) (*Operation, error) {
+	if revision < 0 || revision > len(s.history) {
+		return nil, fmt.Errorf("invalid revision %d", revision)
+	}
+
+	for _, concurrent := range s.history[revision:] {
+		var err error
+		if op, _, err = Transform(op, concurrent); err != nil {
+			return nil, err
+		}
+	}
+
+	content, err := op.Apply(s.content)
+	if err != nil {
+		return nil, err
+	}
+	s.content = content
+	s.history = append(s.history, op)
+
+	for _, sub := range s.peers {
+		sub.peer.Selection = Selection{
+			Anchor: op.TransformIndex(sub.peer.Selection.Anchor),
+			Head:   op.TransformIndex(sub.peer.Selection.Head),
+		}
+	}
+	return op, nil
+}
+
+// broadcast sends an event to every editor except the one given
+func (s *session) broadcast(except string, event Event) {
+	for id, sub := range s.peers {
+		if id != except {
+			s.send(sub, event)
+		}
+	}
+}
+
+// send queues an event for an editor. An editor that falls too far behind is
+// disconnected; it will rejoin and receive a fresh snapshot.
+func (s *session) send(sub *subscriber, event Event) {
+	select {
+	case sub.events <- event:
+	default:
+		log.Printf("Collab: Dropping slow editor %s", sub.peer.ClientID)
+		delete(s.peers, sub.peer.ClientID)
+		close(sub.events)
+	}
+}
+
+// newClientID returns a random identifier for an editor
+func newClientID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,98 @@
+package collab
+
+import (
+	"testing"
+)
+
+func join(t *testing.T, h *Hub, key, name, content string) (*Subscription, Event) {
+	t.Helper()
+	sub, err := h.Join(key, name, func() (string, error) { return content, nil })
+	if err != nil {
+		t.Fatalf("Join failed: %v", err)
+	}
+	return sub, <-sub.Events
+}
+
+func drain(sub *Subscription) []Event {
+	var events []Event
+	for {
+		select {
+		case event := <-sub.Events:
+			events = append(events, event)
+		default:
+			return events
+		}
+	}
+}
+
+func TestConcurrentEditors(t *testing.T) {
+	h := NewHub()
+	key := Key("repo", "main", "README.md")
+
+	alice, snapshot := join(t, h, key, "alice", "hello world")
+	if snapshot.Type != EventSnapshot || *snapshot.Content != "hello world" {
+		t.Fatalf("unexpected snapshot %+v", snapshot)
+	}
+	bob, snapshot := join(t, h, key, "bob", "ignored")
+	if *snapshot.Content != "hello world" || len(snapshot.Peers) != 1 {
+		t.Fatalf("second editor should share the loaded document, got %+v", snapshot)
+	}
+	drain(alice)
+
+	// Both edit revision 0 at the same time
+	if err := h.Submit(key, alice.ClientID, 0, NewOperation().Insert("oh, ").Retain(11), nil); err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+	if err := h.Submit(key, bob.ClientID, 0, NewOperation().Retain(11).Insert("!"), nil); err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+
+	s, _ := h.get(key)
+	if s.content != "oh, hello world!" {
+		t.Errorf("content = %q", s.content)
+	}
+
+	events := drain(bob)
+	if len(events) != 2 || events[0].Type != EventOp || events[1].Type != EventAck || events[1].Revision != 2 {
+		t.Errorf("bob received %+v", events)
+	}
+}
+
+func TestApplyFileChangeRebasesOntoEdits(t *testing.T) {
+	h := NewHub()
+	key := Key("repo", "main", "main.go")
+
+	if h.ApplyFileChange(key, "a", "b", "agent") {
+		t.Fatal("ApplyFileChange should report no session")
+	}
+
+	editor, _ := join(t, h, key, "alice", "line one\nline two\n")
+	if err := h.Submit(key, editor.ClientID, 0, NewOperation().Insert("// header\n").Retain(18), nil); err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+
+	// The agent patches the file as it was on disk, unaware of the edit
+	if !h.ApplyFileChange(key, "line one\nline two\n", "line one\nline 2\n", "agent") {
+		t.Fatal("ApplyFileChange should report an open session")
+	}
+
+	s, _ := h.get(key)
+	if s.content != "// header\nline one\nline 2\n" {
+		t.Errorf("content = %q", s.content)
+	}
+}
+
+func TestLeaveClosesEmptySession(t *testing.T) {
+	h := NewHub()
+	key := Key("repo", "main", "a.txt")
+
+	sub, _ := join(t, h, key, "alice", "text")
+	sub.Close()
+
+	if _, ok := h.get(key); ok {
+		t.Error("session should close when the last editor leaves")
+	}
+	if err := h.Submit(key, sub.ClientID, 0, NewOperation().Retain(4), nil); err != ErrSessionNotFound {
+		t.Errorf("Submit after leave = %v, want ErrSessionNotFound", err)
+	}
+}
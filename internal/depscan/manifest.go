@@ -0,0 +1,367 @@
+// Package depscan finds a repository's dependencies in its manifests and
+// checks them against upstream registries for newer versions and against
+// OSV for known vulnerabilities.
+package depscan
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Ecosystems, named as OSV names them
+const (
+	EcosystemGo   = "Go"
+	EcosystemNPM  = "npm"
+	EcosystemPyPI = "PyPI"
+)
+
+// Manifests are the files a repository is scanned for, relative to its
+// root. Parse reads package-lock.json files too, but scans only check what
+// the project asks for itself.
+var Manifests = []string{"go.mod", "package.json", "requirements.txt"}
+
+// Dependency is one pinned dependency found in a manifest
+type Dependency struct {
+	Ecosystem string `json:"ecosystem"`
+	Name      string `json:"name"`
+	Version   string `json:"version"`
+	Manifest  string `json:"manifest"`
+	Indirect  bool   `json:"indirect"`          // Only checked for vulnerabilities, not updates
+	Dev       bool   `json:"dev,omitempty"`     // Only needed to develop the project, not to run it
+	License   string `json:"license,omitempty"` // As the manifest records it, which only lockfiles do
+}
+
+// npmVersion matches an exact or caret/tilde npm version spec
+var npmVersion = regexp.MustCompile(`^[\^~=v]*\s*(\d+\.\d+\.\d+(?:-[0-9A-Za-z.-]+)?)$`)
+
+// Parse reads the dependencies out of a manifest, picking the format from
+// the file name
+func Parse(manifest string, data []byte) ([]Dependency, error) {
+	switch path.Base(manifest) {
+	case "go.mod":
+		return ParseGoMod(manifest, data), nil
+	case "package.json":
+		return ParsePackageJSON(manifest, data)
+	case "package-lock.json":
+		return ParsePackageLock(manifest, data)
+	case "requirements.txt":
+		return ParseRequirements(manifest, data), nil
+	}
+	return nil, fmt.Errorf("unsupported manifest %s", manifest)
+}
+
+// ParseGoMod reads the require directives of a go.mod file
+func ParseGoMod(manifest string, data []byte) []Dependency {
+	var deps []Dependency
+	inRequire := false
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		indirect := strings.Contains(line, "// indirect")
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+
+		switch {
+		case line == "require (":
+			inRequire = true
+			continue
+		case inRequire && line == ")":
+			inRequire = false
+			continue
+		case strings.HasPrefix(line, "require "):
+			line = strings.TrimSpace(strings.TrimPrefix(line, "require "))
+		case !inRequire:
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		deps = append(deps, Dependency{
+			Ecosystem: EcosystemGo,
+			Name:      strings.Trim(fields[0], `"`),
+			Version:   fields[1],
+			Manifest:  manifest,
+			Indirect:  indirect,
+		})
+	}
+	return deps
+}
+
+// ParsePackageJSON reads the dependencies and devDependencies of a
+// package.json file. Ranges that don't name a single version, like ">=1.0",
+// git URLs and workspace links, are skipped.
+func ParsePackageJSON(manifest string, data []byte) ([]Dependency, error) {
+	var pkg struct {
+		Dependencies    map[string]string `json:"dependencies"`
+		DevDependencies map[string]string `json:"devDependencies"`
+	}
+	if err := json.Unmarshal(data, &pkg); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", manifest, err)
+	}
+
+	var deps []Dependency
+	for i, group := range []map[string]string{pkg.Dependencies, pkg.DevDependencies} {
+		names := make([]string, 0, len(group))
+		for name := range group {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			m := npmVersion.FindStringSubmatch(strings.TrimSpace(group[name]))
+			if m == nil {
+				continue
+			}
+			deps = append(deps, Dependency{Ecosystem: EcosystemNPM, Name: name, Version: m[1], Manifest: manifest, Dev: i == 1})
+		}
+	}
+	return deps, nil
+}
+
+// lockPackage is a package installed by npm, as a lockfile of version 2 or
+// later records it
+type lockPackage struct {
+	Version         string            `json:"version"`
+	Dev             bool              `json:"dev"`
+	Link            bool              `json:"link"` // Workspace packages, which aren't installed from the registry
+	License         json.RawMessage   `json:"license"`
+	Dependencies    map[string]string `json:"dependencies"`
+	DevDependencies map[string]string `json:"devDependencies"`
+}
+
+// lockDependency is a package installed by npm, as a lockfile of version 1
+// records it
+type lockDependency struct {
+	Version      string                    `json:"version"`
+	Dev          bool                      `json:"dev"`
+	Dependencies map[string]lockDependency `json:"dependencies"`
+}
+
+// ParsePackageLock reads every package installed by an npm lockfile, with
+// the packages the project doesn't depend on itself marked indirect.
+// Lockfiles of version 2 and later list packages by their node_modules path
+// and record their licenses. Version 1 lockfiles nest packages instead and
+// don't, and only the nested packages can be told apart as indirect.
+func ParsePackageLock(manifest string, data []byte) ([]Dependency, error) {
+	var lock struct {
+		Packages     map[string]lockPackage    `json:"packages"`
+		Dependencies map[string]lockDependency `json:"dependencies"`
+	}
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", manifest, err)
+	}
+
+	seen := map[string]bool{}
+	var deps []Dependency
+	add := func(dep Dependency) {
+		m := npmVersion.FindStringSubmatch(dep.Version)
+		if dep.Name == "" || m == nil || seen[dep.Name+"@"+m[1]] {
+			return // Git and tarball dependencies have no registry version
+		}
+		dep.Version = m[1]
+		seen[dep.Name+"@"+dep.Version] = true
+		dep.Ecosystem = EcosystemNPM
+		dep.Manifest = manifest
+		deps = append(deps, dep)
+	}
+
+	if len(lock.Packages) > 0 {
+		root := lock.Packages[""]
+		paths := make([]string, 0, len(lock.Packages))
+		for p := range lock.Packages {
+			paths = append(paths, p)
+		}
+		sort.Strings(paths)
+
+		for _, p := range paths {
+			pkg := lock.Packages[p]
+			i := strings.LastIndex(p, "node_modules/")
+			if i < 0 || pkg.Link {
+				continue
+			}
+			name := p[i+len("node_modules/"):]
+			_, direct := root.Dependencies[name]
+			if _, dev := root.DevDependencies[name]; dev {
+				direct = true
+			}
+			add(Dependency{
+				Name:     name,
+				Version:  pkg.Version,
+				Indirect: i > 0 || !direct,
+				Dev:      pkg.Dev,
+				License:  lockLicense(pkg.License),
+			})
+		}
+	} else {
+		var walk func(group map[string]lockDependency, nested bool)
+		walk = func(group map[string]lockDependency, nested bool) {
+			names := make([]string, 0, len(group))
+			for name := range group {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				dep := group[name]
+				add(Dependency{Name: name, Version: dep.Version, Indirect: nested, Dev: dep.Dev})
+				walk(dep.Dependencies, true)
+			}
+		}
+		walk(lock.Dependencies, false)
+	}
+
+	sort.SliceStable(deps, func(i, j int) bool { return deps[i].Name < deps[j].Name })
+	return deps, nil
+}
+
+// lockLicense reads a package's license from a lockfile, which is usually
+// an SPDX expression but is an object with a type in old packages
+func lockLicense(raw json.RawMessage) string {
+	var license string
+	if json.Unmarshal(raw, &license) == nil {
+		return strings.TrimSpace(license)
+	}
+	var legacy struct {
+		Type string `json:"type"`
+	}
+	if json.Unmarshal(raw, &legacy) == nil {
+		return strings.TrimSpace(legacy.Type)
+	}
+	return ""
+}
+
+// ParseRequirements reads the pinned (==) requirements of a pip
+// requirements file. Unpinned requirements and pip options are skipped.
+func ParseRequirements(manifest string, data []byte) []Dependency {
+	var deps []Dependency
+	for _, line := range strings.Split(string(data), "\n") {
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		if i := strings.Index(line, ";"); i >= 0 {
+			line = line[:i] // Environment markers
+		}
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "-") {
+			continue
+		}
+
+		name, version, ok := strings.Cut(line, "==")
+		if !ok {
+			continue
+		}
+		if i := strings.Index(name, "["); i >= 0 {
+			name = name[:i] // Extras
+		}
+		version = strings.TrimPrefix(strings.TrimSpace(version), "=")
+		if name = strings.TrimSpace(name); name == "" || version == "" || strings.ContainsAny(version, "*,") {
+			continue
+		}
+		deps = append(deps, Dependency{
+			Ecosystem: EcosystemPyPI,
+			Name:      normalizePythonName(name),
+			Version:   version,
+			Manifest:  manifest,
+		})
+	}
+	return deps
+}
+
+// normalizePythonName returns the PEP 503 form of a package name
+func normalizePythonName(name string) string {
+	name = strings.ToLower(name)
+	return strings.NewReplacer("_", "-", ".", "-").Replace(name)
+}
+
+// version is a parsed version: its numeric release and anything after it
+type version struct {
+	release    []int
+	prerelease string
+}
+
+// parseVersion reads versions like v1.2.3, 1.2.3-beta.1, 2.0rc1 and
+// v2.0.0+incompatible
+func parseVersion(s string) version {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if i := strings.Index(s, "+"); i >= 0 {
+		s = s[:i]
+	}
+
+	var v version
+	for s != "" {
+		end := 0
+		for end < len(s) && s[end] >= '0' && s[end] <= '9' {
+			end++
+		}
+		if end == 0 {
+			break
+		}
+		n, _ := strconv.Atoi(s[:end])
+		v.release = append(v.release, n)
+		s = s[end:]
+		if !strings.HasPrefix(s, ".") || len(s) < 2 || s[1] < '0' || s[1] > '9' {
+			break
+		}
+		s = s[1:]
+	}
+	v.prerelease = strings.TrimLeft(s, "-.")
+	return v
+}
+
+// CompareVersions returns -1, 0 or 1 as a is older than, the same as or
+// newer than b. Pre-releases sort before their release.
+func CompareVersions(a, b string) int {
+	va, vb := parseVersion(a), parseVersion(b)
+	for i := 0; i < len(va.release) || i < len(vb.release); i++ {
+		var x, y int
+		if i < len(va.release) {
+			x = va.release[i]
+		}
+		if i < len(vb.release) {
+			y = vb.release[i]
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	switch {
+	case va.prerelease == vb.prerelease:
+		return 0
+	case va.prerelease == "":
+		return 1
+	case vb.prerelease == "":
+		return -1
+	case va.prerelease < vb.prerelease:
+		return -1
+	}
+	return 1
+}
+
+// UpdateType returns "major", "minor" or "patch" for an update from current
+// to latest
+func UpdateType(current, latest string) string {
+	vc, vl := parseVersion(current), parseVersion(latest)
+	part := func(v version, i int) int {
+		if i < len(v.release) {
+			return v.release[i]
+		}
+		return 0
+	}
+	switch {
+	case part(vc, 0) != part(vl, 0):
+		return "major"
+	case part(vc, 1) != part(vl, 1):
+		return "minor"
+	}
+	return "patch"
+}
@@ -0,0 +1,154 @@
+package depscan
+
+import "testing"
+
+func TestParseGoMod(t *testing.T) {
+	data := []byte(`module example.com/app
+
+go 1.22
+
+require github.com/pkg/errors v0.9.1
+
+require (
+	github.com/BurntSushi/toml v1.2.0
+	golang.org/x/text v0.3.0 // indirect
+)
+
+replace example.com/other => ../other
+`)
+	deps := ParseGoMod("go.mod", data)
+	if len(deps) != 3 {
+		t.Fatalf("expected 3 dependencies, got %d: %+v", len(deps), deps)
+	}
+	if deps[0].Name != "github.com/pkg/errors" || deps[0].Version != "v0.9.1" {
+		t.Errorf("unexpected single-line require: %+v", deps[0])
+	}
+	if deps[1].Indirect || !deps[2].Indirect {
+		t.Errorf("indirect flags wrong: %+v", deps)
+	}
+}
+
+func TestParsePackageJSON(t *testing.T) {
+	data := []byte(`{
+		"dependencies": {"express": "^4.18.0", "left-pad": "git+https://example.com/left-pad.git", "@scope/ui": "1.0.0"},
+		"devDependencies": {"jest": "~29.1.2", "eslint": ">=8"}
+	}`)
+	deps, err := ParsePackageJSON("web/package.json", data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := map[string]string{}
+	for _, dep := range deps {
+		got[dep.Name] = dep.Version
+	}
+	want := map[string]string{"express": "4.18.0", "@scope/ui": "1.0.0", "jest": "29.1.2"}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for name, version := range want {
+		if got[name] != version {
+			t.Errorf("%s: expected %s, got %s", name, version, got[name])
+		}
+	}
+
+	if _, err := ParsePackageJSON("package.json", []byte("{")); err == nil {
+		t.Error("expected an error for invalid JSON")
+	}
+}
+
+func TestParseRequirements(t *testing.T) {
+	data := []byte(`# web
+Django==4.1.0
+requests[security]==2.28.0 ; python_version >= "3.8"
+flask>=2.0
+-r dev.txt
+Typing_Extensions==4.5.0  # pinned
+`)
+	deps := ParseRequirements("requirements.txt", data)
+	if len(deps) != 3 {
+		t.Fatalf("expected 3 dependencies, got %d: %+v", len(deps), deps)
+	}
+	if deps[0].Name != "django" || deps[1].Name != "requests" || deps[2].Name != "typing-extensions" {
+		t.Errorf("unexpected names: %+v", deps)
+	}
+	if deps[1].Version != "2.28.0" {
+		t.Errorf("expected 2.28.0, got %s", deps[1].Version)
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"v1.2.3", "v1.2.3", 0},
+		{"1.2.3", "1.10.0", -1},
+		{"v2.0.0+incompatible", "v1.9.0", 1},
+		{"1.0.0-beta.1", "1.0.0", -1},
+		{"2.0rc1", "2.0", -1},
+		{"1.2", "1.2.0", 0},
+	}
+	for _, tt := range tests {
+		if got := CompareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("CompareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+
+	if got := UpdateType("v1.2.3", "v1.3.0"); got != "minor" {
+		t.Errorf("expected minor, got %s", got)
+	}
+	if got := UpdateType("4.18.0", "5.0.0"); got != "major" {
+		t.Errorf("expected major, got %s", got)
+	}
+}
+
+func TestParsePackageLock(t *testing.T) {
+	data := []byte(`{
+		"name": "web",
+		"lockfileVersion": 3,
+		"packages": {
+			"": {"name": "web", "dependencies": {"express": "^4.18.0"}, "devDependencies": {"jest": "^29.0.0"}},
+			"node_modules/express": {"version": "4.18.2", "license": "MIT"},
+			"node_modules/jest": {"version": "29.1.2", "dev": true, "license": "MIT"},
+			"node_modules/debug": {"version": "2.6.9", "license": {"type": "MIT"}},
+			"node_modules/express/node_modules/debug": {"version": "4.3.4"},
+			"node_modules/left-pad": {"version": "git+https://example.com/left-pad.git"},
+			"node_modules/shared": {"resolved": "packages/shared", "link": true}
+		}
+	}`)
+	deps, err := ParsePackageLock("web/package-lock.json", data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(deps) != 4 {
+		t.Fatalf("expected 4 dependencies, got %d: %+v", len(deps), deps)
+	}
+	if deps[0].Name != "debug" || deps[0].Version != "2.6.9" || !deps[0].Indirect || deps[0].License != "MIT" {
+		t.Errorf("unexpected hoisted dependency: %+v", deps[0])
+	}
+	if deps[1].Name != "debug" || deps[1].Version != "4.3.4" || !deps[1].Indirect || deps[1].License != "" {
+		t.Errorf("unexpected nested dependency: %+v", deps[1])
+	}
+	if deps[2].Name != "express" || deps[2].Indirect || deps[2].Dev || deps[2].License != "MIT" {
+		t.Errorf("unexpected direct dependency: %+v", deps[2])
+	}
+	if deps[3].Name != "jest" || deps[3].Indirect || !deps[3].Dev {
+		t.Errorf("unexpected dev dependency: %+v", deps[3])
+	}
+
+	v1 := []byte(`{"lockfileVersion": 1, "dependencies": {
+		"express": {"version": "4.18.2", "dependencies": {"debug": {"version": "4.3.4"}}},
+		"jest": {"version": "29.1.2", "dev": true}
+	}}`)
+	deps, err = Parse("package-lock.json", v1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(deps) != 3 || deps[0].Name != "debug" || !deps[0].Indirect || deps[1].Indirect || !deps[2].Dev {
+		t.Errorf("unexpected version 1 dependencies: %+v", deps)
+	}
+
+	if _, err := ParsePackageLock("package-lock.json", []byte("{")); err == nil {
+		t.Error("expected an error for invalid JSON")
+	}
+}
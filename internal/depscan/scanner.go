@@ -0,0 +1,304 @@
+package depscan
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// maxAdvisoryLookups caps the OSV detail requests made for one scan
+	maxAdvisoryLookups = 50
+
+	// maxScanErrors caps the lookup failures kept on a result
+	maxScanErrors = 10
+)
+
+// Advisory is a known vulnerability affecting a dependency's version
+type Advisory struct {
+	ID       string   `json:"id"`
+	Aliases  []string `json:"aliases,omitempty"` // CVE and other IDs for the same vulnerability
+	Summary  string   `json:"summary,omitempty"`
+	Severity string   `json:"severity,omitempty"` // As the advisory database rates it, when it does
+	Fixed    string   `json:"fixed,omitempty"`    // First version with the fix, when known
+}
+
+// CVEs returns the advisory's CVE identifiers
+func (a Advisory) CVEs() []string {
+	var cves []string
+	for _, id := range append([]string{a.ID}, a.Aliases...) {
+		if strings.HasPrefix(id, "CVE-") {
+			cves = append(cves, id)
+		}
+	}
+	return cves
+}
+
+// Finding is a dependency with a newer version, known vulnerabilities, or
+// both
+type Finding struct {
+	Dependency
+	Latest     string     `json:"latest,omitempty"` // Empty when the dependency is up to date
+	Update     string     `json:"update,omitempty"` // "major", "minor" or "patch"
+	Advisories []Advisory `json:"advisories,omitempty"`
+}
+
+// Result is the outcome of scanning a set of dependencies
+type Result struct {
+	Checked  int
+	Findings []Finding
+	Errors   []string // Lookups that failed, which don't stop the scan
+}
+
+// Scanner looks dependencies up in the public registries. The base URLs
+// can be pointed at mirrors.
+type Scanner struct {
+	GoProxy     string
+	NPMRegistry string
+	PyPI        string
+	OSV         string
+	client      *http.Client
+}
+
+// NewScanner creates a scanner using the public registries and OSV
+func NewScanner() *Scanner {
+	return &Scanner{
+		GoProxy:     "https://proxy.golang.org",
+		NPMRegistry: "https://registry.npmjs.org",
+		PyPI:        "https://pypi.org",
+		OSV:         "https://api.osv.dev",
+		client:      &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+// Scan checks every dependency for a newer version, except indirect ones,
+// and for known vulnerabilities. Failed lookups are recorded on the result
+// rather than failing the scan; only a cancelled context does that.
+func (s *Scanner) Scan(ctx context.Context, deps []Dependency) (*Result, error) {
+	result := &Result{Checked: len(deps)}
+	findings := make([]Finding, len(deps))
+	for i, dep := range deps {
+		findings[i].Dependency = dep
+		if dep.Indirect {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		latest, err := s.Latest(ctx, dep)
+		if err != nil {
+			result.addError(err)
+			continue
+		}
+		if CompareVersions(dep.Version, latest) < 0 {
+			findings[i].Latest = latest
+			findings[i].Update = UpdateType(dep.Version, latest)
+		}
+	}
+
+	advisories, err := s.Vulnerabilities(ctx, deps)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		result.addError(err)
+	}
+	for i := range findings {
+		findings[i].Advisories = advisories[i]
+		if findings[i].Latest != "" || len(findings[i].Advisories) > 0 {
+			result.Findings = append(result.Findings, findings[i])
+		}
+	}
+	return result, nil
+}
+
+// addError records a failed lookup
+func (r *Result) addError(err error) {
+	if len(r.Errors) < maxScanErrors {
+		r.Errors = append(r.Errors, err.Error())
+	}
+}
+
+// Latest returns the newest release of a dependency in its registry
+func (s *Scanner) Latest(ctx context.Context, dep Dependency) (string, error) {
+	var endpoint string
+	switch dep.Ecosystem {
+	case EcosystemGo:
+		endpoint = s.GoProxy + "/" + escapeModulePath(dep.Name) + "/@latest"
+	case EcosystemNPM:
+		endpoint = s.NPMRegistry + "/" + strings.Replace(url.PathEscape(dep.Name), "%40", "@", 1) + "/latest"
+	case EcosystemPyPI:
+		endpoint = s.PyPI + "/pypi/" + url.PathEscape(dep.Name) + "/json"
+	default:
+		return "", fmt.Errorf("unsupported ecosystem %s", dep.Ecosystem)
+	}
+
+	var body struct {
+		Version string `json:"version"` // npm, and "Version" from the Go module proxy
+		Info    struct {
+			Version string `json:"version"`
+		} `json:"info"` // PyPI
+	}
+	if err := s.do(ctx, http.MethodGet, endpoint, nil, &body); err != nil {
+		return "", fmt.Errorf("failed to look up %s: %w", dep.Name, err)
+	}
+
+	for _, v := range []string{body.Version, body.Info.Version} {
+		if v != "" {
+			return v, nil
+		}
+	}
+	return "", fmt.Errorf("registry returned no version for %s", dep.Name)
+}
+
+// Vulnerabilities returns the advisories affecting each dependency's
+// version, indexed like deps
+func (s *Scanner) Vulnerabilities(ctx context.Context, deps []Dependency) (map[int][]Advisory, error) {
+	advisories := map[int][]Advisory{}
+	if len(deps) == 0 {
+		return advisories, nil
+	}
+
+	type query struct {
+		Package struct {
+			Name      string `json:"name"`
+			Ecosystem string `json:"ecosystem"`
+		} `json:"package"`
+		Version string `json:"version"`
+	}
+	queries := make([]query, len(deps))
+	for i, dep := range deps {
+		queries[i].Package.Name = dep.Name
+		queries[i].Package.Ecosystem = dep.Ecosystem
+		queries[i].Version = strings.TrimPrefix(dep.Version, "v")
+	}
+
+	var batch struct {
+		Results []struct {
+			Vulns []struct {
+				ID string `json:"id"`
+			} `json:"vulns"`
+		} `json:"results"`
+	}
+	payload, _ := json.Marshal(map[string]any{"queries": queries})
+	if err := s.do(ctx, http.MethodPost, s.OSV+"/v1/querybatch", payload, &batch); err != nil {
+		return advisories, fmt.Errorf("failed to query vulnerabilities: %w", err)
+	}
+
+	details := map[string]*osvVuln{}
+	for i, res := range batch.Results {
+		if i >= len(deps) {
+			break
+		}
+		for _, v := range res.Vulns {
+			advisory := Advisory{ID: v.ID}
+			vuln, ok := details[v.ID]
+			if !ok && len(details) < maxAdvisoryLookups {
+				vuln, _ = s.vulnerability(ctx, v.ID)
+				details[v.ID] = vuln
+			}
+			if vuln != nil {
+				advisory.Aliases = vuln.Aliases
+				advisory.Summary = vuln.Summary
+				advisory.Severity = vuln.DatabaseSpecific.Severity
+				advisory.Fixed = vuln.fixedIn(deps[i].Name)
+			}
+			advisories[i] = append(advisories[i], advisory)
+		}
+	}
+	return advisories, nil
+}
+
+// osvVuln is the part of an OSV vulnerability record the scanner uses
+type osvVuln struct {
+	ID               string   `json:"id"`
+	Summary          string   `json:"summary"`
+	Aliases          []string `json:"aliases"`
+	DatabaseSpecific struct {
+		Severity string `json:"severity"`
+	} `json:"database_specific"`
+	Affected []struct {
+		Package struct {
+			Name string `json:"name"`
+		} `json:"package"`
+		Ranges []struct {
+			Events []struct {
+				Fixed string `json:"fixed"`
+			} `json:"events"`
+		} `json:"ranges"`
+	} `json:"affected"`
+}
+
+// fixedIn returns the first version of a package the vulnerability is fixed
+// in, or "" when the record doesn't say
+func (v *osvVuln) fixedIn(name string) string {
+	for _, affected := range v.Affected {
+		if !strings.EqualFold(affected.Package.Name, name) {
+			continue
+		}
+		for _, r := range affected.Ranges {
+			for _, event := range r.Events {
+				if event.Fixed != "" {
+					return event.Fixed
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// vulnerability fetches an OSV vulnerability record
+func (s *Scanner) vulnerability(ctx context.Context, id string) (*osvVuln, error) {
+	var vuln osvVuln
+	if err := s.do(ctx, http.MethodGet, s.OSV+"/v1/vulns/"+url.PathEscape(id), nil, &vuln); err != nil {
+		return nil, err
+	}
+	return &vuln, nil
+}
+
+// do sends a request and decodes its JSON response into out
+func (s *Scanner) do(ctx context.Context, method, endpoint string, payload []byte, out any) error {
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	client := s.client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", endpoint, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// escapeModulePath encodes a Go module path for the module proxy, which
+// writes each upper-case letter as "!" and its lower-case form
+func escapeModulePath(module string) string {
+	var b strings.Builder
+	for _, r := range module {
+		if r >= 'A' && r <= 'Z' {
+			b.WriteByte('!')
+			r += 'a' - 'A'
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,79 @@
+package depscan
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestScan(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /github.com/!burnt!sushi/toml/@latest", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"Version":"v1.3.2","Time":"2023-06-08T06:41:14Z"}`))
+	})
+	mux.HandleFunc("GET /express/latest", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"name":"express","version":"4.18.0"}`))
+	})
+	mux.HandleFunc("GET /pypi/django/json", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"info":{"version":"4.2.7"}}`))
+	})
+	mux.HandleFunc("POST /v1/querybatch", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Queries []struct {
+				Package struct {
+					Name string `json:"name"`
+				} `json:"package"`
+				Version string `json:"version"`
+			} `json:"queries"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || len(body.Queries) != 4 {
+			http.Error(w, "bad query", http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"results":[{},{"vulns":[{"id":"GHSA-test"}]},{},{}]}`))
+	})
+	mux.HandleFunc("GET /v1/vulns/GHSA-test", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":"GHSA-test","summary":"Open redirect","aliases":["CVE-2024-0001"],
+			"database_specific":{"severity":"MODERATE"},
+			"affected":[{"package":{"name":"express"},"ranges":[{"events":[{"introduced":"0"},{"fixed":"4.19.2"}]}]}]}`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	scanner := &Scanner{GoProxy: server.URL, NPMRegistry: server.URL, PyPI: server.URL, OSV: server.URL}
+	result, err := scanner.Scan(context.Background(), []Dependency{
+		{Ecosystem: EcosystemGo, Name: "github.com/BurntSushi/toml", Version: "v1.2.0"},
+		{Ecosystem: EcosystemNPM, Name: "express", Version: "4.18.0"},
+		{Ecosystem: EcosystemPyPI, Name: "django", Version: "4.1.0"},
+		{Ecosystem: EcosystemPyPI, Name: "missing", Version: "1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Checked != 4 {
+		t.Errorf("expected 4 checked, got %d", result.Checked)
+	}
+	if len(result.Errors) != 1 {
+		t.Errorf("expected the missing package to fail its lookup, got %v", result.Errors)
+	}
+	if len(result.Findings) != 3 {
+		t.Fatalf("expected 3 findings, got %d: %+v", len(result.Findings), result.Findings)
+	}
+
+	toml, express, django := result.Findings[0], result.Findings[1], result.Findings[2]
+	if toml.Latest != "v1.3.2" || toml.Update != "minor" {
+		t.Errorf("unexpected Go finding: %+v", toml)
+	}
+	if express.Latest != "" || len(express.Advisories) != 1 {
+		t.Fatalf("expected express to be current but vulnerable: %+v", express)
+	}
+	advisory := express.Advisories[0]
+	if advisory.Fixed != "4.19.2" || advisory.Severity != "MODERATE" || len(advisory.CVEs()) != 1 {
+		t.Errorf("unexpected advisory: %+v", advisory)
+	}
+	if django.Latest != "4.2.7" || django.Update != "minor" {
+		t.Errorf("unexpected PyPI finding: %+v", django)
+	}
+}
@@ -0,0 +1,148 @@
+// Package events fans workspace events out to listeners in the process,
+// such as the API's event stream. Events are kept in memory only: a
+// listener that connects late can catch up on the most recent ones, but
+// nothing survives a restart.
+package events
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultHistory is how many recent events are kept so reconnecting
+	// listeners can catch up
+	DefaultHistory = 256
+
+	// subscriberBuffer is how many events can wait for a slow listener
+	// before newer ones are dropped for it
+	subscriberBuffer = 64
+)
+
+// Event is something that happened in the workspace
+type Event struct {
+	ID     uint64 // Increases with each event, from 1
+	Type   string // Webhook event name, e.g. "issues" or "push"
+	Action string
+	RepoID string
+	UserID string // Who caused the event, if anyone
+	Data   []byte // JSON payload, as webhooks receive it
+	Time   time.Time
+}
+
+// IDString returns the event's ID as sent to stream listeners
+func (e Event) IDString() string {
+	return strconv.FormatUint(e.ID, 10)
+}
+
+// Bus delivers published events to its subscribers
+type Bus struct {
+	mu      sync.Mutex
+	subs    map[*Subscription]struct{}
+	history []Event
+	size    int
+	lastID  uint64
+}
+
+// Stream is the bus every workspace event is published on
+var Stream = NewBus(DefaultHistory)
+
+// NewBus creates a bus remembering the last history events
+func NewBus(history int) *Bus {
+	return &Bus{
+		subs: map[*Subscription]struct{}{},
+		size: history,
+	}
+}
+
+// Subscription receives a bus's events until it is closed
+type Subscription struct {
+	C <-chan Event
+
+	ch      chan Event
+	bus     *Bus
+	dropped uint64
+	once    sync.Once
+}
+
+// Listening reports whether anyone is subscribed, so publishers can skip
+// encoding events no one will receive
+func (b *Bus) Listening() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subs) > 0
+}
+
+// Publish sends an event to every subscriber, giving it the next ID.
+// Subscribers that have fallen too far behind miss it rather than holding
+// up the publisher.
+func (b *Bus) Publish(event Event) Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastID++
+	event.ID = b.lastID
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	if b.size > 0 {
+		if len(b.history) == b.size {
+			b.history = append(b.history[:0], b.history[1:]...)
+		}
+		b.history = append(b.history, event)
+	}
+
+	for sub := range b.subs {
+		select {
+		case sub.ch <- event:
+		default:
+			sub.dropped++
+		}
+	}
+	return event
+}
+
+// Subscribe starts receiving events. Remembered events published after
+// the one with ID since are delivered first, so a listener that
+// reconnects with the last ID it saw misses nothing still remembered;
+// zero only receives new events.
+func (b *Bus) Subscribe(since uint64) *Subscription {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var missed []Event
+	if since > 0 {
+		for _, event := range b.history {
+			if event.ID > since {
+				missed = append(missed, event)
+			}
+		}
+	}
+
+	ch := make(chan Event, subscriberBuffer+len(missed))
+	for _, event := range missed {
+		ch <- event
+	}
+	sub := &Subscription{C: ch, ch: ch, bus: b}
+	b.subs[sub] = struct{}{}
+	return sub
+}
+
+// Dropped returns how many events the subscription missed because it
+// fell behind
+func (s *Subscription) Dropped() uint64 {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	return s.dropped
+}
+
+// Close stops the subscription. It is safe to call more than once.
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		s.bus.mu.Lock()
+		delete(s.bus.subs, s)
+		s.bus.mu.Unlock()
+	})
+}
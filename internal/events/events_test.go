@@ -0,0 +1,92 @@
+package events
+
+import "testing"
+
+func receive(t *testing.T, sub *Subscription) Event {
+	t.Helper()
+	select {
+	case event := <-sub.C:
+		return event
+	default:
+		t.Fatal("no event waiting")
+		return Event{}
+	}
+}
+
+func TestPublish(t *testing.T) {
+	bus := NewBus(10)
+	if bus.Listening() {
+		t.Error("new bus has listeners")
+	}
+
+	sub := bus.Subscribe(0)
+	defer sub.Close()
+	if !bus.Listening() {
+		t.Error("subscribed bus has no listeners")
+	}
+
+	first := bus.Publish(Event{Type: "issues", RepoID: "api"})
+	second := bus.Publish(Event{Type: "push", RepoID: "web"})
+	if first.ID != 1 || second.ID != 2 || first.Time.IsZero() {
+		t.Errorf("unexpected IDs or time: %+v %+v", first, second)
+	}
+
+	if got := receive(t, sub); got.ID != 1 || got.Type != "issues" {
+		t.Errorf("first event = %+v", got)
+	}
+	if got := receive(t, sub); got.ID != 2 || got.RepoID != "web" || got.IDString() != "2" {
+		t.Errorf("second event = %+v", got)
+	}
+
+	sub.Close()
+	sub.Close()
+	if bus.Listening() {
+		t.Error("closed subscription still listening")
+	}
+}
+
+func TestSubscribeSince(t *testing.T) {
+	bus := NewBus(3)
+	for range 5 {
+		bus.Publish(Event{Type: "push"})
+	}
+
+	// Only the last three are remembered
+	sub := bus.Subscribe(1)
+	defer sub.Close()
+	for _, want := range []uint64{3, 4, 5} {
+		if got := receive(t, sub); got.ID != want {
+			t.Errorf("replayed %d, want %d", got.ID, want)
+		}
+	}
+
+	bus.Publish(Event{Type: "issues"})
+	if got := receive(t, sub); got.ID != 6 {
+		t.Errorf("live event %d, want 6", got.ID)
+	}
+
+	// A new listener without an ID only gets new events
+	fresh := bus.Subscribe(0)
+	defer fresh.Close()
+	select {
+	case event := <-fresh.C:
+		t.Errorf("unexpected replay of %d", event.ID)
+	default:
+	}
+}
+
+func TestSlowSubscriber(t *testing.T) {
+	bus := NewBus(0)
+	sub := bus.Subscribe(0)
+	defer sub.Close()
+
+	for range subscriberBuffer + 5 {
+		bus.Publish(Event{Type: "push"})
+	}
+	if got := sub.Dropped(); got != 5 {
+		t.Errorf("Dropped() = %d, want 5", got)
+	}
+	if got := receive(t, sub); got.ID != 1 {
+		t.Errorf("oldest waiting event %d, want 1", got.ID)
+	}
+}
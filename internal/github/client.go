@@ -51,6 +51,16 @@ type GitHubPullRequest struct {
 	ClosedAt  *time.Time `json:"closed_at"`
 }
 
+// GitHubComment represents a comment on a GitHub issue or pull request
+type GitHubComment struct {
+	ID        int64      `json:"id"`
+	Body      string     `json:"body"`
+	HTMLURL   string     `json:"html_url"`
+	User      GitHubUser `json:"user"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
 // GitHubUser represents a GitHub user
 type GitHubUser struct {
 	Login     string `json:"login"`
@@ -393,6 +403,131 @@ func (c *GitHubClient) UpdateIssue(ctx context.Context, githubURL string, number
 	return &issue, nil
 }
 
+// ListIssueComments lists the conversation comments on an issue or pull request,
+// oldest first. Pull requests share issue numbering, so both use this endpoint.
+func (c *GitHubClient) ListIssueComments(ctx context.Context, githubURL string, number int) ([]*GitHubComment, error) {
+	owner, repo, err := parseGitHubURL(githubURL)
+	if err != nil {
+		return nil, err
+	}
+
+	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/issues/%d/comments?per_page=100", owner, repo, number)
+
+	resp, err := c.doRequest("GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list comments: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("GitHub API error (status %d): %s", resp.StatusCode, string(body))
+	}
+
+	var comments []*GitHubComment
+	if err := json.NewDecoder(resp.Body).Decode(&comments); err != nil {
+		return nil, fmt.Errorf("failed to decode comments: %w", err)
+	}
+
+	return comments, nil
+}
+
+// CreateIssueComment posts a comment on an issue or pull request
+func (c *GitHubClient) CreateIssueComment(ctx context.Context, githubURL string, number int, body string) (*GitHubComment, error) {
+	owner, repo, err := parseGitHubURL(githubURL)
+	if err != nil {
+		return nil, err
+	}
+
+	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/issues/%d/comments", owner, repo, number)
+
+	jsonBody, err := json.Marshal(map[string]string{"body": body})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.doRequest("POST", url, strings.NewReader(string(jsonBody)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create comment: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("GitHub API error (status %d): %s", resp.StatusCode, string(body))
+	}
+
+	var comment GitHubComment
+	if err := json.NewDecoder(resp.Body).Decode(&comment); err != nil {
+		return nil, fmt.Errorf("failed to decode created comment: %w", err)
+	}
+
+	return &comment, nil
+}
+
+// CreateReviewComment posts an inline comment on a line of a pull request's
+// diff, as the file reads at the given commit
+func (c *GitHubClient) CreateReviewComment(ctx context.Context, githubURL string, number int, commitID, path string, line int, body string) (*GitHubComment, error) {
+	owner, repo, err := parseGitHubURL(githubURL)
+	if err != nil {
+		return nil, err
+	}
+
+	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/pulls/%d/comments", owner, repo, number)
+
+	jsonBody, err := json.Marshal(map[string]any{
+		"body":      body,
+		"commit_id": commitID,
+		"path":      path,
+		"line":      line,
+		"side":      "RIGHT",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return c.postReviewComment(url, jsonBody)
+}
+
+// CreateReviewCommentReply posts a reply to an inline comment on a pull
+// request
+func (c *GitHubClient) CreateReviewCommentReply(ctx context.Context, githubURL string, number int, commentID int64, body string) (*GitHubComment, error) {
+	owner, repo, err := parseGitHubURL(githubURL)
+	if err != nil {
+		return nil, err
+	}
+
+	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/pulls/%d/comments/%d/replies", owner, repo, number, commentID)
+
+	jsonBody, err := json.Marshal(map[string]string{"body": body})
+	if err != nil {
+		return nil, err
+	}
+
+	return c.postReviewComment(url, jsonBody)
+}
+
+// postReviewComment sends a new inline comment and decodes what GitHub made
+func (c *GitHubClient) postReviewComment(url string, jsonBody []byte) (*GitHubComment, error) {
+	resp, err := c.doRequest("POST", url, strings.NewReader(string(jsonBody)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create review comment: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("GitHub API error (status %d): %s", resp.StatusCode, string(body))
+	}
+
+	var comment GitHubComment
+	if err := json.NewDecoder(resp.Body).Decode(&comment); err != nil {
+		return nil, fmt.Errorf("failed to decode created review comment: %w", err)
+	}
+
+	return &comment, nil
+}
+
 // GetRateLimit returns the current rate limit status
 func (c *GitHubClient) GetRateLimit(ctx context.Context) (remaining, limit int, resetAt time.Time, err error) {
 	url := "https://api.github.com/rate_limit"
@@ -0,0 +1,269 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"workspace/models"
+)
+
+// commentSyncMu keeps a comment from being posted to GitHub twice when an
+// immediate push and a repository sync run at the same time
+var commentSyncMu sync.Mutex
+
+// commentMarkerPrefix starts the hidden tag added to comments posted to
+// GitHub, so they are recognised instead of imported back
+const commentMarkerPrefix = "<!-- skyscape-comment:"
+
+// commentMarker returns the hidden tag for a local comment
+func commentMarker(commentID string) string {
+	return commentMarkerPrefix + commentID + " -->"
+}
+
+// commentMarkerID returns the local comment a GitHub comment was posted
+// from, or "" when it was written on GitHub
+func commentMarkerID(body string) string {
+	start := strings.LastIndex(body, commentMarkerPrefix)
+	if start < 0 {
+		return ""
+	}
+	rest := body[start+len(commentMarkerPrefix):]
+	end := strings.Index(rest, "-->")
+	if end < 0 {
+		return ""
+	}
+	return strings.TrimSpace(rest[:end])
+}
+
+// formatCommentForGitHub credits a local comment's author, since it is
+// posted with the syncing user's token
+func formatCommentForGitHub(comment *models.Comment, authorName string) string {
+	return fmt.Sprintf("**%s** commented in Skyscape:\n\n%s\n\n%s", authorName, comment.Body, commentMarker(comment.ID))
+}
+
+// syncComments mirrors the comments of every linked issue and pull request
+func (s *GitHubSyncService) syncComments(ctx context.Context, client *GitHubClient, repo *models.Repository) error {
+	issues, err := models.Issues.Search("WHERE RepoID = ? AND GitHubNumber > 0", repo.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get linked issues: %w", err)
+	}
+	for _, issue := range issues {
+		if err := s.syncEntityComments(ctx, client, repo, "issue", issue.ID, issue.GitHubNumber); err != nil {
+			log.Printf("Failed to sync comments for issue #%d: %v", issue.GitHubNumber, err)
+		}
+	}
+
+	prs, err := models.PullRequests.Search("WHERE RepoID = ? AND GitHubNumber > 0", repo.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get linked pull requests: %w", err)
+	}
+	for _, pr := range prs {
+		if err := s.syncEntityComments(ctx, client, repo, "pr", pr.ID, pr.GitHubNumber); err != nil {
+			log.Printf("Failed to sync comments for PR #%d: %v", pr.GitHubNumber, err)
+		}
+	}
+	return nil
+}
+
+// syncEntityComments imports new GitHub comments on one issue or pull request
+// and, for bidirectional sync, posts the local comments GitHub hasn't seen.
+// Comments are matched by GitHub ID, falling back to the hidden marker for
+// ones that were posted but never recorded.
+func (s *GitHubSyncService) syncEntityComments(ctx context.Context, client *GitHubClient, repo *models.Repository, entityType, entityID string, number int) error {
+	commentSyncMu.Lock()
+	defer commentSyncMu.Unlock()
+
+	remote, err := client.ListIssueComments(ctx, repo.GitHubURL, number)
+	if err != nil {
+		return err
+	}
+	local, err := models.GetEntityComments(entityType, entityID)
+	if err != nil {
+		return fmt.Errorf("failed to get local comments: %w", err)
+	}
+
+	byGitHubID := make(map[int64]*models.Comment)
+	byID := make(map[string]*models.Comment)
+	for _, comment := range local {
+		if comment.GitHubID > 0 {
+			byGitHubID[comment.GitHubID] = comment
+		}
+		byID[comment.ID] = comment
+	}
+
+	for _, ghComment := range remote {
+		if existing, ok := byGitHubID[ghComment.ID]; ok {
+			// Edits on GitHub carry over to comments imported from there
+			if existing.Origin == models.CommentOriginGitHub && existing.Body != ghComment.Body {
+				existing.Body = ghComment.Body
+				if err := models.Comments.Update(existing); err != nil {
+					log.Printf("Failed to update comment %s: %v", existing.ID, err)
+				}
+			}
+			continue
+		}
+
+		if id := commentMarkerID(ghComment.Body); id != "" {
+			// Posted from here; record the GitHub ID if it was lost, and never
+			// import it back, even when the local comment is gone
+			if mine, ok := byID[id]; ok && mine.GitHubID == 0 {
+				mine.GitHubID = ghComment.ID
+				if err := models.Comments.Update(mine); err != nil {
+					log.Printf("Failed to link comment %s: %v", mine.ID, err)
+				}
+			}
+			continue
+		}
+
+		_, err := models.Comments.Insert(&models.Comment{
+			Body:         ghComment.Body,
+			AuthorID:     repo.UserID,
+			RepoID:       repo.ID,
+			EntityType:   entityType,
+			EntityID:     entityID,
+			Origin:       models.CommentOriginGitHub,
+			GitHubID:     ghComment.ID,
+			GitHubAuthor: ghComment.User.Login,
+		})
+		if err != nil {
+			log.Printf("Failed to import GitHub comment %d: %v", ghComment.ID, err)
+		}
+	}
+
+	if repo.CommentSync != models.CommentSyncBoth {
+		return nil
+	}
+	for _, comment := range local {
+		// Oldest first, so a thread's first comment is posted before its replies
+		if entityType == "pr" && shouldPushReviewComment(comment, byID[comment.ParentID]) {
+			if err := s.pushReviewCommentToGitHub(ctx, client, repo, number, comment, byID[comment.ParentID]); err != nil {
+				log.Printf("Failed to push review comment %s to GitHub: %v", comment.ID, err)
+			}
+			continue
+		}
+		if !shouldPushComment(comment) {
+			continue
+		}
+		if err := s.pushCommentToGitHub(ctx, client, repo, number, comment); err != nil {
+			log.Printf("Failed to push comment %s to GitHub: %v", comment.ID, err)
+		}
+	}
+	return nil
+}
+
+// shouldPushComment reports whether a local comment still needs posting to
+// the conversation. Inline code comments go through shouldPushReviewComment.
+func shouldPushComment(comment *models.Comment) bool {
+	return comment.GitHubID == 0 && comment.Origin != models.CommentOriginGitHub &&
+		comment.FilePath == "" && comment.LineNumber == 0
+}
+
+// shouldPushReviewComment reports whether a local inline comment on a pull
+// request still needs posting. GitHub anchors them to a commit, so only
+// comments that recorded one can go, and replies wait for their thread.
+func shouldPushReviewComment(comment, parent *models.Comment) bool {
+	if comment.GitHubID != 0 || comment.Origin == models.CommentOriginGitHub ||
+		comment.FilePath == "" || comment.LineNumber == 0 || comment.CommitSHA == "" {
+		return false
+	}
+	return comment.ParentID == "" || (parent != nil && parent.GitHubID != 0)
+}
+
+// pushReviewCommentToGitHub posts a local inline comment, as a reply when it
+// is in a thread, and records its GitHub ID
+func (s *GitHubSyncService) pushReviewCommentToGitHub(ctx context.Context, client *GitHubClient, repo *models.Repository, number int, comment, parent *models.Comment) error {
+	authorName := "Someone"
+	if author, err := comment.Author(); err == nil && author != nil {
+		authorName = author.Name
+	}
+	body := formatCommentForGitHub(comment, authorName)
+
+	var ghComment *GitHubComment
+	var err error
+	if parent != nil {
+		ghComment, err = client.CreateReviewCommentReply(ctx, repo.GitHubURL, number, parent.GitHubID, body)
+	} else {
+		ghComment, err = client.CreateReviewComment(ctx, repo.GitHubURL, number, comment.CommitSHA, comment.FilePath, comment.LineNumber, body)
+	}
+	if err != nil {
+		return err
+	}
+
+	comment.GitHubID = ghComment.ID
+	return models.Comments.Update(comment)
+}
+
+// pushCommentToGitHub posts a local comment and records its GitHub ID
+func (s *GitHubSyncService) pushCommentToGitHub(ctx context.Context, client *GitHubClient, repo *models.Repository, number int, comment *models.Comment) error {
+	authorName := "Someone"
+	if author, err := comment.Author(); err == nil && author != nil {
+		authorName = author.Name
+	}
+
+	ghComment, err := client.CreateIssueComment(ctx, repo.GitHubURL, number, formatCommentForGitHub(comment, authorName))
+	if err != nil {
+		return err
+	}
+
+	comment.GitHubID = ghComment.ID
+	return models.Comments.Update(comment)
+}
+
+// PushComment posts a new local comment to GitHub right away with the
+// commenter's token. It does nothing unless the repository syncs comments both
+// ways and the issue or pull request is linked to GitHub; anything it can't
+// post is picked up by the next repository sync.
+func (s *GitHubSyncService) PushComment(commentID, userID string) error {
+	commentSyncMu.Lock()
+	defer commentSyncMu.Unlock()
+
+	comment, err := models.Comments.Get(commentID)
+	if err != nil {
+		return fmt.Errorf("failed to get comment: %w", err)
+	}
+	var parent *models.Comment
+	if comment.ParentID != "" {
+		if parent, err = models.Comments.Get(comment.ParentID); err != nil {
+			return fmt.Errorf("failed to get review thread: %w", err)
+		}
+	}
+	review := comment.EntityType == "pr" && shouldPushReviewComment(comment, parent)
+	if !review && !shouldPushComment(comment) {
+		return nil
+	}
+
+	repo, err := models.Repositories.Get(comment.RepoID)
+	if err != nil {
+		return fmt.Errorf("failed to get repository: %w", err)
+	}
+	if repo.GitHubURL == "" || repo.CommentSync != models.CommentSyncBoth {
+		return nil
+	}
+
+	var number int
+	switch comment.EntityType {
+	case "issue":
+		if issue, err := models.Issues.Get(comment.EntityID); err == nil {
+			number = issue.GitHubNumber
+		}
+	case "pr":
+		if pr, err := models.PullRequests.Get(comment.EntityID); err == nil {
+			number = pr.GitHubNumber
+		}
+	}
+	if number == 0 {
+		return nil
+	}
+
+	client, err := NewGitHubClient(userID)
+	if err != nil {
+		return fmt.Errorf("failed to create GitHub client: %w", err)
+	}
+	if review {
+		return s.pushReviewCommentToGitHub(context.Background(), client, repo, number, comment, parent)
+	}
+	return s.pushCommentToGitHub(context.Background(), client, repo, number, comment)
+}
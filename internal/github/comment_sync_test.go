@@ -0,0 +1,92 @@
+package github
+
+import (
+	"strings"
+	"testing"
+
+	"workspace/models"
+)
+
+func TestCommentMarker(t *testing.T) {
+	comment := &models.Comment{Body: "Looks good to me", LineNumber: 0}
+	comment.ID = "comment-123"
+
+	body := formatCommentForGitHub(comment, "Alice")
+	if !strings.HasPrefix(body, "**Alice** commented in Skyscape:") {
+		t.Errorf("Expected author credit, got %q", body)
+	}
+	if !strings.Contains(body, "Looks good to me") {
+		t.Errorf("Expected original body, got %q", body)
+	}
+	if id := commentMarkerID(body); id != "comment-123" {
+		t.Errorf("commentMarkerID() = %q, want comment-123", id)
+	}
+
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{name: "Written on GitHub", body: "Thanks for the fix!", want: ""},
+		{name: "Unterminated marker", body: "<!-- skyscape-comment:abc", want: ""},
+		{name: "Quoted marker uses the last one", body: "> <!-- skyscape-comment:old -->\n\n<!-- skyscape-comment:new -->", want: "new"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := commentMarkerID(tt.body); got != tt.want {
+				t.Errorf("commentMarkerID() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShouldPushComment(t *testing.T) {
+	tests := []struct {
+		name    string
+		comment *models.Comment
+		want    bool
+	}{
+		{name: "New local comment", comment: &models.Comment{Body: "hi"}, want: true},
+		{name: "Already mirrored", comment: &models.Comment{Body: "hi", GitHubID: 42}, want: false},
+		{name: "Imported from GitHub", comment: &models.Comment{Body: "hi", Origin: models.CommentOriginGitHub}, want: false},
+		{name: "Inline code comment", comment: &models.Comment{Body: "hi", FilePath: "main.go", LineNumber: 10}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := shouldPushComment(tt.comment); got != tt.want {
+				t.Errorf("shouldPushComment() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShouldPushReviewComment(t *testing.T) {
+	inline := func(c models.Comment) *models.Comment {
+		c.Body, c.FilePath, c.LineNumber = "hi", "main.go", 10
+		return &c
+	}
+	posted := &models.Comment{GitHubID: 7}
+
+	tests := []struct {
+		name    string
+		comment *models.Comment
+		parent  *models.Comment
+		want    bool
+	}{
+		{name: "New thread", comment: inline(models.Comment{CommitSHA: "abc"}), want: true},
+		{name: "No commit to anchor to", comment: inline(models.Comment{}), want: false},
+		{name: "Conversation comment", comment: &models.Comment{Body: "hi", CommitSHA: "abc"}, want: false},
+		{name: "Already mirrored", comment: inline(models.Comment{CommitSHA: "abc", GitHubID: 42}), want: false},
+		{name: "Imported from GitHub", comment: inline(models.Comment{CommitSHA: "abc", Origin: models.CommentOriginGitHub}), want: false},
+		{name: "Reply to a posted thread", comment: inline(models.Comment{CommitSHA: "abc", ParentID: "1"}), parent: posted, want: true},
+		{name: "Reply before its thread is posted", comment: inline(models.Comment{CommitSHA: "abc", ParentID: "1"}), parent: &models.Comment{}, want: false},
+		{name: "Reply to a missing thread", comment: inline(models.Comment{CommitSHA: "abc", ParentID: "1"}), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := shouldPushReviewComment(tt.comment, tt.parent); got != tt.want {
+				t.Errorf("shouldPushReviewComment() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"log"
+	"net/url"
 	"os/exec"
 	"strconv"
 	"strings"
@@ -371,3 +372,182 @@ func (s *GitOperationsService) CheckForConflicts(repo *models.Repository) (bool,
 	return true, files, nil
 }
 
+
+// BitbucketRemote is the git remote that mirrors a repository to Bitbucket
+const BitbucketRemote = "bitbucket"
+
+// ConfigureBitbucketRemote adds or updates the Bitbucket remote for a repository.
+// The remote is stored without credentials; they are added to each push and fetch.
+func (s *GitOperationsService) ConfigureBitbucketRemote(repo *models.Repository, bitbucketURL string) error {
+	if bitbucketURL == "" {
+		return fmt.Errorf("Bitbucket URL is required")
+	}
+
+	cmd := exec.Command("git", "remote", "add", BitbucketRemote, bitbucketURL)
+	cmd.Dir = repo.Path()
+	if err := cmd.Run(); err != nil {
+		cmd = exec.Command("git", "remote", "set-url", BitbucketRemote, bitbucketURL)
+		cmd.Dir = repo.Path()
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("failed to configure Bitbucket remote: %w", err)
+		}
+	}
+
+	repo.BitbucketURL = bitbucketURL
+	repo.BitbucketSyncStatus = ""
+	if err := models.Repositories.Update(repo); err != nil {
+		return fmt.Errorf("failed to update repository: %w", err)
+	}
+
+	log.Printf("Configured Bitbucket remote for repository %s: %s", repo.ID, bitbucketURL)
+	return nil
+}
+
+// RemoveBitbucketRemote removes the Bitbucket remote and its tracking branches
+func (s *GitOperationsService) RemoveBitbucketRemote(repo *models.Repository) error {
+	cmd := exec.Command("git", "remote", "remove", BitbucketRemote)
+	cmd.Dir = repo.Path()
+	cmd.Run() // Ignore error if remote doesn't exist
+
+	repo.BitbucketURL = ""
+	repo.BitbucketAhead = 0
+	repo.BitbucketBehind = 0
+	repo.BitbucketSyncStatus = ""
+	return models.Repositories.Update(repo)
+}
+
+// PushToBitbucket pushes a branch to Bitbucket, authenticating with creds when given
+func (s *GitOperationsService) PushToBitbucket(repo *models.Repository, branch string, creds *url.Userinfo) error {
+	if repo.BitbucketURL == "" {
+		return fmt.Errorf("Bitbucket remote not configured")
+	}
+	if branch == "" {
+		branch = repo.GetDefaultBranch()
+	}
+
+	ref := fmt.Sprintf("refs/heads/%s:refs/heads/%s", branch, branch)
+	if err := s.runBitbucketGit(repo, creds, "push", ref); err != nil {
+		return err
+	}
+
+	repo.BitbucketSyncedAt = time.Now()
+	if err := models.Repositories.Update(repo); err != nil {
+		log.Printf("Failed to update Bitbucket sync time: %v", err)
+	}
+
+	log.Printf("Pushed branch %s to Bitbucket for repository %s", branch, repo.ID)
+	return nil
+}
+
+// PullFromBitbucket fast-forwards a branch to Bitbucket's copy of it. Repositories
+// are bare, so a branch that has diverged is rejected rather than merged.
+func (s *GitOperationsService) PullFromBitbucket(repo *models.Repository, branch string, creds *url.Userinfo) error {
+	if repo.BitbucketURL == "" {
+		return fmt.Errorf("Bitbucket remote not configured")
+	}
+	if branch == "" {
+		branch = repo.GetDefaultBranch()
+	}
+
+	ref := fmt.Sprintf("refs/heads/%s:refs/heads/%s", branch, branch)
+	if err := s.runBitbucketGit(repo, creds, "fetch", ref); err != nil {
+		return err
+	}
+
+	repo.BitbucketSyncedAt = time.Now()
+	if err := models.Repositories.Update(repo); err != nil {
+		log.Printf("Failed to update Bitbucket sync time: %v", err)
+	}
+
+	log.Printf("Pulled branch %s from Bitbucket for repository %s", branch, repo.ID)
+	return nil
+}
+
+// GetBitbucketSyncStatus fetches from Bitbucket and checks how many commits the
+// default branch is ahead/behind it
+func (s *GitOperationsService) GetBitbucketSyncStatus(repo *models.Repository, creds *url.Userinfo) (ahead int, behind int, status string, err error) {
+	if repo.BitbucketURL == "" {
+		return 0, 0, "no-remote", nil
+	}
+
+	tracking := fmt.Sprintf("+refs/heads/*:refs/remotes/%s/*", BitbucketRemote)
+	if err := s.runBitbucketGit(repo, creds, "fetch", "--prune", tracking); err != nil {
+		log.Printf("Warning: Failed to fetch from Bitbucket during sync status check: %v", err)
+	}
+
+	branch := repo.GetDefaultBranch()
+	cmd := exec.Command("git", "rev-list", "--left-right", "--count", fmt.Sprintf("%s/%s...%s", BitbucketRemote, branch, branch))
+	cmd.Dir = repo.Path()
+	output, err := cmd.Output()
+	if err != nil {
+		// Remote branch might not exist yet
+		cmd = exec.Command("git", "rev-list", "--count", branch)
+		cmd.Dir = repo.Path()
+		if output, err = cmd.Output(); err != nil {
+			return 0, 0, "error", fmt.Errorf("failed to get commit count: %w", err)
+		}
+		ahead, _ = strconv.Atoi(strings.TrimSpace(string(output)))
+	} else if parts := strings.Fields(string(output)); len(parts) >= 2 {
+		behind, _ = strconv.Atoi(parts[0])
+		ahead, _ = strconv.Atoi(parts[1])
+	}
+
+	switch {
+	case ahead > 0 && behind > 0:
+		status = "diverged"
+	case ahead > 0:
+		status = "ahead"
+	case behind > 0:
+		status = "behind"
+	default:
+		status = "synced"
+	}
+
+	repo.BitbucketAhead = ahead
+	repo.BitbucketBehind = behind
+	repo.BitbucketSyncStatus = status
+	if err := models.Repositories.Update(repo); err != nil {
+		log.Printf("Failed to update Bitbucket sync status: %v", err)
+	}
+
+	return ahead, behind, status, nil
+}
+
+// runBitbucketGit runs a push or fetch against the Bitbucket URL with credentials
+// embedded, so they are never written to the repository's config
+func (s *GitOperationsService) runBitbucketGit(repo *models.Repository, creds *url.Userinfo, op string, args ...string) error {
+	remoteURL := repo.BitbucketURL
+	if u, err := url.Parse(remoteURL); err == nil && u.Scheme == "https" && creds != nil {
+		u.User = creds
+		remoteURL = u.String()
+	}
+
+	cmd := exec.Command("git", append([]string{op, remoteURL}, args...)...)
+	cmd.Dir = repo.Path()
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		errMsg := stderr.String()
+		if creds != nil {
+			if password, ok := creds.Password(); ok && password != "" {
+				errMsg = strings.ReplaceAll(errMsg, password, "***")
+			}
+		}
+		if strings.Contains(errMsg, "Authentication failed") || strings.Contains(errMsg, "could not read Username") {
+			return fmt.Errorf("Bitbucket authentication failed. Please reconnect your Bitbucket account in Settings")
+		}
+		if strings.Contains(errMsg, "Permission denied") || strings.Contains(errMsg, "403") {
+			return fmt.Errorf("permission denied. Check that your Bitbucket account has access to this repository")
+		}
+		if strings.Contains(errMsg, "non-fast-forward") || strings.Contains(errMsg, "rejected") {
+			return fmt.Errorf("%s rejected: the branch has diverged from Bitbucket. Reconcile the histories, then try again", op)
+		}
+		if strings.Contains(errMsg, "Could not resolve host") {
+			return fmt.Errorf("network error: unable to connect to Bitbucket. Check your internet connection")
+		}
+		return fmt.Errorf("Bitbucket %s failed: %s", op, errMsg)
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package github
+
+import (
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"workspace/models"
+)
+
+// syncCheckInterval is how often repositories due an automatic sync are looked for
+const syncCheckInterval = time.Minute
+
+// SyncScheduler syncs repositories with auto-sync on at their own intervals,
+// backing off after failures and recording every run
+type SyncScheduler struct {
+	issues *GitHubSyncService
+	git    *GitOperationsService
+
+	mu      sync.Mutex
+	running map[string]bool // Repositories being synced right now
+	stopCh  chan struct{}
+}
+
+// Scheduler is the running sync scheduler
+var Scheduler = NewSyncScheduler()
+
+// NewSyncScheduler creates a sync scheduler
+func NewSyncScheduler() *SyncScheduler {
+	return &SyncScheduler{
+		issues:  NewGitHubSyncService(),
+		git:     NewGitOperationsService(),
+		running: make(map[string]bool),
+		stopCh:  make(chan struct{}),
+	}
+}
+
+// Start checks for due repositories until Stop is called
+func (s *SyncScheduler) Start() {
+	go func() {
+		ticker := time.NewTicker(syncCheckInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-s.stopCh:
+				return
+			case now := <-ticker.C:
+				s.runDue(now)
+			}
+		}
+	}()
+}
+
+// Stop stops the scheduler
+func (s *SyncScheduler) Stop() {
+	close(s.stopCh)
+}
+
+// runDue starts a sync for each repository whose next sync is due
+func (s *SyncScheduler) runDue(now time.Time) {
+	repos, err := models.DueGitHubSyncs(now)
+	if err != nil {
+		log.Printf("SyncScheduler: Failed to load due repositories: %v", err)
+		return
+	}
+
+	for _, repo := range repos {
+		go func() {
+			if _, err := s.Sync(repo, syncUserID(repo), models.SyncTriggerScheduled); err != nil {
+				log.Printf("SyncScheduler: Sync of %s failed: %v", repo.Name, err)
+			}
+		}()
+	}
+}
+
+// Sync syncs code, issues and pull requests with GitHub using a user's token,
+// records the run and schedules the next automatic one. Diverged histories
+// are reported as a conflict rather than merged.
+func (s *SyncScheduler) Sync(repo *models.Repository, userID, trigger string) (*models.GitHubSyncRun, error) {
+	s.mu.Lock()
+	if s.running[repo.ID] {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("a sync of %s is already running", repo.Name)
+	}
+	s.running[repo.ID] = true
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.running, repo.ID)
+		s.mu.Unlock()
+	}()
+
+	start := time.Now()
+	run := &models.GitHubSyncRun{Trigger: trigger, UserID: userID}
+	if err := s.syncCode(repo, userID, run); err != nil {
+		run.Outcome = models.SyncFailed
+		run.Error = err.Error()
+	} else if err := s.issues.SyncRepository(repo.ID, userID); err != nil {
+		run.Outcome = models.SyncFailed
+		run.Error = fmt.Sprintf("issues and pull requests: %v", err)
+	} else if run.Outcome == "" {
+		run.Outcome = models.SyncSucceeded
+	}
+	run.Duration = time.Since(start).Milliseconds()
+
+	return models.RecordGitHubSyncRun(repo, run, syncJitter(repo))
+}
+
+// syncCode pushes or pulls commits as the repository's sync direction allows,
+// noting what it found and did on the run
+func (s *SyncScheduler) syncCode(repo *models.Repository, userID string, run *models.GitHubSyncRun) error {
+	token, err := models.GetGitHubOAuthToken(userID)
+	if err != nil {
+		return fmt.Errorf("no GitHub account connected for the syncing user")
+	}
+
+	if !repo.RemoteConfigured {
+		if err := s.git.ConfigureRemote(repo, repo.GitHubURL); err != nil {
+			return err
+		}
+	}
+
+	ahead, behind, status, err := s.git.GetSyncStatus(repo)
+	if err != nil {
+		return err
+	}
+	run.Status, run.Ahead, run.Behind = status, ahead, behind
+
+	direction := repo.SyncDirection
+	if direction == "" {
+		direction = "push"
+	}
+
+	switch status {
+	case "diverged":
+		run.Outcome = models.SyncConflict
+		run.Error = fmt.Sprintf("%d local and %d GitHub commits have diverged and need merging by hand", ahead, behind)
+	case "ahead":
+		if direction == "push" || direction == "both" {
+			if err := s.git.PushToRemote(repo, "", token); err != nil {
+				return err
+			}
+			run.Pushed = true
+		}
+	case "behind":
+		if direction == "pull" || direction == "both" {
+			if err := s.git.PullFromRemote(repo, "", token); err != nil {
+				return err
+			}
+			run.Pulled = true
+		}
+	}
+
+	// Refresh the stored status shown on the integrations page
+	if run.Pushed || run.Pulled {
+		s.git.GetSyncStatus(repo)
+	}
+	return nil
+}
+
+// syncUserID returns whose token an automatic sync uses: the user who
+// connected the repository, or else its owner
+func syncUserID(repo *models.Repository) string {
+	if integration, err := models.GetGitHubRepoIntegration(repo.ID); err == nil {
+		if ownerID, ok := integration["owner_id"].(string); ok && ownerID != "" {
+			return ownerID
+		}
+	}
+	return repo.UserID
+}
+
+// syncJitter returns up to a tenth of the repository's interval, so
+// repositories connected together don't all sync in the same minute
+func syncJitter(repo *models.Repository) time.Duration {
+	spread := time.Duration(repo.SyncIntervalMinutes()) * time.Minute / 10
+	return rand.N(spread + 1)
+}
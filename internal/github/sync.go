@@ -66,6 +66,13 @@ func (s *GitHubSyncService) SyncRepository(repoID, userID string) error {
 	if err := s.syncPullRequests(ctx, client, repo); err != nil {
 		log.Printf("Failed to sync pull requests for %s: %v", repo.Name, err)
 	}
+
+	// Sync comments on linked issues and PRs
+	if repo.CommentSync != "" {
+		if err := s.syncComments(ctx, client, repo); err != nil {
+			log.Printf("Failed to sync comments for %s: %v", repo.Name, err)
+		}
+	}
 	
 	log.Printf("GitHub sync completed for repository %s", repo.Name)
 	
@@ -490,38 +497,3 @@ func (s *GitHubSyncService) SyncIssue(issueID, userID string) error {
 		return nil
 	}
 }
-
-// StartBackgroundSync starts a background sync process that runs periodically
-func (s *GitHubSyncService) StartBackgroundSync(interval time.Duration) {
-	go func() {
-		ticker := time.NewTicker(interval)
-		defer ticker.Stop()
-		
-		for range ticker.C {
-			s.syncAllRepositories()
-		}
-	}()
-}
-
-// syncAllRepositories syncs all repositories with GitHub integration enabled
-func (s *GitHubSyncService) syncAllRepositories() {
-	repos, err := models.Repositories.Search("WHERE GitHubURL != '' AND AutoSync = 1")
-	if err != nil {
-		log.Printf("Failed to get repositories for sync: %v", err)
-		return
-	}
-	
-	for _, repo := range repos {
-		// Get integration data to find owner
-		integration, err := models.GetGitHubRepoIntegration(repo.ID)
-		if err != nil {
-			continue
-		}
-		
-		if ownerID, ok := integration["owner_id"].(string); ok {
-			if err := s.SyncRepository(repo.ID, ownerID); err != nil {
-				log.Printf("Failed to sync repository %s: %v", repo.Name, err)
-			}
-		}
-	}
-}
\ No newline at end of file
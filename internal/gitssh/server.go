@@ -0,0 +1,269 @@
+// Package gitssh serves git clone, fetch and push over SSH. Users are
+// identified by their public keys and every command is authorized per
+// repository before git runs.
+package gitssh
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Git services that can be run over SSH
+const (
+	UploadPack  = "git-upload-pack"  // Clone and fetch
+	ReceivePack = "git-receive-pack" // Push
+)
+
+// userExtension carries the authenticated user from the handshake to the
+// session's permissions
+const userExtension = "user-id"
+
+// Config describes how the server finds repositories and authorizes users
+type Config struct {
+	// Directory holding the bare repositories, named by ID
+	ReposDir string
+
+	// File the server's host key is kept in, created on first start
+	HostKeyPath string
+
+	// LookupKey returns the ID of the user a public key belongs to
+	LookupKey func(key ssh.PublicKey) (userID string, err error)
+
+	// Authorize checks the user may run the service on the repository. The
+	// returned function, when not nil, runs after a successful command.
+	Authorize func(userID, repoID, service string) (done func(), err error)
+}
+
+// Server is an SSH server that only runs git
+type Server struct {
+	config   Config
+	ssh      *ssh.ServerConfig
+	mu       sync.Mutex
+	listener net.Listener
+}
+
+// New creates a server, loading or generating its host key
+func New(config Config) (*Server, error) {
+	if config.LookupKey == nil || config.Authorize == nil {
+		return nil, errors.New("gitssh: LookupKey and Authorize are required")
+	}
+
+	hostKey, err := loadHostKey(config.HostKeyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{config: config}
+	s.ssh = &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			userID, err := config.LookupKey(key)
+			if err != nil {
+				return nil, fmt.Errorf("unknown public key for %s", conn.User())
+			}
+			return &ssh.Permissions{Extensions: map[string]string{userExtension: userID}}, nil
+		},
+	}
+	s.ssh.AddHostKey(hostKey)
+	return s, nil
+}
+
+// ListenAndServe accepts connections on addr until the server is closed
+func (s *Server) ListenAndServe(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(listener)
+}
+
+// Serve accepts connections on the listener until the server is closed
+func (s *Server) Serve(listener net.Listener) error {
+	s.mu.Lock()
+	s.listener = listener
+	s.mu.Unlock()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go s.handleConn(conn)
+	}
+}
+
+// Close stops accepting connections
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Close()
+}
+
+// handleConn completes the handshake and serves the connection's sessions
+func (s *Server) handleConn(conn net.Conn) {
+	serverConn, channels, requests, err := ssh.NewServerConn(conn, s.ssh)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	defer serverConn.Close()
+	go ssh.DiscardRequests(requests)
+
+	userID := serverConn.Permissions.Extensions[userExtension]
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "only sessions are supported")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		go s.handleSession(userID, channel, requests)
+	}
+}
+
+// handleSession waits for the session's exec request and runs it. Shells
+// and anything other than git are refused.
+func (s *Server) handleSession(userID string, channel ssh.Channel, requests <-chan *ssh.Request) {
+	defer channel.Close()
+
+	for req := range requests {
+		switch req.Type {
+		case "env":
+			// Git sends GIT_PROTOCOL this way; the rest is ignored
+			req.Reply(true, nil)
+		case "exec":
+			req.Reply(true, nil)
+			status := s.exec(userID, channel, execCommand(req.Payload))
+			channel.SendRequest("exit-status", false, binary.BigEndian.AppendUint32(nil, uint32(status)))
+			return
+		case "shell":
+			req.Reply(true, nil)
+			fmt.Fprintln(channel.Stderr(), "Hi! You've authenticated, but shell access is not provided.")
+			channel.SendRequest("exit-status", false, binary.BigEndian.AppendUint32(nil, 1))
+			return
+		default:
+			req.Reply(false, nil)
+		}
+	}
+}
+
+// exec authorizes and runs a git command, returning its exit status
+func (s *Server) exec(userID string, channel ssh.Channel, command string) int {
+	service, repoID, err := ParseCommand(command)
+	if err != nil {
+		fmt.Fprintf(channel.Stderr(), "%v\n", err)
+		return 1
+	}
+
+	done, err := s.config.Authorize(userID, repoID, service)
+	if err != nil {
+		fmt.Fprintf(channel.Stderr(), "%v\n", err)
+		return 1
+	}
+
+	cmd := exec.Command(service, filepath.Join(s.config.ReposDir, repoID))
+	cmd.Stdout = channel
+	cmd.Stderr = channel.Stderr()
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return 1
+	}
+	if err := cmd.Start(); err != nil {
+		log.Printf("gitssh: Failed to start %s for %s: %v", service, repoID, err)
+		return 1
+	}
+	// Clients may keep their side open until git exits, so this isn't waited for
+	go func() {
+		io.Copy(stdin, channel)
+		stdin.Close()
+	}()
+	if err := cmd.Wait(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return exitErr.ExitCode()
+		}
+		log.Printf("gitssh: Failed to run %s for %s: %v", service, repoID, err)
+		return 1
+	}
+
+	if done != nil {
+		done()
+	}
+	return 0
+}
+
+// execCommand decodes the command string of an exec request
+func execCommand(payload []byte) string {
+	var msg struct{ Command string }
+	if err := ssh.Unmarshal(payload, &msg); err != nil {
+		return ""
+	}
+	return msg.Command
+}
+
+// ParseCommand splits a git command sent over SSH, such as
+// "git-upload-pack 'repo/abc.git'", into the service and repository ID.
+// The same paths as HTTP clone URLs are accepted, with or without the
+// "repo/" prefix and ".git" suffix.
+func ParseCommand(command string) (service, repoID string, err error) {
+	service, path, ok := strings.Cut(strings.TrimSpace(command), " ")
+	if !ok || (service != UploadPack && service != ReceivePack) {
+		return "", "", errors.New("only git clone, fetch and push are supported")
+	}
+
+	path = strings.Trim(strings.TrimSpace(path), `'"`)
+	path = strings.TrimPrefix(path, "/")
+	path = strings.TrimPrefix(path, "repo/")
+	repoID = strings.TrimSuffix(path, ".git")
+	if repoID == "" || strings.ContainsAny(repoID, `/\'"`) || strings.HasPrefix(repoID, ".") {
+		return "", "", fmt.Errorf("invalid repository path %q", path)
+	}
+	return service, repoID, nil
+}
+
+// loadHostKey reads the server's host key, generating and saving an
+// ed25519 key the first time
+func loadHostKey(path string) (ssh.Signer, error) {
+	if data, err := os.ReadFile(path); err == nil {
+		return ssh.ParsePrivateKey(data)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	block, err := ssh.MarshalPrivateKey(key, "")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0600); err != nil {
+		return nil, err
+	}
+	return ssh.NewSignerFromKey(key)
+}
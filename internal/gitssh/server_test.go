@@ -0,0 +1,152 @@
+package gitssh
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"net"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestParseCommand(t *testing.T) {
+	tests := []struct {
+		command string
+		service string
+		repoID  string
+		wantErr bool
+	}{
+		{command: "git-upload-pack 'repo/abc.git'", service: UploadPack, repoID: "abc"},
+		{command: "git-receive-pack '/repo/abc'", service: ReceivePack, repoID: "abc"},
+		{command: "git-upload-pack 'abc.git'", service: UploadPack, repoID: "abc"},
+		{command: "git-upload-archive 'repo/abc.git'", wantErr: true},
+		{command: "sh -c 'cat /etc/passwd'", wantErr: true},
+		{command: "git-upload-pack '../secrets'", wantErr: true},
+		{command: "git-upload-pack 'repo/a/b.git'", wantErr: true},
+		{command: "git-upload-pack", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			service, repoID, err := ParseCommand(tt.command)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseCommand() = %q, %q, expected an error", service, repoID)
+				}
+				return
+			}
+			if err != nil || service != tt.service || repoID != tt.repoID {
+				t.Errorf("ParseCommand() = %q, %q, %v", service, repoID, err)
+			}
+		})
+	}
+}
+
+func TestServer(t *testing.T) {
+	if _, err := exec.LookPath(UploadPack); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	reposDir := t.TempDir()
+	if out, err := exec.Command("git", "init", "--bare", "-b", "main", filepath.Join(reposDir, "demo")).CombinedOutput(); err != nil {
+		t.Fatalf("git init: %s", out)
+	}
+
+	_, userKey, _ := ed25519.GenerateKey(rand.Reader)
+	signer, _ := ssh.NewSignerFromKey(userKey)
+	_, strangerKey, _ := ed25519.GenerateKey(rand.Reader)
+	stranger, _ := ssh.NewSignerFromKey(strangerKey)
+
+	var authorized []string
+	server, err := New(Config{
+		ReposDir:    reposDir,
+		HostKeyPath: filepath.Join(t.TempDir(), "host_key"),
+		LookupKey: func(key ssh.PublicKey) (string, error) {
+			if bytes.Equal(key.Marshal(), signer.PublicKey().Marshal()) {
+				return "user-1", nil
+			}
+			return "", errors.New("unknown key")
+		},
+		Authorize: func(userID, repoID, service string) (func(), error) {
+			authorized = append(authorized, userID+" "+repoID+" "+service)
+			if service == ReceivePack {
+				return nil, errors.New("only admins can push to repositories")
+			}
+			return nil, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve(listener)
+	defer server.Close()
+
+	dial := func(key ssh.Signer) (*ssh.Client, error) {
+		return ssh.Dial("tcp", listener.Addr().String(), &ssh.ClientConfig{
+			User:            "git",
+			Auth:            []ssh.AuthMethod{ssh.PublicKeys(key)},
+			HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		})
+	}
+
+	if _, err := dial(stranger); err == nil {
+		t.Error("Expected an unknown key to be refused")
+	}
+
+	client, err := dial(signer)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer client.Close()
+
+	t.Run("UploadPack", func(t *testing.T) {
+		session, _ := client.NewSession()
+		defer session.Close()
+		// A flush packet ends the exchange after the ref advertisement
+		session.Stdin = strings.NewReader("0000")
+		out, err := session.Output("git-upload-pack 'repo/demo.git'")
+		if err != nil {
+			t.Fatalf("git-upload-pack error = %v", err)
+		}
+		if !strings.HasPrefix(string(out), "00") {
+			t.Errorf("Expected a pkt-line advertisement, got %q", out)
+		}
+	})
+
+	t.Run("Unauthorized", func(t *testing.T) {
+		session, _ := client.NewSession()
+		defer session.Close()
+		var stderr bytes.Buffer
+		session.Stderr = &stderr
+		err := session.Run("git-receive-pack 'repo/demo.git'")
+		var exitErr *ssh.ExitError
+		if !errors.As(err, &exitErr) || exitErr.ExitStatus() != 1 {
+			t.Errorf("Expected exit status 1, got %v", err)
+		}
+		if !strings.Contains(stderr.String(), "only admins") {
+			t.Errorf("Expected the refusal on stderr, got %q", stderr.String())
+		}
+	})
+
+	t.Run("NoShell", func(t *testing.T) {
+		session, _ := client.NewSession()
+		defer session.Close()
+		if err := session.Run("cat /etc/passwd"); err == nil {
+			t.Error("Expected arbitrary commands to be refused")
+		}
+	})
+
+	want := []string{"user-1 demo git-upload-pack", "user-1 demo git-receive-pack"}
+	if strings.Join(authorized, ",") != strings.Join(want, ",") {
+		t.Errorf("Authorize calls = %v, want %v", authorized, want)
+	}
+}
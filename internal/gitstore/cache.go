@@ -0,0 +1,71 @@
+package gitstore
+
+import (
+	"container/list"
+	"sync"
+)
+
+// cache is a least recently used cache bounded by the approximate size of
+// its values in bytes
+type cache struct {
+	mu       sync.Mutex
+	maxBytes int64
+	bytes    int64
+	order    *list.List
+	entries  map[string]*list.Element
+}
+
+// cacheEntry is a cached value with its approximate size
+type cacheEntry struct {
+	key   string
+	value any
+	size  int64
+}
+
+// newCache creates a cache holding up to maxBytes
+func newCache(maxBytes int64) *cache {
+	return &cache{
+		maxBytes: maxBytes,
+		order:    list.New(),
+		entries:  map[string]*list.Element{},
+	}
+}
+
+// get returns the value for key and marks it as recently used
+func (c *cache) get(key string) (any, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(*cacheEntry).value, true
+}
+
+// add stores value under key, evicting the least recently used values to
+// stay within the size limit. Values larger than the whole cache are skipped.
+func (c *cache) add(key string, value any, size int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if size > c.maxBytes {
+		return
+	}
+	if el, ok := c.entries[key]; ok {
+		c.bytes -= el.Value.(*cacheEntry).size
+		c.order.Remove(el)
+	}
+
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, value: value, size: size})
+	c.bytes += size
+
+	for c.bytes > c.maxBytes {
+		oldest := c.order.Back()
+		entry := oldest.Value.(*cacheEntry)
+		c.order.Remove(oldest)
+		delete(c.entries, entry.key)
+		c.bytes -= entry.size
+	}
+}
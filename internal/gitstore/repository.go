@@ -0,0 +1,134 @@
+package gitstore
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-billy/v5/osfs"
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	objectcache "github.com/go-git/go-git/v5/plumbing/cache"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/filesystem"
+)
+
+// ErrNotFound is returned when a revision, path or object does not exist
+var ErrNotFound = errors.New("git object not found")
+
+// Object describes a git object
+type Object struct {
+	Hash string
+	Type string // "blob", "tree", "commit" or "tag"
+	Size int64
+}
+
+// objectCacheSize bounds the decoded objects kept for each open repository
+const objectCacheSize = 8 * objectcache.MiByte
+
+// repository is an open repository, read in process so lookups need no git
+// process at all
+type repository struct {
+	mu       sync.Mutex
+	dir      string
+	repo     *gogit.Repository
+	storage  *filesystem.Storage
+	packs    time.Time // When the pack directory last changed, to notice pushes and repacks
+	lastUsed time.Time
+}
+
+// openRepository opens the bare or non-bare repository at dir
+func openRepository(dir string) (*repository, error) {
+	gitDir := dir
+	if info, err := os.Stat(filepath.Join(dir, ".git")); err == nil && info.IsDir() {
+		gitDir = filepath.Join(dir, ".git")
+	}
+	// A repository that was never initialized has nothing to find
+	if _, err := os.Stat(filepath.Join(gitDir, "objects")); err != nil {
+		return nil, ErrNotFound
+	}
+
+	storage := filesystem.NewStorage(osfs.New(gitDir), objectcache.NewObjectLRU(objectCacheSize))
+	repo, err := gogit.Open(storage, nil)
+	if err != nil {
+		if errors.Is(err, gogit.ErrRepositoryNotExists) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &repository{
+		dir:      gitDir,
+		repo:     repo,
+		storage:  storage,
+		packs:    packsModified(gitDir),
+		lastUsed: time.Now(),
+	}, nil
+}
+
+// packsModified returns when packs were last added to or removed from the
+// repository at gitDir
+func packsModified(gitDir string) time.Time {
+	info, err := os.Stat(filepath.Join(gitDir, "objects", "pack"))
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// use runs fn with the repository to itself. The pack index is read once
+// and reused, so it's read again when a push or repack changed the packs.
+func (r *repository) use(fn func() error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	defer func() { r.lastUsed = time.Now() }()
+
+	if modified := packsModified(r.dir); !modified.Equal(r.packs) {
+		r.storage.Reindex()
+		r.packs = modified
+	}
+	return notFound(fn())
+}
+
+// commit returns the commit rev points to, peeling annotated tags
+func (r *repository) commit(rev string) (*object.Commit, error) {
+	hash, err := r.repo.ResolveRevision(plumbing.Revision(rev))
+	if err != nil {
+		// Unknown names and malformed revisions alike can't be found
+		return nil, ErrNotFound
+	}
+	return r.repo.CommitObject(*hash)
+}
+
+// tree returns the directory at path in rev
+func (r *repository) tree(rev, path string) (*object.Tree, error) {
+	commit, err := r.commit(rev)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := commit.Tree()
+	if err != nil || path == "" {
+		return tree, err
+	}
+	return tree.Tree(path)
+}
+
+// size returns the size of the object with hash without reading it
+func (r *repository) size(hash plumbing.Hash) (int64, error) {
+	return r.storage.EncodedObjectSize(hash)
+}
+
+// notFound turns go-git's many kinds of missing into ErrNotFound
+func notFound(err error) error {
+	switch {
+	case errors.Is(err, plumbing.ErrObjectNotFound),
+		errors.Is(err, plumbing.ErrReferenceNotFound),
+		errors.Is(err, object.ErrFileNotFound),
+		errors.Is(err, object.ErrDirectoryNotFound),
+		errors.Is(err, object.ErrEntryNotFound):
+		return ErrNotFound
+	}
+	return err
+}
@@ -0,0 +1,433 @@
+// Package gitstore reads files, directory listings and history from
+// repositories in process with go-git, caching results by commit hash, so
+// busy repository pages don't start a git process per read.
+package gitstore
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+)
+
+const (
+	// idleTimeout is how long an unused repository is kept open
+	idleTimeout = 5 * time.Minute
+
+	// maxCachedBlob is the largest file content kept in the cache
+	maxCachedBlob = 1 << 20
+)
+
+// Entry is one item of a directory listing
+type Entry struct {
+	Name string
+	Mode string // Zero padded like git ls-tree, e.g. "100644" or "040000"
+	Type string // "blob", "tree" or "commit" for submodules
+	Hash string
+	Size int64 // Only set for blobs
+}
+
+// Commit is one entry of a repository's history
+type Commit struct {
+	Hash    string
+	Subject string // First paragraph of the message
+	Author  string
+	Email   string
+	Date    time.Time // When it was authored
+}
+
+// Store serves the hot read paths of bare repositories, file listings,
+// contents and history, reading objects in process with go-git rather than
+// running git for each. Results are cached by commit hash: a commit never
+// changes, so nothing needs invalidating when branches move.
+type Store struct {
+	mu    sync.Mutex
+	repos map[string]*repository
+	cache *cache
+}
+
+// Default is the store shared by the repository models
+var Default = New(64 << 20)
+
+// New creates a store caching up to cacheBytes of results
+func New(cacheBytes int64) *Store {
+	return &Store{
+		repos: map[string]*repository{},
+		cache: newCache(cacheBytes),
+	}
+}
+
+// Resolve returns the commit hash rev points to in the repository at dir
+func (s *Store) Resolve(dir, rev string) (string, error) {
+	var hash string
+	err := s.do(dir, func(r *repository) error {
+		commit, err := r.commit(rev)
+		if err != nil {
+			return err
+		}
+		hash = commit.Hash.String()
+		return nil
+	})
+	return hash, err
+}
+
+// Stat looks up the object at path in rev without reading it
+func (s *Store) Stat(dir, rev, path string) (Object, error) {
+	path = cleanPath(path)
+	var obj Object
+	err := s.do(dir, func(r *repository) error {
+		tree, err := r.tree(rev, "")
+		if err != nil {
+			return err
+		}
+		if path == "" {
+			obj = Object{Hash: tree.Hash.String(), Type: "tree"}
+			return nil
+		}
+
+		entry, err := tree.FindEntry(path)
+		if err != nil {
+			return err
+		}
+		obj = Object{Hash: entry.Hash.String(), Type: entryType(entry.Mode)}
+		if obj.Type == "blob" {
+			obj.Size, err = r.size(entry.Hash)
+		}
+		return err
+	})
+	return obj, err
+}
+
+// Tree lists the directory at path in commit, which should be a hash from
+// Resolve so the listing can be cached
+func (s *Store) Tree(dir, commit, path string) ([]Entry, error) {
+	path = cleanPath(path)
+	key := cacheKey(dir, "tree", commit, path)
+	if cached, ok := s.cache.get(key); ok {
+		return cached.([]Entry), nil
+	}
+
+	var entries []Entry
+	err := s.do(dir, func(r *repository) error {
+		tree, err := r.tree(commit, path)
+		if err != nil {
+			return err
+		}
+
+		entries = make([]Entry, 0, len(tree.Entries))
+		for _, e := range tree.Entries {
+			entry := Entry{
+				Name: e.Name,
+				Mode: fmt.Sprintf("%06o", uint32(e.Mode)),
+				Type: entryType(e.Mode),
+				Hash: e.Hash.String(),
+			}
+			// Tree entries don't record sizes, but object headers do
+			if entry.Type == "blob" {
+				if entry.Size, err = r.size(e.Hash); err != nil {
+					return err
+				}
+			}
+			entries = append(entries, entry)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	size := int64(0)
+	for _, entry := range entries {
+		size += int64(len(entry.Name)+len(entry.Hash)) + 32
+	}
+	s.cache.add(key, entries, size)
+	return entries, nil
+}
+
+// Blob reads the file at path in commit. Small files are cached.
+func (s *Store) Blob(dir, commit, path string) ([]byte, error) {
+	path = cleanPath(path)
+	key := cacheKey(dir, "blob", commit, path)
+	if cached, ok := s.cache.get(key); ok {
+		return cached.([]byte), nil
+	}
+
+	var data []byte
+	err := s.do(dir, func(r *repository) error {
+		tree, err := r.tree(commit, "")
+		if err != nil {
+			return err
+		}
+		file, err := tree.File(path)
+		if err != nil {
+			return err
+		}
+		data, err = readBlob(&file.Blob)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) <= maxCachedBlob {
+		s.cache.add(key, data, int64(len(data)))
+	}
+	return data, nil
+}
+
+// Object reads the blob with the given hash. Nothing is cached, so bulk
+// reads such as indexing a whole repository don't flush the cache.
+func (s *Store) Object(dir, hash string) ([]byte, error) {
+	if !plumbing.IsHash(hash) {
+		return nil, ErrNotFound
+	}
+	var data []byte
+	err := s.do(dir, func(r *repository) error {
+		blob, err := r.repo.BlobObject(plumbing.NewHash(hash))
+		if err != nil {
+			return err
+		}
+		data, err = readBlob(blob)
+		return err
+	})
+	return data, err
+}
+
+// Log returns up to limit commits reachable from commit, newest first, or
+// all of them when limit is 0
+func (s *Store) Log(dir, commit string, limit int) ([]Commit, error) {
+	var commits []Commit
+	err := s.do(dir, func(r *repository) error {
+		head, err := r.commit(commit)
+		if err != nil {
+			return err
+		}
+		history, err := r.repo.Log(&gogit.LogOptions{From: head.Hash, Order: gogit.LogOrderCommitterTime})
+		if err != nil {
+			return err
+		}
+		defer history.Close()
+
+		return history.ForEach(func(c *object.Commit) error {
+			// The subject is the first paragraph on one line, as git shows it
+			subject, _, _ := strings.Cut(strings.TrimSpace(c.Message), "\n\n")
+			commits = append(commits, Commit{
+				Hash:    c.Hash.String(),
+				Subject: strings.ReplaceAll(subject, "\n", " "),
+				Author:  c.Author.Name,
+				Email:   c.Author.Email,
+				Date:    c.Author.When,
+			})
+			if limit > 0 && len(commits) >= limit {
+				return storer.ErrStop
+			}
+			return nil
+		})
+	})
+	return commits, err
+}
+
+// LastModified returns when each of paths was last changed in the history
+// of commit. All paths are found with a single walk of the history, which
+// is what makes directory listings cheap; paths without history are zero.
+func (s *Store) LastModified(dir, commit string, paths []string) (map[string]time.Time, error) {
+	times := make(map[string]time.Time, len(paths))
+	pending := map[string]bool{}
+	for _, path := range paths {
+		path = cleanPath(path)
+		if cached, ok := s.cache.get(cacheKey(dir, "modified", commit, path)); ok {
+			times[path] = cached.(time.Time)
+		} else {
+			pending[path] = true
+		}
+	}
+	if len(pending) == 0 {
+		return times, nil
+	}
+
+	err := s.do(dir, func(r *repository) error {
+		head, err := r.commit(commit)
+		if err != nil {
+			return err
+		}
+		history, err := r.repo.Log(&gogit.LogOptions{From: head.Hash, Order: gogit.LogOrderCommitterTime})
+		if err != nil {
+			return err
+		}
+		defer history.Close()
+
+		return history.ForEach(func(c *object.Commit) error {
+			tree, err := c.Tree()
+			if err != nil {
+				return err
+			}
+			parents := make([]*object.Tree, 0, len(c.ParentHashes))
+			for _, hash := range c.ParentHashes {
+				parent, err := r.repo.CommitObject(hash)
+				if err != nil {
+					return err
+				}
+				parentTree, err := parent.Tree()
+				if err != nil {
+					return err
+				}
+				parents = append(parents, parentTree)
+			}
+
+			for path := range pending {
+				if changedIn(tree, parents, path) {
+					times[path] = c.Author.When
+					delete(pending, path)
+					s.cache.add(cacheKey(dir, "modified", commit, path), c.Author.When, 64)
+				}
+			}
+			// Everything was found, so the rest of the history is not needed
+			if len(pending) == 0 {
+				return storer.ErrStop
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for path := range pending {
+		times[path] = time.Time{}
+		s.cache.add(cacheKey(dir, "modified", commit, path), time.Time{}, 64)
+	}
+	return times, nil
+}
+
+// Remember returns the value cached under key for the repository at dir,
+// calling load to produce it and its approximate size in bytes when missing.
+// The key must contain the hash of every commit the value is derived from.
+func (s *Store) Remember(dir, key string, load func() (any, int64, error)) (any, error) {
+	key = cacheKey(dir, "value", key)
+	if cached, ok := s.cache.get(key); ok {
+		return cached, nil
+	}
+
+	value, size, err := load()
+	if err != nil {
+		return nil, err
+	}
+	s.cache.add(key, value, size)
+	return value, nil
+}
+
+// Forget closes a repository, such as one being deleted
+func (s *Store) Forget(dir string) {
+	s.mu.Lock()
+	delete(s.repos, dir)
+	s.mu.Unlock()
+}
+
+// do runs fn with the open repository at dir
+func (s *Store) do(dir string, fn func(*repository) error) error {
+	r, err := s.repository(dir)
+	if err != nil {
+		return err
+	}
+	return r.use(func() error { return fn(r) })
+}
+
+// repository returns the open repository at dir, opening it if needed, and
+// closes repositories that have gone idle so their caches are freed
+func (s *Store) repository(dir string) (*repository, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for path, r := range s.repos {
+		if path == dir || !r.mu.TryLock() {
+			continue
+		}
+		idle := time.Since(r.lastUsed) > idleTimeout
+		r.mu.Unlock()
+		if idle {
+			delete(s.repos, path)
+		}
+	}
+
+	if r, ok := s.repos[dir]; ok {
+		return r, nil
+	}
+	r, err := openRepository(dir)
+	if err != nil {
+		return nil, err
+	}
+	s.repos[dir] = r
+	return r, nil
+}
+
+// entryType names the kind of object a tree entry points to
+func entryType(mode filemode.FileMode) string {
+	switch mode {
+	case filemode.Dir:
+		return "tree"
+	case filemode.Submodule:
+		return "commit"
+	default:
+		return "blob"
+	}
+}
+
+// changedIn reports whether path was changed by the commit with tree and
+// parents. A merge only changed it when it differs from every parent;
+// otherwise the change came from a parent, found further down the history.
+func changedIn(tree *object.Tree, parents []*object.Tree, path string) bool {
+	hash := pathHash(tree, path)
+	if hash.IsZero() {
+		return false
+	}
+	for _, parent := range parents {
+		if pathHash(parent, path) == hash {
+			return false
+		}
+	}
+	return true
+}
+
+// pathHash returns the hash of the object at path in tree, or the zero
+// hash when there's nothing there
+func pathHash(tree *object.Tree, path string) plumbing.Hash {
+	if path == "" {
+		return tree.Hash
+	}
+	entry, err := tree.FindEntry(path)
+	if err != nil {
+		return plumbing.ZeroHash
+	}
+	return entry.Hash
+}
+
+// readBlob reads the whole of a blob
+func readBlob(blob *object.Blob) ([]byte, error) {
+	reader, err := blob.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+// cleanPath normalizes a repository path, with "" for the root
+func cleanPath(path string) string {
+	path = strings.Trim(path, "/")
+	if path == "." {
+		return ""
+	}
+	return path
+}
+
+// cacheKey joins the parts of a cache key
+func cacheKey(parts ...string) string {
+	return strings.Join(parts, "\x00")
+}
@@ -0,0 +1,233 @@
+package gitstore
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// commitFiles writes files into a repository and commits them at date
+func commitFiles(t *testing.T, dir, date string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	git(t, dir, "add", "-A")
+	cmd := exec.Command("git", "commit", "-q", "-m", "update", "--date", date)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_COMMITTER_DATE="+date)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git commit: %v\n%s", err, out)
+	}
+}
+
+func git(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, out)
+	}
+}
+
+func setupRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	dir := t.TempDir()
+	git(t, dir, "init", "-q", "-b", "main")
+	git(t, dir, "config", "user.name", "Test")
+	git(t, dir, "config", "user.email", "test@example.com")
+
+	commitFiles(t, dir, "2024-01-01T10:00:00Z", map[string]string{
+		"README.md":        "# Hello\n",
+		"src/main.go":      "package main\n",
+		"src/has space.go": "package main\n",
+	})
+	commitFiles(t, dir, "2024-02-01T10:00:00Z", map[string]string{
+		"src/main.go": "package main\n\nfunc main() {}\n",
+	})
+	return dir
+}
+
+func TestTreeAndBlob(t *testing.T) {
+	dir := setupRepo(t)
+	s := New(1 << 20)
+	defer s.Forget(dir)
+
+	commit, err := s.Resolve(dir, "main")
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if _, err := s.Resolve(dir, "missing-branch"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Resolve of a missing branch = %v, want ErrNotFound", err)
+	}
+
+	root, err := s.Tree(dir, commit, "")
+	if err != nil {
+		t.Fatalf("Tree failed: %v", err)
+	}
+	if len(root) != 2 || root[0].Name != "README.md" || root[0].Size != 8 || root[1].Type != "tree" || root[1].Mode != "040000" {
+		t.Errorf("unexpected root listing %+v", root)
+	}
+
+	src, err := s.Tree(dir, commit, "src/")
+	if err != nil || len(src) != 2 || src[0].Name != "has space.go" {
+		t.Errorf("unexpected src listing %+v, %v", src, err)
+	}
+
+	data, err := s.Blob(dir, commit, "src/main.go")
+	if err != nil || string(data) != "package main\n\nfunc main() {}\n" {
+		t.Errorf("Blob = %q, %v", data, err)
+	}
+	if _, err := s.Blob(dir, commit, "src"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Blob of a directory = %v, want ErrNotFound", err)
+	}
+
+	obj, err := s.Stat(dir, "main", "src")
+	if err != nil || obj.Type != "tree" {
+		t.Errorf("Stat = %+v, %v", obj, err)
+	}
+
+	file, err := s.Stat(dir, commit, "src/main.go")
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if data, err := s.Object(dir, file.Hash); err != nil || string(data) != "package main\n\nfunc main() {}\n" {
+		t.Errorf("Object = %q, %v", data, err)
+	}
+	if _, err := s.Object(dir, obj.Hash); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Object of a tree = %v, want ErrNotFound", err)
+	}
+}
+
+func TestLastModified(t *testing.T) {
+	dir := setupRepo(t)
+	s := New(1 << 20)
+	defer s.Forget(dir)
+
+	commit, err := s.Resolve(dir, "main")
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+
+	times, err := s.LastModified(dir, commit, []string{"README.md", "src", "src/has space.go"})
+	if err != nil {
+		t.Fatalf("LastModified failed: %v", err)
+	}
+
+	january := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	february := time.Date(2024, 2, 1, 10, 0, 0, 0, time.UTC)
+	if !times["README.md"].Equal(january) || !times["src/has space.go"].Equal(january) {
+		t.Errorf("files changed in the first commit got %v", times)
+	}
+	if !times["src"].Equal(february) {
+		t.Errorf("directory should take the time of its latest change, got %v", times["src"])
+	}
+}
+
+func TestSeesPushedAndRepackedObjects(t *testing.T) {
+	dir := setupRepo(t)
+	s := New(1 << 20)
+	defer s.Forget(dir)
+
+	if _, err := s.Resolve(dir, "main"); err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+
+	// Packs written after the repository was opened must still be read
+	commitFiles(t, dir, "2024-03-01T10:00:00Z", map[string]string{"NEW.md": "new\n"})
+	git(t, dir, "repack", "-a", "-d", "-q")
+	git(t, dir, "pack-refs", "--all")
+
+	commit, err := s.Resolve(dir, "main")
+	if err != nil {
+		t.Fatalf("Resolve after repacking = %v", err)
+	}
+	if data, err := s.Blob(dir, commit, "NEW.md"); err != nil || string(data) != "new\n" {
+		t.Errorf("Blob after repacking = %q, %v", data, err)
+	}
+}
+
+func TestLog(t *testing.T) {
+	dir := setupRepo(t)
+	s := New(1 << 20)
+	defer s.Forget(dir)
+
+	commits, err := s.Log(dir, "main", 0)
+	if err != nil {
+		t.Fatalf("Log failed: %v", err)
+	}
+	if len(commits) != 2 || commits[0].Subject != "update" || commits[0].Email != "test@example.com" {
+		t.Fatalf("unexpected history %+v", commits)
+	}
+	if !commits[0].Date.Equal(time.Date(2024, 2, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("newest commit should come first, got %v", commits[0].Date)
+	}
+
+	if commits, err := s.Log(dir, "main", 1); err != nil || len(commits) != 1 {
+		t.Errorf("Log with a limit = %d commits, %v", len(commits), err)
+	}
+	if _, err := s.Log(dir, "missing-branch", 0); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Log of a missing branch = %v, want ErrNotFound", err)
+	}
+}
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := newCache(10)
+	c.add("a", 1, 4)
+	c.add("b", 2, 4)
+	c.get("a")
+	c.add("c", 3, 4)
+
+	if _, ok := c.get("b"); ok {
+		t.Error("b should have been evicted")
+	}
+	if _, ok := c.get("a"); !ok {
+		t.Error("a was used recently and should be kept")
+	}
+	c.add("huge", 4, 11)
+	if _, ok := c.get("huge"); ok {
+		t.Error("values larger than the cache should not be stored")
+	}
+}
+
+func TestRemember(t *testing.T) {
+	s := New(1 << 20)
+	calls := 0
+	load := func() (any, int64, error) {
+		calls++
+		return "history", 7, nil
+	}
+
+	for i := 0; i < 2; i++ {
+		value, err := s.Remember("/repo", "log:abc", load)
+		if err != nil || value != "history" {
+			t.Fatalf("Remember = %v, %v", value, err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("load called %d times, want 1", calls)
+	}
+	if _, err := s.Remember("/other", "log:abc", load); err != nil || calls != 2 {
+		t.Errorf("keys should be scoped to the repository, load called %d times", calls)
+	}
+}
+
+func TestMissingRepository(t *testing.T) {
+	s := New(1 << 20)
+	if _, err := s.Resolve(filepath.Join(t.TempDir(), "missing"), "main"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Resolve in a missing repository = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,398 @@
+// Package lint runs go vet, staticcheck, eslint and gosec in a single
+// shell script and reads their findings back as one list of issues.
+package lint
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Severities of an issue, as the pull request analysis rates them
+const (
+	SeverityHigh   = "high"
+	SeverityMedium = "medium"
+	SeverityLow    = "low"
+)
+
+// MaxIssues caps the issues read from one run, so a repository that was
+// never linted doesn't flood its pull requests
+const MaxIssues = 500
+
+// Markers the script prints around each linter's output
+const (
+	rootMarker = "::lint-root::"
+	lintMarker = "::lint::"
+	skipMarker = "::lint-skip::"
+)
+
+// Issue is one finding of a linter, at a path relative to the repository
+type Issue struct {
+	Linter   string `json:"linter"`
+	Rule     string `json:"rule,omitempty"` // The check that fired, like SA4006 or G101, when the linter names it
+	Severity string `json:"severity"`       // SeverityHigh, SeverityMedium or SeverityLow
+	File     string `json:"file"`
+	Line     int    `json:"line,omitempty"`
+	Column   int    `json:"column,omitempty"`
+	Message  string `json:"message"`
+	Security bool   `json:"security,omitempty"` // A security problem rather than a bug or style issue
+}
+
+// Location returns where the issue is, as file:line
+func (i Issue) Location() string {
+	if i.Line == 0 {
+		return i.File
+	}
+	return fmt.Sprintf("%s:%d", i.File, i.Line)
+}
+
+// Title returns the linter and rule that found the issue
+func (i Issue) Title() string {
+	if i.Rule == "" {
+		return i.Linter
+	}
+	return i.Linter + " " + i.Rule
+}
+
+// String returns the issue the way compilers print errors
+func (i Issue) String() string {
+	return fmt.Sprintf("%s: %s: %s", i.Location(), i.Title(), i.Message)
+}
+
+// Linter is a linter the script can run from the repository's root
+type Linter struct {
+	Name     string
+	Manifest string // File at the root that shows the linter applies
+	Binary   string // Command that must be installed for it to run
+	Command  string // Prints the findings on stdout
+	parse    func(output []byte) []Issue
+}
+
+// Linters are the linters that can be run, in the order they run
+var Linters = []*Linter{
+	{Name: "govet", Manifest: "go.mod", Binary: "go", Command: "go vet ./... 2>&1", parse: parseVet},
+	{Name: "staticcheck", Manifest: "go.mod", Binary: "staticcheck", Command: "staticcheck -f json ./... 2>/dev/null", parse: parseStaticcheck},
+	{Name: "eslint", Manifest: "package.json", Binary: "eslint", Command: "eslint -f json . 2>/dev/null", parse: parseESLint},
+	{Name: "gosec", Manifest: "go.mod", Binary: "gosec", Command: "gosec -quiet -fmt=json ./... 2>/dev/null", parse: parseGosec},
+}
+
+// Names returns the names of the linters that can be run
+func Names() []string {
+	names := make([]string, len(Linters))
+	for i, linter := range Linters {
+		names[i] = linter.Name
+	}
+	return names
+}
+
+// Find returns the linter with a name, or nil when there isn't one
+func Find(name string) *Linter {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "vet" || name == "go vet" {
+		name = "govet"
+	}
+	for _, linter := range Linters {
+		if linter.Name == name {
+			return linter
+		}
+	}
+	return nil
+}
+
+// Select returns the named linters, or all of them when no names or "all"
+// are given
+func Select(names []string) ([]*Linter, error) {
+	var selected []*Linter
+	seen := map[string]bool{}
+	for _, name := range names {
+		if strings.EqualFold(strings.TrimSpace(name), "all") {
+			return Linters, nil
+		}
+		linter := Find(name)
+		if linter == nil {
+			return nil, fmt.Errorf("unknown linter %q, expected one of %s", name, strings.Join(Names(), ", "))
+		}
+		if !seen[linter.Name] {
+			seen[linter.Name] = true
+			selected = append(selected, linter)
+		}
+	}
+	if len(selected) == 0 {
+		return Linters, nil
+	}
+	return selected, nil
+}
+
+// Script returns a shell script that runs the linters from the repository's
+// root, skipping those that don't apply or aren't installed. Linters
+// installed with go install or npm are found too. The script succeeds
+// whatever the linters find, so its output must be read with ParseOutput.
+// It has no double quotes, so it can be echoed inside them.
+func Script(linters []*Linter) string {
+	var script strings.Builder
+	script.WriteString("export PATH=$PWD/node_modules/.bin:$HOME/go/bin:$PATH\n")
+	script.WriteString("echo " + rootMarker + "$PWD\n")
+	for _, linter := range linters {
+		fmt.Fprintf(&script, "echo '%s%s'\n", lintMarker, linter.Name)
+		fmt.Fprintf(&script, "if [ ! -e %s ]; then echo '%sno %s'\n", linter.Manifest, skipMarker, linter.Manifest)
+		fmt.Fprintf(&script, "elif ! command -v %s >/dev/null 2>&1; then echo '%s%s is not installed'\n", linter.Binary, skipMarker, linter.Binary)
+		fmt.Fprintf(&script, "else %s || true; echo\nfi\n", linter.Command)
+	}
+	return script.String()
+}
+
+// Report is what the linters found in a run of the script
+type Report struct {
+	Ran       []string // Linters that ran
+	Skipped   []string // Linters that didn't, with why, like "eslint: no package.json"
+	Issues    []Issue
+	Truncated bool // More than MaxIssues were found
+}
+
+// Security returns the issues that are security problems
+func (r *Report) Security() []Issue {
+	var issues []Issue
+	for _, issue := range r.Issues {
+		if issue.Security {
+			issues = append(issues, issue)
+		}
+	}
+	return issues
+}
+
+// ErrNoLinters is returned when the output isn't from the script
+var ErrNoLinters = errors.New("no linter output found")
+
+// ParseOutput reads the findings from the output of Script, which may be
+// surrounded by other output
+func ParseOutput(output string) (*Report, error) {
+	report := &Report{}
+	root := ""
+	var current *Linter
+	var section bytes.Buffer
+	found := false
+	flush := func() {
+		if current == nil {
+			return
+		}
+		report.Ran = append(report.Ran, current.Name)
+		for _, issue := range current.parse(section.Bytes()) {
+			if len(report.Issues) >= MaxIssues {
+				report.Truncated = true
+				break
+			}
+			issue.Linter = current.Name
+			issue.File = relative(root, issue.File)
+			report.Issues = append(report.Issues, issue)
+		}
+		current = nil
+		section.Reset()
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimRight(line, "\r")
+		switch {
+		case strings.HasPrefix(line, rootMarker):
+			root = strings.TrimPrefix(line, rootMarker)
+		case strings.HasPrefix(line, lintMarker):
+			flush()
+			current, found = Find(strings.TrimPrefix(line, lintMarker)), true
+		case current != nil && strings.HasPrefix(line, skipMarker):
+			report.Skipped = append(report.Skipped, current.Name+": "+strings.TrimPrefix(line, skipMarker))
+			current = nil
+			section.Reset()
+		case current != nil:
+			section.WriteString(line + "\n")
+		}
+	}
+	flush()
+
+	if !found {
+		return nil, ErrNoLinters
+	}
+	return report, nil
+}
+
+// relative returns a finding's path relative to the repository's root
+func relative(root, file string) string {
+	file = strings.ReplaceAll(file, "\\", "/")
+	if root != "" {
+		file = strings.TrimPrefix(file, strings.TrimSuffix(root, "/")+"/")
+	}
+	return path.Clean(strings.TrimPrefix(file, "./"))
+}
+
+// vetLine matches a finding of go vet, or a compile error it reports
+var vetLine = regexp.MustCompile(`^(?:vet: )?(\S+\.go):(\d+)(?::(\d+))?: (.+)$`)
+
+// parseVet reads go vet's text output
+func parseVet(output []byte) []Issue {
+	var issues []Issue
+	for _, line := range strings.Split(string(output), "\n") {
+		m := vetLine.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			continue
+		}
+		lineNumber, _ := strconv.Atoi(m[2])
+		column, _ := strconv.Atoi(m[3])
+		issues = append(issues, Issue{
+			Severity: SeverityMedium,
+			File:     m[1],
+			Line:     lineNumber,
+			Column:   column,
+			Message:  m[4],
+		})
+	}
+	return issues
+}
+
+// staticcheckProblem is a line of staticcheck -f json output
+type staticcheckProblem struct {
+	Code     string `json:"code"`
+	Severity string `json:"severity"`
+	Location struct {
+		File   string `json:"file"`
+		Line   int    `json:"line"`
+		Column int    `json:"column"`
+	} `json:"location"`
+	Message string `json:"message"`
+}
+
+// parseStaticcheck reads staticcheck's JSON lines
+func parseStaticcheck(output []byte) []Issue {
+	var issues []Issue
+	for _, line := range bytes.Split(output, []byte("\n")) {
+		var problem staticcheckProblem
+		if err := json.Unmarshal(bytes.TrimSpace(line), &problem); err != nil || problem.Message == "" {
+			continue
+		}
+		severity := SeverityMedium
+		switch problem.Severity {
+		case "ignored":
+			continue
+		case "warning":
+			severity = SeverityLow
+		}
+		issues = append(issues, Issue{
+			Rule:     problem.Code,
+			Severity: severity,
+			File:     problem.Location.File,
+			Line:     problem.Location.Line,
+			Column:   problem.Location.Column,
+			Message:  problem.Message,
+		})
+	}
+	return issues
+}
+
+// eslintFile is a file's results in eslint -f json output
+type eslintFile struct {
+	FilePath string `json:"filePath"`
+	Messages []struct {
+		RuleID   string `json:"ruleId"`
+		Severity int    `json:"severity"` // 1 for warnings, 2 for errors
+		Message  string `json:"message"`
+		Line     int    `json:"line"`
+		Column   int    `json:"column"`
+	} `json:"messages"`
+}
+
+// parseESLint reads eslint's JSON output. Rules of eslint-plugin-security
+// and similar plugins are security issues.
+func parseESLint(output []byte) []Issue {
+	var files []eslintFile
+	if !decodeFrom(output, '[', &files) {
+		return nil
+	}
+	var issues []Issue
+	for _, file := range files {
+		for _, message := range file.Messages {
+			severity := SeverityMedium
+			if message.Severity < 2 {
+				severity = SeverityLow
+			}
+			issues = append(issues, Issue{
+				Rule:     message.RuleID,
+				Severity: severity,
+				File:     file.FilePath,
+				Line:     message.Line,
+				Column:   message.Column,
+				Message:  message.Message,
+				Security: strings.HasPrefix(message.RuleID, "security/") || strings.HasPrefix(message.RuleID, "no-unsanitized/"),
+			})
+		}
+	}
+	return issues
+}
+
+// gosecReport is gosec's JSON output, which gives line numbers as strings
+// that may be ranges
+type gosecReport struct {
+	Issues []struct {
+		Severity string `json:"severity"` // HIGH, MEDIUM or LOW
+		RuleID   string `json:"rule_id"`
+		Details  string `json:"details"`
+		File     string `json:"file"`
+		Line     string `json:"line"`
+		Column   string `json:"column"`
+		CWE      struct {
+			ID string `json:"id"`
+		} `json:"cwe"`
+	} `json:"Issues"`
+}
+
+// parseGosec reads gosec's JSON output. All of its findings are security
+// issues.
+func parseGosec(output []byte) []Issue {
+	var report gosecReport
+	if !decodeFrom(output, '{', &report) {
+		return nil
+	}
+	var issues []Issue
+	for _, found := range report.Issues {
+		message := found.Details
+		if found.CWE.ID != "" {
+			message += " (CWE-" + found.CWE.ID + ")"
+		}
+		severity := strings.ToLower(found.Severity)
+		if severity != SeverityHigh && severity != SeverityLow {
+			severity = SeverityMedium
+		}
+		issues = append(issues, Issue{
+			Rule:     found.RuleID,
+			Severity: severity,
+			File:     found.File,
+			Line:     leadingNumber(found.Line),
+			Column:   leadingNumber(found.Column),
+			Message:  message,
+			Security: true,
+		})
+	}
+	return issues
+}
+
+// decodeFrom decodes the JSON value starting at the first open byte,
+// ignoring anything printed before or after it
+func decodeFrom(output []byte, open byte, v any) bool {
+	start := bytes.IndexByte(output, open)
+	if start < 0 {
+		return false
+	}
+	return json.NewDecoder(bytes.NewReader(output[start:])).Decode(v) == nil
+}
+
+// leadingNumber returns the number a string like "12" or "12-14" starts
+// with, or 0
+func leadingNumber(s string) int {
+	end := 0
+	for end < len(s) && s[end] >= '0' && s[end] <= '9' {
+		end++
+	}
+	n, _ := strconv.Atoi(s[:end])
+	return n
+}
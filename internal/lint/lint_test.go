@@ -0,0 +1,154 @@
+package lint
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSelect(t *testing.T) {
+	all, err := Select(nil)
+	if err != nil || len(all) != len(Linters) {
+		t.Fatalf("Select(nil) = %d linters, %v, want all of them", len(all), err)
+	}
+	if all, _ := Select([]string{"gosec", "all"}); len(all) != len(Linters) {
+		t.Errorf("Select(all) = %d linters, want all of them", len(all))
+	}
+
+	selected, err := Select([]string{"go vet", "gosec", "vet"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(selected) != 2 || selected[0].Name != "govet" || selected[1].Name != "gosec" {
+		t.Errorf("Select(go vet, gosec, vet) = %v, want govet and gosec", selected)
+	}
+
+	if _, err := Select([]string{"pylint"}); err == nil {
+		t.Error("Select(pylint) succeeded, want an error")
+	}
+}
+
+func TestScript(t *testing.T) {
+	linters, _ := Select([]string{"govet", "eslint"})
+	script := Script(linters)
+	for _, want := range []string{
+		"echo '::lint::govet'",
+		"if [ ! -e go.mod ]; then echo '::lint-skip::no go.mod'",
+		"go vet ./... 2>&1 || true",
+		"if [ ! -e package.json ]",
+		"eslint -f json . 2>/dev/null || true",
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("script is missing %q:\n%s", want, script)
+		}
+	}
+	if strings.Contains(script, "gosec") {
+		t.Error("script runs gosec, which wasn't selected")
+	}
+	if strings.Contains(script, `"`) {
+		t.Error("script has double quotes, it can't be echoed inside them")
+	}
+}
+
+func TestParseOutput(t *testing.T) {
+	output := strings.Join([]string{
+		"Cloning into 'shop'...",
+		"::lint-root::/workspace/shop",
+		"::lint::govet",
+		"# shop/cart",
+		"cart/cart.go:12:2: fmt.Sprintf format %d has arg name of wrong type string",
+		"vet: ./main.go:40: unreachable code",
+		"",
+		"::lint::staticcheck",
+		`{"code":"SA4006","severity":"error","location":{"file":"/workspace/shop/cart/cart.go","line":20,"column":3},"message":"this value of err is never used"}`,
+		`{"code":"ST1005","severity":"warning","location":{"file":"/workspace/shop/main.go","line":5,"column":1},"message":"error strings should not be capitalized"}`,
+		`{"code":"U1000","severity":"ignored","location":{"file":"/workspace/shop/main.go","line":9,"column":1},"message":"func unused is unused"}`,
+		"",
+		"::lint::eslint",
+		"::lint-skip::no package.json",
+		"::lint::gosec",
+		`[gosec] 2024/01/01 loading packages`,
+		`{"Golang errors":{},"Issues":[{"severity":"HIGH","confidence":"LOW","cwe":{"id":"798"},"rule_id":"G101","details":"Potential hardcoded credentials","file":"/workspace/shop/config.go","code":"","line":"7-9","column":"2"},{"severity":"??","rule_id":"G104","details":"Errors unhandled.","file":"/workspace/shop/main.go","line":"30","column":"5"}],"Stats":{}}`,
+		"",
+	}, "\n")
+
+	report, err := ParseOutput(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(report.Ran, ",") != "govet,staticcheck,gosec" {
+		t.Errorf("Ran = %v, want govet, staticcheck and gosec", report.Ran)
+	}
+	if len(report.Skipped) != 1 || report.Skipped[0] != "eslint: no package.json" {
+		t.Errorf("Skipped = %v, want eslint", report.Skipped)
+	}
+
+	want := []string{
+		"cart/cart.go:12: govet: fmt.Sprintf format %d has arg name of wrong type string",
+		"main.go:40: govet: unreachable code",
+		"cart/cart.go:20: staticcheck SA4006: this value of err is never used",
+		"main.go:5: staticcheck ST1005: error strings should not be capitalized",
+		"config.go:7: gosec G101: Potential hardcoded credentials (CWE-798)",
+		"main.go:30: gosec G104: Errors unhandled.",
+	}
+	if len(report.Issues) != len(want) {
+		t.Fatalf("found %d issues, want %d: %v", len(report.Issues), len(want), report.Issues)
+	}
+	for i, issue := range report.Issues {
+		if issue.String() != want[i] {
+			t.Errorf("issue %d = %q, want %q", i, issue.String(), want[i])
+		}
+	}
+
+	if got := report.Issues[0].Column; got != 2 {
+		t.Errorf("go vet column = %d, want 2", got)
+	}
+	if got := report.Issues[3].Severity; got != SeverityLow {
+		t.Errorf("staticcheck warning severity = %s, want low", got)
+	}
+	security := report.Security()
+	if len(security) != 2 || security[0].Severity != SeverityHigh || security[1].Severity != SeverityMedium {
+		t.Errorf("Security() = %v, want both gosec findings rated high and medium", security)
+	}
+}
+
+func TestParseESLint(t *testing.T) {
+	report, err := ParseOutput("::lint-root::/src\n::lint::eslint\n" +
+		`[{"filePath":"/src/app.js","messages":[` +
+		`{"ruleId":"no-unused-vars","severity":1,"message":"'x' is defined but never used.","line":3,"column":7},` +
+		`{"ruleId":"security/detect-eval-with-expression","severity":2,"message":"eval with argument of type Identifier","line":9,"column":1}]},` +
+		`{"filePath":"/src/lib/ok.js","messages":[]}]` + "\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Issues) != 2 {
+		t.Fatalf("found %d issues, want 2: %v", len(report.Issues), report.Issues)
+	}
+	unused, eval := report.Issues[0], report.Issues[1]
+	if unused.File != "app.js" || unused.Severity != SeverityLow || unused.Security {
+		t.Errorf("unused variable = %+v, want a low severity issue in app.js", unused)
+	}
+	if eval.Severity != SeverityMedium || !eval.Security {
+		t.Errorf("eval = %+v, want a medium severity security issue", eval)
+	}
+}
+
+func TestParseOutputWithoutLinters(t *testing.T) {
+	if _, err := ParseOutput("PASS\nok  \tshop\t0.01s\n"); err != ErrNoLinters {
+		t.Errorf("ParseOutput = %v, want ErrNoLinters", err)
+	}
+}
+
+func TestMaxIssues(t *testing.T) {
+	var output strings.Builder
+	output.WriteString("::lint::govet\n")
+	for i := 0; i < MaxIssues+5; i++ {
+		output.WriteString("main.go:1:1: problem\n")
+	}
+	report, err := ParseOutput(output.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Issues) != MaxIssues || !report.Truncated {
+		t.Errorf("found %d issues, truncated %v, want %d and truncated", len(report.Issues), report.Truncated, MaxIssues)
+	}
+}
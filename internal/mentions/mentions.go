@@ -0,0 +1,49 @@
+// Package mentions finds the users mentioned by handle (@alice) in text.
+package mentions
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	pattern = regexp.MustCompile(`@([A-Za-z0-9][A-Za-z0-9_.-]*)`)
+
+	// Code isn't searched, so examples like `@Override` mention no one
+	fenced = regexp.MustCompile("(?s)```.*?(```|$)")
+	inline = regexp.MustCompile("`[^`\n]*`")
+)
+
+// Parse returns the handles mentioned in text, lowercased, without
+// repeats and in the order they first appear. Email addresses and handles
+// in Markdown code don't count.
+func Parse(s string) []string {
+	s = fenced.ReplaceAllString(s, "")
+	s = inline.ReplaceAllString(s, "")
+
+	var handles []string
+	seen := map[string]bool{}
+	for _, m := range pattern.FindAllStringSubmatchIndex(s, -1) {
+		if m[0] > 0 && !boundary(s[m[0]-1]) {
+			continue
+		}
+		// Sentences end in periods, not handles
+		handle := strings.ToLower(strings.TrimRight(s[m[2]:m[3]], ".-"))
+		if handle == "" || seen[handle] {
+			continue
+		}
+		seen[handle] = true
+		handles = append(handles, handle)
+	}
+	return handles
+}
+
+// boundary returns true if a mention may follow the character. Words,
+// email addresses and URLs don't hold mentions.
+func boundary(c byte) bool {
+	switch {
+	case c >= '0' && c <= '9', c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		return false
+	}
+	return !strings.ContainsRune("_&/@.:=?-", rune(c))
+}
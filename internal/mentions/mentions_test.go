@@ -0,0 +1,28 @@
+package mentions
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"@alice can you look?", []string{"alice"}},
+		{"Thanks @Bob and @carol.", []string{"bob", "carol"}},
+		{"(@dave) @dave, @eve-", []string{"dave", "eve"}},
+		{"@first.last reviewed it", []string{"first.last"}},
+		{"mail alice@example.com or see https://x.io/@bob", nil},
+		{"Use `@Override` here", nil},
+		{"```\n@decorator\n```\n@frank", []string{"frank"}},
+		{"a lone @ sign", nil},
+	}
+
+	for _, tt := range tests {
+		if got := Parse(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Parse(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+// Package metering reports per-user resource consumption to billing
+// systems. Hosted deployments register their own exporters alongside the
+// built-in CSV and webhook ones.
+package metering
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"workspace/models"
+)
+
+// SignatureHeader carries the HMAC-SHA256 of a webhook payload, hex
+// encoded, when the webhook has a secret
+const SignatureHeader = "X-Skyscape-Signature"
+
+// Line is one user's consumption in a report
+type Line struct {
+	UserID      string  `json:"user_id"`
+	Email       string  `json:"email"`
+	Storage     float64 `json:"storage_bytes"`
+	CIMinutes   float64 `json:"ci_minutes"`
+	AITokens    float64 `json:"ai_tokens"`
+	WorkerHours float64 `json:"worker_hours"`
+}
+
+// Report is the usage of every user over a period
+type Report struct {
+	Since time.Time `json:"since"`
+	Until time.Time `json:"until"`
+	Lines []Line    `json:"usage"`
+}
+
+// NewReport collects usage between since and until
+func NewReport(since, until time.Time) (*Report, error) {
+	summaries, err := models.GetUsageSummaries(since, until)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{Since: since, Until: until, Lines: []Line{}}
+	for _, summary := range summaries {
+		line := Line{
+			UserID:      summary.UserID,
+			Storage:     summary.Storage,
+			CIMinutes:   summary.CIMinutes,
+			AITokens:    summary.AITokens,
+			WorkerHours: summary.WorkerHours,
+		}
+		if user, err := models.Auth.Users.Get(summary.UserID); err == nil {
+			line.Email = user.Email
+		}
+		report.Lines = append(report.Lines, line)
+	}
+	return report, nil
+}
+
+// Exporter sends a usage report somewhere
+type Exporter interface {
+	Name() string
+	Export(report *Report) error
+}
+
+var (
+	registered   []Exporter
+	registeredMu sync.Mutex
+)
+
+// Register adds an exporter that receives every scheduled report
+func Register(exporter Exporter) {
+	registeredMu.Lock()
+	defer registeredMu.Unlock()
+	registered = append(registered, exporter)
+}
+
+// Registered returns the exporters added with Register
+func Registered() []Exporter {
+	registeredMu.Lock()
+	defer registeredMu.Unlock()
+	return append([]Exporter(nil), registered...)
+}
+
+// CSVExporter writes a report as CSV, one row per user
+type CSVExporter struct {
+	W io.Writer
+}
+
+// Name identifies the exporter in logs
+func (e *CSVExporter) Name() string { return "csv" }
+
+// Export writes the header and one row per user
+func (e *CSVExporter) Export(report *Report) error {
+	w := csv.NewWriter(e.W)
+	w.Write([]string{"period_start", "period_end", "user_id", "email", "storage_bytes", "ci_minutes", "ai_tokens", "worker_hours"})
+
+	since, until := report.Since.UTC().Format(time.RFC3339), report.Until.UTC().Format(time.RFC3339)
+	for _, line := range report.Lines {
+		w.Write([]string{
+			since, until, line.UserID, line.Email,
+			formatFloat(line.Storage), formatFloat(line.CIMinutes),
+			formatFloat(line.AITokens), formatFloat(line.WorkerHours),
+		})
+	}
+
+	w.Flush()
+	return w.Error()
+}
+
+// WebhookExporter posts a report as JSON. Non-2xx responses are errors so
+// the caller can retry.
+type WebhookExporter struct {
+	URL    string
+	Secret string
+	Client *http.Client
+}
+
+// Name identifies the exporter in logs
+func (e *WebhookExporter) Name() string { return "webhook" }
+
+// Export posts the report, signing it when a secret is set
+func (e *WebhookExporter) Export(report *Report) error {
+	body, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, e.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Skyscape-Metering")
+	if e.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign(body, e.Secret))
+	}
+
+	client := e.Client
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("usage webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// Sign returns the hex HMAC-SHA256 of a payload
+func Sign(payload []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
@@ -0,0 +1,80 @@
+package metering
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testReport() *Report {
+	since := time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC)
+	return &Report{
+		Since: since,
+		Until: since.AddDate(0, 1, 0),
+		Lines: []Line{
+			{UserID: "u1", Email: "alice@example.com", Storage: 2048, CIMinutes: 12.5, AITokens: 3000},
+			{UserID: "u2", Email: "bob@example.com", WorkerHours: 0.25},
+		},
+	}
+}
+
+func TestCSVExporter(t *testing.T) {
+	var buf bytes.Buffer
+	if err := (&CSVExporter{W: &buf}).Export(testReport()); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+
+	rows := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(rows) != 3 {
+		t.Fatalf("got %d rows, want 3", len(rows))
+	}
+	if !strings.HasPrefix(rows[0], "period_start,period_end,user_id") {
+		t.Errorf("unexpected header %q", rows[0])
+	}
+	want := "2026-03-01T00:00:00Z,2026-04-01T00:00:00Z,u1,alice@example.com,2048,12.5,3000,0"
+	if rows[1] != want {
+		t.Errorf("row = %q, want %q", rows[1], want)
+	}
+}
+
+func TestWebhookExporter(t *testing.T) {
+	var body []byte
+	var signature string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		signature = r.Header.Get(SignatureHeader)
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer server.Close()
+
+	exporter := &WebhookExporter{URL: server.URL, Secret: "s3cret"}
+	if err := exporter.Export(testReport()); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+	if signature != Sign(body, "s3cret") {
+		t.Errorf("signature %q does not match payload", signature)
+	}
+
+	var report Report
+	if err := json.Unmarshal(body, &report); err != nil {
+		t.Fatalf("payload is not a report: %v", err)
+	}
+	if len(report.Lines) != 2 || report.Lines[0].CIMinutes != 12.5 {
+		t.Errorf("unexpected payload %s", body)
+	}
+
+	exporter = &WebhookExporter{URL: server.URL + "/fail"}
+	if err := exporter.Export(testReport()); err == nil {
+		t.Error("expected an error for a failing webhook")
+	}
+	if signature != "" {
+		t.Errorf("unsigned webhook sent signature %q", signature)
+	}
+}
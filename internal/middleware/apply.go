@@ -1,14 +1,22 @@
 package middleware
 
 import (
-	"fmt"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
+
+	proxy "workspace/middleware"
 )
 
 // RouteRateLimiter applies different rate limits based on route patterns
 type RouteRateLimiter struct {
 	limiters map[string]*RateLimiter
+
+	// KeyFunc names the user making a request, so each user has their
+	// own limits wherever they connect from. Anonymous requests, and all
+	// when it's nil, are limited by IP address.
+	KeyFunc func(*http.Request) string
 }
 
 // Handle implements the application.Middleware interface
@@ -31,13 +39,9 @@ func (rrl *RouteRateLimiter) Middleware(next http.Handler) http.Handler {
 		
 		// Apply rate limiting
 		if limiter != nil {
-			ip := getClientIP(r)
-			if !limiter.Allow(ip) {
-				// Set rate limit headers
-				w.Header().Set("X-RateLimit-Limit", fmt.Sprintf("%d", limiter.rate))
-				w.Header().Set("X-RateLimit-Window", limiter.window.String())
-				w.Header().Set("Retry-After", fmt.Sprintf("%.0f", limiter.window.Seconds()))
-				
+			result := limiter.Take(rrl.clientKey(r))
+			setRateLimitHeaders(w, result)
+			if !result.Allowed {
 				// Return 429 Too Many Requests
 				http.Error(w, "Rate limit exceeded. Please try again later.", http.StatusTooManyRequests)
 				return
@@ -48,6 +52,28 @@ func (rrl *RouteRateLimiter) Middleware(next http.Handler) http.Handler {
 	})
 }
 
+// clientKey returns who a request is counted against: its user when
+// known, otherwise its IP address
+func (rrl *RouteRateLimiter) clientKey(r *http.Request) string {
+	if rrl.KeyFunc != nil {
+		if user := rrl.KeyFunc(r); user != "" {
+			return "user:" + user
+		}
+	}
+	return "ip:" + proxy.ClientIP(r)
+}
+
+// setRateLimitHeaders tells the client its limit, what's left of it and
+// when the window resets, and when refused how long to wait
+func setRateLimitHeaders(w http.ResponseWriter, result Result) {
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(result.Reset.Unix(), 10))
+	if !result.Allowed {
+		w.Header().Set("Retry-After", strconv.Itoa(max(1, int(math.Ceil(result.RetryAfter.Seconds())))))
+	}
+}
+
 // getLimiterForPath determines which rate limiter to use for a given path
 func (rrl *RouteRateLimiter) getLimiterForPath(path string) *RateLimiter {
 	// AI endpoints - most restrictive
@@ -104,8 +130,9 @@ func ApplyRateLimiting(handler http.Handler, config *RateLimitConfig) http.Handl
 // RateLimitedHandler wraps a handler function with rate limiting
 func RateLimitedHandler(handler http.HandlerFunc, limiter *RateLimiter) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		ip := getClientIP(r)
-		if !limiter.Allow(ip) {
+		result := limiter.Take("ip:" + proxy.ClientIP(r))
+		setRateLimitHeaders(w, result)
+		if !result.Allowed {
 			http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
 			return
 		}
@@ -1,154 +1,84 @@
 package middleware
 
 import (
+	"log"
 	"net/http"
-	"strings"
-	"sync"
+	"sync/atomic"
 	"time"
+
+	proxy "workspace/middleware"
 )
 
-// RateLimiter provides request rate limiting per IP address
+// RateLimiter limits how many requests each client makes in a sliding
+// window, counting them in a store
 type RateLimiter struct {
-	requests map[string][]time.Time
-	mu       sync.RWMutex
-	rate     int           // requests per window
-	window   time.Duration // time window
-	cleanup  time.Duration // cleanup interval
-	stopCh   chan struct{}
+	name   string        // keeps its counters apart in a shared store
+	store  Store
+	rate   int           // requests per window
+	window time.Duration // time window
 }
 
-// NewRateLimiter creates a new rate limiter
+// NewRateLimiter creates a rate limiter counting requests in memory
 func NewRateLimiter(rate int, window time.Duration) *RateLimiter {
-	rl := &RateLimiter{
-		requests: make(map[string][]time.Time),
-		rate:     rate,
-		window:   window,
-		cleanup:  window * 2,
-		stopCh:   make(chan struct{}),
-	}
-	
-	// Start cleanup goroutine
-	go rl.cleanupLoop()
-	
-	return rl
+	return NewStoreRateLimiter("", NewMemoryStore(), rate, window)
 }
 
-// cleanupLoop removes old entries periodically
-func (rl *RateLimiter) cleanupLoop() {
-	ticker := time.NewTicker(rl.cleanup)
-	defer ticker.Stop()
-	
-	for {
-		select {
-		case <-ticker.C:
-			rl.cleanupOldEntries()
-		case <-rl.stopCh:
-			return
-		}
+// NewStoreRateLimiter creates a rate limiter counting requests in a store,
+// under a name keeping its counters apart from other limiters using it
+func NewStoreRateLimiter(name string, store Store, rate int, window time.Duration) *RateLimiter {
+	return &RateLimiter{
+		name:   name,
+		store:  store,
+		rate:   rate,
+		window: window,
 	}
 }
 
-// cleanupOldEntries removes old request records
-func (rl *RateLimiter) cleanupOldEntries() {
-	rl.mu.Lock()
-	defer rl.mu.Unlock()
-	
-	cutoff := time.Now().Add(-rl.window)
-	for ip, times := range rl.requests {
-		// Remove old timestamps
-		var valid []time.Time
-		for _, t := range times {
-			if t.After(cutoff) {
-				valid = append(valid, t)
-			}
-		}
-		
-		if len(valid) == 0 {
-			delete(rl.requests, ip)
-		} else {
-			rl.requests[ip] = valid
-		}
+// Take counts a request from a client, such as a user or IP address, and
+// returns whether it's within the limit. Requests over the limit count
+// too, so clients ignoring Retry-After stay limited.
+func (rl *RateLimiter) Take(key string) Result {
+	now := time.Now()
+	start := now.Truncate(rl.window)
+	previous, current, err := rl.store.Increment(rl.name+":"+key, start, rl.window)
+	if err != nil {
+		// Better to let requests through than to turn everyone away
+		logStoreError(err)
+		return Result{Allowed: true, Limit: rl.rate, Remaining: rl.rate, Reset: start.Add(rl.window)}
 	}
+	return evaluate(rl.rate, previous, current, start, now.Sub(start), rl.window)
 }
 
-// Allow checks if a request from the given IP should be allowed
-func (rl *RateLimiter) Allow(ip string) bool {
-	rl.mu.Lock()
-	defer rl.mu.Unlock()
-	
-	now := time.Now()
-	cutoff := now.Add(-rl.window)
-	
-	// Get or create request history for this IP
-	times, exists := rl.requests[ip]
-	if !exists {
-		rl.requests[ip] = []time.Time{now}
-		return true
-	}
-	
-	// Count recent requests
-	var valid []time.Time
-	for _, t := range times {
-		if t.After(cutoff) {
-			valid = append(valid, t)
-		}
-	}
-	
-	// Check if under limit
-	if len(valid) >= rl.rate {
-		rl.requests[ip] = valid
-		return false
-	}
-	
-	// Add current request
-	valid = append(valid, now)
-	rl.requests[ip] = valid
-	return true
+// Allow checks if a request from the given client should be allowed
+func (rl *RateLimiter) Allow(key string) bool {
+	return rl.Take(key).Allowed
 }
 
 // Middleware returns an HTTP middleware for rate limiting
 func (rl *RateLimiter) Middleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Extract client IP
-		ip := getClientIP(r)
-		
-		// Check rate limit
-		if !rl.Allow(ip) {
+		// Check rate limit by client IP
+		result := rl.Take("ip:" + proxy.ClientIP(r))
+		setRateLimitHeaders(w, result)
+		if !result.Allowed {
 			http.Error(w, "Rate limit exceeded. Please try again later.", http.StatusTooManyRequests)
 			return
 		}
-		
+
 		next.ServeHTTP(w, r)
 	})
 }
 
-// Stop stops the rate limiter cleanup goroutine
-func (rl *RateLimiter) Stop() {
-	close(rl.stopCh)
-}
+// storeErrorLogged is when a store last failed in the log, so an
+// unreachable store doesn't log every request
+var storeErrorLogged atomic.Int64
 
-// getClientIP extracts the client IP from the request
-func getClientIP(r *http.Request) string {
-	// Check X-Forwarded-For header first (for proxies)
-	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
-		// Take the first IP if multiple are present
-		if comma := strings.Index(xff, ","); comma != -1 {
-			return strings.TrimSpace(xff[:comma])
-		}
-		return xff
-	}
-	
-	// Check X-Real-IP header
-	if xri := r.Header.Get("X-Real-IP"); xri != "" {
-		return xri
-	}
-	
-	// Fall back to RemoteAddr
-	if colon := strings.LastIndex(r.RemoteAddr, ":"); colon != -1 {
-		return r.RemoteAddr[:colon]
+// logStoreError logs a store failing at most once a minute
+func logStoreError(err error) {
+	now := time.Now().Unix()
+	if last := storeErrorLogged.Load(); now-last >= 60 && storeErrorLogged.CompareAndSwap(last, now) {
+		log.Printf("RateLimit: Store failed, letting requests through: %v", err)
 	}
-	
-	return r.RemoteAddr
 }
 
 // RateLimitConfig holds rate limiting configuration
@@ -172,6 +102,9 @@ type RateLimitConfig struct {
 	// General endpoints
 	GeneralRate int           // requests per minute for general pages
 	GeneralWindow time.Duration
+
+	// Where requests are counted, in memory when nil
+	Store Store
 }
 
 // DefaultRateLimitConfig returns sensible defaults for production
@@ -199,13 +132,18 @@ func DefaultRateLimitConfig() *RateLimitConfig {
 	}
 }
 
-// CreateRateLimiters creates rate limiters from config
+// CreateRateLimiters creates rate limiters from config, all counting in
+// its store
 func CreateRateLimiters(config *RateLimitConfig) map[string]*RateLimiter {
+	store := config.Store
+	if store == nil {
+		store = NewMemoryStore()
+	}
 	return map[string]*RateLimiter{
-		"api":     NewRateLimiter(config.APIRate, config.APIWindow),
-		"ai":      NewRateLimiter(config.AIRate, config.AIWindow),
-		"auth":    NewRateLimiter(config.AuthRate, config.AuthWindow),
-		"search":  NewRateLimiter(config.SearchRate, config.SearchWindow),
-		"general": NewRateLimiter(config.GeneralRate, config.GeneralWindow),
+		"api":     NewStoreRateLimiter("api", store, config.APIRate, config.APIWindow),
+		"ai":      NewStoreRateLimiter("ai", store, config.AIRate, config.AIWindow),
+		"auth":    NewStoreRateLimiter("auth", store, config.AuthRate, config.AuthWindow),
+		"search":  NewStoreRateLimiter("search", store, config.SearchRate, config.SearchWindow),
+		"general": NewStoreRateLimiter("general", store, config.GeneralRate, config.GeneralWindow),
 	}
 }
\ No newline at end of file
@@ -0,0 +1,210 @@
+package middleware
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEvaluateSlidingWindow(t *testing.T) {
+	start := time.Unix(600, 0)
+
+	// Half of the previous window still counts
+	result := evaluate(10, 10, 4, start, 30*time.Second, time.Minute)
+	if !result.Allowed || result.Remaining != 1 {
+		t.Errorf("expected allowed with 1 remaining, got %+v", result)
+	}
+	if !result.Reset.Equal(start.Add(time.Minute)) {
+		t.Errorf("expected reset at the end of the window, got %v", result.Reset)
+	}
+
+	result = evaluate(10, 10, 6, start, 30*time.Second, time.Minute)
+	if result.Allowed || result.Remaining != 0 {
+		t.Errorf("expected refusal, got %+v", result)
+	}
+	if result.RetryAfter <= 0 || result.RetryAfter > 30*time.Second {
+		t.Errorf("expected to retry within the window, got %v", result.RetryAfter)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		name                   string
+		limit, previous, count int
+		elapsed, want          time.Duration
+	}{
+		{"previous window slides out", 10, 10, 5, 0, 36 * time.Second},
+		{"current window full", 10, 0, 10, 15 * time.Second, 45*time.Second + 6*time.Second},
+		{"already room", 10, 10, 0, 59 * time.Second, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := retryAfter(tt.limit, tt.previous, tt.count, tt.elapsed, time.Minute)
+			if got.Round(time.Second) != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	store := NewMemoryStore()
+	start := time.Now().Truncate(time.Minute)
+
+	store.Increment("a", start, time.Minute)
+	previous, current, _ := store.Increment("a", start, time.Minute)
+	if previous != 0 || current != 2 {
+		t.Errorf("expected 0 and 2, got %d and %d", previous, current)
+	}
+
+	// The next window remembers this one
+	previous, current, _ = store.Increment("a", start.Add(time.Minute), time.Minute)
+	if previous != 2 || current != 1 {
+		t.Errorf("expected 2 and 1, got %d and %d", previous, current)
+	}
+
+	// A gap forgets it
+	previous, current, _ = store.Increment("a", start.Add(5*time.Minute), time.Minute)
+	if previous != 0 || current != 1 {
+		t.Errorf("expected 0 and 1, got %d and %d", previous, current)
+	}
+}
+
+func TestRouteRateLimiter(t *testing.T) {
+	limiters := CreateRateLimiters(&RateLimitConfig{
+		APIRate: 2, APIWindow: time.Hour,
+		GeneralRate: 100, GeneralWindow: time.Hour,
+	})
+	limiter := NewRouteRateLimiter(limiters)
+	limiter.KeyFunc = func(r *http.Request) string { return r.Header.Get("X-Test-User") }
+	handler := limiter.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	request := func(user string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/api/v1/repos", nil)
+		r.RemoteAddr = "10.0.0.1:1234"
+		r.Header.Set("X-Test-User", user)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	w := request("alice")
+	if w.Code != http.StatusOK || w.Header().Get("X-RateLimit-Limit") != "2" || w.Header().Get("X-RateLimit-Remaining") != "1" {
+		t.Fatalf("expected first request allowed with headers, got %d %v", w.Code, w.Header())
+	}
+	if w.Header().Get("X-RateLimit-Reset") == "" {
+		t.Error("expected X-RateLimit-Reset header")
+	}
+	request("alice")
+	w = request("alice")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Errorf("expected 429 with Retry-After, got %d %v", w.Code, w.Header())
+	}
+
+	// Another user from the same address has their own limit
+	if w := request("bob"); w.Code != http.StatusOK {
+		t.Errorf("expected another user allowed, got %d", w.Code)
+	}
+	// As do anonymous requests from it
+	if w := request(""); w.Code != http.StatusOK {
+		t.Errorf("expected anonymous request allowed, got %d", w.Code)
+	}
+}
+
+func TestRouteRateLimiterIgnoresSpoofedForwarding(t *testing.T) {
+	limiter := NewRouteRateLimiter(CreateRateLimiters(&RateLimitConfig{
+		AuthRate: 1, AuthWindow: time.Hour,
+	}))
+	handler := limiter.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	request := func(forwarded string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", "/signin", nil)
+		r.RemoteAddr = "203.0.113.7:5000"
+		r.Header.Set("X-Forwarded-For", forwarded)
+		r.Header.Set("X-Real-IP", forwarded)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	// Not from a trusted proxy, so the header is the visitor's own claim
+	r := httptest.NewRequest("POST", "/signin", nil)
+	r.RemoteAddr = "203.0.113.7:5000"
+	r.Header.Set("X-Forwarded-For", "198.51.100.1")
+	if key := limiter.clientKey(r); key != "ip:203.0.113.7" {
+		t.Errorf("expected key by remote address, got %s", key)
+	}
+
+	if w := request("198.51.100.1"); w.Code != http.StatusOK {
+		t.Fatalf("expected first sign-in allowed, got %d", w.Code)
+	}
+	if w := request("198.51.100.2"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("expected a new forwarded address to stay limited, got %d", w.Code)
+	}
+}
+
+func TestRedisStore(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("can't listen: %v", err)
+	}
+	defer listener.Close()
+
+	commands := make(chan string, 4)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		for {
+			reply, err := readRedisReply(reader)
+			if err != nil {
+				return
+			}
+			args := reply.([]any)
+			commands <- args[0].(string)
+			switch args[0] {
+			case "AUTH":
+				conn.Write([]byte("+OK\r\n"))
+			case "EVAL":
+				conn.Write([]byte("*2\r\n:3\r\n:4\r\n"))
+			}
+		}
+	}()
+
+	store, err := NewRedisStore("redis://:secret@" + listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous, current, err := store.Increment("api:user:1", time.Now().Truncate(time.Minute), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if previous != 3 || current != 4 {
+		t.Errorf("expected 3 and 4, got %d and %d", previous, current)
+	}
+	if first, second := <-commands, <-commands; first != "AUTH" || second != "EVAL" {
+		t.Errorf("expected AUTH then EVAL, got %s and %s", first, second)
+	}
+}
+
+func TestNewRedisStore(t *testing.T) {
+	store, err := NewRedisStore("redis://cache.internal/2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if store.addr != "cache.internal:6379" || store.db != 2 {
+		t.Errorf("unexpected store %+v", store)
+	}
+	for _, bad := range []string{"cache:6379", "http://cache", "redis://cache/x"} {
+		if _, err := NewRedisStore(bad); err == nil || !strings.Contains(err.Error(), "redis") {
+			t.Errorf("expected %q refused, got %v", bad, err)
+		}
+	}
+}
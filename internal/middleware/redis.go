@@ -0,0 +1,214 @@
+package middleware
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// redisTimeout bounds each command, so a slow Redis can't hold requests up
+const redisTimeout = 2 * time.Second
+
+// redisIncrementScript counts a request in the current window and reads
+// the previous one in a single round trip. Counters expire once they're
+// too old to matter.
+const redisIncrementScript = `
+local current = redis.call('INCR', KEYS[1])
+if current == 1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+local previous = tonumber(redis.call('GET', KEYS[2]) or '0')
+return {previous, current}`
+
+// RedisStore keeps counters in Redis, so replicas of the workspace share
+// their limits. It speaks just enough of the Redis protocol to run the
+// counting script.
+type RedisStore struct {
+	addr     string
+	password string
+	username string
+	db       int
+	tls      bool
+
+	mu     sync.Mutex
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// NewRedisStore creates a store for a redis:// or rediss:// URL, such as
+// redis://:password@localhost:6379/0. It connects on first use.
+func NewRedisStore(rawURL string) (*RedisStore, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "redis" && u.Scheme != "rediss") || u.Host == "" {
+		return nil, errors.New("redis address must look like redis://host:6379")
+	}
+
+	store := &RedisStore{addr: u.Host, tls: u.Scheme == "rediss"}
+	if u.Port() == "" {
+		store.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.User != nil {
+		store.username = u.User.Username()
+		store.password, _ = u.User.Password()
+	}
+	if db := strings.Trim(u.Path, "/"); db != "" {
+		if store.db, err = strconv.Atoi(db); err != nil {
+			return nil, fmt.Errorf("redis database must be a number, not %q", db)
+		}
+	}
+	return store, nil
+}
+
+// Increment adds a request to key's counter
+func (s *RedisStore) Increment(key string, start time.Time, window time.Duration) (int, int, error) {
+	reply, err := s.do("EVAL", redisIncrementScript, "2",
+		redisCounterKey(key, start),
+		redisCounterKey(key, start.Add(-window)),
+		strconv.FormatInt((2*window).Milliseconds(), 10))
+	if err != nil {
+		return 0, 0, err
+	}
+	counts, ok := reply.([]any)
+	if !ok || len(counts) != 2 {
+		return 0, 0, fmt.Errorf("unexpected reply from redis: %v", reply)
+	}
+	previous, _ := counts[0].(int64)
+	current, _ := counts[1].(int64)
+	return int(previous), int(current), nil
+}
+
+// redisCounterKey names a key's counter for the window starting at start
+func redisCounterKey(key string, start time.Time) string {
+	return "ratelimit:" + key + ":" + strconv.FormatInt(start.UnixMilli(), 10)
+}
+
+// do sends a command and reads its reply, connecting first if needed. A
+// failed connection is dropped so the next command connects again.
+func (s *RedisStore) do(args ...string) (any, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		if err := s.connect(); err != nil {
+			return nil, err
+		}
+	}
+	reply, err := s.roundTrip(args)
+	var redisErr redisError
+	if err != nil && !errors.As(err, &redisErr) {
+		s.conn.Close()
+		s.conn = nil
+	}
+	return reply, err
+}
+
+// connect dials Redis, signs in and selects the database
+func (s *RedisStore) connect() error {
+	dialer := &net.Dialer{Timeout: redisTimeout}
+	var conn net.Conn
+	var err error
+	if s.tls {
+		host, _, _ := net.SplitHostPort(s.addr)
+		conn, err = tls.DialWithDialer(dialer, "tcp", s.addr, &tls.Config{ServerName: host})
+	} else {
+		conn, err = dialer.Dial("tcp", s.addr)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to redis: %w", err)
+	}
+	s.conn, s.reader = conn, bufio.NewReader(conn)
+
+	var setup [][]string
+	if s.password != "" {
+		if s.username != "" {
+			setup = append(setup, []string{"AUTH", s.username, s.password})
+		} else {
+			setup = append(setup, []string{"AUTH", s.password})
+		}
+	}
+	if s.db != 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(s.db)})
+	}
+	for _, command := range setup {
+		if _, err := s.roundTrip(command); err != nil {
+			conn.Close()
+			s.conn = nil
+			return fmt.Errorf("redis refused %s: %w", command[0], err)
+		}
+	}
+	return nil
+}
+
+// roundTrip writes a command as an array of bulk strings and reads the reply
+func (s *RedisStore) roundTrip(args []string) (any, error) {
+	s.conn.SetDeadline(time.Now().Add(redisTimeout))
+
+	var command strings.Builder
+	fmt.Fprintf(&command, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&command, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := io.WriteString(s.conn, command.String()); err != nil {
+		return nil, err
+	}
+	return readRedisReply(s.reader)
+}
+
+// redisError is an error Redis replied with, after which the connection
+// is still usable
+type redisError string
+
+func (e redisError) Error() string { return string(e) }
+
+// readRedisReply reads one reply: a string, integer, bulk string, array
+// of replies, nil or error
+func readRedisReply(r *bufio.Reader) (any, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("empty reply from redis")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil || size < 0 {
+			return nil, err
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		return string(data[:size]), nil
+	case '*':
+		count, err := strconv.Atoi(line[1:])
+		if err != nil || count < 0 {
+			return nil, err
+		}
+		items := make([]any, count)
+		for i := range items {
+			if items[i], err = readRedisReply(r); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("unexpected reply from redis: %q", line)
+}
@@ -0,0 +1,124 @@
+package middleware
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Store keeps the request counters rate limits are enforced with. Limits
+// use a sliding window: the count of the current fixed window plus the
+// previous window's, weighted by how much of it the sliding window still
+// covers. Stores only count, so one kept outside the process lets limits
+// survive restarts and be shared by replicas.
+type Store interface {
+	// Increment adds a request to key's counter for the window starting
+	// at start, returning the previous window's count and the current one
+	// including this request
+	Increment(key string, start time.Time, window time.Duration) (previous, current int, err error)
+}
+
+// Result is the outcome of counting a request against a limit
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Time     // When the current window ends
+	RetryAfter time.Duration // How long to wait when not allowed
+}
+
+// evaluate applies a limit to a key's counts, elapsed into the window
+// starting at start
+func evaluate(limit, previous, current int, start time.Time, elapsed, window time.Duration) Result {
+	count := float64(previous)*(1-float64(elapsed)/float64(window)) + float64(current)
+	result := Result{
+		Allowed:   count <= float64(limit),
+		Limit:     limit,
+		Remaining: max(0, int(math.Floor(float64(limit)-count))),
+		Reset:     start.Add(window),
+	}
+	if !result.Allowed {
+		result.RetryAfter = retryAfter(limit, previous, current, elapsed, window)
+	}
+	return result
+}
+
+// retryAfter returns how long until the sliding window has room for
+// another request, with no more arriving meanwhile
+func retryAfter(limit, previous, current int, elapsed, window time.Duration) time.Duration {
+	room := float64(limit - 1)
+	w := float64(window)
+
+	// The previous window slides out of view while this one has room
+	if previous > 0 && float64(current) <= room {
+		wait := w*(1-(room-float64(current))/float64(previous)) - float64(elapsed)
+		return max(time.Duration(wait), 0)
+	}
+
+	// Otherwise this window becomes the previous one and must slide too
+	wait := window - elapsed
+	if current > 0 && float64(current) > room {
+		wait += time.Duration(w * (1 - room/float64(current)))
+	}
+	return wait
+}
+
+// memorySweepInterval is how often counters of past windows are dropped
+const memorySweepInterval = time.Minute
+
+// MemoryStore keeps counters in memory, so limits reset when the workspace
+// restarts and aren't shared with other replicas
+type MemoryStore struct {
+	mu       sync.Mutex
+	counters map[string]*memoryCounter
+	swept    time.Time
+}
+
+// memoryCounter counts a key's requests in its latest two windows
+type memoryCounter struct {
+	start    time.Time
+	window   time.Duration
+	previous int
+	current  int
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{counters: make(map[string]*memoryCounter), swept: time.Now()}
+}
+
+// Increment adds a request to key's counter
+func (s *MemoryStore) Increment(key string, start time.Time, window time.Duration) (int, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	counter, ok := s.counters[key]
+	if !ok {
+		counter = &memoryCounter{start: start, window: window}
+		s.counters[key] = counter
+	}
+	if !counter.start.Equal(start) {
+		// A new window, which follows on from the last or doesn't
+		counter.previous = 0
+		if counter.start.Add(window).Equal(start) {
+			counter.previous = counter.current
+		}
+		counter.start, counter.window, counter.current = start, window, 0
+	}
+	counter.current++
+
+	if now := time.Now(); now.Sub(s.swept) > memorySweepInterval {
+		s.sweep(now)
+	}
+	return counter.previous, counter.current, nil
+}
+
+// sweep drops counters that can no longer affect a limit
+func (s *MemoryStore) sweep(now time.Time) {
+	for key, counter := range s.counters {
+		if now.After(counter.start.Add(2 * counter.window)) {
+			delete(s.counters, key)
+		}
+	}
+	s.swept = now
+}
@@ -0,0 +1,121 @@
+package migration
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OffsetError is returned when the receiver expected a chunk at another
+// offset, such as after a send that failed on the way back
+type OffsetError struct {
+	Expected int64
+}
+
+func (e *OffsetError) Error() string {
+	return fmt.Sprintf("receiver expected offset %d", e.Expected)
+}
+
+// Client sends items to a receiving workspace
+type Client struct {
+	baseURL  string
+	inviteID string
+	key      []byte
+	http     *http.Client
+	now      func() time.Time
+}
+
+// NewClient returns a client for the workspace at baseURL, sealing requests
+// with the token it issued
+func NewClient(baseURL, token string) (*Client, error) {
+	u, err := url.Parse(strings.TrimSpace(baseURL))
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("%q is not a workspace URL", baseURL)
+	}
+	inviteID, key, err := ParseToken(token)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{
+		baseURL:  strings.TrimSuffix(u.String(), "/"),
+		inviteID: inviteID,
+		key:      key,
+		http:     &http.Client{Timeout: 5 * time.Minute},
+		now:      time.Now,
+	}, nil
+}
+
+// Status asks what the receiver already has
+func (c *Client) Status() (*Status, error) {
+	var status Status
+	if err := c.do(StatusPath, Request{}, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// Send delivers part of an item starting at offset. The receiver imports
+// the item once it gets the final part.
+func (c *Client) Send(item string, offset int64, data []byte, final bool) error {
+	return c.do(ItemsPath+item, Request{Item: item, Offset: offset, Final: final, Body: data}, nil)
+}
+
+// SendJSON delivers a whole item encoded as JSON
+func (c *Client) SendJSON(item string, value any) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return c.Send(item, 0, data, true)
+}
+
+// do seals and sends a request, decoding the response into result
+func (c *Client) do(path string, req Request, result any) error {
+	sentAt := c.now().Unix()
+	sealed, err := Seal(c.key, req.aad(sentAt), req.Body)
+	if err != nil {
+		return err
+	}
+
+	query := url.Values{"t": {strconv.FormatInt(sentAt, 10)}}
+	if req.Offset > 0 {
+		query.Set("offset", strconv.FormatInt(req.Offset, 10))
+	}
+	if req.Final {
+		query.Set("final", "1")
+	}
+	httpReq, err := http.NewRequest(http.MethodPost, c.baseURL+path+"?"+query.Encode(), bytes.NewReader(sealed))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set(InviteHeader, c.inviteID)
+	httpReq.Header.Set("Content-Type", "application/octet-stream")
+
+	resp, err := c.http.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusConflict:
+		var receipt Receipt
+		if err := json.NewDecoder(resp.Body).Decode(&receipt); err != nil {
+			return errors.New("receiver rejected the offset")
+		}
+		return &OffsetError{Expected: receipt.Offset}
+	case resp.StatusCode != http.StatusOK:
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("receiver returned %s: %s", resp.Status, strings.TrimSpace(string(message)))
+	case result != nil:
+		return json.NewDecoder(resp.Body).Decode(result)
+	}
+	return nil
+}
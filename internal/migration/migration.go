@@ -0,0 +1,165 @@
+// Package migration moves a workspace's data to another workspace over
+// HTTP. The receiving workspace issues a one-time token; every request the
+// sending workspace makes is sealed with a key derived from it, so the data,
+// secrets included, is authenticated and encrypted even without TLS.
+package migration
+
+import (
+	"cmp"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Paths on the receiving workspace
+const (
+	StatusPath = "/migration/receive/status"
+	ItemsPath  = "/migration/receive/items/"
+)
+
+// InviteHeader names the invite a request is sealed for
+const InviteHeader = "X-Migration-Invite"
+
+// ChunkSize is how much of a repository archive is sent per request
+const ChunkSize = 4 << 20
+
+// MaxRequestSize bounds a request body, a sealed chunk or batch of records
+const MaxRequestSize = 64 << 20
+
+// MaxClockSkew is how far a request's timestamp may be from the receiver's
+// clock, which keeps captured requests from being replayed later
+const MaxClockSkew = 5 * time.Minute
+
+// ErrTokenFormat is returned for tokens not issued by a receiving workspace
+var ErrTokenFormat = errors.New("invalid migration token")
+
+// Receipt is how much of an item the receiving workspace has
+type Receipt struct {
+	Offset int64 `json:"offset"` // Bytes received so far
+	Done   bool  `json:"done"`   // Whether the item was imported
+}
+
+// Status is what the receiving workspace has so far, so a transfer can pick
+// up where it stopped
+type Status struct {
+	Workspace string             `json:"workspace"` // The receiver's name
+	Items     map[string]Receipt `json:"items"`
+}
+
+// NewToken returns a token for an invite and the key requests are sealed
+// with. The token is shown to the admin once; only the key is kept.
+func NewToken(inviteID string) (token string, key []byte, err error) {
+	secret := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, secret); err != nil {
+		return "", nil, err
+	}
+	token = inviteID + "." + hex.EncodeToString(secret)
+	_, key, err = ParseToken(token)
+	return token, key, err
+}
+
+// ParseToken splits a token into its invite ID and sealing key
+func ParseToken(token string) (inviteID string, key []byte, err error) {
+	inviteID, secret, ok := strings.Cut(strings.TrimSpace(token), ".")
+	if !ok || inviteID == "" || len(secret) != 64 {
+		return "", nil, ErrTokenFormat
+	}
+	if _, err := hex.DecodeString(secret); err != nil {
+		return "", nil, ErrTokenFormat
+	}
+	sum := sha256.Sum256([]byte("skyscape-migration:" + secret))
+	return inviteID, sum[:], nil
+}
+
+// Seal encrypts and authenticates data, binding it to aad so it can't be
+// replayed as another request
+func Seal(key []byte, aad string, data []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, data, []byte(aad)), nil
+}
+
+// Open decrypts data sealed with the same key and aad
+func Open(key []byte, aad string, sealed []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("sealed data is too short")
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	data, err := gcm.Open(nil, nonce, ciphertext, []byte(aad))
+	if err != nil {
+		return nil, errors.New("request was not sealed with this invite's token")
+	}
+	return data, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Request is one authenticated request from the sending workspace
+type Request struct {
+	Item   string // The item, or empty for a status request
+	Offset int64  // Where Body starts in the item
+	Final  bool   // Whether Body ends the item
+	Body   []byte
+}
+
+// aad binds a sealed body to the request it was sent in
+func (req Request) aad(sentAt int64) string {
+	return fmt.Sprintf("%s\n%d\n%t\n%d", req.Item, req.Offset, req.Final, sentAt)
+}
+
+// ReadRequest opens a request sealed by Client, rejecting any that were
+// tampered with, sealed with another token or sent too long ago
+func ReadRequest(r *http.Request, key []byte) (Request, error) {
+	query := r.URL.Query()
+	req := Request{Item: strings.TrimPrefix(r.URL.Path, ItemsPath), Final: query.Get("final") == "1"}
+	if r.URL.Path == StatusPath {
+		req.Item = ""
+	}
+
+	var err error
+	if req.Offset, err = strconv.ParseInt(cmp.Or(query.Get("offset"), "0"), 10, 64); err != nil || req.Offset < 0 {
+		return req, errors.New("invalid offset")
+	}
+	sentAt, err := strconv.ParseInt(query.Get("t"), 10, 64)
+	if err != nil {
+		return req, errors.New("missing request time")
+	}
+	if skew := time.Since(time.Unix(sentAt, 0)); skew > MaxClockSkew || skew < -MaxClockSkew {
+		return req, fmt.Errorf("request time is %s off, check both clocks", skew.Round(time.Second))
+	}
+
+	sealed, err := io.ReadAll(io.LimitReader(r.Body, MaxRequestSize+1))
+	if err != nil {
+		return req, err
+	}
+	if len(sealed) > MaxRequestSize {
+		return req, errors.New("request is too large")
+	}
+	req.Body, err = Open(key, req.aad(sentAt), sealed)
+	return req, err
+}
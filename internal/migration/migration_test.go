@@ -0,0 +1,166 @@
+package migration
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestToken(t *testing.T) {
+	token, key, err := NewToken("invite-1")
+	if err != nil {
+		t.Fatalf("NewToken: %v", err)
+	}
+	inviteID, parsed, err := ParseToken(" " + token + "\n")
+	if err != nil {
+		t.Fatalf("ParseToken: %v", err)
+	}
+	if inviteID != "invite-1" || !bytes.Equal(parsed, key) {
+		t.Errorf("ParseToken returned %q and another key", inviteID)
+	}
+
+	for _, token := range []string{"", "invite-1", ".abc", "invite-1.not-hex", "invite-1." + strings.Repeat("z", 64)} {
+		if _, _, err := ParseToken(token); !errors.Is(err, ErrTokenFormat) {
+			t.Errorf("ParseToken(%q) = %v, want ErrTokenFormat", token, err)
+		}
+	}
+}
+
+func TestSeal(t *testing.T) {
+	_, key, _ := NewToken("invite-1")
+	_, other, _ := NewToken("invite-2")
+
+	sealed, err := Seal(key, "users", []byte("secret"))
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+	if bytes.Contains(sealed, []byte("secret")) {
+		t.Error("sealed data contains the plaintext")
+	}
+	if data, err := Open(key, "users", sealed); err != nil || string(data) != "secret" {
+		t.Errorf("Open = %q, %v", data, err)
+	}
+	if _, err := Open(other, "users", sealed); err == nil {
+		t.Error("Open accepted data sealed with another key")
+	}
+	if _, err := Open(key, "settings", sealed); err == nil {
+		t.Error("Open accepted data sealed for another request")
+	}
+}
+
+// receiver is a minimal receiving workspace that keeps items in memory
+type receiver struct {
+	key   []byte
+	items map[string][]byte
+	done  map[string]bool
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get(InviteHeader) != "invite-1" {
+		http.Error(w, "unknown invite", http.StatusUnauthorized)
+		return
+	}
+	req, err := ReadRequest(r, rc.key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	if req.Item == "" {
+		status := Status{Workspace: "target", Items: map[string]Receipt{}}
+		for item, data := range rc.items {
+			status.Items[item] = Receipt{Offset: int64(len(data)), Done: rc.done[item]}
+		}
+		json.NewEncoder(w).Encode(status)
+		return
+	}
+
+	if have := int64(len(rc.items[req.Item])); req.Offset != have {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(Receipt{Offset: have})
+		return
+	}
+	rc.items[req.Item] = append(rc.items[req.Item], req.Body...)
+	rc.done[req.Item] = req.Final
+}
+
+func TestClient(t *testing.T) {
+	token, key, _ := NewToken("invite-1")
+	rc := &receiver{key: key, items: map[string][]byte{}, done: map[string]bool{}}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	client, err := NewClient(server.URL+"/", token)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+
+	if err := client.SendJSON("users", []string{"ada", "grace"}); err != nil {
+		t.Fatalf("SendJSON: %v", err)
+	}
+	if err := client.Send("archive/r1", 0, []byte("abc"), false); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	// A chunk sent again after a lost response is refused with the offset
+	// the receiver wants next
+	err = client.Send("archive/r1", 0, []byte("abc"), false)
+	var offsetErr *OffsetError
+	if !errors.As(err, &offsetErr) || offsetErr.Expected != 3 {
+		t.Fatalf("resend returned %v, want an OffsetError for 3", err)
+	}
+	if err := client.Send("archive/r1", 3, []byte("def"), true); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	status, err := client.Status()
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if receipt := status.Items["archive/r1"]; receipt.Offset != 6 || !receipt.Done {
+		t.Errorf("archive receipt = %+v", receipt)
+	}
+	if string(rc.items["users"]) != `["ada","grace"]` {
+		t.Errorf("users = %s", rc.items["users"])
+	}
+
+	// Requests sealed with another token, or sent long ago, are refused
+	wrong, _, _ := NewToken("invite-1")
+	client, _ = NewClient(server.URL, wrong)
+	if _, err := client.Status(); err == nil {
+		t.Error("receiver accepted a request sealed with another token")
+	}
+	client, _ = NewClient(server.URL, token)
+	client.now = func() time.Time { return time.Now().Add(-time.Hour) }
+	if _, err := client.Status(); err == nil {
+		t.Error("receiver accepted a request from an hour ago")
+	}
+}
+
+func TestArchive(t *testing.T) {
+	src := t.TempDir()
+	os.MkdirAll(filepath.Join(src, "refs", "heads"), 0755)
+	os.WriteFile(filepath.Join(src, "HEAD"), []byte("ref: refs/heads/main\n"), 0644)
+	os.WriteFile(filepath.Join(src, "refs", "heads", "main"), []byte("0123abcd\n"), 0644)
+
+	var archive bytes.Buffer
+	if err := ArchiveDir(src, &archive); err != nil {
+		t.Fatalf("ArchiveDir: %v", err)
+	}
+
+	dst := t.TempDir()
+	if err := ExtractArchive(&archive, dst); err != nil {
+		t.Fatalf("ExtractArchive: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dst, "refs", "heads", "main"))
+	if err != nil || string(data) != "0123abcd\n" {
+		t.Errorf("extracted ref = %q, %v", data, err)
+	}
+}
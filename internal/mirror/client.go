@@ -0,0 +1,220 @@
+// Package mirror creates the repositories that workspace repositories are
+// mirrored to in a GitHub organization or GitLab group, and builds the URLs
+// they are pushed to.
+package mirror
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"workspace/models"
+)
+
+const (
+	githubAPIURL     = "https://api.github.com"
+	defaultGitLabURL = "https://gitlab.com"
+)
+
+// errNotFound is returned when the API has no such organization or repository
+var errNotFound = errors.New("not found")
+
+// Target is the organization repositories are mirrored to
+type Target struct {
+	Provider string // models.MirrorGitHub or models.MirrorGitLab
+	BaseURL  string // Self-hosted GitLab server, empty for gitlab.com
+	Org      string // Organization, or GitLab group path
+	Token    string
+}
+
+// Client creates mirror repositories in the target organization
+type Client struct {
+	target Target
+	apiURL string
+	client *http.Client
+}
+
+// NewClient creates a client for the target
+func NewClient(target Target) (*Client, error) {
+	if target.Org == "" {
+		return nil, errors.New("organization is required")
+	}
+	if target.Token == "" {
+		return nil, errors.New("access token is required")
+	}
+
+	c := &Client{target: target, client: &http.Client{Timeout: 30 * time.Second}}
+	switch target.Provider {
+	case models.MirrorGitHub:
+		c.apiURL = githubAPIURL
+	case models.MirrorGitLab:
+		base, err := url.Parse(cmp.Or(target.BaseURL, defaultGitLabURL))
+		if err != nil || base.Scheme != "https" || base.Host == "" {
+			return nil, errors.New("GitLab server must be an https URL")
+		}
+		c.apiURL = strings.TrimRight(base.String(), "/") + "/api/v4"
+	default:
+		return nil, fmt.Errorf("unsupported mirror provider %q", target.Provider)
+	}
+	return c, nil
+}
+
+// Check confirms the token can see the organization
+func (c *Client) Check() error {
+	var err error
+	if c.target.Provider == models.MirrorGitHub {
+		err = c.do("GET", "/orgs/"+url.PathEscape(c.target.Org), nil, nil)
+	} else {
+		_, err = c.gitlabGroupID()
+	}
+	if errors.Is(err, errNotFound) {
+		return fmt.Errorf("organization %s not found or not visible to the token", c.target.Org)
+	}
+	return err
+}
+
+// EnsureRepository creates a private repository called name in the
+// organization unless it already exists, and returns its web address
+func (c *Client) EnsureRepository(name, description string) (string, error) {
+	if c.target.Provider == models.MirrorGitHub {
+		return c.ensureGitHubRepository(name, description)
+	}
+	return c.ensureGitLabProject(name, description)
+}
+
+// ensureGitHubRepository creates the repository in the GitHub organization
+func (c *Client) ensureGitHubRepository(name, description string) (string, error) {
+	var repo struct {
+		HTMLURL string `json:"html_url"`
+	}
+
+	path := fmt.Sprintf("/repos/%s/%s", url.PathEscape(c.target.Org), url.PathEscape(name))
+	err := c.do("GET", path, nil, &repo)
+	if errors.Is(err, errNotFound) {
+		err = c.do("POST", "/orgs/"+url.PathEscape(c.target.Org)+"/repos", map[string]any{
+			"name":        name,
+			"description": description,
+			"private":     true,
+		}, &repo)
+	}
+	if err != nil {
+		return "", err
+	}
+	return repo.HTMLURL, nil
+}
+
+// ensureGitLabProject creates the project in the GitLab group
+func (c *Client) ensureGitLabProject(name, description string) (string, error) {
+	var project struct {
+		WebURL string `json:"web_url"`
+	}
+
+	err := c.do("GET", "/projects/"+url.PathEscape(c.target.Org+"/"+name), nil, &project)
+	if errors.Is(err, errNotFound) {
+		var groupID int
+		if groupID, err = c.gitlabGroupID(); err != nil {
+			return "", err
+		}
+		err = c.do("POST", "/projects", map[string]any{
+			"name":         name,
+			"path":         name,
+			"namespace_id": groupID,
+			"description":  description,
+			"visibility":   "private",
+		}, &project)
+	}
+	if err != nil {
+		return "", err
+	}
+	return project.WebURL, nil
+}
+
+// gitlabGroupID returns the ID of the target GitLab group
+func (c *Client) gitlabGroupID() (int, error) {
+	var group struct {
+		ID int `json:"id"`
+	}
+	if err := c.do("GET", "/groups/"+url.PathEscape(c.target.Org), nil, &group); err != nil {
+		return 0, err
+	}
+	return group.ID, nil
+}
+
+// PushURL returns the address to push a mirror at webURL to, with the
+// token in it
+func (c *Client) PushURL(webURL string) (string, error) {
+	u, err := url.Parse(webURL)
+	if err != nil || u.Host == "" {
+		return "", fmt.Errorf("invalid mirror URL %q", webURL)
+	}
+
+	user := "x-access-token"
+	if c.target.Provider == models.MirrorGitLab {
+		user = "oauth2"
+	}
+	u.User = url.UserPassword(user, c.target.Token)
+	u.Path = strings.TrimSuffix(u.Path, "/") + ".git"
+	return u.String(), nil
+}
+
+// do performs an authenticated API request, encoding body and decoding the
+// JSON response into v when they are set
+func (c *Client) do(method, path string, body, v any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, c.apiURL+path, reader)
+	if err != nil {
+		return err
+	}
+	if c.target.Provider == models.MirrorGitHub {
+		req.Header.Set("Authorization", "Bearer "+c.target.Token)
+		req.Header.Set("Accept", "application/vnd.github+json")
+	} else {
+		req.Header.Set("PRIVATE-TOKEN", c.target.Token)
+		req.Header.Set("Accept", "application/json")
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("network error: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+	case resp.StatusCode == http.StatusNotFound:
+		return errNotFound
+	case resp.StatusCode == http.StatusUnauthorized:
+		return errors.New("mirror access token was rejected")
+	case resp.StatusCode == http.StatusForbidden:
+		return errors.New("mirror access token lacks permission for this operation")
+	default:
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("mirror API error (status %d): %s", resp.StatusCode, string(data))
+	}
+
+	if v == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode mirror API response: %w", err)
+	}
+	return nil
+}
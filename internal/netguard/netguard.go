@@ -0,0 +1,103 @@
+// Package netguard keeps requests that users aim at URLs of their choosing,
+// such as webhooks, away from the workspace's own network: loopback,
+// private and link-local addresses, cloud metadata services among them.
+package netguard
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// maxRedirects is how many redirects a guarded client follows
+const maxRedirects = 5
+
+// Allowed reports whether an address is on the public internet
+func Allowed(ip net.IP) bool {
+	return !(ip.IsLoopback() ||
+		ip.IsPrivate() ||
+		ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast() ||
+		ip.IsUnspecified())
+}
+
+// CheckURL returns an error unless a URL is http or https on a public
+// host. Hostnames are resolved when possible, but as DNS can change
+// before the request is made, clients must still dial through Client.
+func CheckURL(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return errors.New("only http and https URLs are allowed")
+	}
+	host := u.Hostname()
+	if host == "" {
+		return errors.New("the URL has no host")
+	}
+	if strings.EqualFold(host, "localhost") || strings.HasSuffix(strings.ToLower(host), ".localhost") {
+		return fmt.Errorf("%s is a local address", host)
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		if !Allowed(ip) {
+			return fmt.Errorf("%s is a local or private address", host)
+		}
+		return nil
+	}
+	// Unresolvable hosts are left to fail when dialled
+	ips, _ := net.LookupIP(host)
+	for _, ip := range ips {
+		if !Allowed(ip) {
+			return fmt.Errorf("%s resolves to the local or private address %s", host, ip)
+		}
+	}
+	return nil
+}
+
+// Client returns an HTTP client that refuses to connect to addresses that
+// aren't Allowed, checking each address as it's dialled so a hostname
+// can't be rebound to one after it was checked, and refuses redirects to
+// them too. It ignores proxy settings, since the proxy would be what's
+// checked.
+func Client(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout, Control: control}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   timeout,
+			ResponseHeaderTimeout: timeout,
+			MaxIdleConns:          10,
+			IdleConnTimeout:       90 * time.Second,
+		},
+		CheckRedirect: checkRedirect,
+	}
+}
+
+// control refuses connections to addresses that aren't Allowed
+func control(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !Allowed(ip) {
+		return fmt.Errorf("connecting to %s is not allowed", host)
+	}
+	return nil
+}
+
+// checkRedirect applies CheckURL to each redirect
+func checkRedirect(next *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return errors.New("too many redirects")
+	}
+	if err := CheckURL(next.URL); err != nil {
+		return fmt.Errorf("redirect refused: %w", err)
+	}
+	return nil
+}
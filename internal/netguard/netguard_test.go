@@ -0,0 +1,91 @@
+package netguard
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestAllowed(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1::", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"fd00::1", false},
+		{"0.0.0.0", false},
+		{"::ffff:127.0.0.1", false},
+	}
+	for _, tt := range tests {
+		if got := Allowed(net.ParseIP(tt.ip)); got != tt.want {
+			t.Errorf("Allowed(%s) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	tests := []struct {
+		url string
+		ok  bool
+	}{
+		{"https://93.184.216.34/hook", true},
+		{"ftp://93.184.216.34/hook", false},
+		{"http://127.0.0.1:8080/", false},
+		{"http://169.254.169.254/latest/meta-data/", false},
+		{"http://[::1]/", false},
+		{"http://localhost/", false},
+		{"http://api.localhost/", false},
+	}
+	for _, tt := range tests {
+		u, _ := url.Parse(tt.url)
+		if err := CheckURL(u); (err == nil) != tt.ok {
+			t.Errorf("CheckURL(%s) = %v, want ok %v", tt.url, err, tt.ok)
+		}
+	}
+}
+
+func TestClientRefusesLocalAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("internal"))
+	}))
+	defer server.Close()
+
+	// Dialled by address, as a rebound hostname would be
+	resp, err := Client(time.Second).Get(server.URL)
+	if err == nil {
+		resp.Body.Close()
+		t.Fatal("expected connecting to a loopback server to be refused")
+	}
+}
+
+func TestClientRefusesLocalRedirects(t *testing.T) {
+	client := Client(time.Second)
+	from := httptest.NewRequest("GET", "https://93.184.216.34/hook", nil)
+
+	for _, target := range []string{"http://169.254.169.254/latest/meta-data/", "http://localhost/admin"} {
+		next := httptest.NewRequest("GET", target, nil)
+		if err := client.CheckRedirect(next, []*http.Request{from}); err == nil {
+			t.Errorf("expected redirect to %s refused", target)
+		}
+	}
+
+	next := httptest.NewRequest("GET", "https://93.184.216.34/moved", nil)
+	if err := client.CheckRedirect(next, []*http.Request{from}); err != nil {
+		t.Errorf("expected redirect to a public address allowed, got %v", err)
+	}
+	via := make([]*http.Request, maxRedirects)
+	if err := client.CheckRedirect(next, via); err == nil {
+		t.Error("expected too many redirects refused")
+	}
+}
@@ -0,0 +1,294 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+)
+
+// clockSkew is how far the provider's clock may be from ours
+const clockSkew = time.Minute
+
+// keyRefresh is the soonest keys are fetched again for an unknown key ID,
+// so forged tokens can't make every sign-in fetch them
+const keyRefresh = 5 * time.Minute
+
+// Claims are what an ID token says about the user
+type Claims struct {
+	Issuer            string
+	Subject           string // Identifies the user at the provider, never reused
+	Email             string
+	EmailVerified     bool
+	Name              string
+	PreferredUsername string
+	Expiry            time.Time
+
+	raw map[string]any
+}
+
+// Strings returns a claim that lists strings, such as "groups", or that
+// is a single string
+func (c *Claims) Strings(name string) []string {
+	switch value := c.raw[name].(type) {
+	case string:
+		return []string{value}
+	case []any:
+		var list []string
+		for _, item := range value {
+			if s, ok := item.(string); ok {
+				list = append(list, s)
+			}
+		}
+		return list
+	}
+	return nil
+}
+
+// VerifyIDToken checks an ID token was signed by the provider for the
+// client, hasn't expired and carries the nonce, and returns its claims
+func (p *Provider) VerifyIDToken(ctx context.Context, clientID, idToken, nonce string) (*Claims, error) {
+	parts := strings.Split(idToken, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("ID token is malformed")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("ID token header is malformed: %w", err)
+	}
+	if len(p.Algorithms) > 0 && !slices.Contains(p.Algorithms, header.Alg) {
+		return nil, fmt.Errorf("provider doesn't sign ID tokens with %s", header.Alg)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("ID token signature is malformed")
+	}
+	key, err := p.key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySignature(header.Alg, key, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, err
+	}
+
+	raw := map[string]any{}
+	if err := decodeSegment(parts[1], &raw); err != nil {
+		return nil, fmt.Errorf("ID token claims are malformed: %w", err)
+	}
+	claims := &Claims{raw: raw}
+	claims.Issuer, _ = raw["iss"].(string)
+	claims.Subject, _ = raw["sub"].(string)
+	claims.Email, _ = raw["email"].(string)
+	claims.Name, _ = raw["name"].(string)
+	claims.PreferredUsername, _ = raw["preferred_username"].(string)
+	switch verified := raw["email_verified"].(type) {
+	case bool:
+		claims.EmailVerified = verified
+	case string: // Some providers send "true"
+		claims.EmailVerified = verified == "true"
+	}
+	if exp, ok := raw["exp"].(float64); ok {
+		claims.Expiry = time.Unix(int64(exp), 0)
+	}
+
+	now := time.Now()
+	switch {
+	case claims.Issuer != p.Issuer:
+		return nil, fmt.Errorf("ID token was issued by %s, not %s", claims.Issuer, p.Issuer)
+	case claims.Subject == "":
+		return nil, errors.New("ID token has no subject")
+	case !slices.Contains(claims.Strings("aud"), clientID):
+		return nil, errors.New("ID token was issued to another client")
+	case claims.Expiry.IsZero() || now.After(claims.Expiry.Add(clockSkew)):
+		return nil, errors.New("ID token has expired")
+	}
+	if iat, ok := raw["iat"].(float64); ok && time.Unix(int64(iat), 0).After(now.Add(clockSkew)) {
+		return nil, errors.New("ID token was issued in the future")
+	}
+	if azp, ok := raw["azp"].(string); ok && azp != "" && azp != clientID {
+		return nil, errors.New("ID token was issued to another client")
+	}
+	if got, _ := raw["nonce"].(string); got != nonce {
+		return nil, errors.New("ID token nonce doesn't match the sign-in")
+	}
+	return claims, nil
+}
+
+// decodeSegment decodes a base64url JSON part of a token
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// verifySignature checks a JWS signature made with alg
+func verifySignature(alg string, key crypto.PublicKey, signed string, signature []byte) error {
+	if len(alg) != 5 {
+		return fmt.Errorf("unsupported signing algorithm %q", alg)
+	}
+	var hash crypto.Hash
+	switch alg[2:] {
+	case "256":
+		hash = crypto.SHA256
+	case "384":
+		hash = crypto.SHA384
+	case "512":
+		hash = crypto.SHA512
+	default:
+		return fmt.Errorf("unsupported signing algorithm %s", alg)
+	}
+	h := hash.New()
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+
+	switch {
+	case strings.HasPrefix(alg, "RS"):
+		rsaKey, ok := key.(*rsa.PublicKey)
+		if !ok || rsa.VerifyPKCS1v15(rsaKey, hash, digest, signature) != nil {
+			return errors.New("ID token signature is invalid")
+		}
+	case strings.HasPrefix(alg, "PS"):
+		rsaKey, ok := key.(*rsa.PublicKey)
+		if !ok || rsa.VerifyPSS(rsaKey, hash, digest, signature, nil) != nil {
+			return errors.New("ID token signature is invalid")
+		}
+	case strings.HasPrefix(alg, "ES"):
+		ecKey, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return errors.New("ID token signature is invalid")
+		}
+		size := (ecKey.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return errors.New("ID token signature is invalid")
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(ecKey, digest, r, s) {
+			return errors.New("ID token signature is invalid")
+		}
+	default:
+		// "none" and HMAC algorithms are never accepted
+		return fmt.Errorf("unsupported signing algorithm %s", alg)
+	}
+	return nil
+}
+
+// keySet is a provider's published signing keys by ID
+type keySet map[string]crypto.PublicKey
+
+// jwk is a key in a JSON Web Key Set
+type jwk struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// key returns the provider's signing key with an ID, fetching the keys
+// when they haven't been or the ID is new, as providers rotate keys
+func (p *Provider) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.keys != nil {
+		if key, ok := p.lookup(kid); ok {
+			return key, nil
+		}
+		if time.Since(p.fetched) < keyRefresh {
+			return nil, errors.New("ID token was signed with an unknown key")
+		}
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := getJSON(ctx, p.JWKSURI, &set); err != nil {
+		return nil, fmt.Errorf("failed to fetch provider keys: %w", err)
+	}
+	keys := keySet{}
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		if key, err := k.publicKey(); err == nil {
+			keys[k.Kid] = key
+		}
+	}
+	p.keys, p.fetched = &keys, time.Now()
+
+	if key, ok := p.lookup(kid); ok {
+		return key, nil
+	}
+	return nil, errors.New("ID token was signed with an unknown key")
+}
+
+// lookup finds a key by ID; tokens without one match a provider's only key
+func (p *Provider) lookup(kid string) (crypto.PublicKey, bool) {
+	keys := *p.keys
+	if key, ok := keys[kid]; ok {
+		return key, true
+	}
+	if kid == "" && len(keys) == 1 {
+		for _, key := range keys {
+			return key, true
+		}
+	}
+	return nil, false
+}
+
+// publicKey decodes an RSA or elliptic curve key
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	decode := func(s string) (*big.Int, error) {
+		b, err := base64.RawURLEncoding.DecodeString(s)
+		return new(big.Int).SetBytes(b), err
+	}
+
+	switch k.Kty {
+	case "RSA":
+		n, err := decode(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(k.E)
+		if err != nil || !e.IsInt64() {
+			return nil, errors.New("invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		curves := map[string]elliptic.Curve{"P-256": elliptic.P256(), "P-384": elliptic.P384(), "P-521": elliptic.P521()}
+		curve, ok := curves[k.Crv]
+		if !ok {
+			return nil, fmt.Errorf("unsupported curve %s", k.Crv)
+		}
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %s", k.Kty)
+}
@@ -0,0 +1,191 @@
+// Package oidc signs users in with an OpenID Connect provider such as
+// Google Workspace, Azure AD or Keycloak, using the authorization code flow
+// with PKCE. ID tokens are verified against the provider's published keys.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// wellKnown is where providers publish their configuration, under the
+// issuer's URL
+const wellKnown = "/.well-known/openid-configuration"
+
+// httpClient talks to providers
+var httpClient = &http.Client{Timeout: 15 * time.Second}
+
+// Provider is an OpenID Connect provider's configuration, as discovered
+type Provider struct {
+	Issuer                string   `json:"issuer"`
+	AuthorizationEndpoint string   `json:"authorization_endpoint"`
+	TokenEndpoint         string   `json:"token_endpoint"`
+	UserinfoEndpoint      string   `json:"userinfo_endpoint"`
+	JWKSURI               string   `json:"jwks_uri"`
+	Algorithms            []string `json:"id_token_signing_alg_values_supported"`
+
+	mu      sync.Mutex
+	keys    *keySet
+	fetched time.Time
+}
+
+// Config is how the workspace is registered with a provider
+type Config struct {
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string // openid is always requested
+}
+
+// Token is what the token endpoint returns for an authorization code
+type Token struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	IDToken     string `json:"id_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// Discover reads a provider's configuration from its discovery URL, or
+// from its issuer URL with the well-known path added
+func Discover(ctx context.Context, discoveryURL string) (*Provider, error) {
+	discoveryURL = strings.TrimRight(strings.TrimSpace(discoveryURL), "/")
+	if !strings.HasSuffix(discoveryURL, wellKnown) {
+		discoveryURL += wellKnown
+	}
+	u, err := url.Parse(discoveryURL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return nil, errors.New("discovery URL must be an http or https address")
+	}
+
+	provider := &Provider{}
+	if err := getJSON(ctx, discoveryURL, provider); err != nil {
+		return nil, fmt.Errorf("failed to read provider configuration: %w", err)
+	}
+	if provider.Issuer == "" || provider.AuthorizationEndpoint == "" || provider.TokenEndpoint == "" || provider.JWKSURI == "" {
+		return nil, errors.New("provider configuration is missing its issuer, endpoints or keys")
+	}
+
+	// The issuer must be where the configuration was found, so one provider
+	// can't claim to be another
+	if strings.TrimRight(provider.Issuer, "/")+wellKnown != discoveryURL {
+		return nil, fmt.Errorf("provider issuer %s doesn't match the discovery URL", provider.Issuer)
+	}
+	return provider, nil
+}
+
+// AuthCodeURL returns where to send the browser to sign in. state is
+// checked on the callback, nonce in the ID token, and verifier proves the
+// code is exchanged by whoever started the sign-in.
+func (p *Provider) AuthCodeURL(cfg Config, state, nonce, verifier string) string {
+	scopes := []string{"openid"}
+	for _, scope := range cfg.Scopes {
+		if scope != "openid" {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", cfg.ClientID)
+	query.Set("redirect_uri", cfg.RedirectURL)
+	query.Set("scope", strings.Join(scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", Challenge(verifier))
+	query.Set("code_challenge_method", "S256")
+
+	sep := "?"
+	if strings.Contains(p.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return p.AuthorizationEndpoint + sep + query.Encode()
+}
+
+// Exchange trades an authorization code for tokens
+func (p *Provider) Exchange(ctx context.Context, cfg Config, code, verifier string) (*Token, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", cfg.RedirectURL)
+	form.Set("code_verifier", verifier)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(cfg.ClientID), url.QueryEscape(cfg.ClientSecret))
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach token endpoint: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var failure struct {
+			Error       string `json:"error"`
+			Description string `json:"error_description"`
+		}
+		if json.Unmarshal(body, &failure) == nil && failure.Error != "" {
+			return nil, fmt.Errorf("provider refused the code: %s %s", failure.Error, failure.Description)
+		}
+		return nil, fmt.Errorf("token endpoint returned %s", resp.Status)
+	}
+
+	token := &Token{}
+	if err := json.Unmarshal(body, token); err != nil {
+		return nil, fmt.Errorf("invalid token response: %w", err)
+	}
+	if token.IDToken == "" {
+		return nil, errors.New("provider returned no ID token, check the openid scope is allowed")
+	}
+	return token, nil
+}
+
+// RandomString returns a random URL-safe string for states, nonces and
+// PKCE verifiers
+func RandomString() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// Challenge returns the S256 PKCE challenge of a verifier
+func Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// getJSON decodes the JSON document at a URL
+func getJSON(ctx context.Context, u string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", u, resp.Status)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
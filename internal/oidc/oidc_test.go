@@ -0,0 +1,208 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testProvider serves discovery, keys and a token endpoint signing with key
+func testProvider(t *testing.T, key *rsa.PrivateKey) *httptest.Server {
+	t.Helper()
+	var server *httptest.Server
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"issuer":                                server.URL,
+			"authorization_endpoint":                server.URL + "/authorize",
+			"token_endpoint":                        server.URL + "/token",
+			"jwks_uri":                              server.URL + "/keys",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kid": "test",
+			"kty": "RSA",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		id, secret, _ := r.BasicAuth()
+		if id != "workspace" || secret != "s3cret" || r.FormValue("code") != "good" || r.FormValue("code_verifier") != "verifier" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"access_token": "access",
+			"id_token":     sign(t, key, "test", map[string]any{"iss": server.URL, "sub": "1", "aud": "workspace", "exp": time.Now().Add(time.Hour).Unix(), "nonce": "n"}),
+		})
+	})
+	server = httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// sign makes an RS256 ID token
+func sign(t *testing.T, key *rsa.PrivateKey, kid string, claims map[string]any) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestDiscoverAndExchange(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := testProvider(t, key)
+	ctx := context.Background()
+
+	provider, err := Discover(ctx, server.URL+"/")
+	if err != nil {
+		t.Fatalf("Discover: %v", err)
+	}
+	if provider.Issuer != server.URL || provider.TokenEndpoint != server.URL+"/token" {
+		t.Errorf("unexpected provider %+v", provider)
+	}
+
+	cfg := Config{ClientID: "workspace", ClientSecret: "s3cret", RedirectURL: "https://ws.example/_auth/sso/callback", Scopes: []string{"email", "openid"}}
+	authURL := provider.AuthCodeURL(cfg, "state", "n", "verifier")
+	for _, want := range []string{"scope=openid+email", "state=state", "code_challenge=" + Challenge("verifier"), "code_challenge_method=S256"} {
+		if !strings.Contains(authURL, want) {
+			t.Errorf("AuthCodeURL %s missing %s", authURL, want)
+		}
+	}
+
+	if _, err := provider.Exchange(ctx, cfg, "bad", "verifier"); err == nil || !strings.Contains(err.Error(), "invalid_grant") {
+		t.Errorf("Exchange with bad code = %v", err)
+	}
+	token, err := provider.Exchange(ctx, cfg, "good", "verifier")
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	claims, err := provider.VerifyIDToken(ctx, "workspace", token.IDToken, "n")
+	if err != nil || claims.Subject != "1" {
+		t.Errorf("VerifyIDToken = %+v, %v", claims, err)
+	}
+}
+
+func TestDiscoverIssuerMismatch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 "https://elsewhere.example",
+			"authorization_endpoint": "https://elsewhere.example/authorize",
+			"token_endpoint":         "https://elsewhere.example/token",
+			"jwks_uri":               "https://elsewhere.example/keys",
+		})
+	}))
+	defer server.Close()
+
+	if _, err := Discover(context.Background(), server.URL); err == nil {
+		t.Error("Discover accepted a provider claiming another issuer")
+	}
+	if _, err := Discover(context.Background(), "ftp://example.com"); err == nil {
+		t.Error("Discover accepted a non-http URL")
+	}
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := testProvider(t, key)
+	provider, err := Discover(context.Background(), server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	valid := func() map[string]any {
+		return map[string]any{
+			"iss":            server.URL,
+			"sub":            "42",
+			"aud":            []string{"workspace", "other"},
+			"azp":            "workspace",
+			"exp":            now.Add(time.Hour).Unix(),
+			"iat":            now.Unix(),
+			"nonce":          "nonce",
+			"email":          "ada@example.com",
+			"email_verified": "true",
+			"groups":         []string{"engineering", "admins"},
+		}
+	}
+
+	claims, err := provider.VerifyIDToken(context.Background(), "workspace", sign(t, key, "test", valid()), "nonce")
+	if err != nil {
+		t.Fatalf("valid token rejected: %v", err)
+	}
+	if claims.Subject != "42" || claims.Email != "ada@example.com" || !claims.EmailVerified {
+		t.Errorf("unexpected claims %+v", claims)
+	}
+	if groups := claims.Strings("groups"); len(groups) != 2 || groups[1] != "admins" {
+		t.Errorf("groups = %v", groups)
+	}
+
+	tests := []struct {
+		name   string
+		key    *rsa.PrivateKey
+		kid    string
+		change func(map[string]any)
+		nonce  string
+	}{
+		{"wrong key", other, "test", nil, "nonce"},
+		{"unknown key ID", key, "rotated", nil, "nonce"},
+		{"wrong issuer", key, "test", func(c map[string]any) { c["iss"] = "https://evil.example" }, "nonce"},
+		{"wrong audience", key, "test", func(c map[string]any) { c["aud"] = "other"; delete(c, "azp") }, "nonce"},
+		{"wrong authorized party", key, "test", func(c map[string]any) { c["azp"] = "other" }, "nonce"},
+		{"expired", key, "test", func(c map[string]any) { c["exp"] = now.Add(-time.Hour).Unix() }, "nonce"},
+		{"no expiry", key, "test", func(c map[string]any) { delete(c, "exp") }, "nonce"},
+		{"issued in future", key, "test", func(c map[string]any) { c["iat"] = now.Add(time.Hour).Unix() }, "nonce"},
+		{"no subject", key, "test", func(c map[string]any) { delete(c, "sub") }, "nonce"},
+		{"wrong nonce", key, "test", nil, "replayed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := valid()
+			if tt.change != nil {
+				tt.change(claims)
+			}
+			if _, err := provider.VerifyIDToken(context.Background(), "workspace", sign(t, tt.key, tt.kid, claims), tt.nonce); err == nil {
+				t.Error("token accepted")
+			}
+		})
+	}
+
+	// Unsigned tokens are never accepted
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`))
+	payload, _ := json.Marshal(valid())
+	unsigned := header + "." + base64.RawURLEncoding.EncodeToString(payload) + "."
+	if _, err := provider.VerifyIDToken(context.Background(), "workspace", unsigned, "nonce"); err == nil {
+		t.Error("unsigned token accepted")
+	}
+}
@@ -0,0 +1,263 @@
+// Package openapi builds OpenAPI 3 documents from Go types, so an API's
+// spec is generated from the same structs it encodes and can't drift.
+package openapi
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Version is the OpenAPI version of generated documents
+const Version = "3.0.3"
+
+// Spec is an OpenAPI document
+type Spec struct {
+	OpenAPI    string                          `json:"openapi"`
+	Info       Info                            `json:"info"`
+	Servers    []Server                        `json:"servers,omitempty"`
+	Paths      map[string]map[string]Operation `json:"paths"`
+	Components Components                      `json:"components"`
+	Security   []map[string][]string           `json:"security,omitempty"`
+}
+
+// Info describes the API
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// Server is a base URL the API is served from
+type Server struct {
+	URL string `json:"url"`
+}
+
+// Components holds the schemas and security schemes operations refer to
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme is a way to authenticate
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+	Description  string `json:"description,omitempty"`
+}
+
+// Operation is one method on a path
+type Operation struct {
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	OperationID string                `json:"operationId,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]Response   `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+// Parameter is a path, query or header parameter
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody is an operation's JSON body
+type RequestBody struct {
+	Required bool                 `json:"required,omitempty"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response is an operation's response for a status code
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// MediaType is the schema of a body
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Schema describes a JSON value
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+}
+
+// New returns an empty document
+func New(info Info) *Spec {
+	return &Spec{
+		OpenAPI: Version,
+		Info:    info,
+		Paths:   map[string]map[string]Operation{},
+		Components: Components{
+			Schemas: map[string]*Schema{},
+		},
+	}
+}
+
+var pathParam = regexp.MustCompile(`\{([^}]+)\}`)
+
+// Add documents an operation. Path parameters like {id} are added when
+// the operation doesn't describe them itself.
+func (s *Spec) Add(method, path string, op Operation) {
+	for _, m := range pathParam.FindAllStringSubmatch(path, -1) {
+		if !hasParameter(op.Parameters, m[1], "path") {
+			op.Parameters = append(op.Parameters, Parameter{
+				Name:     m[1],
+				In:       "path",
+				Required: true,
+				Schema:   &Schema{Type: "string"},
+			})
+		}
+	}
+	if op.Responses == nil {
+		op.Responses = map[string]Response{}
+	}
+	if s.Paths[path] == nil {
+		s.Paths[path] = map[string]Operation{}
+	}
+	s.Paths[path][strings.ToLower(method)] = op
+}
+
+func hasParameter(params []Parameter, name, in string) bool {
+	for _, p := range params {
+		if p.Name == name && p.In == in {
+			return true
+		}
+	}
+	return false
+}
+
+// Define adds the schema of v's type to the components, returning a
+// reference to it
+func (s *Spec) Define(name string, v any) *Schema {
+	s.Components.Schemas[name] = SchemaOf(v)
+	return Ref(name)
+}
+
+// Ref refers to a schema defined in the components
+func Ref(name string) *Schema {
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// ArrayOf is a schema for a list of items
+func ArrayOf(items *Schema) *Schema {
+	return &Schema{Type: "array", Items: items}
+}
+
+// JSON is the body of a request or response in JSON
+func JSON(schema *Schema) map[string]MediaType {
+	return map[string]MediaType{"application/json": {Schema: schema}}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// SchemaOf describes the JSON encoding of v's type, following its json
+// struct tags. Fields without omitempty or omitzero are required.
+func SchemaOf(v any) *Schema {
+	return schemaOf(reflect.TypeOf(v))
+}
+
+func schemaOf(t reflect.Type) *Schema {
+	if t == nil {
+		return &Schema{}
+	}
+	if t == timeType {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		schema := schemaOf(t.Elem())
+		if schema.Ref == "" {
+			schema.Nullable = true
+		}
+		return schema
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		format := "int32"
+		if t.Bits() == 64 {
+			format = "int64"
+		}
+		return &Schema{Type: "integer", Format: format}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		// []byte is encoded as base64
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return ArrayOf(schemaOf(t.Elem()))
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: schemaOf(t.Elem())}
+	case reflect.Struct:
+		schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+		addFields(schema, t)
+		return schema
+	}
+	return &Schema{}
+}
+
+// addFields adds a struct's exported fields to an object schema, with
+// embedded structs' fields inlined as encoding/json does
+func addFields(schema *Schema, t reflect.Type) {
+	for i := range t.NumField() {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" {
+			ft := field.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				addFields(schema, ft)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		schema.Properties[name] = schemaOf(field.Type)
+		optional := false
+		for _, opt := range strings.Split(opts, ",") {
+			if opt == "omitempty" || opt == "omitzero" {
+				optional = true
+			}
+		}
+		if !optional {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type base struct {
+	ID string `json:"id"`
+}
+
+type item struct {
+	base
+	Title    string         `json:"title"`
+	Count    int            `json:"count"`
+	Score    float64        `json:"score,omitempty"`
+	Done     bool           `json:"done"`
+	Tags     []string       `json:"tags"`
+	Due      *time.Time     `json:"due,omitempty"`
+	Created  time.Time      `json:"created_at,omitzero"`
+	Meta     map[string]int `json:"meta,omitempty"`
+	Secret   string         `json:"-"`
+	NoTag    string
+	internal string
+}
+
+func TestSchemaOf(t *testing.T) {
+	schema := SchemaOf(item{})
+	if schema.Type != "object" {
+		t.Fatalf("Type = %q, want object", schema.Type)
+	}
+
+	want := map[string]Schema{
+		"id":         {Type: "string"},
+		"title":      {Type: "string"},
+		"count":      {Type: "integer", Format: "int64"},
+		"score":      {Type: "number", Format: "double"},
+		"done":       {Type: "boolean"},
+		"due":        {Type: "string", Format: "date-time", Nullable: true},
+		"created_at": {Type: "string", Format: "date-time"},
+		"NoTag":      {Type: "string"},
+	}
+	for name, w := range want {
+		got, ok := schema.Properties[name]
+		if !ok {
+			t.Errorf("missing property %q", name)
+			continue
+		}
+		if got.Type != w.Type || got.Format != w.Format || got.Nullable != w.Nullable {
+			t.Errorf("%s = %+v, want %+v", name, *got, w)
+		}
+	}
+
+	if tags := schema.Properties["tags"]; tags.Type != "array" || tags.Items.Type != "string" {
+		t.Errorf("tags = %+v, want an array of strings", *tags)
+	}
+	if meta := schema.Properties["meta"]; meta.Type != "object" || meta.AdditionalProperties.Type != "integer" {
+		t.Errorf("meta = %+v, want a map of integers", *meta)
+	}
+	for _, name := range []string{"Secret", "internal", "base"} {
+		if _, ok := schema.Properties[name]; ok {
+			t.Errorf("unexpected property %q", name)
+		}
+	}
+
+	required := []string{"id", "title", "count", "done", "tags", "NoTag"}
+	if !reflect.DeepEqual(schema.Required, required) {
+		t.Errorf("Required = %v, want %v", schema.Required, required)
+	}
+}
+
+func TestAdd(t *testing.T) {
+	spec := New(Info{Title: "Test", Version: "1"})
+	ref := spec.Define("Item", item{})
+	if ref.Ref != "#/components/schemas/Item" {
+		t.Errorf("Ref = %q", ref.Ref)
+	}
+
+	spec.Add("GET", "/repos/{id}/items/{number}", Operation{
+		Parameters: []Parameter{{Name: "number", In: "path", Required: true, Schema: &Schema{Type: "integer"}}},
+		Responses:  map[string]Response{"200": {Description: "OK", Content: JSON(ArrayOf(ref))}},
+	})
+
+	op, ok := spec.Paths["/repos/{id}/items/{number}"]["get"]
+	if !ok {
+		t.Fatal("operation not added under its lowercased method")
+	}
+	if len(op.Parameters) != 2 {
+		t.Fatalf("Parameters = %+v, want number and id", op.Parameters)
+	}
+	if p := op.Parameters[1]; p.Name != "id" || p.In != "path" || !p.Required {
+		t.Errorf("added parameter = %+v", p)
+	}
+	if op.Parameters[0].Schema.Type != "integer" {
+		t.Error("described parameter was replaced")
+	}
+
+	// The document must encode as JSON
+	if _, err := json.Marshal(spec); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,225 @@
+// Package pdf renders simple Markdown documents, such as generated reports,
+// as PDF using only the standard Helvetica fonts every reader ships with.
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// A4 page layout in points
+const (
+	pageWidth  = 595
+	pageHeight = 842
+	margin     = 56
+)
+
+// Text styles
+const (
+	bodySize     = 10.5
+	headingSize  = 13
+	titleSize    = 18
+	lineSpacing  = 1.45
+	bulletIndent = 14
+)
+
+var (
+	linkPattern     = regexp.MustCompile(`\[([^\]]*)\]\([^)]*\)`)
+	emphasisPattern = regexp.MustCompile("\\*\\*|__|`")
+	tableRule       = regexp.MustCompile(`^\|?[\s:|-]+\|?$`)
+)
+
+// line is one line of laid out text
+type line struct {
+	text   string
+	size   float64
+	bold   bool
+	indent float64
+	space  float64 // Extra space above the line
+}
+
+// FromMarkdown renders Markdown as a PDF document. Headings, bullet lists
+// and tables are laid out; inline formatting is dropped.
+func FromMarkdown(markdown string) []byte {
+	return render(layout(markdown))
+}
+
+// layout turns Markdown into wrapped lines of styled text
+func layout(markdown string) []line {
+	var lines []line
+	for _, raw := range strings.Split(strings.ReplaceAll(markdown, "\r\n", "\n"), "\n") {
+		text := strings.TrimSpace(raw)
+		switch {
+		case text == "":
+			lines = append(lines, line{size: bodySize / 2})
+		case strings.HasPrefix(text, "# "):
+			lines = append(lines, wrap(plain(text[2:]), titleSize, true, 0, titleSize/2)...)
+		case strings.HasPrefix(text, "#"):
+			lines = append(lines, wrap(plain(strings.TrimLeft(text, "# ")), headingSize, true, 0, headingSize/2)...)
+		case strings.HasPrefix(text, "- "), strings.HasPrefix(text, "* "):
+			wrapped := wrap(plain(text[2:]), bodySize, false, bulletIndent, 0)
+			wrapped[0].text = "• " + wrapped[0].text
+			for i := 1; i < len(wrapped); i++ {
+				wrapped[i].indent += bulletIndent / 2
+			}
+			lines = append(lines, wrapped...)
+		case strings.HasPrefix(text, "|"):
+			if tableRule.MatchString(text) {
+				continue
+			}
+			var cells []string
+			for _, cell := range strings.Split(strings.Trim(text, "|"), "|") {
+				if cell = plain(cell); cell != "" {
+					cells = append(cells, cell)
+				}
+			}
+			lines = append(lines, wrap(strings.Join(cells, ": "), bodySize, false, 0, 0)...)
+		default:
+			lines = append(lines, wrap(plain(text), bodySize, false, 0, 0)...)
+		}
+	}
+	return lines
+}
+
+// plain strips inline Markdown, keeping the text of links
+func plain(text string) string {
+	text = linkPattern.ReplaceAllString(text, "$1")
+	return strings.TrimSpace(emphasisPattern.ReplaceAllString(text, ""))
+}
+
+// wrap breaks text into lines that fit the page at size
+func wrap(text string, size float64, bold bool, indent, space float64) []line {
+	width := pageWidth - 2*margin - indent
+	var lines []line
+	current := ""
+	for _, word := range strings.Fields(text) {
+		candidate := word
+		if current != "" {
+			candidate = current + " " + word
+		}
+		if current != "" && textWidth(candidate, size, bold) > width {
+			lines = append(lines, line{text: current, size: size, bold: bold, indent: indent})
+			current = word
+			continue
+		}
+		current = candidate
+	}
+	lines = append(lines, line{text: current, size: size, bold: bold, indent: indent})
+	lines[0].space = space
+	return lines
+}
+
+// textWidth estimates the width of text in points. Helvetica's glyphs are
+// close enough to these averages that lines never overflow the margin.
+func textWidth(text string, size float64, bold bool) float64 {
+	em := 0.0
+	for _, r := range text {
+		switch {
+		case r == ' ' || strings.ContainsRune("iljtfrI.,:;'|!()[]", r):
+			em += 0.3
+		case r >= 'A' && r <= 'Z' || strings.ContainsRune("mwMW@%", r):
+			em += 0.75
+		default:
+			em += 0.56
+		}
+	}
+	if bold {
+		em *= 1.08
+	}
+	return em * size
+}
+
+// render writes the lines out as PDF pages
+func render(lines []line) []byte {
+	var pages []*bytes.Buffer
+	var page *bytes.Buffer
+	y := 0.0
+	for _, l := range lines {
+		height := l.size*lineSpacing + l.space
+		if page == nil || y-height < margin {
+			page = &bytes.Buffer{}
+			pages = append(pages, page)
+			y = pageHeight - margin
+			if l.text == "" {
+				continue // Don't start a page with blank space
+			}
+		}
+		y -= height
+		if l.text == "" {
+			continue
+		}
+		font := "F1"
+		if l.bold {
+			font = "F2"
+		}
+		fmt.Fprintf(page, "BT /%s %.1f Tf %.1f %.1f Td (%s) Tj ET\n", font, l.size, margin+l.indent, y, escape(l.text))
+	}
+	if len(pages) == 0 {
+		pages = append(pages, &bytes.Buffer{})
+	}
+
+	var out bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	// Objects 1-4 are the catalog, page tree and fonts; each page is then
+	// followed by its content stream
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+2*i)
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	for i, content := range pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			pageWidth, pageHeight, 6+2*i))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return out.Bytes()
+}
+
+// winAnsi maps the characters outside Latin-1 that WinAnsiEncoding has
+var winAnsi = map[rune]byte{
+	'€': 0x80, '‚': 0x82, '„': 0x84, '…': 0x85, '•': 0x95, '–': 0x96, '—': 0x97,
+	'‘': 0x91, '’': 0x92, '“': 0x93, '”': 0x94, '™': 0x99,
+}
+
+// escape encodes text as a PDF string literal in WinAnsiEncoding, with
+// characters the fonts can't show replaced
+func escape(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '→':
+			b.WriteString("->")
+		case r >= 0x20 && r < 0x7f:
+			b.WriteRune(r)
+		case winAnsi[r] != 0:
+			b.WriteByte(winAnsi[r])
+		case r >= 0xa0 && r <= 0xff:
+			b.WriteByte(byte(r))
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,105 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestFromMarkdown(t *testing.T) {
+	doc := FromMarkdown("# Weekly Report\n\n## Merged\n\n- Fix (login) bug → main\n\n| Commits | 12 |\n|---|---|\n")
+
+	if !bytes.HasPrefix(doc, []byte("%PDF-1.4\n")) || !bytes.HasSuffix(doc, []byte("%%EOF\n")) {
+		t.Fatal("document is missing the PDF header or trailer")
+	}
+	for _, want := range []string{"(Weekly Report) Tj", "/F2", "Fix \\(login\\) bug -> main", "(Commits: 12) Tj", "/Count 1"} {
+		if !bytes.Contains(doc, []byte(want)) {
+			t.Errorf("document is missing %q", want)
+		}
+	}
+	if bytes.Contains(doc, []byte("---")) {
+		t.Error("table rule was rendered")
+	}
+	checkXref(t, doc)
+}
+
+func TestFromMarkdownPages(t *testing.T) {
+	var md strings.Builder
+	for i := 0; i < 200; i++ {
+		fmt.Fprintf(&md, "- Item %d with a description long enough that it has to wrap onto the next line of the page\n", i)
+	}
+	doc := FromMarkdown(md.String())
+
+	count := regexp.MustCompile(`/Count (\d+)`).FindSubmatch(doc)
+	if count == nil {
+		t.Fatal("page tree has no count")
+	}
+	if n, _ := strconv.Atoi(string(count[1])); n < 5 {
+		t.Errorf("expected long document to span several pages, got %d", n)
+	}
+	if !bytes.Contains(doc, []byte("Item 199 ")) {
+		t.Error("last item is missing")
+	}
+	checkXref(t, doc)
+}
+
+func TestFromMarkdownEmpty(t *testing.T) {
+	doc := FromMarkdown("")
+	if !bytes.Contains(doc, []byte("/Count 1")) {
+		t.Error("empty document should have one blank page")
+	}
+	checkXref(t, doc)
+}
+
+func TestWrap(t *testing.T) {
+	lines := wrap(strings.Repeat("word ", 100), bodySize, false, 0, 0)
+	if len(lines) < 2 {
+		t.Fatalf("expected text to wrap, got %d lines", len(lines))
+	}
+	for _, l := range lines {
+		if w := textWidth(l.text, bodySize, false); w > pageWidth-2*margin {
+			t.Errorf("line %q is %.0fpt wide", l.text, w)
+		}
+	}
+}
+
+func TestEscape(t *testing.T) {
+	tests := map[string]string{
+		`a (b) \c`: `a \(b\) \\c`,
+		"café":     "caf\xe9",
+		"• — €":    "\x95 \x97 \x80",
+		"日本":       "??",
+	}
+	for in, want := range tests {
+		if got := escape(in); got != want {
+			t.Errorf("escape(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestPlain(t *testing.T) {
+	if got := plain("**Bold** and `code` with [a link](https://example.com)"); got != "Bold and code with a link" {
+		t.Errorf("plain = %q", got)
+	}
+}
+
+// checkXref verifies each cross-reference entry points at its object
+func checkXref(t *testing.T, doc []byte) {
+	t.Helper()
+	start := bytes.LastIndex(doc, []byte("startxref\n"))
+	offset, err := strconv.Atoi(strings.Fields(string(doc[start+len("startxref\n"):]))[0])
+	if err != nil || !bytes.HasPrefix(doc[offset:], []byte("xref\n")) {
+		t.Fatalf("startxref doesn't point at the xref table")
+	}
+
+	entries := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(doc[offset:], -1)
+	for i, entry := range entries {
+		at, _ := strconv.Atoi(string(entry[1]))
+		if want := fmt.Sprintf("%d 0 obj", i+1); !bytes.HasPrefix(doc[at:], []byte(want)) {
+			t.Errorf("xref entry %d doesn't point at %q", i+1, want)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+// Package references finds mentions of issues (#12), pull requests (!7)
+// and commits (a SHA) in text, and links them in rendered Markdown.
+package references
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// Kinds of mentions
+const (
+	Issue       = "issue"
+	PullRequest = "pr"
+	Commit      = "commit"
+)
+
+// Ref is a mention of an issue, pull request or commit. Mentions with a
+// hash may name either an issue or a pull request; Kind is Issue for them
+// and the resolver decides.
+type Ref struct {
+	Kind   string
+	ID     string
+	Closes bool // Preceded by a closing keyword, as in "fixes #12"
+	Start  int  // Byte offsets of the mention itself in the text
+	End    int
+}
+
+// Text returns the mention as written, like #12 or a short SHA
+func (r Ref) Text() string {
+	switch r.Kind {
+	case PullRequest:
+		return "!" + r.ID
+	case Commit:
+		if len(r.ID) > 7 {
+			return r.ID[:7]
+		}
+		return r.ID
+	}
+	return "#" + r.ID
+}
+
+var pattern = regexp.MustCompile(`(?i)(?:\b(close[sd]?|fix(?:e[sd])?|resolve[sd]?):?\s+)?([#!])([0-9a-z][0-9a-z-]*)|\b([0-9a-f]{7,40})\b`)
+
+// Parse returns the mentions in text in the order they appear
+func Parse(s string) []Ref {
+	var refs []Ref
+	for _, m := range pattern.FindAllStringSubmatchIndex(s, -1) {
+		if m[8] >= 0 {
+			refs = append(refs, Ref{Kind: Commit, ID: strings.ToLower(s[m[8]:m[9]]), Start: m[8], End: m[9]})
+			continue
+		}
+
+		start := m[4]
+		if start > 0 && !boundary(s[start-1]) {
+			continue
+		}
+		id := strings.TrimRight(s[m[6]:m[7]], "-")
+		ref := Ref{Kind: Issue, ID: id, Start: start, End: m[6] + len(id)}
+		if s[start] == '!' {
+			ref.Kind = PullRequest
+		} else {
+			ref.Closes = m[2] >= 0
+		}
+		refs = append(refs, ref)
+	}
+	return refs
+}
+
+// boundary returns true if a mention may follow the character. Words,
+// URLs and HTML entities such as &#39; don't hold mentions.
+func boundary(c byte) bool {
+	switch {
+	case c >= '0' && c <= '9', c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		return false
+	}
+	return !strings.ContainsRune("_&/#!-.:=?", rune(c))
+}
+
+// Resolver returns the URL a mention links to, or "" to leave it as text
+type Resolver func(Ref) string
+
+// NewLinker returns a goldmark extension linking the mentions in Markdown
+// text, outside code and existing links, that resolve to a URL
+func NewLinker(resolve Resolver) goldmark.Extender {
+	return &linker{resolve: resolve}
+}
+
+type linker struct {
+	resolve Resolver
+}
+
+// Extend adds the linker to the parser, after the inline parsers ran
+func (l *linker) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(parser.WithASTTransformers(util.Prioritized(l, 999)))
+}
+
+// Transform splits text nodes around the mentions they hold
+func (l *linker) Transform(doc *ast.Document, reader text.Reader, _ parser.Context) {
+	source := reader.Source()
+	var texts []*ast.Text
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n.Kind() {
+		case ast.KindLink, ast.KindAutoLink, ast.KindImage, ast.KindCodeSpan, ast.KindRawHTML:
+			return ast.WalkSkipChildren, nil
+		}
+		if t, ok := n.(*ast.Text); ok {
+			texts = append(texts, t)
+		}
+		return ast.WalkContinue, nil
+	})
+
+	for _, t := range texts {
+		l.link(t, source)
+	}
+}
+
+// link replaces the mentions in a text node with links
+func (l *linker) link(t *ast.Text, source []byte) {
+	segment := t.Segment
+	parent := t.Parent()
+	last := 0
+	for _, ref := range Parse(string(segment.Value(source))) {
+		url := l.resolve(ref)
+		if url == "" {
+			continue
+		}
+		if ref.Start > last {
+			parent.InsertBefore(parent, t, ast.NewTextSegment(text.NewSegment(segment.Start+last, segment.Start+ref.Start)))
+		}
+		link := ast.NewLink()
+		link.Destination = []byte(url)
+		link.AppendChild(link, ast.NewTextSegment(text.NewSegment(segment.Start+ref.Start, segment.Start+ref.End)))
+		parent.InsertBefore(parent, t, link)
+		last = ref.End
+	}
+	if last > 0 {
+		t.Segment = text.NewSegment(segment.Start+last, segment.Stop)
+	}
+}
@@ -0,0 +1,79 @@
+package references
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/yuin/goldmark"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		text string
+		want []Ref
+	}{
+		{"See #12", []Ref{{Kind: Issue, ID: "12", Start: 4, End: 7}}},
+		{"Fixes #12 and closes #13.", []Ref{
+			{Kind: Issue, ID: "12", Closes: true, Start: 6, End: 9},
+			{Kind: Issue, ID: "13", Closes: true, Start: 21, End: 24},
+		}},
+		{"resolved: #4", []Ref{{Kind: Issue, ID: "4", Closes: true, Start: 10, End: 12}}},
+		{"Prefixes #4", []Ref{{Kind: Issue, ID: "4", Start: 9, End: 11}}},
+		{"Depends on !7 (#8-)", []Ref{
+			{Kind: PullRequest, ID: "7", Start: 11, End: 13},
+			{Kind: Issue, ID: "8", Start: 15, End: 17},
+		}},
+		{"fixes !7", []Ref{{Kind: PullRequest, ID: "7", Start: 6, End: 8}}},
+		{"Reverts 3F2A9C1", []Ref{{Kind: Commit, ID: "3f2a9c1", Start: 8, End: 15}}},
+		{"C#1, a/#2, &#39; and http://x/#3", nil},
+		{"Short abc12 and word deadline", nil},
+	}
+
+	for _, tt := range tests {
+		got := Parse(tt.text)
+		if len(got) != len(tt.want) {
+			t.Errorf("Parse(%q) = %v, want %v", tt.text, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("Parse(%q)[%d] = %+v, want %+v", tt.text, i, got[i], tt.want[i])
+			}
+		}
+	}
+}
+
+func TestRefText(t *testing.T) {
+	if got := (Ref{Kind: Commit, ID: "3f2a9c1e8b"}).Text(); got != "3f2a9c1" {
+		t.Errorf("Text() = %q, want 3f2a9c1", got)
+	}
+	if got := (Ref{Kind: PullRequest, ID: "7"}).Text(); got != "!7" {
+		t.Errorf("Text() = %q, want !7", got)
+	}
+}
+
+func TestLinker(t *testing.T) {
+	md := goldmark.New(goldmark.WithExtensions(NewLinker(func(ref Ref) string {
+		if ref.ID == "404" {
+			return ""
+		}
+		return "/" + ref.Kind + "s/" + ref.ID
+	})))
+
+	var buf bytes.Buffer
+	source := "Fixes #12, not #404 or `#13`.\nSee !7 and [#14](/elsewhere)."
+	if err := md.Convert([]byte(source), &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	html := buf.String()
+	for _, want := range []string{
+		`Fixes <a href="/issues/12">#12</a>, not #404 or <code>#13</code>.`,
+		`See <a href="/prs/7">!7</a> and <a href="/elsewhere">#14</a>.`,
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("rendered %q, want it to contain %q", html, want)
+		}
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"sync"
 	"time"
+	"workspace/models"
 	"workspace/services"
 )
 
@@ -225,9 +226,16 @@ func (c *OllamaHealthChecker) CheckHealth(ctx context.Context) ComponentHealth {
 			"port":   status.Port,
 			"models": status.Models,
 		}
+		if status.Remote {
+			health.Message = fmt.Sprintf("Ollama reachable on %d remote server(s)", len(status.Endpoints))
+			health.Metadata["endpoints"] = status.Endpoints
+		}
 	} else {
 		health.Status = HealthUnhealthy
 		health.Message = "Ollama service not running"
+		if status.Remote {
+			health.Message = "No remote Ollama server is reachable"
+		}
 
 		// Attempt to restart
 		log.Println("HealthMonitor: Attempting to restart Ollama service")
@@ -266,10 +274,7 @@ func (c *DatabaseHealthChecker) CheckHealth(ctx context.Context) ComponentHealth
 	return health
 }
 
-// VaultHealthChecker checks Vault service health
-// NOTE: Vault is managed through models.Secrets, not a direct service
-// Commenting out until proper health check implementation
-/*
+// VaultHealthChecker checks Vault seal state and fallback storage
 type VaultHealthChecker struct{}
 
 func (c *VaultHealthChecker) Name() string {
@@ -284,36 +289,34 @@ func (c *VaultHealthChecker) CheckHealth(ctx context.Context) ComponentHealth {
 		LastCheck: start,
 	}
 
-	// Check if Vault service is running
-	if services.Vault == nil {
-		health.Status = HealthUnhealthy
-		health.Message = "Vault service not initialized"
-		return health
-	}
-
-	status := services.Vault.GetStatus()
+	status := models.GetVaultStatus()
 	health.ResponseTime = time.Since(start)
+	health.Metadata = map[string]any{
+		"sealed":            status.Sealed,
+		"fallback":          status.FallbackMode,
+		"pendingMigrations": status.PendingMigrations,
+	}
 
-	if status.Running {
+	switch {
+	case status.Sealed:
+		health.Status = HealthDegraded
+		health.Message = "Vault is sealed"
+	case status.Available && status.PendingMigrations > 0:
+		health.Status = HealthDegraded
+		health.Message = fmt.Sprintf("Vault running with %d secrets awaiting migration", status.PendingMigrations)
+	case status.Available:
 		health.Status = HealthHealthy
 		health.Message = "Vault service running"
-		health.Metadata = map[string]any{
-			"sealed": status.Sealed,
-			"port":   status.Port,
-		}
-
-		if status.Sealed {
-			health.Status = HealthDegraded
-			health.Message = "Vault is sealed"
-		}
-	} else {
+	case status.FallbackMode:
+		health.Status = HealthDegraded
+		health.Message = "Vault unavailable, using fallback storage"
+	default:
 		health.Status = HealthUnhealthy
 		health.Message = "Vault service not running"
 	}
 
 	return health
 }
-*/
 
 // SandboxHealthChecker checks sandbox availability
 type SandboxHealthChecker struct{}
@@ -433,7 +436,7 @@ func InitializeHealthMonitoring() {
 	// Register health checkers
 	Monitor.RegisterChecker(&OllamaHealthChecker{})
 	Monitor.RegisterChecker(&DatabaseHealthChecker{})
-	// Monitor.RegisterChecker(&VaultHealthChecker{}) // Commented out until VaultHealthChecker is implemented
+	Monitor.RegisterChecker(&VaultHealthChecker{})
 	Monitor.RegisterChecker(&SandboxHealthChecker{})
 	// Monitor.RegisterChecker(&AIQueueHealthChecker{}) // Commented out until AIQueueHealthChecker is implemented
 
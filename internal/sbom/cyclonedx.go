@@ -0,0 +1,144 @@
+package sbom
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"workspace/internal/depscan"
+)
+
+// CycloneDXVersion is the version of the CycloneDX specification exported
+const CycloneDXVersion = "1.5"
+
+// Project is the software a bill of materials describes
+type Project struct {
+	Name    string
+	Version string // The commit the bill of materials was generated at
+	Tool    string // What generated it
+}
+
+// cdxBOM is a CycloneDX bill of materials, with the fields this package
+// fills in
+type cdxBOM struct {
+	BOMFormat    string         `json:"bomFormat"`
+	SpecVersion  string         `json:"specVersion"`
+	SerialNumber string         `json:"serialNumber"`
+	Version      int            `json:"version"`
+	Metadata     cdxMetadata    `json:"metadata"`
+	Components   []cdxComponent `json:"components"`
+}
+
+type cdxMetadata struct {
+	Timestamp string `json:"timestamp"`
+	Tools     struct {
+		Components []cdxComponent `json:"components"`
+	} `json:"tools"`
+	Component cdxComponent `json:"component"`
+}
+
+type cdxComponent struct {
+	Type       string        `json:"type"`
+	BOMRef     string        `json:"bom-ref,omitempty"`
+	Group      string        `json:"group,omitempty"`
+	Name       string        `json:"name"`
+	Version    string        `json:"version,omitempty"`
+	Scope      string        `json:"scope,omitempty"`
+	Licenses   []cdxLicense  `json:"licenses,omitempty"`
+	PURL       string        `json:"purl,omitempty"`
+	Properties []cdxProperty `json:"properties,omitempty"`
+}
+
+// cdxLicense is either a single license, by SPDX identifier or by name,
+// or an SPDX expression
+type cdxLicense struct {
+	License    *cdxLicenseID `json:"license,omitempty"`
+	Expression string        `json:"expression,omitempty"`
+}
+
+type cdxLicenseID struct {
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name,omitempty"`
+}
+
+type cdxProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// CycloneDX returns the components as a CycloneDX JSON bill of materials
+// of the project, generated at a time. Development dependencies are scoped
+// optional, and where each component was found is kept in its properties.
+func CycloneDX(project Project, components []Component, generated time.Time) ([]byte, error) {
+	bom := cdxBOM{
+		BOMFormat:    "CycloneDX",
+		SpecVersion:  CycloneDXVersion,
+		SerialNumber: "urn:uuid:" + newUUID(),
+		Version:      1,
+		Components:   make([]cdxComponent, 0, len(components)),
+	}
+	bom.Metadata.Timestamp = generated.UTC().Format(time.RFC3339)
+	bom.Metadata.Tools.Components = []cdxComponent{{Type: "application", Name: project.Tool}}
+	bom.Metadata.Component = cdxComponent{
+		Type:    "application",
+		BOMRef:  project.Name,
+		Name:    project.Name,
+		Version: project.Version,
+	}
+
+	for _, c := range components {
+		component := cdxComponent{
+			Type:     "library",
+			BOMRef:   c.PURL(),
+			Name:     c.Name,
+			Version:  c.Version,
+			Scope:    "required",
+			Licenses: cdxLicenses(c.License),
+			PURL:     c.PURL(),
+			Properties: []cdxProperty{
+				{Name: "workspace:manifest", Value: c.Manifest},
+				{Name: "workspace:license-category", Value: c.Category()},
+			},
+		}
+		if c.Ecosystem == depscan.EcosystemNPM && strings.HasPrefix(c.Name, "@") {
+			component.Group, component.Name, _ = strings.Cut(c.Name, "/")
+		}
+		if c.Dev {
+			component.Scope = "optional"
+		}
+		if c.Indirect {
+			component.Properties = append(component.Properties, cdxProperty{Name: "workspace:indirect", Value: "true"})
+		}
+		bom.Components = append(bom.Components, component)
+	}
+	return json.MarshalIndent(bom, "", "  ")
+}
+
+// cdxLicenses returns a component's license the way CycloneDX records it:
+// by SPDX identifier when it's one, as an expression when it joins several
+// and by name otherwise
+func cdxLicenses(license string) []cdxLicense {
+	license = strings.TrimSpace(license)
+	if license == "" {
+		return nil
+	}
+	if id := Identifier(license); id != "" {
+		return []cdxLicense{{License: &cdxLicenseID{ID: id}}}
+	}
+	if len(tokenize(license)) > 1 && Classify(license) != Unknown {
+		return []cdxLicense{{Expression: license}}
+	}
+	return []cdxLicense{{License: &cdxLicenseID{Name: license}}}
+}
+
+// newUUID returns a random version 4 UUID for a bill of materials' serial
+// number
+func newUUID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
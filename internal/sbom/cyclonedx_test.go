@@ -0,0 +1,78 @@
+package sbom
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"workspace/internal/depscan"
+)
+
+func TestCycloneDX(t *testing.T) {
+	components := []Component{
+		{Dependency: depscan.Dependency{Ecosystem: depscan.EcosystemNPM, Name: "@babel/core", Version: "7.22.0", Manifest: "package-lock.json", Dev: true, Indirect: true, License: "MIT"}},
+		{Dependency: depscan.Dependency{Ecosystem: depscan.EcosystemGo, Name: "example.com/dual", Version: "v1.0.0", Manifest: "go.mod", License: "MIT OR Apache-2.0"}},
+		{Dependency: depscan.Dependency{Ecosystem: depscan.EcosystemPyPI, Name: "custom", Version: "1.0", Manifest: "requirements.txt", License: "Custom License"}},
+		{Dependency: depscan.Dependency{Ecosystem: depscan.EcosystemPyPI, Name: "unlicensed", Version: "2.0", Manifest: "requirements.txt"}},
+	}
+	data, err := CycloneDX(Project{Name: "shop", Version: "abc123", Tool: "workspace"}, components, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var bom struct {
+		BOMFormat    string `json:"bomFormat"`
+		SpecVersion  string `json:"specVersion"`
+		SerialNumber string `json:"serialNumber"`
+		Metadata     struct {
+			Timestamp string `json:"timestamp"`
+			Component struct {
+				Name    string `json:"name"`
+				Version string `json:"version"`
+			} `json:"component"`
+		} `json:"metadata"`
+		Components []struct {
+			Group    string `json:"group"`
+			Name     string `json:"name"`
+			Scope    string `json:"scope"`
+			PURL     string `json:"purl"`
+			Licenses []struct {
+				License struct {
+					ID   string `json:"id"`
+					Name string `json:"name"`
+				} `json:"license"`
+				Expression string `json:"expression"`
+			} `json:"licenses"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(data, &bom); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, data)
+	}
+	if bom.BOMFormat != "CycloneDX" || bom.SpecVersion != CycloneDXVersion || !strings.HasPrefix(bom.SerialNumber, "urn:uuid:") || len(bom.SerialNumber) != 45 {
+		t.Errorf("unexpected header: %s %s %s", bom.BOMFormat, bom.SpecVersion, bom.SerialNumber)
+	}
+	if bom.Metadata.Timestamp != "2024-01-02T03:04:05Z" || bom.Metadata.Component.Name != "shop" || bom.Metadata.Component.Version != "abc123" {
+		t.Errorf("unexpected metadata: %+v", bom.Metadata)
+	}
+	if len(bom.Components) != 4 {
+		t.Fatalf("expected 4 components, got %d", len(bom.Components))
+	}
+
+	babel, dual, custom, unlicensed := bom.Components[0], bom.Components[1], bom.Components[2], bom.Components[3]
+	if babel.Group != "@babel" || babel.Name != "core" || babel.Scope != "optional" || babel.PURL != "pkg:npm/%40babel/core@7.22.0" {
+		t.Errorf("unexpected scoped package: %+v", babel)
+	}
+	if len(babel.Licenses) != 1 || babel.Licenses[0].License.ID != "MIT" {
+		t.Errorf("MIT isn't recorded by identifier: %+v", babel.Licenses)
+	}
+	if dual.Scope != "required" || len(dual.Licenses) != 1 || dual.Licenses[0].Expression != "MIT OR Apache-2.0" {
+		t.Errorf("dual license isn't recorded as an expression: %+v", dual)
+	}
+	if len(custom.Licenses) != 1 || custom.Licenses[0].License.Name != "Custom License" {
+		t.Errorf("custom license isn't recorded by name: %+v", custom.Licenses)
+	}
+	if len(unlicensed.Licenses) != 0 {
+		t.Errorf("unlicensed package has licenses: %+v", unlicensed.Licenses)
+	}
+}
@@ -0,0 +1,251 @@
+package sbom
+
+import "strings"
+
+// License categories, from the least to the most restrictive
+const (
+	Permissive     = "permissive"
+	WeakCopyleft   = "weak-copyleft"
+	StrongCopyleft = "strong-copyleft"
+	Unknown        = "unknown"
+)
+
+// restrictiveness orders the categories, with a license that isn't
+// recognized counting as the most restrictive until someone checks it
+var restrictiveness = map[string]int{
+	Permissive:     0,
+	WeakCopyleft:   1,
+	StrongCopyleft: 2,
+	Unknown:        3,
+}
+
+// licenses are the SPDX identifiers the categories are known for, without
+// their -only and -or-later suffixes
+var licenses = map[string]string{
+	"0BSD":               Permissive,
+	"Apache-1.1":         Permissive,
+	"Apache-2.0":         Permissive,
+	"Artistic-2.0":       Permissive,
+	"BlueOak-1.0.0":      Permissive,
+	"BSD-1-Clause":       Permissive,
+	"BSD-2-Clause":       Permissive,
+	"BSD-3-Clause":       Permissive,
+	"BSD-3-Clause-Clear": Permissive,
+	"BSL-1.0":            Permissive,
+	"CC-BY-3.0":          Permissive,
+	"CC-BY-4.0":          Permissive,
+	"CC0-1.0":            Permissive,
+	"ISC":                Permissive,
+	"MIT":                Permissive,
+	"MIT-0":              Permissive,
+	"NCSA":               Permissive,
+	"OpenSSL":            Permissive,
+	"PostgreSQL":         Permissive,
+	"PSF-2.0":            Permissive,
+	"Python-2.0":         Permissive,
+	"Unicode-DFS-2016":   Permissive,
+	"Unlicense":          Permissive,
+	"UPL-1.0":            Permissive,
+	"W3C":                Permissive,
+	"WTFPL":              Permissive,
+	"X11":                Permissive,
+	"Zlib":               Permissive,
+
+	"CDDL-1.0":     WeakCopyleft,
+	"CDDL-1.1":     WeakCopyleft,
+	"CPL-1.0":      WeakCopyleft,
+	"EPL-1.0":      WeakCopyleft,
+	"EPL-2.0":      WeakCopyleft,
+	"LGPL-2.0":     WeakCopyleft,
+	"LGPL-2.1":     WeakCopyleft,
+	"LGPL-3.0":     WeakCopyleft,
+	"MPL-1.1":      WeakCopyleft,
+	"MPL-2.0":      WeakCopyleft,
+	"CC-BY-SA-4.0": WeakCopyleft,
+
+	"AGPL-1.0": StrongCopyleft,
+	"AGPL-3.0": StrongCopyleft,
+	"EUPL-1.1": StrongCopyleft,
+	"EUPL-1.2": StrongCopyleft,
+	"GPL-1.0":  StrongCopyleft,
+	"GPL-2.0":  StrongCopyleft,
+	"GPL-3.0":  StrongCopyleft,
+	"OSL-3.0":  StrongCopyleft,
+	"SSPL-1.0": StrongCopyleft,
+}
+
+// aliases are names packages give their licenses that aren't SPDX
+// identifiers, lower-cased
+var aliases = map[string]string{
+	"apache 2.0":              "Apache-2.0",
+	"apache-2":                "Apache-2.0",
+	"apache license 2.0":      "Apache-2.0",
+	"apache software license": "Apache-2.0",
+	"bsd":                     "BSD-3-Clause",
+	"new bsd":                 "BSD-3-Clause",
+	"simplified bsd":          "BSD-2-Clause",
+	"mit license":             "MIT",
+	"expat":                   "MIT",
+	"isc license":             "ISC",
+	"mpl 2.0":                 "MPL-2.0",
+	"gpl":                     "GPL-3.0",
+	"gplv2":                   "GPL-2.0",
+	"gplv3":                   "GPL-3.0",
+	"lgpl":                    "LGPL-3.0",
+	"agpl":                    "AGPL-3.0",
+	"public domain":           "Unlicense",
+}
+
+// linkingExceptions are SPDX exceptions that let a copyleft library be
+// linked into code under another license
+var linkingExceptions = map[string]bool{
+	"classpath-exception-2.0": true,
+	"gcc-exception-3.1":       true,
+	"llvm-exception":          true,
+}
+
+// Identifier returns the SPDX identifier of a license name, or "" when it
+// isn't one this package knows
+func Identifier(name string) string {
+	name = strings.TrimSpace(name)
+	if id, ok := aliases[strings.ToLower(name)]; ok {
+		return id
+	}
+	base, suffix := splitSuffix(name)
+	for id := range licenses {
+		if strings.EqualFold(id, base) {
+			return id + suffix
+		}
+	}
+	return ""
+}
+
+// splitSuffix splits the -only, -or-later or + off a license identifier
+func splitSuffix(id string) (base, suffix string) {
+	for _, suffix := range []string{"-only", "-or-later", "+"} {
+		if strings.HasSuffix(id, suffix) {
+			return strings.TrimSuffix(id, suffix), suffix
+		}
+	}
+	return id, ""
+}
+
+// categoryOf returns the category of a license name
+func categoryOf(name string) string {
+	base, _ := splitSuffix(Identifier(name))
+	if category, ok := licenses[base]; ok {
+		return category
+	}
+	return Unknown
+}
+
+// Classify returns the category of an SPDX license expression. Of licenses
+// joined with OR the least restrictive applies, since the choice is the
+// user's, and of licenses joined with AND the most restrictive does.
+func Classify(expression string) string {
+	if id := Identifier(expression); id != "" {
+		return categoryOf(id)
+	}
+	p := &parser{tokens: tokenize(expression)}
+	category := p.or()
+	if category == "" || p.pos < len(p.tokens) {
+		return Unknown
+	}
+	return category
+}
+
+// tokenize splits a license expression into identifiers, operators and
+// parentheses
+func tokenize(expression string) []string {
+	expression = strings.NewReplacer("(", " ( ", ")", " ) ").Replace(expression)
+	return strings.Fields(expression)
+}
+
+// parser reads a license expression by recursive descent, returning the
+// category of each part rather than a tree
+type parser struct {
+	tokens []string
+	pos    int
+}
+
+// or reads licenses joined with OR
+func (p *parser) or() string {
+	category := p.and()
+	for category != "" && p.accept("OR") {
+		next := p.and()
+		if next == "" {
+			return ""
+		}
+		if restrictiveness[next] < restrictiveness[category] {
+			category = next
+		}
+	}
+	return category
+}
+
+// and reads licenses joined with AND
+func (p *parser) and() string {
+	category := p.license()
+	for category != "" && p.accept("AND") {
+		next := p.license()
+		if next == "" {
+			return ""
+		}
+		if restrictiveness[next] > restrictiveness[category] {
+			category = next
+		}
+	}
+	return category
+}
+
+// license reads a license, with its exception, or a parenthesized
+// expression
+func (p *parser) license() string {
+	if p.pos >= len(p.tokens) {
+		return ""
+	}
+	token := p.tokens[p.pos]
+	p.pos++
+	if token == "(" {
+		category := p.or()
+		if !p.accept(")") {
+			return ""
+		}
+		return category
+	}
+	if token == ")" || isOperator(token) {
+		return ""
+	}
+
+	category := categoryOf(token)
+	if p.accept("WITH") {
+		if p.pos >= len(p.tokens) {
+			return ""
+		}
+		exception := p.tokens[p.pos]
+		p.pos++
+		if category == StrongCopyleft && linkingExceptions[strings.ToLower(exception)] {
+			category = WeakCopyleft
+		}
+	}
+	return category
+}
+
+// accept moves past the next token when it's the given operator or
+// parenthesis
+func (p *parser) accept(token string) bool {
+	if p.pos < len(p.tokens) && strings.EqualFold(p.tokens[p.pos], token) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// isOperator returns true for the operators of a license expression
+func isOperator(token string) bool {
+	switch strings.ToUpper(token) {
+	case "AND", "OR", "WITH":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,47 @@
+package sbom
+
+import "testing"
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		expression string
+		want       string
+	}{
+		{"MIT", Permissive},
+		{"apache-2.0", Permissive},
+		{"Apache License 2.0", Permissive},
+		{"LGPL-2.1-or-later", WeakCopyleft},
+		{"GPL-3.0-only", StrongCopyleft},
+		{"GPL-2.0+", StrongCopyleft},
+		{"MIT OR GPL-3.0", Permissive},
+		{"MIT AND GPL-3.0", StrongCopyleft},
+		{"(MIT OR Apache-2.0) AND MPL-2.0", WeakCopyleft},
+		{"GPL-2.0-only WITH Classpath-exception-2.0", WeakCopyleft},
+		{"MIT AND LicenseRef-custom", Unknown},
+		{"MIT OR LicenseRef-custom", Permissive},
+		{"", Unknown},
+		{"non-standard", Unknown},
+		{"(MIT", Unknown},
+		{"MIT OR", Unknown},
+	}
+	for _, tt := range tests {
+		if got := Classify(tt.expression); got != tt.want {
+			t.Errorf("Classify(%q) = %s, want %s", tt.expression, got, tt.want)
+		}
+	}
+}
+
+func TestIdentifier(t *testing.T) {
+	tests := map[string]string{
+		"mit":               "MIT",
+		"bsd-3-clause":      "BSD-3-Clause",
+		"gpl-2.0-or-later":  "GPL-2.0-or-later",
+		"Simplified BSD":    "BSD-2-Clause",
+		"LicenseRef-custom": "",
+	}
+	for name, want := range tests {
+		if got := Identifier(name); got != want {
+			t.Errorf("Identifier(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,299 @@
+// Package sbom builds a software bill of materials from the manifests
+// anywhere in a repository: every dependency with its license, flagged
+// when the license needs attention, and exported as CycloneDX JSON.
+package sbom
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"workspace/internal/depscan"
+)
+
+// Manifests are the files a bill of materials is built from. A
+// package.json is only read when no package-lock.json is beside it, since
+// the lockfile pins every package that's installed.
+var Manifests = []string{"go.mod", "package-lock.json", "package.json", "requirements.txt"}
+
+const (
+	// MaxManifests caps how many manifests are read from one repository
+	MaxManifests = 50
+
+	// MaxLookups caps how many licenses are looked up for one bill of
+	// materials; the rest stay unknown until it's generated again
+	MaxLookups = 500
+
+	// maxLookupErrors caps how many failed lookups are recorded
+	maxLookupErrors = 20
+)
+
+// skippedDirs hold installed or vendored copies of packages, whose
+// manifests aren't the repository's own
+var skippedDirs = map[string]bool{
+	"node_modules": true,
+	"vendor":       true,
+	"testdata":     true,
+	".git":         true,
+}
+
+// SelectManifests returns the manifests among a repository's files, in
+// order, up to MaxManifests
+func SelectManifests(files []string) []string {
+	locked := map[string]bool{}
+	var found []string
+	for _, file := range files {
+		if !isManifest(file) {
+			continue
+		}
+		if path.Base(file) == "package-lock.json" {
+			locked[path.Dir(file)] = true
+		}
+		found = append(found, file)
+	}
+	sort.Strings(found)
+
+	var manifests []string
+	for _, file := range found {
+		if path.Base(file) == "package.json" && locked[path.Dir(file)] {
+			continue
+		}
+		if len(manifests) == MaxManifests {
+			break
+		}
+		manifests = append(manifests, file)
+	}
+	return manifests
+}
+
+// isManifest returns true for a manifest outside of installed packages
+func isManifest(file string) bool {
+	for _, dir := range strings.Split(path.Dir(file), "/") {
+		if skippedDirs[dir] {
+			return false
+		}
+	}
+	base := path.Base(file)
+	for _, name := range Manifests {
+		if base == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Component is a dependency in a bill of materials
+type Component struct {
+	depscan.Dependency
+}
+
+// Key identifies a package version across manifests
+func (c Component) Key() string {
+	return c.Ecosystem + ":" + c.Name + "@" + c.Version
+}
+
+// Category returns the category of the component's license
+func (c Component) Category() string {
+	return Classify(c.License)
+}
+
+// Flag returns why the component's license needs attention before the
+// project is distributed, or "" when it doesn't. Copyleft only matters for
+// code that ships, so it isn't flagged on development dependencies.
+func (c Component) Flag() string {
+	switch c.Category() {
+	case Unknown:
+		if c.License == "" {
+			return "No license found; check the package's terms before using it"
+		}
+		return "License " + c.License + " isn't recognized; check its terms before using it"
+	case StrongCopyleft:
+		if !c.Dev {
+			return "Strong copyleft; distributing the project may require releasing its source under " + c.License
+		}
+	case WeakCopyleft:
+		if !c.Dev {
+			return "Weak copyleft; changes to this package must be shared under " + c.License
+		}
+	}
+	return ""
+}
+
+// PURL returns the component's package URL
+func (c Component) PURL() string {
+	var kind string
+	switch c.Ecosystem {
+	case depscan.EcosystemGo:
+		kind = "golang"
+	case depscan.EcosystemNPM:
+		kind = "npm"
+	case depscan.EcosystemPyPI:
+		kind = "pypi"
+	default:
+		kind = strings.ToLower(c.Ecosystem)
+	}
+	segments := strings.Split(c.Name, "/")
+	for i, segment := range segments {
+		segments[i] = strings.ReplaceAll(url.PathEscape(segment), "@", "%40")
+	}
+	return fmt.Sprintf("pkg:%s/%s@%s", kind, strings.Join(segments, "/"), url.PathEscape(c.Version))
+}
+
+// Components merges the dependencies found in a repository's manifests into
+// one component per package version, ordered by ecosystem and name. A
+// package is direct when any manifest depends on it directly, and only for
+// development when every manifest says so.
+func Components(deps []depscan.Dependency) []Component {
+	index := map[string]int{}
+	var components []Component
+	for _, dep := range deps {
+		component := Component{Dependency: dep}
+		i, ok := index[component.Key()]
+		if !ok {
+			index[component.Key()] = len(components)
+			components = append(components, component)
+			continue
+		}
+		existing := &components[i]
+		existing.Indirect = existing.Indirect && dep.Indirect
+		existing.Dev = existing.Dev && dep.Dev
+		if existing.License == "" {
+			existing.License = dep.License
+		}
+	}
+
+	sort.SliceStable(components, func(i, j int) bool {
+		if components[i].Ecosystem != components[j].Ecosystem {
+			return components[i].Ecosystem < components[j].Ecosystem
+		}
+		if components[i].Name != components[j].Name {
+			return components[i].Name < components[j].Name
+		}
+		return components[i].Version < components[j].Version
+	})
+	return components
+}
+
+// Licenses looks up the licenses of package versions in deps.dev, which
+// knows them for every ecosystem a bill of materials covers
+type Licenses struct {
+	DepsDev string
+	client  *http.Client
+}
+
+// NewLicenses creates a license lookup against the public deps.dev API
+func NewLicenses() *Licenses {
+	return &Licenses{
+		DepsDev: "https://api.deps.dev",
+		client:  &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+// Resolve fills in the licenses the manifests didn't record, first from
+// known, which maps a component's Key to a license found earlier, then from
+// deps.dev up to MaxLookups. Failed lookups are returned rather than
+// failing the whole resolution; only a cancelled context does that.
+func (l *Licenses) Resolve(ctx context.Context, components []Component, known map[string]string) ([]string, error) {
+	var failed []string
+	lookups := 0
+	for i := range components {
+		component := &components[i]
+		if component.License != "" {
+			continue
+		}
+		if license, ok := known[component.Key()]; ok {
+			component.License = license
+			continue
+		}
+		if lookups >= MaxLookups {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		lookups++
+		license, err := l.Lookup(ctx, component.Dependency)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			if len(failed) < maxLookupErrors {
+				failed = append(failed, err.Error())
+			}
+			continue
+		}
+		component.License = license
+	}
+	return failed, nil
+}
+
+// Lookup returns the license of a package version as an SPDX expression, or
+// "" when deps.dev doesn't know the version or its license
+func (l *Licenses) Lookup(ctx context.Context, dep depscan.Dependency) (string, error) {
+	var system string
+	switch dep.Ecosystem {
+	case depscan.EcosystemGo:
+		system = "go"
+	case depscan.EcosystemNPM:
+		system = "npm"
+	case depscan.EcosystemPyPI:
+		system = "pypi"
+	default:
+		return "", fmt.Errorf("unsupported ecosystem %s", dep.Ecosystem)
+	}
+	endpoint := fmt.Sprintf("%s/v3/systems/%s/packages/%s/versions/%s",
+		l.DepsDev, system, url.PathEscape(dep.Name), url.PathEscape(dep.Version))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	client := l.client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to look up the license of %s: %w", dep.Name, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return "", nil
+	default:
+		return "", fmt.Errorf("failed to look up the license of %s: deps.dev returned %s", dep.Name, resp.Status)
+	}
+
+	var version struct {
+		Licenses []string `json:"licenses"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&version); err != nil {
+		return "", fmt.Errorf("failed to read the license of %s: %w", dep.Name, err)
+	}
+
+	var found []string
+	for _, license := range version.Licenses {
+		if license = strings.TrimSpace(license); license != "" {
+			found = append(found, license)
+		}
+	}
+	if len(found) > 1 {
+		for i, license := range found {
+			if strings.Contains(license, " ") {
+				found[i] = "(" + license + ")"
+			}
+		}
+	}
+	return strings.Join(found, " AND "), nil
+}
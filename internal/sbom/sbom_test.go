@@ -0,0 +1,126 @@
+package sbom
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"workspace/internal/depscan"
+)
+
+func TestSelectManifests(t *testing.T) {
+	files := []string{
+		"README.md",
+		"go.mod",
+		"web/package.json",
+		"web/package-lock.json",
+		"tools/package.json",
+		"web/node_modules/express/package.json",
+		"vendor/golang.org/x/text/go.mod",
+		"api/requirements.txt",
+	}
+	got := strings.Join(SelectManifests(files), ",")
+	want := "api/requirements.txt,go.mod,tools/package.json,web/package-lock.json"
+	if got != want {
+		t.Errorf("SelectManifests = %s, want %s", got, want)
+	}
+}
+
+func TestComponents(t *testing.T) {
+	components := Components([]depscan.Dependency{
+		{Ecosystem: depscan.EcosystemNPM, Name: "express", Version: "4.18.2", Manifest: "web/package-lock.json", Indirect: true, Dev: true},
+		{Ecosystem: depscan.EcosystemGo, Name: "github.com/pkg/errors", Version: "v0.9.1", Manifest: "go.mod"},
+		{Ecosystem: depscan.EcosystemNPM, Name: "express", Version: "4.18.2", Manifest: "admin/package-lock.json", License: "MIT"},
+	})
+	if len(components) != 2 {
+		t.Fatalf("expected 2 components, got %d: %+v", len(components), components)
+	}
+	express := components[1]
+	if components[0].Name != "github.com/pkg/errors" || express.Name != "express" {
+		t.Errorf("components aren't ordered by ecosystem: %+v", components)
+	}
+	if express.Indirect || express.Dev || express.License != "MIT" || express.Manifest != "web/package-lock.json" {
+		t.Errorf("express wasn't merged: %+v", express)
+	}
+}
+
+func TestFlag(t *testing.T) {
+	component := func(license string, dev bool) Component {
+		return Component{Dependency: depscan.Dependency{Name: "pkg", License: license, Dev: dev}}
+	}
+	if flag := component("MIT", false).Flag(); flag != "" {
+		t.Errorf("MIT flagged: %s", flag)
+	}
+	if flag := component("GPL-3.0-only", false).Flag(); !strings.HasPrefix(flag, "Strong copyleft") {
+		t.Errorf("GPL-3.0-only flag = %q, want strong copyleft", flag)
+	}
+	if flag := component("GPL-3.0-only", true).Flag(); flag != "" {
+		t.Errorf("GPL-3.0-only development dependency flagged: %s", flag)
+	}
+	if flag := component("", true).Flag(); !strings.HasPrefix(flag, "No license found") {
+		t.Errorf("missing license flag = %q", flag)
+	}
+}
+
+func TestPURL(t *testing.T) {
+	tests := []struct {
+		dep  depscan.Dependency
+		want string
+	}{
+		{depscan.Dependency{Ecosystem: depscan.EcosystemGo, Name: "github.com/pkg/errors", Version: "v0.9.1"}, "pkg:golang/github.com/pkg/errors@v0.9.1"},
+		{depscan.Dependency{Ecosystem: depscan.EcosystemNPM, Name: "@babel/core", Version: "7.22.0"}, "pkg:npm/%40babel/core@7.22.0"},
+		{depscan.Dependency{Ecosystem: depscan.EcosystemPyPI, Name: "django", Version: "4.1.0"}, "pkg:pypi/django@4.1.0"},
+	}
+	for _, tt := range tests {
+		if got := (Component{Dependency: tt.dep}).PURL(); got != tt.want {
+			t.Errorf("PURL(%s) = %s, want %s", tt.dep.Name, got, tt.want)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.EscapedPath())
+		switch r.URL.EscapedPath() {
+		case "/v3/systems/go/packages/github.com%2Fpkg%2Ferrors/versions/v0.9.1":
+			w.Write([]byte(`{"licenses": ["BSD-2-Clause"]}`))
+		case "/v3/systems/npm/packages/dual/versions/1.0.0":
+			w.Write([]byte(`{"licenses": ["MIT OR Apache-2.0", "ISC"]}`))
+		case "/v3/systems/pypi/packages/broken/versions/1.0":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	components := []Component{
+		{Dependency: depscan.Dependency{Ecosystem: depscan.EcosystemGo, Name: "github.com/pkg/errors", Version: "v0.9.1"}},
+		{Dependency: depscan.Dependency{Ecosystem: depscan.EcosystemNPM, Name: "dual", Version: "1.0.0"}},
+		{Dependency: depscan.Dependency{Ecosystem: depscan.EcosystemNPM, Name: "express", Version: "4.18.2", License: "MIT"}},
+		{Dependency: depscan.Dependency{Ecosystem: depscan.EcosystemNPM, Name: "cached", Version: "2.0.0"}},
+		{Dependency: depscan.Dependency{Ecosystem: depscan.EcosystemNPM, Name: "missing", Version: "1.0.0"}},
+		{Dependency: depscan.Dependency{Ecosystem: depscan.EcosystemPyPI, Name: "broken", Version: "1.0"}},
+	}
+	licenses := &Licenses{DepsDev: server.URL}
+	failed, err := licenses.Resolve(context.Background(), components, map[string]string{"npm:cached@2.0.0": "Zlib"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 4 {
+		t.Errorf("looked up %d licenses, want 4: %v", len(paths), paths)
+	}
+	if len(failed) != 1 || !strings.Contains(failed[0], "broken") {
+		t.Errorf("failed = %v, want the broken package", failed)
+	}
+
+	want := []string{"BSD-2-Clause", "(MIT OR Apache-2.0) AND ISC", "MIT", "Zlib", "", ""}
+	for i, component := range components {
+		if component.License != want[i] {
+			t.Errorf("%s license = %q, want %q", component.Name, component.License, want[i])
+		}
+	}
+}
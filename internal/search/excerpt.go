@@ -0,0 +1,63 @@
+package search
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// excerptWidth is about how many characters of a line an excerpt shows
+const excerptWidth = 160
+
+// Excerpt returns the first line of text containing one of the terms'
+// words, shortened around the match, and its 1-based line number. Without
+// a match it returns the first line that isn't blank, with line 0.
+func Excerpt(text string, terms []string) (int, string) {
+	var words []string
+	for _, term := range terms {
+		words = append(words, strings.FieldsFunc(strings.ToLower(term), isSeparator)...)
+	}
+
+	first := ""
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if first == "" {
+			first = line
+		}
+		lower := strings.ToLower(line)
+		for _, word := range words {
+			if at := strings.Index(lower, word); at >= 0 {
+				return i + 1, around(line, at)
+			}
+		}
+	}
+	return 0, around(first, 0)
+}
+
+// around shortens a line to about excerptWidth characters, keeping the
+// byte offset at in view
+func around(line string, at int) string {
+	if utf8.RuneCountInString(line) <= excerptWidth {
+		return line
+	}
+	start := max(0, at-excerptWidth/4)
+	for start > 0 && !utf8.RuneStart(line[start]) {
+		start--
+	}
+	end := start
+	for count := 0; end < len(line) && count < excerptWidth; count++ {
+		_, size := utf8.DecodeRuneInString(line[end:])
+		end += size
+	}
+
+	excerpt := line[start:end]
+	if start > 0 {
+		excerpt = "…" + excerpt
+	}
+	if end < len(line) {
+		excerpt += "…"
+	}
+	return excerpt
+}
@@ -0,0 +1,175 @@
+// Package search parses workspace search queries and builds the SQLite
+// FTS5 expressions and result excerpts for them.
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Kinds of indexed documents
+const (
+	KindIssue       = "issue"
+	KindPullRequest = "pr"
+	KindComment     = "comment"
+	KindCommit      = "commit"
+	KindCode        = "code"
+)
+
+// States of issues and pull requests, whatever their finer status
+const (
+	StateOpen   = "open"
+	StateClosed = "closed"
+	StateMerged = "merged"
+)
+
+// kindNames maps what is: and type: filters accept to a kind
+var kindNames = map[string]string{
+	"issue": KindIssue, "issues": KindIssue,
+	"pr": KindPullRequest, "prs": KindPullRequest, "pull": KindPullRequest, "pullrequest": KindPullRequest,
+	"comment": KindComment, "comments": KindComment,
+	"commit": KindCommit, "commits": KindCommit,
+	"code": KindCode, "file": KindCode, "files": KindCode,
+}
+
+// Query is a search split into its free text and the filters it sets
+type Query struct {
+	Terms    []string // Words and quoted phrases to match
+	Repo     string   // repo: name or ID
+	Author   string   // author: name, email or handle
+	State    string   // is:open, is:closed or is:merged
+	Kind     string   // is: or type: issue, pr, comment, commit or code
+	Language string   // language: of files
+}
+
+// Parse reads a search like `repo:api author:sam is:open "race condition"`.
+// Filters it doesn't recognize are searched for as text.
+func Parse(raw string) Query {
+	var q Query
+	for _, token := range tokenize(raw) {
+		key, value, ok := strings.Cut(token, ":")
+		value = strings.Trim(value, `"`)
+		if !ok || value == "" {
+			q.addTerm(token)
+			continue
+		}
+		switch strings.ToLower(key) {
+		case "repo":
+			q.Repo = value
+		case "author", "by":
+			q.Author = value
+		case "language", "lang":
+			q.Language = strings.ToLower(value)
+		case "type", "in":
+			if kind, ok := kindNames[strings.ToLower(value)]; ok {
+				q.Kind = kind
+			} else {
+				q.addTerm(token)
+			}
+		case "is", "state":
+			switch value = strings.ToLower(value); value {
+			case StateOpen, StateClosed, StateMerged:
+				q.State = value
+			default:
+				if kind, ok := kindNames[value]; ok {
+					q.Kind = kind
+				} else {
+					q.addTerm(token)
+				}
+			}
+		default:
+			q.addTerm(token)
+		}
+	}
+	return q
+}
+
+// addTerm adds text to match, dropping the quotes around phrases
+func (q *Query) addTerm(token string) {
+	if term := strings.TrimSpace(strings.Trim(token, `"`)); term != "" {
+		q.Terms = append(q.Terms, term)
+	}
+}
+
+// IsEmpty returns true when the query neither has text nor filters
+func (q Query) IsEmpty() bool {
+	return len(q.Terms) == 0 && q.Repo == "" && q.Author == "" &&
+		q.State == "" && q.Kind == "" && q.Language == ""
+}
+
+// Text returns the query's free text
+func (q Query) Text() string {
+	return strings.Join(q.Terms, " ")
+}
+
+// Match returns an FTS5 expression requiring every term, or "" when there
+// are none. Terms are reduced to the words the index holds and quoted, so
+// punctuation can't be read as FTS5 syntax, and single words match as
+// prefixes so results show up while typing.
+func (q Query) Match() string {
+	var parts []string
+	for _, term := range q.Terms {
+		words := strings.FieldsFunc(term, isSeparator)
+		if len(words) == 0 {
+			continue
+		}
+		phrase := `"` + strings.Join(words, " ") + `"`
+		if len(words) == 1 {
+			phrase += "*"
+		}
+		parts = append(parts, phrase)
+	}
+	return strings.Join(parts, " AND ")
+}
+
+// WithKind returns a search limited to a kind instead of the kind it
+// asked for, or to no kind when kind is ""
+func WithKind(raw, kind string) string {
+	var tokens []string
+	for _, token := range tokenize(raw) {
+		key, value, ok := strings.Cut(token, ":")
+		switch strings.ToLower(key) {
+		case "is", "type", "in", "state":
+			if _, isKind := kindNames[strings.ToLower(strings.Trim(value, `"`))]; ok && isKind {
+				continue
+			}
+		}
+		tokens = append(tokens, token)
+	}
+	if kind != "" {
+		tokens = append(tokens, "is:"+kind)
+	}
+	return strings.Join(tokens, " ")
+}
+
+// tokenize splits a search on spaces, keeping quoted phrases together even
+// when they follow a filter's colon
+func tokenize(raw string) []string {
+	var tokens []string
+	var current strings.Builder
+	quoted := false
+	for _, r := range raw {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			current.WriteRune(r)
+		case unicode.IsSpace(r) && !quoted:
+			if current.Len() > 0 {
+				tokens = append(tokens, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if current.Len() > 0 {
+		tokens = append(tokens, current.String())
+	}
+	return tokens
+}
+
+// isSeparator reports whether the FTS5 tokenizer splits words at r. It
+// keeps letters, digits and underscores, so identifiers stay whole.
+func isSeparator(r rune) bool {
+	return !(unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_')
+}
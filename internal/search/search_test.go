@@ -0,0 +1,83 @@
+package search
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	q := Parse(`repo:api author:sam is:open "race condition" lang:Go flaky`)
+	want := Query{
+		Terms:    []string{"race condition", "flaky"},
+		Repo:     "api",
+		Author:   "sam",
+		State:    StateOpen,
+		Language: "go",
+	}
+	if !reflect.DeepEqual(q, want) {
+		t.Errorf("Parse() = %+v, want %+v", q, want)
+	}
+
+	q = Parse(`is:pr type:commit is:nothing http://example.com author:"Sam Lee"`)
+	if q.Kind != KindCommit {
+		t.Errorf("Kind = %q, want the last kind filter", q.Kind)
+	}
+	if q.Author != "Sam Lee" {
+		t.Errorf("Author = %q", q.Author)
+	}
+	if !reflect.DeepEqual(q.Terms, []string{"is:nothing", "http://example.com"}) {
+		t.Errorf("Terms = %q", q.Terms)
+	}
+
+	if !Parse("  ").IsEmpty() || Parse("is:merged").IsEmpty() {
+		t.Error("IsEmpty() is wrong")
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := map[string]string{
+		"":                     "",
+		"parse":                `"parse"*`,
+		`"race condition" fix`: `"race condition" AND "fix"*`,
+		`foo.Bar() AND NOT x`:  `"foo Bar" AND "AND"* AND "NOT"* AND "x"*`,
+		`snake_case "*" -- ^`:  `"snake_case"*`,
+		`repo:web "quote""d"`:  `"quote d"`,
+	}
+	for raw, want := range tests {
+		if got := Parse(raw).Match(); got != want {
+			t.Errorf("Parse(%q).Match() = %q, want %q", raw, got, want)
+		}
+	}
+}
+
+func TestWithKind(t *testing.T) {
+	if got := WithKind(`race is:issue  author:sam type:commit is:open`, KindPullRequest); got != "race author:sam is:open is:pr" {
+		t.Errorf("WithKind() = %q", got)
+	}
+	if got := WithKind(`"a b" in:code`, ""); got != `"a b"` {
+		t.Errorf("WithKind() without a kind = %q", got)
+	}
+}
+
+func TestExcerpt(t *testing.T) {
+	text := "package main\n\nfunc main() {\n\tServeHTTP(w, r)\n}\n"
+	line, excerpt := Excerpt(text, []string{"servehttp"})
+	if line != 4 || excerpt != "ServeHTTP(w, r)" {
+		t.Errorf("Excerpt() = %d, %q", line, excerpt)
+	}
+
+	line, excerpt = Excerpt(text, []string{"missing"})
+	if line != 0 || excerpt != "package main" {
+		t.Errorf("Excerpt() without a match = %d, %q", line, excerpt)
+	}
+
+	long := strings.Repeat("é", 300) + " needle " + strings.Repeat("x", 300)
+	_, excerpt = Excerpt(long, []string{"needle"})
+	if !strings.Contains(excerpt, "needle") || !strings.HasPrefix(excerpt, "…") || !strings.HasSuffix(excerpt, "…") {
+		t.Errorf("long line excerpt = %q", excerpt)
+	}
+	if !strings.HasPrefix(strings.TrimPrefix(excerpt, "…"), "é") {
+		t.Errorf("excerpt starts inside a character: %q", excerpt)
+	}
+}
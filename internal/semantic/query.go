@@ -0,0 +1,38 @@
+package semantic
+
+import (
+	"regexp"
+	"strings"
+)
+
+// codeWords are words that suggest a question is about the code itself
+var codeWords = map[string]bool{
+	"code": true, "function": true, "func": true, "method": true, "class": true,
+	"struct": true, "type": true, "interface": true, "file": true, "files": true,
+	"module": true, "package": true, "implement": true, "implemented": true,
+	"implementation": true, "bug": true, "error": true, "exception": true,
+	"panic": true, "test": true, "tests": true, "handler": true, "endpoint": true,
+	"route": true, "api": true, "variable": true, "import": true, "defined": true,
+	"called": true, "calls": true, "refactor": true, "compile": true,
+	"query": true, "schema": true, "config": true, "logic": true,
+}
+
+// identifierPattern matches words that look like code: snake_case,
+// camelCase, calls, or file names
+var identifierPattern = regexp.MustCompile(`\w+_\w+|[a-z][A-Z]\w*|\w+\(\)|\w+\.(go|js|ts|tsx|py|rb|rs|java|html|css|sql|json|yaml|yml|md)\b`)
+
+// IsCodeQuery reports whether a chat message looks like a question about
+// code, worth retrieving snippets for
+func IsCodeQuery(text string) bool {
+	if strings.Contains(text, "`") || identifierPattern.MatchString(text) {
+		return true
+	}
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z')
+	}) {
+		if codeWords[word] {
+			return true
+		}
+	}
+	return false
+}
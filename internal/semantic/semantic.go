@@ -0,0 +1,128 @@
+// Package semantic splits source files into chunks for embedding and ranks
+// stored chunk vectors against a query vector.
+package semantic
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"math"
+	"sort"
+	"strings"
+)
+
+const (
+	// ChunkLines is how many lines each chunk covers
+	ChunkLines = 40
+
+	// ChunkOverlap is how many lines consecutive chunks share, so code near
+	// a boundary appears whole in at least one chunk
+	ChunkOverlap = 8
+
+	// MaxChunkBytes keeps a chunk of very long lines within what embedding
+	// models accept
+	MaxChunkBytes = 4000
+)
+
+// Chunk is a range of lines from one file
+type Chunk struct {
+	StartLine int // 1-indexed, inclusive
+	EndLine   int
+	Text      string
+}
+
+// Split breaks a file into overlapping line windows. Blank-only windows are
+// dropped.
+func Split(content string) []Chunk {
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	var chunks []Chunk
+	for start := 0; start < len(lines); start += ChunkLines - ChunkOverlap {
+		end := min(start+ChunkLines, len(lines))
+		text := strings.Join(lines[start:end], "\n")
+		if strings.TrimSpace(text) != "" {
+			if len(text) > MaxChunkBytes {
+				text = strings.ToValidUTF8(text[:MaxChunkBytes], "")
+			}
+			chunks = append(chunks, Chunk{StartLine: start + 1, EndLine: end, Text: text})
+		}
+		if end == len(lines) {
+			break
+		}
+	}
+	return chunks
+}
+
+// EmbeddingText prefixes a chunk with its path, which carries a lot of
+// meaning for short or generic snippets
+func EmbeddingText(path string, chunk Chunk) string {
+	return path + "\n\n" + chunk.Text
+}
+
+// Encode stores a vector as base64 little-endian float32s
+func Encode(vector []float32) string {
+	buf := make([]byte, 4*len(vector))
+	for i, v := range vector {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(v))
+	}
+	return base64.StdEncoding.EncodeToString(buf)
+}
+
+// Decode reverses Encode
+func Decode(encoded string) ([]float32, error) {
+	buf, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if len(buf)%4 != 0 {
+		return nil, errors.New("vector has a partial value")
+	}
+	vector := make([]float32, len(buf)/4)
+	for i := range vector {
+		vector[i] = math.Float32frombits(binary.LittleEndian.Uint32(buf[4*i:]))
+	}
+	return vector, nil
+}
+
+// Cosine returns the cosine similarity of two vectors, or 0 when they
+// differ in length or either is zero
+func Cosine(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// Match is a ranked candidate
+type Match struct {
+	Index int // Position in the candidates passed to Rank
+	Score float64
+}
+
+// Rank scores each candidate against the query and returns the best limit
+// matches, highest first
+func Rank(query []float32, candidates [][]float32, limit int) []Match {
+	matches := make([]Match, 0, len(candidates))
+	for i, candidate := range candidates {
+		matches = append(matches, Match{Index: i, Score: Cosine(query, candidate)})
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Score > matches[j].Score
+	})
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
@@ -0,0 +1,95 @@
+package semantic
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	var lines []string
+	for i := 1; i <= 100; i++ {
+		lines = append(lines, fmt.Sprintf("line %d", i))
+	}
+	chunks := Split(strings.Join(lines, "\n") + "\n")
+
+	if len(chunks) != 3 {
+		t.Fatalf("got %d chunks, want 3", len(chunks))
+	}
+	if chunks[0].StartLine != 1 || chunks[0].EndLine != 40 {
+		t.Errorf("first chunk covers %d-%d", chunks[0].StartLine, chunks[0].EndLine)
+	}
+	if chunks[1].StartLine != 33 {
+		t.Errorf("second chunk starts at %d, want 33", chunks[1].StartLine)
+	}
+	last := chunks[len(chunks)-1]
+	if last.EndLine != 100 || !strings.HasSuffix(last.Text, "line 100") {
+		t.Errorf("last chunk ends at %d with %q", last.EndLine, last.Text[len(last.Text)-8:])
+	}
+
+	if got := Split("\n\n   \n"); len(got) != 0 {
+		t.Errorf("blank file produced %d chunks", len(got))
+	}
+	if got := Split(strings.Repeat("x", MaxChunkBytes*2)); len(got[0].Text) != MaxChunkBytes {
+		t.Errorf("long chunk is %d bytes", len(got[0].Text))
+	}
+}
+
+func TestEncodeDecode(t *testing.T) {
+	vector := []float32{0.5, -1.25, 3, float32(math.Pi)}
+	decoded, err := Decode(Encode(vector))
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	for i := range vector {
+		if decoded[i] != vector[i] {
+			t.Errorf("value %d = %v, want %v", i, decoded[i], vector[i])
+		}
+	}
+
+	if _, err := Decode("AAA="); err == nil {
+		t.Error("expected an error for a partial vector")
+	}
+}
+
+func TestRank(t *testing.T) {
+	query := []float32{1, 0}
+	candidates := [][]float32{
+		{0, 1},    // orthogonal
+		{1, 0.1},  // close
+		{-1, 0},   // opposite
+		{1, 0},    // identical
+		{1, 0, 0}, // wrong dimensions
+	}
+
+	matches := Rank(query, candidates, 2)
+	if len(matches) != 2 {
+		t.Fatalf("got %d matches, want 2", len(matches))
+	}
+	if matches[0].Index != 3 || matches[1].Index != 1 {
+		t.Errorf("ranked %v, want candidates 3 then 1", matches)
+	}
+	if math.Abs(matches[0].Score-1) > 1e-9 {
+		t.Errorf("identical vector scored %v", matches[0].Score)
+	}
+	if Cosine(query, candidates[4]) != 0 || Cosine(query, []float32{0, 0}) != 0 {
+		t.Error("mismatched or zero vectors should score 0")
+	}
+}
+
+func TestIsCodeQuery(t *testing.T) {
+	for text, want := range map[string]bool{
+		"Where are sessions validated in the auth handler?": true,
+		"what does `Rank` return":                           true,
+		"why is parseConfig slow":                           true,
+		"explain build_index":                               true,
+		"what's in main.go":                                 true,
+		"thanks, that helps!":                               false,
+		"hello":                                             false,
+	} {
+		if got := IsCodeQuery(text); got != want {
+			t.Errorf("IsCodeQuery(%q) = %v, want %v", text, got, want)
+		}
+	}
+}
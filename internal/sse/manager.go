@@ -0,0 +1,222 @@
+// Package sse tracks the server-sent event streams held open by browsers.
+// Every stream (AI responses, todo updates, collaborative editing) is opened
+// through a Manager so one user cannot hold enough connections to exhaust
+// the server's file descriptors, and so open streams can be monitored.
+package sse
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// HeartbeatInterval is how often idle streams are pinged so proxies
+	// don't close them
+	HeartbeatInterval = 30 * time.Second
+
+	// DefaultMaxPerUser leaves room for a few tabs, each with a chat and a
+	// todo stream open
+	DefaultMaxPerUser = 12
+
+	// DefaultMaxTotal caps streams across all users
+	DefaultMaxTotal = 1024
+)
+
+var (
+	// ErrTooManyStreams is returned when a user already has the most
+	// streams they are allowed open
+	ErrTooManyStreams = errors.New("too many open streams, close some tabs and try again")
+
+	// ErrServerBusy is returned when the server-wide limit is reached
+	ErrServerBusy = errors.New("server has too many open streams")
+
+	// ErrNotSupported is returned when the response can't be flushed
+	ErrNotSupported = errors.New("streaming not supported")
+)
+
+// Manager enforces stream limits and keeps counts for monitoring
+type Manager struct {
+	MaxPerUser int
+	MaxTotal   int
+
+	mu       sync.Mutex
+	streams  map[*Stream]struct{}
+	opened   uint64
+	rejected uint64
+}
+
+// Streams is the manager shared by every SSE endpoint
+var Streams = NewManager(DefaultMaxPerUser, DefaultMaxTotal)
+
+// NewManager creates a manager with the given limits. A limit of zero or
+// less disables it.
+func NewManager(maxPerUser, maxTotal int) *Manager {
+	return &Manager{
+		MaxPerUser: maxPerUser,
+		MaxTotal:   maxTotal,
+		streams:    make(map[*Stream]struct{}),
+	}
+}
+
+// Stream is one open event stream. It implements http.Flusher so handlers
+// that write events themselves can keep doing so.
+type Stream struct {
+	Kind   string // What the stream carries, e.g. "ai", "todos", "collab"
+	UserID string
+	Opened time.Time
+
+	w       http.ResponseWriter
+	flusher http.Flusher
+	ticker  *time.Ticker
+	manager *Manager
+	once    sync.Once
+}
+
+// Open registers a stream for the user and writes the event-stream headers.
+// When the stream can't be opened the error response has already been
+// written and the handler should return.
+func (m *Manager) Open(w http.ResponseWriter, r *http.Request, kind, userID string) (*Stream, error) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, ErrNotSupported.Error(), http.StatusInternalServerError)
+		return nil, ErrNotSupported
+	}
+
+	stream := &Stream{
+		Kind:    kind,
+		UserID:  userID,
+		Opened:  time.Now(),
+		w:       w,
+		flusher: flusher,
+		manager: m,
+	}
+	if err := m.register(stream); err != nil {
+		log.Printf("sse: rejected %s stream for user %s: %v", kind, userID, err)
+		status := http.StatusTooManyRequests
+		if errors.Is(err, ErrServerBusy) {
+			status = http.StatusServiceUnavailable
+		}
+		w.Header().Set("Retry-After", "30")
+		http.Error(w, err.Error(), status)
+		return nil, err
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // Disable Nginx buffering
+	stream.ticker = time.NewTicker(HeartbeatInterval)
+	return stream, nil
+}
+
+// register adds the stream if it fits within the limits
+func (m *Manager) register(stream *Stream) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.MaxTotal > 0 && len(m.streams) >= m.MaxTotal {
+		m.rejected++
+		return ErrServerBusy
+	}
+	if m.MaxPerUser > 0 && stream.UserID != "" {
+		count := 0
+		for open := range m.streams {
+			if open.UserID == stream.UserID {
+				count++
+			}
+		}
+		if count >= m.MaxPerUser {
+			m.rejected++
+			return ErrTooManyStreams
+		}
+	}
+
+	m.streams[stream] = struct{}{}
+	m.opened++
+	return nil
+}
+
+// Send writes an event whose payload may span several lines and flushes it
+func (s *Stream) Send(event, data string) {
+	s.SendID("", event, data)
+}
+
+// SendID sends an event with an ID, which browsers send back in the
+// Last-Event-ID header when they reconnect so missed events can be resent
+func (s *Stream) SendID(id, event, data string) {
+	if id != "" {
+		fmt.Fprintf(s.w, "id: %s\n", id)
+	}
+	fmt.Fprintf(s.w, "event: %s\n", event)
+	for _, line := range strings.Split(data, "\n") {
+		fmt.Fprintf(s.w, "data: %s\n", line)
+	}
+	fmt.Fprint(s.w, "\n")
+	s.flusher.Flush()
+}
+
+// Heartbeat fires every HeartbeatInterval; handlers waiting on events
+// select on it and call Ping
+func (s *Stream) Heartbeat() <-chan time.Time {
+	return s.ticker.C
+}
+
+// Ping sends the standard keepalive event
+func (s *Stream) Ping() {
+	s.Send("ping", "keepalive")
+}
+
+// Flush sends anything written to the response so far
+func (s *Stream) Flush() {
+	s.flusher.Flush()
+}
+
+// Close releases the stream's slot. It is safe to call more than once.
+func (s *Stream) Close() {
+	s.once.Do(func() {
+		if s.ticker != nil {
+			s.ticker.Stop()
+		}
+		s.manager.mu.Lock()
+		delete(s.manager.streams, s)
+		s.manager.mu.Unlock()
+	})
+}
+
+// Stats describes the streams currently open
+type Stats struct {
+	Active     int            `json:"active"`
+	Users      int            `json:"users"`
+	ByKind     map[string]int `json:"byKind"`
+	MaxPerUser int            `json:"maxPerUser"`
+	MaxTotal   int            `json:"maxTotal"`
+	Opened     uint64         `json:"opened"`   // Since startup
+	Rejected   uint64         `json:"rejected"` // Since startup, over a limit
+}
+
+// Stats returns a snapshot of the open streams
+func (m *Manager) Stats() Stats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats := Stats{
+		Active:     len(m.streams),
+		ByKind:     make(map[string]int),
+		MaxPerUser: m.MaxPerUser,
+		MaxTotal:   m.MaxTotal,
+		Opened:     m.opened,
+		Rejected:   m.rejected,
+	}
+	users := make(map[string]bool)
+	for stream := range m.streams {
+		stats.ByKind[stream.Kind]++
+		users[stream.UserID] = true
+	}
+	stats.Users = len(users)
+	return stats
+}
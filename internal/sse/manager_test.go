@@ -0,0 +1,95 @@
+package sse
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func open(t *testing.T, m *Manager, kind, userID string) (*Stream, *httptest.ResponseRecorder, error) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/events", nil)
+	stream, err := m.Open(w, r, kind, userID)
+	return stream, w, err
+}
+
+func TestPerUserLimit(t *testing.T) {
+	m := NewManager(2, 0)
+
+	first, _, err := open(t, m, "ai", "alice")
+	if err != nil {
+		t.Fatalf("first stream rejected: %v", err)
+	}
+	if _, _, err := open(t, m, "todos", "alice"); err != nil {
+		t.Fatalf("second stream rejected: %v", err)
+	}
+
+	_, w, err := open(t, m, "ai", "alice")
+	if !errors.Is(err, ErrTooManyStreams) {
+		t.Fatalf("expected ErrTooManyStreams, got %v", err)
+	}
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("expected 429, got %d", w.Code)
+	}
+
+	// Other users have their own allowance
+	if _, _, err := open(t, m, "ai", "bob"); err != nil {
+		t.Fatalf("other user rejected: %v", err)
+	}
+
+	// Closing frees the slot, and closing twice doesn't free another
+	first.Close()
+	first.Close()
+	if _, _, err := open(t, m, "ai", "alice"); err != nil {
+		t.Fatalf("stream rejected after close: %v", err)
+	}
+	if _, _, err := open(t, m, "ai", "alice"); !errors.Is(err, ErrTooManyStreams) {
+		t.Fatalf("expected ErrTooManyStreams, got %v", err)
+	}
+
+	stats := m.Stats()
+	if stats.Active != 3 || stats.Users != 2 || stats.ByKind["ai"] != 2 || stats.ByKind["todos"] != 1 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+	if stats.Opened != 4 || stats.Rejected != 2 {
+		t.Errorf("expected 4 opened and 2 rejected, got %d and %d", stats.Opened, stats.Rejected)
+	}
+}
+
+func TestTotalLimit(t *testing.T) {
+	m := NewManager(0, 1)
+	if _, _, err := open(t, m, "collab", "alice"); err != nil {
+		t.Fatalf("first stream rejected: %v", err)
+	}
+	_, w, err := open(t, m, "collab", "bob")
+	if !errors.Is(err, ErrServerBusy) {
+		t.Fatalf("expected ErrServerBusy, got %v", err)
+	}
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503, got %d", w.Code)
+	}
+}
+
+func TestSend(t *testing.T) {
+	m := NewManager(0, 0)
+	stream, w, err := open(t, m, "ai", "alice")
+	if err != nil {
+		t.Fatalf("stream rejected: %v", err)
+	}
+	defer stream.Close()
+
+	if got := w.Header().Get("Content-Type"); got != "text/event-stream" {
+		t.Errorf("unexpected content type %q", got)
+	}
+
+	stream.Send("message", "line one\nline two")
+	stream.Ping()
+	stream.SendID("7", "issues", "{}")
+	want := "event: message\ndata: line one\ndata: line two\n\nevent: ping\ndata: keepalive\n\nid: 7\nevent: issues\ndata: {}\n\n"
+	if body := w.Body.String(); !strings.HasSuffix(body, want) {
+		t.Errorf("unexpected body %q", body)
+	}
+}
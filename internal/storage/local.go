@@ -0,0 +1,168 @@
+package storage
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// tempPrefix marks files still being written, which List skips
+const tempPrefix = ".tmp-"
+
+// Local keeps files in a directory. NFS shares are used the same way once
+// they are mounted.
+type Local struct {
+	root string
+	kind string
+}
+
+// NewLocal returns a store that keeps files under root
+func NewLocal(root string) *Local {
+	return &Local{root: root, kind: "Local disk"}
+}
+
+// String describes the store
+func (l *Local) String() string {
+	return fmt.Sprintf("%s at %s", l.kind, l.root)
+}
+
+// Path returns where a key's content is kept on disk
+func (l *Local) Path(key string) (string, error) {
+	key, err := cleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(l.root, filepath.FromSlash(key)), nil
+}
+
+// Put writes the content to a temporary file and renames it into place, so
+// readers never see a partial file
+func (l *Local) Put(key string, r io.Reader) error {
+	path, err := l.Path(key)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, tempPrefix+"*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // No-op once renamed into place
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Get opens a key's file
+func (l *Local) Get(key string) (io.ReadCloser, error) {
+	path, err := l.Path(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
+
+// Stat describes a key's file
+func (l *Local) Stat(key string) (Object, error) {
+	path, err := l.Path(key)
+	if err != nil {
+		return Object{}, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return Object{}, err
+	}
+	if info.IsDir() {
+		return Object{}, &fs.PathError{Op: "stat", Path: path, Err: ErrNotExist}
+	}
+	key, _ = cleanKey(key)
+	return Object{Key: key, Size: info.Size(), Modified: info.ModTime()}, nil
+}
+
+// Delete removes a key's file
+func (l *Local) Delete(key string) error {
+	path, err := l.Path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// List walks the directory a prefix names
+func (l *Local) List(prefix string) ([]Object, error) {
+	prefix, err := cleanPrefix(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []Object
+	dir := filepath.Join(l.root, filepath.FromSlash(prefix))
+	err = filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), tempPrefix) {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil // Removed while walking
+		}
+		rel, err := filepath.Rel(l.root, path)
+		if err != nil {
+			return err
+		}
+		objects = append(objects, Object{Key: filepath.ToSlash(rel), Size: info.Size(), Modified: info.ModTime()})
+		return nil
+	})
+	return objects, err
+}
+
+// checkNFSMount makes sure an NFS share is mounted at dir, so files aren't
+// quietly written to the local disk underneath the mount point
+func checkNFSMount(dir string) error {
+	if dir == "" {
+		return fmt.Errorf("NFS storage needs the share's mount point")
+	}
+	dir = filepath.Clean(dir)
+
+	file, err := os.Open("/proc/self/mounts")
+	if err != nil {
+		return fmt.Errorf("failed to read mounts: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// device mountpoint fstype options ...
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 || !strings.HasPrefix(fields[2], "nfs") {
+			continue
+		}
+		mountPoint := fields[1]
+		if dir == mountPoint || strings.HasPrefix(dir, mountPoint+"/") {
+			return nil
+		}
+	}
+	return fmt.Errorf("no NFS share is mounted at %s", dir)
+}
@@ -0,0 +1,399 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// emptyPayloadHash is the SHA-256 of an empty request body
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// S3 keeps files in an S3 bucket or a compatible service such as MinIO.
+// Requests are signed with AWS Signature Version 4.
+type S3 struct {
+	endpoint  *url.URL
+	bucket    string
+	region    string
+	prefix    string
+	accessKey string
+	secretKey string
+	pathStyle bool // Bucket in the path rather than the host name
+	client    *http.Client
+	now       func() time.Time
+}
+
+// NewS3 returns a store for the bucket in config. Without an endpoint the
+// bucket is reached on AWS in the configured region.
+func NewS3(config Config) (*S3, error) {
+	if config.Bucket == "" {
+		return nil, errors.New("S3 storage needs a bucket")
+	}
+	if config.AccessKey == "" || config.SecretKey == "" {
+		return nil, errors.New("S3 storage needs an access key and secret key")
+	}
+	region := config.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	s := &S3{
+		bucket:    config.Bucket,
+		region:    region,
+		accessKey: config.AccessKey,
+		secretKey: config.SecretKey,
+		client:    &http.Client{Timeout: 10 * time.Minute},
+		now:       time.Now,
+	}
+	if config.Prefix != "" {
+		prefix, err := cleanPrefix(config.Prefix)
+		if err != nil {
+			return nil, err
+		}
+		s.prefix = prefix
+	}
+
+	endpoint := config.Endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", region)
+	} else {
+		// Compatible services rarely have per-bucket host names
+		s.pathStyle = true
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("invalid S3 endpoint %q", config.Endpoint)
+	}
+	s.endpoint = u
+	return s, nil
+}
+
+// String describes the store
+func (s *S3) String() string {
+	if s.pathStyle {
+		return fmt.Sprintf("S3 bucket %s at %s", s.bucket, s.endpoint.Host)
+	}
+	return fmt.Sprintf("S3 bucket %s in %s", s.bucket, s.region)
+}
+
+// Put uploads the content. It is staged in a temporary file first because
+// S3 needs the length and checksum before the body is sent.
+func (s *S3) Put(key string, r io.Reader) error {
+	key, err := cleanKey(key)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp("", "s3-upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), r)
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	req, err := s.request(http.MethodPut, s.prefix+key, nil, io.NopCloser(tmp))
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	if size == 0 {
+		req.Body = http.NoBody
+	}
+	resp, err := s.do(req, hex.EncodeToString(hash.Sum(nil)))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Get downloads a key's content
+func (s *S3) Get(key string) (io.ReadCloser, error) {
+	key, err := cleanKey(key)
+	if err != nil {
+		return nil, err
+	}
+	req, err := s.request(http.MethodGet, s.prefix+key, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.do(req, emptyPayloadHash)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Stat reads a key's size and modification time
+func (s *S3) Stat(key string) (Object, error) {
+	key, err := cleanKey(key)
+	if err != nil {
+		return Object{}, err
+	}
+	req, err := s.request(http.MethodHead, s.prefix+key, nil, nil)
+	if err != nil {
+		return Object{}, err
+	}
+	resp, err := s.do(req, emptyPayloadHash)
+	if err != nil {
+		return Object{}, err
+	}
+	resp.Body.Close()
+
+	object := Object{Key: key, Size: resp.ContentLength}
+	object.Modified, _ = http.ParseTime(resp.Header.Get("Last-Modified"))
+	return object, nil
+}
+
+// Delete removes a key's content
+func (s *S3) Delete(key string) error {
+	key, err := cleanKey(key)
+	if err != nil {
+		return err
+	}
+	req, err := s.request(http.MethodDelete, s.prefix+key, nil, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(req, emptyPayloadHash)
+	if errors.Is(err, ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// listBucketResult is the response to a ListObjectsV2 request
+type listBucketResult struct {
+	Contents []struct {
+		Key          string
+		Size         int64
+		LastModified time.Time
+	}
+	IsTruncated           bool
+	NextContinuationToken string
+}
+
+// List pages through the objects under a prefix
+func (s *S3) List(prefix string) ([]Object, error) {
+	prefix, err := cleanPrefix(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []Object
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {s.prefix + prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		req, err := s.request(http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := s.do(req, emptyPayloadHash)
+		if err != nil {
+			return nil, err
+		}
+
+		var result listBucketResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read bucket listing: %w", err)
+		}
+
+		for _, content := range result.Contents {
+			objects = append(objects, Object{
+				Key:      strings.TrimPrefix(content.Key, s.prefix),
+				Size:     content.Size,
+				Modified: content.LastModified,
+			})
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return objects, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// request builds an unsigned request for an object, or the bucket itself
+// when key is empty
+func (s *S3) request(method, key string, query url.Values, body io.ReadCloser) (*http.Request, error) {
+	u := *s.endpoint
+	objectPath := "/" + key
+	if s.pathStyle {
+		objectPath = strings.TrimSuffix("/"+s.bucket+objectPath, "/")
+	} else {
+		u.Host = s.bucket + "." + u.Host
+	}
+	u.Path = strings.TrimSuffix(s.endpoint.Path, "/") + objectPath
+	u.RawPath = uriEncode(u.Path, false)
+	u.RawQuery = canonicalQuery(query)
+
+	req, err := http.NewRequest(method, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Body = body
+	}
+	return req, nil
+}
+
+// do signs and sends a request, turning error responses into errors
+func (s *S3) do(req *http.Request, payloadHash string) (*http.Response, error) {
+	s.sign(req, payloadHash)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	var e struct {
+		Code    string
+		Message string
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	xml.Unmarshal(body, &e)
+
+	// HEAD responses have no body to say which key or bucket is missing
+	if resp.StatusCode == http.StatusNotFound && e.Code != "NoSuchBucket" {
+		return nil, fmt.Errorf("%s: %w", req.URL.Path, ErrNotExist)
+	}
+	if e.Code != "" {
+		return nil, fmt.Errorf("s3: %s: %s", e.Code, e.Message)
+	}
+	return nil, fmt.Errorf("s3: %s %s returned %s", req.Method, req.URL.Path, resp.Status)
+}
+
+// sign adds an AWS Signature Version 4 Authorization header covering the
+// host and every header already set on the request
+func (s *S3) sign(req *http.Request, payloadHash string) {
+	now := s.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex(canonicalRequest),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), date)
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKey, scope, signedHeaders, signature))
+}
+
+// canonicalQuery encodes query parameters sorted by name, as signing needs
+func canonicalQuery(query url.Values) string {
+	if len(query) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var parts []string
+	for _, name := range names {
+		values := append([]string(nil), query[name]...)
+		sort.Strings(values)
+		for _, value := range values {
+			parts = append(parts, uriEncode(name, true)+"="+uriEncode(value, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// uriEncode percent-encodes everything but unreserved characters, and
+// slashes unless encodeSlash is set
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			b.WriteString("%" + strings.ToUpper(strconv.FormatUint(uint64(c)|0x100, 16)[1:]))
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
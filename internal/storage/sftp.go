@@ -0,0 +1,697 @@
+package storage
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// SFTP packet types, from version 3 of the protocol which OpenSSH speaks
+const (
+	sftpInit     = 1
+	sftpVersion  = 2
+	sftpOpen     = 3
+	sftpClose    = 4
+	sftpRead     = 5
+	sftpWrite    = 6
+	sftpOpenDir  = 11
+	sftpReadDir  = 12
+	sftpRemove   = 13
+	sftpMkdir    = 14
+	sftpStat     = 17
+	sftpStatus   = 101
+	sftpHandle   = 102
+	sftpData     = 103
+	sftpName     = 104
+	sftpAttrs    = 105
+	sftpProtocol = 3
+)
+
+// SFTP status codes and open flags
+const (
+	sftpOK         = 0
+	sftpEOF        = 1
+	sftpNoSuchFile = 2
+
+	sftpFlagRead   = 0x01
+	sftpFlagWrite  = 0x02
+	sftpFlagCreate = 0x08
+	sftpFlagTrunc  = 0x10
+)
+
+// sftpChunk is how much is read or written per request, the most every
+// server must accept
+const sftpChunk = 32 << 10
+
+// sftpTimeout bounds connecting and signing in
+const sftpTimeout = 30 * time.Second
+
+// SFTP keeps files on a server reached over SSH. The server's host key
+// must match the fingerprint in the configuration. It connects on first
+// use, and again after the connection fails.
+type SFTP struct {
+	addr   string
+	prefix string
+	dial   func() (io.ReadWriteCloser, error)
+
+	mu     sync.Mutex
+	conn   io.ReadWriteCloser
+	nextID uint32
+}
+
+// NewSFTP returns a store for the server in config. The endpoint is the
+// server's host and port, and keys are stored under the prefix, which is
+// relative to the user's home directory unless it starts with a slash.
+func NewSFTP(config Config) (*SFTP, error) {
+	addr, err := sftpAddress(config.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if config.AccessKey == "" || config.SecretKey == "" {
+		return nil, errors.New("SFTP storage needs a username and a password or private key")
+	}
+	if config.HostKey == "" {
+		return nil, errors.New("SFTP storage needs the server's host key fingerprint")
+	}
+	auth, err := sftpAuth(config.SecretKey)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &SFTP{addr: addr}
+	if config.Prefix != "" {
+		// Absolute prefixes are kept absolute
+		if s.prefix, err = cleanPrefix(config.Prefix); err != nil {
+			return nil, err
+		}
+		if strings.HasPrefix(config.Prefix, "/") {
+			s.prefix = "/" + s.prefix
+		}
+	}
+
+	sshConfig := &ssh.ClientConfig{
+		User:            config.AccessKey,
+		Auth:            auth,
+		HostKeyCallback: sftpHostKeyCheck(config.HostKey),
+		Timeout:         sftpTimeout,
+	}
+	s.dial = func() (io.ReadWriteCloser, error) {
+		client, err := ssh.Dial("tcp", addr, sshConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to %s: %w", addr, err)
+		}
+		return sftpSubsystem(client)
+	}
+	return s, nil
+}
+
+// SFTPHostKey connects to a server and returns its host key fingerprint
+// without signing in, so it can be recorded the first time the server is
+// configured and checked from then on
+func SFTPHostKey(endpoint string) (string, error) {
+	addr, err := sftpAddress(endpoint)
+	if err != nil {
+		return "", err
+	}
+	var fingerprint string
+	client, err := ssh.Dial("tcp", addr, &ssh.ClientConfig{
+		User: "host-key-check",
+		HostKeyCallback: func(_ string, _ net.Addr, key ssh.PublicKey) error {
+			fingerprint = ssh.FingerprintSHA256(key)
+			return nil
+		},
+		Timeout: sftpTimeout,
+	})
+	if client != nil {
+		client.Close()
+	}
+	if fingerprint == "" {
+		return "", fmt.Errorf("failed to read the host key of %s: %w", addr, err)
+	}
+	return fingerprint, nil
+}
+
+// sftpAddress adds the default port to a host
+func sftpAddress(endpoint string) (string, error) {
+	endpoint = strings.TrimPrefix(endpoint, "sftp://")
+	if endpoint == "" || strings.ContainsAny(endpoint, "/@") {
+		return "", fmt.Errorf("invalid SFTP server %q, it should look like host or host:22", endpoint)
+	}
+	if _, _, err := net.SplitHostPort(endpoint); err != nil {
+		return net.JoinHostPort(endpoint, "22"), nil
+	}
+	return endpoint, nil
+}
+
+// sftpAuth signs in with a private key when the secret is one, and with a
+// password otherwise
+func sftpAuth(secret string) ([]ssh.AuthMethod, error) {
+	if strings.HasPrefix(strings.TrimSpace(secret), "-----BEGIN") {
+		signer, err := ssh.ParsePrivateKey([]byte(secret))
+		if err != nil {
+			return nil, fmt.Errorf("invalid SFTP private key: %w", err)
+		}
+		return []ssh.AuthMethod{ssh.PublicKeys(signer)}, nil
+	}
+	return []ssh.AuthMethod{ssh.Password(secret)}, nil
+}
+
+// sftpHostKeyCheck accepts only the host key with the given fingerprint
+func sftpHostKeyCheck(fingerprint string) ssh.HostKeyCallback {
+	return func(hostname string, _ net.Addr, key ssh.PublicKey) error {
+		if got := ssh.FingerprintSHA256(key); got != fingerprint {
+			return fmt.Errorf("host key of %s is %s, not the expected %s", hostname, got, fingerprint)
+		}
+		return nil
+	}
+}
+
+// sftpSession is the SFTP subsystem on an SSH connection, closing the
+// connection with it
+type sftpSession struct {
+	io.Reader
+	io.WriteCloser
+	client *ssh.Client
+}
+
+func (s *sftpSession) Close() error {
+	s.WriteCloser.Close()
+	return s.client.Close()
+}
+
+// sftpSubsystem starts the SFTP subsystem on a connection
+func sftpSubsystem(client *ssh.Client) (io.ReadWriteCloser, error) {
+	session, err := client.NewSession()
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	if err := session.RequestSubsystem("sftp"); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("server does not offer SFTP: %w", err)
+	}
+	return &sftpSession{Reader: stdout, WriteCloser: stdin, client: client}, nil
+}
+
+// String describes the store
+func (s *SFTP) String() string {
+	return fmt.Sprintf("SFTP server %s", s.addr)
+}
+
+// Put uploads the content, creating the directories above it first
+func (s *SFTP) Put(key string, r io.Reader) error {
+	key, err := cleanKey(key)
+	if err != nil {
+		return err
+	}
+	name := s.prefix + key
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.mkdirs(path.Dir(name)); err != nil {
+		return err
+	}
+	handle, err := s.open(name, sftpFlagWrite|sftpFlagCreate|sftpFlagTrunc)
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, sftpChunk)
+	var offset uint64
+	for {
+		n, readErr := io.ReadFull(r, buf)
+		if n > 0 {
+			packet := sftpPacket{}.string(handle).uint64(offset).bytes(buf[:n])
+			if err := s.expectOK(sftpWrite, packet); err != nil {
+				s.closeHandle(handle)
+				return err
+			}
+			offset += uint64(n)
+		}
+		if readErr == io.EOF || readErr == io.ErrUnexpectedEOF {
+			break
+		}
+		if readErr != nil {
+			s.closeHandle(handle)
+			return readErr
+		}
+	}
+	return s.closeHandle(handle)
+}
+
+// Get opens a key's file, which is read a chunk at a time
+func (s *SFTP) Get(key string) (io.ReadCloser, error) {
+	key, err := cleanKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	handle, err := s.open(s.prefix+key, sftpFlagRead)
+	if err != nil {
+		return nil, err
+	}
+	return &sftpFile{store: s, handle: handle}, nil
+}
+
+// Stat reads a key's size and modification time
+func (s *SFTP) Stat(key string) (Object, error) {
+	key, err := cleanKey(key)
+	if err != nil {
+		return Object{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	typ, reply, err := s.request(sftpStat, sftpPacket{}.string(s.prefix+key))
+	if err != nil {
+		return Object{}, err
+	}
+	if typ != sftpAttrs {
+		return Object{}, sftpError(typ, reply, key)
+	}
+	attrs, _, err := readSFTPAttrs(reply)
+	if err != nil {
+		return Object{}, err
+	}
+	if attrs.dir {
+		return Object{}, fmt.Errorf("%s: %w", key, ErrNotExist)
+	}
+	return Object{Key: key, Size: attrs.size, Modified: attrs.modified}, nil
+}
+
+// Delete removes a key's file
+func (s *SFTP) Delete(key string) error {
+	key, err := cleanKey(key)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err = s.expectOK(sftpRemove, sftpPacket{}.string(s.prefix+key))
+	if errors.Is(err, ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// List walks the directories under a prefix
+func (s *SFTP) List(prefix string) ([]Object, error) {
+	prefix, err := cleanPrefix(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var objects []Object
+	pending := []string{prefix}
+	for len(pending) > 0 {
+		dir := pending[0]
+		pending = pending[1:]
+
+		entries, err := s.readDir(strings.TrimSuffix(s.prefix+dir, "/"))
+		if errors.Is(err, ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if entry.name == "." || entry.name == ".." {
+				continue
+			}
+			if entry.attrs.dir {
+				pending = append(pending, dir+entry.name+"/")
+				continue
+			}
+			objects = append(objects, Object{Key: dir + entry.name, Size: entry.attrs.size, Modified: entry.attrs.modified})
+		}
+	}
+	return objects, nil
+}
+
+// sftpEntry is a file in a directory listing
+type sftpEntry struct {
+	name  string
+	attrs sftpFileAttrs
+}
+
+// readDir lists a directory, "" for the starting directory
+func (s *SFTP) readDir(dir string) ([]sftpEntry, error) {
+	if dir == "" {
+		dir = "."
+	}
+	typ, reply, err := s.request(sftpOpenDir, sftpPacket{}.string(dir))
+	if err != nil {
+		return nil, err
+	}
+	if typ != sftpHandle {
+		return nil, sftpError(typ, reply, dir)
+	}
+	handle, _, err := readSFTPString(reply)
+	if err != nil {
+		return nil, err
+	}
+	defer s.closeHandle(handle)
+
+	var entries []sftpEntry
+	for {
+		typ, reply, err := s.request(sftpReadDir, sftpPacket{}.string(handle))
+		if err != nil {
+			return nil, err
+		}
+		if typ != sftpName {
+			if err := sftpError(typ, reply, dir); err != nil && !errors.Is(err, io.EOF) {
+				return nil, err
+			}
+			return entries, nil
+		}
+		if len(reply) < 4 {
+			return nil, errors.New("sftp: short directory listing")
+		}
+		count := binary.BigEndian.Uint32(reply)
+		reply = reply[4:]
+		for i := uint32(0); i < count; i++ {
+			var entry sftpEntry
+			if entry.name, reply, err = readSFTPString(reply); err != nil {
+				return nil, err
+			}
+			if _, reply, err = readSFTPString(reply); err != nil { // Long name
+				return nil, err
+			}
+			if entry.attrs, reply, err = readSFTPAttrs(reply); err != nil {
+				return nil, err
+			}
+			entries = append(entries, entry)
+		}
+	}
+}
+
+// mkdirs creates a directory and any missing parents
+func (s *SFTP) mkdirs(dir string) error {
+	if dir == "." || dir == "/" || dir == "" {
+		return nil
+	}
+	typ, reply, err := s.request(sftpStat, sftpPacket{}.string(dir))
+	if err != nil {
+		return err
+	}
+	if typ == sftpAttrs {
+		return nil
+	}
+	if err := sftpError(typ, reply, dir); !errors.Is(err, ErrNotExist) {
+		return err
+	}
+	if err := s.mkdirs(path.Dir(dir)); err != nil {
+		return err
+	}
+	return s.expectOK(sftpMkdir, sftpPacket{}.string(dir).uint32(0))
+}
+
+// open opens a file, returning its handle
+func (s *SFTP) open(name string, flags uint32) (string, error) {
+	typ, reply, err := s.request(sftpOpen, sftpPacket{}.string(name).uint32(flags).uint32(0))
+	if err != nil {
+		return "", err
+	}
+	if typ != sftpHandle {
+		return "", sftpError(typ, reply, name)
+	}
+	handle, _, err := readSFTPString(reply)
+	return handle, err
+}
+
+// closeHandle closes an open file or directory, unless the connection it
+// was opened on has gone
+func (s *SFTP) closeHandle(handle string) error {
+	if s.conn == nil {
+		return nil
+	}
+	return s.expectOK(sftpClose, sftpPacket{}.string(handle))
+}
+
+// expectOK sends a request answered with a status, returning it as an
+// error unless it's OK
+func (s *SFTP) expectOK(typ byte, packet sftpPacket) error {
+	replyType, reply, err := s.request(typ, packet)
+	if err != nil {
+		return err
+	}
+	return sftpError(replyType, reply, "")
+}
+
+// request sends a packet and reads the reply to it, connecting first if
+// needed. Requests are sent one at a time, with s.mu held.
+func (s *SFTP) request(typ byte, packet sftpPacket) (byte, []byte, error) {
+	if s.conn == nil {
+		if err := s.connect(); err != nil {
+			return 0, nil, err
+		}
+	}
+	s.nextID++
+	id := s.nextID
+
+	replyType, reply, err := s.roundTrip(typ, append(sftpPacket{}.uint32(id), packet...))
+	if err == nil && (len(reply) < 4 || binary.BigEndian.Uint32(reply) != id) {
+		err = errors.New("sftp: reply to the wrong request")
+	}
+	if err != nil {
+		// The connection can't be trusted any more
+		s.conn.Close()
+		s.conn = nil
+		return 0, nil, err
+	}
+	return replyType, reply[4:], nil
+}
+
+// connect opens the connection and agrees on the protocol version
+func (s *SFTP) connect() error {
+	conn, err := s.dial()
+	if err != nil {
+		return err
+	}
+	s.conn = conn
+	typ, _, err := s.roundTrip(sftpInit, sftpPacket{}.uint32(sftpProtocol))
+	if err == nil && typ != sftpVersion {
+		err = fmt.Errorf("sftp: unexpected reply %d to init", typ)
+	}
+	if err != nil {
+		conn.Close()
+		s.conn = nil
+		return err
+	}
+	return nil
+}
+
+// roundTrip writes a packet and reads the next one
+func (s *SFTP) roundTrip(typ byte, payload []byte) (byte, []byte, error) {
+	if err := writeSFTPPacket(s.conn, typ, payload); err != nil {
+		return 0, nil, err
+	}
+	return readSFTPPacket(s.conn)
+}
+
+// sftpFile reads an open file a chunk at a time
+type sftpFile struct {
+	store  *SFTP
+	handle string
+	offset uint64
+	eof    bool
+}
+
+func (f *sftpFile) Read(p []byte) (int, error) {
+	if f.eof {
+		return 0, io.EOF
+	}
+	f.store.mu.Lock()
+	defer f.store.mu.Unlock()
+
+	size := min(len(p), sftpChunk)
+	typ, reply, err := f.store.request(sftpRead, sftpPacket{}.string(f.handle).uint64(f.offset).uint32(uint32(size)))
+	if err != nil {
+		return 0, err
+	}
+	if typ != sftpData {
+		err := sftpError(typ, reply, "")
+		if errors.Is(err, io.EOF) {
+			f.eof = true
+		}
+		return 0, err
+	}
+	data, _, err := readSFTPString(reply)
+	if err != nil {
+		return 0, err
+	}
+	n := copy(p, data)
+	f.offset += uint64(n)
+	return n, nil
+}
+
+func (f *sftpFile) Close() error {
+	f.store.mu.Lock()
+	defer f.store.mu.Unlock()
+	return f.store.closeHandle(f.handle)
+}
+
+// sftpPacket builds a packet's payload
+type sftpPacket []byte
+
+func (p sftpPacket) uint32(v uint32) sftpPacket {
+	return binary.BigEndian.AppendUint32(p, v)
+}
+
+func (p sftpPacket) uint64(v uint64) sftpPacket {
+	return binary.BigEndian.AppendUint64(p, v)
+}
+
+func (p sftpPacket) string(s string) sftpPacket {
+	return append(p.uint32(uint32(len(s))), s...)
+}
+
+func (p sftpPacket) bytes(b []byte) sftpPacket {
+	return append(p.uint32(uint32(len(b))), b...)
+}
+
+// writeSFTPPacket writes a packet with its length and type
+func writeSFTPPacket(w io.Writer, typ byte, payload []byte) error {
+	packet := make([]byte, 5, 5+len(payload))
+	binary.BigEndian.PutUint32(packet, uint32(1+len(payload)))
+	packet[4] = typ
+	_, err := w.Write(append(packet, payload...))
+	return err
+}
+
+// readSFTPPacket reads a packet, returning its type and payload
+func readSFTPPacket(r io.Reader) (byte, []byte, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, nil, err
+	}
+	length := binary.BigEndian.Uint32(header[:4])
+	if length == 0 || length > 1<<20 {
+		return 0, nil, fmt.Errorf("sftp: invalid packet length %d", length)
+	}
+	payload := make([]byte, length-1)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	return header[4], payload, nil
+}
+
+// readSFTPString reads a length prefixed string
+func readSFTPString(b []byte) (string, []byte, error) {
+	if len(b) < 4 {
+		return "", nil, errors.New("sftp: short packet")
+	}
+	length := binary.BigEndian.Uint32(b)
+	if uint32(len(b)-4) < length {
+		return "", nil, errors.New("sftp: short packet")
+	}
+	return string(b[4 : 4+length]), b[4+length:], nil
+}
+
+// sftpFileAttrs are the attributes the store uses
+type sftpFileAttrs struct {
+	size     int64
+	dir      bool
+	modified time.Time
+}
+
+// readSFTPAttrs reads a file's attributes, skipping the ones not used
+func readSFTPAttrs(b []byte) (sftpFileAttrs, []byte, error) {
+	var attrs sftpFileAttrs
+	short := errors.New("sftp: short attributes")
+	if len(b) < 4 {
+		return attrs, nil, short
+	}
+	flags := binary.BigEndian.Uint32(b)
+	b = b[4:]
+	if flags&0x01 != 0 { // Size
+		if len(b) < 8 {
+			return attrs, nil, short
+		}
+		attrs.size = int64(binary.BigEndian.Uint64(b))
+		b = b[8:]
+	}
+	if flags&0x02 != 0 { // Owner and group
+		if len(b) < 8 {
+			return attrs, nil, short
+		}
+		b = b[8:]
+	}
+	if flags&0x04 != 0 { // Permissions, including the file type
+		if len(b) < 4 {
+			return attrs, nil, short
+		}
+		attrs.dir = binary.BigEndian.Uint32(b)&0170000 == 0040000
+		b = b[4:]
+	}
+	if flags&0x08 != 0 { // Access and modification times
+		if len(b) < 8 {
+			return attrs, nil, short
+		}
+		attrs.modified = time.Unix(int64(binary.BigEndian.Uint32(b[4:])), 0)
+		b = b[8:]
+	}
+	if flags&0x80000000 != 0 { // Extensions
+		if len(b) < 4 {
+			return attrs, nil, short
+		}
+		count := binary.BigEndian.Uint32(b)
+		b = b[4:]
+		for i := uint32(0); i < 2*count; i++ {
+			var err error
+			if _, b, err = readSFTPString(b); err != nil {
+				return attrs, nil, err
+			}
+		}
+	}
+	return attrs, b, nil
+}
+
+// sftpError turns a status reply into an error, nil for OK. Missing files
+// match ErrNotExist and the end of a file or listing matches io.EOF.
+func sftpError(typ byte, reply []byte, name string) error {
+	if typ != sftpStatus || len(reply) < 4 {
+		return fmt.Errorf("sftp: unexpected reply %d", typ)
+	}
+	code := binary.BigEndian.Uint32(reply)
+	message, _, _ := readSFTPString(reply[4:])
+	switch code {
+	case sftpOK:
+		return nil
+	case sftpEOF:
+		return io.EOF
+	case sftpNoSuchFile:
+		return fmt.Errorf("%s: %w", name, ErrNotExist)
+	}
+	if message == "" {
+		message = fmt.Sprintf("error %d", code)
+	}
+	if name != "" {
+		return fmt.Errorf("sftp: %s: %s", name, message)
+	}
+	return fmt.Errorf("sftp: %s", message)
+}
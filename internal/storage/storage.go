@@ -0,0 +1,182 @@
+// Package storage keeps the workspace's files, such as attachments,
+// avatars, action artifacts and backups, on local disk, an NFS share, an
+// S3 compatible object store, an SFTP server or a WebDAV share behind one
+// interface.
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+	"time"
+)
+
+// Backends
+const (
+	BackendLocal  = "local"
+	BackendNFS    = "nfs"
+	BackendS3     = "s3"
+	BackendSFTP   = "sftp"
+	BackendWebDAV = "webdav"
+)
+
+// ErrNotExist is returned for keys that have nothing stored under them. It
+// matches fs.ErrNotExist with errors.Is.
+var ErrNotExist = fs.ErrNotExist
+
+// Object describes a stored file
+type Object struct {
+	Key      string
+	Size     int64
+	Modified time.Time
+}
+
+// Store keeps files by key. Keys are slash separated paths such as
+// "attachments/<repo>/<id>"; they can't climb out of the store with "..".
+type Store interface {
+	// Put stores the reader's content under key, replacing what was there
+	Put(key string, r io.Reader) error
+	// Get opens the content stored under key
+	Get(key string) (io.ReadCloser, error)
+	// Stat describes the content stored under key
+	Stat(key string) (Object, error)
+	// Delete removes the content under key. Missing keys aren't an error.
+	Delete(key string) error
+	// List returns the objects under a directory prefix such as "backups/"
+	List(prefix string) ([]Object, error)
+	// String describes where files are kept, for display
+	String() string
+}
+
+// Config selects and configures a backend
+type Config struct {
+	Backend string // One of the backends, empty is local
+
+	// Local and NFS
+	Path string // Directory files are kept in, the share's mount point for NFS
+
+	// S3, SFTP and WebDAV. SFTP and WebDAV sign in with the access key as
+	// the username and the secret key as the password, which may be a
+	// private key for SFTP.
+	Endpoint  string // S3: empty for AWS or a compatible service's URL, SFTP: host:port, WebDAV: the share's URL
+	Bucket    string
+	Region    string
+	Prefix    string // Keys are stored under this prefix in the bucket or directory
+	AccessKey string
+	SecretKey string
+
+	// SFTP
+	HostKey string // SHA256 fingerprint of the server's host key, see SFTPHostKey
+}
+
+// New opens the store a configuration describes
+func New(config Config) (Store, error) {
+	switch config.Backend {
+	case "", BackendLocal:
+		if config.Path == "" {
+			return nil, errors.New("local storage needs a directory")
+		}
+		return NewLocal(config.Path), nil
+	case BackendNFS:
+		if err := checkNFSMount(config.Path); err != nil {
+			return nil, err
+		}
+		store := NewLocal(config.Path)
+		store.kind = "NFS share"
+		return store, nil
+	case BackendS3:
+		return remote(NewS3(config))
+	case BackendSFTP:
+		return remote(NewSFTP(config))
+	case BackendWebDAV:
+		return remote(NewWebDAV(config))
+	}
+	return nil, fmt.Errorf("unknown storage backend %q", config.Backend)
+}
+
+// remote returns a remote store, or a nil Store rather than a nil pointer
+// when it couldn't be configured
+func remote[T Store](store T, err error) (Store, error) {
+	if err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// Check writes, reads back and removes a small file to make sure the store
+// is usable before it is switched to
+func Check(store Store) error {
+	const key = ".storage-check"
+	content := "checked " + time.Now().UTC().Format(time.RFC3339)
+
+	if err := store.Put(key, strings.NewReader(content)); err != nil {
+		return fmt.Errorf("failed to write to %s: %w", store, err)
+	}
+	r, err := store.Get(key)
+	if err != nil {
+		return fmt.Errorf("failed to read from %s: %w", store, err)
+	}
+	data, err := io.ReadAll(r)
+	r.Close()
+	if err != nil || string(data) != content {
+		return fmt.Errorf("%s returned different content than was written", store)
+	}
+	return store.Delete(key)
+}
+
+// Copy copies a file from one store to another, which may be the same
+func Copy(dst Store, dstKey string, src Store, srcKey string) error {
+	r, err := src.Get(srcKey)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return dst.Put(dstKey, r)
+}
+
+// RemoveAll deletes every object under a directory prefix
+func RemoveAll(store Store, prefix string) error {
+	objects, err := store.List(prefix)
+	if err != nil {
+		return err
+	}
+	for _, object := range objects {
+		if err := store.Delete(object.Key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cleanKey normalizes a key, rejecting ones that would leave the store
+func cleanKey(key string) (string, error) {
+	if strings.Contains(key, "\\") {
+		return "", fmt.Errorf("invalid storage key %q", key)
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == ".." {
+			return "", fmt.Errorf("invalid storage key %q", key)
+		}
+	}
+	cleaned := strings.TrimPrefix(path.Clean("/"+key), "/")
+	if cleaned == "" {
+		return "", fmt.Errorf("invalid storage key %q", key)
+	}
+	return cleaned, nil
+}
+
+// cleanPrefix normalizes a directory prefix, which may be empty for the
+// whole store
+func cleanPrefix(prefix string) (string, error) {
+	if strings.Trim(prefix, "/") == "" {
+		return "", nil
+	}
+	cleaned, err := cleanKey(prefix)
+	if err != nil {
+		return "", err
+	}
+	return cleaned + "/", nil
+}
@@ -0,0 +1,332 @@
+package storage
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WebDAV keeps files on a WebDAV server such as Nextcloud, ownCloud or an
+// Apache or nginx share, signing in with basic authentication
+type WebDAV struct {
+	endpoint *url.URL
+	prefix   string
+	username string
+	password string
+	client   *http.Client
+
+	mu          sync.Mutex
+	collections map[string]bool // Directories known to exist
+}
+
+// NewWebDAV returns a store for the server in config. The endpoint is the
+// address of the share, and keys are stored under its prefix.
+func NewWebDAV(config Config) (*WebDAV, error) {
+	u, err := url.Parse(config.Endpoint)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("invalid WebDAV address %q", config.Endpoint)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	u.RawPath = ""
+
+	w := &WebDAV{
+		endpoint: u,
+		username: config.AccessKey,
+		password: config.SecretKey,
+		client: &http.Client{Transport: &http.Transport{
+			Proxy:                 http.ProxyFromEnvironment,
+			DialContext:           (&net.Dialer{Timeout: 30 * time.Second}).DialContext,
+			TLSHandshakeTimeout:   30 * time.Second,
+			ResponseHeaderTimeout: 5 * time.Minute,
+		}},
+		collections: make(map[string]bool),
+	}
+	if config.Prefix != "" {
+		if w.prefix, err = cleanPrefix(config.Prefix); err != nil {
+			return nil, err
+		}
+	}
+	return w, nil
+}
+
+// String describes the store
+func (w *WebDAV) String() string {
+	return fmt.Sprintf("WebDAV share at %s", w.endpoint.Host+w.endpoint.Path)
+}
+
+// Put uploads the content, creating the directories above it first. The
+// body is streamed, so large backups aren't staged on disk.
+func (w *WebDAV) Put(key string, r io.Reader) error {
+	key, err := cleanKey(key)
+	if err != nil {
+		return err
+	}
+	if err := w.mkcols(path.Dir(w.prefix + key)); err != nil {
+		return err
+	}
+
+	req, err := w.request(http.MethodPut, w.prefix+key, io.NopCloser(r))
+	if err != nil {
+		return err
+	}
+	resp, err := w.do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Get downloads a key's content
+func (w *WebDAV) Get(key string) (io.ReadCloser, error) {
+	key, err := cleanKey(key)
+	if err != nil {
+		return nil, err
+	}
+	req, err := w.request(http.MethodGet, w.prefix+key, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := w.do(req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Stat reads a key's size and modification time
+func (w *WebDAV) Stat(key string) (Object, error) {
+	key, err := cleanKey(key)
+	if err != nil {
+		return Object{}, err
+	}
+	responses, err := w.propfind(w.prefix+key, "0")
+	if err != nil {
+		return Object{}, err
+	}
+	if len(responses) == 0 || responses[0].collection() {
+		return Object{}, fmt.Errorf("%s: %w", key, ErrNotExist)
+	}
+	object := responses[0].object()
+	object.Key = key
+	return object, nil
+}
+
+// Delete removes a key's content
+func (w *WebDAV) Delete(key string) error {
+	key, err := cleanKey(key)
+	if err != nil {
+		return err
+	}
+	req, err := w.request(http.MethodDelete, w.prefix+key, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := w.do(req)
+	if errors.Is(err, ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// List walks the collections under a prefix one level at a time, as many
+// servers refuse to list a whole tree at once
+func (w *WebDAV) List(prefix string) ([]Object, error) {
+	prefix, err := cleanPrefix(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []Object
+	pending := []string{strings.TrimSuffix(w.prefix+prefix, "/")}
+	for len(pending) > 0 {
+		dir := pending[0]
+		pending = pending[1:]
+
+		name := dir
+		if name != "" {
+			name += "/"
+		}
+		responses, err := w.propfind(name, "1")
+		if errors.Is(err, ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, response := range responses {
+			key, ok := w.hrefKey(response.Href)
+			if !ok || strings.TrimSuffix(key, "/") == dir {
+				continue // The collection itself
+			}
+			if response.collection() {
+				pending = append(pending, strings.TrimSuffix(key, "/"))
+				continue
+			}
+			if strings.HasPrefix(key, w.prefix) {
+				object := response.object()
+				object.Key = strings.TrimPrefix(key, w.prefix)
+				objects = append(objects, object)
+			}
+		}
+	}
+	return objects, nil
+}
+
+// davResponse is one resource in a PROPFIND reply
+type davResponse struct {
+	Href     string `xml:"DAV: href"`
+	Propstat []struct {
+		Prop struct {
+			ResourceType struct {
+				Collection *struct{} `xml:"DAV: collection"`
+			} `xml:"DAV: resourcetype"`
+			ContentLength int64  `xml:"DAV: getcontentlength"`
+			LastModified  string `xml:"DAV: getlastmodified"`
+		} `xml:"DAV: prop"`
+		Status string `xml:"DAV: status"`
+	} `xml:"DAV: propstat"`
+}
+
+// collection reports whether the resource is a directory
+func (r davResponse) collection() bool {
+	for _, propstat := range r.Propstat {
+		if propstat.Prop.ResourceType.Collection != nil {
+			return true
+		}
+	}
+	return strings.HasSuffix(r.Href, "/")
+}
+
+// object describes the resource, without its key
+func (r davResponse) object() Object {
+	var object Object
+	for _, propstat := range r.Propstat {
+		if !strings.Contains(propstat.Status, " 200 ") {
+			continue
+		}
+		object.Size = propstat.Prop.ContentLength
+		object.Modified, _ = http.ParseTime(propstat.Prop.LastModified)
+	}
+	return object
+}
+
+// propfindBody asks for just the properties the store uses
+const propfindBody = `<?xml version="1.0" encoding="utf-8"?>
+<propfind xmlns="DAV:"><prop><resourcetype/><getcontentlength/><getlastmodified/></prop></propfind>`
+
+// propfind describes a resource, and its children at depth 1
+func (w *WebDAV) propfind(name, depth string) ([]davResponse, error) {
+	req, err := w.request("PROPFIND", name, io.NopCloser(strings.NewReader(propfindBody)))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(len(propfindBody))
+	req.Header.Set("Depth", depth)
+	req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+	resp, err := w.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Responses []davResponse `xml:"DAV: response"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to read WebDAV listing: %w", err)
+	}
+	return result.Responses, nil
+}
+
+// mkcols creates a directory and any missing parents
+func (w *WebDAV) mkcols(dir string) error {
+	if dir == "." || dir == "" {
+		return nil
+	}
+	w.mu.Lock()
+	known := w.collections[dir]
+	w.mu.Unlock()
+	if known {
+		return nil
+	}
+	if err := w.mkcols(path.Dir(dir)); err != nil {
+		return err
+	}
+
+	req, err := w.request("MKCOL", dir+"/", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	// 405 means something is already there, which a later PUT will reveal
+	// if it isn't a directory
+	if resp.StatusCode >= 300 && resp.StatusCode != http.StatusMethodNotAllowed {
+		return fmt.Errorf("webdav: failed to create %s: %s", dir, resp.Status)
+	}
+
+	w.mu.Lock()
+	w.collections[dir] = true
+	w.mu.Unlock()
+	return nil
+}
+
+// request builds an authenticated request for a path under the share
+func (w *WebDAV) request(method, name string, body io.ReadCloser) (*http.Request, error) {
+	u := *w.endpoint
+	u.Path = w.endpoint.Path + "/" + name
+	req, err := http.NewRequest(method, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Body = body
+	}
+	if w.username != "" || w.password != "" {
+		req.SetBasicAuth(w.username, w.password)
+	}
+	return req, nil
+}
+
+// do sends a request, turning error responses into errors
+func (w *WebDAV) do(req *http.Request) (*http.Response, error) {
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 300 {
+		return resp, nil
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%s: %w", req.URL.Path, ErrNotExist)
+	}
+	return nil, fmt.Errorf("webdav: %s %s returned %s", req.Method, req.URL.Path, resp.Status)
+}
+
+// hrefKey turns an href from a listing, which may be a full URL, into a
+// path relative to the share
+func (w *WebDAV) hrefKey(href string) (string, bool) {
+	u, err := url.Parse(href)
+	if err != nil {
+		return "", false
+	}
+	key, ok := strings.CutPrefix(u.Path, w.endpoint.Path+"/")
+	return key, ok
+}
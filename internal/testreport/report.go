@@ -0,0 +1,281 @@
+// Package testreport reads per-test results out of go test -json output,
+// JUnit XML reports and pytest's console output.
+package testreport
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Outcomes of a test
+const (
+	Passed  = "passed"
+	Failed  = "failed"
+	Skipped = "skipped"
+)
+
+// Formats results are read from
+const (
+	FormatGoJSON = "go-json"
+	FormatJUnit  = "junit"
+	FormatPytest = "pytest"
+)
+
+// maxMessage is how much of a failure's output is kept
+const maxMessage = 4 << 10
+
+// ErrNoResults is returned when the data holds no test results in any
+// known format
+var ErrNoResults = errors.New("no test results found")
+
+// Result is the outcome of one test
+type Result struct {
+	Suite    string        `json:"suite"` // Go package, JUnit class name or pytest file
+	Name     string        `json:"name"`
+	Status   string        `json:"status"` // Passed, Failed or Skipped
+	Duration time.Duration `json:"duration"`
+	Message  string        `json:"message"` // Why it failed or was skipped
+}
+
+// Summary counts results by outcome
+type Summary struct {
+	Passed   int
+	Failed   int
+	Skipped  int
+	Duration time.Duration // Total of the tests' durations
+}
+
+// Summarize counts the results by outcome
+func Summarize(results []Result) Summary {
+	var s Summary
+	for _, result := range results {
+		switch result.Status {
+		case Passed:
+			s.Passed++
+		case Failed:
+			s.Failed++
+		case Skipped:
+			s.Skipped++
+		}
+		s.Duration += result.Duration
+	}
+	return s
+}
+
+// Parse reads results in whichever format the data is in, returning the
+// format along with them
+func Parse(data []byte) (string, []Result, error) {
+	if trimmed := bytes.TrimSpace(data); bytes.HasPrefix(trimmed, []byte("<")) {
+		results, err := ParseJUnit(trimmed)
+		if err != nil {
+			return "", nil, err
+		}
+		return FormatJUnit, results, nil
+	}
+	if results := ParseGoJSON(data); len(results) > 0 {
+		return FormatGoJSON, results, nil
+	}
+	if results := ParsePytest(data); len(results) > 0 {
+		return FormatPytest, results, nil
+	}
+	return "", nil, ErrNoResults
+}
+
+// goEvent is a line of go test -json output
+type goEvent struct {
+	Action  string
+	Package string
+	Test    string
+	Elapsed float64
+	Output  string
+}
+
+// ParseGoJSON reads the results of go test -json output, skipping any line
+// that isn't a test event so it can be mixed with other output
+func ParseGoJSON(data []byte) []Result {
+	var results []Result
+	output := map[string]*strings.Builder{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if !bytes.HasPrefix(line, []byte("{")) {
+			continue
+		}
+		var event goEvent
+		if err := json.Unmarshal(line, &event); err != nil || event.Test == "" {
+			continue
+		}
+
+		key := event.Package + "\x00" + event.Test
+		status := ""
+		switch event.Action {
+		case "output":
+			if output[key] == nil {
+				output[key] = &strings.Builder{}
+			}
+			if output[key].Len() < maxMessage {
+				output[key].WriteString(event.Output)
+			}
+			continue
+		case "pass":
+			status = Passed
+		case "fail":
+			status = Failed
+		case "skip":
+			status = Skipped
+		default:
+			continue
+		}
+
+		result := Result{
+			Suite:    event.Package,
+			Name:     event.Test,
+			Status:   status,
+			Duration: time.Duration(event.Elapsed * float64(time.Second)),
+		}
+		if status != Passed && output[key] != nil {
+			result.Message = trimMessage(output[key].String())
+		}
+		delete(output, key)
+		results = append(results, result)
+	}
+	return results
+}
+
+// junitSuite is a <testsuite> or <testsuites> element, which may nest
+type junitSuite struct {
+	Name   string       `xml:"name,attr"`
+	Suites []junitSuite `xml:"testsuite"`
+	Cases  []junitCase  `xml:"testcase"`
+}
+
+// junitCase is a <testcase> element
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitProblem `xml:"failure"`
+	Error     *junitProblem `xml:"error"`
+	Skipped   *junitProblem `xml:"skipped"`
+}
+
+// junitProblem is a failure, error or skipped element
+type junitProblem struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// ParseJUnit reads the results of a JUnit XML report, as written by most
+// test runners including pytest's --junitxml
+func ParseJUnit(data []byte) ([]Result, error) {
+	var root junitSuite
+	if err := xml.Unmarshal(data, &root); err != nil {
+		return nil, errors.New("invalid JUnit XML: " + err.Error())
+	}
+	var results []Result
+	var walk func(suite junitSuite)
+	walk = func(suite junitSuite) {
+		for _, c := range suite.Cases {
+			result := Result{Suite: c.ClassName, Name: c.Name, Status: Passed}
+			if result.Suite == "" {
+				result.Suite = suite.Name
+			}
+			if seconds, err := strconv.ParseFloat(strings.ReplaceAll(c.Time, ",", ""), 64); err == nil {
+				result.Duration = time.Duration(seconds * float64(time.Second))
+			}
+			switch {
+			case c.Failure != nil:
+				result.Status, result.Message = Failed, c.Failure.String()
+			case c.Error != nil:
+				result.Status, result.Message = Failed, c.Error.String()
+			case c.Skipped != nil:
+				result.Status, result.Message = Skipped, c.Skipped.String()
+			}
+			results = append(results, result)
+		}
+		for _, nested := range suite.Suites {
+			walk(nested)
+		}
+	}
+	walk(root)
+	if len(results) == 0 {
+		return nil, ErrNoResults
+	}
+	return results, nil
+}
+
+// String returns the problem's message followed by its details
+func (p *junitProblem) String() string {
+	text := strings.TrimSpace(p.Text)
+	if p.Message != "" && !strings.HasPrefix(text, p.Message) {
+		text = strings.TrimSpace(p.Message + "\n" + text)
+	}
+	return trimMessage(text)
+}
+
+var (
+	// pytestVerbose matches a test's line in pytest -v output
+	pytestVerbose = regexp.MustCompile(`^(\S+?::\S+)\s+(PASSED|FAILED|ERROR|SKIPPED|XFAIL|XPASS)\b`)
+
+	// pytestSummary matches a test's line in pytest's short summary
+	pytestSummary = regexp.MustCompile(`^(PASSED|FAILED|ERROR|SKIPPED|XFAIL|XPASS)\s+(\S+?::\S+)(?:\s+-\s+(.*))?$`)
+)
+
+// pytestStatus maps pytest's outcomes to ours
+var pytestStatus = map[string]string{
+	"PASSED":  Passed,
+	"XPASS":   Passed,
+	"FAILED":  Failed,
+	"ERROR":   Failed,
+	"SKIPPED": Skipped,
+	"XFAIL":   Skipped,
+}
+
+// ParsePytest reads the results pytest prints with -v, or with -rA in its
+// short test summary, which also gives the reason a test failed
+func ParsePytest(data []byte) []Result {
+	var results []Result
+	index := map[string]int{}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		var id, outcome, message string
+		if m := pytestVerbose.FindStringSubmatch(line); m != nil {
+			id, outcome = m[1], m[2]
+		} else if m := pytestSummary.FindStringSubmatch(line); m != nil {
+			id, outcome, message = m[2], m[1], m[3]
+		} else {
+			continue
+		}
+
+		suite, name, _ := strings.Cut(id, "::")
+		result := Result{Suite: suite, Name: name, Status: pytestStatus[outcome], Message: trimMessage(message)}
+		if i, ok := index[id]; ok {
+			// The summary repeats a test the verbose output listed
+			if result.Message == "" {
+				result.Message = results[i].Message
+			}
+			results[i] = result
+			continue
+		}
+		index[id] = len(results)
+		results = append(results, result)
+	}
+	return results
+}
+
+// trimMessage trims a failure message to maxMessage bytes
+func trimMessage(message string) string {
+	message = strings.TrimSpace(message)
+	if len(message) > maxMessage {
+		message = message[:maxMessage] + "…"
+	}
+	return message
+}
@@ -0,0 +1,106 @@
+package testreport
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseGoJSON(t *testing.T) {
+	data := []byte(`go: downloading example.com/dep v1.0.0
+{"Action":"run","Package":"example.com/app","Test":"TestAdd"}
+{"Action":"output","Package":"example.com/app","Test":"TestAdd","Output":"=== RUN   TestAdd\n"}
+{"Action":"pass","Package":"example.com/app","Test":"TestAdd","Elapsed":0.25}
+{"Action":"run","Package":"example.com/app","Test":"TestDivide"}
+{"Action":"output","Package":"example.com/app","Test":"TestDivide","Output":"    math_test.go:12: division by zero\n"}
+{"Action":"fail","Package":"example.com/app","Test":"TestDivide","Elapsed":0.01}
+{"Action":"skip","Package":"example.com/app","Test":"TestSlow","Elapsed":0}
+{"Action":"fail","Package":"example.com/app","Elapsed":0.3}
+`)
+	results := ParseGoJSON(data)
+	if len(results) != 3 {
+		t.Fatalf("expected 3 results, got %d: %+v", len(results), results)
+	}
+	if results[0].Name != "TestAdd" || results[0].Status != Passed || results[0].Duration != 250*time.Millisecond {
+		t.Errorf("unexpected passing test: %+v", results[0])
+	}
+	if results[1].Status != Failed || results[1].Message != "math_test.go:12: division by zero" {
+		t.Errorf("unexpected failing test: %+v", results[1])
+	}
+	if results[2].Status != Skipped || results[2].Suite != "example.com/app" {
+		t.Errorf("unexpected skipped test: %+v", results[2])
+	}
+}
+
+func TestParseJUnit(t *testing.T) {
+	data := []byte(`<?xml version="1.0" encoding="utf-8"?>
+<testsuites>
+  <testsuite name="pytest">
+    <testcase classname="tests.test_api" name="test_get" time="0.5"/>
+    <testcase classname="tests.test_api" name="test_post" time="1,200.0">
+      <failure message="AssertionError: 404 != 200">Traceback...</failure>
+    </testcase>
+    <testcase classname="tests.test_api" name="test_put"><skipped message="not ready"/></testcase>
+    <testcase name="test_delete"><error message="fixture failed"/></testcase>
+  </testsuite>
+</testsuites>`)
+	format, results, err := Parse(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if format != FormatJUnit || len(results) != 4 {
+		t.Fatalf("expected 4 JUnit results, got %s %+v", format, results)
+	}
+	if results[1].Status != Failed || results[1].Message != "AssertionError: 404 != 200\nTraceback..." || results[1].Duration != 1200*time.Second {
+		t.Errorf("unexpected failure: %+v", results[1])
+	}
+	if results[2].Status != Skipped || results[2].Message != "not ready" {
+		t.Errorf("unexpected skip: %+v", results[2])
+	}
+	if results[3].Status != Failed || results[3].Suite != "pytest" {
+		t.Errorf("errors should fail under the suite's name: %+v", results[3])
+	}
+
+	if _, err := ParseJUnit([]byte("<testsuites></testsuites>")); err != ErrNoResults {
+		t.Errorf("expected ErrNoResults for an empty report, got %v", err)
+	}
+	if _, err := ParseJUnit([]byte("<testsuite>")); err == nil {
+		t.Error("expected an error for invalid XML")
+	}
+}
+
+func TestParsePytest(t *testing.T) {
+	data := []byte(`============================= test session starts ==============================
+tests/test_math.py::test_add PASSED                                      [ 33%]
+tests/test_math.py::TestDivide::test_zero FAILED                         [ 66%]
+tests/test_math.py::test_slow[big] SKIPPED (too slow)                    [100%]
+=========================== short test summary info ============================
+FAILED tests/test_math.py::TestDivide::test_zero - ZeroDivisionError: division by zero
+`)
+	format, results, err := Parse(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if format != FormatPytest || len(results) != 3 {
+		t.Fatalf("expected 3 pytest results, got %s %+v", format, results)
+	}
+	if results[1].Suite != "tests/test_math.py" || results[1].Name != "TestDivide::test_zero" {
+		t.Errorf("unexpected test id: %+v", results[1])
+	}
+	if results[1].Status != Failed || results[1].Message != "ZeroDivisionError: division by zero" {
+		t.Errorf("summary should give the failure's reason: %+v", results[1])
+	}
+	if results[2].Status != Skipped || results[2].Name != "test_slow[big]" {
+		t.Errorf("unexpected skipped test: %+v", results[2])
+	}
+
+	s := Summarize(results)
+	if s.Passed != 1 || s.Failed != 1 || s.Skipped != 1 {
+		t.Errorf("unexpected summary: %+v", s)
+	}
+}
+
+func TestParseNothing(t *testing.T) {
+	if _, _, err := Parse([]byte("ok  \texample.com/app\t0.3s\n")); err != ErrNoResults {
+		t.Errorf("expected ErrNoResults, got %v", err)
+	}
+}
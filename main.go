@@ -3,7 +3,10 @@ package main
 import (
 	"cmp"
 	"embed"
+	"log"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/The-Skyscape/devtools/pkg/application"
@@ -12,6 +15,8 @@ import (
 	"workspace/internal/ai"
 	"workspace/internal/backup"
 	"workspace/internal/middleware"
+	"workspace/internal/sse"
+	logging "workspace/middleware"
 	"workspace/models"
 )
 
@@ -28,11 +33,32 @@ func main() {
 		theme = envTheme
 	}
 
+	// Log through slog at the levels and in the format set in the logs
+	// page, with the log package's output bridged to it
+	controllers.ConfigureLogging(settings)
+
 	// Initialize AI system if enabled
 	ai.InitializeAISystem()
 	
 	// Initialize backup scheduler
 	backup.InitializeBackupScheduler()
+	if err == nil && settings.BackupDir != "" {
+		backup.Scheduler.SetBackupDir(settings.BackupDir)
+	}
+	// Copy backups off the host, encrypted, and prune them as configured
+	if err == nil {
+		controllers.ConfigureBackups(settings)
+	}
+
+	// Scan attachments with an external virus scanner when one is configured
+	if command := os.Getenv("ATTACHMENT_SCAN_COMMAND"); command != "" {
+		models.ScanAttachment = models.CommandScanner(command)
+	}
+
+	// Limit how many event streams one user can hold open
+	if limit, err := strconv.Atoi(os.Getenv("SSE_MAX_STREAMS_PER_USER")); err == nil {
+		sse.Streams.MaxPerUser = limit
+	}
 
 	// Configure rate limiting for production environment
 	rateLimitConfig := &middleware.RateLimitConfig{
@@ -57,28 +83,57 @@ func main() {
 		GeneralWindow: time.Minute,
 	}
 
-	// Create rate limiters
+	// Count requests where RATE_LIMIT_STORE says: in memory by default,
+	// in the database to survive restarts at the cost of a write per
+	// request, or in Redis for replicas sharing limits
+	switch store := os.Getenv("RATE_LIMIT_STORE"); {
+	case store == "database":
+		rateLimitConfig.Store = &models.DatabaseRateLimitStore{}
+	case strings.HasPrefix(store, "redis"):
+		redis, err := middleware.NewRedisStore(store)
+		if err != nil {
+			log.Fatalf("RATE_LIMIT_STORE: %v", err)
+		}
+		rateLimitConfig.Store = redis
+	default:
+		rateLimitConfig.Store = middleware.NewMemoryStore()
+	}
+
+	// Create rate limiters, limiting signed in users by who they are
+	authPrefix, auth := controllers.Auth()
 	limiters := middleware.CreateRateLimiters(rateLimitConfig)
 	routeLimiter := middleware.NewRouteRateLimiter(limiters)
+	routeLimiter.KeyFunc = auth.RateLimitKey
 
 	// Start application immediately
 	application.Serve(views,
+		application.WithMiddleware(logging.AppLogger),        // Request IDs and request logs
 		application.WithMiddleware(routeLimiter),
-		application.WithController(controllers.Auth()),       // Use custom auth controller
+		application.WithController(authPrefix, auth),         // Use custom auth controller
 		application.WithController(controllers.Logs()),       // Add logs controller
 		application.WithController(controllers.Home()),
 		application.WithController(controllers.Repos()),
 		application.WithController(controllers.Issues()),
+		application.WithController(controllers.Tasks()),
+		application.WithController(controllers.Projects()),
+		application.WithController(controllers.Notifications()),
+		application.WithController(controllers.Search()),
+		application.WithController(controllers.Usage()),
 		application.WithController(controllers.PullRequests()),
+		application.WithController(controllers.SavedViews()),
 		application.WithController(controllers.Actions()),
 		application.WithController(controllers.Integrations()),
 		application.WithController(controllers.AI()),
 		application.WithController(controllers.Workspaces()),
 		application.WithController(controllers.Settings()),
 		application.WithController(controllers.Monitoring()),
+		application.WithController(controllers.Alerts()),
 		application.WithController(controllers.Users()),
+		application.WithController(controllers.Teams()),
+		application.WithController(controllers.API()),
 		application.WithController(controllers.Health()),
 		application.WithController(controllers.Backup()),
+		application.WithController(controllers.Onboarding()),
 		application.WithHostPrefix(cmp.Or(os.Getenv("PREFIX"), "")),
 		application.WithDaisyTheme(theme),
 	)
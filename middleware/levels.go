@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Levels are the lowest levels logged, for every module and for the
+// modules named, written as "info,ai=debug,backup=warn"
+type Levels struct {
+	Default slog.Level
+	Modules map[string]slog.Level
+}
+
+// ParseLevels reads a default level followed by module=level pairs,
+// separated by commas. Empty logs at info.
+func ParseLevels(s string) (Levels, error) {
+	levels := Levels{Default: slog.LevelInfo, Modules: map[string]slog.Level{}}
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		module, name, found := strings.Cut(part, "=")
+		if !found {
+			module, name = "", module
+		} else if module = ModuleName(module); module == "" {
+			return Levels{}, fmt.Errorf("log level %q names no module", part)
+		}
+		level, err := ParseLevel(name)
+		if err != nil {
+			return Levels{}, err
+		}
+		if module == "" {
+			levels.Default = level
+		} else {
+			levels.Modules[module] = level
+		}
+	}
+	return levels, nil
+}
+
+// ParseLevel reads a level name: debug, info, warn or error
+func ParseLevel(name string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(strings.TrimSpace(name))); err != nil {
+		return level, fmt.Errorf("unknown log level %q, use debug, info, warn or error", strings.TrimSpace(name))
+	}
+	return level, nil
+}
+
+// For returns the lowest level logged for a module
+func (l Levels) For(module string) slog.Level {
+	if level, ok := l.Modules[module]; ok {
+		return level
+	}
+	return l.Default
+}
+
+// lowest returns the lowest level any module logs at
+func (l Levels) lowest() slog.Level {
+	lowest := l.Default
+	for _, level := range l.Modules {
+		lowest = min(lowest, level)
+	}
+	return lowest
+}
+
+// String writes the levels the way ParseLevels reads them
+func (l Levels) String() string {
+	parts := []string{strings.ToLower(l.Default.String())}
+	for _, module := range slices.Sorted(maps.Keys(l.Modules)) {
+		parts = append(parts, module+"="+strings.ToLower(l.Modules[module].String()))
+	}
+	return strings.Join(parts, ",")
+}
+
+// ModuleName turns the prefix of a log line, such as "AIController" or
+// "OllamaService", into the module it is configured and filtered by:
+// "ai" or "ollama"
+func ModuleName(prefix string) string {
+	name := strings.ToLower(strings.TrimSpace(prefix))
+	for _, suffix := range []string{"controller", "service"} {
+		if trimmed := strings.TrimSuffix(name, suffix); trimmed != "" {
+			name = trimmed
+		}
+	}
+	return name
+}
@@ -1,49 +1,27 @@
 package middleware
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
-	"net/http"
+	"log/slog"
+	"maps"
 	"os"
-	"runtime"
+	"regexp"
+	"slices"
 	"strings"
 	"sync"
 	"time"
 )
 
-// LogLevel represents the severity of a log message
-type LogLevel int
-
-const (
-	DEBUG LogLevel = iota
-	INFO
-	WARN
-	ERROR
-	FATAL
-)
-
-func (l LogLevel) String() string {
-	switch l {
-	case DEBUG:
-		return "DEBUG"
-	case INFO:
-		return "INFO"
-	case WARN:
-		return "WARN"
-	case ERROR:
-		return "ERROR"
-	case FATAL:
-		return "FATAL"
-	default:
-		return "UNKNOWN"
-	}
-}
-
 // LogEntry represents a structured log entry
 type LogEntry struct {
+	Seq        uint64         `json:"seq"` // Counts up from startup, for following the log
 	Timestamp  time.Time      `json:"timestamp"`
 	Level      string         `json:"level"`
+	Module     string         `json:"module,omitempty"`
 	Message    string         `json:"message"`
 	Method     string         `json:"method,omitempty"`
 	Path       string         `json:"path,omitempty"`
@@ -51,356 +29,505 @@ type LogEntry struct {
 	Duration   float64        `json:"duration_ms,omitempty"`
 	IP         string         `json:"ip,omitempty"`
 	UserAgent  string         `json:"user_agent,omitempty"`
-	UserID     int            `json:"user_id,omitempty"`
+	UserID     string         `json:"user_id,omitempty"`
 	RequestID  string         `json:"request_id,omitempty"`
 	Error      string         `json:"error,omitempty"`
-	StackTrace string         `json:"stack_trace,omitempty"`
 	Extra      map[string]any `json:"extra,omitempty"`
-}
 
-// Logger provides structured logging capabilities
-type Logger struct {
-	level      LogLevel
-	output     *log.Logger
-	buffer     []LogEntry
-	bufferLock sync.RWMutex
-	maxBuffer  int
+	level slog.Level
 }
 
-// NewLogger creates a new logger instance
-func NewLogger(level LogLevel) *Logger {
-	return &Logger{
-		level:     level,
-		output:    log.New(os.Stdout, "", 0),
-		buffer:    make([]LogEntry, 0, 1000),
-		maxBuffer: 1000,
+// Details writes the entry's fields after its message, as key=value pairs
+func (e LogEntry) Details() string {
+	var details strings.Builder
+	if e.StatusCode != 0 {
+		fmt.Fprintf(&details, " status=%d", e.StatusCode)
 	}
+	if e.Path != "" {
+		fmt.Fprintf(&details, " duration=%.2fms", e.Duration)
+	}
+	for _, field := range [][2]string{{"ip", e.IP}, {"user_id", e.UserID}, {"error", e.Error}} {
+		if field[1] != "" {
+			fmt.Fprintf(&details, " %s=%q", field[0], field[1])
+		}
+	}
+	for _, key := range slices.Sorted(maps.Keys(e.Extra)) {
+		fmt.Fprintf(&details, " %s=%v", key, e.Extra[key])
+	}
+	return strings.TrimPrefix(details.String(), " ")
 }
 
-// Log writes a log entry at the specified level
-func (l *Logger) Log(level LogLevel, message string, extra map[string]any) {
-	if level < l.level {
-		return
-	}
+// Line writes the entry as it appears in the text output, uncolored
+func (e LogEntry) Line() string {
+	return formatText(e, false)
+}
 
-	entry := LogEntry{
-		Timestamp: time.Now(),
-		Level:     level.String(),
-		Message:   message,
-		Extra:     extra,
+// set fills in the field an attribute names, keeping the others as extras
+func (e *LogEntry) set(key string, value slog.Value) {
+	value = value.Resolve()
+	if value.Kind() == slog.KindGroup {
+		for _, attr := range value.Group() {
+			e.set(joinKey(key, attr.Key), attr.Value)
+		}
+		return
 	}
 
-	// Add stack trace for errors
-	if level >= ERROR {
-		entry.StackTrace = l.getStackTrace()
+	switch key {
+	case "module":
+		e.Module = ModuleName(value.String())
+	case "request_id":
+		e.RequestID = value.String()
+	case "method":
+		e.Method = value.String()
+	case "path":
+		e.Path = value.String()
+	case "status":
+		e.StatusCode = int(value.Int64())
+	case "duration":
+		e.Duration = value.Duration().Seconds() * 1000
+	case "ip":
+		e.IP = value.String()
+	case "user_agent":
+		e.UserAgent = value.String()
+	case "user_id":
+		e.UserID = value.String()
+	case "error":
+		e.Error = value.String()
+	default:
+		if e.Extra == nil {
+			e.Extra = map[string]any{}
+		}
+		e.Extra[key] = value.Any()
 	}
+}
 
-	// Store in buffer
-	l.bufferLock.Lock()
-	l.buffer = append(l.buffer, entry)
-	if len(l.buffer) > l.maxBuffer {
-		l.buffer = l.buffer[len(l.buffer)-l.maxBuffer:]
+// joinKey names an attribute inside a group
+func joinKey(group, key string) string {
+	if group == "" {
+		return key
 	}
-	l.bufferLock.Unlock()
-
-	// Output based on environment
-	if os.Getenv("LOG_FORMAT") == "json" {
-		jsonBytes, _ := json.Marshal(entry)
-		l.output.Println(string(jsonBytes))
-	} else {
-		// Human-readable format for development
-		color := l.getColor(level)
-		reset := "\033[0m"
-		if os.Getenv("NO_COLOR") != "" {
-			color = ""
-			reset = ""
-		}
+	return group + "." + key
+}
 
-		timestamp := entry.Timestamp.Format("15:04:05")
-		l.output.Printf("%s%s [%s]%s %s", color, timestamp, entry.Level, reset, entry.Message)
+// Logger keeps recent log entries and writes them to its output as text
+// or as one JSON object per line for log shippers, dropping those below
+// their module's level. It backs slog and the log package once installed.
+type Logger struct {
+	mu          sync.RWMutex
+	levels      Levels
+	json        bool
+	output      io.Writer
+	buffer      []LogEntry
+	maxBuffer   int
+	seq         uint64
+	subscribers map[chan LogEntry]struct{}
+}
 
-		if entry.Error != "" {
-			l.output.Printf("  Error: %s", entry.Error)
-		}
-		if entry.StackTrace != "" && os.Getenv("DEBUG") == "true" {
-			l.output.Printf("  Stack:\n%s", entry.StackTrace)
-		}
+// NewLogger creates a new logger instance writing to output
+func NewLogger(output io.Writer) *Logger {
+	return &Logger{
+		levels:      Levels{Default: slog.LevelInfo},
+		output:      output,
+		buffer:      make([]LogEntry, 0, 5000),
+		maxBuffer:   5000,
+		subscribers: map[chan LogEntry]struct{}{},
 	}
 }
 
-// Helper methods for different log levels
-func (l *Logger) Debug(message string, extra ...map[string]any) {
-	e := l.mergeExtra(extra...)
-	l.Log(DEBUG, message, e)
+// Install makes the logger the default for slog and the log package, so
+// the log.Printf calls throughout the workspace are kept and filtered too
+func (l *Logger) Install() {
+	slog.SetDefault(slog.New(l.Handler()))
+	// SetDefault sends the log package to the handler at info, replace
+	// it with the bridge that reads each line's module and level
+	log.SetFlags(0)
+	log.SetOutput(bridge{l})
 }
 
-func (l *Logger) Info(message string, extra ...map[string]any) {
-	e := l.mergeExtra(extra...)
-	l.Log(INFO, message, e)
+// SetLevels changes the levels logged at
+func (l *Logger) SetLevels(levels Levels) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.levels = levels
 }
 
-func (l *Logger) Warn(message string, extra ...map[string]any) {
-	e := l.mergeExtra(extra...)
-	l.Log(WARN, message, e)
+// Levels returns the levels logged at
+func (l *Logger) Levels() Levels {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.levels
 }
 
-func (l *Logger) Error(message string, err error, extra ...map[string]any) {
-	e := l.mergeExtra(extra...)
-	if err != nil {
-		e["error"] = err.Error()
-	}
-	l.Log(ERROR, message, e)
+// SetJSON switches the output between text and JSON lines
+func (l *Logger) SetJSON(on bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.json = on
 }
 
-func (l *Logger) Fatal(message string, err error, extra ...map[string]any) {
-	e := l.mergeExtra(extra...)
-	if err != nil {
-		e["error"] = err.Error()
-	}
-	l.Log(FATAL, message, e)
-	os.Exit(1)
+// JSON reports whether entries are written as JSON lines
+func (l *Logger) JSON() bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.json
 }
 
-// LogRequest logs HTTP request details
-func (l *Logger) LogRequest(r *http.Request, statusCode int, duration time.Duration, userID int) {
-	entry := LogEntry{
-		Timestamp:  time.Now(),
-		Level:      INFO.String(),
-		Message:    fmt.Sprintf("%s %s", r.Method, r.URL.Path),
-		Method:     r.Method,
-		Path:       r.URL.Path,
-		StatusCode: statusCode,
-		Duration:   duration.Seconds() * 1000, // Convert to milliseconds
-		IP:         l.getClientIP(r),
-		UserAgent:  r.UserAgent(),
-		RequestID:  r.Header.Get("X-Request-ID"),
-	}
+// Enabled reports whether a module logs at a level
+func (l *Logger) Enabled(module string, level slog.Level) bool {
+	return level >= l.Levels().For(module)
+}
 
-	if userID > 0 {
-		entry.UserID = userID
-	}
+// Handler returns an slog handler that logs through l
+func (l *Logger) Handler() slog.Handler {
+	return &handler{logger: l}
+}
 
-	// Determine log level based on status code
-	level := INFO
-	if statusCode >= 500 {
-		level = ERROR
-	} else if statusCode >= 400 {
-		level = WARN
-	}
+// log keeps an entry and writes it out
+func (l *Logger) log(entry LogEntry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
 
-	// Store in buffer
-	l.bufferLock.Lock()
+	l.seq++
+	entry.Seq = l.seq
 	l.buffer = append(l.buffer, entry)
 	if len(l.buffer) > l.maxBuffer {
 		l.buffer = l.buffer[len(l.buffer)-l.maxBuffer:]
 	}
-	l.bufferLock.Unlock()
-
-	// Output
-	if os.Getenv("LOG_FORMAT") == "json" {
-		jsonBytes, _ := json.Marshal(entry)
-		l.output.Println(string(jsonBytes))
-	} else {
-		color := l.getColor(level)
-		reset := "\033[0m"
-		if os.Getenv("NO_COLOR") != "" {
-			color = ""
-			reset = ""
+	for subscriber := range l.subscribers {
+		select {
+		case subscriber <- entry:
+		default: // Too slow to keep up, it can catch up from the buffer
 		}
+	}
 
-		timestamp := entry.Timestamp.Format("15:04:05")
-		l.output.Printf("%s%s [HTTP]%s %d %s %s (%.2fms) %s",
-			color, timestamp, reset, statusCode, r.Method, r.URL.Path, entry.Duration, entry.IP)
+	if l.json {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			data, _ = json.Marshal(LogEntry{Seq: entry.Seq, Timestamp: entry.Timestamp, Level: entry.Level, Module: entry.Module, Message: entry.Message, RequestID: entry.RequestID})
+		}
+		fmt.Fprintln(l.output, string(data))
+		return
 	}
+	fmt.Fprintln(l.output, formatText(entry, os.Getenv("NO_COLOR") == ""))
 }
 
-// GetRecentLogs returns recent log entries from the buffer
-func (l *Logger) GetRecentLogs(limit int) []LogEntry {
-	l.bufferLock.RLock()
-	defer l.bufferLock.RUnlock()
-
-	if limit <= 0 || limit > len(l.buffer) {
-		limit = len(l.buffer)
+// Subscribe returns a channel receiving each entry as it's logged, and a
+// function that stops it. Entries are dropped while the channel is full.
+func (l *Logger) Subscribe() (<-chan LogEntry, func()) {
+	subscriber := make(chan LogEntry, 256)
+	l.mu.Lock()
+	l.subscribers[subscriber] = struct{}{}
+	l.mu.Unlock()
+
+	return subscriber, func() {
+		l.mu.Lock()
+		delete(l.subscribers, subscriber)
+		l.mu.Unlock()
 	}
+}
 
-	start := len(l.buffer) - limit
-	if start < 0 {
-		start = 0
+// formatText writes an entry for people reading the output, colored by
+// level when asked
+func formatText(entry LogEntry, colored bool) string {
+	var line strings.Builder
+	color, reset := levelColor(entry.level), "\033[0m"
+	if !colored {
+		color, reset = "", ""
 	}
-
-	result := make([]LogEntry, limit)
-	copy(result, l.buffer[start:])
-	return result
+	fmt.Fprintf(&line, "%s%s [%s]%s ", color, entry.Timestamp.Format("15:04:05"), entry.Level, reset)
+	if entry.Module != "" {
+		line.WriteString(entry.Module + ": ")
+	}
+	line.WriteString(entry.Message)
+	if details := entry.Details(); details != "" {
+		line.WriteString(" " + details)
+	}
+	if entry.RequestID != "" {
+		fmt.Fprintf(&line, " request_id=%q", entry.RequestID)
+	}
+	return line.String()
 }
 
-// GetLogStats returns statistics about recent logs
-func (l *Logger) GetLogStats() map[string]any {
-	l.bufferLock.RLock()
-	defer l.bufferLock.RUnlock()
-
-	stats := map[string]int{
-		"total": len(l.buffer),
-		"debug": 0,
-		"info":  0,
-		"warn":  0,
-		"error": 0,
-		"fatal": 0,
+func levelColor(level slog.Level) string {
+	switch {
+	case level >= slog.LevelError:
+		return "\033[31m" // Red
+	case level >= slog.LevelWarn:
+		return "\033[33m" // Yellow
+	case level >= slog.LevelInfo:
+		return "\033[32m" // Green
+	default:
+		return "\033[36m" // Cyan
 	}
+}
 
-	for _, entry := range l.buffer {
-		switch entry.Level {
-		case "DEBUG":
-			stats["debug"]++
-		case "INFO":
-			stats["info"]++
-		case "WARN":
-			stats["warn"]++
-		case "ERROR":
-			stats["error"]++
-		case "FATAL":
-			stats["fatal"]++
+// handler is the slog.Handler of a Logger. Attributes added with With are
+// kept flattened, with the groups they were added in as key prefixes.
+type handler struct {
+	logger *Logger
+	attrs  []slog.Attr
+	group  string
+}
+
+// module returns the module attribute added to the handler, if any
+func (h *handler) module() string {
+	for _, attr := range h.attrs {
+		if attr.Key == "module" {
+			return ModuleName(attr.Value.String())
 		}
 	}
+	return ""
+}
 
-	return map[string]any{
-		"counts":      stats,
-		"buffer_size": l.maxBuffer,
+// Enabled checks the module's level when the handler knows it, otherwise
+// the record is let through to Handle, which reads its module
+func (h *handler) Enabled(_ context.Context, level slog.Level) bool {
+	if module := h.module(); module != "" {
+		return h.logger.Enabled(module, level)
 	}
+	return level >= h.logger.Levels().lowest()
 }
 
-// HTTPLoggingMiddleware creates middleware for logging HTTP requests
-func (l *Logger) HTTPLoggingMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Skip logging for static assets and health checks
-		if strings.HasPrefix(r.URL.Path, "/public/") ||
-			strings.HasPrefix(r.URL.Path, "/health") ||
-			strings.HasPrefix(r.URL.Path, "/favicon.ico") {
-			next.ServeHTTP(w, r)
-			return
-		}
+// Handle turns a record into an entry. Its request ID comes from the
+// context when not given, and its module from a "Module: " prefix on the
+// message when not given.
+func (h *handler) Handle(ctx context.Context, record slog.Record) error {
+	entry := LogEntry{
+		Timestamp: record.Time,
+		Level:     record.Level.String(),
+		Message:   record.Message,
+		level:     record.Level,
+	}
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now()
+	}
+	for _, attr := range h.attrs {
+		entry.set(attr.Key, attr.Value)
+	}
+	record.Attrs(func(attr slog.Attr) bool {
+		entry.set(joinKey(h.group, attr.Key), attr.Value)
+		return true
+	})
 
-		start := time.Now()
+	if entry.RequestID == "" {
+		entry.RequestID = RequestID(ctx)
+	}
+	if entry.Module == "" {
+		entry.Module, entry.Message = splitModule(entry.Message)
+	}
+	if h.logger.Enabled(entry.Module, entry.level) {
+		h.logger.log(entry)
+	}
+	return nil
+}
 
-		// Wrap response writer to capture status code
-		lrw := &loggingResponseWriter{
-			ResponseWriter: w,
-			statusCode:     http.StatusOK,
-		}
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	with := &handler{logger: h.logger, group: h.group, attrs: slices.Clone(h.attrs)}
+	for _, attr := range attrs {
+		with.attrs = append(with.attrs, slog.Attr{Key: joinKey(h.group, attr.Key), Value: attr.Value})
+	}
+	return with
+}
 
-		// Add request ID if not present
-		if r.Header.Get("X-Request-ID") == "" {
-			r.Header.Set("X-Request-ID", l.generateRequestID())
-		}
+func (h *handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &handler{logger: h.logger, attrs: h.attrs, group: joinKey(h.group, name)}
+}
 
-		// Serve the request
-		next.ServeHTTP(lrw, r)
+// bridge logs the lines the log package writes, reading the module from
+// a "Module: " prefix and the level from the words the message starts
+// with, such as "Warning:" or "Failed to"
+type bridge struct {
+	logger *Logger
+}
 
-		// Log the request
-		duration := time.Since(start)
-		l.LogRequest(r, lrw.statusCode, duration, 0) // UserID would be extracted from context
-	})
+func (b bridge) Write(p []byte) (int, error) {
+	module, message := splitModule(strings.TrimRight(string(p), "\n"))
+	level := guessLevel(message)
+	if b.logger.Enabled(module, level) {
+		b.logger.log(LogEntry{
+			Timestamp: time.Now(),
+			Level:     level.String(),
+			Module:    module,
+			Message:   message,
+			level:     level,
+		})
+	}
+	return len(p), nil
 }
 
-// Helper functions
+// splitModule takes the module off a message such as "AIController: ..."
+func splitModule(message string) (string, string) {
+	prefix, rest, found := strings.Cut(message, ": ")
+	if !found || prefix == "" || len(prefix) > 40 || strings.ContainsFunc(prefix, notModuleRune) {
+		return "", message
+	}
+	switch strings.ToLower(prefix) {
+	case "warning", "warn", "error", "info", "debug", "fatal", "note":
+		return "", message
+	}
+	return ModuleName(prefix), rest
+}
 
-func (l *Logger) getStackTrace() string {
-	buf := make([]byte, 4096)
-	n := runtime.Stack(buf, false)
-	return string(buf[:n])
+func notModuleRune(r rune) bool {
+	return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-')
 }
 
-func (l *Logger) getColor(level LogLevel) string {
-	switch level {
-	case DEBUG:
-		return "\033[36m" // Cyan
-	case INFO:
-		return "\033[32m" // Green
-	case WARN:
-		return "\033[33m" // Yellow
-	case ERROR:
-		return "\033[31m" // Red
-	case FATAL:
-		return "\033[35m" // Magenta
-	default:
-		return ""
+// guessLevel reads the level of a log package line from how it starts
+func guessLevel(message string) slog.Level {
+	lower := strings.ToLower(message)
+	switch {
+	case strings.HasPrefix(lower, "warn"):
+		return slog.LevelWarn
+	case strings.HasPrefix(lower, "error"), strings.HasPrefix(lower, "failed"),
+		strings.HasPrefix(lower, "fatal"), strings.HasPrefix(lower, "panic"):
+		return slog.LevelError
+	case strings.HasPrefix(lower, "debug"):
+		return slog.LevelDebug
 	}
+	return slog.LevelInfo
 }
 
-func (l *Logger) getClientIP(r *http.Request) string {
-	forwarded := r.Header.Get("X-Forwarded-For")
-	if forwarded != "" {
-		parts := strings.Split(forwarded, ",")
-		return strings.TrimSpace(parts[0])
-	}
+// LogFilter picks log entries. Empty fields match every entry.
+type LogFilter struct {
+	Level     string // The lowest level shown
+	Module    string
+	RequestID string
+	Search    string         // Case insensitive, in the message, path and error
+	Pattern   *regexp.Regexp // Matched against the entry's line
+	From, To  time.Time      // Logged at or after From and before To
+	After     uint64         // Logged after the entry with this sequence number
+}
 
-	if realIP := r.Header.Get("X-Real-IP"); realIP != "" {
-		return realIP
+// Matches reports whether an entry passes the filter
+func (f LogFilter) Matches(entry LogEntry) bool {
+	if f.Level != "" {
+		if level, err := ParseLevel(f.Level); err == nil && entry.level < level {
+			return false
+		}
 	}
-
-	return strings.Split(r.RemoteAddr, ":")[0]
+	if f.Module != "" && entry.Module != f.Module {
+		return false
+	}
+	if f.RequestID != "" && entry.RequestID != f.RequestID {
+		return false
+	}
+	if entry.Seq <= f.After {
+		return false
+	}
+	if !f.From.IsZero() && entry.Timestamp.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && !entry.Timestamp.Before(f.To) {
+		return false
+	}
+	if f.Search != "" {
+		search := strings.ToLower(f.Search)
+		if !strings.Contains(strings.ToLower(entry.Message), search) &&
+			!strings.Contains(strings.ToLower(entry.Path), search) &&
+			!strings.Contains(strings.ToLower(entry.Error), search) {
+			return false
+		}
+	}
+	if f.Pattern != nil && !f.Pattern.MatchString(entry.Line()) {
+		return false
+	}
+	return true
 }
 
-func (l *Logger) generateRequestID() string {
-	return fmt.Sprintf("%d-%d", time.Now().UnixNano(), runtime.NumGoroutine())
+// GetRecentLogs returns recent log entries from the buffer
+func (l *Logger) GetRecentLogs(limit int) []LogEntry {
+	return l.Query(LogFilter{}, limit)
 }
 
-func (l *Logger) mergeExtra(extras ...map[string]any) map[string]any {
-	result := make(map[string]any)
-	for _, extra := range extras {
-		for k, v := range extra {
-			result[k] = v
+// Query returns the latest entries passing a filter, oldest first, at
+// most limit of them when it's above zero
+func (l *Logger) Query(filter LogFilter, limit int) []LogEntry {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	var result []LogEntry
+	for i := len(l.buffer) - 1; i >= 0 && (limit <= 0 || len(result) < limit); i-- {
+		if filter.Matches(l.buffer[i]) {
+			result = append(result, l.buffer[i])
 		}
 	}
+	slices.Reverse(result)
 	return result
 }
 
-// loggingResponseWriter wraps http.ResponseWriter to capture status code
-type loggingResponseWriter struct {
-	http.ResponseWriter
-	statusCode int
-	written    bool
+// Modules returns the modules of the buffered entries, sorted
+func (l *Logger) Modules() []string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	seen := map[string]bool{}
+	for _, entry := range l.buffer {
+		if entry.Module != "" {
+			seen[entry.Module] = true
+		}
+	}
+	return slices.Sorted(maps.Keys(seen))
 }
 
-func (lrw *loggingResponseWriter) WriteHeader(code int) {
-	if !lrw.written {
-		lrw.statusCode = code
-		lrw.written = true
-		lrw.ResponseWriter.WriteHeader(code)
+// GetLogStats returns statistics about recent logs
+func (l *Logger) GetLogStats() map[string]any {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	stats := map[string]int{
+		"total": len(l.buffer),
+		"debug": 0,
+		"info":  0,
+		"warn":  0,
+		"error": 0,
+	}
+
+	for _, entry := range l.buffer {
+		switch {
+		case entry.level >= slog.LevelError:
+			stats["error"]++
+		case entry.level >= slog.LevelWarn:
+			stats["warn"]++
+		case entry.level >= slog.LevelInfo:
+			stats["info"]++
+		default:
+			stats["debug"]++
+		}
+	}
+
+	return map[string]any{
+		"counts":      stats,
+		"buffer_size": l.maxBuffer,
 	}
 }
 
-func (lrw *loggingResponseWriter) Write(b []byte) (int, error) {
-	if !lrw.written {
-		lrw.written = true
+// For returns a logger for a module whose entries carry the ID of the
+// request ctx belongs to, for controllers and the services they call
+func For(ctx context.Context, module string) *slog.Logger {
+	logger := slog.New(AppLogger.Handler()).With("module", module)
+	if id := RequestID(ctx); id != "" {
+		logger = logger.With("request_id", id)
 	}
-	return lrw.ResponseWriter.Write(b)
+	return logger
 }
 
 // Global logger instance
-var AppLogger = NewLogger(INFO)
+var AppLogger = NewLogger(os.Stdout)
 
-// Initialize logger based on environment
+// Initialize logger based on environment. LOG_LEVEL takes the same
+// levels as the settings, such as "info,ai=debug".
 func init() {
-	// Set log level based on environment
+	levels, err := ParseLevels(os.Getenv("LOG_LEVEL"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "LOG_LEVEL: %v\n", err)
+		levels, _ = ParseLevels("")
+	}
 	if os.Getenv("DEBUG") == "true" {
-		AppLogger.level = DEBUG
-	} else if os.Getenv("LOG_LEVEL") != "" {
-		switch strings.ToUpper(os.Getenv("LOG_LEVEL")) {
-		case "DEBUG":
-			AppLogger.level = DEBUG
-		case "INFO":
-			AppLogger.level = INFO
-		case "WARN":
-			AppLogger.level = WARN
-		case "ERROR":
-			AppLogger.level = ERROR
-		case "FATAL":
-			AppLogger.level = FATAL
-		}
+		levels.Default = slog.LevelDebug
 	}
-
-	AppLogger.Info("Logger initialized", map[string]any{
-		"level":  AppLogger.level.String(),
-		"format": os.Getenv("LOG_FORMAT"),
-	})
+	AppLogger.SetLevels(levels)
+	AppLogger.SetJSON(os.Getenv("LOG_FORMAT") == "json")
 }
@@ -0,0 +1,179 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseLevels(t *testing.T) {
+	levels, err := ParseLevels("warn, AIController=debug,backup=error")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if levels.For("http") != slog.LevelWarn || levels.For("ai") != slog.LevelDebug || levels.For("backup") != slog.LevelError {
+		t.Errorf("unexpected levels %v", levels)
+	}
+	if levels.String() != "warn,ai=debug,backup=error" {
+		t.Errorf("expected levels to round trip, got %q", levels.String())
+	}
+
+	if levels, err := ParseLevels(""); err != nil || levels.For("ai") != slog.LevelInfo {
+		t.Errorf("expected info by default, got %v, %v", levels, err)
+	}
+	for _, invalid := range []string{"loud", "ai=loud", "=debug"} {
+		if _, err := ParseLevels(invalid); err == nil {
+			t.Errorf("expected %q to be refused", invalid)
+		}
+	}
+}
+
+func TestBridge(t *testing.T) {
+	var out bytes.Buffer
+	logger := NewLogger(&out)
+	logger.SetLevels(Levels{Default: slog.LevelInfo, Modules: map[string]slog.Level{"ollama": slog.LevelError}})
+	b := bridge{logger}
+
+	b.Write([]byte("BackupController: Failed to copy backup: timeout\n"))
+	b.Write([]byte("Warning: disk almost full\n"))
+	b.Write([]byte("OllamaService: Pulling model llama3\n"))
+	b.Write([]byte("Starting server on :5000\n"))
+
+	entries := logger.GetRecentLogs(0)
+	if len(entries) != 3 {
+		t.Fatalf("expected the ollama line to be dropped, got %+v", entries)
+	}
+	if entries[0].Module != "backup" || entries[0].Level != "ERROR" || entries[0].Message != "Failed to copy backup: timeout" {
+		t.Errorf("unexpected entry %+v", entries[0])
+	}
+	if entries[1].Module != "" || entries[1].Level != "WARN" {
+		t.Errorf("unexpected entry %+v", entries[1])
+	}
+	if entries[2].Module != "" || entries[2].Level != "INFO" {
+		t.Errorf("unexpected entry %+v", entries[2])
+	}
+}
+
+func TestRequestIDs(t *testing.T) {
+	var out bytes.Buffer
+	logger := NewLogger(&out)
+	logger.SetJSON(true)
+	saved := AppLogger
+	AppLogger = logger
+	defer func() { AppLogger = saved }()
+
+	var seen string
+	handler := logger.HTTPLoggingMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = RequestID(r.Context())
+		For(r.Context(), "BackupController").Info("restoring", "backup", "b1")
+		w.WriteHeader(http.StatusNotFound)
+	}))
+
+	// A proxy's ID is kept
+	r := httptest.NewRequest("GET", "/backup/list", nil)
+	r.Header.Set(RequestIDHeader, "proxy-1")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if seen != "proxy-1" || w.Header().Get(RequestIDHeader) != "proxy-1" {
+		t.Errorf("expected the proxy's ID, got %q and %q", seen, w.Header().Get(RequestIDHeader))
+	}
+
+	// Otherwise one is made up, and unsafe ones are replaced
+	r = httptest.NewRequest("GET", "/backup/list", nil)
+	r.Header.Set(RequestIDHeader, "bad id\n")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if seen == "" || seen == "bad id\n" || w.Header().Get(RequestIDHeader) != seen {
+		t.Errorf("expected a new ID, got %q", seen)
+	}
+
+	entries := logger.Query(LogFilter{RequestID: "proxy-1"}, 0)
+	if len(entries) != 2 {
+		t.Fatalf("expected the service entry and the request, got %+v", entries)
+	}
+	if entries[0].Module != "backup" || entries[0].Extra["backup"] != "b1" {
+		t.Errorf("unexpected entry %+v", entries[0])
+	}
+	if entries[1].Module != "http" || entries[1].StatusCode != 404 || entries[1].Level != "WARN" {
+		t.Errorf("unexpected entry %+v", entries[1])
+	}
+
+	// Each line of the output is a JSON object
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("expected 4 lines, got %q", out.String())
+	}
+	var entry LogEntry
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil || entry.RequestID != "proxy-1" || entry.Message != "restoring" {
+		t.Errorf("unexpected line %q: %v", lines[0], err)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	logger := NewLogger(&bytes.Buffer{})
+	logger.SetLevels(Levels{Default: slog.LevelDebug})
+	log := slog.New(logger.Handler())
+
+	log.Debug("AIController: building prompt")
+	log.Info("SearchService: indexed repository")
+	log.With("module", "ai").Error("chat failed", "error", "timeout")
+
+	if got := logger.Query(LogFilter{Level: "warn"}, 0); len(got) != 1 || got[0].Error != "timeout" {
+		t.Errorf("expected only the error, got %+v", got)
+	}
+	if got := logger.Query(LogFilter{Module: "ai"}, 0); len(got) != 2 {
+		t.Errorf("expected both ai entries, got %+v", got)
+	}
+	if got := logger.Query(LogFilter{Search: "INDEXED"}, 0); len(got) != 1 || got[0].Module != "search" {
+		t.Errorf("expected the search entry, got %+v", got)
+	}
+	if got := logger.Query(LogFilter{}, 1); len(got) != 1 || got[0].Message != "chat failed" {
+		t.Errorf("expected the latest entry, got %+v", got)
+	}
+	if modules := logger.Modules(); len(modules) != 2 || modules[0] != "ai" || modules[1] != "search" {
+		t.Errorf("unexpected modules %v", modules)
+	}
+}
+
+func TestFollow(t *testing.T) {
+	logger := NewLogger(&bytes.Buffer{})
+	log := slog.New(logger.Handler())
+
+	log.Info("BackupController: backup started")
+	start := logger.GetRecentLogs(1)[0]
+
+	entries, stop := logger.Subscribe()
+	log.Error("BackupController: Failed to upload backup", "error", "connection reset")
+	log.Info("SearchService: indexed repository")
+	stop()
+	log.Info("BackupController: backup finished")
+
+	var followed []LogEntry
+	for len(entries) > 0 {
+		followed = append(followed, <-entries)
+	}
+	if len(followed) != 2 || followed[0].Seq != start.Seq+1 {
+		t.Fatalf("expected the two entries logged while subscribed, got %+v", followed)
+	}
+
+	// Patterns match the line as written out, including its details
+	filter := LogFilter{Pattern: regexp.MustCompile(`backup: .*error="connection`), After: start.Seq}
+	if !filter.Matches(followed[0]) || filter.Matches(followed[1]) || filter.Matches(start) {
+		t.Error("expected only the failed upload to match")
+	}
+
+	filter = LogFilter{From: start.Timestamp, To: start.Timestamp.Add(time.Hour)}
+	if got := logger.Query(filter, 0); len(got) != 4 {
+		t.Errorf("expected every entry within the hour, got %d", len(got))
+	}
+	filter.To = start.Timestamp
+	if got := logger.Query(filter, 0); len(got) != 0 {
+		t.Errorf("expected the range to end before To, got %+v", got)
+	}
+}
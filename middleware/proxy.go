@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+// trustedProxies are the networks in TRUSTED_PROXIES, comma separated
+// addresses or CIDRs of the reverse proxies in front of the workspace
+var trustedProxies = sync.OnceValue(func() []*net.IPNet {
+	var networks []*net.IPNet
+	for _, entry := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			if ip := net.ParseIP(entry); ip != nil && ip.To4() != nil {
+				entry += "/32"
+			} else {
+				entry += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			log.Printf("Ignoring trusted proxy %q: %v", entry, err)
+			continue
+		}
+		networks = append(networks, network)
+	}
+	return networks
+})
+
+// isTrustedProxy reports whether an address is one of TRUSTED_PROXIES
+func isTrustedProxy(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, network := range trustedProxies() {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the visitor's address. X-Forwarded-For is only believed
+// when the request came through a trusted proxy, and then the nearest hop
+// that isn't one of them is the visitor, since anything further left could
+// have been sent by the visitor themselves.
+func ClientIP(r *http.Request) string {
+	addr := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		addr = host
+	}
+	if !isTrustedProxy(addr) {
+		return addr
+	}
+
+	hops := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		if !isTrustedProxy(hop) {
+			return hop
+		}
+		addr = hop
+	}
+	return addr
+}
@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"net"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	_, network, _ := net.ParseCIDR("10.0.0.0/8")
+	proxies := trustedProxies
+	trustedProxies = func() []*net.IPNet { return []*net.IPNet{network} }
+	defer func() { trustedProxies = proxies }()
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  string
+		want       string
+	}{
+		{"direct", "203.0.113.7:5000", "", "203.0.113.7"},
+		{"spoofed without proxy", "203.0.113.7:5000", "198.51.100.1", "203.0.113.7"},
+		{"through proxy", "10.0.0.2:443", "198.51.100.1", "198.51.100.1"},
+		{"spoofed through proxy", "10.0.0.2:443", "192.0.2.9, 198.51.100.1", "198.51.100.1"},
+		{"through chained proxies", "10.0.0.2:443", "198.51.100.1, 10.0.0.3", "198.51.100.1"},
+		{"proxy without header", "10.0.0.2:443", "", "10.0.0.2"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = tt.remoteAddr
+		if tt.forwarded != "" {
+			r.Header.Set("X-Forwarded-For", tt.forwarded)
+		}
+		if got := ClientIP(r); got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.want, got)
+		}
+	}
+}
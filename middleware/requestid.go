@@ -0,0 +1,168 @@
+package middleware
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// RequestIDHeader carries a request's ID from a proxy in front of the
+// workspace, and back to the client in the response
+const RequestIDHeader = "X-Request-ID"
+
+type requestIDKey struct{}
+
+// WithRequestID returns a context carrying a request ID, for work a
+// request hands to another goroutine
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the ID of the request a context belongs to, empty
+// outside of one
+func RequestID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// newRequestID generates a random request ID
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// validRequestID reports whether an ID sent by a proxy is safe to log
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	return !strings.ContainsFunc(id, func(r rune) bool {
+		return notModuleRune(r) && r != '.' && r != ':'
+	})
+}
+
+// Handle implements the application.Middleware interface
+func (l *Logger) Handle(next http.Handler) http.Handler {
+	return l.HTTPLoggingMiddleware(next)
+}
+
+// HTTPLoggingMiddleware gives each request an ID, kept from the proxy's
+// X-Request-ID when it sent one, puts it in the request's context and the
+// response, and logs the request once it's served
+func (l *Logger) HTTPLoggingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+			r.Header.Set(RequestIDHeader, id)
+		}
+		w.Header().Set(RequestIDHeader, id)
+		r = r.WithContext(WithRequestID(r.Context(), id))
+
+		// Skip logging for static assets and health checks
+		if strings.HasPrefix(r.URL.Path, "/public/") ||
+			strings.HasPrefix(r.URL.Path, "/health") ||
+			strings.HasPrefix(r.URL.Path, "/favicon.ico") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+
+		// Wrap response writer to capture status code
+		lrw := &loggingResponseWriter{
+			ResponseWriter: w,
+			statusCode:     http.StatusOK,
+		}
+
+		// Serve the request
+		next.ServeHTTP(lrw, r)
+
+		// Log the request
+		l.LogRequest(r, lrw.statusCode, time.Since(start))
+	})
+}
+
+// LogRequest logs HTTP request details under the http module, as a
+// warning for client errors and an error for server errors
+func (l *Logger) LogRequest(r *http.Request, statusCode int, duration time.Duration) {
+	level := slog.LevelInfo
+	if statusCode >= 500 {
+		level = slog.LevelError
+	} else if statusCode >= 400 {
+		level = slog.LevelWarn
+	}
+	if !l.Enabled("http", level) {
+		return
+	}
+
+	l.log(LogEntry{
+		Timestamp:  time.Now(),
+		Level:      level.String(),
+		Module:     "http",
+		Message:    r.Method + " " + r.URL.Path,
+		Method:     r.Method,
+		Path:       r.URL.Path,
+		StatusCode: statusCode,
+		Duration:   duration.Seconds() * 1000, // Convert to milliseconds
+		IP:         ClientIP(r),
+		UserAgent:  r.UserAgent(),
+		RequestID:  RequestID(r.Context()),
+		level:      level,
+	})
+}
+
+// loggingResponseWriter wraps http.ResponseWriter to capture status code.
+// Streams and websockets still reach the connection through it.
+type loggingResponseWriter struct {
+	http.ResponseWriter
+	statusCode int
+	written    bool
+}
+
+func (lrw *loggingResponseWriter) WriteHeader(code int) {
+	if !lrw.written {
+		lrw.statusCode = code
+		lrw.written = true
+		lrw.ResponseWriter.WriteHeader(code)
+	}
+}
+
+func (lrw *loggingResponseWriter) Write(b []byte) (int, error) {
+	if !lrw.written {
+		lrw.written = true
+	}
+	return lrw.ResponseWriter.Write(b)
+}
+
+func (lrw *loggingResponseWriter) Flush() {
+	if flusher, ok := lrw.ResponseWriter.(http.Flusher); ok {
+		lrw.written = true
+		flusher.Flush()
+	}
+}
+
+func (lrw *loggingResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := lrw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("connection can't be hijacked")
+	}
+	lrw.statusCode = http.StatusSwitchingProtocols
+	return hijacker.Hijack()
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (lrw *loggingResponseWriter) Unwrap() http.ResponseWriter {
+	return lrw.ResponseWriter
+}
@@ -2,6 +2,7 @@ package models
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"time"
 
@@ -36,6 +37,12 @@ func GenerateToken() string {
 	return hex.EncodeToString(bytes)
 }
 
+// hashToken returns the stored form of a token that is only shown once
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
 // CreateAccessToken creates a new access token
 func CreateAccessToken(repoID, userID string, duration time.Duration) (*AccessToken, error) {
 	token := &AccessToken{
@@ -23,6 +23,8 @@ type Action struct {
 	LastRun         *time.Time // When the action was last run
 	LastSuccess     *time.Time // When the action last succeeded
 	NextRun         *time.Time // For scheduled actions, when to run next
+	Schedule        string     // For scheduled actions, a cron expression like "0 3 * * *"
+	OverlapPolicy   string     // For scheduled actions, what to do when the last run hasn't finished
 	ExecutionCount  int        // How many times this action has been executed
 	SuccessCount    int        // How many times it succeeded
 	FailureCount    int        // How many times it failed
@@ -50,6 +52,11 @@ type Action struct {
 
 func (*Action) Table() string { return "actions" }
 
+// Repository returns the repository this action belongs to
+func (a *Action) Repository() (*Repository, error) {
+	return Repos.Get(a.RepoID)
+}
+
 // NOTE: Execution logic has been moved to services/actions.go to avoid circular dependencies
 // The following methods are now handled by services.Actions:
 // - ExecuteAction(action *Action) error
@@ -1,7 +1,11 @@
 package models
 
 import (
+	"bytes"
+	"io"
+
 	"github.com/The-Skyscape/devtools/pkg/application"
+	"github.com/pkg/errors"
 )
 
 // ActionArtifact represents a file artifact saved from an action execution
@@ -16,7 +20,8 @@ type ActionArtifact struct {
 	Version     int    // Version number for this artifact group
 	ContentType string // MIME type of the file
 	Size        int64  // File size in bytes
-	Content     []byte // BLOB field for file content
+	Content     []byte // BLOB field for file content, empty once kept in file storage
+	StorageKey  string // Where the content is kept in file storage
 }
 
 // Table returns the database table name
@@ -101,3 +106,31 @@ func (a *ActionArtifact) Save() error {
 	a.UpdatedAt = artifact.UpdatedAt
 	return nil
 }
+
+// StoreArtifact keeps the artifact's content in file storage and records it
+func StoreArtifact(a *ActionArtifact, content []byte) error {
+	// Workflow artifacts belong to a run rather than an action
+	owner := a.ActionID
+	if owner == "" {
+		owner = "workflows/" + a.RunID
+	}
+	a.StorageKey = "artifacts/" + owner + "/" + a.ID
+	a.Content = nil
+	if err := Storage().Put(a.StorageKey, bytes.NewReader(content)); err != nil {
+		return errors.Wrap(err, "failed to store artifact")
+	}
+	if _, err := ActionArtifacts.Insert(a); err != nil {
+		Storage().Delete(a.StorageKey)
+		return errors.Wrap(err, "failed to record artifact")
+	}
+	return nil
+}
+
+// Open opens the artifact's content, from the database for artifacts saved
+// before file storage
+func (a *ActionArtifact) Open() (io.ReadCloser, error) {
+	if a.StorageKey == "" {
+		return io.NopCloser(bytes.NewReader(a.Content)), nil
+	}
+	return Storage().Get(a.StorageKey)
+}
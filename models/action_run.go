@@ -54,6 +54,32 @@ func GetLatestRunByAction(actionID string) (*ActionRun, error) {
 	return runs[0], nil
 }
 
+// GetLatestRunOnBranch returns the most recent run of an action on a
+// branch, or nil when it hasn't run there
+func GetLatestRunOnBranch(actionID, branch string) (*ActionRun, error) {
+	runs, err := ActionRuns.Search("WHERE ActionID = ? AND Branch = ? ORDER BY CreatedAt DESC LIMIT 1", actionID, branch)
+	if err != nil {
+		return nil, err
+	}
+	if len(runs) == 0 {
+		return nil, nil
+	}
+	return runs[0], nil
+}
+
+// GetLatestFailedRun returns the most recent failed run of any action in a
+// repository, or nil when none has failed
+func GetLatestFailedRun(repoID string) (*ActionRun, error) {
+	runs, err := ActionRuns.Search("WHERE Status = 'failed' AND ActionID IN (SELECT ID FROM actions WHERE RepoID = ?) ORDER BY CreatedAt DESC LIMIT 1", repoID)
+	if err != nil {
+		return nil, err
+	}
+	if len(runs) == 0 {
+		return nil, nil
+	}
+	return runs[0], nil
+}
+
 // GetRunningByAction returns any currently running instance of an action
 func GetRunningByAction(actionID string) (*ActionRun, error) {
 	runs, err := ActionRuns.Search("WHERE ActionID = ? AND Status = 'running' ORDER BY CreatedAt DESC LIMIT 1", actionID)
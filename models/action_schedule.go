@@ -0,0 +1,135 @@
+package models
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// What a scheduled action does when it comes due while an earlier run of
+// it hasn't finished
+const (
+	ActionOverlapSkip   = "skip"            // Don't run this time
+	ActionOverlapQueue  = "queue"           // Run once the earlier runs finish
+	ActionOverlapCancel = "cancel-previous" // Cancel the earlier runs and run now
+)
+
+// ActionOverlapPolicies lists the overlap policies, the default first
+var ActionOverlapPolicies = []string{ActionOverlapSkip, ActionOverlapQueue, ActionOverlapCancel}
+
+// Statuses of action runs that never ran to completion
+const (
+	ActionRunSkipped   = "skipped"
+	ActionRunCancelled = "cancelled"
+)
+
+// ScheduledRun is a time a scheduled action will run
+type ScheduledRun struct {
+	Action *Action
+	At     time.Time
+}
+
+// SetSchedule makes the action run on a cron schedule, as in "0 3 * * *"
+// or @daily, with what to do when a run overlaps an earlier one
+func (a *Action) SetSchedule(expr, overlap string) error {
+	schedule, err := ParseCron(expr)
+	if err != nil {
+		return err
+	}
+	overlap = strings.TrimSpace(overlap)
+	if overlap == "" {
+		overlap = ActionOverlapSkip
+	}
+	if overlap != ActionOverlapSkip && overlap != ActionOverlapQueue && overlap != ActionOverlapCancel {
+		return errors.Errorf("overlap policy must be one of %s", strings.Join(ActionOverlapPolicies, ", "))
+	}
+	next := schedule.Next(time.Now())
+	if next.IsZero() {
+		return errors.Errorf("schedule %q never runs", schedule.Expr)
+	}
+
+	a.Type = "scheduled"
+	a.Schedule = schedule.Expr
+	a.OverlapPolicy = overlap
+	a.NextRun = &next
+	return nil
+}
+
+// Cron parses the action's schedule
+func (a *Action) Cron() (*CronSchedule, error) {
+	if a.Type != "scheduled" {
+		return nil, errors.New("the action isn't scheduled")
+	}
+	return ParseCron(a.Schedule)
+}
+
+// Overlap returns what the action does when a run overlaps an earlier one
+func (a *Action) Overlap() string {
+	if a.OverlapPolicy == "" {
+		return ActionOverlapSkip
+	}
+	return a.OverlapPolicy
+}
+
+// Due reports whether a scheduled action should run at now
+func (a *Action) Due(now time.Time) bool {
+	return a.Type == "scheduled" && a.Status != "disabled" && a.NextRun != nil && !a.NextRun.After(now)
+}
+
+// Advance moves the action's next run to its first scheduled time after
+// now. Runs missed while the workspace was down are not made up.
+func (a *Action) Advance(now time.Time) error {
+	schedule, err := a.Cron()
+	if err != nil {
+		return err
+	}
+	next := schedule.Next(now)
+	a.NextRun = &next
+	if next.IsZero() {
+		a.NextRun = nil
+	}
+	return errors.Wrap(Actions.Update(a), "failed to update action")
+}
+
+// UpcomingRuns returns the next n times the action runs after from
+func (a *Action) UpcomingRuns(from time.Time, n int) []time.Time {
+	schedule, err := a.Cron()
+	if err != nil {
+		return nil
+	}
+	var times []time.Time
+	for t := from; len(times) < n; {
+		if t = schedule.Next(t); t.IsZero() {
+			break
+		}
+		times = append(times, t)
+	}
+	return times
+}
+
+// ScheduledActions returns the enabled scheduled actions of every repository
+func ScheduledActions() ([]*Action, error) {
+	return Actions.Search("WHERE Type = 'scheduled' AND Status != 'disabled'")
+}
+
+// UpcomingScheduledRuns returns the next runs of a repository's scheduled
+// actions after from, soonest first, up to limit
+func UpcomingScheduledRuns(repoID string, from time.Time, limit int) ([]*ScheduledRun, error) {
+	actions, err := Actions.Search("WHERE RepoID = ? AND Type = 'scheduled' AND Status != 'disabled'", repoID)
+	if err != nil {
+		return nil, err
+	}
+	var runs []*ScheduledRun
+	for _, action := range actions {
+		for _, at := range action.UpcomingRuns(from, limit) {
+			runs = append(runs, &ScheduledRun{Action: action, At: at})
+		}
+	}
+	sort.SliceStable(runs, func(i, j int) bool { return runs[i].At.Before(runs[j].At) })
+	if len(runs) > limit {
+		runs = runs[:limit]
+	}
+	return runs, nil
+}
@@ -0,0 +1,92 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/The-Skyscape/devtools/pkg/testutils"
+)
+
+func TestActionSchedule(t *testing.T) {
+	t.Run("Invalid", func(t *testing.T) {
+		testutils.AssertError(t, (&Action{}).SetSchedule("every night", ""))
+		testutils.AssertError(t, (&Action{}).SetSchedule("0 3 * * *", "wait"))
+		testutils.AssertError(t, (&Action{}).SetSchedule("0 0 31 2 *", ""))
+	})
+
+	t.Run("Valid", func(t *testing.T) {
+		action := &Action{Type: "manual"}
+		testutils.AssertNoError(t, action.SetSchedule("0 3 * * *", ""))
+		testutils.AssertEqual(t, "scheduled", action.Type)
+		testutils.AssertEqual(t, ActionOverlapSkip, action.Overlap())
+		testutils.AssertTrue(t, action.NextRun != nil && action.NextRun.After(time.Now()))
+		testutils.AssertNoError(t, action.SetSchedule("@hourly", ActionOverlapCancel))
+		testutils.AssertEqual(t, ActionOverlapCancel, action.Overlap())
+	})
+
+	t.Run("UpcomingRuns", func(t *testing.T) {
+		action := &Action{}
+		testutils.AssertNoError(t, action.SetSchedule("30 * * * *", ""))
+		from := time.Date(2026, 3, 1, 10, 0, 0, 0, time.Local)
+		runs := action.UpcomingRuns(from, 3)
+		testutils.AssertEqual(t, 3, len(runs))
+		testutils.AssertEqual(t, time.Date(2026, 3, 1, 10, 30, 0, 0, time.Local), runs[0])
+		testutils.AssertEqual(t, time.Date(2026, 3, 1, 12, 30, 0, 0, time.Local), runs[2])
+		testutils.AssertEqual(t, 0, len((&Action{Type: "manual"}).UpcomingRuns(from, 3)))
+	})
+
+	t.Run("Due", func(t *testing.T) {
+		at := time.Date(2026, 3, 1, 3, 0, 0, 0, time.Local)
+		action := &Action{Type: "scheduled", Status: "active", NextRun: &at}
+		testutils.AssertTrue(t, action.Due(at))
+		testutils.AssertFalse(t, action.Due(at.Add(-time.Minute)))
+		action.Status = "disabled"
+		testutils.AssertFalse(t, action.Due(at))
+		testutils.AssertFalse(t, (&Action{Type: "manual", NextRun: &at}).Due(at))
+	})
+}
+
+func TestScheduledActions(t *testing.T) {
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+
+	hourly := &Action{Title: "Hourly", RepoID: "shop", Status: "active"}
+	testutils.AssertNoError(t, hourly.SetSchedule("0 * * * *", ""))
+	hourly, err := Actions.Insert(hourly)
+	testutils.AssertNoError(t, err)
+
+	daily := &Action{Title: "Daily", RepoID: "shop", Status: "active"}
+	testutils.AssertNoError(t, daily.SetSchedule("30 3 * * *", ActionOverlapQueue))
+	_, err = Actions.Insert(daily)
+	testutils.AssertNoError(t, err)
+
+	disabled := &Action{Title: "Disabled", RepoID: "shop", Status: "disabled"}
+	testutils.AssertNoError(t, disabled.SetSchedule("* * * * *", ""))
+	_, err = Actions.Insert(disabled)
+	testutils.AssertNoError(t, err)
+
+	_, err = Actions.Insert(&Action{Title: "Manual", RepoID: "shop", Type: "manual", Status: "active"})
+	testutils.AssertNoError(t, err)
+
+	t.Run("Upcoming", func(t *testing.T) {
+		from := time.Date(2026, 3, 1, 1, 15, 0, 0, time.Local)
+		runs, err := UpcomingScheduledRuns("shop", from, 4)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 4, len(runs))
+		testutils.AssertEqual(t, "Hourly", runs[0].Action.Title)
+		testutils.AssertEqual(t, "Hourly", runs[1].Action.Title)
+		testutils.AssertEqual(t, "Daily", runs[2].Action.Title)
+		testutils.AssertEqual(t, time.Date(2026, 3, 1, 3, 30, 0, 0, time.Local), runs[2].At)
+	})
+
+	t.Run("Advance", func(t *testing.T) {
+		actions, err := ScheduledActions()
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 2, len(actions))
+
+		now := time.Date(2026, 3, 1, 2, 0, 0, 0, time.Local)
+		testutils.AssertNoError(t, hourly.Advance(now))
+		testutils.AssertEqual(t, now.Add(time.Hour), *hourly.NextRun)
+		testutils.AssertFalse(t, hourly.Due(now))
+	})
+}
@@ -0,0 +1,189 @@
+package models
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/The-Skyscape/devtools/pkg/application"
+	"github.com/pkg/errors"
+)
+
+const (
+	// ActionSecretPrefix is the vault key prefix for secrets given to
+	// actions and workflows
+	ActionSecretPrefix = "actions/secrets/"
+
+	// maxActionSecretSize is the largest value a secret may hold
+	maxActionSecretSize = 48 << 10
+
+	// SecretMask replaces secret values in logs
+	SecretMask = "***"
+)
+
+// actionSecretName is a variable name a shell accepts
+var actionSecretName = regexp.MustCompile(`^[A-Z_][A-Z0-9_]*$`)
+
+// ActionSecret records a secret that actions and workflows get as an
+// environment variable. The value lives in Vault; only its name and history
+// are kept here. Workspace secrets have no RepoID and go to every
+// repository, whose own secrets win over them.
+type ActionSecret struct {
+	application.Model
+	RepoID    string // Empty for workspace secrets
+	Name      string
+	CreatedBy string
+	RotatedBy string
+	RotatedAt time.Time // Zero until the value is first changed
+}
+
+// Table returns the database table name
+func (*ActionSecret) Table() string { return "action_secrets" }
+
+// VaultKey returns where the secret's value is kept
+func (s *ActionSecret) VaultKey() string {
+	scope := s.RepoID
+	if scope == "" {
+		scope = "workspace"
+	}
+	return ActionSecretPrefix + scope + "/" + s.Name
+}
+
+// Value reads the secret's value from Vault
+func (s *ActionSecret) Value() (string, error) {
+	secret, err := Secrets.GetSecret(s.VaultKey())
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to read secret %s", s.Name)
+	}
+	value, ok := secret["value"].(string)
+	if !ok {
+		return "", errors.Errorf("secret %s has no value", s.Name)
+	}
+	return value, nil
+}
+
+// SetActionSecret creates a secret for a repository, or for the workspace
+// when repoID is empty, or rotates it to a new value when it exists
+func SetActionSecret(repoID, name, value, userID string) (*ActionSecret, error) {
+	name = strings.ToUpper(strings.TrimSpace(name))
+	switch {
+	case !actionSecretName.MatchString(name):
+		return nil, errors.New("secret names may only have letters, digits and _, and can't start with a digit")
+	case name == "CI" || strings.HasPrefix(name, "SKYSCAPE_"):
+		return nil, errors.Errorf("%s is set for every run and can't be a secret", name)
+	case value == "":
+		return nil, errors.New("secret value is required")
+	case len(value) > maxActionSecretSize:
+		return nil, errors.Errorf("secret values can't be larger than %dKB", maxActionSecretSize>>10)
+	}
+
+	existing, err := ActionSecrets.Search("WHERE RepoID = ? AND Name = ?", repoID, name)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to look up secret")
+	}
+	secret := &ActionSecret{RepoID: repoID, Name: name, CreatedBy: userID}
+	if len(existing) > 0 {
+		secret = existing[0]
+	}
+
+	if err := StoreSecret(secret.VaultKey(), map[string]any{"value": value}); err != nil {
+		return nil, errors.Wrap(err, "failed to store secret")
+	}
+	if secret.ID != "" {
+		secret.RotatedBy, secret.RotatedAt = userID, time.Now()
+		return secret, errors.Wrap(ActionSecrets.Update(secret), "failed to update secret")
+	}
+	secret, err = ActionSecrets.Insert(secret)
+	return secret, errors.Wrap(err, "failed to record secret")
+}
+
+// DeleteActionSecret removes a secret's value and record
+func DeleteActionSecret(secret *ActionSecret) error {
+	if err := DeleteSecret(secret.VaultKey()); err != nil {
+		return errors.Wrap(err, "failed to delete secret")
+	}
+	return ActionSecrets.Delete(secret)
+}
+
+// GetActionSecrets returns a repository's secrets, or the workspace's when
+// repoID is empty, by name
+func GetActionSecrets(repoID string) ([]*ActionSecret, error) {
+	return ActionSecrets.Search("WHERE RepoID = ? ORDER BY Name ASC", repoID)
+}
+
+// ActionSecretEnv returns the secrets a repository's runs get, workspace
+// secrets first and the repository's own replacing any of the same name
+func ActionSecretEnv(repoID string) (map[string]string, error) {
+	env := map[string]string{}
+	for _, scope := range []string{"", repoID} {
+		secrets, err := GetActionSecrets(scope)
+		if err != nil {
+			return nil, err
+		}
+		for _, secret := range secrets {
+			value, err := secret.Value()
+			if err != nil {
+				return nil, err
+			}
+			env[secret.Name] = value
+		}
+	}
+	return env, nil
+}
+
+// DeleteRepoActionSecrets removes every secret of a deleted repository
+func DeleteRepoActionSecrets(repoID string) {
+	if repoID == "" {
+		return
+	}
+	secrets, _ := GetActionSecrets(repoID)
+	for _, secret := range secrets {
+		DeleteActionSecret(secret)
+	}
+}
+
+// SecretMasker hides secret values in output
+type SecretMasker struct {
+	values []string
+}
+
+// NewSecretMasker masks the values of the secrets. Values of multiple lines
+// are masked line by line. Very short values aren't masked, as they would
+// hide too much else.
+func NewSecretMasker(secrets map[string]string) *SecretMasker {
+	seen := map[string]bool{}
+	m := &SecretMasker{}
+	add := func(value string) {
+		value = strings.TrimSpace(value)
+		if len(value) >= 4 && !seen[value] {
+			seen[value] = true
+			m.values = append(m.values, value)
+		}
+	}
+	for _, value := range secrets {
+		for _, line := range strings.Split(value, "\n") {
+			add(line)
+		}
+	}
+	// Longer values go first, so one containing another is masked whole
+	sort.Slice(m.values, func(i, j int) bool { return len(m.values[i]) > len(m.values[j]) })
+	return m
+}
+
+// Mask replaces every secret value in the text with SecretMask. Lines are
+// masked one at a time, so masking more of a growing log never changes
+// the lines already masked.
+func (m *SecretMasker) Mask(text string) string {
+	if m == nil || len(m.values) == 0 {
+		return text
+	}
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		for _, value := range m.values {
+			line = strings.ReplaceAll(line, value, SecretMask)
+		}
+		lines[i] = line
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/The-Skyscape/devtools/pkg/testutils"
+)
+
+func TestSetActionSecretValidation(t *testing.T) {
+	for _, test := range []struct{ name, value string }{
+		{"", "value"},
+		{"1PASSWORD", "value"},
+		{"API-KEY", "value"},
+		{"API KEY", "value"},
+		{"CI", "value"},
+		{"skyscape_token", "value"},
+		{"API_KEY", ""},
+		{"API_KEY", strings.Repeat("x", maxActionSecretSize+1)},
+	} {
+		_, err := SetActionSecret("repo", test.name, test.value, "user")
+		testutils.AssertError(t, err)
+	}
+}
+
+func TestSecretMasker(t *testing.T) {
+	masker := NewSecretMasker(map[string]string{
+		"TOKEN":    "s3cr3t-token",
+		"PREFIX":   "s3cr3t",
+		"SHORT":    "abc",
+		"CERT":     "-----BEGIN KEY-----\nMIIBOgIBAAJBAK\n-----END KEY-----\n",
+		"PASSWORD": "  hunter22  ",
+	})
+
+	t.Run("Values", func(t *testing.T) {
+		testutils.AssertEqual(t, "token=*** prefix=***", masker.Mask("token=s3cr3t-token prefix=s3cr3t"))
+		testutils.AssertEqual(t, "login *** ok", masker.Mask("login hunter22 ok"))
+		testutils.AssertEqual(t, "abc is too short to mask", masker.Mask("abc is too short to mask"))
+	})
+
+	t.Run("MultilineValues", func(t *testing.T) {
+		testutils.AssertEqual(t, "***\n***\n***\n", masker.Mask("-----BEGIN KEY-----\nMIIBOgIBAAJBAK\n-----END KEY-----\n"))
+	})
+
+	t.Run("Nil", func(t *testing.T) {
+		var none *SecretMasker
+		testutils.AssertEqual(t, "s3cr3t", none.Mask("s3cr3t"))
+		testutils.AssertEqual(t, "s3cr3t", NewSecretMasker(nil).Mask("s3cr3t"))
+	})
+}
+
+func TestActionSecretVaultKey(t *testing.T) {
+	testutils.AssertEqual(t, ActionSecretPrefix+"repo/API_KEY", (&ActionSecret{RepoID: "repo", Name: "API_KEY"}).VaultKey())
+	testutils.AssertEqual(t, ActionSecretPrefix+"workspace/API_KEY", (&ActionSecret{Name: "API_KEY"}).VaultKey())
+}
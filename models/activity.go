@@ -1,6 +1,9 @@
 package models
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/The-Skyscape/devtools/pkg/application"
 )
 
@@ -49,4 +52,57 @@ func LogActivity(activityType, title, description, userID, repoID, entityType, e
 func GetUserActivitiesPaginated(userID string, limit, offset int) ([]*Activity, int, error) {
 	condition := "WHERE UserID = ? ORDER BY CreatedAt DESC"
 	return Activities.SearchPaginated(condition, limit, offset, userID)
-}
\ No newline at end of file
+}
+
+// ContributionDay is one cell of the contribution heatmap
+type ContributionDay struct {
+	Date  time.Time
+	Count int
+	Level int // 0-4 intensity relative to the busiest day
+}
+
+// ContributionCalendar is a user's daily activity laid out in weeks, Sunday first
+type ContributionCalendar struct {
+	Weeks [][]ContributionDay
+	Total int
+}
+
+// GetContributionCalendar counts a user's activities per day over the last number of weeks
+func GetContributionCalendar(userID string, weeks int) (*ContributionCalendar, error) {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	start := today.AddDate(0, 0, -int(today.Weekday())-7*(weeks-1))
+
+	condition := fmt.Sprintf("WHERE UserID = ? AND CreatedAt >= datetime('now', '-%d days')", weeks*7+1)
+	activities, err := Activities.Search(condition, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := map[string]int{}
+	busiest := 0
+	for _, activity := range activities {
+		key := activity.CreatedAt.In(now.Location()).Format("2006-01-02")
+		counts[key]++
+		busiest = max(busiest, counts[key])
+	}
+
+	calendar := &ContributionCalendar{}
+	for day := start; !day.After(today); day = day.AddDate(0, 0, 1) {
+		if day.Weekday() == time.Sunday {
+			calendar.Weeks = append(calendar.Weeks, nil)
+		}
+
+		count := counts[day.Format("2006-01-02")]
+		level := 0
+		if count > 0 {
+			level = 1 + (count*4-1)/busiest
+		}
+
+		week := len(calendar.Weeks) - 1
+		calendar.Weeks[week] = append(calendar.Weeks[week], ContributionDay{Date: day, Count: count, Level: level})
+		calendar.Total += count
+	}
+
+	return calendar, nil
+}
@@ -0,0 +1,142 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/The-Skyscape/devtools/pkg/application"
+	"github.com/pkg/errors"
+)
+
+// AI task statuses, matching the queue's
+const (
+	AITaskQueued     = "queued"
+	AITaskProcessing = "processing"
+	AITaskRetrying   = "retrying"
+	AITaskCompleted  = "completed"
+	AITaskFailed     = "failed"
+	AITaskCancelled  = "cancelled"
+)
+
+// AITask is a task on the AI queue, stored so queued work survives a
+// restart. Its ID is the queue's task ID.
+type AITask struct {
+	application.Model
+	Type           string
+	Priority       int
+	RepoID         string
+	UserID         string
+	EntityType     string
+	EntityID       string
+	Data           string // JSON encoded task data
+	IdempotencyKey string // Tasks sharing a key do the same work
+	Status         string
+	Retries        int
+	MaxRetries     int
+	Error          string
+	CommentID      string // Comment the task posted, so retries don't post it again
+	StartedAt      time.Time
+	FinishedAt     time.Time
+}
+
+// Table returns the database table name
+func (*AITask) Table() string { return "ai_tasks" }
+
+// Pending returns whether the task is waiting for or being processed
+func (t *AITask) Pending() bool {
+	switch t.Status {
+	case AITaskQueued, AITaskProcessing, AITaskRetrying:
+		return true
+	}
+	return false
+}
+
+// DecodeData returns the task data
+func (t *AITask) DecodeData() map[string]any {
+	data := map[string]any{}
+	if t.Data != "" {
+		json.Unmarshal([]byte(t.Data), &data)
+	}
+	return data
+}
+
+// SaveAITask inserts or updates the task
+func SaveAITask(task *AITask) error {
+	if _, err := AITasks.Get(task.ID); err != nil {
+		_, err = AITasks.Insert(task)
+		return errors.Wrap(err, "failed to save AI task")
+	}
+	return errors.Wrap(AITasks.Update(task), "failed to save AI task")
+}
+
+// NewAITask returns an unsaved task with the given ID
+func NewAITask(id string) *AITask {
+	return &AITask{Model: DB.NewModel(id)}
+}
+
+// PendingAITasks returns the tasks still to be processed, most urgent first
+func PendingAITasks() ([]*AITask, error) {
+	tasks, err := AITasks.Search("WHERE Status IN (?, ?, ?) ORDER BY Priority, CreatedAt",
+		AITaskQueued, AITaskProcessing, AITaskRetrying)
+	return tasks, errors.Wrap(err, "failed to load pending AI tasks")
+}
+
+// RecoverAITasks marks tasks that were being processed when the server
+// stopped for retry, returning how many there were. The interruption counts
+// as an attempt, so a task that keeps crashing the server eventually fails.
+func RecoverAITasks() (int, error) {
+	tasks, err := AITasks.Search("WHERE Status = ?", AITaskProcessing)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to load interrupted AI tasks")
+	}
+	for _, task := range tasks {
+		task.Retries++
+		task.Status = AITaskRetrying
+		task.Error = "Interrupted by a restart"
+		if task.MaxRetries > 0 && task.Retries >= task.MaxRetries {
+			task.Status = AITaskFailed
+			task.FinishedAt = time.Now()
+		}
+		if err := AITasks.Update(task); err != nil {
+			return 0, errors.Wrap(err, "failed to recover AI task")
+		}
+	}
+	return len(tasks), nil
+}
+
+// FindPendingAITask returns a pending task with the idempotency key other
+// than the given task, or nil
+func FindPendingAITask(key, exceptID string) *AITask {
+	if key == "" {
+		return nil
+	}
+	tasks, err := AITasks.Search("WHERE IdempotencyKey = ? AND ID != ? AND Status IN (?, ?, ?) LIMIT 1",
+		key, exceptID, AITaskQueued, AITaskProcessing, AITaskRetrying)
+	if err != nil || len(tasks) == 0 {
+		return nil
+	}
+	return tasks[0]
+}
+
+// AITaskComment returns the comment a task with the idempotency key has
+// already posted, or "" when none has
+func AITaskComment(key string) string {
+	if key == "" {
+		return ""
+	}
+	tasks, err := AITasks.Search("WHERE IdempotencyKey = ? AND CommentID != '' LIMIT 1", key)
+	if err != nil || len(tasks) == 0 {
+		return ""
+	}
+	return tasks[0].CommentID
+}
+
+// RecordAITaskComment remembers the comment a task posted
+func RecordAITaskComment(taskID, commentID string) error {
+	task, err := AITasks.Get(taskID)
+	if err != nil {
+		return errors.Wrap(err, "failed to find AI task")
+	}
+	task.CommentID = commentID
+	return errors.Wrap(AITasks.Update(task), "failed to record AI task comment")
+}
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/The-Skyscape/devtools/pkg/testutils"
+)
+
+func TestAITask(t *testing.T) {
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+
+	save := func(id, key, status string, priority int) *AITask {
+		task := NewAITask(id)
+		task.Type = "issue_triage"
+		task.IdempotencyKey = key
+		task.Status = status
+		task.Priority = priority
+		task.Data = `{"issue_id":"issue-1"}`
+		testutils.AssertNoError(t, SaveAITask(task))
+		return task
+	}
+
+	t.Run("Save", func(t *testing.T) {
+		task := save("task-1", "issue_triage:issue-1", AITaskQueued, 5)
+		testutils.AssertEqual(t, "issue-1", task.DecodeData()["issue_id"])
+
+		task.Status = AITaskProcessing
+		testutils.AssertNoError(t, SaveAITask(task))
+		testutils.AssertEqual(t, 1, AITasks.Count(""))
+
+		stored, err := AITasks.Get("task-1")
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, AITaskProcessing, stored.Status)
+	})
+
+	t.Run("Recover", func(t *testing.T) {
+		save("task-2", "", AITaskQueued, 2)
+		save("task-3", "", AITaskCompleted, 1)
+		crashing := save("task-4", "", AITaskProcessing, 1)
+		crashing.Retries, crashing.MaxRetries = 2, 3
+		testutils.AssertNoError(t, SaveAITask(crashing))
+
+		recovered, err := RecoverAITasks()
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 2, recovered)
+
+		// The interruption was its last attempt
+		crashing, err = AITasks.Get("task-4")
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, AITaskFailed, crashing.Status)
+
+		pending, err := PendingAITasks()
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 2, len(pending))
+		testutils.AssertEqual(t, "task-2", pending[0].ID)
+		testutils.AssertEqual(t, AITaskRetrying, pending[1].Status)
+		testutils.AssertEqual(t, 1, pending[1].Retries)
+		testutils.AssertTrue(t, pending[1].Pending())
+	})
+
+	t.Run("IdempotencyKey", func(t *testing.T) {
+		// A task doesn't duplicate itself
+		testutils.AssertTrue(t, FindPendingAITask("issue_triage:issue-1", "task-1") == nil)
+		testutils.AssertTrue(t, FindPendingAITask("issue_triage:issue-1", "task-5") != nil)
+		testutils.AssertTrue(t, FindPendingAITask("", "task-5") == nil)
+
+		testutils.AssertEqual(t, "", AITaskComment("issue_triage:issue-1"))
+		testutils.AssertNoError(t, RecordAITaskComment("task-1", "comment-1"))
+		testutils.AssertEqual(t, "comment-1", AITaskComment("issue_triage:issue-1"))
+		testutils.AssertEqual(t, "", AITaskComment(""))
+	})
+}
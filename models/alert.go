@@ -0,0 +1,445 @@
+package models
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/The-Skyscape/devtools/pkg/application"
+	"github.com/pkg/errors"
+)
+
+// Conditions an alert rule can watch
+const (
+	AlertAIBacklog       = "ai_backlog"       // Tasks waiting in the AI queue
+	AlertBackupFailed    = "backup_failed"    // The latest backup failed
+	AlertDiskUsage       = "disk_usage"       // Percent of the host's disk used
+	AlertActionFailures  = "action_failures"  // Percent of action runs failing within the window
+	AlertOllamaUnhealthy = "ollama_unhealthy" // The AI service is enabled but not answering
+)
+
+// Channels alerts are routed to
+const (
+	AlertEmail   = "email"   // Addresses emailed through outgoing mail
+	AlertSlack   = "slack"   // Slack incoming webhook
+	AlertWebhook = "webhook" // JSON posted to a URL, signed when a secret is set
+)
+
+// Statuses of an alert
+const (
+	AlertFiring   = "firing"
+	AlertResolved = "resolved"
+)
+
+// AlertCondition describes a condition for the rule form
+type AlertCondition struct {
+	Kind        string
+	Name        string
+	Description string
+	Threshold   float64 // Default threshold, zero when the condition has none
+	Unit        string  // What the threshold counts
+	Window      int     // Default minutes looked back, zero when the condition has none
+}
+
+// AlertConditions lists the conditions alert rules can watch
+var AlertConditions = []AlertCondition{
+	{Kind: AlertAIBacklog, Name: "AI queue backlog", Description: "More tasks are waiting in the AI queue than the threshold", Threshold: 50, Unit: "tasks"},
+	{Kind: AlertBackupFailed, Name: "Backup failed", Description: "The latest scheduled or manual backup failed"},
+	{Kind: AlertDiskUsage, Name: "Disk usage", Description: "The host's disk is fuller than the threshold", Threshold: 90, Unit: "percent"},
+	{Kind: AlertActionFailures, Name: "Action failure rate", Description: "More action runs failed within the window than the threshold", Threshold: 50, Unit: "percent", Window: 60},
+	{Kind: AlertOllamaUnhealthy, Name: "Ollama unhealthy", Description: "AI is enabled but the Ollama service isn't running or answering"},
+}
+
+// GetAlertCondition returns the description of a condition
+func GetAlertCondition(kind string) (AlertCondition, bool) {
+	i := slices.IndexFunc(AlertConditions, func(c AlertCondition) bool { return c.Kind == kind })
+	if i < 0 {
+		return AlertCondition{}, false
+	}
+	return AlertConditions[i], true
+}
+
+// AlertChannel is somewhere alerts are sent when they fire and resolve
+type AlertChannel struct {
+	application.Model
+	Name       string
+	Kind       string // AlertEmail, AlertSlack or AlertWebhook
+	Target     string // Email addresses, one per line, or the webhook URL
+	Secret     string // Signs webhook payloads with HMAC-SHA256 when set
+	Active     bool
+	CreatedBy  string
+	LastError  string // Why the latest delivery failed, empty when it went out
+	LastSentAt time.Time
+}
+
+// Table returns the database table name
+func (*AlertChannel) Table() string { return "alert_channels" }
+
+// AlertRule fires an alert while its condition holds, and routes it to
+// its channels
+type AlertRule struct {
+	application.Model
+	Name          string
+	Condition     string  // One of AlertConditions
+	Threshold     float64 // Fires above it, unused by conditions without one
+	WindowMinutes int     // How far back rates are measured
+	Channels      string  // Channel IDs, one per line
+	Active        bool
+	CreatedBy     string
+	LastValue     float64 // Measured at the latest evaluation
+	EvaluatedAt   time.Time
+}
+
+// Table returns the database table name
+func (*AlertRule) Table() string { return "alert_rules" }
+
+// Alert is one time a rule's condition held, from when it fired until
+// it resolved. A rule has at most one firing alert, so a condition that
+// keeps holding is only sent once.
+type Alert struct {
+	application.Model
+	RuleID     string
+	RuleName   string
+	Condition  string
+	Status     string // AlertFiring or AlertResolved
+	Summary    string // What was measured, updated while firing
+	Value      float64
+	ResolvedAt time.Time
+	NotifiedTo string // Channels the alert was sent to
+	Error      string // Why sending it failed
+}
+
+// Table returns the database table name
+func (*Alert) Table() string { return "alerts" }
+
+// CreateAlertChannel validates and adds a channel
+func CreateAlertChannel(name, kind, target, secret, userID string) (*AlertChannel, error) {
+	channel := &AlertChannel{
+		Model:     DB.NewModel(""),
+		Kind:      kind,
+		Active:    true,
+		CreatedBy: userID,
+	}
+	if err := channel.configure(name, target, secret); err != nil {
+		return nil, err
+	}
+	channel, err := AlertChannels.Insert(channel)
+	return channel, errors.Wrap(err, "failed to create alert channel")
+}
+
+// Update changes the channel's name and destination. An empty secret
+// keeps the one stored.
+func (c *AlertChannel) Update(name, target, secret string, active bool) error {
+	if strings.TrimSpace(secret) == "" {
+		secret = c.Secret
+	}
+	if err := c.configure(name, target, secret); err != nil {
+		return err
+	}
+	c.Active = active
+	return errors.Wrap(AlertChannels.Update(c), "failed to update alert channel")
+}
+
+// configure validates and sets the channel's name and destination
+func (c *AlertChannel) configure(name, target, secret string) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return errors.New("the channel needs a name")
+	}
+
+	target = strings.TrimSpace(target)
+	switch c.Kind {
+	case AlertEmail:
+		addresses := splitPolicyList(target)
+		if len(addresses) == 0 {
+			return errors.New("add at least one email address")
+		}
+		for _, address := range addresses {
+			if _, err := mail.ParseAddress(address); err != nil {
+				return errors.Errorf("%q is not an email address", address)
+			}
+		}
+		target = strings.Join(addresses, "\n")
+	case AlertSlack:
+		if u, err := url.Parse(target); err != nil || u.Scheme != "https" || u.Host == "" {
+			return errors.New("the Slack webhook must be an https URL")
+		}
+	case AlertWebhook:
+		if u, err := url.Parse(target); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("the webhook must be an http or https URL")
+		}
+	default:
+		return errors.New("choose email, Slack or a webhook")
+	}
+
+	c.Name = name
+	c.Target = target
+	c.Secret = ""
+	if c.Kind == AlertWebhook {
+		c.Secret = strings.TrimSpace(secret)
+	}
+	return nil
+}
+
+// Addresses returns the email channel's recipients
+func (c *AlertChannel) Addresses() []string {
+	return splitPolicyList(c.Target)
+}
+
+// RecordDelivery notes the outcome of sending an alert with the channel
+func (c *AlertChannel) RecordDelivery(err error) error {
+	c.LastSentAt = time.Now()
+	c.LastError = ""
+	if err != nil {
+		c.LastError = err.Error()
+	}
+	return errors.Wrap(AlertChannels.Update(c), "failed to update alert channel")
+}
+
+// GetAlertChannels returns every channel, oldest first
+func GetAlertChannels() ([]*AlertChannel, error) {
+	return AlertChannels.Search("ORDER BY CreatedAt ASC")
+}
+
+// DeleteAlertChannel removes a channel and stops rules routing to it
+func DeleteAlertChannel(channel *AlertChannel) error {
+	rules, err := GetAlertRules()
+	if err != nil {
+		return err
+	}
+	for _, rule := range rules {
+		ids := rule.ChannelIDs()
+		if i := slices.Index(ids, channel.ID); i >= 0 {
+			rule.Channels = strings.Join(slices.Delete(ids, i, i+1), "\n")
+			if err := AlertRules.Update(rule); err != nil {
+				return errors.Wrap(err, "failed to update alert rule")
+			}
+		}
+	}
+	return errors.Wrap(AlertChannels.Delete(channel), "failed to delete alert channel")
+}
+
+// CreateAlertRule validates and adds a rule. Zero thresholds and windows
+// use the condition's defaults.
+func CreateAlertRule(name, condition string, threshold float64, window int, channelIDs []string, userID string) (*AlertRule, error) {
+	rule := &AlertRule{
+		Model:     DB.NewModel(""),
+		Condition: condition,
+		Active:    true,
+		CreatedBy: userID,
+	}
+	if err := rule.configure(name, threshold, window, channelIDs); err != nil {
+		return nil, err
+	}
+	rule, err := AlertRules.Insert(rule)
+	return rule, errors.Wrap(err, "failed to create alert rule")
+}
+
+// Update changes the rule's name, threshold, window and channels. A
+// rule turned off resolves its firing alert.
+func (r *AlertRule) Update(name string, threshold float64, window int, channelIDs []string, active bool) error {
+	if err := r.configure(name, threshold, window, channelIDs); err != nil {
+		return err
+	}
+	r.Active = active
+	if err := AlertRules.Update(r); err != nil {
+		return errors.Wrap(err, "failed to update alert rule")
+	}
+	if !active {
+		_, err := ResolveAlert(r, "The rule was turned off")
+		return err
+	}
+	return nil
+}
+
+// configure validates and sets the rule's name, threshold, window and
+// channels
+func (r *AlertRule) configure(name string, threshold float64, window int, channelIDs []string) error {
+	condition, ok := GetAlertCondition(r.Condition)
+	if !ok {
+		return errors.Errorf("unknown alert condition %q", r.Condition)
+	}
+	if name = strings.TrimSpace(name); name == "" {
+		name = condition.Name
+	}
+	if threshold < 0 || window < 0 {
+		return errors.New("thresholds and windows can't be negative")
+	}
+	if threshold == 0 {
+		threshold = condition.Threshold
+	}
+	if window == 0 {
+		window = condition.Window
+	}
+	if condition.Unit == "percent" && threshold > 100 {
+		return errors.New("the threshold is a percentage, at most 100")
+	}
+
+	for _, id := range channelIDs {
+		if _, err := AlertChannels.Get(id); err != nil {
+			return errors.New("alert channel not found")
+		}
+	}
+
+	r.Name = name
+	r.Threshold = threshold
+	r.WindowMinutes = window
+	r.Channels = strings.Join(channelIDs, "\n")
+	return nil
+}
+
+// ConditionName returns the name of the rule's condition
+func (r *AlertRule) ConditionName() string {
+	if condition, ok := GetAlertCondition(r.Condition); ok {
+		return condition.Name
+	}
+	return r.Condition
+}
+
+// Describe says when the rule fires
+func (r *AlertRule) Describe() string {
+	condition, _ := GetAlertCondition(r.Condition)
+	switch {
+	case condition.Window > 0:
+		return fmt.Sprintf("Over %g %s within %d minutes", r.Threshold, condition.Unit, r.WindowMinutes)
+	case condition.Threshold > 0:
+		return fmt.Sprintf("Over %g %s", r.Threshold, condition.Unit)
+	default:
+		return condition.Description
+	}
+}
+
+// ChannelIDs returns the IDs of the channels the rule routes to
+func (r *AlertRule) ChannelIDs() []string {
+	return splitPolicyList(r.Channels)
+}
+
+// RoutesTo reports whether the rule routes to a channel
+func (r *AlertRule) RoutesTo(channelID string) bool {
+	return slices.Contains(r.ChannelIDs(), channelID)
+}
+
+// RoutedChannels returns the active channels the rule routes to
+func (r *AlertRule) RoutedChannels() []*AlertChannel {
+	var channels []*AlertChannel
+	for _, id := range r.ChannelIDs() {
+		if channel, err := AlertChannels.Get(id); err == nil && channel.Active {
+			channels = append(channels, channel)
+		}
+	}
+	return channels
+}
+
+// RecordEvaluation notes the value measured for the rule
+func (r *AlertRule) RecordEvaluation(value float64) error {
+	r.LastValue = value
+	r.EvaluatedAt = time.Now()
+	return errors.Wrap(AlertRules.Update(r), "failed to update alert rule")
+}
+
+// GetAlertRules returns every rule, oldest first
+func GetAlertRules() ([]*AlertRule, error) {
+	return AlertRules.Search("ORDER BY CreatedAt ASC")
+}
+
+// DeleteAlertRule removes a rule, resolving its firing alert. Its past
+// alerts are kept.
+func DeleteAlertRule(rule *AlertRule) error {
+	if _, err := ResolveAlert(rule, "The rule was deleted"); err != nil {
+		return err
+	}
+	return errors.Wrap(AlertRules.Delete(rule), "failed to delete alert rule")
+}
+
+// FiringAlert returns the rule's firing alert, nil when it isn't firing
+func FiringAlert(ruleID string) (*Alert, error) {
+	alerts, err := Alerts.Search("WHERE RuleID = ? AND Status = ? LIMIT 1", ruleID, AlertFiring)
+	if err != nil || len(alerts) == 0 {
+		return nil, err
+	}
+	return alerts[0], nil
+}
+
+// FireAlert records that the rule's condition holds. It returns the
+// rule's firing alert and whether it just fired; an alert that was
+// already firing only has its summary and value updated.
+func FireAlert(rule *AlertRule, summary string, value float64) (*Alert, bool, error) {
+	alert, err := FiringAlert(rule.ID)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "failed to find firing alert")
+	}
+	if alert != nil {
+		alert.Summary, alert.Value = summary, value
+		return alert, false, errors.Wrap(Alerts.Update(alert), "failed to update alert")
+	}
+
+	alert, err = Alerts.Insert(&Alert{
+		Model:     DB.NewModel(""),
+		RuleID:    rule.ID,
+		RuleName:  rule.Name,
+		Condition: rule.Condition,
+		Status:    AlertFiring,
+		Summary:   summary,
+		Value:     value,
+	})
+	return alert, err == nil, errors.Wrap(err, "failed to save alert")
+}
+
+// ResolveAlert resolves the rule's firing alert, returning nil when it
+// wasn't firing
+func ResolveAlert(rule *AlertRule, summary string) (*Alert, error) {
+	alert, err := FiringAlert(rule.ID)
+	if err != nil || alert == nil {
+		return nil, err
+	}
+	alert.Status = AlertResolved
+	alert.Summary = summary
+	alert.ResolvedAt = time.Now()
+	return alert, errors.Wrap(Alerts.Update(alert), "failed to resolve alert")
+}
+
+// RecordNotification notes where the alert was sent and why sending it
+// failed
+func (a *Alert) RecordNotification(sentTo []string, err error) error {
+	a.NotifiedTo = strings.Join(sentTo, ", ")
+	a.Error = ""
+	if err != nil {
+		a.Error = err.Error()
+	}
+	return errors.Wrap(Alerts.Update(a), "failed to update alert")
+}
+
+// Duration returns how long the alert fired, so far when still firing
+func (a *Alert) Duration() time.Duration {
+	end := a.ResolvedAt
+	if a.Status == AlertFiring {
+		end = time.Now()
+	}
+	return end.Sub(a.CreatedAt).Round(time.Second)
+}
+
+// FiringAlerts returns the alerts firing, newest first
+func FiringAlerts() ([]*Alert, error) {
+	return Alerts.Search("WHERE Status = ? ORDER BY CreatedAt DESC", AlertFiring)
+}
+
+// ResolvedAlerts returns the most recently resolved alerts
+func ResolvedAlerts(limit int) ([]*Alert, error) {
+	return Alerts.Search("WHERE Status = ? ORDER BY ResolvedAt DESC LIMIT ?", AlertResolved, limit)
+}
+
+// PruneResolvedAlerts deletes alerts resolved before a time
+func PruneResolvedAlerts(before time.Time) error {
+	return DB.Query("DELETE FROM alerts WHERE Status = ? AND ResolvedAt < ?", AlertResolved, before).Exec()
+}
+
+// ActionFailureRate counts the action runs that finished since a time
+// and how many of them failed
+func ActionFailureRate(since time.Time) (failed, finished int) {
+	failed = ActionRuns.Count("WHERE Status = ? AND CreatedAt >= ?", "failed", since)
+	finished = ActionRuns.Count("WHERE Status IN ('completed', 'failed') AND CreatedAt >= ?", since)
+	return failed, finished
+}
@@ -0,0 +1,111 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/The-Skyscape/devtools/pkg/testutils"
+)
+
+func TestAlerts(t *testing.T) {
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+
+	admin := CreateTestUser(t, db, "admin@example.com")
+
+	var email, slack *AlertChannel
+	t.Run("Channels", func(t *testing.T) {
+		var err error
+		email, err = CreateAlertChannel("On call", AlertEmail, "ops@example.com, oncall@example.com", "", admin.ID)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 2, len(email.Addresses()))
+
+		slack, err = CreateAlertChannel("Ops", AlertSlack, "https://hooks.slack.com/services/T/B/X", "ignored", admin.ID)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, "", slack.Secret)
+
+		hook, err := CreateAlertChannel("Pager", AlertWebhook, "https://pager.example.com/hook", "s3cret", admin.ID)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, "s3cret", hook.Secret)
+
+		// An empty secret keeps the stored one
+		testutils.AssertNoError(t, hook.Update("Pager", "https://pager.example.com/v2", "", true))
+		testutils.AssertEqual(t, "s3cret", hook.Secret)
+		testutils.AssertNoError(t, AlertChannels.Delete(hook))
+
+		_, err = CreateAlertChannel("Bad", AlertEmail, "not an address", "", admin.ID)
+		testutils.AssertError(t, err)
+		_, err = CreateAlertChannel("Bad", AlertSlack, "http://hooks.slack.com/services/T/B/X", "", admin.ID)
+		testutils.AssertError(t, err)
+		_, err = CreateAlertChannel("Bad", "pager", "https://example.com", "", admin.ID)
+		testutils.AssertError(t, err)
+		_, err = CreateAlertChannel("", AlertSlack, "https://hooks.slack.com/services/T/B/X", "", admin.ID)
+		testutils.AssertError(t, err)
+	})
+
+	var rule *AlertRule
+	t.Run("Rules", func(t *testing.T) {
+		var err error
+		rule, err = CreateAlertRule("", AlertActionFailures, 0, 0, []string{email.ID, slack.ID}, admin.ID)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, "Action failure rate", rule.Name)
+		testutils.AssertEqual(t, 50.0, rule.Threshold)
+		testutils.AssertEqual(t, 60, rule.WindowMinutes)
+		testutils.AssertEqual(t, "Over 50 percent within 60 minutes", rule.Describe())
+		testutils.AssertEqual(t, 2, len(rule.RoutedChannels()))
+
+		_, err = CreateAlertRule("Disk", AlertDiskUsage, 120, 0, nil, admin.ID)
+		testutils.AssertError(t, err)
+		_, err = CreateAlertRule("Unknown", "cpu", 10, 0, nil, admin.ID)
+		testutils.AssertError(t, err)
+		_, err = CreateAlertRule("Missing", AlertBackupFailed, 0, 0, []string{"nope"}, admin.ID)
+		testutils.AssertError(t, err)
+	})
+
+	t.Run("FireAndResolve", func(t *testing.T) {
+		alert, fired, err := FireAlert(rule, "6 of 10 runs failed", 60)
+		testutils.AssertNoError(t, err)
+		testutils.AssertTrue(t, fired)
+		testutils.AssertEqual(t, AlertFiring, alert.Status)
+
+		// While the condition holds the same alert is updated
+		again, fired, err := FireAlert(rule, "7 of 10 runs failed", 70)
+		testutils.AssertNoError(t, err)
+		testutils.AssertFalse(t, fired)
+		testutils.AssertEqual(t, alert.ID, again.ID)
+		testutils.AssertEqual(t, 70.0, again.Value)
+
+		firing, err := FiringAlerts()
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 1, len(firing))
+
+		resolved, err := ResolveAlert(rule, "1 of 10 runs failed")
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, AlertResolved, resolved.Status)
+		testutils.AssertFalse(t, resolved.ResolvedAt.IsZero())
+
+		// Nothing is firing to resolve
+		resolved, err = ResolveAlert(rule, "0 of 10 runs failed")
+		testutils.AssertNoError(t, err)
+		testutils.AssertTrue(t, resolved == nil)
+
+		_, fired, err = FireAlert(rule, "8 of 10 runs failed", 80)
+		testutils.AssertNoError(t, err)
+		testutils.AssertTrue(t, fired)
+	})
+
+	t.Run("TurnOff", func(t *testing.T) {
+		testutils.AssertNoError(t, rule.Update(rule.Name, rule.Threshold, rule.WindowMinutes, rule.ChannelIDs(), false))
+		firing, err := FiringAlert(rule.ID)
+		testutils.AssertNoError(t, err)
+		testutils.AssertTrue(t, firing == nil)
+	})
+
+	t.Run("DeleteChannel", func(t *testing.T) {
+		testutils.AssertNoError(t, DeleteAlertChannel(slack))
+		updated, err := AlertRules.Get(rule.ID)
+		testutils.AssertNoError(t, err)
+		ids := updated.ChannelIDs()
+		testutils.AssertEqual(t, 1, len(ids))
+		testutils.AssertEqual(t, email.ID, ids[0])
+	})
+}
@@ -0,0 +1,263 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"workspace/internal/storage"
+
+	"github.com/The-Skyscape/devtools/pkg/application"
+	"github.com/The-Skyscape/devtools/pkg/database"
+	"github.com/pkg/errors"
+)
+
+const (
+	// MaxAttachmentSize is the largest file that can be attached
+	MaxAttachmentSize = 10 << 20
+
+	// MaxAttachmentsPerUpload limits how many files one request may attach
+	MaxAttachmentsPerUpload = 5
+
+	// attachmentPreviewSize is how much of a text file is shown inline
+	attachmentPreviewSize = 4 << 10
+)
+
+// AttachmentScanner inspects an uploaded file before it is kept and
+// returns an error to reject it
+type AttachmentScanner func(path string) error
+
+// ScanAttachment is called on every upload when set. main installs a
+// CommandScanner when ATTACHMENT_SCAN_COMMAND is configured.
+var ScanAttachment AttachmentScanner
+
+// Attachment is a file uploaded to an issue, pull request or comment. The
+// content is kept in file storage, not in the database.
+type Attachment struct {
+	application.Model
+	RepoID      string
+	EntityType  string // issue, pr, comment
+	EntityID    string
+	Filename    string // Original name, for display and downloads
+	ContentType string // Sniffed from the content, not taken from the client
+	Size        int64
+	Checksum    string // SHA-256 of the content
+	UploadedBy  string
+}
+
+// Table returns the database table name
+func (*Attachment) Table() string { return "attachments" }
+
+// CommandScanner returns a scanner that runs a virus scanner such as
+// "clamscan --no-summary" on each file, rejecting it on a non-zero exit
+func CommandScanner(command string) AttachmentScanner {
+	args := strings.Fields(command)
+	return func(path string) error {
+		if len(args) == 0 {
+			return nil
+		}
+		out, err := exec.Command(args[0], append(args[1:], path)...).CombinedOutput()
+		if err != nil {
+			return errors.Errorf("file failed the virus scan: %s", strings.TrimSpace(string(out)))
+		}
+		return nil
+	}
+}
+
+// SaveAttachment stores an uploaded file and attaches it to an entity. The
+// file is scanned before it is recorded and discarded if it is rejected.
+func SaveAttachment(repoID, entityType, entityID, filename string, content io.Reader, userID string) (*Attachment, error) {
+	filename = filepath.Base(strings.TrimSpace(filename))
+	if filename == "." || filename == string(filepath.Separator) {
+		filename = "attachment"
+	}
+
+	// Uploads are staged on local disk so the scanner can read them
+	tmp, err := os.CreateTemp("", "upload-*")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to store attachment")
+	}
+	defer os.Remove(tmp.Name())
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), io.LimitReader(content, MaxAttachmentSize+1))
+	tmp.Close()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to store attachment")
+	}
+	if size > MaxAttachmentSize {
+		return nil, errors.Errorf("%s is larger than the %d MB limit", filename, MaxAttachmentSize>>20)
+	}
+	if size == 0 {
+		return nil, errors.Errorf("%s is empty", filename)
+	}
+
+	if ScanAttachment != nil {
+		if err := ScanAttachment(tmp.Name()); err != nil {
+			return nil, errors.Wrapf(err, "%s was rejected", filename)
+		}
+	}
+
+	attachment := &Attachment{
+		Model:       DB.NewModel(""),
+		RepoID:      repoID,
+		EntityType:  entityType,
+		EntityID:    entityID,
+		Filename:    filename,
+		ContentType: sniffContentType(tmp.Name()),
+		Size:        size,
+		Checksum:    hex.EncodeToString(hash.Sum(nil)),
+		UploadedBy:  userID,
+	}
+	staged, err := os.Open(tmp.Name())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to store attachment")
+	}
+	err = Storage().Put(attachment.Key(), staged)
+	staged.Close()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to store attachment")
+	}
+
+	saved, err := Attachments.Insert(attachment)
+	if err != nil {
+		Storage().Delete(attachment.Key())
+		return nil, errors.Wrap(err, "failed to record attachment")
+	}
+	return saved, nil
+}
+
+// GetAttachments returns the files attached to an entity, oldest first
+func GetAttachments(entityType, entityID string) ([]*Attachment, error) {
+	return Attachments.Search("WHERE EntityType = ? AND EntityID = ? ORDER BY CreatedAt ASC", entityType, entityID)
+}
+
+// DeleteAttachments removes every file attached to an entity
+func DeleteAttachments(entityType, entityID string) error {
+	attachments, err := GetAttachments(entityType, entityID)
+	if err != nil {
+		return err
+	}
+	for _, attachment := range attachments {
+		if err := attachment.Delete(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Key returns where the attachment's content is kept in file storage
+func (a *Attachment) Key() string {
+	return attachmentPrefix(a.RepoID) + a.ID
+}
+
+// Path returns the attachment's file when storage is on local disk, or
+// nothing when it is kept elsewhere
+func (a *Attachment) Path() string {
+	if local, ok := Storage().(*storage.Local); ok {
+		path, _ := local.Path(a.Key())
+		return path
+	}
+	return ""
+}
+
+// Open opens the attachment's content
+func (a *Attachment) Open() (io.ReadCloser, error) {
+	return Storage().Get(a.Key())
+}
+
+// Read returns the attachment's content
+func (a *Attachment) Read() ([]byte, error) {
+	r, err := a.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// IsImage returns true for raster images that are safe to show inline
+func (a *Attachment) IsImage() bool {
+	switch a.ContentType {
+	case "image/png", "image/jpeg", "image/gif", "image/webp":
+		return true
+	}
+	return false
+}
+
+// IsText returns true for text files such as logs and patches
+func (a *Attachment) IsText() bool {
+	return strings.HasPrefix(a.ContentType, "text/")
+}
+
+// Preview returns the start of a text attachment
+func (a *Attachment) Preview() string {
+	if !a.IsText() {
+		return ""
+	}
+
+	file, err := a.Open()
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	buf := make([]byte, attachmentPreviewSize)
+	n, _ := io.ReadFull(file, buf)
+	// Drop a multi-byte character cut off at the end of the buffer
+	preview := strings.ToValidUTF8(string(buf[:n]), "")
+	if int64(n) < a.Size {
+		return preview + "\n…"
+	}
+	return preview
+}
+
+// FormatSize returns the size in human-readable form
+func (a *Attachment) FormatSize() string {
+	switch {
+	case a.Size >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(a.Size)/(1<<20))
+	case a.Size >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(a.Size)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", a.Size)
+}
+
+// Delete removes the attachment and its content
+func (a *Attachment) Delete() error {
+	if err := Storage().Delete(a.Key()); err != nil {
+		return errors.Wrap(err, "failed to remove attachment")
+	}
+	return Attachments.Delete(a)
+}
+
+// attachmentPrefix is where a repository's attachments are kept in file
+// storage
+func attachmentPrefix(repoID string) string {
+	return "attachments/" + repoID + "/"
+}
+
+// attachmentDir is where a repository's attachments were kept before file
+// storage, and still are when it is the data directory
+func attachmentDir(repoID string) string {
+	return filepath.Join(database.DataDir(), "attachments", repoID)
+}
+
+// sniffContentType detects a file's type from its first bytes
+func sniffContentType(path string) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return "application/octet-stream"
+	}
+	defer file.Close()
+
+	buf := make([]byte, 512)
+	n, _ := io.ReadFull(file, buf)
+	return http.DetectContentType(buf[:n])
+}
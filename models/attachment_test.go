@@ -0,0 +1,102 @@
+package models
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/The-Skyscape/devtools/pkg/testutils"
+)
+
+func TestAttachments(t *testing.T) {
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+	t.Setenv("DATA_DIR", t.TempDir())
+
+	user := CreateTestUser(t, db, "uploader@example.com")
+	repo := createTestRepository(t, "attach-repo", user.ID)
+	issue, err := CreateIssue("Crash on start", "See attached log", user.ID, repo.ID)
+	testutils.AssertNoError(t, err)
+
+	t.Run("SaveAttachment", func(t *testing.T) {
+		log := strings.Repeat("panic: nil map\n", 400)
+		attachment, err := SaveAttachment(repo.ID, "issue", issue.ID, "../../server.log", strings.NewReader(log), user.ID)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, "server.log", attachment.Filename)
+		testutils.AssertEqual(t, int64(len(log)), attachment.Size)
+		testutils.AssertTrue(t, attachment.IsText())
+		testutils.AssertFalse(t, attachment.IsImage())
+		testutils.AssertTrue(t, strings.HasSuffix(attachment.Preview(), "…"))
+
+		png := append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 64)...)
+		image, err := SaveAttachment(repo.ID, "issue", issue.ID, "screenshot.png", bytes.NewReader(png), user.ID)
+		testutils.AssertNoError(t, err)
+		testutils.AssertTrue(t, image.IsImage())
+		testutils.AssertEqual(t, "", image.Preview())
+
+		attachments, err := GetAttachments("issue", issue.ID)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 2, len(attachments))
+	})
+
+	t.Run("RejectsOversizedAndEmpty", func(t *testing.T) {
+		_, err := SaveAttachment(repo.ID, "issue", issue.ID, "huge.bin", bytes.NewReader(make([]byte, MaxAttachmentSize+1)), user.ID)
+		testutils.AssertError(t, err)
+		_, err = SaveAttachment(repo.ID, "issue", issue.ID, "empty.txt", strings.NewReader(""), user.ID)
+		testutils.AssertError(t, err)
+	})
+
+	t.Run("ScannerRejection", func(t *testing.T) {
+		ScanAttachment = func(path string) error { return errors.New("Eicar-Test-Signature FOUND") }
+		defer func() { ScanAttachment = nil }()
+
+		_, err := SaveAttachment(repo.ID, "comment", "c1", "eicar.txt", strings.NewReader("X5O!P%@AP"), user.ID)
+		testutils.AssertError(t, err)
+
+		attachments, err := GetAttachments("comment", "c1")
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 0, len(attachments))
+
+		leftovers, _ := filepath.Glob(filepath.Join(attachmentDir(repo.ID), "upload-*"))
+		testutils.AssertEqual(t, 0, len(leftovers))
+	})
+
+	t.Run("DeleteAttachments", func(t *testing.T) {
+		attachments, err := GetAttachments("issue", issue.ID)
+		testutils.AssertNoError(t, err)
+		path := attachments[0].Path()
+
+		testutils.AssertNoError(t, DeleteAttachments("issue", issue.ID))
+		_, err = os.Stat(path)
+		testutils.AssertTrue(t, os.IsNotExist(err))
+
+		attachments, err = GetAttachments("issue", issue.ID)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 0, len(attachments))
+	})
+
+	t.Run("MessageImages", func(t *testing.T) {
+		msg, err := Messages.Insert(&Message{ConversationID: "conv1", Role: MessageRoleUser, Content: "Why is this dialog blank?"})
+		testutils.AssertNoError(t, err)
+
+		_, err = msg.AttachImage("notes.txt", strings.NewReader("not an image"), user.ID)
+		testutils.AssertError(t, err)
+		testutils.AssertEqual(t, 0, msg.ImageCount)
+
+		png := append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 64)...)
+		_, err = msg.AttachImage("dialog.png", bytes.NewReader(png), user.ID)
+		testutils.AssertNoError(t, err)
+
+		saved, err := Messages.Get(msg.ID)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 1, saved.ImageCount)
+		testutils.AssertEqual(t, 1, len(saved.Images()))
+		encoded := saved.EncodedImages()
+		testutils.AssertEqual(t, 1, len(encoded))
+		testutils.AssertEqual(t, base64.StdEncoding.EncodeToString(png), encoded[0])
+	})
+}
@@ -50,6 +50,7 @@ const (
 	AuditEventUserModified     AuditEventType = "admin.user_modified"
 	AuditEventPermissionGranted AuditEventType = "admin.permission_granted"
 	AuditEventPermissionRevoked AuditEventType = "admin.permission_revoked"
+	AuditEventBackupRestored    AuditEventType = "admin.backup_restored"
 )
 
 // AuditSeverity represents the severity level of an audit event
@@ -112,7 +113,7 @@ func LogAuditEvent(eventType AuditEventType, userID, userEmail, resourceType, re
 // DetermineSeverity determines the severity based on event type
 func DetermineSeverity(eventType AuditEventType) AuditSeverity {
 	switch eventType {
-	case AuditEventLoginFailed, AuditEventAccessDenied:
+	case AuditEventLoginFailed, AuditEventAccessDenied, AuditEventBackupRestored:
 		return AuditSeverityWarning
 	case AuditEventSecurityViolation, AuditEventTokenRevoked:
 		return AuditSeverityCritical
@@ -0,0 +1,190 @@
+package models
+
+import (
+	"path"
+	"strings"
+	"time"
+
+	"github.com/The-Skyscape/devtools/pkg/application"
+	"github.com/pkg/errors"
+)
+
+// Default auto-approval policy, applied to repositories without a saved policy
+const (
+	DefaultMaxLinesChanged = 200
+	DefaultProtectedPaths  = ".github/workflows/\n.skyscape/"
+)
+
+// ApprovalCategories are the change categories the PR analyzer detects
+var ApprovalCategories = []string{
+	"documentation", "testing", "ci/cd", "dependency", "refactoring",
+	"bug", "enhancement", "feature", "performance", "security", "other",
+}
+
+// AutoApprovalPolicy decides which of a repository's pull requests the AI
+// queue may approve without a human review. It is stored with the
+// repository ID as its ID. Pull requests with security findings, breaking
+// changes or more than low risk are never approved, whatever the policy.
+type AutoApprovalPolicy struct {
+	application.Model
+	RepoID            string
+	Enabled           bool   // Repositories opt in, on top of the global auto-approve switch
+	AllowedCategories string // One category per line, every category of the PR must be listed; empty allows any
+	MaxLinesChanged   int    // Most lines added and deleted, 0 for no limit
+	RequiredChecks    string // One status context or action title per line, each must have passed on the PR's branch
+	ProtectedPaths    string // One path per line, a directory ending in / or a glob; changes to them need a human
+	AllowedAuthors    string // One user handle or bot ID per line; empty allows anyone
+	UpdatedBy         string
+}
+
+// Table returns the database table name
+func (*AutoApprovalPolicy) Table() string { return "auto_approval_policies" }
+
+// DefaultAutoApprovalPolicy returns the policy used until an admin
+// configures one, which leaves auto-approval off
+func DefaultAutoApprovalPolicy(repoID string) *AutoApprovalPolicy {
+	return &AutoApprovalPolicy{
+		Model:           DB.NewModel(repoID),
+		RepoID:          repoID,
+		MaxLinesChanged: DefaultMaxLinesChanged,
+		ProtectedPaths:  DefaultProtectedPaths,
+	}
+}
+
+// GetAutoApprovalPolicy returns the repository's auto-approval policy,
+// falling back to the defaults when none has been saved
+func GetAutoApprovalPolicy(repoID string) *AutoApprovalPolicy {
+	if policy, err := AutoApprovalPolicies.Get(repoID); err == nil {
+		return policy
+	}
+	return DefaultAutoApprovalPolicy(repoID)
+}
+
+// SaveAutoApprovalPolicy validates and stores a repository's auto-approval
+// policy
+func SaveAutoApprovalPolicy(policy *AutoApprovalPolicy, userID string) (*AutoApprovalPolicy, error) {
+	if policy.MaxLinesChanged < 0 || policy.MaxLinesChanged > 100000 {
+		return nil, errors.New("max lines changed must be between 0 and 100000")
+	}
+
+	categories := policy.CategoryList()
+	for _, category := range categories {
+		known := false
+		for _, c := range ApprovalCategories {
+			known = known || c == category
+		}
+		if !known {
+			return nil, errors.Errorf("unknown change category %q", category)
+		}
+	}
+
+	paths := splitPolicyList(policy.ProtectedPaths)
+	for _, p := range paths {
+		if _, err := path.Match(strings.TrimSuffix(p, "/"), ""); err != nil {
+			return nil, errors.Errorf("invalid protected path %q", p)
+		}
+	}
+
+	policy.AllowedCategories = strings.Join(categories, "\n")
+	policy.ProtectedPaths = strings.Join(paths, "\n")
+	policy.RequiredChecks = strings.Join(policy.RequiredCheckList(), "\n")
+	policy.AllowedAuthors = strings.Join(policy.AuthorList(), "\n")
+	policy.UpdatedBy = userID
+
+	if _, err := AutoApprovalPolicies.Get(policy.ID); err != nil {
+		return AutoApprovalPolicies.Insert(policy)
+	}
+	policy.UpdatedAt = time.Now()
+	return policy, AutoApprovalPolicies.Update(policy)
+}
+
+// HasCategory reports whether the policy lists category, for the settings form
+func (p *AutoApprovalPolicy) HasCategory(category string) bool {
+	for _, c := range p.CategoryList() {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
+
+// CategoryList returns the lowercased categories a pull request may have
+func (p *AutoApprovalPolicy) CategoryList() []string {
+	var categories []string
+	for _, category := range splitPolicyList(p.AllowedCategories) {
+		categories = append(categories, strings.ToLower(category))
+	}
+	return categories
+}
+
+// RequiredCheckList returns the checks that must pass, by status context
+// or action title
+func (p *AutoApprovalPolicy) RequiredCheckList() []string {
+	return splitPolicyList(p.RequiredChecks)
+}
+
+// ProtectedPathList returns the paths only a human can approve changes to
+func (p *AutoApprovalPolicy) ProtectedPathList() []string {
+	return splitPolicyList(p.ProtectedPaths)
+}
+
+// AuthorList returns the lowercased handles and bot IDs whose pull requests
+// may be approved
+func (p *AutoApprovalPolicy) AuthorList() []string {
+	var authors []string
+	for _, author := range splitPolicyList(p.AllowedAuthors) {
+		authors = append(authors, strings.ToLower(author))
+	}
+	return authors
+}
+
+// CheckResults returns whether each required check passed in its latest
+// run on branch. Checks that haven't run there are left out.
+func (p *AutoApprovalPolicy) CheckResults(branch string) (map[string]bool, error) {
+	return requiredCheckResults(p.RepoID, p.RequiredCheckList(), branch)
+}
+
+// requiredCheckResults returns whether each of a repository's checks
+// passed on branch. A check is a status context reported on the branch's
+// head, or else an action's title, judged by its latest run on branch.
+// Checks that haven't finished there are left out.
+func requiredCheckResults(repoID string, titles []string, branch string) (map[string]bool, error) {
+	var statuses []*CommitStatus
+	if repo, err := Repositories.Get(repoID); err == nil {
+		if head := branchHead(repo, branch); head != "" {
+			if statuses, err = GetCommitStatuses(repoID, head); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	results := map[string]bool{}
+	for _, title := range titles {
+		if status := findStatus(statuses, title); status != nil {
+			if status.State != CommitStatusPending {
+				results[title] = status.State == CommitStatusSuccess
+			}
+			continue
+		}
+
+		actions, err := Actions.Search("WHERE RepoID = ? AND LOWER(Title) = LOWER(?)", repoID, title)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to find required checks")
+		}
+		for _, action := range actions {
+			run, err := GetLatestRunOnBranch(action.ID, branch)
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to get check results")
+			}
+			if run == nil || run.Status == "running" || run.Status == "queued" || run.Status == "pending" {
+				continue
+			}
+			passed := (run.Status == "success" || run.Status == "completed") && run.ExitCode == 0
+			if earlier, ok := results[title]; ok {
+				passed = passed && earlier
+			}
+			results[title] = passed
+		}
+	}
+	return results, nil
+}
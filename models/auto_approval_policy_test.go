@@ -0,0 +1,79 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/The-Skyscape/devtools/pkg/testutils"
+)
+
+func TestAutoApprovalPolicy(t *testing.T) {
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+
+	t.Run("DefaultsWithoutSavedPolicy", func(t *testing.T) {
+		policy := GetAutoApprovalPolicy("approval-repo")
+		testutils.AssertFalse(t, policy.Enabled)
+		testutils.AssertEqual(t, DefaultMaxLinesChanged, policy.MaxLinesChanged)
+		testutils.AssertEqual(t, 0, len(policy.CategoryList()))
+		testutils.AssertEqual(t, 2, len(policy.ProtectedPathList()))
+	})
+
+	t.Run("SaveAndLoad", func(t *testing.T) {
+		policy := GetAutoApprovalPolicy("approval-repo")
+		policy.Enabled = true
+		policy.AllowedCategories = "Documentation, testing\n\n"
+		policy.AllowedAuthors = "Dependabot\nsystem"
+		policy.RequiredChecks = "Unit Tests"
+
+		_, err := SaveAutoApprovalPolicy(policy, "admin")
+		testutils.AssertNoError(t, err)
+
+		saved := GetAutoApprovalPolicy("approval-repo")
+		testutils.AssertTrue(t, saved.Enabled)
+		testutils.AssertEqual(t, "documentation\ntesting", saved.AllowedCategories)
+		testutils.AssertTrue(t, saved.HasCategory("testing"))
+		testutils.AssertFalse(t, saved.HasCategory("feature"))
+		testutils.AssertEqual(t, "dependabot", saved.AuthorList()[0])
+		testutils.AssertEqual(t, "admin", saved.UpdatedBy)
+	})
+
+	t.Run("RejectsInvalidPolicies", func(t *testing.T) {
+		policy := DefaultAutoApprovalPolicy("approval-invalid")
+		policy.MaxLinesChanged = -1
+		_, err := SaveAutoApprovalPolicy(policy, "admin")
+		testutils.AssertError(t, err)
+
+		policy = DefaultAutoApprovalPolicy("approval-invalid")
+		policy.AllowedCategories = "typos"
+		_, err = SaveAutoApprovalPolicy(policy, "admin")
+		testutils.AssertError(t, err)
+
+		policy = DefaultAutoApprovalPolicy("approval-invalid")
+		policy.ProtectedPaths = "src/[auth"
+		_, err = SaveAutoApprovalPolicy(policy, "admin")
+		testutils.AssertError(t, err)
+	})
+
+	t.Run("CheckResults", func(t *testing.T) {
+		policy := DefaultAutoApprovalPolicy("approval-checks")
+		policy.RequiredChecks = "unit tests\nlint\nbuild"
+
+		tests, err := Actions.Insert(&Action{Title: "Unit Tests", RepoID: policy.RepoID})
+		testutils.AssertNoError(t, err)
+		lint, err := Actions.Insert(&Action{Title: "Lint", RepoID: policy.RepoID})
+		testutils.AssertNoError(t, err)
+		_, err = ActionRuns.Insert(&ActionRun{ActionID: tests.ID, Status: "completed", Branch: "feature"})
+		testutils.AssertNoError(t, err)
+		_, err = ActionRuns.Insert(&ActionRun{ActionID: lint.ID, Status: "failed", ExitCode: 1, Branch: "feature"})
+		testutils.AssertNoError(t, err)
+		_, err = ActionRuns.Insert(&ActionRun{ActionID: lint.ID, Status: "completed", Branch: "main"})
+		testutils.AssertNoError(t, err)
+
+		results, err := policy.CheckResults("feature")
+		testutils.AssertNoError(t, err)
+		testutils.AssertTrue(t, results["unit tests"])
+		testutils.AssertFalse(t, results["lint"])
+		_, ran := results["build"]
+		testutils.AssertFalse(t, ran)
+	})
+}
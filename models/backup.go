@@ -0,0 +1,178 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"workspace/internal/backup"
+	"workspace/internal/storage"
+)
+
+// Vault keys of the backup encryption key and the password or secret key
+// of the backup destination
+const (
+	BackupKeySecret         = "backup/encryption"
+	BackupDestinationSecret = "backup/destination"
+)
+
+// How long backups are kept unless the settings say otherwise
+const (
+	DefaultBackupRetentionDays = 30
+	DefaultMaxBackups          = 10
+)
+
+// BackupRetention returns how many days and how many backups are kept on
+// the host, and then in remote storage where zero follows the host
+func (s *Settings) BackupRetention() (days, count, remoteDays, remoteCount int) {
+	days, count = s.BackupRetentionDays, s.BackupMaxBackups
+	if days <= 0 {
+		days = DefaultBackupRetentionDays
+	}
+	if count <= 0 {
+		count = DefaultMaxBackups
+	}
+	return days, count, max(s.BackupRemoteRetentionDays, 0), max(s.BackupRemoteMaxBackups, 0)
+}
+
+// BackupTestRestoreInterval returns how often the latest backup is test
+// restored, zero for never
+func (s *Settings) BackupTestRestoreInterval() time.Duration {
+	switch {
+	case s.BackupTestRestoreDays < 0:
+		return 0
+	case s.BackupTestRestoreDays == 0:
+		return backup.DefaultTestRestoreInterval
+	}
+	return time.Duration(s.BackupTestRestoreDays) * 24 * time.Hour
+}
+
+// BackupDestinationConfig returns the backup destination the settings
+// describe, with its secret from the vault
+func (s *Settings) BackupDestinationConfig() storage.Config {
+	config := storage.Config{
+		Backend:   s.BackupTarget,
+		Endpoint:  s.BackupEndpoint,
+		Bucket:    s.BackupBucket,
+		Region:    s.BackupRegion,
+		Prefix:    s.BackupPrefix,
+		AccessKey: s.BackupUsername,
+		HostKey:   s.BackupHostKey,
+	}
+	config.SecretKey, _ = GetBackupDestinationSecret()
+	return config
+}
+
+// BackupDestination returns the remote storage backups are copied to: their
+// own destination, file storage when it's off the host, or nil for none
+func BackupDestination(settings *Settings) (storage.Store, error) {
+	if settings.BackupTarget != "" {
+		return storage.New(settings.BackupDestinationConfig())
+	}
+	if !settings.UsesDefaultStorage() {
+		return Storage(), nil
+	}
+	return nil, nil
+}
+
+// StoreBackupDestinationSecret stores the backup destination's password,
+// private key or S3 secret key
+func StoreBackupDestinationSecret(secret string) error {
+	return StoreSecret(BackupDestinationSecret, map[string]any{"secret": secret})
+}
+
+// GetBackupDestinationSecret returns the backup destination's secret
+func GetBackupDestinationSecret() (string, error) {
+	secret, err := Secrets.GetSecret(BackupDestinationSecret)
+	if err != nil {
+		return "", err
+	}
+	value, _ := secret["secret"].(string)
+	if value == "" {
+		return "", errors.New("backup destination secret not found")
+	}
+	return value, nil
+}
+
+// DeleteBackupDestinationSecret removes the backup destination's secret
+func DeleteBackupDestinationSecret() error {
+	return DeleteSecret(BackupDestinationSecret)
+}
+
+// GetBackupKey returns the key backups are encrypted with
+func GetBackupKey() ([]byte, error) {
+	secret, err := Secrets.GetSecret(BackupKeySecret)
+	if err != nil {
+		return nil, err
+	}
+	encoded, _ := secret["key"].(string)
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(key) != backup.KeySize {
+		return nil, errors.New("backup encryption key not found")
+	}
+	return key, nil
+}
+
+// EnsureBackupKey returns the backup encryption key, generating one the
+// first time encryption is turned on. Replacing it would leave earlier
+// backups unreadable, so an existing key is always kept.
+func EnsureBackupKey() ([]byte, error) {
+	if key, err := GetBackupKey(); err == nil {
+		return key, nil
+	}
+	key, err := backup.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	if err := StoreSecret(BackupKeySecret, map[string]any{"key": base64.StdEncoding.EncodeToString(key)}); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// CheckBackupDatabase opens a test restored copy of the database without
+// changing it, checks its integrity and counts the rows in each table
+func CheckBackupDatabase(path string) (map[string]int64, error) {
+	db, err := sql.Open("sqlite3", "file:"+path+"?mode=ro&immutable=1")
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var integrity string
+	if err := db.QueryRow("PRAGMA quick_check").Scan(&integrity); err != nil {
+		return nil, fmt.Errorf("failed to open: %w", err)
+	}
+	if integrity != "ok" {
+		return nil, fmt.Errorf("integrity check failed: %s", integrity)
+	}
+
+	tables, err := db.Query("SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%'")
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for tables.Next() {
+		var name string
+		if err := tables.Scan(&name); err != nil {
+			tables.Close()
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	tables.Close()
+
+	rows := make(map[string]int64, len(names))
+	for _, name := range names {
+		var count int64
+		quoted := `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+		if err := db.QueryRow("SELECT COUNT(*) FROM " + quoted).Scan(&count); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		rows[name] = count
+	}
+	return rows, nil
+}
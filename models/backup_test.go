@@ -0,0 +1,67 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"workspace/internal/backup"
+
+	"github.com/The-Skyscape/devtools/pkg/testutils"
+)
+
+func TestBackupSettings(t *testing.T) {
+	SetupTestSecrets(t)
+
+	t.Run("Retention", func(t *testing.T) {
+		days, count, remoteDays, remoteCount := (&Settings{}).BackupRetention()
+		testutils.AssertEqual(t, DefaultBackupRetentionDays, days)
+		testutils.AssertEqual(t, DefaultMaxBackups, count)
+		testutils.AssertEqual(t, 0, remoteDays)
+		testutils.AssertEqual(t, 0, remoteCount)
+
+		settings := &Settings{BackupRetentionDays: 7, BackupMaxBackups: 3, BackupRemoteRetentionDays: 90, BackupRemoteMaxBackups: -1}
+		days, count, remoteDays, remoteCount = settings.BackupRetention()
+		testutils.AssertEqual(t, 7, days)
+		testutils.AssertEqual(t, 3, count)
+		testutils.AssertEqual(t, 90, remoteDays)
+		testutils.AssertEqual(t, 0, remoteCount)
+	})
+
+	t.Run("TestRestoreInterval", func(t *testing.T) {
+		testutils.AssertEqual(t, backup.DefaultTestRestoreInterval, (&Settings{}).BackupTestRestoreInterval())
+		testutils.AssertEqual(t, 24*time.Hour, (&Settings{BackupTestRestoreDays: 1}).BackupTestRestoreInterval())
+		testutils.AssertEqual(t, time.Duration(0), (&Settings{BackupTestRestoreDays: -1}).BackupTestRestoreInterval())
+	})
+
+	t.Run("Destination", func(t *testing.T) {
+		store, err := BackupDestination(&Settings{})
+		testutils.AssertNoError(t, err)
+		testutils.AssertTrue(t, store == nil)
+
+		testutils.AssertNoError(t, StoreBackupDestinationSecret("s3cret"))
+		defer DeleteBackupDestinationSecret()
+		settings := &Settings{BackupTarget: "webdav", BackupEndpoint: "https://dav.example.com/backups", BackupUsername: "ops"}
+		testutils.AssertEqual(t, "s3cret", settings.BackupDestinationConfig().SecretKey)
+		store, err = BackupDestination(settings)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, "WebDAV share at dav.example.com/backups", store.String())
+
+		settings.BackupTarget = "sftp"
+		_, err = BackupDestination(settings)
+		testutils.AssertError(t, err) // No host key yet
+	})
+
+	t.Run("EncryptionKey", func(t *testing.T) {
+		defer DeleteSecret(BackupKeySecret)
+		_, err := GetBackupKey()
+		testutils.AssertError(t, err)
+
+		key, err := EnsureBackupKey()
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, backup.KeySize, len(key))
+
+		again, err := EnsureBackupKey()
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, backup.KeyID(key), backup.KeyID(again))
+	})
+}
@@ -0,0 +1,315 @@
+package models
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/The-Skyscape/devtools/pkg/application"
+	"github.com/pkg/errors"
+)
+
+// MaxRequiredApprovals is the most approvals a protection rule can require
+const MaxRequiredApprovals = 10
+
+// branchPatternChars are the characters allowed in protected branch
+// patterns. Keeping to these lets patterns go into the pre-receive hook
+// unquoted, where * matches the same way it does here.
+var branchPatternChars = regexp.MustCompile(`^[A-Za-z0-9._/*-]+$`)
+
+// protectionHookMarker identifies pre-receive hooks written by the
+// workspace, so hooks put there by hand are never overwritten
+const protectionHookMarker = "# Branch protection written by the workspace."
+
+// BranchProtection guards the branches of a repository matching its
+// pattern. Pushes to them can be refused outright, or only when they
+// rewrite history, and pull requests into them can need approvals and
+// passing checks before they merge.
+type BranchProtection struct {
+	application.Model
+	RepoID            string
+	Pattern           string // Branch name, where * matches anything, such as release/*
+	BlockPushes       bool   // Changes only land through merged pull requests
+	BlockForcePushes  bool   // Refuse pushes that rewrite history or delete the branch
+	RequiredApprovals int    // Approvals of the latest commit needed to merge
+	RequiredChecks    string // One status context or action title per line, each must have passed on the PR's branch
+	UpdatedBy         string
+}
+
+// Table returns the database table name
+func (*BranchProtection) Table() string { return "branch_protections" }
+
+// GetBranchProtections returns a repository's protection rules
+func GetBranchProtections(repoID string) ([]*BranchProtection, error) {
+	return BranchProtections.Search("WHERE RepoID = ? ORDER BY Pattern ASC", repoID)
+}
+
+// ProtectionsFor returns the repository's rules that cover branch
+func ProtectionsFor(repoID, branch string) ([]*BranchProtection, error) {
+	rules, err := GetBranchProtections(repoID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get branch protection")
+	}
+	var matching []*BranchProtection
+	for _, rule := range rules {
+		if rule.Matches(branch) {
+			matching = append(matching, rule)
+		}
+	}
+	return matching, nil
+}
+
+// IsPushProtected reports whether branch only takes changes through pull
+// requests
+func IsPushProtected(repoID, branch string) bool {
+	rules, err := ProtectionsFor(repoID, branch)
+	if err != nil {
+		return false
+	}
+	return slices.ContainsFunc(rules, func(rule *BranchProtection) bool { return rule.BlockPushes })
+}
+
+// SaveBranchProtection validates and stores a protection rule, then
+// rewrites the repository's pre-receive hook to enforce it. The saved rule
+// is returned along with any error writing the hook.
+func SaveBranchProtection(rule *BranchProtection, userID string) (*BranchProtection, error) {
+	rule.Pattern = strings.TrimSpace(rule.Pattern)
+	if rule.Pattern == "" {
+		return nil, errors.New("branch pattern is required")
+	}
+	if !branchPatternChars.MatchString(rule.Pattern) || strings.HasPrefix(rule.Pattern, "-") || strings.Contains(rule.Pattern, "..") {
+		return nil, errors.Errorf("invalid branch pattern %q, use letters, numbers, . _ - / and *", rule.Pattern)
+	}
+	if rule.RequiredApprovals < 0 || rule.RequiredApprovals > MaxRequiredApprovals {
+		return nil, errors.Errorf("required approvals must be between 0 and %d", MaxRequiredApprovals)
+	}
+	rule.RequiredChecks = strings.Join(rule.RequiredCheckList(), "\n")
+	if !rule.BlockPushes && !rule.BlockForcePushes && rule.RequiredApprovals == 0 && rule.RequiredChecks == "" {
+		return nil, errors.New("choose at least one protection for the branch")
+	}
+
+	existing, err := BranchProtections.Search("WHERE RepoID = ? AND Pattern = ? AND ID != ?", rule.RepoID, rule.Pattern, rule.ID)
+	if err == nil && len(existing) > 0 {
+		return nil, errors.Errorf("%s is already protected", rule.Pattern)
+	}
+
+	repo, err := Repositories.Get(rule.RepoID)
+	if err != nil {
+		return nil, errors.New("repository not found")
+	}
+
+	rule.UpdatedBy = userID
+	if _, err := BranchProtections.Get(rule.ID); err != nil {
+		if rule, err = BranchProtections.Insert(rule); err != nil {
+			return nil, errors.Wrap(err, "failed to save branch protection")
+		}
+	} else {
+		rule.UpdatedAt = time.Now()
+		if err := BranchProtections.Update(rule); err != nil {
+			return nil, errors.Wrap(err, "failed to save branch protection")
+		}
+	}
+	return rule, WriteProtectionHook(repo)
+}
+
+// DeleteBranchProtection removes a protection rule and stops enforcing it
+// on pushes
+func DeleteBranchProtection(rule *BranchProtection) error {
+	if err := BranchProtections.Delete(rule); err != nil {
+		return errors.Wrap(err, "failed to delete branch protection")
+	}
+	repo, err := Repositories.Get(rule.RepoID)
+	if err != nil {
+		return nil
+	}
+	return WriteProtectionHook(repo)
+}
+
+// Matches reports whether the rule covers branch
+func (p *BranchProtection) Matches(branch string) bool {
+	// Matches like the shell case patterns in the hook, where * crosses /
+	expr := "^" + strings.ReplaceAll(regexp.QuoteMeta(p.Pattern), `\*`, ".*") + "$"
+	matched, _ := regexp.MatchString(expr, branch)
+	return matched
+}
+
+// RequiredCheckList returns the status contexts and action titles that must
+// pass before merging
+func (p *BranchProtection) RequiredCheckList() []string {
+	return splitPolicyList(p.RequiredChecks)
+}
+
+// MergeBlockers lists what keeps the pull request from merging: being a
+// draft, reviewers asking for changes, and the protection rules of its
+// base branch. It's empty when the pull request can be merged.
+func (pr *PullRequest) MergeBlockers() ([]string, error) {
+	var blockers []string
+	block := func(format string, args ...any) {
+		if blocker := fmt.Sprintf(format, args...); !slices.Contains(blockers, blocker) {
+			blockers = append(blockers, blocker)
+		}
+	}
+
+	if pr.Draft {
+		block("it is a draft")
+	}
+	requested, err := pr.ChangesRequestedBy()
+	if err != nil {
+		return nil, err
+	}
+	for _, userID := range requested {
+		name := userID
+		if user, err := Users.Get(userID); err == nil {
+			name = user.Name
+		}
+		block("%s requested changes", name)
+	}
+
+	rules, err := ProtectionsFor(pr.RepoID, pr.BaseBranch)
+	if err != nil || len(rules) == 0 {
+		return blockers, err
+	}
+
+	approvals := -1
+	for _, rule := range rules {
+		if rule.RequiredApprovals > 0 {
+			if approvals < 0 {
+				if approvals, err = pr.ApprovalCount(); err != nil {
+					return nil, err
+				}
+			}
+			if approvals < rule.RequiredApprovals {
+				block("%d of %d required approvals of the latest commit", approvals, rule.RequiredApprovals)
+			}
+		}
+
+		checks := rule.RequiredCheckList()
+		if len(checks) == 0 {
+			continue
+		}
+		results, err := requiredCheckResults(pr.RepoID, checks, pr.CompareBranch)
+		if err != nil {
+			return nil, err
+		}
+		for _, title := range checks {
+			if passed, ran := results[title]; !ran {
+				block("%s has not passed on %s yet", title, pr.CompareBranch)
+			} else if !passed {
+				block("%s failed on %s", title, pr.CompareBranch)
+			}
+		}
+	}
+	return blockers, nil
+}
+
+// WriteProtectionHook installs the pre-receive hook that enforces the
+// repository's push rules, whether the push comes over HTTP or SSH. The
+// hook is removed once no rule restricts pushes.
+func WriteProtectionHook(repo *Repository) error {
+	rules, err := GetBranchProtections(repo.ID)
+	if err != nil {
+		return errors.Wrap(err, "failed to get branch protection")
+	}
+
+	path := filepath.Join(repo.Path(), "hooks", "pre-receive")
+	if existing, err := os.ReadFile(path); err == nil && !strings.Contains(string(existing), protectionHookMarker) {
+		return errors.New("the repository has its own pre-receive hook, so pushes are not checked")
+	}
+
+	script := protectionHookScript(rules)
+	if script == "" {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return errors.Wrap(err, "failed to remove pre-receive hook")
+		}
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return errors.Wrap(err, "failed to write pre-receive hook")
+	}
+	// Written aside and renamed so a push never runs half a script
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(script), 0755); err != nil {
+		return errors.Wrap(err, "failed to write pre-receive hook")
+	}
+	return errors.Wrap(os.Rename(tmp, path), "failed to write pre-receive hook")
+}
+
+// SyncProtectionHooks rewrites the pre-receive hook of every repository
+// with protected branches, such as after an upgrade or a restore
+func SyncProtectionHooks() error {
+	rules, err := BranchProtections.Search("ORDER BY RepoID")
+	if err != nil {
+		return errors.Wrap(err, "failed to get branch protection")
+	}
+	var failed []string
+	for i, rule := range rules {
+		if i > 0 && rules[i-1].RepoID == rule.RepoID {
+			continue
+		}
+		repo, err := Repositories.Get(rule.RepoID)
+		if err != nil {
+			continue
+		}
+		if err := WriteProtectionHook(repo); err != nil {
+			failed = append(failed, repo.Name+": "+err.Error())
+		}
+	}
+	if len(failed) > 0 {
+		return errors.Errorf("failed to write protection hooks: %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// protectionHookScript builds the pre-receive hook for a repository's
+// rules, or nothing when none of them restrict pushes. Git passes the hook
+// one "old new ref" line per updated ref, and any error refuses the push.
+func protectionHookScript(rules []*BranchProtection) string {
+	var checks strings.Builder
+	for _, rule := range rules {
+		switch {
+		case rule.BlockPushes:
+			fmt.Fprintf(&checks, "\tcase $branch in %s)\n"+
+				"\t\techo \"error: $branch is protected, changes must be merged through a pull request\" >&2\n"+
+				"\t\tstatus=1 ;;\n"+
+				"\tesac\n", rule.Pattern)
+		case rule.BlockForcePushes:
+			fmt.Fprintf(&checks, "\tcase $branch in %s)\n"+
+				"\t\tif $rewrite; then\n"+
+				"\t\t\techo \"error: $branch is protected, force pushes and deletion are not allowed\" >&2\n"+
+				"\t\t\tstatus=1\n"+
+				"\t\tfi ;;\n"+
+				"\tesac\n", rule.Pattern)
+		}
+	}
+	if checks.Len() == 0 {
+		return ""
+	}
+
+	return "#!/bin/sh\n" +
+		protectionHookMarker + "\n" +
+		"# It is rewritten whenever the repository's rules change.\n" +
+		"status=0\n" +
+		"while read -r old new ref; do\n" +
+		"\tcase $ref in refs/heads/*) ;; *) continue ;; esac\n" +
+		"\tbranch=${ref#refs/heads/}\n" +
+		"\n" +
+		"\t# Deleting the branch, or moving it to a commit that doesn't\n" +
+		"\t# descend from where it was, rewrites history\n" +
+		"\trewrite=false\n" +
+		"\tcase $new in\n" +
+		"\t*[!0]*)\n" +
+		"\t\tcase $old in\n" +
+		"\t\t*[!0]*) git merge-base --is-ancestor \"$old\" \"$new\" || rewrite=true ;;\n" +
+		"\t\tesac ;;\n" +
+		"\t*) rewrite=true ;;\n" +
+		"\tesac\n" +
+		"\n" +
+		checks.String() +
+		"done\n" +
+		"exit $status\n"
+}
@@ -0,0 +1,175 @@
+package models
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/The-Skyscape/devtools/pkg/testutils"
+)
+
+func TestBranchProtection(t *testing.T) {
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+	t.Setenv("DATA_DIR", t.TempDir())
+
+	repo, err := Repositories.Insert(&Repository{Name: "protected"})
+	testutils.AssertNoError(t, err)
+
+	t.Run("Matches", func(t *testing.T) {
+		rule := &BranchProtection{Pattern: "release/*"}
+		testutils.AssertTrue(t, rule.Matches("release/1.0"))
+		testutils.AssertTrue(t, rule.Matches("release/1.0/hotfix"))
+		testutils.AssertFalse(t, rule.Matches("release"))
+		testutils.AssertFalse(t, rule.Matches("prerelease/1.0"))
+
+		rule = &BranchProtection{Pattern: "v1.x"}
+		testutils.AssertTrue(t, rule.Matches("v1.x"))
+		testutils.AssertFalse(t, rule.Matches("v1-x"))
+	})
+
+	t.Run("RejectsInvalidRules", func(t *testing.T) {
+		for _, pattern := range []string{"", "main; rm -rf /", "$(id)", "-main", "a/../b", "feature/[ab]"} {
+			_, err := SaveBranchProtection(&BranchProtection{RepoID: repo.ID, Pattern: pattern, BlockPushes: true}, "admin")
+			testutils.AssertError(t, err)
+		}
+
+		_, err := SaveBranchProtection(&BranchProtection{RepoID: repo.ID, Pattern: "main", RequiredApprovals: MaxRequiredApprovals + 1}, "admin")
+		testutils.AssertError(t, err)
+
+		_, err = SaveBranchProtection(&BranchProtection{RepoID: repo.ID, Pattern: "main"}, "admin")
+		testutils.AssertError(t, err)
+	})
+
+	t.Run("SaveAndMatch", func(t *testing.T) {
+		rule, err := SaveBranchProtection(&BranchProtection{RepoID: repo.ID, Pattern: " main ", RequiredApprovals: 1, RequiredChecks: "Unit Tests\n\nLint"}, "admin")
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, "main", rule.Pattern)
+		testutils.AssertEqual(t, "Unit Tests\nLint", rule.RequiredChecks)
+		testutils.AssertEqual(t, "admin", rule.UpdatedBy)
+
+		_, err = SaveBranchProtection(&BranchProtection{RepoID: repo.ID, Pattern: "main", BlockPushes: true}, "admin")
+		testutils.AssertError(t, err)
+
+		rules, err := ProtectionsFor(repo.ID, "main")
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 1, len(rules))
+		testutils.AssertFalse(t, IsPushProtected(repo.ID, "main"))
+
+		testutils.AssertNoError(t, DeleteBranchProtection(rule))
+		rules, err = ProtectionsFor(repo.ID, "main")
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 0, len(rules))
+	})
+
+	t.Run("HookScript", func(t *testing.T) {
+		testutils.AssertEqual(t, "", protectionHookScript([]*BranchProtection{{Pattern: "main", RequiredApprovals: 2}}))
+
+		script := protectionHookScript([]*BranchProtection{
+			{Pattern: "main", BlockPushes: true},
+			{Pattern: "release/*", BlockForcePushes: true},
+		})
+		testutils.AssertTrue(t, strings.HasPrefix(script, "#!/bin/sh\n"+protectionHookMarker))
+		testutils.AssertTrue(t, strings.Contains(script, "case $branch in main)"))
+		testutils.AssertTrue(t, strings.Contains(script, "case $branch in release/*)"))
+	})
+}
+
+func TestBranchProtectionHook(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+	t.Setenv("DATA_DIR", t.TempDir())
+
+	repo, err := Repositories.Insert(&Repository{Name: "hooked"})
+	testutils.AssertNoError(t, err)
+
+	git := func(dir string, args ...string) (string, error) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=Test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=Test", "GIT_COMMITTER_EMAIL=test@example.com")
+		out, err := cmd.CombinedOutput()
+		return string(out), err
+	}
+
+	_, err = git(t.TempDir(), "init", "--bare", "-b", "main", repo.Path())
+	testutils.AssertNoError(t, err)
+	work := t.TempDir()
+	_, err = git(work, "init", "-b", "main")
+	testutils.AssertNoError(t, err)
+	commit := func(message string) {
+		os.WriteFile(filepath.Join(work, "file.txt"), []byte(message), 0644)
+		_, err := git(work, "add", "file.txt")
+		testutils.AssertNoError(t, err)
+		_, err = git(work, "commit", "-m", message)
+		testutils.AssertNoError(t, err)
+	}
+	commit("first")
+	_, err = git(work, "push", repo.Path(), "main", "main:refs/heads/release/1.0")
+	testutils.AssertNoError(t, err)
+
+	_, err = SaveBranchProtection(&BranchProtection{RepoID: repo.ID, Pattern: "release/*", BlockForcePushes: true}, "admin")
+	testutils.AssertNoError(t, err)
+
+	t.Run("FastForwardAllowed", func(t *testing.T) {
+		commit("second")
+		_, err := git(work, "push", repo.Path(), "main:release/1.0")
+		testutils.AssertNoError(t, err)
+	})
+
+	t.Run("ForcePushRefused", func(t *testing.T) {
+		_, err := git(work, "commit", "--amend", "-m", "rewritten")
+		testutils.AssertNoError(t, err)
+		out, err := git(work, "push", "--force", repo.Path(), "main:release/1.0")
+		testutils.AssertError(t, err)
+		testutils.AssertTrue(t, strings.Contains(out, "release/1.0 is protected"))
+
+		out, err = git(work, "push", repo.Path(), ":release/1.0")
+		testutils.AssertError(t, err)
+		testutils.AssertTrue(t, strings.Contains(out, "deletion"))
+	})
+
+	t.Run("DirectPushRefused", func(t *testing.T) {
+		_, err := SaveBranchProtection(&BranchProtection{RepoID: repo.ID, Pattern: "main", BlockPushes: true}, "admin")
+		testutils.AssertNoError(t, err)
+		testutils.AssertTrue(t, IsPushProtected(repo.ID, "main"))
+
+		commit("third")
+		out, err := git(work, "push", repo.Path(), "main")
+		testutils.AssertError(t, err)
+		testutils.AssertTrue(t, strings.Contains(out, "must be merged through a pull request"))
+
+		// Unprotected branches still take pushes
+		_, err = git(work, "push", repo.Path(), "main:feature")
+		testutils.AssertNoError(t, err)
+	})
+
+	t.Run("HookRemovedWithRules", func(t *testing.T) {
+		rules, err := GetBranchProtections(repo.ID)
+		testutils.AssertNoError(t, err)
+		for _, rule := range rules {
+			testutils.AssertNoError(t, DeleteBranchProtection(rule))
+		}
+		_, err = os.Stat(filepath.Join(repo.Path(), "hooks", "pre-receive"))
+		testutils.AssertTrue(t, os.IsNotExist(err))
+
+		_, err = git(work, "push", repo.Path(), "main")
+		testutils.AssertNoError(t, err)
+	})
+
+	t.Run("KeepsOwnHooks", func(t *testing.T) {
+		hook := filepath.Join(repo.Path(), "hooks", "pre-receive")
+		testutils.AssertNoError(t, os.WriteFile(hook, []byte("#!/bin/sh\nexit 0\n"), 0755))
+		_, err := SaveBranchProtection(&BranchProtection{RepoID: repo.ID, Pattern: "main", BlockPushes: true}, "admin")
+		testutils.AssertError(t, err)
+		data, _ := os.ReadFile(hook)
+		testutils.AssertEqual(t, "#!/bin/sh\nexit 0\n", string(data))
+	})
+}
@@ -0,0 +1,306 @@
+package models
+
+import (
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/The-Skyscape/devtools/pkg/application"
+	"github.com/pkg/errors"
+)
+
+// Chat services a repository can post notifications to
+const (
+	ChatSlack   = "slack"   // Slack incoming webhook
+	ChatDiscord = "discord" // Discord channel webhook
+	ChatMatrix  = "matrix"  // Matrix room, posted to as a bot account
+)
+
+// Events a chat integration can post
+const (
+	ChatEventPush         = "push"             // Branches pushed over git
+	ChatEventPROpened     = "pr_opened"        // Pull requests opened
+	ChatEventPRMerged     = "pr_merged"        // Pull requests merged
+	ChatEventIssueOpened  = "issue_opened"     // Issues opened
+	ChatEventActionFailed = "action_failed"    // Action runs that failed
+	ChatEventAutoApproved = "pr_auto_approved" // Pull requests the AI assistant approved
+	ChatEventTest         = "test"             // Sent on demand to test the integration
+)
+
+// ChatEvents lists the events a chat integration can post
+var ChatEvents = []string{
+	ChatEventPush, ChatEventPROpened, ChatEventPRMerged,
+	ChatEventIssueOpened, ChatEventActionFailed, ChatEventAutoApproved,
+}
+
+// ChatTemplates are the messages posted for each event when an integration
+// has no template of its own
+var ChatTemplates = map[string]string{
+	ChatEventPush:         `{{.Actor}} pushed {{.Commits}} commit{{if ne .Commits 1}}s{{end}} to {{.Branch}} in {{.Repo}}{{with .Detail}}: {{.}}{{end}} {{.URL}}`,
+	ChatEventPROpened:     `{{.Actor}} opened pull request !{{.Number}} "{{.Title}}" in {{.Repo}} {{.URL}}`,
+	ChatEventPRMerged:     `{{.Actor}} merged pull request !{{.Number}} "{{.Title}}" into {{.Branch}} in {{.Repo}} {{.URL}}`,
+	ChatEventIssueOpened:  `{{.Actor}} opened issue #{{.Number}} "{{.Title}}" in {{.Repo}} {{.URL}}`,
+	ChatEventActionFailed: `Action "{{.Title}}" failed{{with .Branch}} on {{.}}{{end}} in {{.Repo}} {{.URL}}`,
+	ChatEventAutoApproved: `The AI assistant approved pull request !{{.Number}} "{{.Title}}" in {{.Repo}}{{with .Detail}}: {{.}}{{end}} {{.URL}}`,
+	ChatEventTest:         `Test message from {{.Repo}}, chat notifications are working`,
+}
+
+// maxChatDeliveries is how many deliveries are kept per chat integration
+const maxChatDeliveries = 50
+
+// ChatIntegration posts a repository's events as messages to a Slack or
+// Discord webhook or a Matrix room. The Matrix access token is kept in the
+// vault.
+type ChatIntegration struct {
+	application.Model
+	RepoID         string
+	Provider       string // ChatSlack, ChatDiscord or ChatMatrix
+	URL            string // Webhook URL, or the Matrix homeserver
+	Room           string // Matrix room ID, like !abc:example.org
+	Events         string // One event per line, empty posts every event
+	Template       string // Message for every event, empty uses ChatTemplates
+	Active         bool
+	CreatedBy      string
+	LastStatus     int // HTTP status of the latest delivery, 0 when it couldn't be sent
+	LastDeliveryAt time.Time
+}
+
+// Table returns the database table name
+func (*ChatIntegration) Table() string { return "chat_integrations" }
+
+// ChatDelivery records one message posted by a chat integration
+type ChatDelivery struct {
+	application.Model
+	IntegrationID string
+	RepoID        string
+	Event         string
+	Message       string // Text as it was posted
+	StatusCode    int    // 0 when the request failed before a response
+	Response      string // Start of the response body
+	Error         string
+	Duration      int64 // Milliseconds
+}
+
+// Table returns the database table name
+func (*ChatDelivery) Table() string { return "chat_deliveries" }
+
+// CreateChatIntegration validates and adds a chat integration to a
+// repository. token is the Matrix access token and unused otherwise.
+func CreateChatIntegration(repoID, provider, rawURL, room, token string, events []string, tmpl, userID string) (*ChatIntegration, error) {
+	chat := &ChatIntegration{
+		Model:     DB.NewModel(""),
+		RepoID:    repoID,
+		Provider:  provider,
+		Active:    true,
+		CreatedBy: userID,
+	}
+	if err := chat.configure(rawURL, room, events, tmpl); err != nil {
+		return nil, err
+	}
+	if provider == ChatMatrix && strings.TrimSpace(token) == "" {
+		return nil, errors.New("a Matrix access token is required")
+	}
+
+	chat, err := ChatIntegrations.Insert(chat)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create chat integration")
+	}
+	if provider == ChatMatrix {
+		if err := chat.StoreToken(token); err != nil {
+			ChatIntegrations.Delete(chat)
+			return nil, err
+		}
+	}
+	return chat, nil
+}
+
+// Update changes where the integration posts, what and how. An empty
+// token keeps the stored Matrix access token.
+func (c *ChatIntegration) Update(rawURL, room, token string, events []string, tmpl string) error {
+	if err := c.configure(rawURL, room, events, tmpl); err != nil {
+		return err
+	}
+	if c.Provider == ChatMatrix && strings.TrimSpace(token) != "" {
+		if err := c.StoreToken(token); err != nil {
+			return err
+		}
+	}
+	return errors.Wrap(ChatIntegrations.Update(c), "failed to update chat integration")
+}
+
+// configure validates and sets the integration's destination, events and
+// template
+func (c *ChatIntegration) configure(rawURL, room string, events []string, tmpl string) error {
+	switch c.Provider {
+	case ChatSlack, ChatDiscord, ChatMatrix:
+	default:
+		return errors.New("choose Slack, Discord or Matrix")
+	}
+
+	rawURL = strings.TrimRight(strings.TrimSpace(rawURL), "/")
+	if u, err := url.Parse(rawURL); err != nil || u.Scheme != "https" || u.Host == "" {
+		if c.Provider == ChatMatrix {
+			return errors.New("the Matrix homeserver must be an https URL")
+		}
+		return errors.New("the webhook must be an https URL")
+	}
+	room = strings.TrimSpace(room)
+	if c.Provider == ChatMatrix && !strings.HasPrefix(room, "!") {
+		return errors.New("the Matrix room ID starts with !, find it in the room's settings")
+	}
+
+	for _, event := range events {
+		known := false
+		for _, e := range ChatEvents {
+			known = known || e == event
+		}
+		if !known {
+			return errors.Errorf("unknown chat event %q", event)
+		}
+	}
+
+	tmpl = strings.TrimSpace(tmpl)
+	if tmpl != "" {
+		if _, err := template.New("chat").Parse(tmpl); err != nil {
+			return errors.Wrap(err, "the message template is invalid")
+		}
+	}
+
+	c.URL = rawURL
+	c.Room = ""
+	if c.Provider == ChatMatrix {
+		c.Room = room
+	}
+	c.Events = strings.Join(events, "\n")
+	c.Template = tmpl
+	return nil
+}
+
+// RepoChatIntegrations returns a repository's chat integrations, oldest
+// first
+func RepoChatIntegrations(repoID string) ([]*ChatIntegration, error) {
+	return ChatIntegrations.Search("WHERE RepoID = ? ORDER BY CreatedAt ASC", repoID)
+}
+
+// ChatIntegrationsFor returns the repository's active chat integrations
+// that post event
+func ChatIntegrationsFor(repoID, event string) ([]*ChatIntegration, error) {
+	chats, err := ChatIntegrations.Search("WHERE RepoID = ? AND Active = ?", repoID, true)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find chat integrations")
+	}
+	var subscribed []*ChatIntegration
+	for _, chat := range chats {
+		if chat.Posts(event) {
+			subscribed = append(subscribed, chat)
+		}
+	}
+	return subscribed, nil
+}
+
+// DeleteChatIntegration removes a chat integration, its token and its
+// delivery history
+func DeleteChatIntegration(chat *ChatIntegration) error {
+	if err := ChatIntegrations.Delete(chat); err != nil {
+		return errors.Wrap(err, "failed to delete chat integration")
+	}
+	if chat.Provider == ChatMatrix {
+		DeleteSecret(chat.secretKey())
+	}
+	err := DB.Query("DELETE FROM chat_deliveries WHERE IntegrationID = ?", chat.ID).Exec()
+	return errors.Wrap(err, "failed to delete chat deliveries")
+}
+
+// DeleteRepoChatIntegrations removes a repository's chat integrations
+func DeleteRepoChatIntegrations(repoID string) {
+	chats, _ := RepoChatIntegrations(repoID)
+	for _, chat := range chats {
+		DeleteChatIntegration(chat)
+	}
+}
+
+// EventList returns the events the integration posts, empty for all
+func (c *ChatIntegration) EventList() []string {
+	return splitPolicyList(c.Events)
+}
+
+// Posts reports whether the integration posts event. Tests always go
+// through.
+func (c *ChatIntegration) Posts(event string) bool {
+	events := c.EventList()
+	if len(events) == 0 || event == ChatEventTest {
+		return true
+	}
+	for _, e := range events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// MessageTemplate returns the template of the message posted for event
+func (c *ChatIntegration) MessageTemplate(event string) string {
+	if c.Template != "" {
+		return c.Template
+	}
+	return ChatTemplates[event]
+}
+
+// secretKey is where the integration's Matrix access token is kept
+func (c *ChatIntegration) secretKey() string {
+	return "chat/" + c.ID
+}
+
+// StoreToken stores the Matrix access token the integration posts with
+func (c *ChatIntegration) StoreToken(token string) error {
+	return StoreSecret(c.secretKey(), map[string]any{"token": strings.TrimSpace(token)})
+}
+
+// Token returns the Matrix access token the integration posts with
+func (c *ChatIntegration) Token() (string, error) {
+	secret, err := Secrets.GetSecret(c.secretKey())
+	if err != nil {
+		return "", err
+	}
+	token, _ := secret["token"].(string)
+	if token == "" {
+		return "", errors.New("Matrix access token not found")
+	}
+	return token, nil
+}
+
+// Deliveries returns the integration's most recent deliveries, newest
+// first
+func (c *ChatIntegration) Deliveries(limit int) ([]*ChatDelivery, error) {
+	return ChatDeliveries.Search("WHERE IntegrationID = ? ORDER BY CreatedAt DESC LIMIT ?", c.ID, limit)
+}
+
+// RecordChatDelivery stores a delivery, notes its outcome on the
+// integration and drops the integration's oldest deliveries beyond the
+// ones kept
+func RecordChatDelivery(chat *ChatIntegration, delivery *ChatDelivery) (*ChatDelivery, error) {
+	delivery.IntegrationID = chat.ID
+	delivery.RepoID = chat.RepoID
+	delivery, err := ChatDeliveries.Insert(delivery)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to record chat delivery")
+	}
+
+	chat.LastStatus = delivery.StatusCode
+	chat.LastDeliveryAt = delivery.CreatedAt
+	if err := ChatIntegrations.Update(chat); err != nil {
+		return nil, errors.Wrap(err, "failed to update chat integration")
+	}
+
+	err = DB.Query(`DELETE FROM chat_deliveries WHERE IntegrationID = ? AND ID NOT IN
+		(SELECT ID FROM chat_deliveries WHERE IntegrationID = ? ORDER BY CreatedAt DESC LIMIT ?)`,
+		chat.ID, chat.ID, maxChatDeliveries).Exec()
+	return delivery, errors.Wrap(err, "failed to prune chat deliveries")
+}
+
+// Succeeded reports whether the chat service accepted the message
+func (d *ChatDelivery) Succeeded() bool {
+	return d.Error == "" && d.StatusCode >= 200 && d.StatusCode <= 299
+}
@@ -0,0 +1,82 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/The-Skyscape/devtools/pkg/testutils"
+)
+
+func TestChatIntegration(t *testing.T) {
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+
+	t.Run("CreateAndFilter", func(t *testing.T) {
+		all, err := CreateChatIntegration("chat-repo", ChatSlack, "https://hooks.slack.com/services/T/B/x/", "", "", nil, "", "admin")
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, "https://hooks.slack.com/services/T/B/x", all.URL)
+		testutils.AssertTrue(t, all.Posts(ChatEventPush))
+
+		merged, err := CreateChatIntegration("chat-repo", ChatDiscord, "https://discord.com/api/webhooks/1/x", "", "", []string{ChatEventPRMerged}, "", "admin")
+		testutils.AssertNoError(t, err)
+		testutils.AssertFalse(t, merged.Posts(ChatEventPush))
+		testutils.AssertTrue(t, merged.Posts(ChatEventTest))
+
+		chats, err := ChatIntegrationsFor("chat-repo", ChatEventPush)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 1, len(chats))
+
+		all.Active = false
+		testutils.AssertNoError(t, ChatIntegrations.Update(all))
+		chats, err = ChatIntegrationsFor("chat-repo", ChatEventPRMerged)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 1, len(chats))
+		testutils.AssertEqual(t, merged.ID, chats[0].ID)
+	})
+
+	t.Run("RejectsInvalidIntegrations", func(t *testing.T) {
+		_, err := CreateChatIntegration("chat-repo", "irc", "https://example.com", "", "", nil, "", "admin")
+		testutils.AssertError(t, err)
+		_, err = CreateChatIntegration("chat-repo", ChatSlack, "http://example.com", "", "", nil, "", "admin")
+		testutils.AssertError(t, err)
+		_, err = CreateChatIntegration("chat-repo", ChatSlack, "https://example.com", "", "", []string{"deploys"}, "", "admin")
+		testutils.AssertError(t, err)
+		_, err = CreateChatIntegration("chat-repo", ChatSlack, "https://example.com", "", "", nil, "{{.Repo", "admin")
+		testutils.AssertError(t, err)
+		_, err = CreateChatIntegration("chat-repo", ChatMatrix, "https://matrix.example.com", "general", "token", nil, "", "admin")
+		testutils.AssertError(t, err)
+		_, err = CreateChatIntegration("chat-repo", ChatMatrix, "https://matrix.example.com", "!room:example.com", "", nil, "", "admin")
+		testutils.AssertError(t, err)
+	})
+
+	t.Run("MessageTemplate", func(t *testing.T) {
+		chat, err := CreateChatIntegration("chat-templates", ChatSlack, "https://example.com/hook", "", "", nil, "", "admin")
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, ChatTemplates[ChatEventIssueOpened], chat.MessageTemplate(ChatEventIssueOpened))
+
+		testutils.AssertNoError(t, chat.Update(chat.URL, "", "", nil, "{{.Event}} in {{.Repo}}"))
+		testutils.AssertEqual(t, "{{.Event}} in {{.Repo}}", chat.MessageTemplate(ChatEventIssueOpened))
+	})
+
+	t.Run("RecordsAndPrunesDeliveries", func(t *testing.T) {
+		chat, err := CreateChatIntegration("chat-deliveries", ChatDiscord, "https://discord.com/api/webhooks/2/x", "", "", nil, "", "admin")
+		testutils.AssertNoError(t, err)
+
+		for i := 0; i < maxChatDeliveries+5; i++ {
+			_, err := RecordChatDelivery(chat, &ChatDelivery{Event: ChatEventPush, Message: "pushed", StatusCode: 204})
+			testutils.AssertNoError(t, err)
+		}
+		failed, err := RecordChatDelivery(chat, &ChatDelivery{Event: ChatEventPush, Error: "connection refused"})
+		testutils.AssertNoError(t, err)
+		testutils.AssertFalse(t, failed.Succeeded())
+
+		deliveries, err := chat.Deliveries(100)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, maxChatDeliveries, len(deliveries))
+		testutils.AssertEqual(t, 0, chat.LastStatus)
+
+		testutils.AssertNoError(t, DeleteChatIntegration(chat))
+		deliveries, err = chat.Deliveries(100)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 0, len(deliveries))
+	})
+}
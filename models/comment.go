@@ -16,8 +16,23 @@ type Comment struct {
 	FilePath   string // For inline code comments
 	LineNumber int    // For inline code comments (0 means not a line comment)
 	CommitSHA  string // For commit-specific comments
+
+	// Review threads on inline pull request comments
+	ParentID   string // First comment of the thread, for replies
+	LineText   string // The commented line as it read when the thread started
+	Resolved   bool   // Set on a thread's first comment
+	ResolvedBy string
+	AppliedIn  string // Commit that applied the comment's suggestion
+
+	// GitHub comment sync
+	Origin       string // "" for comments made here, "github" for imported ones
+	GitHubID     int64  // ID of the mirrored GitHub comment, 0 until mirrored
+	GitHubAuthor string // GitHub login of an imported comment's author
 }
 
+// CommentOriginGitHub marks comments imported from GitHub
+const CommentOriginGitHub = "github"
+
 func (*Comment) Table() string { return "comments" }
 
 func init() {
@@ -27,6 +42,7 @@ func init() {
 		Comments.Index("EntityID")
 		Comments.Index("AuthorID")
 		Comments.Index("RepoID")
+		Comments.Index("GitHubID")
 		Comments.Index("CreatedAt DESC")
 		// Composite index for entity lookups
 		Comments.Index("EntityType, EntityID, CreatedAt")
@@ -0,0 +1,202 @@
+package models
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/The-Skyscape/devtools/pkg/application"
+	"github.com/pkg/errors"
+)
+
+// CommitStatus is the latest state a CI system or action reported for a
+// commit under one context. Reporting again under the same context
+// replaces it.
+type CommitStatus struct {
+	application.Model
+	RepoID      string
+	CommitSHA   string
+	Context     string // What reported the status, like "ci/tests" or an action's title
+	State       string // CommitStatusPending, CommitStatusSuccess, CommitStatusFailure or CommitStatusError
+	Description string
+	TargetURL   string // Where to see the details
+	CreatorID   string
+}
+
+// Table returns the database table name
+func (*CommitStatus) Table() string { return "commit_statuses" }
+
+// Commit status states, as GitHub names them
+const (
+	CommitStatusPending = "pending"
+	CommitStatusSuccess = "success"
+	CommitStatusFailure = "failure"
+	CommitStatusError   = "error"
+)
+
+// DefaultStatusContext is the context of statuses reported without one
+const DefaultStatusContext = "default"
+
+// ResolveCommit returns the full hash of the commit a branch, tag or hash
+// names
+func (r *Repository) ResolveCommit(ref string) (string, error) {
+	if ref == "" || strings.HasPrefix(ref, "-") {
+		return "", errors.New("commit required")
+	}
+	stdout, _, err := r.Git("rev-parse", "--verify", "--quiet", ref+"^{commit}")
+	if err != nil {
+		return "", errors.Errorf("commit %s not found", ref)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// SetCommitStatus records a status on the commit ref names, replacing the
+// one already reported under the same context
+func SetCommitStatus(repo *Repository, ref string, status *CommitStatus) (*CommitStatus, error) {
+	switch status.State {
+	case CommitStatusPending, CommitStatusSuccess, CommitStatusFailure, CommitStatusError:
+	default:
+		return nil, errors.Errorf("state must be %s, %s, %s or %s, not %q",
+			CommitStatusPending, CommitStatusSuccess, CommitStatusFailure, CommitStatusError, status.State)
+	}
+	status.Context = strings.TrimSpace(status.Context)
+	if status.Context == "" {
+		status.Context = DefaultStatusContext
+	}
+	if len(status.Context) > 255 {
+		return nil, errors.New("context is longer than 255 characters")
+	}
+	if len(status.Description) > 1000 {
+		return nil, errors.New("description is longer than 1000 characters")
+	}
+	if status.TargetURL != "" {
+		// Shown as a link, so only web addresses and paths on this server
+		u, err := url.Parse(status.TargetURL)
+		web := err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+		local := err == nil && u.Scheme == "" && u.Host == "" && strings.HasPrefix(u.Path, "/")
+		if !web && !local {
+			return nil, errors.New("target URL must be an http or https address")
+		}
+	}
+
+	sha, err := repo.ResolveCommit(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := CommitStatuses.Search("WHERE RepoID = ? AND CommitSHA = ? AND Context = ?", repo.ID, sha, status.Context)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get commit statuses")
+	}
+	if len(existing) > 0 {
+		current := existing[0]
+		current.State = status.State
+		current.Description = status.Description
+		current.TargetURL = status.TargetURL
+		current.CreatorID = status.CreatorID
+		if err := CommitStatuses.Update(current); err != nil {
+			return nil, errors.Wrap(err, "failed to update commit status")
+		}
+		return current, nil
+	}
+
+	status.RepoID = repo.ID
+	status.CommitSHA = sha
+	created, err := CommitStatuses.Insert(status)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to save commit status")
+	}
+	return created, nil
+}
+
+// GetCommitStatuses returns the statuses reported for a commit, by context
+func GetCommitStatuses(repoID, sha string) ([]*CommitStatus, error) {
+	statuses, err := CommitStatuses.Search("WHERE RepoID = ? AND CommitSHA = ?", repoID, sha)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get commit statuses")
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return strings.ToLower(statuses[i].Context) < strings.ToLower(statuses[j].Context)
+	})
+	return statuses, nil
+}
+
+// CombinedStatus sums up a commit's statuses: failure if any failed or
+// errored, pending while any are, success once all succeeded, and "" when
+// nothing reported
+func CombinedStatus(statuses []*CommitStatus) string {
+	combined := ""
+	for _, status := range statuses {
+		switch status.State {
+		case CommitStatusFailure, CommitStatusError:
+			return CommitStatusFailure
+		case CommitStatusPending:
+			combined = CommitStatusPending
+		case CommitStatusSuccess:
+			if combined == "" {
+				combined = CommitStatusSuccess
+			}
+		}
+	}
+	return combined
+}
+
+// findStatus returns the status reported under a context, ignoring case
+func findStatus(statuses []*CommitStatus, context string) *CommitStatus {
+	for _, status := range statuses {
+		if strings.EqualFold(status.Context, context) {
+			return status
+		}
+	}
+	return nil
+}
+
+// Checks returns the statuses reported for the head of the pull request's
+// branch
+func (pr *PullRequest) Checks() ([]*CommitStatus, error) {
+	head, err := pr.HeadCommit()
+	if err != nil {
+		return nil, err
+	}
+	return GetCommitStatuses(pr.RepoID, head)
+}
+
+// ReportRunStatus records an action run as a status on the commit it ran
+// on, with the action's title as the context
+func ReportRunStatus(action *Action, run *ActionRun) error {
+	if run.CommitSHA == "" {
+		return nil
+	}
+	repo, err := Repositories.Get(action.RepoID)
+	if err != nil {
+		return errors.New("repository not found")
+	}
+
+	status := &CommitStatus{
+		Context:     action.Title,
+		State:       CommitStatusPending,
+		Description: "Running",
+		TargetURL:   fmt.Sprintf("/repos/%s/actions/%s/history", action.RepoID, action.ID),
+		CreatorID:   run.TriggeredBy,
+	}
+	switch run.Status {
+	case "success", "completed":
+		status.State = CommitStatusSuccess
+		status.Description = fmt.Sprintf("Passed in %ds", run.Duration)
+		if run.ExitCode != 0 {
+			status.State = CommitStatusFailure
+			status.Description = fmt.Sprintf("Exited with %d after %ds", run.ExitCode, run.Duration)
+		}
+	case "failed":
+		status.State = CommitStatusFailure
+		status.Description = fmt.Sprintf("Failed after %ds", run.Duration)
+	case ActionRunCancelled:
+		status.State = CommitStatusError
+		status.Description = fmt.Sprintf("Cancelled after %ds", run.Duration)
+	case "pending", "queued":
+		status.Description = "Queued"
+	}
+	_, err = SetCommitStatus(repo, run.CommitSHA, status)
+	return err
+}
@@ -0,0 +1,102 @@
+package models
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/The-Skyscape/devtools/pkg/testutils"
+)
+
+func TestCombinedStatus(t *testing.T) {
+	status := func(states ...string) []*CommitStatus {
+		var statuses []*CommitStatus
+		for _, state := range states {
+			statuses = append(statuses, &CommitStatus{State: state})
+		}
+		return statuses
+	}
+
+	testutils.AssertEqual(t, "", CombinedStatus(nil))
+	testutils.AssertEqual(t, CommitStatusSuccess, CombinedStatus(status("success", "success")))
+	testutils.AssertEqual(t, CommitStatusPending, CombinedStatus(status("success", "pending")))
+	testutils.AssertEqual(t, CommitStatusFailure, CombinedStatus(status("pending", "error")))
+	testutils.AssertEqual(t, CommitStatusFailure, CombinedStatus(status("failure", "success")))
+}
+
+func TestCommitStatuses(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+	t.Setenv("DATA_DIR", t.TempDir())
+
+	repo, pr, _ := conflictingRepo(t, "statuses")
+	head, err := pr.HeadCommit()
+	testutils.AssertNoError(t, err)
+
+	t.Run("Validation", func(t *testing.T) {
+		_, err := SetCommitStatus(repo, head, &CommitStatus{State: "passed"})
+		testutils.AssertError(t, err)
+		_, err = SetCommitStatus(repo, head, &CommitStatus{State: "success", TargetURL: "javascript:alert(1)"})
+		testutils.AssertError(t, err)
+		_, err = SetCommitStatus(repo, head, &CommitStatus{State: "success", Context: strings.Repeat("x", 256)})
+		testutils.AssertError(t, err)
+		_, err = SetCommitStatus(repo, "no-such-branch", &CommitStatus{State: "success"})
+		testutils.AssertError(t, err)
+	})
+
+	t.Run("ReplacesByContext", func(t *testing.T) {
+		first, err := SetCommitStatus(repo, "feature", &CommitStatus{State: "pending", Context: "ci/tests"})
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, head, first.CommitSHA)
+
+		second, err := SetCommitStatus(repo, head, &CommitStatus{
+			State: "failure", Context: "ci/tests", Description: "2 failed", TargetURL: "https://ci.example.com/1",
+		})
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, first.ID, second.ID)
+
+		_, err = SetCommitStatus(repo, head, &CommitStatus{State: "success"})
+		testutils.AssertNoError(t, err)
+
+		checks, err := pr.Checks()
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 2, len(checks))
+		testutils.AssertEqual(t, "ci/tests", checks[0].Context)
+		testutils.AssertEqual(t, "failure", checks[0].State)
+		testutils.AssertEqual(t, "2 failed", checks[0].Description)
+		testutils.AssertEqual(t, DefaultStatusContext, checks[1].Context)
+		testutils.AssertEqual(t, CommitStatusFailure, CombinedStatus(checks))
+	})
+
+	t.Run("RequiredContexts", func(t *testing.T) {
+		_, err := SaveBranchProtection(&BranchProtection{
+			RepoID: repo.ID, Pattern: "main", RequiredChecks: "CI/Tests\nci/lint",
+		}, "admin")
+		testutils.AssertNoError(t, err)
+
+		blockers, err := pr.MergeBlockers()
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 2, len(blockers))
+		testutils.AssertEqual(t, "CI/Tests failed on feature", blockers[0])
+		testutils.AssertEqual(t, "ci/lint has not passed on feature yet", blockers[1])
+
+		_, err = SetCommitStatus(repo, head, &CommitStatus{State: "success", Context: "ci/tests"})
+		testutils.AssertNoError(t, err)
+		_, err = SetCommitStatus(repo, head, &CommitStatus{State: "pending", Context: "ci/lint"})
+		testutils.AssertNoError(t, err)
+		blockers, err = pr.MergeBlockers()
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 1, len(blockers))
+		testutils.AssertEqual(t, "ci/lint has not passed on feature yet", blockers[0])
+
+		_, err = SetCommitStatus(repo, head, &CommitStatus{State: "success", Context: "ci/lint"})
+		testutils.AssertNoError(t, err)
+		blockers, err = pr.MergeBlockers()
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 0, len(blockers))
+	})
+}
@@ -16,11 +16,31 @@ type Conversation struct {
 	LastRole       string // Role of last message (user/assistant)
 	WorkingContext string // JSON context for tracking state between messages
 	Settings       string // JSON settings for conversation behavior
+	ModelName      string // Ollama model for this conversation (empty uses the workspace default)
+	RepoID         string // Repository the conversation is bound to (empty for the global AI panel)
 }
 
 // Table returns the database table name
 func (*Conversation) Table() string { return "conversations" }
 
+// Repository returns the repository the conversation is bound to
+func (c *Conversation) Repository() (*Repository, error) {
+	return Repositories.Get(c.RepoID)
+}
+
+// GetRepoConversation returns the user's most recently active conversation
+// bound to the repository, nil if there is none
+func GetRepoConversation(userID, repoID string) (*Conversation, error) {
+	conversations, err := Conversations.Search("WHERE UserID = ? AND RepoID = ? ORDER BY UpdatedAt DESC LIMIT 1", userID, repoID)
+	if err != nil {
+		return nil, err
+	}
+	if len(conversations) == 0 {
+		return nil, nil
+	}
+	return conversations[0], nil
+}
+
 // GetMessages returns all messages for this conversation
 func (c *Conversation) GetMessages() ([]*Message, error) {
 	return Messages.Search("WHERE ConversationID = ? ORDER BY CreatedAt ASC", c.ID)
@@ -127,3 +147,32 @@ func (c *Conversation) GetSettings() map[string]any {
 	}
 	return settings
 }
+
+// UpdateSetting updates a key in the conversation settings
+func (c *Conversation) UpdateSetting(key string, value any) error {
+	settings := c.GetSettings()
+	settings[key] = value
+
+	settingsJSON, err := json.Marshal(settings)
+	if err != nil {
+		return err
+	}
+
+	c.Settings = string(settingsJSON)
+	c.UpdatedAt = time.Now()
+	return Conversations.Update(c)
+}
+
+// RetrievalEnabled returns whether code is retrieved for the conversation's
+// questions, which is on unless the user turned it off
+func (c *Conversation) RetrievalEnabled() bool {
+	enabled, ok := c.GetSettings()["retrieval"].(bool)
+	return !ok || enabled
+}
+
+// CacheEnabled returns whether repeated questions may be answered from the
+// response cache, which is on unless the user bypassed it
+func (c *Conversation) CacheEnabled() bool {
+	enabled, ok := c.GetSettings()["cache"].(bool)
+	return !ok || enabled
+}
@@ -0,0 +1,241 @@
+package models
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// ConversationExportFormat identifies JSON produced by Conversation.Export
+	ConversationExportFormat = "skyscape-conversation"
+
+	// ConversationExportVersion is bumped when the JSON layout changes
+	ConversationExportVersion = 1
+)
+
+// ConversationExport is the portable JSON form of a conversation, used to
+// archive agent sessions and to re-import them later
+type ConversationExport struct {
+	Format         string            `json:"format"`
+	Version        int               `json:"version"`
+	Title          string            `json:"title"`
+	ModelName      string            `json:"model,omitempty"`
+	WorkingContext map[string]any    `json:"working_context,omitempty"`
+	ExportedAt     time.Time         `json:"exported_at"`
+	Messages       []ExportedMessage `json:"messages"`
+}
+
+// ExportedMessage is a single message in a ConversationExport
+type ExportedMessage struct {
+	Role      string          `json:"role"`
+	Content   string          `json:"content"`
+	ToolName  string          `json:"tool_name,omitempty"`
+	Metadata  string          `json:"metadata,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+	Images    []ExportedImage `json:"images,omitempty"`
+}
+
+// ExportedImage carries an attached screenshot inline
+type ExportedImage struct {
+	Filename string `json:"filename"`
+	Data     string `json:"data"` // Base64 encoded
+}
+
+// Export returns the conversation and its messages in portable form
+func (c *Conversation) Export() (*ConversationExport, error) {
+	messages, err := c.GetMessages()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load messages")
+	}
+
+	export := &ConversationExport{
+		Format:         ConversationExportFormat,
+		Version:        ConversationExportVersion,
+		Title:          c.Title,
+		ModelName:      c.ModelName,
+		WorkingContext: c.GetWorkingContext(),
+		ExportedAt:     time.Now(),
+		Messages:       make([]ExportedMessage, 0, len(messages)),
+	}
+	for _, msg := range messages {
+		exported := ExportedMessage{
+			Role:      msg.Role,
+			Content:   msg.Content,
+			ToolName:  msg.ToolName,
+			Metadata:  msg.Metadata,
+			CreatedAt: msg.CreatedAt,
+		}
+		for _, image := range msg.Images() {
+			data, err := image.Read()
+			if err != nil {
+				continue
+			}
+			exported.Images = append(exported.Images, ExportedImage{
+				Filename: image.Filename,
+				Data:     base64.StdEncoding.EncodeToString(data),
+			})
+		}
+		export.Messages = append(export.Messages, exported)
+	}
+	return export, nil
+}
+
+// Markdown returns a readable transcript of the conversation. Tool output and
+// the model's reasoning are folded into collapsible sections.
+func (c *Conversation) Markdown() (string, error) {
+	messages, err := c.GetMessages()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to load messages")
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", c.Title)
+	fmt.Fprintf(&b, "_Exported %s", time.Now().Format("Jan 2, 2006 3:04 PM"))
+	if c.ModelName != "" {
+		fmt.Fprintf(&b, " · Model: %s", c.ModelName)
+	}
+	b.WriteString("_\n")
+
+	for _, msg := range messages {
+		stamp := msg.CreatedAt.Format("Jan 2, 3:04 PM")
+		switch msg.Role {
+		case MessageRoleUser, MessageRoleAssistant:
+			speaker := "User"
+			if msg.Role == MessageRoleAssistant {
+				speaker = "Assistant"
+			}
+			fmt.Fprintf(&b, "\n---\n\n### %s · %s\n\n%s\n", speaker, stamp, strings.TrimSpace(msg.Content))
+			if images := msg.Images(); len(images) > 0 {
+				names := make([]string, len(images))
+				for i, image := range images {
+					names[i] = image.Filename
+				}
+				fmt.Fprintf(&b, "\n_Attached: %s_\n", strings.Join(names, ", "))
+			}
+		case MessageRoleTool:
+			name := msg.ToolName
+			if name == "" {
+				name = "tool"
+			}
+			fmt.Fprintf(&b, "\n<details>\n<summary>Tool: %s</summary>\n\n%s\n\n</details>\n", name, fencedBlock(msg.Content))
+		case MessageRoleThinking:
+			fmt.Fprintf(&b, "\n<details>\n<summary>Thinking</summary>\n\n%s\n\n</details>\n", strings.TrimSpace(msg.Content))
+		case MessageRolePlan:
+			fmt.Fprintf(&b, "\n**Plan**\n\n%s\n", strings.TrimSpace(msg.Content))
+		case MessageRoleError:
+			fmt.Fprintf(&b, "\n> **Error:** %s\n", strings.TrimSpace(msg.Content))
+		}
+		// Status and system messages are progress noise and are left out
+	}
+	return b.String(), nil
+}
+
+// ImportConversation creates a conversation for the user from JSON produced
+// by Export. Messages keep their order; timestamps are those of the import.
+func ImportConversation(userID string, data []byte) (*Conversation, error) {
+	var export ConversationExport
+	if err := json.Unmarshal(data, &export); err != nil {
+		return nil, errors.Wrap(err, "not a valid conversation export")
+	}
+	if export.Format != ConversationExportFormat {
+		return nil, errors.New("not a conversation export: the file must be a JSON export from the AI panel")
+	}
+	if export.Version < 1 || export.Version > ConversationExportVersion {
+		return nil, errors.Errorf("unsupported export version %d", export.Version)
+	}
+	for i, msg := range export.Messages {
+		if !isExportableRole(msg.Role) {
+			return nil, errors.Errorf("message %d has unknown role %q", i+1, msg.Role)
+		}
+		for _, image := range msg.Images {
+			if _, err := base64.StdEncoding.DecodeString(image.Data); err != nil {
+				return nil, errors.Errorf("message %d has an invalid image %s", i+1, image.Filename)
+			}
+		}
+	}
+
+	title := strings.TrimSpace(export.Title)
+	if title == "" {
+		title = "Imported Conversation"
+	}
+	conversation := &Conversation{
+		UserID:    userID,
+		Title:     title,
+		ModelName: export.ModelName,
+	}
+	if len(export.WorkingContext) > 0 {
+		contextJSON, _ := json.Marshal(export.WorkingContext)
+		conversation.WorkingContext = string(contextJSON)
+	}
+	conversation, err := Conversations.Insert(conversation)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create conversation")
+	}
+
+	var last *Message
+	for _, exported := range export.Messages {
+		msg, err := Messages.Insert(&Message{
+			ConversationID: conversation.ID,
+			Role:           exported.Role,
+			Content:        exported.Content,
+			ToolName:       exported.ToolName,
+			Metadata:       exported.Metadata,
+		})
+		if err != nil {
+			discardConversation(conversation)
+			return nil, errors.Wrap(err, "failed to import message")
+		}
+		for _, image := range exported.Images {
+			data, _ := base64.StdEncoding.DecodeString(image.Data)
+			if _, err := msg.AttachImage(image.Filename, bytes.NewReader(data), userID); err != nil {
+				discardConversation(conversation)
+				return nil, errors.Wrapf(err, "failed to import image %s", image.Filename)
+			}
+		}
+		if msg.Role == MessageRoleUser || msg.Role == MessageRoleAssistant {
+			last = msg
+		}
+	}
+
+	if last != nil {
+		if err := conversation.UpdateLastMessage(last.Content, last.Role); err != nil {
+			return nil, errors.Wrap(err, "failed to update conversation")
+		}
+	}
+	return conversation, nil
+}
+
+// discardConversation removes a partially imported conversation
+func discardConversation(c *Conversation) {
+	messages, _ := c.GetMessages()
+	for _, msg := range messages {
+		DeleteAttachments("message", msg.ID)
+		Messages.Delete(msg)
+	}
+	Conversations.Delete(c)
+}
+
+// isExportableRole returns true for the message roles a conversation stores
+func isExportableRole(role string) bool {
+	switch role {
+	case MessageRoleUser, MessageRoleAssistant, MessageRoleTool, MessageRoleError,
+		MessageRoleSystem, MessageRoleThinking, MessageRoleStatus, MessageRolePlan:
+		return true
+	}
+	return false
+}
+
+// fencedBlock wraps text in a code fence longer than any it contains
+func fencedBlock(text string) string {
+	fence := "```"
+	for strings.Contains(text, fence) {
+		fence += "`"
+	}
+	return fence + "\n" + strings.TrimRight(text, "\n") + "\n" + fence
+}
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/The-Skyscape/devtools/pkg/testutils"
+)
+
+func TestConversationExport(t *testing.T) {
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+	t.Setenv("DATA_DIR", t.TempDir())
+
+	user := CreateTestUser(t, db, "archivist@example.com")
+	conversation, err := Conversations.Insert(&Conversation{UserID: user.ID, Title: "Fix the flaky test", ModelName: "gpt-oss"})
+	testutils.AssertNoError(t, err)
+
+	messages := []*Message{
+		{Role: MessageRoleUser, Content: "Why does TestSync fail?"},
+		{Role: MessageRoleStatus, Content: "Reading files..."},
+		{Role: MessageRoleTool, ToolName: "read_file", Content: "```go\nfunc TestSync(t *testing.T) {}\n```"},
+		{Role: MessageRoleAssistant, Content: "It depends on wall-clock time."},
+	}
+	for i, msg := range messages {
+		msg.ConversationID = conversation.ID
+		messages[i], err = Messages.Insert(msg)
+		testutils.AssertNoError(t, err)
+	}
+	png := append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 64)...)
+	_, err = messages[0].AttachImage("failure.png", bytes.NewReader(png), user.ID)
+	testutils.AssertNoError(t, err)
+	conversation.UpdateWorkingContext("current_repo", "repo-1")
+
+	t.Run("Markdown", func(t *testing.T) {
+		transcript, err := conversation.Markdown()
+		testutils.AssertNoError(t, err)
+		testutils.AssertTrue(t, strings.HasPrefix(transcript, "# Fix the flaky test\n"))
+		testutils.AssertTrue(t, strings.Contains(transcript, "### User"))
+		testutils.AssertTrue(t, strings.Contains(transcript, "_Attached: failure.png_"))
+		testutils.AssertTrue(t, strings.Contains(transcript, "<summary>Tool: read_file</summary>"))
+		testutils.AssertTrue(t, strings.Contains(transcript, "````\n```go"))
+		testutils.AssertFalse(t, strings.Contains(transcript, "Reading files..."))
+	})
+
+	t.Run("RoundTrip", func(t *testing.T) {
+		export, err := conversation.Export()
+		testutils.AssertNoError(t, err)
+		data, err := json.Marshal(export)
+		testutils.AssertNoError(t, err)
+
+		imported, err := ImportConversation(user.ID, data)
+		testutils.AssertNoError(t, err)
+		testutils.AssertTrue(t, imported.ID != conversation.ID)
+		testutils.AssertEqual(t, "Fix the flaky test", imported.Title)
+		testutils.AssertEqual(t, "gpt-oss", imported.ModelName)
+		testutils.AssertEqual(t, "repo-1", imported.GetWorkingContext()["current_repo"])
+		testutils.AssertEqual(t, MessageRoleAssistant, imported.LastRole)
+
+		copied, err := imported.GetMessages()
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, len(messages), len(copied))
+		testutils.AssertEqual(t, "read_file", copied[2].ToolName)
+		testutils.AssertEqual(t, 1, copied[0].ImageCount)
+		testutils.AssertEqual(t, 1, len(copied[0].Images()))
+	})
+
+	t.Run("RejectsOtherFiles", func(t *testing.T) {
+		_, err := ImportConversation(user.ID, []byte(`{"title": "not an export"}`))
+		testutils.AssertError(t, err)
+
+		_, err = ImportConversation(user.ID, []byte(`{"format": "skyscape-conversation", "version": 1, "messages": [{"role": "root"}]}`))
+		testutils.AssertError(t, err)
+
+		_, err = ImportConversation(user.ID, []byte("# Just markdown"))
+		testutils.AssertError(t, err)
+	})
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/The-Skyscape/devtools/pkg/testutils"
+)
+
+func TestGetRepoConversation(t *testing.T) {
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+
+	user := CreateTestUser(t, db, "chatter@example.com")
+	repo := createTestRepository(t, "chat-repo", user.ID)
+
+	conversation, err := GetRepoConversation(user.ID, repo.ID)
+	testutils.AssertNoError(t, err)
+	testutils.AssertTrue(t, conversation == nil)
+
+	_, err = Conversations.Insert(&Conversation{UserID: user.ID, Title: "Global"})
+	testutils.AssertNoError(t, err)
+	bound, err := Conversations.Insert(&Conversation{UserID: user.ID, Title: "About the repo", RepoID: repo.ID})
+	testutils.AssertNoError(t, err)
+
+	conversation, err = GetRepoConversation(user.ID, repo.ID)
+	testutils.AssertNoError(t, err)
+	testutils.AssertEqual(t, bound.ID, conversation.ID)
+
+	found, err := conversation.Repository()
+	testutils.AssertNoError(t, err)
+	testutils.AssertEqual(t, "chat-repo", found.Name)
+
+	other := CreateTestUser(t, db, "other@example.com")
+	conversation, err = GetRepoConversation(other.ID, repo.ID)
+	testutils.AssertNoError(t, err)
+	testutils.AssertTrue(t, conversation == nil)
+}
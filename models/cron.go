@@ -0,0 +1,193 @@
+package models
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// CronSchedule is a parsed five-field cron expression: minute, hour, day
+// of month, month and day of week
+type CronSchedule struct {
+	Expr    string
+	minute  uint64
+	hour    uint64
+	dom     uint64
+	month   uint64
+	dow     uint64
+	anyDay  bool // Day of month is *, so only day of week restricts days
+	anyWeek bool // Day of week is *, so only day of month restricts days
+}
+
+// cronDescriptors are the shorthands cron accepts for common schedules
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// cronField is the range and names one field of an expression accepts
+type cronField struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var cronFields = []cronField{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}},
+	// 7 is also Sunday
+	{name: "day of week", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}},
+}
+
+// ParseCron parses a cron expression like "30 2 * * 1-5", with lists,
+// ranges, steps, month and weekday names, and descriptors like @daily.
+// As in cron, a day matches when either the day of month or the day of
+// week does, unless one of them is *.
+func ParseCron(expr string) (*CronSchedule, error) {
+	expr = strings.TrimSpace(expr)
+	spec := expr
+	if strings.HasPrefix(spec, "@") {
+		var ok bool
+		if spec, ok = cronDescriptors[strings.ToLower(spec)]; !ok {
+			return nil, errors.Errorf("unknown schedule %q", expr)
+		}
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != len(cronFields) {
+		return nil, errors.Errorf("schedule %q needs 5 fields: minute, hour, day of month, month and day of week", expr)
+	}
+
+	var bits [5]uint64
+	for i, field := range fields {
+		var err error
+		if bits[i], err = cronFields[i].parse(field); err != nil {
+			return nil, errors.Wrapf(err, "schedule %q", expr)
+		}
+	}
+	if bits[4]&(1<<7) != 0 {
+		bits[4] |= 1
+	}
+
+	return &CronSchedule{
+		Expr:    expr,
+		minute:  bits[0],
+		hour:    bits[1],
+		dom:     bits[2],
+		month:   bits[3],
+		dow:     bits[4],
+		anyDay:  fields[2] == "*" || fields[2] == "?",
+		anyWeek: fields[4] == "*" || fields[4] == "?",
+	}, nil
+}
+
+// parse returns the values a field's list of ranges covers, as bits
+func (f cronField) parse(field string) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, stepText, stepped := strings.Cut(part, "/")
+		step := 1
+		if stepped {
+			var err error
+			if step, err = strconv.Atoi(stepText); err != nil || step < 1 {
+				return 0, errors.Errorf("invalid step %q in %s", stepText, f.name)
+			}
+		}
+
+		low, high := f.min, f.max
+		if rng != "*" && rng != "?" {
+			lowText, highText, isRange := strings.Cut(rng, "-")
+			var err error
+			if low, err = f.value(lowText); err != nil {
+				return 0, err
+			}
+			high = low
+			if isRange {
+				if high, err = f.value(highText); err != nil {
+					return 0, err
+				}
+			} else if stepped {
+				high = f.max
+			}
+			if high < low {
+				return 0, errors.Errorf("range %q in %s runs backwards", rng, f.name)
+			}
+		}
+
+		for v := low; v <= high; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+// value parses a number or name within the field's range
+func (f cronField) value(text string) (int, error) {
+	if v, ok := f.names[strings.ToLower(text)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(text)
+	if err != nil || v < f.min || v > f.max {
+		return 0, errors.Errorf("%q is not a valid %s, use %d-%d", text, f.name, f.min, f.max)
+	}
+	return v, nil
+}
+
+// Matches reports whether the schedule fires in the minute t falls in
+func (s *CronSchedule) Matches(t time.Time) bool {
+	return s.minute&(1<<t.Minute()) != 0 && s.hour&(1<<t.Hour()) != 0 &&
+		s.month&(1<<int(t.Month())) != 0 && s.matchesDay(t)
+}
+
+// matchesDay reports whether the schedule fires on t's day at all
+func (s *CronSchedule) matchesDay(t time.Time) bool {
+	inMonth := s.dom&(1<<t.Day()) != 0
+	inWeek := s.dow&(1<<int(t.Weekday())) != 0
+	switch {
+	case s.anyDay && s.anyWeek:
+		return true
+	case s.anyDay:
+		return inWeek
+	case s.anyWeek:
+		return inMonth
+	default:
+		return inMonth || inWeek
+	}
+}
+
+// Next returns the first time after from the schedule fires, or the zero
+// time if it never does, like on February 30th
+func (s *CronSchedule) Next(from time.Time) time.Time {
+	loc := from.Location()
+	t := from.Truncate(time.Minute).Add(time.Minute)
+	// Any schedule that can fire does so within a leap year cycle
+	for end := t.AddDate(5, 0, 0); t.Before(end); {
+		switch {
+		case s.month&(1<<int(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		case !s.matchesDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		case s.hour&(1<<t.Hour()) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+		case s.minute&(1<<t.Minute()) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
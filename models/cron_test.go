@@ -0,0 +1,74 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/The-Skyscape/devtools/pkg/testutils"
+)
+
+func TestParseCron(t *testing.T) {
+	t.Run("Valid", func(t *testing.T) {
+		for _, expr := range []string{
+			"* * * * *", "*/15 * * * *", "0 9-17 * * mon-fri", "30 2 1,15 * *",
+			"0 0 * jan,jul 0", "0 12 * * 7", "@daily", "@HOURLY",
+		} {
+			_, err := ParseCron(expr)
+			testutils.AssertNoError(t, err)
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		for _, expr := range []string{
+			"", "* * * *", "* * * * * *", "60 * * * *", "* 24 * * *", "* * 0 * *",
+			"* * * 13 *", "* * * * 8", "5-1 * * * *", "*/0 * * * *", "@often", "one * * * *",
+		} {
+			_, err := ParseCron(expr)
+			testutils.AssertError(t, err)
+		}
+	})
+}
+
+func TestCronScheduleMatches(t *testing.T) {
+	at := func(value string) time.Time {
+		parsed, err := time.Parse("2006-01-02 15:04", value)
+		testutils.AssertNoError(t, err)
+		return parsed
+	}
+	matches := func(expr, value string) bool {
+		schedule, err := ParseCron(expr)
+		testutils.AssertNoError(t, err)
+		return schedule.Matches(at(value))
+	}
+
+	// 2025-06-02 is a Monday
+	testutils.AssertTrue(t, matches("*/15 * * * *", "2025-06-02 10:45"))
+	testutils.AssertFalse(t, matches("*/15 * * * *", "2025-06-02 10:46"))
+	testutils.AssertTrue(t, matches("0 9-17 * * mon-fri", "2025-06-02 17:00"))
+	testutils.AssertFalse(t, matches("0 9-17 * * mon-fri", "2025-06-01 12:00"))
+	testutils.AssertTrue(t, matches("0 0 * * 7", "2025-06-01 00:00"))
+	testutils.AssertTrue(t, matches("@monthly", "2025-06-01 00:00"))
+	testutils.AssertFalse(t, matches("@monthly", "2025-06-02 00:00"))
+
+	// Either the day of month or the day of week is enough when both are set
+	testutils.AssertTrue(t, matches("0 0 13 * fri", "2025-06-13 00:00"))
+	testutils.AssertTrue(t, matches("0 0 13 * fri", "2025-06-06 00:00"))
+	testutils.AssertTrue(t, matches("0 0 13 * fri", "2025-07-13 00:00"))
+	testutils.AssertFalse(t, matches("0 0 13 * fri", "2025-06-07 00:00"))
+}
+
+func TestCronScheduleNext(t *testing.T) {
+	from := time.Date(2025, 6, 2, 10, 44, 30, 0, time.UTC)
+	next := func(expr string) time.Time {
+		schedule, err := ParseCron(expr)
+		testutils.AssertNoError(t, err)
+		return schedule.Next(from)
+	}
+
+	testutils.AssertEqual(t, time.Date(2025, 6, 2, 10, 45, 0, 0, time.UTC), next("*/15 * * * *"))
+	testutils.AssertEqual(t, time.Date(2025, 6, 3, 0, 0, 0, 0, time.UTC), next("@daily"))
+	testutils.AssertEqual(t, time.Date(2025, 6, 7, 8, 30, 0, 0, time.UTC), next("30 8 * * sat"))
+	testutils.AssertEqual(t, time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), next("@yearly"))
+	testutils.AssertEqual(t, time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC), next("0 0 29 2 *"))
+	testutils.AssertTrue(t, next("0 0 30 2 *").IsZero())
+}
@@ -0,0 +1,240 @@
+package models
+
+import (
+	"fmt"
+	"log"
+
+	"workspace/internal/references"
+
+	"github.com/The-Skyscape/devtools/pkg/application"
+	"github.com/pkg/errors"
+)
+
+// Things that mention issues and pull requests, and things they mention
+const (
+	RefIssue       = "issue"
+	RefPullRequest = "pr"
+	RefComment     = "comment"
+	RefCommit      = "commit"
+	RefMessage     = "message"
+)
+
+// CrossReference records that an issue, pull request, comment, commit or
+// assistant message mentions an issue or pull request of its repository,
+// so the mentioned one can list where it came up
+type CrossReference struct {
+	application.Model
+	RepoID     string
+	SourceType string // RefIssue, RefPullRequest, RefComment, RefCommit or RefMessage
+	SourceID   string // Full SHA for commits
+	TargetType string // RefIssue or RefPullRequest
+	TargetID   string
+	Closes     bool // Mentioned with a closing keyword, as in "fixes #12"
+	AuthorID   string
+}
+
+// Table returns the database table name
+func (*CrossReference) Table() string { return "cross_references" }
+
+// RecordReferences replaces the references a source makes in its text.
+// Mentions that don't name an issue or pull request of the repository,
+// and mentions of the source itself, aren't recorded.
+func RecordReferences(repoID, sourceType, sourceID, text, authorID string) ([]*CrossReference, error) {
+	if err := DB.Query("DELETE FROM cross_references WHERE SourceType = ? AND SourceID = ?", sourceType, sourceID).Exec(); err != nil {
+		return nil, errors.Wrap(err, "failed to clear references")
+	}
+
+	var recorded []*CrossReference
+	seen := map[string]*CrossReference{}
+	for _, ref := range references.Parse(text) {
+		kind, id := ResolveReference(repoID, ref)
+		if kind == "" || (kind == sourceType && id == sourceID) {
+			continue
+		}
+		if existing := seen[kind+id]; existing != nil {
+			if ref.Closes && !existing.Closes {
+				existing.Closes = true
+				CrossReferences.Update(existing)
+			}
+			continue
+		}
+
+		reference, err := CrossReferences.Insert(&CrossReference{
+			Model:      DB.NewModel(""),
+			RepoID:     repoID,
+			SourceType: sourceType,
+			SourceID:   sourceID,
+			TargetType: kind,
+			TargetID:   id,
+			Closes:     ref.Closes && kind == RefIssue,
+			AuthorID:   authorID,
+		})
+		if err != nil {
+			return recorded, errors.Wrap(err, "failed to record reference")
+		}
+		seen[kind+id] = reference
+		recorded = append(recorded, reference)
+	}
+	return recorded, nil
+}
+
+// RecordCommitReferences records the references in the messages of pushed
+// or merged commits
+func RecordCommitReferences(repoID string, commits []*Commit, authorID string) {
+	for _, commit := range commits {
+		if _, err := RecordReferences(repoID, RefCommit, commit.Hash, commit.Message, authorID); err != nil {
+			log.Printf("Failed to record references of commit %s: %v", commit.ShortHash, err)
+		}
+	}
+}
+
+// ResolveReference returns the issue or pull request of the repository a
+// mention names. Hash mentions name an issue, or a pull request when no
+// issue has the ID. Commit mentions don't resolve here, see
+// ReferenceResolver.
+func ResolveReference(repoID string, ref references.Ref) (kind, id string) {
+	if ref.Kind == references.Issue {
+		if issue, err := Issues.Get(ref.ID); err == nil && issue.RepoID == repoID {
+			return RefIssue, issue.ID
+		}
+	}
+	if ref.Kind == references.Issue || ref.Kind == references.PullRequest {
+		if pr, err := PullRequests.Get(ref.ID); err == nil && pr.RepoID == repoID {
+			return RefPullRequest, pr.ID
+		}
+	}
+	return "", ""
+}
+
+// ReferenceResolver returns the links of mentions in Markdown rendered
+// for a repository. Commits must exist in the repository to be linked.
+func ReferenceResolver(repo *Repository) references.Resolver {
+	urls := map[references.Ref]string{}
+	return func(ref references.Ref) string {
+		ref.Closes, ref.Start, ref.End = false, 0, 0
+		if url, ok := urls[ref]; ok {
+			return url
+		}
+
+		var url string
+		if ref.Kind == references.Commit {
+			if commit, err := repo.GetCommit(ref.ID); err == nil {
+				url = "/repos/" + repo.ID + "/commits/" + commit.Hash + "/diff"
+			}
+		} else if kind, id := ResolveReference(repo.ID, ref); kind == RefIssue {
+			url = "/repos/" + repo.ID + "/issues/" + id
+		} else if kind == RefPullRequest {
+			url = "/repos/" + repo.ID + "/prs/" + id
+		}
+		urls[ref] = url
+		return url
+	}
+}
+
+// GetReferencesTo returns where an issue or pull request was mentioned,
+// newest first
+func GetReferencesTo(targetType, targetID string) ([]*CrossReference, error) {
+	return CrossReferences.Search("WHERE TargetType = ? AND TargetID = ? ORDER BY CreatedAt DESC", targetType, targetID)
+}
+
+// GetReferencesFrom returns the issues and pull requests a source mentions
+func GetReferencesFrom(sourceType, sourceID string) ([]*CrossReference, error) {
+	return CrossReferences.Search("WHERE SourceType = ? AND SourceID = ? ORDER BY CreatedAt ASC", sourceType, sourceID)
+}
+
+// SourceTitle describes where the reference was made
+func (r *CrossReference) SourceTitle() string {
+	switch r.SourceType {
+	case RefIssue:
+		if issue, err := Issues.Get(r.SourceID); err == nil {
+			return fmt.Sprintf("#%s %s", issue.ID, issue.Title)
+		}
+	case RefPullRequest:
+		if pr, err := PullRequests.Get(r.SourceID); err == nil {
+			return fmt.Sprintf("!%s %s", pr.ID, pr.Title)
+		}
+	case RefComment:
+		if comment, err := Comments.Get(r.SourceID); err == nil {
+			if comment.EntityType == RefPullRequest {
+				return "Comment on !" + comment.EntityID
+			}
+			return "Comment on #" + comment.EntityID
+		}
+	case RefCommit:
+		return "Commit " + references.Ref{Kind: references.Commit, ID: r.SourceID}.Text()
+	case RefMessage:
+		return "AI assistant conversation"
+	}
+	return "Deleted " + r.SourceType
+}
+
+// SourceURL returns the page of where the reference was made, empty when
+// it has none
+func (r *CrossReference) SourceURL() string {
+	switch r.SourceType {
+	case RefIssue:
+		return "/repos/" + r.RepoID + "/issues/" + r.SourceID
+	case RefPullRequest:
+		return "/repos/" + r.RepoID + "/prs/" + r.SourceID
+	case RefComment:
+		if comment, err := Comments.Get(r.SourceID); err == nil {
+			if comment.EntityType == RefPullRequest {
+				return "/repos/" + r.RepoID + "/prs/" + comment.EntityID
+			}
+			return "/repos/" + r.RepoID + "/issues/" + comment.EntityID
+		}
+	case RefCommit:
+		return "/repos/" + r.RepoID + "/commits/" + r.SourceID + "/diff"
+	}
+	return ""
+}
+
+// CloseFixedIssues closes the open issues of a repository that texts
+// mention with a closing keyword, like the description of a merged pull
+// request or the messages of commits reaching the default branch. Each
+// gets a comment naming what closed it, such as "!7" or a commit SHA.
+func CloseFixedIssues(repoID, closedBy, userID string, texts ...string) []*Issue {
+	var closed []*Issue
+	for _, text := range texts {
+		for _, ref := range references.Parse(text) {
+			if !ref.Closes {
+				continue
+			}
+			kind, id := ResolveReference(repoID, ref)
+			if kind != RefIssue {
+				continue
+			}
+			issue, err := Issues.Get(id)
+			if err != nil || issue.Status == IssueStatusClosed {
+				continue
+			}
+
+			issue.Status = IssueStatusClosed
+			if err := Issues.Update(issue); err != nil {
+				log.Printf("Failed to close issue %s: %v", issue.ID, err)
+				continue
+			}
+			CreateComment(RefIssue, issue.ID, repoID, userID, "Closed by "+closedBy+".")
+			LogActivity("issue_closed", "Closed issue: "+issue.Title, "Closed by "+closedBy, userID, repoID, "issue", issue.ID)
+			RunProjectRules("issue_closed", repoID, issue.ID, "")
+			closed = append(closed, issue)
+		}
+	}
+	return closed
+}
+
+// CloseIssuesFixedBy records the references in the commits of a merged
+// pull request, then closes the issues the pull request or its commits fix
+func CloseIssuesFixedBy(pr *PullRequest, commits []*Commit, userID string) []*Issue {
+	RecordCommitReferences(pr.RepoID, commits, userID)
+	texts := []string{pr.Title, pr.Body}
+	for _, commit := range commits {
+		texts = append(texts, commit.Message)
+	}
+	return CloseFixedIssues(pr.RepoID, "!"+pr.ID, userID, texts...)
+}
+
+// DeleteRepoReferences removes the references made in a repository
+func DeleteRepoReferences(repoID string) {
+	DB.Query("DELETE FROM cross_references WHERE RepoID = ?", repoID).Exec()
+}
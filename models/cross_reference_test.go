@@ -0,0 +1,61 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/The-Skyscape/devtools/pkg/testutils"
+)
+
+func TestCrossReferences(t *testing.T) {
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+
+	user := CreateTestUser(t, db, "refs@example.com")
+	repo := createTestRepository(t, "refs-repo", user.ID)
+	other := createTestRepository(t, "refs-other", user.ID)
+
+	issue, err := CreateIssue("Crash on save", "", user.ID, repo.ID)
+	testutils.AssertNoError(t, err)
+	elsewhere, err := CreateIssue("Elsewhere", "", user.ID, other.ID)
+	testutils.AssertNoError(t, err)
+	pr, err := PullRequests.Insert(&PullRequest{Title: "Save fix", RepoID: repo.ID, AuthorID: user.ID, Status: "open"})
+	testutils.AssertNoError(t, err)
+
+	t.Run("RecordReferences", func(t *testing.T) {
+		text := "Fixes #" + issue.ID + ", see #" + issue.ID + " and !" + pr.ID + ", not #" + elsewhere.ID
+		refs, err := RecordReferences(repo.ID, RefPullRequest, pr.ID, text, user.ID)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 1, len(refs))
+		testutils.AssertEqual(t, RefIssue, refs[0].TargetType)
+		testutils.AssertTrue(t, refs[0].Closes)
+
+		// Hash mentions fall back to pull requests
+		_, err = RecordReferences(repo.ID, RefComment, "comment-1", "Same as #"+pr.ID, user.ID)
+		testutils.AssertNoError(t, err)
+		to, err := GetReferencesTo(RefPullRequest, pr.ID)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 1, len(to))
+		testutils.AssertEqual(t, RefComment, to[0].SourceType)
+	})
+
+	t.Run("RecordReferencesReplaces", func(t *testing.T) {
+		_, err := RecordReferences(repo.ID, RefPullRequest, pr.ID, "No longer related", user.ID)
+		testutils.AssertNoError(t, err)
+		from, err := GetReferencesFrom(RefPullRequest, pr.ID)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 0, len(from))
+	})
+
+	t.Run("CloseFixedIssues", func(t *testing.T) {
+		closed := CloseFixedIssues(repo.ID, "!"+pr.ID, user.ID, "Mentions #"+issue.ID)
+		testutils.AssertEqual(t, 0, len(closed))
+
+		closed = CloseFixedIssues(repo.ID, "!"+pr.ID, user.ID, "Resolves #"+issue.ID, "fixes #"+elsewhere.ID)
+		testutils.AssertEqual(t, 1, len(closed))
+		testutils.AssertEqual(t, IssueStatusClosed, closed[0].Status)
+
+		// Closed issues stay closed without another comment
+		closed = CloseFixedIssues(repo.ID, "!"+pr.ID, user.ID, "Fixes #"+issue.ID)
+		testutils.AssertEqual(t, 0, len(closed))
+	})
+}
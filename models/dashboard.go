@@ -0,0 +1,183 @@
+package models
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+
+	"github.com/The-Skyscape/devtools/pkg/application"
+	"github.com/pkg/errors"
+)
+
+// MaxDashboardWidgets keeps a dashboard's polling load reasonable
+const MaxDashboardWidgets = 24
+
+// Dashboard is an admin-composed monitoring page. Its widgets are stored in
+// display order as JSON in Layout.
+type Dashboard struct {
+	application.Model
+	Name        string
+	Description string
+	Layout      string
+	CreatedBy   string
+	UpdatedBy   string
+}
+
+// Table returns the database table name
+func (*Dashboard) Table() string { return "dashboards" }
+
+// DashboardWidget is one tile on a dashboard
+type DashboardWidget struct {
+	ID     string `json:"id"`
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Width  int    `json:"width"`            // Grid columns spanned, 1 to 3
+	Metric string `json:"metric,omitempty"` // Metric charts only
+	Window int    `json:"window,omitempty"` // Minutes of history for metric charts
+}
+
+// DashboardWidgetType describes a kind of widget admins can add
+type DashboardWidgetType struct {
+	Type        string
+	Label       string
+	Description string
+}
+
+// DashboardWidgetTypes lists the widgets a dashboard can be built from
+var DashboardWidgetTypes = []DashboardWidgetType{
+	{Type: "metric_chart", Label: "Metric chart", Description: "Recent history of CPU, memory, disk or load"},
+	{Type: "container_status", Label: "Container status", Description: "Running state and usage of each container"},
+	{Type: "queue_depth", Label: "Queue depth", Description: "AI tasks and events waiting to be processed"},
+	{Type: "recent_errors", Label: "Recent errors", Description: "Latest errors from the application log"},
+	{Type: "backup_status", Label: "Backup status", Description: "Schedule and most recent backups"},
+}
+
+// DashboardMetrics are the values a metric chart can plot
+var DashboardMetrics = map[string]string{
+	"cpu":    "CPU usage",
+	"memory": "Memory usage",
+	"disk":   "Disk usage",
+	"load":   "Load average",
+}
+
+// DashboardWindows are the history ranges, in minutes, a metric chart can show
+var DashboardWindows = []int{5, 15, 60}
+
+// CreateDashboard creates an empty dashboard
+func CreateDashboard(name, description, userID string) (*Dashboard, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, errors.New("dashboard name is required")
+	}
+	return Dashboards.Insert(&Dashboard{
+		Model:       DB.NewModel(""),
+		Name:        name,
+		Description: strings.TrimSpace(description),
+		Layout:      "[]",
+		CreatedBy:   userID,
+		UpdatedBy:   userID,
+	})
+}
+
+// ListDashboards returns all dashboards by name
+func ListDashboards() ([]*Dashboard, error) {
+	return Dashboards.Search("ORDER BY Name")
+}
+
+// Widgets returns the dashboard's widgets in display order
+func (d *Dashboard) Widgets() []DashboardWidget {
+	var widgets []DashboardWidget
+	if d.Layout != "" {
+		json.Unmarshal([]byte(d.Layout), &widgets)
+	}
+	return widgets
+}
+
+// Widget returns the widget with the given ID
+func (d *Dashboard) Widget(id string) (DashboardWidget, bool) {
+	widgets := d.Widgets()
+	if i := slices.IndexFunc(widgets, func(w DashboardWidget) bool { return w.ID == id }); i >= 0 {
+		return widgets[i], true
+	}
+	return DashboardWidget{}, false
+}
+
+// AddWidget validates a widget and appends it to the layout
+func (d *Dashboard) AddWidget(widget DashboardWidget, userID string) (DashboardWidget, error) {
+	widgets := d.Widgets()
+	if len(widgets) >= MaxDashboardWidgets {
+		return widget, errors.Errorf("dashboards are limited to %d widgets", MaxDashboardWidgets)
+	}
+	if !slices.ContainsFunc(DashboardWidgetTypes, func(t DashboardWidgetType) bool { return t.Type == widget.Type }) {
+		return widget, errors.Errorf("unknown widget type %q", widget.Type)
+	}
+
+	if widget.Type == "metric_chart" {
+		if _, ok := DashboardMetrics[widget.Metric]; !ok {
+			return widget, errors.Errorf("unknown metric %q", widget.Metric)
+		}
+		if !slices.Contains(DashboardWindows, widget.Window) {
+			widget.Window = DashboardWindows[0]
+		}
+	} else {
+		widget.Metric, widget.Window = "", 0
+	}
+
+	widget.ID = GenerateToken()[:12]
+	widget.Title = strings.TrimSpace(widget.Title)
+	widget.Width = clampWidth(widget.Width)
+	return widget, d.saveWidgets(append(widgets, widget), userID)
+}
+
+// RemoveWidget deletes a widget from the layout
+func (d *Dashboard) RemoveWidget(id, userID string) error {
+	widgets := d.Widgets()
+	i := slices.IndexFunc(widgets, func(w DashboardWidget) bool { return w.ID == id })
+	if i < 0 {
+		return errors.New("widget not found")
+	}
+	return d.saveWidgets(slices.Delete(widgets, i, i+1), userID)
+}
+
+// MoveWidget shifts a widget earlier (negative offset) or later in the layout
+func (d *Dashboard) MoveWidget(id string, offset int, userID string) error {
+	widgets := d.Widgets()
+	i := slices.IndexFunc(widgets, func(w DashboardWidget) bool { return w.ID == id })
+	if i < 0 {
+		return errors.New("widget not found")
+	}
+	j := min(max(i+offset, 0), len(widgets)-1)
+	widget := widgets[i]
+	widgets = slices.Insert(slices.Delete(widgets, i, i+1), j, widget)
+	return d.saveWidgets(widgets, userID)
+}
+
+// ResizeWidget changes how many grid columns a widget spans
+func (d *Dashboard) ResizeWidget(id string, width int, userID string) error {
+	widgets := d.Widgets()
+	i := slices.IndexFunc(widgets, func(w DashboardWidget) bool { return w.ID == id })
+	if i < 0 {
+		return errors.New("widget not found")
+	}
+	widgets[i].Width = clampWidth(width)
+	return d.saveWidgets(widgets, userID)
+}
+
+// saveWidgets stores the layout
+func (d *Dashboard) saveWidgets(widgets []DashboardWidget, userID string) error {
+	if widgets == nil {
+		widgets = []DashboardWidget{}
+	}
+	layout, err := json.Marshal(widgets)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode dashboard layout")
+	}
+	d.Layout = string(layout)
+	d.UpdatedBy = userID
+	return Dashboards.Update(d)
+}
+
+// clampWidth keeps a widget within the three-column grid
+func clampWidth(width int) int {
+	return min(max(width, 1), 3)
+}
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/The-Skyscape/devtools/pkg/testutils"
+)
+
+func TestDashboards(t *testing.T) {
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+
+	dashboard, err := CreateDashboard("Production", "Main server", "admin")
+	testutils.AssertNoError(t, err)
+	testutils.AssertEqual(t, 0, len(dashboard.Widgets()))
+
+	t.Run("RequiresName", func(t *testing.T) {
+		_, err := CreateDashboard("  ", "", "admin")
+		testutils.AssertError(t, err)
+	})
+
+	t.Run("AddWidgets", func(t *testing.T) {
+		cpu, err := dashboard.AddWidget(DashboardWidget{Type: "metric_chart", Metric: "cpu", Window: 15, Width: 2}, "admin")
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 15, cpu.Window)
+
+		queue, err := dashboard.AddWidget(DashboardWidget{Type: "queue_depth", Metric: "cpu", Window: 5, Width: 9}, "admin")
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, "", queue.Metric)
+		testutils.AssertEqual(t, 3, queue.Width)
+
+		_, err = dashboard.AddWidget(DashboardWidget{Type: "weather"}, "admin")
+		testutils.AssertError(t, err)
+		_, err = dashboard.AddWidget(DashboardWidget{Type: "metric_chart", Metric: "temperature"}, "admin")
+		testutils.AssertError(t, err)
+
+		saved, err := Dashboards.Get(dashboard.ID)
+		testutils.AssertNoError(t, err)
+		widgets := saved.Widgets()
+		testutils.AssertEqual(t, 2, len(widgets))
+		testutils.AssertEqual(t, "metric_chart", widgets[0].Type)
+		testutils.AssertEqual(t, "queue_depth", widgets[1].Type)
+	})
+
+	t.Run("ArrangeLayout", func(t *testing.T) {
+		widgets := dashboard.Widgets()
+		first, second := widgets[0].ID, widgets[1].ID
+
+		testutils.AssertNoError(t, dashboard.MoveWidget(second, -1, "admin"))
+		testutils.AssertEqual(t, second, dashboard.Widgets()[0].ID)
+
+		// Moving past either end stays in place
+		testutils.AssertNoError(t, dashboard.MoveWidget(second, -1, "admin"))
+		testutils.AssertEqual(t, second, dashboard.Widgets()[0].ID)
+
+		testutils.AssertNoError(t, dashboard.ResizeWidget(first, 1, "admin"))
+		widget, ok := dashboard.Widget(first)
+		testutils.AssertTrue(t, ok)
+		testutils.AssertEqual(t, 1, widget.Width)
+
+		testutils.AssertNoError(t, dashboard.RemoveWidget(first, "admin"))
+		testutils.AssertEqual(t, 1, len(dashboard.Widgets()))
+		testutils.AssertError(t, dashboard.RemoveWidget(first, "admin"))
+	})
+}
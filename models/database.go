@@ -37,6 +37,7 @@ var (
 	// Normalized tag system
 	TagDefinitions = database.Manage(DB, new(TagDefinition))
 	IssueLabels    = database.Manage(DB, new(IssueLabel))
+	IssueAssignees = database.Manage(DB, new(IssueAssignee))
 	
 	// Event system
 	Events               = database.Manage(DB, new(Event))
@@ -59,11 +60,187 @@ var (
 	Messages      = database.Manage(DB, new(Message))
 	Todos         = database.Manage(DB, new(Todo))
 	AIActivities  = database.Manage(DB, new(AIActivity))
+
+	// Tasks on the AI queue, reloaded after a restart
+	AITasks = database.Manage(DB, new(AITask))
+
+	// Audit log of tool calls made by the AI agent
+	ToolExecutions = database.Manage(DB, new(ToolExecution))
+
+	// Daily repository health scores
+	RepoHealthSnapshots = database.Manage(DB, new(RepoHealthSnapshot))
+
+	// Per-repository limits for AI command sandboxes and agent tools
+	SandboxPolicies = database.Manage(DB, new(SandboxPolicy))
+	ToolPolicies    = database.Manage(DB, new(ToolPolicy))
+
+	// Per-repository stale issue and pull request automation
+	StalePolicies = database.Manage(DB, new(StalePolicy))
+	StaleMarks    = database.Manage(DB, new(StaleMark))
+
+	// Per-repository rules for approving pull requests without a human review
+	AutoApprovalPolicies = database.Manage(DB, new(AutoApprovalPolicy))
+
+	// Latest outdated and vulnerable dependency scan of each repository
+	DependencyScans = database.Manage(DB, new(DependencyScan))
+
+	// Outgoing repository webhooks and their delivery history
+	Webhooks          = database.Manage(DB, new(Webhook))
+	WebhookDeliveries = database.Manage(DB, new(WebhookDelivery))
+
+	// Slack, Discord and Matrix notifications of repositories and their delivery history
+	ChatIntegrations = database.Manage(DB, new(ChatIntegration))
+	ChatDeliveries   = database.Manage(DB, new(ChatDelivery))
+
+	// History of syncs between repositories and GitHub
+	GitHubSyncRuns = database.Manage(DB, new(GitHubSyncRun))
+
+	// Latest push of each repository to the mirror export organization
+	RepoMirrors = database.Manage(DB, new(RepoMirror))
+
+	// Git LFS objects pushed to each repository
+	LFSObjects = database.Manage(DB, new(LFSObject))
+
+	// Protected branch rules and the pull request approvals they count
+	BranchProtections = database.Manage(DB, new(BranchProtection))
+	PRApprovals       = database.Manage(DB, new(PRApproval))
+
+	// Approvals, requests for changes and comments left as pull request reviews
+	PRReviews = database.Manage(DB, new(PRReview))
+
+	// Whether each open pull request merges cleanly
+	MergeChecks = database.Manage(DB, new(MergeCheck))
+
+	// Statuses CI systems and actions report on commits
+	CommitStatuses = database.Manage(DB, new(CommitStatus))
+
+	// Runs of the YAML workflows repositories define, with their jobs and steps
+	WorkflowRuns     = database.Manage(DB, new(WorkflowRun))
+	WorkflowJobRuns  = database.Manage(DB, new(WorkflowJobRun))
+	WorkflowStepRuns = database.Manage(DB, new(WorkflowStepRun))
+
+	// Secrets given to actions and workflows, whose values live in Vault
+	ActionSecrets = database.Manage(DB, new(ActionSecret))
+
+	// Where repositories deploy their images, and what was deployed there
+	Environments = database.Manage(DB, new(Environment))
+	Deployments  = database.Manage(DB, new(Deployment))
+
+	// Test results reported by workflows, actions and the assistant
+	TestRuns    = database.Manage(DB, new(TestRun))
+	TestResults = database.Manage(DB, new(TestResult))
+
+	// Findings of the linters run by workflows and the assistant
+	LintRuns = database.Manage(DB, new(LintRun))
+
+	// Latest bill of materials of each repository, with its dependencies' licenses
+	SBOMs          = database.Manage(DB, new(SBOM))
+	SBOMComponents = database.Manage(DB, new(SBOMComponent))
+
+	// Secrets written while Vault was unavailable
+	FallbackSecrets = database.Manage(DB, new(FallbackSecret))
+
+	// Read-only guest links to single repositories and their access log
+	GuestLinks        = database.Manage(DB, new(GuestLink))
+	GuestLinkAccesses = database.Manage(DB, new(GuestLinkAccess))
+
+	// Admin-composed monitoring dashboards
+	Dashboards = database.Manage(DB, new(Dashboard))
+
+	// Planning: milestones and kanban boards
+	Milestones     = database.Manage(DB, new(Milestone))
+	Projects       = database.Manage(DB, new(Project))
+	ProjectColumns = database.Manage(DB, new(ProjectColumn))
+	ProjectRules   = database.Manage(DB, new(ProjectRule))
+	ProjectCards   = database.Manage(DB, new(ProjectCard))
+
+	// Files attached to issues, pull requests and comments
+	Attachments = database.Manage(DB, new(Attachment))
+
+	// Admin-edited system prompt, repository context and slash commands
+	PromptTemplates = database.Manage(DB, new(PromptTemplate))
+
+	// Pull request review requests and in-app notifications
+	ReviewRequests = database.Manage(DB, new(ReviewRequest))
+	Notifications  = database.Manage(DB, new(Notification))
+
+	// Metered resource consumption for billing and quotas
+	UsageRecords = database.Manage(DB, new(UsageRecord))
+	TokenUsages  = database.Manage(DB, new(TokenUsage))
+
+	// Embedded code chunks for semantic search
+	SemanticIndexes = database.Manage(DB, new(SemanticIndex))
+	CodeChunks      = database.Manage(DB, new(CodeChunk))
+
+	// Full-text search index of issues, pull requests, comments, commits and files
+	SearchDocuments   = database.Manage(DB, new(SearchDocument))
+	SearchIndexStates = database.Manage(DB, new(SearchIndexState))
+
+	// Per-user read state of issues and pull requests
+	ReadStates = database.Manage(DB, new(ReadState))
+
+	// Named issue and pull request filters users save
+	SavedViews = database.Manage(DB, new(SavedView))
+
+	// Mentions of issues and pull requests, for links both ways
+	CrossReferences = database.Manage(DB, new(CrossReference))
+
+	// Users mentioned by handle, so edits don't notify them again
+	Mentions = database.Manage(DB, new(Mention))
+
+	// Teams of users and the roles they have on repositories
+	Teams       = database.Manage(DB, new(Team))
+	TeamMembers = database.Manage(DB, new(TeamMember))
+	TeamRepos   = database.Manage(DB, new(TeamRepo))
+
+	// Personal access tokens for the HTTP API
+	PersonalTokens = database.Manage(DB, new(PersonalToken))
+
+	// Scheduled repository reports and the reports they generated
+	ReportSchedules = database.Manage(DB, new(ReportSchedule))
+	RepoReports     = database.Manage(DB, new(RepoReport))
+
+	// Transfers to another workspace, and invites to receive one
+	Migrations        = database.Manage(DB, new(Migration))
+	MigrationItems    = database.Manage(DB, new(MigrationItem))
+	MigrationInvites  = database.Manage(DB, new(MigrationInvite))
+	MigrationReceipts = database.Manage(DB, new(MigrationReceipt))
+
+	// Email preferences, password reset links and workspace invitations
+	MailPreferences = database.Manage(DB, new(MailPreference))
+	PasswordResets  = database.Manage(DB, new(PasswordReset))
+	Invitations     = database.Manage(DB, new(Invitation))
+
+	// Accounts at the single sign-on provider linked to users
+	SSOIdentities = database.Manage(DB, new(SSOIdentity))
+
+	// Browsers signed in to the workspace
+	UserSessions = database.Manage(DB, new(UserSession))
+
+	// Request counts rate limits are enforced with
+	RateLimitCounters = database.Manage(DB, new(RateLimitCounter))
+
+	// Host and container resource use, for the monitoring history charts
+	ResourceSamples = database.Manage(DB, new(ResourceSample))
+
+	// Alert rules, the channels they're routed to and the alerts they fired
+	AlertRules    = database.Manage(DB, new(AlertRule))
+	AlertChannels = database.Manage(DB, new(AlertChannel))
+	Alerts        = database.Manage(DB, new(Alert))
 )
 
 func init() {
 	// Create database indexes for common queries
 	createIndexes()
+
+	// Index search documents for full-text search
+	setupSearchIndex()
+
+	// Turn plain-text issue tags into labels
+	migrateIssueTags()
+
+	// Put cards from the old fixed columns onto repository boards
+	migrateProjectCards()
 }
 
 // createIndexes creates database indexes for common queries
@@ -89,9 +266,103 @@ func createIndexes() {
 	
 	// AI-related indexes
 	Conversations.Index("UserID")
+	Conversations.Index("UserID", "RepoID")
 	Messages.Index("ConversationID")
 	AIActivities.Index("Status")
 	AIActivities.Index("Priority")
+	AITasks.Index("Status")
+	AITasks.Index("IdempotencyKey")
+	ToolExecutions.Index("ToolName")
+	ToolExecutions.Index("RepoID")
+	ToolExecutions.Index("UserID")
+	ToolExecutions.Index("ExecutedAt")
+	RepoHealthSnapshots.Index("RepoID", "Day")
+	FallbackSecrets.Index("Key")
+	GuestLinks.Index("TokenHash")
+	GuestLinks.Index("RepoID")
+	GuestLinkAccesses.Index("LinkID")
+	Milestones.Index("RepoID")
+	Issues.Index("MilestoneID")
+	IssueAssignees.Index("IssueID")
+	IssueAssignees.Index("UserID")
+	Projects.Index("RepoID")
+	ProjectColumns.Index("ProjectID")
+	ProjectRules.Index("ProjectID", "Event")
+	ProjectCards.Index("ProjectID")
+	ProjectCards.Index("Column")
+	ProjectCards.Index("IssueID")
+	ProjectCards.Index("PullRequestID")
+	SavedViews.Index("Kind")
+	CrossReferences.Index("SourceType", "SourceID")
+	CrossReferences.Index("TargetType", "TargetID")
+	Mentions.Index("SourceType", "SourceID")
+	Teams.Index("ParentID")
+	TeamMembers.Index("TeamID")
+	TeamMembers.Index("UserID")
+	TeamRepos.Index("TeamID")
+	TeamRepos.Index("RepoID")
+	PersonalTokens.Index("TokenHash")
+	PersonalTokens.Index("UserID")
+	Attachments.Index("EntityType", "EntityID")
+	PromptTemplates.Index("Kind", "Name")
+	ReviewRequests.Index("PRID")
+	ReviewRequests.Index("Status")
+	Notifications.Index("UserID", "Read")
+	MailPreferences.Index("UserID")
+	MailPreferences.Index("Token")
+	PasswordResets.Index("TokenHash")
+	Invitations.Index("TokenHash")
+	Invitations.Index("Email")
+	SSOIdentities.Index("Issuer", "Subject")
+	SSOIdentities.Index("UserID")
+	UserSessions.Index("TokenHash")
+	UserSessions.Index("UserID")
+	RateLimitCounters.Index("ExpiresAt")
+	ResourceSamples.Index("Source", "CreatedAt")
+	ResourceSamples.Index("CreatedAt")
+	Alerts.Index("RuleID", "Status")
+	Alerts.Index("Status")
+	UsageRecords.Index("UserID", "Metric")
+	UsageRecords.Index("CreatedAt")
+	TokenUsages.Index("CreatedAt")
+	CodeChunks.Index("RepoID", "Path")
+	SearchDocuments.Index("RepoID", "Kind")
+	SearchDocuments.Index("ParentID")
+	ReadStates.Index("UserID", "EntityType", "EntityID")
+	ReportSchedules.Index("RepoID")
+	ReportSchedules.Index("Enabled", "NextRunAt")
+	RepoReports.Index("ScheduleID")
+	StaleMarks.Index("EntityType", "EntityID")
+	DependencyScans.Index("RepoID")
+	Webhooks.Index("RepoID")
+	WebhookDeliveries.Index("WebhookID")
+	ChatIntegrations.Index("RepoID")
+	ChatDeliveries.Index("IntegrationID")
+	GitHubSyncRuns.Index("RepoID")
+	RepoMirrors.Index("RepoID")
+	LFSObjects.Index("RepoID", "OID")
+	BranchProtections.Index("RepoID")
+	PRApprovals.Index("PRID")
+	PRReviews.Index("PRID")
+	MergeChecks.Index("RepoID")
+	CommitStatuses.Index("RepoID", "CommitSHA")
+	WorkflowRuns.Index("RepoID", "Number")
+	WorkflowRuns.Index("Status")
+	WorkflowJobRuns.Index("RunID")
+	WorkflowStepRuns.Index("JobRunID")
+	ActionSecrets.Index("RepoID", "Name")
+	Environments.Index("RepoID", "Name")
+	Deployments.Index("EnvironmentID", "CreatedAt")
+	Deployments.Index("Status")
+	TestRuns.Index("RepoID", "CreatedAt")
+	TestResults.Index("TestRunID")
+	TestResults.Index("RepoID", "Suite", "Name")
+	LintRuns.Index("RepoID", "Branch", "CreatedAt")
+	SBOMs.Index("RepoID")
+	SBOMComponents.Index("SBOMID")
+	SBOMComponents.Index("RepoID")
+	MigrationItems.Index("MigrationID", "Position")
+	MigrationReceipts.Index("InviteID", "Item")
 	
 	// Sorting indexes
 	Issues.Index("CreatedAt")
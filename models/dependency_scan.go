@@ -0,0 +1,112 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+
+	"workspace/internal/depscan"
+
+	"github.com/The-Skyscape/devtools/pkg/application"
+	"github.com/pkg/errors"
+)
+
+// DependencyScan is the latest check of a repository's manifests for
+// outdated and vulnerable dependencies. Only the newest scan of each
+// repository is kept.
+type DependencyScan struct {
+	application.Model
+	RepoID     string
+	Manifests  string // Scanned manifest paths, one per line
+	Checked    int    // Dependencies found in the manifests
+	Outdated   int
+	Vulnerable int
+	Findings   string // JSON encoded []depscan.Finding
+	Errors     string // Failed registry lookups, one per line
+	IssueID    string // Open issue listing the findings, if any
+}
+
+// Table returns the database table name
+func (*DependencyScan) Table() string { return "dependency_scans" }
+
+// RecordDependencyScan saves the result of scanning a repository's
+// manifests, replacing its previous scan but keeping the issue it opened
+func RecordDependencyScan(repoID string, manifests []string, result *depscan.Result) (*DependencyScan, error) {
+	findings, err := json.Marshal(result.Findings)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encode findings")
+	}
+
+	scan := &DependencyScan{
+		RepoID:    repoID,
+		Manifests: strings.Join(manifests, "\n"),
+		Checked:   result.Checked,
+		Findings:  string(findings),
+		Errors:    strings.Join(result.Errors, "\n"),
+	}
+	for _, finding := range result.Findings {
+		if finding.Latest != "" {
+			scan.Outdated++
+		}
+		if len(finding.Advisories) > 0 {
+			scan.Vulnerable++
+		}
+	}
+
+	if previous := LatestDependencyScan(repoID); previous != nil {
+		scan.IssueID = previous.IssueID
+	}
+	if err := DB.Query("DELETE FROM dependency_scans WHERE RepoID = ?", repoID).Exec(); err != nil {
+		return nil, errors.Wrap(err, "failed to clear previous scan")
+	}
+	scan, err = DependencyScans.Insert(scan)
+	return scan, errors.Wrap(err, "failed to save dependency scan")
+}
+
+// LatestDependencyScan returns the repository's newest dependency scan, or
+// nil when it has never been scanned
+func LatestDependencyScan(repoID string) *DependencyScan {
+	scans, err := DependencyScans.Search("WHERE RepoID = ? ORDER BY CreatedAt DESC LIMIT 1", repoID)
+	if err != nil || len(scans) == 0 {
+		return nil
+	}
+	return scans[0]
+}
+
+// FindingList decodes the scan's findings, vulnerable dependencies first
+func (s *DependencyScan) FindingList() []depscan.Finding {
+	var findings []depscan.Finding
+	if err := json.Unmarshal([]byte(s.Findings), &findings); err != nil {
+		return nil
+	}
+	vulnerable := make([]depscan.Finding, 0, len(findings))
+	var outdated []depscan.Finding
+	for _, finding := range findings {
+		if len(finding.Advisories) > 0 {
+			vulnerable = append(vulnerable, finding)
+		} else {
+			outdated = append(outdated, finding)
+		}
+	}
+	return append(vulnerable, outdated...)
+}
+
+// ManifestList returns the manifest paths that were scanned
+func (s *DependencyScan) ManifestList() []string {
+	return splitLines(s.Manifests)
+}
+
+// ErrorList returns the registry lookups that failed during the scan
+func (s *DependencyScan) ErrorList() []string {
+	return splitLines(s.Errors)
+}
+
+// splitLines returns the non-empty lines of a newline separated field
+func splitLines(value string) []string {
+	var lines []string
+	for _, line := range strings.Split(value, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"testing"
+
+	"workspace/internal/depscan"
+
+	"github.com/The-Skyscape/devtools/pkg/testutils"
+)
+
+func TestDependencyScan(t *testing.T) {
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+
+	result := &depscan.Result{
+		Checked: 3,
+		Findings: []depscan.Finding{
+			{
+				Dependency: depscan.Dependency{Ecosystem: depscan.EcosystemNPM, Name: "react", Version: "18.0.0"},
+				Latest:     "18.2.0",
+				Update:     "minor",
+			},
+			{
+				Dependency: depscan.Dependency{Ecosystem: depscan.EcosystemNPM, Name: "express", Version: "4.18.0"},
+				Advisories: []depscan.Advisory{{ID: "GHSA-test", Aliases: []string{"CVE-2024-0001"}}},
+			},
+		},
+		Errors: []string{"failed to look up left-pad: not found, try again"},
+	}
+
+	t.Run("RecordAndLoad", func(t *testing.T) {
+		testutils.AssertTrue(t, LatestDependencyScan("deps-repo") == nil)
+
+		scan, err := RecordDependencyScan("deps-repo", []string{"package.json"}, result)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 3, scan.Checked)
+		testutils.AssertEqual(t, 1, scan.Outdated)
+		testutils.AssertEqual(t, 1, scan.Vulnerable)
+
+		latest := LatestDependencyScan("deps-repo")
+		testutils.AssertTrue(t, latest != nil)
+		findings := latest.FindingList()
+		testutils.AssertEqual(t, 2, len(findings))
+		testutils.AssertEqual(t, "express", findings[0].Name)
+		testutils.AssertEqual(t, "CVE-2024-0001", findings[0].Advisories[0].CVEs()[0])
+		testutils.AssertEqual(t, 1, len(latest.ErrorList()))
+		testutils.AssertEqual(t, "package.json", latest.ManifestList()[0])
+	})
+
+	t.Run("RescanKeepsIssue", func(t *testing.T) {
+		scan := LatestDependencyScan("deps-repo")
+		scan.IssueID = "issue-1"
+		testutils.AssertNoError(t, DependencyScans.Update(scan))
+
+		rescan, err := RecordDependencyScan("deps-repo", []string{"package.json"}, &depscan.Result{Checked: 3})
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, "issue-1", rescan.IssueID)
+		testutils.AssertEqual(t, 0, len(rescan.FindingList()))
+
+		scans, err := DependencyScans.Search("WHERE RepoID = ?", "deps-repo")
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 1, len(scans))
+	})
+}
@@ -0,0 +1,372 @@
+package models
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/The-Skyscape/devtools/pkg/application"
+	"github.com/pkg/errors"
+)
+
+// EnvironmentPrefix is the vault key prefix for the TLS credentials of
+// environments' Docker hosts
+const EnvironmentPrefix = "environments/"
+
+// Deployment statuses
+const (
+	DeploymentRunning   = "running"
+	DeploymentSucceeded = "succeeded"
+	DeploymentFailed    = "failed"
+)
+
+// Results of the health check run after a deployment
+const (
+	DeploymentHealthy   = "healthy"
+	DeploymentUnhealthy = "unhealthy"
+)
+
+// Health check defaults and limits
+const (
+	DefaultHealthStatus  = 200
+	DefaultHealthTimeout = 60 // Seconds
+	maxHealthTimeout     = 600
+)
+
+var (
+	// environmentName keeps names usable in container names and URLs
+	environmentName = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,39}$`)
+
+	// environmentPort maps a host port to a container port, as docker -p does
+	environmentPort = regexp.MustCompile(`^(?:\d{1,5}:)?\d{1,5}(?:/(?:tcp|udp))?$`)
+)
+
+// Environment is a place a repository's images are deployed to: a Docker
+// host, this server's when DockerHost is empty, running one container of
+// the repository's image at a time
+type Environment struct {
+	application.Model
+	RepoID         string
+	Name           string
+	DockerHost     string // Empty for this server, or tcp://host:2376
+	HasTLS         bool   // Whether TLS client credentials for the host are in Vault
+	Ports          string // Published ports, comma separated, as in 8080:80
+	HealthURL      string // Probed after each deployment when set
+	HealthStatus   int    // Status the probe expects, DefaultHealthStatus when 0
+	HealthTimeout  int    // Seconds the app has to become healthy, DefaultHealthTimeout when 0
+	AutoRollback   bool   // Whether a deployment failing its health check is rolled back
+	Image          string // Image deployed, within the repository's registry namespace
+	CurrentVersion string // Tag deployed
+	DeploymentID   string // The deployment now running
+	CreatedBy      string
+}
+
+// Table returns the database table name
+func (*Environment) Table() string { return "environments" }
+
+// Deployment records one image being deployed to an environment
+type Deployment struct {
+	application.Model
+	RepoID        string
+	EnvironmentID string
+	Image         string
+	Version       string
+	Status        string
+	RollbackOf    string // The deployment replaced, for rollbacks
+	Health        string // Result of the health check, empty when there was none
+	HealthDetail  string // What the health probe last saw
+	Output        string
+	DeployedBy    string // Empty for automatic rollbacks
+	Duration      int    // Seconds
+}
+
+// Table returns the database table name
+func (*Deployment) Table() string { return "deployments" }
+
+// CreateEnvironment adds a deployment target to a repository
+func CreateEnvironment(repoID, name, dockerHost, ports, healthURL, userID string) (*Environment, error) {
+	env := &Environment{
+		RepoID:     repoID,
+		Name:       strings.ToLower(strings.TrimSpace(name)),
+		DockerHost: strings.TrimSpace(dockerHost),
+		Ports:      strings.TrimSpace(ports),
+		HealthURL:  strings.TrimSpace(healthURL),
+		CreatedBy:  userID,
+	}
+	if err := env.validate(); err != nil {
+		return nil, err
+	}
+	if existing, err := GetEnvironment(repoID, env.Name); err == nil && existing != nil {
+		return nil, errors.Errorf("the repository already has a %s environment", env.Name)
+	}
+
+	env, err := Environments.Insert(env)
+	return env, errors.Wrap(err, "failed to create environment")
+}
+
+// validate checks the environment's settings
+func (e *Environment) validate() error {
+	if !environmentName.MatchString(e.Name) {
+		return errors.New("environment names may only have lowercase letters, digits and -")
+	}
+	if e.DockerHost != "" {
+		host, err := url.Parse(e.DockerHost)
+		if err != nil || host.Scheme != "tcp" || host.Hostname() == "" || host.Port() == "" {
+			return errors.New("the Docker host must look like tcp://host:2376")
+		}
+	}
+	for _, port := range e.PortList() {
+		if !environmentPort.MatchString(port) {
+			return errors.Errorf("%q is not a port mapping like 8080:80", port)
+		}
+	}
+	if e.HealthURL != "" {
+		health, err := url.Parse(e.HealthURL)
+		if err != nil || (health.Scheme != "http" && health.Scheme != "https") || health.Host == "" {
+			return errors.New("the health URL must be an http or https URL")
+		}
+	}
+	if e.HealthStatus != 0 && (e.HealthStatus < 100 || e.HealthStatus > 599) {
+		return errors.Errorf("%d is not an HTTP status", e.HealthStatus)
+	}
+	if e.HealthTimeout < 0 || e.HealthTimeout > maxHealthTimeout {
+		return errors.Errorf("the health check timeout must be between 1 and %d seconds", maxHealthTimeout)
+	}
+	if e.AutoRollback && e.HealthURL == "" {
+		return errors.New("automatic rollback needs a health URL to check")
+	}
+	return nil
+}
+
+// SetHealthCheck configures the probe run after each deployment. An empty
+// URL turns it off, and zero status or timeout use the defaults.
+func (e *Environment) SetHealthCheck(healthURL string, status, timeout int, autoRollback bool) error {
+	e.HealthURL = strings.TrimSpace(healthURL)
+	e.HealthStatus, e.HealthTimeout, e.AutoRollback = status, timeout, autoRollback
+	if err := e.validate(); err != nil {
+		return err
+	}
+	return errors.Wrap(Environments.Update(e), "failed to update environment")
+}
+
+// ExpectedStatus is the HTTP status a healthy deployment answers with
+func (e *Environment) ExpectedStatus() int {
+	if e.HealthStatus == 0 {
+		return DefaultHealthStatus
+	}
+	return e.HealthStatus
+}
+
+// HealthWait is how long a deployment has to become healthy
+func (e *Environment) HealthWait() time.Duration {
+	if e.HealthTimeout == 0 {
+		return DefaultHealthTimeout * time.Second
+	}
+	return time.Duration(e.HealthTimeout) * time.Second
+}
+
+// PortList returns the published port mappings
+func (e *Environment) PortList() []string {
+	var ports []string
+	for _, port := range strings.Split(e.Ports, ",") {
+		if port = strings.TrimSpace(port); port != "" {
+			ports = append(ports, port)
+		}
+	}
+	return ports
+}
+
+// ContainerName is the name of the container the environment runs
+func (e *Environment) ContainerName() string {
+	return fmt.Sprintf("skyscape-%s-%s", e.RepoID, e.Name)
+}
+
+// Target describes where the environment runs, for display
+func (e *Environment) Target() string {
+	if e.DockerHost == "" {
+		return "This server"
+	}
+	return e.DockerHost
+}
+
+// vaultKey returns where the environment's TLS credentials are kept
+func (e *Environment) vaultKey() string {
+	return EnvironmentPrefix + e.ID + "/tls"
+}
+
+// SetTLS stores the CA certificate, client certificate and key that
+// authenticate with the environment's Docker host
+func (e *Environment) SetTLS(ca, cert, key string) error {
+	if !strings.Contains(ca, "BEGIN CERTIFICATE") || !strings.Contains(cert, "BEGIN CERTIFICATE") || !strings.Contains(key, "PRIVATE KEY") {
+		return errors.New("TLS credentials need PEM encoded CA and client certificates and a private key")
+	}
+	if err := StoreSecret(e.vaultKey(), map[string]any{"ca": ca, "cert": cert, "key": key}); err != nil {
+		return errors.Wrap(err, "failed to store TLS credentials")
+	}
+	e.HasTLS = true
+	return Environments.Update(e)
+}
+
+// TLS reads the environment's TLS credentials from Vault
+func (e *Environment) TLS() (ca, cert, key string, err error) {
+	secret, err := Secrets.GetSecret(e.vaultKey())
+	if err != nil {
+		return "", "", "", errors.Wrap(err, "failed to read TLS credentials")
+	}
+	ca, _ = secret["ca"].(string)
+	cert, _ = secret["cert"].(string)
+	key, _ = secret["key"].(string)
+	return ca, cert, key, nil
+}
+
+// Deployments returns the environment's latest deployments, newest first
+func (e *Environment) Deployments(limit int) ([]*Deployment, error) {
+	return Deployments.Search("WHERE EnvironmentID = ? ORDER BY CreatedAt DESC LIMIT ?", e.ID, limit)
+}
+
+// Current returns the deployment now running, if any
+func (e *Environment) Current() *Deployment {
+	if e.DeploymentID == "" {
+		return nil
+	}
+	deployment, err := Deployments.Get(e.DeploymentID)
+	if err != nil {
+		return nil
+	}
+	return deployment
+}
+
+// Deploying reports whether a deployment to the environment is under way
+func (e *Environment) Deploying() bool {
+	running, err := Deployments.Search("WHERE EnvironmentID = ? AND Status = ? LIMIT 1", e.ID, DeploymentRunning)
+	return err == nil && len(running) > 0
+}
+
+// Previous returns the last successful deployment before the current one,
+// which a rollback restores
+func (e *Environment) Previous() (*Deployment, error) {
+	deployments, err := Deployments.Search("WHERE EnvironmentID = ? AND Status = ? ORDER BY CreatedAt DESC", e.ID, DeploymentSucceeded)
+	if err != nil {
+		return nil, err
+	}
+	for _, deployment := range deployments {
+		if deployment.ID == e.DeploymentID {
+			continue
+		}
+		if deployment.Image != e.Image || deployment.Version != e.CurrentVersion {
+			return deployment, nil
+		}
+	}
+	return nil, errors.Errorf("%s has no earlier version to roll back to", e.Name)
+}
+
+// StartDeployment records a deployment of an image to the environment
+func StartDeployment(env *Environment, image, version, userID, rollbackOf string) (*Deployment, error) {
+	switch {
+	case !ValidImageName(image):
+		return nil, errors.Errorf("%q is not a valid image name", image)
+	case !ValidImageTag(version):
+		return nil, errors.Errorf("%q is not a valid image tag", version)
+	case env.Deploying():
+		return nil, errors.Errorf("%s is already being deployed to", env.Name)
+	}
+
+	deployment, err := Deployments.Insert(&Deployment{
+		RepoID:        env.RepoID,
+		EnvironmentID: env.ID,
+		Image:         image,
+		Version:       version,
+		Status:        DeploymentRunning,
+		RollbackOf:    rollbackOf,
+		DeployedBy:    userID,
+	})
+	return deployment, errors.Wrap(err, "failed to record deployment")
+}
+
+// Finish records how the deployment ended, and makes it the environment's
+// current one when it succeeded
+func (d *Deployment) Finish(env *Environment, status, output string) error {
+	d.Status, d.Output = status, output
+	d.Duration = int(time.Since(d.CreatedAt).Seconds())
+	if err := Deployments.Update(d); err != nil {
+		return errors.Wrap(err, "failed to update deployment")
+	}
+	if status != DeploymentSucceeded {
+		return nil
+	}
+	env.Image, env.CurrentVersion, env.DeploymentID = d.Image, d.Version, d.ID
+	return errors.Wrap(Environments.Update(env), "failed to update environment")
+}
+
+// Automatic reports whether the deployment was a rollback started by a
+// failed health check rather than by someone
+func (d *Deployment) Automatic() bool {
+	return d.DeployedBy == "" && d.RollbackOf != ""
+}
+
+// Reference is the image and tag deployed, as in app:1a2b3c4
+func (d *Deployment) Reference() string {
+	return d.Image + ":" + d.Version
+}
+
+// FormatDuration returns how long the deployment took
+func (d *Deployment) FormatDuration() string {
+	return (time.Duration(d.Duration) * time.Second).String()
+}
+
+// GetEnvironments returns a repository's environments by name
+func GetEnvironments(repoID string) ([]*Environment, error) {
+	return Environments.Search("WHERE RepoID = ? ORDER BY Name ASC", repoID)
+}
+
+// GetEnvironment returns a repository's environment by name
+func GetEnvironment(repoID, name string) (*Environment, error) {
+	envs, err := Environments.Search("WHERE RepoID = ? AND Name = ?", repoID, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(envs) == 0 {
+		return nil, errors.Errorf("environment %s not found", name)
+	}
+	return envs[0], nil
+}
+
+// DeleteEnvironment removes an environment, its credentials and its
+// deployment history. What it runs is left to the caller to stop.
+func DeleteEnvironment(env *Environment) error {
+	if env.HasTLS {
+		DeleteSecret(env.vaultKey())
+	}
+	if err := DB.Query("DELETE FROM deployments WHERE EnvironmentID = ?", env.ID).Exec(); err != nil {
+		return errors.Wrap(err, "failed to delete deployments")
+	}
+	return Environments.Delete(env)
+}
+
+// DeleteRepoEnvironments removes the environments of a deleted repository
+func DeleteRepoEnvironments(repoID string) {
+	envs, _ := GetEnvironments(repoID)
+	for _, env := range envs {
+		DeleteEnvironment(env)
+	}
+}
+
+// FailInterruptedDeployments marks deployments that were running when the
+// server stopped as failed, since nothing will finish them
+func FailInterruptedDeployments() error {
+	running, err := Deployments.Search("WHERE Status = ?", DeploymentRunning)
+	if err != nil {
+		return errors.Wrap(err, "failed to find interrupted deployments")
+	}
+	for _, deployment := range running {
+		deployment.Status = DeploymentFailed
+		deployment.Output += "\nInterrupted by a server restart after " + strconv.Itoa(int(time.Since(deployment.CreatedAt).Seconds())) + "s"
+		if err := Deployments.Update(deployment); err != nil {
+			return err
+		}
+	}
+	return nil
+}
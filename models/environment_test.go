@@ -0,0 +1,138 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/The-Skyscape/devtools/pkg/testutils"
+)
+
+func TestEnvironmentValidation(t *testing.T) {
+	t.Run("Invalid", func(t *testing.T) {
+		for _, env := range []*Environment{
+			{Name: ""},
+			{Name: "-staging"},
+			{Name: "staging env"},
+			{Name: "staging", DockerHost: "ssh://deploy@host"},
+			{Name: "staging", DockerHost: "tcp://host"},
+			{Name: "staging", Ports: "8080:80, http"},
+			{Name: "staging", HealthURL: "ftp://host/health"},
+			{Name: "staging", HealthURL: "/health"},
+		} {
+			testutils.AssertError(t, env.validate())
+		}
+	})
+
+	t.Run("Valid", func(t *testing.T) {
+		env := &Environment{Name: "staging", DockerHost: "tcp://10.0.0.5:2376", Ports: "8080:80, 53/udp", HealthURL: "https://staging.example.com/health"}
+		testutils.AssertNoError(t, env.validate())
+		testutils.AssertEqual(t, 2, len(env.PortList()))
+		testutils.AssertEqual(t, "tcp://10.0.0.5:2376", env.Target())
+		testutils.AssertEqual(t, "This server", (&Environment{}).Target())
+	})
+}
+
+func TestEnvironmentHealthCheck(t *testing.T) {
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+
+	env, err := CreateEnvironment("shop", "production", "", "", "", "user")
+	testutils.AssertNoError(t, err)
+	testutils.AssertEqual(t, DefaultHealthStatus, env.ExpectedStatus())
+	testutils.AssertEqual(t, DefaultHealthTimeout*time.Second, env.HealthWait())
+
+	t.Run("Invalid", func(t *testing.T) {
+		testutils.AssertError(t, env.SetHealthCheck("https://shop.example.com/health", 42, 0, false))
+		testutils.AssertError(t, env.SetHealthCheck("https://shop.example.com/health", 0, 3600, false))
+		testutils.AssertError(t, env.SetHealthCheck("", 0, 0, true))
+	})
+
+	t.Run("Valid", func(t *testing.T) {
+		testutils.AssertNoError(t, env.SetHealthCheck(" https://shop.example.com/health ", 204, 30, true))
+		testutils.AssertEqual(t, "https://shop.example.com/health", env.HealthURL)
+		testutils.AssertEqual(t, 204, env.ExpectedStatus())
+		testutils.AssertEqual(t, 30*time.Second, env.HealthWait())
+		testutils.AssertTrue(t, env.AutoRollback)
+	})
+
+	t.Run("Automatic", func(t *testing.T) {
+		testutils.AssertTrue(t, (&Deployment{RollbackOf: "deployment"}).Automatic())
+		testutils.AssertFalse(t, (&Deployment{RollbackOf: "deployment", DeployedBy: "user"}).Automatic())
+		testutils.AssertFalse(t, (&Deployment{}).Automatic())
+	})
+}
+
+func TestDeployments(t *testing.T) {
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+
+	env, err := CreateEnvironment("shop", "Staging", "", "8080:80", "", "user")
+	testutils.AssertNoError(t, err)
+	testutils.AssertEqual(t, "staging", env.Name)
+	testutils.AssertEqual(t, "skyscape-shop-staging", env.ContainerName())
+
+	t.Run("DuplicateName", func(t *testing.T) {
+		_, err := CreateEnvironment("shop", "staging", "", "", "", "user")
+		testutils.AssertError(t, err)
+	})
+
+	t.Run("OneAtATime", func(t *testing.T) {
+		first, err := StartDeployment(env, "app", "v1", "user", "")
+		testutils.AssertNoError(t, err)
+		testutils.AssertTrue(t, env.Deploying())
+
+		_, err = StartDeployment(env, "app", "v2", "user", "")
+		testutils.AssertError(t, err)
+
+		testutils.AssertNoError(t, first.Finish(env, DeploymentSucceeded, "ok"))
+		testutils.AssertFalse(t, env.Deploying())
+		testutils.AssertEqual(t, "v1", env.CurrentVersion)
+		testutils.AssertEqual(t, first.ID, env.DeploymentID)
+	})
+
+	t.Run("InvalidImage", func(t *testing.T) {
+		_, err := StartDeployment(env, "App", "v1", "user", "")
+		testutils.AssertError(t, err)
+		_, err = StartDeployment(env, "app", "-v1", "user", "")
+		testutils.AssertError(t, err)
+	})
+
+	t.Run("Previous", func(t *testing.T) {
+		_, err := env.Previous()
+		testutils.AssertError(t, err)
+
+		failed, err := StartDeployment(env, "app", "broken", "user", "")
+		testutils.AssertNoError(t, err)
+		testutils.AssertNoError(t, failed.Finish(env, DeploymentFailed, "exited"))
+		testutils.AssertEqual(t, "v1", env.CurrentVersion)
+
+		second, err := StartDeployment(env, "app", "v2", "user", "")
+		testutils.AssertNoError(t, err)
+		testutils.AssertNoError(t, second.Finish(env, DeploymentSucceeded, "ok"))
+
+		previous, err := env.Previous()
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, "app:v1", previous.Reference())
+	})
+
+	t.Run("Interrupted", func(t *testing.T) {
+		running, err := StartDeployment(env, "app", "v3", "user", "")
+		testutils.AssertNoError(t, err)
+		testutils.AssertNoError(t, FailInterruptedDeployments())
+
+		running, err = Deployments.Get(running.ID)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, DeploymentFailed, running.Status)
+		testutils.AssertFalse(t, env.Deploying())
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		DeleteRepoEnvironments("shop")
+		envs, err := GetEnvironments("shop")
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 0, len(envs))
+		deployments, err := Deployments.Search("WHERE EnvironmentID = ?", env.ID)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 0, len(deployments))
+	})
+}
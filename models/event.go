@@ -203,6 +203,11 @@ func GetPendingEvents(limit int) ([]*Event, error) {
 	return Events.Search("WHERE Status IN ('pending', 'retrying') ORDER BY Priority, CreatedAt LIMIT ?", limit)
 }
 
+// CountQueuedEvents returns how many events are waiting to be processed
+func CountQueuedEvents() int {
+	return Events.Count("WHERE Status IN ('pending', 'retrying')")
+}
+
 // GetRepoEvents returns events for a specific repository
 func GetRepoEvents(repoID string, limit int) ([]*Event, error) {
 	return Events.Search("WHERE RepoID = ? ORDER BY CreatedAt DESC LIMIT ?", repoID, limit)
@@ -0,0 +1,148 @@
+package models
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// FileDiff is one file's changes in a unified diff, line by line
+type FileDiff struct {
+	Path    string
+	OldPath string // Differs from Path when the file was renamed
+	Status  string // "added", "modified", "deleted" or "renamed"
+	Binary  bool
+	Hunks   []*DiffHunk
+
+	// Review threads that aren't on a line of the diff, like outdated ones
+	Threads []*ReviewThread
+}
+
+// DiffHunk is a run of changed lines and the context around them
+type DiffHunk struct {
+	Header string
+	Lines  []*DiffLine
+}
+
+// DiffLine is a line of a hunk with its number on each side of the diff
+type DiffLine struct {
+	Type    string // "add", "del" or "context"
+	OldLine int    // 0 for added lines
+	NewLine int    // 0 for deleted lines
+	Text    string
+
+	// Review threads on the line, by its new line number
+	Threads []*ReviewThread
+}
+
+// hunkHeader matches the line ranges of a hunk header
+var hunkHeader = regexp.MustCompile(`^@@ -(\d+)(?:,\d+)? \+(\d+)(?:,\d+)? @@`)
+
+// ParseFileDiffs splits the output of git diff into files and hunks
+func ParseFileDiffs(diff string) []*FileDiff {
+	var files []*FileDiff
+	var file *FileDiff
+	var hunk *DiffHunk
+	var oldLine, newLine int
+
+	for _, line := range strings.Split(diff, "\n") {
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			file = &FileDiff{Status: "modified"}
+			names := strings.TrimPrefix(line, "diff --git ")
+			if i := strings.Index(names, " b/"); i >= 0 {
+				file.OldPath = strings.TrimPrefix(names[:i], "a/")
+				file.Path = names[i+3:]
+			}
+			files = append(files, file)
+			hunk = nil
+		case file == nil:
+			continue
+		case strings.HasPrefix(line, "@@"):
+			m := hunkHeader.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+			oldLine, _ = strconv.Atoi(m[1])
+			newLine, _ = strconv.Atoi(m[2])
+			hunk = &DiffHunk{Header: line}
+			file.Hunks = append(file.Hunks, hunk)
+		case hunk == nil:
+			// Extended header lines between "diff --git" and the first hunk
+			switch {
+			case strings.HasPrefix(line, "new file"):
+				file.Status = "added"
+			case strings.HasPrefix(line, "deleted file"):
+				file.Status = "deleted"
+			case strings.HasPrefix(line, "rename from "):
+				file.Status = "renamed"
+				file.OldPath = strings.TrimPrefix(line, "rename from ")
+			case strings.HasPrefix(line, "rename to "):
+				file.Path = strings.TrimPrefix(line, "rename to ")
+			case strings.HasPrefix(line, "Binary files "):
+				file.Binary = true
+			}
+		case strings.HasPrefix(line, "+"):
+			hunk.Lines = append(hunk.Lines, &DiffLine{Type: "add", NewLine: newLine, Text: line[1:]})
+			newLine++
+		case strings.HasPrefix(line, "-"):
+			hunk.Lines = append(hunk.Lines, &DiffLine{Type: "del", OldLine: oldLine, Text: line[1:]})
+			oldLine++
+		case strings.HasPrefix(line, " "):
+			hunk.Lines = append(hunk.Lines, &DiffLine{Type: "context", OldLine: oldLine, NewLine: newLine, Text: line[1:]})
+			oldLine++
+			newLine++
+		}
+	}
+	return files
+}
+
+// line returns the diff line with a line number of the new file, or nil
+// when the diff doesn't show it
+func (f *FileDiff) line(number int) *DiffLine {
+	for _, hunk := range f.Hunks {
+		for _, line := range hunk.Lines {
+			if line.NewLine == number {
+				return line
+			}
+		}
+	}
+	return nil
+}
+
+// FileDiffs returns the pull request's diff by file, with each review
+// thread on the line it comments on
+func (pr *PullRequest) FileDiffs() ([]*FileDiff, error) {
+	repo, err := pr.Repository()
+	if err != nil {
+		return nil, errors.New("repository not found")
+	}
+	diff, err := repo.GetPRDiffContent(pr.BaseBranch, pr.CompareBranch)
+	if err != nil {
+		return nil, err
+	}
+	files := ParseFileDiffs(diff)
+
+	threads, err := pr.ReviewThreads()
+	if err != nil {
+		return nil, err
+	}
+	byPath := make(map[string]*FileDiff, len(files))
+	for _, file := range files {
+		byPath[file.Path] = file
+	}
+	for _, thread := range threads {
+		file, ok := byPath[thread.FilePath]
+		if !ok {
+			continue
+		}
+		if line := file.line(thread.LineNumber); line != nil && !thread.Outdated {
+			line.Threads = append(line.Threads, thread)
+		} else {
+			file.Threads = append(file.Threads, thread)
+		}
+	}
+	return files, nil
+}
@@ -0,0 +1,109 @@
+package models
+
+import (
+	"time"
+
+	"github.com/The-Skyscape/devtools/pkg/application"
+	"github.com/pkg/errors"
+)
+
+// Automatic GitHub sync intervals, in minutes
+const (
+	DefaultSyncInterval = 30
+	MinSyncInterval     = 5
+	MaxSyncInterval     = 24 * 60
+)
+
+// maxSyncBackoff caps how long repeated failures push back the next sync
+const maxSyncBackoff = 24 * time.Hour
+
+// maxGitHubSyncRuns is how many sync runs are kept per repository
+const maxGitHubSyncRuns = 50
+
+// What started a GitHub sync
+const (
+	SyncTriggerScheduled = "scheduled"
+	SyncTriggerManual    = "manual"
+)
+
+// How a GitHub sync ended
+const (
+	SyncSucceeded = "success"
+	SyncConflict  = "conflict" // Histories have diverged and need resolving by hand
+	SyncFailed    = "failed"
+)
+
+// GitHubSyncRun records one sync of a repository with GitHub
+type GitHubSyncRun struct {
+	application.Model
+	RepoID   string
+	Trigger  string // SyncTriggerScheduled or SyncTriggerManual
+	UserID   string // Whose GitHub token the sync used
+	Status   string // Code sync status found before syncing: synced, ahead, behind or diverged
+	Ahead    int
+	Behind   int
+	Pushed   bool
+	Pulled   bool
+	Outcome  string // SyncSucceeded, SyncConflict or SyncFailed
+	Error    string
+	Duration int64 // Milliseconds
+}
+
+// Table returns the database table name
+func (*GitHubSyncRun) Table() string { return "github_sync_runs" }
+
+// SyncIntervalMinutes returns how often the repository syncs automatically
+func (r *Repository) SyncIntervalMinutes() int {
+	if r.SyncInterval <= 0 {
+		return DefaultSyncInterval
+	}
+	return r.SyncInterval
+}
+
+// SyncDelay returns how long to wait before the next automatic sync. Each
+// consecutive failure doubles the interval, up to a day.
+func SyncDelay(intervalMinutes, failures int) time.Duration {
+	delay := time.Duration(intervalMinutes) * time.Minute
+	for i := 0; i < failures && delay < maxSyncBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, maxSyncBackoff)
+}
+
+// DueGitHubSyncs returns the repositories with auto-sync on whose next sync
+// is due
+func DueGitHubSyncs(now time.Time) ([]*Repository, error) {
+	return Repositories.Search("WHERE GitHubURL != '' AND AutoSync = ? AND NextSyncAt <= ?", true, now)
+}
+
+// RecordGitHubSyncRun stores a sync run, schedules the repository's next
+// sync with jitter added to spread repositories out, and drops its oldest
+// runs beyond the ones kept
+func RecordGitHubSyncRun(repo *Repository, run *GitHubSyncRun, jitter time.Duration) (*GitHubSyncRun, error) {
+	run.RepoID = repo.ID
+	run, err := GitHubSyncRuns.Insert(run)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to record sync run")
+	}
+
+	if run.Outcome == SyncSucceeded {
+		repo.SyncFailures = 0
+	} else {
+		repo.SyncFailures++
+	}
+	repo.LastSyncAt = run.CreatedAt
+	repo.NextSyncAt = run.CreatedAt.Add(SyncDelay(repo.SyncIntervalMinutes(), repo.SyncFailures) + jitter)
+	if err := Repositories.Update(repo); err != nil {
+		return nil, errors.Wrap(err, "failed to update repository")
+	}
+
+	err = DB.Query(`DELETE FROM github_sync_runs WHERE RepoID = ? AND ID NOT IN
+		(SELECT ID FROM github_sync_runs WHERE RepoID = ? ORDER BY CreatedAt DESC LIMIT ?)`,
+		repo.ID, repo.ID, maxGitHubSyncRuns).Exec()
+	return run, errors.Wrap(err, "failed to prune sync runs")
+}
+
+// RepoGitHubSyncRuns returns a repository's most recent sync runs, newest first
+func RepoGitHubSyncRuns(repoID string, limit int) ([]*GitHubSyncRun, error) {
+	return GitHubSyncRuns.Search("WHERE RepoID = ? ORDER BY CreatedAt DESC LIMIT ?", repoID, limit)
+}
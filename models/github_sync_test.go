@@ -0,0 +1,58 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/The-Skyscape/devtools/pkg/testutils"
+)
+
+func TestGitHubSync(t *testing.T) {
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+
+	t.Run("SyncDelayBacksOff", func(t *testing.T) {
+		testutils.AssertEqual(t, 30*time.Minute, SyncDelay(30, 0))
+		testutils.AssertEqual(t, 2*time.Hour, SyncDelay(30, 2))
+		testutils.AssertEqual(t, 24*time.Hour, SyncDelay(30, 20))
+		testutils.AssertEqual(t, 24*time.Hour, SyncDelay(MaxSyncInterval, 1))
+	})
+
+	t.Run("RecordsRunsAndSchedulesNext", func(t *testing.T) {
+		repo, err := Repositories.Insert(&Repository{Name: "synced", GitHubURL: "https://github.com/o/r", AutoSync: true, SyncInterval: 15})
+		testutils.AssertNoError(t, err)
+
+		due, err := DueGitHubSyncs(time.Now())
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 1, len(due))
+
+		run, err := RecordGitHubSyncRun(repo, &GitHubSyncRun{Trigger: SyncTriggerScheduled, Outcome: SyncFailed, Error: "network error"}, 0)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, repo.ID, run.RepoID)
+		testutils.AssertEqual(t, 1, repo.SyncFailures)
+		testutils.AssertEqual(t, 30*time.Minute, repo.NextSyncAt.Sub(run.CreatedAt))
+
+		due, err = DueGitHubSyncs(time.Now())
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 0, len(due))
+
+		run, err = RecordGitHubSyncRun(repo, &GitHubSyncRun{Trigger: SyncTriggerManual, Outcome: SyncSucceeded}, time.Minute)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 0, repo.SyncFailures)
+		testutils.AssertEqual(t, 16*time.Minute, repo.NextSyncAt.Sub(run.CreatedAt))
+	})
+
+	t.Run("PrunesOldRuns", func(t *testing.T) {
+		repo, err := Repositories.Insert(&Repository{Name: "busy", GitHubURL: "https://github.com/o/busy"})
+		testutils.AssertNoError(t, err)
+
+		for i := 0; i < maxGitHubSyncRuns+5; i++ {
+			_, err := RecordGitHubSyncRun(repo, &GitHubSyncRun{Outcome: SyncSucceeded}, 0)
+			testutils.AssertNoError(t, err)
+		}
+
+		runs, err := RepoGitHubSyncRuns(repo.ID, 100)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, maxGitHubSyncRuns, len(runs))
+	})
+}
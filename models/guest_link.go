@@ -0,0 +1,150 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/The-Skyscape/devtools/pkg/application"
+	"github.com/pkg/errors"
+)
+
+// MaxGuestLinkTTL is the longest a guest link may stay valid
+const MaxGuestLinkTTL = 90 * 24 * time.Hour
+
+// GuestLink grants read-only access to one repository's code and issues
+// without an account. Only a hash of the token is stored, so the link can
+// be shown once when it is created.
+type GuestLink struct {
+	application.Model
+	RepoID     string
+	Label      string // Who the link was given to
+	TokenHash  string
+	CreatedBy  string
+	ExpiresAt  time.Time
+	RevokedAt  time.Time
+	RevokedBy  string
+	LastUsedAt time.Time
+	UseCount   int
+}
+
+// Table returns the database table name
+func (*GuestLink) Table() string { return "guest_links" }
+
+// GuestLinkAccess records one page viewed through a guest link
+type GuestLinkAccess struct {
+	application.Model
+	LinkID    string
+	RepoID    string
+	Path      string
+	IPAddress string
+	UserAgent string
+}
+
+// Table returns the database table name
+func (*GuestLinkAccess) Table() string { return "guest_link_accesses" }
+
+// hashGuestToken returns the stored form of a guest link token
+func hashGuestToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateGuestLink creates a link to the repository that expires after ttl,
+// returning the link and the token to hand out
+func CreateGuestLink(repoID, label, createdBy string, ttl time.Duration) (*GuestLink, string, error) {
+	label = strings.TrimSpace(label)
+	if label == "" {
+		return nil, "", errors.New("a label is required so the link can be told apart later")
+	}
+	if ttl <= 0 || ttl > MaxGuestLinkTTL {
+		return nil, "", errors.Errorf("guest links must expire within %d days", int(MaxGuestLinkTTL.Hours()/24))
+	}
+
+	token := GenerateToken()
+	link, err := GuestLinks.Insert(&GuestLink{
+		Model:     DB.NewModel(""),
+		RepoID:    repoID,
+		Label:     label,
+		TokenHash: hashGuestToken(token),
+		CreatedBy: createdBy,
+		ExpiresAt: time.Now().Add(ttl),
+	})
+	if err != nil {
+		return nil, "", errors.Wrap(err, "failed to create guest link")
+	}
+	return link, token, nil
+}
+
+// GetGuestLink returns the active link for a token, failing for unknown,
+// expired and revoked links alike
+func GetGuestLink(token string) (*GuestLink, error) {
+	if token == "" {
+		return nil, errors.New("guest link not found")
+	}
+	links, err := GuestLinks.Search("WHERE TokenHash = ?", hashGuestToken(token))
+	if err != nil || len(links) == 0 || !links[0].Active() {
+		return nil, errors.New("guest link is invalid or has expired")
+	}
+	return links[0], nil
+}
+
+// GuestLinksForRepo returns a repository's guest links, newest first
+func GuestLinksForRepo(repoID string) ([]*GuestLink, error) {
+	return GuestLinks.Search("WHERE RepoID = ? ORDER BY CreatedAt DESC", repoID)
+}
+
+// Active reports whether the link can still be used
+func (l *GuestLink) Active() bool {
+	return l.RevokedAt.IsZero() && time.Now().Before(l.ExpiresAt)
+}
+
+// Status returns "active", "expired" or "revoked"
+func (l *GuestLink) Status() string {
+	switch {
+	case !l.RevokedAt.IsZero():
+		return "revoked"
+	case !l.Active():
+		return "expired"
+	default:
+		return "active"
+	}
+}
+
+// Revoke stops the link from working
+func (l *GuestLink) Revoke(userID string) error {
+	if !l.RevokedAt.IsZero() {
+		return nil
+	}
+	l.RevokedAt = time.Now()
+	l.RevokedBy = userID
+	return GuestLinks.Update(l)
+}
+
+// RecordAccess logs a page viewed through the link
+func (l *GuestLink) RecordAccess(path, ip, userAgent string) error {
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+	_, err := GuestLinkAccesses.Insert(&GuestLinkAccess{
+		Model:     DB.NewModel(""),
+		LinkID:    l.ID,
+		RepoID:    l.RepoID,
+		Path:      path,
+		IPAddress: ip,
+		UserAgent: userAgent,
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to record guest access")
+	}
+
+	l.LastUsedAt = time.Now()
+	l.UseCount++
+	return GuestLinks.Update(l)
+}
+
+// RecentAccesses returns the link's latest access log entries
+func (l *GuestLink) RecentAccesses(limit int) ([]*GuestLinkAccess, error) {
+	return GuestLinkAccesses.Search("WHERE LinkID = ? ORDER BY CreatedAt DESC LIMIT ?", l.ID, limit)
+}
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/The-Skyscape/devtools/pkg/testutils"
+)
+
+func TestGuestLinks(t *testing.T) {
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+
+	t.Run("CreateAndResolve", func(t *testing.T) {
+		link, token, err := CreateGuestLink("guest-repo", "Security review", "admin", 24*time.Hour)
+		testutils.AssertNoError(t, err)
+		testutils.AssertNotEqual(t, token, link.TokenHash)
+		testutils.AssertEqual(t, "active", link.Status())
+
+		found, err := GetGuestLink(token)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, link.ID, found.ID)
+		testutils.AssertEqual(t, "guest-repo", found.RepoID)
+
+		_, err = GetGuestLink("not-a-token")
+		testutils.AssertError(t, err)
+	})
+
+	t.Run("RejectsBadInput", func(t *testing.T) {
+		_, _, err := CreateGuestLink("guest-repo", "  ", "admin", time.Hour)
+		testutils.AssertError(t, err)
+
+		_, _, err = CreateGuestLink("guest-repo", "Auditor", "admin", MaxGuestLinkTTL+time.Hour)
+		testutils.AssertError(t, err)
+	})
+
+	t.Run("Revoke", func(t *testing.T) {
+		link, token, err := CreateGuestLink("guest-repo", "Contractor", "admin", time.Hour)
+		testutils.AssertNoError(t, err)
+		testutils.AssertNoError(t, link.Revoke("admin"))
+		testutils.AssertEqual(t, "revoked", link.Status())
+
+		_, err = GetGuestLink(token)
+		testutils.AssertError(t, err)
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		link, token, err := CreateGuestLink("guest-repo", "Old reviewer", "admin", time.Hour)
+		testutils.AssertNoError(t, err)
+		link.ExpiresAt = time.Now().Add(-time.Minute)
+		testutils.AssertNoError(t, GuestLinks.Update(link))
+
+		_, err = GetGuestLink(token)
+		testutils.AssertError(t, err)
+		testutils.AssertEqual(t, "expired", link.Status())
+	})
+
+	t.Run("RecordAccess", func(t *testing.T) {
+		link, _, err := CreateGuestLink("guest-repo", "Reviewer", "admin", time.Hour)
+		testutils.AssertNoError(t, err)
+
+		testutils.AssertNoError(t, link.RecordAccess("/repos/guest-repo/files", "10.0.0.1", "curl"))
+		testutils.AssertNoError(t, link.RecordAccess("/repos/guest-repo/issues", "10.0.0.1", "curl"))
+
+		saved, err := GuestLinks.Get(link.ID)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 2, saved.UseCount)
+		testutils.AssertFalse(t, saved.LastUsedAt.IsZero())
+
+		accesses, err := saved.RecentAccesses(10)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 2, len(accesses))
+	})
+
+	t.Run("ListForRepo", func(t *testing.T) {
+		links, err := GuestLinksForRepo("guest-repo")
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 4, len(links))
+	})
+}
@@ -0,0 +1,111 @@
+package models
+
+import (
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/The-Skyscape/devtools/pkg/application"
+	"github.com/pkg/errors"
+)
+
+// InvitationLifetime is how long an emailed invitation can be accepted
+const InvitationLifetime = 7 * 24 * time.Hour
+
+// Invitation asks someone by email to create an account in the workspace.
+// Only a hash of the emailed token is stored.
+type Invitation struct {
+	application.Model
+	Email      string
+	Admin      bool // Joins as a developer instead of a guest
+	InvitedBy  string
+	TokenHash  string
+	ExpiresAt  time.Time
+	AcceptedAt time.Time
+	AcceptedBy string // Account created from the invitation
+	RevokedAt  time.Time
+}
+
+// Table returns the database table name
+func (*Invitation) Table() string { return "invitations" }
+
+// CreateInvitation invites an email address to the workspace, returning
+// the token to email. Inviting an address again replaces its pending
+// invitation.
+func CreateInvitation(email string, admin bool, invitedBy string) (*Invitation, string, error) {
+	address, err := mail.ParseAddress(strings.TrimSpace(email))
+	if err != nil {
+		return nil, "", errors.New("enter a valid email address")
+	}
+	email = strings.ToLower(address.Address)
+
+	if pending, err := Invitations.Search("WHERE Email = ?", email); err == nil {
+		for _, invitation := range pending {
+			if invitation.Status() == "pending" {
+				invitation.Revoke()
+			}
+		}
+	}
+
+	token := GenerateToken()
+	invitation, err := Invitations.Insert(&Invitation{
+		Model:     DB.NewModel(""),
+		Email:     email,
+		Admin:     admin,
+		InvitedBy: invitedBy,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(InvitationLifetime),
+	})
+	if err != nil {
+		return nil, "", errors.Wrap(err, "failed to create invitation")
+	}
+	return invitation, token, nil
+}
+
+// GetInvitation returns the pending invitation for a token, failing for
+// unknown, expired, revoked and accepted invitations alike
+func GetInvitation(token string) (*Invitation, error) {
+	if token != "" {
+		invitations, err := Invitations.Search("WHERE TokenHash = ?", hashToken(token))
+		if err == nil && len(invitations) > 0 && invitations[0].Status() == "pending" {
+			return invitations[0], nil
+		}
+	}
+	return nil, errors.New("invitation is invalid or has expired")
+}
+
+// GetInvitations returns the invitations that haven't been revoked, newest
+// first
+func GetInvitations() ([]*Invitation, error) {
+	return Invitations.Search("WHERE RevokedAt = ? ORDER BY CreatedAt DESC", time.Time{})
+}
+
+// Status returns "pending", "accepted", "expired" or "revoked"
+func (i *Invitation) Status() string {
+	switch {
+	case !i.RevokedAt.IsZero():
+		return "revoked"
+	case !i.AcceptedAt.IsZero():
+		return "accepted"
+	case !time.Now().Before(i.ExpiresAt):
+		return "expired"
+	default:
+		return "pending"
+	}
+}
+
+// Accept records the account created from the invitation
+func (i *Invitation) Accept(userID string) error {
+	i.AcceptedAt = time.Now()
+	i.AcceptedBy = userID
+	return Invitations.Update(i)
+}
+
+// Revoke stops the invitation from being accepted
+func (i *Invitation) Revoke() error {
+	if !i.RevokedAt.IsZero() {
+		return nil
+	}
+	i.RevokedAt = time.Now()
+	return Invitations.Update(i)
+}
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/The-Skyscape/devtools/pkg/testutils"
+)
+
+func TestInvitations(t *testing.T) {
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+
+	admin := CreateTestUser(t, db, "inviter@example.com")
+
+	t.Run("CreateInvitation", func(t *testing.T) {
+		invitation, token, err := CreateInvitation(" New.Dev@Example.com ", true, admin.ID)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, "new.dev@example.com", invitation.Email)
+		testutils.AssertEqual(t, "pending", invitation.Status())
+
+		found, err := GetInvitation(token)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, invitation.ID, found.ID)
+
+		_, _, err = CreateInvitation("not an address", false, admin.ID)
+		testutils.AssertError(t, err)
+	})
+
+	t.Run("InvitingAgainReplaces", func(t *testing.T) {
+		_, first, err := CreateInvitation("again@example.com", false, admin.ID)
+		testutils.AssertNoError(t, err)
+		_, second, err := CreateInvitation("again@example.com", false, admin.ID)
+		testutils.AssertNoError(t, err)
+
+		_, err = GetInvitation(first)
+		testutils.AssertError(t, err)
+		_, err = GetInvitation(second)
+		testutils.AssertNoError(t, err)
+	})
+
+	t.Run("Accept", func(t *testing.T) {
+		invitation, token, err := CreateInvitation("joining@example.com", false, admin.ID)
+		testutils.AssertNoError(t, err)
+		testutils.AssertNoError(t, invitation.Accept("new-user"))
+		testutils.AssertEqual(t, "accepted", invitation.Status())
+
+		_, err = GetInvitation(token)
+		testutils.AssertError(t, err)
+	})
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"regexp"
 	"strings"
 	"time"
 
@@ -9,14 +10,19 @@ import (
 
 type Issue struct {
 	application.Model
-	Title      string
-	Body       string
-	Status     IssueStatus   // "open", "closed", "in_progress", "resolved"
-	Column     string        // Kanban column: "todo", "in_progress", "done"
-	Priority   IssuePriority // 1-10, 1 being highest
-	AuthorID   string        // User who created the issue
-	AssigneeID string
-	RepoID     string
+	Title       string
+	Body        string
+	Status      IssueStatus   // "open", "closed", "in_progress", "resolved"
+	Column      string        // Kanban column: "todo", "in_progress", "done"
+	Priority    IssuePriority // 1-10, 1 being highest
+	AuthorID    string        // User who created the issue
+	AssigneeID  string        // First assignee, see Assignees
+	RepoID      string
+	MilestoneID string // Milestone the issue is planned for, if any
+
+	// Issue form fields
+	FormName string // Name of the issue form used to create the issue
+	FormData string // JSON encoded answers, see FormValues
 
 	// GitHub Sync Fields
 	GitHubNumber  int       // GitHub issue number
@@ -51,6 +57,11 @@ const (
 
 func (*Issue) Table() string { return "issues" }
 
+// Repository returns the repository this issue belongs to
+func (i *Issue) Repository() (*Repository, error) {
+	return Repos.Get(i.RepoID)
+}
+
 // Labels returns all labels (tags) for this issue
 func (i *Issue) Labels() ([]*TagDefinition, error) {
 	return GetIssueLabels(i.ID)
@@ -165,3 +176,53 @@ func CreateIssue(title, body string, authorID, repoID string) (*Issue, error) {
 
 	return issue, err
 }
+
+// stackTraceLine matches the lines that make up common stack traces: Go
+// panics, Python tracebacks and Java or JavaScript "at" frames, along with
+// the exception line that heads them
+var stackTraceLine = regexp.MustCompile(`^(panic: |goroutine \d+ \[|Traceback \(most recent call last\)|\s+at\s|\s*File ".+", line \d+|.*\.go:\d+|\s*([\w$]+\.)*\w*(Error|Exception)\b.*:)`)
+
+// StackTrace returns the first stack trace pasted into the issue or one of
+// its comments, or an empty string when there is none
+func (i *Issue) StackTrace() string {
+	if trace := ExtractStackTrace(i.Body); trace != "" {
+		return trace
+	}
+	comments, _ := GetIssueComments(i.ID)
+	for _, comment := range comments {
+		if trace := ExtractStackTrace(comment.Body); trace != "" {
+			return trace
+		}
+	}
+	return ""
+}
+
+// ExtractStackTrace finds the first block of stack trace lines in text. A
+// couple of unmatched lines are allowed inside the block, since traces often
+// interleave frames with source snippets.
+func ExtractStackTrace(text string) string {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+
+	start, end, matched := -1, -1, 0
+	for n, line := range lines {
+		if !stackTraceLine.MatchString(line) {
+			if start >= 0 && n-end > 2 {
+				if matched >= 2 {
+					break
+				}
+				start, end, matched = -1, -1, 0 // A lone match is not a trace
+			}
+			continue
+		}
+		if start < 0 {
+			start = n
+		}
+		end = n
+		matched++
+	}
+
+	if matched < 2 {
+		return ""
+	}
+	return strings.Join(lines[start:end+1], "\n")
+}
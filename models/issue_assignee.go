@@ -0,0 +1,128 @@
+package models
+
+import (
+	"github.com/The-Skyscape/devtools/pkg/application"
+)
+
+// IssueAssignee is a user an issue is assigned to. Issues can have several;
+// Issue.AssigneeID keeps the first for code that only expects one.
+type IssueAssignee struct {
+	application.Model
+	IssueID    string
+	UserID     string
+	AssignedBy string
+}
+
+// Table returns the database table name
+func (*IssueAssignee) Table() string { return "issue_assignees" }
+
+// Assignees returns the users the issue is assigned to
+func (i *Issue) Assignees() ([]*User, error) {
+	assignees, err := IssueAssignees.Search("WHERE IssueID = ? ORDER BY CreatedAt ASC", i.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Issues assigned before they could have several only have AssigneeID
+	if len(assignees) == 0 && i.AssigneeID != "" {
+		if user, err := Users.Get(i.AssigneeID); err == nil {
+			return []*User{user}, nil
+		}
+		return nil, nil
+	}
+
+	users := make([]*User, 0, len(assignees))
+	for _, assignee := range assignees {
+		if user, err := Users.Get(assignee.UserID); err == nil {
+			users = append(users, user)
+		}
+	}
+	return users, nil
+}
+
+// IsAssigned returns true if the issue is assigned to the user
+func (i *Issue) IsAssigned(userID string) bool {
+	if userID == "" {
+		return false
+	}
+	if i.AssigneeID == userID {
+		return true
+	}
+	return IssueAssignees.Count("WHERE IssueID = ? AND UserID = ?", i.ID, userID) > 0
+}
+
+// AssignIssue assigns an issue to another user
+func AssignIssue(issue *Issue, userID, assignedBy string) error {
+	// Keep who it was assigned to before it could have several
+	if issue.AssigneeID != "" && issue.AssigneeID != userID &&
+		IssueAssignees.Count("WHERE IssueID = ?", issue.ID) == 0 {
+		if _, err := Users.Get(issue.AssigneeID); err == nil {
+			if _, err := IssueAssignees.Insert(&IssueAssignee{
+				IssueID: issue.ID,
+				UserID:  issue.AssigneeID,
+			}); err != nil {
+				return err
+			}
+		}
+	}
+
+	if IssueAssignees.Count("WHERE IssueID = ? AND UserID = ?", issue.ID, userID) == 0 {
+		if _, err := IssueAssignees.Insert(&IssueAssignee{
+			IssueID:    issue.ID,
+			UserID:     userID,
+			AssignedBy: assignedBy,
+		}); err != nil {
+			return err
+		}
+	}
+
+	if issue.AssigneeID == "" {
+		issue.AssigneeID = userID
+		return Issues.Update(issue)
+	}
+	return nil
+}
+
+// SetIssueAssignees replaces the users an issue is assigned to
+func SetIssueAssignees(issue *Issue, userIDs []string, assignedBy string) error {
+	keep := map[string]bool{}
+	for _, userID := range userIDs {
+		if userID == "" || keep[userID] {
+			continue
+		}
+		keep[userID] = true
+		if IssueAssignees.Count("WHERE IssueID = ? AND UserID = ?", issue.ID, userID) > 0 {
+			continue
+		}
+		if _, err := IssueAssignees.Insert(&IssueAssignee{
+			IssueID:    issue.ID,
+			UserID:     userID,
+			AssignedBy: assignedBy,
+		}); err != nil {
+			return err
+		}
+	}
+
+	assignees, err := IssueAssignees.Search("WHERE IssueID = ? ORDER BY CreatedAt ASC", issue.ID)
+	if err != nil {
+		return err
+	}
+	first := ""
+	for _, assignee := range assignees {
+		if !keep[assignee.UserID] {
+			if err := IssueAssignees.Delete(assignee); err != nil {
+				return err
+			}
+			continue
+		}
+		if first == "" {
+			first = assignee.UserID
+		}
+	}
+
+	if issue.AssigneeID != first {
+		issue.AssigneeID = first
+		return Issues.Update(issue)
+	}
+	return nil
+}
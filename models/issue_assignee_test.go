@@ -0,0 +1,61 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/The-Skyscape/devtools/pkg/testutils"
+)
+
+func TestIssueAssignees(t *testing.T) {
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+
+	ada := CreateTestUser(t, db, "ada@example.com")
+	sam := CreateTestUser(t, db, "sam@example.com")
+	lee := CreateTestUser(t, db, "lee@example.com")
+	repo := createTestRepository(t, "assigned-repo", ada.ID)
+
+	t.Run("LegacyAssignee", func(t *testing.T) {
+		issue, err := Issues.Insert(&Issue{Title: "Old", Status: IssueStatusOpen, RepoID: repo.ID, AssigneeID: sam.ID})
+		testutils.AssertNoError(t, err)
+
+		assignees, err := issue.Assignees()
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 1, len(assignees))
+		testutils.AssertTrue(t, issue.IsAssigned(sam.ID))
+
+		// Adding someone keeps who it was already assigned to
+		testutils.AssertNoError(t, AssignIssue(issue, lee.ID, ada.ID))
+		assignees, err = issue.Assignees()
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 2, len(assignees))
+		testutils.AssertEqual(t, sam.ID, issue.AssigneeID)
+	})
+
+	t.Run("SetIssueAssignees", func(t *testing.T) {
+		issue, err := CreateIssue("New", "", ada.ID, repo.ID)
+		testutils.AssertNoError(t, err)
+		testutils.AssertFalse(t, issue.IsAssigned(ada.ID))
+
+		testutils.AssertNoError(t, SetIssueAssignees(issue, []string{sam.ID, lee.ID, sam.ID}, ada.ID))
+		assignees, err := issue.Assignees()
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 2, len(assignees))
+		testutils.AssertTrue(t, issue.IsAssigned(lee.ID))
+		testutils.AssertTrue(t, issue.AssigneeID != "")
+
+		testutils.AssertNoError(t, SetIssueAssignees(issue, []string{lee.ID}, ada.ID))
+		testutils.AssertFalse(t, issue.IsAssigned(sam.ID))
+		testutils.AssertEqual(t, lee.ID, issue.AssigneeID)
+
+		stored, err := Issues.Get(issue.ID)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, lee.ID, stored.AssigneeID)
+
+		testutils.AssertNoError(t, SetIssueAssignees(issue, nil, ada.ID))
+		testutils.AssertEqual(t, "", issue.AssigneeID)
+		assignees, err = issue.Assignees()
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 0, len(assignees))
+	})
+}
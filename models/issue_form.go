@@ -0,0 +1,292 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// IssueFormsDir is where repositories keep their issue form definitions,
+// matching the layout GitHub uses so existing forms work unchanged
+const IssueFormsDir = ".github/ISSUE_TEMPLATE"
+
+// IssueForm is a YAML-defined issue template with structured fields
+type IssueForm struct {
+	Name        string           `yaml:"name"`
+	Description string           `yaml:"description"`
+	Title       string           `yaml:"title"`
+	Labels      []string         `yaml:"labels"`
+	Body        []IssueFormField `yaml:"body"`
+	File        string           `yaml:"-"` // Path of the form within the repository
+}
+
+// IssueFormField is a single element of an issue form
+type IssueFormField struct {
+	Type        string                   `yaml:"type"` // "markdown", "input", "textarea", "dropdown", "checkboxes"
+	ID          string                   `yaml:"id"`
+	Attributes  IssueFormFieldAttributes `yaml:"attributes"`
+	Validations struct {
+		Required bool `yaml:"required"`
+	} `yaml:"validations"`
+}
+
+// IssueFormFieldAttributes holds the display settings of a form field
+type IssueFormFieldAttributes struct {
+	Label       string            `yaml:"label"`
+	Description string            `yaml:"description"`
+	Placeholder string            `yaml:"placeholder"`
+	Value       string            `yaml:"value"`
+	Multiple    bool              `yaml:"multiple"`
+	Options     []IssueFormOption `yaml:"options"`
+}
+
+// IssueFormOption is a dropdown choice or a checkbox
+type IssueFormOption struct {
+	Label    string `yaml:"label"`
+	Required bool   `yaml:"required"`
+}
+
+// UnmarshalYAML accepts both plain string options (dropdowns) and
+// label mappings (checkboxes)
+func (o *IssueFormOption) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		o.Label = node.Value
+		return nil
+	}
+
+	type plain IssueFormOption
+	return node.Decode((*plain)(o))
+}
+
+// InputName returns the form control name used for this field
+func (f *IssueFormField) InputName() string {
+	return "field_" + f.ID
+}
+
+// IsInput reports whether the field collects a value from the user
+func (f *IssueFormField) IsInput() bool {
+	return f.Type != "markdown"
+}
+
+// ParseIssueForm decodes and validates an issue form definition
+func ParseIssueForm(data []byte) (*IssueForm, error) {
+	var form IssueForm
+	if err := yaml.Unmarshal(data, &form); err != nil {
+		return nil, errors.Wrap(err, "invalid issue form")
+	}
+
+	if strings.TrimSpace(form.Name) == "" {
+		return nil, errors.New("issue form name is required")
+	}
+	if len(form.Body) == 0 {
+		return nil, errors.New("issue form body is required")
+	}
+
+	seen := map[string]bool{}
+	for i := range form.Body {
+		field := &form.Body[i]
+		switch field.Type {
+		case "markdown":
+			continue
+		case "input", "textarea", "dropdown", "checkboxes":
+		default:
+			return nil, fmt.Errorf("unsupported field type %q", field.Type)
+		}
+
+		// Fields without an id still need a stable name for submission
+		if field.ID == "" {
+			field.ID = fmt.Sprintf("field%d", i)
+		}
+		if seen[field.ID] {
+			return nil, fmt.Errorf("duplicate field id %q", field.ID)
+		}
+		seen[field.ID] = true
+
+		if field.Attributes.Label == "" {
+			return nil, fmt.Errorf("field %q needs a label", field.ID)
+		}
+		if (field.Type == "dropdown" || field.Type == "checkboxes") && len(field.Attributes.Options) == 0 {
+			return nil, fmt.Errorf("field %q needs options", field.ID)
+		}
+	}
+
+	return &form, nil
+}
+
+// GetIssueForms returns the issue forms defined on the default branch
+func (r *Repository) GetIssueForms() ([]*IssueForm, error) {
+	nodes, err := r.GetFileTree("", IssueFormsDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var forms []*IssueForm
+	for _, node := range nodes {
+		ext := path.Ext(node.Name)
+		if node.Type != "file" || (ext != ".yml" && ext != ".yaml") {
+			continue
+		}
+		// config.yml configures the template chooser, it is not a form
+		if strings.TrimSuffix(node.Name, ext) == "config" {
+			continue
+		}
+
+		file, err := r.GetFile("", node.Path)
+		if err != nil {
+			continue
+		}
+		form, err := ParseIssueForm([]byte(file.Content))
+		if err != nil {
+			continue
+		}
+		form.File = node.Path
+		forms = append(forms, form)
+	}
+
+	sort.Slice(forms, func(i, j int) bool { return forms[i].Name < forms[j].Name })
+	return forms, nil
+}
+
+// GetIssueForm returns the issue form with the given name
+func (r *Repository) GetIssueForm(name string) (*IssueForm, error) {
+	forms, err := r.GetIssueForms()
+	if err != nil {
+		return nil, err
+	}
+	for _, form := range forms {
+		if form.Name == name {
+			return form, nil
+		}
+	}
+	return nil, errors.New("issue form not found")
+}
+
+// IssueFormValue is one answered field of a submitted issue form
+type IssueFormValue struct {
+	ID     string
+	Label  string
+	Values []string
+}
+
+// Collect validates submitted values against the form and returns the answers
+// in form order. get returns all values submitted for a control name.
+func (f *IssueForm) Collect(get func(name string) []string) ([]IssueFormValue, error) {
+	var answers []IssueFormValue
+	for _, field := range f.Body {
+		if !field.IsInput() {
+			continue
+		}
+
+		var values []string
+		for _, v := range get(field.InputName()) {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+
+		switch field.Type {
+		case "dropdown":
+			for _, v := range values {
+				if !field.hasOption(v) {
+					return nil, fmt.Errorf("%s: %q is not a valid option", field.Attributes.Label, v)
+				}
+			}
+			if !field.Attributes.Multiple && len(values) > 1 {
+				values = values[:1]
+			}
+		case "checkboxes":
+			for _, option := range field.Attributes.Options {
+				if option.Required && !slices.Contains(values, option.Label) {
+					return nil, fmt.Errorf("%s: %q must be checked", field.Attributes.Label, option.Label)
+				}
+			}
+		}
+
+		if field.Validations.Required && len(values) == 0 {
+			return nil, fmt.Errorf("%s is required", field.Attributes.Label)
+		}
+
+		answers = append(answers, IssueFormValue{ID: field.ID, Label: field.Attributes.Label, Values: values})
+	}
+	return answers, nil
+}
+
+// RenderIssueFormBody formats form answers as markdown for the issue body
+func RenderIssueFormBody(form *IssueForm, answers []IssueFormValue) string {
+	checkboxes := map[string]*IssueFormField{}
+	for i := range form.Body {
+		if form.Body[i].Type == "checkboxes" {
+			checkboxes[form.Body[i].ID] = &form.Body[i]
+		}
+	}
+
+	var b strings.Builder
+	for _, answer := range answers {
+		fmt.Fprintf(&b, "### %s\n\n", answer.Label)
+		if field, ok := checkboxes[answer.ID]; ok {
+			for _, option := range field.Attributes.Options {
+				mark := " "
+				if slices.Contains(answer.Values, option.Label) {
+					mark = "x"
+				}
+				fmt.Fprintf(&b, "- [%s] %s\n", mark, option.Label)
+			}
+			b.WriteString("\n")
+			continue
+		}
+		if len(answer.Values) == 0 {
+			b.WriteString("_No response_\n\n")
+			continue
+		}
+		b.WriteString(strings.Join(answer.Values, ", "))
+		b.WriteString("\n\n")
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// EncodeIssueFormData serializes answers for storage on the issue
+func EncodeIssueFormData(answers []IssueFormValue) (string, error) {
+	data, err := json.Marshal(answers)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// FormValues returns the structured answers recorded when the issue was created from a form
+func (i *Issue) FormValues() []IssueFormValue {
+	if i.FormData == "" {
+		return nil
+	}
+	var answers []IssueFormValue
+	if err := json.Unmarshal([]byte(i.FormData), &answers); err != nil {
+		return nil
+	}
+	return answers
+}
+
+// FormValue returns the joined answer for a field id, for triage and reporting
+func (i *Issue) FormValue(id string) string {
+	for _, answer := range i.FormValues() {
+		if answer.ID == id {
+			return strings.Join(answer.Values, ", ")
+		}
+	}
+	return ""
+}
+
+// hasOption reports whether label is one of the field's options
+func (f *IssueFormField) hasOption(label string) bool {
+	for _, option := range f.Attributes.Options {
+		if option.Label == label {
+			return true
+		}
+	}
+	return false
+}
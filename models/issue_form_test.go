@@ -0,0 +1,93 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/The-Skyscape/devtools/pkg/testutils"
+)
+
+const bugReportForm = `
+name: Bug Report
+description: Report something that is broken
+title: "[Bug]: "
+labels: ["bug", "triage"]
+body:
+  - type: markdown
+    attributes:
+      value: Thanks for taking the time to report a bug!
+  - type: input
+    id: version
+    attributes:
+      label: Version
+    validations:
+      required: true
+  - type: dropdown
+    id: severity
+    attributes:
+      label: Severity
+      options:
+        - Low
+        - High
+  - type: checkboxes
+    id: terms
+    attributes:
+      label: Checklist
+      options:
+        - label: I searched existing issues
+          required: true
+        - label: I can reproduce this
+`
+
+func TestIssueForm(t *testing.T) {
+	t.Run("Parse", func(t *testing.T) {
+		form, err := ParseIssueForm([]byte(bugReportForm))
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, "Bug Report", form.Name)
+		testutils.AssertEqual(t, 2, len(form.Labels))
+		testutils.AssertEqual(t, 4, len(form.Body))
+		testutils.AssertEqual(t, "High", form.Body[2].Attributes.Options[1].Label)
+		testutils.AssertEqual(t, true, form.Body[3].Attributes.Options[0].Required)
+	})
+
+	t.Run("RejectsUnknownFieldType", func(t *testing.T) {
+		_, err := ParseIssueForm([]byte("name: Broken\nbody:\n  - type: slider\n    id: x\n    attributes:\n      label: X\n"))
+		testutils.AssertError(t, err)
+	})
+
+	t.Run("Collect", func(t *testing.T) {
+		form, err := ParseIssueForm([]byte(bugReportForm))
+		testutils.AssertNoError(t, err)
+
+		values := map[string][]string{
+			"field_version":  {"1.2.0"},
+			"field_severity": {"High"},
+			"field_terms":    {"I searched existing issues"},
+		}
+		answers, err := form.Collect(func(name string) []string { return values[name] })
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 3, len(answers))
+
+		body := RenderIssueFormBody(form, answers)
+		testutils.AssertContains(t, body, "### Version\n\n1.2.0")
+		testutils.AssertContains(t, body, "- [x] I searched existing issues")
+		testutils.AssertContains(t, body, "- [ ] I can reproduce this")
+	})
+
+	t.Run("CollectValidates", func(t *testing.T) {
+		form, err := ParseIssueForm([]byte(bugReportForm))
+		testutils.AssertNoError(t, err)
+
+		// Missing required input
+		_, err = form.Collect(func(name string) []string { return nil })
+		testutils.AssertError(t, err)
+
+		// Option that is not in the dropdown
+		values := map[string][]string{
+			"field_version":  {"1.2.0"},
+			"field_severity": {"Critical"},
+			"field_terms":    {"I searched existing issues"},
+		}
+		_, err = form.Collect(func(name string) []string { return values[name] })
+		testutils.AssertError(t, err)
+	})
+}
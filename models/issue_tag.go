@@ -23,6 +23,8 @@ func init() {
 }
 
 // AddTagToIssue adds a tag to an issue (if not already present)
+//
+// Deprecated: use AddLabelToIssue
 func AddTagToIssue(issueID, tag string) error {
 	// Check if tag already exists
 	existing, err := IssueTags.Search("WHERE IssueID = ? AND Tag = ?", issueID, tag)
@@ -42,6 +44,8 @@ func AddTagToIssue(issueID, tag string) error {
 }
 
 // RemoveTagFromIssue removes a tag from an issue
+//
+// Deprecated: use RemoveLabelFromIssue
 func RemoveTagFromIssue(issueID, tag string) error {
 	tags, err := IssueTags.Search("WHERE IssueID = ? AND Tag = ?", issueID, tag)
 	if err != nil {
@@ -58,6 +62,8 @@ func RemoveTagFromIssue(issueID, tag string) error {
 }
 
 // GetIssueTags returns all tags for an issue
+//
+// Deprecated: use GetIssueLabels
 func GetIssueTags(issueID string) ([]string, error) {
 	tags, err := IssueTags.Search("WHERE IssueID = ? ORDER BY Tag", issueID)
 	if err != nil {
@@ -73,6 +79,8 @@ func GetIssueTags(issueID string) ([]string, error) {
 }
 
 // GetIssuesByTag returns all issue IDs with a specific tag
+//
+// Deprecated: use GetIssuesByTagID
 func GetIssuesByTag(tag string) ([]string, error) {
 	tags, err := IssueTags.Search("WHERE Tag = ?", tag)
 	if err != nil {
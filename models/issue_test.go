@@ -1,6 +1,7 @@
 package models
 
 import (
+	"strings"
 	"testing"
 	
 	"github.com/The-Skyscape/devtools/pkg/testutils"
@@ -179,4 +180,23 @@ func TestIssueModel(t *testing.T) {
 	})
 }
 
+func TestExtractStackTrace(t *testing.T) {
+	t.Run("GoPanic", func(t *testing.T) {
+		body := "Saving crashes the server.\n\npanic: runtime error: invalid memory address\n\ngoroutine 1 [running]:\nmain.save(0x0)\n\t/app/main.go:42 +0x1d\nexit status 2\n\nAny ideas?"
+		trace := ExtractStackTrace(body)
+		testutils.AssertContains(t, trace, "panic: runtime error")
+		testutils.AssertContains(t, trace, "/app/main.go:42")
+		testutils.AssertFalse(t, strings.Contains(trace, "Any ideas"))
+	})
+
+	t.Run("PythonTraceback", func(t *testing.T) {
+		body := "Traceback (most recent call last):\n  File \"app.py\", line 3, in <module>\n    foo()\nZeroDivisionError: division by zero"
+		testutils.AssertEqual(t, body, ExtractStackTrace(body))
+	})
 
+	t.Run("IgnoresLoneMatches", func(t *testing.T) {
+		body := "The bug is around main.go:12.\nIt only happens on Mondays.\nNo crash output.\nSorry.\nTypeError: x is undefined\n    at render (app.js:10:4)"
+		testutils.AssertEqual(t, "TypeError: x is undefined\n    at render (app.js:10:4)", ExtractStackTrace(body))
+		testutils.AssertEqual(t, "", ExtractStackTrace("Please add dark mode"))
+	})
+}
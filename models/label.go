@@ -0,0 +1,162 @@
+package models
+
+import (
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// labelColor matches the hex colors labels are drawn in
+var labelColor = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// GetRepoLabels returns the labels issues in a repository can have: its own
+// and the global ones, by category then name
+func GetRepoLabels(repoID string) ([]*TagDefinition, error) {
+	return TagDefinitions.Search("WHERE RepoID = ? OR RepoID = '' ORDER BY SortOrder, Name", repoID)
+}
+
+// CreateLabel adds a label to a repository
+func CreateLabel(repoID, name, color, description string) (*TagDefinition, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return nil, errors.New("label name is required")
+	}
+	if !labelColor.MatchString(color) {
+		return nil, errors.Errorf("invalid label color %q", color)
+	}
+
+	existing, err := TagDefinitions.Search("WHERE Name = ? AND (RepoID = ? OR RepoID = '')", name, repoID)
+	if err == nil && len(existing) > 0 {
+		return nil, errors.Errorf("label %q already exists", name)
+	}
+
+	return TagDefinitions.Insert(&TagDefinition{
+		Name:        name,
+		Category:    TagCategoryCustom,
+		Color:       strings.ToLower(color),
+		Description: strings.TrimSpace(description),
+		RepoID:      repoID,
+	})
+}
+
+// Edit renames, recolors or redescribes the label
+func (t *TagDefinition) Edit(name, color, description string) error {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return errors.New("label name is required")
+	}
+	if !labelColor.MatchString(color) {
+		return errors.Errorf("invalid label color %q", color)
+	}
+
+	if name != t.Name {
+		existing, err := TagDefinitions.Search("WHERE Name = ? AND (RepoID = ? OR RepoID = '')", name, t.RepoID)
+		if err == nil && len(existing) > 0 {
+			return errors.Errorf("label %q already exists", name)
+		}
+	}
+
+	t.Name = name
+	t.Color = strings.ToLower(color)
+	t.Description = strings.TrimSpace(description)
+	return TagDefinitions.Update(t)
+}
+
+// DeleteLabel removes a label and takes it off every issue
+func DeleteLabel(tag *TagDefinition) error {
+	if err := DB.Query("DELETE FROM issue_labels WHERE TagID = ?", tag.ID).Exec(); err != nil {
+		return errors.Wrap(err, "failed to remove label from issues")
+	}
+	return TagDefinitions.Delete(tag)
+}
+
+// SetIssueLabels replaces an issue's labels with the named ones, creating
+// the labels the repository doesn't have yet
+func SetIssueLabels(issue *Issue, names []string, userID string) error {
+	keep := map[string]bool{}
+	for _, name := range names {
+		tag, err := GetOrCreateTag(name, issue.RepoID)
+		if err != nil {
+			return errors.Wrapf(err, "failed to create label %s", name)
+		}
+		if tag == nil {
+			continue
+		}
+		keep[tag.ID] = true
+		if err := AddLabelToIssue(issue.ID, tag.ID, userID); err != nil {
+			return err
+		}
+	}
+
+	labels, err := IssueLabels.Search("WHERE IssueID = ?", issue.ID)
+	if err != nil {
+		return err
+	}
+	for _, label := range labels {
+		if !keep[label.TagID] {
+			if err := IssueLabels.Delete(label); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// IssueCount returns how many issues have the label
+func (t *TagDefinition) IssueCount() int {
+	return IssueLabels.Count("WHERE TagID = ?", t.ID)
+}
+
+// TextColor returns black or white, whichever reads better on the label
+func (t *TagDefinition) TextColor() string {
+	if !labelColor.MatchString(t.Color) {
+		return "#000000"
+	}
+	r, _ := strconv.ParseUint(t.Color[1:3], 16, 8)
+	g, _ := strconv.ParseUint(t.Color[3:5], 16, 8)
+	b, _ := strconv.ParseUint(t.Color[5:7], 16, 8)
+	if 299*r+587*g+114*b > 150000 {
+		return "#000000"
+	}
+	return "#ffffff"
+}
+
+// migrateIssueTags turns the plain-text tags issues used to have into
+// labels of their repositories
+func migrateIssueTags() {
+	tags, err := IssueTags.Search("")
+	if err != nil || len(tags) == 0 {
+		return
+	}
+
+	migrated := 0
+	for _, legacy := range tags {
+		if issue, err := Issues.Get(legacy.IssueID); err == nil {
+			tag, err := GetOrCreateTag(legacy.Tag, issue.RepoID)
+			if err != nil {
+				log.Printf("Failed to migrate tag %s of issue %s: %v", legacy.Tag, issue.ID, err)
+				continue
+			}
+			if tag != nil {
+				if err := AddLabelToIssue(issue.ID, tag.ID, ""); err != nil {
+					log.Printf("Failed to migrate tag %s of issue %s: %v", legacy.Tag, issue.ID, err)
+					continue
+				}
+			}
+		}
+		IssueTags.Delete(legacy)
+		migrated++
+	}
+	log.Printf("Migrated %d issue tags to labels", migrated)
+}
+
+// DeleteRepoLabels removes the labels and assignees of a repository's
+// issues, and the labels only it had
+func DeleteRepoLabels(repoID string) {
+	DB.Query("DELETE FROM issue_labels WHERE IssueID IN (SELECT ID FROM issues WHERE RepoID = ?)", repoID).Exec()
+	DB.Query("DELETE FROM issue_assignees WHERE IssueID IN (SELECT ID FROM issues WHERE RepoID = ?)", repoID).Exec()
+	DB.Query("DELETE FROM tag_definitions WHERE RepoID = ?", repoID).Exec()
+}
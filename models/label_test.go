@@ -0,0 +1,90 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/The-Skyscape/devtools/pkg/testutils"
+)
+
+func TestLabels(t *testing.T) {
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+
+	user := CreateTestUser(t, db, "labeler@example.com")
+	repo := createTestRepository(t, "labeled-repo", user.ID)
+	issue, err := CreateIssue("Crash on save", "", user.ID, repo.ID)
+	testutils.AssertNoError(t, err)
+
+	t.Run("CreateLabel", func(t *testing.T) {
+		_, err := CreateLabel(repo.ID, " ", "#ff0000", "")
+		testutils.AssertError(t, err)
+		_, err = CreateLabel(repo.ID, "design", "red", "")
+		testutils.AssertError(t, err)
+
+		label, err := CreateLabel(repo.ID, " Needs-Design ", "#FFAA00", "Waiting on mockups")
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, "needs-design", label.Name)
+		testutils.AssertEqual(t, "#ffaa00", label.Color)
+		testutils.AssertEqual(t, "#000000", label.TextColor())
+
+		_, err = CreateLabel(repo.ID, "needs-design", "#000000", "")
+		testutils.AssertError(t, err)
+		// System labels are global
+		_, err = CreateLabel(repo.ID, "bug", "#000000", "")
+		testutils.AssertError(t, err)
+
+		labels, err := GetRepoLabels(repo.ID)
+		testutils.AssertNoError(t, err)
+		found := false
+		for _, l := range labels {
+			found = found || l.ID == label.ID
+		}
+		testutils.AssertTrue(t, found)
+	})
+
+	t.Run("SetIssueLabels", func(t *testing.T) {
+		testutils.AssertNoError(t, SetIssueLabels(issue, []string{"bug", "needs-design", " "}, user.ID))
+		testutils.AssertEqual(t, 2, len(mustLabels(t, issue)))
+		testutils.AssertTrue(t, issue.HasLabel("bug"))
+
+		testutils.AssertNoError(t, SetIssueLabels(issue, []string{"needs-design", "regression", "flaky"}, user.ID))
+		testutils.AssertEqual(t, 3, len(mustLabels(t, issue)))
+		testutils.AssertFalse(t, issue.HasLabel("bug"))
+		testutils.AssertTrue(t, issue.HasLabel("flaky"))
+	})
+
+	t.Run("UpdateAndDelete", func(t *testing.T) {
+		label, err := GetOrCreateTag("flaky", repo.ID)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 1, label.IssueCount())
+
+		testutils.AssertError(t, label.Edit("needs-design", "#112233", ""))
+		testutils.AssertNoError(t, label.Edit("Intermittent", "#112233", "Fails now and then"))
+		testutils.AssertEqual(t, "intermittent", label.Name)
+		testutils.AssertEqual(t, "#ffffff", label.TextColor())
+		testutils.AssertTrue(t, issue.HasLabel("intermittent"))
+
+		testutils.AssertNoError(t, DeleteLabel(label))
+		testutils.AssertEqual(t, 2, len(mustLabels(t, issue)))
+		testutils.AssertFalse(t, issue.HasLabel("intermittent"))
+	})
+
+	t.Run("MigrateIssueTags", func(t *testing.T) {
+		_, err := IssueTags.Insert(&IssueTag{IssueID: issue.ID, Tag: "Stale"})
+		testutils.AssertNoError(t, err)
+		_, err = IssueTags.Insert(&IssueTag{IssueID: "deleted-issue", Tag: "stale"})
+		testutils.AssertNoError(t, err)
+
+		migrateIssueTags()
+		testutils.AssertTrue(t, issue.HasLabel("stale"))
+		testutils.AssertEqual(t, 0, IssueTags.Count(""))
+	})
+}
+
+// mustLabels returns the labels of an issue, failing the test on errors
+func mustLabels(t *testing.T, issue *Issue) []*TagDefinition {
+	t.Helper()
+	labels, err := issue.Labels()
+	testutils.AssertNoError(t, err)
+	return labels
+}
@@ -0,0 +1,148 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"regexp"
+
+	"github.com/The-Skyscape/devtools/pkg/application"
+	"github.com/pkg/errors"
+)
+
+// MaxLFSObjectSize is the largest file that can be pushed through Git LFS
+const MaxLFSObjectSize = 5 << 30
+
+// lfsOIDPattern matches the SHA-256 object IDs Git LFS names files by
+var lfsOIDPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// LFSObject is a large file pushed to a repository through Git LFS. The
+// content is kept in file storage, not in the database.
+type LFSObject struct {
+	application.Model
+	RepoID     string
+	OID        string // SHA-256 of the content
+	Size       int64
+	UploadedBy string
+}
+
+// Table returns the database table name
+func (*LFSObject) Table() string { return "lfs_objects" }
+
+// LFSUsage is how many LFS objects a repository has and their total size
+type LFSUsage struct {
+	Objects int
+	Size    int64
+}
+
+// SizeLabel formats the total size for display
+func (u LFSUsage) SizeLabel() string {
+	return formatBytes(u.Size)
+}
+
+// ValidLFSOID reports whether oid is a well formed Git LFS object ID
+func ValidLFSOID(oid string) bool {
+	return lfsOIDPattern.MatchString(oid)
+}
+
+// GetLFSObject returns a repository's LFS object
+func GetLFSObject(repoID, oid string) (*LFSObject, error) {
+	objects, err := LFSObjects.Search("WHERE RepoID = ? AND OID = ? LIMIT 1", repoID, oid)
+	if err != nil {
+		return nil, err
+	}
+	if len(objects) == 0 {
+		return nil, errors.New("LFS object not found")
+	}
+	return objects[0], nil
+}
+
+// StoreLFSObject stores an uploaded LFS object once its content matches
+// the object ID and size the client announced. Uploading an object the
+// repository already has keeps the stored copy.
+func StoreLFSObject(repoID, oid string, size int64, content io.Reader, userID string) (*LFSObject, error) {
+	if !ValidLFSOID(oid) {
+		return nil, errors.Errorf("invalid LFS object ID %q", oid)
+	}
+	if size < 0 || size > MaxLFSObjectSize {
+		return nil, errors.Errorf("LFS objects are limited to %d GB", MaxLFSObjectSize>>30)
+	}
+	if existing, err := GetLFSObject(repoID, oid); err == nil {
+		io.Copy(io.Discard, content)
+		return existing, nil
+	}
+
+	// Uploads are staged on local disk so nothing unverified reaches storage
+	tmp, err := os.CreateTemp("", "lfs-*")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to store LFS object")
+	}
+	defer os.Remove(tmp.Name())
+
+	hash := sha256.New()
+	written, err := io.Copy(io.MultiWriter(tmp, hash), io.LimitReader(content, size+1))
+	tmp.Close()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to store LFS object")
+	}
+	if written != size {
+		return nil, errors.Errorf("LFS object is %d bytes, expected %d", written, size)
+	}
+	if hex.EncodeToString(hash.Sum(nil)) != oid {
+		return nil, errors.New("LFS object content does not match its ID")
+	}
+
+	object := &LFSObject{
+		Model:      DB.NewModel(""),
+		RepoID:     repoID,
+		OID:        oid,
+		Size:       size,
+		UploadedBy: userID,
+	}
+	staged, err := os.Open(tmp.Name())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to store LFS object")
+	}
+	err = Storage().Put(object.Key(), staged)
+	staged.Close()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to store LFS object")
+	}
+
+	saved, err := LFSObjects.Insert(object)
+	if err != nil {
+		Storage().Delete(object.Key())
+		return nil, errors.Wrap(err, "failed to record LFS object")
+	}
+	return saved, nil
+}
+
+// Key returns where the object's content is kept in file storage
+func (o *LFSObject) Key() string {
+	return lfsPrefix(o.RepoID) + o.OID[:2] + "/" + o.OID[2:4] + "/" + o.OID
+}
+
+// Open opens the object's content
+func (o *LFSObject) Open() (io.ReadCloser, error) {
+	return Storage().Get(o.Key())
+}
+
+// LFSUsage returns how many LFS objects the repository has and their size
+func (r *Repository) LFSUsage() (LFSUsage, error) {
+	var usage LFSUsage
+	objects, err := LFSObjects.Search("WHERE RepoID = ?", r.ID)
+	if err != nil {
+		return usage, err
+	}
+	for _, object := range objects {
+		usage.Objects++
+		usage.Size += object.Size
+	}
+	return usage, nil
+}
+
+// lfsPrefix is where a repository's LFS objects are kept in file storage
+func lfsPrefix(repoID string) string {
+	return "lfs/" + repoID + "/"
+}
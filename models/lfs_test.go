@@ -0,0 +1,63 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/The-Skyscape/devtools/pkg/testutils"
+)
+
+func TestLFSObjects(t *testing.T) {
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+	t.Setenv("DATA_DIR", t.TempDir())
+	testutils.AssertNoError(t, ConfigureStorage(&Settings{}))
+
+	content := "large binary file"
+	sum := sha256.Sum256([]byte(content))
+	oid := hex.EncodeToString(sum[:])
+
+	t.Run("StoreAndOpen", func(t *testing.T) {
+		object, err := StoreLFSObject("repo-1", oid, int64(len(content)), strings.NewReader(content), "user-1")
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, oid, object.OID)
+
+		found, err := GetLFSObject("repo-1", oid)
+		testutils.AssertNoError(t, err)
+		file, err := found.Open()
+		testutils.AssertNoError(t, err)
+		data, _ := io.ReadAll(file)
+		file.Close()
+		testutils.AssertEqual(t, content, string(data))
+
+		_, err = GetLFSObject("repo-2", oid)
+		testutils.AssertError(t, err)
+	})
+
+	t.Run("RejectsMismatchedContent", func(t *testing.T) {
+		_, err := StoreLFSObject("repo-2", oid, int64(len(content)), strings.NewReader("something else!!!"), "user-1")
+		testutils.AssertError(t, err)
+
+		_, err = StoreLFSObject("repo-2", oid, int64(len(content))+1, strings.NewReader(content), "user-1")
+		testutils.AssertError(t, err)
+
+		_, err = StoreLFSObject("repo-2", "not-an-oid", 1, strings.NewReader("x"), "user-1")
+		testutils.AssertError(t, err)
+
+		_, err = GetLFSObject("repo-2", oid)
+		testutils.AssertError(t, err)
+	})
+
+	t.Run("UsageCountsEachObjectOnce", func(t *testing.T) {
+		_, err := StoreLFSObject("repo-1", oid, int64(len(content)), strings.NewReader(content), "user-1")
+		testutils.AssertNoError(t, err)
+
+		usage, err := (&Repository{Model: DB.NewModel("repo-1")}).LFSUsage()
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 1, usage.Objects)
+		testutils.AssertEqual(t, int64(len(content)), usage.Size)
+	})
+}
@@ -0,0 +1,171 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"workspace/internal/lint"
+
+	"github.com/The-Skyscape/devtools/pkg/application"
+	"github.com/pkg/errors"
+)
+
+// Where lint runs come from
+const (
+	LintSourceWorkflow  = "workflow"
+	LintSourceAssistant = "assistant"
+)
+
+const (
+	// maxLintRuns is how many lint runs are kept for each repository
+	maxLintRuns = 100
+
+	// maxLintAnnotations caps the comments one lint run posts on a pull
+	// request
+	maxLintAnnotations = 20
+)
+
+// LintRun is what the linters found at a commit of a repository, run by a
+// workflow step or the assistant
+type LintRun struct {
+	application.Model
+	RepoID    string
+	Source    string // LintSourceWorkflow or LintSourceAssistant
+	Name      string // The workflow job's status context, or the linters the assistant ran
+	URL       string // Where to see the run
+	Branch    string
+	CommitSHA string
+	Linters   string // Linters that ran, one per line
+	Skipped   string // Linters that didn't and why, one per line
+	Issues    int
+	Security  int    // Issues that are security problems
+	Truncated bool   // More issues were found than were kept
+	Findings  string // JSON encoded []lint.Issue
+}
+
+// Table returns the database table name
+func (*LintRun) Table() string { return "lint_runs" }
+
+// RecordLintRun saves what the linters reported, dropping the repository's
+// oldest runs beyond maxLintRuns
+func RecordLintRun(run *LintRun, report *lint.Report) (*LintRun, error) {
+	findings, err := json.Marshal(report.Issues)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encode lint findings")
+	}
+	run.Linters = strings.Join(report.Ran, "\n")
+	run.Skipped = strings.Join(report.Skipped, "\n")
+	run.Issues = len(report.Issues)
+	run.Security = len(report.Security())
+	run.Truncated = report.Truncated
+	run.Findings = string(findings)
+
+	run, err = LintRuns.Insert(run)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to save lint run")
+	}
+	old := "SELECT ID FROM lint_runs WHERE RepoID = ? ORDER BY CreatedAt DESC LIMIT -1 OFFSET ?"
+	if err := DB.Query("DELETE FROM lint_runs WHERE ID IN ("+old+")", run.RepoID, maxLintRuns).Exec(); err != nil {
+		return nil, errors.Wrap(err, "failed to prune lint runs")
+	}
+	return run, nil
+}
+
+// LatestLintRun returns the newest lint run on a branch of the repository,
+// or nil when its linters never ran there
+func LatestLintRun(repoID, branch string) *LintRun {
+	runs, err := LintRuns.Search("WHERE RepoID = ? AND Branch = ? ORDER BY CreatedAt DESC LIMIT 1", repoID, branch)
+	if err != nil || len(runs) == 0 {
+		return nil
+	}
+	return runs[0]
+}
+
+// IssueList decodes the run's findings
+func (r *LintRun) IssueList() []lint.Issue {
+	var issues []lint.Issue
+	if err := json.Unmarshal([]byte(r.Findings), &issues); err != nil {
+		return nil
+	}
+	return issues
+}
+
+// SecurityIssues returns the run's findings that are security problems
+func (r *LintRun) SecurityIssues() []lint.Issue {
+	var issues []lint.Issue
+	for _, issue := range r.IssueList() {
+		if issue.Security {
+			issues = append(issues, issue)
+		}
+	}
+	return issues
+}
+
+// LinterList returns the linters that ran
+func (r *LintRun) LinterList() []string {
+	return splitLines(r.Linters)
+}
+
+// SkippedList returns the linters that didn't run and why
+func (r *LintRun) SkippedList() []string {
+	return splitLines(r.Skipped)
+}
+
+// Annotate comments on each line the pull request adds that the linters
+// found a problem with, up to maxLintAnnotations, and returns how many
+// comments it posted. Nothing is posted when the run wasn't at the pull
+// request's head, and lines already annotated with the same finding are
+// skipped so reruns don't repeat themselves.
+func (r *LintRun) Annotate(pr *PullRequest, authorID string) (int, error) {
+	head, err := pr.HeadCommit()
+	if err != nil || head != r.CommitSHA {
+		return 0, err
+	}
+	files, err := pr.FileDiffs()
+	if err != nil {
+		return 0, err
+	}
+	byPath := make(map[string]*FileDiff, len(files))
+	for _, file := range files {
+		byPath[file.Path] = file
+	}
+
+	posted := 0
+	for _, issue := range r.IssueList() {
+		if posted >= maxLintAnnotations {
+			break
+		}
+		file := byPath[issue.File]
+		if file == nil {
+			continue
+		}
+		if line := file.line(issue.Line); line == nil || line.Type != "add" {
+			continue
+		}
+		body := lintAnnotation(issue)
+		if existing, err := Comments.Search("WHERE EntityType = 'pr' AND EntityID = ? AND FilePath = ? AND LineNumber = ? AND Body = ?",
+			pr.ID, issue.File, issue.Line, body); err == nil && len(existing) > 0 {
+			continue
+		}
+		if _, err := CreateReviewComment(pr, authorID, body, issue.File, issue.Line); err != nil {
+			return posted, err
+		}
+		posted++
+	}
+	return posted, nil
+}
+
+// lintAnnotation is the body of the comment a finding is posted as
+func lintAnnotation(issue lint.Issue) string {
+	kind := "Lint"
+	if issue.Security {
+		kind = "Security"
+	}
+	return fmt.Sprintf("**%s** (%s, %s): %s", kind, issue.Title(), issue.Severity, issue.Message)
+}
+
+// DeleteRepoLintRuns removes the lint runs of a deleted repository
+func DeleteRepoLintRuns(repoID string) {
+	DB.Query("DELETE FROM lint_runs WHERE RepoID = ?", repoID).Exec()
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"testing"
+
+	"workspace/internal/lint"
+
+	"github.com/The-Skyscape/devtools/pkg/testutils"
+)
+
+func TestLintRuns(t *testing.T) {
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+
+	report := &lint.Report{
+		Ran:     []string{"govet", "gosec"},
+		Skipped: []string{"eslint: no package.json"},
+		Issues: []lint.Issue{
+			{Linter: "govet", Severity: lint.SeverityMedium, File: "main.go", Line: 4, Message: "unreachable code"},
+			{Linter: "gosec", Rule: "G101", Severity: lint.SeverityHigh, File: "config.go", Line: 7, Message: "Potential hardcoded credentials", Security: true},
+		},
+	}
+	_, err := RecordLintRun(&LintRun{RepoID: "shop", Source: LintSourceWorkflow, Branch: "feature", CommitSHA: "abc123"}, report)
+	testutils.AssertNoError(t, err)
+
+	t.Run("Latest", func(t *testing.T) {
+		run := LatestLintRun("shop", "feature")
+		testutils.AssertTrue(t, run != nil)
+		testutils.AssertEqual(t, 2, run.Issues)
+		testutils.AssertEqual(t, 1, run.Security)
+		testutils.AssertEqual(t, 2, len(run.LinterList()))
+		testutils.AssertEqual(t, "eslint: no package.json", run.SkippedList()[0])
+
+		issues := run.IssueList()
+		testutils.AssertEqual(t, 2, len(issues))
+		testutils.AssertEqual(t, "unreachable code", issues[0].Message)
+
+		security := run.SecurityIssues()
+		testutils.AssertEqual(t, 1, len(security))
+		testutils.AssertEqual(t, "G101", security[0].Rule)
+
+		testutils.AssertTrue(t, LatestLintRun("shop", "main") == nil)
+	})
+
+	t.Run("Annotation", func(t *testing.T) {
+		testutils.AssertEqual(t, "**Security** (gosec G101, high): Potential hardcoded credentials", lintAnnotation(report.Issues[1]))
+		testutils.AssertEqual(t, "**Lint** (govet, medium): unreachable code", lintAnnotation(report.Issues[0]))
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		DeleteRepoLintRuns("shop")
+		testutils.AssertTrue(t, LatestLintRun("shop", "feature") == nil)
+	})
+}
@@ -0,0 +1,123 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/The-Skyscape/devtools/pkg/application"
+	"github.com/pkg/errors"
+)
+
+// Ways the connection to the SMTP server is secured
+const (
+	MailStartTLS = "starttls" // Upgraded with STARTTLS when the server offers it
+	MailTLS      = "tls"      // TLS from the start, usually on port 465
+	MailPlain    = "none"     // Never encrypted, for relays on the same host
+)
+
+// MailSecretKey is where the SMTP password is kept in the vault
+const MailSecretKey = "mail/smtp"
+
+// DigestInterval is how often users are emailed their unread notifications
+const DigestInterval = 24 * time.Hour
+
+// MailEnabled returns true when outgoing mail has a server in settings
+func (s *Settings) MailEnabled() bool {
+	return s.MailHost != ""
+}
+
+// MailAddress returns the workspace's public address without a trailing
+// slash, for links in emails
+func (s *Settings) MailAddress() string {
+	return strings.TrimRight(s.PublicURL, "/")
+}
+
+// StoreMailPassword stores the password outgoing mail signs in with
+func StoreMailPassword(password string) error {
+	return StoreSecret(MailSecretKey, map[string]any{"password": password})
+}
+
+// GetMailPassword returns the password outgoing mail signs in with
+func GetMailPassword() (string, error) {
+	secret, err := Secrets.GetSecret(MailSecretKey)
+	if err != nil {
+		return "", err
+	}
+	password, _ := secret["password"].(string)
+	if password == "" {
+		return "", errors.New("mail password not found")
+	}
+	return password, nil
+}
+
+// DeleteMailPassword removes the password outgoing mail signs in with
+func DeleteMailPassword() error {
+	return DeleteSecret(MailSecretKey)
+}
+
+// MailPreference is what a user wants emailed to them. Transactional mail,
+// such as password resets, is always sent.
+type MailPreference struct {
+	application.Model
+	UserID       string
+	DigestsOff   bool      // Opted out of notification digests
+	Token        string    // Identifies the user in unsubscribe links
+	LastDigestAt time.Time // When the user was last sent a digest
+}
+
+// Table returns the database table name
+func (*MailPreference) Table() string { return "mail_preferences" }
+
+// GetMailPreference returns a user's mail preference, creating the default
+// of receiving digests
+func GetMailPreference(userID string) (*MailPreference, error) {
+	if userID == "" {
+		return nil, errors.New("user ID required")
+	}
+	if prefs, err := MailPreferences.Search("WHERE UserID = ?", userID); err == nil && len(prefs) > 0 {
+		return prefs[0], nil
+	}
+	pref, err := MailPreferences.Insert(&MailPreference{
+		Model:  DB.NewModel(""),
+		UserID: userID,
+		Token:  GenerateToken(),
+	})
+	return pref, errors.Wrap(err, "failed to save mail preference")
+}
+
+// MailPreferenceByToken returns the preference an unsubscribe link names
+func MailPreferenceByToken(token string) (*MailPreference, error) {
+	if token == "" {
+		return nil, errors.New("unsubscribe link is invalid")
+	}
+	prefs, err := MailPreferences.Search("WHERE Token = ?", token)
+	if err != nil || len(prefs) == 0 {
+		return nil, errors.New("unsubscribe link is invalid")
+	}
+	return prefs[0], nil
+}
+
+// SetDigests turns the user's notification digests on or off
+func (p *MailPreference) SetDigests(on bool) error {
+	p.DigestsOff = !on
+	return MailPreferences.Update(p)
+}
+
+// DigestDue returns true when the user wants digests and hasn't had one
+// within the digest interval
+func (p *MailPreference) DigestDue(now time.Time) bool {
+	return !p.DigestsOff && now.Sub(p.LastDigestAt) >= DigestInterval
+}
+
+// DigestNotifications returns the user's unread notifications that arrived
+// since their last digest, oldest first
+func (p *MailPreference) DigestNotifications() ([]*Notification, error) {
+	return Notifications.Search("WHERE UserID = ? AND Read = ? AND CreatedAt > ? ORDER BY CreatedAt ASC",
+		p.UserID, false, p.LastDigestAt)
+}
+
+// DigestSent records that the user was sent a digest
+func (p *MailPreference) DigestSent(at time.Time) error {
+	p.LastDigestAt = at
+	return MailPreferences.Update(p)
+}
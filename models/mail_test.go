@@ -0,0 +1,55 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/The-Skyscape/devtools/pkg/testutils"
+)
+
+func TestMailPreferences(t *testing.T) {
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+
+	user := CreateTestUser(t, db, "mail@example.com")
+
+	t.Run("GetMailPreference", func(t *testing.T) {
+		pref, err := GetMailPreference(user.ID)
+		testutils.AssertNoError(t, err)
+		testutils.AssertFalse(t, pref.DigestsOff)
+		testutils.AssertTrue(t, pref.Token != "")
+
+		again, err := GetMailPreference(user.ID)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, pref.ID, again.ID)
+
+		_, err = GetMailPreference("")
+		testutils.AssertError(t, err)
+	})
+
+	t.Run("Unsubscribe", func(t *testing.T) {
+		pref, err := GetMailPreference(user.ID)
+		testutils.AssertNoError(t, err)
+
+		found, err := MailPreferenceByToken(pref.Token)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, user.ID, found.UserID)
+		_, err = MailPreferenceByToken("unknown")
+		testutils.AssertError(t, err)
+
+		testutils.AssertTrue(t, found.DigestDue(time.Now()))
+		testutils.AssertNoError(t, found.SetDigests(false))
+		testutils.AssertFalse(t, found.DigestDue(time.Now()))
+	})
+
+	t.Run("DigestDue", func(t *testing.T) {
+		pref, err := GetMailPreference(user.ID)
+		testutils.AssertNoError(t, err)
+		testutils.AssertNoError(t, pref.SetDigests(true))
+
+		now := time.Now()
+		testutils.AssertNoError(t, pref.DigestSent(now))
+		testutils.AssertFalse(t, pref.DigestDue(now.Add(time.Hour)))
+		testutils.AssertTrue(t, pref.DigestDue(now.Add(DigestInterval)))
+	})
+}
@@ -0,0 +1,147 @@
+package models
+
+import (
+	"sort"
+	"strings"
+
+	"workspace/internal/mentions"
+
+	"github.com/The-Skyscape/devtools/pkg/application"
+	"github.com/The-Skyscape/devtools/pkg/authentication"
+	"github.com/pkg/errors"
+)
+
+// mentionExcerptLength is how much of the mentioning text a notification
+// quotes
+const mentionExcerptLength = 200
+
+// Mention records that an issue, pull request, comment or assistant
+// message mentions a user by handle, so editing the text doesn't notify
+// them again
+type Mention struct {
+	application.Model
+	RepoID     string // Empty for conversations outside a repository
+	SourceType string // RefIssue, RefPullRequest, RefComment or RefMessage
+	SourceID   string
+	UserID     string
+	AuthorID   string
+}
+
+// Table returns the database table name
+func (*Mention) Table() string { return "mentions" }
+
+// RecordMentions notifies the users a source's text mentions by handle,
+// returning who was notified. Users mentioned by an earlier version of the
+// text, the author, and users who can't see the repository aren't
+// notified.
+func RecordMentions(repoID, sourceType, sourceID, text, authorID string) ([]*authentication.User, error) {
+	handles := mentions.Parse(text)
+	if len(handles) == 0 {
+		return nil, nil
+	}
+
+	var repo *Repository
+	if repoID != "" {
+		var err error
+		if repo, err = Repositories.Get(repoID); err != nil {
+			return nil, errors.Wrap(err, "repository not found")
+		}
+	}
+
+	author := "Someone"
+	if authorID != "" {
+		if user, err := Users.Get(authorID); err == nil {
+			author = user.Name
+		}
+	}
+	source := &CrossReference{RepoID: repoID, SourceType: sourceType, SourceID: sourceID}
+
+	var notified []*authentication.User
+	for _, handle := range handles {
+		user := userByHandle(handle)
+		if user == nil || user.ID == authorID || !CanMention(user, repo) {
+			continue
+		}
+		if Mentions.Count("WHERE SourceType = ? AND SourceID = ? AND UserID = ?", sourceType, sourceID, user.ID) > 0 {
+			continue
+		}
+
+		_, err := Mentions.Insert(&Mention{
+			Model:      DB.NewModel(""),
+			RepoID:     repoID,
+			SourceType: sourceType,
+			SourceID:   sourceID,
+			UserID:     user.ID,
+			AuthorID:   authorID,
+		})
+		if err != nil {
+			return notified, errors.Wrap(err, "failed to record mention")
+		}
+		if err := Notify(user.ID, "mentioned", author+" mentioned you: "+source.SourceTitle(),
+			mentionExcerpt(text), source.SourceURL(), repoID); err != nil {
+			return notified, err
+		}
+		notified = append(notified, user)
+	}
+	return notified, nil
+}
+
+// MentionableUsers returns the users whose handle or name starts with
+// prefix and who can see the repository, by handle. A nil repository is
+// the workspace itself, which everyone can see.
+func MentionableUsers(repo *Repository, prefix string, limit int) ([]*authentication.User, error) {
+	users, err := Users.Search("WHERE Handle != '' ORDER BY Handle ASC")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list users")
+	}
+
+	prefix = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(prefix), "@"))
+	var matches []*authentication.User
+	for _, user := range users {
+		if !CanMention(user, repo) {
+			continue
+		}
+		if strings.HasPrefix(strings.ToLower(user.Handle), prefix) || strings.HasPrefix(strings.ToLower(user.Name), prefix) {
+			matches = append(matches, user)
+		}
+	}
+
+	// Handles starting with the prefix come before names that do
+	sort.SliceStable(matches, func(i, j int) bool {
+		return strings.HasPrefix(strings.ToLower(matches[i].Handle), prefix) &&
+			!strings.HasPrefix(strings.ToLower(matches[j].Handle), prefix)
+	})
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches, nil
+}
+
+// CanMention reports whether a user can be mentioned in a repository,
+// which takes being able to read it
+func CanMention(user *authentication.User, repo *Repository) bool {
+	return repo == nil || RepoRole(user, repo) != ""
+}
+
+// DeleteRepoMentions removes the mentions made in a repository
+func DeleteRepoMentions(repoID string) {
+	DB.Query("DELETE FROM mentions WHERE RepoID = ?", repoID).Exec()
+}
+
+// userByHandle returns the user with a handle, ignoring case, or nil
+func userByHandle(handle string) *authentication.User {
+	users, err := Users.Search("WHERE Handle = ? COLLATE NOCASE LIMIT 1", handle)
+	if err != nil || len(users) == 0 {
+		return nil
+	}
+	return users[0]
+}
+
+// mentionExcerpt shortens text for a notification's body
+func mentionExcerpt(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if runes := []rune(text); len(runes) > mentionExcerptLength {
+		return string(runes[:mentionExcerptLength-1]) + "…"
+	}
+	return text
+}
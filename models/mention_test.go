@@ -0,0 +1,65 @@
+package models
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/The-Skyscape/devtools/pkg/testutils"
+)
+
+func TestMentions(t *testing.T) {
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+
+	author := CreateTestUser(t, db, "author@example.com")
+	admin := CreateTestUser(t, db, "admin@example.com")
+	admin.IsAdmin = true
+	testutils.AssertNoError(t, Users.Update(admin))
+	guest := CreateTestUser(t, db, "guest@example.com")
+
+	private := createTestRepository(t, "mentions-private", author.ID)
+	private.Visibility = "private"
+	testutils.AssertNoError(t, Repositories.Update(private))
+
+	issue, err := CreateIssue("Broken build", "", author.ID, private.ID)
+	testutils.AssertNoError(t, err)
+
+	t.Run("RecordMentions", func(t *testing.T) {
+		text := "@" + admin.Handle + " and @" + guest.Handle + " please look, cc @" + author.Handle + " @nobody"
+		notified, err := RecordMentions(private.ID, RefIssue, issue.ID, text, author.ID)
+		testutils.AssertNoError(t, err)
+
+		// Guests can't see private repositories and authors aren't notified
+		testutils.AssertEqual(t, 1, len(notified))
+		testutils.AssertEqual(t, admin.ID, notified[0].ID)
+		testutils.AssertEqual(t, 1, CountUnreadNotifications(admin.ID))
+		testutils.AssertEqual(t, 0, CountUnreadNotifications(guest.ID))
+
+		// Editing the text doesn't notify again
+		notified, err = RecordMentions(private.ID, RefIssue, issue.ID, text+" again", author.ID)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 0, len(notified))
+		testutils.AssertEqual(t, 1, CountUnreadNotifications(admin.ID))
+
+		// Conversations outside repositories can mention anyone
+		notified, err = RecordMentions("", RefMessage, "message-1", "@"+strings.ToUpper(guest.Handle), author.ID)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 1, len(notified))
+		testutils.AssertEqual(t, guest.ID, notified[0].ID)
+	})
+
+	t.Run("MentionableUsers", func(t *testing.T) {
+		users, err := MentionableUsers(private, "ADMIN", 10)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 1, len(users))
+		testutils.AssertEqual(t, admin.ID, users[0].ID)
+
+		users, err = MentionableUsers(private, "guest", 10)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 0, len(users))
+
+		users, err = MentionableUsers(nil, "@", 2)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 2, len(users))
+	})
+}
@@ -0,0 +1,139 @@
+package models
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/The-Skyscape/devtools/pkg/application"
+	"github.com/pkg/errors"
+)
+
+// MergeCheck is the last look at whether a pull request merges cleanly:
+// how far its branch is ahead of and behind the base branch, and which
+// files conflict. It is stored with the pull request ID as its ID and
+// checked again once either branch moves.
+type MergeCheck struct {
+	application.Model
+	RepoID     string
+	BaseCommit string // Base branch head when checked
+	HeadCommit string // Compare branch head when checked
+	Ahead      int    // Commits on the compare branch missing from the base
+	Behind     int    // Commits on the base branch missing from the compare branch
+	Conflicts  string // Conflicting files, one per line
+	Error      string // Why the check couldn't run, such as a deleted branch
+}
+
+// Table returns the database table name
+func (*MergeCheck) Table() string { return "merge_checks" }
+
+// ConflictList returns the files that conflict
+func (m *MergeCheck) ConflictList() []string {
+	if m.Conflicts == "" {
+		return nil
+	}
+	return strings.Split(m.Conflicts, "\n")
+}
+
+// HasConflicts reports whether merging would conflict
+func (m *MergeCheck) HasConflicts() bool {
+	return m.Conflicts != ""
+}
+
+// CheckMerge works out whether the pull request merges cleanly now and
+// stores the result
+func CheckMerge(pr *PullRequest) (*MergeCheck, error) {
+	repo, err := pr.Repository()
+	if err != nil {
+		return nil, errors.New("repository not found")
+	}
+
+	check := &MergeCheck{Model: DB.NewModel(pr.ID), RepoID: pr.RepoID}
+	if existing, err := MergeChecks.Get(pr.ID); err == nil {
+		check = existing
+	}
+	check.BaseCommit, check.HeadCommit = branchHead(repo, pr.BaseBranch), branchHead(repo, pr.CompareBranch)
+	check.Ahead, check.Behind, check.Conflicts, check.Error = 0, 0, "", ""
+
+	switch {
+	case check.BaseCommit == "":
+		check.Error = "branch " + pr.BaseBranch + " no longer exists"
+	case check.HeadCommit == "":
+		check.Error = "branch " + pr.CompareBranch + " no longer exists"
+	default:
+		if check.Ahead, check.Behind, err = repo.AheadBehind(check.BaseCommit, check.HeadCommit); err != nil {
+			check.Error = err.Error()
+			break
+		}
+		conflicts, err := repo.MergeConflicts(check.BaseCommit, check.HeadCommit)
+		if err != nil {
+			check.Error = err.Error()
+			break
+		}
+		check.Conflicts = strings.Join(conflicts, "\n")
+	}
+
+	if _, err := MergeChecks.Get(check.ID); err != nil {
+		return MergeChecks.Insert(check)
+	}
+	check.UpdatedAt = time.Now()
+	return check, MergeChecks.Update(check)
+}
+
+// MergeCheck returns whether the pull request merges cleanly, checking
+// again when either branch moved since the last check
+func (pr *PullRequest) MergeCheck() (*MergeCheck, error) {
+	check, err := MergeChecks.Get(pr.ID)
+	if err != nil {
+		return CheckMerge(pr)
+	}
+	repo, err := pr.Repository()
+	if err != nil {
+		return nil, errors.New("repository not found")
+	}
+	if check.BaseCommit != branchHead(repo, pr.BaseBranch) || check.HeadCommit != branchHead(repo, pr.CompareBranch) {
+		return CheckMerge(pr)
+	}
+	return check, nil
+}
+
+// RefreshMergeChecks checks the repository's open pull requests again
+// after its branches moved, such as after a push or a merge
+func RefreshMergeChecks(repoID string) error {
+	prs, err := PullRequests.Search("WHERE RepoID = ? AND Status = 'open'", repoID)
+	if err != nil {
+		return errors.Wrap(err, "failed to find open pull requests")
+	}
+	for _, pr := range prs {
+		if _, err := pr.MergeCheck(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AheadBehind counts the commits on compare that base doesn't have, and
+// the commits on base that compare doesn't have
+func (r *Repository) AheadBehind(base, compare string) (ahead, behind int, err error) {
+	stdout, stderr, err := r.Git("rev-list", "--left-right", "--count", base+"..."+compare)
+	if err != nil {
+		return 0, 0, errors.Wrap(err, strings.TrimSpace(stderr.String()))
+	}
+	counts := strings.Fields(stdout.String())
+	if len(counts) != 2 {
+		return 0, 0, errors.Errorf("unexpected rev-list output %q", stdout.String())
+	}
+	behind, _ = strconv.Atoi(counts[0])
+	ahead, _ = strconv.Atoi(counts[1])
+	return ahead, behind, nil
+}
+
+// branchHead returns the commit a branch points at, or nothing when it
+// doesn't exist
+func branchHead(repo *Repository, branch string) string {
+	stdout, _, err := repo.Git("rev-parse", "--verify", "--quiet", "refs/heads/"+branch)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(stdout.String())
+}
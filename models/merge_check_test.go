@@ -0,0 +1,99 @@
+package models
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/The-Skyscape/devtools/pkg/testutils"
+)
+
+// conflictingRepo creates a repository whose feature branch changes the
+// same line of file.txt as main, returning a pull request for it and a
+// function to run git in a clone
+func conflictingRepo(t *testing.T, name string) (*Repository, *PullRequest, func(args ...string)) {
+	t.Helper()
+	repo, err := Repositories.Insert(&Repository{Name: name})
+	testutils.AssertNoError(t, err)
+
+	work := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = work
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=Test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=Test", "GIT_COMMITTER_EMAIL=test@example.com")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %s", strings.Join(args, " "), out)
+		}
+	}
+	write := func(content string) {
+		os.WriteFile(filepath.Join(work, "file.txt"), []byte(content), 0644)
+		git("add", "file.txt")
+	}
+
+	git("init", "--bare", "-b", "main", repo.Path())
+	git("init", "-b", "main")
+	write("one\ntwo\nthree\n")
+	git("commit", "-m", "first")
+	git("checkout", "-b", "feature")
+	write("one\nfeature\nthree\n")
+	git("commit", "-m", "feature")
+	git("checkout", "main")
+	write("one\nmain\nthree\n")
+	git("commit", "-m", "main")
+	git("push", repo.Path(), "main", "feature")
+
+	pr, err := PullRequests.Insert(&PullRequest{
+		Title: "Feature", RepoID: repo.ID, AuthorID: "author",
+		BaseBranch: "main", CompareBranch: "feature", Status: "open",
+	})
+	testutils.AssertNoError(t, err)
+	return repo, pr, git
+}
+
+func TestMergeCheck(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+	t.Setenv("DATA_DIR", t.TempDir())
+
+	repo, pr, git := conflictingRepo(t, "checked")
+
+	t.Run("FindsConflicts", func(t *testing.T) {
+		check, err := CheckMerge(pr)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, "", check.Error)
+		testutils.AssertEqual(t, 1, check.Ahead)
+		testutils.AssertEqual(t, 1, check.Behind)
+		testutils.AssertTrue(t, check.HasConflicts())
+		testutils.AssertEqual(t, "file.txt", strings.Join(check.ConflictList(), ","))
+	})
+
+	t.Run("ChecksAgainWhenBranchesMove", func(t *testing.T) {
+		git("push", "--force", repo.Path(), "main:feature")
+		check, err := pr.MergeCheck()
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 0, check.Ahead)
+		testutils.AssertEqual(t, 0, check.Behind)
+		testutils.AssertFalse(t, check.HasConflicts())
+
+		checks, err := MergeChecks.Search("WHERE RepoID = ?", repo.ID)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 1, len(checks))
+	})
+
+	t.Run("MissingBranch", func(t *testing.T) {
+		git("push", repo.Path(), ":feature")
+		testutils.AssertNoError(t, RefreshMergeChecks(repo.ID))
+		check, err := MergeChecks.Get(pr.ID)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, "branch feature no longer exists", check.Error)
+	})
+}
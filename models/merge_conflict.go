@@ -0,0 +1,282 @@
+package models
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Ways to resolve one conflicting hunk
+const (
+	ConflictKeepBase   = "base"   // The base branch's lines
+	ConflictKeepHead   = "head"   // The pull request branch's lines
+	ConflictKeepBoth   = "both"   // Base lines, then the pull request's
+	ConflictKeepCustom = "custom" // Lines written in the editor
+)
+
+// ConflictFile is a file that conflicts when merging a pull request,
+// split into the text both branches agree on and the hunks they don't
+type ConflictFile struct {
+	Path       string
+	Sections   []ConflictSection
+	Hunks      int    // How many sections conflict
+	Unresolved string // Why the file can't be resolved in the browser, if it can't
+}
+
+// ConflictSection is a run of lines in a conflicting file. Conflicting
+// sections hold each branch's version, the rest hold the merged text.
+type ConflictSection struct {
+	Conflict bool
+	Hunk     int    // Position among the file's conflicting sections
+	Text     string // Merged text, when not conflicting
+	Base     string // Base branch lines, when conflicting
+	Head     string // Pull request branch lines, when conflicting
+}
+
+// ConflictPick is how one hunk was resolved in the editor
+type ConflictPick struct {
+	Keep   string // ConflictKeepBase, ConflictKeepHead, ConflictKeepBoth or ConflictKeepCustom
+	Custom string // Replacement lines for ConflictKeepCustom
+}
+
+// CanResolve reports whether the file's conflicts can be resolved in the
+// browser
+func (f *ConflictFile) CanResolve() bool {
+	return f.Unresolved == ""
+}
+
+// Resolve builds the file's content from a pick for each hunk
+func (f *ConflictFile) Resolve(picks []ConflictPick) (string, error) {
+	if !f.CanResolve() {
+		return "", errors.Errorf("%s: %s", f.Path, f.Unresolved)
+	}
+	if len(picks) != f.Hunks {
+		return "", errors.Errorf("%s: resolve all %d conflicts", f.Path, f.Hunks)
+	}
+
+	var content strings.Builder
+	for _, section := range f.Sections {
+		if !section.Conflict {
+			content.WriteString(section.Text)
+			continue
+		}
+		pick := picks[section.Hunk]
+		switch pick.Keep {
+		case ConflictKeepBase:
+			content.WriteString(section.Base)
+		case ConflictKeepHead:
+			content.WriteString(section.Head)
+		case ConflictKeepBoth:
+			content.WriteString(section.Base + section.Head)
+		case ConflictKeepCustom:
+			custom := strings.ReplaceAll(pick.Custom, "\r\n", "\n")
+			if custom != "" && !strings.HasSuffix(custom, "\n") {
+				custom += "\n"
+			}
+			content.WriteString(custom)
+		default:
+			return "", errors.Errorf("%s: choose how to resolve conflict %d", f.Path, section.Hunk+1)
+		}
+	}
+
+	if _, _, ok := parseConflictMarkers(content.String()); ok {
+		return "", errors.Errorf("%s still has conflict markers", f.Path)
+	}
+	return content.String(), nil
+}
+
+// ConflictFiles returns the files that conflict when merging the pull
+// request, with their conflicting hunks
+func (pr *PullRequest) ConflictFiles() ([]*ConflictFile, error) {
+	repo, err := pr.Repository()
+	if err != nil {
+		return nil, errors.New("repository not found")
+	}
+	tree, paths, err := repo.mergeTree("refs/heads/"+pr.BaseBranch, "refs/heads/"+pr.CompareBranch)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to merge branches")
+	}
+
+	files := make([]*ConflictFile, 0, len(paths))
+	for _, path := range paths {
+		file := &ConflictFile{Path: path}
+		files = append(files, file)
+
+		stdout, _, err := repo.Git("cat-file", "blob", tree+":"+path)
+		if err != nil {
+			file.Unresolved = "missing from the merge"
+			continue
+		}
+		if bytes.IndexByte(stdout.Bytes(), 0) >= 0 {
+			file.Unresolved = "binary file"
+			continue
+		}
+		sections, hunks, ok := parseConflictMarkers(stdout.String())
+		if !ok {
+			file.Unresolved = "deleted, renamed or changed type on one branch"
+			continue
+		}
+		file.Sections, file.Hunks = sections, hunks
+	}
+	return files, nil
+}
+
+// ResolveConflicts commits a merge of the base branch into the pull
+// request's branch with the conflicting files replaced by their resolved
+// content, so the pull request merges cleanly. headCommit is the branch
+// head the resolutions were made against; if the branch has moved since,
+// nothing is committed.
+func ResolveConflicts(pr *PullRequest, headCommit string, resolved map[string]string, authorName, authorEmail string) (string, error) {
+	repo, err := pr.Repository()
+	if err != nil {
+		return "", errors.New("repository not found")
+	}
+	base, head := branchHead(repo, pr.BaseBranch), branchHead(repo, pr.CompareBranch)
+	if base == "" || head == "" {
+		return "", errors.New("both branches must exist to resolve conflicts")
+	}
+	if head != headCommit {
+		return "", errors.Errorf("%s has changed since the conflicts were loaded, reload and resolve them again", pr.CompareBranch)
+	}
+	if IsPushProtected(pr.RepoID, pr.CompareBranch) {
+		return "", errors.Errorf("%s is protected, resolve the conflicts in another branch", pr.CompareBranch)
+	}
+
+	tree, paths, err := repo.mergeTree(base, head)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to merge branches")
+	}
+	if len(paths) == 0 {
+		return "", errors.New("the pull request has no conflicts to resolve")
+	}
+
+	// Replace the conflicting files in a scratch index built from the
+	// merged tree, leaving the repository's own index alone
+	git, done, err := scratchIndex(repo,
+		"GIT_AUTHOR_NAME="+authorName,
+		"GIT_AUTHOR_EMAIL="+authorEmail,
+		"GIT_COMMITTER_NAME="+authorName,
+		"GIT_COMMITTER_EMAIL="+authorEmail,
+	)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to resolve conflicts")
+	}
+	defer done()
+
+	if _, err := git("", "read-tree", tree); err != nil {
+		return "", errors.Wrap(err, "failed to read merged tree")
+	}
+	for _, path := range paths {
+		content, ok := resolved[path]
+		if !ok {
+			return "", errors.Errorf("%s has not been resolved", path)
+		}
+		mode, err := git("", "ls-tree", "--format=%(objectmode)", tree, "--", path)
+		if err != nil || mode == "" {
+			mode = "100644"
+		}
+		blob, err := git(content, "hash-object", "-w", "--stdin")
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to store %s", path)
+		}
+		if _, err := git("", "update-index", "--add", "--cacheinfo", mode+","+blob+","+path); err != nil {
+			return "", errors.Wrapf(err, "failed to stage %s", path)
+		}
+	}
+	resolvedTree, err := git("", "write-tree")
+	if err != nil {
+		return "", errors.Wrap(err, "failed to write resolved tree")
+	}
+
+	message := fmt.Sprintf("Merge branch '%s' into %s\n\nResolved conflicts in:\n\t%s\n",
+		pr.BaseBranch, pr.CompareBranch, strings.Join(paths, "\n\t"))
+	commit, err := git(message, "commit-tree", resolvedTree, "-p", head, "-p", base)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to commit resolution")
+	}
+
+	// Only moves the branch if nobody pushed to it in the meantime
+	if _, _, err := repo.Git("update-ref", "refs/heads/"+pr.CompareBranch, commit, head); err != nil {
+		return "", errors.Errorf("%s changed while resolving, reload and try again", pr.CompareBranch)
+	}
+	repo.UpdateLastActivity()
+	return commit, nil
+}
+
+// parseConflictMarkers splits a file with conflict markers into merged
+// text and conflicting hunks. It reports false when the file has no
+// complete conflicts.
+func parseConflictMarkers(content string) (sections []ConflictSection, hunks int, ok bool) {
+	const (
+		inText = iota
+		inBase
+		inAncestor // diff3 style lines from the merge base, left out
+		inHead
+	)
+	state := inText
+	var text, base, head strings.Builder
+	for _, line := range strings.SplitAfter(content, "\n") {
+		marker := strings.TrimRight(line, "\r\n")
+		switch {
+		case state == inText && strings.HasPrefix(marker, "<<<<<<<"):
+			if text.Len() > 0 {
+				sections = append(sections, ConflictSection{Text: text.String()})
+				text.Reset()
+			}
+			state = inBase
+		case state == inBase && strings.HasPrefix(marker, "|||||||"):
+			state = inAncestor
+		case (state == inBase || state == inAncestor) && marker == "=======":
+			state = inHead
+		case state == inHead && strings.HasPrefix(marker, ">>>>>>>"):
+			sections = append(sections, ConflictSection{Conflict: true, Hunk: hunks, Base: base.String(), Head: head.String()})
+			base.Reset()
+			head.Reset()
+			hunks++
+			state = inText
+		case state == inText:
+			text.WriteString(line)
+		case state == inBase:
+			base.WriteString(line)
+		case state == inHead:
+			head.WriteString(line)
+		}
+	}
+	if state != inText || hunks == 0 {
+		return nil, 0, false
+	}
+	if text.Len() > 0 {
+		sections = append(sections, ConflictSection{Text: text.String()})
+	}
+	return sections, hunks, true
+}
+
+// scratchIndex returns a way to run git in the repository against a
+// temporary index, for building commits without a work tree, and a function
+// that removes the index. env is added to each command's environment.
+func scratchIndex(repo *Repository, env ...string) (func(stdin string, args ...string) (string, error), func(), error) {
+	index, err := os.CreateTemp("", "scratch-*.index")
+	if err != nil {
+		return nil, nil, err
+	}
+	index.Close()
+
+	git := func(stdin string, args ...string) (string, error) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = repo.Path()
+		cmd.Env = append(append(os.Environ(), "GIT_INDEX_FILE="+index.Name()), env...)
+		cmd.Stdin = strings.NewReader(stdin)
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return "", errors.Wrap(err, strings.TrimSpace(stderr.String()))
+		}
+		return strings.TrimSpace(string(out)), nil
+	}
+	return git, func() { os.Remove(index.Name()) }, nil
+}
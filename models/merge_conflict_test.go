@@ -0,0 +1,115 @@
+package models
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/The-Skyscape/devtools/pkg/testutils"
+)
+
+func TestParseConflictMarkers(t *testing.T) {
+	t.Run("SplitsHunks", func(t *testing.T) {
+		sections, hunks, ok := parseConflictMarkers("a\n<<<<<<< main\nb\n=======\nc\n>>>>>>> feature\nd\n<<<<<<< main\n=======\ne\n>>>>>>> feature\n")
+		testutils.AssertTrue(t, ok)
+		testutils.AssertEqual(t, 2, hunks)
+		testutils.AssertEqual(t, 4, len(sections))
+		testutils.AssertEqual(t, "a\n", sections[0].Text)
+		testutils.AssertEqual(t, "b\n", sections[1].Base)
+		testutils.AssertEqual(t, "c\n", sections[1].Head)
+		testutils.AssertEqual(t, "", sections[3].Base)
+		testutils.AssertEqual(t, 1, sections[3].Hunk)
+	})
+
+	t.Run("SkipsDiff3Ancestor", func(t *testing.T) {
+		sections, _, ok := parseConflictMarkers("<<<<<<< main\nb\n||||||| base\nx\n=======\nc\n>>>>>>> feature\n")
+		testutils.AssertTrue(t, ok)
+		testutils.AssertEqual(t, "b\n", sections[0].Base)
+		testutils.AssertEqual(t, "c\n", sections[0].Head)
+	})
+
+	t.Run("RejectsIncompleteConflicts", func(t *testing.T) {
+		for _, content := range []string{"", "plain text\n", "<<<<<<< main\nb\n=======\nc\n"} {
+			_, _, ok := parseConflictMarkers(content)
+			testutils.AssertFalse(t, ok)
+		}
+	})
+
+	t.Run("Resolve", func(t *testing.T) {
+		sections, hunks, _ := parseConflictMarkers("a\n<<<<<<< main\nb\n=======\nc\n>>>>>>> feature\n")
+		file := &ConflictFile{Path: "file.txt", Sections: sections, Hunks: hunks}
+
+		for keep, want := range map[string]string{
+			ConflictKeepBase: "a\nb\n",
+			ConflictKeepHead: "a\nc\n",
+			ConflictKeepBoth: "a\nb\nc\n",
+		} {
+			content, err := file.Resolve([]ConflictPick{{Keep: keep}})
+			testutils.AssertNoError(t, err)
+			testutils.AssertEqual(t, want, content)
+		}
+
+		content, err := file.Resolve([]ConflictPick{{Keep: ConflictKeepCustom, Custom: "z\r\ny"}})
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, "a\nz\ny\n", content)
+
+		_, err = file.Resolve(nil)
+		testutils.AssertError(t, err)
+		_, err = file.Resolve([]ConflictPick{{Keep: ConflictKeepCustom, Custom: "<<<<<<< x\n=======\n>>>>>>> y\n"}})
+		testutils.AssertError(t, err)
+	})
+}
+
+func TestResolveConflicts(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+	t.Setenv("DATA_DIR", t.TempDir())
+
+	repo, pr, _ := conflictingRepo(t, "resolved")
+	head, err := pr.HeadCommit()
+	testutils.AssertNoError(t, err)
+
+	t.Run("ListsConflictFiles", func(t *testing.T) {
+		files, err := pr.ConflictFiles()
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 1, len(files))
+		testutils.AssertTrue(t, files[0].CanResolve())
+		testutils.AssertEqual(t, 1, files[0].Hunks)
+	})
+
+	t.Run("RefusesStaleHead", func(t *testing.T) {
+		_, err := ResolveConflicts(pr, "0000000", map[string]string{"file.txt": "x\n"}, "Test", "test@example.com")
+		testutils.AssertError(t, err)
+	})
+
+	t.Run("RefusesUnresolvedFiles", func(t *testing.T) {
+		_, err := ResolveConflicts(pr, head, map[string]string{}, "Test", "test@example.com")
+		testutils.AssertError(t, err)
+	})
+
+	t.Run("CommitsResolution", func(t *testing.T) {
+		commit, err := ResolveConflicts(pr, head, map[string]string{"file.txt": "one\nboth\nthree\n"}, "Test", "test@example.com")
+		testutils.AssertNoError(t, err)
+
+		newHead, err := pr.HeadCommit()
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, commit, newHead)
+
+		stdout, _, err := repo.Git("show", "feature:file.txt")
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, "one\nboth\nthree\n", stdout.String())
+
+		stdout, _, err = repo.Git("rev-list", "--parents", "-n", "1", "feature")
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 3, len(strings.Fields(stdout.String())))
+
+		check, err := pr.MergeCheck()
+		testutils.AssertNoError(t, err)
+		testutils.AssertFalse(t, check.HasConflicts())
+		testutils.AssertEqual(t, 0, check.Behind)
+	})
+}
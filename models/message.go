@@ -1,18 +1,25 @@
 package models
 
 import (
+	"encoding/base64"
+	"io"
+
 	"github.com/The-Skyscape/devtools/pkg/application"
+	"github.com/pkg/errors"
 )
 
 // Message represents a single message in a conversation
 type Message struct {
 	application.Model
-	ConversationID string // Conversation this message belongs to
-	Role           string // user, assistant, tool, error, system, thinking, status, plan
-	Content        string // Message content
-	Metadata       string // JSON metadata for tool executions
-	ToolName       string // Name of tool that generated this output
-	TokenCount     int    // Estimated token count for context management
+	ConversationID   string // Conversation this message belongs to
+	Role             string // user, assistant, tool, error, system, thinking, status, plan
+	Content          string // Message content
+	Metadata         string // JSON metadata for tool executions
+	ToolName         string // Name of tool that generated this output
+	TokenCount       int    // Estimated token count for context management
+	ImageCount       int    // Screenshots attached to a user message, see Images
+	PromptTokens     int    // Tokens the model read to write an assistant message
+	CompletionTokens int    // Tokens the model generated for an assistant message
 }
 
 // Table returns the database table name
@@ -49,3 +56,46 @@ func (m *Message) IsToolExecution() bool {
 func (m *Message) IsError() bool {
 	return m.Role == MessageRoleError
 }
+
+// AttachImage stores an image uploaded with the message. Chat images are
+// not tied to a repository, so they are kept as attachments without one.
+func (m *Message) AttachImage(filename string, content io.Reader, userID string) (*Attachment, error) {
+	attachment, err := SaveAttachment("", "message", m.ID, filename, content, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !attachment.IsImage() {
+		attachment.Delete()
+		return nil, errors.Errorf("%s is not a PNG, JPEG, GIF or WebP image", attachment.Filename)
+	}
+
+	m.ImageCount++
+	if err := Messages.Update(m); err != nil {
+		attachment.Delete()
+		return nil, errors.Wrap(err, "failed to update message")
+	}
+	return attachment, nil
+}
+
+// Images returns the images attached to the message
+func (m *Message) Images() []*Attachment {
+	if m.ImageCount == 0 {
+		return nil
+	}
+	images, _ := GetAttachments("message", m.ID)
+	return images
+}
+
+// EncodedImages returns the attached images base64 encoded, the form
+// multimodal models accept them in
+func (m *Message) EncodedImages() []string {
+	var encoded []string
+	for _, image := range m.Images() {
+		data, err := image.Read()
+		if err != nil {
+			continue
+		}
+		encoded = append(encoded, base64.StdEncoding.EncodeToString(data))
+	}
+	return encoded
+}
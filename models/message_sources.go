@@ -0,0 +1,63 @@
+package models
+
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+)
+
+// RetrievedSource is a code snippet retrieved for a user's message. The
+// snippets are kept in the message's Metadata and injected ahead of it in
+// the context window, numbered from 1 so answers can cite them.
+type RetrievedSource struct {
+	RepoID    string
+	Path      string
+	StartLine int
+	EndLine   int
+	Content   string
+	Number    int `json:"-"` // Position cited in answers, set by Sources
+}
+
+// messageMetadata is the JSON stored in Message.Metadata for user messages
+type messageMetadata struct {
+	Sources []RetrievedSource `json:"sources,omitempty"`
+}
+
+// Sources returns the snippets retrieved for a user message
+func (m *Message) Sources() []RetrievedSource {
+	if m.Role != MessageRoleUser || m.Metadata == "" {
+		return nil
+	}
+	var metadata messageMetadata
+	if err := json.Unmarshal([]byte(m.Metadata), &metadata); err != nil {
+		return nil
+	}
+	for i := range metadata.Sources {
+		metadata.Sources[i].Number = i + 1
+	}
+	return metadata.Sources
+}
+
+// SetSources records the snippets retrieved for a user message
+func (m *Message) SetSources(sources []RetrievedSource) error {
+	data, err := json.Marshal(messageMetadata{Sources: sources})
+	if err != nil {
+		return errors.Wrap(err, "failed to encode sources")
+	}
+	m.Metadata = string(data)
+	return errors.Wrap(Messages.Update(m), "failed to save sources")
+}
+
+// AnsweredSources returns the snippets retrieved for the user message an
+// assistant message answers, so its citations can be linked
+func (m *Message) AnsweredSources() []RetrievedSource {
+	if m.Role != MessageRoleAssistant {
+		return nil
+	}
+	asked, err := Messages.Search("WHERE ConversationID = ? AND Role = ? AND CreatedAt <= ? ORDER BY CreatedAt DESC LIMIT 1",
+		m.ConversationID, MessageRoleUser, m.CreatedAt)
+	if err != nil || len(asked) == 0 {
+		return nil
+	}
+	return asked[0].Sources()
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/The-Skyscape/devtools/pkg/testutils"
+)
+
+func TestMessageSources(t *testing.T) {
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+
+	user := CreateTestUser(t, db, "asker@example.com")
+	conversation, err := Conversations.Insert(&Conversation{UserID: user.ID, Title: "Sessions"})
+	testutils.AssertNoError(t, err)
+
+	question, err := Messages.Insert(&Message{ConversationID: conversation.ID, Role: MessageRoleUser, Content: "Where are sessions validated?"})
+	testutils.AssertNoError(t, err)
+	testutils.AssertEqual(t, 0, len(question.Sources()))
+
+	sources := []RetrievedSource{
+		{RepoID: "repo-1", Path: "auth/session.go", StartLine: 1, EndLine: 40, Content: "func Validate() {}"},
+		{RepoID: "repo-1", Path: "auth/middleware.go", StartLine: 33, EndLine: 72, Content: "func Required() {}"},
+	}
+	testutils.AssertNoError(t, question.SetSources(sources))
+
+	saved, err := Messages.Get(question.ID)
+	testutils.AssertNoError(t, err)
+	testutils.AssertEqual(t, 2, len(saved.Sources()))
+	testutils.AssertEqual(t, "auth/middleware.go", saved.Sources()[1].Path)
+	testutils.AssertEqual(t, 2, saved.Sources()[1].Number)
+
+	answer, err := Messages.Insert(&Message{ConversationID: conversation.ID, Role: MessageRoleAssistant, Content: "In Validate [1]."})
+	testutils.AssertNoError(t, err)
+	testutils.AssertEqual(t, 2, len(answer.AnsweredSources()))
+	testutils.AssertEqual(t, 0, len(saved.AnsweredSources()))
+}
@@ -0,0 +1,254 @@
+package models
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"workspace/internal/crypto"
+
+	"github.com/The-Skyscape/devtools/pkg/application"
+	"github.com/The-Skyscape/devtools/pkg/database"
+	"github.com/pkg/errors"
+)
+
+// Migration statuses
+const (
+	MigrationRunning   = "running"
+	MigrationPaused    = "paused" // Stopped by an admin or an error, can resume
+	MigrationCompleted = "completed"
+)
+
+// Migration item statuses
+const (
+	MigrationItemPending = "pending"
+	MigrationItemSent    = "sent"
+	MigrationItemFailed  = "failed"
+)
+
+// Migration is a transfer of this workspace's data to another workspace,
+// split into items so it can resume after an interruption
+type Migration struct {
+	application.Model
+	TargetURL   string
+	Token       string // Issued by the target, encrypted with the workspace key
+	Status      string
+	Error       string
+	StartedBy   string
+	ItemsTotal  int
+	ItemsDone   int
+	BytesSent   int64
+	CompletedAt time.Time
+	Checklist   string // Cutover steps ticked by an admin, comma separated
+}
+
+// Table returns the database table name
+func (*Migration) Table() string { return "migrations" }
+
+// MigrationItem is one unit of a migration: all users, the settings, the
+// secrets, or one repository's record, issues or git data
+type MigrationItem struct {
+	application.Model
+	MigrationID string
+	Item        string // users, settings, secrets, repository/<id>, issues/<id> or archive/<id>
+	Label       string
+	Position    int
+	Status      string
+	Offset      int64 // Bytes of an archive the target has
+	Size        int64
+	Error       string
+}
+
+// Table returns the database table name
+func (*MigrationItem) Table() string { return "migration_items" }
+
+// CutoverStep is one step of moving users over to the new workspace
+type CutoverStep struct {
+	ID        string
+	Title     string
+	Detail    string
+	Automatic bool // Ticked by the migration itself
+	Done      bool
+}
+
+// cutoverSteps are the steps of a cutover, in order
+var cutoverSteps = []CutoverStep{
+	{ID: "transfer", Title: "Transfer everything", Automatic: true,
+		Detail: "Users, settings, secrets, repositories and their issues reach the new workspace."},
+	{ID: "freeze", Title: "Freeze this workspace",
+		Detail: "Ask everyone to stop pushing and editing issues here until the cutover is done."},
+	{ID: "final-sync", Title: "Run a final sync",
+		Detail: "Send everything again so changes made during the transfer aren't left behind."},
+	{ID: "files", Title: "Bring over files and host settings",
+		Detail: "Attachments, avatars and artifacts stay in this workspace's file storage. Point the new workspace at the same storage or copy it, then set up backups and AI there."},
+	{ID: "verify", Title: "Verify the new workspace",
+		Detail: "Sign in with an existing account, clone a repository and open a few issues."},
+	{ID: "redirect", Title: "Point users at the new workspace",
+		Detail: "Update DNS or bookmarks, git remotes, and the GitHub OAuth callback and webhook URLs."},
+	{ID: "retire", Title: "Retire this workspace",
+		Detail: "Take a final backup, then shut this workspace down."},
+}
+
+// CreateMigration plans a transfer to the workspace at targetURL using a
+// token it issued
+func CreateMigration(targetURL, token, userID string) (*Migration, error) {
+	encrypted, err := crypto.Encrypt(strings.TrimSpace(token))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to protect migration token")
+	}
+
+	migration, err := Migrations.Insert(&Migration{
+		Model:     DB.NewModel(""),
+		TargetURL: strings.TrimSuffix(strings.TrimSpace(targetURL), "/"),
+		Token:     encrypted,
+		Status:    MigrationPaused,
+		StartedBy: userID,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create migration")
+	}
+
+	if err := migration.plan(); err != nil {
+		return nil, err
+	}
+	return migration, nil
+}
+
+// plan records the items to send. Users come first so the target can match
+// them to its own accounts before anything refers to them, and secrets come
+// last because they are keyed by user and repository.
+func (m *Migration) plan() error {
+	repos, err := Repositories.Search("ORDER BY CreatedAt ASC")
+	if err != nil {
+		return errors.Wrap(err, "failed to list repositories")
+	}
+
+	items := []*MigrationItem{
+		{Item: "users", Label: "Users"},
+		{Item: "settings", Label: "Settings"},
+	}
+	for _, repo := range repos {
+		items = append(items,
+			&MigrationItem{Item: "repository/" + repo.ID, Label: repo.Name},
+			&MigrationItem{Item: "archive/" + repo.ID, Label: repo.Name + " git data"},
+			&MigrationItem{Item: "issues/" + repo.ID, Label: repo.Name + " issues"},
+		)
+	}
+	items = append(items, &MigrationItem{Item: "secrets", Label: "Secrets"})
+
+	for i, item := range items {
+		item.Model = DB.NewModel("")
+		item.MigrationID = m.ID
+		item.Position = i
+		item.Status = MigrationItemPending
+		if _, err := MigrationItems.Insert(item); err != nil {
+			return errors.Wrap(err, "failed to plan migration")
+		}
+	}
+
+	m.ItemsTotal = len(items)
+	return Migrations.Update(m)
+}
+
+// GetMigrations returns every migration, newest first
+func GetMigrations() ([]*Migration, error) {
+	return Migrations.Search("ORDER BY CreatedAt DESC")
+}
+
+// Items returns the migration's items in the order they are sent
+func (m *Migration) Items() ([]*MigrationItem, error) {
+	return MigrationItems.Search("WHERE MigrationID = ? ORDER BY Position ASC", m.ID)
+}
+
+// TokenValue returns the token the target issued
+func (m *Migration) TokenValue() (string, error) {
+	return crypto.Decrypt(m.Token)
+}
+
+// Percent returns how many of the items were sent
+func (m *Migration) Percent() int {
+	if m.ItemsTotal == 0 {
+		return 0
+	}
+	return m.ItemsDone * 100 / m.ItemsTotal
+}
+
+// ArchiveDir is where repository archives are kept between attempts, so a
+// resumed transfer continues the same archive
+func (m *Migration) ArchiveDir() string {
+	return filepath.Join(database.DataDir(), "migrations", m.ID)
+}
+
+// Resync marks every item to be sent again, for the final sync after the
+// source workspace is frozen
+func (m *Migration) Resync() error {
+	items, err := m.Items()
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		item.Status = MigrationItemPending
+		item.Offset = 0
+		item.Error = ""
+		if err := MigrationItems.Update(item); err != nil {
+			return err
+		}
+	}
+	os.RemoveAll(m.ArchiveDir())
+
+	m.ItemsDone = 0
+	m.BytesSent = 0
+	m.Error = ""
+	m.Status = MigrationPaused
+	m.CompletedAt = time.Time{}
+	return Migrations.Update(m)
+}
+
+// Cutover returns the cutover checklist with the steps done so far
+func (m *Migration) Cutover() []CutoverStep {
+	ticked := strings.Split(m.Checklist, ",")
+	steps := make([]CutoverStep, len(cutoverSteps))
+	for i, step := range cutoverSteps {
+		step.Done = slices.Contains(ticked, step.ID)
+		if step.Automatic {
+			step.Done = m.Status == MigrationCompleted
+		}
+		steps[i] = step
+	}
+	return steps
+}
+
+// ToggleCutoverStep ticks or unticks a manual cutover step
+func (m *Migration) ToggleCutoverStep(id string) error {
+	known := slices.ContainsFunc(cutoverSteps, func(step CutoverStep) bool {
+		return step.ID == id && !step.Automatic
+	})
+	if !known {
+		return fmt.Errorf("unknown cutover step %q", id)
+	}
+
+	var ticked []string
+	for _, step := range strings.Split(m.Checklist, ",") {
+		if step != "" && step != id {
+			ticked = append(ticked, step)
+		}
+	}
+	if !strings.Contains(","+m.Checklist+",", ","+id+",") {
+		ticked = append(ticked, id)
+	}
+	m.Checklist = strings.Join(ticked, ",")
+	return Migrations.Update(m)
+}
+
+// DeleteMigration removes a migration, its items and any archives left over
+func DeleteMigration(m *Migration) error {
+	items, _ := m.Items()
+	for _, item := range items {
+		MigrationItems.Delete(item)
+	}
+	os.RemoveAll(m.ArchiveDir())
+	return Migrations.Delete(m)
+}
@@ -0,0 +1,103 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// migratedUser is a user as sent to another workspace. The password hash
+// goes along so everyone can sign in with their existing password.
+type migratedUser struct {
+	ID        string
+	Name      string
+	Handle    string
+	Email     string
+	Avatar    string
+	IsAdmin   bool
+	PassHash  string
+	CreatedAt time.Time
+}
+
+// migratedIssues are a repository's issues and the comments on them
+type migratedIssues struct {
+	Issues   []*Issue
+	Comments []*Comment
+}
+
+// ExportMigrationItem returns a migration item's records, to be sent as JSON.
+// Repository archives are sent as files and aren't exported here.
+func ExportMigrationItem(item string) (any, error) {
+	kind, id, _ := strings.Cut(item, "/")
+	switch kind {
+	case "users":
+		users, err := Users.Search("ORDER BY CreatedAt ASC")
+		if err != nil {
+			return nil, err
+		}
+		exported := make([]migratedUser, len(users))
+		for i, user := range users {
+			exported[i] = migratedUser{
+				ID:        user.ID,
+				Name:      user.Name,
+				Handle:    user.Handle,
+				Email:     user.Email,
+				Avatar:    user.Avatar,
+				IsAdmin:   user.IsAdmin,
+				PassHash:  string(user.PassHash),
+				CreatedAt: user.CreatedAt,
+			}
+		}
+		return exported, nil
+
+	case "settings":
+		return GetSettings()
+
+	case "repository":
+		return Repositories.Get(id)
+
+	case "issues":
+		issues, err := Issues.Search("WHERE RepoID = ? ORDER BY CreatedAt ASC", id)
+		if err != nil {
+			return nil, err
+		}
+		comments, err := Comments.Search("WHERE RepoID = ? ORDER BY CreatedAt ASC", id)
+		if err != nil {
+			return nil, err
+		}
+		return migratedIssues{Issues: issues, Comments: comments}, nil
+
+	case "secrets":
+		return exportSecrets()
+	}
+	return nil, fmt.Errorf("unknown migration item %q", item)
+}
+
+// exportSecrets reads every secret the workspace keeps: the GitHub OAuth
+// app, and each user's and repository's GitHub credentials
+func exportSecrets() (map[string]map[string]any, error) {
+	keys := []string{"github/oauth_app"}
+	users, err := Users.Search("")
+	if err != nil {
+		return nil, err
+	}
+	for _, user := range users {
+		keys = append(keys, GitHubUserPrefix+user.ID)
+	}
+	repos, err := Repositories.Search("")
+	if err != nil {
+		return nil, err
+	}
+	for _, repo := range repos {
+		keys = append(keys, GitHubRepoPrefix+repo.ID)
+	}
+
+	secrets := map[string]map[string]any{}
+	for _, key := range keys {
+		// Most users and repositories have no GitHub credentials
+		if secret, err := Secrets.GetSecret(key); err == nil && len(secret) > 0 {
+			secrets[key] = secret
+		}
+	}
+	return secrets, nil
+}
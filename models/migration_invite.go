@@ -0,0 +1,408 @@
+package models
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"workspace/internal/crypto"
+	"workspace/internal/migration"
+
+	"github.com/The-Skyscape/devtools/pkg/application"
+	"github.com/The-Skyscape/devtools/pkg/database"
+	"github.com/pkg/errors"
+)
+
+// MigrationInviteLifetime is how long an invite stays usable after it was
+// created or last used
+const MigrationInviteLifetime = 7 * 24 * time.Hour
+
+// ErrMigrationOffset is returned when a chunk doesn't continue where the
+// item's last chunk ended
+var ErrMigrationOffset = errors.New("chunk does not continue the item")
+
+// MigrationInvite lets another workspace send its data to this one. The
+// token is shown once; only the key derived from it is kept.
+type MigrationInvite struct {
+	application.Model
+	Key        string // Sealing key, encrypted with the workspace key
+	CreatedBy  string
+	ExpiresAt  time.Time
+	Revoked    bool
+	Source     string // Address the sending workspace last connected from
+	LastSeenAt time.Time
+	UserMap    string // JSON map of sender user IDs to the accounts here that match them
+}
+
+// Table returns the database table name
+func (*MigrationInvite) Table() string { return "migration_invites" }
+
+// MigrationReceipt is how much of an item arrived through an invite
+type MigrationReceipt struct {
+	application.Model
+	InviteID string
+	Item     string
+	Offset   int64
+	Done     bool
+}
+
+// Table returns the database table name
+func (*MigrationReceipt) Table() string { return "migration_receipts" }
+
+// CreateMigrationInvite issues an invite and returns it with its token
+func CreateMigrationInvite(userID string) (*MigrationInvite, string, error) {
+	invite := &MigrationInvite{
+		Model:     DB.NewModel(""),
+		CreatedBy: userID,
+		ExpiresAt: time.Now().Add(MigrationInviteLifetime),
+	}
+	token, key, err := migration.NewToken(invite.ID)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "failed to create migration token")
+	}
+	if invite.Key, err = crypto.Encrypt(hex.EncodeToString(key)); err != nil {
+		return nil, "", errors.Wrap(err, "failed to protect migration token")
+	}
+
+	invite, err = MigrationInvites.Insert(invite)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "failed to create migration invite")
+	}
+	return invite, token, nil
+}
+
+// GetMigrationInvites returns the invites that haven't been revoked
+func GetMigrationInvites() ([]*MigrationInvite, error) {
+	return MigrationInvites.Search("WHERE Revoked = ? ORDER BY CreatedAt DESC", false)
+}
+
+// Usable reports whether the invite may still receive data
+func (i *MigrationInvite) Usable() bool {
+	return !i.Revoked && time.Now().Before(i.ExpiresAt)
+}
+
+// SealingKey returns the key the sender seals requests with
+func (i *MigrationInvite) SealingKey() ([]byte, error) {
+	key, err := crypto.Decrypt(i.Key)
+	if err != nil {
+		return nil, err
+	}
+	return hex.DecodeString(key)
+}
+
+// Touch records a request from the sender and keeps the invite alive while
+// the transfer is in progress
+func (i *MigrationInvite) Touch(source string) error {
+	i.Source = source
+	i.LastSeenAt = time.Now()
+	i.ExpiresAt = i.LastSeenAt.Add(MigrationInviteLifetime)
+	return MigrationInvites.Update(i)
+}
+
+// Revoke stops the invite from receiving any more data
+func (i *MigrationInvite) Revoke() error {
+	i.Revoked = true
+	return MigrationInvites.Update(i)
+}
+
+// ReceivedItems returns how many items arrived through the invite
+func (i *MigrationInvite) ReceivedItems() int {
+	return MigrationReceipts.Count("WHERE InviteID = ? AND Done = ?", i.ID, true)
+}
+
+// Status returns what arrived through the invite, for the sender to resume
+func (i *MigrationInvite) Status() (*migration.Status, error) {
+	settings, err := GetSettings()
+	if err != nil {
+		return nil, err
+	}
+	receipts, err := MigrationReceipts.Search("WHERE InviteID = ?", i.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &migration.Status{Workspace: settings.AppName, Items: map[string]migration.Receipt{}}
+	for _, receipt := range receipts {
+		status.Items[receipt.Item] = migration.Receipt{Offset: receipt.Offset, Done: receipt.Done}
+	}
+	return status, nil
+}
+
+// stagingDir is where repository archives are assembled as they arrive
+func (i *MigrationInvite) stagingDir() string {
+	return filepath.Join(database.DataDir(), "migrations", "receive", i.ID)
+}
+
+// Receive takes part of an item from the sender, importing the item once
+// its final part arrives. A part at offset zero starts the item over.
+func (i *MigrationInvite) Receive(req migration.Request) (migration.Receipt, error) {
+	receipt, err := i.receipt(req.Item)
+	if err != nil {
+		return migration.Receipt{}, err
+	}
+	if req.Offset != 0 && req.Offset != receipt.Offset {
+		return migration.Receipt{Offset: receipt.Offset, Done: receipt.Done}, ErrMigrationOffset
+	}
+
+	kind, id, _ := strings.Cut(req.Item, "/")
+	if kind == "archive" {
+		err = i.receiveArchive(id, req)
+	} else if req.Offset != 0 || !req.Final {
+		err = errors.Errorf("%s must be sent in one request", req.Item)
+	} else {
+		err = i.importRecords(kind, id, req.Body)
+	}
+	if err != nil {
+		return migration.Receipt{Offset: receipt.Offset, Done: receipt.Done}, err
+	}
+
+	receipt.Offset = req.Offset + int64(len(req.Body))
+	receipt.Done = req.Final
+	if err := MigrationReceipts.Update(receipt); err != nil {
+		return migration.Receipt{}, err
+	}
+	return migration.Receipt{Offset: receipt.Offset, Done: receipt.Done}, nil
+}
+
+// receipt returns the invite's receipt for an item, creating it on first use
+func (i *MigrationInvite) receipt(item string) (*MigrationReceipt, error) {
+	receipts, err := MigrationReceipts.Search("WHERE InviteID = ? AND Item = ?", i.ID, item)
+	if err != nil {
+		return nil, err
+	}
+	if len(receipts) > 0 {
+		return receipts[0], nil
+	}
+	return MigrationReceipts.Insert(&MigrationReceipt{Model: DB.NewModel(""), InviteID: i.ID, Item: item})
+}
+
+// receiveArchive appends a chunk of a repository's git data, and replaces
+// the repository's directory with it once the last chunk is in
+func (i *MigrationInvite) receiveArchive(repoID string, req migration.Request) error {
+	if strings.ContainsAny(repoID, `/\`) || repoID == "" || repoID == "." || repoID == ".." {
+		return errors.Errorf("invalid repository ID %q", repoID)
+	}
+	if err := os.MkdirAll(i.stagingDir(), 0755); err != nil {
+		return err
+	}
+	staged := filepath.Join(i.stagingDir(), repoID+".tar.gz")
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	if req.Offset == 0 {
+		flags |= os.O_TRUNC
+	}
+	file, err := os.OpenFile(staged, flags, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(req.Body)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil || !req.Final {
+		return err
+	}
+
+	archive, err := os.Open(staged)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(staged)
+	defer archive.Close()
+
+	repo := &Repository{Model: DB.NewModel(repoID)}
+	extracted := repo.Path() + ".migrating"
+	os.RemoveAll(extracted)
+	if err := migration.ExtractArchive(archive, extracted); err != nil {
+		os.RemoveAll(extracted)
+		return errors.Wrap(err, "failed to unpack repository")
+	}
+	os.RemoveAll(repo.Path())
+	return os.Rename(extracted, repo.Path())
+}
+
+// importRecords imports an item's records, keeping the IDs they had so
+// references between them still hold
+func (i *MigrationInvite) importRecords(kind, id string, data []byte) error {
+	switch kind {
+	case "users":
+		var users []migratedUser
+		if err := json.Unmarshal(data, &users); err != nil {
+			return err
+		}
+		return i.importUsers(users)
+
+	case "settings":
+		var settings Settings
+		if err := json.Unmarshal(data, &settings); err != nil {
+			return err
+		}
+		return importSettings(&settings)
+
+	case "repository":
+		var repo Repository
+		if err := json.Unmarshal(data, &repo); err != nil {
+			return err
+		}
+		if repo.ID != id {
+			return errors.New("repository does not match its item")
+		}
+		repo.UserID = i.mapUser(repo.UserID)
+		return importRepository(&repo)
+
+	case "issues":
+		var records migratedIssues
+		if err := json.Unmarshal(data, &records); err != nil {
+			return err
+		}
+		for _, issue := range records.Issues {
+			issue.AuthorID = i.mapUser(issue.AuthorID)
+			issue.AssigneeID = i.mapUser(issue.AssigneeID)
+			if err := importIssue(issue); err != nil {
+				return err
+			}
+		}
+		for _, comment := range records.Comments {
+			comment.AuthorID = i.mapUser(comment.AuthorID)
+			if err := importComment(comment); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case "secrets":
+		var secrets map[string]map[string]any
+		if err := json.Unmarshal(data, &secrets); err != nil {
+			return err
+		}
+		// Stored through this workspace's secrets backend, so they end up
+		// encrypted with its keys rather than the sender's
+		for key, secret := range secrets {
+			if userID, ok := strings.CutPrefix(key, GitHubUserPrefix); ok {
+				key = GitHubUserPrefix + i.mapUser(userID)
+			}
+			if err := StoreSecret(key, secret); err != nil {
+				return errors.Wrapf(err, "failed to store secret %s", key)
+			}
+		}
+		return nil
+	}
+	return errors.Errorf("unknown migration item %q", kind)
+}
+
+// importUsers adds the sender's users. Someone who already has an account
+// here with the same email keeps it, and records sent later that refer to
+// them are pointed at it.
+func (i *MigrationInvite) importUsers(users []migratedUser) error {
+	userMap := map[string]string{}
+	for _, sent := range users {
+		user, err := Users.Get(sent.ID)
+		exists := err == nil
+		if !exists {
+			if matches, err := Users.Search("WHERE Email = ?", sent.Email); err == nil && len(matches) > 0 {
+				userMap[sent.ID] = matches[0].ID
+				continue
+			}
+			user = &User{Model: DB.NewModel(sent.ID)}
+			user.CreatedAt = sent.CreatedAt
+		}
+
+		user.Name = sent.Name
+		user.Handle = sent.Handle
+		user.Email = sent.Email
+		user.Avatar = sent.Avatar
+		user.IsAdmin = sent.IsAdmin
+		user.PassHash = []byte(sent.PassHash)
+		if exists {
+			err = Users.Update(user)
+		} else {
+			_, err = Users.Insert(user)
+		}
+		if err != nil {
+			return errors.Wrapf(err, "failed to import user %s", sent.Email)
+		}
+	}
+
+	encoded, err := json.Marshal(userMap)
+	if err != nil {
+		return err
+	}
+	i.UserMap = string(encoded)
+	return MigrationInvites.Update(i)
+}
+
+// mapUser returns the account here for a user ID from the sender
+func (i *MigrationInvite) mapUser(id string) string {
+	var userMap map[string]string
+	json.Unmarshal([]byte(i.UserMap), &userMap)
+	if mapped, ok := userMap[id]; ok {
+		return mapped
+	}
+	return id
+}
+
+// importSettings takes the sender's settings, except the ones tied to the
+// host they ran on
+func importSettings(settings *Settings) error {
+	current, err := GetSettings()
+	if err != nil {
+		return err
+	}
+
+	settings.Model = current.Model
+	settings.BackupDir = current.BackupDir
+	settings.OllamaHost = current.OllamaHost
+	settings.StorageBackend = current.StorageBackend
+	settings.StoragePath = current.StoragePath
+	settings.StorageEndpoint = current.StorageEndpoint
+	settings.StorageBucket = current.StorageBucket
+	settings.StorageRegion = current.StorageRegion
+	settings.StoragePrefix = current.StoragePrefix
+	settings.StorageAccessKey = current.StorageAccessKey
+	settings.StorageSecretKey = current.StorageSecretKey
+	settings.SetupCompleted = true
+	if err := GlobalSettings.Update(settings); err != nil {
+		return err
+	}
+	settings.ApplyEnvironment()
+	return nil
+}
+
+// migratedModel gives a record from another workspace its original ID and
+// timestamps
+func migratedModel(model application.Model) application.Model {
+	migrated := DB.NewModel(model.ID)
+	migrated.CreatedAt = model.CreatedAt
+	migrated.UpdatedAt = model.UpdatedAt
+	return migrated
+}
+
+func importRepository(repo *Repository) error {
+	repo.Model = migratedModel(repo.Model)
+	if _, err := Repositories.Get(repo.ID); err == nil {
+		return Repositories.Update(repo)
+	}
+	_, err := Repositories.Insert(repo)
+	return err
+}
+
+func importIssue(issue *Issue) error {
+	issue.Model = migratedModel(issue.Model)
+	if _, err := Issues.Get(issue.ID); err == nil {
+		return Issues.Update(issue)
+	}
+	_, err := Issues.Insert(issue)
+	return err
+}
+
+func importComment(comment *Comment) error {
+	comment.Model = migratedModel(comment.Model)
+	if _, err := Comments.Get(comment.ID); err == nil {
+		return Comments.Update(comment)
+	}
+	_, err := Comments.Insert(comment)
+	return err
+}
@@ -0,0 +1,108 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"workspace/internal/migration"
+
+	"github.com/The-Skyscape/devtools/pkg/testutils"
+)
+
+func TestMigration(t *testing.T) {
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+	t.Setenv("AUTH_SECRET", "test-secret-key-for-migrations")
+	t.Setenv("DATA_DIR", t.TempDir())
+
+	user := CreateTestUser(t, db, "migrator@example.com")
+	repo := createTestRepository(t, "migrating-repo", user.ID)
+
+	t.Run("Plan", func(t *testing.T) {
+		m, err := CreateMigration("https://new.example.com/", "invite.token", user.ID)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, "https://new.example.com", m.TargetURL)
+		testutils.AssertNotEqual(t, "invite.token", m.Token)
+
+		token, err := m.TokenValue()
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, "invite.token", token)
+
+		items, err := m.Items()
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, m.ItemsTotal, len(items))
+		testutils.AssertEqual(t, "users", items[0].Item)
+		testutils.AssertEqual(t, "secrets", items[len(items)-1].Item)
+
+		planned := map[string]bool{}
+		for _, item := range items {
+			planned[item.Item] = true
+		}
+		testutils.AssertTrue(t, planned["archive/"+repo.ID])
+		testutils.AssertTrue(t, planned["issues/"+repo.ID])
+	})
+
+	t.Run("CutoverChecklist", func(t *testing.T) {
+		m, err := CreateMigration("https://new.example.com", "invite.token", user.ID)
+		testutils.AssertNoError(t, err)
+
+		testutils.AssertNoError(t, m.ToggleCutoverStep("freeze"))
+		testutils.AssertError(t, m.ToggleCutoverStep("transfer"))
+		testutils.AssertError(t, m.ToggleCutoverStep("unknown"))
+
+		done := map[string]bool{}
+		for _, step := range m.Cutover() {
+			done[step.ID] = step.Done
+		}
+		testutils.AssertTrue(t, done["freeze"])
+		testutils.AssertFalse(t, done["transfer"])
+
+		testutils.AssertNoError(t, m.ToggleCutoverStep("freeze"))
+		testutils.AssertEqual(t, "", m.Checklist)
+	})
+
+	t.Run("InviteReceive", func(t *testing.T) {
+		invite, token, err := CreateMigrationInvite(user.ID)
+		testutils.AssertNoError(t, err)
+		testutils.AssertTrue(t, invite.Usable())
+
+		inviteID, key, err := migration.ParseToken(token)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, invite.ID, inviteID)
+		stored, err := invite.SealingKey()
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, string(key), string(stored))
+
+		// A sender's user with an existing email maps to the account here
+		users, _ := json.Marshal([]migratedUser{
+			{ID: "sender-user", Email: user.Email, Name: "Migrator"},
+			{ID: "new-user", Email: "newcomer@example.com", Handle: "newcomer", CreatedAt: time.Now()},
+		})
+		receipt, err := invite.Receive(migration.Request{Item: "users", Body: users, Final: true})
+		testutils.AssertNoError(t, err)
+		testutils.AssertTrue(t, receipt.Done)
+		testutils.AssertEqual(t, user.ID, invite.mapUser("sender-user"))
+		testutils.AssertEqual(t, "new-user", invite.mapUser("new-user"))
+
+		newcomer, err := Users.Get("new-user")
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, "newcomer@example.com", newcomer.Email)
+
+		// Chunks must continue where the last one ended
+		_, err = invite.Receive(migration.Request{Item: "archive/" + repo.ID, Body: []byte("abc")})
+		testutils.AssertNoError(t, err)
+		receipt, err = invite.Receive(migration.Request{Item: "archive/" + repo.ID, Offset: 1, Body: []byte("bc")})
+		testutils.AssertEqual(t, ErrMigrationOffset, err)
+		testutils.AssertEqual(t, int64(3), receipt.Offset)
+
+		status, err := invite.Status()
+		testutils.AssertNoError(t, err)
+		testutils.AssertTrue(t, status.Items["users"].Done)
+		testutils.AssertFalse(t, status.Items["archive/"+repo.ID].Done)
+		testutils.AssertEqual(t, 1, invite.ReceivedItems())
+
+		testutils.AssertNoError(t, invite.Revoke())
+		testutils.AssertFalse(t, invite.Usable())
+	})
+}
@@ -0,0 +1,203 @@
+package models
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/The-Skyscape/devtools/pkg/application"
+	"github.com/pkg/errors"
+)
+
+// Milestone groups a repository's work toward a target date
+type Milestone struct {
+	application.Model
+	RepoID      string
+	Title       string
+	Description string
+	DueDate     time.Time // Zero when the milestone has no due date
+	Status      string    // open, closed
+	CreatedBy   string
+	ClosedAt    time.Time
+}
+
+// Table returns the database table name
+func (*Milestone) Table() string { return "milestones" }
+
+// Milestone status constants
+const (
+	MilestoneOpen   = "open"
+	MilestoneClosed = "closed"
+)
+
+// CreateMilestone adds an open milestone to a repository
+func CreateMilestone(repoID, title, description string, due time.Time, userID string) (*Milestone, error) {
+	title = strings.TrimSpace(title)
+	if title == "" {
+		return nil, errors.New("milestone title is required")
+	}
+
+	existing, err := Milestones.Search("WHERE RepoID = ? AND Title = ?", repoID, title)
+	if err == nil && len(existing) > 0 {
+		return nil, errors.Errorf("milestone %q already exists", title)
+	}
+
+	return Milestones.Insert(&Milestone{
+		Model:       DB.NewModel(""),
+		RepoID:      repoID,
+		Title:       title,
+		Description: strings.TrimSpace(description),
+		DueDate:     due,
+		Status:      MilestoneOpen,
+		CreatedBy:   userID,
+	})
+}
+
+// GetRepoMilestones returns a repository's milestones, soonest due first
+// with undated milestones after them
+func GetRepoMilestones(repoID string, includeClosed bool) ([]*Milestone, error) {
+	query := "WHERE RepoID = ?"
+	args := []any{repoID}
+	if !includeClosed {
+		query += " AND Status = ?"
+		args = append(args, MilestoneOpen)
+	}
+
+	milestones, err := Milestones.Search(query+" ORDER BY CreatedAt ASC", args...)
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortStableFunc(milestones, func(a, b *Milestone) int {
+		switch {
+		case a.IsClosed() != b.IsClosed():
+			if a.IsClosed() {
+				return 1
+			}
+			return -1
+		case a.HasDueDate() != b.HasDueDate():
+			if a.HasDueDate() {
+				return -1
+			}
+			return 1
+		}
+		return a.DueDate.Compare(b.DueDate)
+	})
+	return milestones, nil
+}
+
+// IsClosed returns true once the milestone has been closed
+func (m *Milestone) IsClosed() bool {
+	return m.Status == MilestoneClosed
+}
+
+// HasDueDate returns true if the milestone has a due date
+func (m *Milestone) HasDueDate() bool {
+	return !m.DueDate.IsZero()
+}
+
+// IsOverdue returns true if an open milestone is past its due day
+func (m *Milestone) IsOverdue() bool {
+	if !m.HasDueDate() || m.IsClosed() {
+		return false
+	}
+	return time.Now().After(m.DueDate.AddDate(0, 0, 1))
+}
+
+// SetStatus opens or closes the milestone
+func (m *Milestone) SetStatus(status string) error {
+	switch status {
+	case MilestoneOpen:
+		m.ClosedAt = time.Time{}
+	case MilestoneClosed:
+		if !m.IsClosed() {
+			m.ClosedAt = time.Now()
+		}
+	default:
+		return errors.Errorf("invalid milestone status %q", status)
+	}
+	m.Status = status
+	return Milestones.Update(m)
+}
+
+// Edit changes the milestone's title, description and due date
+func (m *Milestone) Edit(title, description string, due time.Time) error {
+	title = strings.TrimSpace(title)
+	if title == "" {
+		return errors.New("milestone title is required")
+	}
+
+	if title != m.Title {
+		existing, err := Milestones.Search("WHERE RepoID = ? AND Title = ?", m.RepoID, title)
+		if err == nil && len(existing) > 0 {
+			return errors.Errorf("milestone %q already exists", title)
+		}
+	}
+
+	m.Title = title
+	m.Description = strings.TrimSpace(description)
+	m.DueDate = due
+	return Milestones.Update(m)
+}
+
+// DeleteMilestone removes a milestone, leaving its issues without one
+func DeleteMilestone(m *Milestone) error {
+	if err := DB.Query("UPDATE issues SET MilestoneID = '' WHERE MilestoneID = ?", m.ID).Exec(); err != nil {
+		return errors.Wrap(err, "failed to clear milestone from issues")
+	}
+	return Milestones.Delete(m)
+}
+
+// Issues returns the issues in the milestone, open ones first
+func (m *Milestone) Issues() ([]*Issue, error) {
+	return Issues.Search(`
+		WHERE MilestoneID = ?
+		ORDER BY CASE WHEN Status IN ('closed', 'resolved') THEN 1 ELSE 0 END, Priority, CreatedAt DESC
+	`, m.ID)
+}
+
+// OpenIssueCount returns how many of the milestone's issues are still open
+func (m *Milestone) OpenIssueCount() int {
+	return Issues.Count("WHERE MilestoneID = ? AND Status NOT IN ('closed', 'resolved')", m.ID)
+}
+
+// ClosedIssueCount returns how many of the milestone's issues are done
+func (m *Milestone) ClosedIssueCount() int {
+	return Issues.Count("WHERE MilestoneID = ? AND Status IN ('closed', 'resolved')", m.ID)
+}
+
+// Progress returns the percentage of the milestone's issues that are done,
+// 0 when it has none
+func (m *Milestone) Progress() int {
+	closed := m.ClosedIssueCount()
+	total := closed + m.OpenIssueCount()
+	if total == 0 {
+		return 0
+	}
+	return closed * 100 / total
+}
+
+// Milestone returns the milestone the issue is planned for, nil if none
+func (i *Issue) Milestone() *Milestone {
+	if i.MilestoneID == "" {
+		return nil
+	}
+	m, err := Milestones.Get(i.MilestoneID)
+	if err != nil {
+		return nil
+	}
+	return m
+}
+
+// SetIssueMilestone plans an issue for one of its repository's milestones,
+// or for none when milestoneID is ""
+func SetIssueMilestone(issue *Issue, milestoneID string) error {
+	if milestoneID != "" {
+		m, err := Milestones.Get(milestoneID)
+		if err != nil || m.RepoID != issue.RepoID {
+			return errors.New("milestone not found")
+		}
+	}
+	issue.MilestoneID = milestoneID
+	return Issues.Update(issue)
+}
@@ -0,0 +1,99 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/The-Skyscape/devtools/pkg/testutils"
+)
+
+func TestMilestones(t *testing.T) {
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+
+	user := CreateTestUser(t, db, "planner@example.com")
+	repo := createTestRepository(t, "planned-repo", user.ID)
+	today := time.Now().Truncate(24 * time.Hour)
+
+	t.Run("CreateMilestone", func(t *testing.T) {
+		_, err := CreateMilestone(repo.ID, " ", "", time.Time{}, user.ID)
+		testutils.AssertError(t, err)
+
+		m, err := CreateMilestone(repo.ID, "v1.0", "First release", today.AddDate(0, 0, 14), user.ID)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, MilestoneOpen, m.Status)
+
+		_, err = CreateMilestone(repo.ID, "v1.0", "", time.Time{}, user.ID)
+		testutils.AssertError(t, err)
+	})
+
+	t.Run("GetRepoMilestones", func(t *testing.T) {
+		_, err := CreateMilestone(repo.ID, "Someday", "", time.Time{}, user.ID)
+		testutils.AssertNoError(t, err)
+		beta, err := CreateMilestone(repo.ID, "Beta", "", today.AddDate(0, 0, 3), user.ID)
+		testutils.AssertNoError(t, err)
+		alpha, err := CreateMilestone(repo.ID, "Alpha", "", today.AddDate(0, 0, -3), user.ID)
+		testutils.AssertNoError(t, err)
+		testutils.AssertTrue(t, alpha.IsOverdue())
+
+		open, err := GetRepoMilestones(repo.ID, false)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 4, len(open))
+		testutils.AssertEqual(t, "Alpha", open[0].Title)
+		testutils.AssertEqual(t, "Beta", open[1].Title)
+		testutils.AssertEqual(t, "v1.0", open[2].Title)
+		testutils.AssertEqual(t, "Someday", open[3].Title)
+
+		testutils.AssertNoError(t, beta.SetStatus(MilestoneClosed))
+		testutils.AssertFalse(t, beta.ClosedAt.IsZero())
+		testutils.AssertError(t, beta.SetStatus("archived"))
+
+		open, err = GetRepoMilestones(repo.ID, false)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 3, len(open))
+
+		all, err := GetRepoMilestones(repo.ID, true)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 4, len(all))
+		testutils.AssertEqual(t, "Beta", all[3].Title)
+	})
+
+	t.Run("Progress", func(t *testing.T) {
+		m, err := CreateMilestone(repo.ID, "v2.0", "", time.Time{}, user.ID)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 0, m.Progress())
+
+		for _, title := range []string{"One", "Two", "Three", "Four"} {
+			issue, err := CreateIssue(title, "", user.ID, repo.ID)
+			testutils.AssertNoError(t, err)
+			testutils.AssertNoError(t, SetIssueMilestone(issue, m.ID))
+			if title == "One" {
+				issue.Status = IssueStatusClosed
+				testutils.AssertNoError(t, Issues.Update(issue))
+			}
+		}
+		testutils.AssertEqual(t, 3, m.OpenIssueCount())
+		testutils.AssertEqual(t, 1, m.ClosedIssueCount())
+		testutils.AssertEqual(t, 25, m.Progress())
+
+		issues, err := m.Issues()
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 4, len(issues))
+		testutils.AssertEqual(t, "One", issues[3].Title)
+		testutils.AssertEqual(t, m.ID, issues[0].Milestone().ID)
+
+		other := createTestRepository(t, "other-repo", user.ID)
+		stray, err := CreateIssue("Stray", "", user.ID, other.ID)
+		testutils.AssertNoError(t, err)
+		testutils.AssertError(t, SetIssueMilestone(stray, m.ID))
+
+		testutils.AssertNoError(t, m.Edit("v2", "Second release", time.Time{}))
+		testutils.AssertError(t, m.Edit("Alpha", "", time.Time{}))
+
+		testutils.AssertNoError(t, DeleteMilestone(m))
+		stored, err := Issues.Get(issues[0].ID)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, "", stored.MilestoneID)
+		testutils.AssertTrue(t, stored.Milestone() == nil)
+	})
+}
@@ -0,0 +1,160 @@
+package models
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/The-Skyscape/devtools/pkg/application"
+	"github.com/pkg/errors"
+)
+
+// Hosts repositories can be mirrored to
+const (
+	MirrorGitHub = "github"
+	MirrorGitLab = "gitlab"
+)
+
+// DefaultMirrorIntervalHours is how often repositories are mirrored unless
+// another interval is set
+const DefaultMirrorIntervalHours = 24
+
+// MirrorSecretKey is the vault key of the mirror export's access token
+const MirrorSecretKey = "mirror/export"
+
+// How a repository's last push to the mirror went
+const (
+	MirrorSucceeded = "success"
+	MirrorFailed    = "failed"
+)
+
+// RepoMirror is the latest push of a repository to the mirror organization
+type RepoMirror struct {
+	application.Model
+	RepoID    string
+	RemoteURL string // Web address of the mirror
+	Status    string // MirrorSucceeded or MirrorFailed
+	Error     string
+	PushedAt  time.Time // Last successful push
+	Duration  int64     // Milliseconds the last push took
+}
+
+// Table returns the database table name
+func (*RepoMirror) Table() string { return "repo_mirrors" }
+
+// MirrorEnabled reports whether repositories are mirrored to another host
+func (s *Settings) MirrorEnabled() bool {
+	return s.MirrorProvider != "" && s.MirrorOrg != ""
+}
+
+// MirrorInterval returns the time between mirror exports
+func (s *Settings) MirrorInterval() time.Duration {
+	if s.MirrorIntervalHours <= 0 {
+		return DefaultMirrorIntervalHours * time.Hour
+	}
+	return time.Duration(s.MirrorIntervalHours) * time.Hour
+}
+
+// MirrorDue reports whether the next mirror export should run
+func (s *Settings) MirrorDue(now time.Time) bool {
+	return s.MirrorEnabled() && !now.Before(s.MirrorNextRunAt())
+}
+
+// MirrorNextRunAt returns when the next mirror export is due
+func (s *Settings) MirrorNextRunAt() time.Time {
+	return s.MirrorLastRunAt.Add(s.MirrorInterval())
+}
+
+// StoreMirrorToken stores the access token mirrors are pushed with
+func StoreMirrorToken(token string) error {
+	return StoreSecret(MirrorSecretKey, map[string]any{"token": token})
+}
+
+// GetMirrorToken returns the access token mirrors are pushed with
+func GetMirrorToken() (string, error) {
+	secret, err := Secrets.GetSecret(MirrorSecretKey)
+	if err != nil {
+		return "", err
+	}
+	token, _ := secret["token"].(string)
+	if token == "" {
+		return "", errors.New("mirror access token not found")
+	}
+	return token, nil
+}
+
+// DeleteMirrorToken removes the mirror export's access token
+func DeleteMirrorToken() error {
+	return DeleteSecret(MirrorSecretKey)
+}
+
+// RecordRepoMirror saves the outcome of pushing a repository to its mirror
+func RecordRepoMirror(repoID, remoteURL string, pushErr error, duration time.Duration) (*RepoMirror, error) {
+	mirror, err := RepoMirrorFor(repoID)
+	if err != nil {
+		mirror = &RepoMirror{RepoID: repoID}
+	}
+
+	mirror.RemoteURL = remoteURL
+	mirror.Duration = duration.Milliseconds()
+	mirror.Status = MirrorSucceeded
+	mirror.Error = ""
+	if pushErr != nil {
+		mirror.Status = MirrorFailed
+		mirror.Error = pushErr.Error()
+	} else {
+		mirror.PushedAt = time.Now()
+	}
+
+	if mirror.ID == "" {
+		mirror, err = RepoMirrors.Insert(mirror)
+	} else {
+		err = RepoMirrors.Update(mirror)
+	}
+	return mirror, errors.Wrap(err, "failed to save mirror status")
+}
+
+// RepoMirrorFor returns a repository's mirror status
+func RepoMirrorFor(repoID string) (*RepoMirror, error) {
+	mirrors, err := RepoMirrors.Search("WHERE RepoID = ? LIMIT 1", repoID)
+	if err != nil {
+		return nil, err
+	}
+	if len(mirrors) == 0 {
+		return nil, errors.New("repository has not been mirrored")
+	}
+	return mirrors[0], nil
+}
+
+// MirrorReportRow is a repository with its mirror status, nil when it
+// hasn't been pushed yet
+type MirrorReportRow struct {
+	Repo   *Repository
+	Mirror *RepoMirror
+}
+
+// MirrorReport returns every repository with its mirror status, by name
+func MirrorReport() ([]MirrorReportRow, error) {
+	repos, err := Repositories.Search("")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list repositories")
+	}
+	mirrors, err := RepoMirrors.Search("")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list mirrors")
+	}
+
+	byRepo := make(map[string]*RepoMirror, len(mirrors))
+	for _, mirror := range mirrors {
+		byRepo[mirror.RepoID] = mirror
+	}
+
+	rows := make([]MirrorReportRow, 0, len(repos))
+	for _, repo := range repos {
+		rows = append(rows, MirrorReportRow{Repo: repo, Mirror: byRepo[repo.ID]})
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		return strings.ToLower(rows[i].Repo.Name) < strings.ToLower(rows[j].Repo.Name)
+	})
+	return rows, nil
+}
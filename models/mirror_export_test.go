@@ -0,0 +1,64 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/The-Skyscape/devtools/pkg/testutils"
+)
+
+func TestMirrorExport(t *testing.T) {
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+
+	t.Run("MirrorDue", func(t *testing.T) {
+		now := time.Now()
+		settings := &Settings{}
+		testutils.AssertFalse(t, settings.MirrorDue(now))
+
+		settings.MirrorProvider = MirrorGitHub
+		settings.MirrorOrg = "acme-backup"
+		testutils.AssertTrue(t, settings.MirrorDue(now))
+
+		settings.MirrorLastRunAt = now.Add(-time.Hour)
+		testutils.AssertFalse(t, settings.MirrorDue(now))
+
+		settings.MirrorIntervalHours = 1
+		testutils.AssertTrue(t, settings.MirrorDue(now))
+	})
+
+	t.Run("RecordRepoMirrorKeepsLatest", func(t *testing.T) {
+		mirror, err := RecordRepoMirror("alpha", "https://github.com/acme-backup/alpha", nil, time.Second)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, MirrorSucceeded, mirror.Status)
+		pushedAt := mirror.PushedAt
+
+		mirror, err = RecordRepoMirror("alpha", "https://github.com/acme-backup/alpha", errors.New("permission denied"), time.Second)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, MirrorFailed, mirror.Status)
+		testutils.AssertEqual(t, "permission denied", mirror.Error)
+		testutils.AssertTrue(t, mirror.PushedAt.Equal(pushedAt))
+
+		mirrors, err := RepoMirrors.Search("WHERE RepoID = ?", "alpha")
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 1, len(mirrors))
+	})
+
+	t.Run("MirrorReportListsEveryRepository", func(t *testing.T) {
+		beta, err := Repositories.Insert(&Repository{Name: "beta"})
+		testutils.AssertNoError(t, err)
+		alpha, err := Repositories.Insert(&Repository{Name: "Alpha"})
+		testutils.AssertNoError(t, err)
+		_, err = RecordRepoMirror(alpha.ID, "https://github.com/acme-backup/alpha", nil, time.Second)
+		testutils.AssertNoError(t, err)
+
+		rows, err := MirrorReport()
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 2, len(rows))
+		testutils.AssertEqual(t, alpha.ID, rows[0].Repo.ID)
+		testutils.AssertEqual(t, MirrorSucceeded, rows[0].Mirror.Status)
+		testutils.AssertEqual(t, beta.ID, rows[1].Repo.ID)
+		testutils.AssertTrue(t, rows[1].Mirror == nil)
+	})
+}
@@ -0,0 +1,94 @@
+package models
+
+import (
+	"time"
+
+	"github.com/The-Skyscape/devtools/pkg/application"
+	"github.com/pkg/errors"
+)
+
+// Notification is an in-app message for one user
+type Notification struct {
+	application.Model
+	UserID string
+	Type   string // "review_requested", "review_reminder", ...
+	Title  string
+	Body   string
+	URL    string // Page the notification links to
+	RepoID string
+	Read   bool
+	ReadAt time.Time
+}
+
+// Table returns the database table name
+func (*Notification) Table() string { return "notifications" }
+
+// Notify sends an in-app notification to a user
+func Notify(userID, kind, title, body, url, repoID string) error {
+	if userID == "" {
+		return errors.New("notification has no recipient")
+	}
+	_, err := Notifications.Insert(&Notification{
+		UserID: userID,
+		Type:   kind,
+		Title:  title,
+		Body:   body,
+		URL:    url,
+		RepoID: repoID,
+	})
+	return errors.Wrap(err, "failed to save notification")
+}
+
+// NotifyAdmins sends an in-app notification to every administrator
+func NotifyAdmins(kind, title, body, url string) error {
+	admins, err := Users.Search("WHERE IsAdmin = ?", true)
+	if err != nil {
+		return errors.Wrap(err, "failed to find administrators")
+	}
+	for _, admin := range admins {
+		if err := Notify(admin.ID, kind, title, body, url, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetNotifications returns a user's most recent notifications, newest first
+func GetNotifications(userID string, limit int) ([]*Notification, error) {
+	return Notifications.Search("WHERE UserID = ? ORDER BY CreatedAt DESC LIMIT ?", userID, limit)
+}
+
+// CountUnreadNotifications returns how many notifications a user hasn't read
+func CountUnreadNotifications(userID string) int {
+	return Notifications.Count("WHERE UserID = ? AND Read = ?", userID, false)
+}
+
+// MarkNotificationRead marks one of a user's notifications as read
+func MarkNotificationRead(id, userID string) (*Notification, error) {
+	notification, err := Notifications.Get(id)
+	if err != nil || notification.UserID != userID {
+		return nil, errors.New("notification not found")
+	}
+	if notification.Read {
+		return notification, nil
+	}
+	notification.Read = true
+	notification.ReadAt = time.Now()
+	return notification, Notifications.Update(notification)
+}
+
+// MarkAllNotificationsRead marks every notification of a user as read
+func MarkAllNotificationsRead(userID string) error {
+	unread, err := Notifications.Search("WHERE UserID = ? AND Read = ?", userID, false)
+	if err != nil {
+		return err
+	}
+	for _, notification := range unread {
+		notification.Read = true
+		notification.ReadAt = time.Now()
+		if err := Notifications.Update(notification); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"time"
+
+	"github.com/The-Skyscape/devtools/pkg/application"
+	"github.com/pkg/errors"
+)
+
+// PasswordResetLifetime is how long an emailed reset link works
+const PasswordResetLifetime = time.Hour
+
+// PasswordReset lets a user who forgot their password choose a new one.
+// Only a hash of the emailed token is stored.
+type PasswordReset struct {
+	application.Model
+	UserID    string
+	TokenHash string
+	ExpiresAt time.Time
+	UsedAt    time.Time
+}
+
+// Table returns the database table name
+func (*PasswordReset) Table() string { return "password_resets" }
+
+// CreatePasswordReset starts a reset for a user, returning the token to
+// email them. Earlier resets of the user stop working.
+func CreatePasswordReset(userID string) (*PasswordReset, string, error) {
+	if earlier, err := PasswordResets.Search("WHERE UserID = ?", userID); err == nil {
+		for _, reset := range earlier {
+			PasswordResets.Delete(reset)
+		}
+	}
+
+	token := GenerateToken()
+	reset, err := PasswordResets.Insert(&PasswordReset{
+		Model:     DB.NewModel(""),
+		UserID:    userID,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(PasswordResetLifetime),
+	})
+	if err != nil {
+		return nil, "", errors.Wrap(err, "failed to start password reset")
+	}
+	return reset, token, nil
+}
+
+// GetPasswordReset returns the usable reset for a token, failing for
+// unknown, expired and used tokens alike
+func GetPasswordReset(token string) (*PasswordReset, error) {
+	if token != "" {
+		resets, err := PasswordResets.Search("WHERE TokenHash = ?", hashToken(token))
+		if err == nil && len(resets) > 0 && resets[0].Usable() {
+			return resets[0], nil
+		}
+	}
+	return nil, errors.New("password reset link is invalid or has expired")
+}
+
+// Usable returns true when the reset hasn't been used or expired
+func (p *PasswordReset) Usable() bool {
+	return p.UsedAt.IsZero() && time.Now().Before(p.ExpiresAt)
+}
+
+// Use marks the reset as used, so its link stops working
+func (p *PasswordReset) Use() error {
+	p.UsedAt = time.Now()
+	return PasswordResets.Update(p)
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/The-Skyscape/devtools/pkg/testutils"
+)
+
+func TestPasswordResets(t *testing.T) {
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+
+	user := CreateTestUser(t, db, "reset@example.com")
+
+	t.Run("CreatePasswordReset", func(t *testing.T) {
+		reset, token, err := CreatePasswordReset(user.ID)
+		testutils.AssertNoError(t, err)
+		testutils.AssertTrue(t, reset.TokenHash != token)
+
+		found, err := GetPasswordReset(token)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, reset.ID, found.ID)
+
+		_, err = GetPasswordReset("")
+		testutils.AssertError(t, err)
+	})
+
+	t.Run("NewerResetReplacesOlder", func(t *testing.T) {
+		_, first, err := CreatePasswordReset(user.ID)
+		testutils.AssertNoError(t, err)
+		_, second, err := CreatePasswordReset(user.ID)
+		testutils.AssertNoError(t, err)
+
+		_, err = GetPasswordReset(first)
+		testutils.AssertError(t, err)
+		_, err = GetPasswordReset(second)
+		testutils.AssertNoError(t, err)
+	})
+
+	t.Run("Use", func(t *testing.T) {
+		reset, token, err := CreatePasswordReset(user.ID)
+		testutils.AssertNoError(t, err)
+		testutils.AssertNoError(t, reset.Use())
+		_, err = GetPasswordReset(token)
+		testutils.AssertError(t, err)
+
+		reset, _, err = CreatePasswordReset(user.ID)
+		testutils.AssertNoError(t, err)
+		reset.ExpiresAt = time.Now().Add(-time.Minute)
+		testutils.AssertFalse(t, reset.Usable())
+	})
+}
@@ -0,0 +1,202 @@
+package models
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/The-Skyscape/devtools/pkg/application"
+	"github.com/pkg/errors"
+)
+
+// Scopes a personal access token can be limited to
+const (
+	ScopeRepoRead  = "repo:read"  // Read repositories, commits and statuses
+	ScopeRepoWrite = "repo:write" // Also report statuses and change files
+	ScopeIssues    = "issues"     // Issues, pull requests and comments
+	ScopeAI        = "ai"         // The assistant and its conversations
+	ScopeAdmin     = "admin"      // Everything, for admins only
+)
+
+// TokenScope describes a scope for the token settings page
+type TokenScope struct {
+	Name        string
+	Description string
+}
+
+// TokenScopes lists the scopes a token can have
+var TokenScopes = []TokenScope{
+	{ScopeRepoRead, "Read repositories, files, commits and commit statuses"},
+	{ScopeRepoWrite, "Report commit statuses and change repositories, includes repo:read"},
+	{ScopeIssues, "Read and write issues, pull requests and comments"},
+	{ScopeAI, "Chat with the assistant and read its conversations"},
+	{ScopeAdmin, "Everything the account can do, including workspace administration"},
+}
+
+// PersonalTokenPrefix starts every personal access token, so leaked
+// tokens are easy to spot
+const PersonalTokenPrefix = "sky_"
+
+// MaxPersonalTokenTTL is the longest a personal access token may stay
+// valid
+const MaxPersonalTokenTTL = 365 * 24 * time.Hour
+
+// maxPersonalTokens is how many active tokens a user may have
+const maxPersonalTokens = 20
+
+// personalTokenUseInterval is how often a token's last use is saved, so
+// scripts calling the API in a loop don't write on every request
+const personalTokenUseInterval = time.Minute
+
+// PersonalToken lets scripts and CI call the HTTP API as a user with
+// "Authorization: Bearer", limited to some scopes. Only a hash of the
+// token is stored, so it can be shown once when it is created.
+type PersonalToken struct {
+	application.Model
+	UserID     string
+	Name       string
+	TokenHash  string
+	Hint       string // Start of the token, to tell tokens apart
+	Scopes     string // Space separated
+	ExpiresAt  time.Time
+	LastUsedAt time.Time
+	LastUsedIP string
+	RevokedAt  time.Time
+}
+
+// Table returns the database table name
+func (*PersonalToken) Table() string { return "personal_tokens" }
+
+// CreatePersonalToken creates a token for a user that expires after ttl,
+// returning the token and its value to show them
+func CreatePersonalToken(userID, name string, scopes []string, ttl time.Duration) (*PersonalToken, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, "", errors.New("a name is required so the token can be told apart later")
+	}
+	if ttl <= 0 || ttl > MaxPersonalTokenTTL {
+		return nil, "", errors.Errorf("tokens must expire within %d days", int(MaxPersonalTokenTTL.Hours()/24))
+	}
+
+	user, err := Users.Get(userID)
+	if err != nil {
+		return nil, "", errors.New("user not found")
+	}
+
+	// Keep the scopes in the order they're listed, without repeats
+	var granted []string
+	for _, scope := range TokenScopes {
+		if slices.Contains(scopes, scope.Name) {
+			granted = append(granted, scope.Name)
+		}
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(granted, scope) {
+			return nil, "", errors.Errorf("unknown scope %q", scope)
+		}
+	}
+	if len(granted) == 0 {
+		return nil, "", errors.New("choose at least one scope")
+	}
+	if slices.Contains(granted, ScopeAdmin) && !user.IsAdmin {
+		return nil, "", errors.New("only admins can create tokens with the admin scope")
+	}
+
+	if existing, err := UserPersonalTokens(userID); err == nil {
+		active := 0
+		for _, token := range existing {
+			if token.Active() {
+				active++
+			}
+		}
+		if active >= maxPersonalTokens {
+			return nil, "", errors.Errorf("a maximum of %d active tokens is allowed per user, revoke unused ones first", maxPersonalTokens)
+		}
+	}
+
+	value := PersonalTokenPrefix + GenerateToken()
+	token, err := PersonalTokens.Insert(&PersonalToken{
+		Model:     DB.NewModel(""),
+		UserID:    userID,
+		Name:      name,
+		TokenHash: hashToken(value),
+		Hint:      value[:len(PersonalTokenPrefix)+6],
+		Scopes:    strings.Join(granted, " "),
+		ExpiresAt: time.Now().Add(ttl),
+	})
+	if err != nil {
+		return nil, "", errors.Wrap(err, "failed to create token")
+	}
+	return token, value, nil
+}
+
+// GetPersonalToken returns the active token for a value, failing for
+// unknown, expired and revoked tokens alike
+func GetPersonalToken(value string) (*PersonalToken, error) {
+	if strings.HasPrefix(value, PersonalTokenPrefix) {
+		tokens, err := PersonalTokens.Search("WHERE TokenHash = ?", hashToken(value))
+		if err == nil && len(tokens) > 0 && tokens[0].Active() {
+			return tokens[0], nil
+		}
+	}
+	return nil, errors.New("token is invalid, expired or revoked")
+}
+
+// UserPersonalTokens returns a user's tokens, newest first
+func UserPersonalTokens(userID string) ([]*PersonalToken, error) {
+	return PersonalTokens.Search("WHERE UserID = ? ORDER BY CreatedAt DESC", userID)
+}
+
+// ScopeList returns the token's scopes
+func (t *PersonalToken) ScopeList() []string {
+	return strings.Fields(t.Scopes)
+}
+
+// HasScope reports whether the token allows a scope. The admin scope
+// allows everything and repo:write allows repo:read.
+func (t *PersonalToken) HasScope(scope string) bool {
+	scopes := t.ScopeList()
+	switch {
+	case slices.Contains(scopes, ScopeAdmin), slices.Contains(scopes, scope):
+		return true
+	case scope == ScopeRepoRead:
+		return slices.Contains(scopes, ScopeRepoWrite)
+	}
+	return false
+}
+
+// Active reports whether the token can still be used
+func (t *PersonalToken) Active() bool {
+	return t.RevokedAt.IsZero() && time.Now().Before(t.ExpiresAt)
+}
+
+// Status returns "active", "expired" or "revoked"
+func (t *PersonalToken) Status() string {
+	switch {
+	case !t.RevokedAt.IsZero():
+		return "revoked"
+	case !t.Active():
+		return "expired"
+	default:
+		return "active"
+	}
+}
+
+// Revoke stops the token from working
+func (t *PersonalToken) Revoke() error {
+	if !t.RevokedAt.IsZero() {
+		return nil
+	}
+	t.RevokedAt = time.Now()
+	return errors.Wrap(PersonalTokens.Update(t), "failed to revoke token")
+}
+
+// Used records that the token was just used from an address
+func (t *PersonalToken) Used(ip string) error {
+	if time.Since(t.LastUsedAt) < personalTokenUseInterval && t.LastUsedIP == ip {
+		return nil
+	}
+	t.LastUsedAt = time.Now()
+	t.LastUsedIP = ip
+	return PersonalTokens.Update(t)
+}
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/The-Skyscape/devtools/pkg/testutils"
+)
+
+func TestPersonalTokens(t *testing.T) {
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+
+	user := CreateTestUser(t, db, "tokens@example.com")
+	admin := CreateTestUser(t, db, "tokens-admin@example.com")
+	admin.IsAdmin = true
+	testutils.AssertNoError(t, Users.Update(admin))
+
+	t.Run("CreatePersonalToken", func(t *testing.T) {
+		token, value, err := CreatePersonalToken(user.ID, "CI", []string{ScopeIssues, ScopeRepoWrite, ScopeIssues}, 30*24*time.Hour)
+		testutils.AssertNoError(t, err)
+		testutils.AssertTrue(t, strings.HasPrefix(value, PersonalTokenPrefix))
+		testutils.AssertTrue(t, strings.HasPrefix(value, token.Hint))
+		testutils.AssertEqual(t, "repo:write issues", token.Scopes)
+
+		// Only the hash is kept
+		testutils.AssertFalse(t, strings.Contains(token.TokenHash, value))
+
+		found, err := GetPersonalToken(value)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, token.ID, found.ID)
+
+		_, err = GetPersonalToken(strings.TrimPrefix(value, PersonalTokenPrefix))
+		testutils.AssertError(t, err)
+	})
+
+	t.Run("ValidatesTokens", func(t *testing.T) {
+		_, _, err := CreatePersonalToken(user.ID, " ", []string{ScopeRepoRead}, time.Hour)
+		testutils.AssertError(t, err)
+		_, _, err = CreatePersonalToken(user.ID, "Never", []string{ScopeRepoRead}, 0)
+		testutils.AssertError(t, err)
+		_, _, err = CreatePersonalToken(user.ID, "Nothing", nil, time.Hour)
+		testutils.AssertError(t, err)
+		_, _, err = CreatePersonalToken(user.ID, "Unknown", []string{"repo:delete"}, time.Hour)
+		testutils.AssertError(t, err)
+
+		// Only admins get the admin scope
+		_, _, err = CreatePersonalToken(user.ID, "Admin", []string{ScopeAdmin}, time.Hour)
+		testutils.AssertError(t, err)
+		_, _, err = CreatePersonalToken(admin.ID, "Admin", []string{ScopeAdmin}, time.Hour)
+		testutils.AssertNoError(t, err)
+	})
+
+	t.Run("HasScope", func(t *testing.T) {
+		write := &PersonalToken{Scopes: ScopeRepoWrite}
+		testutils.AssertTrue(t, write.HasScope(ScopeRepoRead))
+		testutils.AssertTrue(t, write.HasScope(ScopeRepoWrite))
+		testutils.AssertFalse(t, write.HasScope(ScopeIssues))
+
+		read := &PersonalToken{Scopes: ScopeRepoRead}
+		testutils.AssertFalse(t, read.HasScope(ScopeRepoWrite))
+
+		admin := &PersonalToken{Scopes: ScopeAdmin}
+		testutils.AssertTrue(t, admin.HasScope(ScopeAI))
+	})
+
+	t.Run("Revoke", func(t *testing.T) {
+		token, value, err := CreatePersonalToken(user.ID, "Laptop", []string{ScopeAI}, time.Hour)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, "active", token.Status())
+
+		testutils.AssertNoError(t, token.Revoke())
+		testutils.AssertEqual(t, "revoked", token.Status())
+		_, err = GetPersonalToken(value)
+		testutils.AssertError(t, err)
+
+		expired := &PersonalToken{ExpiresAt: time.Now().Add(-time.Minute)}
+		testutils.AssertEqual(t, "expired", expired.Status())
+	})
+
+	t.Run("Used", func(t *testing.T) {
+		token, _, err := CreatePersonalToken(user.ID, "Script", []string{ScopeRepoRead}, time.Hour)
+		testutils.AssertNoError(t, err)
+		testutils.AssertTrue(t, token.LastUsedAt.IsZero())
+
+		testutils.AssertNoError(t, token.Used("10.0.0.1"))
+		testutils.AssertFalse(t, token.LastUsedAt.IsZero())
+		testutils.AssertEqual(t, "10.0.0.1", token.LastUsedIP)
+	})
+}
@@ -0,0 +1,109 @@
+package models
+
+import (
+	"slices"
+	"strings"
+)
+
+// Playbook is a predefined AI workflow. Starting one opens a conversation
+// bound to a repository, limited to the playbook's tools and seeded with
+// its opening prompt.
+type Playbook struct {
+	ID          string
+	Name        string
+	Description string
+	InputLabel  string // Extra detail the playbook asks for, empty when none
+	InputHint   string // Placeholder for the input field
+	NeedsInput  bool
+	Tools       []string
+	Prompt      string // Rendered with the prompt variables, plus {{input}}
+}
+
+// Playbooks are offered in the AI panel
+var Playbooks = []*Playbook{
+	{
+		ID:          "triage-repo",
+		Name:        "Triage this repo",
+		Description: "Review open issues and pull requests and suggest what to work on next",
+		InputLabel:  "Focus",
+		InputHint:   "Optional, e.g. bugs only",
+		Tools: []string{
+			"get_repo", "list_files", "read_file", "search_files",
+			"list_issues", "update_issue", "list_prs", "git_log",
+			"list_milestones", "todo_list", "todo_update",
+		},
+		Prompt: "Triage {{repo}}. List the open issues and pull requests, group them by area and urgency, and flag anything stale, duplicated or missing information. " +
+			"Look at recent commits on {{branch}} to see what is actively being worked on. Finish with the five things that should be done next and why. " +
+			"Ask before changing any issue.\n\n{{input}}",
+	},
+	{
+		ID:          "write-tests",
+		Name:        "Write tests for a file",
+		Description: "Add tests for a file, following the repository's existing test layout",
+		InputLabel:  "File path",
+		InputHint:   "e.g. models/repository.go",
+		NeedsInput:  true,
+		Tools: []string{
+			"list_files", "read_file", "search_files", "write_file",
+			"edit_file", "run_command", "test", "git_status", "git_diff",
+			"todo_list", "todo_update",
+		},
+		Prompt: "Write tests for `{{input}}` in {{repo}} on {{branch}}. Read the file and the existing tests first, then cover its public behavior and edge cases " +
+			"using the same layout, naming and helpers the repository already uses. Run the tests and fix any failures before summarizing what you added.",
+	},
+	{
+		ID:          "prepare-release",
+		Name:        "Prepare a release",
+		Description: "Summarize changes since the last release and check the branch is ready to ship",
+		InputLabel:  "Notes",
+		InputHint:   "Optional, e.g. releasing as v1.4.0",
+		Tools: []string{
+			"git_log", "git_diff", "git_status", "git_branch", "list_prs",
+			"list_issues", "list_milestones", "update_milestone", "list_files",
+			"read_file", "edit_file", "build", "test", "todo_list", "todo_update",
+		},
+		Prompt: "Prepare a release of {{repo}} from {{branch}}. Go through the commits and merged pull requests since the last release and draft release notes " +
+			"grouped into features, fixes and breaking changes. Check for open issues or milestones that should block the release, then build and run the tests. " +
+			"Finish with a go / no-go recommendation.\n\n{{input}}",
+	},
+	{
+		ID:          "investigate-ci",
+		Name:        "Investigate CI failure",
+		Description: "Find out why the latest failed action run broke and how to fix it",
+		InputLabel:  "Details",
+		InputHint:   "Optional, e.g. started after the last merge",
+		Tools: []string{
+			"list_files", "read_file", "search_files", "git_log", "git_diff",
+			"run_command", "build", "test", "create_issue", "todo_list", "todo_update",
+		},
+		Prompt: "Investigate the CI failure in {{repo}} on {{branch}}. Work out the root cause from the log and the recent changes, reproduce it with a build or test run if you can, " +
+			"and propose a fix. Offer to open an issue if the fix is not obvious.\n\n{{input}}",
+	},
+}
+
+// GetPlaybook returns the playbook with the given ID, or nil
+func GetPlaybook(id string) *Playbook {
+	for _, playbook := range Playbooks {
+		if playbook.ID == id {
+			return playbook
+		}
+	}
+	return nil
+}
+
+// Render builds the playbook's opening message from the prompt variables
+// and the user's input
+func (p *Playbook) Render(vars map[string]string, input string) string {
+	withInput := make(map[string]string, len(vars)+1)
+	for name, value := range vars {
+		withInput[name] = value
+	}
+	withInput["input"] = strings.TrimSpace(input)
+	return RenderPrompt(p.Prompt, withInput)
+}
+
+// AllowsTool reports whether conversations started from the playbook may
+// use the named tool
+func (p *Playbook) AllowsTool(name string) bool {
+	return slices.Contains(p.Tools, name)
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/The-Skyscape/devtools/pkg/testutils"
+)
+
+func TestPlaybooks(t *testing.T) {
+	t.Run("GetPlaybook", func(t *testing.T) {
+		playbook := GetPlaybook("write-tests")
+		testutils.AssertTrue(t, playbook != nil)
+		testutils.AssertEqual(t, "Write tests for a file", playbook.Name)
+		testutils.AssertTrue(t, GetPlaybook("missing") == nil)
+	})
+
+	t.Run("Render", func(t *testing.T) {
+		vars := map[string]string{"repo": "billing", "branch": "main"}
+
+		prompt := GetPlaybook("write-tests").Render(vars, " models/invoice.go ")
+		testutils.AssertTrue(t, strings.HasPrefix(prompt, "Write tests for `models/invoice.go` in billing on main."))
+
+		prompt = GetPlaybook("triage-repo").Render(vars, "")
+		testutils.AssertTrue(t, strings.HasSuffix(prompt, "Ask before changing any issue."))
+		testutils.AssertEqual(t, "", vars["input"])
+	})
+
+	t.Run("Tools", func(t *testing.T) {
+		for _, playbook := range Playbooks {
+			testutils.AssertTrue(t, len(playbook.Tools) > 0)
+			testutils.AssertTrue(t, strings.Contains(playbook.Prompt, "{{repo}}"))
+		}
+
+		playbook := GetPlaybook("triage-repo")
+		testutils.AssertTrue(t, playbook.AllowsTool("list_issues"))
+		testutils.AssertFalse(t, playbook.AllowsTool("delete_repo"))
+	})
+}
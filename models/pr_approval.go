@@ -0,0 +1,100 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/The-Skyscape/devtools/pkg/application"
+	"github.com/pkg/errors"
+)
+
+// PRApproval is a user's approval of a pull request at the commit they
+// reviewed. Pushing new commits to the branch leaves it outdated, and only
+// approvals of the latest commit count toward branch protection.
+type PRApproval struct {
+	application.Model
+	PRID      string
+	RepoID    string
+	UserID    string
+	CommitSHA string // Head of the compare branch when approved
+}
+
+// Table returns the database table name
+func (*PRApproval) Table() string { return "pr_approvals" }
+
+// ApprovePullRequest records a user's approval of the pull request's
+// latest commit, replacing any earlier approval of theirs
+func ApprovePullRequest(pr *PullRequest, userID string) (*PRApproval, error) {
+	if pr.Status != "open" {
+		return nil, errors.New("only open pull requests can be approved")
+	}
+	if userID == pr.AuthorID {
+		return nil, errors.New("authors can't approve their own pull request")
+	}
+	head, err := pr.HeadCommit()
+	if err != nil {
+		return nil, err
+	}
+
+	// Approving completes the user's review request
+	if err := RecordReview(pr, userID); err != nil {
+		return nil, errors.Wrap(err, "failed to record review")
+	}
+
+	existing, err := PRApprovals.Search("WHERE PRID = ? AND UserID = ? LIMIT 1", pr.ID, userID)
+	if err == nil && len(existing) > 0 {
+		approval := existing[0]
+		approval.CommitSHA = head
+		approval.UpdatedAt = time.Now()
+		return approval, errors.Wrap(PRApprovals.Update(approval), "failed to approve pull request")
+	}
+
+	approval, err := PRApprovals.Insert(&PRApproval{
+		PRID:      pr.ID,
+		RepoID:    pr.RepoID,
+		UserID:    userID,
+		CommitSHA: head,
+	})
+	return approval, errors.Wrap(err, "failed to approve pull request")
+}
+
+// GetPRApprovals returns the approvals of a pull request, oldest first
+func GetPRApprovals(prID string) ([]*PRApproval, error) {
+	return PRApprovals.Search("WHERE PRID = ? ORDER BY CreatedAt ASC", prID)
+}
+
+// HeadCommit returns the commit at the tip of the pull request's branch
+func (pr *PullRequest) HeadCommit() (string, error) {
+	repo, err := pr.Repository()
+	if err != nil {
+		return "", errors.New("repository not found")
+	}
+	out, _, err := repo.Git("rev-parse", "--verify", "refs/heads/"+pr.CompareBranch)
+	if err != nil {
+		return "", errors.Errorf("branch %s not found", pr.CompareBranch)
+	}
+	return strings.TrimSpace(out.String()), nil
+}
+
+// ApprovalCount returns how many users approved the pull request's latest
+// commit
+func (pr *PullRequest) ApprovalCount() (int, error) {
+	approvals, err := GetPRApprovals(pr.ID)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to get approvals")
+	}
+	if len(approvals) == 0 {
+		return 0, nil
+	}
+	head, err := pr.HeadCommit()
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, approval := range approvals {
+		if approval.CommitSHA == head {
+			count++
+		}
+	}
+	return count, nil
+}
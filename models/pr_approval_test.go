@@ -0,0 +1,112 @@
+package models
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/The-Skyscape/devtools/pkg/testutils"
+)
+
+func TestPRApprovals(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+	t.Setenv("DATA_DIR", t.TempDir())
+
+	repo, err := Repositories.Insert(&Repository{Name: "approvals"})
+	testutils.AssertNoError(t, err)
+
+	work := t.TempDir()
+	git := func(args ...string) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = work
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=Test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=Test", "GIT_COMMITTER_EMAIL=test@example.com")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %s", strings.Join(args, " "), out)
+		}
+	}
+	commit := func(message string) {
+		os.WriteFile(filepath.Join(work, "file.txt"), []byte(message), 0644)
+		git("add", "file.txt")
+		git("commit", "-m", message)
+		git("push", repo.Path(), "main", "main:feature")
+	}
+	git("init", "--bare", "-b", "main", repo.Path())
+	git("init", "-b", "main")
+	commit("first")
+
+	pr, err := PullRequests.Insert(&PullRequest{
+		Title: "Feature", RepoID: repo.ID, AuthorID: "author",
+		BaseBranch: "main", CompareBranch: "feature", Status: "open",
+	})
+	testutils.AssertNoError(t, err)
+
+	_, err = SaveBranchProtection(&BranchProtection{
+		RepoID: repo.ID, Pattern: "main", RequiredApprovals: 2, RequiredChecks: "Unit Tests",
+	}, "admin")
+	testutils.AssertNoError(t, err)
+
+	t.Run("AuthorsCantApprove", func(t *testing.T) {
+		_, err := ApprovePullRequest(pr, "author")
+		testutils.AssertError(t, err)
+	})
+
+	t.Run("BlockedUntilApprovedAndChecked", func(t *testing.T) {
+		blockers, err := pr.MergeBlockers()
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 2, len(blockers))
+		testutils.AssertEqual(t, "0 of 2 required approvals of the latest commit", blockers[0])
+		testutils.AssertEqual(t, "Unit Tests has not passed on feature yet", blockers[1])
+
+		_, err = ApprovePullRequest(pr, "reviewer-1")
+		testutils.AssertNoError(t, err)
+		_, err = ApprovePullRequest(pr, "reviewer-1")
+		testutils.AssertNoError(t, err)
+		_, err = ApprovePullRequest(pr, "reviewer-2")
+		testutils.AssertNoError(t, err)
+		count, err := pr.ApprovalCount()
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 2, count)
+
+		action, err := Actions.Insert(&Action{Title: "unit tests", RepoID: repo.ID})
+		testutils.AssertNoError(t, err)
+		run, err := ActionRuns.Insert(&ActionRun{ActionID: action.ID, Status: "failed", ExitCode: 1, Branch: "feature"})
+		testutils.AssertNoError(t, err)
+		blockers, err = pr.MergeBlockers()
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 1, len(blockers))
+		testutils.AssertEqual(t, "Unit Tests failed on feature", blockers[0])
+
+		run.Status, run.ExitCode = "completed", 0
+		testutils.AssertNoError(t, ActionRuns.Update(run))
+		blockers, err = pr.MergeBlockers()
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 0, len(blockers))
+	})
+
+	t.Run("NewCommitsOutdateApprovals", func(t *testing.T) {
+		commit("second")
+		count, err := pr.ApprovalCount()
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 0, count)
+
+		approvals, err := GetPRApprovals(pr.ID)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 2, len(approvals))
+	})
+
+	t.Run("UnprotectedBranchesMerge", func(t *testing.T) {
+		other := &PullRequest{RepoID: repo.ID, BaseBranch: "develop", CompareBranch: "feature"}
+		blockers, err := other.MergeBlockers()
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 0, len(blockers))
+	})
+}
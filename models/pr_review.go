@@ -0,0 +1,162 @@
+package models
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/The-Skyscape/devtools/pkg/application"
+	"github.com/pkg/errors"
+)
+
+// PRReview is a review submitted on a pull request: an approval, a
+// request for changes, or a comment. A reviewer's latest approval or
+// request for changes is where they stand; comments leave it unchanged.
+type PRReview struct {
+	application.Model
+	PRID       string
+	RepoID     string
+	UserID     string
+	State      string // ReviewApproved, ReviewChangesRequested or ReviewCommented
+	Body       string
+	CommitSHA  string // Head of the compare branch when reviewed
+	Superseded bool   // The reviewer approved or requested changes again since
+}
+
+// Table returns the database table name
+func (*PRReview) Table() string { return "pr_reviews" }
+
+// Review state constants
+const (
+	ReviewApproved         = "approved"
+	ReviewChangesRequested = "changes_requested"
+	ReviewCommented        = "commented"
+)
+
+// SubmitReview records a user's review of the pull request's latest
+// commit. Approving also counts toward branch protection, and requesting
+// changes withdraws the user's earlier approval.
+func SubmitReview(pr *PullRequest, userID, state, body string) (*PRReview, error) {
+	body = strings.TrimSpace(body)
+	switch state {
+	case ReviewApproved:
+		if _, err := ApprovePullRequest(pr, userID); err != nil {
+			return nil, err
+		}
+	case ReviewChangesRequested, ReviewCommented:
+		if pr.Status != "open" {
+			return nil, errors.New("only open pull requests can be reviewed")
+		}
+		if userID == pr.AuthorID && state == ReviewChangesRequested {
+			return nil, errors.New("authors can't request changes on their own pull request")
+		}
+		if state == ReviewChangesRequested && body == "" {
+			return nil, errors.New("say what needs to change")
+		}
+		if state == ReviewCommented && body == "" {
+			return nil, errors.New("review comment is empty")
+		}
+		if err := RecordReview(pr, userID); err != nil {
+			return nil, errors.Wrap(err, "failed to record review")
+		}
+	default:
+		return nil, errors.Errorf("unknown review state %q", state)
+	}
+
+	head, _ := pr.HeadCommit()
+	if state == ReviewChangesRequested {
+		if err := DB.Query("DELETE FROM pr_approvals WHERE PRID = ? AND UserID = ?", pr.ID, userID).Exec(); err != nil {
+			return nil, errors.Wrap(err, "failed to withdraw approval")
+		}
+	}
+	if state != ReviewCommented {
+		if err := supersedeReviews(pr.ID, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	review, err := PRReviews.Insert(&PRReview{
+		PRID:      pr.ID,
+		RepoID:    pr.RepoID,
+		UserID:    userID,
+		State:     state,
+		Body:      body,
+		CommitSHA: head,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to submit review")
+	}
+
+	if userID != pr.AuthorID {
+		Notify(pr.AuthorID, "pr_reviewed", review.Summary()+": "+pr.Title, body, pr.URL(), pr.RepoID)
+	}
+	return review, nil
+}
+
+// GetPRReviews returns the reviews of a pull request, oldest first
+func GetPRReviews(prID string) ([]*PRReview, error) {
+	return PRReviews.Search("WHERE PRID = ? ORDER BY CreatedAt ASC", prID)
+}
+
+// Summary describes the review in a few words
+func (r *PRReview) Summary() string {
+	switch r.State {
+	case ReviewApproved:
+		return "Approved"
+	case ReviewChangesRequested:
+		return "Changes requested"
+	default:
+		return "Commented"
+	}
+}
+
+// supersedeReviews marks a user's earlier approvals and requests for
+// changes on a pull request as replaced by a new one
+func supersedeReviews(prID, userID string) error {
+	earlier, err := PRReviews.Search("WHERE PRID = ? AND UserID = ? AND State != ?", prID, userID, ReviewCommented)
+	if err != nil {
+		return errors.Wrap(err, "failed to get earlier reviews")
+	}
+	for _, review := range earlier {
+		if review.Superseded {
+			continue
+		}
+		review.Superseded = true
+		if err := PRReviews.Update(review); err != nil {
+			return errors.Wrap(err, "failed to update earlier review")
+		}
+	}
+	return nil
+}
+
+// ReviewStates returns where each reviewer stands on the pull request:
+// their latest approval or request for changes, by user ID
+func (pr *PullRequest) ReviewStates() (map[string]string, error) {
+	reviews, err := GetPRReviews(pr.ID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get reviews")
+	}
+	states := map[string]string{}
+	for _, review := range reviews {
+		if review.State != ReviewCommented && !review.Superseded {
+			states[review.UserID] = review.State
+		}
+	}
+	return states, nil
+}
+
+// ChangesRequestedBy returns the users whose latest review of the pull
+// request asks for changes
+func (pr *PullRequest) ChangesRequestedBy() ([]string, error) {
+	states, err := pr.ReviewStates()
+	if err != nil {
+		return nil, err
+	}
+	var users []string
+	for userID, state := range states {
+		if state == ReviewChangesRequested {
+			users = append(users, userID)
+		}
+	}
+	sort.Strings(users)
+	return users, nil
+}
@@ -0,0 +1,119 @@
+package models
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/The-Skyscape/devtools/pkg/testutils"
+)
+
+func TestPRReviews(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+	t.Setenv("DATA_DIR", t.TempDir())
+
+	repo, err := Repositories.Insert(&Repository{Name: "reviews"})
+	testutils.AssertNoError(t, err)
+
+	work := t.TempDir()
+	git := func(args ...string) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = work
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=Test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=Test", "GIT_COMMITTER_EMAIL=test@example.com")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %s", strings.Join(args, " "), out)
+		}
+	}
+	git("init", "--bare", "-b", "main", repo.Path())
+	git("init", "-b", "main")
+	os.WriteFile(filepath.Join(work, "file.txt"), []byte("first"), 0644)
+	git("add", "file.txt")
+	git("commit", "-m", "first")
+	git("push", repo.Path(), "main", "main:feature")
+
+	reviewer := CreateTestUser(t, db, "reviewer@example.com")
+	pr, err := PullRequests.Insert(&PullRequest{
+		Title: "Feature", RepoID: repo.ID, AuthorID: "author",
+		BaseBranch: "main", CompareBranch: "feature", Status: "open", Draft: true,
+	})
+	testutils.AssertNoError(t, err)
+
+	t.Run("DraftsDontMerge", func(t *testing.T) {
+		blockers, err := pr.MergeBlockers()
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 1, len(blockers))
+		testutils.AssertEqual(t, "it is a draft", blockers[0])
+
+		pr.Draft = false
+		testutils.AssertNoError(t, PullRequests.Update(pr))
+		blockers, err = pr.MergeBlockers()
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 0, len(blockers))
+	})
+
+	t.Run("RejectsInvalidReviews", func(t *testing.T) {
+		_, err := SubmitReview(pr, "author", ReviewChangesRequested, "Fix it")
+		testutils.AssertError(t, err)
+		_, err = SubmitReview(pr, "author", ReviewApproved, "")
+		testutils.AssertError(t, err)
+		_, err = SubmitReview(pr, reviewer.ID, ReviewChangesRequested, "  ")
+		testutils.AssertError(t, err)
+		_, err = SubmitReview(pr, reviewer.ID, ReviewCommented, "")
+		testutils.AssertError(t, err)
+		_, err = SubmitReview(pr, reviewer.ID, "merged", "")
+		testutils.AssertError(t, err)
+
+		// Authors can reply with comments
+		_, err = SubmitReview(pr, "author", ReviewCommented, "Thanks for looking")
+		testutils.AssertNoError(t, err)
+	})
+
+	t.Run("ChangesRequestedBlockMerge", func(t *testing.T) {
+		_, err := SubmitReview(pr, reviewer.ID, ReviewApproved, "")
+		testutils.AssertNoError(t, err)
+		count, err := pr.ApprovalCount()
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 1, count)
+
+		_, err = SubmitReview(pr, reviewer.ID, ReviewChangesRequested, "Needs tests")
+		testutils.AssertNoError(t, err)
+		count, err = pr.ApprovalCount()
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 0, count)
+
+		// Comments don't change where the reviewer stands
+		_, err = SubmitReview(pr, reviewer.ID, ReviewCommented, "Especially for the edge cases")
+		testutils.AssertNoError(t, err)
+		blockers, err := pr.MergeBlockers()
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 1, len(blockers))
+		testutils.AssertEqual(t, reviewer.Name+" requested changes", blockers[0])
+	})
+
+	t.Run("ApprovingClearsRequest", func(t *testing.T) {
+		_, err := SubmitReview(pr, reviewer.ID, ReviewApproved, "Looks good now")
+		testutils.AssertNoError(t, err)
+
+		states, err := pr.ReviewStates()
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 1, len(states))
+		testutils.AssertEqual(t, ReviewApproved, states[reviewer.ID])
+
+		requested, err := pr.ChangesRequestedBy()
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 0, len(requested))
+
+		reviews, err := GetPRReviews(pr.ID)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 5, len(reviews))
+	})
+}
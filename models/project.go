@@ -0,0 +1,485 @@
+package models
+
+import (
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/The-Skyscape/devtools/pkg/application"
+	"github.com/pkg/errors"
+)
+
+// Project is a kanban board planning the work of a repository, or of the
+// whole workspace when it has no repository
+type Project struct {
+	application.Model
+	RepoID      string // Empty for boards spanning repositories
+	Name        string
+	Description string
+	Swimlanes   string // Rows cards are grouped into, one of ProjectSwimlanes
+	CreatedBy   string
+}
+
+// Table returns the database table name
+func (*Project) Table() string { return "projects" }
+
+// ProjectColumn is a column of a board
+type ProjectColumn struct {
+	application.Model
+	ProjectID string
+	Name      string
+	Position  int // Order on the board
+	WIPLimit  int // Most cards the column should hold, 0 for no limit
+}
+
+// Table returns the database table name
+func (*ProjectColumn) Table() string { return "project_columns" }
+
+// ProjectRule moves the cards tracking an issue or pull request to a
+// column when something happens to it
+type ProjectRule struct {
+	application.Model
+	ProjectID string
+	Event     string // One of ProjectEvents
+	ColumnID  string
+}
+
+// Table returns the database table name
+func (*ProjectRule) Table() string { return "project_rules" }
+
+// DefaultProjectColumns are the columns new boards start with
+var DefaultProjectColumns = []string{"To do", "In progress", "Done"}
+
+// ProjectSwimlanes are the ways a board can group its cards into rows
+var ProjectSwimlanes = []string{"", "assignee", "label", "repository"}
+
+// ProjectEvents are what rules react to. Opened issues and pull requests
+// get a card on their repository's boards, the others move the cards
+// already tracking them.
+var ProjectEvents = []string{"issue_opened", "issue_closed", "issue_reopened", "pr_opened", "pr_merged", "pr_closed"}
+
+// ProjectEventNames describe ProjectEvents for people
+var ProjectEventNames = map[string]string{
+	"issue_opened":   "Issue opened",
+	"issue_closed":   "Issue closed",
+	"issue_reopened": "Issue reopened",
+	"pr_opened":      "Pull request opened",
+	"pr_merged":      "Pull request merged",
+	"pr_closed":      "Pull request closed without merging",
+}
+
+// CreateProject adds a board with the default columns. Closed issues and
+// merged pull requests move to Done, and reopened issues back to To do.
+func CreateProject(repoID, name, description, userID string) (*Project, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, errors.New("board name is required")
+	}
+	if repoID != "" {
+		if _, err := Repositories.Get(repoID); err != nil {
+			return nil, errors.New("repository not found")
+		}
+	}
+
+	project, err := Projects.Insert(&Project{
+		Model:       DB.NewModel(""),
+		RepoID:      repoID,
+		Name:        name,
+		Description: strings.TrimSpace(description),
+		CreatedBy:   userID,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create board")
+	}
+
+	var columns []*ProjectColumn
+	for _, name := range DefaultProjectColumns {
+		column, err := project.AddColumn(name, 0)
+		if err != nil {
+			return nil, err
+		}
+		columns = append(columns, column)
+	}
+
+	first, last := columns[0], columns[len(columns)-1]
+	for event, column := range map[string]*ProjectColumn{
+		"issue_closed":   last,
+		"issue_reopened": first,
+		"pr_merged":      last,
+	} {
+		if err := project.SetRule(event, column.ID); err != nil {
+			return nil, err
+		}
+	}
+	return project, nil
+}
+
+// GetProjects returns the boards of a repository, or the boards spanning
+// repositories when repoID is empty
+func GetProjects(repoID string) ([]*Project, error) {
+	return Projects.Search("WHERE RepoID = ? ORDER BY Name", repoID)
+}
+
+// RepoBoard returns a repository's first board, creating one if it has
+// none yet
+func RepoBoard(repoID, userID string) (*Project, error) {
+	projects, err := Projects.Search("WHERE RepoID = ? ORDER BY CreatedAt ASC LIMIT 1", repoID)
+	if err == nil && len(projects) > 0 {
+		return projects[0], nil
+	}
+	return CreateProject(repoID, "Board", "", userID)
+}
+
+// Edit renames the board or changes how it groups cards
+func (p *Project) Edit(name, description, swimlanes string) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return errors.New("board name is required")
+	}
+	if !slices.Contains(ProjectSwimlanes, swimlanes) {
+		return errors.Errorf("invalid swimlanes %q", swimlanes)
+	}
+	p.Name = name
+	p.Description = strings.TrimSpace(description)
+	p.Swimlanes = swimlanes
+	return Projects.Update(p)
+}
+
+// DeleteProject removes a board with its columns, cards and rules
+func DeleteProject(p *Project) error {
+	for _, table := range []string{"project_cards", "project_rules", "project_columns"} {
+		if err := DB.Query("DELETE FROM "+table+" WHERE ProjectID = ?", p.ID).Exec(); err != nil {
+			return errors.Wrap(err, "failed to delete board")
+		}
+	}
+	return Projects.Delete(p)
+}
+
+// Repo returns the repository the board plans, nil for boards spanning
+// repositories
+func (p *Project) Repo() *Repository {
+	if p.RepoID == "" {
+		return nil
+	}
+	repo, err := Repositories.Get(p.RepoID)
+	if err != nil {
+		return nil
+	}
+	return repo
+}
+
+// Columns returns the board's columns in order
+func (p *Project) Columns() ([]*ProjectColumn, error) {
+	return ProjectColumns.Search("WHERE ProjectID = ? ORDER BY Position ASC", p.ID)
+}
+
+// Column returns one of the board's columns. An empty ID or name returns
+// the first column; names match regardless of case.
+func (p *Project) Column(idOrName string) (*ProjectColumn, error) {
+	columns, err := p.Columns()
+	if err != nil {
+		return nil, err
+	}
+	if len(columns) == 0 {
+		return nil, errors.New("the board has no columns")
+	}
+	if idOrName == "" {
+		return columns[0], nil
+	}
+	for _, column := range columns {
+		if column.ID == idOrName || strings.EqualFold(column.Name, idOrName) {
+			return column, nil
+		}
+	}
+	return nil, errors.Errorf("column %q not found", idOrName)
+}
+
+// AddColumn adds a column to the right of the board
+func (p *Project) AddColumn(name string, wipLimit int) (*ProjectColumn, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, errors.New("column name is required")
+	}
+	if wipLimit < 0 {
+		return nil, errors.New("WIP limit can't be negative")
+	}
+
+	position := 1
+	if columns, err := p.Columns(); err == nil && len(columns) > 0 {
+		position = columns[len(columns)-1].Position + 1
+	}
+	return ProjectColumns.Insert(&ProjectColumn{
+		Model:     DB.NewModel(""),
+		ProjectID: p.ID,
+		Name:      name,
+		Position:  position,
+		WIPLimit:  wipLimit,
+	})
+}
+
+// Edit renames the column or changes its WIP limit
+func (c *ProjectColumn) Edit(name string, wipLimit int) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return errors.New("column name is required")
+	}
+	if wipLimit < 0 {
+		return errors.New("WIP limit can't be negative")
+	}
+	c.Name = name
+	c.WIPLimit = wipLimit
+	return ProjectColumns.Update(c)
+}
+
+// Shift swaps the column with its neighbor to the left (-1) or right (1)
+func (c *ProjectColumn) Shift(direction int) error {
+	query := "WHERE ProjectID = ? AND Position > ? ORDER BY Position ASC LIMIT 1"
+	if direction < 0 {
+		query = "WHERE ProjectID = ? AND Position < ? ORDER BY Position DESC LIMIT 1"
+	}
+	neighbors, err := ProjectColumns.Search(query, c.ProjectID, c.Position)
+	if err != nil || len(neighbors) == 0 {
+		return nil
+	}
+
+	neighbor := neighbors[0]
+	c.Position, neighbor.Position = neighbor.Position, c.Position
+	if err := ProjectColumns.Update(neighbor); err != nil {
+		return err
+	}
+	return ProjectColumns.Update(c)
+}
+
+// DeleteProjectColumn removes an empty column and the rules moving cards
+// into it. A board keeps at least one column.
+func DeleteProjectColumn(c *ProjectColumn) error {
+	if c.CardCount() > 0 {
+		return errors.Errorf("move the cards out of %s first", c.Name)
+	}
+	if ProjectColumns.Count("WHERE ProjectID = ?", c.ProjectID) <= 1 {
+		return errors.New("a board needs at least one column")
+	}
+	if err := DB.Query("DELETE FROM project_rules WHERE ColumnID = ?", c.ID).Exec(); err != nil {
+		return errors.Wrap(err, "failed to delete the column's rules")
+	}
+	return ProjectColumns.Delete(c)
+}
+
+// Cards returns the column's cards in order
+func (c *ProjectColumn) Cards() ([]*ProjectCard, error) {
+	return ProjectCards.Search("WHERE Column = ? ORDER BY Position ASC", c.ID)
+}
+
+// LaneCards returns the column's cards in one swimlane of the board
+func (c *ProjectColumn) LaneCards(swimlanes, lane string) ([]*ProjectCard, error) {
+	cards, err := c.Cards()
+	if err != nil || swimlanes == "" {
+		return cards, err
+	}
+	return slices.DeleteFunc(cards, func(card *ProjectCard) bool {
+		return card.Lane(swimlanes) != lane
+	}), nil
+}
+
+// CardCount returns how many cards the column holds
+func (c *ProjectColumn) CardCount() int {
+	return ProjectCards.Count("WHERE Column = ?", c.ID)
+}
+
+// IsFull returns true when the column has reached its WIP limit
+func (c *ProjectColumn) IsFull() bool {
+	return c.WIPLimit > 0 && c.CardCount() >= c.WIPLimit
+}
+
+// IsOverLimit returns true when automation has pushed the column past its
+// WIP limit
+func (c *ProjectColumn) IsOverLimit() bool {
+	return c.WIPLimit > 0 && c.CardCount() > c.WIPLimit
+}
+
+// Lanes returns the board's swimlanes in order, with cards missing what
+// the lanes group by last. Boards without swimlanes have a single one.
+func (p *Project) Lanes() []string {
+	if p.Swimlanes == "" {
+		return []string{""}
+	}
+	cards, err := ProjectCards.Search("WHERE ProjectID = ?", p.ID)
+	if err != nil {
+		return []string{""}
+	}
+
+	none := noLane[p.Swimlanes]
+	var lanes []string
+	hasNone := false
+	for _, card := range cards {
+		lane := card.Lane(p.Swimlanes)
+		if lane == none {
+			hasNone = true
+		} else if !slices.Contains(lanes, lane) {
+			lanes = append(lanes, lane)
+		}
+	}
+	slices.Sort(lanes)
+	if hasNone || len(lanes) == 0 {
+		lanes = append(lanes, none)
+	}
+	return lanes
+}
+
+// noLane names the swimlane of cards missing what the lanes group by
+var noLane = map[string]string{
+	"assignee":   "Unassigned",
+	"label":      "No label",
+	"repository": "No repository",
+}
+
+// Rules returns the board's automation rules
+func (p *Project) Rules() ([]*ProjectRule, error) {
+	return ProjectRules.Search("WHERE ProjectID = ?", p.ID)
+}
+
+// RuleColumn returns the ID of the column an event moves cards to, empty
+// when the board doesn't react to it
+func (p *Project) RuleColumn(event string) string {
+	rules, err := ProjectRules.Search("WHERE ProjectID = ? AND Event = ?", p.ID, event)
+	if err != nil || len(rules) == 0 {
+		return ""
+	}
+	return rules[0].ColumnID
+}
+
+// SetRule makes an event move cards to a column. An empty column ID stops
+// the board reacting to the event.
+func (p *Project) SetRule(event, columnID string) error {
+	if !slices.Contains(ProjectEvents, event) {
+		return errors.Errorf("invalid event %q", event)
+	}
+	if err := DB.Query("DELETE FROM project_rules WHERE ProjectID = ? AND Event = ?", p.ID, event).Exec(); err != nil {
+		return errors.Wrap(err, "failed to update rule")
+	}
+	if columnID == "" {
+		return nil
+	}
+
+	column, err := ProjectColumns.Get(columnID)
+	if err != nil || column.ProjectID != p.ID {
+		return errors.New("column not found on this board")
+	}
+	_, err = ProjectRules.Insert(&ProjectRule{
+		ProjectID: p.ID,
+		Event:     event,
+		ColumnID:  column.ID,
+	})
+	return err
+}
+
+// RunProjectRules applies the rules of every board to an issue or pull
+// request something happened to. Rules ignore WIP limits, so columns can
+// end up over them.
+func RunProjectRules(event, repoID, issueID, pullRequestID string) {
+	if strings.HasSuffix(event, "_opened") {
+		projects, err := GetProjects(repoID)
+		if err != nil || repoID == "" {
+			return
+		}
+		for _, project := range projects {
+			columnID := project.RuleColumn(event)
+			if columnID == "" || project.Tracks(issueID, pullRequestID) {
+				continue
+			}
+			card := &ProjectCard{
+				ProjectID:     project.ID,
+				RepoID:        repoID,
+				Column:        columnID,
+				IssueID:       issueID,
+				PullRequestID: pullRequestID,
+			}
+			if issueID != "" {
+				if issue, err := Issues.Get(issueID); err == nil {
+					card.Title = issue.Title
+				}
+			} else if pr, err := PullRequests.Get(pullRequestID); err == nil {
+				card.Title = pr.Title
+			}
+			card.Model = DB.NewModel("")
+			card.Position = nextCardPosition(columnID)
+			if _, err := ProjectCards.Insert(card); err != nil {
+				log.Printf("Failed to add card to board %s: %v", project.ID, err)
+			}
+		}
+		return
+	}
+
+	query, id := "WHERE IssueID = ?", issueID
+	if issueID == "" {
+		query, id = "WHERE PullRequestID = ?", pullRequestID
+	}
+	cards, err := ProjectCards.Search(query, id)
+	if err != nil {
+		return
+	}
+	for _, card := range cards {
+		project, err := Projects.Get(card.ProjectID)
+		if err != nil {
+			continue
+		}
+		if columnID := project.RuleColumn(event); columnID != "" && columnID != card.Column {
+			if err := card.place(columnID, ""); err != nil {
+				log.Printf("Failed to move card %s: %v", card.ID, err)
+			}
+		}
+	}
+}
+
+// Tracks returns true if the board has a card for the issue or pull request
+func (p *Project) Tracks(issueID, pullRequestID string) bool {
+	if issueID != "" {
+		return ProjectCards.Count("WHERE ProjectID = ? AND IssueID = ?", p.ID, issueID) > 0
+	}
+	return ProjectCards.Count("WHERE ProjectID = ? AND PullRequestID = ?", p.ID, pullRequestID) > 0
+}
+
+// DeleteRepoProjects removes a repository's boards and its cards on
+// boards spanning repositories
+func DeleteRepoProjects(repoID string) {
+	DB.Query("DELETE FROM project_cards WHERE ProjectID IN (SELECT ID FROM projects WHERE RepoID = ?)", repoID).Exec()
+	DB.Query("DELETE FROM project_rules WHERE ProjectID IN (SELECT ID FROM projects WHERE RepoID = ?)", repoID).Exec()
+	DB.Query("DELETE FROM project_columns WHERE ProjectID IN (SELECT ID FROM projects WHERE RepoID = ?)", repoID).Exec()
+	DB.Query("DELETE FROM projects WHERE RepoID = ?", repoID).Exec()
+}
+
+// migrateProjectCards moves cards from the fixed columns repositories used
+// to have onto a board of each repository
+func migrateProjectCards() {
+	cards, err := ProjectCards.Search("WHERE ProjectID = ''")
+	if err != nil || len(cards) == 0 {
+		return
+	}
+
+	legacy := []string{"todo", "in_progress", "done"}
+	for _, card := range cards {
+		if _, err := Repositories.Get(card.RepoID); err != nil {
+			ProjectCards.Delete(card)
+			continue
+		}
+		project, err := RepoBoard(card.RepoID, card.CreatedBy)
+		if err != nil {
+			log.Printf("Failed to migrate card %s: %v", card.ID, err)
+			continue
+		}
+		columns, err := project.Columns()
+		if err != nil || len(columns) == 0 {
+			continue
+		}
+		column := columns[0]
+		if i := slices.Index(legacy, card.Column); i >= 0 && i < len(columns) {
+			column = columns[i]
+		}
+		card.ProjectID = project.ID
+		card.Column = column.ID
+		if err := ProjectCards.Update(card); err != nil {
+			log.Printf("Failed to migrate card %s: %v", card.ID, err)
+		}
+	}
+	log.Printf("Moved %d cards onto repository boards", len(cards))
+}
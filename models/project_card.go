@@ -0,0 +1,262 @@
+package models
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/The-Skyscape/devtools/pkg/application"
+	"github.com/pkg/errors"
+)
+
+// ProjectCard is a card on a kanban board. A card either tracks an issue
+// or pull request, or is a free-standing note.
+type ProjectCard struct {
+	application.Model
+	ProjectID     string
+	RepoID        string // Repository of the tracked issue or pull request
+	Column        string // ID of the ProjectColumn the card is in
+	Title         string
+	Note          string
+	IssueID       string // Tracked issue, if any
+	PullRequestID string // Tracked pull request, if any
+	Position      int    // Order within the column
+	CreatedBy     string
+}
+
+// Table returns the database table name
+func (*ProjectCard) Table() string { return "project_cards" }
+
+// CreateProjectCard adds a card to the end of a column, the board's first
+// when none is given. When the card tracks an issue or pull request, its
+// title defaults to theirs.
+func CreateProjectCard(card *ProjectCard) (*ProjectCard, error) {
+	project, err := Projects.Get(card.ProjectID)
+	if err != nil {
+		return nil, errors.New("board not found")
+	}
+	column, err := project.Column(card.Column)
+	if err != nil {
+		return nil, err
+	}
+	if column.IsFull() {
+		return nil, errors.Errorf("%s is at its WIP limit of %d", column.Name, column.WIPLimit)
+	}
+	if card.IssueID != "" && card.PullRequestID != "" {
+		return nil, errors.New("a card can track an issue or a pull request, not both")
+	}
+
+	card.RepoID = project.RepoID
+	switch {
+	case card.IssueID != "":
+		issue, err := Issues.Get(card.IssueID)
+		if err != nil || (project.RepoID != "" && issue.RepoID != project.RepoID) {
+			return nil, errors.New("issue not found in this repository")
+		}
+		if project.Tracks(issue.ID, "") {
+			return nil, errors.Errorf("issue #%s already has a card", issue.ID)
+		}
+		card.RepoID = issue.RepoID
+		if strings.TrimSpace(card.Title) == "" {
+			card.Title = issue.Title
+		}
+	case card.PullRequestID != "":
+		pr, err := PullRequests.Get(card.PullRequestID)
+		if err != nil || (project.RepoID != "" && pr.RepoID != project.RepoID) {
+			return nil, errors.New("pull request not found in this repository")
+		}
+		if project.Tracks("", pr.ID) {
+			return nil, errors.Errorf("pull request #%s already has a card", pr.ID)
+		}
+		card.RepoID = pr.RepoID
+		if strings.TrimSpace(card.Title) == "" {
+			card.Title = pr.Title
+		}
+	}
+
+	card.Title = strings.TrimSpace(card.Title)
+	if card.Title == "" {
+		return nil, errors.New("card title is required")
+	}
+
+	card.Model = DB.NewModel("")
+	card.Column = column.ID
+	card.Position = nextCardPosition(column.ID)
+	return ProjectCards.Insert(card)
+}
+
+// GetProjectCards returns a board's cards ordered by column and position.
+// An empty column returns the whole board.
+func GetProjectCards(projectID, columnID string) ([]*ProjectCard, error) {
+	if columnID != "" {
+		return ProjectCards.Search("WHERE ProjectID = ? AND Column = ? ORDER BY Position ASC", projectID, columnID)
+	}
+
+	project, err := Projects.Get(projectID)
+	if err != nil {
+		return nil, err
+	}
+	columns, err := project.Columns()
+	if err != nil {
+		return nil, err
+	}
+	var cards []*ProjectCard
+	for _, column := range columns {
+		columnCards, err := column.Cards()
+		if err != nil {
+			return nil, err
+		}
+		cards = append(cards, columnCards...)
+	}
+	return cards, nil
+}
+
+// Move places the card in a column before another card, or at the end of
+// the column when before is empty. Cards can't move into full columns.
+func (c *ProjectCard) Move(columnID, before string) error {
+	column, err := ProjectColumns.Get(columnID)
+	if err != nil || column.ProjectID != c.ProjectID {
+		return errors.New("column not found on this board")
+	}
+	if column.ID != c.Column && column.IsFull() {
+		return errors.Errorf("%s is at its WIP limit of %d", column.Name, column.WIPLimit)
+	}
+	return c.place(column.ID, before)
+}
+
+// place puts the card in a column before another card and renumbers the
+// column's cards
+func (c *ProjectCard) place(columnID, before string) error {
+	cards, err := ProjectCards.Search("WHERE Column = ? AND ID != ? ORDER BY Position ASC", columnID, c.ID)
+	if err != nil {
+		return err
+	}
+
+	at := len(cards)
+	for i, card := range cards {
+		if card.ID == before {
+			at = i
+			break
+		}
+	}
+	cards = slices.Insert(cards, at, c)
+
+	c.Column = columnID
+	for i, card := range cards {
+		if card.Position != i+1 || card == c {
+			card.Position = i + 1
+			if err := ProjectCards.Update(card); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Project returns the board the card is on
+func (c *ProjectCard) Project() (*Project, error) {
+	return Projects.Get(c.ProjectID)
+}
+
+// ColumnName returns the name of the column the card is in
+func (c *ProjectCard) ColumnName() string {
+	column, err := ProjectColumns.Get(c.Column)
+	if err != nil {
+		return ""
+	}
+	return column.Name
+}
+
+// Issue returns the issue the card tracks
+func (c *ProjectCard) Issue() (*Issue, error) {
+	return Issues.Get(c.IssueID)
+}
+
+// PullRequest returns the pull request the card tracks
+func (c *ProjectCard) PullRequest() (*PullRequest, error) {
+	return PullRequests.Get(c.PullRequestID)
+}
+
+// Labels returns the labels of the card's issue
+func (c *ProjectCard) Labels() []*TagDefinition {
+	if c.IssueID == "" {
+		return nil
+	}
+	issue, err := c.Issue()
+	if err != nil {
+		return nil
+	}
+	labels, _ := issue.Labels()
+	return labels
+}
+
+// Repository returns the repository of the card's issue or pull request,
+// nil for notes on boards spanning repositories
+func (c *ProjectCard) Repository() *Repository {
+	if c.RepoID == "" {
+		return nil
+	}
+	repo, err := Repositories.Get(c.RepoID)
+	if err != nil {
+		return nil
+	}
+	return repo
+}
+
+// IsClosed returns true if the card's issue or pull request is closed or
+// merged
+func (c *ProjectCard) IsClosed() bool {
+	switch {
+	case c.IssueID != "":
+		issue, err := c.Issue()
+		return err == nil && (issue.Status == "closed" || issue.Status == "resolved")
+	case c.PullRequestID != "":
+		pr, err := c.PullRequest()
+		return err == nil && (pr.Status == "closed" || pr.Status == "merged")
+	}
+	return false
+}
+
+// Lane returns the swimlane the card is in when a board groups cards by
+// assignee, label or repository
+func (c *ProjectCard) Lane(swimlanes string) string {
+	switch swimlanes {
+	case "assignee":
+		if c.IssueID != "" {
+			if issue, err := c.Issue(); err == nil {
+				if users, err := issue.Assignees(); err == nil && len(users) > 0 {
+					return users[0].Name
+				}
+			}
+		} else if c.PullRequestID != "" {
+			if pr, err := c.PullRequest(); err == nil {
+				if user, err := Users.Get(pr.AuthorID); err == nil {
+					return user.Name
+				}
+			}
+		}
+	case "label":
+		if c.IssueID != "" {
+			if issue, err := c.Issue(); err == nil {
+				if labels, err := issue.Labels(); err == nil && len(labels) > 0 {
+					return labels[0].Name
+				}
+			}
+		}
+	case "repository":
+		if repo, err := Repositories.Get(c.RepoID); err == nil {
+			return repo.Name
+		}
+	default:
+		return ""
+	}
+	return noLane[swimlanes]
+}
+
+// nextCardPosition returns the position after the last card in a column
+func nextCardPosition(columnID string) int {
+	cards, err := ProjectCards.Search("WHERE Column = ? ORDER BY Position DESC LIMIT 1", columnID)
+	if err != nil || len(cards) == 0 {
+		return 1
+	}
+	return cards[0].Position + 1
+}
@@ -0,0 +1,83 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/The-Skyscape/devtools/pkg/testutils"
+)
+
+func TestProjectCards(t *testing.T) {
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+
+	user := CreateTestUser(t, db, "board@example.com")
+	repo := createTestRepository(t, "board-repo", user.ID)
+	issue, err := CreateIssue("Fix login", "Users can't sign in", user.ID, repo.ID)
+	testutils.AssertNoError(t, err)
+
+	board, err := RepoBoard(repo.ID, user.ID)
+	testutils.AssertNoError(t, err)
+	columns, err := board.Columns()
+	testutils.AssertNoError(t, err)
+	testutils.AssertEqual(t, 3, len(columns))
+	todo, doing, done := columns[0], columns[1], columns[2]
+
+	t.Run("CreateProjectCard", func(t *testing.T) {
+		card, err := CreateProjectCard(&ProjectCard{ProjectID: board.ID, IssueID: issue.ID})
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, todo.ID, card.Column)
+		testutils.AssertEqual(t, repo.ID, card.RepoID)
+		testutils.AssertEqual(t, "Fix login", card.Title)
+		testutils.AssertEqual(t, 1, card.Position)
+
+		_, err = CreateProjectCard(&ProjectCard{ProjectID: board.ID, IssueID: issue.ID})
+		testutils.AssertError(t, err)
+		_, err = CreateProjectCard(&ProjectCard{ProjectID: board.ID, IssueID: "missing"})
+		testutils.AssertError(t, err)
+		_, err = CreateProjectCard(&ProjectCard{ProjectID: board.ID, Column: "backlog", Title: "Note"})
+		testutils.AssertError(t, err)
+		_, err = CreateProjectCard(&ProjectCard{ProjectID: board.ID})
+		testutils.AssertError(t, err)
+
+		note, err := CreateProjectCard(&ProjectCard{ProjectID: board.ID, Column: "to do", Title: "Write release notes"})
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 2, note.Position)
+	})
+
+	t.Run("Move", func(t *testing.T) {
+		setup, err := CreateProjectCard(&ProjectCard{ProjectID: board.ID, Column: done.ID, Title: "Set up CI"})
+		testutils.AssertNoError(t, err)
+
+		cards, err := GetProjectCards(board.ID, todo.ID)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 2, len(cards))
+
+		// Reorder within the column
+		testutils.AssertNoError(t, cards[1].Move(todo.ID, cards[0].ID))
+		cards, err = todo.Cards()
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, "Write release notes", cards[0].Title)
+
+		testutils.AssertNoError(t, cards[0].Move(doing.ID, ""))
+		testutils.AssertError(t, cards[1].Move("shipped", ""))
+
+		all, err := GetProjectCards(board.ID, "")
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 3, len(all))
+		testutils.AssertEqual(t, todo.ID, all[0].Column)
+		testutils.AssertEqual(t, doing.ID, all[1].Column)
+		testutils.AssertEqual(t, setup.ID, all[2].ID)
+	})
+
+	t.Run("WIPLimit", func(t *testing.T) {
+		testutils.AssertNoError(t, doing.Edit("In progress", 1))
+		testutils.AssertTrue(t, doing.IsFull())
+
+		_, err := CreateProjectCard(&ProjectCard{ProjectID: board.ID, Column: doing.ID, Title: "Too much"})
+		testutils.AssertError(t, err)
+
+		cards, err := todo.Cards()
+		testutils.AssertNoError(t, err)
+		testutils.AssertError(t, cards[0].Move(doing.ID, ""))
+	})
+}
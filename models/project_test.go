@@ -0,0 +1,133 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/The-Skyscape/devtools/pkg/testutils"
+)
+
+func TestProjects(t *testing.T) {
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+
+	user := CreateTestUser(t, db, "projects@example.com")
+	repo := createTestRepository(t, "projects-repo", user.ID)
+	other := createTestRepository(t, "projects-other", user.ID)
+
+	t.Run("CreateProject", func(t *testing.T) {
+		_, err := CreateProject(repo.ID, " ", "", user.ID)
+		testutils.AssertError(t, err)
+		_, err = CreateProject("missing", "Roadmap", "", user.ID)
+		testutils.AssertError(t, err)
+
+		project, err := CreateProject(repo.ID, "Roadmap", "Next release", user.ID)
+		testutils.AssertNoError(t, err)
+		columns, err := project.Columns()
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, len(DefaultProjectColumns), len(columns))
+		testutils.AssertEqual(t, columns[2].ID, project.RuleColumn("pr_merged"))
+		testutils.AssertEqual(t, columns[0].ID, project.RuleColumn("issue_reopened"))
+		testutils.AssertEqual(t, "", project.RuleColumn("issue_opened"))
+
+		board, err := RepoBoard(repo.ID, user.ID)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, project.ID, board.ID)
+	})
+
+	t.Run("Columns", func(t *testing.T) {
+		project, err := CreateProject("", "Workspace", "", user.ID)
+		testutils.AssertNoError(t, err)
+
+		review, err := project.AddColumn("Review", 2)
+		testutils.AssertNoError(t, err)
+		_, err = project.AddColumn("Blocked", -1)
+		testutils.AssertError(t, err)
+
+		testutils.AssertNoError(t, review.Shift(-1))
+		columns, err := project.Columns()
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, "Review", columns[2].Name)
+		testutils.AssertEqual(t, "Done", columns[3].Name)
+
+		_, err = CreateProjectCard(&ProjectCard{ProjectID: project.ID, Column: review.ID, Title: "Check docs"})
+		testutils.AssertNoError(t, err)
+		testutils.AssertError(t, DeleteProjectColumn(review))
+
+		testutils.AssertNoError(t, project.SetRule("pr_closed", columns[0].ID))
+		testutils.AssertNoError(t, DeleteProjectColumn(columns[0]))
+		testutils.AssertEqual(t, "", project.RuleColumn("pr_closed"))
+		testutils.AssertError(t, project.SetRule("pushed", review.ID))
+	})
+
+	t.Run("RunProjectRules", func(t *testing.T) {
+		board, err := RepoBoard(repo.ID, user.ID)
+		testutils.AssertNoError(t, err)
+		columns, err := board.Columns()
+		testutils.AssertNoError(t, err)
+		testutils.AssertNoError(t, board.SetRule("issue_opened", columns[0].ID))
+
+		issue, err := CreateIssue("Crash on save", "", user.ID, repo.ID)
+		testutils.AssertNoError(t, err)
+		RunProjectRules("issue_opened", repo.ID, issue.ID, "")
+		RunProjectRules("issue_opened", repo.ID, issue.ID, "")
+		cards, err := GetProjectCards(board.ID, columns[0].ID)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 1, len(cards))
+		testutils.AssertEqual(t, "Crash on save", cards[0].Title)
+
+		// Rules move cards even into full columns
+		testutils.AssertNoError(t, columns[2].Edit("Done", 1))
+		_, err = CreateProjectCard(&ProjectCard{ProjectID: board.ID, Column: columns[2].ID, Title: "Shipped"})
+		testutils.AssertNoError(t, err)
+		RunProjectRules("issue_closed", repo.ID, issue.ID, "")
+		card, err := ProjectCards.Get(cards[0].ID)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, columns[2].ID, card.Column)
+		testutils.AssertTrue(t, columns[2].IsOverLimit())
+
+		// Other repositories' issues don't get cards
+		elsewhere, err := CreateIssue("Elsewhere", "", user.ID, other.ID)
+		testutils.AssertNoError(t, err)
+		RunProjectRules("issue_opened", other.ID, elsewhere.ID, "")
+		testutils.AssertFalse(t, board.Tracks(elsewhere.ID, ""))
+	})
+
+	t.Run("Lanes", func(t *testing.T) {
+		project, err := CreateProject("", "Everything", "", user.ID)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 1, len(project.Lanes()))
+
+		for _, r := range []*Repository{repo, other} {
+			issue, err := CreateIssue("Tracked", "", user.ID, r.ID)
+			testutils.AssertNoError(t, err)
+			_, err = CreateProjectCard(&ProjectCard{ProjectID: project.ID, IssueID: issue.ID})
+			testutils.AssertNoError(t, err)
+		}
+		_, err = CreateProjectCard(&ProjectCard{ProjectID: project.ID, Title: "Untracked note"})
+		testutils.AssertNoError(t, err)
+
+		testutils.AssertError(t, project.Edit("Everything", "", "priority"))
+		testutils.AssertNoError(t, project.Edit("Everything", "", "repository"))
+		lanes := project.Lanes()
+		testutils.AssertEqual(t, 3, len(lanes))
+		testutils.AssertEqual(t, "projects-other", lanes[0])
+		testutils.AssertEqual(t, "No repository", lanes[2])
+
+		column, err := project.Column("")
+		testutils.AssertNoError(t, err)
+		cards, err := column.LaneCards(project.Swimlanes, "projects-repo")
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 1, len(cards))
+	})
+
+	t.Run("DeleteProject", func(t *testing.T) {
+		project, err := CreateProject(other.ID, "Temporary", "", user.ID)
+		testutils.AssertNoError(t, err)
+		_, err = CreateProjectCard(&ProjectCard{ProjectID: project.ID, Title: "Gone soon"})
+		testutils.AssertNoError(t, err)
+
+		testutils.AssertNoError(t, DeleteProject(project))
+		testutils.AssertEqual(t, 0, ProjectColumns.Count("WHERE ProjectID = ?", project.ID))
+		testutils.AssertEqual(t, 0, ProjectCards.Count("WHERE ProjectID = ?", project.ID))
+	})
+}
@@ -0,0 +1,283 @@
+package models
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/The-Skyscape/devtools/pkg/application"
+	"github.com/pkg/errors"
+)
+
+// Prompt template kinds
+const (
+	PromptKindSystem  = "system"  // Replaces the assistant's built-in system prompt
+	PromptKindRepo    = "repo"    // Context added to the system prompt for one repository
+	PromptKindCommand = "command" // Slash command expanded when a message is sent
+)
+
+// PromptTemplate is admin-edited text given to the assistant. Content may
+// use {{variables}}, which are filled in when the prompt is used.
+type PromptTemplate struct {
+	application.Model
+	Kind        string
+	Name        string // Command name without the slash, empty for other kinds
+	RepoID      string // Repository a context snippet applies to
+	Description string // Shown next to the command in the settings UI
+	Content     string
+	UpdatedBy   string
+}
+
+// Table returns the database table name
+func (*PromptTemplate) Table() string { return "prompt_templates" }
+
+// PromptVariable documents a variable available to prompt templates
+type PromptVariable struct {
+	Name        string
+	Description string
+}
+
+// PromptVariables are filled in when a prompt is rendered
+var PromptVariables = []PromptVariable{
+	{"repo", "Name of the repository the conversation is working in"},
+	{"branch", "Branch the conversation is working on"},
+	{"user", "Name of the person chatting"},
+	{"date", "Today's date"},
+	{"input", "Text typed after a slash command"},
+}
+
+// DefaultPromptCommands are available until an admin replaces them with a
+// command of the same name
+var DefaultPromptCommands = []*PromptTemplate{
+	{
+		Kind:        PromptKindCommand,
+		Name:        "review",
+		Description: "Review the working branch",
+		Content:     "Review the changes on {{branch}} in {{repo}} against the default branch. Point out bugs, missing tests and anything that doesn't match the surrounding code, most important first.\n\n{{input}}",
+	},
+	{
+		Kind:        PromptKindCommand,
+		Name:        "refactor",
+		Description: "Suggest a refactoring",
+		Content:     "Refactor {{input}} in {{repo}} on {{branch}}. Keep behavior the same, follow the conventions already used in the repository, and explain each change.",
+	},
+	{
+		Kind:        PromptKindCommand,
+		Name:        "explain",
+		Description: "Explain code",
+		Content:     "Explain how {{input}} works in {{repo}}. Read the relevant files first and describe the flow step by step.",
+	},
+	{
+		Kind:        PromptKindCommand,
+		Name:        "tests",
+		Description: "Write tests",
+		Content:     "Write tests for {{input}} in {{repo}} on {{branch}}, following the layout and helpers the existing tests use.",
+	},
+}
+
+var (
+	promptVariablePattern = regexp.MustCompile(`\{\{\s*([a-z_]+)\s*\}\}`)
+	promptCommandName     = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,31}$`)
+)
+
+// RenderPrompt fills in the {{variables}} in a template. Unknown variables
+// are left as written so mistakes are visible in the output.
+func RenderPrompt(content string, vars map[string]string) string {
+	rendered := promptVariablePattern.ReplaceAllStringFunc(content, func(match string) string {
+		name := promptVariablePattern.FindStringSubmatch(match)[1]
+		if value, ok := vars[name]; ok {
+			return value
+		}
+		return match
+	})
+	return strings.TrimSpace(rendered)
+}
+
+// GetSystemPrompt returns the admin's system prompt, or nil when the
+// built-in prompt is in use
+func GetSystemPrompt() *PromptTemplate {
+	return findPrompt("WHERE Kind = ?", PromptKindSystem)
+}
+
+// SaveSystemPrompt replaces the built-in system prompt. Saving empty
+// content restores the built-in prompt.
+func SaveSystemPrompt(content, userID string) error {
+	return savePrompt(GetSystemPrompt(), &PromptTemplate{Kind: PromptKindSystem}, content, userID)
+}
+
+// GetRepoPrompt returns the context snippet for a repository, or nil
+func GetRepoPrompt(repoID string) *PromptTemplate {
+	return findPrompt("WHERE Kind = ? AND RepoID = ?", PromptKindRepo, repoID)
+}
+
+// GetRepoPrompts returns every repository context snippet
+func GetRepoPrompts() ([]*PromptTemplate, error) {
+	return PromptTemplates.Search("WHERE Kind = ? ORDER BY CreatedAt ASC", PromptKindRepo)
+}
+
+// SaveRepoPrompt sets the context snippet for a repository. Saving empty
+// content removes it.
+func SaveRepoPrompt(repoID, content, userID string) error {
+	if _, err := Repositories.Get(repoID); err != nil {
+		return errors.New("repository not found")
+	}
+	return savePrompt(GetRepoPrompt(repoID), &PromptTemplate{Kind: PromptKindRepo, RepoID: repoID}, content, userID)
+}
+
+// GetPromptCommands returns the slash commands sorted by name, with saved
+// commands taking the place of built-in ones of the same name
+func GetPromptCommands() ([]*PromptTemplate, error) {
+	saved, err := PromptTemplates.Search("WHERE Kind = ?", PromptKindCommand)
+	if err != nil {
+		return nil, err
+	}
+
+	commands := make(map[string]*PromptTemplate)
+	for _, command := range DefaultPromptCommands {
+		commands[command.Name] = command
+	}
+	for _, command := range saved {
+		commands[command.Name] = command
+	}
+
+	list := make([]*PromptTemplate, 0, len(commands))
+	for _, command := range commands {
+		list = append(list, command)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+// GetPromptCommand returns the slash command with the given name, or nil
+func GetPromptCommand(name string) *PromptTemplate {
+	if command := findPrompt("WHERE Kind = ? AND Name = ?", PromptKindCommand, name); command != nil {
+		return command
+	}
+	for _, command := range DefaultPromptCommands {
+		if command.Name == name {
+			return command
+		}
+	}
+	return nil
+}
+
+// SavePromptCommand creates or replaces a slash command
+func SavePromptCommand(name, description, content, userID string) error {
+	name = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(name), "/"))
+	if !promptCommandName.MatchString(name) {
+		return errors.New("command names use lowercase letters, numbers, - and _, and start with a letter")
+	}
+	if strings.TrimSpace(content) == "" {
+		return errors.New("command prompt is required")
+	}
+
+	existing := findPrompt("WHERE Kind = ? AND Name = ?", PromptKindCommand, name)
+	if existing != nil {
+		existing.Description = strings.TrimSpace(description)
+	}
+	return savePrompt(existing, &PromptTemplate{
+		Kind:        PromptKindCommand,
+		Name:        name,
+		Description: strings.TrimSpace(description),
+	}, content, userID)
+}
+
+// DeletePromptCommand removes a saved command. A built-in command of the
+// same name becomes available again.
+func DeletePromptCommand(name string) error {
+	command := findPrompt("WHERE Kind = ? AND Name = ?", PromptKindCommand, name)
+	if command == nil {
+		return errors.New("command not found")
+	}
+	return PromptTemplates.Delete(command)
+}
+
+// ExpandPromptCommand turns a message starting with a slash command into
+// the command's prompt. The text after the command fills {{input}}, or is
+// appended when the prompt doesn't use it. Messages that aren't a known
+// command are returned unchanged.
+func ExpandPromptCommand(message string, vars map[string]string) (string, bool) {
+	if !strings.HasPrefix(message, "/") {
+		return message, false
+	}
+
+	name, input, _ := strings.Cut(strings.TrimPrefix(message, "/"), " ")
+	command := GetPromptCommand(strings.ToLower(name))
+	if command == nil {
+		return message, false
+	}
+
+	input = strings.TrimSpace(input)
+	withInput := make(map[string]string, len(vars)+1)
+	for key, value := range vars {
+		withInput[key] = value
+	}
+	withInput["input"] = input
+
+	expanded := RenderPrompt(command.Content, withInput)
+	if input != "" && !promptUsesVariable(command.Content, "input") {
+		expanded += "\n\n" + input
+	}
+	return expanded, true
+}
+
+// IsBuiltIn returns true for a default command that hasn't been replaced
+func (p *PromptTemplate) IsBuiltIn() bool {
+	return p.ID == ""
+}
+
+// Replaces returns true for a saved command that overrides a built-in one
+func (p *PromptTemplate) Replaces() bool {
+	if p.IsBuiltIn() {
+		return false
+	}
+	for _, command := range DefaultPromptCommands {
+		if command.Name == p.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// promptUsesVariable reports whether a template refers to a variable
+func promptUsesVariable(content, name string) bool {
+	for _, match := range promptVariablePattern.FindAllStringSubmatch(content, -1) {
+		if match[1] == name {
+			return true
+		}
+	}
+	return false
+}
+
+// findPrompt returns the first template matching the query, or nil
+func findPrompt(query string, args ...any) *PromptTemplate {
+	templates, err := PromptTemplates.Search(query+" LIMIT 1", args...)
+	if err != nil || len(templates) == 0 {
+		return nil
+	}
+	return templates[0]
+}
+
+// savePrompt updates an existing template, inserts a new one, or deletes
+// the existing one when the content is empty
+func savePrompt(existing, fresh *PromptTemplate, content, userID string) error {
+	content = strings.TrimSpace(content)
+	if existing != nil {
+		if content == "" {
+			return PromptTemplates.Delete(existing)
+		}
+		existing.Content = content
+		existing.UpdatedBy = userID
+		return PromptTemplates.Update(existing)
+	}
+	if content == "" {
+		return nil
+	}
+
+	fresh.Content = content
+	fresh.UpdatedBy = userID
+	if _, err := PromptTemplates.Insert(fresh); err != nil {
+		return errors.Wrap(err, "failed to save prompt")
+	}
+	return nil
+}
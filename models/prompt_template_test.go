@@ -0,0 +1,83 @@
+package models
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/The-Skyscape/devtools/pkg/testutils"
+)
+
+func TestPromptTemplates(t *testing.T) {
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+
+	user := CreateTestUser(t, db, "prompter@example.com")
+	vars := map[string]string{"repo": "billing", "branch": "fix-rounding"}
+
+	t.Run("RenderPrompt", func(t *testing.T) {
+		rendered := RenderPrompt("Work on {{ repo }} ({{branch}}) for {{unknown}}", vars)
+		testutils.AssertEqual(t, "Work on billing (fix-rounding) for {{unknown}}", rendered)
+	})
+
+	t.Run("SystemPrompt", func(t *testing.T) {
+		testutils.AssertTrue(t, GetSystemPrompt() == nil)
+
+		testutils.AssertNoError(t, SaveSystemPrompt("You are the billing team's assistant.", user.ID))
+		testutils.AssertEqual(t, "You are the billing team's assistant.", GetSystemPrompt().Content)
+
+		testutils.AssertNoError(t, SaveSystemPrompt("  ", user.ID))
+		testutils.AssertTrue(t, GetSystemPrompt() == nil)
+	})
+
+	t.Run("RepoPrompt", func(t *testing.T) {
+		repo := createTestRepository(t, "billing", user.ID)
+		testutils.AssertError(t, SaveRepoPrompt("missing", "context", user.ID))
+
+		testutils.AssertNoError(t, SaveRepoPrompt(repo.ID, "Amounts are stored in cents.", user.ID))
+		testutils.AssertNoError(t, SaveRepoPrompt(repo.ID, "Amounts are integer cents.", user.ID))
+		prompts, err := GetRepoPrompts()
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 1, len(prompts))
+		testutils.AssertEqual(t, "Amounts are integer cents.", GetRepoPrompt(repo.ID).Content)
+	})
+
+	t.Run("Commands", func(t *testing.T) {
+		commands, err := GetPromptCommands()
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, len(DefaultPromptCommands), len(commands))
+		testutils.AssertTrue(t, GetPromptCommand("review").IsBuiltIn())
+
+		testutils.AssertError(t, SavePromptCommand("Not Valid", "", "prompt", user.ID))
+		testutils.AssertError(t, SavePromptCommand("empty", "", " ", user.ID))
+
+		testutils.AssertNoError(t, SavePromptCommand("/review", "Strict review", "Review {{repo}} strictly.", user.ID))
+		testutils.AssertNoError(t, SavePromptCommand("changelog", "Draft a changelog", "Summarize {{branch}} as a changelog entry.", user.ID))
+
+		commands, err = GetPromptCommands()
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, len(DefaultPromptCommands)+1, len(commands))
+		testutils.AssertEqual(t, "changelog", commands[0].Name)
+
+		review := GetPromptCommand("review")
+		testutils.AssertFalse(t, review.IsBuiltIn())
+		testutils.AssertTrue(t, review.Replaces())
+		testutils.AssertEqual(t, "Strict review", review.Description)
+
+		testutils.AssertNoError(t, DeletePromptCommand("review"))
+		testutils.AssertTrue(t, GetPromptCommand("review").IsBuiltIn())
+	})
+
+	t.Run("ExpandPromptCommand", func(t *testing.T) {
+		expanded, ok := ExpandPromptCommand("/explain the invoice job", vars)
+		testutils.AssertTrue(t, ok)
+		testutils.AssertTrue(t, strings.HasPrefix(expanded, "Explain how the invoice job works in billing."))
+
+		expanded, ok = ExpandPromptCommand("/changelog mention the migration", vars)
+		testutils.AssertTrue(t, ok)
+		testutils.AssertEqual(t, "Summarize fix-rounding as a changelog entry.\n\nmention the migration", expanded)
+
+		expanded, ok = ExpandPromptCommand("/nope keep this", vars)
+		testutils.AssertFalse(t, ok)
+		testutils.AssertEqual(t, "/nope keep this", expanded)
+	})
+}
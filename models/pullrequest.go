@@ -17,6 +17,7 @@ type PullRequest struct {
 	HeadBranch    string // Alias for CompareBranch
 	CompareBranch string
 	Status        string // "draft", "open", "merged", "closed", "approved", "changes_requested"
+	Draft         bool   // Still in progress; can't be merged until marked ready for review
 
 	// Merge fields
 	MergedAt time.Time
@@ -41,6 +42,39 @@ type PullRequest struct {
 
 func (*PullRequest) Table() string { return "pull_requests" }
 
+// Repository returns the repository this pull request belongs to
+func (pr *PullRequest) Repository() (*Repository, error) {
+	return Repos.Get(pr.RepoID)
+}
+
+// URL returns the path of the pull request page
+func (pr *PullRequest) URL() string {
+	return "/repos/" + pr.RepoID + "/prs/" + pr.ID
+}
+
+// TimeToFirstReview returns how long the pull request waited for its
+// first response, and false if nobody has responded yet
+func (pr *PullRequest) TimeToFirstReview() (time.Duration, bool) {
+	first, err := pr.firstResponseAt()
+	if err != nil || first.IsZero() {
+		return 0, false
+	}
+	return first.Sub(pr.CreatedAt), true
+}
+
+// TimeToMerge returns how long the pull request was open before it was
+// merged, and false if it hasn't been merged
+func (pr *PullRequest) TimeToMerge() (time.Duration, bool) {
+	merged := pr.MergedAt
+	if merged.IsZero() {
+		merged = pr.GitHubMergedAt
+	}
+	if pr.Status != "merged" || merged.IsZero() {
+		return 0, false
+	}
+	return merged.Sub(pr.CreatedAt), true
+}
+
 func init() {
 	// Create indexes for pull requests table
 	go func() {
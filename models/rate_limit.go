@@ -0,0 +1,93 @@
+package models
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/The-Skyscape/devtools/pkg/application"
+	"github.com/pkg/errors"
+)
+
+// rateLimitSweepInterval is how often expired counters are deleted
+const rateLimitSweepInterval = 10 * time.Minute
+
+// RateLimitCounter counts a client's requests to a rate limit in one
+// fixed window
+type RateLimitCounter struct {
+	application.Model
+	Key         string // The limit and client, such as api:user:<id>
+	WindowStart time.Time
+	Count       int
+	ExpiresAt   time.Time // Once it can't affect the limit any more
+}
+
+// Table returns the database table name
+func (*RateLimitCounter) Table() string { return "rate_limit_counters" }
+
+// DatabaseRateLimitStore keeps rate limit counters in the database, so
+// limits survive restarts
+type DatabaseRateLimitStore struct {
+	mu    sync.Mutex
+	swept time.Time
+}
+
+// rateLimitCounterID is the ID of a key's counter for a window, so
+// increments find it without searching
+func rateLimitCounterID(key string, start time.Time) string {
+	return key + "@" + strconv.FormatInt(start.UnixMilli(), 10)
+}
+
+// Increment adds a request to key's counter, in one statement so requests
+// counted at once are all counted
+func (s *DatabaseRateLimitStore) Increment(key string, start time.Time, window time.Duration) (int, int, error) {
+	id := rateLimitCounterID(key, start)
+	counter, err := s.increment(id)
+	if err != nil {
+		_, err = RateLimitCounters.Insert(&RateLimitCounter{
+			Model:       DB.NewModel(id),
+			Key:         key,
+			WindowStart: start,
+			Count:       1,
+			ExpiresAt:   start.Add(2 * window),
+		})
+		if err == nil {
+			counter = 1
+		} else if counter, err = s.increment(id); err != nil {
+			// Another request created it first, or the database failed
+			return 0, 0, errors.Wrap(err, "failed to count request")
+		}
+	}
+
+	previous := 0
+	if last, err := RateLimitCounters.Get(rateLimitCounterID(key, start.Add(-window))); err == nil {
+		previous = last.Count
+	}
+	s.sweep()
+	return previous, counter, nil
+}
+
+// increment adds one to an existing counter, failing when there's none
+func (s *DatabaseRateLimitStore) increment(id string) (int, error) {
+	if err := DB.Query("UPDATE rate_limit_counters SET Count = Count + 1 WHERE ID = ?", id).Exec(); err != nil {
+		return 0, err
+	}
+	counter, err := RateLimitCounters.Get(id)
+	if err != nil {
+		return 0, err
+	}
+	return counter.Count, nil
+}
+
+// sweep deletes counters too old to matter every so often
+func (s *DatabaseRateLimitStore) sweep() {
+	s.mu.Lock()
+	due := time.Since(s.swept) > rateLimitSweepInterval
+	if due {
+		s.swept = time.Now()
+	}
+	s.mu.Unlock()
+	if due {
+		DB.Query("DELETE FROM rate_limit_counters WHERE ExpiresAt < ?", time.Now()).Exec()
+	}
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/The-Skyscape/devtools/pkg/testutils"
+)
+
+func TestDatabaseRateLimitStore(t *testing.T) {
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+
+	store := &DatabaseRateLimitStore{}
+	start := time.Now().Truncate(time.Minute)
+
+	t.Run("CountsRequests", func(t *testing.T) {
+		previous, current, err := store.Increment("api:user:1", start, time.Minute)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 0, previous)
+		testutils.AssertEqual(t, 1, current)
+
+		_, current, err = store.Increment("api:user:1", start, time.Minute)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 2, current)
+	})
+
+	t.Run("KeysAreSeparate", func(t *testing.T) {
+		_, current, err := store.Increment("api:user:2", start, time.Minute)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 1, current)
+	})
+
+	t.Run("ReadsPreviousWindow", func(t *testing.T) {
+		previous, current, err := store.Increment("api:user:1", start.Add(time.Minute), time.Minute)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 2, previous)
+		testutils.AssertEqual(t, 1, current)
+
+		counter, err := RateLimitCounters.Get(rateLimitCounterID("api:user:1", start.Add(time.Minute)))
+		testutils.AssertNoError(t, err)
+		testutils.AssertTrue(t, counter.ExpiresAt.Equal(start.Add(3*time.Minute)))
+	})
+}
@@ -0,0 +1,173 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/The-Skyscape/devtools/pkg/application"
+	"github.com/pkg/errors"
+)
+
+// Entity types that track read state, matching Comment.EntityType
+const (
+	ReadIssue       = "issue"
+	ReadPullRequest = "pr"
+)
+
+// ReadState records when a user last read an issue or pull request and its
+// comments. Its ID is derived from the user and entity so each pair has one
+// row.
+type ReadState struct {
+	application.Model
+	UserID     string
+	EntityType string
+	EntityID   string
+	RepoID     string
+	ReadAt     time.Time
+}
+
+// Table returns the database table name
+func (*ReadState) Table() string { return "read_states" }
+
+func readStateID(userID, entityType, entityID string) string {
+	return userID + ":" + entityType + ":" + entityID
+}
+
+// MarkRead records that a user has read an entity up to now
+func MarkRead(userID, entityType, entityID, repoID string) error {
+	if userID == "" || entityID == "" {
+		return nil
+	}
+
+	id := readStateID(userID, entityType, entityID)
+	state, err := ReadStates.Get(id)
+	if err != nil {
+		_, err = ReadStates.Insert(&ReadState{
+			Model:      DB.NewModel(id),
+			UserID:     userID,
+			EntityType: entityType,
+			EntityID:   entityID,
+			RepoID:     repoID,
+			ReadAt:     time.Now(),
+		})
+		return errors.Wrap(err, "failed to mark as read")
+	}
+
+	state.ReadAt = time.Now()
+	return errors.Wrap(ReadStates.Update(state), "failed to mark as read")
+}
+
+// MarkUnread forgets that a user has read an entity
+func MarkUnread(userID, entityType, entityID string) error {
+	state, err := ReadStates.Get(readStateID(userID, entityType, entityID))
+	if err != nil {
+		return nil
+	}
+	return errors.Wrap(ReadStates.Delete(state), "failed to mark as unread")
+}
+
+// LastRead returns when a user last read an entity, zero if never
+func LastRead(userID, entityType, entityID string) time.Time {
+	state, err := ReadStates.Get(readStateID(userID, entityType, entityID))
+	if err != nil {
+		return time.Time{}
+	}
+	return state.ReadAt
+}
+
+// UnreadCondition returns a SQL condition on table matching the rows the
+// user hasn't read: never opened, or commented on by someone else since.
+func UnreadCondition(table, entityType, userID string) (string, []any) {
+	condition := `NOT EXISTS (SELECT 1 FROM read_states r
+		WHERE r.UserID = ? AND r.EntityType = ? AND r.EntityID = ` + table + `.ID
+		AND NOT EXISTS (SELECT 1 FROM comments c
+			WHERE c.EntityType = r.EntityType AND c.EntityID = r.EntityID
+			AND c.AuthorID != r.UserID AND c.CreatedAt > r.ReadAt))`
+	return condition, []any{userID, entityType}
+}
+
+// UnreadIssues returns which of the issues the user hasn't read
+func UnreadIssues(userID string, issues []*Issue) map[string]bool {
+	ids := make([]string, len(issues))
+	for i, issue := range issues {
+		ids[i] = issue.ID
+	}
+	query, args := unreadAmong("issues", ReadIssue, userID, ids)
+	if query == "" {
+		return map[string]bool{}
+	}
+
+	unread := map[string]bool{}
+	if found, err := Issues.Search(query, args...); err == nil {
+		for _, issue := range found {
+			unread[issue.ID] = true
+		}
+	}
+	return unread
+}
+
+// UnreadPullRequests returns which of the pull requests the user hasn't read
+func UnreadPullRequests(userID string, prs []*PullRequest) map[string]bool {
+	ids := make([]string, len(prs))
+	for i, pr := range prs {
+		ids[i] = pr.ID
+	}
+	query, args := unreadAmong("pull_requests", ReadPullRequest, userID, ids)
+	if query == "" {
+		return map[string]bool{}
+	}
+
+	unread := map[string]bool{}
+	if found, err := PullRequests.Search(query, args...); err == nil {
+		for _, pr := range found {
+			unread[pr.ID] = true
+		}
+	}
+	return unread
+}
+
+// unreadAmong builds a query for the unread rows among ids, empty when
+// there is nothing to look up
+func unreadAmong(table, entityType, userID string, ids []string) (string, []any) {
+	if userID == "" || len(ids) == 0 {
+		return "", nil
+	}
+
+	condition, args := UnreadCondition(table, entityType, userID)
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	return "WHERE " + condition + " AND ID IN (" + placeholders + ")", args
+}
+
+// GetUnreadIssuesPaginated returns a page of the repository's issues the
+// user hasn't read, ordered like GetRepoIssuesPaginated
+func GetUnreadIssuesPaginated(repoID, userID string, includeClosed bool, limit, offset int) ([]*Issue, int, error) {
+	unread, args := UnreadCondition("issues", ReadIssue, userID)
+	condition := "WHERE " + unread + " AND RepoID = ?"
+	args = append(args, repoID)
+
+	if !includeClosed {
+		condition += " AND Status = ?"
+		args = append(args, IssueStatusOpen)
+	}
+
+	condition += " ORDER BY Priority, CreatedAt DESC"
+	return Issues.SearchPaginated(condition, limit, offset, args...)
+}
+
+// GetUnreadPRsPaginated returns a page of the repository's pull requests
+// the user hasn't read, ordered like GetRepoPRsPaginated
+func GetUnreadPRsPaginated(repoID, userID string, includeClosed bool, limit, offset int) ([]*PullRequest, int, error) {
+	unread, args := UnreadCondition("pull_requests", ReadPullRequest, userID)
+	condition := "WHERE " + unread + " AND RepoID = ?"
+	args = append(args, repoID)
+
+	if !includeClosed {
+		condition += " AND Status = 'open'"
+	}
+
+	condition += " ORDER BY CreatedAt DESC"
+	return PullRequests.SearchPaginated(condition, limit, offset, args...)
+}
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/The-Skyscape/devtools/pkg/testutils"
+)
+
+func TestReadState(t *testing.T) {
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+
+	alice := CreateTestUser(t, db, "alice@example.com")
+	bob := CreateTestUser(t, db, "bob@example.com")
+	repo := createTestRepository(t, "read-state-repo", alice.ID)
+
+	first, err := CreateIssue("First", "", alice.ID, repo.ID)
+	testutils.AssertNoError(t, err)
+	second, err := CreateIssue("Second", "", alice.ID, repo.ID)
+	testutils.AssertNoError(t, err)
+	issues := []*Issue{first, second}
+
+	t.Run("NeverOpened", func(t *testing.T) {
+		unread := UnreadIssues(bob.ID, issues)
+		testutils.AssertEqual(t, 2, len(unread))
+		testutils.AssertTrue(t, LastRead(bob.ID, ReadIssue, first.ID).IsZero())
+	})
+
+	t.Run("MarkRead", func(t *testing.T) {
+		testutils.AssertNoError(t, MarkRead(bob.ID, ReadIssue, first.ID, repo.ID))
+		testutils.AssertNoError(t, MarkRead(bob.ID, ReadIssue, first.ID, repo.ID))
+		testutils.AssertEqual(t, 1, ReadStates.Count(""))
+
+		unread := UnreadIssues(bob.ID, issues)
+		testutils.AssertFalse(t, unread[first.ID])
+		testutils.AssertTrue(t, unread[second.ID])
+		testutils.AssertFalse(t, LastRead(bob.ID, ReadIssue, first.ID).IsZero())
+
+		page, total, err := GetUnreadIssuesPaginated(repo.ID, bob.ID, false, 10, 0)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 1, total)
+		testutils.AssertEqual(t, second.ID, page[0].ID)
+	})
+
+	t.Run("NewComments", func(t *testing.T) {
+		state, err := ReadStates.Get(readStateID(bob.ID, ReadIssue, first.ID))
+		testutils.AssertNoError(t, err)
+		state.ReadAt = time.Now().Add(-time.Minute)
+		testutils.AssertNoError(t, ReadStates.Update(state))
+
+		// Your own comments don't make a thread unread
+		_, err = CreateIssueComment(first.ID, repo.ID, bob.ID, "Looking into it")
+		testutils.AssertNoError(t, err)
+		testutils.AssertFalse(t, UnreadIssues(bob.ID, issues)[first.ID])
+
+		_, err = CreateIssueComment(first.ID, repo.ID, alice.ID, "Any news?")
+		testutils.AssertNoError(t, err)
+		testutils.AssertTrue(t, UnreadIssues(bob.ID, issues)[first.ID])
+	})
+
+	t.Run("MarkUnread", func(t *testing.T) {
+		testutils.AssertNoError(t, MarkRead(bob.ID, ReadIssue, second.ID, repo.ID))
+		testutils.AssertNoError(t, MarkUnread(bob.ID, ReadIssue, second.ID))
+		testutils.AssertTrue(t, UnreadIssues(bob.ID, issues)[second.ID])
+		testutils.AssertNoError(t, MarkUnread(alice.ID, ReadIssue, second.ID))
+	})
+}
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// registryImageName is an image name as the registry accepts it, one or
+	// more lowercase components separated by /
+	registryImageName = regexp.MustCompile(`^[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*(?:/[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*)*$`)
+
+	// registryTag is an image tag as the registry accepts it
+	registryTag = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9._-]{0,127}$`)
+
+	// registryTagInvalid matches what can't be part of a tag
+	registryTagInvalid = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+)
+
+// RegistryImage is an image in the container registry. Each repository's
+// images are kept under its ID, so app in repository shop is shop/app.
+type RegistryImage struct {
+	RepoID string
+	Name   string   // Name within the repository's namespace
+	Tags   []string // Sorted, empty once every tag is deleted
+}
+
+// Path is the image's full name in the registry
+func (i *RegistryImage) Path() string {
+	return i.RepoID + "/" + i.Name
+}
+
+// ValidImageName reports whether a name can be used for an image within a
+// repository's namespace
+func ValidImageName(name string) bool {
+	return len(name) <= 200 && registryImageName.MatchString(name)
+}
+
+// ValidImageTag reports whether a tag can be given to an image
+func ValidImageTag(tag string) bool {
+	return registryTag.MatchString(tag)
+}
+
+// ImageTag turns a branch or other name into a valid tag, replacing what a
+// tag can't hold with -, so feature/login becomes feature-login
+func ImageTag(name string) string {
+	tag := strings.Trim(registryTagInvalid.ReplaceAllString(name, "-"), "-.")
+	if len(tag) > 128 {
+		tag = tag[:128]
+	}
+	if !ValidImageTag(tag) {
+		return ""
+	}
+	return tag
+}
+
+// SplitRegistryName splits an image's full name in the registry into the
+// repository whose namespace it is in and its name there
+func SplitRegistryName(name string) (repoID, image string, ok bool) {
+	repoID, image, ok = strings.Cut(name, "/")
+	if !ok || ValidateRepositoryID(repoID) != nil || !ValidImageName(image) {
+		return "", "", false
+	}
+	return repoID, image, true
+}
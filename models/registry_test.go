@@ -0,0 +1,42 @@
+package models
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/The-Skyscape/devtools/pkg/testutils"
+)
+
+func TestRegistryNames(t *testing.T) {
+	t.Run("ImageNames", func(t *testing.T) {
+		for _, name := range []string{"app", "tools/cli", "my-app", "my_app.v2", "a__b"} {
+			testutils.AssertTrue(t, ValidImageName(name))
+		}
+		for _, name := range []string{"", "App", "-app", "app-", "tools//cli", "../app", "app:latest", strings.Repeat("a", 201)} {
+			testutils.AssertFalse(t, ValidImageName(name))
+		}
+	})
+
+	t.Run("Tags", func(t *testing.T) {
+		testutils.AssertTrue(t, ValidImageTag("latest"))
+		testutils.AssertTrue(t, ValidImageTag("v1.2.3"))
+		testutils.AssertFalse(t, ValidImageTag(".hidden"))
+		testutils.AssertFalse(t, ValidImageTag("a/b"))
+		testutils.AssertEqual(t, "feature-login", ImageTag("feature/login"))
+		testutils.AssertEqual(t, "release-1.2", ImageTag("release/1.2"))
+		testutils.AssertEqual(t, "", ImageTag("///"))
+	})
+
+	t.Run("SplitRegistryName", func(t *testing.T) {
+		repoID, image, ok := SplitRegistryName("shop/tools/cli")
+		testutils.AssertTrue(t, ok)
+		testutils.AssertEqual(t, "shop", repoID)
+		testutils.AssertEqual(t, "tools/cli", image)
+		testutils.AssertEqual(t, "shop/tools/cli", (&RegistryImage{RepoID: repoID, Name: image}).Path())
+
+		for _, name := range []string{"shop", "Shop/app", "shop/App", "-shop/app", "/app"} {
+			_, _, ok := SplitRegistryName(name)
+			testutils.AssertFalse(t, ok)
+		}
+	})
+}
@@ -0,0 +1,268 @@
+package models
+
+import (
+	"net/mail"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/The-Skyscape/devtools/pkg/application"
+	"github.com/pkg/errors"
+)
+
+// Report kinds
+const (
+	ReportReleaseNotes  = "release_notes"
+	ReportWeeklySummary = "weekly_summary"
+	ReportRiskItems     = "risk_items"
+
+	// ReportDailyHealth is written by the AI queue for every repository
+	// each day rather than by a schedule
+	ReportDailyHealth = "daily_health"
+)
+
+// Report schedule frequencies
+const (
+	ReportDaily   = "daily"
+	ReportWeekly  = "weekly"
+	ReportMonthly = "monthly"
+)
+
+// Report file formats
+const (
+	ReportMarkdown = "markdown"
+	ReportPDF      = "pdf"
+)
+
+// Report deliveries
+const (
+	ReportByEmail = "email"
+	ReportToRepo  = "repo"
+)
+
+// DefaultReportPath is the directory reports are committed to when the
+// schedule doesn't name one
+const DefaultReportPath = "reports"
+
+// reportKindNames are the display names of the report kinds
+var reportKindNames = map[string]string{
+	ReportReleaseNotes:  "Release Notes Draft",
+	ReportWeeklySummary: "Engineering Summary",
+	ReportRiskItems:     "Open Risk Items",
+	ReportDailyHealth:   "Daily Health Report",
+}
+
+// ReportSchedule generates a report about a repository on a schedule and
+// delivers it by email or as a file committed to the repository
+type ReportSchedule struct {
+	application.Model
+	RepoID     string
+	Kind       string // ReportReleaseNotes, ReportWeeklySummary or ReportRiskItems
+	Frequency  string // ReportDaily, ReportWeekly or ReportMonthly
+	Format     string // ReportMarkdown or ReportPDF
+	Delivery   string // ReportByEmail or ReportToRepo
+	Recipients string // Comma separated addresses for email delivery
+	Path       string // Directory in the repository for repo delivery
+	Enabled    bool
+	CreatedBy  string
+	NextRunAt  time.Time
+	LastRunAt  time.Time
+	LastError  string
+}
+
+// Table returns the database table name
+func (*ReportSchedule) Table() string { return "report_schedules" }
+
+// RepoReport is one generated report, kept so it can be downloaded again
+type RepoReport struct {
+	application.Model
+	ScheduleID  string
+	RepoID      string
+	Kind        string
+	Title       string
+	Content     string // Markdown
+	Summary     string // Written by the AI assistant, empty when it wasn't running
+	PeriodStart time.Time
+	PeriodEnd   time.Time
+	DeliveredTo string // Recipients or the committed file
+	Error       string // Why delivery failed, empty when it succeeded
+}
+
+// Table returns the database table name
+func (*RepoReport) Table() string { return "repo_reports" }
+
+// ReportKindName returns the display name of a report kind
+func ReportKindName(kind string) string {
+	if name, ok := reportKindNames[kind]; ok {
+		return name
+	}
+	return kind
+}
+
+// KindName returns the display name of the schedule's report kind
+func (s *ReportSchedule) KindName() string {
+	return ReportKindName(s.Kind)
+}
+
+// Validate checks the schedule's options, filling in the default path for
+// repo delivery
+func (s *ReportSchedule) Validate() error {
+	if _, ok := reportKindNames[s.Kind]; !ok {
+		return errors.Errorf("unknown report kind %q", s.Kind)
+	}
+	switch s.Frequency {
+	case ReportDaily, ReportWeekly, ReportMonthly:
+	default:
+		return errors.Errorf("unknown frequency %q", s.Frequency)
+	}
+	switch s.Format {
+	case ReportMarkdown, ReportPDF:
+	default:
+		return errors.Errorf("unknown format %q", s.Format)
+	}
+
+	switch s.Delivery {
+	case ReportByEmail:
+		addresses, err := mail.ParseAddressList(s.Recipients)
+		if err != nil || len(addresses) == 0 {
+			return errors.New("email delivery needs at least one valid recipient")
+		}
+		recipients := make([]string, len(addresses))
+		for i, address := range addresses {
+			recipients[i] = address.Address
+		}
+		s.Recipients = strings.Join(recipients, ", ")
+	case ReportToRepo:
+		dir := strings.Trim(path.Clean("/"+strings.TrimSpace(s.Path)), "/")
+		if dir == "" {
+			dir = DefaultReportPath
+		}
+		s.Path = dir
+	default:
+		return errors.Errorf("unknown delivery %q", s.Delivery)
+	}
+	return nil
+}
+
+// RecipientList returns the addresses reports are emailed to
+func (s *ReportSchedule) RecipientList() []string {
+	var recipients []string
+	for _, address := range strings.Split(s.Recipients, ",") {
+		if address = strings.TrimSpace(address); address != "" {
+			recipients = append(recipients, address)
+		}
+	}
+	return recipients
+}
+
+// Period returns the span a report run at end covers, which is one
+// interval of the schedule's frequency
+func (s *ReportSchedule) Period(end time.Time) (time.Time, time.Time) {
+	switch s.Frequency {
+	case ReportDaily:
+		return end.AddDate(0, 0, -1), end
+	case ReportMonthly:
+		return end.AddDate(0, -1, 0), end
+	default:
+		return end.AddDate(0, 0, -7), end
+	}
+}
+
+// NextRun returns when the schedule runs next after from
+func (s *ReportSchedule) NextRun(from time.Time) time.Time {
+	switch s.Frequency {
+	case ReportDaily:
+		return from.AddDate(0, 0, 1)
+	case ReportMonthly:
+		return from.AddDate(0, 1, 0)
+	default:
+		return from.AddDate(0, 0, 7)
+	}
+}
+
+// ReportFileName returns the file name of a report of kind for the period
+// ending at t
+func ReportFileName(kind, format string, t time.Time) string {
+	name := strings.ReplaceAll(kind, "_", "-") + "-" + t.Format("2006-01-02")
+	if format == ReportPDF {
+		return name + ".pdf"
+	}
+	return name + ".md"
+}
+
+// FileName returns the name the schedule's report for the period ending at
+// t is saved under
+func (s *ReportSchedule) FileName(t time.Time) string {
+	return ReportFileName(s.Kind, s.Format, t)
+}
+
+// CreateReportSchedule validates and saves a schedule, which first runs one
+// interval from now
+func CreateReportSchedule(schedule *ReportSchedule) (*ReportSchedule, error) {
+	if err := schedule.Validate(); err != nil {
+		return nil, err
+	}
+	schedule.Enabled = true
+	schedule.NextRunAt = schedule.NextRun(time.Now())
+
+	schedule, err := ReportSchedules.Insert(schedule)
+	return schedule, errors.Wrap(err, "failed to create report schedule")
+}
+
+// ReportSchedulesForRepo returns the repository's report schedules
+func ReportSchedulesForRepo(repoID string) ([]*ReportSchedule, error) {
+	return ReportSchedules.Search("WHERE RepoID = ? ORDER BY CreatedAt ASC", repoID)
+}
+
+// DueReportSchedules returns the enabled schedules whose next run has come
+func DueReportSchedules(now time.Time) ([]*ReportSchedule, error) {
+	return ReportSchedules.Search("WHERE Enabled = ? AND NextRunAt <= ? ORDER BY NextRunAt ASC", true, now)
+}
+
+// RecordRun saves a report the schedule generated and moves the schedule
+// on to its next run. deliveryErr is the delivery failure, if any.
+func (s *ReportSchedule) RecordRun(report *RepoReport, deliveryErr error) (*RepoReport, error) {
+	report.ScheduleID = s.ID
+	report.RepoID = s.RepoID
+	report.Kind = s.Kind
+
+	s.LastRunAt = report.PeriodEnd
+	s.LastError = ""
+	// Runs asked for by hand don't move the schedule
+	if !report.PeriodEnd.Before(s.NextRunAt) {
+		s.NextRunAt = s.NextRun(report.PeriodEnd)
+	}
+	if deliveryErr != nil {
+		s.LastError = deliveryErr.Error()
+		report.Error = s.LastError
+	}
+
+	report, err := RepoReports.Insert(report)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to save report")
+	}
+	return report, errors.Wrap(ReportSchedules.Update(s), "failed to update report schedule")
+}
+
+// RecentReports returns the schedule's latest reports, newest first
+func (s *ReportSchedule) RecentReports(limit int) ([]*RepoReport, error) {
+	return RepoReports.Search("WHERE ScheduleID = ? ORDER BY CreatedAt DESC LIMIT ?", s.ID, limit)
+}
+
+// LatestRepoReport returns the repository's newest report of kind, or nil
+func LatestRepoReport(repoID, kind string) *RepoReport {
+	reports, err := RepoReports.Search("WHERE RepoID = ? AND Kind = ? ORDER BY CreatedAt DESC LIMIT 1", repoID, kind)
+	if err != nil || len(reports) == 0 {
+		return nil
+	}
+	return reports[0]
+}
+
+// DeleteReportSchedule removes a schedule and the reports it generated
+func DeleteReportSchedule(schedule *ReportSchedule) error {
+	if err := ReportSchedules.Delete(schedule); err != nil {
+		return errors.Wrap(err, "failed to delete report schedule")
+	}
+	err := DB.Query("DELETE FROM repo_reports WHERE ScheduleID = ?", schedule.ID).Exec()
+	return errors.Wrap(err, "failed to delete reports")
+}
@@ -0,0 +1,141 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/The-Skyscape/devtools/pkg/testutils"
+)
+
+func TestReportSchedule(t *testing.T) {
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+
+	user := CreateTestUser(t, db, "reports@example.com")
+	repo := createTestRepository(t, "reports-repo", user.ID)
+
+	t.Run("Validate", func(t *testing.T) {
+		schedule := &ReportSchedule{Kind: ReportRiskItems, Frequency: ReportWeekly, Format: ReportPDF, Delivery: ReportToRepo, Path: " ../docs//weekly/ "}
+		testutils.AssertNoError(t, schedule.Validate())
+		testutils.AssertEqual(t, "docs/weekly", schedule.Path)
+
+		schedule = &ReportSchedule{Kind: ReportRiskItems, Frequency: ReportWeekly, Format: ReportMarkdown, Delivery: ReportToRepo}
+		testutils.AssertNoError(t, schedule.Validate())
+		testutils.AssertEqual(t, DefaultReportPath, schedule.Path)
+
+		schedule = &ReportSchedule{Kind: ReportWeeklySummary, Frequency: ReportDaily, Format: ReportMarkdown, Delivery: ReportByEmail, Recipients: "Ann <ann@example.com>, bob@example.com"}
+		testutils.AssertNoError(t, schedule.Validate())
+		testutils.AssertEqual(t, "ann@example.com, bob@example.com", schedule.Recipients)
+		testutils.AssertEqual(t, 2, len(schedule.RecipientList()))
+
+		schedule.Recipients = "not an address"
+		testutils.AssertError(t, schedule.Validate())
+		schedule.Recipients = "ann@example.com"
+		schedule.Kind = "changelog"
+		testutils.AssertError(t, schedule.Validate())
+	})
+
+	t.Run("RunsWhenDue", func(t *testing.T) {
+		schedule, err := CreateReportSchedule(&ReportSchedule{
+			RepoID:    repo.ID,
+			Kind:      ReportReleaseNotes,
+			Frequency: ReportWeekly,
+			Format:    ReportMarkdown,
+			Delivery:  ReportToRepo,
+			CreatedBy: user.ID,
+		})
+		testutils.AssertNoError(t, err)
+		testutils.AssertTrue(t, schedule.Enabled)
+
+		due, err := DueReportSchedules(time.Now())
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 0, len(due))
+
+		due, err = DueReportSchedules(time.Now().AddDate(0, 0, 8))
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 1, len(due))
+	})
+
+	t.Run("RecordRun", func(t *testing.T) {
+		schedules, err := ReportSchedulesForRepo(repo.ID)
+		testutils.AssertNoError(t, err)
+		schedule := schedules[0]
+		next := schedule.NextRunAt
+
+		// A run by hand leaves the schedule alone
+		start, end := schedule.Period(time.Now())
+		report, err := schedule.RecordRun(&RepoReport{Title: "Manual", PeriodStart: start, PeriodEnd: end}, nil)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, schedule.ID, report.ScheduleID)
+		testutils.AssertTrue(t, schedule.NextRunAt.Equal(next))
+
+		// A due run moves it on and remembers a failed delivery
+		start, end = schedule.Period(next)
+		deliveryErr := errors.New("connection refused")
+		_, err = schedule.RecordRun(&RepoReport{Title: "Due", PeriodStart: start, PeriodEnd: end}, deliveryErr)
+		testutils.AssertNoError(t, err)
+		testutils.AssertTrue(t, schedule.NextRunAt.Equal(next.AddDate(0, 0, 7)))
+		testutils.AssertEqual(t, "connection refused", schedule.LastError)
+
+		reports, err := schedule.RecentReports(10)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 2, len(reports))
+
+		testutils.AssertNoError(t, DeleteReportSchedule(schedule))
+		testutils.AssertEqual(t, 0, RepoReports.Count("WHERE ScheduleID = ?", schedule.ID))
+	})
+	t.Run("LatestRepoReport", func(t *testing.T) {
+		testutils.AssertTrue(t, LatestRepoReport(repo.ID, ReportDailyHealth) == nil)
+
+		_, err := RepoReports.Insert(&RepoReport{RepoID: repo.ID, Kind: ReportDailyHealth, Title: "Daily"})
+		testutils.AssertNoError(t, err)
+		latest := LatestRepoReport(repo.ID, ReportDailyHealth)
+		testutils.AssertNotNil(t, latest)
+		testutils.AssertEqual(t, "Daily", latest.Title)
+	})
+}
+
+func TestRenderReport(t *testing.T) {
+	end := time.Date(2026, time.March, 9, 9, 0, 0, 0, time.UTC)
+	stats := &ReportStats{
+		Repo:         &Repository{Name: "api"},
+		Start:        end.AddDate(0, 0, -7),
+		End:          end,
+		Commits:      []*Commit{{Message: "Add rate limiting\n\nDetails", ShortHash: "abc1234", Author: "Ann"}},
+		Contributors: []string{"Ann"},
+		PRsMerged:    []*PullRequest{{Title: "Rate limiting", BaseBranch: "main", CompareBranch: "limits"}},
+		CriticalIssues: []*Issue{
+			{Title: "Login fails", Status: IssueStatusOpen, Priority: PriorityCritical},
+		},
+		RunsTotal:  4,
+		RunsFailed: 1,
+		Health: &RepoHealth{Score: 72, Grade: "C", Metrics: []*HealthMetric{
+			{Name: "CI Stability", Value: "75% of 4 runs passed", Recommendation: "Fix broken actions."},
+		}},
+	}
+
+	notes := RenderReport(ReportReleaseNotes, stats, "Rate limiting shipped.")
+	testutils.AssertTrue(t, strings.HasPrefix(notes, "# Release Notes Draft: api, Mar 2 to Mar 9, 2026\n"))
+	testutils.AssertContains(t, notes, "## Summary\n\nRate limiting shipped.")
+	testutils.AssertContains(t, notes, "- Rate limiting (limits → main")
+	testutils.AssertContains(t, notes, "- Add rate limiting `abc1234` (Ann)\n")
+	testutils.AssertContains(t, notes, "No issues were closed.")
+
+	summary := RenderReport(ReportWeeklySummary, stats, "")
+	testutils.AssertFalse(t, strings.Contains(summary, "## Summary"))
+	testutils.AssertContains(t, summary, "| Action runs | 4, 1 failed |")
+	testutils.AssertContains(t, summary, "**CI Stability**")
+
+	risks := RenderReport(ReportRiskItems, stats, "")
+	testutils.AssertContains(t, risks, "- Login fails (open, critical priority)")
+	testutils.AssertContains(t, risks, "1 of 4 action runs failed.")
+	testutils.AssertContains(t, risks, "No pull requests are waiting that long.")
+
+	daily := RenderReport(ReportDailyHealth, stats, "Quiet day.")
+	testutils.AssertTrue(t, strings.HasPrefix(daily, "# Daily Health Report: api"))
+	testutils.AssertContains(t, daily, "| Pull requests | 0 opened, 1 merged, 0 open over 14 days |")
+	testutils.AssertContains(t, daily, "- Login fails (open, critical priority)")
+	testutils.AssertContains(t, daily, "No issues were opened.")
+}
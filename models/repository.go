@@ -12,6 +12,9 @@ import (
 	"strings"
 	"time"
 
+	"workspace/internal/gitstore"
+	"workspace/internal/storage"
+
 	"github.com/The-Skyscape/devtools/pkg/application"
 	"github.com/The-Skyscape/devtools/pkg/database"
 	"github.com/pkg/errors"
@@ -45,11 +48,31 @@ type Repository struct {
 	LastPushAt       time.Time // Last successful push
 	LastPullAt       time.Time // Last successful pull
 	SyncStatus       string    // "synced", "ahead", "behind", "diverged", "error"
+	CommentSync      string    // CommentSyncPull, CommentSyncBoth, or empty when comments aren't synced
+	SyncInterval     int       // Minutes between automatic syncs, DefaultSyncInterval when 0
+	NextSyncAt       time.Time // When the next automatic sync is due
+	SyncFailures     int       // Consecutive failed syncs, backing off the next one
+
+	// Bitbucket Integration
+	BitbucketURL        string    // Bitbucket Cloud repository URL
+	BitbucketAhead      int       // Commits ahead of the Bitbucket remote
+	BitbucketBehind     int       // Commits behind the Bitbucket remote
+	BitbucketSyncStatus string    // "synced", "ahead", "behind", "diverged", "error"
+	BitbucketSyncedAt   time.Time // Last push or pull with Bitbucket
+
+	// Mirror Export
+	MirrorExcluded bool // Left out when repositories are mirrored to another host
 }
 
 // Table returns the database table name
 func (*Repository) Table() string { return "repositories" }
 
+// Comment sync modes for repositories linked to GitHub
+const (
+	CommentSyncPull = "pull" // GitHub comments are imported
+	CommentSyncBoth = "both" // Comments made here are also posted to GitHub
+)
+
 // Visibility constants
 const (
 	VisibilityPublic  = "public"
@@ -286,19 +309,22 @@ func (r *Repository) Git(args ...string) (stdout, stderr *bytes.Buffer, err erro
 
 // GetFileModTime returns the last modification time of a file from git history
 func (r *Repository) GetFileModTime(branch, path string) (time.Time, error) {
-	stdout, _, err := r.Git("log", "-1", "--format=%aI", branch, "--", path)
+	commit, err := gitstore.Default.Resolve(r.Path(), branch)
 	if err != nil {
 		// If error, return zero time which will be handled by caller
 		return time.Time{}, err
 	}
+	return r.fileModTime(commit, path)
+}
 
-	timeStr := strings.TrimSpace(stdout.String())
-	if timeStr == "" {
-		// File has no history yet, return zero time
-		return time.Time{}, nil
+// fileModTime returns when path last changed in the history of commit,
+// zero if it has no history yet
+func (r *Repository) fileModTime(commit, path string) (time.Time, error) {
+	times, err := gitstore.Default.LastModified(r.Path(), commit, []string{path})
+	if err != nil {
+		return time.Time{}, err
 	}
-
-	return time.Parse(time.RFC3339, timeStr)
+	return times[strings.Trim(path, "/")], nil
 }
 
 // GetRepositoryByID retrieves a repository by its ID
@@ -365,6 +391,7 @@ func DeleteRepository(id string) error {
 	// to avoid circular dependencies
 
 	// Remove git directory
+	gitstore.Default.Forget(repo.Path())
 	if err := os.RemoveAll(repo.Path()); err != nil {
 		return errors.Wrap(err, "failed to remove repository directory")
 	}
@@ -376,9 +403,57 @@ func DeleteRepository(id string) error {
 
 	// Delete related records (permissions, issues, etc.)
 	DB.Query("DELETE FROM permissions WHERE RepoID = ?", id)
+	DeleteRepoTeamAccess(id)
+	DeleteRepoLabels(id)
 	DB.Query("DELETE FROM issues WHERE RepoID = ?", id)
 	DB.Query("DELETE FROM pull_requests WHERE RepoID = ?", id)
 	DB.Query("DELETE FROM access_tokens WHERE RepoID = ?", id)
+	DB.Query("DELETE FROM repo_health_snapshots WHERE RepoID = ?", id).Exec()
+	DB.Query("DELETE FROM sandbox_policies WHERE RepoID = ?", id).Exec()
+	DB.Query("DELETE FROM tool_policies WHERE RepoID = ?", id).Exec()
+	DB.Query("DELETE FROM stale_policies WHERE RepoID = ?", id).Exec()
+	DB.Query("DELETE FROM stale_marks WHERE RepoID = ?", id).Exec()
+	DB.Query("DELETE FROM dependency_scans WHERE RepoID = ?", id).Exec()
+	DB.Query("DELETE FROM auto_approval_policies WHERE RepoID = ?", id).Exec()
+	DB.Query("DELETE FROM guest_links WHERE RepoID = ?", id).Exec()
+	DB.Query("DELETE FROM guest_link_accesses WHERE RepoID = ?", id).Exec()
+	DB.Query("DELETE FROM milestones WHERE RepoID = ?", id).Exec()
+	DeleteRepoProjects(id)
+	DB.Query("DELETE FROM project_cards WHERE RepoID = ?", id).Exec()
+	DB.Query("DELETE FROM prompt_templates WHERE RepoID = ?", id).Exec()
+	DB.Query("DELETE FROM review_requests WHERE RepoID = ?", id).Exec()
+	DB.Query("DELETE FROM notifications WHERE RepoID = ?", id).Exec()
+	DB.Query("DELETE FROM attachments WHERE RepoID = ?", id).Exec()
+	DB.Query("DELETE FROM code_chunks WHERE RepoID = ?", id).Exec()
+	DB.Query("DELETE FROM semantic_indexes WHERE RepoID = ?", id).Exec()
+	DB.Query("DELETE FROM read_states WHERE RepoID = ?", id).Exec()
+	DeleteRepoSavedViews(id)
+	DeleteRepoReferences(id)
+	DeleteRepoMentions(id)
+	DB.Query("DELETE FROM report_schedules WHERE RepoID = ?", id).Exec()
+	DB.Query("DELETE FROM repo_reports WHERE RepoID = ?", id).Exec()
+	DB.Query("DELETE FROM webhooks WHERE RepoID = ?", id).Exec()
+	DB.Query("DELETE FROM webhook_deliveries WHERE RepoID = ?", id).Exec()
+	DeleteRepoChatIntegrations(id)
+	DB.Query("DELETE FROM github_sync_runs WHERE RepoID = ?", id).Exec()
+	DB.Query("DELETE FROM repo_mirrors WHERE RepoID = ?", id).Exec()
+	DB.Query("DELETE FROM lfs_objects WHERE RepoID = ?", id).Exec()
+	DB.Query("DELETE FROM branch_protections WHERE RepoID = ?", id).Exec()
+	DB.Query("DELETE FROM pr_approvals WHERE RepoID = ?", id).Exec()
+	DB.Query("DELETE FROM pr_reviews WHERE RepoID = ?", id).Exec()
+	DB.Query("DELETE FROM merge_checks WHERE RepoID = ?", id).Exec()
+	DB.Query("DELETE FROM commit_statuses WHERE RepoID = ?", id).Exec()
+	DB.Query("DELETE FROM workflow_step_runs WHERE RunID IN (SELECT ID FROM workflow_runs WHERE RepoID = ?)", id).Exec()
+	DB.Query("DELETE FROM workflow_job_runs WHERE RunID IN (SELECT ID FROM workflow_runs WHERE RepoID = ?)", id).Exec()
+	DB.Query("DELETE FROM workflow_runs WHERE RepoID = ?", id).Exec()
+	DeleteRepoActionSecrets(id)
+	DeleteRepoEnvironments(id)
+	DeleteRepoTestRuns(id)
+	DeleteRepoLintRuns(id)
+	DeleteRepoSBOMs(id)
+	DeleteRepoSearchIndex(id)
+	storage.RemoveAll(Storage(), attachmentPrefix(id))
+	storage.RemoveAll(Storage(), lfsPrefix(id))
 
 	return nil
 }
@@ -474,14 +549,7 @@ func (r *Repository) GetStats() (map[string]any, error) {
 
 		// Determine primary language if not set
 		if r.PrimaryLanguage == "" && len(langStats) > 0 {
-			maxBytes := 0
-			primaryLang := ""
-			for lang, bytes := range langStats {
-				if bytes > maxBytes {
-					maxBytes = bytes
-					primaryLang = lang
-				}
-			}
+			primaryLang := topLanguage(langStats)
 			r.PrimaryLanguage = primaryLang
 			stats["primary_language"] = primaryLang
 			// Update the repository with the detected primary language
@@ -2,6 +2,7 @@ package models
 
 import (
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 )
@@ -208,3 +209,23 @@ func (r *Repository) ListBranches() ([]string, error) {
 	}
 	return names, nil
 }
+
+// GetBranchCommitDates returns when each local branch last had a commit
+func (r *Repository) GetBranchCommitDates() (map[string]time.Time, error) {
+	stdout, stderr, err := r.Git("for-each-ref", "--format=%(refname:short)|%(committerdate:iso-strict)", "refs/heads")
+	if err != nil {
+		return nil, errors.Wrap(err, stderr.String())
+	}
+
+	dates := make(map[string]time.Time)
+	for _, line := range strings.Split(strings.TrimSpace(stdout.String()), "\n") {
+		name, date, ok := strings.Cut(line, "|")
+		if !ok {
+			continue
+		}
+		if when, err := time.Parse(time.RFC3339, date); err == nil {
+			dates[name] = when
+		}
+	}
+	return dates, nil
+}
@@ -8,6 +8,8 @@ import (
 	"strings"
 	"time"
 
+	"workspace/internal/gitstore"
+
 	"github.com/pkg/errors"
 )
 
@@ -120,57 +122,56 @@ func (r *Repository) GetCommits(branch string, limit int) ([]*Commit, error) {
 		branch = r.GetDefaultBranch()
 	}
 
-	// Check if repository has any commits
-	if r.IsEmpty() {
-		return []*Commit{}, nil
+	// Resolving the branch also tells us if the repository has any commits
+	head, err := gitstore.Default.Resolve(r.Path(), branch)
+	if err != nil {
+		if errors.Is(err, gitstore.ErrNotFound) {
+			return []*Commit{}, nil
+		}
+		return nil, err
 	}
 
-	// Build git log command
-	args := []string{
-		"log",
-		"--pretty=format:%H|%h|%s|%an|%ae|%ad",
-		"--date=iso",
+	// History below a commit never changes, so it is cached by its hash
+	key := fmt.Sprintf("log:%s:%d", head, limit)
+	cached, err := gitstore.Default.Remember(r.Path(), key, func() (any, int64, error) {
+		commits, err := r.readCommits(head, limit)
+		return commits, int64(len(commits)) * 256, err
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	if limit > 0 {
-		args = append(args, "-n", strconv.Itoa(limit))
+	// Hand out copies so callers can't modify the cached history
+	history := cached.([]*Commit)
+	commits := make([]*Commit, len(history))
+	for i, commit := range history {
+		copied := *commit
+		commits[i] = &copied
 	}
+	return commits, nil
+}
 
-	args = append(args, branch)
-
-	stdout, stderr, err := r.Git(args...)
+// readCommits reads up to limit commits reachable from rev
+func (r *Repository) readCommits(rev string, limit int) ([]*Commit, error) {
+	history, err := gitstore.Default.Log(r.Path(), rev, limit)
 	if err != nil {
-		if strings.Contains(stderr.String(), "does not have any commits") {
+		if errors.Is(err, gitstore.ErrNotFound) {
 			return []*Commit{}, nil
 		}
-		return nil, errors.Wrap(err, stderr.String())
+		return nil, errors.Wrap(err, "failed to read history")
 	}
 
-	var commits []*Commit
-	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
-
-	for _, line := range lines {
-		if line == "" {
-			continue
-		}
-
-		parts := strings.Split(line, "|")
-		if len(parts) >= 6 {
-			date, _ := time.Parse("2006-01-02 15:04:05 -0700", parts[5])
-
-			commit := &Commit{
-				Hash:      parts[0],
-				ShortHash: parts[1],
-				Message:   parts[2],
-				Author:    parts[3],
-				Email:     parts[4],
-				Date:      date,
-			}
-
-			commits = append(commits, commit)
-		}
+	commits := make([]*Commit, 0, len(history))
+	for _, c := range history {
+		commits = append(commits, &Commit{
+			Hash:      c.Hash,
+			ShortHash: c.Hash[:7],
+			Message:   c.Subject,
+			Author:    c.Author,
+			Email:     c.Email,
+			Date:      c.Date,
+		})
 	}
-
 	return commits, nil
 }
 
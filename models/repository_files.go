@@ -5,10 +5,11 @@ import (
 	"os/exec"
 	"path/filepath"
 	"sort"
-	"strconv"
 	"strings"
 	"time"
 
+	"workspace/internal/gitstore"
+
 	"github.com/pkg/errors"
 )
 
@@ -64,98 +65,66 @@ func (r *Repository) GetFileTree(branch, path string) ([]*FileNode, error) {
 	if branch == "" {
 		branch = r.GetDefaultBranch()
 	}
-	
-	// Check if branch exists
-	if !r.BranchExists(branch) {
-		return []*FileNode{}, nil
-	}
-	
-	// Prepare path
-	if path == "" || path == "/" {
-		path = "."
+
+	// A missing branch just has nothing to show
+	commit, err := gitstore.Default.Resolve(r.Path(), branch)
+	if err != nil {
+		if errors.Is(err, gitstore.ErrNotFound) {
+			return []*FileNode{}, nil
+		}
+		return nil, err
 	}
-	
-	// List files using git ls-tree
-	args := []string{"ls-tree", "--long", branch}
-	if path != "." {
-		args = append(args, "--", path+"/")
+
+	path = strings.Trim(path, "/")
+	if path == "." {
+		path = ""
 	}
-	
-	stdout, stderr, err := r.Git(args...)
+
+	entries, err := gitstore.Default.Tree(r.Path(), commit, path)
 	if err != nil {
-		if strings.Contains(stderr.String(), "not a valid object") {
+		if errors.Is(err, gitstore.ErrNotFound) {
 			return []*FileNode{}, nil
 		}
-		return nil, errors.Wrap(err, stderr.String())
+		return nil, err
 	}
-	
-	var nodes []*FileNode
-	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
-	
-	for _, line := range lines {
-		if line == "" {
-			continue
+
+	nodes := make([]*FileNode, 0, len(entries))
+	paths := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		fullPath := entry.Name
+		if path != "" {
+			fullPath = path + "/" + entry.Name
+		}
+
+		node := &FileNode{
+			Name: entry.Name,
+			Path: fullPath, // Keep full path for navigation
+			Mode: entry.Mode,
+			Hash: entry.Hash,
+			Size: entry.Size,
+			Type: "file",
 		}
-		
-		// Parse ls-tree output: mode type hash size name
-		parts := strings.Fields(line)
-		if len(parts) >= 5 {
-			mode := parts[0]
-			nodeType := parts[1]
-			hash := parts[2]
-			size := int64(0)
-			name := strings.Join(parts[4:], " ")
-			
-			// Parse size for files
-			if nodeType == "blob" {
-				if sz, err := strconv.Atoi(parts[3]); err == nil {
-					size = int64(sz)
-				}
-			}
-			
-			// Store the full path for navigation
-			fullPath := name
-			
-			// Clean up the name for display
-			displayName := name
-			if path != "." && strings.HasPrefix(name, path+"/") {
-				displayName = strings.TrimPrefix(name, path+"/")
-			}
-			
-			node := &FileNode{
-				Name: filepath.Base(displayName),
-				Path: fullPath,  // Keep full path for navigation
-				Mode: mode,
-				Hash: hash,
-				Size: size,
-			}
-			
-			if nodeType == "tree" {
-				node.Type = "dir"
-			} else {
-				node.Type = "file"
-			}
-			
-			nodes = append(nodes, node)
+		if entry.Type == "tree" {
+			node.Type = "dir"
 		}
+
+		nodes = append(nodes, node)
+		paths = append(paths, fullPath)
 	}
-	
-	// Get modification times for each node
-	for i, node := range nodes {
-		if node.Type == "file" {
-			modTime, err := r.GetFileModTime(branch, node.Path)
-			if err == nil && !modTime.IsZero() {
-				nodes[i].ModTime = modTime
-			} else {
-				// Fallback to current time if we can't get git history
-				nodes[i].ModTime = time.Now()
-			}
-		} else {
-			// For directories, use current time
-			nodes[i].ModTime = time.Now()
+
+	// Modification times for the whole listing come from a single history walk
+	modTimes, err := gitstore.Default.LastModified(r.Path(), commit, paths)
+	if err != nil {
+		modTimes = nil
+	}
+	for _, node := range nodes {
+		node.ModTime = modTimes[node.Path]
+		if node.ModTime.IsZero() {
+			// Fallback to current time if we can't get git history
+			node.ModTime = time.Now()
 		}
 	}
-	
+
 	// Sort directories first, then by name
 	sort.Slice(nodes, func(i, j int) bool {
 		if nodes[i].Type == "dir" && nodes[j].Type != "dir" {
@@ -166,7 +135,7 @@ func (r *Repository) GetFileTree(branch, path string) ([]*FileNode, error) {
 		}
 		return nodes[i].Name < nodes[j].Name
 	})
-	
+
 	return nodes, nil
 }
 
@@ -175,40 +144,24 @@ func (r *Repository) GetFile(branch, path string) (*File, error) {
 	if branch == "" {
 		branch = r.GetDefaultBranch()
 	}
-	
-	// Check if file exists
-	if !r.FileExists(branch, path) {
+
+	commit, err := gitstore.Default.Resolve(r.Path(), branch)
+	if err != nil {
 		return nil, errors.New("file not found")
 	}
-	
-	// Get file content
-	stdout, stderr, err := r.Git("show", branch+":"+path)
+
+	data, err := gitstore.Default.Blob(r.Path(), commit, path)
 	if err != nil {
-		return nil, errors.Wrap(err, stderr.String())
-	}
-	
-	content := stdout.String()
-	
-	// Check if binary
-	isBinary := false
-	if strings.Contains(content, "\x00") {
-		isBinary = true
-	}
-	
-	// Get file info
-	infoOut, _, err := r.Git("ls-tree", "--long", branch, path)
-	size := int64(len(content))
-	if err == nil {
-		parts := strings.Fields(infoOut.String())
-		if len(parts) >= 4 {
-			if sz, err := strconv.Atoi(parts[3]); err == nil {
-				size = int64(sz)
-			}
+		if errors.Is(err, gitstore.ErrNotFound) {
+			return nil, errors.New("file not found")
 		}
+		return nil, err
 	}
-	
+
+	content := string(data)
+
 	// Get modification time
-	modTime, err := r.GetFileModTime(branch, path)
+	modTime, err := r.fileModTime(commit, path)
 	if err != nil || modTime.IsZero() {
 		// Fallback to current time if we can't get git history
 		modTime = time.Now()
@@ -218,12 +171,12 @@ func (r *Repository) GetFile(branch, path string) (*File, error) {
 		Path:     path,
 		Name:     filepath.Base(path),
 		Content:  content,
-		Size:     size,
-		IsBinary: isBinary,
+		Size:     int64(len(data)),
+		IsBinary: strings.Contains(content, "\x00"),
 		Language: getLanguageFromExtension(filepath.Ext(path)),
 		ModTime:  modTime,
 	}
-	
+
 	return file, nil
 }
 
@@ -232,17 +185,14 @@ func (r *Repository) FileExists(branch, path string) bool {
 	if branch == "" {
 		branch = r.GetDefaultBranch()
 	}
-	
+
 	if branch == "" {
 		return false // No branches in repository
 	}
-	
+
 	// Check if object exists and is a blob (file)
-	stdout, _, err := r.Git("cat-file", "-t", branch+":"+path)
-	if err != nil {
-		return false
-	}
-	return strings.TrimSpace(stdout.String()) == "blob"
+	obj, err := gitstore.Default.Stat(r.Path(), branch, path)
+	return err == nil && obj.Type == "blob"
 }
 
 // IsDirectory checks if a path is a directory in a branch
@@ -250,17 +200,14 @@ func (r *Repository) IsDirectory(branch, path string) bool {
 	if branch == "" {
 		branch = r.GetDefaultBranch()
 	}
-	
+
 	if branch == "" {
 		return false // No branches in repository
 	}
-	
+
 	// Check if object exists and is a tree (directory)
-	stdout, _, err := r.Git("cat-file", "-t", branch+":"+path)
-	if err != nil {
-		return false
-	}
-	return strings.TrimSpace(stdout.String()) == "tree"
+	obj, err := gitstore.Default.Stat(r.Path(), branch, path)
+	return err == nil && obj.Type == "tree"
 }
 
 // GetREADME finds and returns the README file
@@ -560,6 +507,12 @@ func (r *Repository) DeleteFile(branch, path, message, authorName, authorEmail s
 	return nil
 }
 
+// FileLanguage returns the programming language of a file from its
+// extension, or "text" when it isn't recognized
+func FileLanguage(path string) string {
+	return getLanguageFromExtension(filepath.Ext(path))
+}
+
 // getLanguageFromExtension returns the programming language based on file extension
 func getLanguageFromExtension(ext string) string {
 	languages := map[string]string{
@@ -0,0 +1,462 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/The-Skyscape/devtools/pkg/application"
+)
+
+// Health metric keys, also used as the snapshot columns
+const (
+	HealthCIStability   = "ci"
+	HealthIssueAge      = "issues"
+	HealthReviewLatency = "reviews"
+	HealthCoverage      = "coverage"
+	HealthStaleBranches = "branches"
+)
+
+const (
+	// healthWindowDays is how far back CI runs and pull requests are considered
+	healthWindowDays = 30
+
+	// staleBranchDays is how long a branch can go without commits before it
+	// counts as stale
+	staleBranchDays = 90
+)
+
+// HealthMetric is one component of a repository's health score
+type HealthMetric struct {
+	Key            string
+	Name           string
+	Weight         int
+	Score          int    // 0-100, higher is healthier
+	Available      bool   // False when there is no data, the metric is then left out
+	Value          string // Measured value for display, e.g. "92% passing"
+	Recommendation string // What to do about it, empty when healthy
+}
+
+// RepoHealth is the composite health of a repository
+type RepoHealth struct {
+	Score      int
+	Grade      string
+	Metrics    []*HealthMetric
+	ComputedAt time.Time
+}
+
+// Recommendations returns the advice for metrics that need attention,
+// weakest first
+func (h *RepoHealth) Recommendations() []*HealthMetric {
+	var metrics []*HealthMetric
+	for _, m := range h.Metrics {
+		if m.Recommendation != "" {
+			metrics = append(metrics, m)
+		}
+	}
+	slices.SortStableFunc(metrics, func(a, b *HealthMetric) int {
+		return a.Score - b.Score
+	})
+	return metrics
+}
+
+// RepoHealthSnapshot stores one day's health score for trend charts
+type RepoHealthSnapshot struct {
+	application.Model
+	RepoID        string
+	Day           string // 2006-01-02
+	Score         int
+	CIScore       int // -1 when the metric was unavailable
+	IssueScore    int
+	ReviewScore   int
+	CoverageScore int
+	BranchScore   int
+}
+
+// Table returns the database table name
+func (*RepoHealthSnapshot) Table() string { return "repo_health_snapshots" }
+
+// ComputeHealth measures the repository's current health
+func (r *Repository) ComputeHealth() (*RepoHealth, error) {
+	ci, err := r.ciStabilityMetric()
+	if err != nil {
+		return nil, err
+	}
+	issues, err := r.issueAgeMetric()
+	if err != nil {
+		return nil, err
+	}
+	reviews, err := r.reviewLatencyMetric()
+	if err != nil {
+		return nil, err
+	}
+	coverage, err := r.coverageMetric()
+	if err != nil {
+		return nil, err
+	}
+	branches, err := r.staleBranchMetric()
+	if err != nil {
+		return nil, err
+	}
+
+	metrics := []*HealthMetric{ci, issues, reviews, coverage, branches}
+	score := compositeHealthScore(metrics)
+	return &RepoHealth{
+		Score:      score,
+		Grade:      healthGrade(score),
+		Metrics:    metrics,
+		ComputedAt: time.Now(),
+	}, nil
+}
+
+// ciStabilityMetric scores the share of recent action runs that passed
+func (r *Repository) ciStabilityMetric() (*HealthMetric, error) {
+	metric := &HealthMetric{Key: HealthCIStability, Name: "CI Stability", Weight: 25}
+
+	runs, err := ActionRuns.Search(fmt.Sprintf(`
+		WHERE ActionID IN (SELECT ID FROM actions WHERE RepoID = ?)
+		AND Status IN ('completed', 'failed')
+		AND CreatedAt >= datetime('now', '-%d days')`, healthWindowDays), r.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(runs) == 0 {
+		metric.Value = "No runs in the last 30 days"
+		return metric, nil
+	}
+
+	failed := 0
+	for _, run := range runs {
+		if run.Status == "failed" || run.ExitCode != 0 {
+			failed++
+		}
+	}
+
+	metric.Available = true
+	metric.Score = (len(runs) - failed) * 100 / len(runs)
+	metric.Value = fmt.Sprintf("%d%% of %d runs passed", metric.Score, len(runs))
+	if metric.Score < 80 {
+		metric.Recommendation = fmt.Sprintf("%d of the last %d action runs failed. Fix broken or flaky actions so failures mean something again.", failed, len(runs))
+	}
+	return metric, nil
+}
+
+// issueAgeMetric scores how long open issues have been waiting
+func (r *Repository) issueAgeMetric() (*HealthMetric, error) {
+	metric := &HealthMetric{Key: HealthIssueAge, Name: "Open Issue Age", Weight: 20, Available: true}
+
+	issues, err := Issues.Search("WHERE RepoID = ? AND Status IN ('open', 'in_progress')", r.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(issues) == 0 {
+		metric.Score = 100
+		metric.Value = "No open issues"
+		return metric, nil
+	}
+
+	ages := make([]float64, 0, len(issues))
+	old := 0
+	for _, issue := range issues {
+		days := time.Since(issue.CreatedAt).Hours() / 24
+		ages = append(ages, days)
+		if days > 90 {
+			old++
+		}
+	}
+
+	median := medianOf(ages)
+	metric.Score = linearScore(median, 7, 180)
+	metric.Value = fmt.Sprintf("Median %s across %d open", formatDays(median), len(issues))
+	if old > 0 {
+		metric.Recommendation = fmt.Sprintf("%d open issues are over 90 days old. Triage them: close what is no longer relevant and schedule the rest.", old)
+	} else if metric.Score < 70 {
+		metric.Recommendation = "Issues are waiting weeks for attention. Assign owners to the oldest open issues."
+	}
+	return metric, nil
+}
+
+// reviewLatencyMetric scores how quickly pull requests get a first response
+func (r *Repository) reviewLatencyMetric() (*HealthMetric, error) {
+	metric := &HealthMetric{Key: HealthReviewLatency, Name: "Review Latency", Weight: 20}
+
+	prs, err := PullRequests.Search(fmt.Sprintf(`
+		WHERE RepoID = ? AND Status != 'draft'
+		AND CreatedAt >= datetime('now', '-%d days')`, healthWindowDays), r.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(prs) == 0 {
+		metric.Value = "No pull requests in the last 30 days"
+		return metric, nil
+	}
+
+	latencies := make([]float64, 0, len(prs))
+	waiting := 0
+	for _, pr := range prs {
+		responded, err := pr.firstResponseAt()
+		if err != nil {
+			return nil, err
+		}
+		if responded.IsZero() {
+			// Still waiting, count the time so far
+			responded = time.Now()
+			if time.Since(pr.CreatedAt) > 72*time.Hour && (pr.Status == "open" || pr.Status == "changes_requested") {
+				waiting++
+			}
+		}
+		latencies = append(latencies, responded.Sub(pr.CreatedAt).Hours())
+	}
+
+	median := medianOf(latencies)
+	metric.Available = true
+	metric.Score = linearScore(median, 24, 14*24)
+	metric.Value = fmt.Sprintf("Median %s to first response", formatDays(median/24))
+	if waiting > 0 {
+		metric.Recommendation = fmt.Sprintf("%d pull requests have waited over 3 days without a review. Reviewing them unblocks their authors.", waiting)
+	} else if metric.Score < 70 {
+		metric.Recommendation = "Pull requests wait days for a first review. Agree on a review turnaround and share the load."
+	}
+	return metric, nil
+}
+
+// firstResponseAt returns when someone other than the author first
+// commented on or merged the pull request, zero if nobody has
+func (pr *PullRequest) firstResponseAt() (time.Time, error) {
+	comments, err := Comments.Search("WHERE EntityType = 'pr' AND EntityID = ? AND AuthorID != ? ORDER BY CreatedAt ASC LIMIT 1", pr.ID, pr.AuthorID)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var first time.Time
+	if len(comments) > 0 {
+		first = comments[0].CreatedAt
+	}
+	if !pr.MergedAt.IsZero() && (first.IsZero() || pr.MergedAt.Before(first)) {
+		first = pr.MergedAt
+	}
+	return first, nil
+}
+
+// coverageMetric scores the test coverage reported by the latest action run
+// that printed one
+func (r *Repository) coverageMetric() (*HealthMetric, error) {
+	metric := &HealthMetric{Key: HealthCoverage, Name: "Test Coverage", Weight: 20}
+
+	runs, err := ActionRuns.Search(`
+		WHERE ActionID IN (SELECT ID FROM actions WHERE RepoID = ?)
+		AND Status = 'completed'
+		ORDER BY CreatedAt DESC LIMIT 20`, r.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, run := range runs {
+		if percent, ok := ParseCoverage(run.Output); ok {
+			metric.Available = true
+			metric.Score = int(math.Round(percent))
+			metric.Value = fmt.Sprintf("%.1f%% of statements", percent)
+			if percent < 60 {
+				metric.Recommendation = "Coverage is below 60%. Add tests around the code that changes most often."
+			}
+			return metric, nil
+		}
+	}
+
+	metric.Value = "Not reported"
+	metric.Recommendation = "No action reports coverage. Run your tests with coverage enabled (e.g. go test -cover) to track it here."
+	return metric, nil
+}
+
+// staleBranchMetric scores the share of branches that are still active
+func (r *Repository) staleBranchMetric() (*HealthMetric, error) {
+	metric := &HealthMetric{Key: HealthStaleBranches, Name: "Stale Branches", Weight: 15, Available: true}
+
+	dates, err := r.GetBranchCommitDates()
+	if err != nil {
+		return nil, err
+	}
+
+	defaultBranch := r.GetDefaultBranch()
+	total, stale := 0, 0
+	for branch, date := range dates {
+		if branch == defaultBranch {
+			continue
+		}
+		total++
+		if time.Since(date) > staleBranchDays*24*time.Hour {
+			stale++
+		}
+	}
+
+	if total == 0 {
+		metric.Score = 100
+		metric.Value = "No feature branches"
+		return metric, nil
+	}
+
+	metric.Score = (total - stale) * 100 / total
+	metric.Value = fmt.Sprintf("%d of %d branches stale", stale, total)
+	if stale > 0 {
+		metric.Recommendation = fmt.Sprintf("%d branches have had no commits for %d days. Merge or delete them to keep the branch list meaningful.", stale, staleBranchDays)
+	}
+	return metric, nil
+}
+
+// coveragePatterns match the summary lines of common coverage tools
+var coveragePatterns = []*regexp.Regexp{
+	regexp.MustCompile(`coverage:\s*([\d.]+)%\s*of statements`),             // go test -cover
+	regexp.MustCompile(`(?m)^TOTAL\s+.*?([\d.]+)%\s*$`),                     // coverage.py
+	regexp.MustCompile(`(?m)^All files\s*\|\s*([\d.]+)`),                    // istanbul / jest
+	regexp.MustCompile(`(?i)(?:line|statement) coverage[:\s]+([\d.]+)\s*%`), // generic summaries
+}
+
+// ParseCoverage extracts a coverage percentage from test output. When a run
+// covers several packages the last reported value wins.
+func ParseCoverage(output string) (float64, bool) {
+	for _, pattern := range coveragePatterns {
+		matches := pattern.FindAllStringSubmatch(output, -1)
+		if len(matches) == 0 {
+			continue
+		}
+		percent, err := strconv.ParseFloat(matches[len(matches)-1][1], 64)
+		if err == nil && percent >= 0 && percent <= 100 {
+			return percent, true
+		}
+	}
+	return 0, false
+}
+
+// compositeHealthScore averages the available metrics by weight
+func compositeHealthScore(metrics []*HealthMetric) int {
+	total, weights := 0, 0
+	for _, m := range metrics {
+		if m.Available {
+			total += m.Score * m.Weight
+			weights += m.Weight
+		}
+	}
+	if weights == 0 {
+		return 0
+	}
+	return int(math.Round(float64(total) / float64(weights)))
+}
+
+// healthGrade turns a score into a letter grade
+func healthGrade(score int) string {
+	switch {
+	case score >= 90:
+		return "A"
+	case score >= 75:
+		return "B"
+	case score >= 60:
+		return "C"
+	case score >= 40:
+		return "D"
+	default:
+		return "F"
+	}
+}
+
+// linearScore gives 100 at or below good, 0 at or above bad, and a straight
+// line in between
+func linearScore(value, good, bad float64) int {
+	switch {
+	case value <= good:
+		return 100
+	case value >= bad:
+		return 0
+	default:
+		return int(math.Round(100 * (bad - value) / (bad - good)))
+	}
+}
+
+// medianOf returns the median of values, which it sorts
+func medianOf(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	slices.Sort(values)
+	mid := len(values) / 2
+	if len(values)%2 == 0 {
+		return (values[mid-1] + values[mid]) / 2
+	}
+	return values[mid]
+}
+
+// formatDays renders a duration in days, using hours below a day
+func formatDays(days float64) string {
+	switch {
+	case days < 1:
+		return fmt.Sprintf("%.0fh", days*24)
+	case days < 2:
+		return "1 day"
+	default:
+		return fmt.Sprintf("%.0f days", days)
+	}
+}
+
+// RecordHealthSnapshot saves today's score, replacing an earlier one from
+// the same day
+func RecordHealthSnapshot(repoID string, health *RepoHealth) (*RepoHealthSnapshot, error) {
+	day := health.ComputedAt.Format("2006-01-02")
+	existing, err := RepoHealthSnapshots.Search("WHERE RepoID = ? AND Day = ? LIMIT 1", repoID, day)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := &RepoHealthSnapshot{RepoID: repoID, Day: day}
+	if len(existing) > 0 {
+		snapshot = existing[0]
+	}
+
+	snapshot.Score = health.Score
+	for _, m := range health.Metrics {
+		score := -1
+		if m.Available {
+			score = m.Score
+		}
+		switch m.Key {
+		case HealthCIStability:
+			snapshot.CIScore = score
+		case HealthIssueAge:
+			snapshot.IssueScore = score
+		case HealthReviewLatency:
+			snapshot.ReviewScore = score
+		case HealthCoverage:
+			snapshot.CoverageScore = score
+		case HealthStaleBranches:
+			snapshot.BranchScore = score
+		}
+	}
+
+	if len(existing) > 0 {
+		return snapshot, RepoHealthSnapshots.Update(snapshot)
+	}
+	return RepoHealthSnapshots.Insert(snapshot)
+}
+
+// GetHealthHistory returns the repository's daily snapshots for the last
+// days, oldest first
+func GetHealthHistory(repoID string, days int) ([]*RepoHealthSnapshot, error) {
+	since := time.Now().AddDate(0, 0, -days).Format("2006-01-02")
+	return RepoHealthSnapshots.Search("WHERE RepoID = ? AND Day > ? ORDER BY Day ASC", repoID, since)
+}
+
+// GetLatestHealthSnapshot returns the most recent snapshot, nil if the
+// repository has never been scored
+func GetLatestHealthSnapshot(repoID string) (*RepoHealthSnapshot, error) {
+	snapshots, err := RepoHealthSnapshots.Search("WHERE RepoID = ? ORDER BY Day DESC LIMIT 1", repoID)
+	if err != nil || len(snapshots) == 0 {
+		return nil, err
+	}
+	return snapshots[0], nil
+}
+
+// Grade returns the letter grade of the snapshot's score
+func (s *RepoHealthSnapshot) Grade() string {
+	return healthGrade(s.Score)
+}
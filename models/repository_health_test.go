@@ -0,0 +1,88 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/The-Skyscape/devtools/pkg/testutils"
+)
+
+func TestParseCoverage(t *testing.T) {
+	cases := []struct {
+		name   string
+		output string
+		want   float64
+		ok     bool
+	}{
+		{"go test", "ok  \tpkg/a\t0.2s\tcoverage: 71.5% of statements\nok  \tpkg/b\t0.1s\tcoverage: 80.0% of statements", 80.0, true},
+		{"coverage.py", "Name    Stmts   Miss  Cover\n-------\nTOTAL     120     30    75%\n", 75, true},
+		{"jest", "All files |   88.24 |    70 |   90 |   88.24 |", 88.24, true},
+		{"no coverage", "PASS\nok  \tpkg/a\t0.2s", 0, false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := ParseCoverage(tc.output)
+			testutils.AssertEqual(t, tc.ok, ok)
+			testutils.AssertEqual(t, tc.want, got)
+		})
+	}
+}
+
+func TestHealthScoring(t *testing.T) {
+	t.Run("linearScore", func(t *testing.T) {
+		testutils.AssertEqual(t, 100, linearScore(3, 7, 180))
+		testutils.AssertEqual(t, 0, linearScore(200, 7, 180))
+		testutils.AssertEqual(t, 50, linearScore(15, 10, 20))
+	})
+
+	t.Run("UnavailableMetricsAreLeftOut", func(t *testing.T) {
+		metrics := []*HealthMetric{
+			{Key: HealthCIStability, Weight: 25, Score: 80, Available: true},
+			{Key: HealthCoverage, Weight: 20, Score: 0},
+			{Key: HealthStaleBranches, Weight: 15, Score: 100, Available: true},
+		}
+		// (80*25 + 100*15) / 40
+		testutils.AssertEqual(t, 88, compositeHealthScore(metrics))
+		testutils.AssertEqual(t, "B", healthGrade(88))
+	})
+
+	t.Run("RecommendationsWeakestFirst", func(t *testing.T) {
+		health := &RepoHealth{Metrics: []*HealthMetric{
+			{Key: HealthIssueAge, Score: 60, Recommendation: "triage"},
+			{Key: HealthCIStability, Score: 100},
+			{Key: HealthReviewLatency, Score: 20, Recommendation: "review"},
+		}}
+		recommendations := health.Recommendations()
+		testutils.AssertEqual(t, 2, len(recommendations))
+		testutils.AssertEqual(t, HealthReviewLatency, recommendations[0].Key)
+	})
+}
+
+func TestRecordHealthSnapshot(t *testing.T) {
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+
+	health := &RepoHealth{
+		Score:      70,
+		ComputedAt: time.Now(),
+		Metrics: []*HealthMetric{
+			{Key: HealthCIStability, Score: 90, Available: true},
+			{Key: HealthCoverage},
+		},
+	}
+	_, err := RecordHealthSnapshot("health-repo", health)
+	testutils.AssertNoError(t, err)
+
+	// A second score on the same day replaces the first
+	health.Score = 75
+	_, err = RecordHealthSnapshot("health-repo", health)
+	testutils.AssertNoError(t, err)
+
+	history, err := GetHealthHistory("health-repo", 30)
+	testutils.AssertNoError(t, err)
+	testutils.AssertEqual(t, 1, len(history))
+	testutils.AssertEqual(t, 75, history[0].Score)
+	testutils.AssertEqual(t, 90, history[0].CIScore)
+	testutils.AssertEqual(t, -1, history[0].CoverageScore)
+}
@@ -0,0 +1,93 @@
+package models
+
+import (
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// scpLikeURL matches the user@host:path form git accepts for SSH remotes
+var scpLikeURL = regexp.MustCompile(`^[a-zA-Z0-9._-]+@[a-zA-Z0-9.-]+:[^/].*$`)
+
+// ValidateImportURL checks that a repository can be cloned from gitURL.
+// Only network remotes are accepted, so imports can't read repositories or
+// files that are already on the server.
+func ValidateImportURL(gitURL string) error {
+	if gitURL == "" {
+		return errors.New("Git URL is required")
+	}
+	if strings.HasPrefix(gitURL, "-") || strings.ContainsAny(gitURL, " \t\n") {
+		return errors.New("invalid Git URL")
+	}
+	if scpLikeURL.MatchString(gitURL) {
+		return nil
+	}
+
+	u, err := url.Parse(gitURL)
+	if err != nil || u.Host == "" {
+		return errors.New("invalid Git URL")
+	}
+	switch u.Scheme {
+	case "https", "http", "ssh", "git":
+		return nil
+	}
+	return errors.Errorf("unsupported Git URL scheme %q, use https or ssh", u.Scheme)
+}
+
+// ImportName returns a repository name for a clone of gitURL, taken from the
+// last part of its path
+func ImportName(gitURL string) string {
+	p := gitURL
+	if u, err := url.Parse(gitURL); err == nil && u.Host != "" {
+		p = u.Path
+	} else if i := strings.LastIndex(gitURL, ":"); i >= 0 {
+		p = gitURL[i+1:]
+	}
+	return strings.TrimSuffix(path.Base(strings.TrimRight(p, "/")), ".git")
+}
+
+// DetectDefaultBranch sets the default branch to the one HEAD points at,
+// which for a clone is the source repository's default branch
+func (r *Repository) DetectDefaultBranch() (string, error) {
+	stdout, stderr, err := r.Git("symbolic-ref", "--short", "HEAD")
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to read HEAD: %s", stderr.String())
+	}
+	if branch := strings.TrimSpace(stdout.String()); branch != "" {
+		r.DefaultBranch = branch
+	}
+	return r.DefaultBranch, nil
+}
+
+// DetectPrimaryLanguage sets the primary language to the one with the most
+// lines on the default branch
+func (r *Repository) DetectPrimaryLanguage() (string, error) {
+	stats, err := r.GetLanguageStats()
+	if err != nil {
+		return "", err
+	}
+	r.PrimaryLanguage = topLanguage(stats)
+	return r.PrimaryLanguage, nil
+}
+
+// topLanguage returns the language with the most lines, breaking ties by
+// name so the result doesn't depend on map order
+func topLanguage(stats map[string]int) string {
+	langs := make([]string, 0, len(stats))
+	for lang := range stats {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+
+	top := ""
+	for _, lang := range langs {
+		if top == "" || stats[lang] > stats[top] {
+			top = lang
+		}
+	}
+	return top
+}
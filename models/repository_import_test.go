@@ -0,0 +1,33 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/The-Skyscape/devtools/pkg/testutils"
+)
+
+func TestRepositoryImport(t *testing.T) {
+	t.Run("ValidateImportURL", func(t *testing.T) {
+		testutils.AssertNoError(t, ValidateImportURL("https://github.com/owner/repo.git"))
+		testutils.AssertNoError(t, ValidateImportURL("ssh://git@gitlab.com/owner/repo.git"))
+		testutils.AssertNoError(t, ValidateImportURL("git@github.com:owner/repo.git"))
+
+		testutils.AssertError(t, ValidateImportURL(""))
+		testutils.AssertError(t, ValidateImportURL("file:///var/lib/repos/secret"))
+		testutils.AssertError(t, ValidateImportURL("/var/lib/repos/secret"))
+		testutils.AssertError(t, ValidateImportURL("--upload-pack=touch /tmp/x"))
+		testutils.AssertError(t, ValidateImportURL("ext::sh -c touch% /tmp/x"))
+	})
+
+	t.Run("ImportName", func(t *testing.T) {
+		testutils.AssertEqual(t, "repo", ImportName("https://github.com/owner/repo.git"))
+		testutils.AssertEqual(t, "repo", ImportName("https://gitlab.com/group/sub/repo/"))
+		testutils.AssertEqual(t, "repo", ImportName("git@github.com:owner/repo.git"))
+	})
+
+	t.Run("TopLanguage", func(t *testing.T) {
+		testutils.AssertEqual(t, "", topLanguage(map[string]int{}))
+		testutils.AssertEqual(t, "Go", topLanguage(map[string]int{"Go": 900, "Markdown": 40, "YAML": 12}))
+		testutils.AssertEqual(t, "Go", topLanguage(map[string]int{"Python": 10, "Go": 10}))
+	})
+}
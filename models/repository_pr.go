@@ -183,6 +183,52 @@ func (r *Repository) CanMergeBranch(sourceBranch, targetBranch string) (bool, er
 	return canMerge, nil
 }
 
+// MergeConflicts returns the files that would conflict when merging
+// sourceBranch into targetBranch, empty when the merge is clean
+func (r *Repository) MergeConflicts(targetBranch, sourceBranch string) ([]string, error) {
+	_, conflicts, err := r.mergeTree(targetBranch, sourceBranch)
+	return conflicts, err
+}
+
+// mergeTree merges sourceBranch into targetBranch without touching either,
+// returning the resulting tree and the files that conflict. Conflicting
+// files are in the tree with conflict markers.
+func (r *Repository) mergeTree(targetBranch, sourceBranch string) (tree string, conflicts []string, err error) {
+	stdout, stderr, err := r.Git("merge-tree", "--write-tree", "--name-only", "--no-messages", targetBranch, sourceBranch)
+	tree, _, _ = strings.Cut(strings.TrimSpace(stdout.String()), "\n")
+	if err == nil {
+		return tree, nil, nil
+	}
+
+	// Exit status 1 means the merge has conflicts, anything else is a failure
+	if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != 1 {
+		return "", nil, errors.Wrap(err, strings.TrimSpace(stderr.String()))
+	}
+
+	return tree, parseMergeTreeConflicts(stdout.String()), nil
+}
+
+// parseMergeTreeConflicts reads the conflicted paths from merge-tree
+// --name-only output, which lists them after the resulting tree ID
+func parseMergeTreeConflicts(output string) []string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) < 2 {
+		return nil
+	}
+
+	var files []string
+	seen := make(map[string]bool)
+	for _, line := range lines[1:] {
+		line = strings.TrimSpace(line)
+		if line == "" || seen[line] {
+			continue
+		}
+		seen[line] = true
+		files = append(files, line)
+	}
+	return files
+}
+
 // MergeBranch merges one branch into another
 func (r *Repository) MergeBranch(sourceBranch, targetBranch, message, authorName, authorEmail string) error {
 	if sourceBranch == "" || targetBranch == "" {
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/The-Skyscape/devtools/pkg/testutils"
+)
+
+func TestParseMergeTreeConflicts(t *testing.T) {
+	t.Run("Clean", func(t *testing.T) {
+		files := parseMergeTreeConflicts("3aa474783b93a58bbeb8ddfec99c3cc53fc6aaac\n")
+		testutils.AssertEqual(t, 0, len(files))
+	})
+
+	t.Run("Conflicts", func(t *testing.T) {
+		files := parseMergeTreeConflicts("5c63c8a0f5ca1208b6e7bac6e6dc9a376f14bf1c\nmain.go\ndocs/README.md\nmain.go\n")
+		testutils.AssertEqual(t, 2, len(files))
+		testutils.AssertEqual(t, "main.go", files[0])
+		testutils.AssertEqual(t, "docs/README.md", files[1])
+	})
+}
@@ -0,0 +1,230 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// reportCommitLimit caps how much history is read for one report
+	reportCommitLimit = 1000
+
+	// stalePRDays is how long a pull request can stay open before a risk
+	// report lists it
+	stalePRDays = 14
+)
+
+// ReportStats is what happened in a repository over a report's period,
+// along with the risks open at its end
+type ReportStats struct {
+	Repo  *Repository
+	Start time.Time
+	End   time.Time
+
+	Commits      []*Commit
+	Contributors []string // Commit authors, most active first
+	IssuesOpened []*Issue
+	IssuesClosed []*Issue
+	PRsOpened    []*PullRequest
+	PRsMerged    []*PullRequest
+	RunsTotal    int
+	RunsFailed   int
+
+	OpenIssues     int
+	CriticalIssues []*Issue       // Open issues of high priority or above
+	StalePRs       []*PullRequest // Open for more than stalePRDays
+	Health         *RepoHealth
+}
+
+// ReportStats gathers the repository's activity between start and end
+func (r *Repository) ReportStats(start, end time.Time) (*ReportStats, error) {
+	stats := &ReportStats{Repo: r, Start: start, End: end}
+
+	commits, err := r.GetCommits("", reportCommitLimit)
+	if err != nil {
+		return nil, err
+	}
+	counts := map[string]int{}
+	for _, commit := range commits {
+		if commit.Date.Before(start) || !commit.Date.Before(end) {
+			continue
+		}
+		stats.Commits = append(stats.Commits, commit)
+		counts[commit.Author]++
+	}
+	for author := range counts {
+		stats.Contributors = append(stats.Contributors, author)
+	}
+	sort.Slice(stats.Contributors, func(i, j int) bool {
+		a, b := stats.Contributors[i], stats.Contributors[j]
+		return counts[a] > counts[b] || (counts[a] == counts[b] && a < b)
+	})
+
+	if stats.IssuesOpened, err = Issues.Search("WHERE RepoID = ? AND CreatedAt >= ? AND CreatedAt < ? ORDER BY CreatedAt ASC", r.ID, start, end); err != nil {
+		return nil, err
+	}
+	if stats.IssuesClosed, err = Issues.Search("WHERE RepoID = ? AND Status IN ('closed', 'resolved') AND UpdatedAt >= ? AND UpdatedAt < ? ORDER BY UpdatedAt ASC", r.ID, start, end); err != nil {
+		return nil, err
+	}
+	if stats.PRsOpened, err = PullRequests.Search("WHERE RepoID = ? AND CreatedAt >= ? AND CreatedAt < ? ORDER BY CreatedAt ASC", r.ID, start, end); err != nil {
+		return nil, err
+	}
+	if stats.PRsMerged, err = PullRequests.Search("WHERE RepoID = ? AND Status = 'merged' AND MergedAt >= ? AND MergedAt < ? ORDER BY MergedAt ASC", r.ID, start, end); err != nil {
+		return nil, err
+	}
+
+	runs, err := ActionRuns.Search(`
+		WHERE ActionID IN (SELECT ID FROM actions WHERE RepoID = ?)
+		AND Status IN ('completed', 'failed') AND CreatedAt >= ? AND CreatedAt < ?`, r.ID, start, end)
+	if err != nil {
+		return nil, err
+	}
+	stats.RunsTotal = len(runs)
+	for _, run := range runs {
+		if run.Status == "failed" || run.ExitCode != 0 {
+			stats.RunsFailed++
+		}
+	}
+
+	stats.OpenIssues = Issues.Count("WHERE RepoID = ? AND Status IN ('open', 'in_progress')", r.ID)
+	if stats.CriticalIssues, err = Issues.Search("WHERE RepoID = ? AND Status IN ('open', 'in_progress') AND Priority <= ? ORDER BY Priority, CreatedAt ASC", r.ID, PriorityHigh); err != nil {
+		return nil, err
+	}
+	if stats.StalePRs, err = PullRequests.Search("WHERE RepoID = ? AND Status = 'open' AND CreatedAt < ? ORDER BY CreatedAt ASC", r.ID, end.AddDate(0, 0, -stalePRDays)); err != nil {
+		return nil, err
+	}
+
+	if stats.Health, err = r.ComputeHealth(); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// ReportTitle returns the heading of a report of kind on the repository
+func ReportTitle(kind string, stats *ReportStats) string {
+	return fmt.Sprintf("%s: %s, %s to %s", ReportKindName(kind), stats.Repo.Name,
+		stats.Start.Format("Jan 2"), stats.End.Format("Jan 2, 2006"))
+}
+
+// RenderReport writes a report of kind as Markdown. The summary, usually
+// written by the AI assistant, opens the report when there is one.
+func RenderReport(kind string, stats *ReportStats, summary string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", ReportTitle(kind, stats))
+	if summary = strings.TrimSpace(summary); summary != "" {
+		fmt.Fprintf(&b, "## Summary\n\n%s\n\n", summary)
+	}
+
+	switch kind {
+	case ReportReleaseNotes:
+		writePRSection(&b, "Merged Pull Requests", stats.PRsMerged, "No pull requests were merged.")
+		writeIssueSection(&b, "Resolved Issues", stats.IssuesClosed, "No issues were closed.")
+		b.WriteString("## Commits\n\n")
+		if len(stats.Commits) == 0 {
+			b.WriteString("No commits.\n\n")
+		}
+		for _, commit := range stats.Commits {
+			fmt.Fprintf(&b, "- %s `%s` (%s)\n", firstLine(commit.Message), commit.ShortHash, commit.Author)
+		}
+		if len(stats.Commits) > 0 {
+			b.WriteString("\n")
+		}
+		if len(stats.Contributors) > 0 {
+			fmt.Fprintf(&b, "## Contributors\n\n%s\n\n", strings.Join(stats.Contributors, ", "))
+		}
+
+	case ReportWeeklySummary:
+		b.WriteString("## At a Glance\n\n| | |\n|---|---|\n")
+		fmt.Fprintf(&b, "| Commits | %d by %d contributors |\n", len(stats.Commits), len(stats.Contributors))
+		fmt.Fprintf(&b, "| Pull requests | %d opened, %d merged |\n", len(stats.PRsOpened), len(stats.PRsMerged))
+		fmt.Fprintf(&b, "| Issues | %d opened, %d closed, %d open |\n", len(stats.IssuesOpened), len(stats.IssuesClosed), stats.OpenIssues)
+		fmt.Fprintf(&b, "| Action runs | %d, %d failed |\n", stats.RunsTotal, stats.RunsFailed)
+		fmt.Fprintf(&b, "| Health | %d (%s) |\n\n", stats.Health.Score, stats.Health.Grade)
+		writePRSection(&b, "Merged Pull Requests", stats.PRsMerged, "No pull requests were merged.")
+		writeIssueSection(&b, "New Issues", stats.IssuesOpened, "No issues were opened.")
+		writeRecommendations(&b, stats.Health)
+
+	case ReportRiskItems:
+		writeIssueSection(&b, "Critical and High Priority Issues", stats.CriticalIssues, "No critical or high priority issues are open.")
+		writePRSection(&b, fmt.Sprintf("Pull Requests Open Over %d Days", stalePRDays), stats.StalePRs, "No pull requests are waiting that long.")
+		b.WriteString("## CI Failures\n\n")
+		if stats.RunsFailed == 0 {
+			b.WriteString("No action runs failed.\n\n")
+		} else {
+			fmt.Fprintf(&b, "%d of %d action runs failed.\n\n", stats.RunsFailed, stats.RunsTotal)
+		}
+		writeRecommendations(&b, stats.Health)
+
+	case ReportDailyHealth:
+		b.WriteString("## At a Glance\n\n| | |\n|---|---|\n")
+		fmt.Fprintf(&b, "| Commits | %d by %d contributors |\n", len(stats.Commits), len(stats.Contributors))
+		fmt.Fprintf(&b, "| Pull requests | %d opened, %d merged, %d open over %d days |\n", len(stats.PRsOpened), len(stats.PRsMerged), len(stats.StalePRs), stalePRDays)
+		fmt.Fprintf(&b, "| Issues | %d opened, %d closed, %d open |\n", len(stats.IssuesOpened), len(stats.IssuesClosed), stats.OpenIssues)
+		fmt.Fprintf(&b, "| Action runs | %d, %d failed |\n", stats.RunsTotal, stats.RunsFailed)
+		fmt.Fprintf(&b, "| Health | %d (%s) |\n\n", stats.Health.Score, stats.Health.Grade)
+		writePRSection(&b, "Merged Pull Requests", stats.PRsMerged, "No pull requests were merged.")
+		writePRSection(&b, "New Pull Requests", stats.PRsOpened, "No pull requests were opened.")
+		writeIssueSection(&b, "New Issues", stats.IssuesOpened, "No issues were opened.")
+		writeIssueSection(&b, "Closed Issues", stats.IssuesClosed, "No issues were closed.")
+		writeIssueSection(&b, "Critical and High Priority Issues", stats.CriticalIssues, "No critical or high priority issues are open.")
+		writeRecommendations(&b, stats.Health)
+	}
+
+	return strings.TrimRight(b.String(), "\n") + "\n"
+}
+
+func writePRSection(b *strings.Builder, heading string, prs []*PullRequest, empty string) {
+	fmt.Fprintf(b, "## %s\n\n", heading)
+	if len(prs) == 0 {
+		fmt.Fprintf(b, "%s\n\n", empty)
+		return
+	}
+	for _, pr := range prs {
+		fmt.Fprintf(b, "- %s (%s → %s, opened %s)\n", pr.Title, pr.CompareBranch, pr.BaseBranch, pr.CreatedAt.Format("Jan 2"))
+	}
+	b.WriteString("\n")
+}
+
+func writeIssueSection(b *strings.Builder, heading string, issues []*Issue, empty string) {
+	fmt.Fprintf(b, "## %s\n\n", heading)
+	if len(issues) == 0 {
+		fmt.Fprintf(b, "%s\n\n", empty)
+		return
+	}
+	for _, issue := range issues {
+		fmt.Fprintf(b, "- %s (%s, %s priority)\n", issue.Title, issue.Status, priorityName(issue.Priority))
+	}
+	b.WriteString("\n")
+}
+
+func writeRecommendations(b *strings.Builder, health *RepoHealth) {
+	recommendations := health.Recommendations()
+	if len(recommendations) == 0 {
+		return
+	}
+	b.WriteString("## Health Recommendations\n\n")
+	for _, metric := range recommendations {
+		fmt.Fprintf(b, "- **%s** (%s): %s\n", metric.Name, metric.Value, metric.Recommendation)
+	}
+	b.WriteString("\n")
+}
+
+func firstLine(message string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(message), "\n")
+	return line
+}
+
+func priorityName(priority IssuePriority) string {
+	switch {
+	case priority <= PriorityCritical:
+		return "critical"
+	case priority <= PriorityHigh:
+		return "high"
+	case priority <= PriorityMedium:
+		return "medium"
+	default:
+		return "low"
+	}
+}
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/The-Skyscape/devtools/pkg/testutils"
+)
+
+func TestDeleteRepository(t *testing.T) {
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+	t.Setenv("DATA_DIR", t.TempDir())
+
+	user := CreateTestUser(t, db, "owner@example.com")
+	repo := createTestRepository(t, "doomed", user.ID)
+	other := createTestRepository(t, "kept", user.ID)
+
+	for _, id := range []string{repo.ID, other.ID} {
+		run, err := WorkflowRuns.Insert(&WorkflowRun{RepoID: id, Workflow: "CI"})
+		testutils.AssertNoError(t, err)
+		job, err := WorkflowJobRuns.Insert(&WorkflowJobRun{RunID: run.ID, Name: "test"})
+		testutils.AssertNoError(t, err)
+		_, err = WorkflowStepRuns.Insert(&WorkflowStepRun{RunID: run.ID, JobRunID: job.ID, Name: "go test"})
+		testutils.AssertNoError(t, err)
+		_, err = CommitStatuses.Insert(&CommitStatus{RepoID: id, CommitSHA: "abc123", Context: "ci/tests"})
+		testutils.AssertNoError(t, err)
+		_, err = Webhooks.Insert(&Webhook{RepoID: id, URL: "https://example.com/hook", Active: true})
+		testutils.AssertNoError(t, err)
+	}
+
+	testutils.AssertNoError(t, DeleteRepository(repo.ID))
+
+	_, err := Repositories.Get(repo.ID)
+	testutils.AssertError(t, err)
+	testutils.AssertEqual(t, 0, WorkflowRuns.Count("WHERE RepoID = ?", repo.ID))
+	testutils.AssertEqual(t, 1, WorkflowRuns.Count(""))
+	testutils.AssertEqual(t, 1, WorkflowJobRuns.Count(""))
+	testutils.AssertEqual(t, 1, WorkflowStepRuns.Count(""))
+	testutils.AssertEqual(t, 0, CommitStatuses.Count("WHERE RepoID = ?", repo.ID))
+	testutils.AssertEqual(t, 0, Webhooks.Count("WHERE RepoID = ?", repo.ID))
+
+	// Other repositories keep their rows
+	testutils.AssertEqual(t, 1, CommitStatuses.Count("WHERE RepoID = ?", other.ID))
+	testutils.AssertEqual(t, 1, Webhooks.Count("WHERE RepoID = ?", other.ID))
+}
@@ -0,0 +1,130 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/The-Skyscape/devtools/pkg/application"
+	"github.com/pkg/errors"
+)
+
+// ResourceHostSource is the source of samples taken of the host itself,
+// other samples are named after their container
+const ResourceHostSource = "host"
+
+// Kinds of source resource samples are taken of
+const (
+	ResourceHost        = "host"
+	ResourceAI          = "ai"
+	ResourceVault       = "vault"
+	ResourceRegistry    = "registry"
+	ResourceCoder       = "coder"
+	ResourceWorker      = "worker"
+	ResourceSandbox     = "sandbox"
+	ResourceEnvironment = "environment"
+	ResourceOther       = "other"
+)
+
+// How long resource samples are kept and when thresholds alert unless
+// the settings say otherwise
+const (
+	DefaultMonitorRetentionDays = 7
+	DefaultMonitorThreshold     = 90
+)
+
+// ResourceSample is one measurement of the host's or a container's
+// resource use. Disk and load are only measured for the host, a
+// container's disk is the size of its writable layer.
+type ResourceSample struct {
+	application.Model
+	Source      string // ResourceHostSource or the container's name
+	Kind        string
+	CPUPercent  float64
+	MemUsed     int64
+	MemPercent  float64
+	DiskUsed    int64
+	DiskPercent float64
+	Load1       float64
+}
+
+// Table returns the database table name
+func (*ResourceSample) Table() string { return "resource_samples" }
+
+// ContainerKind tells what a container is from the name the workspace
+// gave it
+func ContainerKind(name string) string {
+	switch {
+	case name == "skyscape-ollama":
+		return ResourceAI
+	case name == "skyscape-vault":
+		return ResourceVault
+	case name == "skyscape-registry":
+		return ResourceRegistry
+	case name == "skyscape-coder":
+		return ResourceCoder
+	case strings.HasPrefix(name, "skyscape-workflow-"), strings.HasPrefix(name, "action-"):
+		return ResourceWorker
+	case strings.HasPrefix(name, "skyscape-sandbox-"):
+		return ResourceSandbox
+	case strings.HasPrefix(name, "skyscape-"):
+		return ResourceEnvironment
+	default:
+		return ResourceOther
+	}
+}
+
+// RecordResourceSample stores a measurement
+func RecordResourceSample(sample *ResourceSample) error {
+	if sample.Source == "" {
+		return errors.New("resource sample has no source")
+	}
+	if sample.Kind == "" {
+		sample.Kind = ContainerKind(sample.Source)
+	}
+	_, err := ResourceSamples.Insert(sample)
+	return errors.Wrap(err, "failed to record resource sample")
+}
+
+// ResourceHistory returns a source's samples taken since a time, oldest
+// first
+func ResourceHistory(source string, since time.Time) ([]*ResourceSample, error) {
+	return ResourceSamples.Search("WHERE Source = ? AND CreatedAt >= ? ORDER BY CreatedAt", source, since)
+}
+
+// LatestResourceSample returns a source's most recent sample
+func LatestResourceSample(source string) (*ResourceSample, error) {
+	samples, err := ResourceSamples.Search("WHERE Source = ? ORDER BY CreatedAt DESC LIMIT 1", source)
+	if err != nil {
+		return nil, err
+	}
+	if len(samples) == 0 {
+		return nil, errors.New("no samples of " + source)
+	}
+	return samples[0], nil
+}
+
+// PruneResourceSamples deletes samples taken before a time
+func PruneResourceSamples(before time.Time) error {
+	return DB.Query("DELETE FROM resource_samples WHERE CreatedAt < ?", before).Exec()
+}
+
+// MonitorRetention returns how long resource samples are kept
+func (s *Settings) MonitorRetention() time.Duration {
+	days := s.MonitorRetentionDays
+	if days <= 0 {
+		days = DefaultMonitorRetentionDays
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// MonitorThresholds returns the CPU, memory and disk percentages over
+// which resource alerts fire
+func (s *Settings) MonitorThresholds() (cpu, memory, disk float64) {
+	threshold := func(percent int) float64 {
+		if percent <= 0 || percent > 100 {
+			return DefaultMonitorThreshold
+		}
+		return float64(percent)
+	}
+	return threshold(s.MonitorCPUPercent), threshold(s.MonitorMemoryPercent), threshold(s.MonitorDiskPercent)
+}
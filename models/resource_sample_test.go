@@ -0,0 +1,63 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/The-Skyscape/devtools/pkg/testutils"
+)
+
+func TestResourceSamples(t *testing.T) {
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+
+	since := time.Now().Add(-time.Hour)
+
+	t.Run("ContainerKind", func(t *testing.T) {
+		testutils.AssertEqual(t, ResourceAI, ContainerKind("skyscape-ollama"))
+		testutils.AssertEqual(t, ResourceVault, ContainerKind("skyscape-vault"))
+		testutils.AssertEqual(t, ResourceWorker, ContainerKind("skyscape-workflow-job1"))
+		testutils.AssertEqual(t, ResourceWorker, ContainerKind("action-a1-r1"))
+		testutils.AssertEqual(t, ResourceSandbox, ContainerKind("skyscape-sandbox-build"))
+		testutils.AssertEqual(t, ResourceEnvironment, ContainerKind("skyscape-shop-staging"))
+		testutils.AssertEqual(t, ResourceOther, ContainerKind("postgres"))
+	})
+
+	t.Run("Record", func(t *testing.T) {
+		testutils.AssertNoError(t, RecordResourceSample(&ResourceSample{Source: ResourceHostSource, Kind: ResourceHost, CPUPercent: 12, DiskPercent: 40}))
+		testutils.AssertNoError(t, RecordResourceSample(&ResourceSample{Source: ResourceHostSource, Kind: ResourceHost, CPUPercent: 30, DiskPercent: 41}))
+		testutils.AssertNoError(t, RecordResourceSample(&ResourceSample{Source: "skyscape-ollama", CPUPercent: 80, MemUsed: 4 << 30}))
+		testutils.AssertError(t, RecordResourceSample(&ResourceSample{CPUPercent: 1}))
+
+		history, err := ResourceHistory(ResourceHostSource, since)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 2, len(history))
+		testutils.AssertEqual(t, 12.0, history[0].CPUPercent)
+
+		latest, err := LatestResourceSample("skyscape-ollama")
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, ResourceAI, latest.Kind)
+		testutils.AssertEqual(t, int64(4<<30), latest.MemUsed)
+
+		_, err = LatestResourceSample("skyscape-vault")
+		testutils.AssertError(t, err)
+	})
+
+	t.Run("Prune", func(t *testing.T) {
+		testutils.AssertNoError(t, PruneResourceSamples(since))
+		testutils.AssertEqual(t, 3, ResourceSamples.Count(""))
+
+		testutils.AssertNoError(t, PruneResourceSamples(time.Now().Add(time.Minute)))
+		testutils.AssertEqual(t, 0, ResourceSamples.Count(""))
+	})
+
+	t.Run("Settings", func(t *testing.T) {
+		testutils.AssertEqual(t, DefaultMonitorRetentionDays*24*time.Hour, (&Settings{}).MonitorRetention())
+		testutils.AssertEqual(t, 48*time.Hour, (&Settings{MonitorRetentionDays: 2}).MonitorRetention())
+
+		cpu, memory, disk := (&Settings{MonitorCPUPercent: 75, MonitorDiskPercent: 120}).MonitorThresholds()
+		testutils.AssertEqual(t, 75.0, cpu)
+		testutils.AssertEqual(t, float64(DefaultMonitorThreshold), memory)
+		testutils.AssertEqual(t, float64(DefaultMonitorThreshold), disk)
+	})
+}
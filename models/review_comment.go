@@ -0,0 +1,176 @@
+package models
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ReviewThread is an inline comment on a line of a pull request's diff
+// and the replies to it
+type ReviewThread struct {
+	*Comment            // The first comment, which anchors the thread
+	Replies  []*Comment // Oldest first
+	Outdated bool       // The line has changed since the thread started
+}
+
+// Comments returns the thread's first comment followed by its replies
+func (t *ReviewThread) Comments() []*Comment {
+	return append([]*Comment{t.Comment}, t.Replies...)
+}
+
+// CreateReviewComment starts a review thread on a line of a file as it
+// reads at the head of the pull request's branch
+func CreateReviewComment(pr *PullRequest, authorID, body, path string, line int) (*Comment, error) {
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return nil, errors.New("comment is empty")
+	}
+	if path == "" || line <= 0 {
+		return nil, errors.New("file and line are required")
+	}
+
+	head, err := pr.HeadCommit()
+	if err != nil {
+		return nil, err
+	}
+	repo, err := pr.Repository()
+	if err != nil {
+		return nil, errors.New("repository not found")
+	}
+	text, ok := fileLine(repo, head, path, line)
+	if !ok {
+		return nil, errors.Errorf("%s has no line %d", path, line)
+	}
+
+	return Comments.Insert(&Comment{
+		Body:       body,
+		AuthorID:   authorID,
+		RepoID:     pr.RepoID,
+		EntityType: "pr",
+		EntityID:   pr.ID,
+		FilePath:   path,
+		LineNumber: line,
+		CommitSHA:  head,
+		LineText:   text,
+	})
+}
+
+// ReplyToReviewComment adds a reply to the review thread a comment is in
+func ReplyToReviewComment(pr *PullRequest, commentID, authorID, body string) (*Comment, error) {
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return nil, errors.New("reply is empty")
+	}
+	root, err := reviewThreadRoot(pr, commentID)
+	if err != nil {
+		return nil, err
+	}
+
+	return Comments.Insert(&Comment{
+		Body:       body,
+		AuthorID:   authorID,
+		RepoID:     pr.RepoID,
+		EntityType: "pr",
+		EntityID:   pr.ID,
+		FilePath:   root.FilePath,
+		LineNumber: root.LineNumber,
+		CommitSHA:  root.CommitSHA,
+		ParentID:   root.ID,
+	})
+}
+
+// ResolveReviewThread marks the review thread a comment is in as resolved,
+// or reopens it
+func ResolveReviewThread(pr *PullRequest, commentID, userID string, resolved bool) (*Comment, error) {
+	root, err := reviewThreadRoot(pr, commentID)
+	if err != nil {
+		return nil, err
+	}
+	root.Resolved = resolved
+	root.ResolvedBy = ""
+	if resolved {
+		root.ResolvedBy = userID
+	}
+	if err := Comments.Update(root); err != nil {
+		return nil, errors.Wrap(err, "failed to update review thread")
+	}
+	return root, nil
+}
+
+// reviewThreadRoot returns the first comment of the pull request's review
+// thread that a comment is in
+func reviewThreadRoot(pr *PullRequest, commentID string) (*Comment, error) {
+	comment, err := Comments.Get(commentID)
+	if err != nil || comment.EntityType != "pr" || comment.EntityID != pr.ID || comment.FilePath == "" {
+		return nil, errors.New("review comment not found")
+	}
+	if comment.ParentID == "" {
+		return comment, nil
+	}
+	root, err := Comments.Get(comment.ParentID)
+	if err != nil {
+		return nil, errors.New("review thread not found")
+	}
+	return root, nil
+}
+
+// ReviewThreads returns the pull request's inline comments grouped into
+// threads, oldest first. A thread is outdated once a push changes the line
+// it was started on.
+func (pr *PullRequest) ReviewThreads() ([]*ReviewThread, error) {
+	comments, err := Comments.Search("WHERE EntityType = ? AND EntityID = ? AND FilePath != ? ORDER BY CreatedAt ASC", "pr", pr.ID, "")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get review comments")
+	}
+
+	var threads []*ReviewThread
+	byRoot := map[string]*ReviewThread{}
+	for _, comment := range comments {
+		if comment.ParentID == "" && comment.LineNumber > 0 {
+			thread := &ReviewThread{Comment: comment}
+			threads = append(threads, thread)
+			byRoot[comment.ID] = thread
+		}
+	}
+	for _, comment := range comments {
+		if thread, ok := byRoot[comment.ParentID]; ok {
+			thread.Replies = append(thread.Replies, comment)
+		}
+	}
+
+	// Without the branch there's nothing to compare against, as after a merge
+	head, err := pr.HeadCommit()
+	if err != nil {
+		return threads, nil
+	}
+	repo, err := pr.Repository()
+	if err != nil {
+		return threads, nil
+	}
+	for _, thread := range threads {
+		// Comments without a commit, like the AI's, can't be checked
+		if thread.CommitSHA == "" || thread.CommitSHA == head {
+			continue
+		}
+		text, ok := fileLine(repo, head, thread.FilePath, thread.LineNumber)
+		thread.Outdated = !ok || text != thread.LineText
+	}
+	return threads, nil
+}
+
+// fileLine returns a line of a file at a commit, numbered from 1
+func fileLine(repo *Repository, commit, path string, line int) (string, bool) {
+	out, _, err := repo.Git("show", commit+":"+path)
+	if err != nil {
+		return "", false
+	}
+	lines := strings.Split(out.String(), "\n")
+	if strings.HasSuffix(out.String(), "\n") {
+		lines = lines[:len(lines)-1]
+	}
+	if line <= 0 || line > len(lines) {
+		return "", false
+	}
+	return lines[line-1], true
+}
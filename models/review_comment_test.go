@@ -0,0 +1,191 @@
+package models
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/The-Skyscape/devtools/pkg/testutils"
+)
+
+func TestParseFileDiffs(t *testing.T) {
+	diff := strings.Join([]string{
+		"diff --git a/main.go b/main.go",
+		"index 1111111..2222222 100644",
+		"--- a/main.go",
+		"+++ b/main.go",
+		"@@ -1,3 +1,3 @@ package main",
+		" package main",
+		"-// old",
+		"+// new",
+		" func main() {}",
+		"@@ -10,2 +10,3 @@",
+		" a",
+		"+b",
+		" c",
+		"diff --git a/logo.png b/logo.png",
+		"new file mode 100644",
+		"index 0000000..3333333",
+		"Binary files /dev/null and b/logo.png differ",
+		"diff --git a/old.txt b/new.txt",
+		"similarity index 90%",
+		"rename from old.txt",
+		"rename to new.txt",
+		"",
+	}, "\n")
+
+	files := ParseFileDiffs(diff)
+	testutils.AssertEqual(t, 3, len(files))
+
+	t.Run("LineNumbers", func(t *testing.T) {
+		file := files[0]
+		testutils.AssertEqual(t, "main.go", file.Path)
+		testutils.AssertEqual(t, "modified", file.Status)
+		testutils.AssertEqual(t, 2, len(file.Hunks))
+
+		lines := file.Hunks[0].Lines
+		testutils.AssertEqual(t, 4, len(lines))
+		testutils.AssertEqual(t, "del", lines[1].Type)
+		testutils.AssertEqual(t, 2, lines[1].OldLine)
+		testutils.AssertEqual(t, 0, lines[1].NewLine)
+		testutils.AssertEqual(t, "add", lines[2].Type)
+		testutils.AssertEqual(t, 2, lines[2].NewLine)
+		testutils.AssertEqual(t, "// new", lines[2].Text)
+		testutils.AssertEqual(t, 3, lines[3].OldLine)
+		testutils.AssertEqual(t, 3, lines[3].NewLine)
+
+		testutils.AssertEqual(t, 11, file.Hunks[1].Lines[1].NewLine)
+		testutils.AssertEqual(t, 11, file.Hunks[1].Lines[2].OldLine)
+		testutils.AssertEqual(t, 12, file.Hunks[1].Lines[2].NewLine)
+	})
+
+	t.Run("Headers", func(t *testing.T) {
+		testutils.AssertEqual(t, "added", files[1].Status)
+		testutils.AssertTrue(t, files[1].Binary)
+		testutils.AssertEqual(t, "renamed", files[2].Status)
+		testutils.AssertEqual(t, "old.txt", files[2].OldPath)
+		testutils.AssertEqual(t, "new.txt", files[2].Path)
+	})
+}
+
+func TestReviewThreads(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+	t.Setenv("DATA_DIR", t.TempDir())
+
+	repo, err := Repositories.Insert(&Repository{Name: "threads"})
+	testutils.AssertNoError(t, err)
+
+	work := t.TempDir()
+	git := func(args ...string) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = work
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=Test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=Test", "GIT_COMMITTER_EMAIL=test@example.com")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %s", strings.Join(args, " "), out)
+		}
+	}
+	commit := func(content, message string) {
+		os.WriteFile(filepath.Join(work, "file.txt"), []byte(content), 0644)
+		git("commit", "-am", message)
+		git("push", repo.Path(), "feature")
+	}
+	git("init", "--bare", "-b", "main", repo.Path())
+	git("init", "-b", "main")
+	os.WriteFile(filepath.Join(work, "file.txt"), []byte("one\ntwo\nthree\n"), 0644)
+	git("add", "file.txt")
+	git("commit", "-m", "first")
+	git("push", repo.Path(), "main")
+	git("checkout", "-b", "feature")
+	commit("one\n2\nthree\n", "second")
+
+	reviewer := CreateTestUser(t, db, "reviewer@example.com")
+	pr, err := PullRequests.Insert(&PullRequest{
+		Title: "Feature", RepoID: repo.ID, AuthorID: "author",
+		BaseBranch: "main", CompareBranch: "feature", Status: "open",
+	})
+	testutils.AssertNoError(t, err)
+
+	var root *Comment
+	t.Run("StartsThreadOnLine", func(t *testing.T) {
+		_, err := CreateReviewComment(pr, reviewer.ID, "Spell it out", "file.txt", 4)
+		testutils.AssertError(t, err)
+		_, err = CreateReviewComment(pr, reviewer.ID, "Spell it out", "missing.txt", 1)
+		testutils.AssertError(t, err)
+		_, err = CreateReviewComment(pr, reviewer.ID, " ", "file.txt", 2)
+		testutils.AssertError(t, err)
+
+		root, err = CreateReviewComment(pr, reviewer.ID, "Spell it out", "file.txt", 2)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, "2", root.LineText)
+		head, _ := pr.HeadCommit()
+		testutils.AssertEqual(t, head, root.CommitSHA)
+	})
+
+	t.Run("RepliesJoinThread", func(t *testing.T) {
+		reply, err := ReplyToReviewComment(pr, root.ID, "author", "Will do")
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, root.ID, reply.ParentID)
+
+		// Replying to a reply stays in the same thread
+		again, err := ReplyToReviewComment(pr, reply.ID, reviewer.ID, "Thanks")
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, root.ID, again.ParentID)
+		testutils.AssertEqual(t, "file.txt", again.FilePath)
+		testutils.AssertEqual(t, 2, again.LineNumber)
+
+		threads, err := pr.ReviewThreads()
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 1, len(threads))
+		testutils.AssertEqual(t, 2, len(threads[0].Replies))
+		testutils.AssertFalse(t, threads[0].Outdated)
+	})
+
+	t.Run("ResolvesAndReopens", func(t *testing.T) {
+		thread, err := ResolveReviewThread(pr, root.ID, "author", true)
+		testutils.AssertNoError(t, err)
+		testutils.AssertTrue(t, thread.Resolved)
+		testutils.AssertEqual(t, "author", thread.ResolvedBy)
+
+		thread, err = ResolveReviewThread(pr, root.ID, "author", false)
+		testutils.AssertNoError(t, err)
+		testutils.AssertFalse(t, thread.Resolved)
+		testutils.AssertEqual(t, "", thread.ResolvedBy)
+	})
+
+	t.Run("PlacedOnDiffLine", func(t *testing.T) {
+		files, err := pr.FileDiffs()
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 1, len(files))
+		testutils.AssertEqual(t, 0, len(files[0].Threads))
+		line := files[0].line(2)
+		testutils.AssertEqual(t, "add", line.Type)
+		testutils.AssertEqual(t, 1, len(line.Threads))
+	})
+
+	t.Run("OutdatedWhenLineChanges", func(t *testing.T) {
+		// Changing another line keeps the thread current
+		commit("one\n2\n3\n", "third")
+		threads, err := pr.ReviewThreads()
+		testutils.AssertNoError(t, err)
+		testutils.AssertFalse(t, threads[0].Outdated)
+
+		commit("one\ntwo, spelled out\n3\n", "fourth")
+		threads, err = pr.ReviewThreads()
+		testutils.AssertNoError(t, err)
+		testutils.AssertTrue(t, threads[0].Outdated)
+
+		files, err := pr.FileDiffs()
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 1, len(files[0].Threads))
+		testutils.AssertEqual(t, 0, len(files[0].line(2).Threads))
+	})
+}
@@ -0,0 +1,350 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/The-Skyscape/devtools/pkg/application"
+	"github.com/pkg/errors"
+)
+
+// DefaultReviewSLA is how long a review request may wait before the
+// reviewer is reminded, unless the admin sets another limit
+const DefaultReviewSLA = 24 * time.Hour
+
+// ReviewRequest asks one person to review a pull request
+type ReviewRequest struct {
+	application.Model
+	PRID        string
+	RepoID      string
+	ReviewerID  string
+	RequestedBy string
+	Status      string // pending, reviewed, withdrawn
+	ReviewedAt  time.Time
+	RemindedAt  time.Time // Last reminder sent to the reviewer
+	Reminders   int
+}
+
+// Table returns the database table name
+func (*ReviewRequest) Table() string { return "review_requests" }
+
+// Review request status constants
+const (
+	ReviewPending   = "pending"
+	ReviewDone      = "reviewed"
+	ReviewWithdrawn = "withdrawn" // The pull request was merged or closed first
+)
+
+// RequestReview asks a user to review a pull request and notifies them
+func RequestReview(pr *PullRequest, reviewerID, requestedBy string) (*ReviewRequest, error) {
+	if pr.Status != "open" {
+		return nil, errors.New("reviews can only be requested on open pull requests")
+	}
+	if reviewerID == pr.AuthorID {
+		return nil, errors.New("authors can't review their own pull request")
+	}
+	reviewer, err := Users.Get(reviewerID)
+	if err != nil {
+		return nil, errors.New("reviewer not found")
+	}
+
+	pending, err := ReviewRequests.Search("WHERE PRID = ? AND ReviewerID = ? AND Status = ?", pr.ID, reviewerID, ReviewPending)
+	if err == nil && len(pending) > 0 {
+		return nil, errors.Errorf("%s has already been asked to review", reviewer.Name)
+	}
+
+	request, err := ReviewRequests.Insert(&ReviewRequest{
+		PRID:        pr.ID,
+		RepoID:      pr.RepoID,
+		ReviewerID:  reviewerID,
+		RequestedBy: requestedBy,
+		Status:      ReviewPending,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to request review")
+	}
+
+	Notify(reviewerID, "review_requested", "Review requested: "+pr.Title,
+		"You were asked to review this pull request", pr.URL(), pr.RepoID)
+	return request, nil
+}
+
+// GetReviewRequests returns the review requests for a pull request, oldest first
+func GetReviewRequests(prID string) ([]*ReviewRequest, error) {
+	return ReviewRequests.Search("WHERE PRID = ? ORDER BY CreatedAt ASC", prID)
+}
+
+// RecordReview completes a user's pending review requests on a pull request
+func RecordReview(pr *PullRequest, userID string) error {
+	pending, err := ReviewRequests.Search("WHERE PRID = ? AND ReviewerID = ? AND Status = ?", pr.ID, userID, ReviewPending)
+	if err != nil {
+		return err
+	}
+	for _, request := range pending {
+		request.Status = ReviewDone
+		request.ReviewedAt = time.Now()
+		if err := ReviewRequests.Update(request); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WithdrawReviewRequests closes the pending requests on a pull request that
+// was merged or closed, so reviewers stop being reminded about it
+func WithdrawReviewRequests(prID string) error {
+	pending, err := ReviewRequests.Search("WHERE PRID = ? AND Status = ?", prID, ReviewPending)
+	if err != nil {
+		return err
+	}
+	for _, request := range pending {
+		request.Status = ReviewWithdrawn
+		if err := ReviewRequests.Update(request); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PullRequest returns the pull request the review was requested on
+func (r *ReviewRequest) PullRequest() (*PullRequest, error) {
+	return PullRequests.Get(r.PRID)
+}
+
+// IsPending returns true while the reviewer hasn't responded
+func (r *ReviewRequest) IsPending() bool {
+	return r.Status == ReviewPending
+}
+
+// Waiting returns how long the request waited, or has been waiting, for
+// a review
+func (r *ReviewRequest) Waiting() time.Duration {
+	if r.Status == ReviewDone {
+		return r.ReviewedAt.Sub(r.CreatedAt)
+	}
+	return time.Since(r.CreatedAt)
+}
+
+// Overdue returns true for a pending request older than the SLA
+func (r *ReviewRequest) Overdue(sla time.Duration) bool {
+	return r.IsPending() && r.Waiting() > sla
+}
+
+// SendReviewReminders notifies reviewers whose requests have waited longer
+// than the SLA, at most once per SLA period, and returns how many reminders
+// were sent
+func SendReviewReminders(sla time.Duration, now time.Time) (int, error) {
+	pending, err := ReviewRequests.Search("WHERE Status = ? ORDER BY CreatedAt ASC", ReviewPending)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, request := range pending {
+		pr, err := PullRequests.Get(request.PRID)
+		if err != nil || pr.Status != "open" {
+			WithdrawReviewRequests(request.PRID)
+			continue
+		}
+
+		last := request.CreatedAt
+		if request.RemindedAt.After(last) {
+			last = request.RemindedAt
+		}
+		if now.Sub(last) < sla {
+			continue
+		}
+
+		body := fmt.Sprintf("This review has been waiting %s", FormatLatency(now.Sub(request.CreatedAt)))
+		if err := Notify(request.ReviewerID, "review_reminder", "Review reminder: "+pr.Title, body, pr.URL(), pr.RepoID); err != nil {
+			return sent, err
+		}
+		request.RemindedAt = now
+		request.Reminders++
+		if err := ReviewRequests.Update(request); err != nil {
+			return sent, err
+		}
+		sent++
+	}
+	return sent, nil
+}
+
+// LatencyStats summarizes a set of durations
+type LatencyStats struct {
+	Count   int
+	Median  time.Duration
+	Average time.Duration
+}
+
+// newLatencyStats summarizes the durations
+func newLatencyStats(durations []time.Duration) LatencyStats {
+	if len(durations) == 0 {
+		return LatencyStats{}
+	}
+	sorted := slices.Clone(durations)
+	slices.Sort(sorted)
+
+	var total time.Duration
+	for _, d := range sorted {
+		total += d
+	}
+	median := sorted[len(sorted)/2]
+	if len(sorted)%2 == 0 {
+		median = (sorted[len(sorted)/2-1] + sorted[len(sorted)/2]) / 2
+	}
+	return LatencyStats{
+		Count:   len(sorted),
+		Median:  median,
+		Average: total / time.Duration(len(sorted)),
+	}
+}
+
+// ReviewerLatency is one reviewer's share of the review workload
+type ReviewerLatency struct {
+	UserID    string
+	Requested int
+	Reviewed  int
+	Pending   int
+	Overdue   int
+	Response  LatencyStats // Time from request to review
+}
+
+// RepoLatency is review latency for one repository
+type RepoLatency struct {
+	RepoID      string
+	Name        string
+	Opened      int
+	FirstReview LatencyStats
+	Merge       LatencyStats
+}
+
+// ReviewLatencyReport summarizes how quickly pull requests are reviewed and
+// merged across the workspace
+type ReviewLatencyReport struct {
+	Since       time.Time
+	SLA         time.Duration
+	Opened      int
+	FirstReview LatencyStats
+	Merge       LatencyStats
+	WithinSLA   int // Reviewed pull requests whose first review came within the SLA
+	Reviewers   []*ReviewerLatency
+	Repos       []*RepoLatency
+	Overdue     []*ReviewRequest // Pending requests past the SLA, oldest first
+}
+
+// SLAPercent returns the share of reviewed pull requests that were first
+// reviewed within the SLA
+func (r *ReviewLatencyReport) SLAPercent() int {
+	if r.FirstReview.Count == 0 {
+		return 0
+	}
+	return r.WithinSLA * 100 / r.FirstReview.Count
+}
+
+// GetReviewLatencyReport reports review latency for pull requests opened
+// since the given time
+func GetReviewLatencyReport(since time.Time, sla time.Duration) (*ReviewLatencyReport, error) {
+	prs, err := PullRequests.Search("WHERE CreatedAt >= ? ORDER BY CreatedAt ASC", since)
+	if err != nil {
+		return nil, err
+	}
+	requests, err := ReviewRequests.Search("WHERE CreatedAt >= ? ORDER BY CreatedAt ASC", since)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ReviewLatencyReport{Since: since, SLA: sla, Opened: len(prs)}
+
+	var firstReviews, merges []time.Duration
+	repoFirst := map[string][]time.Duration{}
+	repoMerge := map[string][]time.Duration{}
+	repos := map[string]*RepoLatency{}
+	for _, pr := range prs {
+		repo := repos[pr.RepoID]
+		if repo == nil {
+			repo = &RepoLatency{RepoID: pr.RepoID, Name: pr.RepoID}
+			if r, err := Repositories.Get(pr.RepoID); err == nil {
+				repo.Name = r.Name
+			}
+			repos[pr.RepoID] = repo
+		}
+		repo.Opened++
+
+		if d, ok := pr.TimeToFirstReview(); ok {
+			firstReviews = append(firstReviews, d)
+			repoFirst[pr.RepoID] = append(repoFirst[pr.RepoID], d)
+			if d <= sla {
+				report.WithinSLA++
+			}
+		}
+		if d, ok := pr.TimeToMerge(); ok {
+			merges = append(merges, d)
+			repoMerge[pr.RepoID] = append(repoMerge[pr.RepoID], d)
+		}
+	}
+	report.FirstReview = newLatencyStats(firstReviews)
+	report.Merge = newLatencyStats(merges)
+
+	for id, repo := range repos {
+		repo.FirstReview = newLatencyStats(repoFirst[id])
+		repo.Merge = newLatencyStats(repoMerge[id])
+		report.Repos = append(report.Repos, repo)
+	}
+	sort.Slice(report.Repos, func(i, j int) bool { return report.Repos[i].Opened > report.Repos[j].Opened })
+
+	reviewers := map[string]*ReviewerLatency{}
+	responses := map[string][]time.Duration{}
+	for _, request := range requests {
+		reviewer := reviewers[request.ReviewerID]
+		if reviewer == nil {
+			reviewer = &ReviewerLatency{UserID: request.ReviewerID}
+			reviewers[request.ReviewerID] = reviewer
+		}
+		reviewer.Requested++
+
+		switch {
+		case request.Status == ReviewDone:
+			reviewer.Reviewed++
+			responses[request.ReviewerID] = append(responses[request.ReviewerID], request.Waiting())
+		case !request.IsPending():
+		case request.Waiting() > sla:
+			reviewer.Pending++
+			reviewer.Overdue++
+			report.Overdue = append(report.Overdue, request)
+		default:
+			reviewer.Pending++
+		}
+	}
+	for id, reviewer := range reviewers {
+		reviewer.Response = newLatencyStats(responses[id])
+		report.Reviewers = append(report.Reviewers, reviewer)
+	}
+	sort.Slice(report.Reviewers, func(i, j int) bool {
+		if report.Reviewers[i].Overdue != report.Reviewers[j].Overdue {
+			return report.Reviewers[i].Overdue > report.Reviewers[j].Overdue
+		}
+		return report.Reviewers[i].Requested > report.Reviewers[j].Requested
+	})
+
+	return report, nil
+}
+
+// FormatLatency formats a duration in the largest sensible unit, such as
+// "45m", "6h" or "3d 4h"
+func FormatLatency(d time.Duration) string {
+	switch {
+	case d <= 0:
+		return "-"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	}
+	days := int(d.Hours()) / 24
+	if hours := int(d.Hours()) % 24; hours > 0 {
+		return fmt.Sprintf("%dd %dh", days, hours)
+	}
+	return fmt.Sprintf("%dd", days)
+}
@@ -0,0 +1,118 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/The-Skyscape/devtools/pkg/testutils"
+)
+
+func TestReviewRequests(t *testing.T) {
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+
+	author := CreateTestUser(t, db, "author@example.com")
+	reviewer := CreateTestUser(t, db, "reviewer@example.com")
+	repo := createTestRepository(t, "billing", author.ID)
+
+	pr, err := PullRequests.Insert(&PullRequest{
+		Title:         "Round invoice totals",
+		RepoID:        repo.ID,
+		AuthorID:      author.ID,
+		BaseBranch:    "main",
+		CompareBranch: "fix-rounding",
+		Status:        "open",
+	})
+	testutils.AssertNoError(t, err)
+
+	t.Run("RequestReview", func(t *testing.T) {
+		_, err := RequestReview(pr, author.ID, author.ID)
+		testutils.AssertError(t, err)
+
+		request, err := RequestReview(pr, reviewer.ID, author.ID)
+		testutils.AssertNoError(t, err)
+		testutils.AssertTrue(t, request.IsPending())
+
+		_, err = RequestReview(pr, reviewer.ID, author.ID)
+		testutils.AssertError(t, err)
+
+		testutils.AssertEqual(t, 1, CountUnreadNotifications(reviewer.ID))
+	})
+
+	t.Run("Reminders", func(t *testing.T) {
+		sla := 24 * time.Hour
+		now := time.Now()
+
+		sent, err := SendReviewReminders(sla, now)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 0, sent)
+
+		sent, err = SendReviewReminders(sla, now.Add(25*time.Hour))
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 1, sent)
+
+		// Not reminded again until another SLA period passes
+		sent, err = SendReviewReminders(sla, now.Add(30*time.Hour))
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 0, sent)
+
+		sent, err = SendReviewReminders(sla, now.Add(50*time.Hour))
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 1, sent)
+
+		testutils.AssertEqual(t, 3, CountUnreadNotifications(reviewer.ID))
+	})
+
+	t.Run("RecordReview", func(t *testing.T) {
+		_, err := CreatePRComment(pr.ID, repo.ID, reviewer.ID, "Looks right, one nit")
+		testutils.AssertNoError(t, err)
+		testutils.AssertNoError(t, RecordReview(pr, reviewer.ID))
+
+		requests, err := GetReviewRequests(pr.ID)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 1, len(requests))
+		testutils.AssertEqual(t, ReviewDone, requests[0].Status)
+
+		_, reviewed := pr.TimeToFirstReview()
+		testutils.AssertTrue(t, reviewed)
+
+		sent, err := SendReviewReminders(time.Hour, time.Now().Add(72*time.Hour))
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 0, sent)
+	})
+
+	t.Run("Report", func(t *testing.T) {
+		report, err := GetReviewLatencyReport(time.Now().Add(-time.Hour), DefaultReviewSLA)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 1, report.Opened)
+		testutils.AssertEqual(t, 1, report.FirstReview.Count)
+		testutils.AssertEqual(t, 100, report.SLAPercent())
+		testutils.AssertEqual(t, 0, report.Merge.Count)
+		testutils.AssertEqual(t, 1, len(report.Reviewers))
+		testutils.AssertEqual(t, 1, report.Reviewers[0].Reviewed)
+		testutils.AssertEqual(t, "billing", report.Repos[0].Name)
+	})
+
+	t.Run("Notifications", func(t *testing.T) {
+		notifications, err := GetNotifications(reviewer.ID, 10)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 3, len(notifications))
+
+		_, err = MarkNotificationRead(notifications[0].ID, author.ID)
+		testutils.AssertError(t, err)
+
+		_, err = MarkNotificationRead(notifications[0].ID, reviewer.ID)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 2, CountUnreadNotifications(reviewer.ID))
+
+		testutils.AssertNoError(t, MarkAllNotificationsRead(reviewer.ID))
+		testutils.AssertEqual(t, 0, CountUnreadNotifications(reviewer.ID))
+	})
+}
+
+func TestFormatLatency(t *testing.T) {
+	testutils.AssertEqual(t, "45m", FormatLatency(45*time.Minute))
+	testutils.AssertEqual(t, "6h", FormatLatency(6*time.Hour+10*time.Minute))
+	testutils.AssertEqual(t, "3d 4h", FormatLatency(76*time.Hour))
+	testutils.AssertEqual(t, "2d", FormatLatency(48*time.Hour))
+}
@@ -0,0 +1,167 @@
+package models
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/The-Skyscape/devtools/pkg/application"
+	"github.com/pkg/errors"
+)
+
+// Default sandbox limits, applied to repositories without a saved policy
+const (
+	DefaultSandboxCPUMillicores = 2000
+	DefaultSandboxMemoryMB      = 2048
+	DefaultSandboxTimeoutSecs   = 300
+)
+
+// validBinaryName keeps allowlist entries safe to write into the sandbox script
+var validBinaryName = regexp.MustCompile(`^[A-Za-z0-9._+-]+$`)
+
+// SandboxPolicy limits what commands run on behalf of the AI may do inside a
+// repository's sandbox. It is stored with the repository ID as its ID.
+type SandboxPolicy struct {
+	application.Model
+	RepoID          string
+	CPUMillicores   int    // 1000 is one core, 0 is unlimited
+	MemoryMB        int    // 0 is unlimited
+	MaxTimeoutSecs  int    // Longest a single command may run
+	NetworkEnabled  bool   // Containers get no network at all when false
+	AllowedBinaries string // One program per line, empty allows any program
+	WritablePaths   string // One repository path per line, empty allows writing anywhere
+	UpdatedBy       string
+}
+
+// Table returns the database table name
+func (*SandboxPolicy) Table() string { return "sandbox_policies" }
+
+// DefaultSandboxPolicy returns the policy used until an admin configures one
+func DefaultSandboxPolicy(repoID string) *SandboxPolicy {
+	return &SandboxPolicy{
+		Model:          DB.NewModel(repoID),
+		RepoID:         repoID,
+		CPUMillicores:  DefaultSandboxCPUMillicores,
+		MemoryMB:       DefaultSandboxMemoryMB,
+		MaxTimeoutSecs: DefaultSandboxTimeoutSecs,
+		NetworkEnabled: true,
+	}
+}
+
+// GetSandboxPolicy returns the repository's sandbox policy, falling back to
+// the defaults when none has been saved
+func GetSandboxPolicy(repoID string) *SandboxPolicy {
+	if policy, err := SandboxPolicies.Get(repoID); err == nil {
+		return policy
+	}
+	return DefaultSandboxPolicy(repoID)
+}
+
+// SaveSandboxPolicy validates and stores a repository's sandbox policy
+func SaveSandboxPolicy(policy *SandboxPolicy, userID string) (*SandboxPolicy, error) {
+	if policy.CPUMillicores < 0 || policy.MemoryMB < 0 {
+		return nil, errors.New("resource limits cannot be negative")
+	}
+	if policy.MemoryMB > 0 && policy.MemoryMB < 64 {
+		return nil, errors.New("memory limit must be at least 64 MB")
+	}
+	if policy.MaxTimeoutSecs < 1 || policy.MaxTimeoutSecs > 300 {
+		return nil, errors.New("time limit must be between 1 and 300 seconds")
+	}
+	for _, b := range policy.BinaryList() {
+		if !validBinaryName.MatchString(b) {
+			return nil, errors.Errorf("%q is not a valid program name", b)
+		}
+	}
+	for _, p := range policy.WritablePathList() {
+		if p == ".." || strings.HasPrefix(p, "../") {
+			return nil, errors.Errorf("writable path %q is outside the repository", p)
+		}
+	}
+
+	policy.AllowedBinaries = strings.Join(policy.BinaryList(), "\n")
+	policy.WritablePaths = strings.Join(policy.WritablePathList(), "\n")
+	policy.UpdatedBy = userID
+
+	if _, err := SandboxPolicies.Get(policy.ID); err != nil {
+		return SandboxPolicies.Insert(policy)
+	}
+	policy.UpdatedAt = time.Now()
+	return policy, SandboxPolicies.Update(policy)
+}
+
+// BinaryList returns the allowed program names, empty when any is allowed
+func (p *SandboxPolicy) BinaryList() []string {
+	var binaries []string
+	for _, b := range splitPolicyList(p.AllowedBinaries) {
+		binaries = append(binaries, path.Base(b))
+	}
+	return binaries
+}
+
+// WritablePathList returns the cleaned repository paths commands may modify,
+// empty when the whole repository is writable
+func (p *SandboxPolicy) WritablePathList() []string {
+	var paths []string
+	for _, w := range splitPolicyList(p.WritablePaths) {
+		w = path.Clean(strings.TrimPrefix(w, "/"))
+		if w == "." {
+			return nil // The repository root makes every path writable
+		}
+		paths = append(paths, w)
+	}
+	return paths
+}
+
+// AllowsBinary reports whether the policy lets commands run the named program
+func (p *SandboxPolicy) AllowsBinary(name string) bool {
+	binaries := p.BinaryList()
+	if len(binaries) == 0 {
+		return true
+	}
+	name = path.Base(name)
+	for _, b := range binaries {
+		if b == name {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsWrite reports whether a repository-relative path may be modified
+func (p *SandboxPolicy) AllowsWrite(file string) bool {
+	paths := p.WritablePathList()
+	if len(paths) == 0 {
+		return true
+	}
+	file = path.Clean(strings.TrimPrefix(file, "/"))
+	for _, w := range paths {
+		if file == w || strings.HasPrefix(file, w+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// CPUs returns the CPU limit in cores for display and docker, e.g. "1.5"
+func (p *SandboxPolicy) CPUs() string {
+	if p.CPUMillicores == 0 {
+		return ""
+	}
+	return strings.TrimSuffix(strings.TrimRight(fmt.Sprintf("%.3f", float64(p.CPUMillicores)/1000), "0"), ".")
+}
+
+// splitPolicyList splits a newline or comma separated setting into entries
+func splitPolicyList(value string) []string {
+	var entries []string
+	for _, entry := range strings.FieldsFunc(value, func(r rune) bool {
+		return r == '\n' || r == '\r' || r == ','
+	}) {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
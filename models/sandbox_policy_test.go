@@ -0,0 +1,59 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/The-Skyscape/devtools/pkg/testutils"
+)
+
+func TestSandboxPolicy(t *testing.T) {
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+
+	t.Run("DefaultsWithoutSavedPolicy", func(t *testing.T) {
+		policy := GetSandboxPolicy("sandbox-repo")
+		testutils.AssertEqual(t, DefaultSandboxTimeoutSecs, policy.MaxTimeoutSecs)
+		testutils.AssertTrue(t, policy.NetworkEnabled)
+		testutils.AssertTrue(t, policy.AllowsBinary("anything"))
+		testutils.AssertTrue(t, policy.AllowsWrite("any/file.go"))
+	})
+
+	t.Run("SaveAndLoad", func(t *testing.T) {
+		policy := GetSandboxPolicy("sandbox-repo")
+		policy.CPUMillicores = 1500
+		policy.NetworkEnabled = false
+		policy.AllowedBinaries = "go\n/usr/bin/git, make\n\n"
+		policy.WritablePaths = "/src/\nbuild"
+
+		_, err := SaveSandboxPolicy(policy, "admin")
+		testutils.AssertNoError(t, err)
+
+		saved := GetSandboxPolicy("sandbox-repo")
+		testutils.AssertFalse(t, saved.NetworkEnabled)
+		testutils.AssertEqual(t, "1.5", saved.CPUs())
+		testutils.AssertEqual(t, "go\ngit\nmake", saved.AllowedBinaries)
+		testutils.AssertTrue(t, saved.AllowsBinary("/usr/local/go/bin/go"))
+		testutils.AssertFalse(t, saved.AllowsBinary("curl"))
+		testutils.AssertTrue(t, saved.AllowsWrite("src/main.go"))
+		testutils.AssertTrue(t, saved.AllowsWrite("build"))
+		testutils.AssertFalse(t, saved.AllowsWrite("srcs/main.go"))
+		testutils.AssertFalse(t, saved.AllowsWrite("README.md"))
+	})
+
+	t.Run("RejectsInvalidPolicies", func(t *testing.T) {
+		policy := DefaultSandboxPolicy("sandbox-invalid")
+		policy.MaxTimeoutSecs = 0
+		_, err := SaveSandboxPolicy(policy, "admin")
+		testutils.AssertError(t, err)
+
+		policy = DefaultSandboxPolicy("sandbox-invalid")
+		policy.AllowedBinaries = "go; rm -rf /"
+		_, err = SaveSandboxPolicy(policy, "admin")
+		testutils.AssertError(t, err)
+
+		policy = DefaultSandboxPolicy("sandbox-invalid")
+		policy.WritablePaths = "src/../../etc"
+		_, err = SaveSandboxPolicy(policy, "admin")
+		testutils.AssertError(t, err)
+	})
+}
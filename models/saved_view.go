@@ -0,0 +1,224 @@
+package models
+
+import (
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/The-Skyscape/devtools/pkg/application"
+	"github.com/pkg/errors"
+)
+
+// Lists a saved view can filter
+const (
+	ViewIssues       = "issues"
+	ViewPullRequests = "prs"
+)
+
+// SavedView is a named set of filters for the issues or pull requests of a
+// repository, or of every repository when it has none
+type SavedView struct {
+	application.Model
+	UserID string
+	RepoID string // Empty for views of every repository
+	Kind   string // ViewIssues or ViewPullRequests
+	Name   string
+	Query  string // Filters as a URL query string
+	Pinned bool   // Listed in the sidebar of the list
+	Shared bool   // Visible to the whole team
+}
+
+// Table returns the database table name
+func (*SavedView) Table() string { return "saved_views" }
+
+// ListFilters are the query parameters each list understands and a view
+// keeps. Assignee and reviewer take a user ID, "me" or "none".
+var ListFilters = map[string][]string{
+	ViewIssues:       {"search", "includeClosed", "unread", "label", "assignee", "sort"},
+	ViewPullRequests: {"search", "includeClosed", "unread", "reviewer", "sort"},
+}
+
+// ListSorts are how lists can be ordered, newest first by default
+var ListSorts = map[string]string{
+	"newest":  "CreatedAt DESC",
+	"oldest":  "CreatedAt ASC",
+	"updated": "UpdatedAt DESC",
+}
+
+// CreateSavedView saves a user's filters for a list under a name. Views
+// start pinned to the list's sidebar.
+func CreateSavedView(userID, repoID, kind, name string, filters url.Values, shared bool) (*SavedView, error) {
+	if _, ok := ListFilters[kind]; !ok {
+		return nil, errors.Errorf("invalid list %q", kind)
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, errors.New("view name is required")
+	}
+	if repoID != "" {
+		if _, err := Repositories.Get(repoID); err != nil {
+			return nil, errors.New("repository not found")
+		}
+	}
+	if SavedViews.Count("WHERE UserID = ? AND RepoID = ? AND Kind = ? AND Name = ?", userID, repoID, kind, name) > 0 {
+		return nil, errors.Errorf("you already have a view named %s", name)
+	}
+
+	view, err := SavedViews.Insert(&SavedView{
+		Model:  DB.NewModel(""),
+		UserID: userID,
+		RepoID: repoID,
+		Kind:   kind,
+		Name:   name,
+		Query:  ViewQuery(kind, filters),
+		Pinned: true,
+		Shared: shared,
+	})
+	return view, errors.Wrap(err, "failed to save view")
+}
+
+// GetSavedViews returns the views of a repository's list a user can see:
+// their own and the ones shared with the team, for the repository or for
+// every repository
+func GetSavedViews(userID, repoID, kind string) ([]*SavedView, error) {
+	views, err := SavedViews.Search("WHERE Kind = ? ORDER BY Name", kind)
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(views, func(v *SavedView) bool {
+		return (v.RepoID != "" && v.RepoID != repoID) || !v.VisibleTo(userID)
+	}), nil
+}
+
+// VisibleTo returns true if the user saved the view or it is shared
+func (v *SavedView) VisibleTo(userID string) bool {
+	return v.Shared || (userID != "" && v.UserID == userID)
+}
+
+// Edit renames the view, pins it to or unpins it from the sidebar, or
+// changes who can see it
+func (v *SavedView) Edit(name string, pinned, shared bool) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return errors.New("view name is required")
+	}
+	v.Name = name
+	v.Pinned = pinned
+	v.Shared = shared
+	return SavedViews.Update(v)
+}
+
+// Filters returns the view's filters
+func (v *SavedView) Filters() url.Values {
+	filters, err := url.ParseQuery(v.Query)
+	if err != nil {
+		return url.Values{}
+	}
+	return filters
+}
+
+// DeleteSavedView removes a view
+func DeleteSavedView(v *SavedView) error {
+	return SavedViews.Delete(v)
+}
+
+// DeleteRepoSavedViews removes the views of a repository
+func DeleteRepoSavedViews(repoID string) {
+	DB.Query("DELETE FROM saved_views WHERE RepoID = ?", repoID).Exec()
+}
+
+// ViewQuery encodes the filters a list understands as a query string,
+// leaving out empty ones
+func ViewQuery(kind string, filters url.Values) string {
+	query := url.Values{}
+	for _, key := range ListFilters[kind] {
+		if value := strings.TrimSpace(filters.Get(key)); value != "" && value != "false" {
+			query.Set(key, value)
+		}
+	}
+	return query.Encode()
+}
+
+// ApplyView returns the filters of a list request. When it names a saved
+// view the user can see, the view's filters replace the request's.
+func ApplyView(query url.Values, userID string) url.Values {
+	viewID := query.Get("view")
+	if viewID == "" {
+		return query
+	}
+	view, err := SavedViews.Get(viewID)
+	if err != nil || !view.VisibleTo(userID) {
+		return query
+	}
+	return view.Filters()
+}
+
+// ListCondition returns the WHERE and ORDER BY clauses listing a
+// repository's issues or pull requests with filters. The user is who "me"
+// and unread refer to.
+func ListCondition(kind, repoID, userID string, filters url.Values) (string, []any) {
+	table, entityType := "issues", ReadIssue
+	if kind == ViewPullRequests {
+		table, entityType = "pull_requests", ReadPullRequest
+	}
+
+	condition := "WHERE RepoID = ?"
+	args := []any{repoID}
+
+	if filters.Get("includeClosed") != "true" {
+		condition += " AND Status = ?"
+		args = append(args, "open")
+	}
+
+	if search := strings.TrimSpace(filters.Get("search")); search != "" {
+		condition += " AND (Title LIKE ? OR Body LIKE ?)"
+		pattern := "%" + search + "%"
+		args = append(args, pattern, pattern)
+	}
+
+	if userID != "" && filters.Get("unread") == "true" {
+		unread, unreadArgs := UnreadCondition(table, entityType, userID)
+		condition += " AND " + unread
+		args = append(args, unreadArgs...)
+	}
+
+	if kind == ViewIssues {
+		if label := strings.TrimSpace(filters.Get("label")); label != "" {
+			condition += ` AND ID IN (SELECT l.IssueID FROM issue_labels l
+				JOIN tag_definitions t ON t.ID = l.TagID WHERE LOWER(t.Name) = LOWER(?))`
+			args = append(args, label)
+		}
+
+		switch assignee := filters.Get("assignee"); assignee {
+		case "":
+		case "none":
+			condition += " AND AssigneeID = '' AND ID NOT IN (SELECT IssueID FROM issue_assignees)"
+		default:
+			if assignee == "me" {
+				assignee = userID
+			}
+			condition += " AND (AssigneeID = ? OR ID IN (SELECT IssueID FROM issue_assignees WHERE UserID = ?))"
+			args = append(args, assignee, assignee)
+		}
+	}
+
+	if kind == ViewPullRequests {
+		switch reviewer := filters.Get("reviewer"); reviewer {
+		case "":
+		case "none":
+			condition += " AND ID NOT IN (SELECT PRID FROM review_requests)"
+		default:
+			if reviewer == "me" {
+				reviewer = userID
+			}
+			condition += " AND ID IN (SELECT PRID FROM review_requests WHERE ReviewerID = ?)"
+			args = append(args, reviewer)
+		}
+	}
+
+	order, ok := ListSorts[filters.Get("sort")]
+	if !ok {
+		order = ListSorts["newest"]
+	}
+	return condition + " ORDER BY " + order, args
+}
@@ -0,0 +1,97 @@
+package models
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/The-Skyscape/devtools/pkg/testutils"
+)
+
+func TestSavedViews(t *testing.T) {
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+
+	user := CreateTestUser(t, db, "views@example.com")
+	teammate := CreateTestUser(t, db, "teammate@example.com")
+	repo := createTestRepository(t, "views-repo", user.ID)
+	other := createTestRepository(t, "views-other", user.ID)
+
+	t.Run("CreateSavedView", func(t *testing.T) {
+		filters := url.Values{"label": {"bug"}, "assignee": {"me"}, "unread": {"false"}, "page": {"2"}}
+		view, err := CreateSavedView(user.ID, repo.ID, ViewIssues, " My bugs ", filters, false)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, "My bugs", view.Name)
+		testutils.AssertEqual(t, "assignee=me&label=bug", view.Query)
+		testutils.AssertTrue(t, view.Pinned)
+
+		_, err = CreateSavedView(user.ID, repo.ID, ViewIssues, "My bugs", filters, false)
+		testutils.AssertError(t, err)
+		_, err = CreateSavedView(user.ID, repo.ID, "commits", "Recent", filters, false)
+		testutils.AssertError(t, err)
+		_, err = CreateSavedView(user.ID, repo.ID, ViewIssues, " ", filters, false)
+		testutils.AssertError(t, err)
+		_, err = CreateSavedView(user.ID, "missing", ViewIssues, "Elsewhere", filters, false)
+		testutils.AssertError(t, err)
+	})
+
+	t.Run("GetSavedViews", func(t *testing.T) {
+		_, err := CreateSavedView(user.ID, "", ViewIssues, "Everything open", url.Values{}, true)
+		testutils.AssertNoError(t, err)
+		_, err = CreateSavedView(user.ID, other.ID, ViewIssues, "Other repo", url.Values{}, true)
+		testutils.AssertNoError(t, err)
+		_, err = CreateSavedView(user.ID, repo.ID, ViewPullRequests, "To review", url.Values{"reviewer": {"me"}}, false)
+		testutils.AssertNoError(t, err)
+
+		views, err := GetSavedViews(user.ID, repo.ID, ViewIssues)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 2, len(views))
+
+		// Teammates only see shared views
+		views, err = GetSavedViews(teammate.ID, repo.ID, ViewIssues)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 1, len(views))
+		testutils.AssertEqual(t, "Everything open", views[0].Name)
+	})
+
+	t.Run("Edit", func(t *testing.T) {
+		views, err := GetSavedViews(user.ID, repo.ID, ViewPullRequests)
+		testutils.AssertNoError(t, err)
+		view := views[0]
+
+		testutils.AssertError(t, view.Edit("", false, false))
+		testutils.AssertNoError(t, view.Edit("Review queue", false, true))
+		testutils.AssertFalse(t, view.Pinned)
+		testutils.AssertTrue(t, view.VisibleTo(teammate.ID))
+
+		testutils.AssertNoError(t, DeleteSavedView(view))
+		views, err = GetSavedViews(user.ID, repo.ID, ViewPullRequests)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 0, len(views))
+	})
+
+	t.Run("ApplyView", func(t *testing.T) {
+		view, err := CreateSavedView(user.ID, repo.ID, ViewIssues, "Oldest first", url.Values{"sort": {"oldest"}}, false)
+		testutils.AssertNoError(t, err)
+
+		filters := ApplyView(url.Values{"view": {view.ID}, "search": {"crash"}}, user.ID)
+		testutils.AssertEqual(t, "oldest", filters.Get("sort"))
+		testutils.AssertEqual(t, "", filters.Get("search"))
+
+		// Private views don't apply for other users
+		filters = ApplyView(url.Values{"view": {view.ID}, "search": {"crash"}}, teammate.ID)
+		testutils.AssertEqual(t, "crash", filters.Get("search"))
+	})
+
+	t.Run("ListCondition", func(t *testing.T) {
+		condition, args := ListCondition(ViewIssues, repo.ID, user.ID, url.Values{"assignee": {"me"}, "sort": {"updated"}})
+		testutils.AssertTrue(t, strings.HasSuffix(condition, "ORDER BY UpdatedAt DESC"))
+		testutils.AssertEqual(t, 4, len(args))
+		testutils.AssertEqual(t, user.ID, args[2])
+
+		condition, args = ListCondition(ViewPullRequests, repo.ID, user.ID, url.Values{"includeClosed": {"true"}, "label": {"bug"}})
+		testutils.AssertFalse(t, strings.Contains(condition, "issue_labels"))
+		testutils.AssertTrue(t, strings.HasSuffix(condition, "ORDER BY CreatedAt DESC"))
+		testutils.AssertEqual(t, 1, len(args))
+	})
+}
@@ -0,0 +1,222 @@
+package models
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"workspace/internal/depscan"
+	"workspace/internal/sbom"
+
+	"github.com/The-Skyscape/devtools/pkg/application"
+	"github.com/pkg/errors"
+)
+
+// SBOM is the latest software bill of materials of a repository: the
+// dependencies its manifests declare at a commit, with their licenses.
+// Only the newest of each repository is kept.
+type SBOM struct {
+	application.Model
+	RepoID     string
+	Branch     string
+	CommitSHA  string
+	Manifests  string // Manifest paths read, one per line
+	Components int
+	Flagged    int    // Components whose licenses need attention
+	Unknown    int    // Components without a recognized license
+	Errors     string // Manifests that couldn't be read and failed license lookups, one per line
+}
+
+// Table returns the database table name
+func (*SBOM) Table() string { return "sboms" }
+
+// SBOMComponent is a package version in a repository's bill of materials
+type SBOMComponent struct {
+	application.Model
+	RepoID    string
+	SBOMID    string
+	Ecosystem string
+	Name      string
+	Version   string
+	Manifest  string // The first manifest it was found in
+	Indirect  bool
+	Dev       bool
+	License   string // SPDX expression, or "" when it isn't known
+	Category  string // sbom.Permissive, sbom.WeakCopyleft, sbom.StrongCopyleft or sbom.Unknown
+	Flag      string // Why the license needs attention, if it does
+}
+
+// Table returns the database table name
+func (*SBOMComponent) Table() string { return "sbom_components" }
+
+// LicenseCount is how many of a bill of materials' components have a
+// license
+type LicenseCount struct {
+	License  string
+	Category string
+	Count    int
+}
+
+// RecordSBOM saves a repository's bill of materials, replacing its
+// previous one
+func RecordSBOM(bom *SBOM, components []sbom.Component, errs []string) (*SBOM, error) {
+	bom.Components = len(components)
+	bom.Flagged, bom.Unknown = 0, 0
+	for _, component := range components {
+		if component.Flag() != "" {
+			bom.Flagged++
+		}
+		if component.Category() == sbom.Unknown {
+			bom.Unknown++
+		}
+	}
+	bom.Errors = strings.Join(errs, "\n")
+
+	DeleteRepoSBOMs(bom.RepoID)
+	bom, err := SBOMs.Insert(bom)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to save bill of materials")
+	}
+	for _, component := range components {
+		if _, err := SBOMComponents.Insert(&SBOMComponent{
+			RepoID:    bom.RepoID,
+			SBOMID:    bom.ID,
+			Ecosystem: component.Ecosystem,
+			Name:      component.Name,
+			Version:   component.Version,
+			Manifest:  component.Manifest,
+			Indirect:  component.Indirect,
+			Dev:       component.Dev,
+			License:   component.License,
+			Category:  component.Category(),
+			Flag:      component.Flag(),
+		}); err != nil {
+			return nil, errors.Wrap(err, "failed to save bill of materials component")
+		}
+	}
+	return bom, nil
+}
+
+// LatestSBOM returns the repository's bill of materials, or nil when none
+// has been generated
+func LatestSBOM(repoID string) *SBOM {
+	boms, err := SBOMs.Search("WHERE RepoID = ? ORDER BY CreatedAt DESC LIMIT 1", repoID)
+	if err != nil || len(boms) == 0 {
+		return nil
+	}
+	return boms[0]
+}
+
+// ComponentList returns the bill of materials' components, those whose
+// licenses need attention first
+func (s *SBOM) ComponentList() ([]*SBOMComponent, error) {
+	return SBOMComponents.Search("WHERE SBOMID = ? ORDER BY Flag = '', Ecosystem COLLATE NOCASE, Name, Version", s.ID)
+}
+
+// FlaggedComponents returns the components whose licenses need attention
+func (s *SBOM) FlaggedComponents() ([]*SBOMComponent, error) {
+	return SBOMComponents.Search("WHERE SBOMID = ? AND Flag != '' ORDER BY Ecosystem COLLATE NOCASE, Name, Version", s.ID)
+}
+
+// Licenses counts the components under each license, most used first
+func (s *SBOM) Licenses() ([]LicenseCount, error) {
+	components, err := SBOMComponents.Search("WHERE SBOMID = ?", s.ID)
+	if err != nil {
+		return nil, err
+	}
+	counts := map[string]*LicenseCount{}
+	var licenses []*LicenseCount
+	for _, component := range components {
+		count, ok := counts[component.License]
+		if !ok {
+			count = &LicenseCount{License: component.License, Category: component.Category}
+			counts[component.License] = count
+			licenses = append(licenses, count)
+		}
+		count.Count++
+	}
+	sort.SliceStable(licenses, func(i, j int) bool {
+		if licenses[i].Count != licenses[j].Count {
+			return licenses[i].Count > licenses[j].Count
+		}
+		return licenses[i].License < licenses[j].License
+	})
+
+	result := make([]LicenseCount, len(licenses))
+	for i, count := range licenses {
+		result[i] = *count
+	}
+	return result, nil
+}
+
+// ShortSHA returns the abbreviated commit the bill of materials was
+// generated at
+func (s *SBOM) ShortSHA() string {
+	if len(s.CommitSHA) > 7 {
+		return s.CommitSHA[:7]
+	}
+	return s.CommitSHA
+}
+
+// ManifestList returns the manifest paths that were read
+func (s *SBOM) ManifestList() []string {
+	return splitLines(s.Manifests)
+}
+
+// ErrorList returns what couldn't be read or looked up
+func (s *SBOM) ErrorList() []string {
+	return splitLines(s.Errors)
+}
+
+// CycloneDX exports the bill of materials as CycloneDX JSON
+func (s *SBOM) CycloneDX(repo *Repository) ([]byte, error) {
+	components, err := s.ComponentList()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load bill of materials")
+	}
+	list := make([]sbom.Component, len(components))
+	for i, component := range components {
+		list[i] = component.Component()
+	}
+	return sbom.CycloneDX(sbom.Project{Name: repo.Name, Version: s.CommitSHA, Tool: "workspace"}, list, s.CreatedAt)
+}
+
+// Component returns the package version as the sbom package describes it
+func (c *SBOMComponent) Component() sbom.Component {
+	return sbom.Component{Dependency: depscan.Dependency{
+		Ecosystem: c.Ecosystem,
+		Name:      c.Name,
+		Version:   c.Version,
+		Manifest:  c.Manifest,
+		Indirect:  c.Indirect,
+		Dev:       c.Dev,
+		License:   c.License,
+	}}
+}
+
+// KnownLicenses returns the licenses in the repository's current bill of
+// materials by component key, so generating it again only looks up new
+// package versions
+func KnownLicenses(repoID string) map[string]string {
+	known := map[string]string{}
+	components, err := SBOMComponents.Search("WHERE RepoID = ? AND License != ''", repoID)
+	if err != nil {
+		return known
+	}
+	for _, component := range components {
+		known[component.Component().Key()] = component.License
+	}
+	return known
+}
+
+// SBOMFileName returns the name a repository's CycloneDX bill of materials
+// is downloaded as
+func SBOMFileName(repo *Repository, generated time.Time) string {
+	return repo.ID + "-sbom-" + generated.Format("2006-01-02") + ".cdx.json"
+}
+
+// DeleteRepoSBOMs removes a repository's bill of materials
+func DeleteRepoSBOMs(repoID string) {
+	DB.Query("DELETE FROM sbom_components WHERE RepoID = ?", repoID).Exec()
+	DB.Query("DELETE FROM sboms WHERE RepoID = ?", repoID).Exec()
+}
@@ -0,0 +1,92 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"workspace/internal/depscan"
+	"workspace/internal/sbom"
+
+	"github.com/The-Skyscape/devtools/pkg/testutils"
+)
+
+func TestSBOM(t *testing.T) {
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+
+	components := []sbom.Component{
+		{Dependency: depscan.Dependency{Ecosystem: depscan.EcosystemGo, Name: "github.com/pkg/errors", Version: "v0.9.1", Manifest: "go.mod", License: "BSD-2-Clause"}},
+		{Dependency: depscan.Dependency{Ecosystem: depscan.EcosystemNPM, Name: "express", Version: "4.18.2", Manifest: "package-lock.json", License: "MIT"}},
+		{Dependency: depscan.Dependency{Ecosystem: depscan.EcosystemNPM, Name: "readline", Version: "1.0.0", Manifest: "package-lock.json", License: "GPL-3.0-only"}},
+		{Dependency: depscan.Dependency{Ecosystem: depscan.EcosystemPyPI, Name: "mystery", Version: "0.1", Manifest: "requirements.txt"}},
+	}
+	_, err := RecordSBOM(&SBOM{RepoID: "shop", Branch: "main", CommitSHA: "abc123", Manifests: "go.mod\npackage-lock.json\nrequirements.txt"}, components, []string{"lookup failed"})
+	testutils.AssertNoError(t, err)
+
+	t.Run("Latest", func(t *testing.T) {
+		bom := LatestSBOM("shop")
+		testutils.AssertTrue(t, bom != nil)
+		testutils.AssertEqual(t, 4, bom.Components)
+		testutils.AssertEqual(t, 2, bom.Flagged)
+		testutils.AssertEqual(t, 1, bom.Unknown)
+		testutils.AssertEqual(t, 3, len(bom.ManifestList()))
+		testutils.AssertEqual(t, "lookup failed", bom.ErrorList()[0])
+		testutils.AssertTrue(t, LatestSBOM("other") == nil)
+
+		list, err := bom.ComponentList()
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 4, len(list))
+		testutils.AssertEqual(t, "readline", list[0].Name)
+		testutils.AssertEqual(t, sbom.StrongCopyleft, list[0].Category)
+		testutils.AssertEqual(t, "mystery", list[1].Name)
+		testutils.AssertEqual(t, "", list[2].Flag)
+
+		flagged, err := bom.FlaggedComponents()
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 2, len(flagged))
+	})
+
+	t.Run("Licenses", func(t *testing.T) {
+		licenses, err := LatestSBOM("shop").Licenses()
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 4, len(licenses))
+		testutils.AssertEqual(t, "", licenses[0].License)
+		testutils.AssertEqual(t, sbom.Unknown, licenses[0].Category)
+
+		known := KnownLicenses("shop")
+		testutils.AssertEqual(t, 3, len(known))
+		testutils.AssertEqual(t, "MIT", known["npm:express@4.18.2"])
+	})
+
+	t.Run("CycloneDX", func(t *testing.T) {
+		repo := &Repository{Name: "Shop"}
+		repo.ID = "shop"
+		data, err := LatestSBOM("shop").CycloneDX(repo)
+		testutils.AssertNoError(t, err)
+		var bom struct {
+			Components []struct {
+				PURL string `json:"purl"`
+			} `json:"components"`
+		}
+		testutils.AssertNoError(t, json.Unmarshal(data, &bom))
+		testutils.AssertEqual(t, 4, len(bom.Components))
+		testutils.AssertEqual(t, "shop-sbom-2024-01-02.cdx.json", SBOMFileName(repo, time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)))
+	})
+
+	t.Run("Replace", func(t *testing.T) {
+		_, err := RecordSBOM(&SBOM{RepoID: "shop", Branch: "main", CommitSHA: "def456"}, components[:1], nil)
+		testutils.AssertNoError(t, err)
+		bom := LatestSBOM("shop")
+		testutils.AssertEqual(t, "def456", bom.CommitSHA)
+		testutils.AssertEqual(t, 0, bom.Flagged)
+		list, _ := bom.ComponentList()
+		testutils.AssertEqual(t, 1, len(list))
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		DeleteRepoSBOMs("shop")
+		testutils.AssertTrue(t, LatestSBOM("shop") == nil)
+		testutils.AssertEqual(t, 0, len(KnownLicenses("shop")))
+	})
+}
@@ -0,0 +1,229 @@
+package models
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"workspace/internal/search"
+
+	"github.com/The-Skyscape/devtools/pkg/application"
+	"github.com/pkg/errors"
+)
+
+// SearchDocument is an issue, pull request, comment, commit or file in the
+// search index. Its ID is derived from what it indexes, so indexing it
+// again replaces it.
+type SearchDocument struct {
+	application.Model
+	Kind        string // search.KindIssue, KindPullRequest, KindComment, KindCommit or KindCode
+	RepoID      string
+	EntityID    string // Issue, pull request or comment ID, commit hash or file path
+	ParentID    string // Issue, pull request or commit a comment is on
+	Title       string
+	Body        string
+	AuthorID    string // Empty for commits, whose authors may not have accounts
+	Author      string
+	AuthorEmail string
+	State       string // search.StateOpen, StateClosed or StateMerged for issues and pull requests
+	Language    string // Of files
+	URL         string // Page showing the document, below the host prefix
+}
+
+// Table returns the database table name
+func (*SearchDocument) Table() string { return "search_documents" }
+
+// SearchIndexState tracks how far a repository's search index has got.
+// Its ID is the repository ID.
+type SearchIndexState struct {
+	application.Model
+	RepoID    string
+	CommitSHA string    // Default branch commit whose history and files are indexed
+	SyncedAt  time.Time // Issues, pull requests and comments changed since are indexed next
+}
+
+// Table returns the database table name
+func (*SearchIndexState) Table() string { return "search_index_states" }
+
+// fullTextSearch is true when SQLite was built with FTS5 and the index
+// over search_documents is in place
+var fullTextSearch bool
+
+// setupSearchIndex creates the FTS5 index of search documents' titles and
+// bodies, with triggers keeping it in step with search_documents, and
+// rebuilds it in case rows were renumbered, such as by a VACUUM. Without
+// FTS5, which needs the sqlite_fts5 build tag, searches fall back to LIKE.
+func setupSearchIndex() {
+	statements := []string{
+		`CREATE VIRTUAL TABLE IF NOT EXISTS search_fts USING fts5(Title, Body,
+			content='search_documents', tokenize="unicode61 tokenchars '_'")`,
+		`CREATE TRIGGER IF NOT EXISTS search_documents_fts_insert AFTER INSERT ON search_documents BEGIN
+			INSERT INTO search_fts(rowid, Title, Body) VALUES (new.rowid, new.Title, new.Body);
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS search_documents_fts_delete AFTER DELETE ON search_documents BEGIN
+			INSERT INTO search_fts(search_fts, rowid, Title, Body) VALUES ('delete', old.rowid, old.Title, old.Body);
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS search_documents_fts_update AFTER UPDATE ON search_documents BEGIN
+			INSERT INTO search_fts(search_fts, rowid, Title, Body) VALUES ('delete', old.rowid, old.Title, old.Body);
+			INSERT INTO search_fts(rowid, Title, Body) VALUES (new.rowid, new.Title, new.Body);
+		END`,
+		`INSERT INTO search_fts(search_fts) VALUES ('rebuild')`,
+	}
+	for _, statement := range statements {
+		if err := DB.Query(statement).Exec(); err != nil {
+			log.Printf("Search: Full-text index unavailable, searching with LIKE: %v", err)
+			fullTextSearch = false
+			return
+		}
+	}
+	fullTextSearch = true
+}
+
+// FullTextSearch returns true when searches use the FTS5 index, and false
+// when they fall back to matching text with LIKE
+func FullTextSearch() bool {
+	return fullTextSearch
+}
+
+// searchDocumentID returns the ID of the document indexing an entity
+func searchDocumentID(kind, repoID, entityID string) string {
+	return kind + ":" + repoID + ":" + entityID
+}
+
+// IndexSearchDocument adds a document to the search index, replacing the
+// one indexing the same entity
+func IndexSearchDocument(doc *SearchDocument) error {
+	id := searchDocumentID(doc.Kind, doc.RepoID, doc.EntityID)
+	// Deleted first rather than replaced, so the delete trigger sees it
+	if err := DB.Query("DELETE FROM search_documents WHERE ID = ?", id).Exec(); err != nil {
+		return errors.Wrap(err, "failed to replace search document")
+	}
+	doc.Model = DB.NewModel(id)
+	_, err := SearchDocuments.Insert(doc)
+	return errors.Wrap(err, "failed to index search document")
+}
+
+// UnindexSearchDocument removes an entity from the search index, with the
+// comments on it
+func UnindexSearchDocument(kind, repoID, entityID string) error {
+	if err := DB.Query("DELETE FROM search_documents WHERE ID = ?", searchDocumentID(kind, repoID, entityID)).Exec(); err != nil {
+		return errors.Wrap(err, "failed to remove search document")
+	}
+	err := DB.Query("DELETE FROM search_documents WHERE RepoID = ? AND Kind = ? AND ParentID = ?", repoID, search.KindComment, entityID).Exec()
+	return errors.Wrap(err, "failed to remove comments from search index")
+}
+
+// ClearSearchDocuments removes a repository's documents of a kind, such as
+// its commits after its history was rewritten
+func ClearSearchDocuments(repoID, kind string) error {
+	err := DB.Query("DELETE FROM search_documents WHERE RepoID = ? AND Kind = ?", repoID, kind).Exec()
+	return errors.Wrap(err, "failed to clear search documents")
+}
+
+// SearchAll returns up to limit documents matching the query, best first,
+// from the repositories user can read: public ones, and private ones when
+// they're an admin or their teams have access. Without a user only public
+// repositories are searched.
+func SearchAll(q search.Query, user *User, limit int) ([]*SearchDocument, error) {
+	var conditions []string
+	var args []any
+	order := "UpdatedAt DESC"
+
+	match := q.Match()
+	ranked := match != "" && fullTextSearch
+	if ranked {
+		conditions = append(conditions, "rowid IN (SELECT rowid FROM search_fts WHERE search_fts MATCH ?)")
+		args = append(args, match)
+		// Title matches count ten times as much as body matches
+		order = "(SELECT bm25(search_fts, 10.0, 1.0) FROM search_fts WHERE search_fts MATCH ? AND search_fts.rowid = search_documents.rowid)"
+	} else {
+		for _, term := range q.Terms {
+			conditions = append(conditions, "(Title LIKE ? OR Body LIKE ?)")
+			args = append(args, "%"+term+"%", "%"+term+"%")
+		}
+	}
+
+	if user == nil || !user.IsAdmin {
+		readable := "RepoID IN (SELECT ID FROM repositories WHERE Visibility = 'public')"
+		if user != nil {
+			if ids := TeamRepoIDs(user.ID); len(ids) > 0 {
+				readable = "(" + readable + " OR RepoID IN (" + strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",") + "))"
+				for _, id := range ids {
+					args = append(args, id)
+				}
+			}
+		}
+		conditions = append(conditions, readable)
+	}
+	if q.Repo != "" {
+		conditions = append(conditions, "RepoID IN (SELECT ID FROM repositories WHERE ID = ? OR LOWER(Name) = LOWER(?))")
+		args = append(args, q.Repo, q.Repo)
+	}
+	if q.Author != "" {
+		conditions = append(conditions, "(AuthorID = ? OR Author LIKE ? OR AuthorEmail LIKE ?)")
+		args = append(args, q.Author, "%"+q.Author+"%", "%"+q.Author+"%")
+	}
+	if q.State != "" {
+		conditions = append(conditions, "State = ?")
+		args = append(args, q.State)
+	}
+	if q.Kind != "" {
+		conditions = append(conditions, "Kind = ?")
+		args = append(args, q.Kind)
+	}
+	if q.Language != "" {
+		conditions = append(conditions, "Language = ?")
+		args = append(args, q.Language)
+	}
+
+	query := "ORDER BY " + order + " LIMIT ?"
+	if len(conditions) > 0 {
+		query = "WHERE " + strings.Join(conditions, " AND ") + " " + query
+	}
+	if ranked {
+		args = append(args, match)
+	}
+	return SearchDocuments.Search(query, append(args, limit)...)
+}
+
+// KindName returns what the document is, for display
+func (d *SearchDocument) KindName() string {
+	switch d.Kind {
+	case search.KindIssue:
+		return "Issue"
+	case search.KindPullRequest:
+		return "Pull request"
+	case search.KindComment:
+		return "Comment"
+	case search.KindCommit:
+		return "Commit"
+	default:
+		return "File"
+	}
+}
+
+// GetSearchIndexState returns how far a repository's search index has
+// got, or nil when it has never been indexed
+func GetSearchIndexState(repoID string) *SearchIndexState {
+	state, err := SearchIndexStates.Get(repoID)
+	if err != nil {
+		return nil
+	}
+	return state
+}
+
+// SaveSearchIndexState creates or updates a repository's search index state
+func SaveSearchIndexState(state *SearchIndexState) error {
+	if state.ID == "" {
+		state.Model = DB.NewModel(state.RepoID)
+		_, err := SearchIndexStates.Insert(state)
+		return errors.Wrap(err, "failed to save search index state")
+	}
+	return errors.Wrap(SearchIndexStates.Update(state), "failed to save search index state")
+}
+
+// DeleteRepoSearchIndex removes a repository from the search index
+func DeleteRepoSearchIndex(repoID string) {
+	DB.Query("DELETE FROM search_documents WHERE RepoID = ?", repoID).Exec()
+	DB.Query("DELETE FROM search_index_states WHERE RepoID = ?", repoID).Exec()
+}
@@ -0,0 +1,112 @@
+package models
+
+import (
+	"testing"
+
+	"workspace/internal/search"
+
+	"github.com/The-Skyscape/devtools/pkg/testutils"
+)
+
+func TestSearchIndex(t *testing.T) {
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+
+	public, err := Repositories.Insert(&Repository{Name: "api", Visibility: "public"})
+	testutils.AssertNoError(t, err)
+	private, err := Repositories.Insert(&Repository{Name: "secret", Visibility: "private"})
+	testutils.AssertNoError(t, err)
+	admin := &User{IsAdmin: true}
+
+	docs := []*SearchDocument{
+		{Kind: search.KindIssue, RepoID: public.ID, EntityID: "i1", Title: "Race condition in worker pool", Body: "Workers race on shutdown", Author: "Sam Lee", AuthorEmail: "sam@example.com", State: search.StateOpen},
+		{Kind: search.KindPullRequest, RepoID: public.ID, EntityID: "p1", Title: "Fix shutdown race", Body: "Waits for workers", Author: "Ada", State: search.StateMerged},
+		{Kind: search.KindComment, RepoID: public.ID, EntityID: "c1", ParentID: "i1", Title: "Comment on Race condition in worker pool", Body: "Seen it in CI too", Author: "Ada"},
+		{Kind: search.KindCode, RepoID: public.ID, EntityID: "pool/pool.go", Title: "pool/pool.go", Body: "func (p *Pool) Shutdown() {}", Language: "go"},
+		{Kind: search.KindIssue, RepoID: private.ID, EntityID: "i2", Title: "Race in billing", Body: "", Author: "Sam Lee", State: search.StateClosed},
+	}
+	for _, doc := range docs {
+		testutils.AssertNoError(t, IndexSearchDocument(doc))
+	}
+
+	t.Run("Match", func(t *testing.T) {
+		results, err := SearchAll(search.Parse("race"), admin, 10)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 4, len(results))
+
+		results, err = SearchAll(search.Parse("race"), nil, 10)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 3, len(results))
+
+		// Team members also search the private repositories they can read
+		member := CreateTestUser(t, db, "member@example.com")
+		results, err = SearchAll(search.Parse("race"), member, 10)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 3, len(results))
+
+		team, err := CreateTeam("Billing", "", "", member.ID)
+		testutils.AssertNoError(t, err)
+		testutils.AssertNoError(t, team.AddMember(member.ID))
+		testutils.AssertNoError(t, team.GrantRepo(private.ID, RoleRead))
+		results, err = SearchAll(search.Parse("race"), member, 10)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 4, len(results))
+
+		results, err = SearchAll(search.Parse("Shutdown"), admin, 10)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 3, len(results))
+	})
+
+	t.Run("Filters", func(t *testing.T) {
+		results, err := SearchAll(search.Parse("race is:open author:sam"), admin, 10)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 1, len(results))
+		testutils.AssertEqual(t, "i1", results[0].EntityID)
+
+		results, err = SearchAll(search.Parse("race repo:secret"), admin, 10)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 1, len(results))
+		testutils.AssertEqual(t, "i2", results[0].EntityID)
+
+		results, err = SearchAll(search.Parse("language:Go"), admin, 10)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 1, len(results))
+		testutils.AssertEqual(t, "File", results[0].KindName())
+	})
+
+	t.Run("Replace", func(t *testing.T) {
+		testutils.AssertNoError(t, IndexSearchDocument(&SearchDocument{Kind: search.KindIssue, RepoID: public.ID, EntityID: "i1", Title: "Deadlock in worker pool", State: search.StateClosed}))
+		results, err := SearchAll(search.Parse("deadlock"), admin, 10)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 1, len(results))
+		testutils.AssertEqual(t, search.StateClosed, results[0].State)
+
+		results, err = SearchAll(search.Parse("race is:issue"), admin, 10)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 1, len(results))
+	})
+
+	t.Run("Unindex", func(t *testing.T) {
+		testutils.AssertNoError(t, UnindexSearchDocument(search.KindIssue, public.ID, "i1"))
+		results, err := SearchAll(search.Parse("worker"), admin, 10)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 1, len(results))
+		testutils.AssertEqual(t, "p1", results[0].EntityID)
+	})
+
+	t.Run("State", func(t *testing.T) {
+		testutils.AssertTrue(t, GetSearchIndexState(public.ID) == nil)
+		testutils.AssertNoError(t, SaveSearchIndexState(&SearchIndexState{RepoID: public.ID, CommitSHA: "abc"}))
+		state := GetSearchIndexState(public.ID)
+		testutils.AssertEqual(t, "abc", state.CommitSHA)
+		state.CommitSHA = "def"
+		testutils.AssertNoError(t, SaveSearchIndexState(state))
+		testutils.AssertEqual(t, "def", GetSearchIndexState(public.ID).CommitSHA)
+
+		DeleteRepoSearchIndex(public.ID)
+		testutils.AssertTrue(t, GetSearchIndexState(public.ID) == nil)
+		results, err := SearchAll(search.Parse("race"), admin, 10)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 1, len(results))
+	})
+}
@@ -2,6 +2,7 @@ package models
 
 import (
 	"fmt"
+	"net/url"
 
 	"github.com/The-Skyscape/devtools/pkg/database"
 	"github.com/The-Skyscape/devtools/pkg/security"
@@ -31,7 +32,7 @@ const (
 // StoreGitHubOAuthToken stores a GitHub OAuth token for a user
 func StoreGitHubOAuthToken(userID string, token string) error {
 	key := fmt.Sprintf("%s%s", GitHubUserPrefix, userID)
-	return Secrets.StoreSecret(key, map[string]any{
+	return StoreSecret(key, map[string]any{
 		"token": token,
 	})
 }
@@ -55,7 +56,7 @@ func GetGitHubOAuthToken(userID string) (string, error) {
 // StoreGitHubRepoIntegration stores GitHub integration data for a repository
 func StoreGitHubRepoIntegration(repoID string, data map[string]any) error {
 	key := fmt.Sprintf("%s%s", GitHubRepoPrefix, repoID)
-	return Secrets.StoreSecret(key, data)
+	return StoreSecret(key, data)
 }
 
 // GetGitHubRepoIntegration retrieves GitHub integration data for a repository
@@ -67,11 +68,65 @@ func GetGitHubRepoIntegration(repoID string) (map[string]any, error) {
 // DeleteGitHubRepoIntegration removes GitHub integration data for a repository
 func DeleteGitHubRepoIntegration(repoID string) error {
 	key := fmt.Sprintf("%s%s", GitHubRepoPrefix, repoID)
-	return Secrets.DeleteSecret(key)
+	return DeleteSecret(key)
 }
 
 // DeleteGitHubOAuthToken removes a user's GitHub OAuth token from vault
 func DeleteGitHubOAuthToken(userID string) error {
 	key := fmt.Sprintf("%s%s", GitHubUserPrefix, userID)
-	return Secrets.DeleteSecret(key)
+	return DeleteSecret(key)
+}
+
+// BitbucketUserPrefix is the vault key prefix for users' Bitbucket credentials
+const BitbucketUserPrefix = "bitbucket/users/"
+
+// BitbucketCredentials authenticates a user with Bitbucket Cloud, either
+// with an app password or an OAuth access token
+type BitbucketCredentials struct {
+	Username    string
+	AppPassword string
+	Token       string // OAuth access token, used instead of the app password when set
+}
+
+// GitUserinfo returns the credentials to embed in Bitbucket HTTPS git URLs
+func (c *BitbucketCredentials) GitUserinfo() *url.Userinfo {
+	if c.Token != "" {
+		return url.UserPassword("x-token-auth", c.Token)
+	}
+	return url.UserPassword(c.Username, c.AppPassword)
+}
+
+// StoreBitbucketCredentials stores a user's Bitbucket credentials
+func StoreBitbucketCredentials(userID string, creds *BitbucketCredentials) error {
+	key := fmt.Sprintf("%s%s", BitbucketUserPrefix, userID)
+	return StoreSecret(key, map[string]any{
+		"username":     creds.Username,
+		"app_password": creds.AppPassword,
+		"token":        creds.Token,
+	})
+}
+
+// GetBitbucketCredentials retrieves a user's Bitbucket credentials
+func GetBitbucketCredentials(userID string) (*BitbucketCredentials, error) {
+	key := fmt.Sprintf("%s%s", BitbucketUserPrefix, userID)
+	secret, err := Secrets.GetSecret(key)
+	if err != nil {
+		return nil, err
+	}
+
+	creds := &BitbucketCredentials{}
+	creds.Username, _ = secret["username"].(string)
+	creds.AppPassword, _ = secret["app_password"].(string)
+	creds.Token, _ = secret["token"].(string)
+	if creds.Token == "" && (creds.Username == "" || creds.AppPassword == "") {
+		return nil, fmt.Errorf("credentials not found or invalid format")
+	}
+
+	return creds, nil
+}
+
+// DeleteBitbucketCredentials removes a user's Bitbucket credentials from vault
+func DeleteBitbucketCredentials(userID string) error {
+	key := fmt.Sprintf("%s%s", BitbucketUserPrefix, userID)
+	return DeleteSecret(key)
 }
@@ -0,0 +1,105 @@
+package models
+
+import (
+	"path/filepath"
+	"time"
+
+	"github.com/The-Skyscape/devtools/pkg/application"
+	"github.com/pkg/errors"
+)
+
+// Semantic index states
+const (
+	SemanticIndexing = "indexing"
+	SemanticReady    = "ready"
+	SemanticFailed   = "failed"
+)
+
+// SemanticIndex tracks a repository's embedding index. Its ID is the
+// repository ID.
+type SemanticIndex struct {
+	application.Model
+	RepoID         string
+	CommitSHA      string // Default branch commit the index reflects
+	EmbeddingModel string // Embedding model the vectors came from
+	Status         string
+	Error          string
+	Files          int
+	Chunks         int
+	IndexedAt      time.Time
+}
+
+// Table returns the database table name
+func (*SemanticIndex) Table() string { return "semantic_indexes" }
+
+// CodeChunk is an embedded range of lines from a file on a repository's
+// default branch
+type CodeChunk struct {
+	application.Model
+	RepoID    string
+	Path      string
+	BlobHash  string // Git blob the chunk was read from, to skip unchanged files
+	StartLine int
+	EndLine   int
+	Content   string
+	Vector    string // Base64 little-endian float32s
+}
+
+// Table returns the database table name
+func (*CodeChunk) Table() string { return "code_chunks" }
+
+// Language returns the chunk's programming language from its file extension
+func (c *CodeChunk) Language() string {
+	return getLanguageFromExtension(filepath.Ext(c.Path))
+}
+
+// GetSemanticIndex returns a repository's index, or nil when it has never
+// been indexed
+func GetSemanticIndex(repoID string) *SemanticIndex {
+	index, err := SemanticIndexes.Get(repoID)
+	if err != nil {
+		return nil
+	}
+	return index
+}
+
+// SaveSemanticIndex creates or updates a repository's index record
+func SaveSemanticIndex(index *SemanticIndex) error {
+	if index.ID == "" {
+		index.Model = DB.NewModel(index.RepoID)
+		_, err := SemanticIndexes.Insert(index)
+		return errors.Wrap(err, "failed to save semantic index")
+	}
+	return errors.Wrap(SemanticIndexes.Update(index), "failed to save semantic index")
+}
+
+// GetCodeChunks returns every embedded chunk of a repository
+func GetCodeChunks(repoID string) ([]*CodeChunk, error) {
+	return CodeChunks.Search("WHERE RepoID = ? ORDER BY Path, StartLine", repoID)
+}
+
+// IndexedBlobs maps each indexed path to the blob it was embedded from
+func IndexedBlobs(repoID string) (map[string]string, error) {
+	chunks, err := CodeChunks.Search("WHERE RepoID = ?", repoID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load indexed files")
+	}
+	blobs := make(map[string]string, len(chunks))
+	for _, chunk := range chunks {
+		blobs[chunk.Path] = chunk.BlobHash
+	}
+	return blobs, nil
+}
+
+// DeleteCodeChunks removes a file's chunks from a repository's index
+func DeleteCodeChunks(repoID, path string) error {
+	err := DB.Query("DELETE FROM code_chunks WHERE RepoID = ? AND Path = ?", repoID, path).Exec()
+	return errors.Wrap(err, "failed to delete code chunks")
+}
+
+// ClearSemanticIndex removes all of a repository's chunks, for example when
+// the embedding model changes
+func ClearSemanticIndex(repoID string) error {
+	err := DB.Query("DELETE FROM code_chunks WHERE RepoID = ?", repoID).Exec()
+	return errors.Wrap(err, "failed to clear semantic index")
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/The-Skyscape/devtools/pkg/testutils"
+)
+
+func TestSemanticIndex(t *testing.T) {
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+
+	testutils.AssertTrue(t, GetSemanticIndex("repo-1") == nil)
+
+	index := &SemanticIndex{RepoID: "repo-1", Status: SemanticIndexing}
+	testutils.AssertNoError(t, SaveSemanticIndex(index))
+	testutils.AssertEqual(t, "repo-1", index.ID)
+
+	index.Status = SemanticReady
+	index.Chunks = 3
+	testutils.AssertNoError(t, SaveSemanticIndex(index))
+	testutils.AssertEqual(t, SemanticReady, GetSemanticIndex("repo-1").Status)
+
+	for _, chunk := range []*CodeChunk{
+		{RepoID: "repo-1", Path: "main.go", BlobHash: "aaa", StartLine: 1, EndLine: 40},
+		{RepoID: "repo-1", Path: "main.go", BlobHash: "aaa", StartLine: 33, EndLine: 60},
+		{RepoID: "repo-1", Path: "README.md", BlobHash: "bbb", StartLine: 1, EndLine: 12},
+		{RepoID: "repo-2", Path: "main.go", BlobHash: "ccc", StartLine: 1, EndLine: 5},
+	} {
+		_, err := CodeChunks.Insert(chunk)
+		testutils.AssertNoError(t, err)
+	}
+
+	blobs, err := IndexedBlobs("repo-1")
+	testutils.AssertNoError(t, err)
+	testutils.AssertEqual(t, 2, len(blobs))
+	testutils.AssertEqual(t, "aaa", blobs["main.go"])
+	testutils.AssertEqual(t, "go", (&CodeChunk{Path: "cmd/main.go"}).Language())
+
+	testutils.AssertNoError(t, DeleteCodeChunks("repo-1", "main.go"))
+	chunks, err := GetCodeChunks("repo-1")
+	testutils.AssertNoError(t, err)
+	testutils.AssertEqual(t, 1, len(chunks))
+	testutils.AssertEqual(t, "README.md", chunks[0].Path)
+
+	testutils.AssertNoError(t, ClearSemanticIndex("repo-1"))
+	testutils.AssertEqual(t, 0, CodeChunks.Count("WHERE RepoID = ?", "repo-1"))
+	testutils.AssertEqual(t, 1, CodeChunks.Count("WHERE RepoID = ?", "repo-2"))
+}
@@ -0,0 +1,163 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/The-Skyscape/devtools/pkg/application"
+	"github.com/pkg/errors"
+)
+
+// sessionSeenInterval is how often a session's last activity is saved, so
+// browsing doesn't write on every request
+const sessionSeenInterval = time.Minute
+
+// UserSession is a browser signed in to the workspace. Only a hash of the
+// session cookie is stored, and a cookie without an active session is
+// refused, so revoking one signs that browser out.
+type UserSession struct {
+	application.Model
+	UserID     string
+	TokenHash  string
+	UserAgent  string
+	IPAddress  string // Where it signed in from
+	LastSeenAt time.Time
+	LastSeenIP string
+	ExpiresAt  time.Time
+	RevokedAt  time.Time
+	RevokedBy  string // User who signed it out, empty when it signed out itself
+}
+
+// Table returns the database table name
+func (*UserSession) Table() string { return "user_sessions" }
+
+// CreateUserSession records that a browser signed in with a session cookie
+func CreateUserSession(userID, token, userAgent, ip string, expires time.Time) (*UserSession, error) {
+	if userID == "" || token == "" {
+		return nil, errors.New("user and token required")
+	}
+	if len(userAgent) > 512 {
+		userAgent = userAgent[:512]
+	}
+	session, err := UserSessions.Insert(&UserSession{
+		Model:      DB.NewModel(""),
+		UserID:     userID,
+		TokenHash:  hashToken(token),
+		UserAgent:  userAgent,
+		IPAddress:  ip,
+		LastSeenAt: time.Now(),
+		LastSeenIP: ip,
+		ExpiresAt:  expires,
+	})
+	return session, errors.Wrap(err, "failed to record session")
+}
+
+// GetUserSession returns the active session for a cookie, failing for
+// unknown, expired and revoked sessions alike
+func GetUserSession(token string) (*UserSession, error) {
+	if token != "" {
+		sessions, err := UserSessions.Search("WHERE TokenHash = ?", hashToken(token))
+		if err == nil && len(sessions) > 0 && sessions[0].Active() {
+			return sessions[0], nil
+		}
+	}
+	return nil, errors.New("session is invalid, expired or signed out")
+}
+
+// ActiveUserSessions returns a user's signed in browsers, most recently
+// seen first
+func ActiveUserSessions(userID string) ([]*UserSession, error) {
+	sessions, err := UserSessions.Search("WHERE UserID = ? ORDER BY LastSeenAt DESC", userID)
+	if err != nil {
+		return nil, err
+	}
+	active := sessions[:0]
+	for _, session := range sessions {
+		if session.Active() {
+			active = append(active, session)
+		}
+	}
+	return active, nil
+}
+
+// RevokeUserSessions signs a user out of every browser except keepID, as
+// byUserID, returning how many were signed out
+func RevokeUserSessions(userID, keepID, byUserID string) (int, error) {
+	sessions, err := ActiveUserSessions(userID)
+	if err != nil {
+		return 0, err
+	}
+	revoked := 0
+	for _, session := range sessions {
+		if session.ID == keepID {
+			continue
+		}
+		if err := session.Revoke(byUserID); err != nil {
+			return revoked, err
+		}
+		revoked++
+	}
+	return revoked, nil
+}
+
+// Active reports whether the session still signs its browser in
+func (s *UserSession) Active() bool {
+	return s.RevokedAt.IsZero() && time.Now().Before(s.ExpiresAt)
+}
+
+// Revoke signs the session's browser out
+func (s *UserSession) Revoke(byUserID string) error {
+	if !s.RevokedAt.IsZero() {
+		return nil
+	}
+	s.RevokedAt = time.Now()
+	s.RevokedBy = byUserID
+	return errors.Wrap(UserSessions.Update(s), "failed to sign session out")
+}
+
+// Seen records that the session was just used from an address
+func (s *UserSession) Seen(ip string) error {
+	if time.Since(s.LastSeenAt) < sessionSeenInterval && s.LastSeenIP == ip {
+		return nil
+	}
+	s.LastSeenAt = time.Now()
+	s.LastSeenIP = ip
+	return UserSessions.Update(s)
+}
+
+// Device describes the browser and operating system from the user agent,
+// such as "Firefox on macOS"
+func (s *UserSession) Device() string {
+	ua := s.UserAgent
+	browser := "Unknown browser"
+	for _, b := range []struct{ token, name string }{
+		// Order matters, as Edge and Opera also claim to be Chrome and
+		// Chrome claims to be Safari
+		{"Edg/", "Edge"},
+		{"OPR/", "Opera"},
+		{"Firefox/", "Firefox"},
+		{"Chrome/", "Chrome"},
+		{"Safari/", "Safari"},
+		{"curl/", "curl"},
+	} {
+		if strings.Contains(ua, b.token) {
+			browser = b.name
+			break
+		}
+	}
+
+	for _, system := range []struct{ token, name string }{
+		{"iPhone", "iOS"},
+		{"iPad", "iPadOS"},
+		{"Android", "Android"},
+		{"Mac OS X", "macOS"},
+		{"Windows", "Windows"},
+		{"CrOS", "ChromeOS"},
+		{"Linux", "Linux"},
+	} {
+		if strings.Contains(ua, system.token) {
+			return browser + " on " + system.name
+		}
+	}
+	return browser
+}
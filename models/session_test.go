@@ -0,0 +1,85 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/The-Skyscape/devtools/pkg/testutils"
+)
+
+func TestUserSessions(t *testing.T) {
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+
+	user := CreateTestUser(t, db, "sessions@example.com")
+	admin := CreateTestUser(t, db, "sessions-admin@example.com")
+	expires := time.Now().Add(time.Hour)
+
+	t.Run("CreateAndGet", func(t *testing.T) {
+		session, err := CreateUserSession(user.ID, "cookie-1", "Mozilla/5.0 (Macintosh; Intel Mac OS X 14_0) Gecko/20100101 Firefox/128.0", "10.0.0.1", expires)
+		testutils.AssertNoError(t, err)
+		testutils.AssertTrue(t, session.TokenHash != "cookie-1")
+		testutils.AssertEqual(t, "Firefox on macOS", session.Device())
+
+		found, err := GetUserSession("cookie-1")
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, session.ID, found.ID)
+
+		_, err = GetUserSession("unknown")
+		testutils.AssertError(t, err)
+		_, err = CreateUserSession(user.ID, "", "", "", expires)
+		testutils.AssertError(t, err)
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		_, err := CreateUserSession(user.ID, "cookie-old", "", "10.0.0.2", time.Now().Add(-time.Minute))
+		testutils.AssertNoError(t, err)
+		_, err = GetUserSession("cookie-old")
+		testutils.AssertError(t, err)
+	})
+
+	t.Run("Seen", func(t *testing.T) {
+		session, err := GetUserSession("cookie-1")
+		testutils.AssertNoError(t, err)
+		testutils.AssertNoError(t, session.Seen("10.0.0.9"))
+		testutils.AssertEqual(t, "10.0.0.9", session.LastSeenIP)
+		testutils.AssertEqual(t, "10.0.0.1", session.IPAddress)
+	})
+
+	t.Run("RevokeOthers", func(t *testing.T) {
+		_, err := CreateUserSession(user.ID, "cookie-2", "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) Version/17.0 Mobile Safari/604.1", "10.0.0.3", expires)
+		testutils.AssertNoError(t, err)
+		current, err := GetUserSession("cookie-1")
+		testutils.AssertNoError(t, err)
+
+		active, err := ActiveUserSessions(user.ID)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 2, len(active))
+
+		revoked, err := RevokeUserSessions(user.ID, current.ID, user.ID)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 1, revoked)
+		_, err = GetUserSession("cookie-2")
+		testutils.AssertError(t, err)
+		_, err = GetUserSession("cookie-1")
+		testutils.AssertNoError(t, err)
+	})
+
+	t.Run("SignOutEverywhere", func(t *testing.T) {
+		revoked, err := RevokeUserSessions(user.ID, "", admin.ID)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 1, revoked)
+
+		active, err := ActiveUserSessions(user.ID)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 0, len(active))
+
+		sessions, err := UserSessions.Search("WHERE UserID = ?", user.ID)
+		testutils.AssertNoError(t, err)
+		for _, session := range sessions {
+			if session.TokenHash == hashToken("cookie-1") {
+				testutils.AssertEqual(t, admin.ID, session.RevokedBy)
+			}
+		}
+	})
+}
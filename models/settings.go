@@ -2,6 +2,10 @@ package models
 
 import (
 	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/The-Skyscape/devtools/pkg/application"
@@ -32,6 +36,103 @@ type Settings struct {
 	// Integration Settings
 	GitHubEnabled        bool
 	
+	// AI Settings - AI_ENABLED, AI_MODEL, OLLAMA_HOST and OLLAMA_KEEP_ALIVE take precedence when set
+	AIEnabled           bool
+	AIModel             string
+	OllamaHost          string // Remote Ollama servers, comma separated, instead of the local container
+	OllamaKeepAlive     string // How long models stay loaded after a request, empty for the default
+	WebFetchDomains     string // Hosts the AI may read documentation from, one per line
+	
+	// Review Settings - zero SLA hours uses DefaultReviewSLA
+	ReviewSLAHours      int
+	ReviewRemindersOff  bool
+	
+	// Daily Report Settings - the AI queue's daily health reports are also
+	// emailed and posted to these when set
+	DailyReportRecipients string // Comma separated addresses
+	DailyReportWebhookURL string
+	
+	// Usage Settings - quotas are per user per calendar month, zero is unlimited
+	QuotaStorageMB      int
+	QuotaCIMinutes      int
+	QuotaAITokens       int
+	QuotaWorkerHours    int
+	UsageWebhookURL     string // Receives a usage report each day when set
+	UsageWebhookSecret  string // Signs webhook payloads when set
+	
+	// Backup Settings - empty uses the default backup directory. Backups
+	// are copied to their own destination when one is chosen, otherwise to
+	// file storage when it is off the host. Secrets are kept in the vault.
+	BackupDir           string
+	BackupEncrypted     bool   // Archives are encrypted with the key in the vault
+	BackupTarget        string // s3, sftp or webdav, empty for file storage
+	BackupEndpoint      string // S3 compatible service, SFTP host:port or WebDAV address
+	BackupBucket        string
+	BackupRegion        string
+	BackupPrefix        string // Directory or key prefix backups are kept under
+	BackupUsername      string // S3 access key, or the SFTP or WebDAV user
+	BackupHostKey       string // SFTP host key fingerprint, recorded when first saved
+	BackupRetentionDays int    // Zero uses DefaultBackupRetentionDays
+	BackupMaxBackups    int    // Zero uses DefaultMaxBackups
+	BackupRemoteRetentionDays int // Zero keeps remote copies as long as local ones
+	BackupRemoteMaxBackups    int
+	BackupTestRestoreDays     int // Zero test restores weekly, negative never
+	
+	// File Storage - attachments, avatars, artifacts and backup copies,
+	// kept in the data directory when no backend is chosen
+	StorageBackend      string // local, nfs or s3
+	StoragePath         string // Directory for local disk, the mount point for NFS
+	StorageEndpoint     string // S3 compatible service, empty for AWS
+	StorageBucket       string
+	StorageRegion       string
+	StoragePrefix       string
+	StorageAccessKey    string
+	StorageSecretKey    string
+	
+	// Mirror Export - every repository is pushed to an organization on
+	// another host on a schedule, with the access token kept in the vault
+	MirrorProvider      string // MirrorGitHub or MirrorGitLab, empty when off
+	MirrorBaseURL       string // Self-hosted GitLab server, empty for gitlab.com
+	MirrorOrg           string // Organization or group the mirrors are pushed to
+	MirrorIntervalHours int    // Zero uses DefaultMirrorIntervalHours
+	MirrorLastRunAt     time.Time
+	
+	// Outgoing Mail - the SMTP_ environment variables take precedence when
+	// SMTP_HOST is set, the password is kept in the vault
+	MailHost            string
+	MailPort            int    // Zero uses 587
+	MailSecurity        string // MailStartTLS, MailTLS or MailPlain
+	MailFrom            string
+	MailUsername        string
+	PublicURL           string // Address of the workspace used for links in emails
+	
+	// Single Sign-On - users sign in with an OpenID Connect provider such
+	// as Google Workspace, Azure AD or Keycloak, the client secret is kept
+	// in the vault
+	SSOEnabled          bool
+	SSOProviderName     string    // Shown on the sign-in button
+	SSODiscoveryURL     string    // Issuer address, .well-known/openid-configuration is added
+	SSOClientID         string
+	SSOAllowedDomains   string    // Email domains that may sign in, one per line, empty for any
+	SSOAutoCreate       bool      // Create accounts for new users when they first sign in
+	SSOGroupsClaim      string    // ID token claim listing groups, empty for "groups"
+	SSOGroupRoles       string    // "group = admin" or "group = team:Name", one per line
+	SSOPasswordOff      bool      // Password sign-in is turned off once SSO is verified
+	SSOVerifiedAt       time.Time // When an administrator last tested sign-in
+	
+	// Logging - LOG_LEVEL and LOG_FORMAT take precedence when set
+	LogLevels           string // A default and levels per module, such as "info,ai=debug"
+	LogFormat           string // text, or json for log shippers
+	
+	// Resource Monitoring - zero thresholds alert over DefaultMonitorThreshold
+	MonitorCPUPercent    int
+	MonitorMemoryPercent int
+	MonitorDiskPercent   int
+	MonitorRetentionDays int // Zero keeps samples DefaultMonitorRetentionDays
+	
+	// Set once the first-run setup wizard has been finished or skipped
+	SetupCompleted      bool
+	
 	// Metadata
 	LastUpdatedBy       string
 	LastUpdatedAt       time.Time
@@ -61,6 +162,7 @@ func GetSettings() (*Settings, error) {
 			MaxCacheSize:        100,
 			EnableGitCache:      true,
 			GitHubEnabled:       false,
+			WebFetchDomains:     strings.Join(DefaultWebFetchDomains, "\n"),
 			LastUpdatedAt:       time.Now(),
 		}
 		
@@ -74,6 +176,110 @@ func GetSettings() (*Settings, error) {
 	return settings, nil
 }
 
+// DefaultWebFetchDomains are the documentation sites new workspaces let
+// the AI read. Subdomains are included, so go.dev covers pkg.go.dev.
+var DefaultWebFetchDomains = []string{
+	"go.dev",
+	"developer.mozilla.org",
+	"github.com",
+	"raw.githubusercontent.com",
+	"docs.python.org",
+	"pypi.org",
+	"npmjs.com",
+	"docs.rs",
+}
+
+// WebFetchDomainList returns the domains on the web_fetch allowlist
+func (s *Settings) WebFetchDomainList() []string {
+	var domains []string
+	for _, line := range strings.FieldsFunc(s.WebFetchDomains, func(r rune) bool {
+		return r == '\n' || r == ',' || r == ' ' || r == '\r' || r == '\t'
+	}) {
+		domain := strings.TrimPrefix(strings.ToLower(line), "*.")
+		domain = strings.Trim(domain, ".")
+		if domain != "" && !slices.Contains(domains, domain) {
+			domains = append(domains, domain)
+		}
+	}
+	return domains
+}
+
+// AllowsWebFetch reports whether the AI may fetch pages from a host, which
+// must be an allowlisted domain or one of its subdomains
+func (s *Settings) AllowsWebFetch(host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	for _, domain := range s.WebFetchDomainList() {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// ReviewSLA returns how long a review request may wait before the
+// reviewer is reminded
+func (s *Settings) ReviewSLA() time.Duration {
+	if s.ReviewSLAHours <= 0 {
+		return DefaultReviewSLA
+	}
+	return time.Duration(s.ReviewSLAHours) * time.Hour
+}
+
+// environmentOverrides records which settings were given through
+// environment variables at startup, these are never replaced by saved values
+var environmentOverrides = map[string]bool{
+	"AI_ENABLED":  os.Getenv("AI_ENABLED") != "",
+	"AI_MODEL":    os.Getenv("AI_MODEL") != "",
+	"OLLAMA_HOST": os.Getenv("OLLAMA_HOST") != "",
+
+	"OLLAMA_KEEP_ALIVE": os.Getenv("OLLAMA_KEEP_ALIVE") != "",
+}
+
+// UsageQuota returns the monthly per-user limit for a metric in the
+// metric's own unit, or zero when it is unlimited
+func (s *Settings) UsageQuota(metric string) float64 {
+	switch metric {
+	case UsageStorage:
+		return float64(s.QuotaStorageMB) * 1024 * 1024
+	case UsageCIMinutes:
+		return float64(s.QuotaCIMinutes)
+	case UsageAITokens:
+		return float64(s.QuotaAITokens)
+	case UsageWorkerHours:
+		return float64(s.QuotaWorkerHours)
+	}
+	return 0
+}
+
+// IsSetByEnvironment reports whether the deployment configured the variable
+// itself, making the matching setting read-only
+func IsSetByEnvironment(name string) bool {
+	return environmentOverrides[name]
+}
+
+// ApplyEnvironment exports the saved AI settings as the environment variables
+// read by the AI services, leaving variables set by the deployment untouched
+func (s *Settings) ApplyEnvironment() {
+	if !environmentOverrides["AI_ENABLED"] {
+		os.Setenv("AI_ENABLED", strconv.FormatBool(s.AIEnabled))
+	}
+	if !environmentOverrides["AI_MODEL"] && s.AIModel != "" {
+		os.Setenv("AI_MODEL", s.AIModel)
+	}
+	if !environmentOverrides["OLLAMA_HOST"] {
+		os.Setenv("OLLAMA_HOST", s.OllamaHost)
+	}
+	if !environmentOverrides["OLLAMA_KEEP_ALIVE"] {
+		os.Setenv("OLLAMA_KEEP_ALIVE", s.OllamaKeepAlive)
+	}
+}
+
+// NeedsSetup reports whether the first-run setup wizard should be offered.
+// Workspaces that already have repositories are treated as set up.
+func (s *Settings) NeedsSetup() bool {
+	return !s.SetupCompleted && Repositories.Count("") == 0
+}
+
 // HasGitHubIntegration checks if GitHub integration is configured
 func (s *Settings) HasGitHubIntegration() bool {
 	if !s.GitHubEnabled {
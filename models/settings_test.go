@@ -0,0 +1,81 @@
+package models
+
+import (
+	"os"
+	"testing"
+
+	"github.com/The-Skyscape/devtools/pkg/testutils"
+)
+
+func TestSettingsSetup(t *testing.T) {
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+
+	t.Run("NeedsSetupUntilCompleted", func(t *testing.T) {
+		settings, err := GetSettings()
+		testutils.AssertNoError(t, err)
+		testutils.AssertTrue(t, settings.NeedsSetup())
+
+		settings.SetupCompleted = true
+		testutils.AssertNoError(t, GlobalSettings.Update(settings))
+
+		saved, err := GetSettings()
+		testutils.AssertNoError(t, err)
+		testutils.AssertFalse(t, saved.NeedsSetup())
+	})
+
+	t.Run("ApplyEnvironment", func(t *testing.T) {
+		if IsSetByEnvironment("AI_ENABLED") || IsSetByEnvironment("AI_MODEL") {
+			t.Skip("AI configured by the environment")
+		}
+		t.Setenv("AI_ENABLED", "")
+		t.Setenv("AI_MODEL", "")
+
+		settings := &Settings{AIEnabled: true, AIModel: "llama3.2:1b"}
+		settings.ApplyEnvironment()
+		testutils.AssertEqual(t, "true", os.Getenv("AI_ENABLED"))
+		testutils.AssertEqual(t, "llama3.2:1b", os.Getenv("AI_MODEL"))
+
+		settings.AIEnabled = false
+		settings.ApplyEnvironment()
+		testutils.AssertEqual(t, "false", os.Getenv("AI_ENABLED"))
+	})
+
+	t.Run("ApplyOllamaHost", func(t *testing.T) {
+		if IsSetByEnvironment("OLLAMA_HOST") {
+			t.Skip("Ollama server configured by the environment")
+		}
+		t.Setenv("OLLAMA_HOST", "")
+
+		settings := &Settings{OllamaHost: "gpu-1:11434, gpu-2:11434"}
+		settings.ApplyEnvironment()
+		testutils.AssertEqual(t, "gpu-1:11434, gpu-2:11434", os.Getenv("OLLAMA_HOST"))
+
+		// Clearing the setting goes back to the local container
+		settings.OllamaHost = ""
+		settings.ApplyEnvironment()
+		testutils.AssertEqual(t, "", os.Getenv("OLLAMA_HOST"))
+	})
+}
+
+func TestWebFetchAllowlist(t *testing.T) {
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+
+	settings, err := GetSettings()
+	testutils.AssertNoError(t, err)
+	testutils.AssertTrue(t, settings.AllowsWebFetch("pkg.go.dev"))
+	testutils.AssertTrue(t, settings.AllowsWebFetch("developer.mozilla.org"))
+	testutils.AssertFalse(t, settings.AllowsWebFetch("example.com"))
+
+	settings.WebFetchDomains = "*.Example.com, docs.internal.\n\ngo.dev"
+	testutils.AssertEqual(t, 3, len(settings.WebFetchDomainList()))
+	testutils.AssertTrue(t, settings.AllowsWebFetch("example.com"))
+	testutils.AssertTrue(t, settings.AllowsWebFetch("api.example.com."))
+	testutils.AssertTrue(t, settings.AllowsWebFetch("docs.internal"))
+	testutils.AssertFalse(t, settings.AllowsWebFetch("badexample.com"))
+	testutils.AssertFalse(t, settings.AllowsWebFetch("go.dev.evil.com"))
+
+	settings.WebFetchDomains = ""
+	testutils.AssertFalse(t, settings.AllowsWebFetch("go.dev"))
+}
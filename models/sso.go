@@ -0,0 +1,371 @@
+package models
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/The-Skyscape/devtools/pkg/application"
+	"github.com/pkg/errors"
+)
+
+// SSOSecretKey is where the OpenID Connect client secret is kept in the vault
+const SSOSecretKey = "sso/oidc"
+
+// DefaultSSOGroupsClaim is the ID token claim listing a user's groups when
+// settings don't name another
+const DefaultSSOGroupsClaim = "groups"
+
+// SSORoleAdmin maps a group to developers with full access
+const SSORoleAdmin = "admin"
+
+// ssoTeamPrefix maps a group to a team, as in "group = team:Backend"
+const ssoTeamPrefix = "team:"
+
+// SSOConfigured reports whether users can sign in through the provider
+func (s *Settings) SSOConfigured() bool {
+	return s.SSOEnabled && s.SSODiscoveryURL != "" && s.SSOClientID != ""
+}
+
+// SSOName returns what the provider is called on the sign-in page
+func (s *Settings) SSOName() string {
+	if s.SSOProviderName != "" {
+		return s.SSOProviderName
+	}
+	return "Single Sign-On"
+}
+
+// SSOVerified reports whether an administrator has signed in through the
+// provider since it was configured
+func (s *Settings) SSOVerified() bool {
+	return s.SSOConfigured() && !s.SSOVerifiedAt.IsZero()
+}
+
+// PasswordLoginDisabled reports whether users must sign in through the
+// provider. Passwords stay on until sign-in was verified, and setting
+// SSO_ALLOW_PASSWORD=true lets administrators back in if the provider is
+// unreachable.
+func (s *Settings) PasswordLoginDisabled() bool {
+	return s.SSOPasswordOff && s.SSOVerified() && os.Getenv("SSO_ALLOW_PASSWORD") != "true"
+}
+
+// SSODomainList returns the email domains allowed to sign in, empty when
+// any domain is
+func (s *Settings) SSODomainList() []string {
+	var domains []string
+	for _, line := range strings.FieldsFunc(s.SSOAllowedDomains, func(r rune) bool {
+		return r == '\n' || r == ',' || r == ' ' || r == '\r' || r == '\t'
+	}) {
+		domain := strings.Trim(strings.TrimPrefix(strings.ToLower(line), "@"), ".")
+		if domain != "" && !slices.Contains(domains, domain) {
+			domains = append(domains, domain)
+		}
+	}
+	return domains
+}
+
+// ssoDomainAllowed reports whether an email's domain may sign in
+func (s *Settings) ssoDomainAllowed(email string) bool {
+	domains := s.SSODomainList()
+	if len(domains) == 0 {
+		return true
+	}
+	_, domain, _ := strings.Cut(strings.ToLower(email), "@")
+	return slices.Contains(domains, domain)
+}
+
+// SSOGroupMapping is what membership of a provider group gives a user
+type SSOGroupMapping struct {
+	Group string
+	Admin bool   // Members are developers with full access
+	Team  string // Members join the team with this name
+}
+
+// SSOGroupMappings parses the group mappings in settings, one per line as
+// "group = admin" or "group = team:Name". Blank lines and lines starting
+// with # are skipped.
+func (s *Settings) SSOGroupMappings() ([]SSOGroupMapping, error) {
+	var mappings []SSOGroupMapping
+	for n, line := range strings.Split(s.SSOGroupRoles, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		group, role, ok := strings.Cut(line, "=")
+		group, role = strings.TrimSpace(group), strings.TrimSpace(role)
+		if !ok || group == "" || role == "" {
+			return nil, errors.Errorf("line %d: expected \"group = admin\" or \"group = team:Name\"", n+1)
+		}
+
+		mapping := SSOGroupMapping{Group: group}
+		switch {
+		case strings.EqualFold(role, SSORoleAdmin):
+			mapping.Admin = true
+		case strings.HasPrefix(strings.ToLower(role), ssoTeamPrefix):
+			mapping.Team = strings.TrimSpace(role[len(ssoTeamPrefix):])
+			if mapping.Team == "" {
+				return nil, errors.Errorf("line %d: team name is missing", n+1)
+			}
+		default:
+			return nil, errors.Errorf("line %d: unknown role %q, use admin or team:Name", n+1, role)
+		}
+		mappings = append(mappings, mapping)
+	}
+	return mappings, nil
+}
+
+// SSOGroupsClaimName returns the ID token claim listing a user's groups
+func (s *Settings) SSOGroupsClaimName() string {
+	if s.SSOGroupsClaim != "" {
+		return s.SSOGroupsClaim
+	}
+	return DefaultSSOGroupsClaim
+}
+
+// StoreSSOClientSecret stores the secret the workspace signs in to the
+// provider with
+func StoreSSOClientSecret(secret string) error {
+	return StoreSecret(SSOSecretKey, map[string]any{"client_secret": secret})
+}
+
+// GetSSOClientSecret returns the secret the workspace signs in to the
+// provider with
+func GetSSOClientSecret() (string, error) {
+	secret, err := Secrets.GetSecret(SSOSecretKey)
+	if err != nil {
+		return "", err
+	}
+	clientSecret, _ := secret["client_secret"].(string)
+	if clientSecret == "" {
+		return "", errors.New("single sign-on client secret not found")
+	}
+	return clientSecret, nil
+}
+
+// DeleteSSOClientSecret removes the secret the workspace signs in to the
+// provider with
+func DeleteSSOClientSecret() error {
+	return DeleteSecret(SSOSecretKey)
+}
+
+// SSOIdentity links a user to their account at the provider, so they are
+// recognized even if their email changes
+type SSOIdentity struct {
+	application.Model
+	UserID      string
+	Issuer      string
+	Subject     string // The provider's ID for the user, never reused
+	Email       string
+	LastLoginAt time.Time
+}
+
+// Table returns the database table name
+func (*SSOIdentity) Table() string { return "sso_identities" }
+
+// SSOProfile is who the provider says signed in, from their ID token
+type SSOProfile struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Username      string // Preferred username, used for the handle of new accounts
+	Groups        []string
+}
+
+// SSOSignIn returns the account of the user the provider signed in. They
+// are recognized by their identity at the provider, or linked by email to
+// an existing account the first time, or given a new account when settings
+// allow it. Admin status and team membership are then brought in line with
+// the user's groups.
+func SSOSignIn(settings *Settings, profile SSOProfile) (*User, error) {
+	if profile.Issuer == "" || profile.Subject == "" {
+		return nil, errors.New("the provider didn't identify the user")
+	}
+	email := strings.ToLower(strings.TrimSpace(profile.Email))
+	if email != "" && !settings.ssoDomainAllowed(email) {
+		return nil, errors.Errorf("%s is not in a domain allowed to sign in", email)
+	}
+	if email == "" && len(settings.SSODomainList()) > 0 {
+		return nil, errors.New("the provider didn't share an email address to check the domain of")
+	}
+
+	var err error
+	user, identity := findSSOUser(profile.Issuer, profile.Subject, email, profile.EmailVerified)
+	if user == nil {
+		if !settings.SSOAutoCreate {
+			return nil, errors.Errorf("there's no account for %s, ask an administrator for an invitation", emailOrUser(email))
+		}
+		if email == "" {
+			return nil, errors.New("the provider didn't share an email address for the new account")
+		}
+		if existing, err := Users.Search("WHERE Email = ? COLLATE NOCASE", email); err == nil && len(existing) > 0 {
+			return nil, errors.Errorf("the provider hasn't verified %s, which belongs to an existing account", email)
+		}
+		if user, err = provisionSSOUser(profile, email); err != nil {
+			return nil, err
+		}
+	}
+
+	if identity == nil {
+		identity = &SSOIdentity{
+			Model:   DB.NewModel(""),
+			UserID:  user.ID,
+			Issuer:  profile.Issuer,
+			Subject: profile.Subject,
+		}
+		if identity, err = SSOIdentities.Insert(identity); err != nil {
+			return nil, errors.Wrap(err, "failed to link account to the provider")
+		}
+	}
+	identity.Email = email
+	identity.LastLoginAt = time.Now()
+	if err := SSOIdentities.Update(identity); err != nil {
+		log.Printf("SSO: Failed to record sign-in of %s: %v", user.Email, err)
+	}
+
+	if err := syncSSOGroups(settings, user, profile.Groups); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// emailOrUser names an email in messages, which may be empty
+func emailOrUser(email string) string {
+	if email == "" {
+		return "this user"
+	}
+	return email
+}
+
+// findSSOUser returns the account linked to an identity at the provider,
+// or the account with its email when the provider vouches for the email.
+// The identity is nil when the account isn't linked yet, and the user nil
+// when there's no account.
+func findSSOUser(issuer, subject, email string, verified bool) (*User, *SSOIdentity) {
+	if identities, err := SSOIdentities.Search("WHERE Issuer = ? AND Subject = ?", issuer, subject); err == nil && len(identities) > 0 {
+		user, err := Users.Get(identities[0].UserID)
+		if err == nil {
+			return user, identities[0]
+		}
+		// The account was deleted, so the identity can be linked again
+		SSOIdentities.Delete(identities[0])
+	}
+
+	// Accounts are only linked by email when the provider checked the
+	// address. Otherwise anyone able to set an email at the provider, even
+	// one in an allowed domain, could take over the account using it.
+	if email == "" || !verified {
+		return nil, nil
+	}
+	users, err := Users.Search("WHERE Email = ? COLLATE NOCASE", email)
+	if err != nil || len(users) == 0 {
+		return nil, nil
+	}
+	return users[0], nil
+}
+
+// handleChars are the characters left out of handles made for new accounts
+var handleChars = regexp.MustCompile(`[^a-z0-9_-]+`)
+
+// provisionSSOUser creates the account of someone signing in through the
+// provider for the first time. They start as guests, and get a random
+// password they never see so only the provider can sign them in.
+func provisionSSOUser(profile SSOProfile, email string) (*User, error) {
+	base := profile.Username
+	if base == "" {
+		base = email
+	}
+	base, _, _ = strings.Cut(strings.ToLower(base), "@")
+	base = strings.Trim(handleChars.ReplaceAllString(base, "-"), "-")
+	if base == "" {
+		base = "user"
+	}
+
+	handle := base
+	for n := 2; ; n++ {
+		if _, err := Auth.GetUser(handle); err != nil {
+			break
+		}
+		handle = fmt.Sprintf("%s%d", base, n)
+	}
+
+	name := strings.TrimSpace(profile.Name)
+	if name == "" {
+		name = handle
+	}
+	user, err := Auth.Signup(name, email, handle, GenerateToken(), false)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create account")
+	}
+	LogActivity("user_joined", "Joined the workspace",
+		"Signed in with single sign-on for the first time", user.ID, "", "user", user.ID)
+	return user, nil
+}
+
+// syncSSOGroups makes a user an administrator when they're in a group
+// mapped to admin, and a member of exactly the mapped teams they have a
+// group for. Nothing changes when no group is mapped to admin or a team,
+// and the last administrator is never demoted so the workspace can't be
+// locked out.
+func syncSSOGroups(settings *Settings, user *User, groups []string) error {
+	mappings, err := settings.SSOGroupMappings()
+	if err != nil {
+		return errors.Wrap(err, "single sign-on group mappings are invalid")
+	}
+
+	mapsAdmin, admin := false, false
+	teams := map[string]bool{}
+	for _, mapping := range mappings {
+		member := slices.Contains(groups, mapping.Group)
+		if mapping.Admin {
+			mapsAdmin = true
+			admin = admin || member
+		} else {
+			teams[mapping.Team] = teams[mapping.Team] || member
+		}
+	}
+
+	if mapsAdmin && user.IsAdmin != admin {
+		if !admin && lastAdmin(user) {
+			log.Printf("SSO: Keeping %s an administrator, they are the last one", user.Email)
+		} else {
+			user.IsAdmin = admin
+			if err := Users.Update(user); err != nil {
+				return errors.Wrap(err, "failed to update administrator status")
+			}
+		}
+	}
+
+	for name, member := range teams {
+		found, err := Teams.Search("WHERE Name = ? COLLATE NOCASE", name)
+		if err != nil || len(found) == 0 {
+			log.Printf("SSO: Group mapping names unknown team %s", name)
+			continue
+		}
+		team := found[0]
+		switch {
+		case member && !team.HasMember(user.ID):
+			err = team.AddMember(user.ID)
+		case !member && team.HasMember(user.ID):
+			err = team.RemoveMember(user.ID)
+		}
+		if err != nil {
+			log.Printf("SSO: Failed to update %s's membership of %s: %v", user.Email, team.Name, err)
+		}
+	}
+	return nil
+}
+
+// lastAdmin reports whether a user is the workspace's only administrator
+func lastAdmin(user *User) bool {
+	if !user.IsAdmin {
+		return false
+	}
+	admins, err := Users.Search("WHERE IsAdmin = ?", true)
+	return err == nil && len(admins) <= 1
+}
@@ -0,0 +1,154 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/The-Skyscape/devtools/pkg/testutils"
+)
+
+func TestSSOSettings(t *testing.T) {
+	SetupTestSecrets(t)
+
+	t.Run("GroupMappings", func(t *testing.T) {
+		settings := &Settings{SSOGroupRoles: "# Developers\nengineering = admin\n\nplatform = team: Backend\n"}
+		mappings, err := settings.SSOGroupMappings()
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 2, len(mappings))
+		testutils.AssertTrue(t, mappings[0].Admin)
+		testutils.AssertEqual(t, "engineering", mappings[0].Group)
+		testutils.AssertEqual(t, "Backend", mappings[1].Team)
+
+		for _, invalid := range []string{"engineering", "engineering = owner", "= admin", "ops = team:"} {
+			settings.SSOGroupRoles = invalid
+			_, err := settings.SSOGroupMappings()
+			testutils.AssertError(t, err)
+		}
+	})
+
+	t.Run("Domains", func(t *testing.T) {
+		settings := &Settings{SSOAllowedDomains: "Example.com\n@corp.example.com, example.com"}
+		testutils.AssertEqual(t, 2, len(settings.SSODomainList()))
+		testutils.AssertTrue(t, settings.ssoDomainAllowed("ada@example.com"))
+		testutils.AssertTrue(t, settings.ssoDomainAllowed("ada@CORP.example.com"))
+		testutils.AssertFalse(t, settings.ssoDomainAllowed("ada@evil.com"))
+		testutils.AssertFalse(t, settings.ssoDomainAllowed("ada@sub.example.com"))
+
+		settings.SSOAllowedDomains = ""
+		testutils.AssertTrue(t, settings.ssoDomainAllowed("ada@anywhere.org"))
+	})
+
+	t.Run("PasswordLoginDisabled", func(t *testing.T) {
+		settings := &Settings{SSOEnabled: true, SSODiscoveryURL: "https://idp.example.com", SSOClientID: "workspace", SSOPasswordOff: true}
+		testutils.AssertFalse(t, settings.PasswordLoginDisabled())
+
+		settings.SSOVerifiedAt = time.Now()
+		testutils.AssertTrue(t, settings.PasswordLoginDisabled())
+
+		t.Setenv("SSO_ALLOW_PASSWORD", "true")
+		testutils.AssertFalse(t, settings.PasswordLoginDisabled())
+	})
+
+	t.Run("ClientSecret", func(t *testing.T) {
+		testutils.AssertNoError(t, StoreSSOClientSecret("s3cret"))
+		secret, err := GetSSOClientSecret()
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, "s3cret", secret)
+
+		testutils.AssertNoError(t, DeleteSSOClientSecret())
+		_, err = GetSSOClientSecret()
+		testutils.AssertError(t, err)
+	})
+}
+
+func TestSSOSignIn(t *testing.T) {
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+
+	admin := CreateTestUser(t, db, "admin@example.com")
+	admin.IsAdmin = true
+	testutils.AssertNoError(t, Users.Update(admin))
+	existing := CreateTestUser(t, db, "grace@example.com")
+
+	team, err := CreateTeam("Backend", "", "", admin.ID)
+	testutils.AssertNoError(t, err)
+
+	settings := &Settings{
+		SSOEnabled:        true,
+		SSOAllowedDomains: "example.com",
+		SSOGroupRoles:     "engineering = admin\nplatform = team:Backend",
+	}
+
+	t.Run("LinksExistingAccount", func(t *testing.T) {
+		user, err := SSOSignIn(settings, SSOProfile{Issuer: "https://idp", Subject: "grace", Email: "Grace@example.com", EmailVerified: true})
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, existing.ID, user.ID)
+
+		// Recognized by the identity after the email changes
+		user, err = SSOSignIn(settings, SSOProfile{Issuer: "https://idp", Subject: "grace", Email: "grace.hopper@example.com"})
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, existing.ID, user.ID)
+	})
+
+	t.Run("DoesNotLinkUnverifiedEmail", func(t *testing.T) {
+		settings.SSOAutoCreate = true
+		defer func() { settings.SSOAutoCreate = false }()
+
+		profile := SSOProfile{Issuer: "https://idp", Subject: "impostor", Email: "admin@example.com"}
+		_, err := SSOSignIn(settings, profile)
+		testutils.AssertError(t, err)
+
+		identities, err := SSOIdentities.Search("WHERE Subject = ?", "impostor")
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 0, len(identities))
+	})
+
+	t.Run("RejectsOtherDomains", func(t *testing.T) {
+		_, err := SSOSignIn(settings, SSOProfile{Issuer: "https://idp", Subject: "mallory", Email: "mallory@evil.com", EmailVerified: true})
+		testutils.AssertError(t, err)
+	})
+
+	t.Run("ProvisionsWhenAllowed", func(t *testing.T) {
+		profile := SSOProfile{Issuer: "https://idp", Subject: "ada", Email: "ada@example.com", Name: "Ada", Groups: []string{"platform"}}
+		_, err := SSOSignIn(settings, profile)
+		testutils.AssertError(t, err)
+
+		settings.SSOAutoCreate = true
+		user, err := SSOSignIn(settings, profile)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, "ada@example.com", user.Email)
+		testutils.AssertEqual(t, "ada", user.Handle)
+		testutils.AssertFalse(t, user.IsAdmin)
+		testutils.AssertTrue(t, team.HasMember(user.ID))
+
+		// Group changes are picked up on the next sign-in
+		profile.Groups = []string{"engineering"}
+		user, err = SSOSignIn(settings, profile)
+		testutils.AssertNoError(t, err)
+		testutils.AssertTrue(t, user.IsAdmin)
+		testutils.AssertFalse(t, team.HasMember(user.ID))
+	})
+
+	t.Run("UniqueHandles", func(t *testing.T) {
+		_, err := SSOSignIn(settings, SSOProfile{Issuer: "https://other-idp", Subject: "ada", Email: "ada@corp.example.com", Username: "ada"})
+		testutils.AssertError(t, err) // corp.example.com isn't allowed
+
+		settings.SSOAllowedDomains = ""
+		user, err := SSOSignIn(settings, SSOProfile{Issuer: "https://other-idp", Subject: "ada", Email: "ada@corp.example.com", Username: "ada", EmailVerified: true})
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, "ada2", user.Handle)
+	})
+
+	t.Run("KeepsLastAdmin", func(t *testing.T) {
+		// Ada became an administrator above, so the original one can be
+		// demoted, but then Ada is the last one
+		user, err := SSOSignIn(settings, SSOProfile{Issuer: "https://idp", Subject: "admin", Email: "admin@example.com", EmailVerified: true})
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, admin.ID, user.ID)
+		testutils.AssertFalse(t, user.IsAdmin)
+
+		user, err = SSOSignIn(settings, SSOProfile{Issuer: "https://idp", Subject: "ada", Email: "ada@example.com"})
+		testutils.AssertNoError(t, err)
+		testutils.AssertTrue(t, user.IsAdmin)
+	})
+}
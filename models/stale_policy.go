@@ -0,0 +1,326 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/The-Skyscape/devtools/pkg/application"
+	"github.com/pkg/errors"
+)
+
+// Default stale policy, applied to repositories without a saved policy
+const (
+	DefaultDaysUntilStale = 30
+	DefaultDaysUntilClose = 7
+	DefaultExemptLabels   = "keep-open\nsecurity"
+)
+
+// StaleLabel is the label put on issues marked stale
+const StaleLabel = "stale"
+
+// staleAuthorID is the author of stale notices, whose comments don't count
+// as activity
+const staleAuthorID = "system"
+
+// Actions a stale policy takes on an issue or pull request
+const (
+	StaleActionMark   = "mark"   // Warn and label it stale
+	StaleActionClose  = "close"  // Close it after the warning went unanswered
+	StaleActionUnmark = "unmark" // Drop the stale label after new activity
+)
+
+// StalePolicy decides when the AI queue marks a repository's inactive
+// issues and pull requests stale and when it closes them. It is stored with
+// the repository ID as its ID.
+type StalePolicy struct {
+	application.Model
+	RepoID         string
+	Enabled        bool   // Repositories can opt out of stale automation
+	DryRun         bool   // Log what would happen without commenting, labeling or closing
+	IncludePRs     bool   // Pull requests go stale as well as issues
+	DaysUntilStale int    // Days without activity before the warning
+	DaysUntilClose int    // Days after the warning before closing, 0 never closes
+	ExemptLabels   string // One label per line, issues with any of them never go stale
+	UpdatedBy      string
+}
+
+// Table returns the database table name
+func (*StalePolicy) Table() string { return "stale_policies" }
+
+// StaleMark records when an issue or pull request was marked stale
+type StaleMark struct {
+	application.Model
+	RepoID     string
+	EntityType string // "issue" or "pr"
+	EntityID   string
+}
+
+// Table returns the database table name
+func (*StaleMark) Table() string { return "stale_marks" }
+
+// StaleAction is one change a stale policy makes, or would make in a dry run
+type StaleAction struct {
+	Action       string // StaleActionMark, StaleActionClose or StaleActionUnmark
+	RepoID       string
+	EntityType   string // "issue" or "pr"
+	EntityID     string
+	Title        string
+	URL          string
+	LastActivity time.Time
+}
+
+// DefaultStalePolicy returns the policy used until an admin configures one
+func DefaultStalePolicy(repoID string) *StalePolicy {
+	return &StalePolicy{
+		Model:          DB.NewModel(repoID),
+		RepoID:         repoID,
+		Enabled:        true,
+		IncludePRs:     true,
+		DaysUntilStale: DefaultDaysUntilStale,
+		DaysUntilClose: DefaultDaysUntilClose,
+		ExemptLabels:   DefaultExemptLabels,
+	}
+}
+
+// GetStalePolicy returns the repository's stale policy, falling back to the
+// defaults when none has been saved
+func GetStalePolicy(repoID string) *StalePolicy {
+	if policy, err := StalePolicies.Get(repoID); err == nil {
+		return policy
+	}
+	return DefaultStalePolicy(repoID)
+}
+
+// SaveStalePolicy validates and stores a repository's stale policy
+func SaveStalePolicy(policy *StalePolicy, userID string) (*StalePolicy, error) {
+	if policy.DaysUntilStale < 1 || policy.DaysUntilStale > 365 {
+		return nil, errors.New("days until stale must be between 1 and 365")
+	}
+	if policy.DaysUntilClose < 0 || policy.DaysUntilClose > 365 {
+		return nil, errors.New("days until close must be between 0 and 365")
+	}
+
+	labels := policy.ExemptLabelList()
+	policy.ExemptLabels = strings.Join(labels, "\n")
+	policy.UpdatedBy = userID
+
+	if _, err := StalePolicies.Get(policy.ID); err != nil {
+		return StalePolicies.Insert(policy)
+	}
+	policy.UpdatedAt = time.Now()
+	return policy, StalePolicies.Update(policy)
+}
+
+// ExemptLabelList returns the lowercased labels that keep issues from going
+// stale
+func (p *StalePolicy) ExemptLabelList() []string {
+	var labels []string
+	for _, label := range splitPolicyList(p.ExemptLabels) {
+		labels = append(labels, strings.ToLower(label))
+	}
+	return labels
+}
+
+// Plan works out what the policy does to the repository's open issues and
+// pull requests at now, without changing anything
+func (p *StalePolicy) Plan(now time.Time) ([]*StaleAction, error) {
+	issues, err := Issues.Search("WHERE RepoID = ? AND Status = ? ORDER BY UpdatedAt ASC", p.RepoID, string(IssueStatusOpen))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find open issues")
+	}
+
+	var actions []*StaleAction
+	for _, issue := range issues {
+		action, err := p.planItem(now, &StaleAction{
+			RepoID:       p.RepoID,
+			EntityType:   "issue",
+			EntityID:     issue.ID,
+			Title:        issue.Title,
+			URL:          "/repos/" + issue.RepoID + "/issues/" + issue.ID,
+			LastActivity: issue.UpdatedAt,
+		}, p.exempt(issue))
+		if err != nil {
+			return nil, err
+		}
+		if action != nil {
+			actions = append(actions, action)
+		}
+	}
+
+	if !p.IncludePRs {
+		return actions, nil
+	}
+
+	prs, err := PullRequests.Search("WHERE RepoID = ? AND Status = 'open' ORDER BY UpdatedAt ASC", p.RepoID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find open pull requests")
+	}
+	for _, pr := range prs {
+		action, err := p.planItem(now, &StaleAction{
+			RepoID:       p.RepoID,
+			EntityType:   "pr",
+			EntityID:     pr.ID,
+			Title:        pr.Title,
+			URL:          pr.URL(),
+			LastActivity: pr.UpdatedAt,
+		}, false)
+		if err != nil {
+			return nil, err
+		}
+		if action != nil {
+			actions = append(actions, action)
+		}
+	}
+	return actions, nil
+}
+
+// planItem decides what happens to one issue or pull request, returning nil
+// when it's left alone
+func (p *StalePolicy) planItem(now time.Time, item *StaleAction, exempt bool) (*StaleAction, error) {
+	comments, err := Comments.Search("WHERE EntityType = ? AND EntityID = ? AND AuthorID != ? ORDER BY CreatedAt DESC LIMIT 1",
+		item.EntityType, item.EntityID, staleAuthorID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to check recent comments")
+	}
+	if len(comments) > 0 && comments[0].CreatedAt.After(item.LastActivity) {
+		item.LastActivity = comments[0].CreatedAt
+	}
+
+	mark := GetStaleMark(item.EntityType, item.EntityID)
+	switch {
+	case mark == nil && !exempt && now.Sub(item.LastActivity) >= staleDays(p.DaysUntilStale):
+		item.Action = StaleActionMark
+	case mark != nil && (exempt || item.LastActivity.After(mark.CreatedAt)):
+		item.Action = StaleActionUnmark
+	case mark != nil && p.DaysUntilClose > 0 && now.Sub(mark.CreatedAt) >= staleDays(p.DaysUntilClose):
+		item.Action = StaleActionClose
+	default:
+		return nil, nil
+	}
+	return item, nil
+}
+
+// exempt reports whether the issue has one of the policy's exempt labels
+func (p *StalePolicy) exempt(issue *Issue) bool {
+	for _, label := range p.ExemptLabelList() {
+		if issue.HasLabel(label) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetStaleMark returns the stale mark on an issue or pull request, or nil
+// when it isn't stale
+func GetStaleMark(entityType, entityID string) *StaleMark {
+	marks, err := StaleMarks.Search("WHERE EntityType = ? AND EntityID = ? LIMIT 1", entityType, entityID)
+	if err != nil || len(marks) == 0 {
+		return nil
+	}
+	return marks[0]
+}
+
+// ApplyStaleAction carries out a planned action: posting the warning and
+// label, closing with a comment, or clearing the mark. now is the time
+// the action was planned for, which new marks are dated with.
+func ApplyStaleAction(policy *StalePolicy, action *StaleAction, now time.Time) error {
+	switch action.Action {
+	case StaleActionMark:
+		noun := "issue"
+		if action.EntityType == "pr" {
+			noun = "pull request"
+		}
+		body := fmt.Sprintf("This %s has had no activity for %d days and has been marked stale.", noun, policy.DaysUntilStale)
+		if policy.DaysUntilClose > 0 {
+			body += fmt.Sprintf(" It will be closed in %d days unless there is new activity.", policy.DaysUntilClose)
+		}
+		body += " Comment or update it to keep it open."
+		if err := addStaleComment(action, body); err != nil {
+			return err
+		}
+		if action.EntityType == "issue" {
+			if err := setStaleLabel(action, true); err != nil {
+				return err
+			}
+		}
+		mark := &StaleMark{Model: DB.NewModel(""), RepoID: action.RepoID, EntityType: action.EntityType, EntityID: action.EntityID}
+		mark.CreatedAt = now
+		_, err := StaleMarks.Insert(mark)
+		return errors.Wrap(err, "failed to mark as stale")
+
+	case StaleActionClose:
+		if action.EntityType == "pr" {
+			pr, err := PullRequests.Get(action.EntityID)
+			if err != nil {
+				return errors.Wrap(err, "failed to get pull request")
+			}
+			pr.Status = "closed"
+			if err := PullRequests.Update(pr); err != nil {
+				return errors.Wrap(err, "failed to close pull request")
+			}
+			RunProjectRules("pr_closed", pr.RepoID, "", pr.ID)
+		} else {
+			issue, err := Issues.Get(action.EntityID)
+			if err != nil {
+				return errors.Wrap(err, "failed to get issue")
+			}
+			issue.Status = IssueStatusClosed
+			if err := Issues.Update(issue); err != nil {
+				return errors.Wrap(err, "failed to close issue")
+			}
+			RunProjectRules("issue_closed", issue.RepoID, issue.ID, "")
+		}
+		body := fmt.Sprintf("Closed after %d days marked stale without activity. Reopen it if it's still relevant.", policy.DaysUntilClose)
+		if err := addStaleComment(action, body); err != nil {
+			return err
+		}
+		return clearStaleMark(action)
+
+	case StaleActionUnmark:
+		if action.EntityType == "issue" {
+			if err := setStaleLabel(action, false); err != nil {
+				return err
+			}
+		}
+		return clearStaleMark(action)
+	}
+	return errors.Errorf("unknown stale action %q", action.Action)
+}
+
+// addStaleComment posts a stale notice on an issue or pull request
+func addStaleComment(action *StaleAction, body string) error {
+	_, err := Comments.Insert(&Comment{
+		Body:       body,
+		AuthorID:   staleAuthorID,
+		RepoID:     action.RepoID,
+		EntityType: action.EntityType,
+		EntityID:   action.EntityID,
+	})
+	return errors.Wrap(err, "failed to post stale notice")
+}
+
+// setStaleLabel adds or removes the stale label on an issue
+func setStaleLabel(action *StaleAction, add bool) error {
+	tag, err := GetOrCreateTag(StaleLabel, action.RepoID)
+	if err != nil {
+		return errors.Wrap(err, "failed to get stale label")
+	}
+	if add {
+		return AddLabelToIssue(action.EntityID, tag.ID, staleAuthorID)
+	}
+	return RemoveLabelFromIssue(action.EntityID, tag.ID)
+}
+
+// clearStaleMark removes the stale mark from an issue or pull request
+func clearStaleMark(action *StaleAction) error {
+	if mark := GetStaleMark(action.EntityType, action.EntityID); mark != nil {
+		return errors.Wrap(StaleMarks.Delete(mark), "failed to clear stale mark")
+	}
+	return nil
+}
+
+// staleDays converts a number of days to a duration
+func staleDays(n int) time.Duration {
+	return time.Duration(n) * 24 * time.Hour
+}
@@ -0,0 +1,120 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/The-Skyscape/devtools/pkg/testutils"
+)
+
+func TestStalePolicy(t *testing.T) {
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+
+	t.Run("DefaultsWithoutSavedPolicy", func(t *testing.T) {
+		policy := GetStalePolicy("stale-repo")
+		testutils.AssertTrue(t, policy.Enabled)
+		testutils.AssertFalse(t, policy.DryRun)
+		testutils.AssertEqual(t, DefaultDaysUntilStale, policy.DaysUntilStale)
+		labels := policy.ExemptLabelList()
+		testutils.AssertEqual(t, 2, len(labels))
+		testutils.AssertEqual(t, "keep-open", labels[0])
+	})
+
+	t.Run("SaveAndLoad", func(t *testing.T) {
+		policy := GetStalePolicy("stale-repo")
+		policy.DryRun = true
+		policy.DaysUntilStale = 60
+		policy.ExemptLabels = "Pinned, roadmap\n\n"
+
+		_, err := SaveStalePolicy(policy, "admin")
+		testutils.AssertNoError(t, err)
+
+		saved := GetStalePolicy("stale-repo")
+		testutils.AssertTrue(t, saved.DryRun)
+		testutils.AssertEqual(t, 60, saved.DaysUntilStale)
+		testutils.AssertEqual(t, "pinned\nroadmap", saved.ExemptLabels)
+	})
+
+	t.Run("RejectsInvalidPolicies", func(t *testing.T) {
+		policy := DefaultStalePolicy("stale-invalid")
+		policy.DaysUntilStale = 0
+		_, err := SaveStalePolicy(policy, "admin")
+		testutils.AssertError(t, err)
+
+		policy = DefaultStalePolicy("stale-invalid")
+		policy.DaysUntilClose = -1
+		_, err = SaveStalePolicy(policy, "admin")
+		testutils.AssertError(t, err)
+	})
+
+	t.Run("MarksClosesAndExempts", func(t *testing.T) {
+		policy := DefaultStalePolicy("stale-plan")
+		issue, err := Issues.Insert(&Issue{Title: "Old bug", Status: IssueStatusOpen, RepoID: policy.RepoID})
+		testutils.AssertNoError(t, err)
+		pinned, err := Issues.Insert(&Issue{Title: "Pinned", Status: IssueStatusOpen, RepoID: policy.RepoID})
+		testutils.AssertNoError(t, err)
+		tag, err := GetOrCreateTag("keep-open", policy.RepoID)
+		testutils.AssertNoError(t, err)
+		testutils.AssertNoError(t, AddLabelToIssue(pinned.ID, tag.ID, "admin"))
+
+		actions, err := policy.Plan(time.Now())
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 0, len(actions))
+
+		// A month later the unlabeled issue goes stale
+		later := time.Now().AddDate(0, 0, DefaultDaysUntilStale)
+		actions, err = policy.Plan(later)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 1, len(actions))
+		testutils.AssertEqual(t, StaleActionMark, actions[0].Action)
+		testutils.AssertEqual(t, issue.ID, actions[0].EntityID)
+
+		testutils.AssertNoError(t, ApplyStaleAction(policy, actions[0], later))
+		testutils.AssertTrue(t, GetStaleMark("issue", issue.ID) != nil)
+		marked, _ := Issues.Get(issue.ID)
+		testutils.AssertTrue(t, marked.HasLabel(StaleLabel))
+
+		// Marked issues wait out the warning before closing
+		actions, err = policy.Plan(later)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 0, len(actions))
+
+		closeAt := later.AddDate(0, 0, DefaultDaysUntilClose)
+		actions, err = policy.Plan(closeAt)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 1, len(actions))
+		testutils.AssertEqual(t, StaleActionClose, actions[0].Action)
+
+		testutils.AssertNoError(t, ApplyStaleAction(policy, actions[0], closeAt))
+		closed, _ := Issues.Get(issue.ID)
+		testutils.AssertEqual(t, IssueStatusClosed, closed.Status)
+		testutils.AssertTrue(t, GetStaleMark("issue", issue.ID) == nil)
+	})
+
+	t.Run("ExemptLabelClearsMark", func(t *testing.T) {
+		policy := DefaultStalePolicy("stale-unmark")
+		issue, err := Issues.Insert(&Issue{Title: "Quiet", Status: IssueStatusOpen, RepoID: policy.RepoID})
+		testutils.AssertNoError(t, err)
+
+		later := time.Now().AddDate(0, 0, DefaultDaysUntilStale)
+		actions, err := policy.Plan(later)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 1, len(actions))
+		testutils.AssertNoError(t, ApplyStaleAction(policy, actions[0], later))
+
+		tag, err := GetOrCreateTag("security", policy.RepoID)
+		testutils.AssertNoError(t, err)
+		testutils.AssertNoError(t, AddLabelToIssue(issue.ID, tag.ID, "admin"))
+
+		actions, err = policy.Plan(later)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 1, len(actions))
+		testutils.AssertEqual(t, StaleActionUnmark, actions[0].Action)
+
+		testutils.AssertNoError(t, ApplyStaleAction(policy, actions[0], later))
+		unmarked, _ := Issues.Get(issue.ID)
+		testutils.AssertFalse(t, unmarked.HasLabel(StaleLabel))
+		testutils.AssertTrue(t, GetStaleMark("issue", issue.ID) == nil)
+	})
+}
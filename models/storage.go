@@ -0,0 +1,81 @@
+package models
+
+import (
+	"log"
+	"sync"
+
+	"workspace/internal/storage"
+
+	"github.com/The-Skyscape/devtools/pkg/database"
+)
+
+var (
+	storageMu     sync.RWMutex
+	storageLoaded bool
+	// configuredStorage is the store chosen in settings, nil for the data
+	// directory, which is looked up on each use so DATA_DIR is honoured
+	configuredStorage storage.Store
+)
+
+// Storage returns where uploaded and generated files are kept: the backend
+// chosen in settings, or the data directory
+func Storage() storage.Store {
+	storageMu.RLock()
+	store, loaded := configuredStorage, storageLoaded
+	storageMu.RUnlock()
+
+	if !loaded {
+		settings, err := GetSettings()
+		if err == nil {
+			if err = ConfigureStorage(settings); err == nil {
+				return Storage()
+			}
+		}
+		log.Printf("Storage: Using the data directory, the configured backend failed: %v", err)
+		storageMu.Lock()
+		storageLoaded = true
+		storageMu.Unlock()
+	}
+
+	if store == nil {
+		return storage.NewLocal(database.DataDir())
+	}
+	return store
+}
+
+// StorageConfig returns the file storage backend the settings describe
+func (s *Settings) StorageConfig() storage.Config {
+	return storage.Config{
+		Backend:   s.StorageBackend,
+		Path:      s.StoragePath,
+		Endpoint:  s.StorageEndpoint,
+		Bucket:    s.StorageBucket,
+		Region:    s.StorageRegion,
+		Prefix:    s.StoragePrefix,
+		AccessKey: s.StorageAccessKey,
+		SecretKey: s.StorageSecretKey,
+	}
+}
+
+// UsesDefaultStorage returns true when files are kept in the data directory
+func (s *Settings) UsesDefaultStorage() bool {
+	return (s.StorageBackend == "" || s.StorageBackend == storage.BackendLocal) && s.StoragePath == ""
+}
+
+// ConfigureStorage switches file storage to the backend in settings. Files
+// already stored elsewhere are not moved.
+func ConfigureStorage(settings *Settings) error {
+	var store storage.Store
+	if !settings.UsesDefaultStorage() {
+		var err error
+		if store, err = storage.New(settings.StorageConfig()); err != nil {
+			return err
+		}
+	}
+
+	storageMu.Lock()
+	configuredStorage = store
+	storageLoaded = true
+	storageMu.Unlock()
+	return nil
+}
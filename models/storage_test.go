@@ -0,0 +1,56 @@
+package models
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/The-Skyscape/devtools/pkg/testutils"
+)
+
+func TestStorage(t *testing.T) {
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+	t.Setenv("DATA_DIR", t.TempDir())
+
+	t.Run("DefaultsToDataDir", func(t *testing.T) {
+		settings := &Settings{}
+		testutils.AssertTrue(t, settings.UsesDefaultStorage())
+		testutils.AssertNoError(t, ConfigureStorage(settings))
+		testutils.AssertTrue(t, strings.Contains(Storage().String(), os.Getenv("DATA_DIR")))
+	})
+
+	t.Run("LocalDirectory", func(t *testing.T) {
+		dir := t.TempDir()
+		settings := &Settings{StorageBackend: "local", StoragePath: dir}
+		testutils.AssertFalse(t, settings.UsesDefaultStorage())
+		testutils.AssertNoError(t, ConfigureStorage(settings))
+		defer ConfigureStorage(&Settings{})
+
+		artifact := &ActionArtifact{Model: DB.NewModel(""), ActionID: "action-1", FileName: "build.log"}
+		testutils.AssertNoError(t, StoreArtifact(artifact, []byte("ok")))
+		_, err := os.Stat(filepath.Join(dir, "artifacts", "action-1", artifact.ID))
+		testutils.AssertNoError(t, err)
+
+		file, err := artifact.Open()
+		testutils.AssertNoError(t, err)
+		content, _ := io.ReadAll(file)
+		file.Close()
+		testutils.AssertEqual(t, "ok", string(content))
+	})
+
+	t.Run("ArtifactInDatabase", func(t *testing.T) {
+		artifact := &ActionArtifact{Content: []byte("legacy")}
+		file, err := artifact.Open()
+		testutils.AssertNoError(t, err)
+		content, _ := io.ReadAll(file)
+		testutils.AssertEqual(t, "legacy", string(content))
+	})
+
+	t.Run("UnknownBackend", func(t *testing.T) {
+		err := ConfigureStorage(&Settings{StorageBackend: "ftp"})
+		testutils.AssertError(t, err)
+	})
+}
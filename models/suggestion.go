@@ -0,0 +1,154 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Suggestion is a change proposed in a review comment with a ```suggestion
+// block, replacing the line the comment is on
+type Suggestion struct {
+	Lines []string // Replacement lines, none to delete the line
+	Note  string   // The rest of the comment
+}
+
+// suggestionBlock matches a ```suggestion fenced block
+var suggestionBlock = regexp.MustCompile("(?s)```suggestion[ \\t]*\\r?\\n(.*?)```")
+
+// ParseSuggestion returns the suggestion in a comment body, or nil when it
+// has none. Only the first block counts.
+func ParseSuggestion(body string) *Suggestion {
+	body = strings.ReplaceAll(body, "\r\n", "\n")
+	loc := suggestionBlock.FindStringSubmatchIndex(body)
+	if loc == nil {
+		return nil
+	}
+
+	suggestion := &Suggestion{
+		Note: strings.TrimSpace(body[:loc[0]] + body[loc[1]:]),
+	}
+	if text := body[loc[2]:loc[3]]; text != "" {
+		suggestion.Lines = strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	}
+	return suggestion
+}
+
+// Suggestion returns the change the comment suggests, or nil
+func (c *Comment) Suggestion() *Suggestion {
+	if c.FilePath == "" || c.LineNumber == 0 {
+		return nil
+	}
+	return ParseSuggestion(c.Body)
+}
+
+// ApplySuggestion commits a review comment's suggestion to the pull
+// request's branch. The commit is authored by whoever suggested it and
+// committed by the user applying it, and the comment's thread is resolved.
+// Nothing is committed if the line has changed since the suggestion was made.
+func ApplySuggestion(pr *PullRequest, commentID, userID, committerName, committerEmail string) (string, error) {
+	comment, err := Comments.Get(commentID)
+	if err != nil {
+		return "", errors.New("review comment not found")
+	}
+	root, err := reviewThreadRoot(pr, commentID)
+	if err != nil {
+		return "", err
+	}
+	suggestion := comment.Suggestion()
+	switch {
+	case suggestion == nil:
+		return "", errors.New("the comment doesn't suggest a change")
+	case comment.AppliedIn != "":
+		return "", errors.New("the suggestion has already been applied")
+	case pr.Status != "open":
+		return "", errors.New("pull request is not open")
+	case root.CommitSHA == "":
+		return "", errors.New("the suggestion isn't on a commit and can't be applied")
+	case IsPushProtected(pr.RepoID, pr.CompareBranch):
+		return "", errors.Errorf("%s is protected, apply the suggestion in another branch", pr.CompareBranch)
+	}
+
+	repo, err := pr.Repository()
+	if err != nil {
+		return "", errors.New("repository not found")
+	}
+	head := branchHead(repo, pr.CompareBranch)
+	if head == "" {
+		return "", errors.Errorf("branch %s not found", pr.CompareBranch)
+	}
+
+	stdout, _, err := repo.Git("show", head+":"+root.FilePath)
+	if err != nil {
+		return "", errors.Errorf("%s is no longer in %s", root.FilePath, pr.CompareBranch)
+	}
+	content := stdout.String()
+	trailing := strings.HasSuffix(content, "\n")
+	lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	if root.LineNumber > len(lines) || lines[root.LineNumber-1] != root.LineText {
+		return "", errors.New("the line has changed since the suggestion was made")
+	}
+
+	changed := append(append(append([]string{}, lines[:root.LineNumber-1]...), suggestion.Lines...), lines[root.LineNumber:]...)
+	content = strings.Join(changed, "\n")
+	if trailing && len(changed) > 0 {
+		content += "\n"
+	}
+
+	authorName, authorEmail := committerName, committerEmail
+	if author, err := comment.Author(); err == nil && author != nil {
+		authorName, authorEmail = author.Name, author.Email
+	}
+	git, done, err := scratchIndex(repo,
+		"GIT_AUTHOR_NAME="+authorName,
+		"GIT_AUTHOR_EMAIL="+authorEmail,
+		"GIT_COMMITTER_NAME="+committerName,
+		"GIT_COMMITTER_EMAIL="+committerEmail,
+	)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to apply suggestion")
+	}
+	defer done()
+
+	if _, err := git("", "read-tree", head); err != nil {
+		return "", errors.Wrap(err, "failed to read branch")
+	}
+	mode, err := git("", "ls-tree", "--format=%(objectmode)", head, "--", root.FilePath)
+	if err != nil || mode == "" {
+		mode = "100644"
+	}
+	blob, err := git(content, "hash-object", "-w", "--stdin")
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to store %s", root.FilePath)
+	}
+	if _, err := git("", "update-index", "--cacheinfo", mode+","+blob+","+root.FilePath); err != nil {
+		return "", errors.Wrapf(err, "failed to stage %s", root.FilePath)
+	}
+	tree, err := git("", "write-tree")
+	if err != nil {
+		return "", errors.Wrap(err, "failed to write tree")
+	}
+
+	message := fmt.Sprintf("Apply suggestion to %s\n\nSuggested in review of line %d.\n", root.FilePath, root.LineNumber)
+	commit, err := git(message, "commit-tree", tree, "-p", head)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to commit suggestion")
+	}
+
+	// Only moves the branch if nobody pushed to it in the meantime
+	if _, _, err := repo.Git("update-ref", "refs/heads/"+pr.CompareBranch, commit, head); err != nil {
+		return "", errors.Errorf("%s changed while applying the suggestion, reload and try again", pr.CompareBranch)
+	}
+	repo.UpdateLastActivity()
+
+	comment.AppliedIn = commit
+	if err := Comments.Update(comment); err != nil {
+		return "", errors.Wrap(err, "failed to record applied suggestion")
+	}
+	if _, err := ResolveReviewThread(pr, root.ID, userID, true); err != nil {
+		return "", err
+	}
+	return commit, nil
+}
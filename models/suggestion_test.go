@@ -0,0 +1,124 @@
+package models
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/The-Skyscape/devtools/pkg/testutils"
+)
+
+func TestParseSuggestion(t *testing.T) {
+	t.Run("NoSuggestion", func(t *testing.T) {
+		testutils.AssertTrue(t, ParseSuggestion("Looks good") == nil)
+		testutils.AssertTrue(t, ParseSuggestion("```go\nx := 1\n```") == nil)
+	})
+
+	t.Run("ReplacementLines", func(t *testing.T) {
+		suggestion := ParseSuggestion("Use a constant:\r\n```suggestion\r\nconst x = 1\r\nconst y = 2\r\n```\r\nThanks")
+		testutils.AssertEqual(t, 2, len(suggestion.Lines))
+		testutils.AssertEqual(t, "const x = 1", suggestion.Lines[0])
+		testutils.AssertEqual(t, "const y = 2", suggestion.Lines[1])
+		testutils.AssertEqual(t, "Use a constant:\n\nThanks", suggestion.Note)
+	})
+
+	t.Run("EmptyDeletesLine", func(t *testing.T) {
+		suggestion := ParseSuggestion("```suggestion\n```")
+		testutils.AssertEqual(t, 0, len(suggestion.Lines))
+		testutils.AssertEqual(t, "", suggestion.Note)
+
+		suggestion = ParseSuggestion("```suggestion\n\n```")
+		testutils.AssertEqual(t, 1, len(suggestion.Lines))
+		testutils.AssertEqual(t, "", suggestion.Lines[0])
+	})
+}
+
+func TestApplySuggestion(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+	t.Setenv("DATA_DIR", t.TempDir())
+
+	repo, err := Repositories.Insert(&Repository{Name: "suggestions"})
+	testutils.AssertNoError(t, err)
+
+	work := t.TempDir()
+	git := func(args ...string) string {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = work
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=Test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=Test", "GIT_COMMITTER_EMAIL=test@example.com")
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %s: %s", strings.Join(args, " "), out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	git("init", "--bare", "-b", "main", repo.Path())
+	git("init", "-b", "main")
+	os.WriteFile(filepath.Join(work, "file.txt"), []byte("one\ntwo\nthree\n"), 0644)
+	git("add", "file.txt")
+	git("commit", "-m", "first")
+	git("push", repo.Path(), "main")
+	git("checkout", "-b", "feature")
+	os.WriteFile(filepath.Join(work, "file.txt"), []byte("one\nto\nthree\n"), 0644)
+	git("commit", "-am", "typo")
+	git("push", repo.Path(), "feature")
+
+	reviewer := CreateTestUser(t, db, "reviewer@example.com")
+	pr, err := PullRequests.Insert(&PullRequest{
+		Title: "Feature", RepoID: repo.ID, AuthorID: "author",
+		BaseBranch: "main", CompareBranch: "feature", Status: "open",
+	})
+	testutils.AssertNoError(t, err)
+
+	plain, err := CreateReviewComment(pr, reviewer.ID, "Typo", "file.txt", 2)
+	testutils.AssertNoError(t, err)
+	comment, err := CreateReviewComment(pr, reviewer.ID, "```suggestion\ntwo\n```", "file.txt", 2)
+	testutils.AssertNoError(t, err)
+	stale, err := CreateReviewComment(pr, reviewer.ID, "```suggestion\nTWO\n```", "file.txt", 2)
+	testutils.AssertNoError(t, err)
+
+	t.Run("NeedsSuggestion", func(t *testing.T) {
+		_, err := ApplySuggestion(pr, plain.ID, "author", "Author", "author@example.com")
+		testutils.AssertError(t, err)
+	})
+
+	t.Run("CommitsToBranch", func(t *testing.T) {
+		commit, err := ApplySuggestion(pr, comment.ID, "author", "Author", "author@example.com")
+		testutils.AssertNoError(t, err)
+
+		head, _ := pr.HeadCommit()
+		testutils.AssertEqual(t, commit, head)
+		out, _, err := repo.Git("show", head+":file.txt")
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, "one\ntwo\nthree\n", out.String())
+
+		out, _, _ = repo.Git("log", "-1", "--format=%ae %ce", head)
+		testutils.AssertEqual(t, reviewer.Email+" author@example.com", strings.TrimSpace(out.String()))
+
+		thread, err := Comments.Get(comment.ID)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, commit, thread.AppliedIn)
+		testutils.AssertTrue(t, thread.Resolved)
+	})
+
+	t.Run("OnlyOnce", func(t *testing.T) {
+		_, err := ApplySuggestion(pr, comment.ID, "author", "Author", "author@example.com")
+		testutils.AssertError(t, err)
+	})
+
+	t.Run("LineChanged", func(t *testing.T) {
+		head, _ := pr.HeadCommit()
+		_, err := ApplySuggestion(pr, stale.ID, "author", "Author", "author@example.com")
+		testutils.AssertError(t, err)
+		after, _ := pr.HeadCommit()
+		testutils.AssertEqual(t, head, after)
+	})
+}
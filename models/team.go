@@ -0,0 +1,366 @@
+package models
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/The-Skyscape/devtools/pkg/application"
+	"github.com/The-Skyscape/devtools/pkg/authentication"
+	"github.com/pkg/errors"
+)
+
+// Roles a team can have on a repository, each allowing what the ones
+// before it do
+const (
+	RoleRead  = "read"  // Browse, clone, open issues and comment
+	RoleWrite = "write" // Push and edit files
+	RoleAdmin = "admin" // Change the repository's settings and webhooks
+)
+
+// Roles lists the repository roles from least to most access
+var Roles = []string{RoleRead, RoleWrite, RoleAdmin}
+
+// roleRank orders roles, unknown roles rank below read
+func roleRank(role string) int {
+	for i, r := range Roles {
+		if r == role {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// Team groups users so repositories can be shared with all of them at
+// once. A team nested under a parent gets the parent's repositories too.
+type Team struct {
+	application.Model
+	Name        string
+	Description string
+	ParentID    string // Team this one is nested under, empty at the top
+	CreatedBy   string
+}
+
+// Table returns the database table name
+func (*Team) Table() string { return "teams" }
+
+// TeamMember makes a user part of a team
+type TeamMember struct {
+	application.Model
+	TeamID string
+	UserID string
+}
+
+// Table returns the database table name
+func (*TeamMember) Table() string { return "team_members" }
+
+// TeamRepo gives a team's members a role on a repository
+type TeamRepo struct {
+	application.Model
+	TeamID string
+	RepoID string
+	Role   string // RoleRead, RoleWrite or RoleAdmin
+}
+
+// Table returns the database table name
+func (*TeamRepo) Table() string { return "team_repos" }
+
+// CreateTeam adds a team, nested under parentID unless it's empty
+func CreateTeam(name, description, parentID, userID string) (*Team, error) {
+	team := &Team{Model: DB.NewModel(""), CreatedBy: userID}
+	if err := team.configure(name, description, parentID); err != nil {
+		return nil, err
+	}
+	team, err := Teams.Insert(team)
+	return team, errors.Wrap(err, "failed to create team")
+}
+
+// Update renames, describes or moves the team
+func (t *Team) Update(name, description, parentID string) error {
+	if err := t.configure(name, description, parentID); err != nil {
+		return err
+	}
+	return errors.Wrap(Teams.Update(t), "failed to update team")
+}
+
+// configure validates and sets the team's name, description and parent.
+// Team names are unique ignoring case, and a team can't be nested under
+// itself or a team nested under it.
+func (t *Team) configure(name, description, parentID string) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return errors.New("team name is required")
+	}
+	if others, err := Teams.Search("WHERE Name = ? COLLATE NOCASE", name); err == nil {
+		for _, other := range others {
+			if other.ID != t.ID {
+				return errors.Errorf("a team named %s already exists", other.Name)
+			}
+		}
+	}
+
+	if parentID != "" {
+		parent, err := Teams.Get(parentID)
+		if err != nil {
+			return errors.New("parent team not found")
+		}
+		if parent.ID == t.ID {
+			return errors.New("a team can't be nested under itself")
+		}
+		for _, ancestor := range parent.Ancestors() {
+			if ancestor.ID == t.ID {
+				return errors.New("a team can't be nested under one of its own teams")
+			}
+		}
+	}
+
+	t.Name = name
+	t.Description = strings.TrimSpace(description)
+	t.ParentID = parentID
+	return nil
+}
+
+// GetTeams returns every team by name
+func GetTeams() ([]*Team, error) {
+	return Teams.Search("ORDER BY Name ASC")
+}
+
+// DeleteTeam removes a team with its members and repository roles. Teams
+// nested under it move up to its parent.
+func DeleteTeam(team *Team) error {
+	children, err := team.Children()
+	if err != nil {
+		return err
+	}
+	for _, child := range children {
+		child.ParentID = team.ParentID
+		if err := Teams.Update(child); err != nil {
+			return errors.Wrap(err, "failed to move nested team")
+		}
+	}
+
+	if err := Teams.Delete(team); err != nil {
+		return errors.Wrap(err, "failed to delete team")
+	}
+	DB.Query("DELETE FROM team_members WHERE TeamID = ?", team.ID).Exec()
+	DB.Query("DELETE FROM team_repos WHERE TeamID = ?", team.ID).Exec()
+	return nil
+}
+
+// Parent returns the team this one is nested under, nil at the top
+func (t *Team) Parent() *Team {
+	if t.ParentID == "" {
+		return nil
+	}
+	parent, err := Teams.Get(t.ParentID)
+	if err != nil {
+		return nil
+	}
+	return parent
+}
+
+// Ancestors returns the teams this one is nested under, nearest first
+func (t *Team) Ancestors() []*Team {
+	var ancestors []*Team
+	seen := map[string]bool{t.ID: true}
+	for parent := t.Parent(); parent != nil && !seen[parent.ID]; parent = parent.Parent() {
+		seen[parent.ID] = true
+		ancestors = append(ancestors, parent)
+	}
+	return ancestors
+}
+
+// Children returns the teams nested directly under this one
+func (t *Team) Children() ([]*Team, error) {
+	return Teams.Search("WHERE ParentID = ? ORDER BY Name ASC", t.ID)
+}
+
+// Members returns the users in the team by name
+func (t *Team) Members() ([]*authentication.User, error) {
+	memberships, err := TeamMembers.Search("WHERE TeamID = ?", t.ID)
+	if err != nil {
+		return nil, err
+	}
+	var members []*authentication.User
+	for _, membership := range memberships {
+		if user, err := Users.Get(membership.UserID); err == nil {
+			members = append(members, user)
+		}
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].Name < members[j].Name })
+	return members, nil
+}
+
+// MemberCount returns how many users are in the team
+func (t *Team) MemberCount() int {
+	return TeamMembers.Count("WHERE TeamID = ?", t.ID)
+}
+
+// HasMember reports whether a user is in the team itself
+func (t *Team) HasMember(userID string) bool {
+	return TeamMembers.Count("WHERE TeamID = ? AND UserID = ?", t.ID, userID) > 0
+}
+
+// AddMember puts a user in the team
+func (t *Team) AddMember(userID string) error {
+	if _, err := Users.Get(userID); err != nil {
+		return errors.New("user not found")
+	}
+	if t.HasMember(userID) {
+		return nil
+	}
+	_, err := TeamMembers.Insert(&TeamMember{Model: DB.NewModel(""), TeamID: t.ID, UserID: userID})
+	return errors.Wrap(err, "failed to add team member")
+}
+
+// RemoveMember takes a user out of the team
+func (t *Team) RemoveMember(userID string) error {
+	err := DB.Query("DELETE FROM team_members WHERE TeamID = ? AND UserID = ?", t.ID, userID).Exec()
+	return errors.Wrap(err, "failed to remove team member")
+}
+
+// Repos returns the team's own repository roles
+func (t *Team) Repos() ([]*TeamRepo, error) {
+	return TeamRepos.Search("WHERE TeamID = ? ORDER BY CreatedAt ASC", t.ID)
+}
+
+// GrantRepo gives the team a role on a repository, replacing the role it
+// had
+func (t *Team) GrantRepo(repoID, role string) error {
+	if roleRank(role) == 0 {
+		return errors.Errorf("unknown role %q", role)
+	}
+	if _, err := Repositories.Get(repoID); err != nil {
+		return errors.New("repository not found")
+	}
+
+	grants, err := TeamRepos.Search("WHERE TeamID = ? AND RepoID = ?", t.ID, repoID)
+	if err != nil {
+		return err
+	}
+	if len(grants) > 0 {
+		grants[0].Role = role
+		return errors.Wrap(TeamRepos.Update(grants[0]), "failed to change team role")
+	}
+	_, err = TeamRepos.Insert(&TeamRepo{Model: DB.NewModel(""), TeamID: t.ID, RepoID: repoID, Role: role})
+	return errors.Wrap(err, "failed to give team access")
+}
+
+// RevokeRepo removes the team's role on a repository
+func (t *Team) RevokeRepo(repoID string) error {
+	err := DB.Query("DELETE FROM team_repos WHERE TeamID = ? AND RepoID = ?", t.ID, repoID).Exec()
+	return errors.Wrap(err, "failed to remove team access")
+}
+
+// Repository returns the repository the role is on
+func (r *TeamRepo) Repository() (*Repository, error) {
+	return Repositories.Get(r.RepoID)
+}
+
+// UserTeams returns the teams a user is in, not counting the teams those
+// are nested under
+func UserTeams(userID string) ([]*Team, error) {
+	memberships, err := TeamMembers.Search("WHERE UserID = ?", userID)
+	if err != nil {
+		return nil, err
+	}
+	var teams []*Team
+	for _, membership := range memberships {
+		if team, err := Teams.Get(membership.TeamID); err == nil {
+			teams = append(teams, team)
+		}
+	}
+	sort.Slice(teams, func(i, j int) bool { return teams[i].Name < teams[j].Name })
+	return teams, nil
+}
+
+// userTeamIDs returns the teams a user is in along with the teams they
+// are nested under, whose repositories the user gets too
+func userTeamIDs(userID string) []string {
+	teams, err := UserTeams(userID)
+	if err != nil {
+		return nil
+	}
+	var ids []string
+	seen := map[string]bool{}
+	for _, team := range teams {
+		for _, t := range append([]*Team{team}, team.Ancestors()...) {
+			if !seen[t.ID] {
+				seen[t.ID] = true
+				ids = append(ids, t.ID)
+			}
+		}
+	}
+	return ids
+}
+
+// teamRoles returns the highest role the user's teams have on each
+// repository they were given
+func teamRoles(userID string) map[string]string {
+	roles := map[string]string{}
+	for _, teamID := range userTeamIDs(userID) {
+		grants, err := TeamRepos.Search("WHERE TeamID = ?", teamID)
+		if err != nil {
+			continue
+		}
+		for _, grant := range grants {
+			if roleRank(grant.Role) > roleRank(roles[grant.RepoID]) {
+				roles[grant.RepoID] = grant.Role
+			}
+		}
+	}
+	return roles
+}
+
+// RepoRole returns the role a user has on a repository, empty for none.
+// Workspace admins administer every repository, anyone can read public
+// ones, and otherwise the highest role of the user's teams, or the teams
+// they're nested under, applies. The user is nil for visitors.
+func RepoRole(user *authentication.User, repo *Repository) string {
+	if user != nil && user.IsAdmin {
+		return RoleAdmin
+	}
+	role := ""
+	if repo.Visibility == "public" {
+		role = RoleRead
+	}
+	if user != nil {
+		if team := teamRoles(user.ID)[repo.ID]; roleRank(team) > roleRank(role) {
+			role = team
+		}
+	}
+	return role
+}
+
+// CheckRepoAccess returns an error unless the user has at least role on
+// the repository. The user is nil for visitors.
+func CheckRepoAccess(user *authentication.User, repoID, role string) error {
+	repo, err := Repositories.Get(repoID)
+	if err != nil {
+		return errors.New("repository not found")
+	}
+	have := RepoRole(user, repo)
+	if have != "" && roleRank(have) >= roleRank(role) {
+		return nil
+	}
+	switch {
+	case user == nil:
+		return errors.New("authentication required")
+	case have == "":
+		return errors.New("access denied - private repository")
+	}
+	return errors.Errorf("%s access required", role)
+}
+
+// TeamRepoIDs returns the repositories a user was given through teams
+func TeamRepoIDs(userID string) []string {
+	var ids []string
+	for repoID := range teamRoles(userID) {
+		ids = append(ids, repoID)
+	}
+	return ids
+}
+
+// DeleteRepoTeamAccess removes the teams' roles on a repository
+func DeleteRepoTeamAccess(repoID string) {
+	DB.Query("DELETE FROM team_repos WHERE RepoID = ?", repoID).Exec()
+}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/The-Skyscape/devtools/pkg/testutils"
+)
+
+func TestTeams(t *testing.T) {
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+
+	admin := CreateTestUser(t, db, "teams-admin@example.com")
+	admin.IsAdmin = true
+	testutils.AssertNoError(t, Users.Update(admin))
+	dev := CreateTestUser(t, db, "teams-dev@example.com")
+	outsider := CreateTestUser(t, db, "teams-outsider@example.com")
+
+	private := createTestRepository(t, "teams-private", admin.ID)
+	private.Visibility = "private"
+	testutils.AssertNoError(t, Repositories.Update(private))
+	public := createTestRepository(t, "teams-public", admin.ID)
+	public.Visibility = "public"
+	testutils.AssertNoError(t, Repositories.Update(public))
+
+	engineering, err := CreateTeam("Engineering", "Everyone who ships", "", admin.ID)
+	testutils.AssertNoError(t, err)
+	backend, err := CreateTeam("Backend", "", engineering.ID, admin.ID)
+	testutils.AssertNoError(t, err)
+
+	t.Run("ValidatesTeams", func(t *testing.T) {
+		_, err := CreateTeam("engineering", "", "", admin.ID)
+		testutils.AssertError(t, err)
+		_, err = CreateTeam(" ", "", "", admin.ID)
+		testutils.AssertError(t, err)
+
+		// Nesting a team under its own nested team would make a loop
+		testutils.AssertError(t, engineering.Update("Engineering", "", backend.ID))
+		testutils.AssertError(t, engineering.Update("Engineering", "", engineering.ID))
+	})
+
+	t.Run("CheckRepoAccess", func(t *testing.T) {
+		// Admins administer everything, anyone reads public repositories
+		testutils.AssertNoError(t, CheckRepoAccess(admin, private.ID, RoleAdmin))
+		testutils.AssertNoError(t, CheckRepoAccess(nil, public.ID, RoleRead))
+		testutils.AssertError(t, CheckRepoAccess(nil, private.ID, RoleRead))
+		testutils.AssertError(t, CheckRepoAccess(dev, private.ID, RoleRead))
+
+		// Members of a nested team get the parent team's repositories
+		testutils.AssertNoError(t, backend.AddMember(dev.ID))
+		testutils.AssertNoError(t, engineering.GrantRepo(private.ID, RoleRead))
+		testutils.AssertNoError(t, CheckRepoAccess(dev, private.ID, RoleRead))
+		testutils.AssertError(t, CheckRepoAccess(dev, private.ID, RoleWrite))
+		testutils.AssertError(t, CheckRepoAccess(outsider, private.ID, RoleRead))
+
+		// The highest role of any of the user's teams applies
+		testutils.AssertNoError(t, backend.GrantRepo(private.ID, RoleWrite))
+		testutils.AssertEqual(t, RoleWrite, RepoRole(dev, private))
+		testutils.AssertNoError(t, backend.GrantRepo(public.ID, RoleAdmin))
+		testutils.AssertNoError(t, CheckRepoAccess(dev, public.ID, RoleAdmin))
+		testutils.AssertEqual(t, 2, len(TeamRepoIDs(dev.ID)))
+
+		testutils.AssertError(t, backend.GrantRepo(private.ID, "owner"))
+	})
+
+	t.Run("DeleteTeam", func(t *testing.T) {
+		testutils.AssertNoError(t, DeleteTeam(engineering))
+
+		// Nested teams move up and keep their own roles
+		moved, err := Teams.Get(backend.ID)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, "", moved.ParentID)
+		testutils.AssertEqual(t, RoleWrite, RepoRole(dev, private))
+
+		testutils.AssertNoError(t, backend.RemoveMember(dev.ID))
+		testutils.AssertEqual(t, "", RepoRole(dev, private))
+	})
+}
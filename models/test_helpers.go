@@ -9,6 +9,7 @@ import (
 	"github.com/The-Skyscape/devtools/pkg/authentication"
 	"github.com/The-Skyscape/devtools/pkg/database"
 	"github.com/The-Skyscape/devtools/pkg/database/engines/sqlite3"
+	"github.com/The-Skyscape/devtools/pkg/security"
 	"github.com/The-Skyscape/devtools/pkg/testutils"
 )
 
@@ -38,10 +39,87 @@ func initializeCollections() {
 	Messages = database.Manage(DB, new(Message))
 	Todos = database.Manage(DB, new(Todo))
 	AIActivities = database.Manage(DB, new(AIActivity))
+	AITasks = database.Manage(DB, new(AITask))
+	ToolExecutions = database.Manage(DB, new(ToolExecution))
 	TagDefinitions = database.Manage(DB, new(TagDefinition))
 	IssueLabels = database.Manage(DB, new(IssueLabel))
+	IssueAssignees = database.Manage(DB, new(IssueAssignee))
 	Events = database.Manage(DB, new(Event))
 	EventMetadataEntries = database.Manage(DB, new(EventMetadata))
+	FallbackSecrets = database.Manage(DB, new(FallbackSecret))
+	RepoHealthSnapshots = database.Manage(DB, new(RepoHealthSnapshot))
+	SandboxPolicies = database.Manage(DB, new(SandboxPolicy))
+	ToolPolicies = database.Manage(DB, new(ToolPolicy))
+	StalePolicies = database.Manage(DB, new(StalePolicy))
+	StaleMarks = database.Manage(DB, new(StaleMark))
+	AutoApprovalPolicies = database.Manage(DB, new(AutoApprovalPolicy))
+	DependencyScans = database.Manage(DB, new(DependencyScan))
+	Webhooks = database.Manage(DB, new(Webhook))
+	WebhookDeliveries = database.Manage(DB, new(WebhookDelivery))
+	ChatIntegrations = database.Manage(DB, new(ChatIntegration))
+	ChatDeliveries = database.Manage(DB, new(ChatDelivery))
+	GitHubSyncRuns = database.Manage(DB, new(GitHubSyncRun))
+	RepoMirrors = database.Manage(DB, new(RepoMirror))
+	LFSObjects = database.Manage(DB, new(LFSObject))
+	BranchProtections = database.Manage(DB, new(BranchProtection))
+	PRApprovals = database.Manage(DB, new(PRApproval))
+	PRReviews = database.Manage(DB, new(PRReview))
+	MergeChecks = database.Manage(DB, new(MergeCheck))
+	CommitStatuses = database.Manage(DB, new(CommitStatus))
+	WorkflowRuns = database.Manage(DB, new(WorkflowRun))
+	WorkflowJobRuns = database.Manage(DB, new(WorkflowJobRun))
+	WorkflowStepRuns = database.Manage(DB, new(WorkflowStepRun))
+	ActionSecrets = database.Manage(DB, new(ActionSecret))
+	Environments = database.Manage(DB, new(Environment))
+	Deployments = database.Manage(DB, new(Deployment))
+	TestRuns = database.Manage(DB, new(TestRun))
+	TestResults = database.Manage(DB, new(TestResult))
+	LintRuns = database.Manage(DB, new(LintRun))
+	SBOMs = database.Manage(DB, new(SBOM))
+	SBOMComponents = database.Manage(DB, new(SBOMComponent))
+	GuestLinks = database.Manage(DB, new(GuestLink))
+	GuestLinkAccesses = database.Manage(DB, new(GuestLinkAccess))
+	Dashboards = database.Manage(DB, new(Dashboard))
+	Milestones = database.Manage(DB, new(Milestone))
+	Projects = database.Manage(DB, new(Project))
+	ProjectColumns = database.Manage(DB, new(ProjectColumn))
+	ProjectRules = database.Manage(DB, new(ProjectRule))
+	ProjectCards = database.Manage(DB, new(ProjectCard))
+	Attachments = database.Manage(DB, new(Attachment))
+	PromptTemplates = database.Manage(DB, new(PromptTemplate))
+	ReviewRequests = database.Manage(DB, new(ReviewRequest))
+	Notifications = database.Manage(DB, new(Notification))
+	UsageRecords = database.Manage(DB, new(UsageRecord))
+	TokenUsages = database.Manage(DB, new(TokenUsage))
+	SemanticIndexes = database.Manage(DB, new(SemanticIndex))
+	CodeChunks = database.Manage(DB, new(CodeChunk))
+	SearchDocuments = database.Manage(DB, new(SearchDocument))
+	SearchIndexStates = database.Manage(DB, new(SearchIndexState))
+	ReadStates = database.Manage(DB, new(ReadState))
+	SavedViews = database.Manage(DB, new(SavedView))
+	CrossReferences = database.Manage(DB, new(CrossReference))
+	Mentions = database.Manage(DB, new(Mention))
+	Teams = database.Manage(DB, new(Team))
+	TeamMembers = database.Manage(DB, new(TeamMember))
+	TeamRepos = database.Manage(DB, new(TeamRepo))
+	PersonalTokens = database.Manage(DB, new(PersonalToken))
+	ReportSchedules = database.Manage(DB, new(ReportSchedule))
+	RepoReports = database.Manage(DB, new(RepoReport))
+	Migrations = database.Manage(DB, new(Migration))
+	MigrationItems = database.Manage(DB, new(MigrationItem))
+	MigrationInvites = database.Manage(DB, new(MigrationInvite))
+	MigrationReceipts = database.Manage(DB, new(MigrationReceipt))
+	MailPreferences = database.Manage(DB, new(MailPreference))
+	PasswordResets = database.Manage(DB, new(PasswordReset))
+	Invitations = database.Manage(DB, new(Invitation))
+	SSOIdentities = database.Manage(DB, new(SSOIdentity))
+	UserSessions = database.Manage(DB, new(UserSession))
+	RateLimitCounters = database.Manage(DB, new(RateLimitCounter))
+	ResourceSamples = database.Manage(DB, new(ResourceSample))
+	AlertRules = database.Manage(DB, new(AlertRule))
+	AlertChannels = database.Manage(DB, new(AlertChannel))
+	Alerts = database.Manage(DB, new(Alert))
+	setupSearchIndex()
 }
 
 // Global test workspace for the current test
@@ -87,6 +165,15 @@ func CleanupTestDB(t *testing.T, db *database.DynamicDB) {
 	// It will be garbage collected when test ends
 }
 
+// SetupTestSecrets keeps the secrets a test stores in a temporary directory
+// instead of the user's secrets store
+func SetupTestSecrets(t *testing.T) {
+	t.Setenv("AUTH_SECRET", "test-secret-key-for-secrets")
+	secrets := Secrets
+	Secrets = security.Manage(security.WithoutVault(), security.WithFallbackDir(t.TempDir()))
+	t.Cleanup(func() { Secrets = secrets })
+}
+
 // CreateTestUser creates a test user
 func CreateTestUser(t *testing.T, db *database.DynamicDB, email string) *authentication.User {
 	// Extract handle from email (e.g., "user@example.com" -> "user")
@@ -0,0 +1,256 @@
+package models
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"workspace/internal/testreport"
+
+	"github.com/The-Skyscape/devtools/pkg/application"
+	"github.com/pkg/errors"
+)
+
+// Where test results come from
+const (
+	TestSourceWorkflow  = "workflow"
+	TestSourceAction    = "action"
+	TestSourceAssistant = "assistant"
+)
+
+const (
+	// maxTestRuns is how many test runs are kept for each repository
+	maxTestRuns = 200
+
+	// TestHistoryWindow is how many of a test's latest results its pass
+	// rate and flakiness are judged on
+	TestHistoryWindow = 20
+
+	// FlakyFlips is how many times a test's outcome must change within the
+	// window for it to be flagged as flaky
+	FlakyFlips = 2
+)
+
+// TestRun is one set of test results reported for a repository, by a
+// workflow job, an action run or the assistant
+type TestRun struct {
+	application.Model
+	RepoID    string
+	Source    string // TestSourceWorkflow, TestSourceAction or TestSourceAssistant
+	Name      string // The workflow job's status context, the action's title or the command
+	URL       string // Where to see the run that reported the results
+	Branch    string
+	CommitSHA string
+	Format    string // The testreport format the results were read from
+	Passed    int
+	Failed    int
+	Skipped   int
+	Duration  int // Total of the tests' durations, in milliseconds
+}
+
+// Table returns the database table name
+func (*TestRun) Table() string { return "test_runs" }
+
+// TestResult is the outcome of one test in a test run
+type TestResult struct {
+	application.Model
+	RepoID    string
+	TestRunID string
+	Suite     string // Go package, JUnit class name or pytest file
+	Name      string
+	Status    string // testreport.Passed, Failed or Skipped
+	Duration  int    // Milliseconds
+	Message   string // Why it failed or was skipped
+}
+
+// Table returns the database table name
+func (*TestResult) Table() string { return "test_results" }
+
+// RecordTestRun saves the results a run reported, dropping the
+// repository's oldest runs beyond maxTestRuns
+func RecordTestRun(run *TestRun, format string, results []testreport.Result) (*TestRun, error) {
+	if len(results) == 0 {
+		return nil, testreport.ErrNoResults
+	}
+	summary := testreport.Summarize(results)
+	run.Format = format
+	run.Passed, run.Failed, run.Skipped = summary.Passed, summary.Failed, summary.Skipped
+	run.Duration = int(summary.Duration.Milliseconds())
+
+	run, err := TestRuns.Insert(run)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to save test run")
+	}
+	for _, result := range results {
+		if _, err := TestResults.Insert(&TestResult{
+			RepoID:    run.RepoID,
+			TestRunID: run.ID,
+			Suite:     result.Suite,
+			Name:      result.Name,
+			Status:    result.Status,
+			Duration:  int(result.Duration.Milliseconds()),
+			Message:   result.Message,
+		}); err != nil {
+			return nil, errors.Wrap(err, "failed to save test result")
+		}
+	}
+
+	old := "SELECT ID FROM test_runs WHERE RepoID = ? ORDER BY CreatedAt DESC LIMIT -1 OFFSET ?"
+	if err := DB.Query("DELETE FROM test_results WHERE TestRunID IN ("+old+")", run.RepoID, maxTestRuns).Exec(); err != nil {
+		return nil, errors.Wrap(err, "failed to prune test results")
+	}
+	if err := DB.Query("DELETE FROM test_runs WHERE ID IN ("+old+")", run.RepoID, maxTestRuns).Exec(); err != nil {
+		return nil, errors.Wrap(err, "failed to prune test runs")
+	}
+	return run, nil
+}
+
+// Total returns how many tests the run reported
+func (r *TestRun) Total() int {
+	return r.Passed + r.Failed + r.Skipped
+}
+
+// PassRate returns the percentage of the run's tests that ran and passed
+func (r *TestRun) PassRate() int {
+	if r.Passed+r.Failed == 0 {
+		return 100
+	}
+	return r.Passed * 100 / (r.Passed + r.Failed)
+}
+
+// FormatDuration returns the total of the tests' durations for display
+func (r *TestRun) FormatDuration() string {
+	return (time.Duration(r.Duration) * time.Millisecond).Round(time.Millisecond).String()
+}
+
+// Failures returns the tests that failed in the run
+func (r *TestRun) Failures() ([]*TestResult, error) {
+	return TestResults.Search("WHERE TestRunID = ? AND Status = ?", r.ID, testreport.Failed)
+}
+
+// RecentTestRuns returns a repository's latest test runs, newest first
+func RecentTestRuns(repoID string, limit int) ([]*TestRun, error) {
+	return TestRuns.Search("WHERE RepoID = ? ORDER BY CreatedAt DESC LIMIT ?", repoID, limit)
+}
+
+// TestHistory is how one test fared over its latest results
+type TestHistory struct {
+	Suite    string
+	Name     string
+	Outcomes []string // Passed or failed, oldest first, up to TestHistoryWindow
+	Passed   int
+	Failed   int
+	Flips    int         // How often the outcome changed
+	Last     *TestResult // The latest result, skipped or not
+}
+
+// PassRate returns the percentage of the test's results that passed
+func (h *TestHistory) PassRate() int {
+	if h.Passed+h.Failed == 0 {
+		return 100
+	}
+	return h.Passed * 100 / (h.Passed + h.Failed)
+}
+
+// Flaky reports whether the test alternates between passing and failing
+func (h *TestHistory) Flaky() bool {
+	return h.Flips >= FlakyFlips
+}
+
+// Failing reports whether the test failed the last time it ran
+func (h *TestHistory) Failing() bool {
+	return len(h.Outcomes) > 0 && h.Outcomes[len(h.Outcomes)-1] == testreport.Failed
+}
+
+// TestHistories returns how each test in the repository's last runs fared:
+// flaky tests first, then failing ones, then by pass rate and name
+func TestHistories(repoID string, runs int) ([]*TestHistory, error) {
+	recent, err := RecentTestRuns(repoID, runs)
+	if err != nil || len(recent) == 0 {
+		return nil, err
+	}
+	ids := make([]any, 0, len(recent)+1)
+	ids = append(ids, repoID)
+	age := map[string]int{} // How many runs ago each run was
+	for i, run := range recent {
+		ids = append(ids, run.ID)
+		age[run.ID] = i
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(recent)), ", ")
+	results, err := TestResults.Search("WHERE RepoID = ? AND TestRunID IN ("+placeholders+")", ids...)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get test results")
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return age[results[i].TestRunID] > age[results[j].TestRunID]
+	})
+
+	byTest := map[string]*TestHistory{}
+	var histories []*TestHistory
+	for _, result := range results {
+		key := result.Suite + "\x00" + result.Name
+		history := byTest[key]
+		if history == nil {
+			history = &TestHistory{Suite: result.Suite, Name: result.Name}
+			byTest[key] = history
+			histories = append(histories, history)
+		}
+		history.Last = result
+		if result.Status == testreport.Passed || result.Status == testreport.Failed {
+			history.Outcomes = append(history.Outcomes, result.Status)
+		}
+	}
+
+	for _, history := range histories {
+		if len(history.Outcomes) > TestHistoryWindow {
+			history.Outcomes = history.Outcomes[len(history.Outcomes)-TestHistoryWindow:]
+		}
+		for i, outcome := range history.Outcomes {
+			if outcome == testreport.Passed {
+				history.Passed++
+			} else {
+				history.Failed++
+			}
+			if i > 0 && outcome != history.Outcomes[i-1] {
+				history.Flips++
+			}
+		}
+	}
+
+	sort.SliceStable(histories, func(i, j int) bool {
+		a, b := histories[i], histories[j]
+		if a.Flaky() != b.Flaky() {
+			return a.Flaky()
+		}
+		if a.Failing() != b.Failing() {
+			return a.Failing()
+		}
+		if a.PassRate() != b.PassRate() {
+			return a.PassRate() < b.PassRate()
+		}
+		return a.Suite+"."+a.Name < b.Suite+"."+b.Name
+	})
+	return histories, nil
+}
+
+// FlakyTests returns the tests whose outcome alternates in the repository's
+// last runs
+func FlakyTests(repoID string, runs int) ([]*TestHistory, error) {
+	histories, err := TestHistories(repoID, runs)
+	if err != nil {
+		return nil, err
+	}
+	var flaky []*TestHistory
+	for _, history := range histories {
+		if history.Flaky() {
+			flaky = append(flaky, history)
+		}
+	}
+	return flaky, nil
+}
+
+// DeleteRepoTestRuns removes the test results of a deleted repository
+func DeleteRepoTestRuns(repoID string) {
+	DB.Query("DELETE FROM test_results WHERE RepoID = ?", repoID).Exec()
+	DB.Query("DELETE FROM test_runs WHERE RepoID = ?", repoID).Exec()
+}
@@ -0,0 +1,87 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"workspace/internal/testreport"
+
+	"github.com/The-Skyscape/devtools/pkg/testutils"
+)
+
+func TestTestRuns(t *testing.T) {
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+
+	// Four runs: one test always passes, one breaks in the last run and one
+	// alternates
+	for i, flaky := range []string{testreport.Passed, testreport.Failed, testreport.Passed, testreport.Failed} {
+		broken := testreport.Passed
+		if i == 3 {
+			broken = testreport.Failed
+		}
+		_, err := RecordTestRun(&TestRun{RepoID: "shop", Source: TestSourceWorkflow, Name: "ci / test"}, testreport.FormatGoJSON, []testreport.Result{
+			{Suite: "shop/cart", Name: "TestStable", Status: testreport.Passed, Duration: 1500 * time.Millisecond},
+			{Suite: "shop/cart", Name: "TestBroken", Status: broken, Message: "boom"},
+			{Suite: "shop/cart", Name: "TestFlaky", Status: flaky},
+			{Suite: "shop/cart", Name: "TestSkipped", Status: testreport.Skipped},
+		})
+		testutils.AssertNoError(t, err)
+	}
+
+	t.Run("Empty", func(t *testing.T) {
+		_, err := RecordTestRun(&TestRun{RepoID: "shop"}, testreport.FormatGoJSON, nil)
+		testutils.AssertError(t, err)
+	})
+
+	t.Run("Summary", func(t *testing.T) {
+		runs, err := RecentTestRuns("shop", 10)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 4, len(runs))
+		latest := runs[0]
+		testutils.AssertEqual(t, 1, latest.Passed)
+		testutils.AssertEqual(t, 2, latest.Failed)
+		testutils.AssertEqual(t, 1, latest.Skipped)
+		testutils.AssertEqual(t, 4, latest.Total())
+		testutils.AssertEqual(t, 33, latest.PassRate())
+		testutils.AssertEqual(t, 1500, latest.Duration)
+
+		failures, err := latest.Failures()
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 2, len(failures))
+	})
+
+	t.Run("Histories", func(t *testing.T) {
+		histories, err := TestHistories("shop", 10)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 4, len(histories))
+
+		flaky := histories[0]
+		testutils.AssertEqual(t, "TestFlaky", flaky.Name)
+		testutils.AssertEqual(t, 3, flaky.Flips)
+		testutils.AssertTrue(t, flaky.Flaky())
+		testutils.AssertEqual(t, 50, flaky.PassRate())
+
+		broken := histories[1]
+		testutils.AssertEqual(t, "TestBroken", broken.Name)
+		testutils.AssertFalse(t, broken.Flaky())
+		testutils.AssertTrue(t, broken.Failing())
+		testutils.AssertEqual(t, "boom", broken.Last.Message)
+
+		skipped := histories[2]
+		testutils.AssertEqual(t, "TestSkipped", skipped.Name)
+		testutils.AssertEqual(t, 0, len(skipped.Outcomes))
+		testutils.AssertEqual(t, 100, skipped.PassRate())
+
+		flakyTests, err := FlakyTests("shop", 10)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 1, len(flakyTests))
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		DeleteRepoTestRuns("shop")
+		runs, err := RecentTestRuns("shop", 10)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 0, len(runs))
+	})
+}
@@ -2,15 +2,24 @@ package models
 
 import (
 	"fmt"
+	"slices"
+	"sync"
 	"time"
 
 	"github.com/The-Skyscape/devtools/pkg/application"
+	"github.com/The-Skyscape/devtools/pkg/authentication"
 )
 
-// Todo represents a task item in an AI conversation
+// Todo represents a task item. Tasks can belong to an AI conversation, a
+// repository or an issue, or stand alone on a user's task list.
 type Todo struct {
 	application.Model
 	ConversationID string    // Associated conversation (UUID)
+	RepoID         string    // Associated repository, if any
+	IssueID        string    // Associated issue, if any
+	CreatorID      string    // User who created the task (empty for agent todos)
+	AssigneeID     string    // User responsible for the task
+	DueDate        time.Time // Zero when the task has no due date
 	Content        string    // Task description  
 	Status         string    // pending, in_progress, completed
 	Position       int       // Order in the list
@@ -19,6 +28,12 @@ type Todo struct {
 // Table returns the database table name
 func (*Todo) Table() string { return "todos" }
 
+// todoSubscribers holds the change listeners for each conversation
+var (
+	todoSubscribers   = map[string]map[chan struct{}]struct{}{}
+	todoSubscribersMu sync.Mutex
+)
+
 // TodoStatus constants
 const (
 	TodoStatusPending    = "pending"
@@ -31,18 +46,98 @@ func (t *Todo) GetConversation() (*Conversation, error) {
 	return Conversations.Get(t.ConversationID)
 }
 
+// Repository returns the repository the task is attached to
+func (t *Todo) Repository() (*Repository, error) {
+	return Repos.Get(t.RepoID)
+}
+
+// Issue returns the issue the task is attached to
+func (t *Todo) Issue() (*Issue, error) {
+	return Issues.Get(t.IssueID)
+}
+
+// Assignee returns the user responsible for the task
+func (t *Todo) Assignee() (*authentication.User, error) {
+	return Auth.Users.Get(t.AssigneeID)
+}
+
+// IsCompleted returns true if the task is done
+func (t *Todo) IsCompleted() bool {
+	return t.Status == TodoStatusCompleted
+}
+
+// HasDueDate returns true if the task has a due date
+func (t *Todo) HasDueDate() bool {
+	return !t.DueDate.IsZero()
+}
+
+// IsOverdue returns true if an open task is past its due date
+func (t *Todo) IsOverdue() bool {
+	if !t.HasDueDate() || t.IsCompleted() {
+		return false
+	}
+	// Due dates are whole days, so a task is overdue once its day has passed
+	return time.Now().After(t.DueDate.AddDate(0, 0, 1))
+}
+
 // UpdateStatus updates the todo status
 func (t *Todo) UpdateStatus(status string) error {
 	t.Status = status
 	t.UpdatedAt = time.Now()
-	return Todos.Update(t)
+	if err := Todos.Update(t); err != nil {
+		return err
+	}
+	PublishTodoChange(t.ConversationID)
+	return nil
 }
 
 // UpdateContent updates the todo content
 func (t *Todo) UpdateContent(content string) error {
 	t.Content = content
 	t.UpdatedAt = time.Now()
-	return Todos.Update(t)
+	if err := Todos.Update(t); err != nil {
+		return err
+	}
+	PublishTodoChange(t.ConversationID)
+	return nil
+}
+
+// SubscribeTodos returns a channel that is signalled whenever the todos of a
+// conversation change, and a function that ends the subscription
+func SubscribeTodos(conversationID string) (<-chan struct{}, func()) {
+	// Buffer one signal so bursts of changes collapse into a single refresh
+	ch := make(chan struct{}, 1)
+
+	todoSubscribersMu.Lock()
+	if todoSubscribers[conversationID] == nil {
+		todoSubscribers[conversationID] = map[chan struct{}]struct{}{}
+	}
+	todoSubscribers[conversationID][ch] = struct{}{}
+	todoSubscribersMu.Unlock()
+
+	unsubscribe := func() {
+		todoSubscribersMu.Lock()
+		defer todoSubscribersMu.Unlock()
+		delete(todoSubscribers[conversationID], ch)
+		if len(todoSubscribers[conversationID]) == 0 {
+			delete(todoSubscribers, conversationID)
+		}
+	}
+	return ch, unsubscribe
+}
+
+// PublishTodoChange notifies subscribers that a conversation's todos changed
+func PublishTodoChange(conversationID string) {
+	todoSubscribersMu.Lock()
+	defer todoSubscribersMu.Unlock()
+
+	for ch := range todoSubscribers[conversationID] {
+		select {
+		case ch <- struct{}{}:
+		default:
+			// A refresh is already pending for this subscriber
+		}
+	}
 }
 
 // GetTodosByConversation returns all todos for a conversation
@@ -50,6 +145,49 @@ func GetTodosByConversation(conversationID string) ([]*Todo, error) {
 	return Todos.Search("WHERE ConversationID = ? ORDER BY Position ASC, CreatedAt ASC", conversationID)
 }
 
+// GetTodosByRepo returns the tasks attached to a repository
+func GetTodosByRepo(repoID string) ([]*Todo, error) {
+	return Todos.Search("WHERE RepoID = ? AND IssueID = '' ORDER BY Position ASC, CreatedAt ASC", repoID)
+}
+
+// GetTodosByIssue returns the tasks attached to an issue
+func GetTodosByIssue(issueID string) ([]*Todo, error) {
+	return Todos.Search("WHERE IssueID = ? ORDER BY Position ASC, CreatedAt ASC", issueID)
+}
+
+// GetTodosForUser returns the tasks assigned to a user, plus unassigned tasks
+// they created. Open tasks are sorted by due date, tasks without one last.
+func GetTodosForUser(userID string, includeCompleted bool) ([]*Todo, error) {
+	query := "WHERE (AssigneeID = ? OR (AssigneeID = '' AND CreatorID = ?))"
+	args := []any{userID, userID}
+	if !includeCompleted {
+		query += " AND Status != ?"
+		args = append(args, TodoStatusCompleted)
+	}
+
+	todos, err := Todos.Search(query+" ORDER BY CreatedAt ASC", args...)
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortStableFunc(todos, func(a, b *Todo) int {
+		switch {
+		case a.IsCompleted() != b.IsCompleted():
+			if a.IsCompleted() {
+				return 1
+			}
+			return -1
+		case a.HasDueDate() != b.HasDueDate():
+			if a.HasDueDate() {
+				return -1
+			}
+			return 1
+		}
+		return a.DueDate.Compare(b.DueDate)
+	})
+	return todos, nil
+}
+
 // GetActiveTodos returns non-completed todos for a conversation
 func GetActiveTodos(conversationID string) ([]*Todo, error) {
 	return Todos.Search("WHERE ConversationID = ? AND Status != ? ORDER BY Position ASC, CreatedAt ASC", 
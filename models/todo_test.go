@@ -0,0 +1,74 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/The-Skyscape/devtools/pkg/testutils"
+)
+
+func TestTodoTasks(t *testing.T) {
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+
+	t.Run("GetTodosForUser", func(t *testing.T) {
+		user := CreateTestUser(t, db, "tasks@example.com")
+		other := CreateTestUser(t, db, "other@example.com")
+
+		today := time.Now().Truncate(24 * time.Hour)
+		tasks := []*Todo{
+			{Content: "no due date", Status: TodoStatusPending, CreatorID: user.ID},
+			{Content: "due later", Status: TodoStatusPending, CreatorID: other.ID, AssigneeID: user.ID, DueDate: today.AddDate(0, 0, 7)},
+			{Content: "due soon", Status: TodoStatusPending, CreatorID: user.ID, AssigneeID: user.ID, DueDate: today.AddDate(0, 0, 1)},
+			{Content: "done", Status: TodoStatusCompleted, CreatorID: user.ID},
+			{Content: "someone else's", Status: TodoStatusPending, CreatorID: user.ID, AssigneeID: other.ID},
+		}
+		for _, task := range tasks {
+			_, err := Todos.Insert(task)
+			testutils.AssertNoError(t, err)
+		}
+
+		open, err := GetTodosForUser(user.ID, false)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 3, len(open))
+		testutils.AssertEqual(t, "due soon", open[0].Content)
+		testutils.AssertEqual(t, "due later", open[1].Content)
+		testutils.AssertEqual(t, "no due date", open[2].Content)
+
+		all, err := GetTodosForUser(user.ID, true)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 4, len(all))
+		testutils.AssertEqual(t, "done", all[3].Content)
+	})
+
+	t.Run("IssueTasksStayOutOfRepoList", func(t *testing.T) {
+		user := CreateTestUser(t, db, "issuetasks@example.com")
+		repo := createTestRepository(t, "task-repo", user.ID)
+		issue, err := CreateIssue("Task Issue", "Issue with tasks", user.ID, repo.ID)
+		testutils.AssertNoError(t, err)
+
+		_, err = Todos.Insert(&Todo{Content: "repo task", Status: TodoStatusPending, RepoID: repo.ID})
+		testutils.AssertNoError(t, err)
+		_, err = Todos.Insert(&Todo{Content: "issue task", Status: TodoStatusPending, RepoID: repo.ID, IssueID: issue.ID})
+		testutils.AssertNoError(t, err)
+
+		repoTasks, err := GetTodosByRepo(repo.ID)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 1, len(repoTasks))
+		testutils.AssertEqual(t, "repo task", repoTasks[0].Content)
+
+		issueTasks, err := GetTodosByIssue(issue.ID)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 1, len(issueTasks))
+		testutils.AssertEqual(t, "issue task", issueTasks[0].Content)
+	})
+
+	t.Run("IsOverdue", func(t *testing.T) {
+		today := time.Date(time.Now().Year(), time.Now().Month(), time.Now().Day(), 0, 0, 0, 0, time.Local)
+
+		testutils.AssertFalse(t, (&Todo{Status: TodoStatusPending}).IsOverdue())
+		testutils.AssertFalse(t, (&Todo{Status: TodoStatusPending, DueDate: today}).IsOverdue())
+		testutils.AssertTrue(t, (&Todo{Status: TodoStatusPending, DueDate: today.AddDate(0, 0, -2)}).IsOverdue())
+		testutils.AssertFalse(t, (&Todo{Status: TodoStatusCompleted, DueDate: today.AddDate(0, 0, -2)}).IsOverdue())
+	})
+}
@@ -0,0 +1,201 @@
+package models
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/The-Skyscape/devtools/pkg/application"
+	"github.com/pkg/errors"
+)
+
+// TokenUsage is the tokens one model call used, with its estimated cost
+type TokenUsage struct {
+	application.Model
+	UserID           string
+	ConversationID   string
+	ModelName        string
+	PromptTokens     int
+	CompletionTokens int
+	Cost             float64 // Estimated USD, 0 for models without a price
+}
+
+// Table returns the database table name
+func (*TokenUsage) Table() string { return "token_usage" }
+
+// TotalTokens returns the prompt and completion tokens together
+func (u *TokenUsage) TotalTokens() int {
+	return u.PromptTokens + u.CompletionTokens
+}
+
+// ModelPrice is what a model costs in USD per million tokens
+type ModelPrice struct {
+	Prompt     float64
+	Completion float64
+}
+
+// ModelPrices reads AI_MODEL_PRICES, a comma separated list of
+// model=prompt/completion prices in USD per million tokens, such as
+// "gpt-4o=2.5/10". Models run by Ollama cost nothing and need no price.
+func ModelPrices() map[string]ModelPrice {
+	prices := map[string]ModelPrice{}
+	for _, entry := range strings.Split(os.Getenv("AI_MODEL_PRICES"), ",") {
+		model, price, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok {
+			continue
+		}
+		prompt, completion, _ := strings.Cut(price, "/")
+		p, err := strconv.ParseFloat(strings.TrimSpace(prompt), 64)
+		if err != nil {
+			continue
+		}
+		c, err := strconv.ParseFloat(strings.TrimSpace(completion), 64)
+		if err != nil {
+			c = p
+		}
+		prices[strings.TrimSpace(model)] = ModelPrice{Prompt: p, Completion: c}
+	}
+	return prices
+}
+
+// EstimateCost returns what the tokens cost on the model, 0 when the model
+// has no price
+func EstimateCost(model string, promptTokens, completionTokens int) float64 {
+	price, ok := ModelPrices()[model]
+	if !ok {
+		return 0
+	}
+	return (float64(promptTokens)*price.Prompt + float64(completionTokens)*price.Completion) / 1e6
+}
+
+// RecordTokenUsage stores the tokens a model call used, prices them and
+// meters them against the user's quota
+func RecordTokenUsage(userID, conversationID, model string, promptTokens, completionTokens int) (*TokenUsage, error) {
+	if promptTokens+completionTokens <= 0 {
+		return nil, nil
+	}
+	usage, err := TokenUsages.Insert(&TokenUsage{
+		UserID:           userID,
+		ConversationID:   conversationID,
+		ModelName:        model,
+		PromptTokens:     promptTokens,
+		CompletionTokens: completionTokens,
+		Cost:             EstimateCost(model, promptTokens, completionTokens),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to record token usage")
+	}
+	return usage, RecordUsage(userID, UsageAITokens, float64(usage.TotalTokens()), "", conversationID)
+}
+
+// TokenTotal adds up the usage of one user, conversation, model or day
+type TokenTotal struct {
+	Key              string
+	Calls            int
+	PromptTokens     int
+	CompletionTokens int
+	Cost             float64
+}
+
+// TotalTokens returns the prompt and completion tokens together
+func (t *TokenTotal) TotalTokens() int {
+	return t.PromptTokens + t.CompletionTokens
+}
+
+// CostLabel formats the estimated cost, empty when nothing was priced
+func (t *TokenTotal) CostLabel() string {
+	if t.Cost == 0 {
+		return ""
+	}
+	if t.Cost < 0.01 {
+		return "< $0.01"
+	}
+	return fmt.Sprintf("$%.2f", t.Cost)
+}
+
+func (t *TokenTotal) add(usage *TokenUsage) {
+	t.Calls++
+	t.PromptTokens += usage.PromptTokens
+	t.CompletionTokens += usage.CompletionTokens
+	t.Cost += usage.Cost
+}
+
+// TokenReport breaks token usage over a period down by user, conversation,
+// model and day
+type TokenReport struct {
+	Since          time.Time
+	Total          TokenTotal
+	ByUser         []*TokenTotal // Most tokens first
+	ByConversation []*TokenTotal // Most tokens first
+	ByModel        []*TokenTotal // Most tokens first
+	ByDay          []*TokenTotal // Keyed 2006-01-02, oldest first
+}
+
+// GetTokenReport adds up the token usage recorded since the given time
+func GetTokenReport(since time.Time) (*TokenReport, error) {
+	usages, err := TokenUsages.Search("WHERE CreatedAt >= ? ORDER BY CreatedAt", since)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load token usage")
+	}
+
+	report := &TokenReport{Since: since}
+	users, conversations, byModel, days := map[string]*TokenTotal{}, map[string]*TokenTotal{}, map[string]*TokenTotal{}, map[string]*TokenTotal{}
+	for _, usage := range usages {
+		report.Total.add(usage)
+		addTokenTotal(users, usage.UserID, usage)
+		if usage.ConversationID != "" {
+			addTokenTotal(conversations, usage.ConversationID, usage)
+		}
+		addTokenTotal(byModel, usage.ModelName, usage)
+		addTokenTotal(days, usage.CreatedAt.Format("2006-01-02"), usage)
+	}
+
+	report.ByUser = mostTokensFirst(users)
+	report.ByConversation = mostTokensFirst(conversations)
+	report.ByModel = mostTokensFirst(byModel)
+	for _, day := range days {
+		report.ByDay = append(report.ByDay, day)
+	}
+	sort.Slice(report.ByDay, func(i, j int) bool {
+		return report.ByDay[i].Key < report.ByDay[j].Key
+	})
+	return report, nil
+}
+
+// DayPercent returns a day's tokens as a percentage of the busiest day's
+func (r *TokenReport) DayPercent(day *TokenTotal) int {
+	busiest := 0
+	for _, d := range r.ByDay {
+		busiest = max(busiest, d.TotalTokens())
+	}
+	if busiest == 0 {
+		return 0
+	}
+	return day.TotalTokens() * 100 / busiest
+}
+
+func addTokenTotal(totals map[string]*TokenTotal, key string, usage *TokenUsage) {
+	total, ok := totals[key]
+	if !ok {
+		total = &TokenTotal{Key: key}
+		totals[key] = total
+	}
+	total.add(usage)
+}
+
+func mostTokensFirst(totals map[string]*TokenTotal) []*TokenTotal {
+	sorted := make([]*TokenTotal, 0, len(totals))
+	for _, total := range totals {
+		sorted = append(sorted, total)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].TotalTokens() != sorted[j].TotalTokens() {
+			return sorted[i].TotalTokens() > sorted[j].TotalTokens()
+		}
+		return sorted[i].Key < sorted[j].Key
+	})
+	return sorted
+}
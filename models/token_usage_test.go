@@ -0,0 +1,69 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/The-Skyscape/devtools/pkg/testutils"
+)
+
+func TestTokenUsage(t *testing.T) {
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+	t.Setenv("AI_MODEL_PRICES", "gpt-4o=2.5/10, broken, flat=1")
+
+	alice := CreateTestUser(t, db, "alice@example.com")
+	bob := CreateTestUser(t, db, "bob@example.com")
+	since := time.Now().Add(-time.Hour)
+
+	t.Run("Prices", func(t *testing.T) {
+		prices := ModelPrices()
+		testutils.AssertEqual(t, 2, len(prices))
+		testutils.AssertEqual(t, 10.0, prices["gpt-4o"].Completion)
+		testutils.AssertEqual(t, 1.0, prices["flat"].Completion)
+
+		testutils.AssertEqual(t, 12.5, EstimateCost("gpt-4o", 1e6, 1e6))
+		testutils.AssertEqual(t, 0.0, EstimateCost("llama3.2:3b", 1e6, 1e6))
+	})
+
+	t.Run("Record", func(t *testing.T) {
+		usage, err := RecordTokenUsage(alice.ID, "conversation-1", "gpt-4o", 1000, 200)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 0.0045, usage.Cost)
+		_, err = RecordTokenUsage(alice.ID, "conversation-1", "llama3.2:3b", 500, 100)
+		testutils.AssertNoError(t, err)
+		_, err = RecordTokenUsage(bob.ID, "", "llama3.2:3b", 50, 10)
+		testutils.AssertNoError(t, err)
+
+		// Calls that used nothing aren't stored
+		usage, err = RecordTokenUsage(bob.ID, "", "llama3.2:3b", 0, 0)
+		testutils.AssertNoError(t, err)
+		testutils.AssertTrue(t, usage == nil)
+		testutils.AssertEqual(t, 3, TokenUsages.Count(""))
+
+		// Tokens count against the AI quota too
+		metered, err := GetUserUsage(alice.ID, since)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 1800.0, metered.AITokens)
+	})
+
+	t.Run("Report", func(t *testing.T) {
+		report, err := GetTokenReport(since)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 3, report.Total.Calls)
+		testutils.AssertEqual(t, 1860, report.Total.TotalTokens())
+		testutils.AssertEqual(t, "< $0.01", report.Total.CostLabel())
+
+		testutils.AssertEqual(t, 2, len(report.ByUser))
+		testutils.AssertEqual(t, alice.ID, report.ByUser[0].Key)
+		testutils.AssertEqual(t, 1, len(report.ByConversation))
+		testutils.AssertEqual(t, 2, report.ByConversation[0].Calls)
+		testutils.AssertEqual(t, "gpt-4o", report.ByModel[0].Key)
+		testutils.AssertEqual(t, 1, len(report.ByDay))
+		testutils.AssertEqual(t, 100, report.DayPercent(report.ByDay[0]))
+
+		report, err = GetTokenReport(time.Now().Add(time.Minute))
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 0, report.Total.Calls)
+	})
+}
@@ -0,0 +1,180 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/The-Skyscape/devtools/pkg/application"
+)
+
+const (
+	// maxAuditParamLength caps each recorded parameter, file contents
+	// written by the agent would otherwise fill the table
+	maxAuditParamLength = 500
+
+	// maxAuditSummaryLength caps the recorded tool output
+	maxAuditSummaryLength = 1000
+)
+
+// ToolExecution records a tool call made by the AI agent
+type ToolExecution struct {
+	application.Model
+	ToolName       string
+	Parameters     string // JSON encoded, long values truncated
+	ResultSummary  string // Start of the tool output
+	Error          string // Set when the tool failed
+	Success        bool
+	Duration       int64 // Duration in milliseconds
+	RepoID         string
+	ConversationID string
+	UserID         string
+	ExecutedAt     time.Time
+}
+
+// Table returns the database table name
+func (*ToolExecution) Table() string { return "tool_executions" }
+
+// User returns the user the agent was acting for
+func (e *ToolExecution) User() *User {
+	user, err := Auth.Users.Get(e.UserID)
+	if err != nil {
+		return nil
+	}
+	return user
+}
+
+// Repository returns the repository the tool operated on, if any
+func (e *ToolExecution) Repository() *Repository {
+	if e.RepoID == "" {
+		return nil
+	}
+	repo, err := Repositories.Get(e.RepoID)
+	if err != nil {
+		return nil
+	}
+	return repo
+}
+
+// ParametersPretty returns the parameters indented for display
+func (e *ToolExecution) ParametersPretty() string {
+	var params map[string]any
+	if err := json.Unmarshal([]byte(e.Parameters), &params); err != nil {
+		return e.Parameters
+	}
+	pretty, err := json.MarshalIndent(params, "", "  ")
+	if err != nil {
+		return e.Parameters
+	}
+	return string(pretty)
+}
+
+// DurationString returns the duration in a human-readable form
+func (e *ToolExecution) DurationString() string {
+	if e.Duration < 1000 {
+		return fmt.Sprintf("%dms", e.Duration)
+	}
+	return fmt.Sprintf("%.1fs", float64(e.Duration)/1000)
+}
+
+// RecordToolExecution saves a tool call to the audit log. Parameters whose
+// names start with an underscore are injected by the server and skipped.
+func RecordToolExecution(toolName string, params map[string]any, result string, toolErr error, duration time.Duration, conversationID, userID string) (*ToolExecution, error) {
+	recorded := make(map[string]any, len(params))
+	for key, value := range params {
+		if strings.HasPrefix(key, "_") {
+			continue
+		}
+		if s, ok := value.(string); ok {
+			value = truncateAudit(s, maxAuditParamLength)
+		}
+		recorded[key] = value
+	}
+
+	encoded, err := json.Marshal(recorded)
+	if err != nil {
+		return nil, err
+	}
+
+	repoID, _ := params["repo_id"].(string)
+	execution := &ToolExecution{
+		ToolName:       toolName,
+		Parameters:     string(encoded),
+		ResultSummary:  truncateAudit(result, maxAuditSummaryLength),
+		Success:        toolErr == nil,
+		Duration:       duration.Milliseconds(),
+		RepoID:         repoID,
+		ConversationID: conversationID,
+		UserID:         userID,
+		ExecutedAt:     time.Now(),
+	}
+	if toolErr != nil {
+		execution.Error = toolErr.Error()
+	}
+
+	return ToolExecutions.Insert(execution)
+}
+
+// ToolExecutionFilter represents filter criteria for the tool audit log
+type ToolExecutionFilter struct {
+	ToolName  string
+	RepoID    string
+	UserID    string
+	StartTime time.Time
+	EndTime   time.Time
+	Limit     int
+	Offset    int
+}
+
+// QueryToolExecutions returns the tool calls matching filter, newest first
+func QueryToolExecutions(filter ToolExecutionFilter) ([]*ToolExecution, error) {
+	query := "WHERE 1=1"
+	var args []any
+
+	if filter.ToolName != "" {
+		query += " AND ToolName = ?"
+		args = append(args, filter.ToolName)
+	}
+
+	if filter.RepoID != "" {
+		query += " AND RepoID = ?"
+		args = append(args, filter.RepoID)
+	}
+
+	if filter.UserID != "" {
+		query += " AND UserID = ?"
+		args = append(args, filter.UserID)
+	}
+
+	if !filter.StartTime.IsZero() {
+		query += " AND ExecutedAt >= ?"
+		args = append(args, filter.StartTime)
+	}
+
+	if !filter.EndTime.IsZero() {
+		query += " AND ExecutedAt < ?"
+		args = append(args, filter.EndTime)
+	}
+
+	query += " ORDER BY ExecutedAt DESC"
+
+	if filter.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d OFFSET %d", filter.Limit, filter.Offset)
+	}
+
+	return ToolExecutions.Search(query, args...)
+}
+
+// truncateAudit shortens s to at most n bytes without splitting a character
+func truncateAudit(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	cut := n
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut] + fmt.Sprintf("… (%d bytes total)", len(s))
+}
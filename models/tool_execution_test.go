@@ -0,0 +1,58 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/The-Skyscape/devtools/pkg/testutils"
+)
+
+func TestToolExecutions(t *testing.T) {
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+
+	user := CreateTestUser(t, db, "audit@example.com")
+
+	t.Run("RecordToolExecution", func(t *testing.T) {
+		params := map[string]any{
+			"repo_id":          "audit-repo",
+			"path":             "main.go",
+			"content":          strings.Repeat("x", 2000),
+			"_conversation_id": "conv-1",
+		}
+		execution, err := RecordToolExecution("write_file", params, "wrote main.go", nil, 1500*time.Millisecond, "conv-1", user.ID)
+		testutils.AssertNoError(t, err)
+
+		testutils.AssertEqual(t, "audit-repo", execution.RepoID)
+		testutils.AssertEqual(t, int64(1500), execution.Duration)
+		testutils.AssertEqual(t, "1.5s", execution.DurationString())
+		testutils.AssertTrue(t, execution.Success)
+		testutils.AssertFalse(t, strings.Contains(execution.Parameters, "_conversation_id"))
+		testutils.AssertTrue(t, len(execution.Parameters) < 1000)
+
+		failed, err := RecordToolExecution("run_command", map[string]any{"command": "make"}, "", errors.New("exit status 2"), 20*time.Millisecond, "conv-1", user.ID)
+		testutils.AssertNoError(t, err)
+		testutils.AssertFalse(t, failed.Success)
+		testutils.AssertEqual(t, "exit status 2", failed.Error)
+	})
+
+	t.Run("QueryToolExecutions", func(t *testing.T) {
+		byTool, err := QueryToolExecutions(ToolExecutionFilter{ToolName: "write_file"})
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 1, len(byTool))
+
+		byRepo, err := QueryToolExecutions(ToolExecutionFilter{RepoID: "audit-repo"})
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 1, len(byRepo))
+
+		byUser, err := QueryToolExecutions(ToolExecutionFilter{UserID: user.ID, Limit: 1})
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 1, len(byUser))
+
+		future, err := QueryToolExecutions(ToolExecutionFilter{StartTime: time.Now().Add(time.Hour)})
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 0, len(future))
+	})
+}
@@ -0,0 +1,121 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/The-Skyscape/devtools/pkg/application"
+	"github.com/pkg/errors"
+)
+
+// AI tool access levels for a repository
+const (
+	ToolAccessFull     = "full"      // Any tool not denied by name
+	ToolAccessReadOnly = "read-only" // Only tools that don't change anything
+	ToolAccessNone     = "none"      // No tool may touch the repository
+)
+
+// ReadOnlyAITools are the agent tools that only look at a repository
+var ReadOnlyAITools = []string{
+	"get_repo", "get_repo_link",
+	"list_files", "read_file", "search_files", "semantic_search",
+	"git_status", "git_diff", "git_log", "git_branch",
+	"list_issues", "list_prs", "list_milestones", "list_project_cards",
+	"get_working_directory",
+}
+
+// WriteAITools are the agent tools that change a repository, run commands
+// in it or deploy it
+var WriteAITools = []string{
+	"write_file", "edit_file", "delete_file", "move_file",
+	"git_commit", "git_push", "git_pull", "git_merge", "git_branch_create", "git_checkout",
+	"create_issue", "update_issue", "create_pr", "create_pull_request",
+	"create_milestone", "update_milestone", "create_project_card", "update_project_card",
+	"build", "test", "deploy", "run_linters",
+	"run_command", "install_package", "list_processes",
+	"delete_repo",
+}
+
+// commandTools run their work through run_command, so denying it denies them
+var commandTools = map[string]bool{"install_package": true, "list_processes": true}
+
+// ToolPolicy limits which AI agent tools may be used on a repository,
+// whichever conversation or scheduled agent calls them. It is stored with
+// the repository ID as its ID.
+type ToolPolicy struct {
+	application.Model
+	RepoID      string
+	Access      string
+	DeniedTools string // One tool name per line, denied even when Access allows it
+	UpdatedBy   string
+}
+
+// Table returns the database table name
+func (*ToolPolicy) Table() string { return "tool_policies" }
+
+// GetToolPolicy returns the repository's tool policy, allowing every tool
+// when none has been saved
+func GetToolPolicy(repoID string) *ToolPolicy {
+	if policy, err := ToolPolicies.Get(repoID); err == nil {
+		return policy
+	}
+	return &ToolPolicy{Model: DB.NewModel(repoID), RepoID: repoID, Access: ToolAccessFull}
+}
+
+// SaveToolPolicy validates and stores a repository's tool policy
+func SaveToolPolicy(policy *ToolPolicy, userID string) (*ToolPolicy, error) {
+	switch policy.Access {
+	case ToolAccessFull, ToolAccessReadOnly, ToolAccessNone:
+	default:
+		return nil, errors.Errorf("unknown tool access %q", policy.Access)
+	}
+	for _, name := range policy.DeniedToolList() {
+		if !slices.Contains(ReadOnlyAITools, name) && !slices.Contains(WriteAITools, name) {
+			return nil, errors.Errorf("%q is not an AI tool", name)
+		}
+	}
+
+	policy.DeniedTools = strings.Join(policy.DeniedToolList(), "\n")
+	policy.UpdatedBy = userID
+
+	if _, err := ToolPolicies.Get(policy.ID); err != nil {
+		return ToolPolicies.Insert(policy)
+	}
+	policy.UpdatedAt = time.Now()
+	return policy, ToolPolicies.Update(policy)
+}
+
+// DeniedToolList returns the tools denied by name
+func (p *ToolPolicy) DeniedToolList() []string {
+	return splitPolicyList(p.DeniedTools)
+}
+
+// Denies reports whether the named tool is denied by name
+func (p *ToolPolicy) Denies(tool string) bool {
+	return slices.Contains(p.DeniedToolList(), tool)
+}
+
+// Allows returns why the policy refuses the tool, nil when it may be used
+func (p *ToolPolicy) Allows(tool string) error {
+	switch {
+	case p.Access == ToolAccessNone:
+		return errors.New("AI tools are disabled for this repository")
+	case p.Access == ToolAccessReadOnly && !slices.Contains(ReadOnlyAITools, tool):
+		return fmt.Errorf("this repository is read-only for AI tools, %s is not allowed", tool)
+	case p.Denies(tool), commandTools[tool] && p.Denies("run_command"):
+		return fmt.Errorf("%s is not allowed on this repository", tool)
+	}
+	return nil
+}
+
+// CheckToolPolicy refuses a tool call that the policy of the repository it
+// targets doesn't allow. Calls without a repository aren't limited.
+func CheckToolPolicy(tool string, params map[string]any) error {
+	repoID, _ := params["repo_id"].(string)
+	if repoID == "" {
+		return nil
+	}
+	return GetToolPolicy(repoID).Allows(tool)
+}
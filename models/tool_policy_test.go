@@ -0,0 +1,63 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/The-Skyscape/devtools/pkg/testutils"
+)
+
+func TestToolPolicy(t *testing.T) {
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+
+	t.Run("DefaultsToFullAccess", func(t *testing.T) {
+		policy := GetToolPolicy("tools-repo")
+		testutils.AssertEqual(t, ToolAccessFull, policy.Access)
+		testutils.AssertNoError(t, policy.Allows("run_command"))
+		testutils.AssertNoError(t, CheckToolPolicy("write_file", map[string]any{"repo_id": "tools-repo"}))
+	})
+
+	t.Run("DeniedByName", func(t *testing.T) {
+		policy := GetToolPolicy("tools-repo")
+		policy.DeniedTools = "run_command\ngit_push, deploy"
+		_, err := SaveToolPolicy(policy, "admin")
+		testutils.AssertNoError(t, err)
+
+		saved := GetToolPolicy("tools-repo")
+		testutils.AssertEqual(t, "run_command\ngit_push\ndeploy", saved.DeniedTools)
+		testutils.AssertError(t, CheckToolPolicy("run_command", map[string]any{"repo_id": "tools-repo"}))
+		testutils.AssertError(t, CheckToolPolicy("install_package", map[string]any{"repo_id": "tools-repo"}))
+		testutils.AssertNoError(t, CheckToolPolicy("write_file", map[string]any{"repo_id": "tools-repo"}))
+
+		// Calls without a repository, or for another one, aren't limited
+		testutils.AssertNoError(t, CheckToolPolicy("run_command", map[string]any{}))
+		testutils.AssertNoError(t, CheckToolPolicy("run_command", map[string]any{"repo_id": "other-repo"}))
+	})
+
+	t.Run("ReadOnly", func(t *testing.T) {
+		policy := GetToolPolicy("tools-repo")
+		policy.Access = ToolAccessReadOnly
+		_, err := SaveToolPolicy(policy, "admin")
+		testutils.AssertNoError(t, err)
+
+		testutils.AssertNoError(t, CheckToolPolicy("read_file", map[string]any{"repo_id": "tools-repo"}))
+		testutils.AssertError(t, CheckToolPolicy("write_file", map[string]any{"repo_id": "tools-repo"}))
+		testutils.AssertError(t, CheckToolPolicy("some_new_tool", map[string]any{"repo_id": "tools-repo"}))
+	})
+
+	t.Run("NoTools", func(t *testing.T) {
+		policy := GetToolPolicy("tools-repo")
+		policy.Access = ToolAccessNone
+		_, err := SaveToolPolicy(policy, "admin")
+		testutils.AssertNoError(t, err)
+		testutils.AssertError(t, CheckToolPolicy("read_file", map[string]any{"repo_id": "tools-repo"}))
+	})
+
+	t.Run("RejectsBadInput", func(t *testing.T) {
+		_, err := SaveToolPolicy(&ToolPolicy{Model: DB.NewModel("bad-repo"), Access: "admin"}, "admin")
+		testutils.AssertError(t, err)
+
+		_, err = SaveToolPolicy(&ToolPolicy{Model: DB.NewModel("bad-repo"), Access: ToolAccessFull, DeniedTools: "rm_rf"}, "admin")
+		testutils.AssertError(t, err)
+	})
+}
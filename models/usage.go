@@ -0,0 +1,247 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/The-Skyscape/devtools/pkg/application"
+	"github.com/pkg/errors"
+)
+
+// Metered resources. Storage is sampled, the others are counters that add
+// up over a period.
+const (
+	UsageStorage     = "storage_bytes"
+	UsageCIMinutes   = "ci_minutes"
+	UsageAITokens    = "ai_tokens"
+	UsageWorkerHours = "worker_hours"
+)
+
+// UsageMetrics lists the metered resources in display order
+var UsageMetrics = []string{UsageStorage, UsageCIMinutes, UsageAITokens, UsageWorkerHours}
+
+// ErrQuotaExceeded is returned when a user has used up their plan's
+// allowance of a resource for the current billing period
+var ErrQuotaExceeded = errors.New("usage quota exceeded")
+
+// UsageRecord is one measurement of a user's resource consumption
+type UsageRecord struct {
+	application.Model
+	UserID   string
+	Metric   string
+	Quantity float64
+	RepoID   string
+	Source   string // What was consumed, e.g. an action run or conversation ID
+}
+
+// Table returns the database table name
+func (*UsageRecord) Table() string { return "usage_records" }
+
+// RecordUsage stores a measurement. Empty users and non-positive quantities
+// are ignored so callers can meter unconditionally.
+func RecordUsage(userID, metric string, quantity float64, repoID, source string) error {
+	if userID == "" || quantity <= 0 {
+		return nil
+	}
+	_, err := UsageRecords.Insert(&UsageRecord{
+		UserID:   userID,
+		Metric:   metric,
+		Quantity: quantity,
+		RepoID:   repoID,
+		Source:   source,
+	})
+	return errors.Wrap(err, "failed to record usage")
+}
+
+// MeterActionRun bills a finished action run to the owner of the action's
+// repository
+func MeterActionRun(action *Action, run *ActionRun) error {
+	repo, err := Repositories.Get(action.RepoID)
+	if err != nil {
+		return errors.Wrap(err, "repository not found")
+	}
+	return RecordUsage(repo.UserID, UsageCIMinutes, float64(run.Duration)/60, repo.ID, run.ID)
+}
+
+// MeterWorkflowRun bills a finished workflow run to the owner of its
+// repository
+func MeterWorkflowRun(run *WorkflowRun) error {
+	repo, err := Repositories.Get(run.RepoID)
+	if err != nil {
+		return errors.Wrap(err, "repository not found")
+	}
+	return RecordUsage(repo.UserID, UsageCIMinutes, float64(run.Duration)/60, repo.ID, run.ID)
+}
+
+// MeterStorage records how many bytes each user's repositories take up
+func MeterStorage() (int, error) {
+	repos, err := Repositories.Search("")
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to list repositories")
+	}
+
+	bytes := map[string]int64{}
+	for _, repo := range repos {
+		if size, err := repo.GetSize(); err == nil {
+			bytes[repo.UserID] += size
+		}
+	}
+
+	for userID, size := range bytes {
+		if err := RecordUsage(userID, UsageStorage, float64(size), "", "snapshot"); err != nil {
+			return 0, err
+		}
+	}
+	return len(bytes), nil
+}
+
+// UsageSummary is one user's consumption over a period. Storage is the
+// peak sample, the other metrics are totals.
+type UsageSummary struct {
+	UserID      string
+	Storage     float64
+	CIMinutes   float64
+	AITokens    float64
+	WorkerHours float64
+}
+
+// Get returns the summary's value for a metric
+func (s *UsageSummary) Get(metric string) float64 {
+	switch metric {
+	case UsageStorage:
+		return s.Storage
+	case UsageCIMinutes:
+		return s.CIMinutes
+	case UsageAITokens:
+		return s.AITokens
+	case UsageWorkerHours:
+		return s.WorkerHours
+	}
+	return 0
+}
+
+func (s *UsageSummary) add(record *UsageRecord) {
+	switch record.Metric {
+	case UsageStorage:
+		s.Storage = max(s.Storage, record.Quantity)
+	case UsageCIMinutes:
+		s.CIMinutes += record.Quantity
+	case UsageAITokens:
+		s.AITokens += record.Quantity
+	case UsageWorkerHours:
+		s.WorkerHours += record.Quantity
+	}
+}
+
+// GetUsageSummaries returns every user's consumption between since and
+// until, ordered by user
+func GetUsageSummaries(since, until time.Time) ([]*UsageSummary, error) {
+	records, err := UsageRecords.Search("WHERE CreatedAt >= ? AND CreatedAt < ?", since, until)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load usage")
+	}
+
+	byUser := map[string]*UsageSummary{}
+	for _, record := range records {
+		summary, ok := byUser[record.UserID]
+		if !ok {
+			summary = &UsageSummary{UserID: record.UserID}
+			byUser[record.UserID] = summary
+		}
+		summary.add(record)
+	}
+
+	summaries := make([]*UsageSummary, 0, len(byUser))
+	for _, summary := range byUser {
+		summaries = append(summaries, summary)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].UserID < summaries[j].UserID
+	})
+	return summaries, nil
+}
+
+// GetUserUsage returns one user's consumption since the given time
+func GetUserUsage(userID string, since time.Time) (*UsageSummary, error) {
+	records, err := UsageRecords.Search("WHERE UserID = ? AND CreatedAt >= ?", userID, since)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load usage")
+	}
+
+	summary := &UsageSummary{UserID: userID}
+	for _, record := range records {
+		summary.add(record)
+	}
+	return summary, nil
+}
+
+// BillingPeriodStart returns the start of the calendar month containing t,
+// which is the period quotas apply to
+func BillingPeriodStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+}
+
+// CheckQuota returns ErrQuotaExceeded when the user has reached the
+// workspace's quota for a metric in the current billing period
+func CheckQuota(userID, metric string) error {
+	settings, err := GetSettings()
+	if err != nil {
+		return nil
+	}
+	quota := settings.UsageQuota(metric)
+	if quota <= 0 {
+		return nil
+	}
+
+	usage, err := GetUserUsage(userID, BillingPeriodStart(time.Now()))
+	if err != nil {
+		return err
+	}
+	if usage.Get(metric) >= quota {
+		return errors.Wrapf(ErrQuotaExceeded, "%s limit of %s reached", UsageLabel(metric), FormatUsage(metric, quota))
+	}
+	return nil
+}
+
+// UsageLabel returns a metric's display name
+func UsageLabel(metric string) string {
+	switch metric {
+	case UsageStorage:
+		return "storage"
+	case UsageCIMinutes:
+		return "CI minutes"
+	case UsageAITokens:
+		return "AI tokens"
+	case UsageWorkerHours:
+		return "worker hours"
+	}
+	return metric
+}
+
+// FormatUsage formats a metric's value with its unit
+func FormatUsage(metric string, value float64) string {
+	switch metric {
+	case UsageStorage:
+		return formatBytes(int64(value))
+	case UsageCIMinutes:
+		return formatQuantity(value, "min")
+	case UsageAITokens:
+		return formatQuantity(value, "tokens")
+	case UsageWorkerHours:
+		return formatQuantity(value, "h")
+	}
+	return formatQuantity(value, "")
+}
+
+// formatQuantity formats a counter, keeping one decimal for small values
+func formatQuantity(value float64, unit string) string {
+	text := fmt.Sprintf("%.0f", value)
+	if value < 100 && value != float64(int64(value)) {
+		text = fmt.Sprintf("%.1f", value)
+	}
+	if unit == "" {
+		return text
+	}
+	return text + " " + unit
+}
@@ -0,0 +1,75 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/The-Skyscape/devtools/pkg/testutils"
+)
+
+func TestUsage(t *testing.T) {
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+
+	alice := CreateTestUser(t, db, "alice@example.com")
+	bob := CreateTestUser(t, db, "bob@example.com")
+	since := time.Now().Add(-time.Hour)
+
+	t.Run("RecordUsage", func(t *testing.T) {
+		testutils.AssertNoError(t, RecordUsage(alice.ID, UsageCIMinutes, 2.5, "repo-1", "run-1"))
+		testutils.AssertNoError(t, RecordUsage(alice.ID, UsageCIMinutes, 1.5, "repo-1", "run-2"))
+		testutils.AssertNoError(t, RecordUsage(alice.ID, UsageAITokens, 1200, "", "conversation-1"))
+		testutils.AssertNoError(t, RecordUsage(alice.ID, UsageStorage, 4096, "", "snapshot"))
+		testutils.AssertNoError(t, RecordUsage(alice.ID, UsageStorage, 1024, "", "snapshot"))
+		testutils.AssertNoError(t, RecordUsage(bob.ID, UsageWorkerHours, 0.25, "", "workspace"))
+
+		// Nothing to bill is not stored
+		testutils.AssertNoError(t, RecordUsage("", UsageAITokens, 10, "", ""))
+		testutils.AssertNoError(t, RecordUsage(bob.ID, UsageAITokens, 0, "", ""))
+		testutils.AssertEqual(t, 6, UsageRecords.Count(""))
+	})
+
+	t.Run("Summaries", func(t *testing.T) {
+		summaries, err := GetUsageSummaries(since, time.Now().Add(time.Minute))
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 2, len(summaries))
+
+		usage, err := GetUserUsage(alice.ID, since)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 4.0, usage.CIMinutes)
+		testutils.AssertEqual(t, 1200.0, usage.Get(UsageAITokens))
+		testutils.AssertEqual(t, 4096.0, usage.Storage)
+
+		summaries, err = GetUsageSummaries(since.Add(-time.Hour), since)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 0, len(summaries))
+	})
+
+	t.Run("Quota", func(t *testing.T) {
+		testutils.AssertNoError(t, CheckQuota(alice.ID, UsageCIMinutes))
+
+		settings, err := GetSettings()
+		testutils.AssertNoError(t, err)
+		settings.QuotaCIMinutes = 4
+		settings.QuotaAITokens = 5000
+		testutils.AssertNoError(t, GlobalSettings.Update(settings))
+
+		err = CheckQuota(alice.ID, UsageCIMinutes)
+		testutils.AssertTrue(t, errors.Is(err, ErrQuotaExceeded))
+		testutils.AssertNoError(t, CheckQuota(alice.ID, UsageAITokens))
+		testutils.AssertNoError(t, CheckQuota(bob.ID, UsageCIMinutes))
+	})
+
+	t.Run("Format", func(t *testing.T) {
+		testutils.AssertEqual(t, "2.5 min", FormatUsage(UsageCIMinutes, 2.5))
+		testutils.AssertEqual(t, "1200 tokens", FormatUsage(UsageAITokens, 1200))
+		testutils.AssertEqual(t, "4.0 KB", FormatUsage(UsageStorage, 4096))
+		testutils.AssertEqual(t, "3 h", FormatUsage(UsageWorkerHours, 3))
+	})
+}
+
+func TestBillingPeriodStart(t *testing.T) {
+	now := time.Date(2026, time.March, 17, 15, 4, 5, 0, time.UTC)
+	testutils.AssertEqual(t, time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC), BillingPeriodStart(now))
+}
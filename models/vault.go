@@ -0,0 +1,278 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"workspace/internal/crypto"
+
+	"github.com/The-Skyscape/devtools/pkg/application"
+)
+
+// FallbackSecret holds an encrypted copy of a secret written while Vault was
+// unavailable, so it can be moved into Vault once Vault recovers
+type FallbackSecret struct {
+	application.Model
+	Key  string // Secret path as passed to StoreSecret
+	Data string // AES-GCM encrypted JSON payload
+}
+
+// Table returns the database table name
+func (*FallbackSecret) Table() string { return "fallback_secrets" }
+
+// VaultStatus describes the current state of the secrets backend
+type VaultStatus struct {
+	Available         bool      // Vault is serving reads and writes
+	FallbackMode      bool      // Secrets are being written to the fallback store
+	Reachable         bool      // Vault answered the seal-status request
+	Initialized       bool      // Vault has been initialized
+	Sealed            bool      // Vault is sealed and needs unseal keys
+	Threshold         int       // Number of unseal keys required
+	Progress          int       // Unseal keys provided so far
+	Version           string    // Vault server version
+	PendingMigrations int       // Fallback secrets waiting to move into Vault
+	Error             string    // Last error talking to Vault
+	CheckedAt         time.Time // When this status was collected
+}
+
+// Healthy reports whether secrets are stored securely in Vault
+func (s *VaultStatus) Healthy() bool {
+	return s.Available && !s.Sealed && s.PendingMigrations == 0
+}
+
+// Mode returns a human readable description of the storage mode
+func (s *VaultStatus) Mode() string {
+	switch {
+	case s.Sealed:
+		return "Vault (Sealed)"
+	case s.Available:
+		return "Vault (Secure)"
+	case s.FallbackMode:
+		return "File System (Fallback)"
+	default:
+		return "Unavailable"
+	}
+}
+
+// vaultSealStatus is the response shape of Vault's sys/seal-status and sys/unseal endpoints
+type vaultSealStatus struct {
+	Initialized bool   `json:"initialized"`
+	Sealed      bool   `json:"sealed"`
+	Threshold   int    `json:"t"`
+	Progress    int    `json:"progress"`
+	Version     string `json:"version"`
+}
+
+var vaultClient = &http.Client{Timeout: 5 * time.Second}
+
+// VaultAddress returns the base URL of the Vault server on the internal network
+func VaultAddress() string {
+	if addr := os.Getenv("VAULT_ADDR"); addr != "" {
+		return strings.TrimSuffix(addr, "/")
+	}
+	return "http://skyscape-vault:8200"
+}
+
+// GetVaultStatus collects the current Vault and fallback storage state
+func GetVaultStatus() *VaultStatus {
+	status := &VaultStatus{
+		Available:    Secrets.IsVaultAvailable(),
+		FallbackMode: Secrets.IsFallbackMode(),
+		CheckedAt:    time.Now(),
+	}
+
+	if pending, err := FallbackSecrets.Search(""); err == nil {
+		status.PendingMigrations = len(pending)
+	}
+
+	seal, err := vaultRequest("GET", "/v1/sys/seal-status", nil)
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+
+	status.applySealStatus(seal)
+	return status
+}
+
+// UnsealVault submits an unseal key share to Vault and returns the resulting status
+func UnsealVault(key string) (*VaultStatus, error) {
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return nil, fmt.Errorf("unseal key is required")
+	}
+
+	seal, err := vaultRequest("PUT", "/v1/sys/unseal", map[string]string{"key": key})
+	if err != nil {
+		return nil, fmt.Errorf("failed to unseal vault: %w", err)
+	}
+
+	status := GetVaultStatus()
+	status.applySealStatus(seal)
+	return status, nil
+}
+
+// AutoUnsealVault unseals Vault using the key shares in VAULT_UNSEAL_KEYS.
+// It returns false when no keys are configured or Vault is not sealed.
+func AutoUnsealVault() (bool, error) {
+	keys := strings.FieldsFunc(os.Getenv("VAULT_UNSEAL_KEYS"), func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\n'
+	})
+	if len(keys) == 0 {
+		return false, nil
+	}
+
+	status := GetVaultStatus()
+	if !status.Reachable || !status.Sealed {
+		return false, nil
+	}
+
+	for _, key := range keys {
+		status, err := UnsealVault(key)
+		if err != nil {
+			return false, err
+		}
+		if !status.Sealed {
+			log.Printf("Vault: Auto-unsealed with %d key shares", status.Threshold)
+			return true, nil
+		}
+	}
+
+	return false, fmt.Errorf("vault still sealed after %d configured key shares", len(keys))
+}
+
+// StoreSecret writes a secret through the secrets backend, keeping an encrypted
+// copy when Vault is down so the secret can be migrated later
+func StoreSecret(key string, data map[string]any) error {
+	if err := Secrets.StoreSecret(key, data); err != nil {
+		return err
+	}
+
+	if Secrets.IsVaultAvailable() {
+		return nil
+	}
+
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to encode secret for migration: %w", err)
+	}
+	encrypted, err := crypto.Encrypt(string(payload))
+	if err != nil {
+		return fmt.Errorf("failed to encrypt secret for migration: %w", err)
+	}
+
+	// Keep only the latest value for each key
+	if existing, err := FallbackSecrets.Search("WHERE Key = ?", key); err == nil {
+		for _, secret := range existing {
+			FallbackSecrets.Delete(secret)
+		}
+	}
+
+	_, err = FallbackSecrets.Insert(&FallbackSecret{Key: key, Data: encrypted})
+	return err
+}
+
+// DeleteSecret removes a secret and any copy still waiting for migration
+func DeleteSecret(key string) error {
+	if existing, err := FallbackSecrets.Search("WHERE Key = ?", key); err == nil {
+		for _, secret := range existing {
+			FallbackSecrets.Delete(secret)
+		}
+	}
+	return Secrets.DeleteSecret(key)
+}
+
+// MigrateFallbackSecrets moves secrets written during a Vault outage into Vault.
+// It returns the number of secrets migrated.
+func MigrateFallbackSecrets() (int, error) {
+	if !Secrets.IsVaultAvailable() {
+		return 0, fmt.Errorf("vault is not available")
+	}
+
+	pending, err := FallbackSecrets.Search("ORDER BY CreatedAt ASC")
+	if err != nil {
+		return 0, err
+	}
+
+	migrated := 0
+	for _, secret := range pending {
+		payload, err := crypto.Decrypt(secret.Data)
+		if err != nil {
+			log.Printf("Vault: Failed to decrypt fallback secret %s: %v", secret.Key, err)
+			continue
+		}
+
+		var data map[string]any
+		if err := json.Unmarshal([]byte(payload), &data); err != nil {
+			log.Printf("Vault: Failed to decode fallback secret %s: %v", secret.Key, err)
+			continue
+		}
+
+		if err := Secrets.StoreSecret(secret.Key, data); err != nil {
+			return migrated, fmt.Errorf("failed to migrate %s: %w", secret.Key, err)
+		}
+
+		FallbackSecrets.Delete(secret)
+		migrated++
+	}
+
+	if migrated > 0 {
+		log.Printf("Vault: Migrated %d fallback secrets into Vault", migrated)
+	}
+	return migrated, nil
+}
+
+// applySealStatus copies seal information from a Vault response
+func (s *VaultStatus) applySealStatus(seal *vaultSealStatus) {
+	s.Reachable = true
+	s.Initialized = seal.Initialized
+	s.Sealed = seal.Sealed
+	s.Threshold = seal.Threshold
+	s.Progress = seal.Progress
+	s.Version = seal.Version
+	if seal.Sealed {
+		s.Available = false
+	}
+}
+
+// vaultRequest calls a Vault system endpoint and decodes its seal status
+func vaultRequest(method, path string, body any) (*vaultSealStatus, error) {
+	var reader *bytes.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+
+	req, err := http.NewRequest(method, VaultAddress()+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := vaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("vault returned status %d", resp.StatusCode)
+	}
+
+	var seal vaultSealStatus
+	if err := json.NewDecoder(resp.Body).Decode(&seal); err != nil {
+		return nil, fmt.Errorf("failed to decode vault response: %w", err)
+	}
+	return &seal, nil
+}
@@ -0,0 +1,181 @@
+package models
+
+import (
+	"net/url"
+	"strings"
+	"time"
+
+	"workspace/internal/netguard"
+
+	"github.com/The-Skyscape/devtools/pkg/application"
+	"github.com/pkg/errors"
+)
+
+// Events a webhook can subscribe to
+const (
+	WebhookEventPush        = "push"         // Branches pushed over git
+	WebhookEventIssues      = "issues"       // Issues opened, edited, labeled, assigned, milestoned, closed, reopened or deleted
+	WebhookEventPullRequest = "pull_request" // Pull requests opened, merged or closed
+	WebhookEventComment     = "comment"      // Comments on issues and pull requests
+	WebhookEventRepository  = "repository"   // Repository settings changed
+	WebhookEventAI          = "ai_task"      // AI queue tasks that completed or failed
+	WebhookEventDeployment  = "deployment"   // Deployments to environments that finished, with their health checks
+	WebhookEventPing        = "ping"         // Sent on demand to test an endpoint
+)
+
+// WebhookEvents lists the events a webhook can subscribe to
+var WebhookEvents = []string{
+	WebhookEventPush, WebhookEventIssues, WebhookEventPullRequest,
+	WebhookEventComment, WebhookEventRepository, WebhookEventAI,
+	WebhookEventDeployment,
+}
+
+// maxWebhookDeliveries is how many deliveries are kept per webhook
+const maxWebhookDeliveries = 50
+
+// Webhook posts a repository's events as JSON to an external URL
+type Webhook struct {
+	application.Model
+	RepoID         string
+	URL            string
+	Secret         string // Signs each payload with HMAC-SHA256 when set
+	Events         string // One event per line, empty subscribes to every event
+	Active         bool
+	CreatedBy      string
+	LastStatus     int // HTTP status of the latest delivery, 0 when it couldn't be sent
+	LastDeliveryAt time.Time
+}
+
+// Table returns the database table name
+func (*Webhook) Table() string { return "webhooks" }
+
+// WebhookDelivery records one attempt to deliver an event to a webhook
+type WebhookDelivery struct {
+	application.Model
+	WebhookID  string
+	RepoID     string
+	Event      string
+	Action     string
+	Payload    string // JSON body as it was sent
+	StatusCode int    // 0 when the request failed before a response
+	Response   string // Start of the response body
+	Error      string
+	Duration   int64 // Milliseconds
+	Redelivery bool  // Sent again by an admin
+}
+
+// Table returns the database table name
+func (*WebhookDelivery) Table() string { return "webhook_deliveries" }
+
+// CreateWebhook validates and adds a webhook to a repository
+func CreateWebhook(repoID, rawURL, secret string, events []string, userID string) (*Webhook, error) {
+	rawURL = strings.TrimSpace(rawURL)
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, errors.New("the webhook must be an http or https URL")
+	}
+	if err := netguard.CheckURL(u); err != nil {
+		return nil, errors.Wrap(err, "the webhook must be on a public host")
+	}
+	for _, event := range events {
+		known := false
+		for _, e := range WebhookEvents {
+			known = known || e == event
+		}
+		if !known {
+			return nil, errors.Errorf("unknown webhook event %q", event)
+		}
+	}
+
+	hook, err := Webhooks.Insert(&Webhook{
+		Model:     DB.NewModel(""),
+		RepoID:    repoID,
+		URL:       rawURL,
+		Secret:    strings.TrimSpace(secret),
+		Events:    strings.Join(events, "\n"),
+		Active:    true,
+		CreatedBy: userID,
+	})
+	return hook, errors.Wrap(err, "failed to create webhook")
+}
+
+// RepoWebhooks returns a repository's webhooks, oldest first
+func RepoWebhooks(repoID string) ([]*Webhook, error) {
+	return Webhooks.Search("WHERE RepoID = ? ORDER BY CreatedAt ASC", repoID)
+}
+
+// WebhooksFor returns the repository's active webhooks subscribed to event
+func WebhooksFor(repoID, event string) ([]*Webhook, error) {
+	hooks, err := Webhooks.Search("WHERE RepoID = ? AND Active = ?", repoID, true)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find webhooks")
+	}
+	var subscribed []*Webhook
+	for _, hook := range hooks {
+		if hook.Subscribes(event) {
+			subscribed = append(subscribed, hook)
+		}
+	}
+	return subscribed, nil
+}
+
+// DeleteWebhook removes a webhook and its delivery history
+func DeleteWebhook(hook *Webhook) error {
+	if err := Webhooks.Delete(hook); err != nil {
+		return errors.Wrap(err, "failed to delete webhook")
+	}
+	err := DB.Query("DELETE FROM webhook_deliveries WHERE WebhookID = ?", hook.ID).Exec()
+	return errors.Wrap(err, "failed to delete webhook deliveries")
+}
+
+// EventList returns the events the webhook subscribes to, empty for all
+func (w *Webhook) EventList() []string {
+	return splitPolicyList(w.Events)
+}
+
+// Subscribes reports whether the webhook receives event. Pings always go
+// through.
+func (w *Webhook) Subscribes(event string) bool {
+	events := w.EventList()
+	if len(events) == 0 || event == WebhookEventPing {
+		return true
+	}
+	for _, e := range events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Deliveries returns the webhook's most recent deliveries, newest first
+func (w *Webhook) Deliveries(limit int) ([]*WebhookDelivery, error) {
+	return WebhookDeliveries.Search("WHERE WebhookID = ? ORDER BY CreatedAt DESC LIMIT ?", w.ID, limit)
+}
+
+// RecordWebhookDelivery stores a delivery, notes its outcome on the
+// webhook and drops the webhook's oldest deliveries beyond the ones kept
+func RecordWebhookDelivery(hook *Webhook, delivery *WebhookDelivery) (*WebhookDelivery, error) {
+	delivery.WebhookID = hook.ID
+	delivery.RepoID = hook.RepoID
+	delivery, err := WebhookDeliveries.Insert(delivery)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to record webhook delivery")
+	}
+
+	hook.LastStatus = delivery.StatusCode
+	hook.LastDeliveryAt = delivery.CreatedAt
+	if err := Webhooks.Update(hook); err != nil {
+		return nil, errors.Wrap(err, "failed to update webhook")
+	}
+
+	err = DB.Query(`DELETE FROM webhook_deliveries WHERE WebhookID = ? AND ID NOT IN
+		(SELECT ID FROM webhook_deliveries WHERE WebhookID = ? ORDER BY CreatedAt DESC LIMIT ?)`,
+		hook.ID, hook.ID, maxWebhookDeliveries).Exec()
+	return delivery, errors.Wrap(err, "failed to prune webhook deliveries")
+}
+
+// Succeeded reports whether the endpoint accepted the delivery
+func (d *WebhookDelivery) Succeeded() bool {
+	return d.Error == "" && d.StatusCode >= 200 && d.StatusCode <= 299
+}
@@ -0,0 +1,84 @@
+package models
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/The-Skyscape/devtools/pkg/testutils"
+)
+
+func TestWebhook(t *testing.T) {
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+
+	t.Run("CreateAndFilter", func(t *testing.T) {
+		all, err := CreateWebhook("hook-repo", "https://example.com/all", "", nil, "admin")
+		testutils.AssertNoError(t, err)
+		testutils.AssertTrue(t, all.Active)
+		testutils.AssertTrue(t, all.Subscribes(WebhookEventPush))
+
+		issues, err := CreateWebhook("hook-repo", " https://example.com/issues ", "s3cret", []string{WebhookEventIssues}, "admin")
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, "https://example.com/issues", issues.URL)
+		testutils.AssertFalse(t, issues.Subscribes(WebhookEventPush))
+		testutils.AssertTrue(t, issues.Subscribes(WebhookEventPing))
+
+		hooks, err := WebhooksFor("hook-repo", WebhookEventPush)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 1, len(hooks))
+
+		all.Active = false
+		testutils.AssertNoError(t, Webhooks.Update(all))
+		hooks, err = WebhooksFor("hook-repo", WebhookEventIssues)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 1, len(hooks))
+		testutils.AssertEqual(t, issues.ID, hooks[0].ID)
+	})
+
+	t.Run("RejectsInvalidWebhooks", func(t *testing.T) {
+		_, err := CreateWebhook("hook-repo", "ftp://example.com", "", nil, "admin")
+		testutils.AssertError(t, err)
+		_, err = CreateWebhook("hook-repo", "https://example.com", "", []string{"deploys"}, "admin")
+		testutils.AssertError(t, err)
+	})
+
+	t.Run("RejectsLocalAddresses", func(t *testing.T) {
+		for _, target := range []string{
+			"http://127.0.0.1:8080/hook",
+			"http://169.254.169.254/latest/meta-data/",
+			"http://10.0.0.5/hook",
+			"http://[::1]/hook",
+			"http://localhost/hook",
+		} {
+			_, err := CreateWebhook("hook-repo", target, "", nil, "admin")
+			testutils.AssertError(t, err)
+		}
+	})
+
+	t.Run("RecordsAndPrunesDeliveries", func(t *testing.T) {
+		hook, err := CreateWebhook("hook-deliveries", "https://example.com/hook", "", nil, "admin")
+		testutils.AssertNoError(t, err)
+
+		for i := 0; i < maxWebhookDeliveries+5; i++ {
+			_, err := RecordWebhookDelivery(hook, &WebhookDelivery{
+				Event:      WebhookEventPush,
+				Payload:    fmt.Sprintf(`{"n":%d}`, i),
+				StatusCode: 200,
+			})
+			testutils.AssertNoError(t, err)
+		}
+		failed, err := RecordWebhookDelivery(hook, &WebhookDelivery{Event: WebhookEventPush, Error: "connection refused"})
+		testutils.AssertNoError(t, err)
+		testutils.AssertFalse(t, failed.Succeeded())
+
+		deliveries, err := hook.Deliveries(100)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, maxWebhookDeliveries, len(deliveries))
+		testutils.AssertEqual(t, 0, hook.LastStatus)
+
+		testutils.AssertNoError(t, DeleteWebhook(hook))
+		deliveries, err = hook.Deliveries(100)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 0, len(deliveries))
+	})
+}
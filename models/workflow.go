@@ -0,0 +1,513 @@
+package models
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"workspace/internal/lint"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// WorkflowsDir is where repositories keep their workflow definitions
+const WorkflowsDir = ".skyscape/workflows"
+
+// Events that start workflows
+const (
+	WorkflowEventPush        = "push"
+	WorkflowEventPullRequest = "pr"
+	WorkflowEventSchedule    = "schedule"
+	WorkflowEventManual      = "manual"
+)
+
+// Workflow defaults and limits
+const (
+	DefaultWorkflowImage   = "alpine:latest"
+	DefaultWorkflowTimeout = 30  // Minutes a job may run without timeout_minutes
+	MaxWorkflowTimeout     = 360 // Most minutes timeout_minutes may allow
+)
+
+var (
+	// workflowJobID keeps job IDs usable in container names and URLs
+	workflowJobID = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+	// workflowEnvName is a variable name a shell accepts
+	workflowEnvName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+	// workflowImage is a Docker image reference, which can't start with -
+	workflowImage = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._/:@-]*$`)
+)
+
+// Workflow is a pipeline defined in a YAML file under WorkflowsDir: the
+// events that start it and the jobs it runs, each in its own container
+type Workflow struct {
+	Name   string                  `yaml:"name"`
+	On     WorkflowTriggers        `yaml:"on"`
+	Env    map[string]string       `yaml:"env"`
+	Jobs   map[string]*WorkflowJob `yaml:"jobs"`
+	File   string                  `yaml:"-"` // Path of the workflow within the repository
+	Source string                  `yaml:"-"` // The file's YAML
+	Error  string                  `yaml:"-"` // Why the file isn't a valid workflow, if it isn't
+}
+
+// WorkflowTriggers are the events a workflow runs on. They can be given as
+// one event name, a list of names, or a mapping with each event's settings.
+type WorkflowTriggers struct {
+	Push        *WorkflowBranches // Pushes to matching branches
+	PullRequest *WorkflowBranches // Pull requests into matching branches, on opening and each push
+	Schedule    []string          // Cron expressions, run on the default branch
+	Manual      bool              // Started from the workflows page
+}
+
+// WorkflowBranches limits an event to branches matching any of the
+// patterns, where * matches anything including /. No patterns match all.
+type WorkflowBranches struct {
+	Branches workflowList `yaml:"branches"`
+}
+
+// WorkflowJob is a list of steps run in order in one container
+type WorkflowJob struct {
+	ID             string            `yaml:"-"`
+	Name           string            `yaml:"name"`
+	Image          string            `yaml:"image"`
+	Needs          workflowList      `yaml:"needs"`
+	Env            map[string]string `yaml:"env"`
+	TimeoutMinutes int               `yaml:"timeout_minutes"`
+	Artifacts      workflowList      `yaml:"artifacts"`    // Paths in the workspace kept after the job
+	TestResults    workflowList      `yaml:"test_results"` // Test reports in the workspace, as go test -json, JUnit XML or pytest output
+	Images         []*WorkflowImage  `yaml:"images"`       // Built and pushed once the steps succeed
+	Steps          []*WorkflowStep   `yaml:"steps"`
+}
+
+// WorkflowImage is a Docker image built from the job's workspace and pushed
+// to the repository's namespace in the container registry
+type WorkflowImage struct {
+	Name       string       `yaml:"name"`
+	Dockerfile string       `yaml:"dockerfile"` // Dockerfile in the context by default
+	Context    string       `yaml:"context"`    // The workspace by default
+	Tags       workflowList `yaml:"tags"`       // The commit's short SHA and the branch by default
+}
+
+// WorkflowStep is a shell script run in the job's container, or linters
+// whose findings are recorded and fail the step
+type WorkflowStep struct {
+	Name string            `yaml:"name"`
+	Run  string            `yaml:"run"`
+	Lint workflowList      `yaml:"lint"` // Linters to run instead of a script, or all
+	Env  map[string]string `yaml:"env"`
+}
+
+// workflowList is a list of strings that may be written as a single one
+type workflowList []string
+
+// UnmarshalYAML accepts a string or a list of strings
+func (l *workflowList) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*l = workflowList{node.Value}
+		return nil
+	}
+	var list []string
+	if err := node.Decode(&list); err != nil {
+		return err
+	}
+	*l = list
+	return nil
+}
+
+// UnmarshalYAML reads the forms triggers may be written in: "push",
+// [push, pr], or a mapping like {push: {branches: [main]}, schedule: ...}
+func (t *WorkflowTriggers) UnmarshalYAML(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		return t.set(node.Value, nil)
+	case yaml.SequenceNode:
+		for _, item := range node.Content {
+			if item.Kind != yaml.ScalarNode {
+				return errors.Errorf("line %d: list events by name, or use a mapping for their settings", item.Line)
+			}
+			if err := t.set(item.Value, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if err := t.set(node.Content[i].Value, node.Content[i+1]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return errors.Errorf("line %d: on lists the events that start the workflow", node.Line)
+}
+
+// set turns on an event, with its settings when given
+func (t *WorkflowTriggers) set(event string, settings *yaml.Node) error {
+	empty := settings == nil || settings.Tag == "!!null"
+	switch event {
+	case WorkflowEventPush, WorkflowEventPullRequest, "pull_request":
+		branches := &WorkflowBranches{}
+		if !empty {
+			if err := settings.Decode(branches); err != nil {
+				return errors.Wrapf(err, "%s settings", event)
+			}
+		}
+		if event == WorkflowEventPush {
+			t.Push = branches
+		} else {
+			t.PullRequest = branches
+		}
+	case WorkflowEventSchedule:
+		if empty {
+			return errors.New("schedule needs a cron expression")
+		}
+		// Either cron expressions, or GitHub style [{cron: ...}]
+		var crons []struct {
+			Cron string `yaml:"cron"`
+		}
+		if settings.Kind == yaml.SequenceNode && settings.Decode(&crons) == nil {
+			for _, c := range crons {
+				t.Schedule = append(t.Schedule, c.Cron)
+			}
+			return nil
+		}
+		var list workflowList
+		if err := settings.Decode(&list); err != nil {
+			return errors.Wrap(err, "schedule settings")
+		}
+		t.Schedule = append(t.Schedule, list...)
+	case WorkflowEventManual, "workflow_dispatch":
+		t.Manual = true
+	default:
+		return errors.Errorf("unknown event %q, use push, pr, schedule or manual", event)
+	}
+	return nil
+}
+
+// Matches reports whether the branch is one the event applies to
+func (b *WorkflowBranches) Matches(branch string) bool {
+	if b == nil {
+		return false
+	}
+	if len(b.Branches) == 0 {
+		return true
+	}
+	for _, pattern := range b.Branches {
+		if (&BranchProtection{Pattern: pattern}).Matches(branch) {
+			return true
+		}
+	}
+	return false
+}
+
+// Events lists the events the workflow runs on, for display
+func (t WorkflowTriggers) Events() []string {
+	var events []string
+	if t.Push != nil {
+		events = append(events, WorkflowEventPush)
+	}
+	if t.PullRequest != nil {
+		events = append(events, WorkflowEventPullRequest)
+	}
+	if len(t.Schedule) > 0 {
+		events = append(events, WorkflowEventSchedule)
+	}
+	if t.Manual {
+		events = append(events, WorkflowEventManual)
+	}
+	return events
+}
+
+// ParseWorkflow decodes and validates a workflow definition
+func ParseWorkflow(data []byte) (*Workflow, error) {
+	var wf Workflow
+	if err := yaml.Unmarshal(data, &wf); err != nil {
+		return nil, errors.Wrap(err, "invalid workflow")
+	}
+	wf.Name = strings.TrimSpace(wf.Name)
+
+	if len(wf.On.Events()) == 0 {
+		return nil, errors.New("on must list at least one event")
+	}
+	for _, expr := range wf.On.Schedule {
+		if _, err := ParseCron(expr); err != nil {
+			return nil, err
+		}
+	}
+	if err := checkWorkflowEnv(wf.Env); err != nil {
+		return nil, err
+	}
+	if len(wf.Jobs) == 0 {
+		return nil, errors.New("workflow has no jobs")
+	}
+
+	for id, job := range wf.Jobs {
+		if job == nil {
+			return nil, errors.Errorf("job %q is empty", id)
+		}
+		if !workflowJobID.MatchString(id) {
+			return nil, errors.Errorf("job ID %q may only have letters, digits, - and _", id)
+		}
+		job.ID = id
+		if job.Name = strings.TrimSpace(job.Name); job.Name == "" {
+			job.Name = id
+		}
+		if job.Image == "" {
+			job.Image = DefaultWorkflowImage
+		}
+		if !workflowImage.MatchString(job.Image) {
+			return nil, errors.Errorf("job %q: %q is not a valid image", id, job.Image)
+		}
+		if job.TimeoutMinutes == 0 {
+			job.TimeoutMinutes = DefaultWorkflowTimeout
+		}
+		if job.TimeoutMinutes < 1 || job.TimeoutMinutes > MaxWorkflowTimeout {
+			return nil, errors.Errorf("job %q: timeout_minutes must be between 1 and %d", id, MaxWorkflowTimeout)
+		}
+		if err := checkWorkflowEnv(job.Env); err != nil {
+			return nil, errors.Wrapf(err, "job %q", id)
+		}
+		for _, need := range job.Needs {
+			if _, ok := wf.Jobs[need]; !ok || need == id {
+				return nil, errors.Errorf("job %q needs %q, which isn't another job", id, need)
+			}
+		}
+		for _, artifact := range job.Artifacts {
+			if !inWorkspace(artifact) {
+				return nil, errors.Errorf("job %q: artifact %q must be inside the workspace", id, artifact)
+			}
+		}
+		for _, report := range job.TestResults {
+			if !inWorkspace(report) {
+				return nil, errors.Errorf("job %q: test results %q must be inside the workspace", id, report)
+			}
+		}
+		for _, image := range job.Images {
+			if err := checkWorkflowImage(image); err != nil {
+				return nil, errors.Wrapf(err, "job %q", id)
+			}
+		}
+
+		if len(job.Steps) == 0 {
+			return nil, errors.Errorf("job %q has no steps", id)
+		}
+		for i, step := range job.Steps {
+			if step != nil && len(step.Lint) > 0 {
+				if err := checkLintStep(step); err != nil {
+					return nil, errors.Wrapf(err, "job %q step %d", id, i+1)
+				}
+			}
+			if step == nil || strings.TrimSpace(step.Run) == "" {
+				return nil, errors.Errorf("job %q: step %d has nothing to run", id, i+1)
+			}
+			if err := checkWorkflowEnv(step.Env); err != nil {
+				return nil, errors.Wrapf(err, "job %q step %d", id, i+1)
+			}
+			if step.Name = strings.TrimSpace(step.Name); step.Name == "" {
+				step.Name, _, _ = strings.Cut(strings.TrimSpace(step.Run), "\n")
+			}
+		}
+	}
+
+	if _, err := wf.orderJobs(); err != nil {
+		return nil, err
+	}
+	return &wf, nil
+}
+
+// checkLintStep fills in the script and name of a step that runs linters
+func checkLintStep(step *WorkflowStep) error {
+	if strings.TrimSpace(step.Run) != "" {
+		return errors.New("a step can't both run a script and lint")
+	}
+	linters, err := lint.Select(step.Lint)
+	if err != nil {
+		return err
+	}
+	step.Run = lint.Script(linters)
+	if step.Name == "" {
+		names := make([]string, len(linters))
+		for i, linter := range linters {
+			names[i] = linter.Name
+		}
+		step.Name = "Lint with " + strings.Join(names, ", ")
+	}
+	return nil
+}
+
+// checkWorkflowImage rejects images that can't be pushed, or would be built
+// from outside the workspace, and fills in their defaults
+func checkWorkflowImage(image *WorkflowImage) error {
+	if image == nil || !ValidImageName(image.Name) {
+		return errors.New("images need a lowercase name like app or tools/cli")
+	}
+	if image.Context == "" {
+		image.Context = "."
+	}
+	if image.Dockerfile == "" {
+		image.Dockerfile = path.Join(image.Context, "Dockerfile")
+	}
+	if !inWorkspace(image.Context) || !inWorkspace(image.Dockerfile) {
+		return errors.Errorf("image %s must be built from inside the workspace", image.Name)
+	}
+	for _, tag := range image.Tags {
+		if !ValidImageTag(tag) {
+			return errors.Errorf("image %s: %q is not a valid tag", image.Name, tag)
+		}
+	}
+	return nil
+}
+
+// inWorkspace reports whether a path stays inside the job's workspace
+func inWorkspace(file string) bool {
+	clean := path.Clean(file)
+	return !path.IsAbs(clean) && clean != ".." && !strings.HasPrefix(clean, "../")
+}
+
+// checkWorkflowEnv rejects variable names a shell can't use
+func checkWorkflowEnv(env map[string]string) error {
+	for name := range env {
+		if !workflowEnvName.MatchString(name) {
+			return errors.Errorf("%q is not a valid environment variable name", name)
+		}
+	}
+	return nil
+}
+
+// OrderedJobs returns the workflow's jobs in the order they run, each
+// after the jobs it needs
+func (w *Workflow) OrderedJobs() []*WorkflowJob {
+	jobs, _ := w.orderJobs()
+	return jobs
+}
+
+// orderJobs sorts the jobs so each comes after the jobs it needs, keeping
+// independent jobs in ID order. It fails when jobs need each other.
+func (w *Workflow) orderJobs() ([]*WorkflowJob, error) {
+	ids := make([]string, 0, len(w.Jobs))
+	for id := range w.Jobs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var ordered []*WorkflowJob
+	done := map[string]bool{}
+	for len(ordered) < len(ids) {
+		progressed := false
+		for _, id := range ids {
+			job := w.Jobs[id]
+			if done[id] {
+				continue
+			}
+			ready := true
+			for _, need := range job.Needs {
+				ready = ready && done[need]
+			}
+			if ready {
+				ordered = append(ordered, job)
+				done[id] = true
+				progressed = true
+			}
+		}
+		if !progressed {
+			var stuck []string
+			for _, id := range ids {
+				if !done[id] {
+					stuck = append(stuck, id)
+				}
+			}
+			return nil, errors.Errorf("jobs %s need each other", strings.Join(stuck, ", "))
+		}
+	}
+	return ordered, nil
+}
+
+// Triggered reports whether an event on a branch starts the workflow. For
+// pull requests the branch is the one being merged into.
+func (w *Workflow) Triggered(event, branch string) bool {
+	switch event {
+	case WorkflowEventPush:
+		return w.On.Push.Matches(branch)
+	case WorkflowEventPullRequest:
+		return w.On.PullRequest.Matches(branch)
+	case WorkflowEventSchedule:
+		return len(w.On.Schedule) > 0
+	case WorkflowEventManual:
+		return w.On.Manual
+	}
+	return false
+}
+
+// GetWorkflows returns the workflows defined at a branch or commit.
+// Files that aren't valid workflows are included with their Error set.
+func (r *Repository) GetWorkflows(ref string) ([]*Workflow, error) {
+	if ref == "" {
+		ref = r.GetDefaultBranch()
+	}
+	commit, err := r.ResolveCommit(ref)
+	if err != nil {
+		// An empty repository or missing branch has no workflows
+		return nil, nil
+	}
+
+	stdout, _, err := r.Git("ls-tree", "-z", "--name-only", commit, "--", WorkflowsDir+"/")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list workflows")
+	}
+
+	var workflows []*Workflow
+	for _, file := range strings.Split(stdout.String(), "\x00") {
+		ext := path.Ext(file)
+		if ext != ".yml" && ext != ".yaml" {
+			continue
+		}
+		name := strings.TrimSuffix(path.Base(file), ext)
+
+		content, _, err := r.Git("show", commit+":"+file)
+		if err != nil {
+			workflows = append(workflows, &Workflow{Name: name, File: file, Error: "file can't be read"})
+			continue
+		}
+		wf, err := ParseWorkflow(content.Bytes())
+		if err != nil {
+			workflows = append(workflows, &Workflow{Name: name, File: file, Error: err.Error()})
+			continue
+		}
+		if wf.Name == "" {
+			wf.Name = name
+		}
+		wf.File, wf.Source = file, content.String()
+		workflows = append(workflows, wf)
+	}
+
+	sort.Slice(workflows, func(i, j int) bool { return workflows[i].File < workflows[j].File })
+	return workflows, nil
+}
+
+// GetWorkflow returns the valid workflow defined in a file at a branch or
+// commit
+func (r *Repository) GetWorkflow(ref, file string) (*Workflow, error) {
+	workflows, err := r.GetWorkflows(ref)
+	if err != nil {
+		return nil, err
+	}
+	for _, wf := range workflows {
+		if wf.File != file {
+			continue
+		}
+		if wf.Error != "" {
+			return nil, errors.Errorf("%s: %s", file, wf.Error)
+		}
+		return wf, nil
+	}
+	return nil, errors.Errorf("workflow %s not found", file)
+}
+
+// StatusContext is the commit status context a job reports under
+func (w *Workflow) StatusContext(job *WorkflowJob) string {
+	return fmt.Sprintf("%s / %s", w.Name, job.Name)
+}
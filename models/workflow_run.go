@@ -0,0 +1,244 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/The-Skyscape/devtools/pkg/application"
+	"github.com/pkg/errors"
+)
+
+// Where workflow runs, jobs and steps are
+const (
+	WorkflowQueued    = "queued"
+	WorkflowRunning   = "running"
+	WorkflowSuccess   = "success"
+	WorkflowFailed    = "failed"
+	WorkflowCancelled = "cancelled"
+	WorkflowSkipped   = "skipped" // Jobs whose needs failed, and the steps after a failed one
+)
+
+// WorkflowRun is one run of a workflow on a commit
+type WorkflowRun struct {
+	application.Model
+	RepoID      string
+	Number      int    // Counts up per repository
+	Workflow    string // The workflow's name
+	File        string // Path of the workflow in the repository
+	Definition  string // The workflow's YAML as it was when the run started
+	Event       string // WorkflowEventPush, WorkflowEventPullRequest, WorkflowEventSchedule or WorkflowEventManual
+	Branch      string
+	CommitSHA   string
+	PRID        string // The pull request for WorkflowEventPullRequest runs
+	TriggeredBy string
+	Status      string
+	StartedAt   time.Time
+	Duration    int // Seconds, once finished
+}
+
+// Table returns the database table name
+func (*WorkflowRun) Table() string { return "workflow_runs" }
+
+// WorkflowJobRun is one job of a workflow run
+type WorkflowJobRun struct {
+	application.Model
+	RunID     string
+	JobID     string
+	Name      string
+	Position  int // Order the job runs in
+	Image     string
+	Status    string
+	StartedAt time.Time
+	Duration  int
+}
+
+// Table returns the database table name
+func (*WorkflowJobRun) Table() string { return "workflow_job_runs" }
+
+// WorkflowStepRun is one step of a workflow job, with its log
+type WorkflowStepRun struct {
+	application.Model
+	RunID    string
+	JobRunID string
+	Position int
+	Name     string
+	Script   string
+	Status   string
+	ExitCode int
+	Output   string // Combined stdout and stderr, once finished
+	Duration int
+}
+
+// Table returns the database table name
+func (*WorkflowStepRun) Table() string { return "workflow_step_runs" }
+
+// CreateWorkflowRun records a queued run of a workflow, with its jobs and
+// steps, ready to be executed. The run says where and why it runs: its
+// repository, event, branch, commit and who triggered it.
+func CreateWorkflowRun(wf *Workflow, run *WorkflowRun) (*WorkflowRun, error) {
+	run.Number = 1
+	if latest, err := WorkflowRuns.Search("WHERE RepoID = ? ORDER BY Number DESC LIMIT 1", run.RepoID); err == nil && len(latest) > 0 {
+		run.Number = latest[0].Number + 1
+	}
+	run.Workflow, run.File, run.Definition = wf.Name, wf.File, wf.Source
+	run.Status = WorkflowQueued
+
+	run, err := WorkflowRuns.Insert(run)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to record workflow run")
+	}
+
+	for i, job := range wf.OrderedJobs() {
+		jobRun, err := WorkflowJobRuns.Insert(&WorkflowJobRun{
+			RunID:    run.ID,
+			JobID:    job.ID,
+			Name:     job.Name,
+			Position: i,
+			Image:    job.Image,
+			Status:   WorkflowQueued,
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to record workflow job")
+		}
+		for j, step := range job.Steps {
+			if _, err := WorkflowStepRuns.Insert(&WorkflowStepRun{
+				RunID:    run.ID,
+				JobRunID: jobRun.ID,
+				Position: j,
+				Name:     step.Name,
+				Script:   step.Run,
+				Status:   WorkflowQueued,
+			}); err != nil {
+				return nil, errors.Wrap(err, "failed to record workflow step")
+			}
+		}
+		// Images are pushed in steps of their own after the job's
+		for j, image := range job.Images {
+			if _, err := WorkflowStepRuns.Insert(&WorkflowStepRun{
+				RunID:    run.ID,
+				JobRunID: jobRun.ID,
+				Position: len(job.Steps) + j,
+				Name:     "Push image " + image.Name,
+				Script:   "docker build -f " + image.Dockerfile + " " + image.Context,
+				Status:   WorkflowQueued,
+			}); err != nil {
+				return nil, errors.Wrap(err, "failed to record workflow step")
+			}
+		}
+	}
+	return run, nil
+}
+
+// GetWorkflowRuns returns a repository's latest workflow runs, newest first
+func GetWorkflowRuns(repoID string, limit int) ([]*WorkflowRun, error) {
+	return WorkflowRuns.Search("WHERE RepoID = ? ORDER BY Number DESC LIMIT ?", repoID, limit)
+}
+
+// FailInterruptedWorkflowRuns marks runs that were queued or running when
+// the server stopped as failed, since nothing will finish them
+func FailInterruptedWorkflowRuns() error {
+	runs, err := WorkflowRuns.Search("WHERE Status IN (?, ?)", WorkflowQueued, WorkflowRunning)
+	if err != nil {
+		return errors.Wrap(err, "failed to find interrupted workflow runs")
+	}
+	for _, run := range runs {
+		jobs, err := run.Jobs()
+		if err != nil {
+			return err
+		}
+		for _, job := range jobs {
+			steps, err := job.Steps()
+			if err != nil {
+				return err
+			}
+			for _, step := range steps {
+				if step.Finished() {
+					continue
+				}
+				step.Status = WorkflowCancelled
+				step.Output += "\nInterrupted by a server restart"
+				WorkflowStepRuns.Update(step)
+			}
+			if !job.Finished() {
+				job.Status = WorkflowCancelled
+				WorkflowJobRuns.Update(job)
+			}
+		}
+		run.Status = WorkflowFailed
+		if err := WorkflowRuns.Update(run); err != nil {
+			return errors.Wrap(err, "failed to update interrupted workflow run")
+		}
+	}
+	return nil
+}
+
+// Jobs returns the run's jobs in the order they run
+func (r *WorkflowRun) Jobs() ([]*WorkflowJobRun, error) {
+	return WorkflowJobRuns.Search("WHERE RunID = ? ORDER BY Position ASC", r.ID)
+}
+
+// Artifacts returns the files the run's jobs kept
+func (r *WorkflowRun) Artifacts() ([]*ActionArtifact, error) {
+	return GetArtifactsByRun(r.ID)
+}
+
+// Finished reports whether the run is done, however it ended
+func (r *WorkflowRun) Finished() bool {
+	return r.Status != WorkflowQueued && r.Status != WorkflowRunning
+}
+
+// URL returns the path of the run's page
+func (r *WorkflowRun) URL() string {
+	return fmt.Sprintf("/repos/%s/workflows/runs/%s", r.RepoID, r.ID)
+}
+
+// ShortSHA returns the abbreviated commit the run checked out
+func (r *WorkflowRun) ShortSHA() string {
+	if len(r.CommitSHA) > 7 {
+		return r.CommitSHA[:7]
+	}
+	return r.CommitSHA
+}
+
+// FormatDuration returns how long the run took, or has taken so far
+func (r *WorkflowRun) FormatDuration() string {
+	return formatWorkflowDuration(r.Status, r.StartedAt, r.Duration)
+}
+
+// Steps returns the job's steps in order
+func (j *WorkflowJobRun) Steps() ([]*WorkflowStepRun, error) {
+	return WorkflowStepRuns.Search("WHERE JobRunID = ? ORDER BY Position ASC", j.ID)
+}
+
+// Finished reports whether the job is done, however it ended
+func (j *WorkflowJobRun) Finished() bool {
+	return j.Status != WorkflowQueued && j.Status != WorkflowRunning
+}
+
+// FormatDuration returns how long the job took, or has taken so far
+func (j *WorkflowJobRun) FormatDuration() string {
+	return formatWorkflowDuration(j.Status, j.StartedAt, j.Duration)
+}
+
+// Finished reports whether the step is done, however it ended
+func (s *WorkflowStepRun) Finished() bool {
+	return s.Status != WorkflowQueued && s.Status != WorkflowRunning
+}
+
+// formatWorkflowDuration formats seconds like 1m 5s, counting from start
+// while still running
+func formatWorkflowDuration(status string, start time.Time, seconds int) string {
+	switch status {
+	case WorkflowQueued:
+		return ""
+	case WorkflowRunning:
+		seconds = int(time.Since(start).Seconds())
+	}
+	if seconds < 60 {
+		return fmt.Sprintf("%ds", seconds)
+	}
+	if seconds < 3600 {
+		return fmt.Sprintf("%dm %ds", seconds/60, seconds%60)
+	}
+	return fmt.Sprintf("%dh %dm", seconds/3600, seconds%3600/60)
+}
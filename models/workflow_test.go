@@ -0,0 +1,261 @@
+package models
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/The-Skyscape/devtools/pkg/testutils"
+)
+
+const testWorkflow = `
+name: CI
+on:
+  push:
+    branches: [main, release/*]
+  pr:
+  schedule:
+    - cron: "0 3 * * *"
+  manual:
+env:
+  GOFLAGS: -mod=mod
+jobs:
+  test:
+    image: golang:1.24
+    test_results: report.json
+    steps:
+      - run: |
+          go vet ./...
+          go test ./...
+  build:
+    needs: test
+    timeout_minutes: 10
+    artifacts: bin/app
+    images:
+      - name: app
+        tags: [latest]
+      - name: tools/cli
+        context: cmd/cli
+    steps:
+      - name: Build
+        run: go build -o bin/app .
+        env:
+          CGO_ENABLED: "0"
+  lint:
+    steps:
+      - run: echo lint
+      - lint: [govet, gosec]
+`
+
+func TestParseWorkflow(t *testing.T) {
+	t.Run("Definition", func(t *testing.T) {
+		wf, err := ParseWorkflow([]byte(testWorkflow))
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, "CI", wf.Name)
+		testutils.AssertEqual(t, "push,pr,schedule,manual", strings.Join(wf.On.Events(), ","))
+		testutils.AssertEqual(t, "0 3 * * *", strings.Join(wf.On.Schedule, ","))
+		testutils.AssertEqual(t, "-mod=mod", wf.Env["GOFLAGS"])
+
+		test := wf.Jobs["test"]
+		testutils.AssertEqual(t, "test", test.Name)
+		testutils.AssertEqual(t, "golang:1.24", test.Image)
+		testutils.AssertEqual(t, DefaultWorkflowTimeout, test.TimeoutMinutes)
+		testutils.AssertEqual(t, "go vet ./...", test.Steps[0].Name)
+		testutils.AssertEqual(t, "report.json", strings.Join(test.TestResults, ","))
+
+		build := wf.Jobs["build"]
+		testutils.AssertEqual(t, DefaultWorkflowImage, build.Image)
+		testutils.AssertEqual(t, 10, build.TimeoutMinutes)
+		testutils.AssertEqual(t, "test", strings.Join(build.Needs, ","))
+		testutils.AssertEqual(t, "bin/app", strings.Join(build.Artifacts, ","))
+		testutils.AssertEqual(t, "Build", build.Steps[0].Name)
+		testutils.AssertEqual(t, "0", build.Steps[0].Env["CGO_ENABLED"])
+		testutils.AssertEqual(t, "CI / build", wf.StatusContext(build))
+		testutils.AssertEqual(t, 2, len(build.Images))
+		testutils.AssertEqual(t, "Dockerfile", build.Images[0].Dockerfile)
+		testutils.AssertEqual(t, ".", build.Images[0].Context)
+		testutils.AssertEqual(t, "latest", strings.Join(build.Images[0].Tags, ","))
+		testutils.AssertEqual(t, "cmd/cli/Dockerfile", build.Images[1].Dockerfile)
+
+		lint := wf.Jobs["lint"].Steps[1]
+		testutils.AssertEqual(t, "Lint with govet, gosec", lint.Name)
+		testutils.AssertTrue(t, strings.Contains(lint.Run, "go vet ./..."))
+		testutils.AssertFalse(t, strings.Contains(lint.Run, "eslint"))
+	})
+
+	t.Run("TriggerForms", func(t *testing.T) {
+		jobs := "\njobs:\n  test:\n    steps:\n      - run: make test\n"
+
+		wf, err := ParseWorkflow([]byte("on: push" + jobs))
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, "push", strings.Join(wf.On.Events(), ","))
+
+		wf, err = ParseWorkflow([]byte("on: [pull_request, workflow_dispatch]" + jobs))
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, "pr,manual", strings.Join(wf.On.Events(), ","))
+
+		wf, err = ParseWorkflow([]byte("on:\n  schedule: \"@hourly\"\n  push:\n    branches: main" + jobs))
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, "@hourly", strings.Join(wf.On.Schedule, ","))
+		testutils.AssertEqual(t, "main", strings.Join(wf.On.Push.Branches, ","))
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		for _, definition := range []string{
+			"jobs:\n  test:\n    steps:\n      - run: make",
+			"on: deploy\njobs:\n  test:\n    steps:\n      - run: make",
+			"on:\n  schedule: every day\njobs:\n  test:\n    steps:\n      - run: make",
+			"on: push",
+			"on: push\njobs:\n  test:\n    steps: []",
+			"on: push\njobs:\n  test:\n    steps:\n      - name: empty",
+			"on: push\njobs:\n  bad id:\n    steps:\n      - run: make",
+			"on: push\njobs:\n  test:\n    image: --privileged\n    steps:\n      - run: make",
+			"on: push\njobs:\n  test:\n    timeout_minutes: 1000\n    steps:\n      - run: make",
+			"on: push\njobs:\n  test:\n    env:\n      BAD-NAME: x\n    steps:\n      - run: make",
+			"on: push\njobs:\n  test:\n    needs: build\n    steps:\n      - run: make",
+			"on: push\njobs:\n  test:\n    artifacts: ../secrets\n    steps:\n      - run: make",
+			"on: push\njobs:\n  test:\n    artifacts: /etc/passwd\n    steps:\n      - run: make",
+			"on: push\njobs:\n  test:\n    test_results: ../report.xml\n    steps:\n      - run: make",
+			"on: push\njobs:\n  test:\n    images:\n      - name: App\n    steps:\n      - run: make",
+			"on: push\njobs:\n  test:\n    images:\n      - name: app\n        context: ..\n    steps:\n      - run: make",
+			"on: push\njobs:\n  test:\n    images:\n      - name: app\n        tags: [a/b]\n    steps:\n      - run: make",
+			"on: push\njobs:\n  a:\n    needs: b\n    steps:\n      - run: make\n  b:\n    needs: a\n    steps:\n      - run: make",
+			"on: push\njobs:\n  test:\n    steps:\n      - lint: pylint",
+			"on: push\njobs:\n  test:\n    steps:\n      - run: make\n        lint: all",
+			"on: [push\n",
+		} {
+			_, err := ParseWorkflow([]byte(definition))
+			testutils.AssertError(t, err)
+		}
+	})
+
+	t.Run("JobOrder", func(t *testing.T) {
+		wf, err := ParseWorkflow([]byte(testWorkflow))
+		testutils.AssertNoError(t, err)
+		var ids []string
+		for _, job := range wf.OrderedJobs() {
+			ids = append(ids, job.ID)
+		}
+		testutils.AssertEqual(t, "lint,test,build", strings.Join(ids, ","))
+	})
+
+	t.Run("Triggered", func(t *testing.T) {
+		wf, err := ParseWorkflow([]byte(testWorkflow))
+		testutils.AssertNoError(t, err)
+		testutils.AssertTrue(t, wf.Triggered(WorkflowEventPush, "main"))
+		testutils.AssertTrue(t, wf.Triggered(WorkflowEventPush, "release/1.2"))
+		testutils.AssertFalse(t, wf.Triggered(WorkflowEventPush, "feature"))
+		testutils.AssertTrue(t, wf.Triggered(WorkflowEventPullRequest, "feature"))
+		testutils.AssertTrue(t, wf.Triggered(WorkflowEventSchedule, "main"))
+		testutils.AssertTrue(t, wf.Triggered(WorkflowEventManual, "feature"))
+
+		wf, err = ParseWorkflow([]byte("on: manual\njobs:\n  test:\n    steps:\n      - run: make"))
+		testutils.AssertNoError(t, err)
+		testutils.AssertFalse(t, wf.Triggered(WorkflowEventPush, "main"))
+		testutils.AssertFalse(t, wf.Triggered(WorkflowEventPullRequest, "main"))
+	})
+}
+
+func TestWorkflows(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+	t.Setenv("DATA_DIR", t.TempDir())
+
+	repo, err := Repositories.Insert(&Repository{Name: "workflows"})
+	testutils.AssertNoError(t, err)
+
+	work := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = work
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=Test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=Test", "GIT_COMMITTER_EMAIL=test@example.com")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %s", strings.Join(args, " "), out)
+		}
+	}
+	write := func(file, content string) {
+		path := filepath.Join(work, WorkflowsDir, file)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, []byte(content), 0644)
+	}
+
+	git("init", "--bare", "-b", "main", repo.Path())
+	git("init", "-b", "main")
+	write("ci.yml", testWorkflow)
+	write("broken.yaml", "on: push\njobs: {}\n")
+	write("README.md", "Not a workflow")
+	git("add", ".")
+	git("commit", "-m", "workflows")
+	git("push", repo.Path(), "main")
+
+	t.Run("GetWorkflows", func(t *testing.T) {
+		workflows, err := repo.GetWorkflows("main")
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 2, len(workflows))
+
+		testutils.AssertEqual(t, WorkflowsDir+"/broken.yaml", workflows[0].File)
+		testutils.AssertEqual(t, "broken", workflows[0].Name)
+		testutils.AssertEqual(t, "workflow has no jobs", workflows[0].Error)
+
+		testutils.AssertEqual(t, WorkflowsDir+"/ci.yml", workflows[1].File)
+		testutils.AssertEqual(t, "CI", workflows[1].Name)
+		testutils.AssertEqual(t, "", workflows[1].Error)
+		testutils.AssertEqual(t, testWorkflow, workflows[1].Source)
+
+		_, err = repo.GetWorkflow("main", WorkflowsDir+"/broken.yaml")
+		testutils.AssertError(t, err)
+		_, err = repo.GetWorkflow("main", WorkflowsDir+"/missing.yml")
+		testutils.AssertError(t, err)
+
+		workflows, err = repo.GetWorkflows("no-such-branch")
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 0, len(workflows))
+	})
+
+	t.Run("CreateWorkflowRun", func(t *testing.T) {
+		wf, err := repo.GetWorkflow("main", WorkflowsDir+"/ci.yml")
+		testutils.AssertNoError(t, err)
+
+		run, err := CreateWorkflowRun(wf, &WorkflowRun{RepoID: repo.ID, Event: WorkflowEventManual, Branch: "main"})
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 1, run.Number)
+		testutils.AssertEqual(t, "CI", run.Workflow)
+		testutils.AssertEqual(t, WorkflowQueued, run.Status)
+		testutils.AssertEqual(t, testWorkflow, run.Definition)
+		testutils.AssertFalse(t, run.Finished())
+
+		jobs, err := run.Jobs()
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 3, len(jobs))
+		testutils.AssertEqual(t, "build", jobs[2].JobID)
+		steps, err := jobs[2].Steps()
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 3, len(steps))
+		testutils.AssertEqual(t, "go build -o bin/app .", steps[0].Script)
+		testutils.AssertEqual(t, "Push image tools/cli", steps[2].Name)
+
+		again, err := CreateWorkflowRun(wf, &WorkflowRun{RepoID: repo.ID, Event: WorkflowEventPush, Branch: "main"})
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, 2, again.Number)
+
+		// Runs cut short by a restart end up failed
+		jobs[0].Status = WorkflowRunning
+		WorkflowJobRuns.Update(jobs[0])
+		testutils.AssertNoError(t, FailInterruptedWorkflowRuns())
+		run, err = WorkflowRuns.Get(run.ID)
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, WorkflowFailed, run.Status)
+		jobs, err = run.Jobs()
+		testutils.AssertNoError(t, err)
+		testutils.AssertEqual(t, WorkflowCancelled, jobs[0].Status)
+	})
+}
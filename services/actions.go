@@ -6,6 +6,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -28,6 +29,10 @@ type ActionJob struct {
 	Run      *models.ActionRun
 	RepoPath string
 	Done     chan error
+	After    []*ActionJob // Earlier runs of the action to wait for, under the queue overlap policy
+
+	finished  chan struct{} // Closed once the job is done
+	cancelled bool          // Set under the executor's lock when a newer run cancels this one
 }
 
 var (
@@ -68,15 +73,39 @@ func (e *ActionExecutor) worker(id int) {
 	defer e.workerWG.Done()
 	
 	for job := range e.jobQueue {
-		log.Printf("Worker %d: Starting execution of action %s", id, job.Action.Title)
-		err := e.executeJob(job)
+		// Earlier jobs were queued first, so they are already running
+		for _, earlier := range job.After {
+			<-earlier.finished
+		}
+
+		var err error
+		if e.isCancelled(job) {
+			err = e.cancelQueued(job)
+		} else {
+			log.Printf("Worker %d: Starting execution of action %s", id, job.Action.Title)
+			err = e.executeJob(job)
+		}
 		job.Done <- err
 		close(job.Done)
+		close(job.finished)
 	}
 }
 
 // ExecuteAction queues an action for execution and returns immediately
 func (e *ActionExecutor) ExecuteAction(action *models.Action, triggerEvent string) error {
+	return e.enqueue(action, triggerEvent, nil)
+}
+
+// enqueue queues a run of an action to start once the jobs it's after
+// have finished
+func (e *ActionExecutor) enqueue(action *models.Action, triggerEvent string, after []*ActionJob) error {
+	// Runs count against the repository owner's CI minutes
+	if repo, err := models.Repositories.Get(action.RepoID); err == nil {
+		if err := models.CheckQuota(repo.UserID, models.UsageCIMinutes); err != nil {
+			return err
+		}
+	}
+	
 	// Create action run record
 	run := &models.ActionRun{
 		Model:       models.DB.NewModel(""),
@@ -85,6 +114,15 @@ func (e *ActionExecutor) ExecuteAction(action *models.Action, triggerEvent strin
 		TriggerType: triggerEvent,
 		Branch:      action.Branch,
 	}
+
+	// Runs report their status on the commit they check
+	if repo, err := models.Repositories.Get(action.RepoID); err == nil {
+		branch := action.Branch
+		if branch == "" {
+			branch = repo.GetDefaultBranch()
+		}
+		run.CommitSHA, _ = repo.ResolveCommit("refs/heads/" + branch)
+	}
 	
 	// Save the run
 	run, err := models.ActionRuns.Insert(run)
@@ -104,6 +142,8 @@ func (e *ActionExecutor) ExecuteAction(action *models.Action, triggerEvent strin
 		Run:      run,
 		RepoPath: repo.Path(),
 		Done:     make(chan error, 1),
+		After:    after,
+		finished: make(chan struct{}),
 	}
 	
 	// Track the job
@@ -119,6 +159,7 @@ func (e *ActionExecutor) ExecuteAction(action *models.Action, triggerEvent strin
 		// Update run status to queued
 		run.Status = "queued"
 		models.ActionRuns.Update(run)
+		reportRunStatus(action, run)
 		
 		// Don't wait for completion - return immediately
 		go func() {
@@ -141,10 +182,16 @@ func (e *ActionExecutor) ExecuteAction(action *models.Action, triggerEvent strin
 		
 	default:
 		// Queue is full
+		e.mu.Lock()
+		delete(e.runningJobs, run.ID)
+		e.mu.Unlock()
+		close(job.finished)
+
 		run.Status = "failed"
 		run.Output = "Action queue is full. Too many actions running."
 		run.Duration = 0
 		models.ActionRuns.Update(run)
+		reportRunStatus(action, run)
 		
 		return fmt.Errorf("action queue is full")
 	}
@@ -161,6 +208,7 @@ func (e *ActionExecutor) executeJob(job *ActionJob) error {
 	if err := models.ActionRuns.Update(run); err != nil {
 		return fmt.Errorf("failed to update run status: %v", err)
 	}
+	reportRunStatus(action, run)
 	
 	// Update action status
 	action.Status = "running"
@@ -182,7 +230,14 @@ func (e *ActionExecutor) executeJob(job *ActionJob) error {
 	run.Output = output
 	run.Duration = int(time.Since(startTime).Seconds())
 	
-	if execErr != nil {
+	cancelled := e.isCancelled(job)
+	if cancelled {
+		// The newer run that cancelled this one keeps the action's status
+		run.Status = models.ActionRunCancelled
+		run.Output += "\n\nCancelled by a newer scheduled run"
+		run.ExitCode = -1
+		execErr = fmt.Errorf("cancelled")
+	} else if execErr != nil {
 		run.Status = "failed"
 		run.Output += fmt.Sprintf("\n\nError: %v", execErr)
 		run.ExitCode = 1
@@ -197,17 +252,45 @@ func (e *ActionExecutor) executeJob(job *ActionJob) error {
 	if err := models.ActionRuns.Update(run); err != nil {
 		log.Printf("Failed to update action run: %v", err)
 	}
-	
+	reportRunStatus(action, run)
+	
+	// Keep the results of any tests the action ran
+	if !cancelled {
+		recordTests(&models.TestRun{
+			RepoID:    action.RepoID,
+			Source:    models.TestSourceAction,
+			Name:      action.Title,
+			URL:       fmt.Sprintf("/repos/%s/actions/%s/history", action.RepoID, action.ID),
+			Branch:    run.Branch,
+			CommitSHA: run.CommitSHA,
+		}, []byte(output))
+	}
+
 	// Update action
-	if err := models.Actions.Update(action); err != nil {
-		log.Printf("Failed to update action: %v", err)
+	if !cancelled {
+		if err := models.Actions.Update(action); err != nil {
+			log.Printf("Failed to update action: %v", err)
+		}
+	}
+	
+	if err := models.MeterActionRun(action, run); err != nil {
+		log.Printf("Failed to meter action run: %v", err)
 	}
 	
 	// Log activity
 	status := "completed"
-	if execErr != nil {
+	if cancelled {
+		status = "cancelled"
+	} else if execErr != nil {
 		status = "failed"
 	}
+	if status == "failed" {
+		NotifyChat(action.RepoID, models.ChatEventActionFailed, "", ChatNotice{
+			Title:  action.Title,
+			Branch: run.Branch,
+			URL:    fmt.Sprintf("/repos/%s/actions/%s/history", action.RepoID, action.ID),
+		})
+	}
 	models.LogActivity("action_executed", fmt.Sprintf("Action %s %s", action.Title, status),
 		fmt.Sprintf("Action %s %s after %.1f seconds", action.Title, status, float64(run.Duration)),
 		"system", action.RepoID, "action", action.ID)
@@ -215,6 +298,31 @@ func (e *ActionExecutor) executeJob(job *ActionJob) error {
 	return execErr
 }
 
+// isCancelled reports whether a newer run cancelled the job
+func (e *ActionExecutor) isCancelled(job *ActionJob) bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return job.cancelled
+}
+
+// cancelQueued records a job cancelled before it started
+func (e *ActionExecutor) cancelQueued(job *ActionJob) error {
+	job.Run.Status = models.ActionRunCancelled
+	job.Run.Output = "Cancelled by a newer scheduled run before it started"
+	if err := models.ActionRuns.Update(job.Run); err != nil {
+		log.Printf("Failed to update action run: %v", err)
+	}
+	reportRunStatus(job.Action, job.Run)
+	return fmt.Errorf("cancelled")
+}
+
+// reportRunStatus records where a run is as a status on its commit
+func reportRunStatus(action *models.Action, run *models.ActionRun) {
+	if err := models.ReportRunStatus(action, run); err != nil {
+		log.Printf("ActionExecutor: Failed to report status of %s: %v", action.Title, err)
+	}
+}
+
 // executeScript executes an action script in a Docker container
 func (e *ActionExecutor) executeScript(action *models.Action, run *models.ActionRun, repoPath string) (string, error) {
 	// Create sandbox container
@@ -227,6 +335,11 @@ func (e *ActionExecutor) executeScript(action *models.Action, run *models.Action
 	}
 	defer os.Remove(scriptPath)
 	
+	secrets, err := models.ActionSecretEnv(action.RepoID)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secrets: %v", err)
+	}
+
 	// Create Docker run command
 	dockerCmd := fmt.Sprintf(`docker run --rm --name %s \
 		-v %s:/workspace:ro \
@@ -234,10 +347,10 @@ func (e *ActionExecutor) executeScript(action *models.Action, run *models.Action
 		-w /workspace \
 		--network none \
 		--memory="512m" \
-		--cpus="1" \
+		--cpus="1" %s \
 		alpine:latest \
 		sh -c "apk add --no-cache bash git && bash /script.sh"`,
-		sandboxName, repoPath, scriptPath)
+		sandboxName, repoPath, scriptPath, secretEnvFlags(secrets))
 	
 	// Execute with timeout
 	timeout := 5 * time.Minute
@@ -247,6 +360,7 @@ func (e *ActionExecutor) executeScript(action *models.Action, run *models.Action
 	
 	cmd := exec.Command("bash", "-c", dockerCmd)
 	cmd.Dir = repoPath
+	cmd.Env = secretEnv(secrets)
 	
 	// Set timeout
 	timer := time.AfterFunc(timeout, func() {
@@ -258,8 +372,9 @@ func (e *ActionExecutor) executeScript(action *models.Action, run *models.Action
 	})
 	defer timer.Stop()
 	
-	// Execute and capture output
+	// Execute and capture output, hiding any secret the action printed
 	output, err := cmd.CombinedOutput()
+	output = []byte(models.NewSecretMasker(secrets).Mask(string(output)))
 	
 	// Clean up container if still running
 	exec.Command("docker", "rm", "-f", sandboxName).Run()
@@ -275,16 +390,21 @@ func (e *ActionExecutor) executeCommand(action *models.Action, run *models.Actio
 	// Wrap command for proper execution
 	wrappedCmd := fmt.Sprintf("bash -c %q", action.Command)
 	
+	secrets, err := models.ActionSecretEnv(action.RepoID)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secrets: %v", err)
+	}
+
 	// Create Docker run command
 	dockerCmd := fmt.Sprintf(`docker run --rm --name %s \
 		-v %s:/workspace:ro \
 		-w /workspace \
 		--network none \
 		--memory="512m" \
-		--cpus="1" \
+		--cpus="1" %s \
 		alpine:latest \
 		sh -c "apk add --no-cache bash git && %s"`,
-		sandboxName, repoPath, wrappedCmd)
+		sandboxName, repoPath, secretEnvFlags(secrets), wrappedCmd)
 	
 	// Execute with timeout
 	timeout := 5 * time.Minute
@@ -294,6 +414,7 @@ func (e *ActionExecutor) executeCommand(action *models.Action, run *models.Actio
 	
 	cmd := exec.Command("bash", "-c", dockerCmd)
 	cmd.Dir = repoPath
+	cmd.Env = secretEnv(secrets)
 	
 	// Set timeout
 	timer := time.AfterFunc(timeout, func() {
@@ -305,8 +426,9 @@ func (e *ActionExecutor) executeCommand(action *models.Action, run *models.Actio
 	})
 	defer timer.Stop()
 	
-	// Execute and capture output
+	// Execute and capture output, hiding any secret the action printed
 	output, err := cmd.CombinedOutput()
+	output = []byte(models.NewSecretMasker(secrets).Mask(string(output)))
 	
 	// Clean up container if still running
 	exec.Command("docker", "rm", "-f", sandboxName).Run()
@@ -356,12 +478,11 @@ func (e *ActionExecutor) collectArtifacts(action *models.Action, run *models.Act
 		ActionID:    action.ID,
 		FileName:    filepath.Base(artifactPath),
 		FilePath:    strings.TrimSpace(paths[0]),
-		Content:     content,
 		Size:        info.Size(),
 		ContentType: "application/octet-stream",
 	}
 	
-	if _, err := models.ActionArtifacts.Insert(artifact); err != nil {
+	if err := models.StoreArtifact(artifact, content); err != nil {
 		log.Printf("Failed to save artifact: %v", err)
 	} else {
 		log.Printf("Collected artifact: %s (%d bytes)", artifact.FileName, artifact.Size)
@@ -417,6 +538,96 @@ func (e *ActionExecutor) TriggerActionsByEvent(eventType, repoID string, eventDa
 	return nil
 }
 
+// RunSchedules queues the scheduled actions due at now, applying each
+// action's overlap policy when an earlier run of it hasn't finished
+func (e *ActionExecutor) RunSchedules(now time.Time) {
+	actions, err := models.ScheduledActions()
+	if err != nil {
+		log.Printf("ActionExecutor: Failed to get scheduled actions: %v", err)
+		return
+	}
+
+	for _, action := range actions {
+		if !action.Due(now) {
+			continue
+		}
+
+		// Move the schedule on first, so a failure doesn't retry every minute
+		if err := action.Advance(now); err != nil {
+			log.Printf("ActionExecutor: Failed to advance schedule of %s: %v", action.Title, err)
+			continue
+		}
+		if err := e.runScheduled(action); err != nil {
+			log.Printf("ActionExecutor: Failed to run scheduled action %s: %v", action.Title, err)
+		}
+	}
+}
+
+// runScheduled queues a scheduled run of an action, skipping it, queueing
+// it behind or cancelling the action's unfinished runs
+func (e *ActionExecutor) runScheduled(action *models.Action) error {
+	if action.Script == "" && action.Command == "" {
+		return fmt.Errorf("no script or command defined")
+	}
+
+	earlier := e.actionJobs(action.ID)
+	if len(earlier) == 0 {
+		return e.enqueue(action, "scheduled", nil)
+	}
+
+	switch action.Overlap() {
+	case models.ActionOverlapQueue:
+		return e.enqueue(action, "scheduled", earlier)
+
+	case models.ActionOverlapCancel:
+		for _, job := range earlier {
+			e.cancel(job)
+		}
+		// Still wait for them, so the runs never overlap
+		return e.enqueue(action, "scheduled", earlier)
+
+	default:
+		run := &models.ActionRun{
+			Model:       models.DB.NewModel(""),
+			ActionID:    action.ID,
+			Status:      models.ActionRunSkipped,
+			TriggerType: "scheduled",
+			Branch:      action.Branch,
+			Output:      fmt.Sprintf("Skipped: run %s hadn't finished", earlier[len(earlier)-1].Run.ID),
+		}
+		if _, err := models.ActionRuns.Insert(run); err != nil {
+			return fmt.Errorf("failed to record skipped run: %v", err)
+		}
+		return nil
+	}
+}
+
+// actionJobs returns the queued and running jobs of an action, oldest first
+func (e *ActionExecutor) actionJobs(actionID string) []*ActionJob {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	var jobs []*ActionJob
+	for _, job := range e.runningJobs {
+		if job.Action.ID == actionID {
+			jobs = append(jobs, job)
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].Run.CreatedAt.Before(jobs[j].Run.CreatedAt)
+	})
+	return jobs
+}
+
+// cancel stops a job, killing its container if it has started
+func (e *ActionExecutor) cancel(job *ActionJob) {
+	e.mu.Lock()
+	job.cancelled = true
+	e.mu.Unlock()
+
+	exec.Command("docker", "kill", fmt.Sprintf("action-%s-%s", job.Action.ID, job.Run.ID)).Run()
+}
+
 // Shutdown gracefully shuts down the action executor
 func (e *ActionExecutor) Shutdown() {
 	log.Println("ActionExecutor: Shutting down...")
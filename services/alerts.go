@@ -0,0 +1,151 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"workspace/models"
+
+	"github.com/pkg/errors"
+)
+
+// AlertWebhookEvent is the event header of alerts posted to webhooks
+const AlertWebhookEvent = "alert"
+
+// AlertNotice is what an alert is sent as, and the JSON body posted to
+// webhooks
+type AlertNotice struct {
+	Status     string    `json:"status"` // models.AlertFiring or models.AlertResolved
+	Rule       string    `json:"rule"`
+	Condition  string    `json:"condition"`
+	Summary    string    `json:"summary"`
+	Value      float64   `json:"value"`
+	FiredAt    time.Time `json:"fired_at"`
+	ResolvedAt time.Time `json:"resolved_at"`
+	URL        string    `json:"url"` // The alerts page, absolute once the workspace address is set
+}
+
+// Text writes the notice as a single chat message
+func (n AlertNotice) Text() string {
+	status := "Firing"
+	if n.Status == models.AlertResolved {
+		status = "Resolved"
+	}
+	return fmt.Sprintf("[%s] %s: %s %s", status, n.Rule, n.Summary, n.URL)
+}
+
+// SendAlert sends an alert that fired or resolved to each of its rule's
+// active channels, recording on the alert where it went and on each
+// channel whether it got there
+func SendAlert(rule *models.AlertRule, alert *models.Alert) error {
+	notice := newAlertNotice(alert)
+
+	var sent []string
+	var failed error
+	for _, channel := range rule.RoutedChannels() {
+		err := sendAlert(channel, notice)
+		if err != nil {
+			log.Printf("Alerts: Failed to send %q to %s: %v", rule.Name, channel.Name, err)
+			if failed == nil {
+				failed = errors.Wrap(err, channel.Name)
+			}
+		} else {
+			sent = append(sent, channel.Name)
+		}
+		if err := channel.RecordDelivery(err); err != nil {
+			log.Printf("Alerts: %v", err)
+		}
+	}
+	return alert.RecordNotification(sent, failed)
+}
+
+// TestAlertChannel sends a test alert with a channel and records whether
+// it got there
+func TestAlertChannel(channel *models.AlertChannel) error {
+	notice := newAlertNotice(&models.Alert{
+		Model:    models.DB.NewModel(""),
+		RuleName: "Test alert",
+		Status:   models.AlertResolved,
+		Summary:  "Alerts sent to " + channel.Name + " are working",
+	})
+	notice.FiredAt, notice.ResolvedAt = time.Now(), time.Now()
+
+	err := sendAlert(channel, notice)
+	if recordErr := channel.RecordDelivery(err); recordErr != nil {
+		log.Printf("Alerts: %v", recordErr)
+	}
+	return err
+}
+
+// newAlertNotice describes an alert for its channels
+func newAlertNotice(alert *models.Alert) AlertNotice {
+	notice := AlertNotice{
+		Status:     alert.Status,
+		Rule:       alert.RuleName,
+		Condition:  alert.Condition,
+		Summary:    alert.Summary,
+		Value:      alert.Value,
+		FiredAt:    alert.CreatedAt,
+		ResolvedAt: alert.ResolvedAt,
+		URL:        "/alerts",
+	}
+	if settings, err := models.GetSettings(); err == nil {
+		notice.URL = settings.MailAddress() + notice.URL
+	}
+	return notice
+}
+
+// sendAlert sends a notice the way the channel expects
+func sendAlert(channel *models.AlertChannel, notice AlertNotice) error {
+	switch channel.Kind {
+	case models.AlertEmail:
+		if !MailConfigured() {
+			return errors.New("outgoing mail isn't set up")
+		}
+		return SendTemplate(channel.Addresses(), "alert", MailMessage{Data: notice})
+	case models.AlertSlack:
+		return postAlert(channel, map[string]string{"text": notice.Text()})
+	case models.AlertWebhook:
+		return postAlert(channel, notice)
+	default:
+		return errors.Errorf("unknown alert channel %q", channel.Kind)
+	}
+}
+
+// postAlert posts a JSON body to a Slack or webhook channel, signing it
+// when the channel has a secret
+func postAlert(channel *models.AlertChannel, body any) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, channel.Target, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Skyscape-Alerts")
+	if channel.Kind == models.AlertWebhook {
+		req.Header.Set(WebhookEventHeader, AlertWebhookEvent)
+		if channel.Secret != "" {
+			req.Header.Set(WebhookSignatureHeader, SignWebhook(payload, channel.Secret))
+		}
+	}
+
+	resp, err := notifyClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errors.Errorf("%s returned %s", channel.Kind, resp.Status)
+	}
+	return nil
+}
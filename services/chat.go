@@ -0,0 +1,175 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+
+	"workspace/models"
+
+	"github.com/pkg/errors"
+)
+
+// discordMessageLimit is the longest message Discord accepts
+const discordMessageLimit = 2000
+
+// ChatNotice is what a chat message template is rendered with
+type ChatNotice struct {
+	Event   string // One of models.ChatEvents
+	Repo    string // Name of the repository
+	Actor   string // Name of who caused the event
+	Number  string // ID of the issue or pull request
+	Title   string // Title of the issue, pull request or action
+	Branch  string // Branch pushed, merged into or run on
+	Commits int    // Commits pushed
+	Detail  string // Newest commit pushed, or why a pull request was approved
+	URL     string // Page of the event, absolute once the workspace address is set
+}
+
+// NotifyChat posts an event to each of the repository's active chat
+// integrations that post it. Messages are sent in the background and
+// failures are only recorded in each integration's history.
+func NotifyChat(repoID, event, userID string, notice ChatNotice) {
+	chats, err := models.ChatIntegrationsFor(repoID, event)
+	if err != nil {
+		log.Printf("Chat: Failed to find integrations for %s: %v", repoID, err)
+		return
+	}
+	if len(chats) == 0 {
+		return
+	}
+
+	notice = completeChatNotice(repoID, event, userID, notice)
+	go func() {
+		for _, chat := range chats {
+			if _, err := deliverChat(chat, event, notice); err != nil {
+				log.Printf("Chat: Failed to record delivery to %s: %v", chat.Provider, err)
+			}
+		}
+	}()
+}
+
+// TestChat posts a test message with an integration, whatever events it
+// posts, and returns the delivery
+func TestChat(chat *models.ChatIntegration, userID string) (*models.ChatDelivery, error) {
+	notice := completeChatNotice(chat.RepoID, models.ChatEventTest, userID, ChatNotice{
+		URL: "/repos/" + chat.RepoID,
+	})
+	return deliverChat(chat, models.ChatEventTest, notice)
+}
+
+// completeChatNotice fills in the event, repository and actor, and makes
+// the notice's link absolute when the workspace address is set
+func completeChatNotice(repoID, event, userID string, notice ChatNotice) ChatNotice {
+	notice.Event = event
+	notice.Repo = repoID
+	if repo, err := models.Repositories.Get(repoID); err == nil {
+		notice.Repo = repo.Name
+	}
+	notice.Actor = "Someone"
+	if userID != "" {
+		if user, err := models.Auth.Users.Get(userID); err == nil {
+			notice.Actor = user.Name
+		}
+	}
+	if settings, err := models.GetSettings(); err == nil && strings.HasPrefix(notice.URL, "/") {
+		notice.URL = settings.MailAddress() + notice.URL
+	}
+	return notice
+}
+
+// deliverChat posts an event's message with an integration and records
+// the outcome. Only a failure to record it is returned; the chat service's
+// errors are in the delivery.
+func deliverChat(chat *models.ChatIntegration, event string, notice ChatNotice) (*models.ChatDelivery, error) {
+	delivery := &models.ChatDelivery{
+		Model: models.DB.NewModel(""),
+		Event: event,
+	}
+
+	start := time.Now()
+	message, err := renderChatMessage(chat.MessageTemplate(event), notice)
+	if err == nil {
+		delivery.Message = message
+		delivery.StatusCode, delivery.Response, err = postChat(chat, delivery.ID, message)
+	}
+	delivery.Duration = time.Since(start).Milliseconds()
+	if err != nil {
+		delivery.Error = err.Error()
+	}
+	return models.RecordChatDelivery(chat, delivery)
+}
+
+// renderChatMessage renders a message template for a notice
+func renderChatMessage(tmpl string, notice ChatNotice) (string, error) {
+	t, err := template.New("chat").Parse(tmpl)
+	if err != nil {
+		return "", errors.Wrap(err, "invalid message template")
+	}
+	var message bytes.Buffer
+	if err := t.Execute(&message, notice); err != nil {
+		return "", errors.Wrap(err, "failed to render message")
+	}
+	return strings.TrimSpace(message.String()), nil
+}
+
+// postChat sends a message the way the integration's chat service expects,
+// returning the response status and the start of its body
+func postChat(chat *models.ChatIntegration, deliveryID, message string) (int, string, error) {
+	method, target := http.MethodPost, chat.URL
+	var body any
+	switch chat.Provider {
+	case models.ChatSlack:
+		body = map[string]string{"text": message}
+	case models.ChatDiscord:
+		if runes := []rune(message); len(runes) > discordMessageLimit {
+			message = string(runes[:discordMessageLimit-1]) + "…"
+		}
+		body = map[string]string{"content": message}
+	case models.ChatMatrix:
+		// The delivery ID makes retries of the same message idempotent
+		method = http.MethodPut
+		target = fmt.Sprintf("%s/_matrix/client/v3/rooms/%s/send/m.room.message/%s",
+			chat.URL, url.PathEscape(chat.Room), url.PathEscape(deliveryID))
+		body = map[string]string{"msgtype": "m.text", "body": message}
+	default:
+		return 0, "", errors.Errorf("unknown chat service %q", chat.Provider)
+	}
+
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return 0, "", err
+	}
+	req, err := http.NewRequest(method, target, bytes.NewReader(payload))
+	if err != nil {
+		return 0, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Skyscape-Chat")
+	if chat.Provider == models.ChatMatrix {
+		token, err := chat.Token()
+		if err != nil {
+			return 0, "", err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := notifyClient.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+	response, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, string(response), errors.Errorf("%s returned %s", chat.Provider, resp.Status)
+	}
+	return resp.StatusCode, string(response), nil
+}
@@ -0,0 +1,354 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"workspace/models"
+
+	"github.com/pkg/errors"
+)
+
+// deployTimeout bounds how long pulling, copying and starting an image may take
+const deployTimeout = 15 * time.Minute
+
+// healthInterval is how often a new deployment's health URL is probed
+// until it answers as expected
+const healthInterval = 2 * time.Second
+
+// healthClient probes deployments' health URLs
+var healthClient = &http.Client{Timeout: 10 * time.Second}
+
+// Deployer runs repositories' images from the registry in their
+// environments, replacing the container an environment ran before
+type Deployer struct {
+	mu   sync.Mutex
+	logs map[string]*workflowLog // Output of running deployments, by deployment ID
+}
+
+// Deployments is the global deployer
+var Deployments = &Deployer{logs: map[string]*workflowLog{}}
+
+// Deploy starts deploying a tag of one of the repository's images to an
+// environment, returning the deployment as soon as it is recorded
+func (d *Deployer) Deploy(env *models.Environment, image, version, userID string) (*models.Deployment, error) {
+	return d.start(env, image, version, userID, "")
+}
+
+// Rollback starts redeploying what ran in an environment before its
+// current deployment
+func (d *Deployer) Rollback(env *models.Environment, userID string) (*models.Deployment, error) {
+	previous, err := env.Previous()
+	if err != nil {
+		return nil, err
+	}
+	return d.start(env, previous.Image, previous.Version, userID, env.DeploymentID)
+}
+
+// start records a deployment and runs it in the background
+func (d *Deployer) start(env *models.Environment, image, version, userID, rollbackOf string) (*models.Deployment, error) {
+	deployment, err := models.StartDeployment(env, image, version, userID, rollbackOf)
+	if err != nil {
+		return nil, err
+	}
+	secrets, err := models.ActionSecretEnv(env.RepoID)
+	if err != nil {
+		deployment.Finish(env, models.DeploymentFailed, err.Error())
+		return nil, errors.Wrap(err, "failed to read secrets")
+	}
+
+	output := &workflowLog{masker: models.NewSecretMasker(secrets)}
+	d.mu.Lock()
+	d.logs[deployment.ID] = output
+	d.mu.Unlock()
+
+	go d.run(env, deployment, secrets, output)
+	return deployment, nil
+}
+
+// Log returns the complete lines a running deployment has output so far,
+// and whether it is still running
+func (d *Deployer) Log(deploymentID string) (string, bool) {
+	d.mu.Lock()
+	output, ok := d.logs[deploymentID]
+	d.mu.Unlock()
+	if !ok {
+		return "", false
+	}
+	return output.Lines(), true
+}
+
+// run deploys the image, checks its health and records how it went. A
+// deployment failing its health check is rolled back to what the
+// environment ran before when the environment asks for it.
+func (d *Deployer) run(env *models.Environment, deployment *models.Deployment, secrets map[string]string, output *workflowLog) {
+	defer func() {
+		d.mu.Lock()
+		delete(d.logs, deployment.ID)
+		d.mu.Unlock()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), deployTimeout)
+	defer cancel()
+
+	status := models.DeploymentSucceeded
+	if err := d.deploy(ctx, env, deployment, secrets, output); err != nil {
+		status = models.DeploymentFailed
+		fmt.Fprintf(output, "\n%v\n", err)
+	} else if env.HealthURL != "" {
+		fmt.Fprintf(output, "Checking %s for %d\n", env.HealthURL, env.ExpectedStatus())
+		deployment.Health, deployment.HealthDetail = checkHealth(ctx, env)
+		fmt.Fprintf(output, "%s: %s\n", deployment.Health, deployment.HealthDetail)
+		if deployment.Health != models.DeploymentHealthy {
+			status = models.DeploymentFailed
+		}
+	}
+	if status == models.DeploymentSucceeded {
+		fmt.Fprintf(output, "\n%s is running %s\n", env.Name, deployment.Reference())
+	}
+	if err := deployment.Finish(env, status, output.String()); err != nil {
+		log.Printf("Deployments: Failed to finish deployment %s: %v", deployment.ID, err)
+	}
+
+	action, title := "deployment_"+status, fmt.Sprintf("Deployed %s to %s", deployment.Reference(), env.Name)
+	if deployment.RollbackOf != "" {
+		title = fmt.Sprintf("Rolled %s back to %s", env.Name, deployment.Reference())
+	}
+	if status == models.DeploymentFailed {
+		title = "Failed: " + title
+	}
+	models.LogActivity(action, title, fmt.Sprintf("Took %s", deployment.FormatDuration()),
+		deployment.DeployedBy, env.RepoID, "deployment", deployment.ID)
+	emitDeploymentWebhook(env, deployment)
+
+	if status == models.DeploymentFailed {
+		d.failed(env, deployment)
+	}
+}
+
+// failed tells whoever started a failed deployment about it, first rolling
+// the environment back when the deployment's health check failed and the
+// environment asks for that. The container was replaced by then, so the
+// rollback redeploys the version the environment still records as current.
+func (d *Deployer) failed(env *models.Environment, deployment *models.Deployment) {
+	title := fmt.Sprintf("Deploying %s to %s failed", deployment.Reference(), env.Name)
+	body := "See the deployment's output for what went wrong."
+	if deployment.Health == models.DeploymentUnhealthy {
+		title = fmt.Sprintf("%s failed its health check on %s", deployment.Reference(), env.Name)
+		body = deployment.HealthDetail
+		// An automatic rollback failing too is left alone, as rolling it
+		// back would redeploy the same version again
+		if current := env.Current(); env.AutoRollback && current != nil && !deployment.Automatic() {
+			if _, err := d.start(env, current.Image, current.Version, "", deployment.ID); err != nil {
+				body += fmt.Sprintf(". Rolling back to %s failed: %v", current.Reference(), err)
+			} else {
+				body += fmt.Sprintf(". Rolling back to %s.", current.Reference())
+			}
+		}
+	}
+
+	// Automatic rollbacks were started by nobody, so whoever started the
+	// deployment they replace hears about them
+	recipient := deployment.DeployedBy
+	if deployment.Automatic() {
+		title = "Automatic rollback failed: " + title
+		if original, err := models.Deployments.Get(deployment.RollbackOf); err == nil {
+			recipient = original.DeployedBy
+		}
+	}
+	if recipient == "" {
+		return
+	}
+	if err := models.Notify(recipient, "deployment_failed", title, body,
+		fmt.Sprintf("/repos/%s/environments", env.RepoID), env.RepoID); err != nil {
+		log.Printf("Deployments: Failed to notify %s: %v", recipient, err)
+	}
+}
+
+// checkHealth probes the environment's health URL until it answers with the
+// expected status or the environment's timeout passes, returning the result
+// and what the probe last saw
+func checkHealth(ctx context.Context, env *models.Environment) (string, string) {
+	deadline := time.Now().Add(env.HealthWait())
+	var last string
+	for {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, env.HealthURL, nil)
+		if err != nil {
+			return models.DeploymentUnhealthy, err.Error()
+		}
+		if resp, err := healthClient.Do(req); err != nil {
+			last = err.Error()
+		} else {
+			resp.Body.Close()
+			if resp.StatusCode == env.ExpectedStatus() {
+				return models.DeploymentHealthy, fmt.Sprintf("Answered %d", resp.StatusCode)
+			}
+			last = fmt.Sprintf("answered %d", resp.StatusCode)
+		}
+
+		if time.Now().Add(healthInterval).After(deadline) {
+			return models.DeploymentUnhealthy, fmt.Sprintf("Not healthy after %s, last %s", env.HealthWait(), last)
+		}
+		select {
+		case <-ctx.Done():
+			return models.DeploymentUnhealthy, fmt.Sprintf("Stopped checking, last %s", last)
+		case <-time.After(healthInterval):
+		}
+	}
+}
+
+// emitDeploymentWebhook sends a deployment event for a finished deployment
+func emitDeploymentWebhook(env *models.Environment, deployment *models.Deployment) {
+	EmitWebhook(env.RepoID, models.WebhookEventDeployment, deployment.Status, deployment.DeployedBy, map[string]any{
+		"id":            deployment.ID,
+		"environment":   env.Name,
+		"image":         deployment.Image,
+		"version":       deployment.Version,
+		"status":        deployment.Status,
+		"health":        deployment.Health,
+		"health_detail": deployment.HealthDetail,
+		"rollback_of":   deployment.RollbackOf,
+		"automatic":     deployment.Automatic(),
+		"duration":      deployment.Duration,
+		"created_at":    deployment.CreatedAt,
+	})
+}
+
+// deploy pulls the image from the registry, copies it to the environment's
+// Docker host when that is another server, and replaces the environment's
+// container with one running it
+func (d *Deployer) deploy(ctx context.Context, env *models.Environment, deployment *models.Deployment, secrets map[string]string, output io.Writer) error {
+	if err := Registry.Start(); err != nil {
+		return err
+	}
+	ref := Registry.ImageRef(env.RepoID, deployment.Image, deployment.Version)
+	fmt.Fprintf(output, "Pulling %s\n", ref)
+	if err := dockerRun(ctx, nil, output, "pull", ref); err != nil {
+		return errors.Wrap(err, "failed to pull the image from the registry")
+	}
+
+	hostEnv, cleanup, err := dockerHostEnv(env)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	if env.DockerHost != "" {
+		fmt.Fprintf(output, "Copying the image to %s\n", env.DockerHost)
+		if err := copyImage(ctx, ref, hostEnv, output); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintf(output, "Replacing %s\n", env.ContainerName())
+	dockerRun(ctx, hostEnv, io.Discard, "rm", "-f", env.ContainerName())
+
+	args := []string{"run", "-d",
+		"--name", env.ContainerName(),
+		"--restart", "unless-stopped",
+		"--label", "skyscape.repo=" + env.RepoID,
+		"--label", "skyscape.environment=" + env.Name,
+		"--label", "skyscape.deployment=" + deployment.ID,
+	}
+	for _, port := range env.PortList() {
+		args = append(args, "-p", port)
+	}
+	// Secrets are passed through from the client's environment, so their
+	// values never appear in the command line
+	runEnv := hostEnv
+	for _, name := range sortedKeys(secrets) {
+		args = append(args, "-e", name)
+		runEnv = append(runEnv, name+"="+secrets[name])
+	}
+	args = append(args, ref)
+
+	if err := dockerRun(ctx, runEnv, output, args...); err != nil {
+		return errors.Wrap(err, "failed to start the container")
+	}
+	return nil
+}
+
+// Stop removes the container an environment runs, as when the environment
+// is deleted
+func (d *Deployer) Stop(env *models.Environment) error {
+	hostEnv, cleanup, err := dockerHostEnv(env)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	return dockerRun(ctx, hostEnv, io.Discard, "rm", "-f", env.ContainerName())
+}
+
+// dockerHostEnv returns the variables pointing the docker client at an
+// environment's host, writing its TLS credentials to a temporary directory
+// the returned function removes. This server's Docker needs none.
+func dockerHostEnv(env *models.Environment) ([]string, func(), error) {
+	if env.DockerHost == "" {
+		return nil, func() {}, nil
+	}
+	vars := []string{"DOCKER_HOST=" + env.DockerHost}
+	if !env.HasTLS {
+		return vars, func() {}, nil
+	}
+
+	ca, cert, key, err := env.TLS()
+	if err != nil {
+		return nil, nil, err
+	}
+	dir, err := os.MkdirTemp("", "skyscape-docker-")
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to create TLS directory")
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+	for name, pem := range map[string]string{"ca.pem": ca, "cert.pem": cert, "key.pem": key} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(pem), 0600); err != nil {
+			cleanup()
+			return nil, nil, errors.Wrap(err, "failed to write TLS credentials")
+		}
+	}
+	return append(vars, "DOCKER_TLS_VERIFY=1", "DOCKER_CERT_PATH="+dir), cleanup, nil
+}
+
+// copyImage streams an image from this server's Docker to another host's,
+// which can't reach the registry as it only listens on localhost
+func copyImage(ctx context.Context, ref string, hostEnv []string, output io.Writer) error {
+	save := exec.CommandContext(ctx, "docker", "save", ref)
+	load := exec.CommandContext(ctx, "docker", "load")
+	load.Env = append(os.Environ(), hostEnv...)
+
+	reader, writer := io.Pipe()
+	save.Stdout, save.Stderr = writer, output
+	load.Stdin, load.Stdout, load.Stderr = reader, output, output
+	if err := load.Start(); err != nil {
+		return errors.Wrap(err, "failed to copy the image")
+	}
+	saveErr := save.Run()
+	writer.CloseWithError(saveErr)
+	loadErr := load.Wait()
+	if saveErr != nil {
+		return errors.Wrap(saveErr, "failed to export the image")
+	}
+	return errors.Wrap(loadErr, "failed to load the image on the host")
+}
+
+// dockerRun runs a docker command with the given environment on top of
+// this process's, writing its output
+func dockerRun(ctx context.Context, env []string, output io.Writer, args ...string) error {
+	cmd := exec.CommandContext(ctx, "docker", args...)
+	if env != nil {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	cmd.Stdout, cmd.Stderr = output, output
+	return cmd.Run()
+}
@@ -0,0 +1,93 @@
+package services
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"workspace/models"
+)
+
+// digestCheckInterval is how often users are checked for a due digest
+const digestCheckInterval = time.Hour
+
+// DigestService emails users their unread notifications once a day,
+// unless they opted out
+type DigestService struct{}
+
+var (
+	// Digests is the global notification digest mailer
+	Digests = &DigestService{}
+)
+
+// digestItem is a notification as listed in a digest
+type digestItem struct {
+	*models.Notification
+	Link string
+}
+
+// Start sends due digests in the background while mail is configured
+func (s *DigestService) Start() {
+	go func() {
+		ticker := time.NewTicker(digestCheckInterval)
+		defer ticker.Stop()
+
+		for now := range ticker.C {
+			if MailConfigured() {
+				s.run(now)
+			}
+		}
+	}()
+}
+
+// run sends every user who is due a digest and has unread notifications
+// since their last one
+func (s *DigestService) run(now time.Time) {
+	users, err := models.Auth.Users.Search("")
+	if err != nil {
+		log.Printf("DigestService: Failed to list users: %v", err)
+		return
+	}
+
+	for _, user := range users {
+		pref, err := models.GetMailPreference(user.ID)
+		if err != nil || !pref.DigestDue(now) {
+			continue
+		}
+		notifications, err := pref.DigestNotifications()
+		if err != nil || len(notifications) == 0 {
+			continue
+		}
+
+		message := MailMessage{Data: map[string]any{
+			"Name":          user.Name,
+			"Notifications": digestItems(notifications),
+		}}
+		if settings, err := models.GetSettings(); err == nil && settings.MailAddress() != "" {
+			message.Unsubscribe = settings.MailAddress() + "/mail/unsubscribe/" + pref.Token
+		}
+		if err := SendTemplate([]string{user.Email}, "digest", message); err != nil {
+			log.Printf("DigestService: Failed to email %s: %v", user.Email, err)
+			continue
+		}
+		pref.DigestSent(now)
+	}
+}
+
+// digestItems links each notification absolutely, as email clients can't
+// follow the workspace's relative links
+func digestItems(notifications []*models.Notification) []digestItem {
+	address := ""
+	if settings, err := models.GetSettings(); err == nil {
+		address = settings.MailAddress()
+	}
+
+	items := make([]digestItem, len(notifications))
+	for i, notification := range notifications {
+		items[i] = digestItem{Notification: notification, Link: notification.URL}
+		if strings.HasPrefix(notification.URL, "/") {
+			items[i].Link = address + notification.URL
+		}
+	}
+	return items
+}
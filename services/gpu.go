@@ -0,0 +1,129 @@
+package services
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// GPU vendors Ollama can use
+const (
+	GPUVendorNVIDIA = "nvidia"
+	GPUVendorAMD    = "amd"
+)
+
+// GPUDevice is a GPU found on the host
+type GPUDevice struct {
+	Name   string
+	Memory string // Total memory as reported by the driver, empty if unknown
+}
+
+// GPUInfo describes the GPUs on the host and whether containers can use
+// them. Reason explains why a GPU that was found can't be used.
+type GPUInfo struct {
+	Vendor  string // GPUVendorNVIDIA, GPUVendorAMD, or empty when none was found
+	Driver  string
+	Devices []GPUDevice
+	Usable  bool
+	Reason  string
+}
+
+// DetectGPU looks for NVIDIA GPUs with nvidia-smi and AMD GPUs through the
+// ROCm kernel driver, and checks that Docker can pass them to containers
+func DetectGPU() *GPUInfo {
+	if output, err := exec.Command("nvidia-smi", "--query-gpu=name,memory.total,driver_version", "--format=csv,noheader").Output(); err == nil {
+		info := &GPUInfo{Vendor: GPUVendorNVIDIA}
+		for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+			fields := strings.Split(line, ",")
+			if len(fields) < 3 {
+				continue
+			}
+			info.Devices = append(info.Devices, GPUDevice{
+				Name:   strings.TrimSpace(fields[0]),
+				Memory: strings.TrimSpace(fields[1]),
+			})
+			info.Driver = strings.TrimSpace(fields[2])
+		}
+		if len(info.Devices) == 0 {
+			return &GPUInfo{}
+		}
+
+		// --gpus needs the NVIDIA container toolkit registered with Docker
+		runtimes, err := exec.Command("docker", "info", "--format", "{{json .Runtimes}}").Output()
+		if err == nil && strings.Contains(string(runtimes), "nvidia") {
+			info.Usable = true
+		} else {
+			info.Reason = "the NVIDIA Container Toolkit is not installed for Docker"
+		}
+		return info
+	}
+
+	if _, err := os.Stat("/dev/kfd"); err == nil {
+		info := &GPUInfo{Vendor: GPUVendorAMD, Driver: "amdgpu"}
+		if output, err := exec.Command("rocm-smi", "--showproductname", "--csv").Output(); err == nil {
+			info.Devices = parseROCmProducts(string(output))
+		}
+		if len(info.Devices) == 0 {
+			info.Devices = []GPUDevice{{Name: "AMD GPU"}}
+		}
+		if _, err := os.Stat("/dev/dri"); err == nil {
+			info.Usable = true
+		} else {
+			info.Reason = "/dev/dri is missing"
+		}
+		return info
+	}
+
+	return &GPUInfo{}
+}
+
+// parseROCmProducts reads device names from rocm-smi's CSV output, whose
+// header names a "Card series" column
+func parseROCmProducts(output string) []GPUDevice {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) < 2 {
+		return nil
+	}
+	column := -1
+	for i, name := range strings.Split(lines[0], ",") {
+		if strings.EqualFold(strings.TrimSpace(name), "Card series") {
+			column = i
+		}
+	}
+	if column < 0 {
+		return nil
+	}
+
+	var devices []GPUDevice
+	for _, line := range lines[1:] {
+		fields := strings.Split(line, ",")
+		if column < len(fields) && strings.TrimSpace(fields[column]) != "" {
+			devices = append(devices, GPUDevice{Name: strings.TrimSpace(fields[column])})
+		}
+	}
+	return devices
+}
+
+// Found reports whether any GPU was found on the host
+func (g *GPUInfo) Found() bool {
+	return g != nil && g.Vendor != ""
+}
+
+// DockerArgs returns the docker run flags that give a container the GPUs
+func (g *GPUInfo) DockerArgs() []string {
+	switch g.Vendor {
+	case GPUVendorNVIDIA:
+		return []string{"--gpus", "all"}
+	case GPUVendorAMD:
+		return []string{"--device", "/dev/kfd", "--device", "/dev/dri", "--group-add", "video"}
+	}
+	return nil
+}
+
+// OllamaImage returns the Ollama image built for the GPU's runtime
+func (g *GPUInfo) OllamaImage() string {
+	if g != nil && g.Vendor == GPUVendorAMD {
+		return "ollama/ollama:rocm"
+	}
+	return "ollama/ollama:latest"
+}
@@ -0,0 +1,276 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"workspace/internal/github"
+	"workspace/models"
+
+	"github.com/The-Skyscape/devtools/pkg/authentication"
+	"github.com/pkg/errors"
+)
+
+// finishedImportTTL is how long a finished import's result stays available
+// to browsers that reconnect to its progress
+const finishedImportTTL = time.Hour
+
+// RepoImport is the progress of a repository being cloned from another host
+type RepoImport struct {
+	RepoID     string
+	RepoName   string
+	SourceURL  string
+	Step       string // What the import is doing now
+	Detail     string // Latest progress reported by git
+	Error      string
+	Done       bool
+	StartedAt  time.Time
+	FinishedAt time.Time
+}
+
+// ImportOptions describes where a repository is imported from
+type ImportOptions struct {
+	SourceURL string
+	Issues    bool // Also import issues and pull requests from GitHub
+}
+
+// ImportService clones repositories from other hosts in the background
+type ImportService struct {
+	mu      sync.Mutex
+	imports map[string]*RepoImport
+}
+
+var (
+	// Importer is the global repository importer
+	Importer = &ImportService{imports: map[string]*RepoImport{}}
+)
+
+// Start clones the source into a newly created repository in the
+// background and returns the import's progress. The user's GitHub or
+// Bitbucket credentials are used for private repositories on those hosts.
+// A failed import deletes the repository again.
+func (s *ImportService) Start(repo *models.Repository, user *authentication.User, opts ImportOptions) (RepoImport, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if imp, ok := s.imports[repo.ID]; ok && !imp.Done {
+		return *imp, errors.New("repository is already being imported")
+	}
+	for id, imp := range s.imports {
+		if imp.Done && time.Since(imp.FinishedAt) > finishedImportTTL {
+			delete(s.imports, id)
+		}
+	}
+
+	imp := &RepoImport{
+		RepoID:    repo.ID,
+		RepoName:  repo.Name,
+		SourceURL: opts.SourceURL,
+		Step:      "Starting import",
+		StartedAt: time.Now(),
+	}
+	s.imports[repo.ID] = imp
+
+	go func() {
+		err := s.run(imp, repo, user, opts)
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		imp.Done = true
+		imp.FinishedAt = time.Now()
+		if err != nil {
+			log.Printf("Importer: Failed to import %s from %s: %v", repo.ID, opts.SourceURL, err)
+			imp.Error = err.Error()
+		}
+	}()
+
+	return *imp, nil
+}
+
+// Status returns the progress of an import started with Start
+func (s *ImportService) Status(repoID string) (RepoImport, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	imp, ok := s.imports[repoID]
+	if !ok {
+		return RepoImport{}, false
+	}
+	return *imp, true
+}
+
+// update records what an import is doing
+func (s *ImportService) update(imp *RepoImport, step, detail string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	imp.Step = step
+	imp.Detail = detail
+}
+
+// run performs the import, removing the repository if it fails
+func (s *ImportService) run(imp *RepoImport, repo *models.Repository, user *authentication.User, opts ImportOptions) error {
+	s.update(imp, "Cloning repository", "")
+	if err := s.clone(imp, repo, user.ID, opts.SourceURL); err != nil {
+		if err := models.DeleteRepository(repo.ID); err != nil {
+			log.Printf("Importer: Failed to remove %s after a failed clone: %v", repo.ID, err)
+		}
+		return err
+	}
+
+	s.update(imp, "Detecting default branch and language", "")
+	if _, err := repo.DetectDefaultBranch(); err != nil {
+		log.Printf("Importer: Failed to detect default branch of %s: %v", repo.ID, err)
+	}
+	if _, err := repo.DetectPrimaryLanguage(); err != nil {
+		log.Printf("Importer: Failed to detect language of %s: %v", repo.ID, err)
+	}
+	if _, err := repo.GetSize(); err != nil {
+		log.Printf("Importer: Failed to measure %s: %v", repo.ID, err)
+	}
+	if err := models.Repositories.Update(repo); err != nil {
+		return errors.Wrap(err, "failed to save repository")
+	}
+
+	if opts.Issues {
+		s.update(imp, "Importing issues and pull requests", "")
+		if err := importGitHubIssues(repo, user.ID, opts.SourceURL); err != nil {
+			// The code is in place, so keep the repository and report what's missing
+			return errors.Wrap(err, "repository imported, but its issues and pull requests could not be")
+		}
+	}
+
+	s.update(imp, "Preparing workspace", "")
+	if err := Coder.CloneRepository(repo, user); err != nil {
+		log.Printf("Importer: Failed to clone %s to Code Server: %v", repo.ID, err)
+	}
+	Indexer.RefreshAsync(repo.ID)
+	Search.SyncAsync(repo.ID)
+
+	models.LogActivity("repo_imported", fmt.Sprintf("Imported repository %s", repo.Name),
+		fmt.Sprintf("Repository %s was imported from %s", repo.Name, opts.SourceURL),
+		user.ID, repo.ID, "repository", "")
+
+	s.update(imp, "Import complete", "")
+	return nil
+}
+
+// clone replaces the repository's empty git directory with a bare clone of
+// the source, passing git's progress on to the import
+func (s *ImportService) clone(imp *RepoImport, repo *models.Repository, userID, sourceURL string) error {
+	cloneURL := sourceURL
+	if creds := importCredentials(userID, sourceURL); creds != nil {
+		u, _ := url.Parse(sourceURL)
+		u.User = creds
+		cloneURL = u.String()
+	}
+
+	if err := os.RemoveAll(repo.Path()); err != nil {
+		return errors.Wrap(err, "failed to clear repository directory")
+	}
+
+	cmd := exec.Command("git", "clone", "--bare", "--progress", "--", cloneURL, repo.Path())
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return errors.Wrap(err, "failed to start git clone")
+	}
+	if err := cmd.Start(); err != nil {
+		return errors.Wrap(err, "failed to start git clone")
+	}
+
+	// Keep the last lines for the error message and report progress as it comes
+	var tail []string
+	scanner := bufio.NewScanner(stderr)
+	scanner.Split(scanProgressLines)
+	for scanner.Scan() {
+		line := strings.TrimSpace(strings.ReplaceAll(scanner.Text(), cloneURL, sourceURL))
+		if line == "" {
+			continue
+		}
+		s.update(imp, "Cloning repository", line)
+		if tail = append(tail, line); len(tail) > 5 {
+			tail = tail[1:]
+		}
+	}
+	io.Copy(io.Discard, stderr)
+
+	if err := cmd.Wait(); err != nil {
+		return errors.Errorf("failed to clone repository: %s", strings.Join(tail, "\n"))
+	}
+
+	// Keep credentials out of the stored remote
+	if _, stderr, err := repo.Git("remote", "set-url", "origin", sourceURL); err != nil {
+		log.Printf("Importer: Failed to reset origin of %s: %s", repo.ID, stderr.String())
+	}
+	return nil
+}
+
+// scanProgressLines splits git's progress output, which rewrites the
+// current line with carriage returns, into lines
+func scanProgressLines(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// importCredentials returns the user's credentials for the source's host,
+// or nil when the source is public or hosted elsewhere
+func importCredentials(userID, sourceURL string) *url.Userinfo {
+	u, err := url.Parse(sourceURL)
+	if err != nil || u.Scheme != "https" || u.User != nil {
+		return nil
+	}
+
+	switch u.Hostname() {
+	case "github.com":
+		if token, err := models.GetGitHubOAuthToken(userID); err == nil && token != "" {
+			return url.User(token)
+		}
+	case "bitbucket.org":
+		if creds, err := models.GetBitbucketCredentials(userID); err == nil {
+			return creds.GitUserinfo()
+		}
+	}
+	return nil
+}
+
+// importGitHubIssues connects the repository to GitHub and copies its
+// issues and pull requests, leaving later syncs to the user's settings
+func importGitHubIssues(repo *models.Repository, userID, githubURL string) error {
+	githubURL = strings.TrimSuffix(githubURL, ".git")
+
+	repo.GitHubURL = githubURL
+	repo.SyncDirection = "pull"
+	if err := models.Repositories.Update(repo); err != nil {
+		return errors.Wrap(err, "failed to save GitHub settings")
+	}
+
+	err := models.StoreGitHubRepoIntegration(repo.ID, map[string]any{
+		"github_url":     githubURL,
+		"sync_direction": repo.SyncDirection,
+		"auto_sync":      false,
+		"owner_id":       userID,
+		"enabled":        true,
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to store GitHub integration")
+	}
+
+	if err := github.NewGitOperationsService().ConfigureRemote(repo, githubURL); err != nil {
+		log.Printf("Importer: Failed to configure GitHub remote for %s: %v", repo.ID, err)
+	}
+	return github.NewGitHubSyncService().SyncRepository(repo.ID, userID)
+}
@@ -0,0 +1,141 @@
+package services
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"workspace/internal/codeindex"
+	"workspace/internal/gitstore"
+	"workspace/models"
+
+	"github.com/pkg/errors"
+)
+
+// IndexerService keeps a code index of each repository's default branch in
+// memory. An index is rebuilt when the branch moves, reusing the files whose
+// blobs didn't change, and pushes refresh it in the background so searches
+// don't wait.
+type IndexerService struct {
+	mu      sync.Mutex
+	indexes map[string]*codeindex.Index
+	locks   map[string]*sync.Mutex
+}
+
+var (
+	// Indexer is the global repository indexer instance
+	Indexer = &IndexerService{
+		indexes: map[string]*codeindex.Index{},
+		locks:   map[string]*sync.Mutex{},
+	}
+)
+
+// Get returns an index of the repository's default branch, building or
+// updating it first when the branch has moved since it was built
+func (s *IndexerService) Get(repo *models.Repository) (*codeindex.Index, error) {
+	commit, err := gitstore.Default.Resolve(repo.Path(), repo.GetDefaultBranch())
+	if err != nil {
+		if errors.Is(err, gitstore.ErrNotFound) {
+			// Nothing has been pushed yet
+			return codeindex.Build("", nil), nil
+		}
+		return nil, errors.Wrap(err, "failed to resolve default branch")
+	}
+
+	if ix := s.cached(repo.ID); ix != nil && ix.Commit == commit {
+		return ix, nil
+	}
+
+	// One build per repository at a time; later callers get its result
+	lock := s.lock(repo.ID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	previous := s.cached(repo.ID)
+	if previous != nil && previous.Commit == commit {
+		return previous, nil
+	}
+
+	ix, err := s.build(repo, commit, previous)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.indexes[repo.ID] = ix
+	s.mu.Unlock()
+	return ix, nil
+}
+
+// RefreshAsync brings a repository's index up to date in the background,
+// such as after a push
+func (s *IndexerService) RefreshAsync(repoID string) {
+	go func() {
+		repo, err := models.Repositories.Get(repoID)
+		if err != nil {
+			return
+		}
+		if _, err := s.Get(repo); err != nil {
+			log.Printf("IndexerService: Failed to index %s: %v", repo.Name, err)
+		}
+	}()
+}
+
+// Forget drops a repository's index, such as one being deleted
+func (s *IndexerService) Forget(repoID string) {
+	s.mu.Lock()
+	delete(s.indexes, repoID)
+	delete(s.locks, repoID)
+	s.mu.Unlock()
+}
+
+func (s *IndexerService) cached(repoID string) *codeindex.Index {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.indexes[repoID]
+}
+
+func (s *IndexerService) lock(repoID string) *sync.Mutex {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lock, ok := s.locks[repoID]
+	if !ok {
+		lock = &sync.Mutex{}
+		s.locks[repoID] = lock
+	}
+	return lock
+}
+
+// build indexes commit, taking unchanged files from the previous index
+func (s *IndexerService) build(repo *models.Repository, commit string, previous *codeindex.Index) (*codeindex.Index, error) {
+	start := time.Now()
+	blobs, err := listBlobs(repo, commit)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]*codeindex.File, 0, len(blobs))
+	read := 0
+	for path, blob := range blobs {
+		if previous != nil {
+			if file, ok := previous.File(path); ok && file.Blob == blob {
+				files = append(files, file)
+				continue
+			}
+		}
+
+		content, err := gitstore.Default.Object(repo.Path(), blob)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read %s", path)
+		}
+		read++
+		if file := codeindex.NewFile(path, blob, content); file != nil {
+			files = append(files, file)
+		}
+	}
+
+	ix := codeindex.Build(commit, files)
+	log.Printf("IndexerService: Indexed %s at %.7s, %d files (%d read) in %s",
+		repo.Name, commit, ix.Len(), read, time.Since(start).Round(time.Millisecond))
+	return ix, nil
+}
@@ -4,6 +4,8 @@ import (
 	"log"
 	"os"
 	"strings"
+
+	"workspace/models"
 )
 
 // init automatically starts required services during package initialization.
@@ -18,6 +20,17 @@ func init() {
 
 	log.Println("Services: Starting service initialization...")
 
+	// Settings saved by the setup wizard fill in what the environment leaves out
+	if settings, err := models.GetSettings(); err == nil {
+		settings.ApplyEnvironment()
+		if model := os.Getenv("AI_MODEL"); model != "" {
+			Ollama.SetDefaultModel(model)
+		}
+	}
+
+	// Migrations don't survive a restart; they wait for an admin to resume them
+	Migrator.PauseInterrupted()
+
 	// Initialize Ollama service if AI is enabled
 	aiEnabled := os.Getenv("AI_ENABLED") == "true"
 	if aiEnabled {
@@ -40,4 +53,17 @@ func init() {
 	// Note: Coder proxy is handled differently (not a container service in workspace)
 
 	log.Println("Services: Service initialization scheduled")
-}
\ No newline at end of file
+}
+
+// EnableAI starts the AI services after AI was switched on at runtime,
+// such as from the setup wizard
+func EnableAI(model string) {
+	if model != "" {
+		Ollama.SetDefaultModel(model)
+	}
+	go func() {
+		if err := Ollama.Init(); err != nil {
+			log.Printf("Services: Warning - Ollama service initialization failed: %v", err)
+		}
+	}()
+}
@@ -0,0 +1,99 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"time"
+
+	"workspace/internal/lint"
+	"workspace/models"
+
+	"github.com/pkg/errors"
+)
+
+// lintTimeout bounds how long the linters may run in a sandbox, in seconds
+const lintTimeout = 600
+
+// lintBranch matches the branch names the linters can be run on, which are
+// written into the sandbox's script
+var lintBranch = regexp.MustCompile(`^[\w./-]+$`)
+
+// RunLinters runs the named linters, or all of them, on a branch of the
+// repository in a sandbox with the repository's sandbox policy. The
+// findings are recorded and annotated on the branch's open pull requests.
+func RunLinters(ctx context.Context, repo *models.Repository, branch string, names []string, userID string) (*models.LintRun, *lint.Report, error) {
+	linters, err := lint.Select(names)
+	if err != nil {
+		return nil, nil, err
+	}
+	if branch == "" {
+		branch = repo.GetDefaultBranch()
+	}
+	if !lintBranch.MatchString(branch) {
+		return nil, nil, errors.Errorf("invalid branch name %q", branch)
+	}
+	commit, err := repo.ResolveCommit("refs/heads/" + branch)
+	if err != nil {
+		return nil, nil, errors.Errorf("branch %s not found", branch)
+	}
+
+	script := "git checkout -q " + branch + "\n" + lint.Script(linters)
+	sandbox, err := NewSandbox(fmt.Sprintf("lint-%s-%d", repo.ID, time.Now().Unix()), repo.Path(), repo.Name, script, lintTimeout, models.GetSandboxPolicy(repo.ID))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer sandbox.Cleanup()
+	if err := sandbox.Start(); err != nil {
+		return nil, nil, err
+	}
+	for sandbox.IsRunning() {
+		select {
+		case <-ctx.Done():
+			sandbox.Stop()
+			return nil, nil, ctx.Err()
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+	output, err := sandbox.GetOutput()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	run, report, err := recordLint(&models.LintRun{
+		RepoID:    repo.ID,
+		Source:    models.LintSourceAssistant,
+		Name:      "Lint " + branch,
+		Branch:    branch,
+		CommitSHA: commit,
+	}, output, userID)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "linters didn't run")
+	}
+	return run, report, nil
+}
+
+// recordLint saves the findings in the output of lint.Script and annotates
+// them on the open pull requests whose head the run was at
+func recordLint(run *models.LintRun, output, authorID string) (*models.LintRun, *lint.Report, error) {
+	report, err := lint.ParseOutput(output)
+	if err != nil {
+		return nil, nil, err
+	}
+	run, err = models.RecordLintRun(run, report)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	prs, err := models.PullRequests.Search("WHERE RepoID = ? AND CompareBranch = ? AND Status NOT IN (?, ?)", run.RepoID, run.Branch, "merged", "closed")
+	if err != nil {
+		return run, report, nil
+	}
+	for _, pr := range prs {
+		if _, err := run.Annotate(pr, authorID); err != nil {
+			log.Printf("Lint: Failed to annotate PR %s: %v", pr.ID, err)
+		}
+	}
+	return run, report, nil
+}
@@ -0,0 +1,302 @@
+package services
+
+import (
+	"bytes"
+	"crypto/tls"
+	"embed"
+	"encoding/base64"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"workspace/models"
+
+	"github.com/pkg/errors"
+)
+
+// mailTimeout bounds connecting to the SMTP server
+const mailTimeout = 30 * time.Second
+
+//go:embed emails/*.html
+var mailTemplates embed.FS
+
+// MailAttachment is a file attached to an email
+type MailAttachment struct {
+	Name        string
+	ContentType string
+	Data        []byte
+}
+
+// mailServer is the SMTP server outgoing mail goes through
+type mailServer struct {
+	Host     string
+	Port     string
+	Security string // models.MailStartTLS, models.MailTLS or models.MailPlain
+	From     string
+	Username string
+	Password string
+}
+
+// currentMailServer returns the SMTP server named by SMTP_HOST and the other
+// SMTP_ environment variables when set, otherwise the one in settings
+func currentMailServer() (*mailServer, error) {
+	server := &mailServer{
+		Host:     os.Getenv("SMTP_HOST"),
+		Port:     os.Getenv("SMTP_PORT"),
+		Security: models.MailStartTLS,
+		From:     os.Getenv("SMTP_FROM"),
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+	}
+	if server.Host == "" {
+		settings, err := models.GetSettings()
+		if err != nil || !settings.MailEnabled() {
+			return nil, errors.New("outgoing mail is not configured, set up a mail server in settings")
+		}
+		server = &mailServer{
+			Host:     settings.MailHost,
+			Security: settings.MailSecurity,
+			From:     settings.MailFrom,
+			Username: settings.MailUsername,
+		}
+		if settings.MailPort > 0 {
+			server.Port = strconv.Itoa(settings.MailPort)
+		}
+		if server.Username != "" {
+			server.Password, _ = models.GetMailPassword()
+		}
+	}
+
+	if server.Port == "" {
+		server.Port = "587"
+	}
+	if server.From == "" {
+		server.From = "workspace@" + server.Host
+	}
+	return server, nil
+}
+
+// MailConfigured reports whether an SMTP server is set up for outgoing mail
+func MailConfigured() bool {
+	_, err := currentMailServer()
+	return err == nil
+}
+
+// MailSetByEnvironment reports whether the SMTP_ environment variables
+// choose the mail server, overriding settings
+func MailSetByEnvironment() bool {
+	return os.Getenv("SMTP_HOST") != ""
+}
+
+// SendMail sends a plain text email through the configured SMTP server
+func SendMail(to []string, subject, body string, attachments ...MailAttachment) error {
+	return sendMail(to, subject, body, "", attachments)
+}
+
+// sendMail sends an email with a text body, and an HTML alternative when
+// html isn't empty
+func sendMail(to []string, subject, text, html string, attachments []MailAttachment) error {
+	server, err := currentMailServer()
+	if err != nil {
+		return err
+	}
+	if len(to) == 0 {
+		return errors.New("no recipients")
+	}
+
+	message, err := buildMail(server.From, to, subject, text, html, attachments)
+	if err != nil {
+		return err
+	}
+	return errors.Wrap(server.send(to, message), "failed to send mail")
+}
+
+// send delivers a message, securing the connection as the server is set up
+func (s *mailServer) send(to []string, message []byte) error {
+	address := net.JoinHostPort(s.Host, s.Port)
+	var auth smtp.Auth
+	if s.Username != "" {
+		auth = smtp.PlainAuth("", s.Username, s.Password, s.Host)
+	}
+
+	var conn net.Conn
+	var err error
+	switch s.Security {
+	case models.MailTLS:
+		dialer := &net.Dialer{Timeout: mailTimeout}
+		conn, err = tls.DialWithDialer(dialer, "tcp", address, &tls.Config{ServerName: s.Host})
+	case models.MailPlain:
+		conn, err = net.DialTimeout("tcp", address, mailTimeout)
+	default:
+		// STARTTLS is used whenever the server offers it
+		return smtp.SendMail(address, auth, s.From, to, message)
+	}
+	if err != nil {
+		return err
+	}
+
+	client, err := smtp.NewClient(conn, s.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if auth != nil {
+		if err := client.Auth(auth); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(s.From); err != nil {
+		return err
+	}
+	for _, recipient := range to {
+		if err := client.Rcpt(recipient); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(message); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// MailMessage is what an email template is rendered with
+type MailMessage struct {
+	AppName     string // Name of the workspace
+	URL         string // Address of the workspace, for links
+	Unsubscribe string // Link turning the recipient's digests off, if any
+	Data        any    // What the email itself shows
+}
+
+// SendTemplate sends the email in emails/<name>.html. Each template
+// defines its "subject", its "text" body and its "html" body, which is
+// wrapped in the shared layout.
+func SendTemplate(to []string, name string, message MailMessage) error {
+	if settings, err := models.GetSettings(); err == nil {
+		if message.AppName == "" {
+			message.AppName = settings.AppName
+		}
+		if message.URL == "" {
+			message.URL = settings.MailAddress()
+		}
+	}
+
+	file := "emails/" + name + ".html"
+	text, err := template.ParseFS(mailTemplates, file)
+	if err != nil {
+		return errors.Wrap(err, "failed to load email template")
+	}
+	html, err := htmltemplate.ParseFS(mailTemplates, "emails/layout.html", file)
+	if err != nil {
+		return errors.Wrap(err, "failed to load email template")
+	}
+
+	var subject, body, page bytes.Buffer
+	if err := text.ExecuteTemplate(&subject, "subject", message); err != nil {
+		return errors.Wrap(err, "failed to render email subject")
+	}
+	if err := text.ExecuteTemplate(&body, "text", message); err != nil {
+		return errors.Wrap(err, "failed to render email")
+	}
+	if err := html.ExecuteTemplate(&page, "layout", message); err != nil {
+		return errors.Wrap(err, "failed to render email")
+	}
+	return sendMail(to, strings.TrimSpace(subject.String()), strings.TrimSpace(body.String())+"\n", page.String(), nil)
+}
+
+// buildMail writes a MIME message with the text body, the HTML body as an
+// alternative to it when given, and each attachment base64 encoded
+func buildMail(from string, to []string, subject, text, html string, attachments []MailAttachment) ([]byte, error) {
+	var buf bytes.Buffer
+	parts := multipart.NewWriter(&buf)
+
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&buf, "Content-Type: multipart/mixed; boundary=%q\r\n\r\n", parts.Boundary())
+
+	if html == "" {
+		if err := writeMailPart(parts, "text/plain; charset=utf-8", []byte(text)); err != nil {
+			return nil, err
+		}
+	} else {
+		var alternatives bytes.Buffer
+		bodies := multipart.NewWriter(&alternatives)
+		if err := writeMailPart(bodies, "text/plain; charset=utf-8", []byte(text)); err != nil {
+			return nil, err
+		}
+		if err := writeMailPart(bodies, "text/html; charset=utf-8", []byte(html)); err != nil {
+			return nil, err
+		}
+		if err := bodies.Close(); err != nil {
+			return nil, err
+		}
+		part, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type": {mime.FormatMediaType("multipart/alternative", map[string]string{"boundary": bodies.Boundary()})},
+		})
+		if err != nil {
+			return nil, err
+		}
+		part.Write(alternatives.Bytes())
+	}
+
+	for _, attachment := range attachments {
+		part, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {attachment.ContentType},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Name})},
+		})
+		if err != nil {
+			return nil, err
+		}
+		writeBase64(part, attachment.Data)
+	}
+
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeMailPart writes a base64 encoded body part
+func writeMailPart(parts *multipart.Writer, contentType string, data []byte) error {
+	part, err := parts.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {contentType},
+		"Content-Transfer-Encoding": {"base64"},
+	})
+	if err != nil {
+		return err
+	}
+	writeBase64(part, data)
+	return nil
+}
+
+// writeBase64 writes data base64 encoded in 76 character lines
+func writeBase64(w io.Writer, data []byte) {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 76 {
+		w.Write([]byte(encoded[:76] + "\r\n"))
+		encoded = encoded[76:]
+	}
+	w.Write([]byte(encoded + "\r\n"))
+}
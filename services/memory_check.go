@@ -0,0 +1,80 @@
+package services
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// AIModelOption describes a model offered during setup and the memory it
+// needs to run comfortably
+type AIModelOption struct {
+	Name        string
+	Label       string
+	Description string
+	MinMemoryGB int
+}
+
+// AIModelOptions lists the models the workspace has providers for, largest first
+var AIModelOptions = []AIModelOption{
+	{Name: "gpt-oss", Label: "GPT-OSS", Description: "Full assistant with tool use and code review", MinMemoryGB: 16},
+	{Name: "llama3.2:1b", Label: "Llama 3.2 1B", Description: "Lightweight model for small servers", MinMemoryGB: 4},
+}
+
+// MemoryInfo holds the host memory in bytes
+type MemoryInfo struct {
+	Total     uint64
+	Available uint64
+}
+
+// TotalGB returns the total memory rounded to whole gigabytes
+func (m MemoryInfo) TotalGB() int {
+	return int((m.Total + 1<<29) >> 30)
+}
+
+// Fits reports whether the host has enough memory for the model
+func (m MemoryInfo) Fits(option AIModelOption) bool {
+	return m.TotalGB() >= option.MinMemoryGB
+}
+
+// RecommendedModel returns the largest model that fits in memory, or an
+// empty string when none does
+func (m MemoryInfo) RecommendedModel() string {
+	for _, option := range AIModelOptions {
+		if m.Fits(option) {
+			return option.Name
+		}
+	}
+	return ""
+}
+
+// CheckMemory reads the host memory from /proc/meminfo
+func CheckMemory() (MemoryInfo, error) {
+	file, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return MemoryInfo{}, err
+	}
+	defer file.Close()
+
+	var info MemoryInfo
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		kb, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		switch fields[0] {
+		case "MemTotal:":
+			info.Total = kb * 1024
+		case "MemAvailable:":
+			info.Available = kb * 1024
+		}
+	}
+
+	return info, scanner.Err()
+}
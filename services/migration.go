@@ -0,0 +1,281 @@
+package services
+
+import (
+	"context"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"workspace/internal/migration"
+	"workspace/models"
+
+	"github.com/pkg/errors"
+)
+
+// MigratorService sends migrations to their target workspaces in the
+// background, one goroutine per running migration
+type MigratorService struct {
+	mu      sync.Mutex
+	running map[string]context.CancelFunc
+}
+
+var (
+	// Migrator is the global migration runner
+	Migrator = &MigratorService{running: map[string]context.CancelFunc{}}
+)
+
+// Start sends a migration's remaining items in the background
+func (s *MigratorService) Start(m *models.Migration) error {
+	client, err := migrationClient(m)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.running[m.ID]; ok {
+		return errors.New("migration is already running")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.running[m.ID] = cancel
+
+	m.Status = models.MigrationRunning
+	m.Error = ""
+	if err := models.Migrations.Update(m); err != nil {
+		cancel()
+		delete(s.running, m.ID)
+		return err
+	}
+
+	go func() {
+		err := s.run(ctx, client, m)
+
+		s.mu.Lock()
+		delete(s.running, m.ID)
+		s.mu.Unlock()
+
+		m.Status = models.MigrationPaused
+		switch {
+		case err == nil:
+			m.Status = models.MigrationCompleted
+			m.CompletedAt = time.Now()
+		case ctx.Err() != nil:
+			// Paused by an admin
+		default:
+			log.Printf("Migrator: Migration to %s stopped: %v", m.TargetURL, err)
+			m.Error = err.Error()
+		}
+		if err := models.Migrations.Update(m); err != nil {
+			log.Printf("Migrator: Failed to save migration %s: %v", m.ID, err)
+		}
+	}()
+	return nil
+}
+
+// Pause stops a running migration after the request in flight
+func (s *MigratorService) Pause(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cancel, ok := s.running[id]; ok {
+		cancel()
+	}
+}
+
+// IsRunning reports whether a migration is being sent
+func (s *MigratorService) IsRunning(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.running[id]
+	return ok
+}
+
+// PauseInterrupted marks migrations that were running when the workspace
+// stopped as paused, so an admin can resume them
+func (s *MigratorService) PauseInterrupted() {
+	migrations, err := models.Migrations.Search("WHERE Status = ?", models.MigrationRunning)
+	if err != nil {
+		return
+	}
+	for _, m := range migrations {
+		if s.IsRunning(m.ID) {
+			continue
+		}
+		m.Status = models.MigrationPaused
+		m.Error = "Interrupted by a restart"
+		models.Migrations.Update(m)
+	}
+}
+
+// CheckMigrationTarget makes sure the target workspace accepts the token
+// and returns its name
+func CheckMigrationTarget(targetURL, token string) (string, error) {
+	client, err := migration.NewClient(targetURL, token)
+	if err != nil {
+		return "", err
+	}
+	status, err := client.Status()
+	if err != nil {
+		return "", errors.Wrap(err, "target workspace refused the token")
+	}
+	return status.Workspace, nil
+}
+
+func migrationClient(m *models.Migration) (*migration.Client, error) {
+	token, err := m.TokenValue()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read migration token")
+	}
+	return migration.NewClient(m.TargetURL, token)
+}
+
+// run sends every item not sent yet, in order, stopping at the first
+// failure so a resumed migration picks up from there
+func (s *MigratorService) run(ctx context.Context, client *migration.Client, m *models.Migration) error {
+	status, err := client.Status()
+	if err != nil {
+		return errors.Wrap(err, "failed to reach target workspace")
+	}
+	items, err := m.Items()
+	if err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if item.Status == models.MigrationItemSent {
+			continue
+		}
+		receipt := status.Items[item.Item]
+
+		if err := s.send(ctx, client, m, item, receipt); err != nil {
+			item.Status = models.MigrationItemFailed
+			item.Error = err.Error()
+			models.MigrationItems.Update(item)
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return errors.Wrapf(err, "failed to send %s", item.Label)
+		}
+
+		item.Status = models.MigrationItemSent
+		item.Error = ""
+		if err := models.MigrationItems.Update(item); err != nil {
+			return err
+		}
+		m.ItemsDone++
+		if err := models.Migrations.Update(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// send delivers one item, picking up an archive where the target left off
+func (s *MigratorService) send(ctx context.Context, client *migration.Client, m *models.Migration, item *models.MigrationItem, receipt migration.Receipt) error {
+	kind, repoID, _ := strings.Cut(item.Item, "/")
+	if kind != "archive" {
+		value, err := models.ExportMigrationItem(item.Item)
+		if err != nil {
+			return err
+		}
+		return client.SendJSON(item.Item, value)
+	}
+
+	repo, err := models.Repositories.Get(repoID)
+	if err != nil {
+		return errors.Wrap(err, "repository not found")
+	}
+	if _, err := os.Stat(repo.Path()); os.IsNotExist(err) {
+		// Nothing was ever pushed
+		return nil
+	}
+
+	path, err := s.archive(m, repo)
+	if err != nil {
+		return err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	item.Size = info.Size()
+
+	// What the target has only continues this archive if some of it was
+	// sent already; after a resync the archive is rebuilt and starts over
+	offset := int64(0)
+	if item.Offset > 0 {
+		if receipt.Done && receipt.Offset == item.Size {
+			return nil
+		}
+		offset = min(receipt.Offset, item.Size)
+	}
+
+	chunk := make([]byte, migration.ChunkSize)
+	for {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		n, err := file.ReadAt(chunk, offset)
+		if err != nil && err != io.EOF {
+			return err
+		}
+		final := offset+int64(n) >= item.Size
+
+		err = client.Send(item.Item, offset, chunk[:n], final)
+		var offsetErr *migration.OffsetError
+		if errors.As(err, &offsetErr) {
+			// A response was lost; continue from what the target has
+			offset = min(offsetErr.Expected, item.Size)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		offset += int64(n)
+		item.Offset = offset
+		m.BytesSent += int64(n)
+		models.MigrationItems.Update(item)
+		if final {
+			return nil
+		}
+	}
+}
+
+// archive packs a repository's git data once per migration, so resuming
+// continues the same bytes the target already has part of
+func (s *MigratorService) archive(m *models.Migration, repo *models.Repository) (string, error) {
+	path := filepath.Join(m.ArchiveDir(), repo.ID+".tar.gz")
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+	if err := os.MkdirAll(m.ArchiveDir(), 0755); err != nil {
+		return "", err
+	}
+
+	partial := path + ".tmp"
+	file, err := os.Create(partial)
+	if err != nil {
+		return "", err
+	}
+	err = migration.ArchiveDir(repo.Path(), file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(partial)
+		return "", errors.Wrap(err, "failed to archive repository")
+	}
+	return path, os.Rename(partial, path)
+}
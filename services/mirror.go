@@ -0,0 +1,178 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"workspace/internal/mirror"
+	"workspace/models"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// mirrorCheckInterval is how often the exporter checks whether a mirror
+	// export is due
+	mirrorCheckInterval = 10 * time.Minute
+
+	// mirrorPushTimeout bounds the push of a single repository
+	mirrorPushTimeout = 30 * time.Minute
+)
+
+// MirrorService pushes every repository to the organization configured in
+// settings, as an off-site copy alongside backups
+type MirrorService struct {
+	mu      sync.Mutex
+	running bool
+}
+
+var (
+	// Mirrors is the global mirror exporter
+	Mirrors = &MirrorService{}
+)
+
+// Start runs mirror exports in the background whenever one is due
+func (s *MirrorService) Start() {
+	go func() {
+		ticker := time.NewTicker(mirrorCheckInterval)
+		defer ticker.Stop()
+
+		for now := range ticker.C {
+			settings, err := models.GetSettings()
+			if err != nil || !settings.MirrorDue(now) {
+				continue
+			}
+			if err := s.RunAsync(); err != nil {
+				log.Printf("MirrorService: %v", err)
+			}
+		}
+	}()
+}
+
+// IsRunning reports whether a mirror export is in progress
+func (s *MirrorService) IsRunning() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.running
+}
+
+// RunAsync starts a mirror export in the background
+func (s *MirrorService) RunAsync() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.running {
+		return errors.New("a mirror export is already running")
+	}
+	s.running = true
+
+	go func() {
+		defer func() {
+			s.mu.Lock()
+			s.running = false
+			s.mu.Unlock()
+		}()
+		if err := s.run(); err != nil {
+			log.Printf("MirrorService: Export failed: %v", err)
+		}
+	}()
+	return nil
+}
+
+// run pushes every repository that hasn't opted out to the mirror
+// organization, recording how each push went
+func (s *MirrorService) run() error {
+	settings, err := models.GetSettings()
+	if err != nil {
+		return err
+	}
+	if !settings.MirrorEnabled() {
+		return errors.New("mirror export is not configured")
+	}
+
+	// Record the run up front so a failing export waits for the next interval
+	settings.MirrorLastRunAt = time.Now()
+	if err := models.GlobalSettings.Update(settings); err != nil {
+		return errors.Wrap(err, "failed to save mirror run time")
+	}
+
+	token, err := models.GetMirrorToken()
+	if err != nil {
+		return err
+	}
+	client, err := mirror.NewClient(mirror.Target{
+		Provider: settings.MirrorProvider,
+		BaseURL:  settings.MirrorBaseURL,
+		Org:      settings.MirrorOrg,
+		Token:    token,
+	})
+	if err != nil {
+		return err
+	}
+
+	repos, err := models.Repositories.Search("")
+	if err != nil {
+		return errors.Wrap(err, "failed to list repositories")
+	}
+
+	var pushed, failed int
+	for _, repo := range repos {
+		if repo.MirrorExcluded {
+			continue
+		}
+		if refs, _, err := repo.Git("for-each-ref", "--count=1", "refs/heads", "refs/tags"); err != nil || refs.Len() == 0 {
+			continue // Nothing to push yet
+		}
+
+		start := time.Now()
+		webURL, pushErr := s.push(client, repo, token)
+		if _, err := models.RecordRepoMirror(repo.ID, webURL, pushErr, time.Since(start)); err != nil {
+			log.Printf("MirrorService: %v", err)
+		}
+		if pushErr != nil {
+			log.Printf("MirrorService: Failed to mirror %s: %v", repo.ID, pushErr)
+			failed++
+			continue
+		}
+		pushed++
+	}
+
+	log.Printf("MirrorService: Mirrored %d repositories to %s (%d failed)", pushed, settings.MirrorOrg, failed)
+	models.LogActivity("mirror_exported", "Mirrored repositories",
+		fmt.Sprintf("Pushed %d repositories to %s, %d failed", pushed, settings.MirrorOrg, failed),
+		"", "", "settings", "")
+	return nil
+}
+
+// push creates the repository's mirror if needed and force-pushes all of
+// its branches and tags to it, returning the mirror's web address
+func (s *MirrorService) push(client *mirror.Client, repo *models.Repository, token string) (string, error) {
+	webURL, err := client.EnsureRepository(repo.ID, repo.Description)
+	if err != nil {
+		return "", err
+	}
+	pushURL, err := client.PushURL(webURL)
+	if err != nil {
+		return webURL, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), mirrorPushTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "git", "push", "--prune", "--", pushURL,
+		"+refs/heads/*:refs/heads/*", "+refs/tags/*:refs/tags/*")
+	cmd.Dir = repo.Path()
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		// Keep the token out of the stored error
+		msg := strings.TrimSpace(strings.ReplaceAll(string(output), token, "***"))
+		return webURL, errors.Errorf("git push failed: %s", msg)
+	}
+	return webURL, nil
+}
@@ -2,16 +2,20 @@ package services
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
+	"os/exec"
 	"strings"
 	"sync"
 	"time"
 
+	"workspace/middleware"
+
 	"github.com/The-Skyscape/devtools/pkg/containers"
 	"github.com/The-Skyscape/devtools/pkg/database"
 	"github.com/pkg/errors"
@@ -23,7 +27,7 @@ type OllamaConfig struct {
 	ContainerName string
 	DataDir       string
 	DefaultModel  string
-	GPUEnabled    bool
+	GPUEnabled    bool // Whether the container was given the host's GPUs
 }
 
 // OllamaService manages the Ollama container for AI models
@@ -32,6 +36,28 @@ type OllamaService struct {
 	service *containers.Service
 	client  *http.Client
 	mu      sync.RWMutex
+
+	// GPU detection is guarded separately so status pages don't wait on a
+	// container start, which holds mu
+	gpuMu sync.Mutex
+	gpu   *GPUInfo
+
+	// Model downloads started from the settings page, by model name
+	pullMu sync.Mutex
+	pulls  map[string]*ModelPull
+
+	// Chats running and waiting for a turn, see MaxChats
+	queue chatQueue
+
+	// Models being loaded into memory in the background, by model name
+	loadMu  sync.Mutex
+	loading map[string]bool
+
+	// Remote servers from OLLAMA_HOST, used instead of the container
+	remoteMu    sync.Mutex
+	remotes     []*OllamaEndpoint
+	nextRemote  int
+	monitorOnce sync.Once
 }
 
 // OllamaStatus represents the current status of the Ollama service
@@ -41,6 +67,8 @@ type OllamaStatus struct {
 	Health       string
 	Models       []string
 	DefaultModel string
+	Remote       bool             // Whether Ollama runs on remote servers
+	Endpoints    []OllamaEndpoint // The remote servers, when Remote
 }
 
 // OllamaMessage represents a chat message
@@ -48,6 +76,7 @@ type OllamaMessage struct {
 	Role      string           `json:"role"` // "user", "assistant", "system", "tool"
 	Content   string           `json:"content"`
 	ToolCalls []OllamaToolCall `json:"tool_calls,omitempty"` // Tool calls in the message
+	Images    []string         `json:"images,omitempty"`     // Base64 images for multimodal models
 }
 
 // OllamaChatRequest represents a chat completion request
@@ -57,6 +86,9 @@ type OllamaChatRequest struct {
 	Stream   bool            `json:"stream"`
 	Options  map[string]any  `json:"options,omitempty"`
 	Tools    []OllamaTool    `json:"tools,omitempty"` // Native tool support
+
+	// How long the model stays loaded afterwards, a duration string or seconds
+	KeepAlive any `json:"keep_alive,omitempty"`
 }
 
 // OllamaTool represents a tool definition for function calling
@@ -103,6 +135,11 @@ type OllamaModelInfo struct {
 	Name       string    `json:"name"`
 	ModifiedAt time.Time `json:"modified_at"`
 	Size       int64     `json:"size"`
+	Details    struct {
+		Family            string `json:"family"`
+		ParameterSize     string `json:"parameter_size"`
+		QuantizationLevel string `json:"quantization_level"`
+	} `json:"details"`
 }
 
 var (
@@ -118,24 +155,13 @@ func NewOllamaService() *OllamaService {
 		aiModel = "gpt-oss" // Default to GPT-OSS for Pro workspaces
 	}
 
-	// Check GPU_ENABLED flag explicitly
-	gpuEnabled := os.Getenv("GPU_ENABLED") == "true"
-
-	// Auto-enable GPU for certain models if not explicitly set
-	if !gpuEnabled && os.Getenv("GPU_ENABLED") == "" {
-		if strings.Contains(aiModel, "gpt-oss") || strings.Contains(aiModel, "llama2") {
-			gpuEnabled = true
-			log.Printf("OllamaService: Auto-enabling GPU for model %s", aiModel)
-		}
-	}
-
+	// GPUs are detected when the container starts, see configureGPU
 	return &OllamaService{
 		config: &OllamaConfig{
 			Port:          11434,
 			ContainerName: "skyscape-ollama",
 			DataDir:       fmt.Sprintf("%s/ollama", database.DataDir()),
 			DefaultModel:  aiModel,
-			GPUEnabled:    gpuEnabled,
 		},
 		client: &http.Client{},
 	}
@@ -150,6 +176,20 @@ func (o *OllamaService) Init() error {
 		return nil
 	}
 
+	// Remote servers replace the container entirely
+	if err := o.configureRemote(); err != nil {
+		return err
+	}
+	if o.IsRemote() {
+		o.checkRemotes()
+		o.monitorOnce.Do(func() { go o.monitorRemotes() })
+		if !o.IsRunning() {
+			log.Println("OllamaService: No remote server is reachable yet, retrying in the background")
+		}
+		go o.ensureDefaultModel()
+		return nil
+	}
+
 	o.mu.Lock()
 	defer o.mu.Unlock()
 
@@ -159,6 +199,13 @@ func (o *OllamaService) Init() error {
 		log.Println("OllamaService: Already running")
 		o.service = existing
 
+		// Report how the running container was started
+		o.config.GPUEnabled = containerHasGPU(o.config.ContainerName)
+		if gpu := o.GPU(); gpu.Usable && !o.config.GPUEnabled && os.Getenv("GPU_ENABLED") != "false" {
+			log.Printf("OllamaService: A %s GPU is available but the running container uses the CPU; remove %s to recreate it with the GPU",
+				gpu.Vendor, o.config.ContainerName)
+		}
+
 		// Pull default model if not already present
 		go o.ensureDefaultModel()
 		return nil
@@ -199,13 +246,10 @@ func (o *OllamaService) startAsync() error {
 		return errors.Wrap(err, "failed to prepare Ollama directories")
 	}
 
-	// Create service configuration
-	o.service = o.createServiceConfig()
-
 	// Launch the service with progress tracking
 	log.Println("OllamaService: Pulling Docker image (this may take a few minutes)...")
-	if err := containers.Launch(host, o.service); err != nil {
-		return errors.Wrap(err, "failed to launch Ollama service")
+	if err := o.launch(); err != nil {
+		return err
 	}
 
 	// Wait for service to be ready
@@ -236,6 +280,15 @@ func (o *OllamaService) Start() error {
 
 // start is the internal start method (must be called with lock held)
 func (o *OllamaService) start() error {
+	// Remote servers can't be started, only checked again
+	if o.IsRemote() {
+		o.checkRemotes()
+		if _, err := o.remoteEndpoint(false); err != nil {
+			return err
+		}
+		return nil
+	}
+
 	// Check if already running
 	if o.service != nil && o.service.IsRunning() {
 		log.Println("OllamaService: Already running")
@@ -259,12 +312,9 @@ func (o *OllamaService) start() error {
 		return errors.Wrap(err, "failed to prepare Ollama directories")
 	}
 
-	// Create service configuration
-	o.service = o.createServiceConfig()
-
 	// Launch the service
-	if err := containers.Launch(host, o.service); err != nil {
-		return errors.Wrap(err, "failed to launch Ollama service")
+	if err := o.launch(); err != nil {
+		return err
 	}
 
 	// Wait for service to be ready
@@ -289,6 +339,11 @@ func (o *OllamaService) Stop() error {
 	o.mu.Lock()
 	defer o.mu.Unlock()
 
+	if o.IsRemote() {
+		log.Println("OllamaService: Remote servers are not managed by the workspace")
+		return nil
+	}
+
 	if o.service == nil {
 		log.Println("OllamaService: Not initialized")
 		return nil
@@ -312,6 +367,10 @@ func (o *OllamaService) Restart() error {
 	o.mu.Lock()
 	defer o.mu.Unlock()
 
+	if o.IsRemote() {
+		return o.start()
+	}
+
 	if o.service == nil {
 		return errors.New("Ollama service not initialized")
 	}
@@ -336,6 +395,13 @@ func (o *OllamaService) GetDefaultModel() string {
 	return o.config.DefaultModel
 }
 
+// SetDefaultModel changes the model pulled on startup and used by default
+func (o *OllamaService) SetDefaultModel(model string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.config.DefaultModel = model
+}
+
 // IsRunning checks if the service is running
 func (o *OllamaService) IsRunning() bool {
 	// Check if AI is enabled
@@ -343,6 +409,12 @@ func (o *OllamaService) IsRunning() bool {
 		return false
 	}
 
+	// A remote bank is up while any of its servers is
+	if o.IsRemote() {
+		_, err := o.remoteEndpoint(false)
+		return err == nil
+	}
+
 	o.mu.RLock()
 	defer o.mu.RUnlock()
 
@@ -377,6 +449,8 @@ func (o *OllamaService) GetStatus() *OllamaStatus {
 		Health:       "unknown",
 		DefaultModel: o.config.DefaultModel,
 		Models:       []string{},
+		Remote:       o.IsRemote(),
+		Endpoints:    o.Endpoints(),
 	}
 
 	if status.Running {
@@ -416,22 +490,108 @@ func (o *OllamaService) createServiceConfig() *containers.Service {
 		},
 	}
 
-	// Add GPU support if enabled
+	// The device flags themselves are added by launchWithGPU
 	if o.config.GPUEnabled {
-		log.Printf("OllamaService: GPU support enabled for %s", o.config.DefaultModel)
-		// Note: GPU support would require Docker runtime configuration
-		// For now, we'll set environment variables that Ollama can use
-		service.Env["CUDA_VISIBLE_DEVICES"] = "0"
-		service.Env["NVIDIA_VISIBLE_DEVICES"] = "all"
-		service.Env["NVIDIA_DRIVER_CAPABILITIES"] = "compute,utility"
-		// TODO: Add proper GPU runtime support when containers package supports it
-	} else {
-		log.Printf("OllamaService: Running in CPU-only mode")
+		gpu := o.GPU()
+		service.Image = gpu.OllamaImage()
+		if gpu.Vendor == GPUVendorNVIDIA {
+			service.Env["NVIDIA_VISIBLE_DEVICES"] = "all"
+			service.Env["NVIDIA_DRIVER_CAPABILITIES"] = "compute,utility"
+		}
 	}
 
 	return service
 }
 
+// configureGPU decides whether the container gets the host's GPUs: a usable
+// GPU is used unless GPU_ENABLED=false, and anything else runs on the CPU
+func (o *OllamaService) configureGPU() {
+	gpu := o.GPU()
+	o.config.GPUEnabled = false
+
+	switch {
+	case os.Getenv("GPU_ENABLED") == "false":
+		log.Println("OllamaService: GPU disabled by GPU_ENABLED=false, running on CPU")
+	case gpu.Usable:
+		o.config.GPUEnabled = true
+		log.Printf("OllamaService: Using %d %s GPU(s), driver %s", len(gpu.Devices), gpu.Vendor, gpu.Driver)
+	case gpu.Found():
+		log.Printf("OllamaService: Found a %s GPU but %s, running on CPU", gpu.Vendor, gpu.Reason)
+	case os.Getenv("GPU_ENABLED") == "true":
+		log.Println("OllamaService: GPU_ENABLED=true but no GPU was found, running on CPU")
+	default:
+		log.Println("OllamaService: No GPU found, running on CPU")
+	}
+}
+
+// launch starts the container, on the GPU when one is usable and on the
+// CPU when there is none or the GPU container fails to start
+func (o *OllamaService) launch() error {
+	o.configureGPU()
+	o.service = o.createServiceConfig()
+
+	if o.config.GPUEnabled {
+		err := o.launchWithGPU()
+		if err == nil {
+			return nil
+		}
+		log.Printf("OllamaService: Failed to start with the GPU, falling back to CPU: %v", err)
+		o.config.GPUEnabled = false
+		o.service = o.createServiceConfig()
+	}
+
+	if err := containers.Launch(containers.Local(), o.service); err != nil {
+		return errors.Wrap(err, "failed to launch Ollama service")
+	}
+	return nil
+}
+
+// launchWithGPU runs the container with docker directly, since the
+// containers package can't pass device flags
+func (o *OllamaService) launchWithGPU() error {
+	exec.Command("docker", "rm", "-f", o.service.Name).Run()
+
+	args := []string{"run", "-d",
+		"--name", o.service.Name,
+		"--network", o.service.Network,
+		"--restart", o.service.RestartPolicy,
+	}
+	for source, target := range o.service.Mounts {
+		args = append(args, "-v", source+":"+target)
+	}
+	for key, value := range o.service.Env {
+		args = append(args, "-e", key+"="+value)
+	}
+	args = append(args, o.GPU().DockerArgs()...)
+	args = append(args, o.service.Image)
+
+	if output, err := exec.Command("docker", args...).CombinedOutput(); err != nil {
+		exec.Command("docker", "rm", "-f", o.service.Name).Run()
+		return errors.Errorf("%v: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// containerHasGPU reports whether a container was started with GPU access
+func containerHasGPU(name string) bool {
+	output, err := exec.Command("docker", "inspect", "-f", "{{json .HostConfig.DeviceRequests}} {{json .HostConfig.Devices}}", name).Output()
+	if err != nil {
+		return false
+	}
+	return strings.Contains(string(output), `"gpu"`) || strings.Contains(string(output), "/dev/kfd")
+}
+
+// GPU returns the GPUs found on the host, detecting them on first use
+func (o *OllamaService) GPU() *GPUInfo {
+	o.gpuMu.Lock()
+	defer o.gpuMu.Unlock()
+
+	if o.gpu == nil {
+		o.gpu = DetectGPU()
+	}
+	return o.gpu
+}
+
 // healthCheck performs a health check on the service
 func (o *OllamaService) healthCheck() error {
 	resp, err := o.httpRequest("GET", "/api/tags", nil)
@@ -449,12 +609,25 @@ func (o *OllamaService) healthCheck() error {
 
 // httpRequest makes an HTTP request to the Ollama service
 func (o *OllamaService) httpRequest(method, path string, body io.Reader) (*http.Response, error) {
+	return o.httpRequestContext(context.Background(), method, path, body)
+}
+
+// httpRequestContext makes an HTTP request to the Ollama service that is aborted when ctx is cancelled
+func (o *OllamaService) httpRequestContext(ctx context.Context, method, path string, body io.Reader) (*http.Response, error) {
 	if !o.IsRunning() {
 		return nil, errors.New("Ollama service is not running")
 	}
 
-	url := fmt.Sprintf("http://localhost:%d%s", o.config.Port, path)
-	req, err := http.NewRequest(method, url, body)
+	baseURL := fmt.Sprintf("http://localhost:%d", o.config.Port)
+	remote := o.IsRemote()
+	if remote {
+		var err error
+		if baseURL, err = o.remoteEndpoint(isInferencePath(path)); err != nil {
+			return nil, err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, baseURL+path, body)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to create request")
 	}
@@ -463,26 +636,22 @@ func (o *OllamaService) httpRequest(method, path string, body io.Reader) (*http.
 		req.Header.Set("Content-Type", "application/json")
 	}
 
-	return o.client.Do(req)
+	resp, err := o.client.Do(req)
+	if err != nil && remote && ctx.Err() == nil {
+		o.markUnreachable(baseURL, err)
+	}
+	return resp, err
 }
 
 // ListModels returns a list of installed models
 func (o *OllamaService) ListModels() ([]string, error) {
-	resp, err := o.httpRequest("GET", "/api/tags", nil)
+	installed, err := o.ListModelInfo()
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to list models")
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	var result struct {
-		Models []OllamaModelInfo `json:"models"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, errors.Wrap(err, "failed to decode response")
-	}
-
-	models := make([]string, len(result.Models))
-	for i, model := range result.Models {
+	models := make([]string, len(installed))
+	for i, model := range installed {
 		models[i] = model.Name
 	}
 
@@ -491,6 +660,21 @@ func (o *OllamaService) ListModels() ([]string, error) {
 
 // PullModel pulls a model from the Ollama registry with streaming progress
 func (o *OllamaService) PullModel(modelName string) error {
+	return o.pullModel(modelName, nil)
+}
+
+// ollamaPullStatus is one progress line of a streamed pull
+type ollamaPullStatus struct {
+	Status    string `json:"status"`
+	Digest    string `json:"digest"`
+	Total     int64  `json:"total"`
+	Completed int64  `json:"completed"`
+	Error     string `json:"error"`
+}
+
+// pullModel pulls a model, calling progress with each status Ollama streams
+// back when it isn't nil
+func (o *OllamaService) pullModel(modelName string, progress func(ollamaPullStatus)) error {
 	log.Printf("OllamaService: Pulling model %s...", modelName)
 
 	payload := map[string]any{
@@ -516,41 +700,45 @@ func (o *OllamaService) PullModel(modelName string) error {
 	progressCount := 0
 
 	for {
-		var status map[string]any
+		var status ollamaPullStatus
 		if err := decoder.Decode(&status); err != nil {
 			if err == io.EOF {
 				break
 			}
-			// Don't fail on decode errors, just log and continue
-			log.Printf("OllamaService: Warning - decode error: %v", err)
-			continue
+			// A broken stream can't be resynced, so give up on it
+			return errors.Wrap(err, "failed to read pull progress")
+		}
+
+		// Check for errors in response
+		if status.Error != "" {
+			return fmt.Errorf("pull failed: %s", status.Error)
+		}
+
+		if progress != nil {
+			progress(status)
 		}
 
 		// Log progress periodically to avoid spam
-		if statusMsg, ok := status["status"].(string); ok {
-			if statusMsg != lastStatus {
-				log.Printf("OllamaService: %s", statusMsg)
-				lastStatus = statusMsg
-				progressCount = 0
-			} else {
-				progressCount++
-				// Show dots for same status to indicate progress
-				if progressCount%10 == 0 {
-					log.Printf("OllamaService: ... still %s", statusMsg)
-				}
+		if status.Status != lastStatus {
+			log.Printf("OllamaService: %s", status.Status)
+			lastStatus = status.Status
+			progressCount = 0
+		} else {
+			progressCount++
+			// Show dots for same status to indicate progress
+			if progressCount%10 == 0 {
+				log.Printf("OllamaService: ... still %s", status.Status)
 			}
 		}
 
-		// Check for completion
-		if completed, ok := status["completed"].(bool); ok && completed {
-			log.Printf("OllamaService: Model %s pull completed", modelName)
+		// Ollama ends a successful pull with a "success" status
+		if status.Status == "success" {
 			break
 		}
+	}
 
-		// Check for errors in response
-		if errMsg, ok := status["error"].(string); ok && errMsg != "" {
-			return fmt.Errorf("pull failed: %s", errMsg)
-		}
+	if lastStatus != "success" {
+		return fmt.Errorf("pull of %s ended before it completed", modelName)
 	}
 
 	log.Printf("OllamaService: Model %s pulled successfully", modelName)
@@ -588,10 +776,17 @@ func (o *OllamaService) Chat(modelName string, messages []OllamaMessage, stream
 		modelName = o.config.DefaultModel
 	}
 
+	release, err := o.acquireChat(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
 	request := OllamaChatRequest{
-		Model:    modelName,
-		Messages: messages,
-		Stream:   stream,
+		Model:     modelName,
+		Messages:  messages,
+		Stream:    stream,
+		KeepAlive: o.keepAliveParam(),
 		// Let Ollama use its default context size (8192 for Llama 3.2)
 	}
 
@@ -653,20 +848,78 @@ func (o *OllamaService) Chat(modelName string, messages []OllamaMessage, stream
 	return &response, nil
 }
 
+// DefaultEmbeddingModel is used for semantic code search unless
+// EMBEDDING_MODEL names another model
+const DefaultEmbeddingModel = "nomic-embed-text"
+
+// EmbeddingModel returns the model used to embed code and queries
+func (o *OllamaService) EmbeddingModel() string {
+	if model := os.Getenv("EMBEDDING_MODEL"); model != "" {
+		return model
+	}
+	return DefaultEmbeddingModel
+}
+
+// Embed returns an embedding vector for each input, in order
+func (o *OllamaService) Embed(ctx context.Context, modelName string, inputs []string) ([][]float32, error) {
+	if modelName == "" {
+		modelName = o.EmbeddingModel()
+	}
+
+	body, err := json.Marshal(map[string]any{
+		"model":      modelName,
+		"input":      inputs,
+		"keep_alive": o.keepAliveParam(),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal request")
+	}
+
+	resp, err := o.httpRequestContext(ctx, "POST", "/api/embed", bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to send embed request")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("embed request failed: status %d, body: %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	var result struct {
+		Embeddings [][]float32 `json:"embeddings"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, errors.Wrap(err, "failed to decode response")
+	}
+	if len(result.Embeddings) != len(inputs) {
+		return nil, fmt.Errorf("embed request returned %d vectors for %d inputs", len(result.Embeddings), len(inputs))
+	}
+	return result.Embeddings, nil
+}
+
 // ChatWithTools sends a chat request with tool definitions to Ollama
-func (o *OllamaService) ChatWithTools(modelName string, messages []OllamaMessage, tools []OllamaTool, stream bool) (*OllamaChatResponse, error) {
+func (o *OllamaService) ChatWithTools(ctx context.Context, modelName string, messages []OllamaMessage, tools []OllamaTool, stream bool) (*OllamaChatResponse, error) {
 	startTime := time.Now()
 	if modelName == "" {
 		modelName = o.config.DefaultModel
 	}
 
-	log.Printf("OllamaService: ChatWithTools called with %d messages, %d tools", len(messages), len(tools))
+	logger := middleware.For(ctx, "ollama").With("model", modelName)
+	logger.Debug("chat with tools", "messages", len(messages), "tools", len(tools))
+
+	release, err := o.acquireChat(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
 
 	request := OllamaChatRequest{
-		Model:    modelName,
-		Messages: messages,
-		Stream:   stream,
-		Tools:    tools, // Include tool definitions
+		Model:     modelName,
+		Messages:  messages,
+		Stream:    stream,
+		Tools:     tools, // Include tool definitions
+		KeepAlive: o.keepAliveParam(),
 		// Let Ollama use its default context size (8192 for Llama 3.2)
 	}
 
@@ -675,12 +928,11 @@ func (o *OllamaService) ChatWithTools(modelName string, messages []OllamaMessage
 		return nil, errors.Wrap(err, "failed to marshal request")
 	}
 
-	log.Printf("OllamaService: Request body size: %d bytes", len(body))
-	log.Printf("OllamaService: Sending HTTP request to Ollama at %v", time.Now())
+	logger.Debug("sending chat request", "bytes", len(body))
 
-	resp, err := o.httpRequest("POST", "/api/chat", bytes.NewReader(body))
+	resp, err := o.httpRequestContext(ctx, "POST", "/api/chat", bytes.NewReader(body))
 	httpDuration := time.Since(startTime)
-	log.Printf("OllamaService: HTTP request completed after %v", httpDuration)
+	logger.Debug("chat request completed", "took", httpDuration)
 
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to send chat request")
@@ -704,31 +956,28 @@ func (o *OllamaService) ChatWithTools(modelName string, messages []OllamaMessage
 	}
 
 	// Log raw response for debugging empty responses
-	log.Printf("OllamaService: Raw response body length: %d bytes", len(bodyBytes))
 	if len(bodyBytes) < 1000 {
 		// Log small responses entirely
-		log.Printf("OllamaService: Raw response: %s", string(bodyBytes))
+		logger.Debug("raw response", "bytes", len(bodyBytes), "body", string(bodyBytes))
 	} else {
 		// Log first 500 chars of large responses
-		log.Printf("OllamaService: Raw response (first 500 chars): %s...", string(bodyBytes[:500]))
+		logger.Debug("raw response", "bytes", len(bodyBytes), "body", string(bodyBytes[:500])+"...")
 	}
 
 	var response OllamaChatResponse
 	if err := json.Unmarshal(bodyBytes, &response); err != nil {
-		log.Printf("OllamaService: Failed to unmarshal response: %v", err)
-		log.Printf("OllamaService: Response that failed to parse: %s", string(bodyBytes))
+		logger.Error("failed to unmarshal response", "error", err, "body", string(bodyBytes))
 		return nil, errors.Wrap(err, "failed to decode response")
 	}
 
 	// Log parsed response details
-	log.Printf("OllamaService: Parsed response - Message content length: %d", len(response.Message.Content))
+	logger.Debug("parsed response", "content_length", len(response.Message.Content), "tool_calls", len(response.Message.ToolCalls))
 	if len(response.Message.ToolCalls) > 0 {
-		log.Printf("OllamaService: Response contains %d tool calls", len(response.Message.ToolCalls))
 		for i, tc := range response.Message.ToolCalls {
-			log.Printf("OllamaService: Tool call %d: %s with args %s", i+1, tc.Function.Name, tc.Function.Arguments)
+			logger.Debug("tool call", "index", i+1, "tool", tc.Function.Name, "args", string(tc.Function.Arguments))
 		}
 	} else if response.Message.Content != "" {
-		log.Printf("OllamaService: Response content: %q", response.Message.Content)
+		logger.Debug("response content", "content", response.Message.Content)
 	}
 
 	return &response, nil
@@ -736,14 +985,29 @@ func (o *OllamaService) ChatWithTools(modelName string, messages []OllamaMessage
 
 // StreamChat sends a streaming chat request to Ollama
 func (o *OllamaService) StreamChat(modelName string, messages []OllamaMessage, callback func(chunk *OllamaChatResponse) error) error {
+	return o.StreamChatWithTools(context.Background(), modelName, messages, nil, callback)
+}
+
+// StreamChatWithTools sends a streaming chat request with tool definitions to Ollama.
+// The callback receives each chunk as it is decoded, including partial tool calls.
+// Cancelling ctx closes the connection, which also stops generation in Ollama.
+func (o *OllamaService) StreamChatWithTools(ctx context.Context, modelName string, messages []OllamaMessage, tools []OllamaTool, callback func(chunk *OllamaChatResponse) error) error {
 	if modelName == "" {
 		modelName = o.config.DefaultModel
 	}
 
+	release, err := o.acquireChat(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
 	request := OllamaChatRequest{
-		Model:    modelName,
-		Messages: messages,
-		Stream:   true,
+		Model:     modelName,
+		Messages:  messages,
+		Stream:    true,
+		Tools:     tools,
+		KeepAlive: o.keepAliveParam(),
 		// Let Ollama use its default context size (8192 for Llama 3.2)
 	}
 
@@ -752,7 +1016,7 @@ func (o *OllamaService) StreamChat(modelName string, messages []OllamaMessage, c
 		return errors.Wrap(err, "failed to marshal request")
 	}
 
-	resp, err := o.httpRequest("POST", "/api/chat", bytes.NewReader(body))
+	resp, err := o.httpRequestContext(ctx, "POST", "/api/chat", bytes.NewReader(body))
 	if err != nil {
 		return errors.Wrap(err, "failed to send chat request")
 	}
@@ -760,7 +1024,12 @@ func (o *OllamaService) StreamChat(modelName string, messages []OllamaMessage, c
 
 	if resp.StatusCode != http.StatusOK {
 		bodyBytes, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("chat request failed: status %d, body: %s", resp.StatusCode, string(bodyBytes))
+		errMsg := string(bodyBytes)
+		// Check for memory-related errors
+		if strings.Contains(errMsg, "insufficient memory") || strings.Contains(errMsg, "model requires more") {
+			return fmt.Errorf("AI model requires more memory than available. Please upgrade to a larger server or use external AI services")
+		}
+		return fmt.Errorf("chat request failed: status %d, body: %s", resp.StatusCode, errMsg)
 	}
 
 	// Read streaming response
@@ -827,6 +1096,12 @@ func (o *OllamaService) ensureDefaultModel() {
 			}
 		}
 
+		// Models on shared servers are left to whoever runs them
+		if !hasDefault && o.IsRemote() {
+			log.Printf("OllamaService: ⚠️  Model %s is not installed on the remote server, pull it there or from Settings → AI Models", o.config.DefaultModel)
+			return
+		}
+
 		if !hasDefault {
 			log.Printf("OllamaService: Downloading %s (this may take a few minutes)...", o.config.DefaultModel)
 			startTime := time.Now()
@@ -847,7 +1122,8 @@ func (o *OllamaService) ensureDefaultModel() {
 			log.Printf("OllamaService: ✓ Model %s ready (downloaded in %.1fs)", o.config.DefaultModel, pullDuration.Seconds())
 		}
 
-		// Success!
+		// Success! Load it now rather than on the first request
+		o.warmup()
 		return
 	}
 
@@ -868,6 +1144,10 @@ func (o *OllamaService) GetServiceInfo() map[string]any {
 		"port":          o.config.Port,
 		"default_model": o.config.DefaultModel,
 		"gpu_enabled":   o.config.GPUEnabled,
+		"remote":        o.IsRemote(),
+	}
+	if o.IsRemote() {
+		info["endpoints"] = o.Endpoints()
 	}
 
 	if o.IsRunning() {
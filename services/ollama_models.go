@@ -0,0 +1,172 @@
+package services
+
+import (
+	"encoding/json"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/The-Skyscape/devtools/pkg/containers"
+	"github.com/pkg/errors"
+)
+
+// ModelPull is the progress of a model download started from the settings
+// page. Total and Completed are in bytes of the layer being downloaded.
+type ModelPull struct {
+	Model      string
+	Status     string
+	Total      int64
+	Completed  int64
+	Error      string
+	Done       bool
+	StartedAt  time.Time
+	FinishedAt time.Time
+}
+
+// Percent returns how much of the current layer has been downloaded
+func (p ModelPull) Percent() int {
+	if p.Done && p.Error == "" {
+		return 100
+	}
+	if p.Total <= 0 {
+		return 0
+	}
+	return int(p.Completed * 100 / p.Total)
+}
+
+// Progress describes the download's progress in bytes
+func (p ModelPull) Progress() string {
+	if p.Total <= 0 {
+		return ""
+	}
+	return containers.FormatBytes(uint64(p.Completed)) + " of " + containers.FormatBytes(uint64(p.Total))
+}
+
+// SizeLabel returns the model's size on disk for display
+func (m OllamaModelInfo) SizeLabel() string {
+	return containers.FormatBytes(uint64(m.Size))
+}
+
+// ListModelInfo returns the installed models with their size and details,
+// sorted by name
+func (o *OllamaService) ListModelInfo() ([]OllamaModelInfo, error) {
+	resp, err := o.httpRequest("GET", "/api/tags", nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list models")
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Models []OllamaModelInfo `json:"models"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, errors.Wrap(err, "failed to decode response")
+	}
+
+	sort.Slice(result.Models, func(i, j int) bool {
+		return result.Models[i].Name < result.Models[j].Name
+	})
+	return result.Models, nil
+}
+
+// HasModel reports whether a model is installed, treating a name without a
+// tag as the latest tag like Ollama does
+func (o *OllamaService) HasModel(name string) bool {
+	installed, err := o.ListModels()
+	if err != nil {
+		return false
+	}
+	for _, model := range installed {
+		if sameModel(model, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsDefaultModel reports whether name is the default model
+func (o *OllamaService) IsDefaultModel(name string) bool {
+	return sameModel(name, o.GetDefaultModel())
+}
+
+// sameModel compares model names, filling in the latest tag when missing
+func sameModel(a, b string) bool {
+	if !strings.Contains(a, ":") {
+		a += ":latest"
+	}
+	if !strings.Contains(b, ":") {
+		b += ":latest"
+	}
+	return a == b
+}
+
+// StartPull downloads a model in the background and returns its progress.
+// A model that is already downloading isn't pulled twice.
+func (o *OllamaService) StartPull(name string) (ModelPull, error) {
+	if !o.IsRunning() {
+		return ModelPull{}, errors.New("Ollama service is not running")
+	}
+
+	o.pullMu.Lock()
+	defer o.pullMu.Unlock()
+
+	if pull, ok := o.pulls[name]; ok && !pull.Done {
+		return *pull, nil
+	}
+	if o.pulls == nil {
+		o.pulls = map[string]*ModelPull{}
+	}
+	pull := &ModelPull{Model: name, Status: "starting", StartedAt: time.Now()}
+	o.pulls[name] = pull
+
+	go func() {
+		err := o.pullModel(name, func(status ollamaPullStatus) {
+			o.pullMu.Lock()
+			defer o.pullMu.Unlock()
+			pull.Status = status.Status
+			pull.Total = status.Total
+			pull.Completed = status.Completed
+		})
+
+		o.pullMu.Lock()
+		defer o.pullMu.Unlock()
+		pull.Done = true
+		pull.FinishedAt = time.Now()
+		if err != nil {
+			log.Printf("OllamaService: Failed to pull %s: %v", name, err)
+			pull.Error = err.Error()
+		}
+	}()
+
+	return *pull, nil
+}
+
+// PullStatus returns the progress of a model download started with StartPull
+func (o *OllamaService) PullStatus(name string) (ModelPull, bool) {
+	o.pullMu.Lock()
+	defer o.pullMu.Unlock()
+
+	pull, ok := o.pulls[name]
+	if !ok {
+		return ModelPull{}, false
+	}
+	return *pull, true
+}
+
+// ActivePulls returns the downloads still in progress, oldest first
+func (o *OllamaService) ActivePulls() []ModelPull {
+	o.pullMu.Lock()
+	defer o.pullMu.Unlock()
+
+	var pulls []ModelPull
+	for _, pull := range o.pulls {
+		if !pull.Done {
+			pulls = append(pulls, *pull)
+		}
+	}
+	sort.Slice(pulls, func(i, j int) bool {
+		return pulls[i].StartedAt.Before(pulls[j].StartedAt)
+	})
+	return pulls
+}
@@ -0,0 +1,281 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultKeepAlive is how long Ollama keeps a model in memory after its last
+// request, unless OLLAMA_KEEP_ALIVE says otherwise. Ollama's own default of
+// five minutes means most conversations start with a cold load.
+const DefaultKeepAlive = "30m"
+
+// preloadTimeout bounds loading a model, which can take minutes on a CPU
+const preloadTimeout = 10 * time.Minute
+
+// LoadedModel is a model an Ollama server holds in memory
+type LoadedModel struct {
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	SizeVRAM  int64     `json:"size_vram"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Server    string    `json:"-"` // Remote server holding it, empty for the container
+}
+
+// Processor says where the model runs, as ollama ps does
+func (m LoadedModel) Processor() string {
+	switch {
+	case m.SizeVRAM == 0:
+		return "CPU"
+	case m.SizeVRAM >= m.Size:
+		return "GPU"
+	}
+	return fmt.Sprintf("%d%% GPU", m.SizeVRAM*100/m.Size)
+}
+
+// KeptForever reports whether the model stays loaded until it is unloaded
+func (m LoadedModel) KeptForever() bool {
+	return m.ExpiresAt.After(time.Now().AddDate(10, 0, 0))
+}
+
+// ParseKeepAlive checks a keep-alive in Ollama's format: a duration such as
+// 30m or 2h, a number of seconds, a negative value to keep models loaded
+// until they are unloaded, or 0 to unload them after each request. Empty
+// uses DefaultKeepAlive.
+func ParseKeepAlive(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", nil
+	}
+	if _, err := strconv.Atoi(value); err == nil {
+		return value, nil
+	}
+	if _, err := time.ParseDuration(value); err != nil {
+		return "", fmt.Errorf("%q is not a duration such as 30m, a number of seconds, or -1", value)
+	}
+	return value, nil
+}
+
+// KeepAlive returns how long models stay loaded after their last request
+func (o *OllamaService) KeepAlive() string {
+	if value, err := ParseKeepAlive(os.Getenv("OLLAMA_KEEP_ALIVE")); err == nil && value != "" {
+		return value
+	}
+	return DefaultKeepAlive
+}
+
+// keepAliveParam returns KeepAlive as Ollama's API takes it: plain numbers
+// are seconds and must be sent as JSON numbers
+func (o *OllamaService) keepAliveParam() any {
+	keepAlive := o.KeepAlive()
+	if seconds, err := strconv.Atoi(keepAlive); err == nil {
+		return seconds
+	}
+	return keepAlive
+}
+
+// serverURLs returns the base URL of every Ollama server requests may go to,
+// so a model can be loaded on each server in a bank
+func (o *OllamaService) serverURLs() []string {
+	if !o.IsRemote() {
+		return []string{fmt.Sprintf("http://localhost:%d", o.config.Port)}
+	}
+	var urls []string
+	for _, endpoint := range o.Endpoints() {
+		if endpoint.Healthy {
+			urls = append(urls, endpoint.URL)
+		}
+	}
+	return urls
+}
+
+// Preload loads a model into memory on every server, so the next request
+// doesn't wait for it. It stays loaded for KeepAlive.
+func (o *OllamaService) Preload(ctx context.Context, modelName string) error {
+	return o.setLoaded(ctx, modelName, o.keepAliveParam())
+}
+
+// StartPreload loads a model in the background, since a cold load can take
+// minutes. A model that is already loading isn't loaded twice.
+func (o *OllamaService) StartPreload(modelName string) error {
+	if !o.IsRunning() {
+		return errors.New("Ollama service is not running")
+	}
+	if modelName == "" {
+		modelName = o.GetDefaultModel()
+	}
+
+	o.loadMu.Lock()
+	defer o.loadMu.Unlock()
+	if o.loading[modelName] {
+		return nil
+	}
+	if o.loading == nil {
+		o.loading = map[string]bool{}
+	}
+	o.loading[modelName] = true
+
+	go func() {
+		start := time.Now()
+		if err := o.Preload(context.Background(), modelName); err != nil {
+			log.Printf("OllamaService: Failed to preload %s: %v", modelName, err)
+		} else {
+			log.Printf("OllamaService: Model %s preloaded in %.1fs", modelName, time.Since(start).Seconds())
+		}
+		o.loadMu.Lock()
+		delete(o.loading, modelName)
+		o.loadMu.Unlock()
+	}()
+	return nil
+}
+
+// IsModelLoading reports whether a model is being loaded by StartPreload
+func (o *OllamaService) IsModelLoading(name string) bool {
+	o.loadMu.Lock()
+	defer o.loadMu.Unlock()
+	for model := range o.loading {
+		if sameModel(model, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Unload frees the memory a model holds on every server
+func (o *OllamaService) Unload(ctx context.Context, modelName string) error {
+	return o.setLoaded(ctx, modelName, 0)
+}
+
+// setLoaded sends a request without a prompt, which only loads or unloads
+// the model, to every server
+func (o *OllamaService) setLoaded(ctx context.Context, modelName string, keepAlive any) error {
+	if !o.IsRunning() {
+		return errors.New("Ollama service is not running")
+	}
+	if modelName == "" {
+		modelName = o.GetDefaultModel()
+	}
+	body, err := json.Marshal(map[string]any{"model": modelName, "keep_alive": keepAlive})
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal request")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, preloadTimeout)
+	defer cancel()
+
+	var failures []string
+	urls := o.serverURLs()
+	for _, baseURL := range urls {
+		if err := o.postTo(ctx, baseURL, "/api/generate", body); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", baseURL, err))
+		}
+	}
+	if len(failures) > 0 {
+		if len(urls) == 1 {
+			return errors.New(strings.TrimPrefix(failures[0], urls[0]+": "))
+		}
+		return fmt.Errorf("failed on %d of %d servers (%s)", len(failures), len(urls), strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// postTo sends a request to one server and discards the response
+func (o *OllamaService) postTo(ctx context.Context, baseURL, path string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	_, err = io.Copy(io.Discard, resp.Body)
+	return err
+}
+
+// LoadedModels returns the models held in memory on every server
+func (o *OllamaService) LoadedModels() ([]LoadedModel, error) {
+	if !o.IsRunning() {
+		return nil, nil
+	}
+
+	var loaded []LoadedModel
+	for _, baseURL := range o.serverURLs() {
+		models, err := o.loadedOn(baseURL)
+		if err != nil && !o.IsRemote() {
+			return nil, err
+		}
+		// A server in a bank that stopped answering shows nothing loaded
+		for _, model := range models {
+			if o.IsRemote() {
+				model.Server = baseURL
+			}
+			loaded = append(loaded, model)
+		}
+	}
+	return loaded, nil
+}
+
+// loadedOn lists the models one server holds in memory
+func (o *OllamaService) loadedOn(baseURL string) ([]LoadedModel, error) {
+	client := &http.Client{Timeout: remoteCheckTimeout}
+	resp, err := client.Get(baseURL + "/api/ps")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list loaded models")
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Models []LoadedModel `json:"models"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, errors.Wrap(err, "failed to decode loaded models")
+	}
+	return result.Models, nil
+}
+
+// IsModelLoaded reports whether any server holds the model in memory
+func (o *OllamaService) IsModelLoaded(name string) bool {
+	loaded, _ := o.LoadedModels()
+	for _, model := range loaded {
+		if sameModel(model.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// warmup loads the default model once it is installed, so the first
+// conversation after a start doesn't wait minutes for it
+func (o *OllamaService) warmup() {
+	// Models that unload after every request aren't worth loading early
+	if duration, err := time.ParseDuration(o.KeepAlive()); o.KeepAlive() == "0" || (err == nil && duration == 0) {
+		return
+	}
+	model := o.GetDefaultModel()
+	start := time.Now()
+	if err := o.Preload(context.Background(), model); err != nil {
+		log.Printf("OllamaService: Failed to warm up %s: %v", model, err)
+		return
+	}
+	log.Printf("OllamaService: ✓ Model %s loaded in %.1fs, kept for %s", model, time.Since(start).Seconds(), o.KeepAlive())
+}
@@ -0,0 +1,166 @@
+package services
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Chat concurrency defaults, unless OLLAMA_MAX_CHATS and OLLAMA_MAX_QUEUE
+// say otherwise. A single Ollama instance slows every request down when it
+// answers more than a couple at once, until they all time out.
+const (
+	DefaultMaxChats = 2
+	DefaultMaxQueue = 16
+)
+
+// ErrQueueFull is returned when more chats are waiting than the queue holds
+var ErrQueueFull = errors.New("the AI service is busy, try again in a moment")
+
+// ChatQueueStatus describes the chats running and waiting for Ollama
+type ChatQueueStatus struct {
+	Running  int
+	Waiting  int
+	MaxChats int
+	MaxQueue int
+}
+
+// Full reports whether a new chat would be refused
+func (s ChatQueueStatus) Full() bool {
+	return s.Running >= s.MaxChats && s.Waiting >= s.MaxQueue
+}
+
+// chatQueue admits chats in the order they arrive, no more than the limit at
+// a time
+type chatQueue struct {
+	mu      sync.Mutex
+	running int
+	waiting []*chatTicket
+}
+
+// chatTicket is a chat waiting for its turn
+type chatTicket struct {
+	ready chan struct{}
+	moved chan int // Latest position, read by the waiting chat
+}
+
+// queueWaiterKey carries a chat's position callback in its context
+type queueWaiterKey struct{}
+
+// WithQueueUpdates returns a context whose chats call onWait with their
+// position in the queue, starting at 1, each time it changes while they wait
+func WithQueueUpdates(ctx context.Context, onWait func(position int)) context.Context {
+	return context.WithValue(ctx, queueWaiterKey{}, onWait)
+}
+
+// envLimit reads a positive limit from the environment
+func envLimit(name string, fallback int) int {
+	if value, err := strconv.Atoi(os.Getenv(name)); err == nil && value > 0 {
+		return value
+	}
+	return fallback
+}
+
+// MaxChats returns how many chats Ollama answers at once
+func (o *OllamaService) MaxChats() int {
+	return envLimit("OLLAMA_MAX_CHATS", DefaultMaxChats)
+}
+
+// MaxQueue returns how many chats may wait before new ones are refused
+func (o *OllamaService) MaxQueue() int {
+	return envLimit("OLLAMA_MAX_QUEUE", DefaultMaxQueue)
+}
+
+// QueueStatus returns the chats running and waiting
+func (o *OllamaService) QueueStatus() ChatQueueStatus {
+	o.queue.mu.Lock()
+	defer o.queue.mu.Unlock()
+	return ChatQueueStatus{
+		Running:  o.queue.running,
+		Waiting:  len(o.queue.waiting),
+		MaxChats: o.MaxChats(),
+		MaxQueue: o.MaxQueue(),
+	}
+}
+
+// acquireChat waits for a turn to chat, returning a func that ends it. It
+// fails with ErrQueueFull when the queue is full, or when ctx is cancelled
+// before the turn comes.
+func (o *OllamaService) acquireChat(ctx context.Context) (func(), error) {
+	q := &o.queue
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			q.mu.Lock()
+			defer q.mu.Unlock()
+			q.running--
+			q.admit(o.MaxChats())
+		})
+	}
+
+	q.mu.Lock()
+	if q.running < o.MaxChats() && len(q.waiting) == 0 {
+		q.running++
+		q.mu.Unlock()
+		return release, nil
+	}
+	if len(q.waiting) >= o.MaxQueue() {
+		q.mu.Unlock()
+		return nil, ErrQueueFull
+	}
+	ticket := &chatTicket{ready: make(chan struct{}), moved: make(chan int, 1)}
+	q.waiting = append(q.waiting, ticket)
+	position := len(q.waiting)
+	q.mu.Unlock()
+
+	onWait, _ := ctx.Value(queueWaiterKey{}).(func(int))
+	if onWait != nil {
+		onWait(position)
+	}
+	for {
+		select {
+		case <-ticket.ready:
+			return release, nil
+		case position := <-ticket.moved:
+			if onWait != nil {
+				onWait(position)
+			}
+		case <-ctx.Done():
+			q.mu.Lock()
+			for i, waiting := range q.waiting {
+				if waiting == ticket {
+					q.waiting = append(q.waiting[:i], q.waiting[i+1:]...)
+					q.admit(o.MaxChats())
+					q.mu.Unlock()
+					return nil, ctx.Err()
+				}
+			}
+			q.mu.Unlock()
+			// The turn came as the chat gave up, so pass it on
+			release()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// admit starts waiting chats while there is room and tells the rest where
+// they now stand. The caller must hold mu.
+func (q *chatQueue) admit(limit int) {
+	for q.running < limit && len(q.waiting) > 0 {
+		ticket := q.waiting[0]
+		q.waiting = q.waiting[1:]
+		q.running++
+		close(ticket.ready)
+	}
+	for i, ticket := range q.waiting {
+		// Replace a position the chat hasn't read yet
+		select {
+		case <-ticket.moved:
+		default:
+		}
+		ticket.moved <- i + 1
+	}
+}
@@ -0,0 +1,223 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// remoteCheckInterval is how often remote Ollama servers are health checked
+const remoteCheckInterval = 30 * time.Second
+
+// remoteCheckTimeout bounds one health check so an unreachable server
+// doesn't hold up the others
+const remoteCheckTimeout = 5 * time.Second
+
+// OllamaEndpoint is a remote Ollama server the workspace sends AI requests to
+type OllamaEndpoint struct {
+	URL       string
+	Healthy   bool
+	Error     string // Why the last health check failed
+	CheckedAt time.Time
+}
+
+// ParseOllamaHosts reads a comma separated list of Ollama servers in the
+// format of OLLAMA_HOST, such as gpu-1:11434 or https://ollama.example.com,
+// and returns their base URLs. Port 11434 is assumed for plain HTTP.
+func ParseOllamaHosts(value string) ([]string, error) {
+	var hosts []string
+	seen := map[string]bool{}
+	for _, field := range strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\n' || r == '\r' || r == '\t'
+	}) {
+		raw := field
+		if !strings.Contains(raw, "://") {
+			raw = "http://" + raw
+		}
+		u, err := url.Parse(raw)
+		if err != nil || u.Hostname() == "" || (u.Scheme != "http" && u.Scheme != "https") {
+			return nil, fmt.Errorf("%q is not an Ollama server address", field)
+		}
+		if u.Port() == "" && u.Scheme == "http" {
+			u.Host += ":11434"
+		}
+
+		host := u.Scheme + "://" + u.Host + strings.TrimSuffix(u.Path, "/")
+		if !seen[host] {
+			seen[host] = true
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts, nil
+}
+
+// CheckOllamaEndpoint makes sure an Ollama server answers at a base URL
+func CheckOllamaEndpoint(baseURL string) error {
+	client := &http.Client{Timeout: remoteCheckTimeout}
+	resp, err := client.Get(baseURL + "/api/tags")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unhealthy response: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// configureRemote points the service at the servers in OLLAMA_HOST, or at
+// the local container when it is empty
+func (o *OllamaService) configureRemote() error {
+	hosts, err := ParseOllamaHosts(os.Getenv("OLLAMA_HOST"))
+	if err != nil {
+		return errors.Wrap(err, "invalid OLLAMA_HOST")
+	}
+	o.SetRemoteHosts(hosts)
+	return nil
+}
+
+// SetRemoteHosts sends AI requests to remote Ollama servers instead of the
+// local container, or back to the container when hosts is empty. Servers
+// that were already in use keep their health.
+func (o *OllamaService) SetRemoteHosts(hosts []string) {
+	o.remoteMu.Lock()
+	defer o.remoteMu.Unlock()
+
+	existing := map[string]*OllamaEndpoint{}
+	for _, endpoint := range o.remotes {
+		existing[endpoint.URL] = endpoint
+	}
+
+	o.remotes = nil
+	for _, host := range hosts {
+		endpoint := existing[host]
+		if endpoint == nil {
+			endpoint = &OllamaEndpoint{URL: host, Error: "not checked yet"}
+		}
+		o.remotes = append(o.remotes, endpoint)
+	}
+	o.nextRemote = 0
+}
+
+// IsRemote returns true when AI requests go to remote Ollama servers and the
+// local container isn't managed
+func (o *OllamaService) IsRemote() bool {
+	o.remoteMu.Lock()
+	defer o.remoteMu.Unlock()
+	return len(o.remotes) > 0
+}
+
+// Endpoints returns the remote Ollama servers and their last health check
+func (o *OllamaService) Endpoints() []OllamaEndpoint {
+	o.remoteMu.Lock()
+	defer o.remoteMu.Unlock()
+
+	endpoints := make([]OllamaEndpoint, len(o.remotes))
+	for i, endpoint := range o.remotes {
+		endpoints[i] = *endpoint
+	}
+	return endpoints
+}
+
+// checkRemotes health checks every remote server at once
+func (o *OllamaService) checkRemotes() {
+	o.remoteMu.Lock()
+	endpoints := append([]*OllamaEndpoint(nil), o.remotes...)
+	o.remoteMu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, endpoint := range endpoints {
+		wg.Add(1)
+		go func(endpoint *OllamaEndpoint) {
+			defer wg.Done()
+			err := CheckOllamaEndpoint(endpoint.URL)
+
+			o.remoteMu.Lock()
+			defer o.remoteMu.Unlock()
+			if err != nil && endpoint.Healthy {
+				log.Printf("OllamaService: Remote server %s is unreachable: %v", endpoint.URL, err)
+			} else if err == nil && !endpoint.Healthy {
+				log.Printf("OllamaService: Remote server %s is reachable", endpoint.URL)
+			}
+			endpoint.Healthy = err == nil
+			endpoint.Error = ""
+			if err != nil {
+				endpoint.Error = err.Error()
+			}
+			endpoint.CheckedAt = time.Now()
+		}(endpoint)
+	}
+	wg.Wait()
+}
+
+// monitorRemotes keeps the remote servers' health current, so requests skip
+// servers that went down and return to them once they are back
+func (o *OllamaService) monitorRemotes() {
+	ticker := time.NewTicker(remoteCheckInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if o.IsRemote() {
+			o.checkRemotes()
+		}
+	}
+}
+
+// remoteEndpoint picks a healthy remote server. Inference requests rotate
+// through the servers, everything else goes to the first healthy one so
+// model lists and downloads stay on one server.
+func (o *OllamaService) remoteEndpoint(balanced bool) (string, error) {
+	o.remoteMu.Lock()
+	defer o.remoteMu.Unlock()
+
+	count := len(o.remotes)
+	if count == 0 {
+		return "", errors.New("no remote Ollama server is configured")
+	}
+
+	start := 0
+	if balanced {
+		start = o.nextRemote % count
+		o.nextRemote = (start + 1) % count
+	}
+	for i := 0; i < count; i++ {
+		if endpoint := o.remotes[(start+i)%count]; endpoint.Healthy {
+			return endpoint.URL, nil
+		}
+	}
+	return "", errors.New("no remote Ollama server is reachable")
+}
+
+// markUnreachable takes a remote server out of rotation after a failed
+// request, until the next health check finds it again
+func (o *OllamaService) markUnreachable(baseURL string, err error) {
+	o.remoteMu.Lock()
+	defer o.remoteMu.Unlock()
+
+	for _, endpoint := range o.remotes {
+		if endpoint.URL == baseURL && endpoint.Healthy {
+			log.Printf("OllamaService: Remote server %s failed a request: %v", baseURL, err)
+			endpoint.Healthy = false
+			endpoint.Error = err.Error()
+			endpoint.CheckedAt = time.Now()
+		}
+	}
+}
+
+// isInferencePath reports whether an API path runs a model, which can be
+// sent to any server in a bank
+func isInferencePath(path string) bool {
+	switch path {
+	case "/api/chat", "/api/generate", "/api/embed", "/api/embeddings":
+		return true
+	}
+	return false
+}
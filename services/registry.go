@@ -0,0 +1,404 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"workspace/models"
+
+	"github.com/The-Skyscape/devtools/pkg/containers"
+	"github.com/The-Skyscape/devtools/pkg/database"
+	"github.com/pkg/errors"
+)
+
+// RegistryConfig holds configuration for the registry service
+type RegistryConfig struct {
+	Port          int
+	ContainerName string
+	DataDir       string
+	GCInterval    time.Duration // How often untagged layers are removed
+}
+
+// RegistryService runs an OCI registry for images built from repositories.
+// It only listens on localhost: clients reach it through the workspace,
+// which checks their credentials and keeps each repository's images under
+// its ID, while workflows push to it directly from the host.
+type RegistryService struct {
+	config  *RegistryConfig
+	service *containers.Service
+	client  *http.Client
+	proxy   *httputil.ReverseProxy
+	mu      sync.Mutex
+
+	// Pushes hold the read lock and garbage collection the write lock, as
+	// collecting during a push can delete the layers it just uploaded
+	gcMu   sync.RWMutex
+	gcOnce sync.Once
+	lastGC *RegistryGC
+}
+
+// RegistryGC describes the last garbage collection
+type RegistryGC struct {
+	FinishedAt time.Time
+	Duration   time.Duration
+	Output     string
+	Error      string
+}
+
+// registryAccept lists the manifest types the registry is asked for, so it
+// returns the digest images were pushed with
+var registryAccept = strings.Join([]string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}, ", ")
+
+var (
+	// Registry is the global registry service instance
+	Registry = NewRegistryService()
+)
+
+// NewRegistryService creates a new registry service with default configuration
+func NewRegistryService() *RegistryService {
+	r := &RegistryService{
+		config: &RegistryConfig{
+			Port:          5000,
+			ContainerName: "skyscape-registry",
+			DataDir:       fmt.Sprintf("%s/registry", database.DataDir()),
+			GCInterval:    24 * time.Hour,
+		},
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+	r.proxy = httputil.NewSingleHostReverseProxy(&url.URL{Scheme: "http", Host: r.Address()})
+	r.proxy.ErrorHandler = func(w http.ResponseWriter, req *http.Request, err error) {
+		log.Printf("Registry: Proxy error for %s %s: %v", req.Method, req.URL.Path, err)
+		http.Error(w, "registry unavailable", http.StatusBadGateway)
+	}
+	return r
+}
+
+// Address is where the registry listens on the host, which Docker trusts
+// without TLS because it is local
+func (r *RegistryService) Address() string {
+	return fmt.Sprintf("127.0.0.1:%d", r.config.Port)
+}
+
+// ImageRef is what the host's Docker calls an image of a repository
+func (r *RegistryService) ImageRef(repoID, image, tag string) string {
+	return fmt.Sprintf("%s/%s/%s:%s", r.Address(), repoID, image, tag)
+}
+
+// Start launches the registry container if it isn't running. It is started
+// on demand, when an image is first pushed or pulled.
+func (r *RegistryService) Start() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.service == nil {
+		r.service = containers.Local().Service(r.config.ContainerName)
+	}
+	if r.service != nil && r.service.IsRunning() {
+		r.gcOnce.Do(func() { go r.collectPeriodically() })
+		return nil
+	}
+
+	log.Printf("Registry: Starting on %s", r.Address())
+	if err := os.MkdirAll(r.config.DataDir, 0755); err != nil {
+		return errors.Wrap(err, "failed to create registry directory")
+	}
+
+	r.service = &containers.Service{
+		Host:          containers.Local(),
+		Name:          r.config.ContainerName,
+		Image:         "registry:2",
+		Network:       "host",
+		RestartPolicy: "always",
+		Mounts: map[string]string{
+			r.config.DataDir: "/var/lib/registry",
+		},
+		Env: map[string]string{
+			"REGISTRY_HTTP_ADDR":              r.Address(),
+			"REGISTRY_HTTP_RELATIVEURLS":      "true", // Locations work behind the workspace's proxy
+			"REGISTRY_STORAGE_DELETE_ENABLED": "true",
+		},
+	}
+	if err := containers.Launch(containers.Local(), r.service); err != nil {
+		return errors.Wrap(err, "failed to launch registry")
+	}
+	if err := r.service.WaitForReady(30*time.Second, r.healthCheck); err != nil {
+		return errors.Wrap(err, "registry did not become ready")
+	}
+
+	r.gcOnce.Do(func() { go r.collectPeriodically() })
+	log.Println("Registry: Started")
+	return nil
+}
+
+// IsRunning reports whether the registry container is up
+func (r *RegistryService) IsRunning() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.service == nil {
+		r.service = containers.Local().Service(r.config.ContainerName)
+	}
+	return r.service != nil && r.service.IsRunning()
+}
+
+// healthCheck pings the registry's API
+func (r *RegistryService) healthCheck() error {
+	resp, err := r.client.Get("http://" + r.Address() + "/v2/")
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unhealthy response: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// ServeHTTP passes a registry API request on to the registry, which must
+// already be authorized. Uploads wait for garbage collection to finish.
+func (r *RegistryService) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if err := r.Start(); err != nil {
+		log.Printf("Registry: %v", err)
+		http.Error(w, "registry unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		r.gcMu.RLock()
+		defer r.gcMu.RUnlock()
+	}
+	r.proxy.ServeHTTP(w, req)
+}
+
+// Push builds an image from a directory with the host's Docker and pushes
+// it to the repository's namespace under each tag. Builds get no network
+// unless the repository's sandbox policy allows it.
+func (r *RegistryService) Push(ctx context.Context, repoID string, image *models.WorkflowImage, dir string, tags []string, output io.Writer) error {
+	if err := r.Start(); err != nil {
+		return err
+	}
+	if len(tags) == 0 {
+		return errors.New("the image has no tags")
+	}
+
+	args := []string{"build", "--pull", "-f", image.Dockerfile}
+	if !models.GetSandboxPolicy(repoID).NetworkEnabled {
+		args = append(args, "--network", "none")
+	}
+	for _, tag := range tags {
+		args = append(args, "-t", r.ImageRef(repoID, image.Name, tag))
+	}
+	args = append(args, image.Context)
+
+	build := exec.CommandContext(ctx, "docker", args...)
+	build.Dir = dir
+	build.Stdout, build.Stderr = output, output
+	if err := build.Run(); err != nil {
+		return errors.Wrap(err, "failed to build the image")
+	}
+
+	// Built images are kept in the registry, not the host's image store
+	defer func() {
+		remove := []string{"rmi"}
+		for _, tag := range tags {
+			remove = append(remove, r.ImageRef(repoID, image.Name, tag))
+		}
+		exec.Command("docker", remove...).Run()
+	}()
+
+	r.gcMu.RLock()
+	defer r.gcMu.RUnlock()
+	for _, tag := range tags {
+		ref := r.ImageRef(repoID, image.Name, tag)
+		fmt.Fprintf(output, "Pushing %s\n", ref)
+		push := exec.CommandContext(ctx, "docker", "push", ref)
+		push.Stdout, push.Stderr = output, output
+		if err := push.Run(); err != nil {
+			return errors.Wrapf(err, "failed to push %s", tag)
+		}
+	}
+	return nil
+}
+
+// Images returns the images in a repository's namespace, by name. Nothing
+// is returned while the registry isn't running, as it has no images then.
+func (r *RegistryService) Images(repoID string) ([]*models.RegistryImage, error) {
+	if !r.IsRunning() {
+		return nil, nil
+	}
+
+	var catalog struct {
+		Repositories []string `json:"repositories"`
+	}
+	if err := r.getJSON("/v2/_catalog?n=10000", &catalog); err != nil {
+		return nil, errors.Wrap(err, "failed to list images")
+	}
+
+	var images []*models.RegistryImage
+	for _, name := range catalog.Repositories {
+		owner, image, ok := models.SplitRegistryName(name)
+		if !ok || owner != repoID {
+			continue
+		}
+		var tags struct {
+			Tags []string `json:"tags"`
+		}
+		if err := r.getJSON("/v2/"+name+"/tags/list", &tags); err != nil {
+			return nil, errors.Wrapf(err, "failed to list tags of %s", image)
+		}
+		sort.Strings(tags.Tags)
+		images = append(images, &models.RegistryImage{RepoID: repoID, Name: image, Tags: tags.Tags})
+	}
+	sort.Slice(images, func(i, j int) bool { return images[i].Name < images[j].Name })
+	return images, nil
+}
+
+// DeleteTag deletes the manifest a tag points at, which removes every tag
+// of the same manifest. Its layers go at the next garbage collection.
+func (r *RegistryService) DeleteTag(repoID, image, tag string) error {
+	if !models.ValidImageName(image) || !models.ValidImageTag(tag) {
+		return errors.New("image not found")
+	}
+	path := fmt.Sprintf("/v2/%s/%s/manifests/", repoID, image)
+
+	req, err := http.NewRequest(http.MethodHead, "http://"+r.Address()+path+tag, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", registryAccept)
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "failed to reach the registry")
+	}
+	resp.Body.Close()
+	digest := resp.Header.Get("Docker-Content-Digest")
+	if resp.StatusCode != http.StatusOK || digest == "" {
+		return errors.Errorf("%s:%s not found", image, tag)
+	}
+
+	r.gcMu.RLock()
+	defer r.gcMu.RUnlock()
+	req, err = http.NewRequest(http.MethodDelete, "http://"+r.Address()+path+digest, nil)
+	if err != nil {
+		return err
+	}
+	resp, err = r.client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "failed to reach the registry")
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted && resp.StatusCode != http.StatusNotFound {
+		return errors.Errorf("registry refused to delete %s:%s (%d)", image, tag, resp.StatusCode)
+	}
+	return nil
+}
+
+// DeleteRepository deletes every image of a deleted repository and
+// collects their layers
+func (r *RegistryService) DeleteRepository(repoID string) {
+	images, err := r.Images(repoID)
+	if err != nil || len(images) == 0 {
+		return
+	}
+	for _, image := range images {
+		for _, tag := range image.Tags {
+			if err := r.DeleteTag(repoID, image.Name, tag); err != nil {
+				log.Printf("Registry: Failed to delete %s:%s: %v", image.Path(), tag, err)
+			}
+		}
+	}
+	if _, err := r.GarbageCollect(); err != nil {
+		log.Printf("Registry: %v", err)
+	}
+}
+
+// GarbageCollect removes manifests without tags and the layers nothing
+// refers to any more. Pushes wait until it is done, and the registry is
+// restarted afterwards so it forgets the layers it cached.
+func (r *RegistryService) GarbageCollect() (*RegistryGC, error) {
+	if !r.IsRunning() {
+		return nil, errors.New("the registry isn't running")
+	}
+
+	r.gcMu.Lock()
+	defer r.gcMu.Unlock()
+
+	start := time.Now()
+	out, err := exec.Command("docker", "exec", r.config.ContainerName,
+		"registry", "garbage-collect", "--delete-untagged", "/etc/docker/registry/config.yml").CombinedOutput()
+	gc := &RegistryGC{FinishedAt: time.Now(), Duration: time.Since(start), Output: summarizeGC(string(out))}
+	if err != nil {
+		gc.Error = strings.TrimSpace(string(out))
+		err = errors.Wrap(err, "garbage collection failed")
+	} else if restartErr := r.service.Restart(); restartErr != nil {
+		err = errors.Wrap(restartErr, "failed to restart the registry after garbage collection")
+	} else if readyErr := r.service.WaitForReady(30*time.Second, r.healthCheck); readyErr != nil {
+		log.Printf("Registry: Not ready after garbage collection: %v", readyErr)
+	}
+
+	r.mu.Lock()
+	r.lastGC = gc
+	r.mu.Unlock()
+	return gc, err
+}
+
+// LastGC returns the last garbage collection since the server started
+func (r *RegistryService) LastGC() *RegistryGC {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.lastGC
+}
+
+// collectPeriodically runs garbage collection on the configured interval
+func (r *RegistryService) collectPeriodically() {
+	ticker := time.NewTicker(r.config.GCInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		gc, err := r.GarbageCollect()
+		if err != nil {
+			log.Printf("Registry: %v", err)
+			continue
+		}
+		log.Printf("Registry: Garbage collected in %s, %s", gc.Duration.Round(time.Millisecond), gc.Output)
+	}
+}
+
+// summarizeGC keeps the totals the garbage collector prints last
+func summarizeGC(output string) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		if strings.Contains(lines[i], "blobs marked") {
+			return strings.TrimSpace(lines[i])
+		}
+	}
+	return "nothing to remove"
+}
+
+// getJSON reads a registry API response
+func (r *RegistryService) getJSON(path string, v any) error {
+	resp, err := r.client.Get("http://" + r.Address() + path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("registry returned %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,208 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"workspace/internal/pdf"
+	"workspace/models"
+
+	"github.com/pkg/errors"
+)
+
+// reportPrompts ask the model for the summary that opens each kind of report
+var reportPrompts = map[string]string{
+	models.ReportReleaseNotes: "Draft the opening of release notes from the repository activity below. " +
+		"Group the user-facing changes into a few themes and describe them for users, not developers.",
+	models.ReportWeeklySummary: "Summarize the engineering activity below for the team and its managers. " +
+		"Say what shipped, what is in progress and anything that needs attention.",
+	models.ReportRiskItems: "Assess the open risks below. Say which items need attention first and why, " +
+		"and suggest a next step for each.",
+	models.ReportDailyHealth: "Summarize the last day in the repository below for its maintainers. " +
+		"Point out notable changes first, then any risks such as failing builds, urgent issues or stalled pull requests.",
+}
+
+// digestTimeout bounds posting a daily report to the digest webhook
+const digestTimeout = 30 * time.Second
+
+// RunReport generates the schedule's report for the period ending at end,
+// delivers it and records the run. A failed delivery is recorded on the
+// report and schedule rather than returned.
+func RunReport(schedule *models.ReportSchedule, end time.Time) (*models.RepoReport, error) {
+	repo, err := models.Repositories.Get(schedule.RepoID)
+	if err != nil {
+		return nil, errors.Wrap(err, "repository not found")
+	}
+
+	start, end := schedule.Period(end)
+	stats, err := repo.ReportStats(start, end)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to gather repository stats")
+	}
+
+	report := &models.RepoReport{
+		Title:       models.ReportTitle(schedule.Kind, stats),
+		Content:     models.RenderReport(schedule.Kind, stats, summarizeReport(schedule.Kind, stats)),
+		PeriodStart: start,
+		PeriodEnd:   end,
+	}
+
+	deliveryErr := deliverReport(schedule, repo, report)
+	if deliveryErr != nil {
+		log.Printf("Reports: Failed to deliver %s for %s: %v", schedule.Kind, repo.Name, deliveryErr)
+	}
+	return schedule.RecordRun(report, deliveryErr)
+}
+
+// RunDailyReport writes the repository's health report for the day ending
+// at end and sends it to the daily digest recipients and webhook, if any.
+// A failed delivery is recorded on the report rather than returned.
+func RunDailyReport(repo *models.Repository, end time.Time) (*models.RepoReport, error) {
+	start := end.Add(-24 * time.Hour)
+	stats, err := repo.ReportStats(start, end)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to gather repository stats")
+	}
+
+	summary := summarizeReport(models.ReportDailyHealth, stats)
+	report := &models.RepoReport{
+		RepoID:      repo.ID,
+		Kind:        models.ReportDailyHealth,
+		Title:       models.ReportTitle(models.ReportDailyHealth, stats),
+		Content:     models.RenderReport(models.ReportDailyHealth, stats, summary),
+		Summary:     summary,
+		PeriodStart: start,
+		PeriodEnd:   end,
+	}
+
+	if err := sendDailyDigest(repo, report); err != nil {
+		log.Printf("Reports: Failed to send the daily digest for %s: %v", repo.Name, err)
+		report.Error = err.Error()
+	}
+
+	report, err = models.RepoReports.Insert(report)
+	return report, errors.Wrap(err, "failed to save report")
+}
+
+// sendDailyDigest emails a daily report to the configured recipients and
+// posts it to the configured webhook, recording where it went
+func sendDailyDigest(repo *models.Repository, report *models.RepoReport) error {
+	settings, err := models.GetSettings()
+	if err != nil {
+		return err
+	}
+
+	var delivered []string
+	var recipients []string
+	for _, address := range strings.Split(settings.DailyReportRecipients, ",") {
+		if address = strings.TrimSpace(address); address != "" {
+			recipients = append(recipients, address)
+		}
+	}
+	if len(recipients) > 0 && MailConfigured() {
+		if err := SendMail(recipients, report.Title, report.Content); err != nil {
+			return err
+		}
+		delivered = append(delivered, recipients...)
+	}
+
+	if url := settings.DailyReportWebhookURL; url != "" {
+		if err := postDigest(url, repo, report); err != nil {
+			return err
+		}
+		delivered = append(delivered, url)
+	}
+
+	report.DeliveredTo = strings.Join(delivered, ", ")
+	return nil
+}
+
+// postDigest sends a daily report to a webhook as JSON
+func postDigest(url string, repo *models.Repository, report *models.RepoReport) error {
+	body, err := json.Marshal(map[string]any{
+		"repo_id":      repo.ID,
+		"repo_name":    repo.Name,
+		"title":        report.Title,
+		"summary":      report.Summary,
+		"content":      report.Content,
+		"period_start": report.PeriodStart,
+		"period_end":   report.PeriodEnd,
+	})
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{Timeout: digestTimeout}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "failed to post daily digest")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errors.Errorf("daily digest webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// summarizeReport asks the AI assistant to summarize the report's data,
+// returning nothing when it isn't running or fails so the report goes out
+// with the stats alone
+func summarizeReport(kind string, stats *models.ReportStats) string {
+	if Ollama == nil || !Ollama.IsRunning() {
+		return ""
+	}
+
+	response, err := Ollama.Chat(Ollama.GetDefaultModel(), []OllamaMessage{
+		{Role: "system", Content: reportPrompts[kind] + " Write two or three short paragraphs of plain Markdown without headings. " +
+			"Only use facts from the data and don't invent numbers."},
+		{Role: "user", Content: models.RenderReport(kind, stats, "")},
+	}, false)
+	if err != nil {
+		log.Printf("Reports: Failed to summarize %s for %s: %v", kind, stats.Repo.Name, err)
+		return ""
+	}
+	return strings.TrimSpace(response.Message.Content)
+}
+
+// ReportDocument returns a report as a file in format, with its content type
+func ReportDocument(report *models.RepoReport, format string) ([]byte, string) {
+	if format == models.ReportPDF {
+		return pdf.FromMarkdown(report.Content), "application/pdf"
+	}
+	return []byte(report.Content), "text/markdown; charset=utf-8"
+}
+
+// deliverReport emails the report or commits it to the repository's default
+// branch, recording where it went
+func deliverReport(schedule *models.ReportSchedule, repo *models.Repository, report *models.RepoReport) error {
+	data, contentType := ReportDocument(report, schedule.Format)
+	name := schedule.FileName(report.PeriodEnd)
+
+	switch schedule.Delivery {
+	case models.ReportByEmail:
+		recipients := schedule.RecipientList()
+		body := report.Content
+		if schedule.Format == models.ReportPDF {
+			body = fmt.Sprintf("%s\n\nThe report is attached as %s.\n", report.Title, name)
+		}
+		if err := SendMail(recipients, report.Title, body, MailAttachment{Name: name, ContentType: contentType, Data: data}); err != nil {
+			return err
+		}
+		report.DeliveredTo = strings.Join(recipients, ", ")
+
+	case models.ReportToRepo:
+		file := path.Join(schedule.Path, name)
+		err := repo.WriteFile("", file, string(data), "Add "+report.Title, "Skyscape Reports", "reports@skyscape.local")
+		if err != nil {
+			return errors.Wrap(err, "failed to commit report")
+		}
+		report.DeliveredTo = file
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+package services
+
+import (
+	"workspace/models"
+)
+
+// DeleteRepository removes a repository with everything the services keep
+// for it. Environments stop what they run while their credentials still
+// exist, and the registry drops its images in the background. The working
+// copy in Code Server is kept, since the user may still want it.
+func DeleteRepository(repo *models.Repository) error {
+	envs, _ := models.GetEnvironments(repo.ID)
+	for _, env := range envs {
+		if env.DeploymentID != "" {
+			Deployments.Stop(env)
+		}
+	}
+
+	if err := models.DeleteRepository(repo.ID); err != nil {
+		return err
+	}
+	Indexer.Forget(repo.ID)
+	go Registry.DeleteRepository(repo.ID)
+	return nil
+}
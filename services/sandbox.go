@@ -12,6 +12,8 @@ import (
 	"github.com/The-Skyscape/devtools/pkg/containers"
 	"github.com/The-Skyscape/devtools/pkg/database"
 	"github.com/pkg/errors"
+
+	"workspace/models"
 )
 
 // Sandbox represents a containerized execution environment
@@ -22,7 +24,10 @@ type Sandbox struct {
 	Command     string
 	TimeoutSecs int
 	Container   *containers.Service
+	Policy      *models.SandboxPolicy // Nil when the command is not subject to a repository policy
 	startTime   time.Time
+	timedOut    bool
+	masker      *models.SecretMasker // Hides secret values in the sandbox's output
 	mu          sync.RWMutex
 }
 
@@ -32,8 +37,16 @@ var (
 	registryMu      sync.RWMutex
 )
 
-// NewSandbox creates a new sandbox instance and prepares it for execution
-func NewSandbox(name, repoPath, repoName, command string, timeoutSecs int) (*Sandbox, error) {
+// NewSandbox creates a new sandbox instance and prepares it for execution.
+// When a policy is given the command is checked against it first, and the
+// container is started with its network, CPU, memory and PATH restrictions.
+func NewSandbox(name, repoPath, repoName, command string, timeoutSecs int, policy *models.SandboxPolicy) (*Sandbox, error) {
+	if policy != nil {
+		if err := checkSandboxPolicy(policy, command, timeoutSecs); err != nil {
+			return nil, err
+		}
+	}
+
 	// Check if sandbox already exists
 	registryMu.RLock()
 	if existing, exists := sandboxRegistry[name]; exists {
@@ -81,6 +94,7 @@ echo "Command: %s"
 echo "==================================="
 echo ""
 
+%s
 # Execute the user command
 %s
 
@@ -89,7 +103,7 @@ echo ""
 echo "==================================="
 echo "=== Sandbox Completed with exit code: $EXIT_CODE ==="
 exit $EXIT_CODE
-`, name, repoName, command, command)
+`, name, repoName, command, sandboxPolicyScript(policy), command)
 
 	if err := os.WriteFile(scriptPath, []byte(scriptContent), 0755); err != nil {
 		return nil, errors.Wrap(err, "failed to create command script")
@@ -97,12 +111,16 @@ exit $EXIT_CODE
 
 	// Create container configuration
 	containerName := fmt.Sprintf("skyscape-sandbox-%s", name)
+	network := "bridge"
+	if policy != nil && !policy.NetworkEnabled {
+		network = "none"
+	}
 	container := &containers.Service{
 		Host:    host,
 		Name:    containerName,
 		Image:   "skyscape:latest",
 		Command: "/bin/bash -c '/bin/bash /sandbox/run.sh > /sandbox/output.log 2>&1'",
-		Network: "bridge",
+		Network: network,
 		Mounts: map[string]string{
 			workspaceDir: "/workspace",
 			sandboxDir:   "/sandbox",
@@ -119,6 +137,7 @@ exit $EXIT_CODE
 		Command:     command,
 		TimeoutSecs: timeoutSecs,
 		Container:   container,
+		Policy:      policy,
 	}
 
 	// Register sandbox
@@ -129,6 +148,46 @@ exit $EXIT_CODE
 	return sandbox, nil
 }
 
+// SetSecrets gives the sandbox's container the secrets as environment
+// variables and masks their values in its output. Call it before Start.
+func (s *Sandbox) SetSecrets(secrets map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for name, value := range secrets {
+		if _, set := s.Container.Env[name]; !set {
+			s.Container.Env[name] = value
+		}
+	}
+	s.masker = models.NewSecretMasker(secrets)
+}
+
+// secretEnvFlags returns docker flags passing the secrets through from the
+// docker client's environment, which secretEnv sets up, so their values
+// never appear in a command line
+func secretEnvFlags(secrets map[string]string) string {
+	var flags strings.Builder
+	for _, name := range sortedKeys(secrets) {
+		flags.WriteString(" -e " + name)
+	}
+	return flags.String()
+}
+
+// secretEnv returns this process's environment with the secrets added
+func secretEnv(secrets map[string]string) []string {
+	env := os.Environ()
+	for _, name := range sortedKeys(secrets) {
+		env = append(env, name+"="+secrets[name])
+	}
+	return env
+}
+
+// sandboxDir is the host directory holding the sandbox's script, output and
+// workspace
+func (s *Sandbox) sandboxDir() string {
+	return fmt.Sprintf("%s/sandboxes/%s", database.DataDir(), s.Name)
+}
+
 // GetSandbox retrieves an existing sandbox by name
 func GetSandbox(name string) (*Sandbox, error) {
 	registryMu.RLock()
@@ -169,6 +228,11 @@ func (s *Sandbox) Start() error {
 
 	s.startTime = time.Now()
 
+	if err := s.applyResourceLimits(); err != nil {
+		s.Container.Stop()
+		return err
+	}
+
 	// Start monitoring in a goroutine if timeout is set
 	if s.TimeoutSecs > 0 {
 		go s.monitorTimeout()
@@ -202,6 +266,7 @@ func (s *Sandbox) Execute(command string) (string, int, error) {
 	}
 
 	output, err := s.Container.ExecInContainerWithOutput("bash", "-c", command)
+	output = s.masker.Mask(output)
 	if err != nil {
 		// Try to extract exit code from error
 		return output, 1, err
@@ -221,12 +286,13 @@ func (s *Sandbox) GetOutput() (string, error) {
 		return "", errors.Wrap(err, "failed to read output file")
 	}
 
-	return string(output), nil
+	return s.masker.Mask(string(output)), nil
 }
 
 // GetLogs retrieves container logs
 func (s *Sandbox) GetLogs(tail int) (string, error) {
-	return s.Container.GetLogs(tail)
+	logs, err := s.Container.GetLogs(tail)
+	return s.masker.Mask(logs), err
 }
 
 // GetExitCode gets the exit code of the container
@@ -402,6 +468,9 @@ func (s *Sandbox) monitorTimeout() {
 
 	if s.IsRunning() {
 		log.Printf("Sandbox %s timed out after %v", s.Name, timeout)
+		s.mu.Lock()
+		s.timedOut = true
+		s.mu.Unlock()
 		s.Stop()
 	}
 }
@@ -0,0 +1,244 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"path"
+	"regexp"
+	"strings"
+
+	"workspace/models"
+)
+
+// Sandbox policy rules reported in violations
+const (
+	SandboxRuleBinary  = "binary"
+	SandboxRuleTimeout = "timeout"
+	SandboxRuleMemory  = "memory"
+	SandboxRuleWrite   = "write"
+)
+
+// SandboxViolation is returned when a command breaks its repository's
+// sandbox policy. The message is JSON so the AI can see which rule it hit
+// and adjust the command rather than retrying it unchanged.
+type SandboxViolation struct {
+	Rule    string   `json:"rule"`
+	Detail  string   `json:"detail"`
+	Allowed []string `json:"allowed,omitempty"`
+}
+
+func (v *SandboxViolation) Error() string {
+	data, _ := json.Marshal(map[string]any{"sandbox_policy_violation": v})
+	return string(data)
+}
+
+var (
+	// shellSeparators split a command line into the simple commands it runs
+	shellSeparators = regexp.MustCompile("&&|\\|\\||[;|&\\n()`]|\\$\\(")
+
+	// shellRedirections would otherwise be mistaken for separators
+	shellRedirections = regexp.MustCompile(`[0-9]*[<>]&[0-9-]*|&>>?`)
+
+	// shellBuiltins never resolve through PATH, so the allowlist ignores them
+	shellBuiltins = map[string]bool{
+		"cd": true, "echo": true, "export": true, "set": true, "unset": true,
+		"exit": true, "true": true, "false": true, "test": true, "[": true,
+		"[[": true, "]]": true, "source": true, ".": true, "pwd": true,
+		"read": true, "printf": true, "local": true, "return": true,
+		"fi": true, "for": true, "done": true, "case": true, "esac": true,
+		"}": true, "exec": true, "time": true, "command": true,
+		"shift": true, "wait": true,
+	}
+
+	// shellKeywords start a compound command, the next word is the program
+	shellKeywords = map[string]bool{
+		"if": true, "then": true, "else": true, "elif": true, "while": true,
+		"until": true, "do": true, "{": true, "!": true,
+	}
+
+	// commandWrappers run the program named by their first argument
+	commandWrappers = map[string]bool{
+		"exec": true, "time": true, "command": true, "sudo": true,
+		"env": true, "nohup": true,
+	}
+)
+
+// CommandBinaries lists the programs a shell command line would start. It
+// is a best-effort parse used to reject commands up front; the restricted
+// PATH inside the container is what actually enforces the allowlist.
+func CommandBinaries(command string) []string {
+	command = shellRedirections.ReplaceAllString(command, " ")
+
+	var binaries []string
+	for _, segment := range shellSeparators.Split(command, -1) {
+		fields := strings.Fields(segment)
+		for len(fields) > 0 {
+			name := strings.Trim(fields[0], `"'`)
+			switch {
+			case strings.Contains(name, "=") && !strings.HasPrefix(name, "="):
+				// Variable assignment before the command
+			case shellKeywords[name]:
+			case commandWrappers[name]:
+				if !shellBuiltins[name] {
+					binaries = append(binaries, name)
+				}
+			case strings.HasPrefix(name, "-"):
+				// Option of a wrapper such as env -i
+			case shellBuiltins[name]:
+				fields = nil
+				continue
+			default:
+				binaries = append(binaries, path.Base(name))
+				fields = nil
+				continue
+			}
+			fields = fields[1:]
+		}
+	}
+	return binaries
+}
+
+// checkSandboxPolicy rejects a command before any container is started
+func checkSandboxPolicy(policy *models.SandboxPolicy, command string, timeoutSecs int) error {
+	if policy.MaxTimeoutSecs > 0 && timeoutSecs > policy.MaxTimeoutSecs {
+		return &SandboxViolation{
+			Rule:   SandboxRuleTimeout,
+			Detail: fmt.Sprintf("requested timeout of %ds exceeds the repository limit of %ds", timeoutSecs, policy.MaxTimeoutSecs),
+		}
+	}
+	for _, binary := range CommandBinaries(command) {
+		if !policy.AllowsBinary(binary) {
+			return &SandboxViolation{
+				Rule:    SandboxRuleBinary,
+				Detail:  fmt.Sprintf("%q is not an allowed program in this repository's sandbox", binary),
+				Allowed: policy.BinaryList(),
+			}
+		}
+	}
+	return nil
+}
+
+// sandboxPolicyScript returns the shell run before the user command. It
+// waits for the resource limits to be applied and then narrows PATH to the
+// allowed programs.
+func sandboxPolicyScript(policy *models.SandboxPolicy) string {
+	if policy == nil {
+		return ""
+	}
+
+	var script strings.Builder
+	if policy.CPUMillicores > 0 || policy.MemoryMB > 0 {
+		script.WriteString(`# Wait for the CPU and memory limits to be applied
+for ((i = 0; i < 100; i++)); do
+  [ -f /sandbox/.limits-applied ] && break
+  sleep 0.1
+done
+if [ ! -f /sandbox/.limits-applied ]; then
+  echo "Sandbox resource limits were not applied"
+  exit 125
+fi
+`)
+	}
+
+	if binaries := policy.BinaryList(); len(binaries) > 0 {
+		fmt.Fprintf(&script, `# Only the allowed programs are reachable through PATH
+SANDBOX_BIN=$(mktemp -d)
+for bin in %s; do
+  if found=$(command -v "$bin"); then ln -s "$found" "$SANDBOX_BIN/$bin"; fi
+done
+export PATH="$SANDBOX_BIN"
+`, strings.Join(binaries, " "))
+	}
+
+	return script.String()
+}
+
+// applyResourceLimits sets the policy's CPU and memory limits on the running
+// container and then lets the waiting script continue
+func (s *Sandbox) applyResourceLimits() error {
+	if s.Policy == nil || (s.Policy.CPUMillicores == 0 && s.Policy.MemoryMB == 0) {
+		return nil
+	}
+
+	args := []string{"update"}
+	if s.Policy.CPUMillicores > 0 {
+		args = append(args, "--cpus", s.Policy.CPUs())
+	}
+	if s.Policy.MemoryMB > 0 {
+		memory := fmt.Sprintf("%dm", s.Policy.MemoryMB)
+		args = append(args, "--memory", memory, "--memory-swap", memory)
+	}
+	args = append(args, s.Container.Name)
+
+	if output, err := exec.Command("docker", args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to apply sandbox limits: %s", strings.TrimSpace(string(output)))
+	}
+
+	marker := fmt.Sprintf("%s/.limits-applied", s.sandboxDir())
+	return exec.Command("touch", marker).Run()
+}
+
+// Violation reports the policy rule a finished command broke, if any
+func (s *Sandbox) Violation() error {
+	if s.Policy == nil {
+		return nil
+	}
+
+	s.mu.RLock()
+	timedOut := s.timedOut
+	s.mu.RUnlock()
+	if timedOut {
+		return &SandboxViolation{
+			Rule:   SandboxRuleTimeout,
+			Detail: fmt.Sprintf("command was stopped after exceeding its %ds time limit", s.TimeoutSecs),
+		}
+	}
+
+	if s.Policy.MemoryMB > 0 {
+		output, err := exec.Command("docker", "inspect", "-f", "{{.State.OOMKilled}}", s.Container.Name).Output()
+		if err == nil && strings.TrimSpace(string(output)) == "true" {
+			return &SandboxViolation{
+				Rule:   SandboxRuleMemory,
+				Detail: fmt.Sprintf("command was killed for exceeding the %d MB memory limit", s.Policy.MemoryMB),
+			}
+		}
+	}
+
+	if s.RepoPath != "" && len(s.Policy.WritablePathList()) > 0 {
+		var denied []string
+		for _, file := range s.changedFiles() {
+			if !s.Policy.AllowsWrite(file) {
+				denied = append(denied, file)
+			}
+		}
+		if len(denied) > 0 {
+			return &SandboxViolation{
+				Rule:    SandboxRuleWrite,
+				Detail:  fmt.Sprintf("command modified files outside the writable paths: %s", strings.Join(denied, ", ")),
+				Allowed: s.Policy.WritablePathList(),
+			}
+		}
+	}
+
+	return nil
+}
+
+// changedFiles lists the files the command added, modified or deleted in the
+// sandbox's copy of the repository
+func (s *Sandbox) changedFiles() []string {
+	repoDir := fmt.Sprintf("%s/workspace/repo", s.sandboxDir())
+	output, err := exec.Command("git", "-c", "safe.directory=*", "-C", repoDir,
+		"status", "--porcelain", "-z", "--no-renames", "--untracked-files=all").Output()
+	if err != nil {
+		return nil
+	}
+
+	var files []string
+	for _, entry := range strings.Split(string(output), "\x00") {
+		if len(entry) > 3 {
+			files = append(files, entry[3:])
+		}
+	}
+	return files
+}
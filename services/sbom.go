@@ -0,0 +1,102 @@
+package services
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"workspace/internal/depscan"
+	"workspace/internal/gitstore"
+	"workspace/internal/sbom"
+	"workspace/models"
+
+	"github.com/pkg/errors"
+)
+
+// sbomTimeout bounds how long generating a bill of materials may take,
+// most of which is looking up licenses
+const sbomTimeout = 10 * time.Minute
+
+// sbomLicenses looks up the licenses manifests don't record
+var sbomLicenses = sbom.NewLicenses()
+
+// generatingSBOMs holds the IDs of the repositories whose bills of
+// materials are being generated
+var generatingSBOMs sync.Map
+
+// GenerateSBOM builds the bill of materials of the repository's default
+// branch from the manifests anywhere in it, looks up the licenses they
+// don't record and saves it, replacing the previous one
+func GenerateSBOM(ctx context.Context, repo *models.Repository) (*models.SBOM, error) {
+	branch := repo.GetDefaultBranch()
+	commit, err := gitstore.Default.Resolve(repo.Path(), branch)
+	if err != nil {
+		return nil, errors.New("repository has no commits on its default branch")
+	}
+
+	stdout, _, err := repo.Git("ls-tree", "-r", "-z", "--name-only", commit)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list files")
+	}
+	manifests := sbom.SelectManifests(strings.Split(stdout.String(), "\x00"))
+
+	var deps []depscan.Dependency
+	var read, problems []string
+	for _, manifest := range manifests {
+		data, err := gitstore.Default.Blob(repo.Path(), commit, manifest)
+		if err != nil {
+			problems = append(problems, manifest+": "+err.Error())
+			continue
+		}
+		found, err := depscan.Parse(manifest, data)
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		read = append(read, manifest)
+		deps = append(deps, found...)
+	}
+
+	components := sbom.Components(deps)
+	failed, err := sbomLicenses.Resolve(ctx, components, models.KnownLicenses(repo.ID))
+	if err != nil {
+		return nil, err
+	}
+
+	return models.RecordSBOM(&models.SBOM{
+		RepoID:    repo.ID,
+		Branch:    branch,
+		CommitSHA: commit,
+		Manifests: strings.Join(read, "\n"),
+	}, components, append(problems, failed...))
+}
+
+// GenerateSBOMAsync generates the repository's bill of materials in the
+// background, returning false when it's already being generated
+func GenerateSBOMAsync(repo *models.Repository) bool {
+	if _, running := generatingSBOMs.LoadOrStore(repo.ID, true); running {
+		return false
+	}
+	go func() {
+		defer generatingSBOMs.Delete(repo.ID)
+		ctx, cancel := context.WithTimeout(context.Background(), sbomTimeout)
+		defer cancel()
+
+		bom, err := GenerateSBOM(ctx, repo)
+		if err != nil {
+			log.Printf("SBOM: Failed to generate the bill of materials of %s: %v", repo.Name, err)
+			return
+		}
+		log.Printf("SBOM: Found %d components in %s, %d flagged", bom.Components, repo.Name, bom.Flagged)
+	}()
+	return true
+}
+
+// IsGeneratingSBOM returns true while the repository's bill of materials
+// is being generated
+func IsGeneratingSBOM(repoID string) bool {
+	_, running := generatingSBOMs.Load(repoID)
+	return running
+}
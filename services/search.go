@@ -0,0 +1,410 @@
+package services
+
+import (
+	"bytes"
+	"log"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"workspace/internal/gitstore"
+	"workspace/internal/search"
+	"workspace/models"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// searchSyncInterval is how often issues, pull requests and comments
+	// changed outside of pushes are picked up
+	searchSyncInterval = 2 * time.Minute
+
+	// maxSearchCommits is how many commits of a repository's history are
+	// indexed at most, newest first
+	maxSearchCommits = 5000
+)
+
+// SearchService keeps the full-text search index of every repository up to
+// date: its issues, pull requests and comments, and the history and files
+// of its default branch. Each pass only indexes what changed since the
+// last one.
+type SearchService struct {
+	mu      sync.Mutex
+	running map[string]bool
+}
+
+var (
+	// Search is the global search indexer instance
+	Search = &SearchService{running: map[string]bool{}}
+)
+
+// Start indexes every repository in the background, then keeps the index
+// up to date
+func (s *SearchService) Start() {
+	go func() {
+		s.syncAll()
+
+		ticker := time.NewTicker(searchSyncInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			s.syncAll()
+		}
+	}()
+}
+
+// syncAll brings every repository's documents up to date
+func (s *SearchService) syncAll() {
+	repos, err := models.Repositories.Search("")
+	if err != nil {
+		log.Printf("SearchService: Failed to load repositories: %v", err)
+		return
+	}
+	for _, repo := range repos {
+		if err := s.Sync(repo); err != nil {
+			log.Printf("SearchService: Failed to index %s: %v", repo.Name, err)
+		}
+	}
+}
+
+// SyncAsync brings a repository's documents up to date in the background,
+// such as after a push
+func (s *SearchService) SyncAsync(repoID string) {
+	go func() {
+		repo, err := models.Repositories.Get(repoID)
+		if err != nil {
+			return
+		}
+		if err := s.Sync(repo); err != nil {
+			log.Printf("SearchService: Failed to index %s: %v", repo.Name, err)
+		}
+	}()
+}
+
+// Sync indexes what changed in a repository since it was last indexed. It
+// does nothing while the repository is already being indexed, since that
+// pass or the next one picks up the changes.
+func (s *SearchService) Sync(repo *models.Repository) error {
+	s.mu.Lock()
+	if s.running[repo.ID] {
+		s.mu.Unlock()
+		return nil
+	}
+	s.running[repo.ID] = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.running, repo.ID)
+		s.mu.Unlock()
+	}()
+
+	state := models.GetSearchIndexState(repo.ID)
+	if state == nil {
+		state = &models.SearchIndexState{RepoID: repo.ID}
+	}
+
+	// Anything changed while indexing is picked up by the next pass
+	started := time.Now()
+	if err := s.syncDiscussions(repo, state.SyncedAt); err != nil {
+		return err
+	}
+	state.SyncedAt = started
+
+	commit, err := s.syncBranch(repo, state.CommitSHA)
+	if err != nil {
+		return err
+	}
+	state.CommitSHA = commit
+	return models.SaveSearchIndexState(state)
+}
+
+// syncDiscussions indexes the repository's issues, pull requests and
+// comments changed since a time
+func (s *SearchService) syncDiscussions(repo *models.Repository, since time.Time) error {
+	authors := searchAuthors{}
+
+	issues, err := models.Issues.Search("WHERE RepoID = ? AND UpdatedAt >= ?", repo.ID, since)
+	if err != nil {
+		return errors.Wrap(err, "failed to load issues")
+	}
+	for _, issue := range issues {
+		name, email := authors.lookup(issue.AuthorID)
+		if err := models.IndexSearchDocument(&models.SearchDocument{
+			Kind:        search.KindIssue,
+			RepoID:      repo.ID,
+			EntityID:    issue.ID,
+			Title:       issue.Title,
+			Body:        issue.Body,
+			AuthorID:    issue.AuthorID,
+			Author:      name,
+			AuthorEmail: email,
+			State:       issueSearchState(issue.Status),
+			URL:         "/repos/" + repo.ID + "/issues/" + issue.ID,
+		}); err != nil {
+			return err
+		}
+	}
+
+	prs, err := models.PullRequests.Search("WHERE RepoID = ? AND UpdatedAt >= ?", repo.ID, since)
+	if err != nil {
+		return errors.Wrap(err, "failed to load pull requests")
+	}
+	for _, pr := range prs {
+		name, email := authors.lookup(pr.AuthorID)
+		body := pr.Body
+		if body == "" {
+			body = pr.Description
+		}
+		if err := models.IndexSearchDocument(&models.SearchDocument{
+			Kind:        search.KindPullRequest,
+			RepoID:      repo.ID,
+			EntityID:    pr.ID,
+			Title:       pr.Title,
+			Body:        body,
+			AuthorID:    pr.AuthorID,
+			Author:      name,
+			AuthorEmail: email,
+			State:       pullRequestSearchState(pr.Status),
+			URL:         pr.URL(),
+		}); err != nil {
+			return err
+		}
+	}
+
+	comments, err := models.Comments.Search("WHERE RepoID = ? AND UpdatedAt >= ?", repo.ID, since)
+	if err != nil {
+		return errors.Wrap(err, "failed to load comments")
+	}
+	for _, comment := range comments {
+		title, url := commentSearchParent(repo, comment)
+		if url == "" {
+			continue
+		}
+		name, email := authors.lookup(comment.AuthorID)
+		if err := models.IndexSearchDocument(&models.SearchDocument{
+			Kind:        search.KindComment,
+			RepoID:      repo.ID,
+			EntityID:    comment.ID,
+			ParentID:    comment.EntityID,
+			Title:       title,
+			Body:        comment.Body,
+			AuthorID:    comment.AuthorID,
+			Author:      name,
+			AuthorEmail: email,
+			URL:         url,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// syncBranch indexes the commits and files of the repository's default
+// branch that changed since the indexed commit, returning the commit it
+// indexed
+func (s *SearchService) syncBranch(repo *models.Repository, indexed string) (string, error) {
+	head, err := gitstore.Default.Resolve(repo.Path(), repo.GetDefaultBranch())
+	if err != nil {
+		if errors.Is(err, gitstore.ErrNotFound) {
+			// Nothing has been pushed yet
+			return indexed, nil
+		}
+		return indexed, errors.Wrap(err, "failed to resolve default branch")
+	}
+	if head == indexed {
+		return head, nil
+	}
+
+	// Rewritten history starts over, since indexed commits may be gone
+	if indexed != "" {
+		if _, _, err := repo.Git("merge-base", "--is-ancestor", indexed, head); err != nil {
+			if err := models.ClearSearchDocuments(repo.ID, search.KindCommit); err != nil {
+				return indexed, err
+			}
+			if err := models.ClearSearchDocuments(repo.ID, search.KindCode); err != nil {
+				return indexed, err
+			}
+			indexed = ""
+		}
+	}
+
+	if err := s.indexCommits(repo, indexed, head); err != nil {
+		return indexed, err
+	}
+	if err := s.indexFiles(repo, indexed, head); err != nil {
+		return indexed, err
+	}
+	return head, nil
+}
+
+// indexCommits indexes the messages of the commits after indexed up to
+// head
+func (s *SearchService) indexCommits(repo *models.Repository, indexed, head string) error {
+	args := []string{"log", "-z", "--format=%H%x1f%an%x1f%ae%x1f%B", "-n", strconv.Itoa(maxSearchCommits), head}
+	if indexed != "" {
+		args = append(args, "^"+indexed)
+	}
+	stdout, stderr, err := repo.Git(args...)
+	if err != nil {
+		return errors.Wrap(err, "failed to read history: "+stderr.String())
+	}
+
+	for _, record := range strings.Split(stdout.String(), "\x00") {
+		fields := strings.SplitN(record, "\x1f", 4)
+		if len(fields) != 4 {
+			continue
+		}
+		hash, message := strings.TrimSpace(fields[0]), strings.TrimSpace(fields[3])
+		subject, _, _ := strings.Cut(message, "\n")
+		if err := models.IndexSearchDocument(&models.SearchDocument{
+			Kind:        search.KindCommit,
+			RepoID:      repo.ID,
+			EntityID:    hash,
+			Title:       subject,
+			Body:        message,
+			Author:      fields[1],
+			AuthorEmail: fields[2],
+			URL:         "/repos/" + repo.ID + "/commits/" + hash + "/diff",
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// indexFiles indexes the contents of the files changed after indexed up to
+// head, or of every file when nothing was indexed yet. Binary files and
+// those too large to be worth searching are left out.
+func (s *SearchService) indexFiles(repo *models.Repository, indexed, head string) error {
+	files, err := listBlobs(repo, head)
+	if err != nil {
+		return err
+	}
+
+	changed := make([]string, 0, len(files))
+	if indexed == "" {
+		for path := range files {
+			changed = append(changed, path)
+		}
+	} else {
+		stdout, stderr, err := repo.Git("diff", "--name-only", "--no-renames", "-z", indexed, head)
+		if err != nil {
+			return errors.Wrap(err, "failed to list changed files: "+stderr.String())
+		}
+		for _, path := range strings.Split(stdout.String(), "\x00") {
+			if path != "" {
+				changed = append(changed, path)
+			}
+		}
+	}
+
+	for _, path := range changed {
+		if _, ok := files[path]; !ok {
+			// Deleted, or no longer worth indexing
+			if err := models.UnindexSearchDocument(search.KindCode, repo.ID, path); err != nil {
+				return err
+			}
+			continue
+		}
+		content, err := gitstore.Default.Blob(repo.Path(), head, path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to read %s", path)
+		}
+		if bytes.IndexByte(content, 0) >= 0 {
+			continue
+		}
+		if err := models.IndexSearchDocument(&models.SearchDocument{
+			Kind:     search.KindCode,
+			RepoID:   repo.ID,
+			EntityID: path,
+			Title:    path,
+			Body:     string(content),
+			Language: models.FileLanguage(path),
+			URL:      "/repos/" + repo.ID + "/files/" + path,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UnindexIssue removes a deleted issue from the search index, with the
+// comments on it
+func (s *SearchService) UnindexIssue(issue *models.Issue) {
+	if err := models.UnindexSearchDocument(search.KindIssue, issue.RepoID, issue.ID); err != nil {
+		log.Printf("SearchService: Failed to remove issue %s: %v", issue.ID, err)
+	}
+}
+
+// searchAuthors caches the names and emails of the users whose work is
+// indexed during a pass
+type searchAuthors map[string][2]string
+
+// lookup returns a user's name and email, or blanks when they're gone
+func (a searchAuthors) lookup(userID string) (string, string) {
+	if userID == "" {
+		return "", ""
+	}
+	author, ok := a[userID]
+	if !ok {
+		if user, err := models.Users.Get(userID); err == nil {
+			author = [2]string{user.Name, user.Email}
+		}
+		a[userID] = author
+	}
+	return author[0], author[1]
+}
+
+// issueSearchState returns whether an issue counts as open or closed
+func issueSearchState(status models.IssueStatus) string {
+	switch status {
+	case models.IssueStatusClosed, models.IssueStatusResolved:
+		return search.StateClosed
+	default:
+		return search.StateOpen
+	}
+}
+
+// pullRequestSearchState returns whether a pull request counts as open,
+// closed or merged
+func pullRequestSearchState(status string) string {
+	switch status {
+	case "merged":
+		return search.StateMerged
+	case "closed":
+		return search.StateClosed
+	default:
+		return search.StateOpen
+	}
+}
+
+// commentSearchParent returns the title and page of what a comment is on,
+// or no page when comments on it aren't searched
+func commentSearchParent(repo *models.Repository, comment *models.Comment) (string, string) {
+	switch comment.EntityType {
+	case "issue":
+		issue, err := models.Issues.Get(comment.EntityID)
+		if err != nil {
+			return "", ""
+		}
+		return "Comment on " + issue.Title, "/repos/" + repo.ID + "/issues/" + issue.ID
+	case "pr":
+		pr, err := models.PullRequests.Get(comment.EntityID)
+		if err != nil {
+			return "", ""
+		}
+		if comment.FilePath != "" {
+			return "Review comment on " + filepath.Base(comment.FilePath) + " in " + pr.Title, pr.URL()
+		}
+		return "Comment on " + pr.Title, pr.URL()
+	case "commit":
+		hash := comment.EntityID
+		if len(hash) > 7 {
+			hash = hash[:7]
+		}
+		return "Comment on commit " + hash, "/repos/" + repo.ID + "/commits/" + comment.EntityID + "/diff"
+	default:
+		return "", ""
+	}
+}
@@ -0,0 +1,298 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"workspace/internal/semantic"
+	"workspace/models"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// maxIndexedFileSize skips generated and vendored blobs that would
+	// crowd out real code
+	maxIndexedFileSize = 256 * 1024
+
+	// embedBatchSize is how many chunks are sent per embed request
+	embedBatchSize = 32
+
+	// indexTimeout bounds a single indexing pass
+	indexTimeout = 30 * time.Minute
+)
+
+// SemanticService maintains per-repository embedding indexes of the default
+// branch and answers natural-language code searches against them
+type SemanticService struct {
+	mu      sync.Mutex
+	running map[string]bool
+}
+
+// SemanticResult is an indexed chunk and how closely it matches a query
+type SemanticResult struct {
+	*models.CodeChunk
+	Score float64
+}
+
+var (
+	// Semantic is the global semantic search service instance
+	Semantic = &SemanticService{running: map[string]bool{}}
+)
+
+// IsAvailable reports whether embeddings can be computed
+func (s *SemanticService) IsAvailable() bool {
+	return Ollama.IsRunning()
+}
+
+// IsIndexing reports whether a repository is being indexed
+func (s *SemanticService) IsIndexing(repoID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.running[repoID]
+}
+
+// IndexAsync re-indexes a repository in the background. It does nothing
+// when embeddings are unavailable or the repository is already being
+// indexed.
+func (s *SemanticService) IndexAsync(repoID string) {
+	if !s.IsAvailable() {
+		return
+	}
+	go func() {
+		repo, err := models.Repositories.Get(repoID)
+		if err != nil {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), indexTimeout)
+		defer cancel()
+		if err := s.Index(ctx, repo); err != nil {
+			log.Printf("SemanticService: Failed to index %s: %v", repo.Name, err)
+		}
+	}()
+}
+
+// Index brings a repository's embeddings up to date with its default
+// branch. Only files whose blob changed since the last pass are embedded
+// again, and everything is rebuilt when the embedding model changes.
+func (s *SemanticService) Index(ctx context.Context, repo *models.Repository) error {
+	s.mu.Lock()
+	if s.running[repo.ID] {
+		s.mu.Unlock()
+		return errors.New("repository is already being indexed")
+	}
+	s.running[repo.ID] = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.running, repo.ID)
+		s.mu.Unlock()
+	}()
+
+	index := models.GetSemanticIndex(repo.ID)
+	if index == nil {
+		index = &models.SemanticIndex{RepoID: repo.ID}
+	}
+	index.Status = models.SemanticIndexing
+	index.Error = ""
+	if err := models.SaveSemanticIndex(index); err != nil {
+		return err
+	}
+
+	err := s.index(ctx, repo, index)
+	if err != nil {
+		index.Status = models.SemanticFailed
+		index.Error = err.Error()
+	} else {
+		index.Status = models.SemanticReady
+		index.IndexedAt = time.Now()
+	}
+	if saveErr := models.SaveSemanticIndex(index); saveErr != nil && err == nil {
+		err = saveErr
+	}
+	return err
+}
+
+func (s *SemanticService) index(ctx context.Context, repo *models.Repository, index *models.SemanticIndex) error {
+	model := Ollama.EmbeddingModel()
+	if err := s.ensureModel(model); err != nil {
+		return err
+	}
+
+	branch := repo.GetDefaultBranch()
+	stdout, stderr, err := repo.Git("rev-parse", "--verify", "--quiet", branch+"^{commit}")
+	if err != nil {
+		// Nothing has been pushed yet
+		log.Printf("SemanticService: %s has no commits on %s: %s", repo.Name, branch, stderr.String())
+		return nil
+	}
+	commit := strings.TrimSpace(stdout.String())
+
+	if index.EmbeddingModel != model {
+		if err := models.ClearSemanticIndex(repo.ID); err != nil {
+			return err
+		}
+		index.EmbeddingModel = model
+	}
+
+	files, err := listBlobs(repo, commit)
+	if err != nil {
+		return err
+	}
+	indexed, err := models.IndexedBlobs(repo.ID)
+	if err != nil {
+		return err
+	}
+
+	for path := range indexed {
+		if _, ok := files[path]; !ok {
+			if err := models.DeleteCodeChunks(repo.ID, path); err != nil {
+				return err
+			}
+		}
+	}
+
+	for path, blob := range files {
+		if indexed[path] == blob {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := s.indexFile(ctx, repo, model, path, blob); err != nil {
+			return errors.Wrapf(err, "failed to index %s", path)
+		}
+	}
+
+	index.CommitSHA = commit
+	index.Files = len(files)
+	index.Chunks = models.CodeChunks.Count("WHERE RepoID = ?", repo.ID)
+	return nil
+}
+
+// indexFile replaces a file's chunks with freshly embedded ones
+func (s *SemanticService) indexFile(ctx context.Context, repo *models.Repository, model, path, blob string) error {
+	stdout, stderr, err := repo.Git("cat-file", "blob", blob)
+	if err != nil {
+		return errors.Wrap(err, stderr.String())
+	}
+	if err := models.DeleteCodeChunks(repo.ID, path); err != nil {
+		return err
+	}
+	content := stdout.Bytes()
+	if bytes.IndexByte(content, 0) >= 0 {
+		return nil
+	}
+
+	chunks := semantic.Split(string(content))
+	for start := 0; start < len(chunks); start += embedBatchSize {
+		batch := chunks[start:min(start+embedBatchSize, len(chunks))]
+		inputs := make([]string, len(batch))
+		for i, chunk := range batch {
+			inputs[i] = semantic.EmbeddingText(path, chunk)
+		}
+
+		vectors, err := Ollama.Embed(ctx, model, inputs)
+		if err != nil {
+			return err
+		}
+		for i, chunk := range batch {
+			if _, err := models.CodeChunks.Insert(&models.CodeChunk{
+				RepoID:    repo.ID,
+				Path:      path,
+				BlobHash:  blob,
+				StartLine: chunk.StartLine,
+				EndLine:   chunk.EndLine,
+				Content:   chunk.Text,
+				Vector:    semantic.Encode(vectors[i]),
+			}); err != nil {
+				return errors.Wrap(err, "failed to save chunk")
+			}
+		}
+	}
+	return nil
+}
+
+// Search ranks a repository's indexed chunks against a natural-language
+// query
+func (s *SemanticService) Search(ctx context.Context, repo *models.Repository, query string, limit int) ([]*SemanticResult, error) {
+	if strings.TrimSpace(query) == "" {
+		return nil, errors.New("search query is required")
+	}
+	if !s.IsAvailable() {
+		return nil, errors.New("semantic search requires the AI service to be running")
+	}
+
+	index := models.GetSemanticIndex(repo.ID)
+	if index == nil || index.Chunks == 0 {
+		return nil, errors.New("repository has not been indexed yet")
+	}
+
+	vectors, err := Ollama.Embed(ctx, index.EmbeddingModel, []string{query})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to embed query")
+	}
+
+	chunks, err := models.GetCodeChunks(repo.ID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load index")
+	}
+	candidates := make([][]float32, len(chunks))
+	for i, chunk := range chunks {
+		// A corrupt vector decodes to nil and scores 0
+		candidates[i], _ = semantic.Decode(chunk.Vector)
+	}
+
+	var results []*SemanticResult
+	for _, match := range semantic.Rank(vectors[0], candidates, limit) {
+		results = append(results, &SemanticResult{CodeChunk: chunks[match.Index], Score: match.Score})
+	}
+	return results, nil
+}
+
+// ensureModel pulls the embedding model when Ollama doesn't have it yet
+func (s *SemanticService) ensureModel(model string) error {
+	installed, err := Ollama.ListModels()
+	if err != nil {
+		return err
+	}
+	for _, name := range installed {
+		if name == model || strings.TrimSuffix(name, ":latest") == model {
+			return nil
+		}
+	}
+	return Ollama.PullModel(model)
+}
+
+// listBlobs maps each indexable file at commit to its blob hash, skipping
+// submodules and files too large to be worth embedding
+func listBlobs(repo *models.Repository, commit string) (map[string]string, error) {
+	stdout, stderr, err := repo.Git("ls-tree", "-r", "--long", commit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files: %s", stderr.String())
+	}
+
+	files := map[string]string{}
+	for _, line := range strings.Split(stdout.String(), "\n") {
+		// <mode> SP <type> SP <object> SP+ <size> TAB <path>
+		meta, path, ok := strings.Cut(line, "\t")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(meta)
+		if len(fields) != 4 || fields[1] != "blob" {
+			continue
+		}
+		if size, err := strconv.Atoi(fields[3]); err != nil || size == 0 || size > maxIndexedFileSize {
+			continue
+		}
+		files[path] = fields[2]
+	}
+	return files, nil
+}
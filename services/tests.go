@@ -0,0 +1,38 @@
+package services
+
+import (
+	"log"
+
+	"workspace/internal/testreport"
+	"workspace/models"
+)
+
+// maxTestReportSize is the largest test report read for results
+const maxTestReportSize = 20 << 20
+
+// recordTests saves the test results found in the reports as one test run,
+// doing nothing when none of them hold any
+func recordTests(run *models.TestRun, reports ...[]byte) *models.TestRun {
+	format := ""
+	var results []testreport.Result
+	for _, report := range reports {
+		f, found, err := testreport.Parse(report)
+		if err != nil {
+			continue
+		}
+		if format == "" {
+			format = f
+		}
+		results = append(results, found...)
+	}
+	if len(results) == 0 {
+		return nil
+	}
+
+	recorded, err := models.RecordTestRun(run, format, results)
+	if err != nil {
+		log.Printf("Failed to record test results of %s: %v", run.Name, err)
+		return nil
+	}
+	return recorded
+}
@@ -0,0 +1,325 @@
+package services
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"workspace/internal/events"
+	"workspace/internal/netguard"
+	"workspace/models"
+
+	"github.com/pkg/errors"
+)
+
+// Headers sent with each webhook delivery
+const (
+	WebhookEventHeader     = "X-Skyscape-Event"
+	WebhookDeliveryHeader  = "X-Skyscape-Delivery"
+	WebhookSignatureHeader = "X-Skyscape-Signature" // Hex HMAC-SHA256 of the body, when the webhook has a secret
+)
+
+// webhookTimeout bounds each webhook delivery
+const webhookTimeout = 10 * time.Second
+
+// webhookClient sends repository webhook deliveries. Repository admins
+// choose where they go and can read the responses, so it won't connect
+// to the workspace's own network.
+var webhookClient = netguard.Client(webhookTimeout)
+
+// notifyClient sends alerts and chat messages, whose destinations only
+// site admins set, so these may be on the local network
+var notifyClient = &http.Client{Timeout: webhookTimeout}
+
+// WebhookPayload is the JSON body of every webhook delivery
+type WebhookPayload struct {
+	Event      string            `json:"event"`
+	Action     string            `json:"action,omitempty"`
+	Repository WebhookRepository `json:"repository"`
+	Sender     string            `json:"sender,omitempty"` // ID of the user who caused the event
+	Data       any               `json:"data,omitempty"`
+	Timestamp  time.Time         `json:"timestamp"`
+}
+
+// WebhookRepository identifies the repository an event happened in
+type WebhookRepository struct {
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	DefaultBranch string `json:"default_branch"`
+}
+
+// WebhookCommit is a commit in a push event
+type WebhookCommit struct {
+	Hash    string    `json:"hash"`
+	Message string    `json:"message"`
+	Author  string    `json:"author"`
+	Email   string    `json:"email"`
+	Date    time.Time `json:"date"`
+}
+
+// EmitWebhook sends an event to each of the repository's active webhooks
+// that subscribe to it, and publishes it to the API's event stream.
+// Deliveries happen in the background and failures are only recorded in
+// each webhook's history.
+func EmitWebhook(repoID, event, action, userID string, data any) {
+	hooks, err := models.WebhooksFor(repoID, event)
+	if err != nil {
+		log.Printf("Webhooks: Failed to find webhooks for %s: %v", repoID, err)
+	}
+	if len(hooks) == 0 && !events.Stream.Listening() {
+		return
+	}
+
+	body, err := webhookBody(repoID, event, action, userID, data)
+	if err != nil {
+		log.Printf("Webhooks: Failed to encode %s event for %s: %v", event, repoID, err)
+		return
+	}
+	events.Stream.Publish(events.Event{
+		Type:   event,
+		Action: action,
+		RepoID: repoID,
+		UserID: userID,
+		Data:   body,
+	})
+	if len(hooks) == 0 {
+		return
+	}
+
+	go func() {
+		for _, hook := range hooks {
+			if _, err := deliverWebhook(hook, event, action, body, false); err != nil {
+				log.Printf("Webhooks: Failed to record delivery to %s: %v", hook.URL, err)
+			}
+		}
+	}()
+}
+
+// EmitIssueWebhook sends an issues event
+func EmitIssueWebhook(action string, issue *models.Issue, userID string) {
+	EmitWebhook(issue.RepoID, models.WebhookEventIssues, action, userID, map[string]any{
+		"id":           issue.ID,
+		"title":        issue.Title,
+		"body":         issue.Body,
+		"status":       issue.Status,
+		"priority":     issue.Priority,
+		"author_id":    issue.AuthorID,
+		"assignee_id":  issue.AssigneeID,
+		"milestone_id": issue.MilestoneID,
+		"created_at":   issue.CreatedAt,
+		"updated_at":   issue.UpdatedAt,
+	})
+
+	if action == "opened" {
+		NotifyChat(issue.RepoID, models.ChatEventIssueOpened, userID, ChatNotice{
+			Number: issue.ID,
+			Title:  issue.Title,
+			URL:    "/repos/" + issue.RepoID + "/issues/" + issue.ID,
+		})
+	}
+}
+
+// EmitPullRequestWebhook sends a pull_request event
+func EmitPullRequestWebhook(action string, pr *models.PullRequest, userID string) {
+	EmitWebhook(pr.RepoID, models.WebhookEventPullRequest, action, userID, map[string]any{
+		"id":             pr.ID,
+		"title":          pr.Title,
+		"body":           pr.Body,
+		"status":         pr.Status,
+		"draft":          pr.Draft,
+		"base_branch":    pr.BaseBranch,
+		"compare_branch": pr.CompareBranch,
+		"author_id":      pr.AuthorID,
+		"additions":      pr.Additions,
+		"deletions":      pr.Deletions,
+		"created_at":     pr.CreatedAt,
+		"updated_at":     pr.UpdatedAt,
+	})
+
+	chatEvents := map[string]string{"opened": models.ChatEventPROpened, "merged": models.ChatEventPRMerged}
+	if event, ok := chatEvents[action]; ok {
+		NotifyChat(pr.RepoID, event, userID, ChatNotice{
+			Number: pr.ID,
+			Title:  pr.Title,
+			Branch: pr.BaseBranch,
+			URL:    "/repos/" + pr.RepoID + "/prs/" + pr.ID,
+		})
+	}
+}
+
+// EmitCommentWebhook sends a comment event for a comment on an issue or
+// pull request
+func EmitCommentWebhook(comment *models.Comment, entityTitle string) {
+	payload := map[string]any{
+		"id":           comment.ID,
+		"body":         comment.Body,
+		"entity_type":  comment.EntityType,
+		"entity_id":    comment.EntityID,
+		"entity_title": entityTitle,
+		"author_id":    comment.AuthorID,
+		"created_at":   comment.CreatedAt,
+	}
+	// Inline review comments say which line they're on
+	if comment.FilePath != "" {
+		payload["path"] = comment.FilePath
+		payload["line"] = comment.LineNumber
+		payload["commit_id"] = comment.CommitSHA
+		payload["in_reply_to"] = comment.ParentID
+	}
+	EmitWebhook(comment.RepoID, models.WebhookEventComment, "created", comment.AuthorID, payload)
+}
+
+// EmitPushWebhook sends a push event for a branch that moved from before
+// to after. before is empty for a new branch and after for a deleted one.
+func EmitPushWebhook(repo *models.Repository, branch, before, after, userID string) {
+	action := "updated"
+	var commits []*models.Commit
+	switch {
+	case before == "":
+		action = "created"
+		commits, _ = repo.GetCommits(branch, 20)
+	case after == "":
+		action = "deleted"
+	default:
+		commits, _ = repo.GetCommitsBetween(before, after)
+	}
+
+	list := []WebhookCommit{}
+	for _, commit := range commits {
+		if len(list) == 20 {
+			break
+		}
+		list = append(list, WebhookCommit{
+			Hash:    commit.Hash,
+			Message: commit.Message,
+			Author:  commit.Author,
+			Email:   commit.Email,
+			Date:    commit.Date,
+		})
+	}
+	EmitWebhook(repo.ID, models.WebhookEventPush, action, userID, map[string]any{
+		"ref":     "refs/heads/" + branch,
+		"branch":  branch,
+		"before":  before,
+		"after":   after,
+		"commits": list,
+	})
+
+	if after != "" {
+		notice := ChatNotice{
+			Branch:  branch,
+			Commits: len(commits),
+			URL:     "/repos/" + repo.ID + "/commits?branch=" + url.QueryEscape(branch),
+		}
+		if len(commits) > 0 {
+			notice.Detail = commits[0].Message
+		}
+		NotifyChat(repo.ID, models.ChatEventPush, userID, notice)
+	}
+}
+
+// PingWebhook sends a ping event to a webhook, whatever it subscribes to,
+// and returns the delivery
+func PingWebhook(hook *models.Webhook, userID string) (*models.WebhookDelivery, error) {
+	body, err := webhookBody(hook.RepoID, models.WebhookEventPing, "", userID, map[string]any{
+		"webhook_id": hook.ID,
+		"events":     hook.EventList(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return deliverWebhook(hook, models.WebhookEventPing, "", body, false)
+}
+
+// RedeliverWebhook sends a recorded delivery's payload again, signed with
+// the webhook's current secret, and returns the new delivery
+func RedeliverWebhook(delivery *models.WebhookDelivery) (*models.WebhookDelivery, error) {
+	hook, err := models.Webhooks.Get(delivery.WebhookID)
+	if err != nil {
+		return nil, errors.Wrap(err, "webhook not found")
+	}
+	return deliverWebhook(hook, delivery.Event, delivery.Action, []byte(delivery.Payload), true)
+}
+
+// webhookBody encodes an event's payload
+func webhookBody(repoID, event, action, userID string, data any) ([]byte, error) {
+	payload := WebhookPayload{
+		Event:      event,
+		Action:     action,
+		Repository: WebhookRepository{ID: repoID},
+		Sender:     userID,
+		Data:       data,
+		Timestamp:  time.Now().UTC(),
+	}
+	if repo, err := models.Repositories.Get(repoID); err == nil {
+		payload.Repository.Name = repo.Name
+		payload.Repository.DefaultBranch = repo.GetDefaultBranch()
+	}
+	return json.Marshal(payload)
+}
+
+// deliverWebhook posts a payload to a webhook and records the outcome.
+// Only a failure to record it is returned; the endpoint's errors are in
+// the delivery.
+func deliverWebhook(hook *models.Webhook, event, action string, body []byte, redelivery bool) (*models.WebhookDelivery, error) {
+	delivery := &models.WebhookDelivery{
+		Model:      models.DB.NewModel(""),
+		Event:      event,
+		Action:     action,
+		Payload:    string(body),
+		Redelivery: redelivery,
+	}
+
+	start := time.Now()
+	status, response, err := postWebhook(hook, delivery.ID, event, body)
+	delivery.Duration = time.Since(start).Milliseconds()
+	delivery.StatusCode = status
+	delivery.Response = response
+	if err != nil {
+		delivery.Error = err.Error()
+	}
+	return models.RecordWebhookDelivery(hook, delivery)
+}
+
+// postWebhook sends one delivery, returning the response status and the
+// start of its body
+func postWebhook(hook *models.Webhook, deliveryID, event string, body []byte) (int, string, error) {
+	req, err := http.NewRequest(http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Skyscape-Webhooks")
+	req.Header.Set(WebhookEventHeader, event)
+	req.Header.Set(WebhookDeliveryHeader, deliveryID)
+	if hook.Secret != "" {
+		req.Header.Set(WebhookSignatureHeader, SignWebhook(body, hook.Secret))
+	}
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+	response, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, string(response), errors.Errorf("webhook returned %s", resp.Status)
+	}
+	return resp.StatusCode, string(response), nil
+}
+
+// SignWebhook returns the hex HMAC-SHA256 of a payload, which receivers
+// compare against the signature header
+func SignWebhook(payload []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,743 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"workspace/models"
+)
+
+const (
+	// maxWorkflowLog is how much of a step's output is kept
+	maxWorkflowLog = 1 << 20
+
+	// Limits on the files a job keeps as artifacts
+	maxWorkflowArtifactSize  = 10 << 20
+	maxWorkflowArtifactFiles = 50
+)
+
+// WorkflowRunner runs the YAML workflows repositories define. Each job runs
+// in its own container with the repository checked out at the run's
+// commit, one step after another, and reports a commit status.
+type WorkflowRunner struct {
+	slots chan struct{} // Limits how many runs execute at once
+
+	mu      sync.Mutex
+	logs    map[string]*workflowLog       // Output of running steps, by step run ID
+	cancels map[string]context.CancelFunc // Stops a queued or running run, by run ID
+}
+
+// Workflows is the global workflow runner
+var Workflows = NewWorkflowRunner()
+
+// NewWorkflowRunner creates a runner that executes as many runs at once as
+// MAX_PARALLEL_ACTIONS allows, 5 by default
+func NewWorkflowRunner() *WorkflowRunner {
+	parallel := 5
+	if value, err := strconv.Atoi(os.Getenv("MAX_PARALLEL_ACTIONS")); err == nil && value > 0 {
+		parallel = value
+	}
+	return &WorkflowRunner{
+		slots:   make(chan struct{}, parallel),
+		logs:    map[string]*workflowLog{},
+		cancels: map[string]context.CancelFunc{},
+	}
+}
+
+// workflowLog collects a running step's output for the log stream
+type workflowLog struct {
+	mu        sync.Mutex
+	buf       bytes.Buffer
+	truncated bool
+	masker    *models.SecretMasker // Hides the values of the run's secrets
+}
+
+// Write keeps output up to maxWorkflowLog and drops the rest
+func (l *workflowLog) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.truncated {
+		return len(p), nil
+	}
+	if room := maxWorkflowLog - l.buf.Len(); len(p) > room {
+		l.buf.Write(p[:room])
+		l.buf.WriteString("\n[Log truncated]\n")
+		l.truncated = true
+		return len(p), nil
+	}
+	return l.buf.Write(p)
+}
+
+// String returns the output so far, with secrets masked
+func (l *workflowLog) String() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.masker.Mask(l.buf.String())
+}
+
+// Lines returns the complete lines of output so far, with secrets masked.
+// A line still being written could hold part of a secret, so it waits.
+func (l *workflowLog) Lines() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	output := l.buf.String()
+	return l.masker.Mask(output[:strings.LastIndex(output, "\n")+1])
+}
+
+// StepLog returns the complete lines a running step has output so far,
+// and false once the step isn't running
+func (w *WorkflowRunner) StepLog(stepID string) (string, bool) {
+	w.mu.Lock()
+	output, ok := w.logs[stepID]
+	w.mu.Unlock()
+	if !ok {
+		return "", false
+	}
+	return output.Lines(), true
+}
+
+// Pushed starts the workflows a push to a branch triggers: those on push,
+// and those on pull requests for the open pull requests from the branch
+func (w *WorkflowRunner) Pushed(repo *models.Repository, branch, commit, userID string) {
+	w.trigger(repo, models.WorkflowEventPush, branch, &models.WorkflowRun{
+		Branch: branch, CommitSHA: commit, TriggeredBy: userID,
+	})
+
+	prs, err := models.PullRequests.Search("WHERE RepoID = ? AND CompareBranch = ? AND Status NOT IN (?, ?)", repo.ID, branch, "merged", "closed")
+	if err != nil {
+		log.Printf("Workflows: Failed to find pull requests from %s: %v", branch, err)
+		return
+	}
+	for _, pr := range prs {
+		w.trigger(repo, models.WorkflowEventPullRequest, pr.BaseBranch, &models.WorkflowRun{
+			Branch: branch, CommitSHA: commit, PRID: pr.ID, TriggeredBy: userID,
+		})
+	}
+}
+
+// PullRequestOpened starts the workflows on pull requests for a new pull
+// request
+func (w *WorkflowRunner) PullRequestOpened(pr *models.PullRequest, userID string) {
+	repo, err := models.Repositories.Get(pr.RepoID)
+	if err != nil {
+		return
+	}
+	commit, err := repo.ResolveCommit("refs/heads/" + pr.CompareBranch)
+	if err != nil {
+		return
+	}
+	w.trigger(repo, models.WorkflowEventPullRequest, pr.BaseBranch, &models.WorkflowRun{
+		Branch: pr.CompareBranch, CommitSHA: commit, PRID: pr.ID, TriggeredBy: userID,
+	})
+}
+
+// trigger starts every workflow at the run's commit that the event on the
+// branch triggers. For pull requests the branch is the one merged into.
+func (w *WorkflowRunner) trigger(repo *models.Repository, event, branch string, run *models.WorkflowRun) {
+	workflows, err := repo.GetWorkflows(run.CommitSHA)
+	if err != nil {
+		log.Printf("Workflows: Failed to load workflows of %s: %v", repo.Name, err)
+		return
+	}
+	for _, wf := range workflows {
+		if wf.Error != "" || !wf.Triggered(event, branch) {
+			continue
+		}
+		started := *run
+		started.Event = event
+		if _, err := w.Start(repo, wf, &started); err != nil {
+			log.Printf("Workflows: Failed to start %s in %s: %v", wf.Name, repo.Name, err)
+		}
+	}
+}
+
+// Dispatch starts a workflow that runs manually on a branch
+func (w *WorkflowRunner) Dispatch(repo *models.Repository, file, branch, userID string) (*models.WorkflowRun, error) {
+	if branch == "" {
+		branch = repo.GetDefaultBranch()
+	}
+	commit, err := repo.ResolveCommit("refs/heads/" + branch)
+	if err != nil {
+		return nil, fmt.Errorf("branch %s not found", branch)
+	}
+	wf, err := repo.GetWorkflow(commit, file)
+	if err != nil {
+		return nil, err
+	}
+	if !wf.On.Manual {
+		return nil, fmt.Errorf("%s doesn't run manually, add manual to its on events", wf.Name)
+	}
+	return w.Start(repo, wf, &models.WorkflowRun{
+		Event: models.WorkflowEventManual, Branch: branch, CommitSHA: commit, TriggeredBy: userID,
+	})
+}
+
+// Rerun starts a run again, with the workflow as it was the first time
+func (w *WorkflowRunner) Rerun(run *models.WorkflowRun, userID string) (*models.WorkflowRun, error) {
+	repo, err := models.Repositories.Get(run.RepoID)
+	if err != nil {
+		return nil, errors.New("repository not found")
+	}
+	wf, err := models.ParseWorkflow([]byte(run.Definition))
+	if err != nil {
+		return nil, err
+	}
+	wf.Name, wf.File, wf.Source = run.Workflow, run.File, run.Definition
+	return w.Start(repo, wf, &models.WorkflowRun{
+		Event: run.Event, Branch: run.Branch, CommitSHA: run.CommitSHA, PRID: run.PRID, TriggeredBy: userID,
+	})
+}
+
+// RunSchedules starts the scheduled workflows on each repository's default
+// branch whose schedule fires in the minute now falls in
+func (w *WorkflowRunner) RunSchedules(now time.Time) {
+	repos, err := models.Repositories.Search("")
+	if err != nil {
+		log.Printf("Workflows: Failed to list repositories: %v", err)
+		return
+	}
+	for _, repo := range repos {
+		branch := repo.GetDefaultBranch()
+		commit, err := repo.ResolveCommit("refs/heads/" + branch)
+		if err != nil {
+			continue
+		}
+		workflows, err := repo.GetWorkflows(commit)
+		if err != nil {
+			continue
+		}
+		for _, wf := range workflows {
+			if wf.Error != "" || !scheduledAt(wf, now) {
+				continue
+			}
+			if _, err := w.Start(repo, wf, &models.WorkflowRun{
+				Event: models.WorkflowEventSchedule, Branch: branch, CommitSHA: commit,
+			}); err != nil {
+				log.Printf("Workflows: Failed to start scheduled %s in %s: %v", wf.Name, repo.Name, err)
+			}
+		}
+	}
+}
+
+// scheduledAt reports whether any of the workflow's schedules fire at now
+func scheduledAt(wf *models.Workflow, now time.Time) bool {
+	for _, expr := range wf.On.Schedule {
+		if schedule, err := models.ParseCron(expr); err == nil && schedule.Matches(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// Start records a run of the workflow and executes it in the background
+func (w *WorkflowRunner) Start(repo *models.Repository, wf *models.Workflow, run *models.WorkflowRun) (*models.WorkflowRun, error) {
+	// Runs count against the repository owner's CI minutes
+	if err := models.CheckQuota(repo.UserID, models.UsageCIMinutes); err != nil {
+		return nil, err
+	}
+
+	run.RepoID = repo.ID
+	run, err := models.CreateWorkflowRun(wf, run)
+	if err != nil {
+		return nil, err
+	}
+	for _, job := range wf.OrderedJobs() {
+		w.reportJob(repo, wf, run, job, models.CommitStatusPending, "Queued")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w.mu.Lock()
+	w.cancels[run.ID] = cancel
+	w.mu.Unlock()
+
+	go w.execute(ctx, repo, wf, run)
+	return run, nil
+}
+
+// Cancel stops a queued or running run
+func (w *WorkflowRunner) Cancel(runID string) error {
+	w.mu.Lock()
+	cancel, ok := w.cancels[runID]
+	w.mu.Unlock()
+	if !ok {
+		return errors.New("the run isn't in progress")
+	}
+	cancel()
+	return nil
+}
+
+// execute runs the jobs of a run in order, skipping those whose needs
+// didn't succeed
+func (w *WorkflowRunner) execute(ctx context.Context, repo *models.Repository, wf *models.Workflow, run *models.WorkflowRun) {
+	defer func() {
+		w.mu.Lock()
+		if cancel, ok := w.cancels[run.ID]; ok {
+			cancel()
+			delete(w.cancels, run.ID)
+		}
+		w.mu.Unlock()
+	}()
+
+	select {
+	case w.slots <- struct{}{}:
+		defer func() { <-w.slots }()
+	case <-ctx.Done():
+	}
+
+	run.Status = models.WorkflowRunning
+	run.StartedAt = time.Now()
+	models.WorkflowRuns.Update(run)
+
+	jobs, err := run.Jobs()
+	if err != nil {
+		log.Printf("Workflows: Failed to load jobs of run %s: %v", run.ID, err)
+	}
+
+	workspace, err := os.MkdirTemp("", "workflow-*")
+	if err == nil {
+		defer os.RemoveAll(workspace)
+		source := filepath.Join(workspace, "source.tar")
+		if _, stderr, gitErr := repo.Git("archive", "--format=tar", "-o", source, run.CommitSHA); gitErr != nil {
+			err = fmt.Errorf("failed to check out %s: %s", run.ShortSHA(), strings.TrimSpace(stderr.String()))
+		}
+	}
+
+	// Every step gets the workspace's and repository's secrets
+	secrets, secretsErr := models.ActionSecretEnv(repo.ID)
+	if err == nil && secretsErr != nil {
+		err = fmt.Errorf("failed to read secrets: %v", secretsErr)
+	}
+
+	results := map[string]string{}
+	for _, job := range jobs {
+		def := wf.Jobs[job.JobID]
+		switch {
+		case def == nil:
+			results[job.JobID] = w.finishJob(repo, wf, run, job, def, models.WorkflowFailed, "The job is missing from the workflow")
+		case ctx.Err() != nil:
+			results[job.JobID] = w.finishJob(repo, wf, run, job, def, models.WorkflowCancelled, "")
+		case err != nil:
+			results[job.JobID] = w.finishJob(repo, wf, run, job, def, models.WorkflowFailed, err.Error())
+		case !needsSucceeded(def, results):
+			results[job.JobID] = w.finishJob(repo, wf, run, job, def, models.WorkflowSkipped, "")
+		default:
+			results[job.JobID] = w.runJob(ctx, repo, wf, run, job, def, workspace, secrets)
+		}
+	}
+
+	run.Status = models.WorkflowSuccess
+	for _, status := range results {
+		if status == models.WorkflowCancelled && run.Status == models.WorkflowSuccess {
+			run.Status = models.WorkflowCancelled
+		}
+		if status == models.WorkflowFailed || status == models.WorkflowSkipped {
+			run.Status = models.WorkflowFailed
+		}
+	}
+	if ctx.Err() != nil {
+		run.Status = models.WorkflowCancelled
+	}
+	run.Duration = int(time.Since(run.StartedAt).Seconds())
+	if err := models.WorkflowRuns.Update(run); err != nil {
+		log.Printf("Workflows: Failed to update run %s: %v", run.ID, err)
+	}
+	if err := models.MeterWorkflowRun(run); err != nil {
+		log.Printf("Workflows: Failed to meter run %s: %v", run.ID, err)
+	}
+
+	userID := run.TriggeredBy
+	if userID == "" {
+		userID = "system"
+	}
+	models.LogActivity("workflow_run", fmt.Sprintf("Workflow %s %s", run.Workflow, run.Status),
+		fmt.Sprintf("Run #%d on %s %s after %s", run.Number, run.Branch, run.Status, run.FormatDuration()),
+		userID, repo.ID, "workflow_run", run.ID)
+}
+
+// needsSucceeded reports whether every job the job needs succeeded
+func needsSucceeded(def *models.WorkflowJob, results map[string]string) bool {
+	for _, need := range def.Needs {
+		if results[need] != models.WorkflowSuccess {
+			return false
+		}
+	}
+	return true
+}
+
+// runJob runs a job's steps in a container, keeps its artifacts and
+// returns how it ended
+func (w *WorkflowRunner) runJob(ctx context.Context, repo *models.Repository, wf *models.Workflow, run *models.WorkflowRun, job *models.WorkflowJobRun, def *models.WorkflowJob, workspace string, secrets map[string]string) string {
+	job.Status = models.WorkflowRunning
+	job.StartedAt = time.Now()
+	models.WorkflowJobRuns.Update(job)
+	w.reportJob(repo, wf, run, def, models.CommitStatusPending, "Running")
+
+	dir := filepath.Join(workspace, job.JobID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return w.finishJob(repo, wf, run, job, def, models.WorkflowFailed, "Failed to create the workspace: "+err.Error())
+	}
+	if out, err := exec.Command("tar", "-xf", filepath.Join(workspace, "source.tar"), "-C", dir).CombinedOutput(); err != nil {
+		return w.finishJob(repo, wf, run, job, def, models.WorkflowFailed, "Failed to check out the repository: "+string(out))
+	}
+
+	// Containers get the limits of the repository's sandbox
+	container := "skyscape-workflow-" + job.ID
+	policy := models.GetSandboxPolicy(repo.ID)
+	args := []string{"run", "-d", "--name", container, "-v", dir + ":/workspace", "-w", "/workspace"}
+	if !policy.NetworkEnabled {
+		args = append(args, "--network", "none")
+	}
+	if policy.MemoryMB > 0 {
+		args = append(args, "--memory", fmt.Sprintf("%dm", policy.MemoryMB))
+	}
+	if cpus := policy.CPUs(); cpus != "" {
+		args = append(args, "--cpus", cpus)
+	}
+	args = append(args, "--entrypoint", "sh", def.Image, "-c", "while :; do sleep 3600; done")
+	if out, err := exec.CommandContext(ctx, "docker", args...).CombinedOutput(); err != nil {
+		return w.finishJob(repo, wf, run, job, def, models.WorkflowFailed, fmt.Sprintf("Failed to start %s: %s", def.Image, strings.TrimSpace(string(out))))
+	}
+	defer exec.Command("docker", "rm", "-f", container).Run()
+
+	jobCtx, cancel := context.WithTimeout(ctx, time.Duration(def.TimeoutMinutes)*time.Minute)
+	defer cancel()
+
+	steps, err := job.Steps()
+	if err != nil {
+		return w.finishJob(repo, wf, run, job, def, models.WorkflowFailed, "Failed to load the job's steps")
+	}
+	status := models.WorkflowSuccess
+	for i, step := range steps {
+		if status != models.WorkflowSuccess || jobCtx.Err() != nil {
+			step.Status = models.WorkflowSkipped
+			models.WorkflowStepRuns.Update(step)
+			continue
+		}
+		switch image := i - len(def.Steps); {
+		case i < len(def.Steps):
+			status = w.runStep(jobCtx, container, workflowEnv(repo, wf, run, def, def.Steps[i]), secrets, step)
+			if status == models.WorkflowSuccess && len(def.Steps[i].Lint) > 0 {
+				status = w.collectLint(run, wf, def, step)
+			}
+		case image < len(def.Images):
+			status = w.pushImage(jobCtx, repo, run, def.Images[image], dir, secrets, step)
+		}
+	}
+	if ctx.Err() != nil {
+		status = models.WorkflowCancelled
+	} else if jobCtx.Err() != nil {
+		status = models.WorkflowFailed
+	}
+
+	w.collectArtifacts(run, job, def, dir)
+	w.collectTestResults(run, wf, def, dir)
+	return w.finishJob(repo, wf, run, job, def, status, "")
+}
+
+// runStep runs one step in the job's container and returns how it ended.
+// Secrets reach the container through docker's environment rather than its
+// arguments, so they don't show up in the host's process list.
+func (w *WorkflowRunner) runStep(ctx context.Context, container string, env, secrets map[string]string, step *models.WorkflowStepRun) string {
+	return w.trackStep(ctx, step, secrets, func(output io.Writer) error {
+		args := []string{"exec", "-w", "/workspace"}
+		cmdEnv := os.Environ()
+		for _, name := range sortedKeys(secrets) {
+			// Variables the workflow sets itself win over secrets
+			if _, set := env[name]; !set {
+				args = append(args, "-e", name)
+				cmdEnv = append(cmdEnv, name+"="+secrets[name])
+			}
+		}
+		for _, name := range sortedKeys(env) {
+			args = append(args, "-e", name+"="+env[name])
+		}
+		args = append(args, container, "sh", "-ec", step.Script)
+		cmd := exec.CommandContext(ctx, "docker", args...)
+		cmd.Env = cmdEnv
+		cmd.Stdout, cmd.Stderr = output, output
+		return cmd.Run()
+	})
+}
+
+// pushImage builds one of the job's images from its workspace and pushes it
+// to the repository's namespace in the registry, tagged with the commit's
+// short SHA and the branch unless the workflow names the tags
+func (w *WorkflowRunner) pushImage(ctx context.Context, repo *models.Repository, run *models.WorkflowRun, image *models.WorkflowImage, dir string, secrets map[string]string, step *models.WorkflowStepRun) string {
+	tags := image.Tags
+	if len(tags) == 0 {
+		tags = []string{run.ShortSHA()}
+		if branch := models.ImageTag(run.Branch); branch != "" && run.Event != models.WorkflowEventPullRequest {
+			tags = append(tags, branch)
+		}
+	}
+	return w.trackStep(ctx, step, secrets, func(output io.Writer) error {
+		return Registry.Push(ctx, repo.ID, image, dir, tags, output)
+	})
+}
+
+// trackStep runs a step, streaming its masked output to viewers of the run,
+// and records how it ended
+func (w *WorkflowRunner) trackStep(ctx context.Context, step *models.WorkflowStepRun, secrets map[string]string, run func(output io.Writer) error) string {
+	output := &workflowLog{masker: models.NewSecretMasker(secrets)}
+	w.mu.Lock()
+	w.logs[step.ID] = output
+	w.mu.Unlock()
+	defer func() {
+		w.mu.Lock()
+		delete(w.logs, step.ID)
+		w.mu.Unlock()
+	}()
+
+	step.Status = models.WorkflowRunning
+	models.WorkflowStepRuns.Update(step)
+	start := time.Now()
+	err := run(output)
+
+	step.Status = models.WorkflowSuccess
+	var exitErr *exec.ExitError
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		step.Status = models.WorkflowFailed
+		fmt.Fprintf(output, "\nThe job ran out of time")
+	case ctx.Err() != nil:
+		step.Status = models.WorkflowCancelled
+		fmt.Fprintf(output, "\nCancelled")
+	case errors.As(err, &exitErr):
+		step.Status = models.WorkflowFailed
+		step.ExitCode = exitErr.ExitCode()
+		fmt.Fprintf(output, "\nExited with code %d", step.ExitCode)
+	case err != nil:
+		step.Status = models.WorkflowFailed
+		step.ExitCode = -1
+		fmt.Fprintf(output, "\n%v", err)
+	}
+	step.Output = output.String()
+	step.Duration = int(time.Since(start).Seconds())
+	if err := models.WorkflowStepRuns.Update(step); err != nil {
+		log.Printf("Workflows: Failed to update step %s: %v", step.ID, err)
+	}
+	return step.Status
+}
+
+// workflowEnv returns the variables a step runs with: details of the run,
+// then the workflow's, job's and step's own, later ones winning
+func workflowEnv(repo *models.Repository, wf *models.Workflow, run *models.WorkflowRun, job *models.WorkflowJob, step *models.WorkflowStep) map[string]string {
+	vars := map[string]string{
+		"CI":                    "true",
+		"SKYSCAPE_REPOSITORY":   repo.Name,
+		"SKYSCAPE_WORKFLOW":     run.Workflow,
+		"SKYSCAPE_JOB":          job.ID,
+		"SKYSCAPE_EVENT":        run.Event,
+		"SKYSCAPE_BRANCH":       run.Branch,
+		"SKYSCAPE_SHA":          run.CommitSHA,
+		"SKYSCAPE_RUN_ID":       run.ID,
+		"SKYSCAPE_RUN_NUMBER":   strconv.Itoa(run.Number),
+		"SKYSCAPE_PULL_REQUEST": run.PRID,
+	}
+	for _, env := range []map[string]string{wf.Env, job.Env, step.Env} {
+		for name, value := range env {
+			vars[name] = value
+		}
+	}
+	return vars
+}
+
+// sortedKeys returns a map's keys in order, so commands are repeatable
+func sortedKeys(vars map[string]string) []string {
+	keys := make([]string, 0, len(vars))
+	for name := range vars {
+		keys = append(keys, name)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// finishJob records how a job ended, with a note on its first unfinished
+// step when it couldn't run, and reports its commit status
+func (w *WorkflowRunner) finishJob(repo *models.Repository, wf *models.Workflow, run *models.WorkflowRun, job *models.WorkflowJobRun, def *models.WorkflowJob, status, note string) string {
+	steps, _ := job.Steps()
+	for _, step := range steps {
+		if step.Finished() {
+			continue
+		}
+		step.Status = models.WorkflowSkipped
+		if status == models.WorkflowCancelled {
+			step.Status = models.WorkflowCancelled
+		}
+		if note != "" {
+			step.Status, step.Output, note = models.WorkflowFailed, note, ""
+		}
+		models.WorkflowStepRuns.Update(step)
+	}
+
+	job.Status = status
+	if !job.StartedAt.IsZero() {
+		job.Duration = int(time.Since(job.StartedAt).Seconds())
+	}
+	if err := models.WorkflowJobRuns.Update(job); err != nil {
+		log.Printf("Workflows: Failed to update job %s: %v", job.ID, err)
+	}
+
+	if def != nil {
+		switch status {
+		case models.WorkflowSuccess:
+			w.reportJob(repo, wf, run, def, models.CommitStatusSuccess, "Passed in "+job.FormatDuration())
+		case models.WorkflowSkipped:
+			w.reportJob(repo, wf, run, def, models.CommitStatusFailure, "Skipped, a job it needs didn't pass")
+		case models.WorkflowCancelled:
+			w.reportJob(repo, wf, run, def, models.CommitStatusError, "Cancelled")
+		default:
+			w.reportJob(repo, wf, run, def, models.CommitStatusFailure, "Failed after "+job.FormatDuration())
+		}
+	}
+	return status
+}
+
+// reportJob records where a job is as a status on the run's commit
+func (w *WorkflowRunner) reportJob(repo *models.Repository, wf *models.Workflow, run *models.WorkflowRun, job *models.WorkflowJob, state, description string) {
+	if _, err := models.SetCommitStatus(repo, run.CommitSHA, &models.CommitStatus{
+		Context:     wf.StatusContext(job),
+		State:       state,
+		Description: description,
+		TargetURL:   run.URL(),
+		CreatorID:   run.TriggeredBy,
+	}); err != nil {
+		log.Printf("Workflows: Failed to report status of %s: %v", wf.StatusContext(job), err)
+	}
+}
+
+// collectTestResults records the results in the job's test reports, which
+// may be globs, whether or not its steps passed
+func (w *WorkflowRunner) collectTestResults(run *models.WorkflowRun, wf *models.Workflow, def *models.WorkflowJob, dir string) {
+	var reports [][]byte
+	for _, pattern := range def.TestResults {
+		matches, _ := filepath.Glob(filepath.Join(dir, filepath.FromSlash(pattern)))
+		for _, match := range matches {
+			// Links could point outside the workspace, so they aren't followed
+			info, err := os.Lstat(match)
+			if err != nil || !info.Mode().IsRegular() || info.Size() > maxTestReportSize {
+				continue
+			}
+			if report, err := os.ReadFile(match); err == nil {
+				reports = append(reports, report)
+			}
+		}
+	}
+	if len(reports) == 0 {
+		return
+	}
+
+	recordTests(&models.TestRun{
+		RepoID:    run.RepoID,
+		Source:    models.TestSourceWorkflow,
+		Name:      wf.StatusContext(def),
+		URL:       run.URL(),
+		Branch:    run.Branch,
+		CommitSHA: run.CommitSHA,
+	}, reports...)
+}
+
+// collectLint records what a lint step's linters found and fails the step
+// when they found anything, listing the findings at the end of its log
+func (w *WorkflowRunner) collectLint(run *models.WorkflowRun, wf *models.Workflow, def *models.WorkflowJob, step *models.WorkflowStepRun) string {
+	_, report, err := recordLint(&models.LintRun{
+		RepoID:    run.RepoID,
+		Source:    models.LintSourceWorkflow,
+		Name:      wf.StatusContext(def),
+		URL:       run.URL(),
+		Branch:    run.Branch,
+		CommitSHA: run.CommitSHA,
+	}, step.Output, run.TriggeredBy)
+	if err != nil {
+		log.Printf("Workflows: Failed to record lint findings of run %s: %v", run.ID, err)
+		return step.Status
+	}
+	if len(report.Issues) == 0 {
+		return step.Status
+	}
+
+	var summary strings.Builder
+	fmt.Fprintf(&summary, "\n\nThe linters found %d issues:\n", len(report.Issues))
+	for _, issue := range report.Issues {
+		summary.WriteString(issue.String() + "\n")
+	}
+	step.Status = models.WorkflowFailed
+	step.Output += strings.TrimSuffix(summary.String(), "\n")
+	if err := models.WorkflowStepRuns.Update(step); err != nil {
+		log.Printf("Workflows: Failed to update step %s: %v", step.ID, err)
+	}
+	return step.Status
+}
+
+// collectArtifacts keeps the files under the job's artifact paths, which
+// may be globs, up to maxWorkflowArtifactFiles files of
+// maxWorkflowArtifactSize each
+func (w *WorkflowRunner) collectArtifacts(run *models.WorkflowRun, job *models.WorkflowJobRun, def *models.WorkflowJob, dir string) {
+	kept := 0
+	keep := func(file string, info fs.FileInfo) {
+		rel, err := filepath.Rel(dir, file)
+		if err != nil || strings.HasPrefix(rel, "..") || !info.Mode().IsRegular() {
+			return
+		}
+		if kept >= maxWorkflowArtifactFiles || info.Size() > maxWorkflowArtifactSize {
+			log.Printf("Workflows: Skipped artifact %s of run %s, too many or too large", rel, run.ID)
+			return
+		}
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return
+		}
+		artifact := &models.ActionArtifact{
+			Model:       models.DB.NewModel(""),
+			RunID:       run.ID,
+			FileName:    filepath.Base(file),
+			FilePath:    filepath.ToSlash(rel),
+			GroupName:   job.JobID,
+			Version:     1,
+			ContentType: "application/octet-stream",
+			Size:        info.Size(),
+		}
+		if err := models.StoreArtifact(artifact, content); err != nil {
+			log.Printf("Workflows: Failed to save artifact %s: %v", rel, err)
+			return
+		}
+		kept++
+	}
+
+	for _, pattern := range def.Artifacts {
+		matches, _ := filepath.Glob(filepath.Join(dir, filepath.FromSlash(pattern)))
+		for _, match := range matches {
+			// Links could point outside the workspace, so they aren't followed
+			info, err := os.Lstat(match)
+			if err != nil {
+				continue
+			}
+			if !info.IsDir() {
+				keep(match, info)
+				continue
+			}
+			filepath.WalkDir(match, func(file string, entry fs.DirEntry, err error) error {
+				if err != nil || entry.IsDir() {
+					return nil
+				}
+				if info, err := entry.Info(); err == nil {
+					keep(file, info)
+				}
+				return nil
+			})
+		}
+	}
+}